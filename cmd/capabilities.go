@@ -0,0 +1,41 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"github.com/cloudspannerecosystem/harbourbridge/conversion"
+	"github.com/cloudspannerecosystem/harbourbridge/internal"
+)
+
+// Capabilities writes the capabilities of driver (the source types it
+// supports and their Spanner types, the constructs it drops, and flags,
+// the command line flags that apply to it) to out, as JSON.
+func Capabilities(driver, targetDb string, features internal.Features, flags []string, out *os.File) error {
+	c, err := conversion.Capabilities(driver, targetDb, features)
+	if err != nil {
+		return fmt.Errorf("can't get capabilities of driver %s: %w", driver, err)
+	}
+	c.Flags = flags
+	b, err := json.MarshalIndent(c, "", "  ")
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(out, "%s\n", b)
+	return err
+}
@@ -0,0 +1,66 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+
+	"github.com/cloudspannerecosystem/harbourbridge/conversion"
+	"github.com/cloudspannerecosystem/harbourbridge/internal"
+)
+
+// Check reads session file sessionJSON, and checks that the schema of
+// Spanner database db matches the schema the session file says was (or
+// will be) created, e.g. before a data-only run, so that changes made by
+// hand to the database since it was created are caught before rows are
+// written to mismatched tables. It only reads the database. It writes the
+// differences to out, and returns an error if any of them would make
+// writing rows fail (see internal.Conv.TargetDrift).
+func Check(sessionJSON, db string, out *os.File) error {
+	conv := internal.MakeConv()
+	if err := conversion.ReadSessionFile(conv, sessionJSON); err != nil {
+		return fmt.Errorf("can't read session file %s: %w", sessionJSON, err)
+	}
+	client, err := conversion.GetClient(db, conversion.SpannerOptions{})
+	if err != nil {
+		return fmt.Errorf("can't create client for db %s: %w", db, err)
+	}
+	defer client.Close()
+	target, err := conversion.ReadTargetSchema(client)
+	if err != nil {
+		return fmt.Errorf("can't read schema of db %s: %w", db, err)
+	}
+	mismatches, notes := conv.TargetDrift(target)
+	w := bufio.NewWriter(out)
+	defer w.Flush()
+	fmt.Fprintf(w, "Checking the schema of %s against session file %s\n\n", db, sessionJSON)
+	for _, m := range mismatches {
+		fmt.Fprintf(w, "Mismatch: %s.\n", m)
+	}
+	for _, n := range notes {
+		fmt.Fprintf(w, "Note: %s.\n", n)
+	}
+	if len(mismatches) > 0 {
+		w.WriteString("\n")
+		return fmt.Errorf("schema of db %s doesn't match session file %s: %d mismatches", db, sessionJSON, len(mismatches))
+	}
+	if len(notes) > 0 {
+		w.WriteString("\n")
+	}
+	fmt.Fprintf(w, "The schema of %s matches the session file.\n", db)
+	return nil
+}
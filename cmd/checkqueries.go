@@ -0,0 +1,148 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"bufio"
+	"context"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strings"
+
+	sp "cloud.google.com/go/spanner"
+
+	"github.com/cloudspannerecosystem/harbourbridge/conversion"
+	"github.com/cloudspannerecosystem/harbourbridge/internal"
+)
+
+// errPlanOnly rolls back the read-write transactions used to plan DML
+// statements.
+var errPlanOnly = errors.New("plan only")
+
+// CheckQueries reads representative application queries from
+// queriesFile, rewrites the source table and column names they use to
+// their Spanner names (using the mapping recorded in session file
+// sessionJSON), and asks Spanner database db to plan each query, without
+// running it. It writes to out which queries Spanner accepts, and why it
+// rejects the others. queriesFile is either a file of SQL statements
+// separated by semicolons, or (if it ends in .csv) a CSV export of
+// pg_stat_statements with a header row and a query column, such as the
+// output of
+//
+//	\copy (SELECT query FROM pg_stat_statements) TO 'queries.csv' CSV HEADER
+func CheckQueries(sessionJSON, queriesFile, db string, out *os.File) error {
+	conv := internal.MakeConv()
+	if err := conversion.ReadSessionFile(conv, sessionJSON); err != nil {
+		return fmt.Errorf("can't read session file %s: %w", sessionJSON, err)
+	}
+	queries, err := readQueries(queriesFile)
+	if err != nil {
+		return err
+	}
+	client, err := conversion.GetClient(db, conversion.SpannerOptions{})
+	if err != nil {
+		return fmt.Errorf("can't create client for db %s: %w", db, err)
+	}
+	defer client.Close()
+	w := bufio.NewWriter(out)
+	defer w.Flush()
+	fmt.Fprintf(w, "Checking %d queries against %s\n\n", len(queries), db)
+	ok := 0
+	for i, q := range queries {
+		q = internal.RewriteQuery(conv, q)
+		err := planQuery(client, q)
+		if err == nil {
+			ok++
+			fmt.Fprintf(w, "Query %d: OK\n", i+1)
+		} else {
+			fmt.Fprintf(w, "Query %d: rejected by Spanner: %s\n", i+1, sp.ErrDesc(err))
+		}
+		fmt.Fprintf(w, "  %s\n", strings.ReplaceAll(q, "\n", "\n  "))
+		if err != nil {
+			for _, h := range internal.QueryHints(q) {
+				fmt.Fprintf(w, "  Hint: %s.\n", h)
+			}
+		}
+		w.WriteString("\n")
+	}
+	fmt.Fprintf(w, "%d of %d queries are accepted by Spanner.\n", ok, len(queries))
+	return nil
+}
+
+// planQuery asks Spanner for the query plan of q. DML statements are
+// planned in a read-write transaction that is then rolled back.
+func planQuery(client *sp.Client, q string) error {
+	ctx := context.Background()
+	stmt := sp.NewStatement(q)
+	fields := strings.Fields(q)
+	if len(fields) > 0 {
+		switch strings.ToUpper(fields[0]) {
+		case "INSERT", "UPDATE", "DELETE":
+			_, err := client.ReadWriteTransaction(ctx, func(ctx context.Context, txn *sp.ReadWriteTransaction) error {
+				if _, err := txn.AnalyzeQuery(ctx, stmt); err != nil {
+					return err
+				}
+				return errPlanOnly
+			})
+			if errors.Is(err, errPlanOnly) {
+				return nil
+			}
+			return err
+		}
+	}
+	_, err := client.Single().AnalyzeQuery(ctx, stmt)
+	return err
+}
+
+func readQueries(name string) ([]string, error) {
+	if !strings.HasSuffix(strings.ToLower(name), ".csv") {
+		b, err := ioutil.ReadFile(name)
+		if err != nil {
+			return nil, fmt.Errorf("can't read queries file %s: %w", name, err)
+		}
+		return internal.SplitQueries(string(b)), nil
+	}
+	f, err := os.Open(name)
+	if err != nil {
+		return nil, fmt.Errorf("can't read queries file %s: %w", name, err)
+	}
+	defer f.Close()
+	records, err := csv.NewReader(f).ReadAll()
+	if err != nil {
+		return nil, fmt.Errorf("can't parse queries file %s: %w", name, err)
+	}
+	if len(records) == 0 {
+		return nil, nil
+	}
+	col := -1
+	for i, h := range records[0] {
+		if strings.EqualFold(strings.TrimSpace(h), "query") {
+			col = i
+		}
+	}
+	if col < 0 {
+		return nil, fmt.Errorf("queries file %s has no query column", name)
+	}
+	var l []string
+	for _, r := range records[1:] {
+		if col < len(r) && strings.TrimSpace(r[col]) != "" {
+			l = append(l, strings.TrimSpace(r[col]))
+		}
+	}
+	return l, nil
+}
@@ -0,0 +1,39 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/cloudspannerecosystem/harbourbridge/conversion"
+	"github.com/cloudspannerecosystem/harbourbridge/internal"
+)
+
+// Clone clones the Spanner database of a migration, whose schema mapping
+// is read from session file sessionJSON, as configured by cfg (see
+// conversion.Clone).
+func Clone(sessionJSON string, cfg conversion.CloneConfig, out *os.File) error {
+	conv := internal.MakeConv()
+	if err := conversion.ReadSessionFile(conv, sessionJSON); err != nil {
+		return fmt.Errorf("can't read session file %s: %w", sessionJSON, err)
+	}
+	db, err := conversion.Clone(conv, cfg, out)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(out, "Cloned database %s to %s.\n", cfg.Database, db)
+	return nil
+}
@@ -17,17 +17,32 @@ package cmd
 
 import (
 	"fmt"
+	"strings"
 	"time"
 
+	sp "cloud.google.com/go/spanner"
+
 	"github.com/cloudspannerecosystem/harbourbridge/conversion"
 	"github.com/cloudspannerecosystem/harbourbridge/internal"
+	"github.com/cloudspannerecosystem/harbourbridge/spanner"
 )
 
 var (
-	badDataFile = "dropped.txt"
-	reportFile  = "report.txt"
-	schemaFile  = "schema.txt"
-	sessionFile = "session.json"
+	anomaliesFile        = "anomalies.txt"
+	sourceFixesFile      = "fixes.sql"
+	badDataFile          = "dropped.txt"
+	reportFile           = "report.txt"
+	structuredReportFile = "report.json"
+	lineageFile          = "lineage.json"
+	schemaFile           = "schema.txt"
+	schemaDirectory      = "schema"
+	manifestDirectory    = "manifests"
+	sessionFile          = "session.json"
+	sarifFile            = "issues.sarif"
+	pgAdapterFile        = "pgadapter.docker-compose.yaml"
+	modelsFile           = "models"
+	diagramFile          = "schema"
+	sourceDiagramFile    = "source"
 )
 
 // CommandLine provides the core processing for HarbourBridge when run as a command-line tool.
@@ -36,57 +51,364 @@ var (
 // 2. Create database (if schemaOnly is set to false)
 // 3. Run data conversion (if schemaOnly is set to false)
 // 4. Generate report
-func CommandLine(driver, targetDb, projectID, instanceID, dbName string, dataOnly, schemaOnly, skipForeignKeys bool, schemaSampleSize int64, sessionJSON string, ioHelper *conversion.IOStreams, outputFilePrefix string, now time.Time) error {
+// For PostgreSQL-dialect targets, it also writes a docker-compose file
+// that runs PGAdapter for the new database.
+// Data conversion policies are always taken from 'policies' (rather than
+// the session file), so they can be changed for data-only runs.
+// spannerOpts configures the Spanner client used for data conversion, and
+// source how live source databases are read. If source stages data in
+// Cloud Storage, the bucket is created in the region of the instance, and
+// the extract step stops once data is staged (see conversion.Staging).
+// If scanAnomalies is set, the (live) source database is scanned for data
+// that will cause conversion problems before any data is loaded. If
+// sourceFixes is set, statements that fix issues of the (live) source
+// database before migrating are written to fixes.sql (see
+// conversion.WriteSourceFixes); it is ignored for data-only runs. If
+// stringFactor is positive, the data of the (live) source database is
+// profiled to give columns converted to STRING(MAX) the length of their
+// longest value multiplied by stringFactor (see
+// conversion.TightenStrings); it is ignored for data-only runs. If
+// profileRows is positive, the columns of the (live) source database are
+// profiled from a sample of up to profileRows rows per table, for the
+// report (see conversion.ProfileColumns); it is also ignored for
+// data-only runs. If longStrings is set, STRING(n) columns too short for
+// the values of the (live) source database are widened as it specifies
+// (see conversion.FitStrings); it is also ignored for data-only runs.
+// ddlComments controls the comments of the schema file (see
+// conversion.WriteSchemaFile).
+// dropColumns lists source columns (as table.column) that are not migrated,
+// in addition to those recorded in the session file, and computedCols
+// defines new Spanner columns computed during data conversion. remodel
+// specifies tables to split and merge (remodel.AutoPartition is ignored
+// for data-only runs). ordering specifies the order of tables and columns
+// in the generated schema and report files. If schemaDir is set, the
+// schema is also written as one file per table, index and foreign key.
+// fkNameTemplate specifies how foreign keys are named (see
+// internal.NameForeignKeys); it is ignored for data-only runs, since
+// names are recorded in the session file. The Spanner schema only uses
+// the features of the target that are in features (nil means all). For
+// each language of models, models of the Spanner schema are written in
+// that language (see internal.GenerateModels), and diagrams lists the
+// formats of the schema diagrams to write. reportLayout specifies how the
+// report is written. Overrides of the default conversion, DDL statements
+// and data conversion runs are recorded in audit (if it isn't nil). If
+// metadataTable is set, the state of the run is also recorded in the new
+// database (see conversion.MetadataTable). The completion of schema
+// conversion, data conversion and row count verification is notified to
+// notifier (if it isn't nil). Generated files are written to workspace.
+// The returned summary gives the outcome of the run (see
+// internal.RunSummary).
+func CommandLine(driver, targetDb string, features internal.Features, projectID, instanceID, dbName string, dataOnly, schemaOnly, skipForeignKeys, scanAnomalies, sourceFixes, schemaDir, metadataTable bool, schemaSampleSize int64, stringFactor float64, profileRows, seed int64, longStrings, ddlComments, sessionJSON, fkNameTemplate string, dropColumns []string, computedCols []internal.ComputedCol, remodel internal.Remodel, policies internal.Policies, ordering internal.Ordering, models []internal.ModelLanguage, diagrams internal.Diagrams, reportLayout conversion.ReportLayout, spannerOpts conversion.SpannerOptions, source conversion.SourceOptions, audit *conversion.AuditLog, notifier *conversion.Notifier, ioHelper *conversion.IOStreams, workspace conversion.Workspace, now time.Time) (internal.RunSummary, error) {
 	var conv *internal.Conv
 	var err error
+	db := fmt.Sprintf("projects/%s/instances/%s/databases/%s", projectID, instanceID, dbName)
+	audit.Run(driver, db)
+	settings := conversion.SchemaSettings{Seed: seed, DropColumns: dropColumns, ComputedCols: computedCols, Remodel: remodel, Policies: policies, Ordering: ordering, ForeignKeyNames: fkNameTemplate}
 	if !dataOnly {
-		conv, err = conversion.SchemaConv(driver, targetDb, ioHelper, schemaSampleSize)
+		conv, err = conversion.SchemaConv(driver, targetDb, features, ioHelper, schemaSampleSize, source)
 		if err != nil {
-			return err
+			return internal.RunSummary{}, err
 		}
 		if ioHelper.SeekableIn != nil {
 			defer ioHelper.In.Close()
 		}
+		if err := conversion.PrepareSchema(conv, settings, false); err != nil {
+			return internal.RunSummary{}, err
+		}
+		if stringFactor > 0 {
+			if err := conversion.TightenStrings(driver, conv, stringFactor, ioHelper.Out); err != nil {
+				return internal.RunSummary{}, err
+			}
+		}
+		if longStrings != "" {
+			if err := conversion.FitStrings(driver, conv, longStrings, ioHelper.Out); err != nil {
+				return internal.RunSummary{}, err
+			}
+		}
+		if profileRows > 0 {
+			if err := conversion.ProfileColumns(driver, conv, profileRows, ioHelper.Out); err != nil {
+				return internal.RunSummary{}, err
+			}
+		}
+
+		audit.Overrides(conv, "")
 
-		conversion.WriteSchemaFile(conv, now, outputFilePrefix+schemaFile, ioHelper.Out)
-		conversion.WriteSessionFile(conv, outputFilePrefix+sessionFile, ioHelper.Out)
+		conversion.WriteSchemaFile(conv, now, workspace.File(conversion.DDLFiles, schemaFile), ddlComments, ioHelper.Out)
+		if reportLayout.SARIF {
+			conversion.WriteSARIF(conv, workspace.File(conversion.DDLFiles, schemaFile), workspace.File(conversion.DDLFiles, sarifFile), ioHelper.Out)
+		}
+		if schemaDir {
+			conversion.WriteSchemaDir(conv, workspace.File(conversion.DDLFiles, schemaDirectory), ioHelper.Out)
+		}
+		for _, l := range models {
+			conversion.WriteModels(conv, l, workspace.File(conversion.DDLFiles, modelsFile+"."+l.Extension()), ioHelper.Out)
+		}
+		for _, f := range diagrams.Formats {
+			conversion.WriteDiagram(conv, f, false, workspace.File(conversion.DDLFiles, diagramFile+"."+f.Extension()), ioHelper.Out)
+			if diagrams.Source {
+				conversion.WriteDiagram(conv, f, true, workspace.File(conversion.DDLFiles, sourceDiagramFile+"."+f.Extension()), ioHelper.Out)
+			}
+		}
+		conversion.WriteSessionFile(conv, workspace.File(conversion.SessionFiles, sessionFile), ioHelper.Out)
+		var anomalies []internal.Anomaly
+		if scanAnomalies {
+			if anomalies, err = conversion.ScanAnomalies(driver, conv, workspace.File(conversion.ReportFiles, anomaliesFile), ioHelper.Out); err != nil {
+				return internal.RunSummary{}, err
+			}
+		}
+		if sourceFixes {
+			if err := conversion.WriteSourceFixes(driver, conv, anomalies, workspace.File(conversion.ReportFiles, sourceFixesFile), ioHelper.Out); err != nil {
+				return internal.RunSummary{}, err
+			}
+		}
+		summary := internal.Summarize(conv, nil)
+		notifier.Notify(conversion.SchemaComplete, &summary, "")
 		if schemaOnly {
-			conversion.Report(driver, nil, ioHelper.BytesRead, "", conv, outputFilePrefix+reportFile, ioHelper.Out)
-			return nil
+			conversion.Report(driver, nil, ioHelper.BytesRead, "", conv, workspace.File(conversion.ReportFiles, reportFile), reportLayout, ioHelper.Out)
+			conversion.WriteStructuredReport(driver, nil, conv, workspace.File(conversion.ReportFiles, structuredReportFile), ioHelper.Out)
+			conversion.WriteLineage(driver, conv, workspace.File(conversion.ReportFiles, lineageFile), ioHelper.Out)
+			if reportLayout.Manifests {
+				conversion.WriteManifests(driver, conv, nil, workspace.File(conversion.ReportFiles, manifestDirectory), now, ioHelper.Out)
+			}
+			return summary, nil
 		}
 	} else {
 		conv = internal.MakeConv()
 		err = conversion.ReadSessionFile(conv, sessionJSON)
 		if err != nil {
-			return err
+			return internal.RunSummary{}, err
+		}
+		if err := conversion.PrepareSchema(conv, settings, true); err != nil {
+			return internal.RunSummary{}, err
+		}
+		audit.Overrides(conv, sessionJSON)
+		if scanAnomalies {
+			if _, err := conversion.ScanAnomalies(driver, conv, workspace.File(conversion.ReportFiles, anomaliesFile), ioHelper.Out); err != nil {
+				return internal.RunSummary{}, err
+			}
+		}
+	}
+
+	restore, err := conversion.ScaleInstance(projectID, instanceID, spannerOpts, ioHelper.Out)
+	if err != nil {
+		return internal.RunSummary{}, err
+	}
+	defer restore()
+
+	// The instance config is informational: don't fail if we can't get it.
+	instanceConfig, err := conversion.GetInstanceConfig(projectID, instanceID)
+	if err != nil {
+		fmt.Fprintf(ioHelper.Out, "Can't get configuration of instance %s: %v\n", instanceID, err)
+	} else {
+		for _, w := range instanceConfig.Warnings() {
+			fmt.Fprintf(ioHelper.Out, "Warning: %s\n", w)
 		}
+		// Data is staged in the region of the instance.
+		source.Staging.Region = instanceConfig.Region()
 	}
+	source.Staging.Project = projectID
 
-	db, err := conversion.CreateDatabase(projectID, instanceID, dbName, conv, ioHelper.Out)
+	db, err = conversion.PrepareDatabase(projectID, instanceID, dbName, conv, spannerOpts, ioHelper.Out)
 	if err != nil {
 		fmt.Printf("\nCan't create database: %v\n", err)
-		return fmt.Errorf("can't create database")
+		return internal.RunSummary{}, fmt.Errorf("can't create database")
+	}
+	audit.SchemaDDL(conv, db)
+	if err := conversion.GrantDatabaseRoles(projectID, instanceID, dbName, spannerOpts.Grants, ioHelper.Out); err != nil {
+		fmt.Printf("\nCan't grant access to database: %v\n", err)
+		return internal.RunSummary{}, fmt.Errorf("can't grant access to database")
 	}
+	audit.Grants(db, spannerOpts.Grants)
 
-	client, err := conversion.GetClient(db)
+	conversion.WritePGAdapterCompose(conv, projectID, instanceID, dbName, workspace.File(conversion.DDLFiles, pgAdapterFile), ioHelper.Out)
+
+	client, err := conversion.GetClient(db, spannerOpts)
 	if err != nil {
 		fmt.Printf("\nCan't create client for db %s: %v\n", db, err)
-		return fmt.Errorf("can't create Spanner client")
+		return internal.RunSummary{}, fmt.Errorf("can't create Spanner client")
 	}
 
-	bw, err := conversion.DataConv(driver, ioHelper, client, conv, dataOnly)
+	if notifier != nil {
+		conv.SetProgressSink(notifier.Event)
+	}
+	var metadata *conversion.MigrationMetadata
+	// Checkpoints are recorded in the metadata table, and sent as
+	// progress events.
+	checkpoint := func(status string) {
+		metadata.Checkpoint(status)
+		conv.Checkpoint(status)
+	}
+	if metadataTable {
+		if err := conversion.CreateMetadataTable(projectID, instanceID, dbName); err != nil {
+			return internal.RunSummary{}, err
+		}
+		audit.DDL(db, "metadata table created", []string{conversion.MetadataTableDDL})
+		metadata = conversion.NewMigrationMetadata(client, now, ioHelper.Out)
+		metadata.TablesCreated(conv)
+	}
+	checkpoint("schema created")
+	if len(source.Incremental) > 0 {
+		if err := conversion.CreateWatermarkTable(projectID, instanceID, dbName); err != nil {
+			return internal.RunSummary{}, err
+		}
+		audit.DDL(db, "watermark table created", []string{conversion.WatermarkTableDDL})
+	}
+
+	stopTUI := func() {}
+	if ioHelper.TUI {
+		if stopTUI, err = startTUI(conv, &spannerOpts, notifier, ioHelper); err != nil {
+			return internal.RunSummary{}, err
+		}
+	}
+	dataStart := time.Now()
+	checkpoint("loading data")
+	bw, err := conversion.DataConv(driver, ioHelper, client, conv, dataOnly, source, spannerOpts)
+	conv.FinishTables()
+	stopTUI()
 	if err != nil {
 		fmt.Printf("\nCan't finish data conversion for db %s: %v\n", db, err)
-		return fmt.Errorf("can't finish data conversion")
+		checkpoint("data conversion failed")
+		return internal.RunSummary{}, fmt.Errorf("can't finish data conversion")
+	}
+	if err := abortData(driver, db, conv, bw, reportLayout, ioHelper, workspace, now); err != nil {
+		checkpoint("data conversion aborted")
+		return internal.RunSummary{}, err
+	}
+	if source.Staging.Step == conversion.StageExtract {
+		checkpoint("data staged")
+		return stagedData(driver, db, conv, bw, reportLayout, source.Staging, audit, ioHelper, workspace, dataStart, now), nil
+	}
+	audit.Data(conv, db, dataStart, bw.DroppedRowsByTable())
+	loadedAt, err := conversion.LoadTimestamp(client)
+	if err != nil {
+		// Rows are then counted at the current time.
+		fmt.Fprintf(ioHelper.Out, "Can't get the load timestamp: %v\n", err)
+	}
+	if err := conversion.WriteWatermarks(client, conv, bw.DroppedRowsByTable(), ioHelper.Out); err != nil {
+		// The next load reads the rows of this one again.
+		fmt.Fprintf(ioHelper.Out, "Can't record watermarks: %v\n", err)
 	}
+	if len(conv.Snapshots) > 0 {
+		// Record the source positions the data was read at, for CDC.
+		conversion.WriteSessionFile(conv, workspace.File(conversion.SessionFiles, sessionFile), ioHelper.Out)
+	}
+	summary := internal.Summarize(conv, bw.DroppedRowsByTable())
+	notifier.Notify(conversion.DataComplete, &summary, "")
+	var checks []conversion.RowCountCheck
+	if metadataTable || spannerOpts.BackupBeforeCutover || notifier != nil || reportLayout.Manifests {
+		checks = conversion.VerifyRowCounts(client, conv, bw.DroppedRowsByTable(), loadedAt)
+		var verified int
+		for _, c := range checks {
+			if c.Status == "verified" {
+				verified++
+			}
+		}
+		detail := fmt.Sprintf("row counts of %d of %d table(s) verified", verified, len(checks))
+		if failed := conversion.FailedRowCountChecks(checks); len(failed) > 0 {
+			detail += fmt.Sprintf(", row counts of tables %s can't be verified", strings.Join(failed, ", "))
+		}
+		notifier.Notify(conversion.VerificationComplete, nil, detail)
+	}
+	metadata.TablesLoaded(checks)
+	checkpoint("data loaded")
 	if !skipForeignKeys {
 		if err = conversion.UpdateDDLForeignKeys(projectID, instanceID, dbName, conv, ioHelper.Out); err != nil {
 			fmt.Printf("\nCan't perform update operation on db %s with foreign keys: %v\n", db, err)
-			return fmt.Errorf("can't perform update schema with foreign keys")
+			return internal.RunSummary{}, fmt.Errorf("can't perform update schema with foreign keys")
 		}
+		audit.ForeignKeyDDL(conv, db)
+		checkpoint("foreign keys added")
+	}
+	optimizer, ready := optimizeDatabase(projectID, instanceID, dbName, db, client, spannerOpts, audit, notifier, ioHelper)
+	if ready {
+		checkpoint("optimizer statistics ready")
 	}
 	banner := conversion.GetBanner(now, db)
-	conversion.Report(driver, bw.DroppedRowsByTable(), ioHelper.BytesRead, banner, conv, outputFilePrefix+reportFile, ioHelper.Out)
-	conversion.WriteBadData(bw, conv, banner, outputFilePrefix+badDataFile, ioHelper.Out)
-	return nil
+	if instanceConfig != nil {
+		banner += instanceConfig.Summary()
+	}
+	banner += optimizer
+	if spannerOpts.BackupBeforeCutover {
+		if failed := conversion.FailedRowCountChecks(checks); len(failed) > 0 {
+			fmt.Fprintf(ioHelper.Out, "Not backing up database %s: row counts of tables %s can't be verified\n", dbName, strings.Join(failed, ", "))
+			banner += "No backup before cutover: row count verification failed\n\n"
+		} else {
+			backupID := fmt.Sprintf("%s-cutover-%s", dbName, time.Now().UTC().Format("20060102-150405"))
+			// The migration itself succeeded: still write the report.
+			if backup, err := conversion.BackupDatabase(projectID, instanceID, dbName, backupID, spannerOpts.BackupRetention, ioHelper.Out); err != nil {
+				fmt.Fprintf(ioHelper.Out, "\nCan't back up database %s: %v\n", dbName, err)
+				banner += fmt.Sprintf("No backup before cutover: %v\n\n", err)
+			} else {
+				audit.Backup(db, backup)
+				checkpoint("backed up to " + backup)
+				banner += fmt.Sprintf("Backup before cutover: %s (expires %s)\n\n", backup, time.Now().Add(spannerOpts.BackupRetention).Format("2006-01-02 15:04:05"))
+			}
+		}
+	}
+	conversion.Report(driver, bw.DroppedRowsByTable(), ioHelper.BytesRead, banner, conv, workspace.File(conversion.ReportFiles, reportFile), reportLayout, ioHelper.Out)
+	conversion.WriteStructuredReport(driver, bw.DroppedRowsByTable(), conv, workspace.File(conversion.ReportFiles, structuredReportFile), ioHelper.Out)
+	conversion.WriteLineage(driver, conv, workspace.File(conversion.ReportFiles, lineageFile), ioHelper.Out)
+	conversion.WriteBadData(bw, conv, banner, workspace.File(conversion.BadRowFiles, badDataFile), ioHelper.Out)
+	if reportLayout.Manifests {
+		conversion.WriteManifests(driver, conv, checks, workspace.File(conversion.ReportFiles, manifestDirectory), now, ioHelper.Out)
+	}
+	checkpoint("done")
+	return summary, nil
+}
+
+// optimizeDatabase prepares the query optimizer of database dbName for
+// cutover (see conversion.OptimizeDatabase). It returns the lines of the
+// report banner describing the optimizer, and whether new statistics
+// are ready. Failures are reported, but don't fail the run: data is
+// loaded, and statistics can be constructed before cutover.
+func optimizeDatabase(projectID, instanceID, dbName, db string, client *sp.Client, spannerOpts conversion.SpannerOptions, audit *conversion.AuditLog, notifier *conversion.Notifier, ioHelper *conversion.IOStreams) (string, bool) {
+	stmts, status, err := conversion.OptimizeDatabase(projectID, instanceID, dbName, client, spannerOpts, ioHelper.Out)
+	audit.DDL(db, "optimizer prepared for cutover", stmts)
+	banner := status.Summary()
+	if err != nil {
+		fmt.Fprintf(ioHelper.Out, "\n%v\n", err)
+		banner += fmt.Sprintf("Optimizer not prepared for cutover: %v\n", err)
+	} else if status.Package != "" {
+		notifier.Notify(conversion.StatisticsReady, nil, fmt.Sprintf("statistics package %s constructed in %s", status.Package, status.Took.Round(time.Second)))
+	}
+	if banner != "" {
+		banner += "\n"
+	}
+	return banner, err == nil && status.Package != ""
+}
+
+// abortData handles data conversions aborted because they exceeded their
+// error budget (see internal.ErrorBudget): it prints the leading causes of
+// bad rows, and writes the report and the bad data file, so that they can
+// be investigated. It returns the error to fail with, or nil if data
+// conversion wasn't aborted.
+func abortData(driver, db string, conv *internal.Conv, bw *spanner.BatchWriter, reportLayout conversion.ReportLayout, ioHelper *conversion.IOStreams, workspace conversion.Workspace, now time.Time) error {
+	err := conv.Aborted()
+	if err == nil {
+		return nil
+	}
+	fmt.Fprintf(ioHelper.Out, "\nData conversion aborted: %v\nLeading causes of bad rows:\n", err)
+	for _, l := range conv.LeadingErrors(5) {
+		fmt.Fprintf(ioHelper.Out, "  %s\n", l)
+	}
+	banner := conversion.GetBanner(now, db) + fmt.Sprintf("Data conversion aborted: %v\n\n", err)
+	conversion.Report(driver, bw.DroppedRowsByTable(), ioHelper.BytesRead, banner, conv, workspace.File(conversion.ReportFiles, reportFile), reportLayout, ioHelper.Out)
+	conversion.WriteBadData(bw, conv, banner, workspace.File(conversion.BadRowFiles, badDataFile), ioHelper.Out)
+	return err
+}
+
+// stagedData completes the extract step of staged data conversions (see
+// conversion.Staging): data is loaded by a later data-only run with the
+// load step, which verifies row counts and adds foreign keys. The report
+// and bad data file describe the conversion of the staged data.
+func stagedData(driver, db string, conv *internal.Conv, bw *spanner.BatchWriter, reportLayout conversion.ReportLayout, staging conversion.Staging, audit *conversion.AuditLog, ioHelper *conversion.IOStreams, workspace conversion.Workspace, dataStart, now time.Time) internal.RunSummary {
+	fmt.Fprintf(ioHelper.Out, "Data staged in %s: load it with -data-only -staging-step %s\n", staging.URI, conversion.StageLoad)
+	audit.Data(conv, db, dataStart, nil)
+	if len(conv.Snapshots) > 0 {
+		conversion.WriteSessionFile(conv, workspace.File(conversion.SessionFiles, sessionFile), ioHelper.Out)
+	}
+	banner := conversion.GetBanner(now, db) + fmt.Sprintf("Data staged in %s, not loaded\n\n", staging.URI)
+	conversion.Report(driver, nil, ioHelper.BytesRead, banner, conv, workspace.File(conversion.ReportFiles, reportFile), reportLayout, ioHelper.Out)
+	conversion.WriteBadData(bw, conv, banner, workspace.File(conversion.BadRowFiles, badDataFile), ioHelper.Out)
+	return internal.Summarize(conv, nil)
 }
@@ -0,0 +1,32 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/cloudspannerecosystem/harbourbridge/conversion"
+)
+
+func TestOptimizeDatabase_Unchanged(t *testing.T) {
+	// Without -analyze and -optimizer-version, the banner is unchanged,
+	// and no checkpoint is recorded.
+	banner, ready := optimizeDatabase("p", "i", "db", "projects/p/instances/i/databases/db", nil, conversion.SpannerOptions{}, nil, nil, &conversion.IOStreams{Out: os.Stdout})
+	assert.Equal(t, "", banner)
+	assert.False(t, ready)
+}
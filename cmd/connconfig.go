@@ -0,0 +1,41 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"github.com/cloudspannerecosystem/harbourbridge/conversion"
+	"github.com/cloudspannerecosystem/harbourbridge/internal"
+)
+
+// ConnectionConfig reads the session file of a migration to Spanner
+// database dbName, and writes the connection configuration of the database
+// (see conversion.ConnectionConfig) to out as JSON. Application teams can
+// use it to switch their configuration to Spanner at cutover.
+func ConnectionConfig(sessionJSON, project, instance, dbName string, out *os.File) error {
+	conv := internal.MakeConv()
+	if err := conversion.ReadSessionFile(conv, sessionJSON); err != nil {
+		return fmt.Errorf("can't read session file %s: %w", sessionJSON, err)
+	}
+	b, err := json.MarshalIndent(conversion.BuildConnectionConfig(conv, project, instance, dbName), "", " ")
+	if err != nil {
+		return fmt.Errorf("can't encode connection configuration to JSON: %w", err)
+	}
+	fmt.Fprintf(out, "%s\n", b)
+	return nil
+}
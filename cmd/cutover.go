@@ -0,0 +1,72 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+
+	"github.com/cloudspannerecosystem/harbourbridge/conversion"
+	"github.com/cloudspannerecosystem/harbourbridge/internal"
+)
+
+// Cutover runs the cutover steps of cfg (see conversion.Cutover) for the
+// source database of driver and Spanner database cfg.Database, whose
+// schema mapping is read from session file sessionJSON. Recent rows are
+// checked as by Validate. The cutover report is written as JSON to file
+// reportFile, and the outcome of each step to out.
+func Cutover(sessionJSON, driver string, rows int, cfg conversion.CutoverConfig, reportFile string, out *os.File) error {
+	conv := internal.MakeConv()
+	if err := conversion.ReadSessionFile(conv, sessionJSON); err != nil {
+		return fmt.Errorf("can't read session file %s: %w", sessionJSON, err)
+	}
+	client, err := conversion.GetClient(cfg.Database, conversion.SpannerOptions{})
+	if err != nil {
+		return fmt.Errorf("can't create client for db %s: %w", cfg.Database, err)
+	}
+	defer client.Close()
+	v, err := conversion.NewValidator(driver, conv, client, rows)
+	if err != nil {
+		return err
+	}
+	defer v.Close()
+	r, cutoverErr := conversion.Cutover(cfg, v)
+	printSteps(r.Steps, out)
+	b, err := json.MarshalIndent(r, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := ioutil.WriteFile(reportFile, append(b, '\n'), 0644); err != nil {
+		return fmt.Errorf("can't write cutover report %s: %w", reportFile, err)
+	}
+	fmt.Fprintf(out, "Wrote cutover report to file '%s'.\n", reportFile)
+	return cutoverErr
+}
+
+// printSteps writes the outcome of each step to out.
+func printSteps(steps []conversion.CutoverStep, out *os.File) {
+	for _, s := range steps {
+		switch {
+		case s.Skipped:
+			fmt.Fprintf(out, "%-16s skipped\n", s.Name)
+		case s.Error != "":
+			fmt.Fprintf(out, "%-16s failed after %.1fs: %s\n", s.Name, s.DurationSeconds, s.Error)
+		default:
+			fmt.Fprintf(out, "%-16s done in %.1fs %s\n", s.Name, s.DurationSeconds, s.Detail)
+		}
+	}
+}
@@ -0,0 +1,229 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/cloudspannerecosystem/harbourbridge/conversion"
+	"github.com/cloudspannerecosystem/harbourbridge/internal"
+)
+
+// RecordAssessment records the assess phase (see conversion.AssessPhase),
+// run by CommandLine in schema-only mode from start: its session file in
+// workspace is copied to store, for the later phases.
+func RecordAssessment(store conversion.StateStore, summary internal.RunSummary, workspace conversion.Workspace, start time.Time) error {
+	if err := conversion.WriteStateSession(store, workspace.File(conversion.SessionFiles, sessionFile)); err != nil {
+		return fmt.Errorf("can't record session: %w", err)
+	}
+	return conversion.WritePhase(store, conversion.PhaseRecord{Phase: conversion.AssessPhase, Start: start, End: time.Now(), Summary: &summary})
+}
+
+// EmitK8sJobs writes to file name the manifests of Kubernetes Jobs that
+// load the data of the tables of the session recorded in store by the
+// assess phase, split into at most shards shards (see
+// conversion.WriteK8sJobs).
+func EmitK8sJobs(store conversion.StateStore, cfg conversion.K8sJobConfig, shards int, name string, out *os.File) error {
+	conv := internal.MakeConv()
+	if err := conversion.ReadStateSession(store, conv); err != nil {
+		return err
+	}
+	return conversion.WriteK8sJobs(cfg, conv.ShardTables(shards), name, out)
+}
+
+// Phase runs phase (conversion.SchemaPhase, DataPhase or VerifyPhase) of
+// the migration of the source database of driver to Spanner database
+// dbName, as a short-lived invocation coordinated with the other phases
+// through store, so that the migration can be driven by a workflow
+// scheduler. The schema is read from the session recorded by the assess
+// phase (see RecordAssessment). The data phase loads the source tables in
+// tables (all tables if it is empty), so that batches of tables can be
+// loaded by parallel invocations; the verify phase checks the row counts
+// of all tables, and then adds foreign keys unless skipForeignKeys is
+// set. The other arguments are as for CommandLine.
+func Phase(phase string, store conversion.StateStore, driver, projectID, instanceID, dbName string, tables []string, skipForeignKeys bool, dropColumns []string, computedCols []internal.ComputedCol, remodel internal.Remodel, policies internal.Policies, ordering internal.Ordering, reportLayout conversion.ReportLayout, spannerOpts conversion.SpannerOptions, source conversion.SourceOptions, audit *conversion.AuditLog, notifier *conversion.Notifier, ioHelper *conversion.IOStreams, workspace conversion.Workspace, now time.Time) (internal.RunSummary, error) {
+	if err := conversion.CheckPhase(store, phase); err != nil {
+		return internal.RunSummary{}, err
+	}
+	conv := internal.MakeConv()
+	if err := conversion.ReadStateSession(store, conv); err != nil {
+		return internal.RunSummary{}, err
+	}
+	// Phases use the seed recorded by the assess phase.
+	conv.SetSeed(0)
+	db := fmt.Sprintf("projects/%s/instances/%s/databases/%s", projectID, instanceID, dbName)
+	audit.Run(driver, db)
+	record := conversion.PhaseRecord{Phase: phase, Start: now}
+	var summary internal.RunSummary
+	var err error
+	switch phase {
+	case conversion.SchemaPhase:
+		summary, err = schemaPhase(conv, projectID, instanceID, dbName, spannerOpts, audit, ioHelper, workspace)
+	case conversion.DataPhase:
+		settings := conversion.SchemaSettings{DropColumns: dropColumns, ComputedCols: computedCols, Remodel: remodel, Policies: policies, Ordering: ordering}
+		if err := conversion.PrepareSchema(conv, settings, true); err != nil {
+			return internal.RunSummary{}, err
+		}
+		record.Tables, summary, err = dataPhase(conv, store, driver, projectID, instanceID, dbName, tables, spannerOpts, source, audit, notifier, ioHelper, workspace, now)
+	case conversion.VerifyPhase:
+		summary, err = verifyPhase(conv, store, driver, projectID, instanceID, dbName, skipForeignKeys, reportLayout, spannerOpts, audit, notifier, ioHelper, workspace, now)
+	default:
+		return internal.RunSummary{}, fmt.Errorf("phase %s can't be run on its own", phase)
+	}
+	if err != nil {
+		return internal.RunSummary{}, err
+	}
+	record.End = time.Now()
+	record.Summary = &summary
+	if err := conversion.WritePhase(store, record); err != nil {
+		return internal.RunSummary{}, fmt.Errorf("can't record phase %s: %w", phase, err)
+	}
+	fmt.Fprintf(ioHelper.Out, "Phase %s completed.\n", phase)
+	return summary, nil
+}
+
+// schemaPhase creates the Spanner database and its tables.
+func schemaPhase(conv *internal.Conv, projectID, instanceID, dbName string, spannerOpts conversion.SpannerOptions, audit *conversion.AuditLog, ioHelper *conversion.IOStreams, workspace conversion.Workspace) (internal.RunSummary, error) {
+	db, err := conversion.PrepareDatabase(projectID, instanceID, dbName, conv, spannerOpts, ioHelper.Out)
+	if err != nil {
+		return internal.RunSummary{}, fmt.Errorf("can't create database: %w", err)
+	}
+	audit.SchemaDDL(conv, db)
+	if err := conversion.GrantDatabaseRoles(projectID, instanceID, dbName, spannerOpts.Grants, ioHelper.Out); err != nil {
+		return internal.RunSummary{}, fmt.Errorf("can't grant access to database: %w", err)
+	}
+	audit.Grants(db, spannerOpts.Grants)
+	conversion.WritePGAdapterCompose(conv, projectID, instanceID, dbName, workspace.File(conversion.DDLFiles, pgAdapterFile), ioHelper.Out)
+	return internal.Summarize(conv, nil), nil
+}
+
+// dataPhase loads the data of tables (all tables if it is empty), and
+// returns the tables loaded.
+func dataPhase(conv *internal.Conv, store conversion.StateStore, driver, projectID, instanceID, dbName string, tables []string, spannerOpts conversion.SpannerOptions, source conversion.SourceOptions, audit *conversion.AuditLog, notifier *conversion.Notifier, ioHelper *conversion.IOStreams, workspace conversion.Workspace, now time.Time) ([]string, internal.RunSummary, error) {
+	if err := conv.SetDataTables(tables); err != nil {
+		return nil, internal.RunSummary{}, err
+	}
+	if len(tables) == 0 {
+		tables = conv.SrcTables()
+	}
+	// Loading a table twice would fail on the rows already written.
+	var loaded []string
+	for _, t := range tables {
+		l, err := conversion.ReadTableLoad(store, t)
+		if err != nil {
+			return nil, internal.RunSummary{}, err
+		}
+		if l != nil {
+			loaded = append(loaded, t)
+		}
+	}
+	if len(loaded) > 0 {
+		return nil, internal.RunSummary{}, fmt.Errorf("data of tables %s is already loaded", strings.Join(loaded, ", "))
+	}
+	restore, err := conversion.ScaleInstance(projectID, instanceID, spannerOpts, ioHelper.Out)
+	if err != nil {
+		return nil, internal.RunSummary{}, err
+	}
+	defer restore()
+	db := fmt.Sprintf("projects/%s/instances/%s/databases/%s", projectID, instanceID, dbName)
+	client, err := conversion.GetClient(db, spannerOpts)
+	if err != nil {
+		return nil, internal.RunSummary{}, fmt.Errorf("can't create client for db %s: %w", db, err)
+	}
+	defer client.Close()
+	stopTUI := func() {}
+	if ioHelper.TUI {
+		if stopTUI, err = startTUI(conv, &spannerOpts, notifier, ioHelper); err != nil {
+			return nil, internal.RunSummary{}, err
+		}
+	} else if notifier != nil {
+		conv.SetProgressSink(notifier.Event)
+	}
+	dataStart := time.Now()
+	bw, err := conversion.DataConv(driver, ioHelper, client, conv, true, source, spannerOpts)
+	conv.FinishTables()
+	stopTUI()
+	if err != nil {
+		return nil, internal.RunSummary{}, fmt.Errorf("can't finish data conversion for db %s: %w", db, err)
+	}
+	if err := abortData(driver, db, conv, bw, conversion.ReportLayout{}, ioHelper, workspace, now); err != nil {
+		return nil, internal.RunSummary{}, err
+	}
+	audit.Data(conv, db, dataStart, bw.DroppedRowsByTable())
+	loadedAt, err := conversion.LoadTimestamp(client)
+	if err != nil {
+		// The verify phase then counts rows at the current time.
+		fmt.Fprintf(ioHelper.Out, "Can't get the load timestamp: %v\n", err)
+	}
+	if err := conversion.WriteTableLoads(store, conv, tables, bw.DroppedRowsByTable(), time.Now(), loadedAt); err != nil {
+		return nil, internal.RunSummary{}, fmt.Errorf("can't record loaded tables: %w", err)
+	}
+	summary := internal.Summarize(conv, bw.DroppedRowsByTable())
+	notifier.Notify(conversion.DataComplete, &summary, fmt.Sprintf("data of %d table(s) loaded", len(tables)))
+	conversion.WriteBadData(bw, conv, conversion.GetBanner(now, db), workspace.File(conversion.BadRowFiles, badDataFile), ioHelper.Out)
+	return tables, summary, nil
+}
+
+// verifyPhase checks the row counts of all tables against the rows
+// loaded by data phases, adds foreign keys unless skipForeignKeys is set,
+// prepares the query optimizer for cutover, and writes the report.
+func verifyPhase(conv *internal.Conv, store conversion.StateStore, driver, projectID, instanceID, dbName string, skipForeignKeys bool, reportLayout conversion.ReportLayout, spannerOpts conversion.SpannerOptions, audit *conversion.AuditLog, notifier *conversion.Notifier, ioHelper *conversion.IOStreams, workspace conversion.Workspace, now time.Time) (internal.RunSummary, error) {
+	badWrites, loadedAt, err := conversion.LoadTableStats(store, conv)
+	if err != nil {
+		return internal.RunSummary{}, err
+	}
+	db := fmt.Sprintf("projects/%s/instances/%s/databases/%s", projectID, instanceID, dbName)
+	client, err := conversion.GetClient(db, conversion.SpannerOptions{})
+	if err != nil {
+		return internal.RunSummary{}, fmt.Errorf("can't create client for db %s: %w", db, err)
+	}
+	defer client.Close()
+	checks := conversion.VerifyRowCounts(client, conv, badWrites, loadedAt)
+	var verified int
+	for _, c := range checks {
+		if c.Status == "verified" {
+			verified++
+		}
+	}
+	detail := fmt.Sprintf("row counts of %d of %d table(s) verified", verified, len(checks))
+	failed := conversion.FailedRowCountChecks(checks)
+	if len(failed) > 0 {
+		detail += fmt.Sprintf(", row counts of tables %s can't be verified", strings.Join(failed, ", "))
+	}
+	notifier.Notify(conversion.VerificationComplete, nil, detail)
+	fmt.Fprintf(ioHelper.Out, "%s\n", detail)
+	if reportLayout.Manifests {
+		// Manifests are also evidence of failed verifications.
+		conversion.WriteManifests(driver, conv, checks, workspace.File(conversion.ReportFiles, manifestDirectory), now, ioHelper.Out)
+	}
+	if len(failed) > 0 {
+		return internal.RunSummary{}, fmt.Errorf("row counts of tables %s can't be verified", strings.Join(failed, ", "))
+	}
+	if !skipForeignKeys {
+		if err := conversion.UpdateDDLForeignKeys(projectID, instanceID, dbName, conv, ioHelper.Out); err != nil {
+			return internal.RunSummary{}, fmt.Errorf("can't add foreign keys to db %s: %w", db, err)
+		}
+		audit.ForeignKeyDDL(conv, db)
+	}
+	optimizer, _ := optimizeDatabase(projectID, instanceID, dbName, db, client, spannerOpts, audit, notifier, ioHelper)
+	banner := conversion.GetBanner(now, db) + optimizer
+	conversion.Report(driver, badWrites, 0, banner, conv, workspace.File(conversion.ReportFiles, reportFile), reportLayout, ioHelper.Out)
+	conversion.WriteStructuredReport(driver, badWrites, conv, workspace.File(conversion.ReportFiles, structuredReportFile), ioHelper.Out)
+	conversion.WriteLineage(driver, conv, workspace.File(conversion.ReportFiles, lineageFile), ioHelper.Out)
+	return internal.Summarize(conv, badWrites), nil
+}
@@ -0,0 +1,60 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+
+	"github.com/cloudspannerecosystem/harbourbridge/internal"
+)
+
+// ReportDiff compares the structured reports (report.json files) of two
+// runs and writes the newly introduced issues, resolved issues and row
+// count changes to out.
+func ReportDiff(oldFile, newFile string, out *os.File) error {
+	old, err := readStructuredReport(oldFile)
+	if err != nil {
+		return err
+	}
+	new, err := readStructuredReport(newFile)
+	if err != nil {
+		return err
+	}
+	w := bufio.NewWriter(out)
+	defer w.Flush()
+	fmt.Fprintf(w, "Comparing report %s (old) with %s (new)\n", oldFile, newFile)
+	if old.Summary != new.Summary {
+		fmt.Fprintf(w, "Old summary:\n%sNew summary:\n%s", old.Summary, new.Summary)
+	}
+	fmt.Fprintf(w, "Rows: %d -> %d, bad rows: %d -> %d\n\n", old.Rows, new.Rows, old.BadRows, new.BadRows)
+	internal.WriteReportDiff(internal.DiffReports(old, new), w)
+	return nil
+}
+
+func readStructuredReport(name string) (internal.StructuredReport, error) {
+	var r internal.StructuredReport
+	b, err := ioutil.ReadFile(name)
+	if err != nil {
+		return r, fmt.Errorf("can't read report %s: %w", name, err)
+	}
+	if err := json.Unmarshal(b, &r); err != nil {
+		return r, fmt.Errorf("can't parse report %s: %w", name, err)
+	}
+	return r, nil
+}
@@ -0,0 +1,60 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/cloudspannerecosystem/harbourbridge/conversion"
+)
+
+// SmokeTest runs the smoke test of cfg (see conversion.SmokeTest), in a
+// new directory if cfg.Dir is empty. The smoke test report is written as
+// JSON to file smoke-test.json of the directory, and the outcome of each
+// step to out.
+func SmokeTest(cfg conversion.SmokeTestConfig, out *os.File) error {
+	if cfg.Dir == "" {
+		dir, err := ioutil.TempDir("", "harbourbridge-smoke-test-")
+		if err != nil {
+			return fmt.Errorf("can't create smoke test directory: %w", err)
+		}
+		cfg.Dir = dir
+	}
+	dir, err := filepath.Abs(cfg.Dir)
+	if err != nil {
+		return err
+	}
+	cfg.Dir = dir
+	if err := os.MkdirAll(cfg.Dir, 0755); err != nil {
+		return fmt.Errorf("can't create smoke test directory %s: %w", cfg.Dir, err)
+	}
+	fmt.Fprintf(out, "Running smoke test of driver %s in directory '%s' ...\n", cfg.Driver, cfg.Dir)
+	r, smokeErr := conversion.SmokeTest(cfg)
+	printSteps(r.Steps, out)
+	b, err := json.MarshalIndent(r, "", "  ")
+	if err != nil {
+		return err
+	}
+	reportFile := filepath.Join(cfg.Dir, "smoke-test.json")
+	if err := ioutil.WriteFile(reportFile, append(b, '\n'), 0644); err != nil {
+		return fmt.Errorf("can't write smoke test report %s: %w", reportFile, err)
+	}
+	fmt.Fprintf(out, "Wrote smoke test report to file '%s'.\n", reportFile)
+	return smokeErr
+}
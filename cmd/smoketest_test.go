@@ -0,0 +1,47 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/cloudspannerecosystem/harbourbridge/conversion"
+)
+
+func TestSmokeTest_Report(t *testing.T) {
+	dir, err := ioutil.TempDir("", "smoke-test-")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+	out, err := os.Create(filepath.Join(dir, "out.txt"))
+	assert.Nil(t, err)
+	defer out.Close()
+
+	// The report is written even if the smoke test fails, here before any
+	// step runs.
+	cfg := conversion.SmokeTestConfig{Driver: conversion.DYNAMODB, Dir: filepath.Join(dir, "run")}
+	assert.NotNil(t, SmokeTest(cfg, out))
+	b, err := ioutil.ReadFile(filepath.Join(dir, "run", "smoke-test.json"))
+	assert.Nil(t, err)
+	var r conversion.SmokeTestReport
+	assert.Nil(t, json.Unmarshal(b, &r))
+	assert.False(t, r.Passed)
+	assert.Empty(t, r.Steps)
+}
@@ -0,0 +1,422 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"golang.org/x/crypto/ssh/terminal"
+
+	"github.com/cloudspannerecosystem/harbourbridge/conversion"
+	"github.com/cloudspannerecosystem/harbourbridge/internal"
+	"github.com/cloudspannerecosystem/harbourbridge/spanner"
+)
+
+const (
+	tuiRefresh  = time.Second // How often the screen is redrawn.
+	tuiSamples  = 60          // Number of throughput samples in the graph.
+	tuiLogLines = 1000        // Number of lines of output kept, and printed once the UI exits.
+)
+
+// tui is an interactive terminal UI shown during data conversion. It
+// shows the Spanner tables being read and written, the throughput of
+// writes, errors and the last lines of output, and pauses and resumes the
+// writes of tables (see spanner.WriteControl). It is drawn on the
+// controlling terminal, which also gives the keys: the standard input can
+// be a dump. Output written to the standard output while the UI is shown
+// is kept, and printed once it exits.
+type tui struct {
+	conv    *internal.Conv
+	control *spanner.WriteControl
+	forward func(internal.ProgressEvent) // Progress sink of the notifier; may be nil.
+	tables  []internal.TableLineage
+	tty     *os.File
+	state   *terminal.State
+	start   time.Time
+
+	// Output captured while the UI is shown.
+	io          *conversion.IOStreams
+	stdout, out *os.File // The standard output and io.Out, restored when the UI exits.
+	pipe        *os.File
+	captured    chan bool // Closed once all output is captured.
+	exited      sync.Once // The UI exits once, on Ctrl-C or when data conversion is done.
+
+	mu       sync.Mutex
+	reading  string          // Source table being read.
+	finished map[string]bool // Source tables read.
+	errors   int64           // Number of unexpected conditions.
+	lastErr  string          // Last unexpected condition.
+	selected int             // Index of the selected table.
+	written  map[string]int64
+	rates    map[string]float64 // Rows written per second, broken down by Spanner table.
+	samples  []float64          // Rows written per second, for the graph.
+	logs     []string           // Last lines of output.
+	dropped  int                // Number of lines of output no longer kept.
+	stop     chan bool
+	stopped  chan bool
+}
+
+// startTUI shows the terminal UI for the data conversion of conv, until
+// the returned function is called. Writes are controlled by
+// spannerOpts.Control, which is set if it is nil. Progress events are
+// forwarded to notifier.
+func startTUI(conv *internal.Conv, spannerOpts *conversion.SpannerOptions, notifier *conversion.Notifier, ioHelper *conversion.IOStreams) (func(), error) {
+	tty, err := os.OpenFile("/dev/tty", os.O_RDWR, 0)
+	if err != nil {
+		return nil, fmt.Errorf("the terminal UI needs a terminal: %w", err)
+	}
+	if spannerOpts.Control == nil {
+		spannerOpts.Control = spanner.NewWriteControl()
+	}
+	t := &tui{
+		conv:     conv,
+		control:  spannerOpts.Control,
+		tables:   internal.BuildLineage("", conv).Tables,
+		tty:      tty,
+		start:    time.Now(),
+		finished: make(map[string]bool),
+		written:  make(map[string]int64),
+		rates:    make(map[string]float64),
+		stop:     make(chan bool),
+		stopped:  make(chan bool),
+		captured: make(chan bool),
+	}
+	if notifier != nil {
+		t.forward = notifier.Event
+	}
+	if t.state, err = terminal.MakeRaw(int(tty.Fd())); err != nil {
+		tty.Close()
+		return nil, fmt.Errorf("can't set up terminal: %w", err)
+	}
+	r, w, err := os.Pipe()
+	if err != nil {
+		terminal.Restore(int(tty.Fd()), t.state)
+		tty.Close()
+		return nil, err
+	}
+	t.io, t.stdout, t.out, t.pipe = ioHelper, os.Stdout, ioHelper.Out, w
+	os.Stdout, ioHelper.Out = w, w
+	conv.SetProgressSink(t.event)
+	// Use the alternate screen, without cursor.
+	fmt.Fprint(tty, "\x1b[?1049h\x1b[?25l")
+	go t.capture(r)
+	go t.keys()
+	go t.run()
+	return t.exit, nil
+}
+
+// exit restores the terminal and the standard output, and prints the
+// output captured. Calls after the first do nothing.
+func (t *tui) exit() {
+	t.exited.Do(func() {
+		close(t.stop)
+		<-t.stopped
+		fmt.Fprint(t.tty, "\x1b[?25h\x1b[?1049l")
+		terminal.Restore(int(t.tty.Fd()), t.state)
+		t.tty.Close()
+		os.Stdout, t.io.Out = t.stdout, t.out
+		t.pipe.Close()
+		<-t.captured
+		if t.dropped > 0 {
+			fmt.Fprintf(os.Stdout, "[%d lines of output not shown]\n", t.dropped)
+		}
+		for _, l := range t.logs {
+			fmt.Fprintln(os.Stdout, l)
+		}
+	})
+}
+
+// event records progress events, and forwards them.
+func (t *tui) event(e internal.ProgressEvent) {
+	t.mu.Lock()
+	switch e.Kind {
+	case internal.TableStartEvent:
+		t.reading = e.Table
+	case internal.TableFinishEvent:
+		t.finished[e.Table] = true
+		if t.reading == e.Table {
+			t.reading = ""
+		}
+	case internal.ErrorEvent:
+		t.errors++
+		t.lastErr = e.Detail
+	}
+	t.mu.Unlock()
+	if t.forward != nil {
+		t.forward(e)
+	}
+}
+
+// capture keeps the last lines of the output written to r. Progress
+// percentages are overwritten using backspaces.
+func (t *tui) capture(r *os.File) {
+	defer close(t.captured)
+	defer r.Close()
+	br := bufio.NewReader(r)
+	var line []rune
+	for {
+		c, _, err := br.ReadRune()
+		if err != nil {
+			break
+		}
+		switch c {
+		case '\b':
+			if len(line) > 0 {
+				line = line[:len(line)-1]
+			}
+		case '\r':
+			line = line[:0]
+		case '\n':
+			t.addLog(string(line))
+			line = line[:0]
+		default:
+			line = append(line, c)
+		}
+	}
+	if len(line) > 0 {
+		t.addLog(string(line))
+	}
+}
+
+func (t *tui) addLog(l string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.logs = append(t.logs, l)
+	if len(t.logs) > tuiLogLines {
+		t.logs = t.logs[1:]
+		t.dropped++
+	}
+}
+
+// keys handles key presses: arrows (or j and k) select a table, space
+// pauses or resumes its writes, and p pauses or resumes all writes. In
+// raw mode, Ctrl-C doesn't send SIGINT: keys sends it once the UI has
+// exited, so that the terminal and the standard output are restored and
+// the output captured is printed.
+func (t *tui) keys() {
+	b := make([]byte, 16)
+	for {
+		n, err := t.tty.Read(b)
+		if err != nil {
+			return
+		}
+		key := string(b[:n])
+		t.mu.Lock()
+		switch key {
+		case "\x1b[A", "k":
+			if t.selected > 0 {
+				t.selected--
+			}
+		case "\x1b[B", "j":
+			if t.selected < len(t.tables)-1 {
+				t.selected++
+			}
+		case " ":
+			if t.selected < len(t.tables) {
+				table := t.tables[t.selected].SpTable
+				if t.tablePaused(table) {
+					t.control.ResumeTable(table)
+				} else {
+					t.control.PauseTable(table)
+				}
+			}
+		case "p":
+			if t.control.Paused() {
+				t.control.Resume()
+			} else {
+				t.control.Pause()
+			}
+		case "\x03":
+			t.mu.Unlock()
+			t.exit()
+			syscall.Kill(os.Getpid(), syscall.SIGINT)
+			return
+		}
+		t.mu.Unlock()
+		t.draw()
+	}
+}
+
+func (t *tui) tablePaused(table string) bool {
+	for _, p := range t.control.PausedTables() {
+		if p == table {
+			return true
+		}
+	}
+	return false
+}
+
+// run samples the rows written, and redraws the screen, until the UI
+// exits.
+func (t *tui) run() {
+	defer close(t.stopped)
+	last := time.Now()
+	ticker := time.NewTicker(tuiRefresh)
+	defer ticker.Stop()
+	t.draw()
+	for {
+		select {
+		case <-t.stop:
+			return
+		case now := <-ticker.C:
+			t.sample(now.Sub(last).Seconds())
+			last = now
+			t.draw()
+		}
+	}
+}
+
+// sample updates the throughput of writes, over the last secs seconds.
+func (t *tui) sample(secs float64) {
+	stats := t.control.Stats()
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	var total int64
+	for table, n := range stats.Written {
+		total += n - t.written[table]
+		t.rates[table] = float64(n-t.written[table]) / secs
+		t.written[table] = n
+	}
+	t.samples = append(t.samples, float64(total)/secs)
+	if len(t.samples) > tuiSamples {
+		t.samples = t.samples[1:]
+	}
+}
+
+// draw redraws the screen.
+func (t *tui) draw() {
+	width, height, err := terminal.GetSize(int(t.tty.Fd()))
+	if err != nil {
+		width, height = 80, 24
+	}
+	stats := t.control.Stats()
+	rows := t.conv.TableRows()
+	paused := make(map[string]bool)
+	for _, p := range t.control.PausedTables() {
+		paused[p] = true
+	}
+	t.mu.Lock()
+	var lines []string
+	state := fmt.Sprintf("running, %d in progress", stats.Writes)
+	switch {
+	case t.control.Paused() && stats.Writes > 0:
+		state = fmt.Sprintf("PAUSED, draining %d in progress", stats.Writes)
+	case t.control.Paused():
+		state = "PAUSED, drained"
+	}
+	lines = append(lines, fmt.Sprintf("HarbourBridge data conversion: %s elapsed, writes %s", time.Since(t.start).Round(time.Second), state))
+	var rate, peak float64
+	if len(t.samples) > 0 {
+		rate = t.samples[len(t.samples)-1]
+	}
+	for _, s := range t.samples {
+		if s > peak {
+			peak = s
+		}
+	}
+	lines = append(lines, fmt.Sprintf("Throughput: %.0f rows/s (peak %.0f rows/s over the last %d s)", rate, peak, len(t.samples)))
+	lines = append(lines, graph(t.samples, peak))
+	var bad, rejected int64
+	for _, r := range rows {
+		bad += r.BadRows
+	}
+	for _, n := range stats.Dropped {
+		rejected += n
+	}
+	lines = append(lines, fmt.Sprintf("Errors: %d bad rows, %d rows rejected by Spanner, %d failed writes, %d unexpected conditions", bad, rejected, stats.Errors, t.errors))
+	if t.lastErr != "" {
+		lines = append(lines, "Last unexpected condition: "+t.lastErr)
+	}
+	lines = append(lines, "", fmt.Sprintf("  %-30s %-8s %21s %12s %9s %10s", "TABLE", "STATE", "CONVERTED / ROWS", "WRITTEN", "REJECTED", "ROWS/S"))
+	// Keep room for the header, the log and the help line.
+	visible := height - len(lines) - 6
+	if visible < 1 {
+		visible = 1
+	}
+	first := 0
+	if t.selected >= visible {
+		first = t.selected - visible + 1
+	}
+	for i := first; i < len(t.tables) && i < first+visible; i++ {
+		lines = append(lines, t.tableLine(t.tables[i], i == t.selected, rows, stats, paused))
+	}
+	lines = append(lines, "", "Output:")
+	for i := len(t.logs) - 3; i < len(t.logs); i++ {
+		if i >= 0 {
+			lines = append(lines, "  "+t.logs[i])
+		}
+	}
+	lines = append(lines, "[up/down] select table  [space] pause/resume table  [p] pause/resume all  [Ctrl-C] abort")
+	// Progress events wait for t.mu: don't hold it while the terminal
+	// is written.
+	t.mu.Unlock()
+	for i, l := range lines {
+		if r := []rune(l); len(r) > width {
+			lines[i] = string(r[:width])
+		}
+	}
+	fmt.Fprint(t.tty, "\x1b[H\x1b[2J"+strings.Join(lines, "\r\n"))
+}
+
+// tableLine describes the progress of Spanner table l, with t.mu held.
+func (t *tui) tableLine(l internal.TableLineage, selected bool, rows map[string]internal.TableRows, stats spanner.WriteStats, paused map[string]bool) string {
+	var total, converted int64
+	reading, finished := false, true
+	for _, src := range l.SrcTables {
+		total += rows[src].Rows
+		converted += rows[src].GoodRows
+		reading = reading || src == t.reading
+		finished = finished && t.finished[src]
+	}
+	written, rejected := stats.Written[l.SpTable], stats.Dropped[l.SpTable]
+	var state string
+	switch {
+	case paused[l.SpTable]:
+		state = "paused"
+	case reading:
+		state = "reading"
+	case written+rejected < converted:
+		state = "writing"
+	case finished:
+		state = "done"
+	default:
+		state = "waiting"
+	}
+	cursor := " "
+	if selected {
+		cursor = ">"
+	}
+	return fmt.Sprintf("%s %-30s %-8s %10d / %-8d %12d %9d %10.0f", cursor, l.SpTable, state, converted, total, written, rejected, t.rates[l.SpTable])
+}
+
+// graph draws samples as a line of bars, scaled to max.
+func graph(samples []float64, max float64) string {
+	bars := []rune("▁▂▃▄▅▆▇█")
+	var b strings.Builder
+	for _, s := range samples {
+		i := 0
+		if max > 0 {
+			i = int(s / max * float64(len(bars)-1))
+		}
+		b.WriteRune(bars[i])
+	}
+	return b.String()
+}
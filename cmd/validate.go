@@ -0,0 +1,61 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/cloudspannerecosystem/harbourbridge/conversion"
+	"github.com/cloudspannerecosystem/harbourbridge/internal"
+)
+
+// Validate compares recent rows of the source database of driver with
+// Spanner database db every interval, until interrupted, while changes
+// are replicated to Spanner (see conversion.Validator). The schema
+// mapping is read from session file sessionJSON, and each round is
+// written to out as a line of JSON. If propagate is set, compatible
+// changes of the source schema are propagated to Spanner and to the
+// session file.
+func Validate(sessionJSON, driver, db string, rows int, interval time.Duration, propagate bool, out *os.File) error {
+	conv := internal.MakeConv()
+	if err := conversion.ReadSessionFile(conv, sessionJSON); err != nil {
+		return fmt.Errorf("can't read session file %s: %w", sessionJSON, err)
+	}
+	client, err := conversion.GetClient(db, conversion.SpannerOptions{})
+	if err != nil {
+		return fmt.Errorf("can't create client for db %s: %w", db, err)
+	}
+	defer client.Close()
+	v, err := conversion.NewValidator(driver, conv, client, rows)
+	if err != nil {
+		return err
+	}
+	defer v.Close()
+	if propagate {
+		v.PropagateSchemaChanges(db, sessionJSON)
+	}
+	stop := make(chan struct{})
+	sig := make(chan os.Signal, 1)
+	signal.Notify(sig, os.Interrupt, syscall.SIGTERM)
+	go func() {
+		<-sig
+		close(stop)
+	}()
+	return v.Run(interval, stop, out)
+}
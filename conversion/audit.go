@@ -0,0 +1,265 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package conversion
+
+import (
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"runtime"
+	"runtime/debug"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/cloudspannerecosystem/harbourbridge/internal"
+)
+
+// AuditLog is an append-only log of what HarbourBridge runs did: the
+// decisions that override the default conversion, the DDL statements
+// applied to Spanner, and data conversion runs. It is meant as evidence
+// for compliance reviews of regulated migrations. Each record is written
+// as a line of JSON, and the log is never truncated, so successive runs
+// can share it. Methods of a nil *AuditLog do nothing.
+type AuditLog struct {
+	mu  sync.Mutex
+	f   *os.File
+	out *os.File // For reporting write errors.
+}
+
+// AuditRecord is a record of the audit log. Fields that don't apply to
+// an event are omitted.
+type AuditRecord struct {
+	Time       time.Time
+	Event      string           // One of "run", "override", "ddl", "grant", "data" and "backup".
+	Detail     string           `json:",omitempty"`
+	Args       []string         `json:",omitempty"` // Command line of the run.
+	Version    string           `json:",omitempty"` // HarbourBridge and Go versions.
+	Driver     string           `json:",omitempty"`
+	Database   string           `json:",omitempty"`
+	Statements []string         `json:",omitempty"`
+	Start      *time.Time       `json:",omitempty"` // Start of data conversion (Time is its end).
+	Rows       map[string]int64 `json:",omitempty"` // Rows read, by source table.
+	GoodRows   map[string]int64 `json:",omitempty"` // Rows converted, by source table.
+	BadRows    map[string]int64 `json:",omitempty"` // Rows that couldn't be converted, by source table.
+	BadWrites  map[string]int64 `json:",omitempty"` // Rows that Spanner rejected, by Spanner table.
+}
+
+// OpenAuditLog opens the audit log in file name, creating it if needed.
+// Write errors are reported to out.
+func OpenAuditLog(name string, out *os.File) (*AuditLog, error) {
+	f, err := os.OpenFile(name, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return nil, fmt.Errorf("can't open audit log %s: %w", name, err)
+	}
+	return &AuditLog{f: f, out: out}, nil
+}
+
+// Close closes the audit log.
+func (a *AuditLog) Close() error {
+	if a == nil {
+		return nil
+	}
+	return a.f.Close()
+}
+
+// Record appends r to the audit log. If r.Time isn't set, it is set to
+// the current time.
+func (a *AuditLog) Record(r AuditRecord) {
+	if a == nil {
+		return
+	}
+	if r.Time.IsZero() {
+		r.Time = time.Now()
+	}
+	b, err := json.Marshal(r)
+	if err != nil {
+		fmt.Fprintf(a.out, "Can't encode audit record: %v\n", err)
+		return
+	}
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if _, err := a.f.Write(append(b, '\n')); err != nil {
+		fmt.Fprintf(a.out, "Can't write to audit log: %v\n", err)
+		return
+	}
+	// Records must survive a crash of the run they describe.
+	if err := a.f.Sync(); err != nil {
+		fmt.Fprintf(a.out, "Can't write to audit log: %v\n", err)
+	}
+}
+
+// Run records the start of a run migrating from driver to Spanner
+// database db.
+func (a *AuditLog) Run(driver, db string) {
+	a.Record(AuditRecord{
+		Event:    "run",
+		Args:     os.Args,
+		Version:  toolVersion(),
+		Driver:   driver,
+		Database: db,
+	})
+}
+
+// toolVersion returns the versions of HarbourBridge (as recorded in the
+// binary by the go command) and Go.
+func toolVersion() string {
+	version := "(devel)"
+	if bi, ok := debug.ReadBuildInfo(); ok && bi.Main.Version != "" {
+		version = bi.Main.Version
+	}
+	return fmt.Sprintf("harbourbridge %s, %s", version, runtime.Version())
+}
+
+// Overrides records the decisions that change the default conversion of
+// the source schema: dropped columns, renamed tables and columns, split
+// and merged tables, computed columns and data conversion policies. Edits
+// made in the web interface (e.g. type changes) are recorded in the
+// session file, so for runs that use session file sessionJSON, we record
+// its SHA-256 hash.
+func (a *AuditLog) Overrides(conv *internal.Conv, sessionJSON string) {
+	if a == nil {
+		return
+	}
+	if sessionJSON != "" {
+		detail := fmt.Sprintf("schema and edits read from session file %s", sessionJSON)
+		if b, err := ioutil.ReadFile(sessionJSON); err == nil {
+			detail += fmt.Sprintf(" (sha256 %x)", sha256.Sum256(b))
+		}
+		a.Record(AuditRecord{Event: "override", Detail: detail})
+	}
+	for _, t := range conv.SrcTables() {
+		for _, c := range conv.DroppedCols[t] {
+			a.Record(AuditRecord{Event: "override", Detail: fmt.Sprintf("source column %s.%s dropped", t, c)})
+		}
+	}
+	for _, r := range internal.Renames(conv) {
+		if r.SpTable != r.SrcTable {
+			a.Record(AuditRecord{Event: "override", Detail: fmt.Sprintf("table %s renamed to %s", r.SrcTable, r.SpTable)})
+		}
+		var cols []string
+		for c := range r.Cols {
+			cols = append(cols, c)
+		}
+		sort.Strings(cols)
+		for _, c := range cols {
+			a.Record(AuditRecord{Event: "override", Detail: fmt.Sprintf("column %s.%s renamed to %s.%s", r.SrcTable, c, r.SpTable, r.Cols[c])})
+		}
+	}
+	for _, t := range conv.SpTables() {
+		for _, s := range conv.Splits[t] {
+			a.Record(AuditRecord{Event: "override", Detail: fmt.Sprintf("table %s split: columns %s moved to table %s", t, strings.Join(s.Cols, ", "), s.NewTable)})
+		}
+		for _, cc := range conv.ComputedCols[t] {
+			a.Record(AuditRecord{Event: "override", Detail: fmt.Sprintf("computed column %s.%s %s added: %s", t, cc.Column, cc.Type, cc.Expr)})
+		}
+	}
+	for _, t := range conv.SrcTables() {
+		if m, ok := conv.MergedTables[t]; ok {
+			a.Record(AuditRecord{Event: "override", Detail: fmt.Sprintf("table %s merged into table %s", m.Table, m.Into)})
+		}
+	}
+	p := conv.Policies
+	detail := fmt.Sprintf("policies: special values %s, oversize %s, orphans %s, duplicates %s, NOT NULL %s, empty strings %s, CHAR padding %s", p.SpecialValues, p.Oversize, p.Orphans, p.Duplicates, p.NotNull, p.EmptyStrings, p.CharPadding)
+	var cols []string
+	for c := range p.NotNullColumns {
+		cols = append(cols, c)
+	}
+	sort.Strings(cols)
+	for _, c := range cols {
+		detail += fmt.Sprintf(", NOT NULL %s for %s", p.NotNullColumns[c], c)
+	}
+	cols = nil
+	for c := range p.EmptyStringColumns {
+		cols = append(cols, c)
+	}
+	sort.Strings(cols)
+	for _, c := range cols {
+		detail += fmt.Sprintf(", empty strings %s for %s", p.EmptyStringColumns[c], c)
+	}
+	cols = nil
+	for c := range p.CharPaddingColumns {
+		cols = append(cols, c)
+	}
+	sort.Strings(cols)
+	for _, c := range cols {
+		detail += fmt.Sprintf(", CHAR padding %s for %s", p.CharPaddingColumns[c], c)
+	}
+	for _, r := range p.DateRules {
+		detail += fmt.Sprintf(", date rule %s", r)
+	}
+	for _, r := range p.ValueDictionaries {
+		detail += fmt.Sprintf(", value dictionary %s", r)
+	}
+	a.Record(AuditRecord{Event: "override", Detail: detail})
+}
+
+// DDL records DDL statements applied to Spanner database db.
+func (a *AuditLog) DDL(db, detail string, stmts []string) {
+	if len(stmts) == 0 {
+		return
+	}
+	a.Record(AuditRecord{Event: "ddl", Detail: detail, Database: db, Statements: stmts})
+}
+
+// SchemaDDL records the statements that PrepareDatabase applies to db.
+func (a *AuditLog) SchemaDDL(conv *internal.Conv, db string) {
+	if a == nil {
+		return
+	}
+	a.DDL(db, "tables created", schemaStatements(conv))
+}
+
+// ForeignKeyDDL records the statements that UpdateDDLForeignKeys applies
+// to db. Statements that failed are reported in the Unexpected
+// conditions of the conversion report.
+func (a *AuditLog) ForeignKeyDDL(conv *internal.Conv, db string) {
+	if a == nil {
+		return
+	}
+	a.DDL(db, "foreign keys added", foreignKeyStatements(conv))
+}
+
+// Grants records IAM roles granted on database db.
+func (a *AuditLog) Grants(db string, grants []IAMGrant) {
+	for _, g := range grants {
+		a.Record(AuditRecord{Event: "grant", Database: db, Detail: fmt.Sprintf("%s granted to %s", g.Role, g.Member)})
+	}
+}
+
+// Backup records backup of database db.
+func (a *AuditLog) Backup(db, backup string) {
+	a.Record(AuditRecord{Event: "backup", Database: db, Detail: backup})
+}
+
+// Data records a data conversion run into db that started at start.
+// badWrites are the rows that Spanner rejected, by Spanner table.
+func (a *AuditLog) Data(conv *internal.Conv, db string, start time.Time, badWrites map[string]int64) {
+	if a == nil {
+		return
+	}
+	a.Record(AuditRecord{
+		Event:     "data",
+		Database:  db,
+		Start:     &start,
+		Rows:      conv.Stats.Rows,
+		GoodRows:  conv.Stats.GoodRows,
+		BadRows:   conv.Stats.BadRows,
+		BadWrites: badWrites,
+	})
+}
@@ -0,0 +1,121 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package conversion
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/cloudspannerecosystem/harbourbridge/internal"
+)
+
+// dumpCacheKey returns the key of the schema parsed from dump f by driver
+// in the cache: a hash of the HarbourBridge binary (so that schemas cached
+// by other versions aren't used), of the schema conversion settings and
+// of the content of f. f is read to its end, showing progress (as
+// configured by ioHelper) if it is large.
+func dumpCacheKey(driver, targetDb string, features internal.Features, names internal.NameMapping, f *os.File, size int64, ioHelper *IOStreams) (string, error) {
+	h := sha256.New()
+	exe, err := os.Executable()
+	if err != nil {
+		return "", err
+	}
+	if err := hashFile(h, exe); err != nil {
+		return "", err
+	}
+	fmt.Fprintf(h, "\n%s\n%s\n%s\n%v\n", driver, targetDb, features, names)
+	p := ioHelper.newProgress(size, "Hashing dump")
+	if _, err := io.Copy(h, &progressReader{r: f, p: p}); err != nil {
+		return "", fmt.Errorf("can't read dump: %w", err)
+	}
+	p.Done()
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+func hashFile(w io.Writer, name string) error {
+	f, err := os.Open(name)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	_, err = io.Copy(w, f)
+	return err
+}
+
+// progressReader reports the bytes read from r to p.
+type progressReader struct {
+	r io.Reader
+	p *internal.Progress
+	n int64
+}
+
+func (r *progressReader) Read(b []byte) (int, error) {
+	n, err := r.r.Read(b)
+	r.n += int64(n)
+	r.p.MaybeReport(r.n)
+	return n, err
+}
+
+// readCachedSchema returns the conv cached under key in dir, or nil if
+// there isn't one.
+func readCachedSchema(dir, key string) (*internal.Conv, error) {
+	data, err := ioutil.ReadFile(filepath.Join(dir, key+".json"))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	conv := internal.MakeConv()
+	if err := json.Unmarshal(data, conv); err != nil {
+		return nil, fmt.Errorf("can't decode cached schema %s: %w", key, err)
+	}
+	conv.SetSchemaMode()
+	conv.SetDataSink(nil)
+	return conv, nil
+}
+
+// writeCachedSchema caches conv under key in dir. The file is renamed
+// into place once written, so that concurrent runs never read a partial
+// file.
+func writeCachedSchema(dir, key string, conv *internal.Conv) error {
+	if err := os.MkdirAll(dir, os.ModePerm); err != nil {
+		return err
+	}
+	data, err := json.Marshal(conv)
+	if err != nil {
+		return err
+	}
+	tmp, err := ioutil.TempFile(dir, key+".*.tmp")
+	if err != nil {
+		return err
+	}
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	return os.Rename(tmp.Name(), filepath.Join(dir, key+".json"))
+}
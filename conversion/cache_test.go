@@ -0,0 +1,78 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package conversion
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/cloudspannerecosystem/harbourbridge/internal"
+	"github.com/cloudspannerecosystem/harbourbridge/spanner/ddl"
+)
+
+func TestDumpCacheKey(t *testing.T) {
+	dir, err := ioutil.TempDir("", "cache-test-")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+	key := func(content, targetDb string) string {
+		name := filepath.Join(dir, "dump.sql")
+		assert.Nil(t, ioutil.WriteFile(name, []byte(content), 0644))
+		f, err := os.Open(name)
+		assert.Nil(t, err)
+		defer f.Close()
+		k, err := dumpCacheKey(PGDUMP, targetDb, nil, internal.NameMapping{}, f, int64(len(content)), &IOStreams{Out: os.Stdout})
+		assert.Nil(t, err)
+		return k
+	}
+	k := key("CREATE TABLE t (a int);", TARGET_SPANNER)
+	assert.Len(t, k, 64)
+	assert.Equal(t, k, key("CREATE TABLE t (a int);", TARGET_SPANNER))
+	assert.NotEqual(t, k, key("CREATE TABLE t (a bigint);", TARGET_SPANNER))
+	assert.NotEqual(t, k, key("CREATE TABLE t (a int);", TARGET_EXPERIMENTAL_POSTGRES))
+}
+
+func TestCachedSchema(t *testing.T) {
+	dir, err := ioutil.TempDir("", "cache-test-")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+
+	// Schemas that aren't cached yet.
+	conv, err := readCachedSchema(filepath.Join(dir, "cache"), "k")
+	assert.Nil(t, err)
+	assert.Nil(t, conv)
+
+	want := internal.MakeConv()
+	want.SpSchema["t"] = ddl.CreateTable{Name: "t", ColNames: []string{"a"}, ColDefs: map[string]ddl.ColumnDef{"a": {Name: "a", T: ddl.Type{Name: ddl.Int64}}}}
+	assert.Nil(t, writeCachedSchema(filepath.Join(dir, "cache"), "k", want))
+	conv, err = readCachedSchema(filepath.Join(dir, "cache"), "k")
+	assert.Nil(t, err)
+	assert.Equal(t, want.SpSchema, conv.SpSchema)
+	// Only the cached schema is left in the directory.
+	files, err := ioutil.ReadDir(filepath.Join(dir, "cache"))
+	assert.Nil(t, err)
+	assert.Len(t, files, 1)
+
+	// Corrupt cache files are reported.
+	assert.Nil(t, ioutil.WriteFile(filepath.Join(dir, "cache", "bad.json"), []byte("{"), 0644))
+	_, err = readCachedSchema(filepath.Join(dir, "cache"), "bad")
+	assert.NotNil(t, err)
+
+	// The cache directory can't be created under a file.
+	assert.NotNil(t, writeCachedSchema(filepath.Join(dir, "cache", "k.json"), "k", want))
+}
@@ -0,0 +1,229 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package conversion
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"time"
+
+	sp "cloud.google.com/go/spanner"
+	database "cloud.google.com/go/spanner/admin/database/apiv1"
+	"google.golang.org/api/iterator"
+	adminpb "google.golang.org/genproto/googleapis/spanner/admin/database/v1"
+
+	"github.com/cloudspannerecosystem/harbourbridge/internal"
+	"github.com/cloudspannerecosystem/harbourbridge/spanner"
+)
+
+// Methods of cloning a database (see CloneConfig).
+const (
+	// CloneBackup backs up the database, and restores the backup in the
+	// target instance, which must have the same instance configuration.
+	// It copies the database as is, but can't mask values.
+	CloneBackup = "backup"
+	// CloneCopy creates the tables of the session in the target database,
+	// and copies their rows, masking values on the way.
+	CloneCopy = "copy"
+)
+
+// CloneConfig configures the cloning of a migrated database into another
+// instance, e.g. a staging instance for load tests (see Clone).
+type CloneConfig struct {
+	Project, Instance, Database string // Database to clone.
+	// TargetInstance and TargetDatabase are the instance and the name of
+	// the clone, which mustn't exist.
+	TargetInstance, TargetDatabase string
+	Method                         string        // CloneBackup or CloneCopy.
+	Retention                      time.Duration // How long the backup of CloneBackup is kept.
+	// Masks are the masks of source columns applied to the rows copied
+	// by CloneCopy (see internal.MaskRule).
+	Masks map[string]internal.MaskRule
+}
+
+// Clone clones the database of a migration with the schema of conv, as
+// configured by cfg. It returns the name of the clone.
+func Clone(conv *internal.Conv, cfg CloneConfig, out *os.File) (string, error) {
+	if cfg.TargetInstance == cfg.Instance && cfg.TargetDatabase == cfg.Database {
+		return "", fmt.Errorf("can't clone database %s into itself", cfg.Database)
+	}
+	switch cfg.Method {
+	case CloneBackup:
+		if len(cfg.Masks) > 0 {
+			return "", fmt.Errorf("can't mask values when cloning with a backup: use method %s", CloneCopy)
+		}
+		return cloneBackup(cfg, out)
+	case CloneCopy:
+		conv.Policies.Masks = cfg.Masks
+		if err := conv.CheckMasks(); err != nil {
+			return "", err
+		}
+		return cloneCopy(conv, cfg, out)
+	}
+	return "", fmt.Errorf("unknown clone method %q (accepted values are %q and %q)", cfg.Method, CloneBackup, CloneCopy)
+}
+
+func cloneBackup(cfg CloneConfig, out *os.File) (string, error) {
+	backupID := fmt.Sprintf("%s-clone-%s", cfg.Database, time.Now().UTC().Format("20060102-150405"))
+	backup, err := BackupDatabase(cfg.Project, cfg.Instance, cfg.Database, backupID, cfg.Retention, out)
+	if err != nil {
+		return "", err
+	}
+	ctx := context.Background()
+	adminClient, err := database.NewDatabaseAdminClient(ctx)
+	if err != nil {
+		return "", fmt.Errorf("can't create admin client: %w", analyzeError(err, cfg.Project, cfg.TargetInstance))
+	}
+	defer adminClient.Close()
+	fmt.Fprintf(out, "Restoring backup %s to database %s in instance %s ... ", backupID, cfg.TargetDatabase, cfg.TargetInstance)
+	op, err := adminClient.RestoreDatabase(ctx, &adminpb.RestoreDatabaseRequest{
+		Parent:     fmt.Sprintf("projects/%s/instances/%s", cfg.Project, cfg.TargetInstance),
+		DatabaseId: cfg.TargetDatabase,
+		Source:     &adminpb.RestoreDatabaseRequest_Backup{Backup: backup},
+	})
+	if err != nil {
+		return "", fmt.Errorf("can't restore backup %s: %w", backupID, analyzeError(err, cfg.Project, cfg.TargetInstance))
+	}
+	db, err := op.Wait(ctx)
+	if err != nil {
+		return "", fmt.Errorf("can't restore backup %s: %w", backupID, analyzeError(err, cfg.Project, cfg.TargetInstance))
+	}
+	fmt.Fprintf(out, "done.\n")
+	return db.Name, nil
+}
+
+func cloneCopy(conv *internal.Conv, cfg CloneConfig, out *os.File) (string, error) {
+	srcDB := fmt.Sprintf("projects/%s/instances/%s/databases/%s", cfg.Project, cfg.Instance, cfg.Database)
+	src, err := GetClient(srcDB, SpannerOptions{})
+	if err != nil {
+		return "", fmt.Errorf("can't create client for db %s: %w", srcDB, err)
+	}
+	defer src.Close()
+	db, err := CreateDatabase(cfg.Project, cfg.TargetInstance, cfg.TargetDatabase, "", conv, out)
+	if err != nil {
+		return "", err
+	}
+	dst, err := GetClient(db, SpannerOptions{})
+	if err != nil {
+		return "", fmt.Errorf("can't create client for db %s: %w", db, err)
+	}
+	defer dst.Close()
+	// Rows are read from a single snapshot, so that foreign keys hold in
+	// the clone. Interleaved tables are copied after the rows of their
+	// parent are written.
+	txn := src.ReadOnlyTransaction()
+	defer txn.Close()
+	bw := spanner.NewBatchWriter(spanner.BatchWriterConfig{
+		BytesLimit: 100 * 1000 * 1000,
+		WriteLimit: 40,
+		RetryLimit: 1000,
+		Verbose:    internal.Verbose(),
+		Write: func(m []*sp.Mutation) error {
+			_, err := dst.Apply(context.Background(), m)
+			return err
+		},
+		IndexMutations: conv.IndexMutations,
+	})
+	for _, t := range parentsFirst(conv) {
+		n, err := copyTable(conv, txn, bw, t)
+		if err != nil {
+			return "", err
+		}
+		bw.Flush()
+		fmt.Fprintf(out, "Copied %d rows of table %s.\n", n, t)
+	}
+	if dropped := sum(bw.DroppedRowsByTable()); dropped > 0 {
+		return "", fmt.Errorf("can't write %d rows to database %s: %v", dropped, db, bw.Errors())
+	}
+	if err := UpdateDDLForeignKeys(cfg.Project, cfg.TargetInstance, cfg.TargetDatabase, conv, out); err != nil {
+		return "", err
+	}
+	return db, nil
+}
+
+// copyTable adds the rows of Spanner table t read with txn to bw, masked
+// according to conv.Policies.Masks, and returns the number of rows.
+func copyTable(conv *internal.Conv, txn *sp.ReadOnlyTransaction, bw *spanner.BatchWriter, t string) (int64, error) {
+	cols := conv.SpSchema[t].ColNames
+	// Source table and column of masked Spanner columns.
+	masked := make(map[string][2]string)
+	for srcTable, nc := range conv.ToSpanner {
+		if nc.Name != t {
+			continue
+		}
+		for srcCol, spCol := range nc.Cols {
+			if _, ok := conv.Policies.Masks[srcTable+"."+srcCol]; ok {
+				masked[spCol] = [2]string{srcTable, srcCol}
+			}
+		}
+	}
+	iter := txn.Read(context.Background(), t, sp.AllKeys(), cols)
+	defer iter.Stop()
+	var n int64
+	for {
+		row, err := iter.Next()
+		if err == iterator.Done {
+			return n, nil
+		}
+		if err != nil {
+			return n, fmt.Errorf("can't read table %s: %w", t, err)
+		}
+		vals := make([]interface{}, len(cols))
+		for i, c := range cols {
+			src, ok := masked[c]
+			if !ok {
+				var v sp.GenericColumnValue
+				if err := row.Column(i, &v); err != nil {
+					return n, err
+				}
+				vals[i] = v
+				continue
+			}
+			// Masked columns are STRING columns (see internal.Conv.CheckMasks).
+			var s sp.NullString
+			if err := row.Column(i, &s); err != nil {
+				return n, err
+			}
+			if s.Valid {
+				if m, ok := conv.Mask(src[0], src[1], s.StringVal); ok {
+					vals[i] = m
+				}
+			}
+		}
+		bw.AddRow(t, cols, vals)
+		n++
+	}
+}
+
+// parentsFirst returns the Spanner tables of conv, with interleaved tables
+// after their parent.
+func parentsFirst(conv *internal.Conv) []string {
+	var tables []string
+	added := make(map[string]bool)
+	for len(tables) < len(conv.SpSchema) {
+		n := len(tables)
+		for _, t := range conv.SpTables() {
+			if p := conv.SpSchema[t].Parent; !added[t] && (p == "" || added[p]) {
+				tables = append(tables, t)
+				added[t] = true
+			}
+		}
+		if len(tables) == n {
+			break // Parents missing from the schema.
+		}
+	}
+	return tables
+}
@@ -0,0 +1,64 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package conversion
+
+import (
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/cloudspannerecosystem/harbourbridge/internal"
+	"github.com/cloudspannerecosystem/harbourbridge/spanner/ddl"
+)
+
+func TestClone_Errors(t *testing.T) {
+	// Errors found before connecting to Spanner.
+	cfg := CloneConfig{Project: "p", Instance: "i", Database: "db", TargetInstance: "staging", TargetDatabase: "db", Method: CloneCopy}
+	for _, tc := range []struct {
+		name   string
+		change func(cfg *CloneConfig)
+	}{
+		{"itself", func(cfg *CloneConfig) { cfg.TargetInstance = "i" }},
+		{"unknown method", func(cfg *CloneConfig) { cfg.Method = "export" }},
+		{"no method", func(cfg *CloneConfig) { cfg.Method = "" }},
+		{"masked backup", func(cfg *CloneConfig) {
+			cfg.Method = CloneBackup
+			cfg.Masks = map[string]internal.MaskRule{"users.email": {Method: internal.MaskRedact}}
+		}},
+		{"bad mask", func(cfg *CloneConfig) {
+			cfg.Masks = map[string]internal.MaskRule{"users.email": {Method: internal.MaskRedact}}
+		}},
+	} {
+		c := cfg
+		tc.change(&c)
+		_, err := Clone(internal.MakeConv(), c, os.Stdout)
+		assert.NotNil(t, err, tc.name)
+	}
+}
+
+func TestParentsFirst(t *testing.T) {
+	conv := internal.MakeConv()
+	for name, parent := range map[string]string{
+		"a_child":      "z_parent",
+		"b_grandchild": "a_child",
+		"orphan":       "missing",
+		"z_parent":     "",
+	} {
+		conv.SpSchema[name] = ddl.CreateTable{Name: name, Parent: parent}
+	}
+	// Tables whose parent is missing are left out.
+	assert.Equal(t, []string{"z_parent", "a_child", "b_grandchild"}, parentsFirst(conv))
+}
@@ -0,0 +1,106 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package conversion
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/cloudspannerecosystem/harbourbridge/internal"
+)
+
+// ConnectionConfig is what applications need to switch from the source
+// database to the migrated Spanner database: how to connect to it, and
+// which tables and columns have a different name in Spanner.
+type ConnectionConfig struct {
+	Database  string           // Database URI, as used by the Spanner client libraries.
+	Dialect   string           // GoogleSQL or PostgreSQL.
+	JDBCURL   string           // URL for the Spanner JDBC driver.
+	PGAdapter *PGAdapterConfig `json:",omitempty"` // Only set for the PostgreSQL dialect.
+	Renames   []internal.TableRename
+}
+
+// PGAdapterConfig describes how PostgreSQL clients connect to a
+// PostgreSQL-dialect database through PGAdapter, assuming PGAdapter runs
+// next to the application on its default port.
+type PGAdapterConfig struct {
+	Command          string // Command line to start PGAdapter.
+	ConnectionString string // libpq connection string (e.g. for psql).
+	JDBCURL          string // URL for the PostgreSQL JDBC driver.
+}
+
+// pgAdapterPort is PGAdapter's default port.
+const pgAdapterPort = 5432
+
+// BuildConnectionConfig returns the connection configuration of Spanner
+// database dbName (of project and instance), migrated using conv.
+func BuildConnectionConfig(conv *internal.Conv, project, instance, dbName string) ConnectionConfig {
+	db := fmt.Sprintf("projects/%s/instances/%s/databases/%s", project, instance, dbName)
+	c := ConnectionConfig{
+		Database: db,
+		Dialect:  "GoogleSQL",
+		JDBCURL:  "jdbc:cloudspanner:/" + db,
+		Renames:  internal.Renames(conv),
+	}
+	if pgDialect(conv) {
+		c.Dialect = "PostgreSQL"
+		c.PGAdapter = &PGAdapterConfig{
+			Command:          fmt.Sprintf("java -jar pgadapter.jar -p %s -i %s -d %s", project, instance, dbName),
+			ConnectionString: fmt.Sprintf("host=localhost port=%d dbname=%s", pgAdapterPort, dbName),
+			JDBCURL:          fmt.Sprintf("jdbc:postgresql://localhost:%d/%s", pgAdapterPort, dbName),
+		}
+	}
+	return c
+}
+
+// pgAdapterCompose is a docker-compose file that runs PGAdapter for a
+// Spanner database, using the application default credentials of the user.
+// Option -x lets PGAdapter accept connections from outside its container.
+const pgAdapterCompose = `# PGAdapter for Spanner database %[1]s, generated by HarbourBridge.
+# Start it with 'docker compose -f %[6]s up -d', then connect with:
+#   psql -h localhost -p %[5]d -d %[4]s
+services:
+  pgadapter:
+    image: gcr.io/cloud-spanner-pg-adapter/pgadapter
+    command: ["-p", "%[2]s", "-i", "%[3]s", "-d", "%[4]s", "-x"]
+    ports:
+      - "%[5]d:5432"
+    volumes:
+      - ${HOME}/.config/gcloud/application_default_credentials.json:/credentials.json:ro
+    environment:
+      GOOGLE_APPLICATION_CREDENTIALS: /credentials.json
+`
+
+// WritePGAdapterCompose writes a docker-compose file to name, which starts
+// PGAdapter for Spanner database dbName, so that psql and validation
+// suites can be pointed at the migrated database. It does nothing unless
+// the target uses the PostgreSQL dialect.
+func WritePGAdapterCompose(conv *internal.Conv, project, instance, dbName, name string, out *os.File) {
+	if !pgDialect(conv) {
+		return
+	}
+	f, err := os.Create(name)
+	if err != nil {
+		fmt.Fprintf(out, "Can't create PGAdapter docker-compose file %s: %v\n", name, err)
+		return
+	}
+	defer f.Close()
+	db := fmt.Sprintf("projects/%s/instances/%s/databases/%s", project, instance, dbName)
+	if _, err := fmt.Fprintf(f, pgAdapterCompose, db, project, instance, dbName, pgAdapterPort, name); err != nil {
+		fmt.Fprintf(out, "Can't write out PGAdapter docker-compose file: %v\n", err)
+		return
+	}
+	fmt.Fprintf(out, "Wrote PGAdapter docker-compose file to '%s': start it with 'docker compose -f %s up -d', then connect with 'psql -h localhost -p %d -d %s'.\n", name, name, pgAdapterPort, dbName)
+}
@@ -0,0 +1,131 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package conversion
+
+import (
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/gorilla/mux"
+
+	"github.com/cloudspannerecosystem/harbourbridge/spanner"
+)
+
+// DefaultDrainWait is how long a request to pause writes waits for the
+// writes in progress to complete, unless it gives a wait.
+const DefaultDrainWait = 5 * time.Minute
+
+// ControlStatus is the state of the writes of data conversion, as returned
+// by the control endpoint (see ControlHandler).
+type ControlStatus struct {
+	Paused bool `json:"paused"`
+	// Drained is true if writes are paused, and the writes that were in
+	// progress when they were paused have completed.
+	Drained      bool             `json:"drained"`
+	Writes       int64            `json:"writes_in_progress"`
+	PausedTables []string         `json:"paused_tables,omitempty"`
+	Written      map[string]int64 `json:"rows_written"`  // Broken down by Spanner table.
+	Rejected     map[string]int64 `json:"rows_rejected"` // Rows that couldn't be written, broken down by Spanner table.
+}
+
+// ControlHandler returns the handler of the control endpoint, which
+// pauses and resumes the writes of data conversion with c:
+//
+//	GET  /status                        returns the ControlStatus
+//	POST /pause[?table=T][&wait=D]      pauses all writes (or those of table T)
+//	POST /resume[?table=T]              resumes them
+//
+// A request to pause all writes returns once the writes in progress have
+// completed (the writes are drained), or after wait D (a duration such as
+// 30s, DefaultDrainWait if not given), with status 202 if they haven't
+// completed yet. All requests return the ControlStatus as JSON.
+func ControlHandler(c *spanner.WriteControl) http.Handler {
+	router := mux.NewRouter()
+	router.HandleFunc("/status", func(w http.ResponseWriter, r *http.Request) {
+		writeControlStatus(w, http.StatusOK, c)
+	}).Methods("GET")
+	router.HandleFunc("/pause", func(w http.ResponseWriter, r *http.Request) {
+		if table := r.URL.Query().Get("table"); table != "" {
+			c.PauseTable(table)
+			writeControlStatus(w, http.StatusOK, c)
+			return
+		}
+		wait := DefaultDrainWait
+		if s := r.URL.Query().Get("wait"); s != "" {
+			var err error
+			if wait, err = time.ParseDuration(s); err != nil {
+				http.Error(w, fmt.Sprintf("invalid wait %q: %v", s, err), http.StatusBadRequest)
+				return
+			}
+		}
+		c.Pause()
+		status := http.StatusOK
+		if !c.Drain(wait) {
+			status = http.StatusAccepted
+		}
+		writeControlStatus(w, status, c)
+	}).Methods("POST")
+	router.HandleFunc("/resume", func(w http.ResponseWriter, r *http.Request) {
+		if table := r.URL.Query().Get("table"); table != "" {
+			c.ResumeTable(table)
+		} else {
+			c.Resume()
+		}
+		writeControlStatus(w, http.StatusOK, c)
+	}).Methods("POST")
+	return router
+}
+
+func writeControlStatus(w http.ResponseWriter, status int, c *spanner.WriteControl) {
+	stats := c.Stats()
+	paused := c.Paused()
+	s := ControlStatus{
+		Paused:       paused,
+		Drained:      paused && stats.Writes == 0,
+		Writes:       stats.Writes,
+		PausedTables: c.PausedTables(),
+		Written:      stats.Written,
+		Rejected:     stats.Dropped,
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(s)
+}
+
+// ServeControl serves the control endpoint (see ControlHandler) at addr,
+// a host:port whose host must be a loopback address (such as localhost),
+// since the endpoint doesn't authenticate requests. It returns the
+// WriteControl to write data with (see SpannerOptions.Control), and a
+// function that stops serving the endpoint.
+func ServeControl(addr string) (*spanner.WriteControl, func(), error) {
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		return nil, nil, fmt.Errorf("invalid control address %q: %w", addr, err)
+	}
+	if ip := net.ParseIP(host); host != "localhost" && (ip == nil || !ip.IsLoopback()) {
+		return nil, nil, fmt.Errorf("invalid control address %q: the host must be localhost or a loopback address", addr)
+	}
+	l, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, nil, fmt.Errorf("can't serve control endpoint: %w", err)
+	}
+	c := spanner.NewWriteControl()
+	server := &http.Server{Handler: ControlHandler(c)}
+	go server.Serve(l)
+	return c, func() { server.Close() }, nil
+}
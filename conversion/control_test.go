@@ -0,0 +1,113 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package conversion
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	sp "cloud.google.com/go/spanner"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/cloudspannerecosystem/harbourbridge/spanner"
+)
+
+func TestControlHandler(t *testing.T) {
+	c := spanner.NewWriteControl()
+	release := make(chan bool)
+	bw := spanner.NewBatchWriter(spanner.BatchWriterConfig{
+		BytesLimit: 100 << 20,
+		WriteLimit: 40,
+		Write: func(m []*sp.Mutation) error {
+			<-release
+			return nil
+		},
+		Control: c,
+	})
+	server := httptest.NewServer(ControlHandler(c))
+	defer server.Close()
+	request := func(method, path string) (int, ControlStatus) {
+		req, err := http.NewRequest(method, server.URL+path, nil)
+		assert.Nil(t, err)
+		resp, err := http.DefaultClient.Do(req)
+		assert.Nil(t, err)
+		defer resp.Body.Close()
+		var s ControlStatus
+		if resp.StatusCode/100 == 2 {
+			assert.Nil(t, json.NewDecoder(resp.Body).Decode(&s))
+		}
+		return resp.StatusCode, s
+	}
+
+	code, s := request("POST", "/pause?table=users")
+	assert.Equal(t, http.StatusOK, code)
+	assert.Equal(t, []string{"users"}, s.PausedTables)
+	assert.False(t, s.Paused)
+	code, s = request("POST", "/resume?table=users")
+	assert.Equal(t, http.StatusOK, code)
+	assert.Empty(t, s.PausedTables)
+
+	// A write in progress that doesn't complete in time.
+	done := make(chan bool)
+	go func() {
+		bw.AddRow("orders", []string{"id"}, []interface{}{1})
+		bw.Flush()
+		close(done)
+	}()
+	for c.Stats().Writes == 0 {
+		time.Sleep(10 * time.Millisecond)
+	}
+	code, s = request("POST", "/pause?wait=50ms")
+	assert.Equal(t, http.StatusAccepted, code)
+	assert.True(t, s.Paused)
+	assert.False(t, s.Drained)
+	assert.Equal(t, int64(1), s.Writes)
+	close(release)
+	code, s = request("POST", "/pause?wait=10s")
+	assert.Equal(t, http.StatusOK, code)
+	assert.True(t, s.Drained)
+	assert.Equal(t, map[string]int64{"orders": 1}, s.Written)
+	code, s = request("POST", "/resume")
+	assert.Equal(t, http.StatusOK, code)
+	assert.False(t, s.Paused)
+	assert.False(t, s.Drained)
+	<-done
+
+	code, _ = request("POST", "/pause?wait=soon")
+	assert.Equal(t, http.StatusBadRequest, code)
+	assert.False(t, c.Paused())
+	code, _ = request("GET", "/pause")
+	assert.Equal(t, http.StatusMethodNotAllowed, code)
+	code, _ = request("POST", "/status")
+	assert.Equal(t, http.StatusMethodNotAllowed, code)
+	code, s = request("GET", "/status")
+	assert.Equal(t, http.StatusOK, code)
+	assert.Equal(t, map[string]int64{"orders": 1}, s.Written)
+	assert.Empty(t, s.Rejected)
+}
+
+func TestServeControl(t *testing.T) {
+	for _, addr := range []string{"localhost", "10.0.0.1:8080", "example.com:8080", ":8080"} {
+		_, _, err := ServeControl(addr)
+		assert.NotNil(t, err, addr)
+	}
+	c, stop, err := ServeControl("127.0.0.1:0")
+	assert.Nil(t, err)
+	assert.NotNil(t, c)
+	stop()
+}
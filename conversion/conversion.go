@@ -28,6 +28,7 @@ import (
 	"context"
 	"crypto/rand"
 	"database/sql"
+	"encoding/csv"
 	"encoding/json"
 	"fmt"
 	"io"
@@ -35,9 +36,10 @@ import (
 	"log"
 	"os"
 	"os/exec"
+	"path/filepath"
+	"plugin"
 	"strings"
 	"sync"
-	"sync/atomic"
 	"syscall"
 	"time"
 
@@ -58,6 +60,7 @@ import (
 	"github.com/cloudspannerecosystem/harbourbridge/internal"
 	"github.com/cloudspannerecosystem/harbourbridge/mysql"
 	"github.com/cloudspannerecosystem/harbourbridge/postgres"
+	"github.com/cloudspannerecosystem/harbourbridge/schema"
 	"github.com/cloudspannerecosystem/harbourbridge/spanner"
 	"github.com/cloudspannerecosystem/harbourbridge/spanner/ddl"
 )
@@ -85,53 +88,120 @@ var (
 	MaxWorkers = 10
 )
 
-func SchemaConv(driver string, targetDb string, ioHelper *IOStreams, schemaSampleSize int64) (*internal.Conv, error) {
+// SchemaConv performs schema conversion for driver. The Spanner schema
+// only uses the features of the target that are in features (nil means
+// all features). Live source databases are read as configured by source.
+func SchemaConv(driver string, targetDb string, features internal.Features, ioHelper *IOStreams, schemaSampleSize int64, source SourceOptions) (*internal.Conv, error) {
+	var conv *internal.Conv
+	var err error
 	switch driver {
 	case POSTGRES, MYSQL:
-		return schemaFromSQL(driver, targetDb)
+		conv, err = schemaFromSQL(driver, targetDb, features, source)
 	case PGDUMP, MYSQLDUMP:
-		return schemaFromDump(driver, targetDb, ioHelper)
+		conv, err = schemaFromDump(driver, targetDb, features, ioHelper, source.Names)
 	case DYNAMODB:
-		return schemaFromDynamoDB(schemaSampleSize)
+		conv, err = schemaFromDynamoDB(schemaSampleSize, source.Names)
 	default:
 		return nil, fmt.Errorf("schema conversion for driver %s not supported", driver)
 	}
+	if err != nil {
+		return nil, err
+	}
+	ioHelper.watchProgress(conv)
+	return conv, nil
 }
 
-func DataConv(driver string, ioHelper *IOStreams, client *sp.Client, conv *internal.Conv, dataOnly bool) (*spanner.BatchWriter, error) {
+// DataConv performs data conversion for driver, writing to Spanner with
+// client. Live source databases are read as configured by source, and
+// their data is staged in Cloud Storage before it is written if
+// source.Staging says so (for StageExtract, nothing is written and the
+// returned BatchWriter is empty). The number of concurrent writes is
+// given by spannerOpts.
+func DataConv(driver string, ioHelper *IOStreams, client *sp.Client, conv *internal.Conv, dataOnly bool, source SourceOptions, spannerOpts SpannerOptions) (*spanner.BatchWriter, error) {
 	config := spanner.BatchWriterConfig{
 		BytesLimit: 100 * 1000 * 1000,
-		WriteLimit: 40,
+		WriteLimit: spannerOpts.Writes,
 		RetryLimit: 1000,
 		Verbose:    internal.Verbose(),
+		// Batches are sized using projected mutation counts that include
+		// secondary index mutations, so that tables with many indexes
+		// don't exceed Spanner's per-commit mutation limit.
+		IndexMutations: conv.IndexMutations,
+	}
+	if spannerOpts.Writes == 0 {
+		config.Autotune = &spanner.DefaultAutotune
+	}
+	for t := range spannerOpts.TableWrites {
+		if _, ok := conv.SpSchema[t]; !ok {
+			return nil, fmt.Errorf("can't limit the writes of table %s: unknown Spanner table", t)
+		}
 	}
+	config.TableLimits = spannerOpts.TableWrites
+	config.Control = spannerOpts.Control
+	if len(source.Windows.Tables) > 0 {
+		if err := conv.SetTableWindows(source.Windows); err != nil {
+			return nil, err
+		}
+		if config.Control == nil {
+			config.Control = spanner.NewWriteControl()
+		}
+		defer pauseClosedWindows(conv, config.Control)()
+	}
+	var err error
+	if config.DML, err = dmlTables(client, spannerOpts.WriteMode, ioHelper.Out); err != nil {
+		return nil, err
+	}
+	var stage *stagingWriter
+	if source.Staging.URI != "" {
+		st, err := openStaging(source.Staging, ioHelper.Out)
+		if err != nil {
+			return nil, err
+		}
+		if source.Staging.Step == StageLoad {
+			return loadStaged(st, config, client, conv, ioHelper.Out)
+		}
+		stage = &stagingWriter{st: st}
+	}
+	var bw *spanner.BatchWriter
 	switch driver {
 	case POSTGRES, MYSQL:
-		return dataFromSQL(driver, config, client, conv)
+		bw, err = dataFromSQL(driver, config, ioHelper, client, conv, dataOnly, source, stage)
 	case PGDUMP, MYSQLDUMP:
-		if conv.SpSchema.CheckInterleaved() {
+		if conv.HasInterleavedTables() {
 			return nil, fmt.Errorf("HarbourBridge does not currently support data conversion from dump files\nif the schema contains interleaved tables. Suggest using direct access to source database\ni.e. using drivers postgres and mysql.")
 		}
-		return dataFromDump(driver, config, ioHelper, client, conv, dataOnly)
+		bw, err = dataFromDump(driver, config, ioHelper, client, conv, dataOnly)
 	case DYNAMODB:
-		return dataFromDynamoDB(config, client, conv)
+		bw, err = dataFromDynamoDB(config, client, conv, stage)
 	default:
 		return nil, fmt.Errorf("data conversion for driver %s not supported", driver)
 	}
+	if err == nil && stage != nil {
+		if err = stage.Close(conv); err == nil && source.Staging.Step != StageExtract {
+			bw, err = loadStaged(stage.st, config, client, conv, ioHelper.Out)
+		}
+	}
+	if err == nil && config.Autotune != nil && source.Staging.Step != StageExtract {
+		fmt.Fprintf(ioHelper.Out, "Write concurrency settled at %d concurrent writes.\n", bw.WriteLimit())
+	}
+	return bw, err
 }
 
-func driverConfig(driver string) (string, error) {
+// driverConfig returns the connection string of driver, connecting to
+// replica instead of the host given by the environment if it isn't empty
+// (see SourceOptions.Replica).
+func driverConfig(driver, replica string) (string, error) {
 	switch driver {
 	case POSTGRES:
-		return pgDriverConfig()
+		return pgDriverConfig(replica)
 	case MYSQL:
-		return mysqlDriverConfig()
+		return mysqlDriverConfig(replica)
 	default:
 		return "", fmt.Errorf("Driver %s not supported", driver)
 	}
 }
 
-func pgDriverConfig() (string, error) {
+func pgDriverConfig(replica string) (string, error) {
 	server := os.Getenv("PGHOST")
 	port := os.Getenv("PGPORT")
 	user := os.Getenv("PGUSER")
@@ -144,10 +214,11 @@ func pgDriverConfig() (string, error) {
 	if password == "" {
 		password = getPassword()
 	}
+	server, port = replicaHostPort(replica, server, port)
 	return fmt.Sprintf("host=%s port=%s user=%s password=%s dbname=%s sslmode=disable", server, port, user, password, dbname), nil
 }
 
-func mysqlDriverConfig() (string, error) {
+func mysqlDriverConfig(replica string) (string, error) {
 	server := os.Getenv("MYSQLHOST")
 	port := os.Getenv("MYSQLPORT")
 	user := os.Getenv("MYSQLUSER")
@@ -160,11 +231,12 @@ func mysqlDriverConfig() (string, error) {
 	if password == "" {
 		password = getPassword()
 	}
+	server, port = replicaHostPort(replica, server, port)
 	return fmt.Sprintf("%s:%s@tcp(%s:%s)/%s", user, password, server, port, dbname), nil
 }
 
-func schemaFromSQL(driver string, targetDb string) (*internal.Conv, error) {
-	driverConfig, err := driverConfig(driver)
+func schemaFromSQL(driver string, targetDb string, features internal.Features, source SourceOptions) (*internal.Conv, error) {
+	driverConfig, err := driverConfig(driver, source.Replica)
 	if err != nil {
 		return nil, err
 	}
@@ -174,20 +246,29 @@ func schemaFromSQL(driver string, targetDb string) (*internal.Conv, error) {
 	}
 	conv := internal.MakeConv()
 	conv.TargetDb = targetDb
+	conv.Features = features
+	conv.NameMapping = source.Names
+	if source.SpillDir != "" {
+		if err := conv.SpillTo(source.SpillDir); err != nil {
+			return nil, err
+		}
+	}
+	DetectServerVersion(driver, sourceDB, conv, os.Stdout)
 	err = ProcessInfoSchema(driver, conv, sourceDB)
 	if err != nil {
 		return nil, err
 	}
+	if source.SpillDir == "" {
+		conv.RecordSchemaHash()
+	}
 	return conv, nil
 }
 
-func dataFromSQL(driver string, config spanner.BatchWriterConfig, client *sp.Client, conv *internal.Conv) (*spanner.BatchWriter, error) {
+func dataFromSQL(driver string, config spanner.BatchWriterConfig, ioHelper *IOStreams, client *sp.Client, conv *internal.Conv, dataOnly bool, source SourceOptions, stage *stagingWriter) (*spanner.BatchWriter, error) {
 	// TODO: Refactor to avoid redundant calls to driverConfig and
 	// Open in schemaFromSQL and dataFromSQL. Also refactor to
-	// share code with dataFromPgDump. Use single transaction for
-	// reading schema and data from source db to get consistent
-	// dump.
-	driverConfig, err := driverConfig(driver)
+	// share code with dataFromPgDump.
+	driverConfig, err := driverConfig(driver, source.Replica)
 	if err != nil {
 		return nil, err
 	}
@@ -195,36 +276,99 @@ func dataFromSQL(driver string, config spanner.BatchWriterConfig, client *sp.Cli
 	if err != nil {
 		return nil, err
 	}
-	err = SetRowStats(driver, conv, sourceDB)
-	if err != nil {
-		return nil, err
+	DetectServerVersion(driver, sourceDB, conv, ioHelper.Out)
+	if dataOnly {
+		if err := checkSchemaDrift(driver, conv, sourceDB, source.SchemaDrift, ioHelper.Out); err != nil {
+			return nil, err
+		}
 	}
-	totalRows := conv.Rows()
-	p := internal.NewProgress(totalRows, "Writing data to Spanner", internal.Verbose())
-	rows := int64(0)
-	config.Write = func(m []*sp.Mutation) error {
-		_, err := client.Apply(context.Background(), m)
+	var q internal.Queryer = sourceDB
+	if source.Snapshot != "" {
+		var end func()
+		var pos internal.SnapshotPosition
+		q, pos, end, err = beginSnapshot(driver, sourceDB, source.Snapshot, conv.SrcVersion)
 		if err != nil {
-			return err
+			return nil, err
 		}
-		atomic.AddInt64(&rows, int64(len(m)))
-		p.MaybeReport(atomic.LoadInt64(&rows))
-		return nil
+		defer end()
+		conv.SetSnapshotSource(func(string) (internal.SnapshotPosition, error) { return pos, nil })
+	} else {
+		// Each table is read when it is converted.
+		conv.SetSnapshotSource(func(string) (internal.SnapshotPosition, error) {
+			if driver == MYSQL {
+				return mysql.CurrentPosition(sourceDB, conv.SrcVersion)
+			}
+			return postgres.CurrentPosition(sourceDB, conv.SrcVersion)
+		})
 	}
-	writer := spanner.NewBatchWriter(config)
+	conv.SetSoftDelete(source.SoftDelete)
+	conv.SetFetchSize(source.Fetch)
+	if source.Tenant.Column != "" {
+		if err := conv.SetTenant(source.Tenant); err != nil {
+			return nil, err
+		}
+	}
+	if len(source.Incremental) > 0 {
+		since, err := ReadWatermarks(client, source.Incremental)
+		if err != nil {
+			return nil, err
+		}
+		if err := conv.SetIncremental(source.Incremental, since); err != nil {
+			return nil, err
+		}
+		// Rows changed since the previous load were already written.
+		config.Upsert = true
+	}
+	err = SetRowStats(driver, conv, q)
+	if err != nil {
+		return nil, err
+	}
+	writer, w := newWriters(config, client, conv, stage)
 	conv.SetDataMode()
-	conv.SetDataSink(
-		func(table string, cols []string, vals []interface{}) {
-			writer.AddRow(table, cols, vals)
-		})
-	err = ProcessSQLData(driver, conv, sourceDB)
+	setSinks(conv, w)
+	err = ProcessSQLData(driver, conv, q)
 	if err != nil {
 		return nil, err
 	}
-	writer.Flush()
+	conv.ResolveOrphans()
+	w.Flush()
+	conv.ResolveMerges()
+	w.Flush()
 	return writer, nil
 }
 
+// rowWriter is where converted rows of live source databases are
+// written: a spanner.BatchWriter, or a stagingWriter if data is staged.
+type rowWriter interface {
+	AddRow(table string, cols []string, vals []interface{})
+	ReplaceRow(table string, cols []string, vals []interface{})
+	UpdateRow(table string, cols []string, vals []interface{})
+	AddChildRow(table string, cols []string, vals []interface{})
+	Flush()
+}
+
+// newWriters returns the BatchWriter of data conversion, and the
+// rowWriter converted rows are written to: the BatchWriter, or stage if
+// it isn't nil (the BatchWriter then writes nothing).
+func newWriters(config spanner.BatchWriterConfig, client *sp.Client, conv *internal.Conv, stage *stagingWriter) (*spanner.BatchWriter, rowWriter) {
+	if stage != nil {
+		stage.p = conv.NewProgress(conv.Rows(), "Staging data in "+stage.st.uri)
+		return spanner.NewBatchWriter(config), stage
+	}
+	p := conv.NewProgress(conv.Rows(), "Writing data to Spanner")
+	config.Write, config.WriteDML = writeFuncs(client, p)
+	writer := spanner.NewBatchWriter(config)
+	return writer, writer
+}
+
+// setSinks configures the sinks of conv to write rows to w.
+func setSinks(conv *internal.Conv, w rowWriter) {
+	conv.SetDataSink(w.AddRow)
+	conv.SetReplaceSink(w.ReplaceRow)
+	conv.SetUpdateSink(w.UpdateRow)
+	conv.SetChildSink(w.AddChildRow)
+}
+
 func getDynamoDBClientConfig() *aws.Config {
 	cfg := aws.Config{}
 	endpointOverride := os.Getenv("DYNAMODB_ENDPOINT_OVERRIDE")
@@ -234,8 +378,9 @@ func getDynamoDBClientConfig() *aws.Config {
 	return &cfg
 }
 
-func schemaFromDynamoDB(sampleSize int64) (*internal.Conv, error) {
+func schemaFromDynamoDB(sampleSize int64, names internal.NameMapping) (*internal.Conv, error) {
 	conv := internal.MakeConv()
+	conv.NameMapping = names
 	mySession := session.Must(session.NewSession())
 	dydbClient := dydb.New(mySession, getDynamoDBClientConfig())
 	err := dynamodb.ProcessSchema(conv, dydbClient, []string{}, sampleSize)
@@ -245,44 +390,65 @@ func schemaFromDynamoDB(sampleSize int64) (*internal.Conv, error) {
 	return conv, nil
 }
 
-func dataFromDynamoDB(config spanner.BatchWriterConfig, client *sp.Client, conv *internal.Conv) (*spanner.BatchWriter, error) {
+func dataFromDynamoDB(config spanner.BatchWriterConfig, client *sp.Client, conv *internal.Conv, stage *stagingWriter) (*spanner.BatchWriter, error) {
 	mySession := session.Must(session.NewSession())
 	dydbClient := dydb.New(mySession, getDynamoDBClientConfig())
 	dynamodb.SetRowStats(conv, dydbClient)
-	totalRows := conv.Rows()
-	p := internal.NewProgress(totalRows, "Writing data to Spanner", internal.Verbose())
-
-	rows := int64(0)
-	config.Write = func(m []*sp.Mutation) error {
-		_, err := client.Apply(context.Background(), m)
-		if err != nil {
-			return err
-		}
-		atomic.AddInt64(&rows, int64(len(m)))
-		p.MaybeReport(atomic.LoadInt64(&rows))
-		return nil
-	}
-	writer := spanner.NewBatchWriter(config)
+	conv.SetSnapshotSource(func(srcTable string) (internal.SnapshotPosition, error) {
+		return dynamodb.StreamPosition(dydbClient, srcTable)
+	})
+	writer, w := newWriters(config, client, conv, stage)
 	conv.SetDataMode()
-	conv.SetDataSink(
-		func(table string, cols []string, vals []interface{}) {
-			writer.AddRow(table, cols, vals)
-		})
+	setSinks(conv, w)
 
 	err := dynamodb.ProcessData(conv, dydbClient)
 	if err != nil {
 		return nil, err
 	}
-	writer.Flush()
+	w.Flush()
+	conv.ResolveMerges()
+	w.Flush()
 	return writer, nil
 }
 
 type IOStreams struct {
 	In, SeekableIn, Out *os.File
 	BytesRead           int64
+	// CacheDir, if not empty, is a directory where the schemas parsed from
+	// dumps are cached, so that converting the schema of the same dump
+	// again (e.g. to regenerate the report and DDL during schema reviews)
+	// doesn't parse it again.
+	CacheDir string
+	// TUI shows an interactive terminal UI during data conversion,
+	// instead of its output.
+	TUI bool
+	// Progress, if not nil, is sent the progress events of the tasks of
+	// schema conversion, and becomes the progress sink of the Conv that
+	// SchemaConv returns (see Conv.SetProgressSink).
+	Progress func(internal.ProgressEvent)
+	// ProgressOut is where the percentages of tasks are printed (nil
+	// means the standard output, see Conv.SetProgressOutput).
+	ProgressOut io.Writer
 }
 
-func schemaFromDump(driver string, targetDb string, ioHelper *IOStreams) (*internal.Conv, error) {
+// newProgress returns a Progress for a task run before there is a Conv,
+// which reports as configured by ioHelper.
+func (ioHelper *IOStreams) newProgress(total int64, message string) *internal.Progress {
+	return internal.NewProgressTo(total, message, internal.Verbose(), ioHelper.ProgressOut, ioHelper.Progress)
+}
+
+// watchProgress sets the progress sink and output of conv as configured
+// by ioHelper.
+func (ioHelper *IOStreams) watchProgress(conv *internal.Conv) {
+	if ioHelper.Progress != nil {
+		conv.SetProgressSink(ioHelper.Progress)
+	}
+	if ioHelper.ProgressOut != nil {
+		conv.SetProgressOutput(ioHelper.ProgressOut)
+	}
+}
+
+func schemaFromDump(driver string, targetDb string, features internal.Features, ioHelper *IOStreams, names internal.NameMapping) (*internal.Conv, error) {
 	f, n, err := getSeekable(ioHelper.In)
 	if err != nil {
 		printSeekError(driver, err, ioHelper.Out)
@@ -290,9 +456,27 @@ func schemaFromDump(driver string, targetDb string, ioHelper *IOStreams) (*inter
 	}
 	ioHelper.SeekableIn = f
 	ioHelper.BytesRead = n
+	var key string
+	if ioHelper.CacheDir != "" {
+		if key, err = dumpCacheKey(driver, targetDb, features, names, f, n, ioHelper); err != nil {
+			return nil, fmt.Errorf("can't compute cache key of the data file: %w", err)
+		}
+		if _, err := f.Seek(0, 0); err != nil {
+			return nil, fmt.Errorf("can't reset file offset: %w", err)
+		}
+		conv, err := readCachedSchema(ioHelper.CacheDir, key)
+		if err != nil {
+			fmt.Fprintf(ioHelper.Out, "Can't read cached schema, parsing the data file: %v\n", err)
+		} else if conv != nil {
+			fmt.Fprintf(ioHelper.Out, "Using schema cached in '%s' (key %s).\n", ioHelper.CacheDir, key)
+			return conv, nil
+		}
+	}
 	conv := internal.MakeConv()
 	conv.TargetDb = targetDb
-	p := internal.NewProgress(n, "Generating schema", internal.Verbose())
+	conv.Features = features
+	conv.NameMapping = names
+	p := ioHelper.newProgress(n, "Generating schema")
 	r := internal.NewReader(bufio.NewReader(f), p)
 	conv.SetSchemaMode() // Build schema and ignore data in dump.
 	conv.SetDataSink(nil)
@@ -302,6 +486,11 @@ func schemaFromDump(driver string, targetDb string, ioHelper *IOStreams) (*inter
 		return nil, fmt.Errorf("failed to parse the data file")
 	}
 	p.Done()
+	if key != "" {
+		if err := writeCachedSchema(ioHelper.CacheDir, key, conv); err != nil {
+			fmt.Fprintf(ioHelper.Out, "Can't cache schema in '%s': %v\n", ioHelper.CacheDir, err)
+		}
+	}
 	return conv, nil
 }
 
@@ -327,33 +516,75 @@ func dataFromDump(driver string, config spanner.BatchWriterConfig, ioHelper *IOS
 	}
 	totalRows := conv.Rows()
 
-	p := internal.NewProgress(totalRows, "Writing data to Spanner", internal.Verbose())
+	p := conv.NewProgress(totalRows, "Writing data to Spanner")
 	r := internal.NewReader(bufio.NewReader(ioHelper.SeekableIn), nil)
-	rows := int64(0)
-	config.Write = func(m []*sp.Mutation) error {
-		_, err := client.Apply(context.Background(), m)
-		if err != nil {
-			return err
-		}
-		atomic.AddInt64(&rows, int64(len(m)))
-		p.MaybeReport(atomic.LoadInt64(&rows))
-		return nil
-	}
+	config.Write, config.WriteDML = writeFuncs(client, p)
 	writer := spanner.NewBatchWriter(config)
 	conv.SetDataMode() // Process data in dump; schema is unchanged.
 	conv.SetDataSink(
 		func(table string, cols []string, vals []interface{}) {
 			writer.AddRow(table, cols, vals)
 		})
+	conv.SetReplaceSink(
+		func(table string, cols []string, vals []interface{}) {
+			writer.ReplaceRow(table, cols, vals)
+		})
+	conv.SetUpdateSink(
+		func(table string, cols []string, vals []interface{}) {
+			writer.UpdateRow(table, cols, vals)
+		})
+	conv.SetChildSink(
+		func(table string, cols []string, vals []interface{}) {
+			writer.AddChildRow(table, cols, vals)
+		})
 	ProcessDump(driver, conv, r)
+	conv.ResolveOrphans()
+	writer.Flush()
+	conv.ResolveMerges()
 	writer.Flush()
 	p.Done()
 
 	return writer, nil
 }
 
+// ReportLayout configures how the report is written (see Report). The
+// zero value writes the whole report to the report file.
+type ReportLayout struct {
+	// Split writes the summary and an index of the tables to the report
+	// file, and the report of each table to a file of its own, in a
+	// directory named after the report file without its extension (see
+	// internal.GenerateSplitReport).
+	Split bool
+	// Tables, if not empty, are the source tables whose reports are
+	// written, e.g. to regenerate the reports of the tables under review.
+	// The summary still covers all tables.
+	Tables []string
+	// Manifests writes a sign-off manifest of each Spanner table, in
+	// Markdown, to a directory of its own (see WriteManifests).
+	Manifests bool
+	// SARIF writes the schema issues in SARIF format, next to the schema
+	// files (see WriteSARIF).
+	SARIF bool
+}
+
+// tables returns the source tables whose reports are written (nil means
+// all tables), and warns of those that aren't in conv.
+func (l ReportLayout) tables(conv *internal.Conv, out *os.File) map[string]bool {
+	if len(l.Tables) == 0 {
+		return nil
+	}
+	m := make(map[string]bool)
+	for _, t := range l.Tables {
+		if _, ok := conv.SrcSchema[t]; !ok {
+			fmt.Fprintf(out, "Warning: table %s of the report filter isn't a source table.\n", t)
+		}
+		m[t] = true
+	}
+	return m
+}
+
 // Report generates a report of schema and data conversion.
-func Report(driver string, badWrites map[string]int64, BytesRead int64, banner string, conv *internal.Conv, reportFileName string, out *os.File) {
+func Report(driver string, badWrites map[string]int64, BytesRead int64, banner string, conv *internal.Conv, reportFileName string, layout ReportLayout, out *os.File) {
 	f, err := os.Create(reportFileName)
 	if err != nil {
 		fmt.Fprintf(out, "Can't write out report file %s: %v\n", reportFileName, err)
@@ -365,7 +596,24 @@ func Report(driver string, badWrites map[string]int64, BytesRead int64, banner s
 	w := bufio.NewWriter(f)
 	w.WriteString(banner)
 
-	summary := internal.GenerateReport(driver, conv, w, badWrites, true, true)
+	var summary string
+	tables := layout.tables(conv, out)
+	details := fmt.Sprintf("file '%s'", reportFileName)
+	if layout.Split {
+		dir := strings.TrimSuffix(reportFileName, filepath.Ext(reportFileName))
+		details = fmt.Sprintf("file '%s' and directory '%s'", reportFileName, dir)
+		if err := os.MkdirAll(dir, os.ModePerm); err != nil {
+			fmt.Fprintf(out, "Can't create report directory %s: %v\n", dir, err)
+		}
+		summary, err = internal.GenerateSplitReport(driver, conv, w, badWrites, tables, func(file string, report []byte) error {
+			return ioutil.WriteFile(filepath.Join(dir, file), append([]byte(banner), report...), 0644)
+		})
+		if err != nil {
+			fmt.Fprintf(out, "Can't write out table reports: %v\n", err)
+		}
+	} else {
+		summary = internal.GenerateTablesReport(driver, conv, w, badWrites, true, true, tables)
+	}
 	w.Flush()
 	var isDump bool
 	if strings.Contains(driver, "dump") {
@@ -382,8 +630,265 @@ func Report(driver string, badWrites map[string]int64, BytesRead int64, banner s
 	// In the case where f is stdout, don't write a duplicate copy.
 	if f != out {
 		fmt.Fprint(out, summary)
-		fmt.Fprintf(out, "See file '%s' for details of the schema and data conversions.\n", reportFileName)
+		fmt.Fprintf(out, "See %s for details of the schema and data conversions.\n", details)
+	}
+}
+
+// WriteStructuredReport writes a JSON version of the conversion report
+// to name, for comparison with the reports of other runs (see
+// 'harbourbridge report-diff').
+func WriteStructuredReport(driver string, badWrites map[string]int64, conv *internal.Conv, name string, out *os.File) {
+	f, err := os.Create(name)
+	if err != nil {
+		fmt.Fprintf(out, "Can't create structured report file %s: %v\n", name, err)
+		return
+	}
+	defer f.Close()
+	reportJSON, err := json.MarshalIndent(internal.GenerateStructuredReport(driver, conv, badWrites), "", " ")
+	if err != nil {
+		fmt.Fprintf(out, "Can't encode report to JSON: %v\n", err)
+		return
+	}
+	if _, err := f.Write(reportJSON); err != nil {
+		fmt.Fprintf(out, "Can't write out structured report file: %v\n", err)
+		return
+	}
+}
+
+// WriteLineage writes the lineage of the Spanner schema (see
+// internal.BuildLineage) to file name, in JSON format.
+func WriteLineage(driver string, conv *internal.Conv, name string, out *os.File) {
+	if conv.Spilling() {
+		fmt.Fprintf(out, "Not writing lineage file: the schema is spilled to disk.\n")
+		return
+	}
+	f, err := os.Create(name)
+	if err != nil {
+		fmt.Fprintf(out, "Can't create lineage file %s: %v\n", name, err)
+		return
+	}
+	defer f.Close()
+	lineageJSON, err := json.MarshalIndent(internal.BuildLineage(driver, conv), "", " ")
+	if err != nil {
+		fmt.Fprintf(out, "Can't encode lineage to JSON: %v\n", err)
+		return
+	}
+	if _, err := f.Write(lineageJSON); err != nil {
+		fmt.Fprintf(out, "Can't write out lineage file: %v\n", err)
+		return
+	}
+	fmt.Fprintf(out, "Wrote lineage to file '%s'.\n", name)
+}
+
+// WriteManifests writes the manifest of each Spanner table of conv (see
+// internal.WriteManifest) to a Markdown file of directory dir, named after
+// the table. checks are the row count checks of the tables (see
+// VerifyRowCounts), nil if the data wasn't converted.
+func WriteManifests(driver string, conv *internal.Conv, checks []RowCountCheck, dir string, now time.Time, out *os.File) {
+	if conv.Spilling() {
+		fmt.Fprintf(out, "Not writing manifests: the schema is spilled to disk.\n")
+		return
+	}
+	if err := os.MkdirAll(dir, os.ModePerm); err != nil {
+		fmt.Fprintf(out, "Can't create manifest directory: %v\n", err)
+		return
+	}
+	rows := make(map[string]*internal.ManifestRows)
+	for _, c := range checks {
+		r := &internal.ManifestRows{Read: c.Read, Converted: c.Converted, Bad: c.Bad, Spanner: c.SpannerRows, Verification: c.Status + ": " + c.Detail}
+		if c.Err != nil {
+			r.Spanner = -1
+		}
+		rows[c.SpTable] = r
+	}
+	tables := internal.BuildLineage(driver, conv).Tables
+	for _, t := range tables {
+		name := filepath.Join(dir, t.SpTable+".md")
+		f, err := os.Create(name)
+		if err != nil {
+			fmt.Fprintf(out, "Can't create manifest file %s: %v\n", name, err)
+			return
+		}
+		err = internal.WriteManifest(f, driver, conv, t, rows[t.SpTable], now)
+		if cerr := f.Close(); err == nil {
+			err = cerr
+		}
+		if err != nil {
+			fmt.Fprintf(out, "Can't write out manifest file: %v\n", err)
+			return
+		}
+	}
+	fmt.Fprintf(out, "Wrote manifests of %d table(s) to directory '%s'.\n", len(tables), dir)
+}
+
+// ScanAnomalies scans a live source database for data that will cause
+// problems during data conversion, and writes a report of what it finds
+// to name. Scanning reads all rows of the source tables (and runs an
+// anti-join per foreign key), so it can take a while on large databases.
+// It returns the anomalies found.
+func ScanAnomalies(driver string, conv *internal.Conv, name string, out *os.File) ([]internal.Anomaly, error) {
+	driverConfig, err := driverConfig(driver, "")
+	if err != nil {
+		return nil, err
+	}
+	sourceDB, err := sql.Open(driver, driverConfig)
+	if err != nil {
+		return nil, err
+	}
+	defer sourceDB.Close()
+	fmt.Fprintf(out, "Scanning source data for anomalies ...\n")
+	var anomalies []internal.Anomaly
+	switch driver {
+	case MYSQL:
+		anomalies, err = mysql.ScanAnomalies(conv, sourceDB, os.Getenv("MYSQLDATABASE"))
+	case POSTGRES:
+		anomalies, err = postgres.ScanAnomalies(conv, sourceDB)
+	default:
+		return nil, fmt.Errorf("anomaly scan for driver %s not supported", driver)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("can't scan source data: %w", err)
+	}
+	fmt.Fprint(out, internal.AnomalySummary(anomalies))
+	f, err := os.Create(name)
+	if err != nil {
+		fmt.Fprintf(out, "Can't create anomalies file %s: %v\n", name, err)
+		return anomalies, nil
 	}
+	defer f.Close()
+	w := bufio.NewWriter(f)
+	internal.WriteAnomalies(anomalies, w)
+	w.Flush()
+	fmt.Fprintf(out, "See file '%s' for details of source data anomalies.\n", name)
+	return anomalies, nil
+}
+
+// WriteSourceFixes writes statements that fix issues of a live source
+// database before migrating (see internal.SourceFixes) to name. Invalid
+// dates are only fixed if anomalies, from ScanAnomalies, are given.
+func WriteSourceFixes(driver string, conv *internal.Conv, anomalies []internal.Anomaly, name string, out *os.File) error {
+	var fixer internal.SourceFixer
+	switch driver {
+	case MYSQL:
+		fixer = mysql.SourceFixer(os.Getenv("MYSQLDATABASE"))
+	case POSTGRES:
+		driverConfig, err := driverConfig(driver, "")
+		if err != nil {
+			return err
+		}
+		sourceDB, err := sql.Open(driver, driverConfig)
+		if err != nil {
+			return err
+		}
+		defer sourceDB.Close()
+		if fixer, err = postgres.SourceFixer(sourceDB); err != nil {
+			return err
+		}
+	default:
+		return fmt.Errorf("source fixes for driver %s not supported", driver)
+	}
+	fixes := internal.SourceFixes(conv, fixer, anomalies)
+	f, err := os.Create(name)
+	if err != nil {
+		fmt.Fprintf(out, "Can't create source fixes file %s: %v\n", name, err)
+		return nil
+	}
+	defer f.Close()
+	w := bufio.NewWriter(f)
+	internal.WriteSourceFixes(fixes, w)
+	w.Flush()
+	fmt.Fprintf(out, "Wrote %d fix(es) for the source database to file '%s'.\n", len(fixes), name)
+	return nil
+}
+
+// TightenStrings profiles the data of a live source database, and gives
+// the columns that would be converted to STRING(MAX) the length of their
+// longest value multiplied by factor (see internal.Conv.TightenStrings).
+// Profiling reads all rows of the source tables with such columns.
+func TightenStrings(driver string, conv *internal.Conv, factor float64, out *os.File) error {
+	fmt.Fprintf(out, "Profiling the length of source strings ...\n")
+	lens, err := maxStringLengths(driver, conv, conv.UnboundedStringCols)
+	if err != nil {
+		return err
+	}
+	for srcTable, m := range lens {
+		conv.TightenStrings(srcTable, m, factor)
+	}
+	fmt.Fprintf(out, "Gave a length to %d STRING(MAX) column(s).\n", len(conv.StringLens))
+	return nil
+}
+
+// FitStrings checks the data of a live source database for values longer
+// than their STRING(n) column, and widens the columns they are in as
+// given by mode (see internal.Conv.FitStrings). Checking reads all rows
+// of the source tables with such columns.
+func FitStrings(driver string, conv *internal.Conv, mode string, out *os.File) error {
+	fmt.Fprintf(out, "Checking the length of source strings ...\n")
+	lens, err := maxStringLengths(driver, conv, conv.BoundedStringCols)
+	if err != nil {
+		return err
+	}
+	for srcTable, m := range lens {
+		conv.FitStrings(srcTable, m, mode)
+	}
+	fmt.Fprintf(out, "Widened %d STRING column(s).\n", len(conv.WidenedStrs))
+	return nil
+}
+
+// maxStringLengths returns the length of the longest value of the columns
+// returned by colsOf for each table of a live source database.
+func maxStringLengths(driver string, conv *internal.Conv, colsOf func(srcTable string) []string) (map[string]map[string]int64, error) {
+	driverConfig, err := driverConfig(driver, "")
+	if err != nil {
+		return nil, err
+	}
+	sourceDB, err := sql.Open(driver, driverConfig)
+	if err != nil {
+		return nil, err
+	}
+	defer sourceDB.Close()
+	var lens map[string]map[string]int64
+	switch driver {
+	case MYSQL:
+		lens, err = mysql.MaxStringLengths(conv, sourceDB, os.Getenv("MYSQLDATABASE"), colsOf)
+	case POSTGRES:
+		lens, err = postgres.MaxStringLengths(conv, sourceDB, colsOf)
+	default:
+		return nil, fmt.Errorf("profiling string lengths for driver %s not supported", driver)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("can't profile source data: %w", err)
+	}
+	return lens, nil
+}
+
+// ProfileColumns profiles the data of a live source database from a
+// sample of up to rows rows per table, and records the profile of each
+// column (NULL fraction, distinct values, smallest and largest values) in
+// conv, for the report (see internal.ColumnProfile).
+func ProfileColumns(driver string, conv *internal.Conv, rows int64, out *os.File) error {
+	driverConfig, err := driverConfig(driver, "")
+	if err != nil {
+		return err
+	}
+	sourceDB, err := sql.Open(driver, driverConfig)
+	if err != nil {
+		return err
+	}
+	defer sourceDB.Close()
+	fmt.Fprintf(out, "Profiling source columns ...\n")
+	switch driver {
+	case MYSQL:
+		err = mysql.ProfileColumns(conv, sourceDB, os.Getenv("MYSQLDATABASE"), rows)
+	case POSTGRES:
+		err = postgres.ProfileColumns(conv, sourceDB, rows)
+	default:
+		return fmt.Errorf("profiling for driver %s not supported", driver)
+	}
+	if err != nil {
+		return fmt.Errorf("can't profile source data: %w", err)
+	}
+	return nil
 }
 
 // getSeekable returns a seekable file (with same content as f) and the size of the content (in bytes).
@@ -421,23 +926,33 @@ func getSeekable(f *os.File) (*os.File, int64, error) {
 // It automatically determines an appropriate project, selects a
 // Spanner instance to use, generates a new Spanner DB name,
 // and call into the Spanner admin interface to create the new DB.
-func CreateDatabase(project, instance, dbName string, conv *internal.Conv, out *os.File) (string, error) {
-	fmt.Fprintf(out, "Creating new database %s in instance %s with default permissions ... ", dbName, instance)
+// If kmsKey isn't empty, the database is encrypted with this Cloud KMS
+// key, rather than a Google-managed key.
+func CreateDatabase(project, instance, dbName, kmsKey string, conv *internal.Conv, out *os.File) (string, error) {
+	if err := checkDDL(conv); err != nil {
+		return "", err
+	}
+	if kmsKey != "" {
+		fmt.Fprintf(out, "Creating new database %s in instance %s with default permissions, encrypted with key %s ... ", dbName, instance, kmsKey)
+	} else {
+		fmt.Fprintf(out, "Creating new database %s in instance %s with default permissions ... ", dbName, instance)
+	}
 	ctx := context.Background()
 	adminClient, err := database.NewDatabaseAdminClient(ctx)
 	if err != nil {
 		return "", fmt.Errorf("can't create admin client: %w", analyzeError(err, project, instance))
 	}
 	defer adminClient.Close()
-	// The schema we send to Spanner excludes comments (since Cloud
-	// Spanner DDL doesn't accept them), and protects table and col names
-	// using backticks (to avoid any issues with Spanner reserved words).
-	schema := conv.SpSchema.GetDDL(ddl.Config{Comments: false, ProtectIds: true, Tables: true, ForeignKeys: false})
-	op, err := adminClient.CreateDatabase(ctx, &adminpb.CreateDatabaseRequest{
+	schema := schemaStatements(conv)
+	req := &adminpb.CreateDatabaseRequest{
 		Parent:          fmt.Sprintf("projects/%s/instances/%s", project, instance),
 		CreateStatement: "CREATE DATABASE `" + dbName + "`",
 		ExtraStatements: schema,
-	})
+	}
+	if kmsKey != "" {
+		req.EncryptionConfig = &adminpb.EncryptionConfig{KmsKeyName: kmsKey}
+	}
+	op, err := adminClient.CreateDatabase(ctx, req)
 	if err != nil {
 		return "", fmt.Errorf("can't build CreateDatabaseRequest: %w", analyzeError(err, project, instance))
 	}
@@ -448,6 +963,27 @@ func CreateDatabase(project, instance, dbName string, conv *internal.Conv, out *
 	return fmt.Sprintf("projects/%s/instances/%s/databases/%s", project, instance, dbName), nil
 }
 
+// schemaStatements returns the DDL statements that create the tables
+// and indexes of conv.SpSchema. The schema we send to Spanner excludes
+// comments (since Cloud Spanner DDL doesn't accept them), and protects
+// table and col names using backticks (to avoid any issues with Spanner
+// reserved words).
+func schemaStatements(conv *internal.Conv) []string {
+	return conv.GetDDL(ddl.Config{Comments: false, ProtectIds: true, Tables: true, ForeignKeys: false})
+}
+
+// checkDDL checks the syntax of the statements of schemaStatements and
+// foreignKeyStatements (see internal.Conv.CheckDDL).
+func checkDDL(conv *internal.Conv) error {
+	return conv.CheckDDL(ddl.Config{Comments: false, ProtectIds: true, Tables: true, ForeignKeys: true})
+}
+
+// foreignKeyStatements returns the DDL statements that add the foreign
+// keys of conv.SpSchema (see schemaStatements).
+func foreignKeyStatements(conv *internal.Conv) []string {
+	return conv.GetDDL(ddl.Config{Comments: false, ProtectIds: true, Tables: false, ForeignKeys: true})
+}
+
 // UpdateDDLForeignKeys updates the Spanner database with foreign key
 // constraints using ALTER TABLE statements.
 func UpdateDDLForeignKeys(project, instance, dbName string, conv *internal.Conv, out *os.File) error {
@@ -458,15 +994,12 @@ func UpdateDDLForeignKeys(project, instance, dbName string, conv *internal.Conv,
 	}
 	defer adminClient.Close()
 
-	// The schema we send to Spanner excludes comments (since Cloud
-	// Spanner DDL doesn't accept them), and protects table and col names
-	// using backticks (to avoid any issues with Spanner reserved words).
-	fkStmts := conv.SpSchema.GetDDL(ddl.Config{Comments: false, ProtectIds: true, Tables: false, ForeignKeys: true})
+	fkStmts := foreignKeyStatements(conv)
 	if len(fkStmts) == 0 {
 		return nil
 	}
 	msg := fmt.Sprintf("Updating schema of database %s in instance %s with foreign key constraints ...", dbName, instance)
-	p := internal.NewProgress(int64(len(fkStmts)), msg, internal.Verbose())
+	p := conv.NewProgress(int64(len(fkStmts)), msg)
 
 	workers := make(chan int, MaxWorkers)
 	for i := 1; i <= MaxWorkers; i++ {
@@ -583,10 +1116,21 @@ func getInstances(project string) ([]string, error) {
 	return l, nil
 }
 
+// Values of the comments argument of WriteSchemaFile, for how much of the
+// conversion metadata the schema file gives in comments.
+const (
+	DDLCommentsFull    = "full"    // Table comments, and the source column of each column.
+	DDLCommentsMinimal = "minimal" // Table comments only.
+	DDLCommentsNone    = "none"    // No comments.
+)
+
 // WriteSchemaFile writes DDL statements in a file. It includes CREATE TABLE
 // statements and ALTER TABLE statements to add foreign keys.
-// The parameter name should end with a .txt.
-func WriteSchemaFile(conv *internal.Conv, now time.Time, name string, out *os.File) {
+// The parameter name should end with a .txt. For the experimental_postgres
+// target, statements use the syntax of Spanner's PostgreSQL dialect.
+// comments is one of DDLCommentsFull (or empty), DDLCommentsMinimal and
+// DDLCommentsNone.
+func WriteSchemaFile(conv *internal.Conv, now time.Time, name, comments string, out *os.File) {
 	f, err := os.Create(name)
 	if err != nil {
 		fmt.Fprintf(out, "Can't create schema file %s: %v\n", name, err)
@@ -597,7 +1141,7 @@ func WriteSchemaFile(conv *internal.Conv, now time.Time, name string, out *os.Fi
 	// and doesn't add backticks around table and column names. This file is
 	// intended for explanatory and documentation purposes, and is not strictly
 	// legal Cloud Spanner DDL (Cloud Spanner doesn't currently support comments).
-	spDDL := conv.SpSchema.GetDDL(ddl.Config{Comments: true, ProtectIds: false, Tables: true, ForeignKeys: true})
+	spDDL := conv.GetDDL(ddl.Config{Comments: comments != DDLCommentsNone, TableCommentsOnly: comments == DDLCommentsMinimal, ProtectIds: false, Tables: true, ForeignKeys: true, PostgreSQL: pgDialect(conv)})
 	if len(spDDL) == 0 {
 		spDDL = []string{"\n-- Schema is empty -- no tables found\n"}
 	}
@@ -612,10 +1156,7 @@ func WriteSchemaFile(conv *internal.Conv, now time.Time, name string, out *os.Fi
 	}
 	fmt.Fprintf(out, "Wrote schema to file '%s'.\n", name)
 
-	// Convert <file_name>.<ext> to <file_name>.ddl.<ext>.
-	nameSplit := strings.Split(name, ".")
-	nameSplit = append(nameSplit[:len(nameSplit)-1], "ddl", nameSplit[len(nameSplit)-1])
-	name = strings.Join(nameSplit, ".")
+	name = legalDDLFile(name)
 	f, err = os.Create(name)
 	if err != nil {
 		fmt.Fprintf(out, "Can't create legal schema ddl file %s: %v\n", name, err)
@@ -624,7 +1165,7 @@ func WriteSchemaFile(conv *internal.Conv, now time.Time, name string, out *os.Fi
 
 	// We change 'Comments' to false and 'ProtectIds' to true below to write out a
 	// schema file that is a legal Cloud Spanner DDL.
-	spDDL = conv.SpSchema.GetDDL(ddl.Config{Comments: false, ProtectIds: true, Tables: true, ForeignKeys: true})
+	spDDL = conv.GetDDL(legalDDLConfig(conv))
 	if len(spDDL) == 0 {
 		spDDL = []string{"\n-- Schema is empty -- no tables found\n"}
 	}
@@ -639,6 +1180,143 @@ func WriteSchemaFile(conv *internal.Conv, now time.Time, name string, out *os.Fi
 	fmt.Fprintf(out, "Wrote legal schema ddl to file '%s'.\n", name)
 }
 
+// legalDDLFile returns the name of the legal DDL file written by
+// WriteSchemaFile for schema file name: <file_name>.<ext> becomes
+// <file_name>.ddl.<ext>.
+func legalDDLFile(name string) string {
+	nameSplit := strings.Split(name, ".")
+	nameSplit = append(nameSplit[:len(nameSplit)-1], "ddl", nameSplit[len(nameSplit)-1])
+	return strings.Join(nameSplit, ".")
+}
+
+// legalDDLConfig is the configuration of the legal DDL file.
+func legalDDLConfig(conv *internal.Conv) ddl.Config {
+	return ddl.Config{Comments: false, ProtectIds: true, Tables: true, ForeignKeys: true, PostgreSQL: pgDialect(conv)}
+}
+
+// WriteSARIF writes the schema issues of conv to file name in SARIF format
+// (see internal.BuildSARIF), located in the legal DDL file written by
+// WriteSchemaFile for schemaFile, so that code review tools can show them
+// as annotations of the DDL.
+func WriteSARIF(conv *internal.Conv, schemaFile, name string, out *os.File) {
+	if conv.Spilling() {
+		fmt.Fprintf(out, "Not writing SARIF file: the schema is spilled to disk.\n")
+		return
+	}
+	ddlFile := legalDDLFile(schemaFile)
+	// Files are given relative to the SARIF file.
+	uri, err := filepath.Rel(filepath.Dir(name), ddlFile)
+	if err != nil {
+		uri = ddlFile
+	}
+	b, err := json.MarshalIndent(internal.BuildSARIF(conv, filepath.ToSlash(uri), legalDDLConfig(conv)), "", "  ")
+	if err != nil {
+		fmt.Fprintf(out, "Can't encode SARIF file: %v\n", err)
+		return
+	}
+	if err := ioutil.WriteFile(name, append(b, '\n'), 0644); err != nil {
+		fmt.Fprintf(out, "Can't write out SARIF file: %v\n", err)
+		return
+	}
+	fmt.Fprintf(out, "Wrote schema issues in SARIF format to file '%s'.\n", name)
+}
+
+// pgDialect returns true if schema files should be written in the syntax
+// of Spanner's PostgreSQL dialect. Note that CreateDatabase always uses
+// GoogleSQL syntax: the version of the database admin API we use can't
+// create PostgreSQL-dialect databases, so the PostgreSQL DDL is intended
+// to be applied with PGAdapter or psql.
+func pgDialect(conv *internal.Conv) bool {
+	return conv.TargetDb == TARGET_EXPERIMENTAL_POSTGRES
+}
+
+// Subdirectories of the schema directory written by WriteSchemaDir, by
+// kind of statement.
+var schemaDirs = map[string]string{
+	ddl.TableStatement:      "tables",
+	ddl.IndexStatement:      "indexes",
+	ddl.ForeignKeyStatement: "constraints",
+	ddl.SequenceStatement:   "sequences",
+}
+
+// WriteSchemaDir writes each DDL statement of the Spanner schema in its own
+// file under directory dir: tables in dir/tables, indexes in dir/indexes,
+// foreign keys in dir/constraints and sequences in dir/sequences. Files are
+// named after the object they create. It also writes dir/manifest.txt, which
+// lists the files in an order they can be applied in (sequences first,
+// parent tables before interleaved tables, tables before their indexes, and
+// foreign keys last). Subdirectories left
+// over from an earlier run are replaced.
+func WriteSchemaDir(conv *internal.Conv, dir string, out *os.File) {
+	for _, d := range schemaDirs {
+		if err := os.RemoveAll(filepath.Join(dir, d)); err != nil {
+			fmt.Fprintf(out, "Can't remove old schema directory: %v\n", err)
+			return
+		}
+		if err := os.MkdirAll(filepath.Join(dir, d), os.ModePerm); err != nil {
+			fmt.Fprintf(out, "Can't create schema directory: %v\n", err)
+			return
+		}
+	}
+	var manifest []string
+	fks := make(map[string]int) // Number of unnamed foreign keys, by table.
+	for _, st := range conv.GetStatements(ddl.Config{Comments: false, ProtectIds: true, Tables: true, ForeignKeys: true, PostgreSQL: pgDialect(conv)}) {
+		name := st.Name
+		if name == "" {
+			fks[st.Table]++
+			name = fmt.Sprintf("%s_fk%d", st.Table, fks[st.Table])
+		}
+		file := filepath.Join(schemaDirs[st.Kind], name+".sql")
+		if err := ioutil.WriteFile(filepath.Join(dir, file), []byte(st.DDL+";\n"), 0644); err != nil {
+			fmt.Fprintf(out, "Can't write out schema file: %v\n", err)
+			return
+		}
+		manifest = append(manifest, filepath.ToSlash(file))
+	}
+	manifest = append(manifest, "")
+	if err := ioutil.WriteFile(filepath.Join(dir, "manifest.txt"), []byte(strings.Join(manifest, "\n")), 0644); err != nil {
+		fmt.Fprintf(out, "Can't write out schema manifest: %v\n", err)
+		return
+	}
+	fmt.Fprintf(out, "Wrote schema (one file per table, index, foreign key and sequence) to directory '%s'.\n", dir)
+}
+
+// WriteModels writes models of the Spanner schema in language l to file
+// name (see internal.GenerateModels).
+func WriteModels(conv *internal.Conv, l internal.ModelLanguage, name string, out *os.File) {
+	f, err := os.Create(name)
+	if err != nil {
+		fmt.Fprintf(out, "Can't create models file %s: %v\n", name, err)
+		return
+	}
+	defer f.Close()
+	if _, err := f.WriteString(internal.GenerateModels(conv, l)); err != nil {
+		fmt.Fprintf(out, "Can't write out models file: %v\n", err)
+		return
+	}
+	fmt.Fprintf(out, "Wrote %s models to file '%s'.\n", l, name)
+}
+
+// WriteDiagram writes a diagram of the Spanner schema (or, if source is
+// set, of the source schema) in format f to file name.
+func WriteDiagram(conv *internal.Conv, f internal.DiagramFormat, source bool, name string, out *os.File) {
+	file, err := os.Create(name)
+	if err != nil {
+		fmt.Fprintf(out, "Can't create diagram file %s: %v\n", name, err)
+		return
+	}
+	defer file.Close()
+	d, schema := internal.SpannerDiagram(conv, f), "Spanner"
+	if source {
+		d, schema = internal.SourceDiagram(conv, f), "source"
+	}
+	if _, err := file.WriteString(d); err != nil {
+		fmt.Fprintf(out, "Can't write out diagram file: %v\n", err)
+		return
+	}
+	fmt.Fprintf(out, "Wrote %s diagram of the %s schema to file '%s'.\n", f, schema, name)
+}
+
 // WriteSessionFile writes conv struct to a file in JSON format.
 func WriteSessionFile(conv *internal.Conv, name string, out *os.File) {
 	f, err := os.Create(name)
@@ -646,6 +1324,21 @@ func WriteSessionFile(conv *internal.Conv, name string, out *os.File) {
 		fmt.Fprintf(out, "Can't create session file %s: %v\n", name, err)
 		return
 	}
+	defer f.Close()
+	if conv.Spilling() {
+		// Tables are written one at a time, without indentation.
+		w := bufio.NewWriter(f)
+		if err := conv.WriteJSON(w); err != nil {
+			fmt.Fprintf(out, "Can't write out session file: %v\n", err)
+			return
+		}
+		if err := w.Flush(); err != nil {
+			fmt.Fprintf(out, "Can't write out session file: %v\n", err)
+			return
+		}
+		fmt.Fprintf(out, "Wrote session to file '%s'.\n", name)
+		return
+	}
 	// Session file will basically contain 'conv' struct in JSON format.
 	// It contains all the information for schema and data conversion state.
 	convJSON, err := json.MarshalIndent(conv, "", " ")
@@ -671,9 +1364,9 @@ func WriteConvGeneratedFiles(conv *internal.Conv, dbName string, driver string,
 		return "", err
 	}
 	schemaFileName := dirPath + dbName + "_schema.txt"
-	WriteSchemaFile(conv, now, schemaFileName, out)
+	WriteSchemaFile(conv, now, schemaFileName, DDLCommentsFull, out)
 	reportFileName := dirPath + dbName + "_report.txt"
-	Report(driver, nil, BytesRead, "", conv, reportFileName, out)
+	Report(driver, nil, BytesRead, "", conv, reportFileName, ReportLayout{}, out)
 	sessionFileName := dirPath + dbName + ".session.json"
 	WriteSessionFile(conv, sessionFileName, out)
 	return dirPath, nil
@@ -693,6 +1386,110 @@ func ReadSessionFile(conv *internal.Conv, sessionJSON string) error {
 	return nil
 }
 
+// ReadComputedColsFile reads a JSON file containing a list of computed
+// column definitions (see internal.ComputedCol).
+func ReadComputedColsFile(name string) ([]internal.ComputedCol, error) {
+	s, err := ioutil.ReadFile(name)
+	if err != nil {
+		return nil, err
+	}
+	var cols []internal.ComputedCol
+	if err := json.Unmarshal(s, &cols); err != nil {
+		return nil, fmt.Errorf("can't parse computed columns file %s: %w", name, err)
+	}
+	return cols, nil
+}
+
+// ReadRemodelFile reads a JSON file containing primary key changes, table
+// splits and merges (see internal.Remodel).
+func ReadRemodelFile(name string) (internal.Remodel, error) {
+	var r internal.Remodel
+	s, err := ioutil.ReadFile(name)
+	if err != nil {
+		return r, err
+	}
+	if err := json.Unmarshal(s, &r); err != nil {
+		return r, fmt.Errorf("can't parse remodel file %s: %w", name, err)
+	}
+	return r, nil
+}
+
+// LoadPlugin loads a Go plugin (see package plugin) that defines schema
+// conversion hooks, and registers them. The plugin must export a
+// function PreTable of type func(*schema.Table) error, or a function
+// PostTable of type func(string, *ddl.CreateTable) error, or both (see
+// internal.PreTableHook and internal.PostTableHook).
+func LoadPlugin(path string) error {
+	p, err := plugin.Open(path)
+	if err != nil {
+		return fmt.Errorf("can't load plugin %s: %w", path, err)
+	}
+	found := false
+	if sym, err := p.Lookup("PreTable"); err == nil {
+		h, ok := sym.(func(*schema.Table) error)
+		if !ok {
+			return fmt.Errorf("plugin %s: PreTable has type %T, want func(*schema.Table) error", path, sym)
+		}
+		internal.RegisterPreTableHook(h)
+		found = true
+	}
+	if sym, err := p.Lookup("PostTable"); err == nil {
+		h, ok := sym.(func(string, *ddl.CreateTable) error)
+		if !ok {
+			return fmt.Errorf("plugin %s: PostTable has type %T, want func(string, *ddl.CreateTable) error", path, sym)
+		}
+		internal.RegisterPostTableHook(h)
+		found = true
+	}
+	if !found {
+		return fmt.Errorf("plugin %s exports neither PreTable nor PostTable", path)
+	}
+	return nil
+}
+
+// tableHookInput is the input of table hook commands (see
+// CommandTableHook).
+type tableHookInput struct {
+	SrcTable string
+	Table    ddl.CreateTable
+}
+
+// CommandTableHook returns a PostTableHook that runs command (a program
+// and its arguments, separated by spaces) for each converted table. The
+// command reads a JSON object with fields SrcTable (the source table
+// name) and Table (the Spanner table, as in session files) from its
+// standard input, and writes the table to use instead to its standard
+// output, or nothing to keep it unchanged. A non-zero exit status rejects
+// the table, and aborts schema conversion.
+func CommandTableHook(command string) internal.PostTableHook {
+	args := strings.Fields(command)
+	return func(srcTable string, ct *ddl.CreateTable) error {
+		if len(args) == 0 {
+			return fmt.Errorf("empty hook command")
+		}
+		in, err := json.Marshal(tableHookInput{SrcTable: srcTable, Table: *ct})
+		if err != nil {
+			return err
+		}
+		cmd := exec.Command(args[0], args[1:]...)
+		cmd.Stdin = strings.NewReader(string(in))
+		cmd.Stderr = os.Stderr
+		out, err := cmd.Output()
+		if err != nil {
+			return fmt.Errorf("hook command %q failed: %w", command, err)
+		}
+		if len(strings.TrimSpace(string(out))) == 0 {
+			return nil
+		}
+		var t ddl.CreateTable
+		if err := json.Unmarshal(out, &t); err != nil {
+			return fmt.Errorf("can't parse output of hook command %q: %w", command, err)
+		}
+		*ct = t
+		return nil
+	}
+}
+
 // WriteBadData prints summary stats about bad rows and writes detailed info
 // to file 'name'.
 func WriteBadData(bw *spanner.BatchWriter, conv *internal.Conv, banner, name string, out *os.File) {
@@ -778,6 +1575,14 @@ Possible cause: Spanner instance specified via instance option does not exist.
 Please check that '%s' is correct and that it is a valid Spanner
 instance for project %s.
 `, err, instance, project)
+	}
+	if containsAny(e, []string{"cloudkms", "kms key"}) {
+		return fmt.Errorf("%w.\n"+`
+Possible cause: Spanner can't use the KMS key. Check that the key is enabled,
+and that the Spanner service agent of project %s
+(service-<project number>@gcp-sa-spanner.iam.gserviceaccount.com) has role
+roles/cloudkms.cryptoKeyEncrypterDecrypter on the key.
+`, err, project)
 	}
 	return err
 }
@@ -823,10 +1628,10 @@ func generateName(prefix string) (string, error) {
 	return fmt.Sprintf("%s_%x-%x", prefix, b[0:2], b[2:4]), nil
 }
 
-// GetClient returns new spanner client.
-func GetClient(db string) (*sp.Client, error) {
+// GetClient returns new spanner client, configured using opts.
+func GetClient(db string, opts SpannerOptions) (*sp.Client, error) {
 	ctx := context.Background()
-	return sp.NewClient(ctx, db)
+	return sp.NewClientWithConfig(ctx, db, opts.clientConfig(), opts.clientOptions()...)
 }
 
 func getSize(f *os.File) (int64, error) {
@@ -838,14 +1643,13 @@ func getSize(f *os.File) (int64, error) {
 }
 
 // SetupLogFile configures the file used for logs.
-// By default we just drop logs on the floor. To enable them (e.g. to debug
-// Cloud Spanner client library issues), set logfile to a non-empty filename.
+// If logfile is empty, we just drop logs on the floor. Runs with a
+// workspace keep them in its logs directory (e.g. to debug Cloud Spanner
+// client library issues).
 // Note: this tool itself doesn't generate logs, but some of the libraries it
 // uses do. If we don't set the log file, we see a number of unhelpful and
 // unactionable logs spamming stdout, which is annoying and confusing.
-func SetupLogFile() (*os.File, error) {
-	// To enable debug logs, set logfile to a non-empty filename.
-	logfile := ""
+func SetupLogFile(logfile string) (*os.File, error) {
 	if logfile == "" {
 		log.SetOutput(ioutil.Discard)
 		return nil, nil
@@ -890,6 +1694,188 @@ func ProcessDump(driver string, conv *internal.Conv, r *internal.Reader) error {
 	}
 }
 
+// MaskDump reads the dump in ioHelper.In, and writes it to the file name
+// with the values of the source columns in masks replaced (see
+// internal.MaskRule), so that sanitized dumps can be loaded in test
+// databases. The dump is read twice: once for its schema, which masks are
+// checked against, then to mask it. Nothing is written to Spanner.
+func MaskDump(driver string, masks map[string]internal.MaskRule, ioHelper *IOStreams, name string) error {
+	conv, err := schemaFromDump(driver, TARGET_SPANNER, nil, ioHelper, internal.NameMapping{})
+	if err != nil {
+		return err
+	}
+	defer ioHelper.In.Close()
+	conv.Policies.Masks = masks
+	if err := conv.CheckMasks(); err != nil {
+		return err
+	}
+	if _, err := ioHelper.SeekableIn.Seek(0, 0); err != nil {
+		return fmt.Errorf("can't seek to start of file: %w", err)
+	}
+	f, err := os.Create(name)
+	if err != nil {
+		return fmt.Errorf("can't create masked dump %s: %w", name, err)
+	}
+	w := bufio.NewWriter(f)
+	p := conv.NewProgress(ioHelper.BytesRead, "Masking dump")
+	r := internal.NewReader(bufio.NewReader(ioHelper.SeekableIn), p)
+	conv.SetDataMode()
+	switch driver {
+	case MYSQLDUMP:
+		err = mysql.MaskMySQLDump(conv, r, w)
+	case PGDUMP:
+		err = postgres.MaskPgDump(conv, r, w)
+	default:
+		err = fmt.Errorf("masking dumps for driver %s not supported", driver)
+	}
+	if err == nil {
+		err = w.Flush()
+	}
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return fmt.Errorf("can't write masked dump %s: %w", name, err)
+	}
+	p.Done()
+	fmt.Fprintf(ioHelper.Out, "Wrote masked dump to file '%s'.\n", name)
+	return nil
+}
+
+// ReadMasksFile reads a JSON file containing the masks of source
+// columns, by table.column (see internal.MaskRule).
+func ReadMasksFile(name string) (map[string]internal.MaskRule, error) {
+	s, err := ioutil.ReadFile(name)
+	if err != nil {
+		return nil, err
+	}
+	var masks map[string]internal.MaskRule
+	if err := json.Unmarshal(s, &masks); err != nil {
+		return nil, fmt.Errorf("can't parse masks file %s: %w", name, err)
+	}
+	return masks, nil
+}
+
+// ReadDateRulesFile reads a JSON file containing a list of rules
+// remapping sentinel values of DATE and TIMESTAMP columns e.g.
+// [{"Match": "9999-12-31"}, {"Columns": ["users.birth_date"], "Match":
+// "1900-01-01"}] (see internal.DateRule).
+func ReadDateRulesFile(name string) ([]internal.DateRule, error) {
+	s, err := ioutil.ReadFile(name)
+	if err != nil {
+		return nil, err
+	}
+	var rules []internal.DateRule
+	if err := json.Unmarshal(s, &rules); err != nil {
+		return nil, fmt.Errorf("can't parse date rules file %s: %w", name, err)
+	}
+	return rules, nil
+}
+
+// ReadValueDictionariesFile reads a JSON file containing a list of rules
+// validating and normalizing the values of STRING columns against value
+// dictionaries e.g. [{"Columns": ["claims.diagnosis"], "File":
+// "icd10.csv", "IgnoreChars": ".", "Unknown": "reject"}] (see
+// internal.ValueDictionaryRule). The dictionaries of rules with a File
+// are read from their CSV file (relative to the directory of name), and
+// registered.
+func ReadValueDictionariesFile(name string) ([]internal.ValueDictionaryRule, error) {
+	s, err := ioutil.ReadFile(name)
+	if err != nil {
+		return nil, err
+	}
+	var rules []internal.ValueDictionaryRule
+	if err := json.Unmarshal(s, &rules); err != nil {
+		return nil, fmt.Errorf("can't parse value dictionaries file %s: %w", name, err)
+	}
+	for i, r := range rules {
+		if r.File == "" {
+			continue
+		}
+		file := r.File
+		if !filepath.IsAbs(file) {
+			file = filepath.Join(filepath.Dir(name), file)
+		}
+		d, err := readCodeListFile(file, r.IgnoreCase, r.IgnoreChars)
+		if err != nil {
+			return nil, err
+		}
+		if r.Dictionary == "" {
+			rules[i].Dictionary = r.File
+		}
+		internal.RegisterValueDictionary(rules[i].Dictionary, d)
+	}
+	return rules, nil
+}
+
+// readCodeListFile reads a CSV file listing codes, one per line, each
+// followed by its aliases (see internal.CodeList). Lines starting with #
+// are comments.
+func readCodeListFile(name string, ignoreCase bool, ignoreChars string) (*internal.CodeList, error) {
+	f, err := os.Open(name)
+	if err != nil {
+		return nil, fmt.Errorf("can't read value dictionary %s: %w", name, err)
+	}
+	defer f.Close()
+	r := csv.NewReader(f)
+	r.Comment = '#'
+	r.FieldsPerRecord = -1
+	r.TrimLeadingSpace = true
+	records, err := r.ReadAll()
+	if err != nil {
+		return nil, fmt.Errorf("can't parse value dictionary %s: %w", name, err)
+	}
+	codes := make(map[string][]string)
+	for _, rec := range records {
+		code := strings.TrimSpace(rec[0])
+		if code == "" {
+			continue
+		}
+		for _, alias := range rec[1:] {
+			if alias = strings.TrimSpace(alias); alias != "" {
+				codes[code] = append(codes[code], alias)
+			}
+		}
+		if _, ok := codes[code]; !ok {
+			codes[code] = nil
+		}
+	}
+	l, err := internal.NewCodeList(codes, ignoreCase, ignoreChars)
+	if err != nil {
+		return nil, fmt.Errorf("bad value dictionary %s: %w", name, err)
+	}
+	return l, nil
+}
+
+// ReadTableWritesFile reads a JSON file containing the limits on the
+// writes of Spanner tables, by table e.g. {"users": {"writes": 2,
+// "batch_rows": 500}} (see spanner.TableLimit).
+func ReadTableWritesFile(name string) (map[string]spanner.TableLimit, error) {
+	s, err := ioutil.ReadFile(name)
+	if err != nil {
+		return nil, err
+	}
+	var limits map[string]spanner.TableLimit
+	if err := json.Unmarshal(s, &limits); err != nil {
+		return nil, fmt.Errorf("can't parse table writes file %s: %w", name, err)
+	}
+	return limits, nil
+}
+
+// ReadNameDictionary reads a JSON file mapping words of source names to
+// their replacement in Spanner names (see internal.NameMapping).
+func ReadNameDictionary(name string) (map[string]string, error) {
+	s, err := ioutil.ReadFile(name)
+	if err != nil {
+		return nil, err
+	}
+	var dictionary map[string]string
+	if err := json.Unmarshal(s, &dictionary); err != nil {
+		return nil, fmt.Errorf("can't parse name dictionary %s: %w", name, err)
+	}
+	return dictionary, nil
+}
+
 // ProcessInfoSchema invokes process infoschema function from a sql package based on driver selected.
 func ProcessInfoSchema(driver string, conv *internal.Conv, db *sql.DB) error {
 	switch driver {
@@ -902,8 +1888,31 @@ func ProcessInfoSchema(driver string, conv *internal.Conv, db *sql.DB) error {
 	}
 }
 
+// Capabilities returns the type mappings and dropped constructs of
+// driver, when converting to targetDb with features. The flags that apply
+// to the driver are left to the caller.
+func Capabilities(driver, targetDb string, features internal.Features) (internal.Capabilities, error) {
+	var c internal.Capabilities
+	var err error
+	if targetDb == TARGET_EXPERIMENTAL_POSTGRES && !(driver == PGDUMP || driver == POSTGRES) {
+		return c, fmt.Errorf("can only convert to experimental postgres when source is %s or %s (driver: %s)", PGDUMP, POSTGRES, driver)
+	}
+	switch driver {
+	case POSTGRES, PGDUMP:
+		c, err = postgres.Capabilities(targetDb, features)
+	case MYSQL, MYSQLDUMP:
+		c, err = mysql.Capabilities(targetDb, features)
+	case DYNAMODB:
+		c, err = dynamodb.Capabilities(targetDb, features)
+	default:
+		return c, fmt.Errorf("driver %s not supported", driver)
+	}
+	c.Driver = driver
+	return c, err
+}
+
 // SetRowStats invokes SetRowStats function from a sql package based on driver selected.
-func SetRowStats(driver string, conv *internal.Conv, db *sql.DB) error {
+func SetRowStats(driver string, conv *internal.Conv, db internal.Queryer) error {
 	switch driver {
 	case MYSQL:
 		mysql.SetRowStats(conv, db, os.Getenv("MYSQLDATABASE"))
@@ -916,7 +1925,7 @@ func SetRowStats(driver string, conv *internal.Conv, db *sql.DB) error {
 }
 
 // ProcessSQLData invokes ProcessSQLData function from a sql package based on driver selected.
-func ProcessSQLData(driver string, conv *internal.Conv, db *sql.DB) error {
+func ProcessSQLData(driver string, conv *internal.Conv, db internal.Queryer) error {
 	switch driver {
 	case MYSQL:
 		mysql.ProcessSQLData(conv, db, os.Getenv("MYSQLDATABASE"))
@@ -0,0 +1,228 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package conversion
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"os/exec"
+	"strings"
+	"time"
+
+	"github.com/cloudspannerecosystem/harbourbridge/internal"
+	"github.com/cloudspannerecosystem/harbourbridge/mysql"
+	"github.com/cloudspannerecosystem/harbourbridge/postgres"
+)
+
+// CutoverConfig configures the steps of a cutover (see Cutover). Empty
+// commands, statements and URLs skip the corresponding step.
+type CutoverConfig struct {
+	Database string // Spanner database URI.
+	// StopCDC is a command (a program and its arguments, separated by
+	// spaces) that stops the process applying changes to Spanner, once
+	// it has applied the changes it has received.
+	StopCDC string
+	// DrainTimeout is how long to wait for Spanner to catch up with the
+	// source, checking every DrainInterval.
+	DrainTimeout  time.Duration
+	DrainInterval time.Duration
+	// ReadOnlySQL is a statement run on the source database to make it
+	// read-only e.g. "ALTER DATABASE mydb SET default_transaction_read_only = on".
+	ReadOnlySQL string
+	// Webhook is a URL that is sent a POST request, with the JSON
+	// CutoverEvent as body, to switch applications to Spanner e.g. by
+	// flipping a feature flag or a DNS record. Any status other than 2xx
+	// is an error.
+	Webhook string
+}
+
+// CutoverEvent is the body of the request sent to CutoverConfig.Webhook.
+type CutoverEvent struct {
+	Event    string    `json:"event"` // Always "cutover".
+	Database string    `json:"database"`
+	Time     time.Time `json:"time"`
+}
+
+// CutoverStep is a step of a cutover, with its timing.
+type CutoverStep struct {
+	Name            string    `json:"name"`
+	Start           time.Time `json:"start"`
+	DurationSeconds float64   `json:"duration_seconds"`
+	Skipped         bool      `json:"skipped,omitempty"`
+	Detail          string    `json:"detail,omitempty"`
+	Error           string    `json:"error,omitempty"`
+}
+
+// CutoverReport is the result of a cutover.
+type CutoverReport struct {
+	Database        string        `json:"database"`
+	Start           time.Time     `json:"start"`
+	DurationSeconds float64       `json:"duration_seconds"`
+	Steps           []CutoverStep `json:"steps"`
+	// Completed is true if all steps succeeded, and applications were
+	// switched to Spanner.
+	Completed bool `json:"completed"`
+}
+
+// Cutover runs the final steps of a migration with continuous
+// replication, in order: stop applying changes to Spanner, drain (wait
+// until recent rows are consistent, see Validator), verify (check recent
+// rows and row counts once more), make the source read-only, and switch
+// applications to Spanner. Cutover stops at the first step that fails,
+// so applications are only switched once Spanner has been verified. The
+// report lists the steps that ran.
+func Cutover(cfg CutoverConfig, v *Validator) (CutoverReport, error) {
+	r := CutoverReport{Database: cfg.Database, Start: time.Now()}
+	steps := []struct {
+		name string
+		skip bool
+		run  func() (string, error)
+	}{
+		{"stop-cdc", cfg.StopCDC == "", func() (string, error) { return runCommand(cfg.StopCDC) }},
+		{"drain", false, func() (string, error) { return drain(v, cfg.DrainTimeout, cfg.DrainInterval) }},
+		{"verify", false, func() (string, error) { return verify(v) }},
+		{"source-read-only", cfg.ReadOnlySQL == "", func() (string, error) { return v.exec(cfg.ReadOnlySQL) }},
+		{"switch", cfg.Webhook == "", func() (string, error) { return callWebhook(cfg.Webhook, cfg.Database) }},
+	}
+	var err error
+	for _, s := range steps {
+		step := CutoverStep{Name: s.name, Start: time.Now(), Skipped: s.skip}
+		if !s.skip {
+			step.Detail, err = s.run()
+		}
+		step.DurationSeconds = time.Since(step.Start).Seconds()
+		if err != nil {
+			step.Error = err.Error()
+		}
+		r.Steps = append(r.Steps, step)
+		if err != nil {
+			err = fmt.Errorf("cutover step %s failed: %w", s.name, err)
+			break
+		}
+	}
+	r.DurationSeconds = time.Since(r.Start).Seconds()
+	r.Completed = err == nil
+	return r, err
+}
+
+func runCommand(command string) (string, error) {
+	args := strings.Fields(command)
+	cmd := exec.Command(args[0], args[1:]...)
+	cmd.Stderr = os.Stderr
+	out, err := cmd.Output()
+	if err != nil {
+		return "", fmt.Errorf("command %q failed: %w", command, err)
+	}
+	return strings.TrimSpace(string(out)), nil
+}
+
+// drain runs rounds of validation until one is consistent.
+func drain(v *Validator, timeout, interval time.Duration) (string, error) {
+	deadline := time.Now().Add(timeout)
+	for rounds := 1; ; rounds++ {
+		r := v.Round(time.Now())
+		if r.Consistent {
+			return fmt.Sprintf("consistent after %d round(s)", rounds), nil
+		}
+		if time.Now().Add(interval).After(deadline) {
+			return "", fmt.Errorf("still inconsistent after %v (%s)", timeout, inconsistencies(r))
+		}
+		time.Sleep(interval)
+	}
+}
+
+// verify checks recent rows, and the row counts of source tables that are
+// migrated to a Spanner table of their own.
+func verify(v *Validator) (string, error) {
+	if r := v.Round(time.Now()); !r.Consistent {
+		return "", fmt.Errorf("recent rows are inconsistent (%s)", inconsistencies(r))
+	}
+	var checked int
+	var failed []string
+	for _, t := range internal.BuildLineage("", v.conv).Tables {
+		if len(t.SrcTables) != 1 || t.Note != "" {
+			continue
+		}
+		srcRows, err := v.countRows(t.SrcTables[0])
+		if err != nil {
+			return "", err
+		}
+		spRows, err := countRows(v.client, v.conv, t.SpTable)
+		if err != nil {
+			return "", fmt.Errorf("can't count rows of Spanner table %s: %w", t.SpTable, err)
+		}
+		checked++
+		if srcRows != spRows {
+			failed = append(failed, fmt.Sprintf("%s: %d rows, expected %d", t.SpTable, spRows, srcRows))
+		}
+	}
+	if len(failed) > 0 {
+		return "", fmt.Errorf("row counts differ: %s", strings.Join(failed, "; "))
+	}
+	return fmt.Sprintf("recent rows consistent, row counts of %d table(s) match", checked), nil
+}
+
+func inconsistencies(r ValidationRound) string {
+	var l []string
+	for _, t := range r.Tables {
+		switch {
+		case t.Error != "":
+			l = append(l, fmt.Sprintf("%s: %s", t.Table, t.Error))
+		case t.Missing+t.Mismatched > 0:
+			l = append(l, fmt.Sprintf("%s: %d missing, %d mismatched", t.Table, t.Missing, t.Mismatched))
+		}
+	}
+	return strings.Join(l, "; ")
+}
+
+func callWebhook(url, db string) (string, error) {
+	body, err := json.Marshal(CutoverEvent{Event: "cutover", Database: db, Time: time.Now()})
+	if err != nil {
+		return "", err
+	}
+	client := http.Client{Timeout: time.Minute}
+	resp, err := client.Post(url, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		return "", fmt.Errorf("webhook %s returned %s", url, resp.Status)
+	}
+	return resp.Status, nil
+}
+
+// countRows returns the number of rows of srcTable in the source database.
+func (v *Validator) countRows(srcTable string) (int64, error) {
+	if v.driver == MYSQL {
+		return mysql.CountRows(v.db, os.Getenv("MYSQLDATABASE"), srcTable)
+	}
+	return postgres.CountRows(v.db, srcTable)
+}
+
+// exec runs statement on the source database.
+func (v *Validator) exec(statement string) (string, error) {
+	res, err := v.db.Exec(statement)
+	if err != nil {
+		return "", err
+	}
+	if n, err := res.RowsAffected(); err == nil && n > 0 {
+		return fmt.Sprintf("%d rows affected", n), nil
+	}
+	return "", nil
+}
@@ -0,0 +1,103 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package conversion
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCutover_StopsAtFailedStep(t *testing.T) {
+	// Steps after the failed one, which need the validator, aren't run.
+	r, err := Cutover(CutoverConfig{Database: "projects/p/instances/i/databases/d", StopCDC: "false"}, nil)
+	assert.NotNil(t, err)
+	assert.Contains(t, err.Error(), "cutover step stop-cdc failed")
+	assert.False(t, r.Completed)
+	assert.Len(t, r.Steps, 1)
+	assert.Equal(t, "stop-cdc", r.Steps[0].Name)
+	assert.NotEmpty(t, r.Steps[0].Error)
+}
+
+func TestRunCommand(t *testing.T) {
+	out, err := runCommand("echo  stopped   cdc ")
+	assert.Nil(t, err)
+	assert.Equal(t, "stopped cdc", out)
+	_, err = runCommand("false")
+	assert.NotNil(t, err)
+	_, err = runCommand("/nonexistent/stop-cdc")
+	assert.NotNil(t, err)
+}
+
+func TestCallWebhook(t *testing.T) {
+	var events []CutoverEvent
+	status := http.StatusOK
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var e CutoverEvent
+		assert.Nil(t, json.NewDecoder(r.Body).Decode(&e))
+		events = append(events, e)
+		w.WriteHeader(status)
+	}))
+	defer server.Close()
+
+	out, err := callWebhook(server.URL, "projects/p/instances/i/databases/d")
+	assert.Nil(t, err)
+	assert.Equal(t, "200 OK", out)
+	status = http.StatusServiceUnavailable
+	_, err = callWebhook(server.URL, "projects/p/instances/i/databases/d")
+	assert.EqualError(t, err, fmt.Sprintf("webhook %s returned 503 Service Unavailable", server.URL))
+	assert.Len(t, events, 2)
+	assert.Equal(t, "cutover", events[0].Event)
+	assert.Equal(t, "projects/p/instances/i/databases/d", events[0].Database)
+
+	server.Close()
+	_, err = callWebhook(server.URL, "projects/p/instances/i/databases/d")
+	assert.NotNil(t, err)
+}
+
+func TestInconsistencies(t *testing.T) {
+	r := ValidationRound{Tables: []TableValidation{
+		{Table: "a", Checked: 10},
+		{Table: "b", Checked: 10, Missing: 2, Mismatched: 1},
+		{Table: "c", Error: "can't read rows"},
+	}}
+	assert.Equal(t, "b: 2 missing, 1 mismatched; c: can't read rows", inconsistencies(r))
+	assert.Equal(t, "", inconsistencies(ValidationRound{}))
+}
+
+func TestValidatorExec(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.Nil(t, err)
+	defer db.Close()
+	v := &Validator{db: db}
+	mock.ExpectExec("ALTER DATABASE mydb SET default_transaction_read_only = on").WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec("UPDATE flags SET read_only = true").WillReturnResult(sqlmock.NewResult(0, 3))
+	mock.ExpectExec("ALTER DATABASE mydb").WillReturnError(fmt.Errorf("permission denied"))
+
+	out, err := v.exec("ALTER DATABASE mydb SET default_transaction_read_only = on")
+	assert.Nil(t, err)
+	assert.Equal(t, "", out)
+	out, err = v.exec("UPDATE flags SET read_only = true")
+	assert.Nil(t, err)
+	assert.Equal(t, "3 rows affected", out)
+	_, err = v.exec("ALTER DATABASE mydb SET default_transaction_read_only = on")
+	assert.EqualError(t, err, "permission denied")
+	assert.Nil(t, mock.ExpectationsWereMet())
+}
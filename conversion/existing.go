@@ -0,0 +1,140 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package conversion
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+	"time"
+
+	database "cloud.google.com/go/spanner/admin/database/apiv1"
+	adminpb "google.golang.org/genproto/googleapis/spanner/admin/database/v1"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+
+	"github.com/cloudspannerecosystem/harbourbridge/internal"
+)
+
+var createTableRegexp = regexp.MustCompile("(?is)^\\s*CREATE\\s+TABLE\\s+(?:IF\\s+NOT\\s+EXISTS\\s+)?`?([A-Za-z_][A-Za-z0-9_]*)`?")
+
+// PrepareDatabase returns the Spanner database to migrate to: a new
+// database dbName, or, if dbName exists, the existing database with the
+// schema of conv added to it. To avoid mixing the migrated tables with
+// unrelated data by mistake, existing databases that have tables are only
+// used if opts.AllowExisting is set, and if opts.BackupExisting is set,
+// they are backed up first.
+func PrepareDatabase(project, instance, dbName string, conv *internal.Conv, opts SpannerOptions, out *os.File) (string, error) {
+	ctx := context.Background()
+	adminClient, err := database.NewDatabaseAdminClient(ctx)
+	if err != nil {
+		return "", fmt.Errorf("can't create admin client: %w", analyzeError(err, project, instance))
+	}
+	defer adminClient.Close()
+	db := fmt.Sprintf("projects/%s/instances/%s/databases/%s", project, instance, dbName)
+	existing, err := adminClient.GetDatabase(ctx, &adminpb.GetDatabaseRequest{Name: db})
+	if status.Code(err) == codes.NotFound {
+		return CreateDatabase(project, instance, dbName, opts.KMSKey, conv, out)
+	}
+	if err != nil {
+		return "", fmt.Errorf("can't check whether database %s exists: %w", dbName, analyzeError(err, project, instance))
+	}
+	resp, err := adminClient.GetDatabaseDdl(ctx, &adminpb.GetDatabaseDdlRequest{Database: db})
+	if err != nil {
+		return "", fmt.Errorf("can't get schema of database %s: %w", dbName, analyzeError(err, project, instance))
+	}
+	tables := existingTables(resp.Statements)
+	if len(tables) > 0 && !opts.AllowExisting {
+		return "", fmt.Errorf("database %s already exists and has %d tables (%s): use -allow-existing to add the migrated tables to it, or choose another database with -dbname",
+			dbName, len(tables), strings.Join(tables, ", "))
+	}
+	for _, t := range tables {
+		if _, ok := conv.SpSchema[t]; ok {
+			return "", fmt.Errorf("database %s already has a table %s", dbName, t)
+		}
+	}
+	if opts.KMSKey != "" {
+		if ec := existing.EncryptionConfig; ec == nil || ec.KmsKeyName != opts.KMSKey {
+			return "", fmt.Errorf("database %s already exists, and isn't encrypted with KMS key %s", dbName, opts.KMSKey)
+		}
+	}
+	if len(tables) > 0 && opts.BackupExisting {
+		backupID := fmt.Sprintf("%s-%s", dbName, time.Now().UTC().Format("20060102-150405"))
+		if _, err := BackupDatabase(project, instance, dbName, backupID, opts.BackupRetention, out); err != nil {
+			return "", err
+		}
+	}
+	if err := checkDDL(conv); err != nil {
+		return "", err
+	}
+	fmt.Fprintf(out, "Adding tables to existing database %s in instance %s ... ", dbName, instance)
+	op, err := adminClient.UpdateDatabaseDdl(ctx, &adminpb.UpdateDatabaseDdlRequest{
+		Database:   db,
+		Statements: schemaStatements(conv),
+	})
+	if err != nil {
+		return "", fmt.Errorf("can't add tables to database %s: %w", dbName, analyzeError(err, project, instance))
+	}
+	if err := op.Wait(ctx); err != nil {
+		return "", fmt.Errorf("can't add tables to database %s: %w", dbName, analyzeError(err, project, instance))
+	}
+	fmt.Fprintf(out, "done.\n")
+	return db, nil
+}
+
+// existingTables returns the tables created by DDL statements stmts,
+// except MetadataTable (which is shared by migration runs).
+func existingTables(stmts []string) []string {
+	var l []string
+	for _, s := range stmts {
+		if m := createTableRegexp.FindStringSubmatch(s); m != nil && m[1] != MetadataTable {
+			l = append(l, m[1])
+		}
+	}
+	return l
+}
+
+// BackupDatabase creates backup backupID of Spanner database dbName,
+// which expires after retention, and waits for it to complete. It returns
+// the name of the backup.
+func BackupDatabase(project, instance, dbName, backupID string, retention time.Duration, out *os.File) (string, error) {
+	ctx := context.Background()
+	adminClient, err := database.NewDatabaseAdminClient(ctx)
+	if err != nil {
+		return "", fmt.Errorf("can't create admin client: %w", analyzeError(err, project, instance))
+	}
+	defer adminClient.Close()
+	fmt.Fprintf(out, "Backing up database %s to backup %s (kept for %s) ... ", dbName, backupID, retention)
+	op, err := adminClient.CreateBackup(ctx, &adminpb.CreateBackupRequest{
+		Parent:   fmt.Sprintf("projects/%s/instances/%s", project, instance),
+		BackupId: backupID,
+		Backup: &adminpb.Backup{
+			Database:   fmt.Sprintf("projects/%s/instances/%s/databases/%s", project, instance, dbName),
+			ExpireTime: timestamppb.New(time.Now().Add(retention)),
+		},
+	})
+	if err != nil {
+		return "", fmt.Errorf("can't back up database %s: %w", dbName, analyzeError(err, project, instance))
+	}
+	backup, err := op.Wait(ctx)
+	if err != nil {
+		return "", fmt.Errorf("can't back up database %s: %w", dbName, analyzeError(err, project, instance))
+	}
+	fmt.Fprintf(out, "done.\n")
+	return backup.Name, nil
+}
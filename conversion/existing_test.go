@@ -0,0 +1,53 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package conversion
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestExistingTables(t *testing.T) {
+	for _, tc := range []struct {
+		name  string
+		stmts []string
+		want  []string
+	}{
+		{"empty database", nil, nil},
+		{"tables", []string{
+			"CREATE TABLE Singers (\n\tSingerId INT64 NOT NULL,\n) PRIMARY KEY (SingerId)",
+			"CREATE INDEX SingersByName ON Singers(Name)",
+			"create table `Albums` (AlbumId INT64) PRIMARY KEY (AlbumId), INTERLEAVE IN PARENT Singers",
+			"\n  CREATE TABLE IF NOT EXISTS Songs (SongId INT64) PRIMARY KEY (SongId)",
+		}, []string{"Singers", "Albums", "Songs"}},
+		{"metadata table only", []string{"CREATE TABLE " + MetadataTable + " (Id STRING(MAX)) PRIMARY KEY (Id)"}, nil},
+		{"no tables", []string{
+			"CREATE INDEX SingersByName ON Singers(Name)",
+			"CREATE VIEW SingerNames SQL SECURITY INVOKER AS SELECT Name FROM Singers",
+			"ALTER TABLE Albums ADD CONSTRAINT FK FOREIGN KEY (SingerId) REFERENCES Singers (SingerId)",
+		}, nil},
+	} {
+		assert.Equal(t, tc.want, existingTables(tc.stmts), tc.name)
+	}
+}
+
+func TestValidateExisting(t *testing.T) {
+	assert.Nil(t, SpannerOptions{AllowExisting: true}.Validate(TARGET_SPANNER))
+	assert.Nil(t, SpannerOptions{AllowExisting: true, BackupExisting: true, BackupRetention: 24 * time.Hour}.Validate(TARGET_SPANNER))
+	assert.EqualError(t, SpannerOptions{BackupExisting: true, BackupRetention: 24 * time.Hour}.Validate(TARGET_SPANNER),
+		"backing up existing databases requires allowing existing databases")
+}
@@ -0,0 +1,114 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package conversion
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+
+	database "cloud.google.com/go/spanner/admin/database/apiv1"
+	iampb "google.golang.org/genproto/googleapis/iam/v1"
+)
+
+// defaultGrantRole is the role granted when a grant doesn't specify one:
+// it lets applications read and write data.
+const defaultGrantRole = "roles/spanner.databaseUser"
+
+// IAMGrant is an IAM role to grant to a member (e.g. the service account
+// of an application) on the new database.
+type IAMGrant struct {
+	Member string // e.g. serviceAccount:app@my-project.iam.gserviceaccount.com.
+	Role   string // e.g. roles/spanner.databaseUser.
+}
+
+// ParseIAMGrants parses a comma-separated list of grants of the form
+// member[=role]. Members without a type are service accounts, and roles
+// without a prefix are Spanner roles: for example,
+// "app@p.iam.gserviceaccount.com=databaseReader" grants
+// roles/spanner.databaseReader to serviceAccount:app@p.iam.gserviceaccount.com.
+// The default role is roles/spanner.databaseUser.
+func ParseIAMGrants(s string) ([]IAMGrant, error) {
+	var l []IAMGrant
+	for _, e := range strings.Split(s, ",") {
+		e = strings.TrimSpace(e)
+		if e == "" {
+			continue
+		}
+		g := IAMGrant{Member: e, Role: defaultGrantRole}
+		if i := strings.LastIndex(e, "="); i >= 0 {
+			g.Member, g.Role = strings.TrimSpace(e[:i]), strings.TrimSpace(e[i+1:])
+		}
+		if g.Member == "" || g.Role == "" {
+			return nil, fmt.Errorf("bad grant %q: must be of the form member[=role]", e)
+		}
+		if !strings.Contains(g.Member, ":") {
+			g.Member = "serviceAccount:" + g.Member
+		}
+		if !strings.HasPrefix(g.Role, "roles/") {
+			g.Role = "roles/spanner." + g.Role
+		}
+		l = append(l, g)
+	}
+	return l, nil
+}
+
+// GrantDatabaseRoles adds grants to the IAM policy of Spanner database
+// dbName, keeping its existing bindings.
+func GrantDatabaseRoles(project, instance, dbName string, grants []IAMGrant, out *os.File) error {
+	if len(grants) == 0 {
+		return nil
+	}
+	ctx := context.Background()
+	adminClient, err := database.NewDatabaseAdminClient(ctx)
+	if err != nil {
+		return fmt.Errorf("can't create admin client: %w", analyzeError(err, project, instance))
+	}
+	defer adminClient.Close()
+	db := fmt.Sprintf("projects/%s/instances/%s/databases/%s", project, instance, dbName)
+	policy, err := adminClient.GetIamPolicy(ctx, &iampb.GetIamPolicyRequest{Resource: db})
+	if err != nil {
+		return fmt.Errorf("can't get IAM policy of database %s: %w", dbName, analyzeError(err, project, instance))
+	}
+	for _, g := range grants {
+		addBinding(policy, g)
+	}
+	// The policy's etag makes this fail if the policy changed since we
+	// read it, rather than overwrite the change.
+	if _, err := adminClient.SetIamPolicy(ctx, &iampb.SetIamPolicyRequest{Resource: db, Policy: policy}); err != nil {
+		return fmt.Errorf("can't set IAM policy of database %s: %w", dbName, analyzeError(err, project, instance))
+	}
+	for _, g := range grants {
+		fmt.Fprintf(out, "Granted %s to %s on database %s.\n", g.Role, g.Member, dbName)
+	}
+	return nil
+}
+
+func addBinding(policy *iampb.Policy, g IAMGrant) {
+	for _, b := range policy.Bindings {
+		if b.Role != g.Role || b.Condition != nil {
+			continue
+		}
+		for _, m := range b.Members {
+			if m == g.Member {
+				return
+			}
+		}
+		b.Members = append(b.Members, g.Member)
+		return
+	}
+	policy.Bindings = append(policy.Bindings, &iampb.Binding{Role: g.Role, Members: []string{g.Member}})
+}
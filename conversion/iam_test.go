@@ -0,0 +1,71 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package conversion
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	iampb "google.golang.org/genproto/googleapis/iam/v1"
+	"google.golang.org/genproto/googleapis/type/expr"
+)
+
+func TestParseIAMGrants(t *testing.T) {
+	for _, tc := range []struct {
+		name    string
+		s       string
+		want    []IAMGrant
+		wantErr bool
+	}{
+		{name: "empty", s: ""},
+		{name: "default role", s: "app@p.iam.gserviceaccount.com",
+			want: []IAMGrant{{"serviceAccount:app@p.iam.gserviceaccount.com", "roles/spanner.databaseUser"}}},
+		{name: "short role", s: "app@p.iam.gserviceaccount.com=databaseReader",
+			want: []IAMGrant{{"serviceAccount:app@p.iam.gserviceaccount.com", "roles/spanner.databaseReader"}}},
+		{name: "full names", s: " user:ana@example.com = roles/spanner.databaseAdmin , group:eng@example.com=roles/spanner.fineGrainedAccessUser,",
+			want: []IAMGrant{
+				{"user:ana@example.com", "roles/spanner.databaseAdmin"},
+				{"group:eng@example.com", "roles/spanner.fineGrainedAccessUser"},
+			}},
+		{name: "no member", s: "=databaseReader", wantErr: true},
+		{name: "no role", s: "app@p.iam.gserviceaccount.com=", wantErr: true},
+		{name: "one bad grant", s: "user:ana@example.com,=databaseReader", wantErr: true},
+	} {
+		got, err := ParseIAMGrants(tc.s)
+		if tc.wantErr {
+			assert.NotNil(t, err, tc.name)
+			continue
+		}
+		assert.Nil(t, err, tc.name)
+		assert.Equal(t, tc.want, got, tc.name)
+	}
+}
+
+func TestAddBinding(t *testing.T) {
+	policy := &iampb.Policy{Bindings: []*iampb.Binding{
+		{Role: "roles/spanner.databaseReader", Members: []string{"user:ana@example.com"}, Condition: &expr.Expr{Expression: "request.time < timestamp('2022-01-01T00:00:00Z')"}},
+		{Role: "roles/spanner.databaseUser", Members: []string{"user:ana@example.com"}},
+	}}
+	addBinding(policy, IAMGrant{"user:ana@example.com", "roles/spanner.databaseUser"})
+	addBinding(policy, IAMGrant{"serviceAccount:app@p.iam.gserviceaccount.com", "roles/spanner.databaseUser"})
+	// Conditional bindings are kept as is.
+	addBinding(policy, IAMGrant{"serviceAccount:app@p.iam.gserviceaccount.com", "roles/spanner.databaseReader"})
+	assert.Len(t, policy.Bindings, 3)
+	assert.Equal(t, []string{"user:ana@example.com"}, policy.Bindings[0].Members)
+	assert.Equal(t, []string{"user:ana@example.com", "serviceAccount:app@p.iam.gserviceaccount.com"}, policy.Bindings[1].Members)
+	assert.Equal(t, "roles/spanner.databaseReader", policy.Bindings[2].Role)
+	assert.Nil(t, policy.Bindings[2].Condition)
+	assert.Equal(t, []string{"serviceAccount:app@p.iam.gserviceaccount.com"}, policy.Bindings[2].Members)
+}
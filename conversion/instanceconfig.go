@@ -0,0 +1,136 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package conversion
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	instance "cloud.google.com/go/spanner/admin/instance/apiv1"
+	instancepb "google.golang.org/genproto/googleapis/spanner/admin/instance/v1"
+)
+
+// InstanceConfig describes the configuration of a Spanner instance,
+// as relevant to data conversion.
+type InstanceConfig struct {
+	Name            string   // Short name of the instance config e.g. regional-us-central1, nam3 or custom-....
+	DisplayName     string   // Display name of the instance config.
+	Locations       []string // Distinct replica locations, in the order returned by Spanner.
+	LeaderLocation  string   // Default leader location; empty if unknown.
+	ProcessingUnits int32    // Compute capacity of the instance.
+}
+
+// GetInstanceConfig returns the configuration of the given instance.
+func GetInstanceConfig(project, instanceID string) (*InstanceConfig, error) {
+	ctx := context.Background()
+	client, err := instance.NewInstanceAdminClient(ctx)
+	if err != nil {
+		return nil, analyzeError(err, project, instanceID)
+	}
+	defer client.Close()
+	inst, err := client.GetInstance(ctx, &instancepb.GetInstanceRequest{Name: fmt.Sprintf("projects/%s/instances/%s", project, instanceID)})
+	if err != nil {
+		return nil, analyzeError(err, project, instanceID)
+	}
+	ic, err := client.GetInstanceConfig(ctx, &instancepb.GetInstanceConfigRequest{Name: inst.Config})
+	if err != nil {
+		return nil, analyzeError(err, project, instanceID)
+	}
+	cfg := &InstanceConfig{
+		Name:            ic.Name[strings.LastIndex(ic.Name, "/")+1:],
+		DisplayName:     ic.DisplayName,
+		ProcessingUnits: processingUnits(inst),
+	}
+	seen := make(map[string]bool)
+	for _, r := range ic.Replicas {
+		if !seen[r.Location] {
+			seen[r.Location] = true
+			cfg.Locations = append(cfg.Locations, r.Location)
+		}
+		if r.DefaultLeaderLocation {
+			cfg.LeaderLocation = r.Location
+		}
+	}
+	return cfg, nil
+}
+
+// MultiRegion returns true if the instance has replicas in more than
+// one region.
+func (cfg *InstanceConfig) MultiRegion() bool {
+	return len(cfg.Locations) > 1
+}
+
+// Custom returns true if cfg is a custom (user-managed) instance config.
+func (cfg *InstanceConfig) Custom() bool {
+	return strings.HasPrefix(cfg.Name, "custom-")
+}
+
+// Warnings returns warnings about the implications of the instance
+// config for data conversion.
+func (cfg *InstanceConfig) Warnings() []string {
+	if !cfg.MultiRegion() {
+		return nil
+	}
+	l := []string{fmt.Sprintf("Instance config %s is multi-region (replicas in %s). Each commit must be acknowledged "+
+		"by replicas in more than one region, so commits typically take tens of milliseconds longer than in a "+
+		"regional instance and data conversion will be slower. Consider increasing write concurrency "+
+		"(e.g. -max-sessions), or loading into a regional instance and then moving the database.",
+		cfg.Name, strings.Join(cfg.Locations, ", "))}
+	if cfg.LeaderLocation != "" {
+		l = append(l, fmt.Sprintf("For best write throughput, run HarbourBridge in (or close to) %s, the default leader region of instance config %s.",
+			cfg.LeaderLocation, cfg.Name))
+	}
+	if cfg.Custom() {
+		l = append(l, fmt.Sprintf("Instance config %s is a custom config: check that its default leader is the region where most writes will come from.", cfg.Name))
+	}
+	return l
+}
+
+// CheckKMSKey checks that Cloud KMS key (see SpannerOptions.KMSKey) can
+// encrypt databases of instance config cfg: Spanner requires the key to
+// be in the same location as the instance config, e.g. us-central1 for
+// regional-us-central1 and nam3 for nam3. Keys of custom configs aren't
+// checked.
+func (cfg *InstanceConfig) CheckKMSKey(key string) error {
+	m := kmsKeyRegexp.FindStringSubmatch(key)
+	if m == nil {
+		return fmt.Errorf("invalid KMS key %q", key)
+	}
+	if cfg.Custom() {
+		return nil
+	}
+	want := strings.TrimPrefix(cfg.Name, "regional-")
+	if m[1] != want {
+		return fmt.Errorf("KMS key %s is in location %s, but databases of instance config %s must use a key in location %s", key, m[1], cfg.Name, want)
+	}
+	return nil
+}
+
+// Summary returns a short description of cfg for the conversion report.
+func (cfg *InstanceConfig) Summary() string {
+	kind := "regional"
+	if cfg.MultiRegion() {
+		kind = "multi-region"
+	}
+	if cfg.Custom() {
+		kind = "custom " + kind
+	}
+	s := fmt.Sprintf("Instance config: %s (%s, %s), replicas in %s", cfg.Name, cfg.DisplayName, kind, strings.Join(cfg.Locations, ", "))
+	if cfg.LeaderLocation != "" {
+		s += fmt.Sprintf(", default leader in %s", cfg.LeaderLocation)
+	}
+	return s + fmt.Sprintf("; compute capacity: %d processing units\n\n", cfg.ProcessingUnits)
+}
@@ -0,0 +1,113 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package conversion
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+)
+
+// K8sJobConfig configures the Kubernetes Jobs that load the data of a
+// migration run in phases (see WriteK8sJobs).
+type K8sJobConfig struct {
+	Database string // Spanner database name, used to name the jobs.
+	Image    string // Container image of HarbourBridge.
+	// ServiceAccount is the Kubernetes service account the jobs run as,
+	// e.g. one bound to a Google service account with Workload Identity.
+	// Empty means the default service account of the namespace.
+	ServiceAccount string
+	// Secret is a Kubernetes secret whose keys are set as environment
+	// variables of the jobs, e.g. PGHOST, PGUSER and PGPASSWORD. Empty
+	// means none.
+	Secret string
+	// Args are the arguments of HarbourBridge common to all jobs, which
+	// run DataPhase.
+	Args []string
+}
+
+// k8sJob is the manifest of a Kubernetes Job loading a shard of tables.
+// A failed job isn't retried, since its tables are partially loaded.
+const k8sJob = `apiVersion: batch/v1
+kind: Job
+metadata:
+  name: %[1]s
+  labels:
+    app: harbourbridge
+    harbourbridge/database: %[2]s
+spec:
+  backoffLimit: 0
+  template:
+    metadata:
+      labels:
+        app: harbourbridge
+        harbourbridge/database: %[2]s
+    spec:
+      restartPolicy: Never
+%[3]s      containers:
+      - name: harbourbridge
+        image: %[4]s
+        args: %[5]s
+%[6]s`
+
+var k8sNameRegexp = regexp.MustCompile("[^a-z0-9-]+")
+
+// k8sJobName returns the name of the job loading shard i of database
+// db: a DNS label of at most 63 characters.
+func k8sJobName(db string, i int) string {
+	suffix := fmt.Sprintf("-data-%d", i)
+	name := "harbourbridge-" + strings.Trim(k8sNameRegexp.ReplaceAllString(strings.ToLower(db), "-"), "-")
+	if len(name) > 63-len(suffix) {
+		name = strings.TrimRight(name[:63-len(suffix)], "-")
+	}
+	return name + suffix
+}
+
+// WriteK8sJobs writes to file name the manifests of Kubernetes Jobs
+// running DataPhase, one per shard of tables (see internal.ShardTables),
+// so that the data of very large sources is loaded in parallel across a
+// cluster. The verify phase runs once all jobs have completed.
+func WriteK8sJobs(cfg K8sJobConfig, shards [][]string, name string, out *os.File) error {
+	f, err := os.Create(name)
+	if err != nil {
+		return fmt.Errorf("can't create Kubernetes jobs file %s: %w", name, err)
+	}
+	defer f.Close()
+	var serviceAccount, env string
+	if cfg.ServiceAccount != "" {
+		serviceAccount = fmt.Sprintf("      serviceAccountName: %s\n", cfg.ServiceAccount)
+	}
+	if cfg.Secret != "" {
+		env = fmt.Sprintf("        envFrom:\n        - secretRef:\n            name: %s\n", cfg.Secret)
+	}
+	fmt.Fprintf(f, "# Kubernetes jobs loading the data of Spanner database %s in %d shards,\n# generated by HarbourBridge. Run the verify phase once they have completed.\n", cfg.Database, len(shards))
+	var tables int
+	for i, shard := range shards {
+		// JSON arrays are YAML flow sequences.
+		args, err := json.Marshal(append(append([]string{}, cfg.Args...), "-tables="+strings.Join(shard, ",")))
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(f, "---\n# Shard %d: %s\n", i+1, strings.Join(shard, ", "))
+		if _, err := fmt.Fprintf(f, k8sJob, k8sJobName(cfg.Database, i+1), cfg.Database, serviceAccount, cfg.Image, args, env); err != nil {
+			return fmt.Errorf("can't write out Kubernetes jobs file: %w", err)
+		}
+		tables += len(shard)
+	}
+	fmt.Fprintf(out, "Wrote %d Kubernetes jobs loading %d tables to file '%s': start them with 'kubectl apply -f %s'.\n", len(shards), tables, name, name)
+	return nil
+}
@@ -0,0 +1,295 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package conversion
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"time"
+
+	sp "cloud.google.com/go/spanner"
+	database "cloud.google.com/go/spanner/admin/database/apiv1"
+	adminpb "google.golang.org/genproto/googleapis/spanner/admin/database/v1"
+	"google.golang.org/grpc/codes"
+
+	"github.com/cloudspannerecosystem/harbourbridge/internal"
+)
+
+// MetadataTable is the table of the target database that records the
+// state of migration runs (see MigrationMetadata).
+const MetadataTable = "harbourbridge_migration_metadata"
+
+// MetadataTableDDL creates MetadataTable. Each run has a row for the run
+// itself (with an empty table_name), whose status is the last checkpoint
+// the run reached, and a row for each Spanner table.
+const MetadataTableDDL = "CREATE TABLE IF NOT EXISTS " + MetadataTable + ` (
+	run_id STRING(MAX) NOT NULL,
+	table_name STRING(MAX) NOT NULL,
+	source_tables ARRAY<STRING(MAX)>,
+	status STRING(MAX) NOT NULL,
+	rows_read INT64,
+	rows_converted INT64,
+	bad_rows INT64,
+	spanner_rows INT64,
+	verification STRING(MAX),
+	tool_version STRING(MAX) NOT NULL,
+	updated_at TIMESTAMP NOT NULL OPTIONS (allow_commit_timestamp=true)
+) PRIMARY KEY (run_id, table_name)`
+
+// CreateMetadataTable creates MetadataTable in Spanner database dbName,
+// unless it already exists.
+func CreateMetadataTable(project, instance, dbName string) error {
+	return createTable(project, instance, dbName, MetadataTable, MetadataTableDDL)
+}
+
+// createTable creates table in Spanner database dbName with statement
+// ddl, which must do nothing if the table already exists.
+func createTable(project, instance, dbName, table, ddl string) error {
+	ctx := context.Background()
+	adminClient, err := database.NewDatabaseAdminClient(ctx)
+	if err != nil {
+		return fmt.Errorf("can't create admin client: %w", analyzeError(err, project, instance))
+	}
+	defer adminClient.Close()
+	op, err := adminClient.UpdateDatabaseDdl(ctx, &adminpb.UpdateDatabaseDdlRequest{
+		Database:   fmt.Sprintf("projects/%s/instances/%s/databases/%s", project, instance, dbName),
+		Statements: []string{ddl},
+	})
+	if err != nil {
+		return fmt.Errorf("can't create table %s: %w", table, analyzeError(err, project, instance))
+	}
+	if err := op.Wait(ctx); err != nil {
+		return fmt.Errorf("can't create table %s: %w", table, analyzeError(err, project, instance))
+	}
+	return nil
+}
+
+// MigrationMetadata records the state of a migration run in
+// MetadataTable, so that it survives the machine running HarbourBridge
+// and can be queried during cutover reviews. Failures to update the table
+// are reported, but don't stop the run. Methods of a nil
+// *MigrationMetadata do nothing.
+type MigrationMetadata struct {
+	client *sp.Client
+	runID  string
+	out    *os.File
+}
+
+// NewMigrationMetadata returns a MigrationMetadata for the run started
+// at now, which writes to MetadataTable using client.
+func NewMigrationMetadata(client *sp.Client, now time.Time, out *os.File) *MigrationMetadata {
+	return &MigrationMetadata{client: client, runID: now.UTC().Format(time.RFC3339Nano), out: out}
+}
+
+// Checkpoint records that the run reached checkpoint status, e.g. "data
+// loaded".
+func (m *MigrationMetadata) Checkpoint(status string) {
+	if m == nil {
+		return
+	}
+	m.apply([]*sp.Mutation{m.mutation("", []string{"status"}, []interface{}{status})})
+}
+
+// TablesCreated records the Spanner tables of conv, and the source
+// tables their rows come from.
+func (m *MigrationMetadata) TablesCreated(conv *internal.Conv) {
+	if m == nil {
+		return
+	}
+	var l []*sp.Mutation
+	for _, t := range internal.BuildLineage("", conv).Tables {
+		l = append(l, m.mutation(t.SpTable, []string{"source_tables", "status"}, []interface{}{t.SrcTables, "created"}))
+	}
+	m.apply(l)
+}
+
+// TablesLoaded records the row counts of data conversion for each
+// Spanner table, and their verification (see VerifyRowCounts).
+func (m *MigrationMetadata) TablesLoaded(checks []RowCountCheck) {
+	if m == nil {
+		return
+	}
+	var l []*sp.Mutation
+	for _, c := range checks {
+		cols := []string{"status", "rows_read", "rows_converted", "bad_rows", "verification"}
+		vals := []interface{}{c.Status, c.Read, c.Converted, c.Bad, c.Detail}
+		if c.Err == nil {
+			cols, vals = append(cols, "spanner_rows"), append(vals, c.SpannerRows)
+		}
+		l = append(l, m.mutation(c.SpTable, cols, vals))
+	}
+	m.apply(l)
+}
+
+// RowCountCheck is the verification of the number of rows of a Spanner
+// table after data conversion.
+type RowCountCheck struct {
+	SpTable     string
+	Read        int64  // Rows read from the source tables of SpTable.
+	Converted   int64  // Rows converted.
+	Bad         int64  // Rows that couldn't be converted or written.
+	SpannerRows int64  // Rows in Spanner (if Err is nil).
+	Err         error  // Error counting rows in Spanner.
+	Status      string // "verified", "count mismatch", or "loaded" if the count can't be verified.
+	Detail      string
+	// ReadTime is the time SpannerRows were counted at: the load
+	// timestamp given to VerifyRowCounts, or zero if they were counted at
+	// the current time.
+	ReadTime time.Time
+}
+
+// VerifyRowCounts compares the number of rows of each Spanner table of
+// conv to the number of rows converted. badWrites are the rows Spanner
+// rejected, by Spanner table. Row counts of the source are per source
+// table: they are only compared to Spanner for tables that have a single
+// source table, and haven't been split, merged or created for overflow
+// values.
+//
+// If loadTime isn't zero, Spanner rows are counted with stale reads at
+// loadTime (see LoadTimestamp), so that writes made after the load (e.g.
+// by change data capture) don't confound verification. Tables whose rows
+// can no longer be read at loadTime, because it is older than the version
+// retention period of the database, are counted at the current time. The
+// source isn't read again: its row counts are those of the rows data
+// conversion read, at the snapshot or the time each table was read at
+// (see SourceOptions.Snapshot), so that changes made to the source since
+// aren't detected.
+func VerifyRowCounts(client *sp.Client, conv *internal.Conv, badWrites map[string]int64, loadTime time.Time) []RowCountCheck {
+	var l []RowCountCheck
+	for _, t := range internal.BuildLineage("", conv).Tables {
+		c := RowCountCheck{SpTable: t.SpTable, Status: "loaded"}
+		for _, src := range t.SrcTables {
+			c.Read += conv.Stats.Rows[src]
+			c.Converted += conv.Stats.GoodRows[src]
+			c.Bad += conv.Stats.BadRows[src]
+		}
+		c.Bad += badWrites[t.SpTable]
+		c.SpannerRows, c.ReadTime, c.Err = countRowsAt(client, conv, t.SpTable, loadTime)
+		switch {
+		case c.Err != nil:
+			c.Detail = fmt.Sprintf("can't count rows: %v", c.Err)
+		case len(t.SrcTables) == 1 && t.Note == "" && !topUp(conv, t.SrcTables[0]):
+			if want := c.Converted - badWrites[t.SpTable]; c.SpannerRows == want {
+				c.Status, c.Detail = "verified", fmt.Sprintf("%d rows, as expected", c.SpannerRows)
+			} else {
+				c.Status, c.Detail = "count mismatch", fmt.Sprintf("%d rows, expected %d", c.SpannerRows, want)
+			}
+		default:
+			c.Detail = fmt.Sprintf("%d rows", c.SpannerRows)
+		}
+		switch {
+		case c.Err != nil || loadTime.IsZero():
+		case c.ReadTime.IsZero():
+			c.Detail += "; counted at the current time, the load timestamp is older than the version retention period"
+		default:
+			c.Detail += fmt.Sprintf("; counted at load timestamp %s", c.ReadTime.UTC().Format(time.RFC3339Nano))
+		}
+		l = append(l, c)
+	}
+	return l
+}
+
+// FailedRowCountChecks returns the tables of checks whose rows couldn't
+// be counted, or whose count doesn't match the number of rows converted.
+func FailedRowCountChecks(checks []RowCountCheck) []string {
+	var l []string
+	for _, c := range checks {
+		if c.Err != nil || c.Status == "count mismatch" {
+			l = append(l, c.SpTable)
+		}
+	}
+	return l
+}
+
+// topUp returns true if only the rows of srcTable changed since the
+// previous load were read (see internal.Conv.SetIncremental): its row
+// count can't be verified.
+func topUp(conv *internal.Conv, srcTable string) bool {
+	_, since, _ := conv.IncrementalKey(srcTable)
+	return since != ""
+}
+
+// LoadTimestamp returns a Spanner timestamp at which all the writes made
+// with client so far are visible, to pin verification reads to the end of
+// a load (see VerifyRowCounts). Unlike the local time, it isn't affected
+// by clock skew.
+func LoadTimestamp(client *sp.Client) (time.Time, error) {
+	ro := client.Single()
+	defer ro.Close()
+	iter := ro.Query(context.Background(), sp.NewStatement("SELECT 1"))
+	defer iter.Stop()
+	if _, err := iter.Next(); err != nil {
+		return time.Time{}, err
+	}
+	return ro.Timestamp()
+}
+
+// countRowsAt counts the rows of table at readTime, or at the current
+// time if readTime is zero or older than the version retention period of
+// the database. It returns the time the rows were counted at (zero for the
+// current time).
+func countRowsAt(client *sp.Client, conv *internal.Conv, table string, readTime time.Time) (int64, time.Time, error) {
+	if !readTime.IsZero() {
+		n, err := countRowsWith(client.Single().WithTimestampBound(sp.ReadTimestamp(readTime)), conv, table)
+		if sp.ErrCode(err) != codes.FailedPrecondition {
+			return n, readTime, err
+		}
+	}
+	n, err := countRows(client, conv, table)
+	return n, time.Time{}, err
+}
+
+func countRows(client *sp.Client, conv *internal.Conv, table string) (int64, error) {
+	return countRowsWith(client.Single(), conv, table)
+}
+
+func countRowsWith(ro *sp.ReadOnlyTransaction, conv *internal.Conv, table string) (int64, error) {
+	defer ro.Close()
+	var n int64
+	iter := ro.Query(context.Background(), countRowsStatement(conv, table))
+	defer iter.Stop()
+	row, err := iter.Next()
+	if err != nil {
+		return 0, err
+	}
+	if err := row.Column(0, &n); err != nil {
+		return 0, err
+	}
+	return n, nil
+}
+
+// countRowsStatement returns the query that counts the rows of Spanner
+// table, in the dialect of the target database of conv.
+func countRowsStatement(conv *internal.Conv, table string) sp.Statement {
+	if pgDialect(conv) {
+		return sp.NewStatement(`SELECT COUNT(*) FROM "` + table + `"`)
+	}
+	return sp.NewStatement("SELECT COUNT(*) FROM `" + table + "`")
+}
+
+// mutation returns a mutation that sets cols of the row of table in
+// MetadataTable (the row of the run if table is empty).
+func (m *MigrationMetadata) mutation(table string, cols []string, vals []interface{}) *sp.Mutation {
+	cols = append([]string{"run_id", "table_name", "tool_version", "updated_at"}, cols...)
+	vals = append([]interface{}{m.runID, table, toolVersion(), sp.CommitTimestamp}, vals...)
+	return sp.InsertOrUpdate(MetadataTable, cols, vals)
+}
+
+func (m *MigrationMetadata) apply(l []*sp.Mutation) {
+	if _, err := m.client.Apply(context.Background(), l); err != nil {
+		fmt.Fprintf(m.out, "Can't update table %s: %v\n", MetadataTable, err)
+	}
+}
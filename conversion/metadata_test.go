@@ -0,0 +1,69 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package conversion
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/cloudspannerecosystem/harbourbridge/internal"
+)
+
+func TestFailedRowCountChecks(t *testing.T) {
+	assert.Nil(t, FailedRowCountChecks(nil))
+	checks := []RowCountCheck{
+		{SpTable: "verified", Status: "verified"},
+		{SpTable: "merged", Status: "loaded"},
+		{SpTable: "mismatch", Status: "count mismatch"},
+		{SpTable: "not counted", Status: "loaded", Err: fmt.Errorf("deadline exceeded")},
+	}
+	assert.Equal(t, []string{"mismatch", "not counted"}, FailedRowCountChecks(checks))
+	assert.Nil(t, FailedRowCountChecks(checks[:2]))
+
+	// Runs without a metadata table still verify row counts for backups.
+	var m *MigrationMetadata
+	m.TablesLoaded(checks)
+}
+
+func TestValidateBackupRetention(t *testing.T) {
+	for _, tc := range []struct {
+		name string
+		opts SpannerOptions
+		ok   bool
+	}{
+		{"no backup", SpannerOptions{}, true},
+		{"retention without backup", SpannerOptions{BackupRetention: time.Hour}, true},
+		{"minimum", SpannerOptions{BackupBeforeCutover: true, BackupRetention: 6 * time.Hour}, true},
+		{"maximum", SpannerOptions{BackupBeforeCutover: true, BackupRetention: 366 * 24 * time.Hour}, true},
+		{"too short", SpannerOptions{BackupBeforeCutover: true, BackupRetention: 6*time.Hour - time.Second}, false},
+		{"too long", SpannerOptions{BackupBeforeCutover: true, BackupRetention: 367 * 24 * time.Hour}, false},
+		{"unset", SpannerOptions{BackupBeforeCutover: true}, false},
+		{"existing", SpannerOptions{AllowExisting: true, BackupExisting: true, BackupRetention: time.Hour}, false},
+	} {
+		err := tc.opts.Validate(TARGET_SPANNER)
+		assert.Equal(t, tc.ok, err == nil, tc.name)
+	}
+}
+
+func TestCountRowsStatement(t *testing.T) {
+	conv := internal.MakeConv()
+	conv.TargetDb = TARGET_SPANNER
+	assert.Equal(t, "SELECT COUNT(*) FROM `order`", countRowsStatement(conv, "order").SQL)
+	conv.TargetDb = TARGET_EXPERIMENTAL_POSTGRES
+	assert.Equal(t, `SELECT COUNT(*) FROM "order"`, countRowsStatement(conv, "order").SQL)
+}
@@ -0,0 +1,270 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package conversion
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"regexp"
+	"sync"
+	"time"
+
+	pubsub "google.golang.org/api/pubsub/v1"
+
+	"github.com/cloudspannerecosystem/harbourbridge/internal"
+)
+
+// Notification events.
+const (
+	SchemaComplete       = "schema-complete"       // Schema conversion is done (the report and schema files are written).
+	DataComplete         = "data-complete"         // Data conversion is done.
+	VerificationComplete = "verification-complete" // Row counts of Spanner tables were verified.
+	StatisticsReady      = "statistics-ready"      // Query optimizer statistics were constructed once data was loaded.
+	MigrationFailed      = "failure"               // The run failed.
+)
+
+// Notification is the payload of notifications, sent as JSON to webhooks
+// and Pub/Sub topics.
+type Notification struct {
+	Event    string               `json:"event"`
+	Database string               `json:"database"`
+	Time     time.Time            `json:"time"`
+	Summary  *internal.RunSummary `json:"summary,omitempty"`
+	Detail   string               `json:"detail,omitempty"`
+}
+
+// Text returns n as a line of text, for chat messages.
+func (n Notification) Text() string {
+	s := fmt.Sprintf("HarbourBridge: %s for %s", n.Event, n.Database)
+	if n.Summary != nil {
+		s += fmt.Sprintf(": %s (%d tables, %d schema warnings, %d rows, %d bad rows)", n.Summary.Status, n.Summary.Tables, n.Summary.SchemaWarnings, n.Summary.Rows, n.Summary.BadRows)
+		if n.Summary.Error != "" {
+			s += ": " + n.Summary.Error
+		}
+	}
+	if n.Detail != "" {
+		s += ": " + n.Detail
+	}
+	return s
+}
+
+var topicRegexp = regexp.MustCompile(`^projects/[^/]+/topics/[^/]+$`)
+
+// Notifier sends notifications of the completion of the phases of a
+// migration, so that long migrations don't need someone watching them,
+// and streams progress events (see internal.ProgressEvent) to a Pub/Sub
+// topic, for dashboards and automation. Failures to notify are reported
+// to out, but don't fail the run. Methods of a nil *Notifier do nothing.
+type Notifier struct {
+	Database string   // Spanner database URI.
+	Webhooks []string // URLs sent a POST request, with the JSON Notification as body.
+	Slack    []string // Slack incoming webhook URLs, sent a message.
+	Topics   []string // Pub/Sub topics (projects/p/topics/t), published the JSON Notification.
+	// Events is the Pub/Sub topic progress events are published to, as
+	// JSON ProgressMessages (empty means none).
+	Events string
+	out    *os.File
+	events chan internal.ProgressEvent
+	done   chan struct{}
+	mu     sync.Mutex // Protects closed and dropped, and serializes sends with Close.
+	closed bool
+	// dropped counts the progress events that were dropped because
+	// publishing couldn't keep up with them.
+	dropped int64
+}
+
+// ProgressMessage is the payload of the messages published to
+// Notifier.Events.
+type ProgressMessage struct {
+	Database string `json:"database"`
+	internal.ProgressEvent
+}
+
+// progressBatch is the maximum number of progress events published at
+// once.
+const progressBatch = 100
+
+// NewNotifier returns a Notifier for webhooks, Slack webhooks and Pub/Sub
+// topics, publishing progress events to the Pub/Sub topic events (if not
+// empty), or nil if there are none.
+func NewNotifier(webhooks, slack, topics []string, events string, out *os.File) (*Notifier, error) {
+	if len(webhooks)+len(slack)+len(topics) == 0 && events == "" {
+		return nil, nil
+	}
+	for _, t := range append([]string{events}, topics...) {
+		if t != "" && !topicRegexp.MatchString(t) {
+			return nil, fmt.Errorf("invalid Pub/Sub topic %q: expected projects/<project>/topics/<topic>", t)
+		}
+	}
+	n := &Notifier{Webhooks: webhooks, Slack: slack, Topics: topics, Events: events, out: out}
+	if events != "" {
+		svc, err := pubsub.NewService(context.Background())
+		if err != nil {
+			return nil, fmt.Errorf("can't create Pub/Sub client: %w", err)
+		}
+		n.events = make(chan internal.ProgressEvent, 10*progressBatch)
+		n.done = make(chan struct{})
+		go n.publishEvents(svc)
+	}
+	return n, nil
+}
+
+// Event publishes e to n.Events. Events are published in the background,
+// in batches, so that data conversion isn't slowed down by Pub/Sub: when
+// publishing can't keep up, events are dropped (and counted, see Close)
+// rather than blocking. Events after Close are ignored.
+func (n *Notifier) Event(e internal.ProgressEvent) {
+	if n == nil || n.events == nil {
+		return
+	}
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	if n.closed {
+		return
+	}
+	select {
+	case n.events <- e:
+	default:
+		n.dropped++
+	}
+}
+
+// Close publishes the progress events that haven't been published yet,
+// and reports the events that were dropped. Closing n again does nothing.
+func (n *Notifier) Close() {
+	if n == nil || n.events == nil {
+		return
+	}
+	n.mu.Lock()
+	if n.closed {
+		n.mu.Unlock()
+		return
+	}
+	n.closed = true
+	close(n.events)
+	n.mu.Unlock()
+	<-n.done
+	if n.dropped > 0 {
+		fmt.Fprintf(n.out, "Dropped %d progress event(s): publishing to %s couldn't keep up with them\n", n.dropped, n.Events)
+	}
+}
+
+func (n *Notifier) publishEvents(svc *pubsub.Service) {
+	defer close(n.done)
+	for e := range n.events {
+		batch := []internal.ProgressEvent{e}
+	more:
+		for len(batch) < progressBatch {
+			select {
+			case e, ok := <-n.events:
+				if !ok {
+					break more
+				}
+				batch = append(batch, e)
+			default:
+				break more
+			}
+		}
+		var msgs []*pubsub.PubsubMessage
+		for _, e := range batch {
+			data, err := json.Marshal(ProgressMessage{Database: n.Database, ProgressEvent: e})
+			if err != nil {
+				fmt.Fprintf(n.out, "Can't encode progress event: %v\n", err)
+				continue
+			}
+			attributes := map[string]string{"kind": e.Kind}
+			if e.Table != "" {
+				attributes["table"] = e.Table
+			}
+			msgs = append(msgs, &pubsub.PubsubMessage{Data: base64.StdEncoding.EncodeToString(data), Attributes: attributes})
+		}
+		if err := publish(svc, n.Events, msgs); err != nil {
+			fmt.Fprintf(n.out, "Can't publish %d progress event(s) to %s: %v\n", len(msgs), n.Events, err)
+		}
+	}
+}
+
+// Notify sends a notification of event, with summary (if not nil) and
+// detail, to all destinations of n.
+func (n *Notifier) Notify(event string, summary *internal.RunSummary, detail string) {
+	if n == nil {
+		return
+	}
+	msg := Notification{Event: event, Database: n.Database, Time: time.Now(), Summary: summary, Detail: detail}
+	body, err := json.Marshal(msg)
+	if err != nil {
+		fmt.Fprintf(n.out, "Can't encode notification: %v\n", err)
+		return
+	}
+	for _, url := range n.Webhooks {
+		if err := postJSON(url, body); err != nil {
+			fmt.Fprintf(n.out, "Can't send %s notification: %v\n", event, err)
+		}
+	}
+	if len(n.Slack) > 0 {
+		text, err := json.Marshal(map[string]string{"text": msg.Text()})
+		if err != nil {
+			fmt.Fprintf(n.out, "Can't encode notification: %v\n", err)
+			return
+		}
+		for _, url := range n.Slack {
+			if err := postJSON(url, text); err != nil {
+				fmt.Fprintf(n.out, "Can't send %s notification to Slack: %v\n", event, err)
+			}
+		}
+	}
+	if len(n.Topics) > 0 {
+		svc, err := pubsub.NewService(context.Background())
+		if err != nil {
+			fmt.Fprintf(n.out, "Can't create Pub/Sub client: %v\n", err)
+			return
+		}
+		msg := &pubsub.PubsubMessage{Data: base64.StdEncoding.EncodeToString(body), Attributes: map[string]string{"event": event}}
+		for _, topic := range n.Topics {
+			if err := publish(svc, topic, []*pubsub.PubsubMessage{msg}); err != nil {
+				fmt.Fprintf(n.out, "Can't publish %s notification to %s: %v\n", event, topic, err)
+			}
+		}
+	}
+}
+
+// postJSON sends a POST request with body to url. Any status other than
+// 2xx is an error.
+func postJSON(url string, body []byte) error {
+	client := http.Client{Timeout: time.Minute}
+	resp, err := client.Post(url, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		return fmt.Errorf("%s returned %s", url, resp.Status)
+	}
+	return nil
+}
+
+// publish publishes msgs to a Pub/Sub topic, with svc (which uses the
+// application default credentials).
+func publish(svc *pubsub.Service, topic string, msgs []*pubsub.PubsubMessage) error {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+	_, err := svc.Projects.Topics.Publish(topic, &pubsub.PublishRequest{Messages: msgs}).Context(ctx).Do()
+	return err
+}
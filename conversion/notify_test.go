@@ -0,0 +1,60 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package conversion
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/cloudspannerecosystem/harbourbridge/internal"
+)
+
+func TestNotifierEvent(t *testing.T) {
+	out, err := ioutil.TempFile("", "notify-test-")
+	assert.Nil(t, err)
+	defer os.Remove(out.Name())
+	defer out.Close()
+
+	// Publishing doesn't keep up: events that don't fit are dropped
+	// rather than blocking.
+	n := &Notifier{Events: "projects/p/topics/t", out: out, events: make(chan internal.ProgressEvent, 2), done: make(chan struct{})}
+	for _, table := range []string{"a", "b", "c", "d"} {
+		n.Event(internal.ProgressEvent{Kind: internal.TableStartEvent, Table: table})
+	}
+	var published []string
+	go func() {
+		defer close(n.done)
+		for e := range n.events {
+			published = append(published, e.Table)
+		}
+	}()
+	n.Close()
+	assert.Equal(t, []string{"a", "b"}, published)
+
+	// Events after Close are ignored, and closing again does nothing.
+	n.Event(internal.ProgressEvent{Kind: internal.TableStartEvent, Table: "e"})
+	n.Close()
+	b, err := ioutil.ReadFile(out.Name())
+	assert.Nil(t, err)
+	assert.Equal(t, "Dropped 2 progress event(s): publishing to projects/p/topics/t couldn't keep up with them\n", string(b))
+
+	// Nil notifiers do nothing.
+	var none *Notifier
+	none.Event(internal.ProgressEvent{Kind: internal.TableStartEvent})
+	none.Close()
+}
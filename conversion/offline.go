@@ -0,0 +1,99 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package conversion
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+)
+
+// ErrOffline is the error of network accesses attempted after
+// DisableNetwork.
+var ErrOffline = errors.New("network access attempted in offline mode")
+
+// TargetNone is the -target of runs that don't use Spanner at all, the
+// same as -no-spanner (see CheckNoSpanner).
+const TargetNone = "none"
+
+// CheckNoSpanner checks that a run can be done without Spanner at all,
+// e.g. for schema reviews before a Spanner instance exists: only schema
+// conversion, which writes the DDL, session and report files, without
+// the options that act on the Spanner database (which isn't created).
+func CheckNoSpanner(schemaOnly bool, opts SpannerOptions) error {
+	if !schemaOnly {
+		return fmt.Errorf("running without Spanner requires schema-only mode: data conversion writes to Spanner")
+	}
+	var l []string
+	if opts.KMSKey != "" {
+		l = append(l, "-kms-key")
+	}
+	if opts.AllowExisting {
+		l = append(l, "-allow-existing")
+	}
+	if opts.BackupExisting {
+		l = append(l, "-backup-existing")
+	}
+	if opts.BackupBeforeCutover {
+		l = append(l, "-backup-before-cutover")
+	}
+	if len(opts.Grants) > 0 {
+		l = append(l, "-grant")
+	}
+	if opts.LoadProcessingUnits != 0 {
+		l = append(l, "-scale-processing-units")
+	}
+	if len(l) > 0 {
+		return fmt.Errorf("%s act on the Spanner database, so can't be used without Spanner", strings.Join(l, ", "))
+	}
+	return nil
+}
+
+// CheckOffline checks that a run can be done without network access:
+// only schema conversion of dump files (the report and other output files
+// are written locally).
+func CheckOffline(driver string, schemaOnly bool) error {
+	if driver != PGDUMP && driver != MYSQLDUMP {
+		return fmt.Errorf("offline mode only supports the %s and %s drivers (driver: %s)", PGDUMP, MYSQLDUMP, driver)
+	}
+	if !schemaOnly {
+		return fmt.Errorf("offline mode requires schema-only mode: data conversion writes to Spanner")
+	}
+	return nil
+}
+
+// DisableNetwork makes host name lookups and requests through Go's
+// default HTTP transport fail with ErrOffline. Google Cloud clients
+// (including credential lookups on the metadata server) connect through
+// one or the other, so an offline run that unexpectedly reaches for the
+// network fails rather than quietly making the call.
+func DisableNetwork() {
+	net.DefaultResolver = &net.Resolver{
+		PreferGo: true,
+		Dial: func(ctx context.Context, network, address string) (net.Conn, error) {
+			return nil, fmt.Errorf("can't look up host names (via %s): %w", address, ErrOffline)
+		},
+	}
+	http.DefaultTransport = offlineTransport{}
+}
+
+type offlineTransport struct{}
+
+func (offlineTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	return nil, fmt.Errorf("can't send %s request to %s: %w", req.Method, req.URL.Host, ErrOffline)
+}
@@ -0,0 +1,80 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package conversion
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCheckNoSpanner(t *testing.T) {
+	for _, tc := range []struct {
+		name       string
+		schemaOnly bool
+		opts       SpannerOptions
+		ok         bool
+	}{
+		{"schema only", true, SpannerOptions{}, true},
+		{"client options", true, SpannerOptions{NumChannels: 4, Priority: 1}, true},
+		{"data conversion", false, SpannerOptions{}, false},
+		{"kms key", true, SpannerOptions{KMSKey: "projects/p/locations/l/keyRings/r/cryptoKeys/k"}, false},
+		{"allow existing", true, SpannerOptions{AllowExisting: true}, false},
+		{"backup existing", true, SpannerOptions{AllowExisting: true, BackupExisting: true}, false},
+		{"backup before cutover", true, SpannerOptions{BackupBeforeCutover: true}, false},
+		{"grants", true, SpannerOptions{Grants: []IAMGrant{{}}}, false},
+		{"scaling", true, SpannerOptions{LoadProcessingUnits: 1000}, false},
+	} {
+		err := CheckNoSpanner(tc.schemaOnly, tc.opts)
+		assert.Equal(t, tc.ok, err == nil, tc.name)
+	}
+}
+
+func TestCheckOffline(t *testing.T) {
+	for _, tc := range []struct {
+		driver     string
+		schemaOnly bool
+		ok         bool
+	}{
+		{PGDUMP, true, true},
+		{MYSQLDUMP, true, true},
+		{PGDUMP, false, false},
+		{POSTGRES, true, false},
+		{DYNAMODB, true, false},
+	} {
+		err := CheckOffline(tc.driver, tc.schemaOnly)
+		assert.Equal(t, tc.ok, err == nil, "%s, schema only: %t", tc.driver, tc.schemaOnly)
+	}
+}
+
+func TestDisableNetwork(t *testing.T) {
+	resolver, transport := net.DefaultResolver, http.DefaultTransport
+	defer func() {
+		net.DefaultResolver, http.DefaultTransport = resolver, transport
+	}()
+	DisableNetwork()
+
+	_, err := http.Get("http://127.0.0.1:1/")
+	assert.True(t, errors.Is(err, ErrOffline), "got %v", err)
+	_, err = http.Post("https://spanner.googleapis.com/", "application/json", nil)
+	assert.True(t, errors.Is(err, ErrOffline), "got %v", err)
+	_, err = net.DefaultResolver.LookupHost(context.Background(), "spanner.googleapis.com")
+	assert.NotNil(t, err)
+	assert.Contains(t, err.Error(), ErrOffline.Error())
+}
@@ -0,0 +1,135 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package conversion
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	sp "cloud.google.com/go/spanner"
+	database "cloud.google.com/go/spanner/admin/database/apiv1"
+	adminpb "google.golang.org/genproto/googleapis/spanner/admin/database/v1"
+)
+
+// OptimizerStatus describes the query optimizer of a database prepared
+// for cutover by OptimizeDatabase.
+type OptimizerStatus struct {
+	Version int // Default optimizer version set (0 if unchanged).
+	// Package is the newest optimizer statistics package, which the
+	// optimizer uses unless the database pins another one. Empty if
+	// statistics weren't constructed.
+	Package string
+	Ready   time.Time     // When the statistics package was ready.
+	Took    time.Duration // Time taken to construct the statistics package.
+}
+
+// Summary describes s for the banner of the report, one line per
+// change made to the optimizer.
+func (s OptimizerStatus) Summary() string {
+	var summary string
+	if s.Version != 0 {
+		summary += fmt.Sprintf("Default optimizer version: %d\n", s.Version)
+	}
+	if s.Package != "" {
+		summary += fmt.Sprintf("Optimizer statistics: package %s ready at %s (constructed in %s)\n", s.Package, s.Ready.Format("2006-01-02 15:04:05"), s.Took.Round(time.Second))
+	}
+	return summary
+}
+
+// OptimizeDatabase prepares the query optimizer of database dbName for
+// cutover once data is loaded, so that applications aren't switched to a
+// database whose optimizer has no statistics on the loaded data (Spanner
+// only constructs statistics packages automatically every few days): it
+// sets the default optimizer version to opts.OptimizerVersion unless it
+// is 0, and constructs a new statistics package with ANALYZE if
+// opts.Analyze is set. It returns the DDL statements applied, for the
+// audit log, with the status of the optimizer.
+func OptimizeDatabase(project, instance, dbName string, client *sp.Client, opts SpannerOptions, out *os.File) ([]string, OptimizerStatus, error) {
+	var status OptimizerStatus
+	if opts.OptimizerVersion == 0 && !opts.Analyze {
+		return nil, status, nil
+	}
+	ctx := context.Background()
+	adminClient, err := database.NewDatabaseAdminClient(ctx)
+	if err != nil {
+		return nil, status, fmt.Errorf("can't create admin client: %w", analyzeError(err, project, instance))
+	}
+	defer adminClient.Close()
+	db := fmt.Sprintf("projects/%s/instances/%s/databases/%s", project, instance, dbName)
+	var stmts []string
+	if opts.OptimizerVersion != 0 {
+		stmt := fmt.Sprintf("ALTER DATABASE `%s` SET OPTIONS (optimizer_version = %d)", dbName, opts.OptimizerVersion)
+		fmt.Fprintf(out, "Setting the default optimizer version of database %s to %d ... ", dbName, opts.OptimizerVersion)
+		if err := updateDDL(ctx, adminClient, db, stmt); err != nil {
+			return stmts, status, fmt.Errorf("can't set the optimizer version of database %s: %w", dbName, analyzeError(err, project, instance))
+		}
+		fmt.Fprintf(out, "done.\n")
+		stmts = append(stmts, stmt)
+		status.Version = opts.OptimizerVersion
+	}
+	if opts.Analyze {
+		start := time.Now()
+		fmt.Fprintf(out, "Constructing query optimizer statistics for database %s ... ", dbName)
+		if err := updateDDL(ctx, adminClient, db, "ANALYZE"); err != nil {
+			return stmts, status, fmt.Errorf("can't construct optimizer statistics for database %s: %w", dbName, analyzeError(err, project, instance))
+		}
+		stmts = append(stmts, "ANALYZE")
+		status.Ready = time.Now()
+		status.Took = status.Ready.Sub(start)
+		if status.Package, err = newestStatisticsPackage(client); err != nil {
+			return stmts, status, fmt.Errorf("can't read optimizer statistics packages of database %s: %w", dbName, err)
+		}
+		fmt.Fprintf(out, "done: package %s is ready.\n", status.Package)
+	}
+	return stmts, status, nil
+}
+
+// updateDDL applies DDL statement stmt to database db, and waits for it
+// to complete.
+func updateDDL(ctx context.Context, adminClient *database.DatabaseAdminClient, db, stmt string) error {
+	op, err := adminClient.UpdateDatabaseDdl(ctx, &adminpb.UpdateDatabaseDdlRequest{Database: db, Statements: []string{stmt}})
+	if err != nil {
+		return err
+	}
+	return op.Wait(ctx)
+}
+
+// newestStatisticsPackage returns the name of the newest optimizer
+// statistics package of the database of client. Package names end with
+// the time they were constructed, e.g. auto_20191128_14_47_22UTC.
+func newestStatisticsPackage(client *sp.Client) (string, error) {
+	var newest string
+	err := querySchema(client, "SELECT PACKAGE_NAME FROM INFORMATION_SCHEMA.SPANNER_STATISTICS", func(row *sp.Row) error {
+		var name string
+		if err := row.Columns(&name); err != nil {
+			return err
+		}
+		if newest == "" || packageTime(name) > packageTime(newest) {
+			newest = name
+		}
+		return nil
+	})
+	return newest, err
+}
+
+func packageTime(name string) string {
+	if i := strings.Index(name, "_"); i >= 0 {
+		return name[i+1:]
+	}
+	return name
+}
@@ -0,0 +1,64 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package conversion
+
+import (
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestOptimizerStatusSummary(t *testing.T) {
+	ready := time.Date(2021, time.March, 4, 15, 16, 17, 0, time.UTC)
+	for _, tc := range []struct {
+		name   string
+		status OptimizerStatus
+		want   string
+	}{
+		{"unchanged", OptimizerStatus{}, ""},
+		{"version", OptimizerStatus{Version: 3}, "Default optimizer version: 3\n"},
+		{"statistics", OptimizerStatus{Package: "analyze_20210304_15_16_17UTC", Ready: ready, Took: 93*time.Second + 400*time.Millisecond},
+			"Optimizer statistics: package analyze_20210304_15_16_17UTC ready at 2021-03-04 15:16:17 (constructed in 1m33s)\n"},
+		{"both", OptimizerStatus{Version: 3, Package: "p_1", Ready: ready, Took: time.Second},
+			"Default optimizer version: 3\nOptimizer statistics: package p_1 ready at 2021-03-04 15:16:17 (constructed in 1s)\n"},
+	} {
+		assert.Equal(t, tc.want, tc.status.Summary(), tc.name)
+	}
+}
+
+func TestPackageTime(t *testing.T) {
+	// Packages are ordered by the time they were constructed, whatever
+	// constructed them.
+	assert.True(t, packageTime("analyze_20191128_14_47_22UTC") > packageTime("auto_20191127_09_00_00UTC"))
+	assert.True(t, packageTime("auto_20191128_14_47_23UTC") > packageTime("analyze_20191128_14_47_22UTC"))
+	assert.Equal(t, "20191128_14_47_22UTC", packageTime("auto_20191128_14_47_22UTC"))
+	assert.Equal(t, "custom", packageTime("custom"))
+}
+
+func TestOptimizeDatabase_Unchanged(t *testing.T) {
+	// Nothing to do: no client is needed.
+	stmts, status, err := OptimizeDatabase("p", "i", "db", nil, SpannerOptions{}, os.Stdout)
+	assert.Nil(t, err)
+	assert.Empty(t, stmts)
+	assert.Equal(t, OptimizerStatus{}, status)
+}
+
+func TestValidateOptimizerVersion(t *testing.T) {
+	assert.Nil(t, SpannerOptions{OptimizerVersion: 3, Analyze: true}.Validate(TARGET_SPANNER))
+	assert.Nil(t, SpannerOptions{}.Validate(TARGET_SPANNER))
+	assert.NotNil(t, SpannerOptions{OptimizerVersion: -1}.Validate(TARGET_SPANNER))
+}
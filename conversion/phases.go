@@ -0,0 +1,400 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package conversion
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+
+	sp "cloud.google.com/go/spanner"
+	database "cloud.google.com/go/spanner/admin/database/apiv1"
+	"google.golang.org/api/googleapi"
+	storage "google.golang.org/api/storage/v1"
+	adminpb "google.golang.org/genproto/googleapis/spanner/admin/database/v1"
+	"google.golang.org/grpc/codes"
+
+	"github.com/cloudspannerecosystem/harbourbridge/internal"
+)
+
+// Phases of a migration run as separate invocations, e.g. by a workflow
+// scheduler (see cmd.Phase). Each phase needs the previous one to have
+// completed.
+const (
+	AssessPhase = "assess" // Schema conversion and report.
+	SchemaPhase = "schema" // Creation of the Spanner database and tables.
+	DataPhase   = "data"   // Data conversion, of all tables or of a batch of tables.
+	VerifyPhase = "verify" // Row count verification and foreign keys.
+)
+
+// Phases lists the phases in the order they run.
+var Phases = []string{AssessPhase, SchemaPhase, DataPhase, VerifyPhase}
+
+// PhaseStateTable is the table of the Spanner state stores (see
+// OpenStateStore).
+const PhaseStateTable = "harbourbridge_phase_state"
+
+// PhaseStateTableDDL creates PhaseStateTable. Each object of the store is
+// a row.
+const PhaseStateTableDDL = "CREATE TABLE IF NOT EXISTS " + PhaseStateTable + ` (
+	name STRING(MAX) NOT NULL,
+	data BYTES(MAX) NOT NULL,
+	updated_at TIMESTAMP NOT NULL OPTIONS (allow_commit_timestamp=true)
+) PRIMARY KEY (name)`
+
+// StateStore keeps the state shared by the phases of a migration: the
+// session of the assess phase, a record of each completed phase, and a
+// record of each table whose data is loaded. Tables are recorded
+// separately, so that batches of tables can be loaded in parallel.
+type StateStore interface {
+	// Read returns the data of object name, or nil if there is no such
+	// object.
+	Read(name string) ([]byte, error)
+	// Write creates or replaces object name.
+	Write(name string, data []byte) error
+}
+
+var spannerStoreRegexp = regexp.MustCompile(`^spanner://(projects/[^/]+/instances/[^/]+/databases/[^/]+)$`)
+
+// OpenStateStore opens the state store at uri: gs://bucket/prefix for
+// objects in Cloud Storage, spanner://projects/<project>/instances/<instance>/databases/<db>
+// for rows of PhaseStateTable in an existing Spanner database (the table
+// is created if needed), or otherwise a local directory.
+func OpenStateStore(uri string) (StateStore, error) {
+	ctx := context.Background()
+	switch {
+	case strings.HasPrefix(uri, "gs://"):
+		l := strings.SplitN(strings.TrimPrefix(uri, "gs://"), "/", 2)
+		if l[0] == "" {
+			return nil, fmt.Errorf("invalid state store %s: no bucket", uri)
+		}
+		s := &gcsStore{bucket: l[0]}
+		if len(l) == 2 && strings.Trim(l[1], "/") != "" {
+			s.prefix = strings.Trim(l[1], "/") + "/"
+		}
+		svc, err := storage.NewService(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("can't create Cloud Storage client: %w", err)
+		}
+		s.svc = svc
+		return s, nil
+	case strings.HasPrefix(uri, "spanner://"):
+		m := spannerStoreRegexp.FindStringSubmatch(uri)
+		if m == nil {
+			return nil, fmt.Errorf("invalid state store %s: expected spanner://projects/<project>/instances/<instance>/databases/<db>", uri)
+		}
+		adminClient, err := database.NewDatabaseAdminClient(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("can't create admin client: %w", err)
+		}
+		defer adminClient.Close()
+		op, err := adminClient.UpdateDatabaseDdl(ctx, &adminpb.UpdateDatabaseDdlRequest{
+			Database:   m[1],
+			Statements: []string{PhaseStateTableDDL},
+		})
+		if err == nil {
+			err = op.Wait(ctx)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("can't create table %s in %s: %w", PhaseStateTable, m[1], err)
+		}
+		client, err := sp.NewClient(ctx, m[1])
+		if err != nil {
+			return nil, fmt.Errorf("can't create client for db %s: %w", m[1], err)
+		}
+		return &spannerStore{client: client}, nil
+	default:
+		if err := os.MkdirAll(uri, 0755); err != nil {
+			return nil, fmt.Errorf("can't create state directory %s: %w", uri, err)
+		}
+		return dirStore(uri), nil
+	}
+}
+
+// dirStore is a StateStore that keeps objects as files of a directory.
+type dirStore string
+
+func (d dirStore) Read(name string) ([]byte, error) {
+	b, err := ioutil.ReadFile(filepath.Join(string(d), name))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	return b, err
+}
+
+func (d dirStore) Write(name string, data []byte) error {
+	file := filepath.Join(string(d), name)
+	if err := os.MkdirAll(filepath.Dir(file), 0755); err != nil {
+		return err
+	}
+	// Objects are replaced atomically, as for the other stores.
+	tmp := file + ".tmp"
+	if err := ioutil.WriteFile(tmp, data, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, file)
+}
+
+// gcsStore is a StateStore that keeps objects in a Cloud Storage bucket,
+// under a prefix.
+type gcsStore struct {
+	svc    *storage.Service
+	bucket string
+	prefix string
+}
+
+func (s *gcsStore) Read(name string) ([]byte, error) {
+	resp, err := s.svc.Objects.Get(s.bucket, s.prefix+name).Download()
+	if e, ok := err.(*googleapi.Error); ok && e.Code == http.StatusNotFound {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("can't read gs://%s/%s%s: %w", s.bucket, s.prefix, name, err)
+	}
+	defer resp.Body.Close()
+	return ioutil.ReadAll(resp.Body)
+}
+
+func (s *gcsStore) Write(name string, data []byte) error {
+	obj := &storage.Object{Name: s.prefix + name, ContentType: "application/json"}
+	if _, err := s.svc.Objects.Insert(s.bucket, obj).Media(bytes.NewReader(data)).Do(); err != nil {
+		return fmt.Errorf("can't write gs://%s/%s%s: %w", s.bucket, s.prefix, name, err)
+	}
+	return nil
+}
+
+// spannerStore is a StateStore that keeps objects as rows of
+// PhaseStateTable.
+type spannerStore struct {
+	client *sp.Client
+}
+
+func (s *spannerStore) Read(name string) ([]byte, error) {
+	row, err := s.client.Single().ReadRow(context.Background(), PhaseStateTable, sp.Key{name}, []string{"data"})
+	if sp.ErrCode(err) == codes.NotFound {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("can't read %s from %s: %w", name, PhaseStateTable, err)
+	}
+	var data []byte
+	if err := row.Column(0, &data); err != nil {
+		return nil, err
+	}
+	return data, nil
+}
+
+func (s *spannerStore) Write(name string, data []byte) error {
+	m := sp.InsertOrUpdate(PhaseStateTable, []string{"name", "data", "updated_at"}, []interface{}{name, data, sp.CommitTimestamp})
+	if _, err := s.client.Apply(context.Background(), []*sp.Mutation{m}); err != nil {
+		return fmt.Errorf("can't write %s to %s: %w", name, PhaseStateTable, err)
+	}
+	return nil
+}
+
+// PhaseRecord records a completed phase.
+type PhaseRecord struct {
+	Phase   string               `json:"phase"`
+	Start   time.Time            `json:"start"`
+	End     time.Time            `json:"end"`
+	Tables  []string             `json:"tables,omitempty"` // Source tables loaded, for DataPhase.
+	Summary *internal.RunSummary `json:"summary,omitempty"`
+	Detail  string               `json:"detail,omitempty"`
+}
+
+// TableLoad records the data conversion of a source table.
+type TableLoad struct {
+	Table    string    `json:"table"`
+	Time     time.Time `json:"time"`
+	Rows     int64     `json:"rows"`
+	GoodRows int64     `json:"good_rows"`
+	BadRows  int64     `json:"bad_rows"`
+	// BadWrites are the rows of the Spanner table of Table that Spanner
+	// rejected.
+	BadWrites int64 `json:"bad_writes"`
+	// LoadedAt is the Spanner timestamp the load of the batch of Table
+	// completed at (see LoadTimestamp), zero if it isn't known.
+	LoadedAt time.Time `json:"loaded_at,omitempty"`
+}
+
+const stateSessionFile = "session.json"
+
+func phaseObject(phase string) string {
+	return "phases/" + phase + ".json"
+}
+
+func tableObject(srcTable string) string {
+	return "tables/" + url.PathEscape(srcTable) + ".json"
+}
+
+// ReadPhase returns the record of phase, or nil if phase hasn't
+// completed.
+func ReadPhase(store StateStore, phase string) (*PhaseRecord, error) {
+	b, err := store.Read(phaseObject(phase))
+	if err != nil || b == nil {
+		return nil, err
+	}
+	var r PhaseRecord
+	if err := json.Unmarshal(b, &r); err != nil {
+		return nil, fmt.Errorf("can't decode record of phase %s: %w", phase, err)
+	}
+	return &r, nil
+}
+
+// WritePhase records that phase r.Phase completed.
+func WritePhase(store StateStore, r PhaseRecord) error {
+	return writeState(store, phaseObject(r.Phase), r)
+}
+
+// CheckPhase returns an error if phase can't run: if the phases before
+// it haven't all completed, or if it has already completed (except for
+// DataPhase, which runs once per batch of tables).
+func CheckPhase(store StateStore, phase string) error {
+	for i, p := range Phases {
+		if p != phase {
+			continue
+		}
+		for _, prev := range Phases[:i] {
+			r, err := ReadPhase(store, prev)
+			if err != nil {
+				return err
+			}
+			if r == nil {
+				return fmt.Errorf("can't run phase %s: phase %s hasn't completed", phase, prev)
+			}
+		}
+		r, err := ReadPhase(store, phase)
+		if err != nil {
+			return err
+		}
+		if r != nil && phase != DataPhase {
+			return fmt.Errorf("can't run phase %s: it already completed at %s", phase, r.End.Format(time.RFC3339))
+		}
+		return nil
+	}
+	return fmt.Errorf("unknown phase %q (accepted values are %s)", phase, strings.Join(Phases, ", "))
+}
+
+// WriteStateSession copies session file sessionJSON (see
+// WriteSessionFile) to store, for the phases after AssessPhase.
+func WriteStateSession(store StateStore, sessionJSON string) error {
+	b, err := ioutil.ReadFile(sessionJSON)
+	if err != nil {
+		return err
+	}
+	return store.Write(stateSessionFile, b)
+}
+
+// ReadStateSession reads the session recorded by AssessPhase into conv.
+func ReadStateSession(store StateStore, conv *internal.Conv) error {
+	b, err := store.Read(stateSessionFile)
+	if err != nil {
+		return err
+	}
+	if b == nil {
+		return fmt.Errorf("no session in state store")
+	}
+	if err := json.Unmarshal(b, conv); err != nil {
+		return fmt.Errorf("can't decode session: %w", err)
+	}
+	return nil
+}
+
+// ReadTableLoad returns the record of the data conversion of srcTable,
+// or nil if its data isn't loaded.
+func ReadTableLoad(store StateStore, srcTable string) (*TableLoad, error) {
+	b, err := store.Read(tableObject(srcTable))
+	if err != nil || b == nil {
+		return nil, err
+	}
+	var l TableLoad
+	if err := json.Unmarshal(b, &l); err != nil {
+		return nil, fmt.Errorf("can't decode record of table %s: %w", srcTable, err)
+	}
+	return &l, nil
+}
+
+// WriteTableLoads records the data conversion of tables, with the row
+// counts of conv, badWrites, the rows Spanner rejected by Spanner table,
+// and loadedAt, the Spanner timestamp the load completed at.
+func WriteTableLoads(store StateStore, conv *internal.Conv, tables []string, badWrites map[string]int64, now, loadedAt time.Time) error {
+	for _, t := range tables {
+		l := TableLoad{Table: t, Time: now, Rows: conv.Stats.Rows[t], GoodRows: conv.Stats.GoodRows[t], BadRows: conv.Stats.BadRows[t], LoadedAt: loadedAt}
+		if spTable, err := internal.GetSpannerTable(conv, t); err == nil {
+			l.BadWrites = badWrites[spTable]
+		}
+		if err := writeState(store, tableObject(t), l); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// LoadTableStats replaces the row counts of conv with those recorded for
+// its tables, and returns the rows Spanner rejected, by Spanner table,
+// and the Spanner timestamp the last load completed at (zero if it isn't
+// known for all tables). It is an error if the data of a table isn't
+// loaded.
+func LoadTableStats(store StateStore, conv *internal.Conv) (map[string]int64, time.Time, error) {
+	badWrites := make(map[string]int64)
+	var missing []string
+	var loadedAt time.Time
+	unknown := false
+	for _, t := range conv.SrcTables() {
+		l, err := ReadTableLoad(store, t)
+		if err != nil {
+			return nil, time.Time{}, err
+		}
+		if l == nil {
+			missing = append(missing, t)
+			continue
+		}
+		conv.Stats.Rows[t] = l.Rows
+		conv.Stats.GoodRows[t] = l.GoodRows
+		conv.Stats.BadRows[t] = l.BadRows
+		if spTable, err := internal.GetSpannerTable(conv, t); err == nil {
+			badWrites[spTable] += l.BadWrites
+		}
+		if l.LoadedAt.IsZero() {
+			unknown = true
+		} else if l.LoadedAt.After(loadedAt) {
+			loadedAt = l.LoadedAt
+		}
+	}
+	if len(missing) > 0 {
+		return nil, time.Time{}, fmt.Errorf("data of tables %s isn't loaded", strings.Join(missing, ", "))
+	}
+	if unknown {
+		loadedAt = time.Time{}
+	}
+	return badWrites, loadedAt, nil
+}
+
+func writeState(store StateStore, name string, x interface{}) error {
+	b, err := json.MarshalIndent(x, "", "  ")
+	if err != nil {
+		return err
+	}
+	return store.Write(name, append(b, '\n'))
+}
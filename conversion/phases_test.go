@@ -0,0 +1,165 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package conversion
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/cloudspannerecosystem/harbourbridge/internal"
+	"github.com/cloudspannerecosystem/harbourbridge/schema"
+)
+
+func tableLoadsConv(tables ...string) *internal.Conv {
+	conv := internal.MakeConv()
+	for _, t := range tables {
+		conv.SrcSchema[t] = schema.Table{Name: t}
+		conv.ToSpanner[t] = internal.NameAndCols{Name: "sp_" + t}
+	}
+	return conv
+}
+
+func TestTableLoads(t *testing.T) {
+	now := time.Date(2021, 3, 1, 10, 0, 0, 0, time.UTC)
+	t1 := now.Add(time.Minute)
+	t2 := now.Add(2 * time.Minute)
+	tests := []struct {
+		name     string
+		loadedAt []time.Time // One load per batch of tables: [a], [b].
+		want     time.Time
+	}{
+		{name: "latest load", loadedAt: []time.Time{t2, t1}, want: t2},
+		{name: "one load unknown", loadedAt: []time.Time{t1, {}}, want: time.Time{}},
+		{name: "all unknown", loadedAt: []time.Time{{}, {}}, want: time.Time{}},
+	}
+	for _, tc := range tests {
+		dir, err := ioutil.TempDir("", "phases-test-")
+		assert.Nil(t, err)
+		defer os.RemoveAll(dir)
+		store := dirStore(dir)
+		conv := tableLoadsConv("a", "b")
+		conv.Stats.Rows["a"], conv.Stats.GoodRows["a"], conv.Stats.BadRows["a"] = 10, 9, 1
+		conv.Stats.Rows["b"], conv.Stats.GoodRows["b"] = 5, 5
+		badWrites := map[string]int64{"sp_a": 2, "sp_b": 3}
+		assert.Nil(t, WriteTableLoads(store, conv, []string{"a"}, badWrites, now, tc.loadedAt[0]), tc.name)
+		assert.Nil(t, WriteTableLoads(store, conv, []string{"b"}, badWrites, now, tc.loadedAt[1]), tc.name)
+
+		conv = tableLoadsConv("a", "b")
+		gotBadWrites, gotLoadedAt, err := LoadTableStats(store, conv)
+		assert.Nil(t, err, tc.name)
+		assert.Equal(t, badWrites, gotBadWrites, tc.name)
+		assert.True(t, tc.want.Equal(gotLoadedAt), tc.name)
+		assert.Equal(t, map[string]int64{"a": 10, "b": 5}, conv.Stats.Rows, tc.name)
+		assert.Equal(t, map[string]int64{"a": 9, "b": 5}, conv.Stats.GoodRows, tc.name)
+		assert.Equal(t, map[string]int64{"a": 1, "b": 0}, conv.Stats.BadRows, tc.name)
+	}
+}
+
+func TestLoadTableStats_Errors(t *testing.T) {
+	dir, err := ioutil.TempDir("", "phases-test-")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+	store := dirStore(dir)
+	now := time.Date(2021, 3, 1, 10, 0, 0, 0, time.UTC)
+
+	conv := tableLoadsConv("a", "b", "c")
+	assert.Nil(t, WriteTableLoads(store, conv, []string{"b"}, nil, now, now))
+	_, _, err = LoadTableStats(store, conv)
+	assert.EqualError(t, err, "data of tables a, c isn't loaded")
+
+	assert.Nil(t, ioutil.WriteFile(filepath.Join(dir, tableObject("a")), []byte("{"), 0644))
+	_, err = ReadTableLoad(store, "a")
+	assert.Contains(t, err.Error(), "can't decode record of table a")
+	_, _, err = LoadTableStats(store, tableLoadsConv("a"))
+	assert.Contains(t, err.Error(), "can't decode record of table a")
+}
+
+func TestCheckPhase(t *testing.T) {
+	dir, err := ioutil.TempDir("", "phases-test-")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+	store := dirStore(dir)
+	end := time.Date(2021, 3, 1, 10, 0, 0, 0, time.UTC)
+
+	assert.Nil(t, CheckPhase(store, AssessPhase))
+	assert.EqualError(t, CheckPhase(store, DataPhase), "can't run phase data: phase assess hasn't completed")
+	assert.EqualError(t, CheckPhase(store, "load"), `unknown phase "load" (accepted values are assess, schema, data, verify)`)
+
+	for _, p := range []string{AssessPhase, SchemaPhase, DataPhase} {
+		assert.Nil(t, WritePhase(store, PhaseRecord{Phase: p, End: end}))
+	}
+	assert.EqualError(t, CheckPhase(store, SchemaPhase), "can't run phase schema: it already completed at 2021-03-01T10:00:00Z")
+	// Data is loaded in batches of tables.
+	assert.Nil(t, CheckPhase(store, DataPhase))
+	assert.Nil(t, CheckPhase(store, VerifyPhase))
+
+	r, err := ReadPhase(store, SchemaPhase)
+	assert.Nil(t, err)
+	assert.Equal(t, &PhaseRecord{Phase: SchemaPhase, End: end}, r)
+	assert.Nil(t, ioutil.WriteFile(filepath.Join(dir, phaseObject(AssessPhase)), []byte("{"), 0644))
+	assert.Contains(t, CheckPhase(store, SchemaPhase).Error(), "can't decode record of phase assess")
+}
+
+func TestStateSession(t *testing.T) {
+	dir, err := ioutil.TempDir("", "phases-test-")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+	store := dirStore(filepath.Join(dir, "state"))
+
+	conv := internal.MakeConv()
+	assert.EqualError(t, ReadStateSession(store, conv), "no session in state store")
+	assert.NotNil(t, WriteStateSession(store, filepath.Join(dir, "missing.session.json")))
+
+	session := filepath.Join(dir, "session.json")
+	assert.Nil(t, ioutil.WriteFile(session, []byte(`{"SrcSchema": {"a": {"Name": "a"}}}`), 0644))
+	assert.Nil(t, WriteStateSession(store, session))
+	assert.Nil(t, ReadStateSession(store, conv))
+	assert.Equal(t, "a", conv.SrcSchema["a"].Name)
+
+	assert.Nil(t, ioutil.WriteFile(session, []byte("{"), 0644))
+	assert.Nil(t, WriteStateSession(store, session))
+	assert.Contains(t, ReadStateSession(store, conv).Error(), "can't decode session")
+}
+
+func TestOpenStateStore(t *testing.T) {
+	dir, err := ioutil.TempDir("", "phases-test-")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+
+	_, err = OpenStateStore("gs://")
+	assert.EqualError(t, err, "invalid state store gs://: no bucket")
+	_, err = OpenStateStore("spanner://projects/p/instances/i")
+	assert.Contains(t, err.Error(), "invalid state store spanner://projects/p/instances/i")
+	file := filepath.Join(dir, "file")
+	assert.Nil(t, ioutil.WriteFile(file, nil, 0644))
+	_, err = OpenStateStore(filepath.Join(file, "state"))
+	assert.Contains(t, err.Error(), "can't create state directory")
+
+	// Objects of tables whose names aren't valid file names.
+	store, err := OpenStateStore(filepath.Join(dir, "state"))
+	assert.Nil(t, err)
+	b, err := store.Read(tableObject("sales/orders"))
+	assert.Nil(t, err)
+	assert.Nil(t, b)
+	assert.Nil(t, WriteTableLoads(store, tableLoadsConv("sales/orders"), []string{"sales/orders"}, nil, time.Time{}, time.Time{}))
+	l, err := ReadTableLoad(store, "sales/orders")
+	assert.Nil(t, err)
+	assert.Equal(t, "sales/orders", l.Table)
+}
@@ -0,0 +1,112 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package conversion
+
+import (
+	"context"
+	"fmt"
+	"os"
+
+	instance "cloud.google.com/go/spanner/admin/instance/apiv1"
+	instancepb "google.golang.org/genproto/googleapis/spanner/admin/instance/v1"
+	"google.golang.org/genproto/protobuf/field_mask"
+)
+
+// ScaleInstance raises the compute capacity of a Spanner instance to
+// opts.LoadProcessingUnits for the data conversion phase. It returns a
+// function that restores the instance's original capacity, which
+// callers should defer (it is a no-op if no scaling was done).
+//
+// Since scaling changes the cost of the instance, and affects any
+// other databases it serves, ScaleInstance only scales if
+// opts.ConfirmScaling is set; otherwise it describes the change it
+// would make and returns an error.
+func ScaleInstance(project, instanceID string, opts SpannerOptions, out *os.File) (func(), error) {
+	noop := func() {}
+	if opts.LoadProcessingUnits == 0 {
+		return noop, nil
+	}
+	ctx := context.Background()
+	client, err := instance.NewInstanceAdminClient(ctx)
+	if err != nil {
+		return noop, fmt.Errorf("can't create instance admin client: %w", analyzeError(err, project, instanceID))
+	}
+	name := fmt.Sprintf("projects/%s/instances/%s", project, instanceID)
+	inst, err := client.GetInstance(ctx, &instancepb.GetInstanceRequest{Name: name})
+	if err != nil {
+		client.Close()
+		return noop, fmt.Errorf("can't get instance %s: %w", instanceID, analyzeError(err, project, instanceID))
+	}
+	orig := processingUnits(inst)
+	if orig >= opts.LoadProcessingUnits {
+		client.Close()
+		fmt.Fprintf(out, "Instance %s already has %d processing units: not scaling it for data conversion.\n", instanceID, orig)
+		return noop, nil
+	}
+	if !opts.ConfirmScaling {
+		client.Close()
+		fmt.Fprintf(out, "Data conversion would scale instance %s from %d to %d processing units, and restore it to %d processing units afterwards.\n"+
+			"This changes the cost of the instance and affects all databases in it. Use -scale-confirm to confirm.\n",
+			instanceID, orig, opts.LoadProcessingUnits, orig)
+		return noop, fmt.Errorf("scaling of instance %s not confirmed", instanceID)
+	}
+	fmt.Fprintf(out, "Scaling instance %s from %d to %d processing units for data conversion ... ", instanceID, orig, opts.LoadProcessingUnits)
+	if err := setProcessingUnits(ctx, client, name, opts.LoadProcessingUnits); err != nil {
+		client.Close()
+		fmt.Fprintf(out, "failed.\n")
+		return noop, fmt.Errorf("can't scale instance %s: %w", instanceID, analyzeError(err, project, instanceID))
+	}
+	fmt.Fprintf(out, "done.\n")
+	return func() {
+		defer client.Close()
+		// Don't undo changes made by someone else while we were running.
+		inst, err := client.GetInstance(ctx, &instancepb.GetInstanceRequest{Name: name})
+		if err != nil {
+			fmt.Fprintf(out, "Can't get instance %s to restore its size: %v\nPlease restore it to %d processing units manually.\n", instanceID, err, orig)
+			return
+		}
+		if n := processingUnits(inst); n != opts.LoadProcessingUnits {
+			fmt.Fprintf(out, "Instance %s was resized to %d processing units during data conversion: not restoring it to %d processing units.\n", instanceID, n, orig)
+			return
+		}
+		fmt.Fprintf(out, "Restoring instance %s to %d processing units ... ", instanceID, orig)
+		if err := setProcessingUnits(ctx, client, name, orig); err != nil {
+			fmt.Fprintf(out, "failed: %v\nPlease restore it to %d processing units manually.\n", err, orig)
+			return
+		}
+		fmt.Fprintf(out, "done.\n")
+	}, nil
+}
+
+// processingUnits returns the compute capacity of inst in processing
+// units (1 node is 1000 processing units).
+func processingUnits(inst *instancepb.Instance) int32 {
+	if inst.ProcessingUnits != 0 {
+		return inst.ProcessingUnits
+	}
+	return inst.NodeCount * 1000
+}
+
+func setProcessingUnits(ctx context.Context, client *instance.InstanceAdminClient, name string, n int32) error {
+	op, err := client.UpdateInstance(ctx, &instancepb.UpdateInstanceRequest{
+		Instance:  &instancepb.Instance{Name: name, ProcessingUnits: n},
+		FieldMask: &field_mask.FieldMask{Paths: []string{"processing_units"}},
+	})
+	if err != nil {
+		return err
+	}
+	_, err = op.Wait(ctx)
+	return err
+}
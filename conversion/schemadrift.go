@@ -0,0 +1,128 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package conversion
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"io"
+	"strings"
+
+	database "cloud.google.com/go/spanner/admin/database/apiv1"
+	adminpb "google.golang.org/genproto/googleapis/spanner/admin/database/v1"
+
+	"github.com/cloudspannerecosystem/harbourbridge/internal"
+	"github.com/cloudspannerecosystem/harbourbridge/spanner/ddl"
+)
+
+// Values of SourceOptions.SchemaDrift.
+const (
+	DriftAbort  = "abort"  // Data conversion fails.
+	DriftWarn   = "warn"   // The changes are listed, and data conversion goes on.
+	DriftIgnore = "ignore" // The source schema isn't checked.
+)
+
+// sourceSchemaDrift reads the schema of source database db again, and
+// returns how it differs from the source schema of conv (see
+// internal.Conv.SchemaDrift).
+func sourceSchemaDrift(driver string, conv *internal.Conv, db *sql.DB) ([]string, error) {
+	if conv.SrcSchemaHash == "" {
+		return nil, nil
+	}
+	current, err := readSourceSchema(driver, conv, db)
+	if err != nil {
+		return nil, err
+	}
+	return conv.SchemaDrift(current.SrcSchema), nil
+}
+
+// propagateSchemaChanges reads the schema of source database db again,
+// and applies the changes that can be propagated (see
+// internal.Conv.SchemaChanges) to Spanner database spDB, then to conv. It
+// returns the changes applied, and the other changes.
+func propagateSchemaChanges(driver string, conv *internal.Conv, db *sql.DB, spDB string) ([]string, []string, error) {
+	if conv.SrcSchemaHash == "" {
+		return nil, nil, nil
+	}
+	current, err := readSourceSchema(driver, conv, db)
+	if err != nil {
+		return nil, nil, err
+	}
+	changes, others := conv.SchemaChanges(current)
+	var stmts, applied []string
+	for _, ch := range changes {
+		if ch.DDL != "" {
+			if err := ddl.CheckSyntax(ch.DDL, false); err != nil {
+				return nil, nil, fmt.Errorf("can't propagate change of table %s (%s): %w in statement %s", ch.SrcTable, ch.Description, err, ch.DDL)
+			}
+			stmts = append(stmts, ch.DDL)
+		}
+		applied = append(applied, fmt.Sprintf("Table %s: %s", ch.SrcTable, ch.Description))
+	}
+	if len(stmts) > 0 {
+		ctx := context.Background()
+		adminClient, err := database.NewDatabaseAdminClient(ctx)
+		if err != nil {
+			return nil, nil, fmt.Errorf("can't create admin client: %w", err)
+		}
+		defer adminClient.Close()
+		op, err := adminClient.UpdateDatabaseDdl(ctx, &adminpb.UpdateDatabaseDdlRequest{Database: spDB, Statements: stmts})
+		if err == nil {
+			err = op.Wait(ctx)
+		}
+		if err != nil {
+			return nil, nil, fmt.Errorf("can't propagate source schema changes to %s: %w", spDB, err)
+		}
+	}
+	conv.ApplySchemaChanges(current, changes)
+	if len(others) == 0 {
+		conv.SrcSchemaHash = internal.SchemaHash(current.SrcSchema)
+	}
+	return applied, others, nil
+}
+
+// readSourceSchema reads the schema of source database db again, as it
+// was read for conv.
+func readSourceSchema(driver string, conv *internal.Conv, db *sql.DB) (*internal.Conv, error) {
+	current := internal.MakeConv()
+	current.TargetDb = conv.TargetDb
+	current.Features = conv.Features
+	current.NameMapping = conv.NameMapping
+	current.SrcVersion = conv.SrcVersion
+	if err := ProcessInfoSchema(driver, current, db); err != nil {
+		return nil, fmt.Errorf("can't read source schema: %w", err)
+	}
+	return current, nil
+}
+
+// checkSchemaDrift checks that the source schema hasn't changed since the
+// schema of conv was converted, before its data is converted from a
+// session file: the rows of a changed table may not fit its Spanner
+// table, or lose data. The changes are handled as given by policy.
+func checkSchemaDrift(driver string, conv *internal.Conv, db *sql.DB, policy string, out io.Writer) error {
+	if policy == DriftIgnore {
+		return nil
+	}
+	drift, err := sourceSchemaDrift(driver, conv, db)
+	if err != nil || len(drift) == 0 {
+		return err
+	}
+	if policy == DriftWarn {
+		fmt.Fprintf(out, "Warning: the source schema changed since the session was created:\n  %s\n", strings.Join(drift, "\n  "))
+		return nil
+	}
+	return fmt.Errorf("the source schema changed since the session was created (use -schema-drift=%s to convert the data anyway):\n  %s", DriftWarn, strings.Join(drift, "\n  "))
+}
@@ -0,0 +1,85 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package conversion
+
+import (
+	"github.com/cloudspannerecosystem/harbourbridge/internal"
+)
+
+// SchemaSettings are the settings of a migration applied to the Spanner
+// schema converted from the source, or read from a session file (see
+// PrepareSchema).
+type SchemaSettings struct {
+	// Seed is the seed of the random values generated during conversion
+	// (see internal.Conv.SetSeed).
+	Seed         int64
+	DropColumns  []string // Source columns (table.column) to drop.
+	ComputedCols []internal.ComputedCol
+	Remodel      internal.Remodel
+	Policies     internal.Policies
+	Ordering     internal.Ordering
+	// ForeignKeyNames is the template for naming foreign keys (see
+	// internal.Conv.NameForeignKeys).
+	ForeignKeyNames string
+}
+
+// PrepareSchema applies settings to conv, whose schema was just converted
+// or, if fromSession is set, read from a session file. It is the one
+// place where the harbourbridge command and the engine package apply
+// their settings, so that both give the same schema for the same
+// settings, and reject the same invalid policies before any data is
+// converted. Suggestions (e.g. remodel.AutoPartition), overflow tables
+// and foreign key names are recorded in session files, so they are only
+// added to converted schemas.
+func PrepareSchema(conv *internal.Conv, settings SchemaSettings, fromSession bool) error {
+	conv.SetSeed(settings.Seed)
+	if err := conv.DropColumns(settings.DropColumns); err != nil {
+		return err
+	}
+	conv.SkipColumnlessTables()
+	remodel := settings.Remodel
+	if fromSession {
+		remodel.AutoPartition = false
+		remodel.AutoMoney = false
+		remodel.AutoBools = false
+		remodel.AutoDropIndexes = false
+		remodel.AutoLongKeys = false
+	}
+	if err := conv.ApplyRemodel(remodel); err != nil {
+		return err
+	}
+	if err := conv.AddComputedCols(settings.ComputedCols); err != nil {
+		return err
+	}
+	conv.Policies = settings.Policies
+	if err := conv.CheckMasks(); err != nil {
+		return err
+	}
+	if err := conv.CheckDateRules(); err != nil {
+		return err
+	}
+	if err := conv.CheckValueDictionaries(); err != nil {
+		return err
+	}
+	conv.Ordering = settings.Ordering
+	conv.RelaxNotNull()
+	if fromSession {
+		return nil
+	}
+	if settings.Policies.Oversize == internal.OverflowOversize {
+		conv.AddOverflowTables()
+	}
+	return conv.NameForeignKeys(settings.ForeignKeyNames)
+}
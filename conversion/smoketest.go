@@ -0,0 +1,415 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package conversion
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+
+	database "cloud.google.com/go/spanner/admin/database/apiv1"
+	instance "cloud.google.com/go/spanner/admin/instance/apiv1"
+	adminpb "google.golang.org/genproto/googleapis/spanner/admin/database/v1"
+	instancepb "google.golang.org/genproto/googleapis/spanner/admin/instance/v1"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// SmokeTestConfig configures a smoke test (see SmokeTest).
+type SmokeTestConfig struct {
+	Driver string // POSTGRES or MYSQL.
+	// Fixtures is a file of SQL statements run on the source database
+	// before it is migrated, e.g. a schema-only dump of the production
+	// database with a few rows. Empty means a built-in fixture with a few
+	// tables and rows.
+	Fixtures string
+	// Project and Instance are the Spanner instance the ephemeral
+	// database is created in. Empty Instance means the Spanner emulator is
+	// run in a container instead.
+	Project  string
+	Instance string
+	// Binary is the HarbourBridge executable that runs the phases, with
+	// Args (e.g. the flags of the production run) in addition to the
+	// flags selecting the driver, database, phase and state store.
+	Binary string
+	Args   []string
+	// Dir is the directory the state, logs and reports of the phases are
+	// written to. It is kept after the smoke test.
+	Dir string
+}
+
+// SmokeTestReport is the result of a smoke test. Its steps are reported
+// as the steps of a cutover.
+type SmokeTestReport struct {
+	Driver          string        `json:"driver"`
+	Database        string        `json:"database"` // Spanner database URI.
+	Start           time.Time     `json:"start"`
+	DurationSeconds float64       `json:"duration_seconds"`
+	Steps           []CutoverStep `json:"steps"`
+	// Passed is true if all phases of the migration succeeded.
+	Passed bool `json:"passed"`
+}
+
+// Images and settings of the containers run by smoke tests.
+const (
+	smokeEmulatorImage = "gcr.io/cloud-spanner-emulator/emulator"
+	smokeEmulatorPort  = "9010"
+	smokeProject       = "harbourbridge-smoke-test"
+	smokeDatabase      = "smoke"
+	smokePassword      = "smoke-test"
+	smokeReadyTimeout  = 2 * time.Minute
+)
+
+// smokeSource describes the container running the source database of a
+// driver.
+type smokeSource struct {
+	image string
+	port  string
+	env   []string // Environment of the container.
+	ready []string // Command exiting with 0 once the database accepts connections.
+	seed  []string // Command running the statements read from its stdin.
+	// connEnv returns the environment variables connecting HarbourBridge
+	// to the database on host and port.
+	connEnv func(host, port string) []string
+}
+
+var smokeSources = map[string]smokeSource{
+	POSTGRES: {
+		image: "postgres:15",
+		port:  "5432",
+		env:   []string{"POSTGRES_PASSWORD=" + smokePassword, "POSTGRES_DB=" + smokeDatabase},
+		// The server started while the database is initialized only
+		// listens on a Unix socket.
+		ready: []string{"pg_isready", "-h", "127.0.0.1", "-U", "postgres", "-d", smokeDatabase},
+		seed:  []string{"psql", "-q", "-v", "ON_ERROR_STOP=1", "-U", "postgres", "-d", smokeDatabase},
+		connEnv: func(host, port string) []string {
+			return []string{"PGHOST=" + host, "PGPORT=" + port, "PGUSER=postgres", "PGPASSWORD=" + smokePassword, "PGDATABASE=" + smokeDatabase}
+		},
+	},
+	MYSQL: {
+		image: "mysql:8",
+		port:  "3306",
+		env:   []string{"MYSQL_ROOT_PASSWORD=" + smokePassword, "MYSQL_DATABASE=" + smokeDatabase},
+		ready: []string{"mysqladmin", "ping", "-h", "127.0.0.1", "-uroot", "-p" + smokePassword, "--silent"},
+		seed:  []string{"mysql", "-uroot", "-p" + smokePassword, smokeDatabase},
+		connEnv: func(host, port string) []string {
+			return []string{"MYSQLHOST=" + host, "MYSQLPORT=" + port, "MYSQLUSER=root", "MYSQLPWD=" + smokePassword, "MYSQLDATABASE=" + smokeDatabase}
+		},
+	},
+}
+
+// smokeFixtures are the built-in fixtures of each driver: tables with
+// common types, a foreign key, an index, NULLs and non-ASCII strings.
+var smokeFixtures = map[string]string{
+	POSTGRES: `CREATE TABLE customers (
+  id bigint PRIMARY KEY,
+  name varchar(100) NOT NULL,
+  email text UNIQUE,
+  created_at timestamptz NOT NULL DEFAULT now()
+);
+CREATE TABLE orders (
+  id serial PRIMARY KEY,
+  customer_id bigint NOT NULL REFERENCES customers (id),
+  total numeric(10,2),
+  paid boolean,
+  tags text[],
+  placed date,
+  details jsonb
+);
+CREATE INDEX orders_placed ON orders (placed);
+INSERT INTO customers (id, name, email) VALUES (1, 'Ada', 'ada@example.com'), (2, 'Zoë', NULL), (3, '李', 'li@example.com');
+INSERT INTO orders (customer_id, total, paid, tags, placed, details) VALUES
+  (1, 12.50, true, '{gift,express}', '2020-01-31', '{"items": 2}'),
+  (1, NULL, false, NULL, NULL, NULL),
+  (3, 99999999.99, true, '{}', '1999-12-31', '{"items": []}');
+`,
+	MYSQL: `CREATE TABLE customers (
+  id bigint PRIMARY KEY,
+  name varchar(100) NOT NULL,
+  email varchar(255) UNIQUE,
+  created_at timestamp NOT NULL DEFAULT CURRENT_TIMESTAMP
+) DEFAULT CHARSET=utf8mb4;
+CREATE TABLE orders (
+  id int AUTO_INCREMENT PRIMARY KEY,
+  customer_id bigint NOT NULL,
+  total decimal(10,2),
+  paid tinyint(1),
+  status enum('new','shipped'),
+  placed date,
+  details json,
+  KEY orders_placed (placed),
+  FOREIGN KEY (customer_id) REFERENCES customers (id)
+) DEFAULT CHARSET=utf8mb4;
+INSERT INTO customers (id, name, email) VALUES (1, 'Ada', 'ada@example.com'), (2, 'Zoë', NULL), (3, '李', 'li@example.com');
+INSERT INTO orders (customer_id, total, paid, status, placed, details) VALUES
+  (1, 12.50, 1, 'shipped', '2020-01-31', '{"items": 2}'),
+  (1, NULL, 0, NULL, NULL, NULL),
+  (3, 99999999.99, 1, 'new', '1999-12-31', '{"items": []}');
+`,
+}
+
+// SmokeTest runs a migration end to end against ephemeral databases, to
+// check a configuration before running it in production: it starts the
+// source database in a Docker container and seeds it with cfg.Fixtures,
+// runs the assess, schema, data and verify phases with cfg.Binary (each
+// logged to a file of cfg.Dir), then tears down the containers and the
+// Spanner database. Teardown steps always run, while other steps stop at
+// the first failure. The report lists the steps that ran.
+func SmokeTest(cfg SmokeTestConfig) (SmokeTestReport, error) {
+	src, ok := smokeSources[cfg.Driver]
+	if !ok {
+		return SmokeTestReport{}, fmt.Errorf("smoke tests for driver %s not supported", cfg.Driver)
+	}
+	fixtures := []byte(smokeFixtures[cfg.Driver])
+	if cfg.Fixtures != "" {
+		var err error
+		if fixtures, err = ioutil.ReadFile(cfg.Fixtures); err != nil {
+			return SmokeTestReport{}, fmt.Errorf("can't read fixtures file %s: %w", cfg.Fixtures, err)
+		}
+	}
+	now := time.Now()
+	dbName := "smoke-" + now.Format("20060102-150405")
+	suffix := fmt.Sprintf("%s-%d", dbName, os.Getpid())
+	srcContainer := "harbourbridge-source-" + suffix
+	emulatorContainer := "harbourbridge-emulator-" + suffix
+	project, instanceID := cfg.Project, cfg.Instance
+	if instanceID == "" {
+		project, instanceID = smokeProject, "smoke"
+	}
+	r := SmokeTestReport{
+		Driver:   cfg.Driver,
+		Database: fmt.Sprintf("projects/%s/instances/%s/databases/%s", project, instanceID, dbName),
+		Start:    now,
+	}
+	var srcEnv []string
+	phaseArgs := func(phase string) []string {
+		args := []string{"-driver=" + cfg.Driver, "-instance=" + instanceID, "-dbname=" + dbName, "-state=" + filepath.Join(cfg.Dir, "state"), "-phase=" + phase}
+		return append(args, cfg.Args...)
+	}
+	type step struct {
+		name     string
+		skip     bool
+		teardown bool
+		run      func() (string, error)
+	}
+	steps := []step{
+		{name: "start-source", run: func() (string, error) {
+			host, port, err := startContainer(srcContainer, src.image, src.port, src.env, nil)
+			if err != nil {
+				return "", err
+			}
+			if err := waitReady(srcContainer, src.ready); err != nil {
+				return "", err
+			}
+			srcEnv = append(src.connEnv(host, port), "GCLOUD_PROJECT="+project)
+			return fmt.Sprintf("%s on %s:%s", src.image, host, port), nil
+		}},
+		{name: "seed-source", run: func() (string, error) {
+			if _, err := docker(bytes.NewReader(fixtures), append([]string{"exec", "-i", srcContainer}, src.seed...)...); err != nil {
+				return "", fmt.Errorf("can't load fixtures: %w", err)
+			}
+			return fmt.Sprintf("%d bytes of fixtures", len(fixtures)), nil
+		}},
+		{name: "start-spanner", skip: cfg.Instance != "", run: func() (string, error) {
+			return startEmulator(emulatorContainer, project, instanceID)
+		}},
+	}
+	for _, phase := range Phases {
+		phase := phase
+		steps = append(steps, step{name: phase, run: func() (string, error) {
+			return runPhase(cfg.Binary, cfg.Dir, phase, phaseArgs(phase), srcEnv)
+		}})
+	}
+	steps = append(steps,
+		step{name: "drop-database", skip: cfg.Instance == "", teardown: true, run: func() (string, error) {
+			return dropDatabase(project, instanceID, r.Database)
+		}},
+		step{name: "stop-spanner", skip: cfg.Instance != "", teardown: true, run: func() (string, error) {
+			return removeContainer(emulatorContainer)
+		}},
+		step{name: "stop-source", teardown: true, run: func() (string, error) {
+			return removeContainer(srcContainer)
+		}},
+	)
+	var err error
+	for _, s := range steps {
+		if err != nil && !s.teardown {
+			continue
+		}
+		cs := CutoverStep{Name: s.name, Start: time.Now(), Skipped: s.skip}
+		var stepErr error
+		if !s.skip {
+			cs.Detail, stepErr = s.run()
+		}
+		cs.DurationSeconds = time.Since(cs.Start).Seconds()
+		if stepErr != nil {
+			cs.Error = stepErr.Error()
+			if err == nil {
+				err = fmt.Errorf("smoke test step %s failed: %w", s.name, stepErr)
+			}
+		}
+		r.Steps = append(r.Steps, cs)
+	}
+	r.DurationSeconds = time.Since(r.Start).Seconds()
+	r.Passed = err == nil
+	return r, err
+}
+
+// docker runs the docker command with args, and returns its output.
+func docker(stdin io.Reader, args ...string) (string, error) {
+	cmd := exec.Command("docker", args...)
+	cmd.Stdin = stdin
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		return "", fmt.Errorf("docker %s failed: %v: %s", args[0], err, strings.TrimSpace(stderr.String()))
+	}
+	return strings.TrimSpace(string(out)), nil
+}
+
+// startContainer starts container name from image, and returns the host
+// and port that port of the container is published on (a free port of
+// the loopback interface).
+func startContainer(name, image, port string, env, args []string) (string, string, error) {
+	run := []string{"run", "-d", "--rm", "--name", name, "-p", "127.0.0.1::" + port}
+	for _, e := range env {
+		run = append(run, "-e", e)
+	}
+	run = append(append(run, image), args...)
+	if _, err := docker(nil, run...); err != nil {
+		return "", "", err
+	}
+	out, err := docker(nil, "port", name, port+"/tcp")
+	if err != nil {
+		return "", "", err
+	}
+	host, hostPort, err := net.SplitHostPort(strings.Fields(out)[0])
+	if err != nil {
+		return "", "", fmt.Errorf("can't parse port of container %s: %w", name, err)
+	}
+	return host, hostPort, nil
+}
+
+// removeContainer removes container name and its volumes.
+func removeContainer(name string) (string, error) {
+	if _, err := docker(nil, "rm", "-f", "-v", name); err != nil {
+		return "", err
+	}
+	return "removed", nil
+}
+
+// waitReady runs command in container until it succeeds.
+func waitReady(container string, command []string) error {
+	deadline := time.Now().Add(smokeReadyTimeout)
+	for {
+		_, err := docker(nil, append([]string{"exec", container}, command...)...)
+		if err == nil {
+			return nil
+		}
+		if time.Now().After(deadline) {
+			return fmt.Errorf("container %s not ready after %v: %w", container, smokeReadyTimeout, err)
+		}
+		time.Sleep(time.Second)
+	}
+}
+
+// startEmulator starts the Spanner emulator in container name, points
+// the Spanner clients of this process and of the phases at it, and
+// creates instance instanceID of project in it.
+func startEmulator(name, project, instanceID string) (string, error) {
+	host, port, err := startContainer(name, smokeEmulatorImage, smokeEmulatorPort, nil, nil)
+	if err != nil {
+		return "", err
+	}
+	os.Setenv("SPANNER_EMULATOR_HOST", net.JoinHostPort(host, port))
+	ctx := context.Background()
+	client, err := instance.NewInstanceAdminClient(ctx)
+	if err != nil {
+		return "", fmt.Errorf("can't create instance admin client: %w", err)
+	}
+	defer client.Close()
+	req := &instancepb.CreateInstanceRequest{
+		Parent:     "projects/" + project,
+		InstanceId: instanceID,
+		Instance: &instancepb.Instance{
+			Config:      fmt.Sprintf("projects/%s/instanceConfigs/emulator-config", project),
+			DisplayName: instanceID,
+			NodeCount:   1,
+		},
+	}
+	// The emulator doesn't accept connections right after it starts.
+	deadline := time.Now().Add(smokeReadyTimeout)
+	for {
+		op, err := client.CreateInstance(ctx, req)
+		if err == nil {
+			_, err = op.Wait(ctx)
+		}
+		if err == nil {
+			break
+		}
+		if time.Now().After(deadline) {
+			return "", fmt.Errorf("can't create emulator instance %s: %w", instanceID, err)
+		}
+		time.Sleep(time.Second)
+	}
+	return fmt.Sprintf("emulator on %s:%s", host, port), nil
+}
+
+// runPhase runs phase with binary in dir, with args and the environment
+// of this process and env, and logs its output to a file of dir.
+func runPhase(binary, dir, phase string, args, env []string) (string, error) {
+	log := filepath.Join(dir, phase+".log")
+	f, err := os.Create(log)
+	if err != nil {
+		return "", fmt.Errorf("can't create log file %s: %w", log, err)
+	}
+	defer f.Close()
+	cmd := exec.Command(binary, args...)
+	cmd.Dir = dir
+	cmd.Env = append(os.Environ(), env...)
+	cmd.Stdout = f
+	cmd.Stderr = f
+	if err := cmd.Run(); err != nil {
+		return "", fmt.Errorf("phase %s failed (see %s): %w", phase, log, err)
+	}
+	return "log in " + log, nil
+}
+
+// dropDatabase drops database db of a real instance, if it was created.
+func dropDatabase(project, instanceID, db string) (string, error) {
+	ctx := context.Background()
+	adminClient, err := database.NewDatabaseAdminClient(ctx)
+	if err != nil {
+		return "", fmt.Errorf("can't create admin client: %w", analyzeError(err, project, instanceID))
+	}
+	defer adminClient.Close()
+	err = adminClient.DropDatabase(ctx, &adminpb.DropDatabaseRequest{Database: db})
+	if status.Code(err) == codes.NotFound {
+		return "not created", nil
+	}
+	if err != nil {
+		return "", fmt.Errorf("can't drop database %s: %w", db, analyzeError(err, project, instanceID))
+	}
+	return "dropped", nil
+}
@@ -0,0 +1,66 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package conversion
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSmokeTest_Errors(t *testing.T) {
+	// Errors found before any container is started.
+	_, err := SmokeTest(SmokeTestConfig{Driver: DYNAMODB})
+	assert.NotNil(t, err)
+	_, err = SmokeTest(SmokeTestConfig{Driver: POSTGRES, Fixtures: "testdata/missing.sql"})
+	assert.NotNil(t, err)
+}
+
+func TestSmokeSources(t *testing.T) {
+	for driver, src := range smokeSources {
+		assert.NotEmpty(t, smokeFixtures[driver], driver)
+		env := src.connEnv("localhost", "1234")
+		assert.Contains(t, strings.Join(env, " "), "localhost", driver)
+		assert.Contains(t, strings.Join(env, " "), "1234", driver)
+	}
+}
+
+func TestRunPhase(t *testing.T) {
+	dir, err := ioutil.TempDir("", "smoke-test-")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+	detail, err := runPhase("true", dir, DataPhase, nil, nil)
+	assert.Nil(t, err)
+	assert.Equal(t, "log in "+filepath.Join(dir, DataPhase+".log"), detail)
+
+	_, err = runPhase("false", dir, VerifyPhase, nil, nil)
+	assert.NotNil(t, err)
+	_, statErr := os.Stat(filepath.Join(dir, VerifyPhase+".log"))
+	assert.Nil(t, statErr)
+
+	// The output of the phase is logged.
+	_, err = runPhase("sh", dir, SchemaPhase, []string{"-c", "echo $SMOKE"}, []string{"SMOKE=phase output"})
+	assert.Nil(t, err)
+	b, err := ioutil.ReadFile(filepath.Join(dir, SchemaPhase+".log"))
+	assert.Nil(t, err)
+	assert.Equal(t, "phase output\n", string(b))
+
+	_, err = runPhase("true", filepath.Join(dir, "missing"), DataPhase, nil, nil)
+	assert.NotNil(t, err)
+}
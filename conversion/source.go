@@ -0,0 +1,286 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package conversion
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"io"
+	"net"
+	"strings"
+	"time"
+
+	"github.com/cloudspannerecosystem/harbourbridge/internal"
+	"github.com/cloudspannerecosystem/harbourbridge/mysql"
+	"github.com/cloudspannerecosystem/harbourbridge/postgres"
+)
+
+// ConsistentSnapshot is the SourceOptions.Snapshot value for reading all
+// tables from a snapshot taken when data conversion starts.
+const ConsistentSnapshot = "consistent"
+
+// SourceOptions configures how live source databases (the postgres and
+// mysql drivers) are read. The zero value reads from the host given by
+// the environment, and reads each table when it is converted.
+type SourceOptions struct {
+	// Replica is the host (host or host:port) of a read replica to read
+	// the schema and data from, instead of PGHOST/PGPORT or
+	// MYSQLHOST/MYSQLPORT. Other connection settings are unchanged.
+	Replica string
+	// Snapshot pins the reads of all tables to a single consistent
+	// snapshot, so that tables loaded hours apart are consistent with
+	// each other. It is ConsistentSnapshot, or a snapshot to read from:
+	// for PostgreSQL, the name of an exported snapshot (see
+	// pg_export_snapshot, or the snapshot exported when a logical
+	// replication slot is created), optionally followed by @ and the WAL
+	// location it was taken at (e.g. the consistent point of the slot),
+	// and for MySQL a GTID set that the server must have executed before
+	// the snapshot is taken. Empty means no snapshot.
+	//
+	// The position of the source that each table is read at is recorded
+	// in Conv.Snapshots. It is exact for MySQL snapshots (if the global
+	// read lock needed to take them can be acquired) and for PostgreSQL
+	// snapshots given with their WAL location.
+	Snapshot string
+	// SpillDir is a directory to keep the schema of converted tables in,
+	// one file per table, instead of keeping the whole schema in memory
+	// (see internal.Conv.SpillTo). It is for source databases with so many
+	// tables that their schema doesn't fit in memory. Empty means the
+	// schema is kept in memory.
+	SpillDir string
+	// Incremental maps source tables to their incremental key column, for
+	// loading only the rows changed since the previous load (see
+	// internal.Conv.SetIncremental). Watermarks are kept in the Spanner
+	// database (see WatermarkTable), and rows are inserted or updated.
+	Incremental map[string]string
+	// SoftDelete identifies the soft-deleted rows of source tables, which
+	// aren't migrated (see internal.Conv.SetSoftDelete). The zero value
+	// migrates all rows.
+	SoftDelete internal.SoftDelete
+	// Tenant selects the rows of a single tenant, to extract it from a
+	// multi-tenant source (see internal.Conv.SetTenant). The zero value
+	// migrates the rows of all tenants.
+	Tenant internal.Tenant
+	// Names specifies how source names are mapped to Spanner names, e.g.
+	// to transliterate non-ASCII names. It applies to all drivers.
+	Names internal.NameMapping
+	// Fetch configures batched reads of tables, e.g. for tables with very
+	// wide rows (see internal.FetchSize). The zero value reads each table
+	// with a single query.
+	Fetch internal.FetchSize
+	// SchemaDrift is how changes of the source schema since the session
+	// was created are handled when data is converted from a session
+	// file: DriftAbort, DriftWarn or DriftIgnore. Empty means DriftAbort.
+	SchemaDrift string
+	// Windows schedules the data conversion of source tables during time
+	// windows, e.g. to only read enormous tables at night (see
+	// internal.Conv.SetTableWindows). It applies to all drivers, but only
+	// tables of live source databases are read in the order their windows
+	// open: the tables of dumps are read in order, and their writes are
+	// paused while their window is closed. The zero value converts the
+	// data of all tables right away.
+	Windows internal.TableWindows
+	// Staging stages the data of the source in a Cloud Storage bucket
+	// before it is loaded, e.g. for sources in another cloud (see
+	// Staging). It applies to all live source databases, including
+	// DynamoDB. The zero value writes data directly to Spanner.
+	Staging Staging
+}
+
+// Validate checks that o can be used for driver.
+func (o SourceOptions) Validate(driver string) error {
+	if (o.Replica != "" || o.Snapshot != "") && driver != POSTGRES && driver != MYSQL {
+		return fmt.Errorf("source replicas and snapshots are only supported for drivers %s and %s (driver: %s)", POSTGRES, MYSQL, driver)
+	}
+	if o.SoftDelete.Column != "" && driver != POSTGRES && driver != MYSQL {
+		return fmt.Errorf("excluding soft-deleted rows is only supported for drivers %s and %s (driver: %s)", POSTGRES, MYSQL, driver)
+	}
+	if o.Tenant.Column != "" && driver != POSTGRES && driver != MYSQL {
+		return fmt.Errorf("extracting a tenant is only supported for drivers %s and %s (driver: %s)", POSTGRES, MYSQL, driver)
+	}
+	if len(o.Incremental) > 0 && driver != POSTGRES && driver != MYSQL {
+		return fmt.Errorf("incremental loads are only supported for drivers %s and %s (driver: %s)", POSTGRES, MYSQL, driver)
+	}
+	if o.Fetch.Rows > 0 && driver != POSTGRES && driver != MYSQL {
+		return fmt.Errorf("batched reads are only supported for drivers %s and %s (driver: %s)", POSTGRES, MYSQL, driver)
+	}
+	if o.SpillDir != "" && driver != POSTGRES && driver != MYSQL {
+		return fmt.Errorf("spilling the schema to disk is only supported for drivers %s and %s (driver: %s)", POSTGRES, MYSQL, driver)
+	}
+	if err := o.Staging.Validate(driver); err != nil {
+		return err
+	}
+	if len(o.Incremental) > 0 && (o.Staging.Step == StageExtract || o.Staging.Step == StageLoad) {
+		return fmt.Errorf("incremental loads can't be staged in separate steps")
+	}
+	switch o.SchemaDrift {
+	case "", DriftAbort, DriftWarn, DriftIgnore:
+	default:
+		return fmt.Errorf("invalid schema drift policy %q (accepted values are %q, %q and %q)", o.SchemaDrift, DriftAbort, DriftWarn, DriftIgnore)
+	}
+	if driver == POSTGRES && strings.ContainsAny(o.Snapshot, "'\\") {
+		return fmt.Errorf("invalid PostgreSQL snapshot name %q", o.Snapshot)
+	}
+	return nil
+}
+
+// DetectServerVersion detects the version of the server of the live
+// source database db when HarbourBridge connects to it, and records it in
+// conv, so that discovery queries are adjusted to it (see
+// internal.ServerVersion). The parts of the catalogs of old versions that
+// can't be read are printed to out as warnings, and reported as
+// unexpected conditions, unless the version was already recorded (e.g.
+// when the schema was converted). If the version can't be detected, it is
+// assumed to be recent.
+func DetectServerVersion(driver string, db *sql.DB, conv *internal.Conv, out io.Writer) {
+	var v internal.ServerVersion
+	var err error
+	var warnings []string
+	switch driver {
+	case POSTGRES:
+		v, err = postgres.ServerVersion(db)
+		warnings = postgres.VersionWarnings(v)
+	case MYSQL:
+		v, err = mysql.ServerVersion(db)
+		warnings = mysql.VersionWarnings(v)
+	default:
+		return
+	}
+	if err != nil {
+		fmt.Fprintf(out, "Warning: %v: assuming a recent version\n", err)
+		return
+	}
+	if v == conv.SrcVersion {
+		return
+	}
+	conv.SrcVersion = v
+	internal.VerbosePrintf("Source server: %s\n", v)
+	for _, w := range warnings {
+		fmt.Fprintf(out, "Warning: %s\n", w)
+		conv.Unexpected(w)
+	}
+}
+
+// replicaHostPort returns the host and port to connect to: the replica
+// if there is one (with port unchanged unless the replica gives one).
+func replicaHostPort(replica, host, port string) (string, string) {
+	if replica == "" {
+		return host, port
+	}
+	if h, p, err := net.SplitHostPort(replica); err == nil {
+		return h, p
+	}
+	return replica, port
+}
+
+// snapshotQueryer runs queries on a single connection, within a
+// read-only transaction.
+type snapshotQueryer struct {
+	conn *sql.Conn
+}
+
+func (s snapshotQueryer) Query(query string, args ...interface{}) (*sql.Rows, error) {
+	return s.conn.QueryContext(context.Background(), query, args...)
+}
+
+func (s snapshotQueryer) QueryRow(query string, args ...interface{}) *sql.Row {
+	return s.conn.QueryRowContext(context.Background(), query, args...)
+}
+
+func (s snapshotQueryer) exec(statement string, args ...interface{}) error {
+	_, err := s.conn.ExecContext(context.Background(), statement, args...)
+	return err
+}
+
+// beginSnapshot starts a read-only transaction on a connection of db that
+// reads from snapshot (see SourceOptions.Snapshot), and returns the
+// position of the source it reads at. Statements are run directly,
+// rather than with db.BeginTx, since database/sql can't ask for a
+// snapshot. The returned function ends the transaction. version is the
+// version of the server (see DetectServerVersion).
+func beginSnapshot(driver string, db *sql.DB, snapshot string, version internal.ServerVersion) (internal.Queryer, internal.SnapshotPosition, func(), error) {
+	var pos internal.SnapshotPosition
+	conn, err := db.Conn(context.Background())
+	if err != nil {
+		return nil, pos, nil, err
+	}
+	s := snapshotQueryer{conn: conn}
+	fail := func(err error) (internal.Queryer, internal.SnapshotPosition, func(), error) {
+		conn.Close()
+		return nil, pos, nil, err
+	}
+	switch driver {
+	case POSTGRES:
+		name, lsn := snapshot, ""
+		if i := strings.Index(snapshot, "@"); i >= 0 {
+			name, lsn = snapshot[:i], snapshot[i+1:]
+		}
+		if name == ConsistentSnapshot {
+			// The snapshot is taken by the first query of the transaction,
+			// after the WAL location is read: it can include changes made
+			// after that location.
+			if pos, err = postgres.CurrentPosition(s, version); err != nil {
+				return fail(err)
+			}
+		} else {
+			pos = internal.SnapshotPosition{Kind: "lsn", Position: lsn, Snapshot: name, Exact: lsn != "", Time: time.Now()}
+		}
+		if err := s.exec("BEGIN ISOLATION LEVEL REPEATABLE READ READ ONLY"); err != nil {
+			return fail(fmt.Errorf("can't start snapshot: %w", err))
+		}
+		if name != ConsistentSnapshot {
+			if err := s.exec(fmt.Sprintf("SET TRANSACTION SNAPSHOT '%s'", name)); err != nil {
+				return fail(fmt.Errorf("can't read from snapshot %s: %w", name, err))
+			}
+		}
+	case MYSQL:
+		if snapshot != ConsistentSnapshot {
+			if !mysql.CanWaitForGTIDs(version) {
+				return fail(fmt.Errorf("can't wait for GTID set %s: %s can't wait for GTID sets (MySQL 5.7 or later is needed)", snapshot, version))
+			}
+			var timedOut sql.NullInt64
+			if err := s.QueryRow("SELECT WAIT_FOR_EXECUTED_GTID_SET(?)", snapshot).Scan(&timedOut); err != nil {
+				return fail(fmt.Errorf("can't wait for GTID set %s: %w", snapshot, err))
+			}
+		}
+		if err := s.exec("SET SESSION TRANSACTION ISOLATION LEVEL REPEATABLE READ"); err != nil {
+			return fail(err)
+		}
+		// As for mysqldump, a global read lock keeps the position from
+		// moving while the snapshot is taken. It needs the RELOAD
+		// privilege: without it, the position is taken right after the
+		// snapshot.
+		locked := s.exec("FLUSH TABLES WITH READ LOCK") == nil
+		if err := s.exec("START TRANSACTION WITH CONSISTENT SNAPSHOT, READ ONLY"); err != nil {
+			return fail(fmt.Errorf("can't start snapshot: %w", err))
+		}
+		pos, err = mysql.CurrentPosition(s, version)
+		if locked {
+			s.exec("UNLOCK TABLES")
+		}
+		if err != nil {
+			return fail(err)
+		}
+		pos.Exact = locked
+	default:
+		return fail(fmt.Errorf("snapshots for driver %s not supported", driver))
+	}
+	end := func() {
+		s.exec("COMMIT")
+		conn.Close()
+	}
+	return s, pos, end, nil
+}
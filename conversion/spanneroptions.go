@@ -0,0 +1,229 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package conversion
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+
+	sp "cloud.google.com/go/spanner"
+	"google.golang.org/api/option"
+	sppb "google.golang.org/genproto/googleapis/spanner/v1"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/keepalive"
+	"google.golang.org/grpc/metadata"
+
+	"github.com/cloudspannerecosystem/harbourbridge/spanner"
+)
+
+// SpannerOptions configures how data is written to Spanner: how
+// migration traffic can be distinguished from (and deprioritized
+// relative to) live traffic, the client's session pool and gRPC
+// channels, scaling of the instance during data conversion, the
+// encryption of the new database, whether an existing database can be
+// used, who can access the database, and how its query optimizer is
+// prepared for cutover. The zero value gives the Spanner client's defaults.
+type SpannerOptions struct {
+	Priority       sppb.RequestOptions_Priority // Priority of commits (PRIORITY_UNSPECIFIED means Spanner's default, which is high).
+	TransactionTag string                       // Tag for write transactions, shown in Spanner's transaction statistics.
+	RequestTag     string                       // Tag for commits and DML requests, shown in Spanner's query statistics.
+	RouteToLeader  bool                         // Route requests to the leader region.
+	NumChannels    int                          // Number of gRPC channels (0 means the client default).
+	MinSessions    uint64                       // Minimum number of sessions in the session pool (0 means the client default).
+	MaxSessions    uint64                       // Maximum number of sessions in the session pool (0 means the client default).
+	KeepaliveTime  time.Duration                // Interval for gRPC keepalive pings on idle connections (0 disables them).
+	WriteMode      string                       // How rows are written: WriteMutations (the default if empty), WriteDML or WriteAuto.
+	// Writes is the number of concurrent writes during data conversion.
+	// 0 means it is adjusted to Spanner's push back (see
+	// spanner.DefaultAutotune).
+	Writes int64
+	// TableWrites lowers the number of concurrent writes, and the number
+	// of rows per write, for some Spanner tables, e.g. for hot parent
+	// tables (see ReadTableWritesFile).
+	TableWrites map[string]spanner.TableLimit
+	// Control pauses and resumes writes during data conversion, e.g. from
+	// an interactive terminal UI. nil means writes can't be paused.
+	Control *spanner.WriteControl
+	// LoadProcessingUnits is the compute capacity to scale the instance
+	// to during data conversion (0 means don't scale). See ScaleInstance.
+	LoadProcessingUnits int32
+	ConfirmScaling      bool // Confirms that scaling the instance is ok.
+	// KMSKey is the Cloud KMS key (projects/p/locations/l/keyRings/r/cryptoKeys/k)
+	// that encrypts the new database. Empty means Google-managed encryption.
+	KMSKey string
+	// AllowExisting allows adding the migrated tables to an existing
+	// database that already has tables (see PrepareDatabase).
+	AllowExisting  bool
+	BackupExisting bool // Back up existing databases before adding tables to them.
+	// BackupBeforeCutover backs up the database once data conversion is
+	// complete and row counts are verified (see VerifyRowCounts), as a
+	// rollback point for go-live.
+	BackupBeforeCutover bool
+	BackupRetention     time.Duration // How long backups are kept.
+	// Grants are the IAM roles granted on the database once its tables are
+	// created, e.g. to the service accounts of applications.
+	Grants []IAMGrant
+	// Analyze constructs a new query optimizer statistics package once
+	// data is loaded (see OptimizeDatabase).
+	Analyze bool
+	// OptimizerVersion is the default query optimizer version set once
+	// data is loaded (0 means Spanner's default is kept).
+	OptimizerVersion int
+}
+
+var kmsKeyRegexp = regexp.MustCompile(`^projects/[^/]+/locations/([^/]+)/keyRings/[^/]+/cryptoKeys/[^/]+$`)
+
+// routeToLeaderHeader is the request header that asks Spanner to route a
+// request to the leader region.
+const routeToLeaderHeader = "x-goog-spanner-route-to-leader"
+
+var priorityNames = map[string]sppb.RequestOptions_Priority{
+	"":       sppb.RequestOptions_PRIORITY_UNSPECIFIED,
+	"low":    sppb.RequestOptions_PRIORITY_LOW,
+	"medium": sppb.RequestOptions_PRIORITY_MEDIUM,
+	"high":   sppb.RequestOptions_PRIORITY_HIGH,
+}
+
+// ParsePriority maps a priority name (as used on the command line) to a
+// Spanner request priority. The empty string means Spanner's default.
+func ParsePriority(s string) (sppb.RequestOptions_Priority, error) {
+	if p, ok := priorityNames[strings.ToLower(s)]; ok {
+		return p, nil
+	}
+	return sppb.RequestOptions_PRIORITY_UNSPECIFIED, fmt.Errorf("unknown priority %q (accepted values are \"low\", \"medium\" and \"high\")", s)
+}
+
+// Validate checks that opts is consistent, and supported by target
+// database targetDb.
+func (opts SpannerOptions) Validate(targetDb string) error {
+	if opts.NumChannels < 0 {
+		return fmt.Errorf("number of channels can't be negative")
+	}
+	if opts.MaxSessions != 0 && opts.MinSessions > opts.MaxSessions {
+		return fmt.Errorf("minimum number of sessions (%d) is larger than maximum (%d)", opts.MinSessions, opts.MaxSessions)
+	}
+	if opts.Writes < 0 {
+		return fmt.Errorf("number of concurrent writes can't be negative")
+	}
+	switch opts.WriteMode {
+	case "", WriteMutations, WriteDML, WriteAuto:
+	default:
+		return fmt.Errorf("unknown write mode %q (accepted values are %q, %q and %q)", opts.WriteMode, WriteMutations, WriteDML, WriteAuto)
+	}
+	// DML statements are generated in GoogleSQL.
+	if (opts.WriteMode == WriteDML || opts.WriteMode == WriteAuto) && targetDb == TARGET_EXPERIMENTAL_POSTGRES {
+		return fmt.Errorf("write mode %q isn't supported with target-db %s", opts.WriteMode, targetDb)
+	}
+	for t, l := range opts.TableWrites {
+		if l.Writes < 0 || l.BatchRows < 0 {
+			return fmt.Errorf("limits on the writes of table %s can't be negative", t)
+		}
+	}
+	if opts.KeepaliveTime < 0 {
+		return fmt.Errorf("keepalive time can't be negative")
+	}
+	// Spanner accepts multiples of 100 processing units up to 1000, and
+	// multiples of 1000 after that.
+	if n := opts.LoadProcessingUnits; n < 0 || (n < 1000 && n%100 != 0) || (n >= 1000 && n%1000 != 0) {
+		return fmt.Errorf("invalid number of processing units %d: must be a multiple of 100 up to 1000, and a multiple of 1000 after that", n)
+	}
+	if opts.BackupExisting && !opts.AllowExisting {
+		return fmt.Errorf("backing up existing databases requires allowing existing databases")
+	}
+	// Spanner keeps backups for at least 6 hours, and at most a year.
+	if r := opts.BackupRetention; (opts.BackupExisting || opts.BackupBeforeCutover) && (r < 6*time.Hour || r > 366*24*time.Hour) {
+		return fmt.Errorf("invalid backup retention %s: must be between 6h and 366 days", r)
+	}
+	if opts.OptimizerVersion < 0 {
+		return fmt.Errorf("optimizer version can't be negative")
+	}
+	if opts.KMSKey != "" && !kmsKeyRegexp.MatchString(opts.KMSKey) {
+		return fmt.Errorf("invalid KMS key %q: must be of the form projects/<project>/locations/<location>/keyRings/<key ring>/cryptoKeys/<key>", opts.KMSKey)
+	}
+	return nil
+}
+
+// clientConfig returns the Spanner client configuration for opts.
+func (opts SpannerOptions) clientConfig() sp.ClientConfig {
+	config := sp.ClientConfig{NumChannels: opts.NumChannels, SessionPoolConfig: sp.DefaultSessionPoolConfig}
+	if opts.MinSessions != 0 {
+		config.SessionPoolConfig.MinOpened = opts.MinSessions
+	}
+	if opts.MaxSessions != 0 {
+		config.SessionPoolConfig.MaxOpened = opts.MaxSessions
+	}
+	return config
+}
+
+// clientOptions returns the options for creating a Spanner client that
+// applies opts. The Spanner client doesn't expose request options for
+// Apply, so we set them using an interceptor.
+func (opts SpannerOptions) clientOptions() []option.ClientOption {
+	var l []option.ClientOption
+	if opts.Priority != sppb.RequestOptions_PRIORITY_UNSPECIFIED || opts.TransactionTag != "" || opts.RequestTag != "" || opts.RouteToLeader {
+		l = append(l, option.WithGRPCDialOption(grpc.WithChainUnaryInterceptor(opts.interceptor)))
+	}
+	if opts.KeepaliveTime > 0 {
+		l = append(l, option.WithGRPCDialOption(grpc.WithKeepaliveParams(keepalive.ClientParameters{Time: opts.KeepaliveTime, PermitWithoutStream: true})))
+	}
+	return l
+}
+
+// interceptor adds request options to the requests used by write
+// transactions. Transaction tags are set on both BeginTransaction and
+// Commit (Spanner uses the tag from the first request of the
+// transaction). Priority and request tags aren't supported by
+// BeginTransaction, so they are set on Commit, and on the DML requests
+// of -write-mode dml (ExecuteSql and ExecuteBatchDml). Options already
+// set by the Spanner client are kept.
+func (opts SpannerOptions) interceptor(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, callOpts ...grpc.CallOption) error {
+	switch r := req.(type) {
+	case *sppb.BeginTransactionRequest:
+		if opts.TransactionTag != "" {
+			r.RequestOptions = &sppb.RequestOptions{TransactionTag: opts.TransactionTag}
+		}
+	case *sppb.CommitRequest:
+		r.RequestOptions = opts.requestOptions(r.RequestOptions)
+		if r.RequestOptions.TransactionTag == "" {
+			r.RequestOptions.TransactionTag = opts.TransactionTag
+		}
+	case *sppb.ExecuteSqlRequest:
+		r.RequestOptions = opts.requestOptions(r.RequestOptions)
+	case *sppb.ExecuteBatchDmlRequest:
+		r.RequestOptions = opts.requestOptions(r.RequestOptions)
+	}
+	if opts.RouteToLeader {
+		ctx = metadata.AppendToOutgoingContext(ctx, routeToLeaderHeader, "true")
+	}
+	return invoker(ctx, method, req, reply, cc, callOpts...)
+}
+
+// requestOptions returns ro (a new RequestOptions if nil) with the
+// priority and request tag of opts, unless ro already sets them.
+func (opts SpannerOptions) requestOptions(ro *sppb.RequestOptions) *sppb.RequestOptions {
+	if ro == nil {
+		ro = &sppb.RequestOptions{}
+	}
+	if ro.Priority == sppb.RequestOptions_PRIORITY_UNSPECIFIED {
+		ro.Priority = opts.Priority
+	}
+	if ro.RequestTag == "" {
+		ro.RequestTag = opts.RequestTag
+	}
+	return ro
+}
@@ -0,0 +1,61 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package conversion
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	sppb "google.golang.org/genproto/googleapis/spanner/v1"
+	"google.golang.org/grpc"
+)
+
+func TestValidateWriteMode(t *testing.T) {
+	for _, mode := range []string{"", WriteMutations, WriteDML, WriteAuto} {
+		assert.Nil(t, SpannerOptions{WriteMode: mode}.Validate(TARGET_SPANNER), mode)
+	}
+	assert.EqualError(t, SpannerOptions{WriteMode: "bulk"}.Validate(TARGET_SPANNER), `unknown write mode "bulk" (accepted values are "mutation", "dml" and "auto")`)
+
+	// DML is only generated in GoogleSQL.
+	assert.Nil(t, SpannerOptions{WriteMode: WriteMutations}.Validate(TARGET_EXPERIMENTAL_POSTGRES))
+	assert.EqualError(t, SpannerOptions{WriteMode: WriteDML}.Validate(TARGET_EXPERIMENTAL_POSTGRES), `write mode "dml" isn't supported with target-db experimental_postgres`)
+	assert.NotNil(t, SpannerOptions{WriteMode: WriteAuto}.Validate(TARGET_EXPERIMENTAL_POSTGRES))
+}
+
+func TestInterceptor(t *testing.T) {
+	opts := SpannerOptions{Priority: sppb.RequestOptions_PRIORITY_LOW, TransactionTag: "migration", RequestTag: "load"}
+	invoke := func(req interface{}) {
+		invoker := func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, callOpts ...grpc.CallOption) error {
+			return nil
+		}
+		assert.Nil(t, opts.interceptor(context.Background(), "method", req, nil, nil, invoker))
+	}
+
+	begin := &sppb.BeginTransactionRequest{}
+	invoke(begin)
+	assert.Equal(t, &sppb.RequestOptions{TransactionTag: "migration"}, begin.RequestOptions)
+	commit := &sppb.CommitRequest{}
+	invoke(commit)
+	assert.Equal(t, &sppb.RequestOptions{Priority: sppb.RequestOptions_PRIORITY_LOW, TransactionTag: "migration", RequestTag: "load"}, commit.RequestOptions)
+	batch := &sppb.ExecuteBatchDmlRequest{}
+	invoke(batch)
+	assert.Equal(t, &sppb.RequestOptions{Priority: sppb.RequestOptions_PRIORITY_LOW, RequestTag: "load"}, batch.RequestOptions)
+
+	// Options set by the Spanner client are kept.
+	sql := &sppb.ExecuteSqlRequest{RequestOptions: &sppb.RequestOptions{Priority: sppb.RequestOptions_PRIORITY_HIGH}}
+	invoke(sql)
+	assert.Equal(t, &sppb.RequestOptions{Priority: sppb.RequestOptions_PRIORITY_HIGH, RequestTag: "load"}, sql.RequestOptions)
+}
@@ -0,0 +1,449 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package conversion
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/gob"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math/big"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"cloud.google.com/go/civil"
+	sp "cloud.google.com/go/spanner"
+	"google.golang.org/api/googleapi"
+	storage "google.golang.org/api/storage/v1"
+
+	"github.com/cloudspannerecosystem/harbourbridge/internal"
+	"github.com/cloudspannerecosystem/harbourbridge/spanner"
+)
+
+// Steps of staged data conversions (see Staging.Step).
+const (
+	StageAll     = "all"     // Stage the data of the source, then load it.
+	StageExtract = "extract" // Only stage the data of the source.
+	StageLoad    = "load"    // Only load data staged by a previous run.
+)
+
+// Staging configures the staging of the data of live source databases
+// in a Cloud Storage bucket before it is loaded, e.g. for sources in
+// another cloud: converted rows are streamed to the bucket (in the region
+// of the Spanner instance) with resumable uploads, so that the transfer
+// out of the other cloud is retried chunk by chunk rather than batch by
+// batch, and the load then reads from within Google Cloud. The zero value
+// writes data directly to Spanner.
+type Staging struct {
+	// URI is where data is staged: gs://bucket/prefix.
+	URI string
+	// Step is StageAll, StageExtract or StageLoad. Empty means StageAll.
+	// The extract step converts the schema and stages the data; the load
+	// step is a data-only run that loads the staged data.
+	Step string
+	// Project and Region are where the bucket is created if it doesn't
+	// exist. If Region isn't empty, a warning is printed if the bucket is
+	// elsewhere.
+	Project, Region string
+}
+
+// Validate checks that s can be used for driver.
+func (s Staging) Validate(driver string) error {
+	if s.URI == "" {
+		if s.Step != "" {
+			return fmt.Errorf("staging step %s needs a staging bucket", s.Step)
+		}
+		return nil
+	}
+	if driver != POSTGRES && driver != MYSQL && driver != DYNAMODB {
+		return fmt.Errorf("staging data is only supported for drivers %s, %s and %s (driver: %s)", POSTGRES, MYSQL, DYNAMODB, driver)
+	}
+	if b, _ := splitGCSURI(s.URI); b == "" {
+		return fmt.Errorf("invalid staging bucket %s: expected gs://bucket/prefix", s.URI)
+	}
+	switch s.Step {
+	case "", StageAll, StageExtract, StageLoad:
+	default:
+		return fmt.Errorf("invalid staging step %q (accepted values are %q, %q and %q)", s.Step, StageAll, StageExtract, StageLoad)
+	}
+	return nil
+}
+
+// splitGCSURI returns the bucket and the object prefix of uri
+// (gs://bucket/prefix); the prefix is empty or ends with /.
+func splitGCSURI(uri string) (string, string) {
+	if !strings.HasPrefix(uri, "gs://") {
+		return "", ""
+	}
+	l := strings.SplitN(strings.TrimPrefix(uri, "gs://"), "/", 2)
+	if len(l) == 2 && strings.Trim(l[1], "/") != "" {
+		return l[0], strings.Trim(l[1], "/") + "/"
+	}
+	return l[0], ""
+}
+
+// Region returns the region of the instance: its only region, or the
+// region of its default leader for multi-region instances.
+func (cfg *InstanceConfig) Region() string {
+	switch {
+	case cfg.LeaderLocation != "":
+		return cfg.LeaderLocation
+	case strings.HasPrefix(cfg.Name, "regional-"):
+		return strings.TrimPrefix(cfg.Name, "regional-")
+	case len(cfg.Locations) > 0:
+		return cfg.Locations[0]
+	}
+	return ""
+}
+
+const (
+	stagingManifest    = "manifest.json"
+	stagingObjectBytes = 256 * 1000 * 1000 // Encoded bytes per staged object, before compression.
+	stagingChunkBytes  = 16 * 1024 * 1024  // Bytes per request of resumable uploads.
+)
+
+// Operations of staged rows, as the sinks of Conv.
+const (
+	stagedInsert = iota
+	stagedReplace
+	stagedUpdate
+	stagedChild
+	stagedFlush // BatchWriter.Flush: rows staged before it are written first.
+)
+
+// stagedRow is a converted row, or a flush, in staged objects.
+type stagedRow struct {
+	Op    int
+	Table string
+	Cols  []string
+	Vals  []interface{}
+}
+
+func init() {
+	// Types of converted values that aren't registered by gob.
+	for _, v := range []interface{}{
+		civil.Date{}, time.Time{}, &big.Rat{}, [][]byte{}, []civil.Date{}, []time.Time{}, []big.Rat{},
+		sp.NullString{}, sp.NullInt64{}, sp.NullFloat64{}, sp.NullBool{}, sp.NullDate{}, sp.NullTime{},
+		[]sp.NullString{}, []sp.NullInt64{}, []sp.NullFloat64{}, []sp.NullBool{}, []sp.NullDate{}, []sp.NullTime{},
+	} {
+		gob.Register(v)
+	}
+}
+
+// stagingIndex is written when all data is staged: it lists the staged
+// objects, and the stats of data conversion, for the load step.
+type stagingIndex struct {
+	Time    time.Time       `json:"time"`
+	Objects []string        `json:"objects"` // Relative to the prefix.
+	Rows    int64           `json:"rows"`
+	Bytes   int64           `json:"bytes"`
+	Stats   json.RawMessage `json:"stats"`
+}
+
+// stagingStore is the bucket data is staged in.
+type stagingStore struct {
+	svc    *storage.Service
+	uri    string
+	bucket string
+	prefix string
+}
+
+// openStaging opens the bucket of s, creating it in s.Region if it
+// doesn't exist.
+func openStaging(s Staging, out io.Writer) (*stagingStore, error) {
+	bucket, prefix := splitGCSURI(s.URI)
+	svc, err := storage.NewService(context.Background())
+	if err != nil {
+		return nil, fmt.Errorf("can't create Cloud Storage client: %w", err)
+	}
+	st := &stagingStore{svc: svc, uri: s.URI, bucket: bucket, prefix: prefix}
+	b, err := svc.Buckets.Get(bucket).Do()
+	if e, ok := err.(*googleapi.Error); ok && e.Code == http.StatusNotFound {
+		if s.Project == "" || s.Region == "" {
+			return nil, fmt.Errorf("staging bucket %s doesn't exist, and can't be created: unknown region", bucket)
+		}
+		if _, err := svc.Buckets.Insert(s.Project, &storage.Bucket{Name: bucket, Location: s.Region}).Do(); err != nil {
+			return nil, fmt.Errorf("can't create staging bucket %s: %w", bucket, err)
+		}
+		fmt.Fprintf(out, "Created staging bucket %s in %s\n", bucket, s.Region)
+		return st, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("can't get staging bucket %s: %w", bucket, err)
+	}
+	if s.Region != "" && !strings.EqualFold(b.Location, s.Region) {
+		fmt.Fprintf(out, "Warning: staging bucket %s is in %s, not in %s (the region of the Spanner instance): loading data will be slower\n", bucket, strings.ToLower(b.Location), s.Region)
+	}
+	return st, nil
+}
+
+func (st *stagingStore) readIndex() (*stagingIndex, error) {
+	resp, err := st.svc.Objects.Get(st.bucket, st.prefix+stagingManifest).Download()
+	if e, ok := err.(*googleapi.Error); ok && e.Code == http.StatusNotFound {
+		return nil, fmt.Errorf("no data staged in %s: run the %s step first", st.uri, StageExtract)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("can't read gs://%s/%s%s: %w", st.bucket, st.prefix, stagingManifest, err)
+	}
+	defer resp.Body.Close()
+	var index stagingIndex
+	if err := json.NewDecoder(resp.Body).Decode(&index); err != nil {
+		return nil, fmt.Errorf("can't read gs://%s/%s%s: %w", st.bucket, st.prefix, stagingManifest, err)
+	}
+	return &index, nil
+}
+
+func (st *stagingStore) writeIndex(index *stagingIndex) error {
+	data, err := json.MarshalIndent(index, "", "  ")
+	if err != nil {
+		return err
+	}
+	obj := &storage.Object{Name: st.prefix + stagingManifest, ContentType: "application/json"}
+	if _, err := st.svc.Objects.Insert(st.bucket, obj).Media(bytes.NewReader(data)).Do(); err != nil {
+		return fmt.Errorf("can't write gs://%s/%s%s: %w", st.bucket, st.prefix, stagingManifest, err)
+	}
+	return nil
+}
+
+// stagingWriter stages rows in a stagingStore, as gzipped gob streams
+// of stagedRow. Rows are written to a sequence of objects, in order.
+// It is a rowWriter: its errors are returned by Close.
+type stagingWriter struct {
+	st *stagingStore
+	p  *internal.Progress // Reports staged rows.
+
+	mu    sync.Mutex
+	index stagingIndex
+	err   error // First error: rows aren't staged after it.
+	// Upload of the current object (enc is nil if there is none).
+	pw   *io.PipeWriter
+	zw   *gzip.Writer
+	cw   *countingWriter // Counts encoded bytes.
+	enc  *gob.Encoder
+	done chan error
+}
+
+type countingWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (c *countingWriter) Write(b []byte) (int, error) {
+	n, err := c.w.Write(b)
+	c.n += int64(n)
+	return n, err
+}
+
+func (w *stagingWriter) AddRow(table string, cols []string, vals []interface{}) {
+	w.stage(stagedRow{Op: stagedInsert, Table: table, Cols: cols, Vals: stageVals(vals)})
+}
+
+func (w *stagingWriter) ReplaceRow(table string, cols []string, vals []interface{}) {
+	w.stage(stagedRow{Op: stagedReplace, Table: table, Cols: cols, Vals: stageVals(vals)})
+}
+
+func (w *stagingWriter) UpdateRow(table string, cols []string, vals []interface{}) {
+	w.stage(stagedRow{Op: stagedUpdate, Table: table, Cols: cols, Vals: stageVals(vals)})
+}
+
+func (w *stagingWriter) AddChildRow(table string, cols []string, vals []interface{}) {
+	w.stage(stagedRow{Op: stagedChild, Table: table, Cols: cols, Vals: stageVals(vals)})
+}
+
+// stageVals returns vals with big.Rat values replaced by pointers: gob
+// can't encode them in interfaces, since their GobEncode method has a
+// pointer receiver. vals isn't modified.
+func stageVals(vals []interface{}) []interface{} {
+	staged := vals
+	for i, v := range vals {
+		if r, ok := v.(big.Rat); ok {
+			if &staged[0] == &vals[0] {
+				staged = append([]interface{}{}, vals...)
+			}
+			staged[i] = &r
+		}
+	}
+	return staged
+}
+
+// unstageVals reverses stageVals, in place.
+func unstageVals(vals []interface{}) []interface{} {
+	for i, v := range vals {
+		if r, ok := v.(*big.Rat); ok {
+			vals[i] = *r
+		}
+	}
+	return vals
+}
+
+func (w *stagingWriter) Flush() {
+	w.stage(stagedRow{Op: stagedFlush})
+}
+
+func (w *stagingWriter) stage(r stagedRow) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.err != nil {
+		return
+	}
+	if w.enc == nil {
+		w.open()
+	}
+	if w.err = w.enc.Encode(r); w.err != nil {
+		w.err = fmt.Errorf("can't stage row of table %s: %w", r.Table, w.err)
+		w.pw.CloseWithError(w.err)
+		return
+	}
+	if r.Op != stagedFlush {
+		w.index.Rows++
+		w.p.MaybeReport(w.index.Rows)
+	}
+	if w.cw.n >= stagingObjectBytes {
+		w.err = w.closeObject()
+	}
+}
+
+// open starts the upload of the next object.
+func (w *stagingWriter) open() {
+	name := fmt.Sprintf("rows/%06d.gob.gz", len(w.index.Objects))
+	w.index.Objects = append(w.index.Objects, name)
+	pr, pw := io.Pipe()
+	w.done = make(chan error, 1)
+	go func() {
+		obj := &storage.Object{Name: w.st.prefix + name, ContentType: "application/octet-stream"}
+		_, err := w.st.svc.Objects.Insert(w.st.bucket, obj).Media(pr, googleapi.ChunkSize(stagingChunkBytes)).Do()
+		if err != nil {
+			err = fmt.Errorf("can't write gs://%s/%s%s: %w", w.st.bucket, w.st.prefix, name, err)
+		}
+		// Unblock the writer if the upload failed.
+		pr.CloseWithError(err)
+		w.done <- err
+	}()
+	w.pw = pw
+	w.zw = gzip.NewWriter(pw)
+	w.cw = &countingWriter{w: w.zw}
+	w.enc = gob.NewEncoder(w.cw)
+}
+
+// closeObject completes the upload of the current object.
+func (w *stagingWriter) closeObject() error {
+	w.index.Bytes += w.cw.n
+	err := w.zw.Close()
+	if err != nil {
+		w.pw.CloseWithError(err)
+	} else {
+		w.pw.Close()
+	}
+	if uerr := <-w.done; err == nil {
+		err = uerr
+	}
+	w.enc = nil
+	return err
+}
+
+// Close completes the upload of staged rows, and writes the index of
+// staged objects with the stats of conv.
+func (w *stagingWriter) Close(conv *internal.Conv) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.err == nil && w.enc != nil {
+		w.err = w.closeObject()
+	}
+	if w.err != nil {
+		return w.err
+	}
+	w.p.Done()
+	stats, err := json.Marshal(conv.Stats)
+	if err != nil {
+		return err
+	}
+	w.index.Stats = stats
+	w.index.Time = time.Now()
+	return w.st.writeIndex(&w.index)
+}
+
+// loadStaged writes the rows staged in st to Spanner with client, in the
+// order they were staged, and restores in conv the stats of data
+// conversion recorded when they were staged.
+func loadStaged(st *stagingStore, config spanner.BatchWriterConfig, client *sp.Client, conv *internal.Conv, out io.Writer) (*spanner.BatchWriter, error) {
+	index, err := st.readIndex()
+	if err != nil {
+		return nil, err
+	}
+	fmt.Fprintf(out, "Loading %d rows staged in %s on %s\n", index.Rows, st.uri, index.Time.Format("2006-01-02 15:04:05"))
+	p := conv.NewProgress(index.Rows, "Writing data to Spanner")
+	config.Write, config.WriteDML = writeFuncs(client, p)
+	writer := spanner.NewBatchWriter(config)
+	var rows int64
+	for _, name := range index.Objects {
+		n, err := st.loadObject(name, writer)
+		rows += n
+		if err != nil {
+			return nil, err
+		}
+	}
+	writer.Flush()
+	p.Done()
+	if rows != index.Rows {
+		return nil, fmt.Errorf("%d rows staged in %s, but %d were read", index.Rows, st.uri, rows)
+	}
+	if err := json.Unmarshal(index.Stats, &conv.Stats); err != nil {
+		return nil, fmt.Errorf("can't read stats of staged data: %w", err)
+	}
+	return writer, nil
+}
+
+func (st *stagingStore) loadObject(name string, writer *spanner.BatchWriter) (int64, error) {
+	resp, err := st.svc.Objects.Get(st.bucket, st.prefix+name).Download()
+	if err != nil {
+		return 0, fmt.Errorf("can't read gs://%s/%s%s: %w", st.bucket, st.prefix, name, err)
+	}
+	defer resp.Body.Close()
+	zr, err := gzip.NewReader(resp.Body)
+	if err != nil {
+		return 0, fmt.Errorf("can't read gs://%s/%s%s: %w", st.bucket, st.prefix, name, err)
+	}
+	dec := gob.NewDecoder(zr)
+	var rows int64
+	for {
+		var r stagedRow
+		err := dec.Decode(&r)
+		if err == io.EOF {
+			return rows, nil
+		}
+		if err != nil {
+			return rows, fmt.Errorf("can't read gs://%s/%s%s: %w", st.bucket, st.prefix, name, err)
+		}
+		switch r.Op {
+		case stagedInsert:
+			writer.AddRow(r.Table, r.Cols, unstageVals(r.Vals))
+		case stagedReplace:
+			writer.ReplaceRow(r.Table, r.Cols, unstageVals(r.Vals))
+		case stagedUpdate:
+			writer.UpdateRow(r.Table, r.Cols, unstageVals(r.Vals))
+		case stagedChild:
+			writer.AddChildRow(r.Table, r.Cols, unstageVals(r.Vals))
+		case stagedFlush:
+			writer.Flush()
+			continue
+		}
+		rows++
+	}
+}
@@ -0,0 +1,88 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package conversion
+
+import (
+	"bytes"
+	"encoding/gob"
+	"math/big"
+	"testing"
+	"time"
+
+	"cloud.google.com/go/civil"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStagingValidate(t *testing.T) {
+	for _, tc := range []struct {
+		name    string
+		staging Staging
+		driver  string
+		ok      bool
+	}{
+		{"not staged", Staging{}, PGDUMP, true},
+		{"step without bucket", Staging{Step: StageLoad}, POSTGRES, false},
+		{"all", Staging{URI: "gs://b/p"}, POSTGRES, true},
+		{"extract", Staging{URI: "gs://b", Step: StageExtract}, MYSQL, true},
+		{"load", Staging{URI: "gs://b/p/", Step: StageLoad}, DYNAMODB, true},
+		{"dump", Staging{URI: "gs://b/p"}, PGDUMP, false},
+		{"not gcs", Staging{URI: "s3://b/p"}, POSTGRES, false},
+		{"no bucket", Staging{URI: "gs:///p"}, POSTGRES, false},
+		{"unknown step", Staging{URI: "gs://b/p", Step: "copy"}, POSTGRES, false},
+	} {
+		err := tc.staging.Validate(tc.driver)
+		assert.Equal(t, tc.ok, err == nil, tc.name)
+	}
+}
+
+func TestSplitGCSURI(t *testing.T) {
+	for _, tc := range []struct {
+		uri, bucket, prefix string
+	}{
+		{"gs://b", "b", ""},
+		{"gs://b/", "b", ""},
+		{"gs://b/p", "b", "p/"},
+		{"gs://b/p/q/", "b", "p/q/"},
+		{"b/p", "", ""},
+	} {
+		bucket, prefix := splitGCSURI(tc.uri)
+		assert.Equal(t, tc.bucket, bucket, tc.uri)
+		assert.Equal(t, tc.prefix, prefix, tc.uri)
+	}
+}
+
+func TestInstanceConfigRegion(t *testing.T) {
+	assert.Equal(t, "us-central1", (&InstanceConfig{Name: "regional-us-central1", Locations: []string{"us-central1"}}).Region())
+	assert.Equal(t, "us-east4", (&InstanceConfig{Name: "nam4", Locations: []string{"us-central1", "us-east4"}, LeaderLocation: "us-east4"}).Region())
+	assert.Equal(t, "europe-west1", (&InstanceConfig{Name: "custom-eu", Locations: []string{"europe-west1"}}).Region())
+	assert.Equal(t, "", (&InstanceConfig{Name: "custom"}).Region())
+}
+
+func TestStageVals(t *testing.T) {
+	vals := []interface{}{int64(1), *big.NewRat(3, 2), civil.Date{Year: 2021, Month: time.March, Day: 4}, nil}
+	staged := stageVals(vals)
+	// vals isn't modified.
+	assert.IsType(t, big.Rat{}, vals[1])
+	var buf bytes.Buffer
+	assert.Nil(t, gob.NewEncoder(&buf).Encode(stagedRow{Op: stagedInsert, Table: "t", Cols: []string{"a", "b", "c", "d"}, Vals: staged}))
+	var r stagedRow
+	assert.Nil(t, gob.NewDecoder(&buf).Decode(&r))
+	assert.Equal(t, "t", r.Table)
+	assert.Equal(t, vals, unstageVals(r.Vals))
+
+	// Rows without big.Rat values are staged as they are.
+	plain := []interface{}{"x", int64(2)}
+	assert.Equal(t, plain, stageVals(plain))
+}
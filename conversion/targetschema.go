@@ -0,0 +1,142 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package conversion
+
+import (
+	"context"
+	"fmt"
+
+	sp "cloud.google.com/go/spanner"
+
+	"github.com/cloudspannerecosystem/harbourbridge/spanner/ddl"
+)
+
+// ReadTargetSchema reads the schema of the tables of a Spanner database
+// (except MetadataTable) from its INFORMATION_SCHEMA: their columns,
+// primary keys, parent tables, indexes and foreign keys. Indexes managed
+// by Spanner (e.g. the backing indexes of foreign keys) are left out.
+// Only databases using the GoogleSQL dialect are supported.
+func ReadTargetSchema(client *sp.Client) (ddl.Schema, error) {
+	s := ddl.NewSchema()
+	err := querySchema(client, `SELECT TABLE_NAME, PARENT_TABLE_NAME FROM INFORMATION_SCHEMA.TABLES
+WHERE TABLE_SCHEMA = '' AND TABLE_TYPE = 'BASE TABLE'`, func(r *sp.Row) error {
+		var table string
+		var parent sp.NullString
+		if err := r.Columns(&table, &parent); err != nil {
+			return err
+		}
+		if table != MetadataTable {
+			s[table] = ddl.CreateTable{Name: table, Parent: parent.StringVal, ColDefs: make(map[string]ddl.ColumnDef)}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("can't read tables: %w", err)
+	}
+	err = querySchema(client, `SELECT TABLE_NAME, COLUMN_NAME, SPANNER_TYPE, IS_NULLABLE FROM INFORMATION_SCHEMA.COLUMNS
+WHERE TABLE_SCHEMA = '' ORDER BY TABLE_NAME, ORDINAL_POSITION`, func(r *sp.Row) error {
+		var table, col, spannerType, nullable string
+		if err := r.Columns(&table, &col, &spannerType, &nullable); err != nil {
+			return err
+		}
+		ct, ok := s[table]
+		if !ok {
+			return nil
+		}
+		ty, err := ddl.ParseColumnDefType(spannerType)
+		if err != nil {
+			return fmt.Errorf("column %s of table %s: %w", col, table, err)
+		}
+		ct.ColNames = append(ct.ColNames, col)
+		ct.ColDefs[col] = ddl.ColumnDef{Name: col, T: ty, NotNull: nullable == "NO"}
+		s[table] = ct
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("can't read columns: %w", err)
+	}
+	indexes := make(map[string]int) // Position of indexes in the Indexes of their table, by table and name.
+	err = querySchema(client, `SELECT i.TABLE_NAME, i.INDEX_NAME, i.INDEX_TYPE, i.IS_UNIQUE, c.COLUMN_NAME, c.COLUMN_ORDERING
+FROM INFORMATION_SCHEMA.INDEXES AS i JOIN INFORMATION_SCHEMA.INDEX_COLUMNS AS c
+ON c.TABLE_SCHEMA = i.TABLE_SCHEMA AND c.TABLE_NAME = i.TABLE_NAME AND c.INDEX_NAME = i.INDEX_NAME
+WHERE i.TABLE_SCHEMA = '' AND NOT i.SPANNER_IS_MANAGED AND c.ORDINAL_POSITION IS NOT NULL
+ORDER BY i.TABLE_NAME, i.INDEX_NAME, c.ORDINAL_POSITION`, func(r *sp.Row) error {
+		var table, index, indexType, col string
+		var unique bool
+		var ordering sp.NullString
+		if err := r.Columns(&table, &index, &indexType, &unique, &col, &ordering); err != nil {
+			return err
+		}
+		ct, ok := s[table]
+		if !ok {
+			return nil
+		}
+		key := ddl.IndexKey{Col: col, Desc: ordering.StringVal == "DESC"}
+		if indexType == "PRIMARY_KEY" {
+			ct.Pks = append(ct.Pks, key)
+		} else {
+			i, ok := indexes[table+"."+index]
+			if !ok {
+				i = len(ct.Indexes)
+				indexes[table+"."+index] = i
+				ct.Indexes = append(ct.Indexes, ddl.CreateIndex{Name: index, Table: table, Unique: unique})
+			}
+			ct.Indexes[i].Keys = append(ct.Indexes[i].Keys, key)
+		}
+		s[table] = ct
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("can't read indexes: %w", err)
+	}
+	fks := make(map[string]int) // Position of foreign keys in the Fks of their table, by table and name.
+	err = querySchema(client, `SELECT k.TABLE_NAME, k.CONSTRAINT_NAME, k.COLUMN_NAME, u.TABLE_NAME, u.COLUMN_NAME
+FROM INFORMATION_SCHEMA.REFERENTIAL_CONSTRAINTS AS r
+JOIN INFORMATION_SCHEMA.KEY_COLUMN_USAGE AS k
+ON k.CONSTRAINT_SCHEMA = r.CONSTRAINT_SCHEMA AND k.CONSTRAINT_NAME = r.CONSTRAINT_NAME
+JOIN INFORMATION_SCHEMA.KEY_COLUMN_USAGE AS u
+ON u.CONSTRAINT_SCHEMA = r.UNIQUE_CONSTRAINT_SCHEMA AND u.CONSTRAINT_NAME = r.UNIQUE_CONSTRAINT_NAME AND u.ORDINAL_POSITION = k.POSITION_IN_UNIQUE_CONSTRAINT
+WHERE r.CONSTRAINT_SCHEMA = ''
+ORDER BY k.TABLE_NAME, k.CONSTRAINT_NAME, k.ORDINAL_POSITION`, func(r *sp.Row) error {
+		var table, fk, col, referTable, referCol string
+		if err := r.Columns(&table, &fk, &col, &referTable, &referCol); err != nil {
+			return err
+		}
+		ct, ok := s[table]
+		if !ok {
+			return nil
+		}
+		i, ok := fks[table+"."+fk]
+		if !ok {
+			i = len(ct.Fks)
+			fks[table+"."+fk] = i
+			ct.Fks = append(ct.Fks, ddl.Foreignkey{Name: fk, ReferTable: referTable})
+		}
+		ct.Fks[i].Columns = append(ct.Fks[i].Columns, col)
+		ct.Fks[i].ReferColumns = append(ct.Fks[i].ReferColumns, referCol)
+		s[table] = ct
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("can't read foreign keys: %w", err)
+	}
+	return s, nil
+}
+
+// querySchema runs query q, which reads the INFORMATION_SCHEMA, and calls
+// f for each row.
+func querySchema(client *sp.Client, q string, f func(*sp.Row) error) error {
+	return client.Single().Query(context.Background(), sp.NewStatement(q)).Do(f)
+}
@@ -0,0 +1,411 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package conversion
+
+import (
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"cloud.google.com/go/civil"
+	sp "cloud.google.com/go/spanner"
+
+	"github.com/cloudspannerecosystem/harbourbridge/internal"
+	"github.com/cloudspannerecosystem/harbourbridge/mysql"
+	"github.com/cloudspannerecosystem/harbourbridge/postgres"
+	"github.com/cloudspannerecosystem/harbourbridge/spanner/ddl"
+)
+
+// TableValidation is the validation of the recent rows of a table.
+type TableValidation struct {
+	Table      string `json:"table"`
+	Checked    int    `json:"checked"`
+	Missing    int    `json:"missing"`    // Rows not found in Spanner.
+	Mismatched int    `json:"mismatched"` // Rows whose checksum differs in Spanner.
+	Error      string `json:"error,omitempty"`
+}
+
+// ValidationRound is the result of a round of validation.
+type ValidationRound struct {
+	Time   time.Time         `json:"time"`
+	Tables []TableValidation `json:"tables"`
+	// Consistent is true if all checked rows match.
+	Consistent bool `json:"consistent"`
+	// LagSeconds is how long the oldest row that is still missing or
+	// mismatched has been, i.e. how far behind the source Spanner is.
+	LagSeconds float64 `json:"lag_seconds"`
+	// SchemaDrift lists the changes of the source schema since the
+	// session was created (see internal.Conv.SchemaDrift).
+	SchemaDrift []string `json:"schema_drift,omitempty"`
+	// Propagated lists the changes of the source schema propagated to
+	// Spanner in this round (see Validator.PropagateSchemaChanges).
+	Propagated []string `json:"propagated,omitempty"`
+}
+
+// Validator compares the checksums of recent rows of the source database
+// and of Spanner, while changes are replicated to Spanner (e.g. by a CDC
+// pipeline). Recent rows are the rows with the largest primary keys:
+// tables without primary keys aren't validated. Source rows are converted
+// as by data conversion, then the Spanner columns converted from source
+// columns are compared, if the Spanner client can decode their type (not
+// JSON and FLOAT32). Values modified by data conversion policies (see
+// internal.Policies) are reported as mismatches.
+type Validator struct {
+	driver string
+	conv   *internal.Conv
+	db     *sql.DB
+	client *sp.Client
+	rows   int
+	// since records when each row found inconsistent (by table and key)
+	// was first found inconsistent.
+	since map[string]time.Time
+	// propagateTo is the Spanner database that changes of the source
+	// schema are propagated to, empty if they are only reported, and
+	// sessionFile the session file updated with them.
+	propagateTo string
+	sessionFile string
+}
+
+// NewValidator returns a Validator that checks the given number of recent
+// rows of each table of conv, read from the source database of driver
+// (configured as for schema conversion).
+func NewValidator(driver string, conv *internal.Conv, client *sp.Client, rows int) (*Validator, error) {
+	if driver != POSTGRES && driver != MYSQL {
+		return nil, fmt.Errorf("validation for driver %s not supported", driver)
+	}
+	config, err := driverConfig(driver, "")
+	if err != nil {
+		return nil, err
+	}
+	db, err := sql.Open(driver, config)
+	if err != nil {
+		return nil, err
+	}
+	return &Validator{driver: driver, conv: conv, db: db, client: client, rows: rows, since: make(map[string]time.Time)}, nil
+}
+
+// PropagateSchemaChanges makes each round of validation propagate the
+// changes of the source schema that can be (see
+// internal.Conv.SchemaChanges) to Spanner database db, and write the
+// updated session to file sessionFile. Other changes are reported.
+func (v *Validator) PropagateSchemaChanges(db, sessionFile string) {
+	v.propagateTo = db
+	v.sessionFile = sessionFile
+}
+
+// Close closes the connection to the source database.
+func (v *Validator) Close() {
+	v.db.Close()
+}
+
+// Run validates every interval until stop is closed, and writes each
+// round to out as a line of JSON.
+func (v *Validator) Run(interval time.Duration, stop <-chan struct{}, out io.Writer) error {
+	enc := json.NewEncoder(out)
+	t := time.NewTicker(interval)
+	defer t.Stop()
+	for {
+		if err := enc.Encode(v.Round(time.Now())); err != nil {
+			return err
+		}
+		select {
+		case <-stop:
+			return nil
+		case <-t.C:
+		}
+	}
+}
+
+// Round validates the recent rows of each table once.
+func (v *Validator) Round(now time.Time) ValidationRound {
+	r := ValidationRound{Time: now, Consistent: true}
+	// Schema changes are checked first, so that propagated columns are
+	// validated.
+	var err error
+	if v.propagateTo != "" {
+		r.Propagated, r.SchemaDrift, err = propagateSchemaChanges(v.driver, v.conv, v.db, v.propagateTo)
+		if len(r.Propagated) > 0 {
+			WriteSessionFile(v.conv, v.sessionFile, os.Stderr)
+		}
+	} else {
+		r.SchemaDrift, err = sourceSchemaDrift(v.driver, v.conv, v.db)
+	}
+	if err != nil {
+		r.SchemaDrift = append(r.SchemaDrift, err.Error())
+	}
+	since := make(map[string]time.Time)
+	for _, srcTable := range v.conv.SrcTables() {
+		if len(v.conv.SrcSchema[srcTable].PrimaryKeys) == 0 {
+			continue
+		}
+		tv := TableValidation{Table: srcTable}
+		inconsistent, err := v.validateTable(srcTable, &tv)
+		if err != nil {
+			tv.Error = err.Error()
+			r.Consistent = false
+		}
+		for _, k := range inconsistent {
+			since[k] = now
+			if t, ok := v.since[k]; ok {
+				since[k] = t
+			}
+			if lag := now.Sub(since[k]).Seconds(); lag > r.LagSeconds {
+				r.LagSeconds = lag
+			}
+			r.Consistent = false
+		}
+		r.Tables = append(r.Tables, tv)
+	}
+	// Rows that are no longer recent are forgotten.
+	v.since = since
+	return r
+}
+
+// validateTable validates srcTable, and returns the keys of the rows that
+// are missing or mismatched.
+func (v *Validator) validateTable(srcTable string, tv *TableValidation) ([]string, error) {
+	spTable, err := internal.GetSpannerTable(v.conv, srcTable)
+	if err != nil {
+		return nil, err
+	}
+	spSchema, ok := v.conv.SpSchema[spTable]
+	if !ok {
+		return nil, fmt.Errorf("table %s isn't migrated", srcTable)
+	}
+	var rows []map[string]interface{}
+	switch v.driver {
+	case POSTGRES:
+		rows, err = postgres.RecentRows(v.conv, v.db, srcTable, v.rows)
+	case MYSQL:
+		rows, err = mysql.RecentRows(v.conv, v.db, os.Getenv("MYSQLDATABASE"), srcTable, v.rows)
+	}
+	if err != nil {
+		return nil, err
+	}
+	fromSrc := make(map[string]bool)
+	for _, c := range v.conv.ToSpanner[srcTable].Cols {
+		fromSrc[c] = true
+	}
+	var cols []string
+	for _, c := range spSchema.ColNames {
+		if fromSrc[c] && readable(spSchema.ColDefs[c].T) {
+			cols = append(cols, c)
+		}
+	}
+	var keys []sp.Key
+	want := make(map[string]string)
+	for _, row := range rows {
+		var k sp.Key
+		for _, pk := range spSchema.Pks {
+			k = append(k, row[pk.Col])
+		}
+		keys = append(keys, k)
+		want[keyString(k)] = checksum(cols, row)
+	}
+	got := make(map[string]string)
+	iter := v.client.Single().Read(context.Background(), spTable, sp.KeySetFromKeys(keys...), cols)
+	err = iter.Do(func(r *sp.Row) error {
+		row := make(map[string]interface{})
+		var k sp.Key
+		for i, c := range cols {
+			x, err := decodeColumn(r, i, spSchema.ColDefs[c].T)
+			if err != nil {
+				return fmt.Errorf("can't decode column %s of table %s: %w", c, spTable, err)
+			}
+			row[c] = x
+		}
+		for _, pk := range spSchema.Pks {
+			k = append(k, row[pk.Col])
+		}
+		got[keyString(k)] = checksum(cols, row)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	var inconsistent []string
+	for k, sum := range want {
+		tv.Checked++
+		s, ok := got[k]
+		switch {
+		case !ok:
+			tv.Missing++
+		case s != sum:
+			tv.Mismatched++
+		default:
+			continue
+		}
+		inconsistent = append(inconsistent, spTable+":"+k)
+	}
+	return inconsistent, nil
+}
+
+// readable returns true if values of type ty can be read with the
+// Spanner client.
+func readable(ty ddl.Type) bool {
+	return ty.Name != ddl.JSON && ty.Name != ddl.Float32
+}
+
+// decodeColumn decodes column i of r, of type ty.
+func decodeColumn(r *sp.Row, i int, ty ddl.Type) (interface{}, error) {
+	var p interface{}
+	switch ty.Name {
+	case ddl.Bool:
+		p = &sp.NullBool{}
+		if ty.IsArray {
+			p = &[]sp.NullBool{}
+		}
+	case ddl.Bytes:
+		p = &[]byte{}
+		if ty.IsArray {
+			p = &[][]byte{}
+		}
+	case ddl.Date:
+		p = &sp.NullDate{}
+		if ty.IsArray {
+			p = &[]sp.NullDate{}
+		}
+	case ddl.Float64:
+		p = &sp.NullFloat64{}
+		if ty.IsArray {
+			p = &[]sp.NullFloat64{}
+		}
+	case ddl.Int64:
+		p = &sp.NullInt64{}
+		if ty.IsArray {
+			p = &[]sp.NullInt64{}
+		}
+	case ddl.Numeric:
+		p = &sp.NullNumeric{}
+		if ty.IsArray {
+			p = &[]sp.NullNumeric{}
+		}
+	case ddl.Timestamp:
+		p = &sp.NullTime{}
+		if ty.IsArray {
+			p = &[]sp.NullTime{}
+		}
+	default:
+		p = &sp.NullString{}
+		if ty.IsArray {
+			p = &[]sp.NullString{}
+		}
+	}
+	if err := r.Column(i, p); err != nil {
+		return nil, err
+	}
+	return reflect.ValueOf(p).Elem().Interface(), nil
+}
+
+// checksum returns a checksum of the values of cols in row.
+func checksum(cols []string, row map[string]interface{}) string {
+	h := sha256.New()
+	for _, c := range cols {
+		fmt.Fprintf(h, "%s=%s\n", c, canonical(row[c]))
+	}
+	return base64.StdEncoding.EncodeToString(h.Sum(nil))
+}
+
+func keyString(k sp.Key) string {
+	var l []string
+	for _, x := range k {
+		l = append(l, canonical(x))
+	}
+	return strings.Join(l, "/")
+}
+
+// canonical returns the same string for a value converted from the
+// source and for the value read from Spanner, e.g. for an int64 and a
+// spanner.NullInt64 of the same value.
+func canonical(x interface{}) string {
+	const null = "NULL"
+	switch v := x.(type) {
+	case nil:
+		return null
+	case bool:
+		return strconv.FormatBool(v)
+	case sp.NullBool:
+		if !v.Valid {
+			return null
+		}
+		return canonical(v.Bool)
+	case int64:
+		return strconv.FormatInt(v, 10)
+	case sp.NullInt64:
+		if !v.Valid {
+			return null
+		}
+		return canonical(v.Int64)
+	case float64:
+		return strconv.FormatFloat(v, 'g', -1, 64)
+	case sp.NullFloat64:
+		if !v.Valid {
+			return null
+		}
+		return canonical(v.Float64)
+	case string:
+		return strconv.Quote(v)
+	case sp.NullString:
+		if !v.Valid {
+			return null
+		}
+		return canonical(v.StringVal)
+	case []byte:
+		if v == nil {
+			return null
+		}
+		return base64.StdEncoding.EncodeToString(v)
+	case civil.Date:
+		return v.String()
+	case sp.NullDate:
+		if !v.Valid {
+			return null
+		}
+		return canonical(v.Date)
+	case time.Time:
+		return v.UTC().Format(time.RFC3339Nano)
+	case sp.NullTime:
+		if !v.Valid {
+			return null
+		}
+		return canonical(v.Time)
+	case sp.NullNumeric:
+		if !v.Valid {
+			return null
+		}
+		// Numeric values are converted to strings (see spanner.NumericString).
+		return canonical(sp.NumericString(&v.Numeric))
+	}
+	if rv := reflect.ValueOf(x); rv.Kind() == reflect.Slice {
+		if rv.IsNil() {
+			return null
+		}
+		var l []string
+		for i := 0; i < rv.Len(); i++ {
+			l = append(l, canonical(rv.Index(i).Interface()))
+		}
+		return "[" + strings.Join(l, ",") + "]"
+	}
+	return fmt.Sprint(x)
+}
@@ -0,0 +1,84 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package conversion
+
+import (
+	"testing"
+	"time"
+
+	"cloud.google.com/go/civil"
+	sp "cloud.google.com/go/spanner"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/cloudspannerecosystem/harbourbridge/internal"
+	"github.com/cloudspannerecosystem/harbourbridge/spanner/ddl"
+)
+
+func TestCanonical(t *testing.T) {
+	ts := time.Date(2021, 3, 1, 10, 0, 0, 500, time.UTC)
+	d := civil.Date{Year: 2021, Month: 3, Day: 1}
+	// Values converted from the source, and the same values read from
+	// Spanner.
+	for _, tc := range []struct {
+		src, spanner interface{}
+		want         string
+	}{
+		{true, sp.NullBool{Bool: true, Valid: true}, "true"},
+		{int64(-7), sp.NullInt64{Int64: -7, Valid: true}, "-7"},
+		{1.5, sp.NullFloat64{Float64: 1.5, Valid: true}, "1.5"},
+		{"a\"b", sp.NullString{StringVal: "a\"b", Valid: true}, `"a\"b"`},
+		{[]byte("ab"), []byte("ab"), "YWI="},
+		{d, sp.NullDate{Date: d, Valid: true}, "2021-03-01"},
+		{ts.In(time.FixedZone("X", 3600)), sp.NullTime{Time: ts, Valid: true}, "2021-03-01T10:00:00.0000005Z"},
+		{[]int64{1, 2}, []sp.NullInt64{{Int64: 1, Valid: true}, {Int64: 2, Valid: true}}, "[1,2]"},
+		{[]interface{}{"x", nil}, []sp.NullString{{StringVal: "x", Valid: true}, {}}, `["x",NULL]`},
+		{nil, sp.NullInt64{}, "NULL"},
+		{nil, sp.NullString{}, "NULL"},
+		{nil, sp.NullTime{}, "NULL"},
+		{nil, sp.NullNumeric{}, "NULL"},
+		{nil, []byte(nil), "NULL"},
+		{nil, []sp.NullInt64(nil), "NULL"},
+	} {
+		assert.Equal(t, tc.want, canonical(tc.src), "%#v", tc.src)
+		assert.Equal(t, tc.want, canonical(tc.spanner), "%#v", tc.spanner)
+	}
+	// Empty arrays aren't NULL.
+	assert.Equal(t, "[]", canonical([]int64{}))
+}
+
+func TestChecksum(t *testing.T) {
+	cols := []string{"id", "name", "note"}
+	src := map[string]interface{}{"id": int64(1), "name": "ana"}
+	spanner := map[string]interface{}{"id": sp.NullInt64{Int64: 1, Valid: true}, "name": sp.NullString{StringVal: "ana", Valid: true}, "note": sp.NullString{}}
+	assert.Equal(t, checksum(cols, src), checksum(cols, spanner))
+	spanner["note"] = sp.NullString{StringVal: "", Valid: true}
+	assert.NotEqual(t, checksum(cols, src), checksum(cols, spanner))
+	// Values are bound to their columns.
+	assert.NotEqual(t, checksum([]string{"a", "b"}, map[string]interface{}{"a": "x"}), checksum([]string{"a", "b"}, map[string]interface{}{"b": "x"}))
+	assert.Equal(t, `1/"a/b"`, keyString(sp.Key{int64(1), "a/b"}))
+}
+
+func TestReadable(t *testing.T) {
+	assert.True(t, readable(ddl.Type{Name: ddl.Int64}))
+	assert.True(t, readable(ddl.Type{Name: ddl.String, Len: ddl.MaxLength, IsArray: true}))
+	assert.False(t, readable(ddl.Type{Name: ddl.JSON}))
+}
+
+func TestNewValidator_Driver(t *testing.T) {
+	for _, driver := range []string{PGDUMP, MYSQLDUMP, DYNAMODB, "oracle"} {
+		_, err := NewValidator(driver, internal.MakeConv(), nil, 10)
+		assert.EqualError(t, err, "validation for driver "+driver+" not supported")
+	}
+}
@@ -0,0 +1,103 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package conversion
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"sort"
+
+	sp "cloud.google.com/go/spanner"
+	"google.golang.org/api/iterator"
+
+	"github.com/cloudspannerecosystem/harbourbridge/internal"
+)
+
+// WatermarkTable is the table of the target database that records the
+// watermark reached by the last incremental load of each source table
+// (see internal.Conv.SetIncremental). Keeping watermarks in the target
+// database means they are only advanced once the rows are written.
+const WatermarkTable = "harbourbridge_watermarks"
+
+// WatermarkTableDDL creates WatermarkTable.
+const WatermarkTableDDL = "CREATE TABLE IF NOT EXISTS " + WatermarkTable + ` (
+	source_table STRING(MAX) NOT NULL,
+	key_column STRING(MAX) NOT NULL,
+	watermark STRING(MAX) NOT NULL,
+	updated_at TIMESTAMP NOT NULL OPTIONS (allow_commit_timestamp=true)
+) PRIMARY KEY (source_table)`
+
+// CreateWatermarkTable creates WatermarkTable in Spanner database dbName,
+// unless it already exists.
+func CreateWatermarkTable(project, instance, dbName string) error {
+	return createTable(project, instance, dbName, WatermarkTable, WatermarkTableDDL)
+}
+
+// ReadWatermarks returns the watermarks of the source tables in keys
+// (which maps source tables to their incremental key column), by source
+// table. Watermarks recorded for another key column are ignored: such
+// tables are loaded in full.
+func ReadWatermarks(client *sp.Client, keys map[string]string) (map[string]string, error) {
+	m := make(map[string]string)
+	iter := client.Single().Read(context.Background(), WatermarkTable, sp.AllKeys(), []string{"source_table", "key_column", "watermark"})
+	defer iter.Stop()
+	for {
+		row, err := iter.Next()
+		if err == iterator.Done {
+			return m, nil
+		}
+		if err != nil {
+			return nil, fmt.Errorf("can't read table %s: %w", WatermarkTable, err)
+		}
+		var table, col, watermark string
+		if err := row.Columns(&table, &col, &watermark); err != nil {
+			return nil, err
+		}
+		if keys[table] == col {
+			m[table] = watermark
+		}
+	}
+}
+
+// WriteWatermarks records the watermarks reached by the incremental loads
+// of conv in WatermarkTable. Watermarks of tables with rows that Spanner
+// rejected (badWrites, by Spanner table) aren't advanced, so that the
+// next load reads these rows again.
+func WriteWatermarks(client *sp.Client, conv *internal.Conv, badWrites map[string]int64, out *os.File) error {
+	watermarks := conv.Watermarks()
+	var tables []string
+	for t := range watermarks {
+		tables = append(tables, t)
+	}
+	sort.Strings(tables)
+	var l []*sp.Mutation
+	for _, t := range tables {
+		if spTable, err := internal.GetSpannerTable(conv, t); err == nil && badWrites[spTable] > 0 {
+			fmt.Fprintf(out, "Not advancing the watermark of table %s: %d rows couldn't be written.\n", t, badWrites[spTable])
+			continue
+		}
+		col, _, _ := conv.IncrementalKey(t)
+		l = append(l, sp.InsertOrUpdate(WatermarkTable, []string{"source_table", "key_column", "watermark", "updated_at"}, []interface{}{t, col, watermarks[t], sp.CommitTimestamp}))
+	}
+	if len(l) == 0 {
+		return nil
+	}
+	if _, err := client.Apply(context.Background(), l); err != nil {
+		return fmt.Errorf("can't update table %s: %w", WatermarkTable, err)
+	}
+	fmt.Fprintf(out, "Recorded the watermarks of %d table(s) in table %s.\n", len(l), WatermarkTable)
+	return nil
+}
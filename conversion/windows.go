@@ -0,0 +1,77 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package conversion
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"time"
+
+	"github.com/cloudspannerecosystem/harbourbridge/internal"
+	"github.com/cloudspannerecosystem/harbourbridge/spanner"
+)
+
+// windowCheckInterval is how often the writes of tables with a time
+// window are paused or resumed as their windows close and open.
+const windowCheckInterval = time.Minute
+
+// ReadTableWindowsFile reads a JSON file scheduling the data conversion
+// of source tables during time windows (see internal.TableWindows).
+func ReadTableWindowsFile(name string) (internal.TableWindows, error) {
+	var w internal.TableWindows
+	s, err := ioutil.ReadFile(name)
+	if err != nil {
+		return w, err
+	}
+	if err := json.Unmarshal(s, &w); err != nil {
+		return w, fmt.Errorf("can't parse table windows file %s: %w", name, err)
+	}
+	return w, nil
+}
+
+// pauseClosedWindows pauses the writes of the tables of conv whose time
+// window is closed, and resumes them when it opens, until the returned
+// function is called.
+func pauseClosedWindows(conv *internal.Conv, control *spanner.WriteControl) func() {
+	stop := make(chan bool)
+	update := func() {
+		closed, all := conv.ClosedWindowTables(time.Now())
+		isClosed := make(map[string]bool)
+		for _, t := range closed {
+			isClosed[t] = true
+			control.PauseTable(t)
+		}
+		for _, t := range all {
+			if !isClosed[t] {
+				control.ResumeTable(t)
+			}
+		}
+	}
+	update()
+	go func() {
+		ticker := time.NewTicker(windowCheckInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-stop:
+				return
+			case <-ticker.C:
+				update()
+			}
+		}
+	}()
+	return func() { close(stop) }
+}
@@ -0,0 +1,84 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package conversion
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// Kinds of files written by a run, named after their subdirectory of the
+// workspace (see Workspace).
+const (
+	DDLFiles     = "ddl"     // Schema, models, diagrams and PGAdapter files.
+	ReportFiles  = "report"  // Reports, lineage and anomalies.
+	SessionFiles = "session" // Session files.
+	BadRowFiles  = "badrows" // Rows that couldn't be converted or written.
+	LogFiles     = "logs"    // Logs.
+)
+
+var workspaceKinds = []string{DDLFiles, ReportFiles, SessionFiles, BadRowFiles, LogFiles}
+
+// Workspace is where the files of a run are written. The names of files
+// start with Prefix. If Dir is empty, files are written to the current
+// directory (or the directory given by Prefix); otherwise, they are
+// written to the subdirectory of Dir for their kind.
+type Workspace struct {
+	Dir    string
+	Prefix string
+}
+
+// NewWorkspace creates the workspace of a run started at now: a directory
+// under dir named after now (e.g. hb-out/20060102-150405), with a
+// subdirectory for each kind of file, so that the files of different runs
+// don't collide. If dir is empty, files are written to the current
+// directory.
+func NewWorkspace(dir, prefix string, now time.Time) (Workspace, error) {
+	if dir == "" {
+		return Workspace{Prefix: prefix}, nil
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return Workspace{}, fmt.Errorf("can't create output directory %s: %w", dir, err)
+	}
+	name := now.Format("20060102-150405")
+	w := Workspace{Dir: filepath.Join(dir, name), Prefix: prefix}
+	// Runs started in the same second get directories of their own.
+	for i := 2; ; i++ {
+		err := os.Mkdir(w.Dir, 0755)
+		if err == nil {
+			break
+		}
+		if !os.IsExist(err) {
+			return Workspace{}, fmt.Errorf("can't create output directory %s: %w", w.Dir, err)
+		}
+		w.Dir = filepath.Join(dir, fmt.Sprintf("%s-%d", name, i))
+	}
+	for _, kind := range workspaceKinds {
+		if err := os.Mkdir(filepath.Join(w.Dir, kind), 0755); err != nil {
+			return Workspace{}, fmt.Errorf("can't create output directory: %w", err)
+		}
+	}
+	return w, nil
+}
+
+// File returns the path of file name of kind.
+func (w Workspace) File(kind, name string) string {
+	if w.Dir == "" {
+		return w.Prefix + name
+	}
+	return filepath.Join(w.Dir, kind, w.Prefix+name)
+}
@@ -0,0 +1,123 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package conversion
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"sync/atomic"
+
+	sp "cloud.google.com/go/spanner"
+
+	"github.com/cloudspannerecosystem/harbourbridge/internal"
+)
+
+// Write modes (see SpannerOptions.WriteMode).
+const (
+	// WriteMutations writes rows with mutations, the fastest way to load
+	// data into Spanner.
+	WriteMutations = "mutation"
+	// WriteDML writes rows with batches of INSERT statements, in
+	// read-write transactions, so that Spanner evaluates the DEFAULT
+	// values and generated columns of tables.
+	WriteDML = "dml"
+	// WriteAuto writes the rows of tables that have columns with a
+	// DEFAULT value or generated columns with DML, and the rows of other
+	// tables with mutations.
+	WriteAuto = "auto"
+)
+
+// dmlTables returns the function that tells which tables are written with
+// DML in write mode mode (see spanner.BatchWriterConfig.DML), or nil if
+// all tables are written with mutations.
+func dmlTables(client *sp.Client, mode string, out io.Writer) (func(string) bool, error) {
+	switch mode {
+	case "", WriteMutations:
+		return nil, nil
+	case WriteDML:
+		return func(string) bool { return true }, nil
+	case WriteAuto:
+		tables, err := evaluatedTables(client)
+		if err != nil {
+			return nil, fmt.Errorf("can't find tables with DEFAULT values or generated columns: %w", err)
+		}
+		if len(tables) == 0 {
+			return nil, nil
+		}
+		var l []string
+		for t := range tables {
+			l = append(l, t)
+		}
+		sort.Strings(l)
+		fmt.Fprintf(out, "Writing tables with DEFAULT values or generated columns with DML: %s\n", strings.Join(l, ", "))
+		return func(table string) bool { return tables[table] }, nil
+	}
+	return nil, fmt.Errorf("unknown write mode %q (accepted values are %q, %q and %q)", mode, WriteMutations, WriteDML, WriteAuto)
+}
+
+// evaluatedTables returns the tables of the database that have columns
+// whose values Spanner evaluates: columns with a DEFAULT value, and
+// generated columns.
+func evaluatedTables(client *sp.Client) (map[string]bool, error) {
+	stmt := sp.NewStatement(`SELECT DISTINCT TABLE_NAME FROM INFORMATION_SCHEMA.COLUMNS
+WHERE TABLE_SCHEMA = '' AND (COLUMN_DEFAULT IS NOT NULL OR IS_GENERATED = 'ALWAYS')`)
+	iter := client.Single().Query(context.Background(), stmt)
+	tables := make(map[string]bool)
+	err := iter.Do(func(r *sp.Row) error {
+		var t string
+		if err := r.Column(0, &t); err != nil {
+			return err
+		}
+		tables[t] = true
+		return nil
+	})
+	return tables, err
+}
+
+// writeFuncs returns the functions that write batches of rows to Spanner
+// with client (see spanner.BatchWriterConfig): batches of mutations, and
+// batches with DML statements, which are run with the mutations in a
+// read-write transaction. Rows written are reported to p.
+func writeFuncs(client *sp.Client, p *internal.Progress) (func([]*sp.Mutation) error, func([]*sp.Mutation, []sp.Statement) error) {
+	rows := int64(0)
+	write := func(m []*sp.Mutation) error {
+		_, err := client.Apply(context.Background(), m)
+		if err != nil {
+			return err
+		}
+		p.MaybeReport(atomic.AddInt64(&rows, int64(len(m))))
+		return nil
+	}
+	writeDML := func(m []*sp.Mutation, stmts []sp.Statement) error {
+		_, err := client.ReadWriteTransaction(context.Background(), func(ctx context.Context, txn *sp.ReadWriteTransaction) error {
+			if len(m) > 0 {
+				if err := txn.BufferWrite(m); err != nil {
+					return err
+				}
+			}
+			_, err := txn.BatchUpdate(ctx, stmts)
+			return err
+		})
+		if err != nil {
+			return err
+		}
+		p.MaybeReport(atomic.AddInt64(&rows, int64(len(m)+len(stmts))))
+		return nil
+	}
+	return write, writeDML
+}
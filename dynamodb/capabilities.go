@@ -0,0 +1,50 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dynamodb
+
+import (
+	"github.com/cloudspannerecosystem/harbourbridge/internal"
+	"github.com/cloudspannerecosystem/harbourbridge/schema"
+)
+
+// sourceTypes are the attribute types inferred from sampled items (see
+// inferDataTypes), all of which toSpannerType maps.
+var sourceTypes = []string{
+	typeString, typeBool, typeNumber, typeNumberString, typeBinary, typeList,
+	typeMap, typeStringSet, typeNumberSet, typeNumberStringSet, typeBinarySet,
+}
+
+// Capabilities returns how DynamoDB attribute types are mapped to targetDb
+// when it supports features. DynamoDB tables have no constructs besides
+// attributes, keys and secondary indexes, which are all converted.
+func Capabilities(targetDb string, features internal.Features) (internal.Capabilities, error) {
+	conv := internal.MakeConv()
+	conv.TargetDb = targetDb
+	conv.Features = features
+	var types []schema.Type
+	for _, t := range sourceTypes {
+		types = append(types, schema.Type{Name: t})
+	}
+	mappings, err := internal.MapTypes(conv, types, schemaToDDL)
+	if err != nil {
+		return internal.Capabilities{}, err
+	}
+	return internal.Capabilities{
+		Target:   targetDb,
+		Features: features.String(),
+		Types:    mappings,
+		Dropped:  []string{},
+	}, nil
+}
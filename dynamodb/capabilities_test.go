@@ -0,0 +1,33 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dynamodb
+
+import (
+	"testing"
+
+	"github.com/cloudspannerecosystem/harbourbridge/internal"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCapabilities(t *testing.T) {
+	c, err := Capabilities("spanner", internal.DefaultFeatures())
+	assert.Nil(t, err)
+	assert.Equal(t, len(sourceTypes), len(c.Types))
+	for _, m := range c.Types {
+		// All inferred types have a good mapping.
+		assert.Empty(t, m.Issues, m.Source)
+	}
+	assert.Equal(t, "ARRAY<NUMERIC>", c.Types[8].Spanner)
+}
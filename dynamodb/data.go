@@ -18,6 +18,7 @@ import (
 	"encoding/json"
 	"fmt"
 	"math/big"
+	"time"
 
 	"github.com/aws/aws-sdk-go/aws"
 	"github.com/aws/aws-sdk-go/service/dynamodb"
@@ -33,35 +34,52 @@ import (
 // tables.
 func ProcessData(conv *internal.Conv, client dynamoClient) error {
 	for srcTable, srcSchema := range conv.SrcSchema {
+		if conv.SkipData(srcTable) {
+			continue
+		}
+		// Skip columns that are not migrated (see internal.DropColumns).
+		// Note that srcSchema is a copy, so conv.SrcSchema is unchanged.
+		var srcCols []string
+		for _, c := range srcSchema.ColNames {
+			if !conv.IsDroppedCol(srcTable, c) {
+				srcCols = append(srcCols, c)
+			}
+		}
+		srcSchema.ColNames = srcCols
 		spTable, err1 := internal.GetSpannerTable(conv, srcTable)
 		spCols, err2 := internal.GetSpannerCols(conv, srcTable, srcSchema.ColNames)
-		spSchema, ok := conv.SpSchema[spTable]
+		spSchema, ok := conv.DataSchema(spTable)
 		if err1 != nil || err2 != nil || !ok {
-			conv.Stats.BadRows[srcTable] += conv.Stats.Rows[srcTable]
+			conv.StatsAddTableBadRows(srcTable)
 			conv.Unexpected(fmt.Sprintf("Can't get cols and schemas for table %s: err1=%s, err2=%s, ok=%t",
 				srcTable, err1, err2, ok))
 			continue
 		}
 
-		err := scan(srcTable, client, func(m map[string]*dynamodb.AttributeValue) {
-			spVals, badCols, srcStrVals := cvtRow(m, srcSchema, spSchema, spCols)
+		conv.StartTable(srcTable)
+		conv.RecordSnapshot(srcTable)
+		err := scan(srcTable, client, func(m map[string]*dynamodb.AttributeValue) bool {
+			spVals, badCols, srcStrVals, err := cvtRow(m, srcSchema, spSchema, spCols)
 			if len(badCols) == 0 {
 				conv.WriteRow(srcTable, spTable, spCols, spVals)
 			} else {
 				conv.Unexpected(fmt.Sprintf("Data conversion error for table %s in column(s) %s\n", srcTable, badCols))
 				conv.StatsAddBadRow(srcTable, conv.DataMode())
 				conv.CollectBadRow(srcTable, srcSchema.ColNames, srcStrVals)
+				conv.GroupBadRow(srcTable, err, srcSchema.ColNames, srcStrVals)
 			}
+			return conv.Aborted() == nil
 		})
 		if err != nil {
-			conv.Stats.BadRows[srcTable] += conv.Stats.Rows[srcTable]
+			conv.StatsAddTableBadRows(srcTable)
 			conv.Unexpected(fmt.Sprintf("Can't scan the data for table %s: %s", srcTable, err))
 		}
 	}
 	return nil
 }
 
-func scan(table string, client dynamoClient, f func(map[string]*dynamodb.AttributeValue)) error {
+// scan calls f for each item of table, until f returns false.
+func scan(table string, client dynamoClient, f func(map[string]*dynamodb.AttributeValue) bool) error {
 	var lastEvaluatedKey map[string]*dynamodb.AttributeValue
 	for {
 		// Build the query input parameters.
@@ -80,7 +98,9 @@ func scan(table string, client dynamoClient, f func(map[string]*dynamodb.Attribu
 
 		// Iterate the items returned.
 		for _, attrsMap := range result.Items {
-			f(attrsMap)
+			if !f(attrsMap) {
+				return nil
+			}
 		}
 		if result.LastEvaluatedKey == nil {
 			return nil
@@ -90,8 +110,11 @@ func scan(table string, client dynamoClient, f func(map[string]*dynamodb.Attribu
 	}
 }
 
-func cvtRow(attrsMap map[string]*dynamodb.AttributeValue, srcSchema schema.Table, spSchema ddl.CreateTable, spCols []string) ([]interface{}, []string, []string) {
-	var err error
+// cvtRow converts an item, and returns the converted values, the columns
+// that can't be converted, the source values as strings, and the error of
+// the first column that can't be converted.
+func cvtRow(attrsMap map[string]*dynamodb.AttributeValue, srcSchema schema.Table, spSchema ddl.CreateTable, spCols []string) ([]interface{}, []string, []string, error) {
+	var err, badErr error
 	var srcStrVals []string
 	var spVals []interface{}
 	var badCols []string
@@ -104,15 +127,18 @@ func cvtRow(attrsMap map[string]*dynamodb.AttributeValue, srcSchema schema.Table
 		} else {
 			// Convert data to the target type.
 			spVal, err = cvtColValue(attrsMap[srcCol], srcSchema.ColDefs[srcCol].Type.Name, spSchema.ColDefs[spCols[i]].T.Name)
+			srcStrVal = attrsMap[srcCol].GoString()
 			if err != nil {
 				badCols = append(badCols, srcCol)
+				if badErr == nil {
+					badErr = &internal.ColumnError{Col: srcCol, Val: srcStrVal, Err: err}
+				}
 			}
-			srcStrVal = attrsMap[srcCol].GoString()
 		}
 		srcStrVals = append(srcStrVals, srcStrVal)
 		spVals = append(spVals, spVal)
 	}
-	return spVals, badCols, srcStrVals
+	return spVals, badCols, srcStrVals, badErr
 }
 
 func cvtColValue(attrVal *dynamodb.AttributeValue, srcType string, spType string) (interface{}, error) {
@@ -240,3 +266,22 @@ func stripNull(a *dynamodb.AttributeValue) (interface{}, error) {
 		return nil, fmt.Errorf("unknown type of AttributeValue: %v", a)
 	}
 }
+
+// StreamPosition returns the position of srcTable's DynamoDB stream that
+// changes made from now on are in: the ARN of the stream, from which
+// records created after the returned Time must be replayed. DynamoDB
+// scans aren't snapshots, so the position isn't exact. It is empty if
+// the table has no stream.
+func StreamPosition(client dynamoClient, srcTable string) (internal.SnapshotPosition, error) {
+	p := internal.SnapshotPosition{Kind: "dynamodb-stream", Time: time.Now()}
+	result, err := client.DescribeTable(&dynamodb.DescribeTableInput{TableName: aws.String(srcTable)})
+	if err != nil {
+		return p, fmt.Errorf("failed to make a DescribeTable API call for table %v: %v", srcTable, err)
+	}
+	spec := result.Table.StreamSpecification
+	if spec == nil || !aws.BoolValue(spec.StreamEnabled) || result.Table.LatestStreamArn == nil {
+		return internal.SnapshotPosition{}, nil
+	}
+	p.Position = *result.Table.LatestStreamArn
+	return p, nil
+}
@@ -20,6 +20,7 @@ import (
 	"math/big"
 	"testing"
 
+	"github.com/aws/aws-sdk-go/aws"
 	"github.com/aws/aws-sdk-go/service/dynamodb"
 	"github.com/cloudspannerecosystem/harbourbridge/internal"
 	"github.com/cloudspannerecosystem/harbourbridge/schema"
@@ -143,9 +144,10 @@ func TestCvtRowWithError(t *testing.T) {
 	attrs := map[string]*dynamodb.AttributeValue{
 		"a": {S: &strA},
 	}
-	_, badCols, srcStrVals := cvtRow(attrs, srcSchema, spSchema, cols)
+	_, badCols, srcStrVals, err := cvtRow(attrs, srcSchema, spSchema, cols)
 
 	assert.Equal(t, []string{"a"}, badCols)
+	assert.Equal(t, "a", err.(*internal.ColumnError).Col)
 	assert.Equal(t, []string{attrs["a"].GoString()}, srcStrVals)
 }
 
@@ -260,3 +262,29 @@ func buildConv(spTable ddl.CreateTable, srcTable schema.Table) *internal.Conv {
 	}
 	return conv
 }
+
+func TestStreamPosition(t *testing.T) {
+	arn := "arn:aws:dynamodb:us-east-1:123456789012:table/t/stream/2021-03-04T05:06:07.000"
+	client := &mockDynamoClient{
+		describeTableOutputs: []dynamodb.DescribeTableOutput{
+			{Table: &dynamodb.TableDescription{
+				StreamSpecification: &dynamodb.StreamSpecification{StreamEnabled: aws.Bool(true)},
+				LatestStreamArn:     aws.String(arn),
+			}},
+			{Table: &dynamodb.TableDescription{}},
+		},
+	}
+	p, err := StreamPosition(client, "t")
+	assert.Nil(t, err)
+	assert.Equal(t, "dynamodb-stream", p.Kind)
+	assert.Equal(t, arn, p.Position)
+	assert.False(t, p.Exact)
+
+	// Tables without streams have no position.
+	p, err = StreamPosition(client, "u")
+	assert.Nil(t, err)
+	assert.Equal(t, internal.SnapshotPosition{}, p)
+
+	_, err = StreamPosition(client, "v")
+	assert.NotNil(t, err)
+}
@@ -70,7 +70,9 @@ func ProcessSchema(conv *internal.Conv, client dynamoClient, tables []string, sa
 			return err
 		}
 	}
-	schemaToDDL(conv)
+	if err := schemaToDDL(conv); err != nil {
+		return err
+	}
 	conv.AddPrimaryKeys()
 	return nil
 }
@@ -109,7 +111,7 @@ func processTable(conv *internal.Conv, client dynamoClient, table string, sample
 	// Sort column names in increasing order, because the server may return them
 	// in a random order.
 	sort.Strings(dySchema.ColNames)
-	conv.SrcSchema[table] = dySchema
+	conv.SetSrcTable(dySchema)
 	return nil
 }
 
@@ -358,6 +360,9 @@ func SetRowStats(conv *internal.Conv, client dynamoClient) {
 		return
 	}
 	for _, t := range tables {
+		if conv.SkipData(t) {
+			continue
+		}
 		input := &dynamodb.DescribeTableInput{
 			TableName: aws.String(t),
 		}
@@ -366,6 +371,6 @@ func SetRowStats(conv *internal.Conv, client dynamoClient) {
 			conv.Unexpected(fmt.Sprintf("failed to make a DescribeTable API call for table %v: %v", t, err))
 			return
 		}
-		conv.Stats.Rows[t] = *result.Table.ItemCount
+		conv.StatsAddRows(t, *result.Table.ItemCount)
 	}
 }
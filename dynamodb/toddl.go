@@ -28,20 +28,24 @@ import (
 // Spanner. It uses the source schema in conv.SrcSchema, and writes
 // the Spanner schema to conv.SpSchema.
 func schemaToDDL(conv *internal.Conv) error {
-	// Tracks Spanner names that have been used for indexes. We use this to ensure we generate unique names when
-	// we map from DynamoDB to Spanner since we want Spanner table names and index names to be distinct.
-	usedNames := make(map[string]bool)
-	// We need to pre-populate usedNames with Spanner table names to handle collisions
-	// with table names, foreign key names and index names.
-	for _, srcTable := range conv.SrcSchema {
+	if err := conv.RunPreTableHooks(); err != nil {
+		return err
+	}
+	// We need to reserve Spanner table names to handle collisions with
+	// index names.
+	// Process tables in a deterministic order, so that Spanner names are
+	// allocated the same way in every run.
+	for _, t := range conv.SrcTables() {
+		srcTable := conv.SrcSchema[t]
 		spTableName, err := internal.GetSpannerTable(conv, srcTable.Name)
 		if err != nil {
 			conv.Unexpected(fmt.Sprintf("Couldn't map source table %s to Spanner: %s", srcTable.Name, err))
 			continue
 		}
-		usedNames[spTableName] = true
+		conv.ReserveName(spTableName)
 	}
-	for _, srcTable := range conv.SrcSchema {
+	for _, t := range conv.SrcTables() {
+		srcTable := conv.SrcSchema[t]
 		spTableName, err := internal.GetSpannerTable(conv, srcTable.Name)
 		if err != nil {
 			conv.Unexpected(fmt.Sprintf("Couldn't map source table %s to Spanner: %s", srcTable.Name, err))
@@ -49,7 +53,7 @@ func schemaToDDL(conv *internal.Conv) error {
 		}
 		var spColNames []string
 		spColDef := make(map[string]ddl.ColumnDef)
-		conv.Issues[srcTable.Name] = make(map[string][]internal.SchemaIssue)
+		colIssues := make(map[string][]internal.SchemaIssue)
 		// Iterate over columns using ColNames order.
 		for _, srcColName := range srcTable.ColNames {
 			srcCol := srcTable.ColDefs[srcColName]
@@ -62,7 +66,7 @@ func schemaToDDL(conv *internal.Conv) error {
 			ty, issues := toSpannerType(conv, srcCol.Type.Name, srcCol.Type.Mods)
 
 			if len(issues) > 0 {
-				conv.Issues[srcTable.Name][srcCol.Name] = issues
+				colIssues[srcCol.Name] = issues
 			}
 			spColDef[colName] = ddl.ColumnDef{
 				Name:    colName,
@@ -72,13 +76,17 @@ func schemaToDDL(conv *internal.Conv) error {
 			}
 		}
 		comment := "Spanner schema for source table " + quoteIfNeeded(srcTable.Name)
-		conv.SpSchema[spTableName] = ddl.CreateTable{
+		conv.SetIssues(srcTable.Name, colIssues)
+		ct := ddl.CreateTable{
 			Name:     spTableName,
 			ColNames: spColNames,
 			ColDefs:  spColDef,
 			Pks:      cvtPrimaryKeys(conv, srcTable.Name, srcTable.PrimaryKeys),
-			Indexes:  cvtIndexes(conv, spTableName, srcTable.Name, srcTable.Indexes, usedNames),
+			Indexes:  cvtIndexes(conv, spTableName, srcTable.Name, srcTable.Indexes),
 			Comment:  comment}
+		if err := conv.AddConvertedTable(srcTable.Name, ct); err != nil {
+			return err
+		}
 	}
 	return nil
 }
@@ -131,11 +139,13 @@ func cvtPrimaryKeys(conv *internal.Conv, srcTable string, srcKeys []schema.Key)
 	return spKeys
 }
 
-func cvtIndexes(conv *internal.Conv, spTableName string, srcTable string, srcIndexes []schema.Index, usedNames map[string]bool) []ddl.CreateIndex {
+func cvtIndexes(conv *internal.Conv, spTableName string, srcTable string, srcIndexes []schema.Index) []ddl.CreateIndex {
 	var spIndexes []ddl.CreateIndex
 	for _, srcIndex := range srcIndexes {
 		var spKeys []ddl.IndexKey
+		var srcCols []string
 		for _, k := range srcIndex.Keys {
+			srcCols = append(srcCols, k.Column)
 			spCol, err := internal.GetSpannerCol(conv, srcTable, k.Column, true)
 			if err != nil {
 				conv.Unexpected(fmt.Sprintf("Can't map index key column name for table %s", srcTable))
@@ -143,7 +153,7 @@ func cvtIndexes(conv *internal.Conv, spTableName string, srcTable string, srcInd
 			}
 			spKeys = append(spKeys, ddl.IndexKey{Col: spCol, Desc: k.Desc})
 		}
-		spIndexName := internal.ToSpannerIndexName(srcIndex.Name, usedNames)
+		spIndexName := conv.IndexName(srcTable, srcIndex.Name, srcCols)
 		spIndex := ddl.CreateIndex{
 			Name:   spIndexName,
 			Table:  spTableName,
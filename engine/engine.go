@@ -0,0 +1,272 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package engine is the Go API of HarbourBridge's conversion engine, for
+// embedding migrations in other programs (e.g. migration orchestrators)
+// instead of running the harbourbridge command.
+//
+// A migration converts the schema of the source (ConvertSchema), creates
+// the Spanner database (Migration.CreateDatabase), loads the data
+// (Migration.LoadData) and finally adds foreign keys
+// (Migration.AddForeignKeys). The progress of all these phases can be
+// rendered by the caller, from events sent to SchemaOptions.Progress. See
+// examples/embed for a complete program.
+//
+// ConvertSchema applies its options to the schema as the harbourbridge
+// command does (see conversion.PrepareSchema), so that both give the
+// same schema, and reject the same invalid policies. The types this
+// package aliases (Conv, SpannerOptions, ...) are those of the command,
+// and change with it.
+package engine
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"sync"
+
+	"github.com/cloudspannerecosystem/harbourbridge/conversion"
+	"github.com/cloudspannerecosystem/harbourbridge/internal"
+	"github.com/cloudspannerecosystem/harbourbridge/spanner/ddl"
+)
+
+// Source drivers.
+const (
+	PostgreSQL = conversion.POSTGRES  // A PostgreSQL database, configured by the PG* environment variables.
+	PGDump     = conversion.PGDUMP    // A pg_dump file.
+	MySQL      = conversion.MYSQL     // A MySQL database, configured by the MYSQL* environment variables.
+	MySQLDump  = conversion.MYSQLDUMP // A mysqldump file.
+	DynamoDB   = conversion.DYNAMODB  // AWS DynamoDB, configured by the AWS environment variables.
+)
+
+// Target dialects.
+const (
+	Spanner           = conversion.TARGET_SPANNER               // Google Standard SQL.
+	SpannerPostgreSQL = conversion.TARGET_EXPERIMENTAL_POSTGRES // Spanner's PostgreSQL dialect.
+)
+
+type (
+	// Conv is the state of a migration: the source and Spanner schemas,
+	// how they map to each other, and the statistics and issues reported.
+	Conv = internal.Conv
+	// Features are the Spanner features that the target supports.
+	Features = internal.Features
+	// Policies are the data conversion policies.
+	Policies = internal.Policies
+	// Remodel specifies tables to split or merge.
+	Remodel = internal.Remodel
+	// Ordering specifies the order of tables and columns in the schema
+	// and report.
+	Ordering = internal.Ordering
+	// ComputedCol is a new Spanner column computed from other columns.
+	ComputedCol = internal.ComputedCol
+	// Report is the structured conversion report.
+	Report = internal.StructuredReport
+	// SpannerOptions configures the Spanner database and client.
+	SpannerOptions = conversion.SpannerOptions
+	// SourceOptions configures how live source databases are read.
+	SourceOptions = conversion.SourceOptions
+	// ProgressEvent is an event of the progress of a migration (see
+	// SchemaOptions.Progress).
+	ProgressEvent = internal.ProgressEvent
+)
+
+// Kinds of progress events.
+const (
+	TableStartEvent  = internal.TableStartEvent  // Data conversion of a source table started.
+	TableFinishEvent = internal.TableFinishEvent // Data conversion of a source table finished, with its row counts.
+	ErrorEvent       = internal.ErrorEvent       // An unexpected condition was first encountered.
+	CheckpointEvent  = internal.CheckpointEvent  // A phase of the migration finished (e.g. "data loaded").
+	TaskEvent        = internal.TaskEvent        // The percentage done of a task (e.g. "Writing data to Spanner") changed.
+)
+
+// SchemaOptions configures schema conversion. Only Driver is required:
+// the zero value of the other fields gives the defaults of the
+// harbourbridge command.
+type SchemaOptions struct {
+	Driver string
+	// Dump is the dump file, for the PGDump and MySQLDump drivers. It is
+	// read twice (for schema and data conversion), so if it isn't
+	// seekable (e.g. a pipe), it is first copied to a temporary file.
+	Dump   *os.File
+	Target string // Target dialect (empty means Spanner).
+	// Features are the Spanner features the target supports (nil means
+	// internal.AutoFeatures, as for the harbourbridge command).
+	Features Features
+	// SampleSize is the number of rows sampled to infer the schema of
+	// DynamoDB tables (0 means the default).
+	SampleSize      int64
+	DropColumns     []string // Source columns (table.column) to drop.
+	ComputedCols    []ComputedCol
+	Remodel         Remodel
+	Policies        Policies
+	Ordering        Ordering
+	ForeignKeyNames string // Template for foreign key names (see the -fk-names flag).
+	// Seed is the seed of the random values generated during data
+	// conversion, e.g. random synthetic keys (0 means a random seed).
+	Seed int64
+	// Source configures how the PostgreSQL and MySQL drivers read the
+	// source, for both schema and data conversion.
+	Source SourceOptions
+	// Out receives progress and error messages (nil means os.Stdout).
+	Out *os.File
+	// Progress, if not nil, is sent the progress events of all the phases
+	// of the migration, and the percentages of tasks are no longer printed
+	// to the standard output, so that they can be rendered by the caller.
+	// Calls are serialized, but can come from different goroutines. The
+	// migration waits for them with its Conv locked, so Progress must
+	// never block (e.g. send to a buffered channel without waiting, and
+	// drop events when it is full), and must not call methods of the
+	// migration or its Conv.
+	Progress func(ProgressEvent)
+}
+
+// Migration is a migration whose schema has been converted.
+type Migration struct {
+	Conv   *Conv
+	driver string
+	source SourceOptions
+	io     *conversion.IOStreams
+	// badWrites are the rows Spanner rejected, by Spanner table.
+	badWrites map[string]int64
+}
+
+// ConvertSchema converts the schema of the source.
+func ConvertSchema(opts SchemaOptions) (*Migration, error) {
+	if opts.Target == "" {
+		opts.Target = Spanner
+	}
+	if opts.Features == nil {
+		opts.Features = internal.AutoFeatures()
+	}
+	if opts.Out == nil {
+		opts.Out = os.Stdout
+	}
+	if opts.SampleSize == 0 {
+		opts.SampleSize = 100000
+	}
+	m := &Migration{driver: opts.Driver, source: opts.Source, io: &conversion.IOStreams{In: opts.Dump, Out: opts.Out}}
+	if opts.Progress != nil {
+		var mu sync.Mutex
+		m.io.Progress = func(e ProgressEvent) {
+			mu.Lock()
+			defer mu.Unlock()
+			opts.Progress(e)
+		}
+		m.io.ProgressOut = ioutil.Discard
+	}
+	if (opts.Driver == PGDump || opts.Driver == MySQLDump) && opts.Dump == nil {
+		return nil, fmt.Errorf("driver %s requires a dump file", opts.Driver)
+	}
+	if err := opts.Source.Validate(opts.Driver); err != nil {
+		return nil, err
+	}
+	conv, err := conversion.SchemaConv(opts.Driver, opts.Target, opts.Features, m.io, opts.SampleSize, opts.Source)
+	if err != nil {
+		return nil, err
+	}
+	settings := conversion.SchemaSettings{
+		Seed:            opts.Seed,
+		DropColumns:     opts.DropColumns,
+		ComputedCols:    opts.ComputedCols,
+		Remodel:         opts.Remodel,
+		Policies:        opts.Policies,
+		Ordering:        opts.Ordering,
+		ForeignKeyNames: opts.ForeignKeyNames,
+	}
+	if err := conversion.PrepareSchema(conv, settings, false); err != nil {
+		return nil, err
+	}
+	conv.Checkpoint("schema converted")
+	m.Conv = conv
+	return m, nil
+}
+
+// DDL returns the statements that create the Spanner tables and indexes.
+func (m *Migration) DDL() []string {
+	return m.Conv.GetDDL(ddl.Config{ProtectIds: true, Tables: true, PostgreSQL: m.Conv.TargetDb == SpannerPostgreSQL})
+}
+
+// ForeignKeyDDL returns the statements that add the foreign keys.
+func (m *Migration) ForeignKeyDDL() []string {
+	return m.Conv.GetDDL(ddl.Config{ProtectIds: true, ForeignKeys: true, PostgreSQL: m.Conv.TargetDb == SpannerPostgreSQL})
+}
+
+// CreateDatabase creates Spanner database dbName with the tables and
+// indexes of the migration (see conversion.PrepareDatabase for the use
+// of existing databases), and returns its full name.
+func (m *Migration) CreateDatabase(project, instance, dbName string, opts SpannerOptions) (string, error) {
+	if err := opts.Validate(m.Conv.TargetDb); err != nil {
+		return "", err
+	}
+	db, err := conversion.PrepareDatabase(project, instance, dbName, m.Conv, opts, m.io.Out)
+	if err != nil {
+		return "", err
+	}
+	if err := conversion.GrantDatabaseRoles(project, instance, dbName, opts.Grants, m.io.Out); err != nil {
+		return "", err
+	}
+	m.Conv.Checkpoint("schema created")
+	return db, nil
+}
+
+// LoadData converts the data of the source and writes it to Spanner
+// database db (as returned by CreateDatabase). Rows that can't be
+// converted or written are counted in the report rather than failing
+// the load.
+func (m *Migration) LoadData(db string, opts SpannerOptions) error {
+	if err := opts.Validate(m.Conv.TargetDb); err != nil {
+		return err
+	}
+	client, err := conversion.GetClient(db, opts)
+	if err != nil {
+		return fmt.Errorf("can't create client for database %s: %w", db, err)
+	}
+	defer client.Close()
+	m.Conv.Checkpoint("loading data")
+	bw, err := conversion.DataConv(m.driver, m.io, client, m.Conv, false, m.source, opts)
+	m.Conv.FinishTables()
+	if err != nil {
+		return err
+	}
+	m.badWrites = bw.DroppedRowsByTable()
+	m.Conv.Checkpoint("data loaded")
+	return nil
+}
+
+// AddForeignKeys adds the foreign keys of the migration to Spanner
+// database dbName. Foreign keys that can't be added are reported in the
+// unexpected conditions of the report.
+func (m *Migration) AddForeignKeys(project, instance, dbName string) error {
+	if err := conversion.UpdateDDLForeignKeys(project, instance, dbName, m.Conv, m.io.Out); err != nil {
+		return err
+	}
+	m.Conv.Checkpoint("foreign keys added")
+	return nil
+}
+
+// Report returns the structured report of the migration.
+func (m *Migration) Report() Report {
+	return internal.GenerateStructuredReport(m.driver, m.Conv, m.badWrites)
+}
+
+// WriteReport writes the text report of the migration (as in the
+// report.txt file of the harbourbridge command) to w.
+func (m *Migration) WriteReport(w io.Writer) error {
+	bw := bufio.NewWriter(w)
+	internal.GenerateReport(m.driver, m.Conv, bw, m.badWrites, true, true)
+	return bw.Flush()
+}
@@ -0,0 +1,163 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package engine
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/cloudspannerecosystem/harbourbridge/internal"
+	"github.com/stretchr/testify/assert"
+)
+
+const testDump = `
+CREATE TABLE public.cart (
+    productid text NOT NULL,
+    userid text NOT NULL,
+    quantity bigint
+);
+
+CREATE TABLE public.products (
+    productid text NOT NULL,
+    name text
+);
+
+ALTER TABLE ONLY public.cart
+    ADD CONSTRAINT cart_pkey PRIMARY KEY (productid, userid);
+
+ALTER TABLE ONLY public.products
+    ADD CONSTRAINT products_pkey PRIMARY KEY (productid);
+
+ALTER TABLE ONLY public.cart
+    ADD CONSTRAINT cart_productid_fkey FOREIGN KEY (productid) REFERENCES public.products(productid);
+`
+
+// convertTestDump converts the schema of testDump with opts.
+func convertTestDump(t *testing.T, opts SchemaOptions) (*Migration, error) {
+	return convertDump(t, testDump, opts)
+}
+
+// convertDump converts the schema of pg_dump output dump with opts.
+func convertDump(t *testing.T, dump string, opts SchemaOptions) (*Migration, error) {
+	f, err := ioutil.TempFile("", "dump")
+	assert.Nil(t, err)
+	defer os.Remove(f.Name())
+	defer f.Close()
+	_, err = f.WriteString(dump)
+	assert.Nil(t, err)
+	_, err = f.Seek(0, 0)
+	assert.Nil(t, err)
+	out, err := os.OpenFile(os.DevNull, os.O_WRONLY, 0)
+	assert.Nil(t, err)
+	defer out.Close()
+	opts.Driver = PGDump
+	opts.Dump = f
+	opts.Out = out
+	return ConvertSchema(opts)
+}
+
+func TestConvertSchema(t *testing.T) {
+	m, err := convertTestDump(t, SchemaOptions{})
+	assert.Nil(t, err)
+	assert.Equal(t, []string{
+		"CREATE TABLE `cart` (\n    `productid` STRING(MAX) NOT NULL,\n    `userid` STRING(MAX) NOT NULL,\n    `quantity` INT64 \n) PRIMARY KEY (`productid`, `userid`)",
+		"CREATE TABLE `products` (\n    `productid` STRING(MAX) NOT NULL,\n    `name` STRING(MAX) \n) PRIMARY KEY (`productid`)",
+	}, m.DDL())
+	assert.Equal(t, []string{
+		"ALTER TABLE `cart` ADD CONSTRAINT `cart_productid_fkey` FOREIGN KEY (`productid`) REFERENCES `products` (`productid`)",
+	}, m.ForeignKeyDDL())
+	assert.NotEqual(t, int64(0), m.Conv.Seed)
+}
+
+func TestConvertSchema_Options(t *testing.T) {
+	m, err := convertTestDump(t, SchemaOptions{
+		DropColumns:     []string{"cart.quantity"},
+		ForeignKeyNames: "FK_{table}_{cols}",
+		Seed:            42,
+	})
+	assert.Nil(t, err)
+	assert.Equal(t, []string{
+		"CREATE TABLE `cart` (\n    `productid` STRING(MAX) NOT NULL,\n    `userid` STRING(MAX) NOT NULL \n) PRIMARY KEY (`productid`, `userid`)",
+		"CREATE TABLE `products` (\n    `productid` STRING(MAX) NOT NULL,\n    `name` STRING(MAX) \n) PRIMARY KEY (`productid`)",
+	}, m.DDL())
+	assert.Equal(t, []string{
+		"ALTER TABLE `cart` ADD CONSTRAINT `FK_cart_productid` FOREIGN KEY (`productid`) REFERENCES `products` (`productid`)",
+	}, m.ForeignKeyDDL())
+	assert.Equal(t, int64(42), m.Conv.Seed)
+}
+
+func TestConvertSchema_Errors(t *testing.T) {
+	_, err := ConvertSchema(SchemaOptions{Driver: PGDump})
+	assert.NotNil(t, err, "no dump file")
+	_, err = convertTestDump(t, SchemaOptions{DropColumns: []string{"cart.missing"}})
+	assert.NotNil(t, err, "unknown column")
+	_, err = convertTestDump(t, SchemaOptions{ForeignKeyNames: "FK_{unknown}"})
+	assert.NotNil(t, err, "bad foreign key name template")
+}
+
+func TestConvertSchema_Masks(t *testing.T) {
+	m, err := convertTestDump(t, SchemaOptions{Policies: Policies{Masks: map[string]internal.MaskRule{"products.name": {Method: internal.MaskRedact}}}})
+	assert.Nil(t, err)
+	assert.Equal(t, internal.MaskRedact, m.Conv.Policies.Masks["products.name"].Method)
+	// Invalid masks are rejected before any data is converted.
+	for _, masks := range []map[string]internal.MaskRule{
+		{"cart.quantity": {Method: internal.MaskRedact}},
+		{"cart.productid": {Method: internal.MaskNull}},
+		{"products.missing": {Method: internal.MaskHash}},
+	} {
+		_, err := convertTestDump(t, SchemaOptions{Policies: Policies{Masks: masks}})
+		assert.NotNil(t, err, "%v", masks)
+	}
+}
+
+func TestConvertSchema_ColumnlessTables(t *testing.T) {
+	m, err := convertDump(t, testDump+"\nCREATE TABLE public.placeholder ();\n", SchemaOptions{})
+	assert.Nil(t, err)
+	assert.Equal(t, map[string]string{"placeholder": "it has no columns"}, m.Conv.SkippedTables)
+	assert.Equal(t, 2, len(m.DDL()))
+}
+
+func TestConvertSchema_DateRules(t *testing.T) {
+	_, err := convertTestDump(t, SchemaOptions{Policies: Policies{DateRules: []internal.DateRule{{Match: "9999-12-31"}}}})
+	assert.Nil(t, err)
+	// Invalid date rules are rejected before any data is converted.
+	for _, r := range []internal.DateRule{
+		{Match: "31/12/9999"},
+		{Match: "9999-12-31", Columns: []string{"cart.productid"}},
+	} {
+		_, err := convertTestDump(t, SchemaOptions{Policies: Policies{DateRules: []internal.DateRule{r}}})
+		assert.NotNil(t, err, r.String())
+	}
+}
+
+func TestConvertSchema_ValueDictionaries(t *testing.T) {
+	l, err := internal.NewCodeList(map[string][]string{"P1": nil}, true, "")
+	assert.Nil(t, err)
+	internal.RegisterValueDictionary("engine-test-products", l)
+	_, err = convertTestDump(t, SchemaOptions{Policies: Policies{ValueDictionaries: []internal.ValueDictionaryRule{
+		{Columns: []string{"products.productid", "cart.productid"}, Dictionary: "engine-test-products"},
+	}}})
+	assert.Nil(t, err)
+	// Invalid rules are rejected before any data is converted.
+	for _, r := range []internal.ValueDictionaryRule{
+		{Columns: []string{"products.productid"}, Dictionary: "missing"},
+		{Columns: []string{"cart.quantity"}, Dictionary: "engine-test-products"},
+		{Columns: []string{"products.productid"}, Dictionary: "engine-test-products", Unknown: "drop"},
+	} {
+		_, err := convertTestDump(t, SchemaOptions{Policies: Policies{ValueDictionaries: []internal.ValueDictionaryRule{r}}})
+		assert.NotNil(t, err, r.String())
+	}
+}
@@ -0,0 +1,97 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Program embed shows how to run a migration with the engine package.
+// It converts the schema of a pg_dump file and prints the Spanner DDL.
+// With -project, -instance and -dbname, it also migrates the data. The
+// progress of the migration is rendered from progress events:
+//
+//	go run ./examples/embed -dump examples/cart.pg_dump
+//	go run ./examples/embed -dump examples/cart.pg_dump -project p -instance i -dbname cart
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+
+	"github.com/cloudspannerecosystem/harbourbridge/engine"
+)
+
+func main() {
+	dump := flag.String("dump", "examples/cart.pg_dump", "pg_dump file to migrate")
+	project := flag.String("project", "", "Google Cloud project")
+	instance := flag.String("instance", "", "Spanner instance")
+	dbName := flag.String("dbname", "", "Spanner database to create")
+	flag.Parse()
+
+	f, err := os.Open(*dump)
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer f.Close()
+	events := make(chan engine.ProgressEvent, 100)
+	done := make(chan bool)
+	go func() {
+		for e := range events {
+			switch e.Kind {
+			case engine.TaskEvent:
+				fmt.Fprintf(os.Stderr, "%s: %d%%\n", e.Detail, e.Percent)
+			case engine.TableFinishEvent:
+				fmt.Fprintf(os.Stderr, "Table %s: %d rows converted, %d bad\n", e.Table, e.GoodRows, e.BadRows)
+			case engine.CheckpointEvent:
+				fmt.Fprintf(os.Stderr, "Checkpoint: %s\n", e.Detail)
+			}
+		}
+		close(done)
+	}()
+	defer func() {
+		close(events)
+		<-done
+	}()
+	progress := func(e engine.ProgressEvent) {
+		select {
+		case events <- e:
+		default:
+			// Don't block the migration when events aren't printed fast
+			// enough.
+		}
+	}
+	m, err := engine.ConvertSchema(engine.SchemaOptions{Driver: engine.PGDump, Dump: f, Out: os.Stderr, Progress: progress})
+	if err != nil {
+		log.Fatal(err)
+	}
+	for _, stmt := range append(m.DDL(), m.ForeignKeyDDL()...) {
+		fmt.Printf("%s;\n", stmt)
+	}
+	if *project == "" || *instance == "" || *dbName == "" {
+		return
+	}
+
+	opts := engine.SpannerOptions{TransactionTag: "embed-example"}
+	db, err := m.CreateDatabase(*project, *instance, *dbName, opts)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if err := m.LoadData(db, opts); err != nil {
+		log.Fatal(err)
+	}
+	if err := m.AddForeignKeys(*project, *instance, *dbName); err != nil {
+		log.Fatal(err)
+	}
+	if err := m.WriteReport(os.Stdout); err != nil {
+		log.Fatal(err)
+	}
+}
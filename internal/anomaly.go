@@ -0,0 +1,148 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package internal
+
+import (
+	"bufio"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/cloudspannerecosystem/harbourbridge/spanner/ddl"
+)
+
+// AnomalyKind is a kind of source data that will cause problems
+// during data conversion.
+type AnomalyKind int
+
+const (
+	InvalidUTF8       AnomalyKind = iota // Strings that aren't valid UTF-8.
+	NumericOutOfRange                    // Numerics that don't fit Spanner's NUMERIC (29 digits before the decimal point, 9 after).
+	InvalidDate                          // Zero dates, or dates outside Spanner's range (0001-01-01 to 9999-12-31).
+	OrphanedRow                          // Rows whose foreign key doesn't match a row in the referenced table.
+)
+
+func (k AnomalyKind) String() string {
+	switch k {
+	case InvalidUTF8:
+		return "invalid UTF-8"
+	case NumericOutOfRange:
+		return "numeric out of range"
+	case InvalidDate:
+		return "invalid date"
+	case OrphanedRow:
+		return "orphaned rows"
+	}
+	return fmt.Sprintf("anomaly %d", int(k))
+}
+
+// Anomaly records the number of source rows with a given kind of
+// problem. For OrphanedRow, Column is the name of the foreign key.
+type Anomaly struct {
+	Table  string
+	Column string
+	Kind   AnomalyKind
+	Count  int64
+}
+
+// AnomalyColumns returns the columns of srcTable that should be scanned
+// for each kind of anomaly, based on the Spanner type they are mapped
+// to. Array columns aren't scanned.
+func AnomalyColumns(conv *Conv, srcTable string) map[AnomalyKind][]string {
+	m := make(map[AnomalyKind][]string)
+	srcSchema, ok := conv.SrcSchema[srcTable]
+	if !ok {
+		return m
+	}
+	spTable, err := GetSpannerTable(conv, srcTable)
+	if err != nil {
+		return m
+	}
+	spSchema, ok := conv.DataSchema(spTable)
+	if !ok {
+		return m
+	}
+	for _, srcCol := range srcSchema.ColNames {
+		if conv.IsDroppedCol(srcTable, srcCol) {
+			continue
+		}
+		spCol, err := GetSpannerCol(conv, srcTable, srcCol, false)
+		if err != nil {
+			continue
+		}
+		cd, ok := spSchema.ColDefs[spCol]
+		if !ok || cd.T.IsArray {
+			continue
+		}
+		switch cd.T.Name {
+		case ddl.String:
+			m[InvalidUTF8] = append(m[InvalidUTF8], srcCol)
+		case ddl.Numeric:
+			m[NumericOutOfRange] = append(m[NumericOutOfRange], srcCol)
+		case ddl.Date, ddl.Timestamp:
+			m[InvalidDate] = append(m[InvalidDate], srcCol)
+		}
+	}
+	return m
+}
+
+// WriteAnomalies writes a report of anomalies to w, grouped by table.
+func WriteAnomalies(anomalies []Anomaly, w *bufio.Writer) {
+	if len(anomalies) == 0 {
+		w.WriteString("No anomalies found in source data.\n")
+		return
+	}
+	byTable := make(map[string][]Anomaly)
+	var tables []string
+	for _, a := range anomalies {
+		if _, ok := byTable[a.Table]; !ok {
+			tables = append(tables, a.Table)
+		}
+		byTable[a.Table] = append(byTable[a.Table], a)
+	}
+	sort.Strings(tables)
+	justifyLines(w, "The following source data will cause problems during data conversion. "+
+		"Consider fixing it in the source database before migrating.", 80, 0)
+	w.WriteString("\n\n")
+	for _, t := range tables {
+		writeHeading(w, fmt.Sprintf("Table %s", t))
+		for _, a := range byTable[t] {
+			what := "column " + a.Column
+			if a.Kind == OrphanedRow {
+				what = "foreign key " + a.Column
+			}
+			fmt.Fprintf(w, "  %s: %d rows with %s\n", what, a.Count, a.Kind)
+		}
+		w.WriteString("\n")
+	}
+}
+
+// AnomalySummary returns a one-line summary of anomalies.
+func AnomalySummary(anomalies []Anomaly) string {
+	counts := make(map[AnomalyKind]int64)
+	for _, a := range anomalies {
+		counts[a.Kind] += a.Count
+	}
+	var l []string
+	for _, k := range []AnomalyKind{InvalidUTF8, NumericOutOfRange, InvalidDate, OrphanedRow} {
+		if counts[k] > 0 {
+			l = append(l, fmt.Sprintf("%d %s", counts[k], k))
+		}
+	}
+	if len(l) == 0 {
+		return "Source data scan found no anomalies.\n"
+	}
+	return fmt.Sprintf("Source data scan found anomalies: %s.\n", strings.Join(l, ", "))
+}
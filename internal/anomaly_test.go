@@ -0,0 +1,56 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package internal
+
+import (
+	"testing"
+
+	"github.com/cloudspannerecosystem/harbourbridge/schema"
+	"github.com/cloudspannerecosystem/harbourbridge/spanner/ddl"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAnomalyColumns(t *testing.T) {
+	conv := MakeConv()
+	conv.SrcSchema["t"] = schema.Table{Name: "t", ColNames: []string{"a", "b", "c", "d", "e"}}
+	conv.ToSpanner["t"] = NameAndCols{Name: "t", Cols: map[string]string{"a": "a", "b": "b", "c": "c", "d": "d", "e": "e"}}
+	conv.ToSource["t"] = NameAndCols{Name: "t", Cols: map[string]string{"a": "a", "b": "b", "c": "c", "d": "d", "e": "e"}}
+	conv.SpSchema["t"] = ddl.CreateTable{
+		Name:     "t",
+		ColNames: []string{"a", "b", "c", "d", "e"},
+		ColDefs: map[string]ddl.ColumnDef{
+			"a": ddl.ColumnDef{Name: "a", T: ddl.Type{Name: ddl.Int64}},
+			"b": ddl.ColumnDef{Name: "b", T: ddl.Type{Name: ddl.String, Len: ddl.MaxLength}},
+			"c": ddl.ColumnDef{Name: "c", T: ddl.Type{Name: ddl.Numeric}},
+			"d": ddl.ColumnDef{Name: "d", T: ddl.Type{Name: ddl.Timestamp}},
+			"e": ddl.ColumnDef{Name: "e", T: ddl.Type{Name: ddl.String, Len: ddl.MaxLength, IsArray: true}},
+		},
+	}
+	assert.Equal(t, map[AnomalyKind][]string{
+		InvalidUTF8:       []string{"b"},
+		NumericOutOfRange: []string{"c"},
+		InvalidDate:       []string{"d"},
+	}, AnomalyColumns(conv, "t"))
+	assert.Equal(t, map[AnomalyKind][]string{}, AnomalyColumns(conv, "missing"))
+}
+
+func TestAnomalySummary(t *testing.T) {
+	assert.Equal(t, "Source data scan found no anomalies.\n", AnomalySummary(nil))
+	assert.Equal(t, "Source data scan found anomalies: 3 invalid UTF-8, 4 orphaned rows.\n", AnomalySummary([]Anomaly{
+		{Table: "t1", Column: "fk", Kind: OrphanedRow, Count: 4},
+		{Table: "t1", Column: "a", Kind: InvalidUTF8, Count: 1},
+		{Table: "t2", Column: "b", Kind: InvalidUTF8, Count: 2},
+	}))
+}
@@ -0,0 +1,175 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package internal
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/cloudspannerecosystem/harbourbridge/spanner/ddl"
+)
+
+// boolWords are the words of column names that suggest the column holds
+// flags (see LooksBoolean).
+var boolWords = map[string]bool{
+	"active": true, "approved": true, "archived": true, "deleted": true,
+	"disabled": true, "enabled": true, "flag": true, "hidden": true,
+	"locked": true, "published": true, "verified": true, "visible": true,
+	"yn": true,
+}
+
+// boolPrefixes are the first words of column names, such as is_active or
+// hasChildren, that suggest the column holds flags.
+var boolPrefixes = map[string]bool{"allow": true, "can": true, "has": true, "is": true, "should": true}
+
+// LooksBoolean returns true if col is the name of a column that is likely
+// to hold flags, such as is_active, deleted or newsletter_flag.
+func LooksBoolean(col string) bool {
+	words := nameWords(col)
+	if len(words) > 1 && boolPrefixes[words[0]] {
+		return true
+	}
+	for _, w := range words {
+		if boolWords[w] {
+			return true
+		}
+	}
+	return false
+}
+
+// ParseBoolColumns parses a comma-separated list of entries of the form
+// 'table.column=bool' (convert the STRING Spanner column converted from a
+// MySQL CHAR(1) column to BOOL) or 'table.column=string' (keep it),
+// optionally starting with "auto" to also convert the columns suggested by
+// SuggestBoolCols. For example, "auto,users.grade=string". See
+// Remodel.Bools.
+func ParseBoolColumns(s string) (bool, map[string]bool, error) {
+	return parseColumnTypes(s, "boolean", "bool", "string")
+}
+
+// isCharFlag returns the source table and column of Spanner column spCol
+// of spTable, and true if it is a STRING column converted from a MySQL
+// CHAR(1) column.
+func (conv *Conv) isCharFlag(spTable, spCol string) (string, string, bool) {
+	src, ok := conv.ToSource[spTable]
+	if !ok {
+		return "", "", false
+	}
+	srcCol, ok := src.Cols[spCol]
+	if !ok {
+		return "", "", false
+	}
+	ty := conv.SrcSchema[src.Name].ColDefs[srcCol].Type
+	if ty.Name != "char" || len(ty.Mods) != 1 || ty.Mods[0] != 1 || len(ty.ArrayBounds) > 0 {
+		return "", "", false
+	}
+	spType := conv.SpSchema[spTable].ColDefs[spCol].T
+	return src.Name, srcCol, spType.Name == ddl.String && !spType.IsArray
+}
+
+// SuggestBoolCols returns the STRING columns (as table.column, sorted)
+// converted from MySQL CHAR(1) columns whose name suggests they hold flags
+// (see LooksBoolean), such as 'Y'/'N' or '0'/'1'.
+func (conv *Conv) SuggestBoolCols() []string {
+	var l []string
+	for t, ct := range conv.SpSchema {
+		for _, c := range ct.ColNames {
+			if _, _, ok := conv.isCharFlag(t, c); ok && LooksBoolean(c) && conv.canChangeColType(t, c) {
+				l = append(l, t+"."+c)
+			}
+		}
+	}
+	sort.Strings(l)
+	return l
+}
+
+// applyBoolCols converts Spanner columns converted from MySQL CHAR(1)
+// columns to BOOL (if cols maps them to true), or back to their original
+// type (if cols maps them to false and they were converted), and records
+// the conversions in conv.BoolCols. If auto is set, the columns suggested
+// by SuggestBoolCols are also converted, unless cols maps them to false.
+// During data conversion, 'Y', 'T' and '1' are converted to true, and
+// 'N', 'F' and '0' to false (in either case); other values are errors.
+func (conv *Conv) applyBoolCols(auto bool, cols map[string]bool) error {
+	all := make(map[string]bool)
+	if auto {
+		for _, c := range conv.SuggestBoolCols() {
+			all[c] = true
+		}
+	}
+	for c, b := range cols {
+		all[c] = b
+	}
+	var names []string
+	for c := range all {
+		names = append(names, c)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		i := strings.LastIndex(name, ".")
+		if i < 0 {
+			return fmt.Errorf("boolean column %s must be given as table.column", name)
+		}
+		if err := conv.setBoolCol(name[:i], name[i+1:], all[name]); err != nil {
+			return fmt.Errorf("can't convert column %s: %w", name, err)
+		}
+	}
+	return nil
+}
+
+func (conv *Conv) setBoolCol(spTable, spCol string, b bool) error {
+	ct, ok := conv.SpSchema[spTable]
+	if !ok {
+		return fmt.Errorf("unknown table")
+	}
+	cd, ok := ct.ColDefs[spCol]
+	if !ok {
+		return fmt.Errorf("unknown column")
+	}
+	original, converted := conv.BoolCols[spTable+"."+spCol]
+	_, _, isFlag := conv.isCharFlag(spTable, spCol)
+	switch {
+	case b && converted, !b && !converted && isFlag:
+		return nil // Already applied.
+	case !b && converted:
+		cd.T = original
+		delete(conv.BoolCols, spTable+"."+spCol)
+	case !isFlag:
+		return fmt.Errorf("it isn't a CHAR(1) column of the source database")
+	case !conv.canChangeColType(spTable, spCol):
+		return fmt.Errorf("key columns and columns used by foreign keys can't be converted")
+	default:
+		if conv.BoolCols == nil {
+			conv.BoolCols = make(map[string]ddl.Type)
+		}
+		conv.BoolCols[spTable+"."+spCol] = cd.T
+		cd.T = ddl.Type{Name: ddl.Bool}
+	}
+	ct.ColDefs[spCol] = cd
+	return nil
+}
+
+// ParseCharFlag converts a value of a CHAR(1) column converted to BOOL
+// (see Remodel.Bools).
+func ParseCharFlag(val string) (bool, error) {
+	switch strings.ToUpper(val) {
+	case "Y", "T", "1":
+		return true, nil
+	case "N", "F", "0":
+		return false, nil
+	}
+	return false, fmt.Errorf("can't convert %q to bool (expected Y/N, T/F or 1/0)", val)
+}
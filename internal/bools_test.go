@@ -0,0 +1,108 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package internal
+
+import (
+	"testing"
+
+	"github.com/cloudspannerecosystem/harbourbridge/schema"
+	"github.com/cloudspannerecosystem/harbourbridge/spanner/ddl"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLooksBoolean(t *testing.T) {
+	for col, want := range map[string]bool{
+		"is_active":       true,
+		"hasChildren":     true,
+		"deleted":         true,
+		"newsletter_flag": true,
+		"is":              false,
+		"grade":           false,
+		"island":          false,
+	} {
+		assert.Equal(t, want, LooksBoolean(col), col)
+	}
+}
+
+func TestParseBoolColumns(t *testing.T) {
+	auto, cols, err := ParseBoolColumns("auto,users.grade=string,users.vip=bool")
+	assert.Nil(t, err)
+	assert.True(t, auto)
+	assert.Equal(t, map[string]bool{"users.grade": false, "users.vip": true}, cols)
+	_, _, err = ParseBoolColumns("users.vip=numeric")
+	assert.NotNil(t, err)
+}
+
+func TestParseCharFlag(t *testing.T) {
+	for val, want := range map[string]bool{"Y": true, "y": true, "T": true, "1": true, "N": false, "f": false, "0": false} {
+		b, err := ParseCharFlag(val)
+		assert.Nil(t, err, val)
+		assert.Equal(t, want, b, val)
+	}
+	_, err := ParseCharFlag("X")
+	assert.NotNil(t, err)
+}
+
+func TestBoolCols(t *testing.T) {
+	conv := MakeConv()
+	char1 := schema.Type{Name: "char", Mods: []int64{1}}
+	conv.SrcSchema["users"] = schema.Table{
+		Name:     "users",
+		ColNames: []string{"id", "is_active", "grade", "vip", "deleted"},
+		ColDefs: map[string]schema.Column{
+			"id":        schema.Column{Name: "id", Type: schema.Type{Name: "int"}},
+			"is_active": schema.Column{Name: "is_active", Type: char1},
+			"grade":     schema.Column{Name: "grade", Type: char1},
+			"vip":       schema.Column{Name: "vip", Type: char1},
+			"deleted":   schema.Column{Name: "deleted", Type: schema.Type{Name: "varchar", Mods: []int64{1}}},
+		},
+	}
+	str1 := ddl.Type{Name: ddl.String, Len: 1}
+	conv.SpSchema["users"] = ddl.CreateTable{
+		Name:     "users",
+		ColNames: []string{"id", "is_active", "grade", "vip", "deleted"},
+		ColDefs: map[string]ddl.ColumnDef{
+			"id":        ddl.ColumnDef{Name: "id", T: ddl.Type{Name: ddl.Int64}},
+			"is_active": ddl.ColumnDef{Name: "is_active", T: str1, NotNull: true},
+			"grade":     ddl.ColumnDef{Name: "grade", T: str1},
+			"vip":       ddl.ColumnDef{Name: "vip", T: str1},
+			"deleted":   ddl.ColumnDef{Name: "deleted", T: str1},
+		},
+		Pks: []ddl.IndexKey{ddl.IndexKey{Col: "id"}},
+	}
+	conv.ToSpanner["users"] = NameAndCols{Name: "users", Cols: map[string]string{"id": "id", "is_active": "is_active", "grade": "grade", "vip": "vip", "deleted": "deleted"}}
+	conv.ToSource["users"] = NameAndCols{Name: "users", Cols: map[string]string{"id": "id", "is_active": "is_active", "grade": "grade", "vip": "vip", "deleted": "deleted"}}
+	// deleted isn't a CHAR(1) column.
+	assert.Equal(t, []string{"users.is_active"}, conv.SuggestBoolCols())
+
+	r := Remodel{AutoBools: true, Bools: map[string]bool{"users.vip": true}}
+	assert.Nil(t, conv.ApplyRemodel(r))
+	ct := conv.SpSchema["users"]
+	assert.Equal(t, ddl.ColumnDef{Name: "is_active", T: ddl.Type{Name: ddl.Bool}, NotNull: true}, ct.ColDefs["is_active"])
+	assert.Equal(t, ddl.Type{Name: ddl.Bool}, ct.ColDefs["vip"].T)
+	assert.Equal(t, str1, ct.ColDefs["grade"].T)
+	assert.Equal(t, map[string]ddl.Type{"users.is_active": str1, "users.vip": str1}, conv.BoolCols)
+	// Already applied.
+	assert.Nil(t, conv.ApplyRemodel(r))
+	assert.Equal(t, 2, len(conv.BoolCols))
+
+	assert.Nil(t, conv.ApplyRemodel(Remodel{Bools: map[string]bool{"users.vip": false}}))
+	assert.Equal(t, str1, conv.SpSchema["users"].ColDefs["vip"].T)
+	assert.Equal(t, map[string]ddl.Type{"users.is_active": str1}, conv.BoolCols)
+
+	for _, c := range []string{"users.id", "users.deleted", "users.missing"} {
+		assert.NotNil(t, conv.ApplyRemodel(Remodel{Bools: map[string]bool{c: true}}), c)
+	}
+}
@@ -0,0 +1,124 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package internal
+
+import (
+	"bufio"
+	"fmt"
+	"strings"
+
+	"github.com/cloudspannerecosystem/harbourbridge/schema"
+)
+
+// Capabilities describes what HarbourBridge supports for a source driver
+// and Spanner target. Type mappings and dropped constructs aren't
+// maintained by hand: they are found by converting sample schemas with
+// the conversion code itself (see MapTypes and DroppedConstructs).
+type Capabilities struct {
+	Driver   string        `json:"driver"`
+	Target   string        `json:"target"`
+	Features string        `json:"features"`
+	Types    []TypeMapping `json:"types"`
+	// Dropped are the source constructs (other than tables and their
+	// columns) that aren't migrated to Spanner.
+	Dropped []string `json:"dropped"`
+	// Flags are the command line flags that apply to the driver.
+	Flags []string `json:"flags"`
+}
+
+// TypeMapping describes the mapping of a source type to Spanner.
+type TypeMapping struct {
+	Source  string   `json:"source"`
+	Spanner string   `json:"spanner"`
+	Issues  []string `json:"issues,omitempty"` // Issues reported for columns of this type.
+}
+
+// Construct is a source construct, given as a dump snippet using it.
+type Construct struct {
+	Name string
+	Dump string
+}
+
+// MapTypes converts a table with a column of each of types to Spanner
+// using toDDL (a source's schema conversion), and returns how each type
+// is mapped for the target and features of conv.
+func MapTypes(conv *Conv, types []schema.Type, toDDL func(conv *Conv) error) ([]TypeMapping, error) {
+	t := schema.Table{Name: "capabilities", ColDefs: make(map[string]schema.Column)}
+	for i, ty := range types {
+		c := fmt.Sprintf("c%d", i)
+		t.ColNames = append(t.ColNames, c)
+		t.ColDefs[c] = schema.Column{Name: c, Type: ty}
+	}
+	conv.SetSrcTable(t)
+	if err := toDDL(conv); err != nil {
+		return nil, err
+	}
+	sp, ok := conv.ToSpanner[t.Name]
+	if !ok {
+		return nil, fmt.Errorf("can't convert table %s", t.Name)
+	}
+	var l []TypeMapping
+	for i, ty := range types {
+		c := fmt.Sprintf("c%d", i)
+		spTy := conv.SpSchema[sp.Name].ColDefs[sp.Cols[c]].T
+		m := TypeMapping{Source: ty.Print(), Spanner: spTy.PrintColumnDefType()}
+		if conv.TargetDb == "experimental_postgres" { // conversion.TARGET_EXPERIMENTAL_POSTGRES, which would be an import cycle.
+			m.Spanner = spTy.PrintPGColumnDefType()
+		}
+		for _, issue := range conv.Issues[t.Name][c] {
+			m.Issues = append(m.Issues, IssueDB[issue].Brief)
+		}
+		l = append(l, m)
+	}
+	return l, nil
+}
+
+// DroppedConstructs processes each of constructs with processDump (a
+// source's dump processing) in schema mode, using a new Conv made by
+// newConv. It returns the names of the constructs that are dropped: their
+// statements are skipped or fail, they set column properties that aren't
+// converted (see schema.Ignored), or they define sequences that the target
+// doesn't support.
+func DroppedConstructs(newConv func() *Conv, constructs []Construct, processDump func(conv *Conv, r *Reader) error) []string {
+	dropped := []string{}
+	for _, c := range constructs {
+		conv := newConv()
+		conv.SetSchemaMode()
+		conv.SetDataSink(nil)
+		err := processDump(conv, NewReader(bufio.NewReader(strings.NewReader(c.Dump)), nil))
+		conv.AddSequences()
+		if err != nil || conv.Unexpecteds() > 0 || len(conv.Sequences) < len(conv.SrcSequences) || dropsConstruct(conv) {
+			dropped = append(dropped, c.Name)
+		}
+	}
+	return dropped
+}
+
+func dropsConstruct(conv *Conv) bool {
+	for _, s := range conv.Stats.Statement {
+		if s.Skip > 0 || s.Error > 0 {
+			return true
+		}
+	}
+	for _, t := range conv.SrcSchema {
+		for _, c := range t.ColDefs {
+			i := c.Ignored
+			if i.Check || i.Identity || i.Default || i.Exclusion || i.ForeignKey {
+				return true
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,187 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package internal
+
+import (
+	"fmt"
+	"strings"
+)
+
+// CharPaddingPolicy specifies how data conversion handles the trailing
+// blanks of PostgreSQL's blank-padded CHAR(n) (bpchar) columns. Their
+// values are padded to n characters, and compare equal regardless of
+// trailing blanks in PostgreSQL, but are STRING values in Spanner, where
+// 'abc  ' and 'abc' differ, so that padded values no longer match the
+// trimmed values applications use.
+type CharPaddingPolicy int
+
+const (
+	// KeepCharPadding writes values with their padding.
+	KeepCharPadding CharPaddingPolicy = iota
+	// TrimCharPadding removes the trailing blanks of values. It is
+	// applied before the empty string policy, so blank values become
+	// empty strings.
+	TrimCharPadding
+)
+
+var charPaddingPolicyNames = map[CharPaddingPolicy]string{
+	KeepCharPadding: "keep",
+	TrimCharPadding: "trim",
+}
+
+func (p CharPaddingPolicy) String() string {
+	if s, ok := charPaddingPolicyNames[p]; ok {
+		return s
+	}
+	return fmt.Sprintf("CharPaddingPolicy(%d)", int(p))
+}
+
+// ParseCharPaddingPolicy maps a policy name (as used on the command line)
+// to a CharPaddingPolicy.
+func ParseCharPaddingPolicy(s string) (CharPaddingPolicy, error) {
+	for p, name := range charPaddingPolicyNames {
+		if strings.ToLower(s) == name {
+			return p, nil
+		}
+	}
+	return KeepCharPadding, fmt.Errorf("unknown CHAR padding policy %q (accepted values are \"keep\" and \"trim\")", s)
+}
+
+// ParseCharPaddingPolicies parses a comma-separated list of CHAR padding
+// policies. An entry of the form 'table.column=policy' sets the policy for
+// a Spanner column; an entry without '=' sets the policy for all other
+// columns. For example, "trim,accounts.code=keep".
+func ParseCharPaddingPolicies(s string) (CharPaddingPolicy, map[string]CharPaddingPolicy, error) {
+	policy := KeepCharPadding
+	cols := make(map[string]CharPaddingPolicy)
+	for _, e := range strings.Split(s, ",") {
+		e = strings.TrimSpace(e)
+		if e == "" {
+			continue
+		}
+		i := strings.LastIndex(e, "=")
+		if i < 0 {
+			p, err := ParseCharPaddingPolicy(e)
+			if err != nil {
+				return KeepCharPadding, nil, err
+			}
+			policy = p
+			continue
+		}
+		col := e[:i]
+		if !strings.Contains(col, ".") {
+			return KeepCharPadding, nil, fmt.Errorf("bad CHAR padding policy %q: column must be given as table.column", e)
+		}
+		p, err := ParseCharPaddingPolicy(e[i+1:])
+		if err != nil {
+			return KeepCharPadding, nil, err
+		}
+		cols[col] = p
+	}
+	return policy, cols, nil
+}
+
+// charPaddingPolicy returns the CHAR padding policy for a Spanner column.
+func (conv *Conv) charPaddingPolicy(spTable, spCol string) CharPaddingPolicy {
+	if p, ok := conv.Policies.CharPaddingColumns[spTable+"."+spCol]; ok {
+		return p
+	}
+	return conv.Policies.CharPadding
+}
+
+// isPaddedCharType returns true if ty is a blank-padded CHAR type of
+// PostgreSQL (bpchar is its internal name).
+func isPaddedCharType(ty string) bool {
+	switch strings.ToLower(ty) {
+	case "bpchar", "character":
+		return true
+	}
+	return false
+}
+
+// trimCharPadding removes the trailing blanks of the values of the
+// Spanner columns of srcTable whose source column is blank-padded CHAR,
+// as set by the CHAR padding policy.
+func (conv *Conv) trimCharPadding(srcTable, spTable string, spCols []string, spVals []interface{}) []interface{} {
+	conv.rowsMu.Lock()
+	if conv.charCols == nil {
+		// Find the Spanner columns of blank-padded columns, by source
+		// table. Array columns are left alone.
+		conv.charCols = make(map[string]map[string]bool)
+		for t, st := range conv.SrcSchema {
+			for c, cd := range st.ColDefs {
+				if !isPaddedCharType(cd.Type.Name) || len(cd.Type.ArrayBounds) > 0 {
+					continue
+				}
+				if spCol, ok := conv.ToSpanner[t].Cols[c]; ok {
+					if conv.charCols[t] == nil {
+						conv.charCols[t] = make(map[string]bool)
+					}
+					conv.charCols[t][spCol] = true
+				}
+			}
+		}
+	}
+	padded := conv.charCols[srcTable]
+	conv.rowsMu.Unlock()
+	if len(padded) == 0 {
+		return spVals
+	}
+	vals := spVals
+	copied := false
+	for i, c := range spCols {
+		s, ok := spVals[i].(string)
+		if !ok || !padded[c] || conv.charPaddingPolicy(spTable, c) != TrimCharPadding {
+			continue
+		}
+		t := strings.TrimRight(s, " ")
+		if t == s {
+			continue
+		}
+		if !copied {
+			// Don't modify the caller's slice.
+			vals = append([]interface{}{}, spVals...)
+			copied = true
+		}
+		vals[i] = t
+		conv.statsAddCharPadding(srcTable, c)
+	}
+	return vals
+}
+
+// statsAddCharPadding increments the count of values of 'spCol' of
+// 'srcTable' whose padding was trimmed. Only called in data mode (from
+// WriteRow).
+func (conv *Conv) statsAddCharPadding(srcTable, spCol string) {
+	conv.statsMu.Lock()
+	defer conv.statsMu.Unlock()
+	if conv.Stats.CharPadding == nil {
+		conv.Stats.CharPadding = make(map[string]map[string]int64)
+	}
+	if conv.Stats.CharPadding[srcTable] == nil {
+		conv.Stats.CharPadding[srcTable] = make(map[string]int64)
+	}
+	conv.Stats.CharPadding[srcTable][spCol]++
+}
+
+// buildCharPaddingBody lists, for each blank-padded CHAR column of
+// srcTable, how many values had their padding trimmed during data
+// conversion.
+func buildCharPaddingBody(conv *Conv, srcTable string) []tableReportBody {
+	return buildColCountBody(conv, srcTable, "Trimmed CHAR padding", conv.Stats.CharPadding[srcTable],
+		func(col string, n int64) string {
+			return fmt.Sprintf("Column '%s': %d values had their trailing blanks trimmed (policy: %s)", col, n, TrimCharPadding)
+		})
+}
@@ -0,0 +1,89 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package internal
+
+import (
+	"testing"
+
+	"github.com/cloudspannerecosystem/harbourbridge/schema"
+	"github.com/cloudspannerecosystem/harbourbridge/spanner/ddl"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWriteRow_CharPadding(t *testing.T) {
+	str := ddl.Type{Name: ddl.String, Len: 10}
+	for _, tc := range []struct {
+		name     string
+		policy   CharPaddingPolicy
+		cols     map[string]CharPaddingPolicy
+		empty    EmptyStringPolicy
+		wantVals []interface{}
+		stats    map[string]int64
+	}{
+		{"keep", KeepCharPadding, nil, KeepEmptyStrings, []interface{}{int64(1), "ab   ", "  ", "cd  "}, nil},
+		// Column c isn't blank-padded.
+		{"trim", TrimCharPadding, nil, KeepEmptyStrings, []interface{}{int64(1), "ab", "", "cd  "}, map[string]int64{"a": 1, "b": 1}},
+		{"per-column", KeepCharPadding, map[string]CharPaddingPolicy{"t.b": TrimCharPadding}, KeepEmptyStrings, []interface{}{int64(1), "ab   ", "", "cd  "}, map[string]int64{"b": 1}},
+		// Blank values are then written as NULL.
+		{"before empty strings", TrimCharPadding, nil, EmptyToNull, []interface{}{int64(1), "ab", nil, "cd  "}, map[string]int64{"a": 1, "b": 1}},
+	} {
+		conv := MakeConv()
+		conv.SrcSchema["src"] = schema.Table{
+			Name:     "src",
+			ColNames: []string{"k", "a", "b", "c"},
+			ColDefs: map[string]schema.Column{
+				"k": schema.Column{Name: "k", Type: schema.Type{Name: "bigint"}},
+				"a": schema.Column{Name: "a", Type: schema.Type{Name: "bpchar", Mods: []int64{5}}},
+				"b": schema.Column{Name: "b", Type: schema.Type{Name: "character", Mods: []int64{2}}},
+				"c": schema.Column{Name: "c", Type: schema.Type{Name: "varchar", Mods: []int64{10}}},
+			}}
+		conv.SpSchema["t"] = ddl.CreateTable{
+			Name:     "t",
+			ColNames: []string{"k", "a", "b", "c"},
+			ColDefs: map[string]ddl.ColumnDef{
+				"k": ddl.ColumnDef{Name: "k", T: ddl.Type{Name: ddl.Int64}, NotNull: true},
+				"a": ddl.ColumnDef{Name: "a", T: str},
+				"b": ddl.ColumnDef{Name: "b", T: str},
+				"c": ddl.ColumnDef{Name: "c", T: str},
+			},
+			Pks: []ddl.IndexKey{ddl.IndexKey{Col: "k"}}}
+		conv.ToSpanner["src"] = NameAndCols{Name: "t", Cols: map[string]string{"k": "k", "a": "a", "b": "b", "c": "c"}}
+		conv.Policies.CharPadding = tc.policy
+		conv.Policies.CharPaddingColumns = tc.cols
+		conv.Policies.EmptyStrings = tc.empty
+		conv.SetDataMode()
+		var gotVals []interface{}
+		conv.SetDataSink(func(table string, cols []string, vals []interface{}) {
+			gotVals = vals
+		})
+		vals := []interface{}{int64(1), "ab   ", "  ", "cd  "}
+		conv.WriteRow("src", "t", []string{"k", "a", "b", "c"}, vals)
+		assert.Equal(t, tc.wantVals, gotVals, tc.name)
+		assert.Equal(t, "ab   ", vals[1], tc.name) // The caller's values are unchanged.
+		assert.Equal(t, tc.stats, conv.Stats.CharPadding["src"], tc.name)
+	}
+}
+
+func TestParseCharPaddingPolicies(t *testing.T) {
+	p, cols, err := ParseCharPaddingPolicies("trim, accounts.code=keep")
+	assert.Nil(t, err)
+	assert.Equal(t, TrimCharPadding, p)
+	assert.Equal(t, map[string]CharPaddingPolicy{"accounts.code": KeepCharPadding}, cols)
+
+	for _, s := range []string{"strip", "code=trim", "accounts.code=pad"} {
+		_, _, err = ParseCharPaddingPolicies(s)
+		assert.NotNil(t, err, s)
+	}
+}
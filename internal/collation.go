@@ -0,0 +1,86 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package internal
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/cloudspannerecosystem/harbourbridge/schema"
+	"github.com/cloudspannerecosystem/harbourbridge/spanner/ddl"
+)
+
+// BinaryCollation returns true if collation orders strings by their
+// binary value (i.e. by code point for UTF-8 strings), as Spanner orders
+// STRING values. Other collations, such as MySQL's default
+// utf8mb4_0900_ai_ci or PostgreSQL's en_US.utf8, order strings by
+// linguistic rules, and some compare them ignoring case and accents.
+func BinaryCollation(collation string) bool {
+	switch c := strings.ToLower(collation); c {
+	case "binary", "c", "posix", "ucs_basic", "c.utf8", "c.utf-8", "pg_c_utf8":
+		return true
+	default:
+		return strings.HasSuffix(c, "_bin")
+	}
+}
+
+// caseInsensitive returns true if collation compares strings ignoring
+// case (MySQL's _ci collations).
+func caseInsensitive(collation string) bool {
+	return strings.Contains(strings.ToLower(collation), "_ci")
+}
+
+// collationWarnings describes how the order (and for unique keys, the
+// uniqueness) of the primary key and indexes of srcTable change in
+// Spanner, for keys with STRING columns whose source collation isn't
+// binary. Columns whose collation is unknown are skipped.
+func (conv *Conv) collationWarnings(srcTable, spTable string) []string {
+	srcSchema := conv.SrcSchema[srcTable]
+	spSchema := conv.SpSchema[spTable]
+	check := func(what string, keys []schema.Key, unique bool) []string {
+		var cols, ci []string
+		for _, k := range keys {
+			c := srcSchema.ColDefs[k.Column]
+			spCol, ok := conv.ToSpanner[srcTable].Cols[k.Column]
+			if !ok || c.Collation == "" || BinaryCollation(c.Collation) || spSchema.ColDefs[spCol].T.Name != ddl.String {
+				continue
+			}
+			cols = append(cols, fmt.Sprintf("column '%s' (%s)", k.Column, c.Collation))
+			if caseInsensitive(c.Collation) {
+				ci = append(ci, spCol)
+			}
+		}
+		if len(cols) == 0 {
+			return nil
+		}
+		l := []string{fmt.Sprintf("%s orders %s by collation, but Spanner orders strings by their binary value: ORDER BY and range scans using it return rows in a different order (e.g. 'Zebra' sorts before 'apple')", what, strings.Join(cols, " and "))}
+		if unique && len(ci) > 0 {
+			l[0] += ", and values the collation treats as equal (e.g. 'a' and 'A') are distinct, so it no longer prevents them"
+		}
+		for _, c := range ci {
+			l = append(l, fmt.Sprintf("To keep the case-insensitive order of column '%s', consider indexing a normalized copy of it, e.g. %s_norm STRING(MAX) AS (LOWER(%s)) STORED", c, c, c))
+		}
+		return l
+	}
+	l := check("Primary key", srcSchema.PrimaryKeys, true)
+	for _, index := range srcSchema.Indexes {
+		what := fmt.Sprintf("Index '%s'", index.Name)
+		if index.Name == "" {
+			what = "Unique constraint"
+		}
+		l = append(l, check(what, index.Keys, index.Unique)...)
+	}
+	return l
+}
@@ -0,0 +1,78 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package internal
+
+import (
+	"testing"
+
+	"github.com/cloudspannerecosystem/harbourbridge/schema"
+	"github.com/cloudspannerecosystem/harbourbridge/spanner/ddl"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBinaryCollation(t *testing.T) {
+	for coll, want := range map[string]bool{
+		"utf8mb4_bin":        true,
+		"binary":             true,
+		"C":                  true,
+		"POSIX":              true,
+		"utf8mb4_0900_ai_ci": false,
+		"en_US.utf8":         false,
+		"latin1_swedish_ci":  false,
+	} {
+		assert.Equal(t, want, BinaryCollation(coll), coll)
+	}
+}
+
+func TestCollationWarnings(t *testing.T) {
+	conv := MakeConv()
+	conv.SrcSchema["users"] = schema.Table{
+		Name:     "users",
+		ColNames: []string{"id", "email", "name", "code"},
+		ColDefs: map[string]schema.Column{
+			"id":    schema.Column{Name: "id", Type: schema.Type{Name: "int"}},
+			"email": schema.Column{Name: "email", Type: schema.Type{Name: "varchar"}, Collation: "utf8mb4_0900_ai_ci"},
+			"name":  schema.Column{Name: "name", Type: schema.Type{Name: "varchar"}, Collation: "en_US.utf8"},
+			"code":  schema.Column{Name: "code", Type: schema.Type{Name: "varchar"}, Collation: "utf8mb4_bin"},
+		},
+		PrimaryKeys: []schema.Key{schema.Key{Column: "id"}},
+		Indexes: []schema.Index{
+			schema.Index{Name: "email_idx", Unique: true, Keys: []schema.Key{schema.Key{Column: "email"}}},
+			schema.Index{Name: "name_idx", Keys: []schema.Key{schema.Key{Column: "name"}}},
+			schema.Index{Name: "code_idx", Keys: []schema.Key{schema.Key{Column: "code"}}},
+		},
+	}
+	str := ddl.Type{Name: ddl.String, Len: ddl.MaxLength}
+	conv.SpSchema["users"] = ddl.CreateTable{
+		Name:     "users",
+		ColNames: []string{"id", "email", "name", "code"},
+		ColDefs: map[string]ddl.ColumnDef{
+			"id":    ddl.ColumnDef{Name: "id", T: ddl.Type{Name: ddl.Int64}},
+			"email": ddl.ColumnDef{Name: "email", T: str},
+			"name":  ddl.ColumnDef{Name: "name", T: str},
+			"code":  ddl.ColumnDef{Name: "code", T: str},
+		},
+		Pks: []ddl.IndexKey{ddl.IndexKey{Col: "id"}},
+	}
+	conv.ToSpanner["users"] = NameAndCols{Name: "users", Cols: map[string]string{"id": "id", "email": "email", "name": "name", "code": "code"}}
+	l := conv.collationWarnings("users", "users")
+	// No warnings for the primary key (not a string) and code_idx (binary).
+	assert.Equal(t, 3, len(l))
+	assert.Contains(t, l[0], "Index 'email_idx' orders column 'email' (utf8mb4_0900_ai_ci) by collation")
+	assert.Contains(t, l[0], "no longer prevents them")
+	assert.Contains(t, l[1], "email_norm STRING(MAX) AS (LOWER(email)) STORED")
+	assert.Contains(t, l[2], "Index 'name_idx' orders column 'name' (en_US.utf8) by collation")
+	assert.NotContains(t, l[2], "no longer prevents them")
+}
@@ -0,0 +1,479 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package internal
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+
+	"cloud.google.com/go/civil"
+	"github.com/cloudspannerecosystem/harbourbridge/spanner/ddl"
+)
+
+// ComputedCol specifies a new Spanner column whose values are computed
+// during data conversion from the other columns of the row. Expr is an
+// expression over the (Spanner) columns of Table, using the following
+// grammar:
+//
+//	expr: column | 'string' | number | function ( [expr, ...] )
+//
+// Supported functions are:
+//
+//	concat(e, ...)         concatenation of its arguments
+//	coalesce(e, ...)       first non-NULL argument
+//	lower(e), upper(e)     lower or upper case
+//	trim(e)                e without leading and trailing white space
+//	substr(e, pos [, len]) substring of e starting at pos (1-based)
+//	json_extract(e, path)  field of the JSON document e, where path is
+//	                       a dot-separated list of object keys and
+//	                       array indexes, e.g. 'address.lines.0'
+//
+// Column values are converted to strings, and any function with a NULL
+// argument (except coalesce) returns NULL, as in SQL. The result is
+// converted to Type, which must be one of BOOL, FLOAT64, INT64 and
+// STRING(length).
+type ComputedCol struct {
+	Table  string
+	Column string
+	Type   string
+	Expr   string
+}
+
+// AddComputedCols adds computed columns to the Spanner schema. Columns
+// that have already been added (e.g. when conv was read from a session
+// file) are skipped.
+func (conv *Conv) AddComputedCols(cols []ComputedCol) error {
+	if conv.ComputedCols == nil {
+		conv.ComputedCols = make(map[string][]ComputedCol)
+	}
+	for _, cc := range cols {
+		ct, ok := conv.SpSchema[cc.Table]
+		if !ok {
+			return fmt.Errorf("can't add computed column %s: unknown Spanner table %s", cc.Column, cc.Table)
+		}
+		if existing, ok := conv.computedCol(cc.Table, cc.Column); ok {
+			if existing != cc {
+				return fmt.Errorf("can't add computed column %s to table %s: it already exists with a different definition", cc.Column, cc.Table)
+			}
+			continue
+		}
+		if _, ok := ct.ColDefs[cc.Column]; ok {
+			return fmt.Errorf("can't add computed column %s to table %s: column already exists", cc.Column, cc.Table)
+		}
+		if _, changed := FixName(cc.Column); changed {
+			return fmt.Errorf("can't add computed column %s to table %s: not a valid Spanner column name", cc.Column, cc.Table)
+		}
+		t, err := parseComputedType(cc.Type)
+		if err != nil {
+			return fmt.Errorf("can't add computed column %s to table %s: %w", cc.Column, cc.Table, err)
+		}
+		e, err := parseExpr(cc.Expr)
+		if err != nil {
+			return fmt.Errorf("can't add computed column %s to table %s: bad expression %q: %w", cc.Column, cc.Table, cc.Expr, err)
+		}
+		for _, c := range e.cols() {
+			if _, ok := ct.ColDefs[c]; !ok {
+				return fmt.Errorf("can't add computed column %s to table %s: expression refers to unknown column %s", cc.Column, cc.Table, c)
+			}
+		}
+		ct.ColNames = append(ct.ColNames, cc.Column)
+		ct.ColDefs[cc.Column] = ddl.ColumnDef{Name: cc.Column, T: t, Comment: "Computed from: " + cc.Expr}
+		conv.SpSchema[cc.Table] = ct
+		conv.ComputedCols[cc.Table] = append(conv.ComputedCols[cc.Table], cc)
+	}
+	return nil
+}
+
+func (conv *Conv) computedCol(spTable, spCol string) (ComputedCol, bool) {
+	for _, cc := range conv.ComputedCols[spTable] {
+		if cc.Column == spCol {
+			return cc, true
+		}
+	}
+	return ComputedCol{}, false
+}
+
+// addComputedVals evaluates the computed columns of spTable for a row, and
+// returns the row with the computed values added (NULL values are left
+// out, as for other columns).
+func (conv *Conv) addComputedVals(spTable string, spCols []string, spVals []interface{}) ([]string, []interface{}, error) {
+	row := make(map[string]interface{})
+	for i, c := range spCols {
+		row[c] = spVals[i]
+	}
+	cols := append([]string{}, spCols...)
+	vals := append([]interface{}{}, spVals...)
+	for _, cc := range conv.ComputedCols[spTable] {
+		e, err := conv.compiledExpr(spTable, cc)
+		if err != nil {
+			return nil, nil, err
+		}
+		s, err := e.eval(row)
+		if err != nil {
+			return nil, nil, fmt.Errorf("can't compute column %s: %w", cc.Column, err)
+		}
+		if s == nil {
+			continue
+		}
+		t := conv.SpSchema[spTable].ColDefs[cc.Column].T
+		v, err := convComputed(t, *s)
+		if err != nil {
+			// Don't include the value: this message is used as a key
+			// for counting unexpected conditions.
+			return nil, nil, fmt.Errorf("can't compute column %s: result is not a valid %s", cc.Column, t.Name)
+		}
+		cols = append(cols, cc.Column)
+		vals = append(vals, v)
+		row[cc.Column] = v // Later computed columns can use this one.
+	}
+	return cols, vals, nil
+}
+
+// compiledExpr returns the parsed expression of a computed column. Parsed
+// expressions are cached, since they aren't saved in session files.
+func (conv *Conv) compiledExpr(spTable string, cc ComputedCol) (expr, error) {
+	conv.rowsMu.Lock()
+	defer conv.rowsMu.Unlock()
+	k := spTable + "\x00" + cc.Column
+	if e, ok := conv.computedExprs[k]; ok {
+		return e, nil
+	}
+	e, err := parseExpr(cc.Expr)
+	if err != nil {
+		return nil, fmt.Errorf("bad expression %q for computed column %s: %w", cc.Expr, cc.Column, err)
+	}
+	if conv.computedExprs == nil {
+		conv.computedExprs = make(map[string]expr)
+	}
+	conv.computedExprs[k] = e
+	return e, nil
+}
+
+func parseComputedType(s string) (ddl.Type, error) {
+	s = strings.ToUpper(strings.Replace(s, " ", "", -1))
+	switch s {
+	case ddl.Bool, ddl.Float64, ddl.Int64:
+		return ddl.Type{Name: s}, nil
+	case ddl.String, "STRING(MAX)":
+		return ddl.Type{Name: ddl.String, Len: ddl.MaxLength}, nil
+	}
+	if strings.HasPrefix(s, "STRING(") && strings.HasSuffix(s, ")") {
+		n, err := strconv.ParseInt(s[len("STRING("):len(s)-1], 10, 64)
+		if err == nil && n > 0 {
+			return ddl.Type{Name: ddl.String, Len: n}, nil
+		}
+	}
+	return ddl.Type{}, fmt.Errorf("unsupported type %q (accepted types are BOOL, FLOAT64, INT64 and STRING(length))", s)
+}
+
+func convComputed(t ddl.Type, s string) (interface{}, error) {
+	switch t.Name {
+	case ddl.Bool:
+		return strconv.ParseBool(s)
+	case ddl.Float64:
+		return strconv.ParseFloat(s, 64)
+	case ddl.Int64:
+		return strconv.ParseInt(s, 10, 64)
+	}
+	return s, nil
+}
+
+// expr is a parsed computed column expression. eval returns nil for NULL.
+type expr interface {
+	eval(row map[string]interface{}) (*string, error)
+	cols() []string
+}
+
+type literal string
+
+func (l literal) eval(row map[string]interface{}) (*string, error) {
+	s := string(l)
+	return &s, nil
+}
+
+func (l literal) cols() []string { return nil }
+
+type colRef string
+
+func (c colRef) eval(row map[string]interface{}) (*string, error) {
+	v := row[string(c)]
+	if v == nil {
+		return nil, nil
+	}
+	s := valueString(v)
+	return &s, nil
+}
+
+func (c colRef) cols() []string { return []string{string(c)} }
+
+type call struct {
+	fn   string
+	args []expr
+}
+
+func (c call) cols() []string {
+	var l []string
+	for _, a := range c.args {
+		l = append(l, a.cols()...)
+	}
+	return l
+}
+
+func (c call) eval(row map[string]interface{}) (*string, error) {
+	var args []string
+	for _, a := range c.args {
+		v, err := a.eval(row)
+		if err != nil {
+			return nil, err
+		}
+		if c.fn == "coalesce" {
+			if v != nil {
+				return v, nil
+			}
+			continue
+		}
+		if v == nil {
+			return nil, nil
+		}
+		args = append(args, *v)
+	}
+	var r string
+	switch c.fn {
+	case "coalesce":
+		return nil, nil
+	case "concat":
+		r = strings.Join(args, "")
+	case "lower":
+		r = strings.ToLower(args[0])
+	case "upper":
+		r = strings.ToUpper(args[0])
+	case "trim":
+		r = strings.TrimSpace(args[0])
+	case "substr":
+		rs := []rune(args[0])
+		pos, err := strconv.Atoi(args[1])
+		if err != nil || pos < 1 {
+			return nil, fmt.Errorf("substr: bad position %q", args[1])
+		}
+		end := len(rs)
+		if len(args) == 3 {
+			n, err := strconv.Atoi(args[2])
+			if err != nil || n < 0 {
+				return nil, fmt.Errorf("substr: bad length %q", args[2])
+			}
+			if pos-1+n < end {
+				end = pos - 1 + n
+			}
+		}
+		if pos-1 < end {
+			r = string(rs[pos-1 : end])
+		}
+	case "json_extract":
+		return jsonExtract(args[0], args[1])
+	}
+	return &r, nil
+}
+
+// jsonExtract returns the value at path in the JSON document doc. Strings
+// are returned unquoted, objects and arrays as JSON, and JSON null or a
+// missing path as NULL.
+func jsonExtract(doc, path string) (*string, error) {
+	var v interface{}
+	if err := json.Unmarshal([]byte(doc), &v); err != nil {
+		return nil, fmt.Errorf("json_extract: invalid JSON: %w", err)
+	}
+	path = strings.TrimPrefix(strings.TrimPrefix(path, "$"), ".")
+	if path != "" {
+		for _, k := range strings.Split(path, ".") {
+			switch x := v.(type) {
+			case map[string]interface{}:
+				v = x[k]
+			case []interface{}:
+				i, err := strconv.Atoi(k)
+				if err != nil || i < 0 || i >= len(x) {
+					return nil, nil
+				}
+				v = x[i]
+			default:
+				return nil, nil
+			}
+		}
+	}
+	var s string
+	switch x := v.(type) {
+	case nil:
+		return nil, nil
+	case string:
+		s = x
+	case float64:
+		s = strconv.FormatFloat(x, 'f', -1, 64)
+	default:
+		b, err := json.Marshal(x)
+		if err != nil {
+			return nil, fmt.Errorf("json_extract: %w", err)
+		}
+		s = string(b)
+	}
+	return &s, nil
+}
+
+// valueString converts a (converted) Spanner value to a string.
+func valueString(v interface{}) string {
+	switch x := v.(type) {
+	case string:
+		return x
+	case []byte:
+		return string(x)
+	case bool:
+		return strconv.FormatBool(x)
+	case int64:
+		return strconv.FormatInt(x, 10)
+	case float64:
+		return strconv.FormatFloat(x, 'g', -1, 64)
+	case time.Time:
+		return x.Format(time.RFC3339Nano)
+	case civil.Date:
+		return x.String()
+	}
+	return fmt.Sprintf("%v", v)
+}
+
+// Number of arguments accepted by each function (max -1 means any number).
+var exprFuncs = map[string]struct{ min, max int }{
+	"coalesce":     {1, -1},
+	"concat":       {1, -1},
+	"json_extract": {2, 2},
+	"lower":        {1, 1},
+	"substr":       {2, 3},
+	"trim":         {1, 1},
+	"upper":        {1, 1},
+}
+
+type exprParser struct {
+	s   string
+	pos int
+}
+
+func parseExpr(s string) (expr, error) {
+	p := &exprParser{s: s}
+	e, err := p.expr()
+	if err != nil {
+		return nil, err
+	}
+	p.skipSpace()
+	if p.pos < len(p.s) {
+		return nil, fmt.Errorf("unexpected %q at offset %d", p.s[p.pos:], p.pos)
+	}
+	return e, nil
+}
+
+func (p *exprParser) skipSpace() {
+	for p.pos < len(p.s) && unicode.IsSpace(rune(p.s[p.pos])) {
+		p.pos++
+	}
+}
+
+func (p *exprParser) expr() (expr, error) {
+	p.skipSpace()
+	if p.pos >= len(p.s) {
+		return nil, fmt.Errorf("unexpected end of expression")
+	}
+	c := p.s[p.pos]
+	switch {
+	case c == '\'':
+		return p.str()
+	case c == '-' || (c >= '0' && c <= '9'):
+		start := p.pos
+		p.pos++
+		for p.pos < len(p.s) && (p.s[p.pos] == '.' || (p.s[p.pos] >= '0' && p.s[p.pos] <= '9')) {
+			p.pos++
+		}
+		if _, err := strconv.ParseFloat(p.s[start:p.pos], 64); err != nil {
+			return nil, fmt.Errorf("bad number %q", p.s[start:p.pos])
+		}
+		return literal(p.s[start:p.pos]), nil
+	case c == '_' || unicode.IsLetter(rune(c)):
+		start := p.pos
+		for p.pos < len(p.s) && (p.s[p.pos] == '_' || unicode.IsLetter(rune(p.s[p.pos])) || unicode.IsDigit(rune(p.s[p.pos]))) {
+			p.pos++
+		}
+		name := p.s[start:p.pos]
+		p.skipSpace()
+		if p.pos >= len(p.s) || p.s[p.pos] != '(' {
+			return colRef(name), nil
+		}
+		return p.call(strings.ToLower(name))
+	}
+	return nil, fmt.Errorf("unexpected %q at offset %d", c, p.pos)
+}
+
+// str parses a single-quoted string; quotes are escaped by doubling them.
+func (p *exprParser) str() (expr, error) {
+	var b strings.Builder
+	for p.pos++; p.pos < len(p.s); p.pos++ {
+		if p.s[p.pos] == '\'' {
+			if p.pos+1 < len(p.s) && p.s[p.pos+1] == '\'' {
+				b.WriteByte('\'')
+				p.pos++
+				continue
+			}
+			p.pos++
+			return literal(b.String()), nil
+		}
+		b.WriteByte(p.s[p.pos])
+	}
+	return nil, fmt.Errorf("unterminated string")
+}
+
+func (p *exprParser) call(fn string) (expr, error) {
+	nargs, ok := exprFuncs[fn]
+	if !ok {
+		return nil, fmt.Errorf("unknown function %s", fn)
+	}
+	p.pos++ // Skip '('.
+	var args []expr
+	p.skipSpace()
+	if p.pos < len(p.s) && p.s[p.pos] == ')' {
+		p.pos++
+	} else {
+		for {
+			a, err := p.expr()
+			if err != nil {
+				return nil, err
+			}
+			args = append(args, a)
+			p.skipSpace()
+			if p.pos >= len(p.s) {
+				return nil, fmt.Errorf("unexpected end of expression")
+			}
+			if p.s[p.pos] == ')' {
+				p.pos++
+				break
+			}
+			if p.s[p.pos] != ',' {
+				return nil, fmt.Errorf("expected ',' or ')' at offset %d", p.pos)
+			}
+			p.pos++
+		}
+	}
+	if len(args) < nargs.min || (nargs.max >= 0 && len(args) > nargs.max) {
+		return nil, fmt.Errorf("wrong number of arguments for %s: %d", fn, len(args))
+	}
+	return call{fn: fn, args: args}, nil
+}
@@ -0,0 +1,138 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package internal
+
+import (
+	"testing"
+
+	"github.com/cloudspannerecosystem/harbourbridge/spanner/ddl"
+	"github.com/stretchr/testify/assert"
+)
+
+func computedTestConv() *Conv {
+	conv := MakeConv()
+	conv.SpSchema["t"] = ddl.CreateTable{
+		Name:     "t",
+		ColNames: []string{"id", "first", "last", "doc"},
+		ColDefs: map[string]ddl.ColumnDef{
+			"id":    ddl.ColumnDef{Name: "id", T: ddl.Type{Name: ddl.Int64}},
+			"first": ddl.ColumnDef{Name: "first", T: ddl.Type{Name: ddl.String, Len: ddl.MaxLength}},
+			"last":  ddl.ColumnDef{Name: "last", T: ddl.Type{Name: ddl.String, Len: ddl.MaxLength}},
+			"doc":   ddl.ColumnDef{Name: "doc", T: ddl.Type{Name: ddl.String, Len: ddl.MaxLength}},
+		},
+		Pks: []ddl.IndexKey{ddl.IndexKey{Col: "id"}}}
+	return conv
+}
+
+func TestEvalExpr(t *testing.T) {
+	row := map[string]interface{}{
+		"id":    int64(42),
+		"first": "Ada",
+		"last":  "Lovelace",
+		"doc":   `{"address": {"lines": ["12 St James's Square", "London"], "zip": 12345}, "tags": null}`,
+	}
+	str := func(s string) *string { return &s }
+	for _, tc := range []struct {
+		expr string
+		want *string
+	}{
+		{"concat(first, ' ', last)", str("Ada Lovelace")},
+		{"concat('#', id)", str("#42")},
+		{"concat(first, missing)", nil},
+		{"coalesce(missing, last)", str("Lovelace")},
+		{"coalesce(missing)", nil},
+		{"upper(first)", str("ADA")},
+		{"lower( first )", str("ada")},
+		{"trim('  x ')", str("x")},
+		{"substr(last, 1, 4)", str("Love")},
+		{"substr(last, 5)", str("lace")},
+		{"substr(last, 20)", str("")},
+		{"'it''s'", str("it's")},
+		{"json_extract(doc, 'address.lines.1')", str("London")},
+		{"json_extract(doc, '$.address.zip')", str("12345")},
+		{"json_extract(doc, 'address.lines')", str(`["12 St James's Square","London"]`)},
+		{"json_extract(doc, 'tags')", nil},
+		{"json_extract(doc, 'address.nope.x')", nil},
+	} {
+		e, err := parseExpr(tc.expr)
+		assert.Nil(t, err, tc.expr)
+		got, err := e.eval(row)
+		assert.Nil(t, err, tc.expr)
+		assert.Equal(t, tc.want, got, tc.expr)
+	}
+}
+
+func TestParseExpr_Errors(t *testing.T) {
+	for _, s := range []string{
+		"",
+		"concat(first",
+		"concat(first last)",
+		"unknown(first)",
+		"upper(first, last)",
+		"substr(first)",
+		"'unterminated",
+		"first last",
+		"1.2.3",
+	} {
+		_, err := parseExpr(s)
+		assert.NotNil(t, err, s)
+	}
+}
+
+func TestAddComputedCols(t *testing.T) {
+	conv := computedTestConv()
+	cols := []ComputedCol{
+		{Table: "t", Column: "name", Type: "STRING(MAX)", Expr: "concat(first, ' ', last)"},
+		{Table: "t", Column: "zip", Type: "INT64", Expr: "json_extract(doc, 'address.zip')"},
+		{Table: "t", Column: "initial", Type: "STRING(1)", Expr: "substr(name, 1, 1)"},
+	}
+	assert.Nil(t, conv.AddComputedCols(cols))
+	ct := conv.SpSchema["t"]
+	assert.Equal(t, []string{"id", "first", "last", "doc", "name", "zip", "initial"}, ct.ColNames)
+	assert.Equal(t, ddl.Type{Name: ddl.Int64}, ct.ColDefs["zip"].T)
+	assert.Equal(t, ddl.Type{Name: ddl.String, Len: 1}, ct.ColDefs["initial"].T)
+	// Adding the same columns again (e.g. for a data-only run using a
+	// session file) is a no-op.
+	assert.Nil(t, conv.AddComputedCols(cols))
+	assert.Equal(t, 7, len(conv.SpSchema["t"].ColNames))
+
+	conv.SetDataMode()
+	var gotCols []string
+	var gotVals []interface{}
+	conv.SetDataSink(func(table string, cols []string, vals []interface{}) {
+		gotCols, gotVals = cols, vals
+	})
+	conv.WriteRow("src", "t", []string{"id", "first", "last", "doc"}, []interface{}{int64(1), "Ada", "Lovelace", `{"address": {"zip": 12345}}`})
+	assert.Equal(t, []string{"id", "first", "last", "doc", "name", "zip", "initial"}, gotCols)
+	assert.Equal(t, []interface{}{int64(1), "Ada", "Lovelace", `{"address": {"zip": 12345}}`, "Ada Lovelace", int64(12345), "A"}, gotVals)
+
+	// Values that can't be converted to the column's type give bad rows.
+	conv.WriteRow("src", "t", []string{"id", "doc"}, []interface{}{int64(2), `{"address": {"zip": "SW1Y"}}`})
+	assert.Equal(t, int64(1), conv.Stats.GoodRows["src"])
+	assert.Equal(t, int64(1), conv.Stats.BadRows["src"])
+}
+
+func TestAddComputedCols_Errors(t *testing.T) {
+	for _, cc := range []ComputedCol{
+		{Table: "u", Column: "name", Type: "STRING(MAX)", Expr: "first"},
+		{Table: "t", Column: "first", Type: "STRING(MAX)", Expr: "last"},
+		{Table: "t", Column: "name", Type: "DATE", Expr: "first"},
+		{Table: "t", Column: "name", Type: "STRING(MAX)", Expr: "concat(first, middle)"},
+		{Table: "t", Column: "name", Type: "STRING(MAX)", Expr: "concat(first"},
+	} {
+		conv := computedTestConv()
+		assert.NotNil(t, conv.AddComputedCols([]ComputedCol{cc}), cc.Expr)
+	}
+}
@@ -0,0 +1,150 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package internal
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/cloudspannerecosystem/harbourbridge/spanner/ddl"
+)
+
+// The tests in this file convert rows of several tables in parallel.
+// Sinks aren't synchronized, since rows reach them one after the other:
+// run with -race to check synchronization.
+
+func TestWriteRow_Concurrent(t *testing.T) {
+	conv := MakeConv()
+	tables := []string{"t0", "t1", "t2", "t3"}
+	for _, name := range tables {
+		conv.SpSchema[name] = ddl.CreateTable{
+			Name:     name,
+			ColNames: []string{"id", "v"},
+			ColDefs: map[string]ddl.ColumnDef{
+				"id": {Name: "id", T: ddl.Type{Name: ddl.Int64}, NotNull: true},
+				"v":  {Name: "v", T: ddl.Type{Name: ddl.String, Len: 4}, NotNull: true},
+			},
+			Pks: []ddl.IndexKey{{Col: "id"}}}
+	}
+	conv.Policies.Duplicates = SidelineDuplicates
+	conv.Policies.NotNull = DropNotNull
+	conv.SetDataMode()
+	written := make(map[string]int)
+	conv.SetDataSink(func(table string, cols []string, vals []interface{}) {
+		written[table]++
+	})
+	var wg sync.WaitGroup
+	for _, name := range tables {
+		wg.Add(1)
+		go func(name string) {
+			defer wg.Done()
+			for i := 0; i < 1000; i++ {
+				conv.StatsAddRow(name, true)
+				switch i % 10 {
+				case 0: // Duplicate key.
+					conv.WriteRow(name, name, []string{"id", "v"}, []interface{}{int64(1), "x"})
+				case 1: // NULL value for NOT NULL column.
+					conv.WriteRow(name, name, []string{"id"}, []interface{}{int64(i)})
+				default:
+					conv.WriteRow(name, name, []string{"id", "v"}, []interface{}{int64(i), "x"})
+				}
+			}
+		}(name)
+	}
+	wg.Wait()
+	assert.Equal(t, int64(4000), conv.Rows())
+	for _, name := range tables {
+		assert.Equal(t, 801, written[name], name)
+		assert.Equal(t, int64(801), conv.Stats.GoodRows[name], name)
+		assert.Equal(t, int64(99), conv.Stats.Duplicates[name], name)
+		assert.Equal(t, int64(199), conv.Stats.BadRows[name], name)
+	}
+}
+
+func TestWriteRow_ConcurrentPolicies(t *testing.T) {
+	conv := MakeConv()
+	tables := []string{"t0", "t1", "t2", "t3"}
+	conv.SpSchema["codes"] = ddl.CreateTable{
+		Name:     "codes",
+		ColNames: []string{"id", "value"},
+		ColDefs: map[string]ddl.ColumnDef{
+			"id":    {Name: "id", T: ddl.Type{Name: ddl.Int64}, NotNull: true},
+			"value": {Name: "value", T: ddl.Type{Name: ddl.String, Len: ddl.MaxLength}, NotNull: true},
+		},
+		Pks: []ddl.IndexKey{{Col: "id"}}}
+	conv.DictCols = make(map[string]DictionaryColumn)
+	conv.Policies.Masks = make(map[string]MaskRule)
+	for _, name := range tables {
+		conv.SpSchema[name] = ddl.CreateTable{
+			Name:     name,
+			ColNames: []string{"id", "v", "code"},
+			ColDefs: map[string]ddl.ColumnDef{
+				"id":   {Name: "id", T: ddl.Type{Name: ddl.Int64}, NotNull: true},
+				"v":    {Name: "v", T: ddl.Type{Name: ddl.String, Len: 8}},
+				"code": {Name: "code", T: ddl.Type{Name: ddl.Int64}},
+			},
+			Pks: []ddl.IndexKey{{Col: "id"}}}
+		conv.ToSpanner[name] = NameAndCols{Name: name, Cols: map[string]string{"id": "id", "v": "v", "code": "code"}}
+		conv.ToSource[name] = NameAndCols{Name: name, Cols: map[string]string{"id": "id", "v": "v", "code": "code"}}
+		conv.DictCols[name+".code"] = DictionaryColumn{Table: name, Column: "code", Dictionary: "codes"}
+		conv.Policies.Masks[name+".v"] = MaskRule{Method: MaskRedact}
+	}
+	conv.Policies.Duplicates = LastWins
+	conv.SetDataMode()
+	written := make(map[string]int)
+	replaced := make(map[string]int)
+	var values []interface{}
+	conv.SetDataSink(func(table string, cols []string, vals []interface{}) {
+		written[table]++
+		if table != "codes" {
+			values = append(values, vals[1])
+		}
+	})
+	conv.SetReplaceSink(func(table string, cols []string, vals []interface{}) {
+		replaced[table]++
+	})
+	var wg sync.WaitGroup
+	for _, name := range tables {
+		wg.Add(1)
+		go func(name string) {
+			defer wg.Done()
+			for i := 0; i < 1000; i++ {
+				conv.StatsAddRow(name, true)
+				id := int64(i)
+				if i%10 == 0 {
+					id = 1 // Duplicate key.
+				}
+				conv.WriteRow(name, name, []string{"id", "v", "code"}, []interface{}{id, "ab12", fmt.Sprintf("c%d", i%5)})
+			}
+		}(name)
+	}
+	wg.Wait()
+	for _, name := range tables {
+		assert.Equal(t, 900, written[name], name)
+		assert.Equal(t, 100, replaced[name], name)
+		assert.Equal(t, int64(1000), conv.Stats.GoodRows[name], name)
+		assert.Equal(t, int64(100), conv.Stats.Duplicates[name], name)
+	}
+	// Values are added to the dictionary once, whichever table has them
+	// first.
+	assert.Equal(t, 5, replaced["codes"])
+	assert.Equal(t, 0, written["codes"])
+	for _, v := range values {
+		assert.Equal(t, "xx00", v)
+	}
+}
@@ -16,6 +16,9 @@ package internal
 
 import (
 	"fmt"
+	"io"
+	"math/rand"
+	"sync"
 	"time"
 
 	"github.com/cloudspannerecosystem/harbourbridge/schema"
@@ -35,9 +38,88 @@ type Conv struct {
 	Location       *time.Location // Timezone (for timestamp conversion).
 	sampleBadRows  rowSamples     // Rows that generated errors during conversion.
 	Stats          stats
-	TimezoneOffset string // Timezone offset for timestamp conversion.
-	TargetDb       string // The target database to which HarbourBridge is writing.
-}
+	TimezoneOffset string                        // Timezone offset for timestamp conversion.
+	TargetDb       string                        // The target database to which HarbourBridge is writing.
+	Features       Features                      // Features supported by the target (nil means the base features, see Supports).
+	Policies       Policies                      // Policies for handling values Spanner can't store as-is.
+	OverflowTables map[string]string             // Maps Spanner table name to its overflow table (see OverflowOversize).
+	DroppedCols    map[string][]string           // Source columns that are not migrated, broken down by source table (see DropColumns).
+	ComputedCols   map[string][]ComputedCol      // Computed columns, broken down by Spanner table (see AddComputedCols).
+	computedExprs  map[string]expr               // Parsed expressions of computed columns.
+	maskedCols     map[string]map[string]string  // Source columns of the masked Spanner columns, by source table (see maskRow).
+	charCols       map[string]map[string]bool    // Spanner columns of blank-padded CHAR columns, by source table (see trimCharPadding).
+	Splits         map[string][]SplitTable       // Tables split from a Spanner table, broken down by Spanner table (see SplitTable).
+	MergedTables   map[string]MergeTable         // Maps source table to the merge of its Spanner table into another table (see MergeTable).
+	KeyIndexes     map[string]PrimaryKeyIndex    // Primary keys taken from unique indexes, by Spanner table (see PrimaryKeyIndex).
+	KeyOrders      map[string]KeyOrder           // Reordered primary keys, by Spanner table (see KeyOrder).
+	MoneyCols      map[string]ddl.Type           // Original type of the floating point columns converted to NUMERIC, by Spanner table.column (see Remodel.Money).
+	BoolCols       map[string]ddl.Type           // Original type of the CHAR(1) columns converted to BOOL, by Spanner table.column (see Remodel.Bools).
+	DictCols       map[string]DictionaryColumn   // Dictionary-encoded columns, by Spanner table.column (see DictionaryColumn).
+	LongKeys       map[string]LongKey            // Primary key columns changed to fit Spanner's limit on key size, by Spanner table.column (see LongKey).
+	StringLens     map[string]int64              // Length of the longest value of the STRING(MAX) columns given a length, by Spanner table.column (see TightenStrings).
+	WidenedStrs    map[string]WidenedString      // STRING(n) columns widened to fit the source data, by Spanner table.column (see FitStrings).
+	Profiles       map[string]ColumnProfile      // Profiles of the values of source columns, by source table.column (see ColumnProfile).
+	IndexScans     map[string]int64              // Scans of the source indexes of Spanner indexes, if the source reports them, by Spanner index.
+	DroppedIndexes map[string]RedundantIndex     // Dropped Spanner indexes, by name (see Remodel.DropIndexes).
+	TrimmedFks     map[string][]ddl.Foreignkey   // Foreign keys dropped to keep Spanner tables within Spanner's limits, by table (see Remodel.TrimToLimits).
+	SkippedTables  map[string]string             // Source tables that are not migrated, with the reason why (see SkipColumnlessTables).
+	Seed           int64                         // Seed of the random values generated during conversion, 0 if not set (see SetSeed).
+	SrcOrder       []string                      // Source tables in the order they are defined in the source database.
+	Ordering       Ordering                      // Order of tables, columns, indexes and foreign keys in generated DDL and reports.
+	SrcSequences   map[string]schema.Sequence    // Maps source sequence name to sequence information.
+	Sequences      map[string]ddl.CreateSequence // Maps source sequence name to Spanner sequence (see AddSequences).
+	SrcTriggers    map[string][]schema.Trigger   // Source triggers, broken down by source table.
+	SrcFunctions   map[string]string             // Maps source function name to its body (used to analyze triggers).
+	SrcEnums       map[string][]string           // Maps source enum type name to its values (used to decode binary data).
+	Names          SpannerNames                  // Spanner names of foreign keys and indexes (see ForeignKeyName and IndexName).
+	NameMapping    NameMapping                   // How source names are mapped to Spanner names.
+	Snapshots      map[string]SnapshotPosition   // Maps source table name to the source position its data was read at (see RecordSnapshot).
+	SrcSchemaHash  string                        // Hash of the source schema when it was read, empty if not recorded (see RecordSchemaHash).
+	SrcVersion     ServerVersion                 // Version of the source server, zero if unknown (see ServerVersion).
+	merges         []deferredRow                 // Rows of merged tables, written by ResolveMerges.
+	updateSink     func(table string, cols []string, values []interface{})
+	childSink      func(table string, cols []string, values []interface{})
+	fks            *fkChecker                 // Foreign key checking state (see OrphanPolicy).
+	pkeys          map[string]map[string]bool // Primary keys written, broken down by Spanner table (see DuplicatePolicy).
+	dataTables     map[string]bool            // Source tables whose data is converted, nil for all (see SetDataTables).
+	incremental    *incremental               // Incremental key columns and watermarks (see SetIncremental).
+	softDelete     SoftDelete                 // Soft-deleted rows, excluded from data conversion (see SetSoftDelete).
+	tenant         Tenant                     // Tenant whose rows are converted, if any (see SetTenant).
+	fetchSize      FetchSize                  // How tables of live sources are read (see SetFetchSize).
+	windows        *tableWindows              // Time windows of tables, if any (see SetTableWindows).
+	dateRules      []dateRule                 // Parsed Policies.DateRules (see CheckDateRules).
+	valueDicts     map[string]valueDictRule   // Resolved Policies.ValueDictionaries, by Spanner table.column (see CheckValueDictionaries).
+	dicts          map[string]*dictionary     // Lookup tables of dictionary-encoded columns, by name.
+	truncKeys      *truncatedKeys             // Truncated long key values (see LongKey).
+	rands          map[string]*rand.Rand      // Sources of random values, by Spanner table (see randFor).
+	aborted        error                      // Why data conversion was aborted, if it was (see Aborted).
+	errorGroups    map[string]*ErrorGroup     // Bad rows, by cause (see GroupBadRow).
+	replaceSink    func(table string, cols []string, values []interface{})
+	progressSink   func(ProgressEvent)
+	progressOut    io.Writer // Where task percentages are printed (see SetProgressOutput).
+	snapshotSource func(srcTable string) (SnapshotPosition, error)
+	progressTable  string     // Source table being converted, for progress events (see SetProgressSink).
+	spill          *spill     // Files the schema of tables is kept in, if it isn't kept in memory (see SpillTo).
+	schemaMu       sync.Mutex // Protects schema and name mappings (see Note on concurrency).
+	statsMu        sync.Mutex // Protects Stats, sampleBadRows and errorGroups.
+	rowsMu         sync.Mutex // Protects the state kept across rows (see Note on concurrency), and serializes the sinks.
+}
+
+// Note on concurrency.
+// Conv can be used to convert several tables in parallel:
+// a) During schema conversion, name mappings (GetSpannerTable,
+// GetSpannerCol and GetSpannerCols) and updates of the schema of a
+// table (SetSrcTable, AddConvertedTable, SetIssues) are synchronized.
+// Other schema changes (e.g. AddPrimaryKeys, DropColumns) must not run
+// concurrently with anything else.
+// b) During data conversion, the schema is read-only. Stats, bad row
+// samples and synthetic primary key sequences are synchronized. WriteRow
+// converts values concurrently, but the state kept across rows (lookups
+// built on first use, truncated and written keys, dictionaries, held back
+// rows) is protected by rowsMu, which is also held while rows are
+// written: the rows written for a source row (including split and
+// overflow rows) reach the sinks one after the other, so sinks don't
+// need to be thread-safe.
 
 type mode int
 
@@ -52,6 +134,9 @@ const (
 type SyntheticPKey struct {
 	Col      string
 	Sequence int64
+	// Generator is the name of the KeyGenerator of the key values. Empty
+	// means SequenceKeys.
+	Generator string `json:",omitempty"`
 }
 
 // SchemaIssue specifies a schema conversion issue.
@@ -76,6 +161,9 @@ const (
 	Datetime
 	Widened
 	Time
+	IndexPrefix
+	TinyintBool
+	LargeObject
 )
 
 // NameAndCols contains the name of a table and its columns.
@@ -104,12 +192,28 @@ type row struct {
 // c) successfully converted, but an error occurs when writing the row to Spanner.
 // d) unsuccessfully converted (we won't try to write such rows to Spanner).
 type stats struct {
-	Rows       map[string]int64          // Count of rows encountered during processing (a + b + c + d), broken down by source table.
-	GoodRows   map[string]int64          // Count of rows successfully converted (b + c), broken down by source table.
-	BadRows    map[string]int64          // Count of rows where conversion failed (d), broken down by source table.
-	Statement  map[string]*statementStat // Count of processed statements, broken down by statement type.
-	Unexpected map[string]int64          // Count of unexpected conditions, broken down by condition description.
-	Reparsed   int64                     // Count of times we re-parse dump data looking for end-of-statement.
+	Rows           map[string]int64            // Count of rows encountered during processing (a + b + c + d), broken down by source table.
+	GoodRows       map[string]int64            // Count of rows successfully converted (b + c), broken down by source table.
+	BadRows        map[string]int64            // Count of rows where conversion failed (d), broken down by source table.
+	Statement      map[string]*statementStat   // Count of processed statements, broken down by statement type.
+	Unexpected     map[string]int64            // Count of unexpected conditions, broken down by condition description.
+	Reparsed       int64                       // Count of times we re-parse dump data looking for end-of-statement.
+	SpecialValues  map[string]map[string]int64 // Count of special values (e.g. 'infinity', NaN) handled by policy, broken down by source table and column.
+	Oversize       map[string]map[string]int64 // Count of values exceeding MaxCellBytes, broken down by source table and Spanner column.
+	LongStrings    map[string]map[string]int64 // Count of values longer than their STRING(n) column, broken down by source table and Spanner column.
+	LongestStrings map[string]map[string]int64 // Length (in characters) of the longest of these values, broken down by source table and Spanner column.
+	Orphans        map[string]map[string]int64 // Count of orphaned rows (see OrphanPolicy), broken down by source table and Spanner foreign key.
+	Duplicates     map[string]int64            // Count of rows with duplicate primary keys (see DuplicatePolicy), broken down by source table.
+	DuplicateKeys  map[string][]string         // Sample of duplicate primary keys, broken down by source table.
+	NotNull        map[string]map[string]int64 // Count of NULL values for NOT NULL columns handled by policy, broken down by source table and Spanner column.
+	NotNullRelaxed map[string][]string         // Spanner columns whose NOT NULL constraint was removed (see RelaxNotNull), broken down by source table.
+	CharPadding    map[string]map[string]int64 // Count of values of blank-padded CHAR columns trimmed by policy, broken down by source table and Spanner column.
+	EmptyStrings   map[string]map[string]int64 // Count of empty strings and NULL values of STRING columns changed by policy, broken down by source table and Spanner column.
+	DateRules      map[string]map[string]int64 // Count of values remapped by date rules, broken down by source table and rule (see DateRule.String).
+	Normalized     map[string]map[string]int64 // Count of values replaced by their canonical form by value dictionaries, broken down by source table and Spanner column.
+	UnknownCodes   map[string]map[string]int64 // Count of values not in their value dictionary, broken down by source table and Spanner column.
+	UnknownSamples map[string][]string         // Sample of these values, as column=value, broken down by source table.
+	Excluded       map[string]int64            // Count of soft-deleted rows excluded from data conversion (see SetSoftDelete), broken down by source table.
 }
 
 type statementStat struct {
@@ -128,14 +232,30 @@ func MakeConv() *Conv {
 		Issues:         make(map[string]map[string][]SchemaIssue),
 		ToSpanner:      make(map[string]NameAndCols),
 		ToSource:       make(map[string]NameAndCols),
+		OverflowTables: make(map[string]string),
+		DroppedCols:    make(map[string][]string),
+		ComputedCols:   make(map[string][]ComputedCol),
+		SrcSequences:   make(map[string]schema.Sequence),
+		Sequences:      make(map[string]ddl.CreateSequence),
+		SrcTriggers:    make(map[string][]schema.Trigger),
+		SrcFunctions:   make(map[string]string),
+		SrcEnums:       make(map[string][]string),
+		Names:          SpannerNames{Used: make(map[string]bool), Allocated: make(map[string]string)},
+		Snapshots:      make(map[string]SnapshotPosition),
 		Location:       time.Local, // By default, use go's local time, which uses $TZ (when set).
 		sampleBadRows:  rowSamples{bytesLimit: 10 * 1000 * 1000},
 		Stats: stats{
-			Rows:       make(map[string]int64),
-			GoodRows:   make(map[string]int64),
-			BadRows:    make(map[string]int64),
-			Statement:  make(map[string]*statementStat),
-			Unexpected: make(map[string]int64),
+			Rows:          make(map[string]int64),
+			GoodRows:      make(map[string]int64),
+			BadRows:       make(map[string]int64),
+			Statement:     make(map[string]*statementStat),
+			Unexpected:    make(map[string]int64),
+			SpecialValues: make(map[string]map[string]int64),
+			Oversize:      make(map[string]map[string]int64),
+			Orphans:       make(map[string]map[string]int64),
+			Duplicates:    make(map[string]int64),
+			DuplicateKeys: make(map[string][]string),
+			NotNull:       make(map[string]map[string]int64),
 		},
 		TimezoneOffset: "+00:00", // By default, use +00:00 offset which is equal to UTC timezone
 	}
@@ -170,19 +290,151 @@ func (conv *Conv) SetDataMode() {
 
 // WriteRow calls dataSink and updates row stats.
 func (conv *Conv) WriteRow(srcTable, spTable string, spCols []string, spVals []interface{}) {
+	conv.StartTable(srcTable)
 	if conv.dataSink == nil {
 		msg := "Internal error: ProcessDataRow called but dataSink not configured"
 		VerbosePrintf("%s\n", msg)
 		conv.Unexpected(msg)
 		conv.StatsAddBadRow(srcTable, conv.DataMode())
 	} else {
-		conv.dataSink(spTable, spCols, spVals)
-		conv.statsAddGoodRow(srcTable, conv.DataMode())
+		if len(conv.Policies.Masks) > 0 {
+			spCols, spVals = conv.maskRow(srcTable, spCols, spVals)
+		}
+		if conv.Policies.CharPadding != KeepCharPadding || len(conv.Policies.CharPaddingColumns) > 0 {
+			spVals = conv.trimCharPadding(srcTable, spTable, spCols, spVals)
+		}
+		if len(conv.ComputedCols[spTable]) > 0 {
+			cols, vals, err := conv.addComputedVals(spTable, spCols, spVals)
+			if err != nil {
+				conv.Unexpected(fmt.Sprintf("Error while computing columns of table %s: %s", spTable, err))
+				conv.StatsAddBadRow(srcTable, conv.DataMode())
+				conv.CollectBadRow(srcTable, spCols, oversizeRowVals(spVals))
+				return
+			}
+			spCols, spVals = cols, vals
+		}
+		if len(conv.LongKeys) > 0 {
+			cols, vals, err := conv.applyLongKeyStrategies(spTable, spCols, spVals)
+			if err != nil {
+				conv.Unexpected(fmt.Sprintf("Error while changing long keys of table %s: %s", spTable, err))
+				conv.StatsAddBadRow(srcTable, conv.DataMode())
+				conv.CollectBadRow(srcTable, spCols, oversizeRowVals(spVals))
+				return
+			}
+			spCols, spVals = cols, vals
+		}
+		if !conv.checkStringLengths(srcTable, spTable, spCols, spVals) {
+			conv.StatsAddBadRow(srcTable, conv.DataMode())
+			conv.CollectBadRow(srcTable, spCols, oversizeRowVals(spVals))
+			return
+		}
+		if conv.Policies.EmptyStrings != KeepEmptyStrings || len(conv.Policies.EmptyStringColumns) > 0 {
+			spCols, spVals = conv.applyEmptyStringPolicy(srcTable, spTable, spCols, spVals)
+		}
+		if len(conv.Policies.DateRules) > 0 {
+			spVals = conv.applyDateRules(srcTable, spTable, spCols, spVals)
+		}
+		if len(conv.Policies.ValueDictionaries) > 0 {
+			vals, ok := conv.applyValueDictionaries(srcTable, spTable, spCols, spVals)
+			if !ok {
+				conv.StatsAddBadRow(srcTable, conv.DataMode())
+				conv.CollectBadRow(srcTable, spCols, oversizeRowVals(spVals))
+				return
+			}
+			spVals = vals
+		}
+		if len(conv.DictCols) > 0 {
+			vals, err := conv.encodeDictionaries(spTable, spCols, spVals)
+			if err != nil {
+				conv.Unexpected(fmt.Sprintf("Error while encoding values of table %s: %s", spTable, err))
+				conv.StatsAddBadRow(srcTable, conv.DataMode())
+				conv.CollectBadRow(srcTable, spCols, oversizeRowVals(spVals))
+				return
+			}
+			spVals = vals
+		}
+		if _, ok := conv.MergedTables[srcTable]; ok {
+			// Rows of merged tables update rows of the table they are
+			// merged into, so they are held back until all of these
+			// have been written. Key and NOT NULL policies apply to rows
+			// of that table.
+			cols, vals, overflow, ok := conv.applyOversizePolicy(srcTable, spTable, spCols, spVals)
+			if !ok {
+				conv.StatsAddBadRow(srcTable, conv.DataMode())
+				conv.CollectBadRow(srcTable, spCols, oversizeRowVals(spVals))
+				return
+			}
+			conv.rowsMu.Lock()
+			conv.merges = append(conv.merges, deferredRow{srcTable: srcTable, spTable: spTable, cols: cols, vals: vals, overflow: overflow})
+			conv.rowsMu.Unlock()
+			return
+		}
+		if conv.Policies.NotNull != IgnoreNotNull || len(conv.Policies.NotNullColumns) > 0 {
+			cols, vals, ok := conv.applyNotNullPolicy(srcTable, spTable, spCols, spVals)
+			if !ok {
+				conv.StatsAddBadRow(srcTable, conv.DataMode())
+				conv.CollectBadRow(srcTable, spCols, oversizeRowVals(spVals))
+				return
+			}
+			spCols, spVals = cols, vals
+		}
+		cols, vals, overflow, ok := conv.applyOversizePolicy(srcTable, spTable, spCols, spVals)
+		if !ok {
+			conv.StatsAddBadRow(srcTable, conv.DataMode())
+			conv.CollectBadRow(srcTable, spCols, oversizeRowVals(spVals))
+			return
+		}
+		// Keys are checked and recorded, and the row written, as one
+		// step, so that rows reach the sinks in the order their keys
+		// were seen.
+		conv.rowsMu.Lock()
+		defer conv.rowsMu.Unlock()
+		replace := false
+		if conv.Policies.Duplicates != IgnoreDuplicates {
+			if ok, replace = conv.applyDuplicatePolicy(srcTable, spTable, cols, vals); !ok {
+				return
+			}
+		}
+		if conv.Policies.Orphans != IgnoreOrphans && len(conv.missingRefs(spTable, cols, vals)) > 0 {
+			// Hold the row back until the rows it references have been
+			// written (see ResolveOrphans).
+			fc := conv.getFkChecker()
+			fc.deferred = append(fc.deferred, deferredRow{srcTable: srcTable, spTable: spTable, cols: cols, vals: vals, overflow: overflow, replace: replace})
+			return
+		}
+		conv.writeRow(srcTable, spTable, cols, vals, overflow, replace)
 	}
 }
 
+// writeRow writes a converted row (and its split and overflow rows) to
+// dataSink, or to replaceSink if the row replaces an earlier one.
+func (conv *Conv) writeRow(srcTable, spTable string, cols []string, vals []interface{}, overflow []overflowRow, replace bool) {
+	sink := conv.dataSink
+	if replace && conv.replaceSink != nil {
+		sink = conv.replaceSink
+	}
+	mainCols, mainVals, splits := conv.splitRow(spTable, cols, vals)
+	sink(spTable, mainCols, mainVals)
+	for _, r := range splits {
+		if r.interleaved && !replace && conv.childSink != nil {
+			conv.childSink(r.table, r.cols, r.vals)
+		} else {
+			sink(r.table, r.cols, r.vals)
+		}
+	}
+	for _, r := range overflow {
+		sink(conv.OverflowTables[spTable], r.cols, r.vals)
+	}
+	if conv.Policies.Orphans != IgnoreOrphans {
+		conv.recordKeys(spTable, cols, vals)
+	}
+	conv.statsAddGoodRow(srcTable, conv.DataMode())
+}
+
 // Rows returns the total count of data rows processed.
 func (conv *Conv) Rows() int64 {
+	conv.statsMu.Lock()
+	defer conv.statsMu.Unlock()
 	n := int64(0)
 	for _, c := range conv.Stats.Rows {
 		n += c
@@ -193,6 +445,8 @@ func (conv *Conv) Rows() int64 {
 // BadRows returns the total count of bad rows encountered during
 // data conversion.
 func (conv *Conv) BadRows() int64 {
+	conv.statsMu.Lock()
+	defer conv.statsMu.Unlock()
 	n := int64(0)
 	for _, c := range conv.Stats.BadRows {
 		n += c
@@ -202,6 +456,8 @@ func (conv *Conv) BadRows() int64 {
 
 // Statements returns the total number of statements processed.
 func (conv *Conv) Statements() int64 {
+	conv.statsMu.Lock()
+	defer conv.statsMu.Unlock()
 	n := int64(0)
 	for _, x := range conv.Stats.Statement {
 		n += x.Schema + x.Data + x.Skip + x.Error
@@ -211,6 +467,8 @@ func (conv *Conv) Statements() int64 {
 
 // StatementErrors returns the number of statement errors encountered.
 func (conv *Conv) StatementErrors() int64 {
+	conv.statsMu.Lock()
+	defer conv.statsMu.Unlock()
 	n := int64(0)
 	for _, x := range conv.Stats.Statement {
 		n += x.Error
@@ -221,12 +479,16 @@ func (conv *Conv) StatementErrors() int64 {
 // Unexpecteds returns the total number of distinct unexpected conditions
 // encountered during processing.
 func (conv *Conv) Unexpecteds() int64 {
+	conv.statsMu.Lock()
+	defer conv.statsMu.Unlock()
 	return int64(len(conv.Stats.Unexpected))
 }
 
 // CollectBadRow updates the list of bad rows, while respecting
 // the byte limit for bad rows.
 func (conv *Conv) CollectBadRow(srcTable string, srcCols, vals []string) {
+	conv.statsMu.Lock()
+	defer conv.statsMu.Unlock()
 	r := &row{table: srcTable, cols: srcCols, vals: vals}
 	bytes := byteSize(r)
 	// Cap storage used by badRows. Keep at least one bad row.
@@ -239,6 +501,8 @@ func (conv *Conv) CollectBadRow(srcTable string, srcCols, vals []string) {
 // SampleBadRows returns a string-formatted list of rows that generated errors.
 // Returns at most n rows.
 func (conv *Conv) SampleBadRows(n int) []string {
+	conv.statsMu.Lock()
+	defer conv.statsMu.Unlock()
 	var l []string
 	for _, x := range conv.sampleBadRows.rows {
 		l = append(l, fmt.Sprintf("table=%s cols=%v data=%v\n", x.table, x.cols, x.vals))
@@ -259,11 +523,83 @@ func (conv *Conv) AddPrimaryKeys() {
 			ct.ColDefs[k] = ddl.ColumnDef{Name: k, T: ddl.Type{Name: ddl.Int64}}
 			ct.Pks = []ddl.IndexKey{{Col: k}}
 			conv.SpSchema[t] = ct
-			conv.SyntheticPKeys[t] = SyntheticPKey{k, 0}
+			conv.SyntheticPKeys[t] = SyntheticPKey{Col: k}
 		}
 	}
 }
 
+// NextSyntheticPKey returns the synthetic primary key column of spTable
+// and its value for the next row, or false if spTable doesn't have a
+// synthetic primary key. Values are generated by the KeyGenerator of the
+// key (by default, bit-reversed sequence numbers, so that rows are spread
+// across the key space). Random keys are reproducible if a seed is set
+// (see SetSeed).
+func (conv *Conv) NextSyntheticPKey(spTable string) (string, interface{}, bool) {
+	conv.schemaMu.Lock()
+	defer conv.schemaMu.Unlock()
+	aux, ok := conv.SyntheticPKeys[spTable]
+	if !ok {
+		return "", nil, false
+	}
+	g, err := keyGenerator(aux.Generator)
+	if err != nil {
+		// The generator was registered by a plugin that isn't loaded.
+		conv.Unexpected(err.Error())
+		return "", nil, false
+	}
+	var v interface{}
+	if rg, ok := g.(RandomKeyGenerator); ok && conv.rands != nil {
+		v = rg.NextRandom(aux.Sequence, conv.randFor(spTable))
+	} else {
+		v = g.Next(aux.Sequence)
+	}
+	aux.Sequence++
+	conv.SyntheticPKeys[spTable] = aux
+	return aux.Col, v, true
+}
+
+// SetSrcTable adds (or replaces) the source schema of a table.
+func (conv *Conv) SetSrcTable(t schema.Table) {
+	conv.schemaMu.Lock()
+	defer conv.schemaMu.Unlock()
+	if _, ok := conv.SrcSchema[t.Name]; !ok {
+		conv.SrcOrder = append(conv.SrcOrder, t.Name)
+	}
+	conv.SrcSchema[t.Name] = t
+}
+
+// SetSrcEnum records the values of source enum type name.
+func (conv *Conv) SetSrcEnum(name string, values []string) {
+	conv.schemaMu.Lock()
+	defer conv.schemaMu.Unlock()
+	if conv.SrcEnums == nil {
+		conv.SrcEnums = make(map[string][]string)
+	}
+	conv.SrcEnums[name] = values
+}
+
+// SetIssues sets the schema conversion issues of the columns of srcTable.
+func (conv *Conv) SetIssues(srcTable string, issues map[string][]SchemaIssue) {
+	conv.schemaMu.Lock()
+	defer conv.schemaMu.Unlock()
+	conv.Issues[srcTable] = issues
+}
+
+// SetColumnIssues sets the schema conversion issues of srcCol of
+// srcTable. Nil issues remove those of the column.
+func (conv *Conv) SetColumnIssues(srcTable, srcCol string, issues []SchemaIssue) {
+	conv.schemaMu.Lock()
+	defer conv.schemaMu.Unlock()
+	if issues == nil {
+		delete(conv.Issues[srcTable], srcCol)
+		return
+	}
+	if conv.Issues[srcTable] == nil {
+		conv.Issues[srcTable] = make(map[string][]SchemaIssue)
+	}
+	conv.Issues[srcTable][srcCol] = issues
+}
+
 // SetLocation configures the timezone for data conversion.
 func (conv *Conv) SetLocation(loc *time.Location) {
 	conv.Location = loc
@@ -293,11 +629,17 @@ func (conv *Conv) buildPrimaryKey(spTable string) string {
 // because we process dump data twice.
 func (conv *Conv) Unexpected(u string) {
 	VerbosePrintf("Unexpected condition: %s\n", u)
+	conv.statsMu.Lock()
+	defer conv.statsMu.Unlock()
 	// Limit size of unexpected map. If over limit, then only
 	// update existing entries.
-	if _, ok := conv.Stats.Unexpected[u]; ok || len(conv.Stats.Unexpected) < 1000 {
+	_, seen := conv.Stats.Unexpected[u]
+	if seen || len(conv.Stats.Unexpected) < 1000 {
 		conv.Stats.Unexpected[u]++
 	}
+	if !seen && conv.progressSink != nil {
+		conv.progressSink(ProgressEvent{Time: time.Now(), Kind: ErrorEvent, Table: conv.progressTable, Detail: u})
+	}
 }
 
 // StatsAddRow increments the count of rows for 'srcTable' if b is
@@ -310,15 +652,36 @@ func (conv *Conv) Unexpected(u string) {
 // otherwise stats will be dropped.
 func (conv *Conv) StatsAddRow(srcTable string, b bool) {
 	if b {
+		conv.statsMu.Lock()
 		conv.Stats.Rows[srcTable]++
+		conv.statsMu.Unlock()
 	}
 }
 
+// StatsAddRows adds n to the count of rows for 'srcTable' e.g. when
+// the number of rows is known before they are processed.
+func (conv *Conv) StatsAddRows(srcTable string, n int64) {
+	conv.statsMu.Lock()
+	defer conv.statsMu.Unlock()
+	conv.Stats.Rows[srcTable] += n
+}
+
+// StatsAddTableBadRows counts all rows of 'srcTable' as bad rows, for
+// tables whose data can't be converted at all.
+func (conv *Conv) StatsAddTableBadRows(srcTable string) {
+	conv.statsMu.Lock()
+	defer conv.statsMu.Unlock()
+	conv.Stats.BadRows[srcTable] += conv.Stats.Rows[srcTable]
+	conv.checkErrorBudget(srcTable)
+}
+
 // statsAddGoodRow increments the good-row stats for 'srcTable' if b
 // is true.  See StatsAddRow comments for context.
 func (conv *Conv) statsAddGoodRow(srcTable string, b bool) {
 	if b {
+		conv.statsMu.Lock()
 		conv.Stats.GoodRows[srcTable]++
+		conv.statsMu.Unlock()
 	}
 }
 
@@ -326,10 +689,75 @@ func (conv *Conv) statsAddGoodRow(srcTable string, b bool) {
 // true.  See StatsAddRow comments for context.
 func (conv *Conv) StatsAddBadRow(srcTable string, b bool) {
 	if b {
+		conv.statsMu.Lock()
+		conv.startTable(srcTable)
 		conv.Stats.BadRows[srcTable]++
+		conv.checkErrorBudget(srcTable)
+		conv.statsMu.Unlock()
+	}
+}
+
+// StatsAddSpecialValue increments the special-value stats for
+// 'srcTable' and 'srcCol' if b is true. See StatsAddRow comments
+// for context.
+func (conv *Conv) StatsAddSpecialValue(srcTable, srcCol string, b bool) {
+	if b {
+		conv.statsMu.Lock()
+		defer conv.statsMu.Unlock()
+		if conv.Stats.SpecialValues[srcTable] == nil {
+			conv.Stats.SpecialValues[srcTable] = make(map[string]int64)
+		}
+		conv.Stats.SpecialValues[srcTable][srcCol]++
+	}
+}
+
+// statsAddOversize increments the oversize-value stats for 'srcTable'
+// and 'spCol'. Only called in data mode (from WriteRow).
+func (conv *Conv) statsAddOversize(srcTable, spCol string) {
+	conv.statsMu.Lock()
+	defer conv.statsMu.Unlock()
+	if conv.Stats.Oversize[srcTable] == nil {
+		conv.Stats.Oversize[srcTable] = make(map[string]int64)
+	}
+	conv.Stats.Oversize[srcTable][spCol]++
+}
+
+// statsAddLongString increments the long-string stats for 'srcTable'
+// and 'spCol', and records the length n of the value. Only called in
+// data mode (from WriteRow).
+func (conv *Conv) statsAddLongString(srcTable, spCol string, n int64) {
+	conv.statsMu.Lock()
+	defer conv.statsMu.Unlock()
+	if conv.Stats.LongStrings == nil {
+		conv.Stats.LongStrings = make(map[string]map[string]int64)
+		conv.Stats.LongestStrings = make(map[string]map[string]int64)
+	}
+	if conv.Stats.LongStrings[srcTable] == nil {
+		conv.Stats.LongStrings[srcTable] = make(map[string]int64)
+		conv.Stats.LongestStrings[srcTable] = make(map[string]int64)
+	}
+	conv.Stats.LongStrings[srcTable][spCol]++
+	if n > conv.Stats.LongestStrings[srcTable][spCol] {
+		conv.Stats.LongestStrings[srcTable][spCol] = n
+	}
+}
+
+// statsAddOrphan increments the orphaned-row stats for 'srcTable' and
+// foreign key 'fk'. Only called in data mode (from ResolveOrphans).
+func (conv *Conv) statsAddOrphan(srcTable, fk string) {
+	conv.statsMu.Lock()
+	defer conv.statsMu.Unlock()
+	if conv.Stats.Orphans == nil {
+		conv.Stats.Orphans = make(map[string]map[string]int64)
+	}
+	if conv.Stats.Orphans[srcTable] == nil {
+		conv.Stats.Orphans[srcTable] = make(map[string]int64)
 	}
+	conv.Stats.Orphans[srcTable][fk]++
 }
 
+// getStatementStat returns the stats for statement type s. The caller
+// must hold statsMu.
 func (conv *Conv) getStatementStat(s string) *statementStat {
 	if conv.Stats.Statement[s] == nil {
 		conv.Stats.Statement[s] = &statementStat{}
@@ -341,7 +769,9 @@ func (conv *Conv) getStatementStat(s string) *statementStat {
 func (conv *Conv) SkipStatement(stmtType string) {
 	if conv.SchemaMode() { // Record statement stats on first pass only.
 		VerbosePrintf("Skipping statement: %s\n", stmtType)
+		conv.statsMu.Lock()
 		conv.getStatementStat(stmtType).Skip++
+		conv.statsMu.Unlock()
 	}
 }
 
@@ -349,21 +779,27 @@ func (conv *Conv) SkipStatement(stmtType string) {
 func (conv *Conv) ErrorInStatement(stmtType string) {
 	if conv.SchemaMode() { // Record statement stats on first pass only.
 		VerbosePrintf("Error processing statement: %s\n", stmtType)
+		conv.statsMu.Lock()
 		conv.getStatementStat(stmtType).Error++
+		conv.statsMu.Unlock()
 	}
 }
 
 // SchemaStatement increments the schema statement stats for 'stmtType'.
 func (conv *Conv) SchemaStatement(stmtType string) {
 	if conv.SchemaMode() { // Record statement stats on first pass only.
+		conv.statsMu.Lock()
 		conv.getStatementStat(stmtType).Schema++
+		conv.statsMu.Unlock()
 	}
 }
 
 // DataStatement increments the data statement stats for 'stmtType'.
 func (conv *Conv) DataStatement(stmtType string) {
 	if conv.SchemaMode() { // Record statement stats on first pass only.
+		conv.statsMu.Lock()
 		conv.getStatementStat(stmtType).Data++
+		conv.statsMu.Unlock()
 	}
 }
 
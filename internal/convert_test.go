@@ -15,6 +15,7 @@
 package internal
 
 import (
+	"math/bits"
 	"testing"
 
 	pg_query "github.com/lfittl/pg_query_go"
@@ -114,3 +115,15 @@ func parse(t *testing.T, s string) []nodes.Node {
 	assert.Nil(t, err, "Failed to parse")
 	return tree.Statements
 }
+
+func TestNextSyntheticPKey(t *testing.T) {
+	conv := MakeConv()
+	conv.SyntheticPKeys["table"] = SyntheticPKey{Col: "synth_id", Sequence: 1}
+	col, v, ok := conv.NextSyntheticPKey("table")
+	assert.True(t, ok)
+	assert.Equal(t, "synth_id", col)
+	assert.Equal(t, int64(bits.Reverse64(1)), v)
+	assert.Equal(t, int64(2), conv.SyntheticPKeys["table"].Sequence)
+	_, _, ok = conv.NextSyntheticPKey("other")
+	assert.False(t, ok)
+}
@@ -0,0 +1,59 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package internal
+
+import (
+	"fmt"
+)
+
+// SetDataTables restricts data conversion to the source tables in tables,
+// so that the data of a database can be loaded by several runs, each
+// loading a batch of tables. An empty list converts the data of all
+// tables. Rows of merged tables are only resolved (see ResolveMerges),
+// and orphan rows only detected (see OrphanPolicy), among the tables of
+// a batch.
+func (conv *Conv) SetDataTables(tables []string) error {
+	if len(tables) == 0 {
+		conv.dataTables = nil
+		return nil
+	}
+	m := make(map[string]bool)
+	for _, t := range tables {
+		_, ok := conv.SrcSchema[t]
+		if !ok && conv.spill != nil {
+			_, ok = conv.spill.files[t]
+		}
+		if !ok {
+			return fmt.Errorf("can't load data of table %s: no such source table", t)
+		}
+		m[t] = true
+	}
+	conv.dataTables = m
+	return nil
+}
+
+// SkipData returns true if the data of srcTable isn't converted (see
+// SetDataTables and SkipColumnlessTables), or if data conversion was
+// aborted (see Aborted). Source packages check it before reading the rows
+// of a table, and skip the rows of dumps that belong to other tables.
+func (conv *Conv) SkipData(srcTable string) bool {
+	if _, ok := conv.SkippedTables[srcTable]; ok {
+		return true
+	}
+	if conv.Aborted() != nil {
+		return true
+	}
+	return conv.dataTables != nil && !conv.dataTables[srcTable]
+}
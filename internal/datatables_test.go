@@ -0,0 +1,69 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package internal
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSetDataTables(t *testing.T) {
+	conv := remodelTestConv()
+	assert.False(t, conv.SkipData("users"))
+	assert.False(t, conv.SkipData("settings"))
+
+	assert.Nil(t, conv.SetDataTables([]string{"settings"}))
+	assert.True(t, conv.SkipData("users"))
+	assert.False(t, conv.SkipData("settings"))
+
+	assert.Nil(t, conv.SetDataTables(nil))
+	assert.False(t, conv.SkipData("users"))
+
+	err := conv.SetDataTables([]string{"users", "orders"})
+	assert.EqualError(t, err, "can't load data of table orders: no such source table")
+	assert.False(t, conv.SkipData("users"))
+}
+
+func TestSetDataTables_SkippedAndSpilled(t *testing.T) {
+	dir, err := ioutil.TempDir("", "datatables-test-")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+
+	conv := remodelTestConv()
+	assert.Nil(t, conv.SpillTo(dir))
+	assert.Nil(t, conv.SpillTable("settings"))
+	// Spilled tables can be loaded, and listing a table twice is harmless.
+	assert.Nil(t, conv.SetDataTables([]string{"settings", "settings"}))
+	assert.False(t, conv.SkipData("settings"))
+	assert.True(t, conv.SkipData("users"))
+	assert.Nil(t, conv.SetDataTables([]string{}))
+	assert.False(t, conv.SkipData("users"))
+
+	// Skipped tables stay skipped, even if listed.
+	conv.SkippedTables = map[string]string{"users": "no columns"}
+	assert.Nil(t, conv.SetDataTables([]string{"users", "settings"}))
+	assert.True(t, conv.SkipData("users"))
+	assert.False(t, conv.SkipData("settings"))
+
+	// Aborted conversions skip all tables.
+	conv.Policies.ErrorBudget.MaxBadRowsTotal = 1
+	conv.StatsAddBadRow("settings", true)
+	conv.StatsAddBadRow("settings", true)
+	assert.NotNil(t, conv.Aborted())
+	assert.True(t, conv.SkipData("settings"))
+}
@@ -0,0 +1,231 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package internal
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"cloud.google.com/go/civil"
+	"github.com/cloudspannerecosystem/harbourbridge/spanner/ddl"
+)
+
+// DateRule remaps a sentinel value of DATE and TIMESTAMP columns, such as
+// 9999-12-31 or 1900-01-01, which legacy systems often use instead of
+// NULL for "no end date" or "unknown". Rules are given in
+// Policies.DateRules, and the first rule matching a value applies.
+type DateRule struct {
+	// Columns are the Spanner columns the rule applies to, given as
+	// table.column. Empty for all DATE and TIMESTAMP columns.
+	Columns []string `json:",omitempty"`
+	// Match is the value remapped: a date (YYYY-MM-DD), which matches
+	// that date and all timestamps of that day (in the time zone of data
+	// conversion, see SetLocation), or an RFC 3339 timestamp, which only
+	// matches that instant.
+	Match string
+	// Replace is the value written instead, given as Match (dates are
+	// midnight in TIMESTAMP columns, and timestamps are truncated to
+	// their date in DATE columns), or empty for NULL.
+	Replace string `json:",omitempty"`
+}
+
+func (r DateRule) String() string {
+	s := r.Match + " -> "
+	if r.Replace == "" {
+		s += "NULL"
+	} else {
+		s += r.Replace
+	}
+	if len(r.Columns) > 0 {
+		s += " for " + strings.Join(r.Columns, ", ")
+	}
+	return s
+}
+
+// dateRule is a parsed DateRule.
+type dateRule struct {
+	cols      map[string]bool // Spanner table.column, nil for all columns.
+	match     dateValue
+	replace   dateValue
+	nullValue bool // Replace by NULL.
+	desc      string
+}
+
+// dateValue is a date, or a timestamp if isTime is set.
+type dateValue struct {
+	d      civil.Date
+	t      time.Time
+	isTime bool
+}
+
+func parseDateValue(s string) (dateValue, error) {
+	if d, err := civil.ParseDate(s); err == nil {
+		return dateValue{d: d}, nil
+	}
+	t, err := time.Parse(time.RFC3339Nano, s)
+	if err != nil {
+		return dateValue{}, fmt.Errorf("%q is neither a date (YYYY-MM-DD) nor an RFC 3339 timestamp", s)
+	}
+	return dateValue{t: t, isTime: true}, nil
+}
+
+// CheckDateRules checks that conv.Policies.DateRules are valid for the
+// schema of conv: values must be dates or timestamps, and columns must be
+// DATE or TIMESTAMP Spanner columns.
+func (conv *Conv) CheckDateRules() error {
+	rules, err := parseDateRules(conv.Policies.DateRules)
+	if err != nil {
+		return err
+	}
+	for _, r := range conv.Policies.DateRules {
+		for _, c := range r.Columns {
+			i := strings.LastIndex(c, ".")
+			if i < 0 {
+				return fmt.Errorf("bad date rule %s: columns must be given as table.column", r)
+			}
+			cd, ok := conv.SpSchema[c[:i]].ColDefs[c[i+1:]]
+			if !ok {
+				return fmt.Errorf("bad date rule %s: unknown column %s", r, c)
+			}
+			if (cd.T.Name != ddl.Date && cd.T.Name != ddl.Timestamp) || cd.T.IsArray {
+				return fmt.Errorf("bad date rule %s: column %s isn't a DATE or TIMESTAMP column", r, c)
+			}
+		}
+	}
+	conv.dateRules = rules
+	return nil
+}
+
+func parseDateRules(l []DateRule) ([]dateRule, error) {
+	var rules []dateRule
+	for _, r := range l {
+		dr := dateRule{nullValue: r.Replace == "", desc: r.String()}
+		var err error
+		if dr.match, err = parseDateValue(r.Match); err != nil {
+			return nil, fmt.Errorf("bad date rule %s: %w", r, err)
+		}
+		if !dr.nullValue {
+			if dr.replace, err = parseDateValue(r.Replace); err != nil {
+				return nil, fmt.Errorf("bad date rule %s: %w", r, err)
+			}
+		}
+		if len(r.Columns) > 0 {
+			dr.cols = make(map[string]bool)
+			for _, c := range r.Columns {
+				dr.cols[c] = true
+			}
+		}
+		rules = append(rules, dr)
+	}
+	return rules, nil
+}
+
+// remap returns the value written instead of v (a DATE or TIMESTAMP
+// value) if the rule matches it.
+func (r dateRule) remap(v interface{}, loc *time.Location) (interface{}, bool) {
+	switch v := v.(type) {
+	case civil.Date:
+		if r.match.isTime || r.match.d != v {
+			return nil, false
+		}
+		switch {
+		case r.nullValue:
+			return nil, true
+		case r.replace.isTime:
+			return civil.DateOf(r.replace.t.In(loc)), true
+		}
+		return r.replace.d, true
+	case time.Time:
+		if r.match.isTime && !r.match.t.Equal(v) || !r.match.isTime && civil.DateOf(v.In(loc)) != r.match.d {
+			return nil, false
+		}
+		switch {
+		case r.nullValue:
+			return nil, true
+		case r.replace.isTime:
+			return r.replace.t, true
+		}
+		return r.replace.d.In(loc), true
+	}
+	return nil, false
+}
+
+// applyDateRules applies conv.Policies.DateRules to the DATE and
+// TIMESTAMP values of a row, and returns the values to write.
+func (conv *Conv) applyDateRules(srcTable, spTable string, spCols []string, spVals []interface{}) []interface{} {
+	conv.rowsMu.Lock()
+	if conv.dateRules == nil {
+		// Rules are checked by CheckDateRules.
+		conv.dateRules, _ = parseDateRules(conv.Policies.DateRules)
+	}
+	rules := conv.dateRules
+	conv.rowsMu.Unlock()
+	vals := spVals
+	copied := false
+	for i, c := range spCols {
+		for _, r := range rules {
+			if r.cols != nil && !r.cols[spTable+"."+c] {
+				continue
+			}
+			v, ok := r.remap(spVals[i], conv.Location)
+			if !ok {
+				continue
+			}
+			if !copied {
+				// Don't modify the caller's slice.
+				vals = append([]interface{}{}, spVals...)
+				copied = true
+			}
+			vals[i] = v
+			conv.statsAddDateRule(srcTable, r.desc)
+			break
+		}
+	}
+	return vals
+}
+
+// statsAddDateRule increments the count of values of 'srcTable' remapped
+// by the date rule described by 'rule'. Only called in data mode (from
+// WriteRow).
+func (conv *Conv) statsAddDateRule(srcTable, rule string) {
+	conv.statsMu.Lock()
+	defer conv.statsMu.Unlock()
+	if conv.Stats.DateRules == nil {
+		conv.Stats.DateRules = make(map[string]map[string]int64)
+	}
+	if conv.Stats.DateRules[srcTable] == nil {
+		conv.Stats.DateRules[srcTable] = make(map[string]int64)
+	}
+	conv.Stats.DateRules[srcTable][rule]++
+}
+
+// buildDateRulesBody lists, for each date rule, how many values of
+// srcTable it remapped during data conversion.
+func buildDateRulesBody(conv *Conv, srcTable string) []tableReportBody {
+	counts := conv.Stats.DateRules[srcTable]
+	if len(counts) == 0 {
+		return nil
+	}
+	var l []string
+	seen := make(map[string]bool) // Rules can be repeated.
+	for _, r := range conv.Policies.DateRules {
+		if n, ok := counts[r.String()]; ok && !seen[r.String()] {
+			l = append(l, fmt.Sprintf("Rule %s: %d values were remapped", r, n))
+			seen[r.String()] = true
+		}
+	}
+	return []tableReportBody{{Heading: "Remapped dates", Lines: l}}
+}
@@ -0,0 +1,86 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package internal
+
+import (
+	"testing"
+	"time"
+
+	"cloud.google.com/go/civil"
+	"github.com/cloudspannerecosystem/harbourbridge/spanner/ddl"
+	"github.com/stretchr/testify/assert"
+)
+
+func dateRulesTestConv() *Conv {
+	conv := MakeConv()
+	conv.SpSchema["t"] = ddl.CreateTable{
+		Name:     "t",
+		ColNames: []string{"k", "d", "ts", "s"},
+		ColDefs: map[string]ddl.ColumnDef{
+			"k":  ddl.ColumnDef{Name: "k", T: ddl.Type{Name: ddl.Int64}, NotNull: true},
+			"d":  ddl.ColumnDef{Name: "d", T: ddl.Type{Name: ddl.Date}},
+			"ts": ddl.ColumnDef{Name: "ts", T: ddl.Type{Name: ddl.Timestamp}},
+			"s":  ddl.ColumnDef{Name: "s", T: ddl.Type{Name: ddl.String, Len: ddl.MaxLength}},
+		},
+		Pks: []ddl.IndexKey{ddl.IndexKey{Col: "k"}}}
+	conv.ToSpanner["src"] = NameAndCols{Name: "t", Cols: map[string]string{"k": "k", "d": "d", "ts": "ts", "s": "s"}}
+	conv.SetLocation(time.UTC)
+	return conv
+}
+
+func TestWriteRow_DateRules(t *testing.T) {
+	conv := dateRulesTestConv()
+	conv.Policies.DateRules = []DateRule{
+		{Match: "9999-12-31"},
+		{Match: "1900-01-01", Replace: "1970-01-01", Columns: []string{"t.d"}},
+		{Match: "2000-01-01T00:00:00Z", Replace: "2000-01-02T00:00:00Z"},
+	}
+	assert.Nil(t, conv.CheckDateRules())
+	conv.SetDataMode()
+	var got [][]interface{}
+	conv.SetDataSink(func(table string, cols []string, vals []interface{}) {
+		got = append(got, vals)
+	})
+	cols := []string{"k", "d", "ts", "s"}
+	forever := civil.Date{Year: 9999, Month: time.December, Day: 31}
+	unknown := civil.Date{Year: 1900, Month: time.January, Day: 1}
+	conv.WriteRow("src", "t", cols, []interface{}{int64(1), forever, time.Date(9999, time.December, 31, 23, 59, 59, 0, time.UTC), "9999-12-31"})
+	conv.WriteRow("src", "t", cols, []interface{}{int64(2), unknown, unknown.In(time.UTC), "x"})
+	conv.WriteRow("src", "t", cols, []interface{}{int64(3), nil, time.Date(2000, time.January, 1, 0, 0, 0, 0, time.UTC), "x"})
+	assert.Equal(t, [][]interface{}{
+		{int64(1), nil, nil, "9999-12-31"},
+		{int64(2), civil.Date{Year: 1970, Month: time.January, Day: 1}, unknown.In(time.UTC), "x"},
+		{int64(3), nil, time.Date(2000, time.January, 2, 0, 0, 0, 0, time.UTC), "x"},
+	}, got)
+	assert.Equal(t, map[string]int64{
+		"9999-12-31 -> NULL":                           2,
+		"1900-01-01 -> 1970-01-01 for t.d":             1,
+		"2000-01-01T00:00:00Z -> 2000-01-02T00:00:00Z": 1,
+	}, conv.Stats.DateRules["src"])
+}
+
+func TestCheckDateRules(t *testing.T) {
+	for _, r := range []DateRule{
+		{Match: "31/12/9999"},
+		{Match: "9999-12-31", Replace: "never"},
+		{Match: "9999-12-31", Columns: []string{"d"}},
+		{Match: "9999-12-31", Columns: []string{"t.missing"}},
+		{Match: "9999-12-31", Columns: []string{"t.s"}},
+	} {
+		conv := dateRulesTestConv()
+		conv.Policies.DateRules = []DateRule{r}
+		assert.NotNil(t, conv.CheckDateRules(), r.String())
+	}
+}
@@ -0,0 +1,59 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package internal
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/cloudspannerecosystem/harbourbridge/spanner/ddl"
+)
+
+// CheckDDL checks the syntax of the DDL statements of conv.SpSchema
+// printed with c (see ddl.CheckSyntax), so that invalid statements, e.g.
+// after editing the schema, are reported with the table and column that
+// produced them before any statement is sent to Spanner.
+func (conv *Conv) CheckDDL(c ddl.Config) error {
+	var l []string
+	for _, st := range conv.GetStatements(c) {
+		err := ddl.CheckSyntax(st.DDL, c.PostgreSQL)
+		if err == nil {
+			continue
+		}
+		where := st.Kind
+		if st.Name != "" {
+			where += " " + st.Name
+		}
+		if st.Table != "" && st.Kind != ddl.TableStatement {
+			where += " of table " + st.Table
+		}
+		src, fromSrc := conv.ToSource[st.Table]
+		var se *ddl.SyntaxError
+		if errors.As(err, &se) && se.Column != "" {
+			where += ", column " + se.Column
+			if srcCol, ok := src.Cols[se.Column]; ok && fromSrc {
+				where += fmt.Sprintf(" (source column %s.%s)", src.Name, srcCol)
+			}
+		} else if fromSrc {
+			where += fmt.Sprintf(" (source table %s)", src.Name)
+		}
+		l = append(l, fmt.Sprintf("%s: %s in statement:\n%s", where, err, st.DDL))
+	}
+	if len(l) > 0 {
+		return fmt.Errorf("invalid DDL for %s", strings.Join(l, "\nand for "))
+	}
+	return nil
+}
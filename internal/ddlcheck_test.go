@@ -0,0 +1,49 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package internal
+
+import (
+	"testing"
+
+	"github.com/cloudspannerecosystem/harbourbridge/spanner/ddl"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCheckDDL(t *testing.T) {
+	conv := MakeConv()
+	conv.SpSchema["orders"] = ddl.CreateTable{
+		Name:     "orders",
+		ColNames: []string{"id", "note"},
+		ColDefs: map[string]ddl.ColumnDef{
+			"id":   ddl.ColumnDef{Name: "id", T: ddl.Type{Name: ddl.Int64}, NotNull: true},
+			"note": ddl.ColumnDef{Name: "note", T: ddl.Type{Name: ddl.String, Len: 100}},
+		},
+		Pks:     []ddl.IndexKey{ddl.IndexKey{Col: "id"}},
+		Indexes: []ddl.CreateIndex{{Name: "orders_note", Table: "orders", Keys: []ddl.IndexKey{{Col: "note"}}}},
+	}
+	conv.ToSource["orders"] = NameAndCols{Name: "ORDERS", Cols: map[string]string{"id": "ID", "note": "NOTE"}}
+	c := ddl.Config{ProtectIds: true, Tables: true, ForeignKeys: true}
+	assert.Nil(t, conv.CheckDDL(c))
+
+	// E.g. after editing the schema.
+	ct := conv.SpSchema["orders"]
+	ct.ColDefs["note"] = ddl.ColumnDef{Name: "note", T: ddl.Type{Name: ddl.String, Len: 0}}
+	ct.Indexes[0].Name = "orders note"
+	err := conv.CheckDDL(c)
+	assert.NotNil(t, err)
+	assert.Contains(t, err.Error(), "table orders, column note (source column ORDERS.NOTE): syntax error at position")
+	assert.Contains(t, err.Error(), "invalid length 0 for STRING")
+	assert.Contains(t, err.Error(), "index orders note of table orders (source table ORDERS)")
+}
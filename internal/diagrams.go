@@ -0,0 +1,305 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package internal
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// DiagramFormat is a format of schema diagrams, for reviewing the data
+// model visually.
+type DiagramFormat int
+
+const (
+	// DBMLDiagram is DBML (https://dbml.dbdiagram.io), as used by
+	// dbdiagram.io and dbdocs.
+	DBMLDiagram DiagramFormat = iota
+	// MermaidDiagram is a Mermaid entity relationship diagram, which
+	// GitHub and GitLab render in Markdown files.
+	MermaidDiagram
+)
+
+var diagramFormatNames = map[DiagramFormat]string{
+	DBMLDiagram:    "dbml",
+	MermaidDiagram: "mermaid",
+}
+
+func (f DiagramFormat) String() string {
+	if s, ok := diagramFormatNames[f]; ok {
+		return s
+	}
+	return fmt.Sprintf("DiagramFormat(%d)", int(f))
+}
+
+// Extension returns the file extension of diagrams in format f.
+func (f DiagramFormat) Extension() string {
+	if f == MermaidDiagram {
+		return "mmd"
+	}
+	return "dbml"
+}
+
+// Diagrams specifies the schema diagrams to write.
+type Diagrams struct {
+	Formats []DiagramFormat
+	Source  bool // If true, also write diagrams of the source schema.
+}
+
+// ParseDiagramFormats maps a comma-separated list of diagram format names
+// (as used on the command line) to DiagramFormats.
+func ParseDiagramFormats(s string) ([]DiagramFormat, error) {
+	var l []DiagramFormat
+	for _, name := range strings.Split(s, ",") {
+		name = strings.ToLower(strings.TrimSpace(name))
+		if name == "" {
+			continue
+		}
+		found := false
+		for f, n := range diagramFormatNames {
+			if n == name {
+				l = append(l, f)
+				found = true
+			}
+		}
+		if !found {
+			return nil, fmt.Errorf("unknown diagram format %q (accepted values are \"dbml\" and \"mermaid\")", name)
+		}
+	}
+	return l, nil
+}
+
+// diagramTable is a table of a schema diagram, in a form common to the
+// Spanner and source schemas.
+type diagramTable struct {
+	name string
+	cols []diagramCol
+	pks  []string
+	note string
+}
+
+type diagramCol struct {
+	name    string
+	typ     string
+	notNull bool
+	fk      bool
+}
+
+// diagramEdge is a foreign key, or the interleaving of table from in
+// table to.
+type diagramEdge struct {
+	from, to         string
+	fromCols, toCols []string
+	name             string
+	interleave       bool
+}
+
+// SpannerDiagram returns a diagram of conv.SpSchema in format f, with
+// foreign keys and interleaving.
+func SpannerDiagram(conv *Conv, f DiagramFormat) string {
+	var tables []diagramTable
+	var edges []diagramEdge
+	for _, t := range conv.SpTables() {
+		ct := conv.SpSchema[t]
+		fkCols := make(map[string]bool)
+		for _, fk := range ct.Fks {
+			for _, c := range fk.Columns {
+				fkCols[c] = true
+			}
+			edges = append(edges, diagramEdge{from: t, to: fk.ReferTable, fromCols: fk.Columns, toCols: fk.ReferColumns, name: fk.Name})
+		}
+		dt := diagramTable{name: t}
+		for _, c := range ct.ColNames {
+			cd := ct.ColDefs[c]
+			dt.cols = append(dt.cols, diagramCol{name: c, typ: cd.T.PrintColumnDefType(), notNull: cd.NotNull, fk: fkCols[c]})
+		}
+		for _, k := range ct.Pks {
+			dt.pks = append(dt.pks, k.Col)
+		}
+		if ct.Parent != "" {
+			dt.note = "Interleaved in " + ct.Parent
+			var keys []string
+			for _, k := range conv.SpSchema[ct.Parent].Pks {
+				keys = append(keys, k.Col)
+			}
+			edges = append(edges, diagramEdge{from: t, to: ct.Parent, fromCols: keys, toCols: keys, interleave: true})
+		}
+		tables = append(tables, dt)
+	}
+	return printDiagram(f, "Spanner schema", tables, edges)
+}
+
+// SourceDiagram returns a diagram of conv.SrcSchema in format f, with
+// foreign keys.
+func SourceDiagram(conv *Conv, f DiagramFormat) string {
+	var tables []diagramTable
+	var edges []diagramEdge
+	for _, t := range conv.SrcTables() {
+		st := conv.SrcSchema[t]
+		fkCols := make(map[string]bool)
+		for _, fk := range st.ForeignKeys {
+			for _, c := range fk.Columns {
+				fkCols[c] = true
+			}
+			edges = append(edges, diagramEdge{from: t, to: fk.ReferTable, fromCols: fk.Columns, toCols: fk.ReferColumns, name: fk.Name})
+		}
+		dt := diagramTable{name: t}
+		for _, c := range st.ColNames {
+			cd := st.ColDefs[c]
+			dt.cols = append(dt.cols, diagramCol{name: c, typ: cd.Type.Print(), notNull: cd.NotNull, fk: fkCols[c]})
+		}
+		for _, k := range st.PrimaryKeys {
+			dt.pks = append(dt.pks, k.Column)
+		}
+		tables = append(tables, dt)
+	}
+	return printDiagram(f, "Source schema", tables, edges)
+}
+
+func printDiagram(f DiagramFormat, title string, tables []diagramTable, edges []diagramEdge) string {
+	if f == MermaidDiagram {
+		return printMermaid(title, tables, edges)
+	}
+	return printDBML(title, tables, edges)
+}
+
+var dbmlIdRegexp = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// dbmlId quotes s if it isn't a plain DBML identifier.
+func dbmlId(s string) string {
+	if dbmlIdRegexp.MatchString(s) {
+		return s
+	}
+	return `"` + strings.ReplaceAll(s, `"`, `\"`) + `"`
+}
+
+func printDBML(title string, tables []diagramTable, edges []diagramEdge) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "// %s, generated by HarbourBridge.\n", title)
+	for _, t := range tables {
+		fmt.Fprintf(&b, "\nTable %s {\n", dbmlId(t.name))
+		for _, c := range t.cols {
+			var settings []string
+			if len(t.pks) == 1 && t.pks[0] == c.name {
+				settings = append(settings, "pk")
+			}
+			if c.notNull {
+				settings = append(settings, "not null")
+			}
+			s := ""
+			if len(settings) > 0 {
+				s = " [" + strings.Join(settings, ", ") + "]"
+			}
+			fmt.Fprintf(&b, "  %s %s%s\n", dbmlId(c.name), dbmlId(c.typ), s)
+		}
+		if len(t.pks) > 1 {
+			var l []string
+			for _, k := range t.pks {
+				l = append(l, dbmlId(k))
+			}
+			fmt.Fprintf(&b, "\n  indexes {\n    (%s) [pk]\n  }\n", strings.Join(l, ", "))
+		}
+		if t.note != "" {
+			fmt.Fprintf(&b, "\n  Note: '%s'\n", t.note)
+		}
+		b.WriteString("}\n")
+	}
+	if len(edges) > 0 {
+		b.WriteString("\n")
+	}
+	for _, e := range edges {
+		comment := ""
+		switch {
+		case e.interleave:
+			comment = " // INTERLEAVE IN PARENT " + e.to
+		case e.name != "":
+			comment = " // " + e.name
+		}
+		fmt.Fprintf(&b, "Ref: %s.%s > %s.%s%s\n", dbmlId(e.from), dbmlCols(e.fromCols), dbmlId(e.to), dbmlCols(e.toCols), comment)
+	}
+	return b.String()
+}
+
+func dbmlCols(cols []string) string {
+	if len(cols) == 1 {
+		return dbmlId(cols[0])
+	}
+	var l []string
+	for _, c := range cols {
+		l = append(l, dbmlId(c))
+	}
+	return "(" + strings.Join(l, ", ") + ")"
+}
+
+var (
+	mermaidNameRegexp = regexp.MustCompile(`[^A-Za-z0-9_-]`)
+	mermaidTypeRegexp = regexp.MustCompile(`[^A-Za-z0-9_()\[\]~-]`)
+)
+
+// mermaidName replaces the characters Mermaid doesn't accept in entity
+// and attribute names.
+func mermaidName(s string) string {
+	return mermaidNameRegexp.ReplaceAllString(s, "_")
+}
+
+// mermaidType rewrites type t for Mermaid, which writes generic types
+// such as ARRAY<INT64> as ARRAY~INT64~.
+func mermaidType(t string) string {
+	t = strings.NewReplacer("<", "~", ">", "~").Replace(t)
+	return mermaidTypeRegexp.ReplaceAllString(t, "_")
+}
+
+func printMermaid(title string, tables []diagramTable, edges []diagramEdge) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "%%%% %s, generated by HarbourBridge.\nerDiagram\n", title)
+	for _, t := range tables {
+		pks := make(map[string]bool)
+		for _, k := range t.pks {
+			pks[k] = true
+		}
+		fmt.Fprintf(&b, "    %s {\n", mermaidName(t.name))
+		for _, c := range t.cols {
+			var keys []string
+			if pks[c.name] {
+				keys = append(keys, "PK")
+			}
+			if c.fk {
+				keys = append(keys, "FK")
+			}
+			s := ""
+			if len(keys) > 0 {
+				s = " " + strings.Join(keys, ", ")
+			}
+			if c.notNull {
+				s += ` "NOT NULL"`
+			}
+			fmt.Fprintf(&b, "        %s %s%s\n", mermaidType(c.typ), mermaidName(c.name), s)
+		}
+		b.WriteString("    }\n")
+	}
+	for _, e := range edges {
+		if e.interleave {
+			fmt.Fprintf(&b, "    %s ||--o{ %s : \"INTERLEAVE IN PARENT\"\n", mermaidName(e.to), mermaidName(e.from))
+			continue
+		}
+		label := e.name
+		if label == "" {
+			label = "FOREIGN KEY"
+		}
+		fmt.Fprintf(&b, "    %s }o--|| %s : %q\n", mermaidName(e.from), mermaidName(e.to), label)
+	}
+	return b.String()
+}
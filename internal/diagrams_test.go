@@ -0,0 +1,128 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package internal
+
+import (
+	"testing"
+
+	"github.com/cloudspannerecosystem/harbourbridge/schema"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSpannerDiagram_DBML(t *testing.T) {
+	assert.Equal(t, `// Spanner schema, generated by HarbourBridge.
+
+Table albums {
+  singer_id INT64 [not null]
+  album_id INT64 [not null]
+  tags "ARRAY<STRING(MAX)>"
+  from TIMESTAMP [not null]
+
+  indexes {
+    (singer_id, album_id) [pk]
+  }
+
+  Note: 'Interleaved in singers'
+}
+
+Table singers {
+  singer_id INT64 [pk, not null]
+  name "STRING(100)" [not null]
+  table_name "STRING(MAX)"
+  born DATE
+}
+
+Ref: albums.singer_id > singers.singer_id
+Ref: albums.singer_id > singers.singer_id // INTERLEAVE IN PARENT singers
+`, SpannerDiagram(modelsConv(), DBMLDiagram))
+}
+
+func TestSpannerDiagram_Mermaid(t *testing.T) {
+	assert.Equal(t, `%% Spanner schema, generated by HarbourBridge.
+erDiagram
+    albums {
+        INT64 singer_id PK, FK "NOT NULL"
+        INT64 album_id PK "NOT NULL"
+        ARRAY~STRING(MAX)~ tags
+        TIMESTAMP from "NOT NULL"
+    }
+    singers {
+        INT64 singer_id PK "NOT NULL"
+        STRING(100) name "NOT NULL"
+        STRING(MAX) table_name
+        DATE born
+    }
+    albums }o--|| singers : "FOREIGN KEY"
+    singers ||--o{ albums : "INTERLEAVE IN PARENT"
+`, SpannerDiagram(modelsConv(), MermaidDiagram))
+}
+
+func TestSourceDiagram(t *testing.T) {
+	conv := MakeConv()
+	conv.SrcSchema["order items"] = schema.Table{
+		Name:     "order items",
+		ColNames: []string{"id", "order_id", "price"},
+		ColDefs: map[string]schema.Column{
+			"id":       {Name: "id", Type: schema.Type{Name: "bigint"}, NotNull: true},
+			"order_id": {Name: "order_id", Type: schema.Type{Name: "bigint"}},
+			"price":    {Name: "price", Type: schema.Type{Name: "numeric", Mods: []int64{6, 2}}},
+		},
+		PrimaryKeys: []schema.Key{{Column: "id"}},
+		ForeignKeys: []schema.ForeignKey{{Name: "fk_order", Columns: []string{"order_id"}, ReferTable: "orders", ReferColumns: []string{"id"}}},
+	}
+	conv.SrcSchema["orders"] = schema.Table{
+		Name:        "orders",
+		ColNames:    []string{"id"},
+		ColDefs:     map[string]schema.Column{"id": {Name: "id", Type: schema.Type{Name: "bigint"}, NotNull: true}},
+		PrimaryKeys: []schema.Key{{Column: "id"}},
+	}
+	assert.Equal(t, `// Source schema, generated by HarbourBridge.
+
+Table "order items" {
+  id bigint [pk, not null]
+  order_id bigint
+  price "numeric(6,2)"
+}
+
+Table orders {
+  id bigint [pk, not null]
+}
+
+Ref: "order items".order_id > orders.id // fk_order
+`, SourceDiagram(conv, DBMLDiagram))
+	assert.Equal(t, `%% Source schema, generated by HarbourBridge.
+erDiagram
+    order_items {
+        bigint id PK "NOT NULL"
+        bigint order_id FK
+        numeric(6_2) price
+    }
+    orders {
+        bigint id PK "NOT NULL"
+    }
+    order_items }o--|| orders : "fk_order"
+`, SourceDiagram(conv, MermaidDiagram))
+}
+
+func TestParseDiagramFormats(t *testing.T) {
+	l, err := ParseDiagramFormats("mermaid, DBML")
+	assert.Nil(t, err)
+	assert.Equal(t, []DiagramFormat{MermaidDiagram, DBMLDiagram}, l)
+	l, err = ParseDiagramFormats("")
+	assert.Nil(t, err)
+	assert.Nil(t, l)
+	_, err = ParseDiagramFormats("graphviz")
+	assert.NotNil(t, err)
+}
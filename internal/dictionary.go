@@ -0,0 +1,171 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package internal
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"fmt"
+
+	"github.com/cloudspannerecosystem/harbourbridge/spanner/ddl"
+)
+
+// DictionaryColumn dictionary-encodes a STRING Spanner column holding few
+// distinct values: its distinct values are moved to a lookup table with
+// columns id (INT64, the primary key) and value (the original type), and
+// the column becomes an INT64 column holding the id of its value, with a
+// foreign key to the lookup table (added after data conversion). Ids are
+// derived from the values (see DictionaryID), so runs of data conversion
+// over the same dictionary agree on them.
+type DictionaryColumn struct {
+	Table      string // Spanner table.
+	Column     string // STRING Spanner column to encode.
+	Dictionary string // Name of the lookup table, <Table>_<Column>_dict if empty.
+}
+
+// dictionary is the state of a lookup table during data conversion.
+type dictionary struct {
+	values  map[int64]string // Values written to the lookup table, by id.
+	encoded int64            // Number of values encoded.
+}
+
+// DictionaryID returns the id of value in the lookup table of a
+// dictionary-encoded column: the first 63 bits of its SHA-256 hash.
+func DictionaryID(value string) int64 {
+	h := sha256.Sum256([]byte(value))
+	return int64(binary.BigEndian.Uint64(h[:8]) >> 1)
+}
+
+func (conv *Conv) applyDictionary(d DictionaryColumn) error {
+	if d.Dictionary == "" {
+		d.Dictionary = d.Table + "_" + d.Column + "_dict"
+	}
+	if x, ok := conv.DictCols[d.Table+"."+d.Column]; ok {
+		if x.Dictionary != d.Dictionary {
+			return fmt.Errorf("it is already encoded with table %s", x.Dictionary)
+		}
+		return nil // Already applied.
+	}
+	ct, ok := conv.SpSchema[d.Table]
+	if !ok {
+		return fmt.Errorf("unknown table")
+	}
+	if _, ok := conv.ToSource[d.Table]; !ok {
+		return fmt.Errorf("only columns of tables converted from a source table can be encoded")
+	}
+	cd, ok := ct.ColDefs[d.Column]
+	if !ok {
+		return fmt.Errorf("unknown column")
+	}
+	if cd.T.Name != ddl.String || cd.T.IsArray {
+		return fmt.Errorf("it isn't a STRING column")
+	}
+	if !conv.canChangeColType(d.Table, d.Column) {
+		return fmt.Errorf("key columns and columns used by foreign keys can't be encoded")
+	}
+	if _, ok := conv.SpSchema[d.Dictionary]; ok {
+		return fmt.Errorf("table %s already exists", d.Dictionary)
+	}
+	if _, changed := FixName(d.Dictionary); changed {
+		return fmt.Errorf("%s is not a valid Spanner table name", d.Dictionary)
+	}
+	conv.SpSchema[d.Dictionary] = ddl.CreateTable{
+		Name:     d.Dictionary,
+		ColNames: []string{"id", "value"},
+		ColDefs: map[string]ddl.ColumnDef{
+			"id":    {Name: "id", T: ddl.Type{Name: ddl.Int64}, NotNull: true},
+			"value": {Name: "value", T: cd.T, NotNull: true},
+		},
+		Pks:     []ddl.IndexKey{{Col: "id"}},
+		Comment: fmt.Sprintf("Distinct values of column %s of table %s", d.Column, d.Table),
+	}
+	cd.T = ddl.Type{Name: ddl.Int64}
+	cd.Comment = fmt.Sprintf("Id of the value in table %s", d.Dictionary)
+	ct.ColDefs[d.Column] = cd
+	ct.Fks = append(ct.Fks, ddl.Foreignkey{Name: conv.unusedName(d.Dictionary + "_fk"), Columns: []string{d.Column}, ReferTable: d.Dictionary, ReferColumns: []string{"id"}})
+	conv.SpSchema[d.Table] = ct
+	if conv.DictCols == nil {
+		conv.DictCols = make(map[string]DictionaryColumn)
+	}
+	conv.DictCols[d.Table+"."+d.Column] = d
+	return nil
+}
+
+// encodeDictionaries replaces the values of the dictionary-encoded columns
+// of a row of spTable by their ids, and writes the values that haven't
+// been seen yet to their lookup tables. Lookup rows are written to the
+// replace sink, if configured, so that they can be written again by later
+// runs.
+func (conv *Conv) encodeDictionaries(spTable string, cols []string, vals []interface{}) ([]interface{}, error) {
+	conv.rowsMu.Lock()
+	defer conv.rowsMu.Unlock()
+	var encoded []interface{}
+	for i, c := range cols {
+		d, ok := conv.DictCols[spTable+"."+c]
+		if !ok || vals[i] == nil {
+			continue
+		}
+		s, ok := vals[i].(string)
+		if !ok {
+			return nil, fmt.Errorf("can't encode value of type %T of column %s", vals[i], c)
+		}
+		if conv.dicts == nil {
+			conv.dicts = make(map[string]*dictionary)
+		}
+		dict, ok := conv.dicts[d.Dictionary]
+		if !ok {
+			dict = &dictionary{values: make(map[int64]string)}
+			conv.dicts[d.Dictionary] = dict
+		}
+		id := DictionaryID(s)
+		if v, ok := dict.values[id]; !ok {
+			sink := conv.dataSink
+			if conv.replaceSink != nil {
+				sink = conv.replaceSink
+			}
+			sink(d.Dictionary, []string{"id", "value"}, []interface{}{id, s})
+			if conv.Policies.Orphans != IgnoreOrphans {
+				conv.recordKeys(d.Dictionary, []string{"id", "value"}, []interface{}{id, s})
+			}
+			dict.values[id] = s
+		} else if v != s {
+			return nil, fmt.Errorf("values %q and %q of column %s have the same id %d", v, s, c, id)
+		}
+		if encoded == nil {
+			encoded = append([]interface{}{}, vals...)
+		}
+		encoded[i] = id
+		dict.encoded++
+	}
+	if encoded == nil {
+		return vals, nil
+	}
+	return encoded, nil
+}
+
+// DictionaryStats returns the number of values of dictionary-encoded
+// column spCol of spTable encoded during data conversion, and the number
+// of distinct values among them.
+func (conv *Conv) DictionaryStats(spTable, spCol string) (int64, int64) {
+	d, ok := conv.DictCols[spTable+"."+spCol]
+	if !ok {
+		return 0, 0
+	}
+	dict, ok := conv.dicts[d.Dictionary]
+	if !ok {
+		return 0, 0
+	}
+	return dict.encoded, int64(len(dict.values))
+}
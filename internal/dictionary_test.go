@@ -0,0 +1,94 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package internal
+
+import (
+	"testing"
+
+	"github.com/cloudspannerecosystem/harbourbridge/spanner/ddl"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDictionaryID(t *testing.T) {
+	assert.Equal(t, DictionaryID("shipped"), DictionaryID("shipped"))
+	assert.NotEqual(t, DictionaryID("shipped"), DictionaryID("pending"))
+	assert.True(t, DictionaryID("shipped") >= 0)
+}
+
+func TestDictionaries(t *testing.T) {
+	conv := MakeConv()
+	str := ddl.Type{Name: ddl.String, Len: 50}
+	conv.SpSchema["orders"] = ddl.CreateTable{
+		Name:     "orders",
+		ColNames: []string{"id", "status", "total"},
+		ColDefs: map[string]ddl.ColumnDef{
+			"id":     ddl.ColumnDef{Name: "id", T: ddl.Type{Name: ddl.Int64}},
+			"status": ddl.ColumnDef{Name: "status", T: str, NotNull: true},
+			"total":  ddl.ColumnDef{Name: "total", T: ddl.Type{Name: ddl.Float64}},
+		},
+		Pks: []ddl.IndexKey{ddl.IndexKey{Col: "id"}},
+	}
+	conv.ToSource["orders"] = NameAndCols{Name: "orders", Cols: map[string]string{"id": "id", "status": "status", "total": "total"}}
+	conv.ToSpanner["orders"] = NameAndCols{Name: "orders", Cols: map[string]string{"id": "id", "status": "status", "total": "total"}}
+
+	r := Remodel{Dictionaries: []DictionaryColumn{{Table: "orders", Column: "status"}}}
+	assert.Nil(t, conv.ApplyRemodel(r))
+	ct := conv.SpSchema["orders"]
+	assert.Equal(t, ddl.Type{Name: ddl.Int64}, ct.ColDefs["status"].T)
+	assert.True(t, ct.ColDefs["status"].NotNull)
+	assert.Equal(t, []ddl.Foreignkey{{Name: "orders_status_dict_fk", Columns: []string{"status"}, ReferTable: "orders_status_dict", ReferColumns: []string{"id"}}}, ct.Fks)
+	dt := conv.SpSchema["orders_status_dict"]
+	assert.Equal(t, []string{"id", "value"}, dt.ColNames)
+	assert.Equal(t, str, dt.ColDefs["value"].T)
+	assert.Equal(t, []ddl.IndexKey{{Col: "id"}}, dt.Pks)
+	ds, _ := conv.DataSchema("orders")
+	assert.Equal(t, str, ds.ColDefs["status"].T)
+	// Already applied.
+	assert.Nil(t, conv.ApplyRemodel(r))
+	assert.Equal(t, 1, len(conv.DictCols))
+	for _, d := range []DictionaryColumn{
+		{Table: "orders", Column: "status", Dictionary: "statuses"},
+		{Table: "orders", Column: "id"},
+		{Table: "orders", Column: "total"},
+		{Table: "orders", Column: "missing"},
+		{Table: "missing", Column: "status"},
+	} {
+		assert.NotNil(t, conv.ApplyRemodel(Remodel{Dictionaries: []DictionaryColumn{d}}), d.Column)
+	}
+
+	type row struct {
+		table string
+		vals  []interface{}
+	}
+	var rows []row
+	conv.SetDataMode()
+	conv.SetDataSink(func(table string, cols []string, vals []interface{}) {
+		rows = append(rows, row{table, vals})
+	})
+	conv.WriteRow("orders", "orders", []string{"id", "status"}, []interface{}{int64(1), "shipped"})
+	conv.WriteRow("orders", "orders", []string{"id", "status"}, []interface{}{int64(2), "pending"})
+	conv.WriteRow("orders", "orders", []string{"id", "status"}, []interface{}{int64(3), "shipped"})
+	shipped, pending := DictionaryID("shipped"), DictionaryID("pending")
+	assert.Equal(t, []row{
+		{"orders_status_dict", []interface{}{shipped, "shipped"}},
+		{"orders", []interface{}{int64(1), shipped}},
+		{"orders_status_dict", []interface{}{pending, "pending"}},
+		{"orders", []interface{}{int64(2), pending}},
+		{"orders", []interface{}{int64(3), shipped}},
+	}, rows)
+	n, distinct := conv.DictionaryStats("orders", "status")
+	assert.Equal(t, int64(3), n)
+	assert.Equal(t, int64(2), distinct)
+}
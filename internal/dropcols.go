@@ -0,0 +1,139 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package internal
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// DropColumns marks source columns as not migrated: they are removed from
+// the Spanner schema, and their data is skipped during data conversion.
+// Columns are specified as 'table.column', using source table and column
+// names. Columns that are part of a primary key, a secondary index or a
+// foreign key (on either side) can't be dropped.
+func (conv *Conv) DropColumns(cols []string) error {
+	if conv.DroppedCols == nil {
+		conv.DroppedCols = make(map[string][]string)
+	}
+	for _, s := range cols {
+		i := strings.LastIndex(s, ".")
+		if i <= 0 || i == len(s)-1 {
+			return fmt.Errorf("bad column %q: columns must be given as table.column", s)
+		}
+		srcTable, srcCol := s[:i], s[i+1:]
+		if !conv.IsDroppedCol(srcTable, srcCol) {
+			conv.DroppedCols[srcTable] = append(conv.DroppedCols[srcTable], srcCol)
+		}
+	}
+	return conv.ApplyDroppedCols()
+}
+
+// ApplyDroppedCols removes the columns in conv.DroppedCols from the
+// Spanner schema and the source-to-Spanner mapping. Columns that have
+// already been removed are skipped, so it is safe to call this on a conv
+// read from a session file (where DroppedCols may have been edited).
+func (conv *Conv) ApplyDroppedCols() error {
+	var tables []string
+	for t := range conv.DroppedCols {
+		tables = append(tables, t)
+	}
+	sort.Strings(tables)
+	for _, srcTable := range tables {
+		if _, ok := conv.SrcSchema[srcTable]; !ok {
+			return fmt.Errorf("can't drop columns of table %s: unknown table", srcTable)
+		}
+		for _, srcCol := range conv.DroppedCols[srcTable] {
+			if _, ok := conv.SrcSchema[srcTable].ColDefs[srcCol]; !ok {
+				return fmt.Errorf("can't drop column %s of table %s: unknown column", srcCol, srcTable)
+			}
+			nc := conv.ToSpanner[srcTable]
+			spCol, ok := nc.Cols[srcCol]
+			if !ok {
+				continue // Already dropped.
+			}
+			if err := conv.canDropCol(nc.Name, spCol); err != nil {
+				return fmt.Errorf("can't drop column %s of table %s: %w", srcCol, srcTable, err)
+			}
+			conv.dropCol(srcTable, srcCol, nc.Name, spCol)
+		}
+		sort.Strings(conv.DroppedCols[srcTable])
+	}
+	return nil
+}
+
+// IsDroppedCol returns true if the source column srcCol of srcTable is not
+// migrated (see DropColumns).
+func (conv *Conv) IsDroppedCol(srcTable, srcCol string) bool {
+	for _, c := range conv.DroppedCols[srcTable] {
+		if c == srcCol {
+			return true
+		}
+	}
+	return false
+}
+
+// canDropCol checks whether the Spanner column spCol of spTable can be
+// removed without breaking keys, indexes or foreign keys.
+func (conv *Conv) canDropCol(spTable, spCol string) error {
+	ct := conv.SpSchema[spTable]
+	if isKeyCol(ct, spCol) {
+		return fmt.Errorf("column is part of the primary key")
+	}
+	for _, idx := range ct.Indexes {
+		for _, k := range idx.Keys {
+			if k.Col == spCol {
+				return fmt.Errorf("column is part of secondary index %s", idx.Name)
+			}
+		}
+	}
+	for _, fk := range ct.Fks {
+		for _, c := range fk.Columns {
+			if c == spCol {
+				return fmt.Errorf("column is part of foreign key %s", fk.Name)
+			}
+		}
+	}
+	for _, t := range conv.SpSchema {
+		for _, fk := range t.Fks {
+			if fk.ReferTable != spTable {
+				continue
+			}
+			for _, c := range fk.ReferColumns {
+				if c == spCol {
+					return fmt.Errorf("column is referenced by foreign key %s of table %s", fk.Name, t.Name)
+				}
+			}
+		}
+	}
+	return nil
+}
+
+func (conv *Conv) dropCol(srcTable, srcCol, spTable, spCol string) {
+	ct := conv.SpSchema[spTable]
+	var colNames []string
+	for _, c := range ct.ColNames {
+		if c != spCol {
+			colNames = append(colNames, c)
+		}
+	}
+	ct.ColNames = colNames
+	delete(ct.ColDefs, spCol)
+	conv.SpSchema[spTable] = ct
+	delete(conv.ToSpanner[srcTable].Cols, srcCol)
+	delete(conv.ToSource[spTable].Cols, spCol)
+	conv.SetColumnIssues(srcTable, srcCol, nil)
+}
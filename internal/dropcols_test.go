@@ -0,0 +1,116 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package internal
+
+import (
+	"testing"
+
+	"github.com/cloudspannerecosystem/harbourbridge/schema"
+	"github.com/cloudspannerecosystem/harbourbridge/spanner/ddl"
+	"github.com/stretchr/testify/assert"
+)
+
+func dropColsTestConv() *Conv {
+	conv := MakeConv()
+	conv.SrcSchema["parent"] = schema.Table{
+		Name:     "parent",
+		ColNames: []string{"id", "code", "audit"},
+		ColDefs: map[string]schema.Column{
+			"id":    schema.Column{Name: "id"},
+			"code":  schema.Column{Name: "code"},
+			"audit": schema.Column{Name: "audit"},
+		}}
+	conv.SrcSchema["child"] = schema.Table{
+		Name:     "child",
+		ColNames: []string{"id", "parent_code", "name"},
+		ColDefs: map[string]schema.Column{
+			"id":          schema.Column{Name: "id"},
+			"parent_code": schema.Column{Name: "parent_code"},
+			"name":        schema.Column{Name: "name"},
+		}}
+	conv.SpSchema["parent"] = ddl.CreateTable{
+		Name:     "parent",
+		ColNames: []string{"id", "code", "audit"},
+		ColDefs: map[string]ddl.ColumnDef{
+			"id":    ddl.ColumnDef{Name: "id", T: ddl.Type{Name: ddl.Int64}},
+			"code":  ddl.ColumnDef{Name: "code", T: ddl.Type{Name: ddl.String, Len: ddl.MaxLength}},
+			"audit": ddl.ColumnDef{Name: "audit", T: ddl.Type{Name: ddl.String, Len: ddl.MaxLength}},
+		},
+		Pks: []ddl.IndexKey{ddl.IndexKey{Col: "id"}}}
+	conv.SpSchema["child"] = ddl.CreateTable{
+		Name:     "child",
+		ColNames: []string{"id", "parent_code", "name"},
+		ColDefs: map[string]ddl.ColumnDef{
+			"id":          ddl.ColumnDef{Name: "id", T: ddl.Type{Name: ddl.Int64}},
+			"parent_code": ddl.ColumnDef{Name: "parent_code", T: ddl.Type{Name: ddl.String, Len: ddl.MaxLength}},
+			"name":        ddl.ColumnDef{Name: "name", T: ddl.Type{Name: ddl.String, Len: ddl.MaxLength}},
+		},
+		Pks:     []ddl.IndexKey{ddl.IndexKey{Col: "id"}},
+		Fks:     []ddl.Foreignkey{ddl.Foreignkey{Name: "fk", Columns: []string{"parent_code"}, ReferTable: "parent", ReferColumns: []string{"code"}}},
+		Indexes: []ddl.CreateIndex{ddl.CreateIndex{Name: "idx", Table: "child", Keys: []ddl.IndexKey{ddl.IndexKey{Col: "name"}}}}}
+	for _, t := range []string{"parent", "child"} {
+		toSp, toSrc := make(map[string]string), make(map[string]string)
+		for _, c := range conv.SrcSchema[t].ColNames {
+			toSp[c], toSrc[c] = c, c
+		}
+		conv.ToSpanner[t] = NameAndCols{Name: t, Cols: toSp}
+		conv.ToSource[t] = NameAndCols{Name: t, Cols: toSrc}
+	}
+	conv.Issues["parent"] = map[string][]SchemaIssue{"audit": []SchemaIssue{Widened}}
+	return conv
+}
+
+func TestDropColumns(t *testing.T) {
+	conv := dropColsTestConv()
+	assert.Nil(t, conv.DropColumns([]string{"parent.audit"}))
+	assert.Equal(t, []string{"id", "code"}, conv.SpSchema["parent"].ColNames)
+	_, ok := conv.SpSchema["parent"].ColDefs["audit"]
+	assert.False(t, ok)
+	assert.Equal(t, map[string]string{"id": "id", "code": "code"}, conv.ToSpanner["parent"].Cols)
+	assert.Equal(t, map[string]string{"id": "id", "code": "code"}, conv.ToSource["parent"].Cols)
+	assert.Equal(t, map[string][]SchemaIssue{}, conv.Issues["parent"])
+	assert.Equal(t, map[string][]string{"parent": []string{"audit"}}, conv.DroppedCols)
+	assert.True(t, conv.IsDroppedCol("parent", "audit"))
+	assert.False(t, conv.IsDroppedCol("parent", "code"))
+
+	// Dropped columns map to "" so data conversion can skip them.
+	spCols, err := GetSpannerCols(conv, "parent", []string{"id", "code", "audit"})
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"id", "code", ""}, spCols)
+
+	// Applying dropped columns again (e.g. after reading a session
+	// file) is a no-op.
+	assert.Nil(t, conv.DropColumns([]string{"parent.audit"}))
+	assert.Equal(t, []string{"audit"}, conv.DroppedCols["parent"])
+	assert.Equal(t, []string{"id", "code"}, conv.SpSchema["parent"].ColNames)
+}
+
+func TestDropColumns_Errors(t *testing.T) {
+	for _, tc := range []struct {
+		name string
+		col  string
+	}{
+		{"bad format", "audit"},
+		{"unknown table", "orders.audit"},
+		{"unknown column", "parent.created"},
+		{"primary key", "parent.id"},
+		{"secondary index", "child.name"},
+		{"foreign key", "child.parent_code"},
+		{"referenced by foreign key", "parent.code"},
+	} {
+		conv := dropColsTestConv()
+		assert.NotNil(t, conv.DropColumns([]string{tc.col}), tc.name)
+	}
+}
@@ -0,0 +1,136 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package internal
+
+import (
+	"fmt"
+	"strings"
+)
+
+// maxDuplicateKeySamples is the number of duplicate primary keys we keep
+// for each table, for the report.
+const maxDuplicateKeySamples = 10
+
+// DuplicatePolicy specifies how data conversion handles rows whose
+// Spanner primary key matches that of a row already written. Source
+// rows with distinct keys can collide after conversion (e.g. when names
+// or values are case-folded or otherwise normalized), and Spanner
+// rejects the second insert, failing the entire batch it is part of.
+//
+// Checking for duplicates requires keeping the primary key values of
+// every row in memory.
+type DuplicatePolicy int
+
+const (
+	// IgnoreDuplicates doesn't check primary keys during data conversion.
+	IgnoreDuplicates DuplicatePolicy = iota
+	// FirstWins keeps the first row with a given key, and discards
+	// later ones.
+	FirstWins
+	// LastWins keeps the last row with a given key: later rows replace
+	// earlier ones.
+	LastWins
+	// SidelineDuplicates keeps the first row with a given key, and
+	// reports later ones as bad rows.
+	SidelineDuplicates
+)
+
+var duplicatePolicyNames = map[DuplicatePolicy]string{
+	IgnoreDuplicates:   "ignore",
+	FirstWins:          "first-wins",
+	LastWins:           "last-wins",
+	SidelineDuplicates: "sideline",
+}
+
+func (p DuplicatePolicy) String() string {
+	if s, ok := duplicatePolicyNames[p]; ok {
+		return s
+	}
+	return fmt.Sprintf("DuplicatePolicy(%d)", int(p))
+}
+
+// ParseDuplicatePolicy maps a policy name (as used on the command line)
+// to a DuplicatePolicy.
+func ParseDuplicatePolicy(s string) (DuplicatePolicy, error) {
+	for p, name := range duplicatePolicyNames {
+		if strings.ToLower(s) == name {
+			return p, nil
+		}
+	}
+	return IgnoreDuplicates, fmt.Errorf("unknown duplicate policy %q (accepted values are \"ignore\", \"first-wins\", \"last-wins\" and \"sideline\")", s)
+}
+
+// SetReplaceSink configures conv to use the specified sink for rows that
+// replace an earlier row with the same primary key (see LastWins). The
+// sink must only write the row once all earlier rows have been written.
+// If no replace sink is configured, such rows are written to the data
+// sink.
+func (conv *Conv) SetReplaceSink(rs func(table string, cols []string, values []interface{})) {
+	conv.replaceSink = rs
+}
+
+// applyDuplicatePolicy checks whether a row's primary key has been seen
+// before, and applies conv's duplicate policy if so. It returns false if
+// the row should not be written, and true as the second result if the
+// row should replace an earlier one.
+func (conv *Conv) applyDuplicatePolicy(srcTable, spTable string, cols []string, vals []interface{}) (bool, bool) {
+	pkVals, ok := conv.primaryKeyVals(spTable, cols, vals)
+	if !ok {
+		return true, false
+	}
+	if conv.pkeys == nil {
+		conv.pkeys = make(map[string]map[string]bool)
+	}
+	if conv.pkeys[spTable] == nil {
+		conv.pkeys[spTable] = make(map[string]bool)
+	}
+	k := encodeKey(pkVals)
+	if !conv.pkeys[spTable][k] {
+		conv.pkeys[spTable][k] = true
+		return true, false
+	}
+	var l []string
+	for _, v := range pkVals {
+		l = append(l, fmt.Sprintf("%v", v))
+	}
+	conv.statsAddDuplicate(srcTable, "("+strings.Join(l, ", ")+")")
+	switch conv.Policies.Duplicates {
+	case LastWins:
+		return true, true
+	case SidelineDuplicates:
+		conv.StatsAddBadRow(srcTable, conv.DataMode())
+		conv.CollectBadRow(srcTable, cols, oversizeRowVals(vals))
+	default:
+		// Not a bad row: the data for this key is written.
+		conv.statsAddGoodRow(srcTable, conv.DataMode())
+	}
+	return false, false
+}
+
+// statsAddDuplicate increments the duplicate-key stats for 'srcTable',
+// and keeps a sample of duplicate keys. Only called in data mode (from
+// WriteRow).
+func (conv *Conv) statsAddDuplicate(srcTable, key string) {
+	conv.statsMu.Lock()
+	defer conv.statsMu.Unlock()
+	if conv.Stats.Duplicates == nil {
+		conv.Stats.Duplicates = make(map[string]int64)
+		conv.Stats.DuplicateKeys = make(map[string][]string)
+	}
+	conv.Stats.Duplicates[srcTable]++
+	if len(conv.Stats.DuplicateKeys[srcTable]) < maxDuplicateKeySamples {
+		conv.Stats.DuplicateKeys[srcTable] = append(conv.Stats.DuplicateKeys[srcTable], key)
+	}
+}
@@ -0,0 +1,84 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package internal
+
+import (
+	"testing"
+
+	"github.com/cloudspannerecosystem/harbourbridge/spanner/ddl"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWriteRow_Duplicates(t *testing.T) {
+	type write struct {
+		replace bool
+		vals    []interface{}
+	}
+	r1 := []interface{}{"abc", int64(1)}
+	r2 := []interface{}{"def", int64(2)}
+	r3 := []interface{}{"abc", int64(3)}
+	for _, tc := range []struct {
+		policy DuplicatePolicy
+		writes []write
+		good   int64
+		bad    int64
+	}{
+		{IgnoreDuplicates, []write{{false, r1}, {false, r2}, {false, r3}}, 3, 0},
+		{FirstWins, []write{{false, r1}, {false, r2}}, 3, 0},
+		{LastWins, []write{{false, r1}, {false, r2}, {true, r3}}, 3, 0},
+		{SidelineDuplicates, []write{{false, r1}, {false, r2}}, 2, 1},
+	} {
+		conv := MakeConv()
+		conv.SpSchema["t"] = ddl.CreateTable{
+			Name:     "t",
+			ColNames: []string{"k", "v"},
+			ColDefs: map[string]ddl.ColumnDef{
+				"k": ddl.ColumnDef{Name: "k", T: ddl.Type{Name: ddl.String, Len: ddl.MaxLength}, NotNull: true},
+				"v": ddl.ColumnDef{Name: "v", T: ddl.Type{Name: ddl.Int64}},
+			},
+			Pks: []ddl.IndexKey{ddl.IndexKey{Col: "k"}}}
+		conv.Policies.Duplicates = tc.policy
+		conv.SetDataMode()
+		var writes []write
+		conv.SetDataSink(func(table string, cols []string, vals []interface{}) {
+			writes = append(writes, write{false, vals})
+		})
+		conv.SetReplaceSink(func(table string, cols []string, vals []interface{}) {
+			writes = append(writes, write{true, vals})
+		})
+		for _, r := range [][]interface{}{r1, r2, r3} {
+			conv.WriteRow("src", "t", []string{"k", "v"}, r)
+		}
+		assert.Equal(t, tc.writes, writes, tc.policy.String())
+		assert.Equal(t, tc.good, conv.Stats.GoodRows["src"], tc.policy.String())
+		assert.Equal(t, tc.bad, conv.Stats.BadRows["src"], tc.policy.String())
+		if tc.policy == IgnoreDuplicates {
+			assert.Equal(t, int64(0), conv.Stats.Duplicates["src"], tc.policy.String())
+		} else {
+			assert.Equal(t, int64(1), conv.Stats.Duplicates["src"], tc.policy.String())
+			assert.Equal(t, []string{"(abc)"}, conv.Stats.DuplicateKeys["src"], tc.policy.String())
+		}
+	}
+}
+
+func TestParseDuplicatePolicy(t *testing.T) {
+	for _, p := range []DuplicatePolicy{IgnoreDuplicates, FirstWins, LastWins, SidelineDuplicates} {
+		q, err := ParseDuplicatePolicy(p.String())
+		assert.Nil(t, err)
+		assert.Equal(t, p, q)
+	}
+	_, err := ParseDuplicatePolicy("merge")
+	assert.NotNil(t, err)
+}
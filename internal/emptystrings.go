@@ -0,0 +1,179 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package internal
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/cloudspannerecosystem/harbourbridge/spanner/ddl"
+)
+
+// EmptyStringPolicy specifies how data conversion handles empty strings
+// and NULL values of STRING Spanner columns. Some sources don't
+// distinguish them (Oracle stores empty strings as NULL, and MySQL
+// applications ported from Oracle often use both), but Spanner does:
+// queries, unique indexes and NOT NULL constraints treat them differently.
+type EmptyStringPolicy int
+
+const (
+	// KeepEmptyStrings writes empty strings and NULL values as they are.
+	KeepEmptyStrings EmptyStringPolicy = iota
+	// EmptyToNull writes empty strings as NULL.
+	EmptyToNull
+	// NullToEmpty writes NULL values as empty strings. It is applied
+	// before the NOT NULL policy, so NOT NULL columns get empty strings.
+	NullToEmpty
+)
+
+var emptyStringPolicyNames = map[EmptyStringPolicy]string{
+	KeepEmptyStrings: "keep",
+	EmptyToNull:      "null",
+	NullToEmpty:      "empty",
+}
+
+func (p EmptyStringPolicy) String() string {
+	if s, ok := emptyStringPolicyNames[p]; ok {
+		return s
+	}
+	return fmt.Sprintf("EmptyStringPolicy(%d)", int(p))
+}
+
+// ParseEmptyStringPolicy maps a policy name (as used on the command line)
+// to an EmptyStringPolicy.
+func ParseEmptyStringPolicy(s string) (EmptyStringPolicy, error) {
+	for p, name := range emptyStringPolicyNames {
+		if strings.ToLower(s) == name {
+			return p, nil
+		}
+	}
+	return KeepEmptyStrings, fmt.Errorf("unknown empty string policy %q (accepted values are \"keep\", \"null\" and \"empty\")", s)
+}
+
+// ParseEmptyStringPolicies parses a comma-separated list of empty string
+// policies. An entry of the form 'table.column=policy' sets the policy for
+// a Spanner column; an entry without '=' sets the policy for all other
+// columns. For example, "keep,users.middle_name=null".
+func ParseEmptyStringPolicies(s string) (EmptyStringPolicy, map[string]EmptyStringPolicy, error) {
+	policy := KeepEmptyStrings
+	cols := make(map[string]EmptyStringPolicy)
+	for _, e := range strings.Split(s, ",") {
+		e = strings.TrimSpace(e)
+		if e == "" {
+			continue
+		}
+		i := strings.LastIndex(e, "=")
+		if i < 0 {
+			p, err := ParseEmptyStringPolicy(e)
+			if err != nil {
+				return KeepEmptyStrings, nil, err
+			}
+			policy = p
+			continue
+		}
+		col := e[:i]
+		if !strings.Contains(col, ".") {
+			return KeepEmptyStrings, nil, fmt.Errorf("bad empty string policy %q: column must be given as table.column", e)
+		}
+		p, err := ParseEmptyStringPolicy(e[i+1:])
+		if err != nil {
+			return KeepEmptyStrings, nil, err
+		}
+		cols[col] = p
+	}
+	return policy, cols, nil
+}
+
+// emptyStringPolicy returns the empty string policy for a Spanner column.
+func (conv *Conv) emptyStringPolicy(spTable, spCol string) EmptyStringPolicy {
+	if p, ok := conv.Policies.EmptyStringColumns[spTable+"."+spCol]; ok {
+		return p
+	}
+	return conv.Policies.EmptyStrings
+}
+
+// isStringCol returns true if spCol is a STRING column of spTable, or a
+// dictionary-encoded column (whose values are strings until WriteRow
+// encodes them).
+func (conv *Conv) isStringCol(spTable, spCol string) bool {
+	if _, ok := conv.DictCols[spTable+"."+spCol]; ok {
+		return true
+	}
+	t := conv.SpSchema[spTable].ColDefs[spCol].T
+	return t.Name == ddl.String && !t.IsArray
+}
+
+// applyEmptyStringPolicy applies the empty string policy of each STRING
+// column of a row (NULL values are either missing from spCols, or nil),
+// and returns the columns and values to write. Rows of merged tables only
+// update the columns they have, so missing columns are left alone.
+func (conv *Conv) applyEmptyStringPolicy(srcTable, spTable string, spCols []string, spVals []interface{}) ([]string, []interface{}) {
+	cols, vals := spCols, spVals
+	copied := false
+	copyRow := func() {
+		if !copied {
+			// Don't modify the caller's slices.
+			cols = append([]string{}, spCols...)
+			vals = append([]interface{}{}, spVals...)
+			copied = true
+		}
+	}
+	inRow := make(map[string]bool)
+	for i, c := range spCols {
+		inRow[c] = true
+		if !conv.isStringCol(spTable, c) {
+			continue
+		}
+		switch p := conv.emptyStringPolicy(spTable, c); {
+		case p == EmptyToNull && spVals[i] == "":
+			copyRow()
+			vals[i] = nil
+		case p == NullToEmpty && spVals[i] == nil:
+			copyRow()
+			vals[i] = ""
+		default:
+			continue
+		}
+		conv.statsAddEmptyString(srcTable, c)
+	}
+	if _, ok := conv.MergedTables[srcTable]; ok {
+		return cols, vals
+	}
+	for _, c := range conv.SpSchema[spTable].ColNames {
+		if inRow[c] || !conv.isStringCol(spTable, c) || conv.emptyStringPolicy(spTable, c) != NullToEmpty {
+			continue
+		}
+		copyRow()
+		cols = append(cols, c)
+		vals = append(vals, "")
+		conv.statsAddEmptyString(srcTable, c)
+	}
+	return cols, vals
+}
+
+// statsAddEmptyString increments the count of values of 'spCol' of
+// 'srcTable' changed by the empty string policy. Only called in data mode
+// (from WriteRow).
+func (conv *Conv) statsAddEmptyString(srcTable, spCol string) {
+	conv.statsMu.Lock()
+	defer conv.statsMu.Unlock()
+	if conv.Stats.EmptyStrings == nil {
+		conv.Stats.EmptyStrings = make(map[string]map[string]int64)
+	}
+	if conv.Stats.EmptyStrings[srcTable] == nil {
+		conv.Stats.EmptyStrings[srcTable] = make(map[string]int64)
+	}
+	conv.Stats.EmptyStrings[srcTable][spCol]++
+}
@@ -0,0 +1,80 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package internal
+
+import (
+	"testing"
+
+	"github.com/cloudspannerecosystem/harbourbridge/spanner/ddl"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWriteRow_EmptyStrings(t *testing.T) {
+	str := ddl.Type{Name: ddl.String, Len: ddl.MaxLength}
+	for _, tc := range []struct {
+		name     string
+		policy   EmptyStringPolicy
+		cols     map[string]EmptyStringPolicy
+		notNull  NotNullPolicy
+		wantCols []string
+		wantVals []interface{}
+		stats    map[string]int64
+	}{
+		{"keep", KeepEmptyStrings, nil, IgnoreNotNull, []string{"k", "a", "b"}, []interface{}{int64(1), "", nil}, nil},
+		{"null", EmptyToNull, nil, IgnoreNotNull, []string{"k", "a", "b"}, []interface{}{int64(1), nil, nil}, map[string]int64{"a": 1}},
+		{"empty", NullToEmpty, nil, IgnoreNotNull, []string{"k", "a", "b", "c"}, []interface{}{int64(1), "", "", ""}, map[string]int64{"b": 1, "c": 1}},
+		{"per-column", KeepEmptyStrings, map[string]EmptyStringPolicy{"t.a": EmptyToNull, "t.b": NullToEmpty}, IgnoreNotNull, []string{"k", "a", "b"}, []interface{}{int64(1), nil, ""}, map[string]int64{"a": 1, "b": 1}},
+		// NOT NULL column a is then filled with its default value.
+		{"before NOT NULL", EmptyToNull, nil, DefaultNotNull, []string{"k", "a"}, []interface{}{int64(1), ""}, map[string]int64{"a": 1}},
+	} {
+		conv := MakeConv()
+		conv.SpSchema["t"] = ddl.CreateTable{
+			Name:     "t",
+			ColNames: []string{"k", "a", "b", "c"},
+			ColDefs: map[string]ddl.ColumnDef{
+				"k": ddl.ColumnDef{Name: "k", T: ddl.Type{Name: ddl.Int64}, NotNull: true},
+				"a": ddl.ColumnDef{Name: "a", T: str, NotNull: true},
+				"b": ddl.ColumnDef{Name: "b", T: str},
+				"c": ddl.ColumnDef{Name: "c", T: str},
+			},
+			Pks: []ddl.IndexKey{ddl.IndexKey{Col: "k"}}}
+		conv.ToSpanner["src"] = NameAndCols{Name: "t", Cols: map[string]string{"k": "k", "a": "a", "b": "b", "c": "c"}}
+		conv.Policies.EmptyStrings = tc.policy
+		conv.Policies.EmptyStringColumns = tc.cols
+		conv.Policies.NotNull = tc.notNull
+		conv.SetDataMode()
+		var gotCols []string
+		var gotVals []interface{}
+		conv.SetDataSink(func(table string, cols []string, vals []interface{}) {
+			gotCols, gotVals = cols, vals
+		})
+		conv.WriteRow("src", "t", []string{"k", "a", "b"}, []interface{}{int64(1), "", nil})
+		assert.Equal(t, tc.wantCols, gotCols, tc.name)
+		assert.Equal(t, tc.wantVals, gotVals, tc.name)
+		assert.Equal(t, tc.stats, conv.Stats.EmptyStrings["src"], tc.name)
+	}
+}
+
+func TestParseEmptyStringPolicies(t *testing.T) {
+	p, cols, err := ParseEmptyStringPolicies("null, users.nickname=empty,users.code=keep")
+	assert.Nil(t, err)
+	assert.Equal(t, EmptyToNull, p)
+	assert.Equal(t, map[string]EmptyStringPolicy{"users.nickname": NullToEmpty, "users.code": KeepEmptyStrings}, cols)
+
+	for _, s := range []string{"blank", "nickname=empty", "users.nickname=zero"} {
+		_, _, err = ParseEmptyStringPolicies(s)
+		assert.NotNil(t, err, s)
+	}
+}
@@ -0,0 +1,77 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package internal
+
+import (
+	"fmt"
+	"sort"
+)
+
+// SkipColumnlessTables removes the source tables that have no column to
+// migrate from the schema: tables without columns, and tables whose
+// columns are all dropped (see DropColumns). Their Spanner table would
+// only have a synthetic primary key, and hold nothing of the source. The
+// tables are listed, with the reason they are skipped, in
+// conv.SkippedTables, and their rows are skipped (see SkipData). Tables
+// kept in spill files (see SpillTo) aren't checked.
+func (conv *Conv) SkipColumnlessTables() {
+	for srcTable, t := range conv.SrcSchema {
+		nc, ok := conv.ToSpanner[srcTable]
+		if !ok || len(nc.Cols) > 0 {
+			continue
+		}
+		reason := "it has no columns"
+		if len(t.ColDefs) > 0 {
+			reason = "all its columns are dropped"
+		}
+		delete(conv.SpSchema, nc.Name)
+		delete(conv.SyntheticPKeys, nc.Name)
+		delete(conv.ToSource, nc.Name)
+		delete(conv.ToSpanner, srcTable)
+		delete(conv.SrcSchema, srcTable)
+		delete(conv.Issues, srcTable)
+		delete(conv.DroppedCols, srcTable)
+		if conv.SkippedTables == nil {
+			conv.SkippedTables = make(map[string]string)
+		}
+		conv.SkippedTables[srcTable] = reason
+	}
+}
+
+// EmptyTable returns true if the rows of srcTable were counted before data
+// conversion (see StatsAddRows), and there were none: source packages
+// don't read the rows of such tables. Their Spanner table is still
+// created, empty.
+func (conv *Conv) EmptyTable(srcTable string) bool {
+	conv.statsMu.Lock()
+	defer conv.statsMu.Unlock()
+	n, ok := conv.Stats.Rows[srcTable]
+	return ok && n == 0
+}
+
+// skippedTablesReport lists the source tables that are not migrated (see
+// SkipColumnlessTables).
+func skippedTablesReport(conv *Conv) []string {
+	var tables []string
+	for t := range conv.SkippedTables {
+		tables = append(tables, t)
+	}
+	sort.Strings(tables)
+	var l []string
+	for _, t := range tables {
+		l = append(l, fmt.Sprintf("Table %s was skipped, since %s: no Spanner table was created for it", t, conv.SkippedTables[t]))
+	}
+	return l
+}
@@ -0,0 +1,75 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package internal
+
+import (
+	"testing"
+
+	"github.com/cloudspannerecosystem/harbourbridge/schema"
+	"github.com/cloudspannerecosystem/harbourbridge/spanner/ddl"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSkipColumnlessTables(t *testing.T) {
+	conv := dropColsTestConv()
+	conv.SrcSchema["log"] = schema.Table{Name: "log", ColNames: []string{"line"}, ColDefs: map[string]schema.Column{"line": schema.Column{Name: "line"}}}
+	conv.SpSchema["log"] = ddl.CreateTable{
+		Name:     "log",
+		ColNames: []string{"line", "synth_id"},
+		ColDefs: map[string]ddl.ColumnDef{
+			"line":     ddl.ColumnDef{Name: "line", T: ddl.Type{Name: ddl.String, Len: ddl.MaxLength}},
+			"synth_id": ddl.ColumnDef{Name: "synth_id", T: ddl.Type{Name: ddl.Int64}},
+		},
+		Pks: []ddl.IndexKey{ddl.IndexKey{Col: "synth_id"}}}
+	conv.SyntheticPKeys["log"] = SyntheticPKey{Col: "synth_id"}
+	conv.ToSpanner["log"] = NameAndCols{Name: "log", Cols: map[string]string{"line": "line"}}
+	conv.ToSource["log"] = NameAndCols{Name: "log", Cols: map[string]string{"line": "line"}}
+	conv.SrcSchema["empty"] = schema.Table{Name: "empty", ColDefs: map[string]schema.Column{}}
+	conv.SpSchema["empty"] = ddl.CreateTable{
+		Name:     "empty",
+		ColNames: []string{"synth_id"},
+		ColDefs:  map[string]ddl.ColumnDef{"synth_id": ddl.ColumnDef{Name: "synth_id", T: ddl.Type{Name: ddl.Int64}}},
+		Pks:      []ddl.IndexKey{ddl.IndexKey{Col: "synth_id"}}}
+	conv.SyntheticPKeys["empty"] = SyntheticPKey{Col: "synth_id"}
+	conv.ToSpanner["empty"] = NameAndCols{Name: "empty", Cols: map[string]string{}}
+	conv.ToSource["empty"] = NameAndCols{Name: "empty", Cols: map[string]string{}}
+
+	assert.Nil(t, conv.DropColumns([]string{"log.line", "parent.audit"}))
+	conv.SkipColumnlessTables()
+	assert.Equal(t, map[string]string{"log": "all its columns are dropped", "empty": "it has no columns"}, conv.SkippedTables)
+	assert.Equal(t, []string{"child", "parent"}, conv.SrcTables())
+	for _, tb := range []string{"log", "empty"} {
+		assert.NotContains(t, conv.SpSchema, tb)
+		assert.NotContains(t, conv.SyntheticPKeys, tb)
+		assert.NotContains(t, conv.ToSpanner, tb)
+		assert.NotContains(t, conv.ToSource, tb)
+		assert.NotContains(t, conv.DroppedCols, tb)
+		assert.True(t, conv.SkipData(tb))
+	}
+	assert.False(t, conv.SkipData("parent"))
+	assert.Equal(t, []string{
+		"Table empty was skipped, since it has no columns: no Spanner table was created for it",
+		"Table log was skipped, since all its columns are dropped: no Spanner table was created for it",
+	}, skippedTablesReport(conv))
+}
+
+func TestEmptyTable(t *testing.T) {
+	conv := MakeConv()
+	assert.False(t, conv.EmptyTable("t"))
+	conv.StatsAddRows("t", 0)
+	assert.True(t, conv.EmptyTable("t"))
+	conv.StatsAddRows("t", 3)
+	assert.False(t, conv.EmptyTable("t"))
+}
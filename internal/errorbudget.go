@@ -0,0 +1,112 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package internal
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+)
+
+// ErrorBudget limits the rows that data conversion can fail to convert
+// before it is aborted, e.g. rather than spending hours loading a table
+// whose values are mostly unconvertible. Rows that Spanner rejects are not
+// counted. 0 means no limit.
+type ErrorBudget struct {
+	MaxBadRowsPerTable int64 `json:",omitempty"` // Bad rows allowed in each source table.
+	MaxBadRowsTotal    int64 `json:",omitempty"` // Bad rows allowed across all tables.
+}
+
+// ErrErrorBudget is the error of data conversions aborted because they
+// exceeded their error budget (see Conv.Aborted).
+var ErrErrorBudget = errors.New("error budget exceeded")
+
+// checkErrorBudget aborts data conversion if srcTable, or all tables,
+// have more bad rows than conv.Policies.ErrorBudget allows. It must be
+// called with conv.statsMu held.
+func (conv *Conv) checkErrorBudget(srcTable string) {
+	b := conv.Policies.ErrorBudget
+	if conv.aborted != nil {
+		return
+	}
+	if n := conv.Stats.BadRows[srcTable]; b.MaxBadRowsPerTable > 0 && n > b.MaxBadRowsPerTable {
+		conv.aborted = fmt.Errorf("%w: table %s has more than %d bad rows", ErrErrorBudget, srcTable, b.MaxBadRowsPerTable)
+		return
+	}
+	if b.MaxBadRowsTotal > 0 {
+		var n int64
+		for _, x := range conv.Stats.BadRows {
+			n += x
+		}
+		if n > b.MaxBadRowsTotal {
+			conv.aborted = fmt.Errorf("%w: more than %d bad rows in all tables", ErrErrorBudget, b.MaxBadRowsTotal)
+		}
+	}
+}
+
+// Aborted returns why data conversion was aborted, or nil if it wasn't
+// (see ErrorBudget). Once it is aborted, source packages stop reading
+// rows, and SkipData is true for all tables.
+func (conv *Conv) Aborted() error {
+	conv.statsMu.Lock()
+	defer conv.statsMu.Unlock()
+	return conv.aborted
+}
+
+// LeadingErrors describes the leading causes of bad rows: up to n of the
+// tables with the most bad rows, and up to n of the most frequent causes
+// of bad rows (see ErrorGroups), or if they aren't known, of the most
+// frequent unexpected conditions.
+func (conv *Conv) LeadingErrors(n int) []string {
+	groups := conv.ErrorGroups(n)
+	conv.statsMu.Lock()
+	defer conv.statsMu.Unlock()
+	type count struct {
+		s string
+		n int64
+	}
+	top := func(m map[string]int64) []count {
+		var l []count
+		for s, x := range m {
+			if x > 0 {
+				l = append(l, count{s, x})
+			}
+		}
+		sort.Slice(l, func(i, j int) bool {
+			if l[i].n != l[j].n {
+				return l[i].n > l[j].n
+			}
+			return l[i].s < l[j].s
+		})
+		if len(l) > n {
+			l = l[:n]
+		}
+		return l
+	}
+	var l []string
+	for _, c := range top(conv.Stats.BadRows) {
+		l = append(l, fmt.Sprintf("table %s: %d bad rows", c.s, c.n))
+	}
+	for _, g := range groups {
+		l = append(l, g.String())
+	}
+	if len(groups) > 0 {
+		return l
+	}
+	for _, c := range top(conv.Stats.Unexpected) {
+		l = append(l, fmt.Sprintf("%d times: %s", c.n, c.s))
+	}
+	return l
+}
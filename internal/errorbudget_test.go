@@ -0,0 +1,47 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package internal
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestErrorBudget(t *testing.T) {
+	conv := MakeConv()
+	conv.SetDataMode()
+	conv.Policies.ErrorBudget = ErrorBudget{MaxBadRowsPerTable: 2, MaxBadRowsTotal: 4}
+	for i := 0; i < 2; i++ {
+		conv.Unexpected("can't convert a")
+		conv.StatsAddBadRow("a", true)
+		conv.StatsAddBadRow("b", true)
+	}
+	assert.Nil(t, conv.Aborted())
+	assert.False(t, conv.SkipData("a"))
+	conv.Unexpected("can't convert b")
+	conv.StatsAddBadRow("b", true)
+	assert.True(t, errors.Is(conv.Aborted(), ErrErrorBudget))
+	assert.EqualError(t, conv.Aborted(), "error budget exceeded: table b has more than 2 bad rows")
+	assert.True(t, conv.SkipData("a"))
+	assert.Equal(t, []string{"table b: 3 bad rows", "2 times: can't convert a"}, conv.LeadingErrors(1))
+
+	conv = MakeConv()
+	conv.Policies.ErrorBudget = ErrorBudget{MaxBadRowsTotal: 2}
+	conv.StatsAddRows("a", 3)
+	conv.StatsAddTableBadRows("a")
+	assert.EqualError(t, conv.Aborted(), "error budget exceeded: more than 2 bad rows in all tables")
+}
@@ -0,0 +1,180 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package internal
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// ColumnError is an error converting the value of a source column.
+type ColumnError struct {
+	Col string // Source column.
+	Val string // Source value.
+	Err error
+}
+
+func (e *ColumnError) Error() string {
+	return fmt.Sprintf("column %s: %s", e.Col, e.Err)
+}
+
+func (e *ColumnError) Unwrap() error {
+	return e.Err
+}
+
+// ErrorGroup counts the bad rows of a source table that have the same
+// cause: the same conversion error, of the same column, for values of
+// the same shape.
+type ErrorGroup struct {
+	Table    string
+	Column   string `json:",omitempty"` // Empty if the error isn't about a column.
+	Cause    string // Error, with values and numbers replaced by ?.
+	Pattern  string `json:",omitempty"` // Shape of the values: digits are 9, letters a, runs are collapsed.
+	Count    int64
+	Examples []string // Up to maxErrorExamples bad rows.
+}
+
+const (
+	maxErrorGroups   = 1000
+	maxErrorExamples = 3
+	maxExampleValue  = 100 // Longer values of examples are truncated.
+)
+
+var (
+	quotedRe = regexp.MustCompile(`"(?:[^"\\]|\\.)*"|'[^']*'`)
+	numberRe = regexp.MustCompile(`\b\d+\b`)
+)
+
+// GroupBadRow adds a bad row of srcTable, rejected because of err, to the
+// group of its cause (see ErrorGroups). srcCols and vals are the columns
+// and values of the row.
+func (conv *Conv) GroupBadRow(srcTable string, err error, srcCols, vals []string) {
+	g := ErrorGroup{Table: srcTable, Cause: err.Error()}
+	var ce *ColumnError
+	if errors.As(err, &ce) {
+		g.Column, g.Cause, g.Pattern = ce.Col, ce.Err.Error(), valuePattern(ce.Val)
+	}
+	g.Cause = numberRe.ReplaceAllString(quotedRe.ReplaceAllString(g.Cause, "?"), "?")
+	conv.statsMu.Lock()
+	defer conv.statsMu.Unlock()
+	if conv.errorGroups == nil {
+		conv.errorGroups = make(map[string]*ErrorGroup)
+	}
+	key := strings.Join([]string{g.Table, g.Column, g.Cause, g.Pattern}, "\x00")
+	p, ok := conv.errorGroups[key]
+	if !ok {
+		// Limit the number of groups. If over limit, then the row is
+		// counted in a catch-all group of its table.
+		if len(conv.errorGroups) >= maxErrorGroups {
+			g = ErrorGroup{Table: srcTable, Cause: "other causes"}
+			key = srcTable + "\x00"
+			p, ok = conv.errorGroups[key]
+		}
+		if !ok {
+			p = &g
+			conv.errorGroups[key] = p
+		}
+	}
+	p.Count++
+	if len(p.Examples) < maxErrorExamples {
+		var l []string
+		for _, v := range vals {
+			if len(v) > maxExampleValue {
+				v = v[:maxExampleValue] + "..."
+			}
+			l = append(l, v)
+		}
+		p.Examples = append(p.Examples, fmt.Sprintf("cols=%v data=%v", srcCols, l))
+	}
+}
+
+// ErrorGroups returns up to n groups of bad rows (see GroupBadRow), with
+// the most rows first.
+func (conv *Conv) ErrorGroups(n int) []ErrorGroup {
+	conv.statsMu.Lock()
+	defer conv.statsMu.Unlock()
+	var l []ErrorGroup
+	for _, g := range conv.errorGroups {
+		x := *g
+		x.Examples = append([]string(nil), g.Examples...)
+		l = append(l, x)
+	}
+	sort.Slice(l, func(i, j int) bool {
+		if l[i].Count != l[j].Count {
+			return l[i].Count > l[j].Count
+		}
+		if l[i].Table != l[j].Table {
+			return l[i].Table < l[j].Table
+		}
+		if l[i].Column != l[j].Column {
+			return l[i].Column < l[j].Column
+		}
+		if l[i].Cause != l[j].Cause {
+			return l[i].Cause < l[j].Cause
+		}
+		return l[i].Pattern < l[j].Pattern
+	})
+	if len(l) > n {
+		l = l[:n]
+	}
+	return l
+}
+
+// valuePattern returns the shape of v e.g. 9-9-9 for 2020-13-45 and
+// 13/45/2020, and a@a.a for an email address.
+func valuePattern(v string) string {
+	if v == "" {
+		return "(empty)"
+	}
+	var b strings.Builder
+	var last rune
+	for _, r := range v {
+		switch {
+		case unicode.IsDigit(r):
+			r = '9'
+		case unicode.IsLetter(r):
+			r = 'a'
+		case unicode.IsSpace(r):
+			r = ' '
+		}
+		if r == last && (r == '9' || r == 'a' || r == ' ') {
+			continue
+		}
+		last = r
+		b.WriteRune(r)
+		if b.Len() >= 40 {
+			b.WriteString("...")
+			break
+		}
+	}
+	return b.String()
+}
+
+// String describes g, without its examples.
+func (g ErrorGroup) String() string {
+	s := fmt.Sprintf("%d bad rows of table %s", g.Count, g.Table)
+	if g.Column != "" {
+		s += fmt.Sprintf(", column %s", g.Column)
+	}
+	s += ": " + g.Cause
+	if g.Pattern != "" {
+		s += fmt.Sprintf(" (values like %s)", g.Pattern)
+	}
+	return s
+}
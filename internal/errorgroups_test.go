@@ -0,0 +1,57 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package internal
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGroupBadRow(t *testing.T) {
+	conv := MakeConv()
+	colErr := func(col, val string) error {
+		_, err := strconv.ParseInt(val, 10, 64)
+		return fmt.Errorf("can't convert: %w", &ColumnError{Col: col, Val: val, Err: err})
+	}
+	for i := 0; i < 5; i++ {
+		v := fmt.Sprintf("1%d.5", i)
+		conv.GroupBadRow("t", colErr("a", v), []string{"a"}, []string{v})
+	}
+	conv.GroupBadRow("t", colErr("a", "abc"), []string{"a"}, []string{"abc"})
+	conv.GroupBadRow("u", errors.New("row 12 has 3 columns"), []string{"a"}, []string{strings.Repeat("x", 200)})
+	assert.Equal(t, []ErrorGroup{
+		{Table: "t", Column: "a", Cause: `strconv.ParseInt: parsing ?: invalid syntax`, Pattern: "9.9", Count: 5,
+			Examples: []string{"cols=[a] data=[10.5]", "cols=[a] data=[11.5]", "cols=[a] data=[12.5]"}},
+		{Table: "t", Column: "a", Cause: `strconv.ParseInt: parsing ?: invalid syntax`, Pattern: "a", Count: 1,
+			Examples: []string{"cols=[a] data=[abc]"}},
+		{Table: "u", Cause: "row ? has ? columns", Count: 1,
+			Examples: []string{"cols=[a] data=[" + strings.Repeat("x", 100) + "...]"}},
+	}, conv.ErrorGroups(10))
+	assert.Equal(t, 1, len(conv.ErrorGroups(1)))
+	assert.Equal(t, "5 bad rows of table t, column a: strconv.ParseInt: parsing ?: invalid syntax (values like 9.9)", conv.ErrorGroups(1)[0].String())
+	assert.Equal(t, []string{"5 bad rows of table t, column a: strconv.ParseInt: parsing ?: invalid syntax (values like 9.9)"}, conv.LeadingErrors(1)[:1])
+}
+
+func TestValuePattern(t *testing.T) {
+	assert.Equal(t, "(empty)", valuePattern(""))
+	assert.Equal(t, "9-9-9", valuePattern("2020-13-45"))
+	assert.Equal(t, "a.a@a.a", valuePattern("jane.doe@example.com"))
+	assert.Equal(t, "a 9", valuePattern("abc  123"))
+}
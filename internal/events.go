@@ -0,0 +1,164 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package internal
+
+import (
+	"io"
+	"time"
+)
+
+// Kinds of progress events.
+const (
+	TableStartEvent  = "table-start"  // Data conversion of a table started.
+	TableFinishEvent = "table-finish" // Data conversion of a table finished.
+	ErrorEvent       = "error"        // An unexpected condition was first encountered.
+	CheckpointEvent  = "checkpoint"   // The run reached a checkpoint.
+	TaskEvent        = "task"         // The percentage done of a task changed.
+)
+
+// ProgressEvent is an event of the progress of a migration (see
+// SetProgressSink).
+type ProgressEvent struct {
+	Time  time.Time `json:"time"`
+	Kind  string    `json:"kind"`
+	Table string    `json:"table,omitempty"` // Source table.
+	// Rows, GoodRows and BadRows are the rows of Table read, converted
+	// and that couldn't be converted, for TableFinishEvent. Rows rejected
+	// by Spanner aren't known yet, and are counted as converted.
+	Rows     int64 `json:"rows,omitempty"`
+	GoodRows int64 `json:"good_rows,omitempty"`
+	BadRows  int64 `json:"bad_rows,omitempty"`
+	// Detail is the condition for ErrorEvent, the checkpoint for
+	// CheckpointEvent and the task (e.g. "Writing data to Spanner") for
+	// TaskEvent.
+	Detail  string `json:"detail,omitempty"`
+	Percent int    `json:"percent,omitempty"` // Percentage of the task done, for TaskEvent.
+}
+
+// SetProgressSink sets the function that progress events are sent to
+// during data conversion, and that the progress of tasks measured with
+// conv.NewProgress is sent to. f is called with conv locked, which keeps
+// events in order: it must not call methods of conv, and must never block
+// (e.g. on I/O or on a full channel), since data conversion waits for it.
+// Sinks that do slow work should queue events, and drop them when the
+// queue is full (as conversion.Notifier does).
+func (conv *Conv) SetProgressSink(f func(ProgressEvent)) {
+	conv.progressSink = f
+}
+
+// SetProgressOutput sets where the percentages of tasks measured with
+// conv.NewProgress are printed: nil (the default) means the standard
+// output, and ioutil.Discard doesn't print them, e.g. when they are
+// rendered from progress events instead.
+func (conv *Conv) SetProgressOutput(w io.Writer) {
+	conv.progressOut = w
+}
+
+// NewProgress returns a Progress for a task of conv, which prints its
+// percentages as set by SetProgressOutput, and sends them to the progress
+// sink as TaskEvents.
+func (conv *Conv) NewProgress(total int64, message string) *Progress {
+	var sink func(ProgressEvent)
+	if conv.progressSink != nil {
+		sink = func(e ProgressEvent) {
+			conv.statsMu.Lock()
+			defer conv.statsMu.Unlock()
+			conv.progressSink(e)
+		}
+	}
+	return NewProgressTo(total, message, Verbose(), conv.progressOut, sink)
+}
+
+// StartTable notes that data conversion of srcTable starts, finishing the
+// table being converted, if any. Source packages that read tables one at
+// a time call it before reading each table; it is also called for each
+// row converted, so tables of dumps are started by their first row.
+func (conv *Conv) StartTable(srcTable string) {
+	if conv.progressSink == nil {
+		return
+	}
+	conv.statsMu.Lock()
+	defer conv.statsMu.Unlock()
+	conv.startTable(srcTable)
+}
+
+// FinishTables notes that data conversion finished.
+func (conv *Conv) FinishTables() {
+	if conv.progressSink == nil {
+		return
+	}
+	conv.statsMu.Lock()
+	defer conv.statsMu.Unlock()
+	conv.finishTable()
+}
+
+// Checkpoint sends a CheckpointEvent for checkpoint, e.g. "data loaded".
+func (conv *Conv) Checkpoint(checkpoint string) {
+	if conv.progressSink == nil {
+		return
+	}
+	conv.statsMu.Lock()
+	defer conv.statsMu.Unlock()
+	conv.progressSink(ProgressEvent{Time: time.Now(), Kind: CheckpointEvent, Detail: checkpoint})
+}
+
+// TableRows are the row counts of a source table (see Conv.TableRows).
+type TableRows struct {
+	// Rows is the number of rows read, or for live source databases the
+	// number of rows of the table (counted before data conversion).
+	Rows     int64
+	GoodRows int64 // Rows converted.
+	BadRows  int64 // Rows that couldn't be converted.
+}
+
+// TableRows returns the row counts of source tables, e.g. to monitor data
+// conversion while it runs.
+func (conv *Conv) TableRows() map[string]TableRows {
+	conv.statsMu.Lock()
+	defer conv.statsMu.Unlock()
+	m := make(map[string]TableRows)
+	for t, n := range conv.Stats.Rows {
+		m[t] = TableRows{Rows: n, GoodRows: conv.Stats.GoodRows[t], BadRows: conv.Stats.BadRows[t]}
+	}
+	return m
+}
+
+// startTable is StartTable, with conv.statsMu held.
+func (conv *Conv) startTable(srcTable string) {
+	if conv.progressSink == nil || srcTable == conv.progressTable {
+		return
+	}
+	conv.finishTable()
+	conv.progressTable = srcTable
+	conv.progressSink(ProgressEvent{Time: time.Now(), Kind: TableStartEvent, Table: srcTable})
+}
+
+// finishTable sends a TableFinishEvent for the table being converted, if
+// any, with conv.statsMu held.
+func (conv *Conv) finishTable() {
+	t := conv.progressTable
+	if t == "" {
+		return
+	}
+	conv.progressTable = ""
+	conv.progressSink(ProgressEvent{
+		Time:     time.Now(),
+		Kind:     TableFinishEvent,
+		Table:    t,
+		Rows:     conv.Stats.Rows[t],
+		GoodRows: conv.Stats.GoodRows[t],
+		BadRows:  conv.Stats.BadRows[t],
+	})
+}
@@ -0,0 +1,79 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package internal
+
+import (
+	"io/ioutil"
+	"testing"
+	"time"
+
+	"github.com/cloudspannerecosystem/harbourbridge/schema"
+	"github.com/cloudspannerecosystem/harbourbridge/spanner/ddl"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestProgressEvents(t *testing.T) {
+	conv := MakeConv()
+	for _, name := range []string{"t", "u"} {
+		conv.SrcSchema[name] = schema.Table{Name: name, ColNames: []string{"id"}, ColDefs: map[string]schema.Column{"id": {Name: "id", Type: schema.Type{Name: "bigint"}}}}
+		GetSpannerTable(conv, name)
+		GetSpannerCols(conv, name, []string{"id"})
+		conv.SpSchema[name] = ddl.CreateTable{Name: name, ColNames: []string{"id"}, ColDefs: map[string]ddl.ColumnDef{"id": {Name: "id", T: ddl.Type{Name: ddl.Int64}}}}
+	}
+	conv.SetDataMode()
+	conv.SetDataSink(func(table string, cols []string, values []interface{}) {})
+	var events []ProgressEvent
+	conv.SetProgressSink(func(e ProgressEvent) { events = append(events, e) })
+	conv.StartTable("t")
+	conv.StatsAddRows("t", 2)
+	conv.WriteRow("t", "t", []string{"id"}, []interface{}{int64(1)})
+	conv.WriteRow("t", "t", []string{"id"}, []interface{}{int64(2)})
+	conv.StatsAddRow("u", true)
+	conv.StatsAddBadRow("u", true)
+	conv.Unexpected("can't convert")
+	conv.Unexpected("can't convert")
+	conv.FinishTables()
+	conv.Checkpoint("data loaded")
+	var got []ProgressEvent
+	for _, e := range events {
+		assert.False(t, e.Time.IsZero())
+		e.Time = time.Time{}
+		got = append(got, e)
+	}
+	assert.Equal(t, []ProgressEvent{
+		{Kind: TableStartEvent, Table: "t"},
+		{Kind: TableFinishEvent, Table: "t", Rows: 2, GoodRows: 2},
+		{Kind: TableStartEvent, Table: "u"},
+		{Kind: ErrorEvent, Table: "u", Detail: "can't convert"},
+		{Kind: TableFinishEvent, Table: "u", Rows: 1, BadRows: 1},
+		{Kind: CheckpointEvent, Detail: "data loaded"},
+	}, got)
+	events = nil
+	conv.SetProgressOutput(ioutil.Discard)
+	p := conv.NewProgress(2, "Writing data to Spanner")
+	p.MaybeReport(1)
+	p.Done()
+	var pcts []int
+	for _, e := range events {
+		assert.Equal(t, TaskEvent, e.Kind)
+		assert.Equal(t, "Writing data to Spanner", e.Detail)
+		pcts = append(pcts, e.Percent)
+	}
+	assert.Equal(t, []int{0, 50, 100}, pcts)
+	assert.Equal(t, map[string]TableRows{
+		"t": {Rows: 2, GoodRows: 2},
+		"u": {Rows: 1, BadRows: 1},
+	}, conv.TableRows())
+}
@@ -0,0 +1,172 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package internal
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+)
+
+// Feature is a Spanner feature that isn't available in every Spanner
+// environment, e.g. because it was added to the PostgreSQL dialect after
+// its preview, or because an emulator lags behind Spanner. Schema
+// conversion only uses the features the target supports, and falls back
+// to other types otherwise.
+type Feature string
+
+const (
+	// PGNumeric is support for numeric columns in the PostgreSQL dialect.
+	// Without it, numeric columns are mapped to text.
+	PGNumeric Feature = "pg-numeric"
+	// PGDate is support for date columns in the PostgreSQL dialect.
+	// Without it, date columns are mapped to text.
+	PGDate Feature = "pg-date"
+	// PGArrays is support for array columns in the PostgreSQL dialect.
+	// Without it, array columns are mapped to text.
+	PGArrays Feature = "pg-arrays"
+	// JSON is support for the JSON type. Without it, json columns are
+	// mapped to STRING(MAX).
+	JSON Feature = "json"
+	// Float32 is support for the FLOAT32 type. Without it, single
+	// precision floating point columns are mapped to FLOAT64.
+	Float32 Feature = "float32"
+	// DefaultValues is support for column DEFAULT values.
+	DefaultValues Feature = "default-values"
+	// CheckConstraints is support for CHECK constraints.
+	CheckConstraints Feature = "check-constraints"
+	// Sequences is support for sequences.
+	Sequences Feature = "sequences"
+	// NamedSchemas is support for named schemas.
+	NamedSchemas Feature = "named-schemas"
+)
+
+// allFeatures lists the known features, all of which are supported by
+// current versions of Spanner.
+var allFeatures = []Feature{PGNumeric, PGDate, PGArrays, JSON, Float32, DefaultValues, CheckConstraints, Sequences, NamedSchemas}
+
+// baseFeatures lists the features HarbourBridge used before the others
+// could be selected. They are the features of Convs that don't record
+// features (e.g. Convs read from older session files), and the features
+// assumed for the Spanner emulator.
+var baseFeatures = []Feature{PGNumeric, PGDate, PGArrays}
+
+// emulatorHostEnv is the environment variable the Spanner client libraries
+// use to connect to the Spanner emulator.
+const emulatorHostEnv = "SPANNER_EMULATOR_HOST"
+
+// Features is the set of features a Spanner target supports.
+type Features map[Feature]bool
+
+// DefaultFeatures returns the features supported by current versions of
+// Spanner.
+func DefaultFeatures() Features {
+	return makeFeatures(allFeatures)
+}
+
+// AutoFeatures returns the features of the Spanner target HarbourBridge
+// connects to: only the base features when the Spanner emulator is used
+// (SPANNER_EMULATOR_HOST is set), since the emulator lags behind Spanner,
+// and all features otherwise.
+func AutoFeatures() Features {
+	if os.Getenv(emulatorHostEnv) != "" {
+		return makeFeatures(baseFeatures)
+	}
+	return DefaultFeatures()
+}
+
+func makeFeatures(l []Feature) Features {
+	f := make(Features)
+	for _, x := range l {
+		f[x] = true
+	}
+	return f
+}
+
+// ParseFeatures parses a comma-separated list of features. The list can
+// start with 'all', 'none' or 'auto' (see AutoFeatures; the default is
+// 'all'), and each feature can be prefixed with '-' to remove it e.g.
+// 'all,-pg-date', 'auto,-json' or 'none,pg-numeric'.
+func ParseFeatures(s string) (Features, error) {
+	f := DefaultFeatures()
+	for i, x := range strings.Split(s, ",") {
+		x = strings.ToLower(strings.TrimSpace(x))
+		switch {
+		case x == "":
+		case (x == "all" || x == "none" || x == "auto") && i == 0:
+			switch x {
+			case "none":
+				f = make(Features)
+			case "auto":
+				f = AutoFeatures()
+			}
+		case strings.HasPrefix(x, "-") && isFeature(Feature(x[1:])):
+			delete(f, Feature(x[1:]))
+		case isFeature(Feature(x)):
+			f[Feature(x)] = true
+		default:
+			return nil, fmt.Errorf("unknown Spanner feature %q (known features are %s)", x, featureList(allFeatures))
+		}
+	}
+	return f, nil
+}
+
+func (f Features) String() string {
+	var l []Feature
+	for x, ok := range f {
+		if ok {
+			l = append(l, x)
+		}
+	}
+	if len(l) == 0 {
+		return "none"
+	}
+	return featureList(l)
+}
+
+func isFeature(x Feature) bool {
+	for _, y := range allFeatures {
+		if x == y {
+			return true
+		}
+	}
+	return false
+}
+
+func featureList(l []Feature) string {
+	var s []string
+	for _, x := range l {
+		s = append(s, string(x))
+	}
+	sort.Strings(s)
+	return strings.Join(s, ", ")
+}
+
+// Supports returns true if the Spanner target supports feature x. Convs
+// that don't record features (e.g. read from session files written before
+// features were recorded) support the base features, so that converting
+// them again gives the same schema.
+func (conv *Conv) Supports(x Feature) bool {
+	if conv.Features == nil {
+		for _, y := range baseFeatures {
+			if x == y {
+				return true
+			}
+		}
+		return false
+	}
+	return conv.Features[x]
+}
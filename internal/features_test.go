@@ -0,0 +1,72 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package internal
+
+import (
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseFeatures(t *testing.T) {
+	for _, tc := range []struct {
+		s        string
+		expected Features
+	}{
+		{"", DefaultFeatures()},
+		{"all", DefaultFeatures()},
+		{"none", Features{}},
+		{"all,-pg-date,-json,-float32,-default-values,-check-constraints,-sequences,-named-schemas", Features{PGNumeric: true, PGArrays: true}},
+		{"none, PG-Date", Features{PGDate: true}},
+	} {
+		f, err := ParseFeatures(tc.s)
+		assert.Nil(t, err, tc.s)
+		assert.Equal(t, tc.expected, f, tc.s)
+	}
+	for _, s := range []string{"pg-json", "pg-date,none", "-all", "json,auto"} {
+		_, err := ParseFeatures(s)
+		assert.NotNil(t, err, s)
+	}
+}
+
+func TestAutoFeatures(t *testing.T) {
+	old, set := os.LookupEnv(emulatorHostEnv)
+	defer func() {
+		if set {
+			os.Setenv(emulatorHostEnv, old)
+		} else {
+			os.Unsetenv(emulatorHostEnv)
+		}
+	}()
+	os.Unsetenv(emulatorHostEnv)
+	f, err := ParseFeatures("auto")
+	assert.Nil(t, err)
+	assert.Equal(t, DefaultFeatures(), f)
+	os.Setenv(emulatorHostEnv, "localhost:9010")
+	f, err = ParseFeatures("auto,json")
+	assert.Nil(t, err)
+	assert.Equal(t, Features{PGNumeric: true, PGDate: true, PGArrays: true, JSON: true}, f)
+}
+
+func TestSupports(t *testing.T) {
+	conv := MakeConv()
+	assert.True(t, conv.Supports(PGDate))
+	assert.False(t, conv.Supports(JSON))
+	conv.Features = Features{PGNumeric: true}
+	assert.False(t, conv.Supports(PGDate))
+	assert.True(t, conv.Supports(PGNumeric))
+	assert.Equal(t, "pg-numeric", conv.Features.String())
+}
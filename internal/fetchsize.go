@@ -0,0 +1,88 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package internal
+
+// DefaultFetchBytes is the default FetchSize.Bytes (64MB).
+const DefaultFetchBytes = 64 << 20
+
+// FetchSize configures batched reads of the tables of live source
+// databases (the postgres and mysql drivers). Tables with a primary key
+// are read in batches of rows, in primary key order, instead of with a
+// single query, so that tables with very wide rows (e.g. many multi-MB
+// TOASTed values) don't cause long-running queries and memory pressure on
+// the source. The zero value reads each table with a single query.
+type FetchSize struct {
+	Rows  int   // Largest number of rows read per batch.
+	Bytes int64 // Number of bytes to read per batch, 0 for DefaultFetchBytes.
+}
+
+// SetFetchSize sets how the tables of live source databases are read.
+func (conv *Conv) SetFetchSize(f FetchSize) {
+	conv.fetchSize = f
+}
+
+// Fetcher returns the Fetcher to read srcTable in batches with, and true
+// if it is read in batches (i.e. if batched reads are enabled and it has a
+// primary key).
+func (conv *Conv) Fetcher(srcTable string) (*Fetcher, bool) {
+	if conv.fetchSize.Rows <= 0 || len(conv.SrcSchema[srcTable].PrimaryKeys) == 0 {
+		return nil, false
+	}
+	bytes := conv.fetchSize.Bytes
+	if bytes <= 0 {
+		bytes = DefaultFetchBytes
+	}
+	return &Fetcher{table: srcTable, maxRows: conv.fetchSize.Rows, bytes: bytes, rows: conv.fetchSize.Rows}, true
+}
+
+// Fetcher adapts the number of rows read per batch to the size of the rows
+// of a table: when the average size of the rows of a batch exceeds
+// FetchSize.Bytes divided by the number of rows of the batch, the next
+// batch has fewer rows (down to a single row), and it grows back (up to
+// FetchSize.Rows) as rows get smaller.
+type Fetcher struct {
+	table   string
+	maxRows int
+	bytes   int64
+	rows    int
+}
+
+// Rows returns the number of rows to read in the next batch.
+func (f *Fetcher) Rows() int {
+	return f.rows
+}
+
+// Done records that a batch of rows was read, and their total size in
+// bytes.
+func (f *Fetcher) Done(rows int, bytes int64) {
+	if rows == 0 {
+		return
+	}
+	avg := bytes / int64(rows)
+	if avg < 1 {
+		avg = 1
+	}
+	next := f.bytes / avg
+	if next > int64(f.maxRows) {
+		next = int64(f.maxRows)
+	}
+	if next < 1 {
+		next = 1
+	}
+	if int(next) < f.rows {
+		VerbosePrintf("Reading table %s in batches of %d rows (average row size: %d bytes)\n", f.table, next, avg)
+	}
+	f.rows = int(next)
+}
@@ -0,0 +1,50 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package internal
+
+import (
+	"testing"
+
+	"github.com/cloudspannerecosystem/harbourbridge/schema"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFetcher(t *testing.T) {
+	conv := MakeConv()
+	conv.SrcSchema["t"] = schema.Table{Name: "t", PrimaryKeys: []schema.Key{{Column: "a"}}}
+	conv.SrcSchema["nopk"] = schema.Table{Name: "nopk"}
+	_, ok := conv.Fetcher("t")
+	assert.False(t, ok)
+	conv.SetFetchSize(FetchSize{Rows: 1000, Bytes: 1 << 20})
+	_, ok = conv.Fetcher("nopk")
+	assert.False(t, ok)
+	f, ok := conv.Fetcher("t")
+	assert.True(t, ok)
+	assert.Equal(t, 1000, f.Rows())
+	// Small rows: batches keep their size.
+	f.Done(1000, 100*1000)
+	assert.Equal(t, 1000, f.Rows())
+	// 64KB rows: 16 rows per batch.
+	f.Done(1000, 64<<10*1000)
+	assert.Equal(t, 16, f.Rows())
+	// Rows larger than the size of a batch: a row per batch.
+	f.Done(16, 2<<20*16)
+	assert.Equal(t, 1, f.Rows())
+	// Batches grow back.
+	f.Done(1, 1<<10)
+	assert.Equal(t, 1000, f.Rows())
+	f.Done(0, 0)
+	assert.Equal(t, 1000, f.Rows())
+}
@@ -0,0 +1,105 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package internal
+
+import (
+	"bufio"
+	"fmt"
+	"sort"
+)
+
+// SourceFixer gives the statements of a source database dialect that fix
+// issues in the source database (see SourceFixes). Tables are source
+// table names.
+type SourceFixer interface {
+	// AddPrimaryKey adds an auto-incremented primary key column col to
+	// srcTable.
+	AddPrimaryKey(srcTable, col string) string
+	// ClearInvalidDates sets the values of col that are outside Spanner's
+	// date range (including zero dates) to NULL.
+	ClearInvalidDates(srcTable, col string) string
+	// DropIndex drops index of srcTable.
+	DropIndex(srcTable, index string) string
+}
+
+// SourceFix is a statement to run on the source database to fix an issue
+// that is cheaper to fix before migrating than after. SQL is empty if the
+// issue can't be fixed automatically.
+type SourceFix struct {
+	Table string // Source table.
+	Issue string
+	SQL   string
+}
+
+// SourceFixes returns the fixes of the issues of the source tables of
+// conv, by table (sorted by name):
+//   - tables without a primary key, which are given a synthetic primary
+//     key (see AddPrimaryKeys) that is never seen by applications: adding
+//     one to the source keeps the keys of rows stable across migrations;
+//   - columns with invalid dates (from an anomaly scan, see
+//     ScanAnomalies), which would otherwise fail to convert;
+//   - non-unique indexes that the source database reports as unused,
+//     which would otherwise slow down data conversion.
+func SourceFixes(conv *Conv, f SourceFixer, anomalies []Anomaly) []SourceFix {
+	invalidDates := make(map[string][]Anomaly)
+	for _, a := range anomalies {
+		if a.Kind == InvalidDate {
+			invalidDates[a.Table] = append(invalidDates[a.Table], a)
+		}
+	}
+	tables := conv.SrcTables()
+	sort.Strings(tables)
+	var l []SourceFix
+	for _, srcTable := range tables {
+		srcSchema := conv.SrcSchema[srcTable]
+		if spTable, err := GetSpannerTable(conv, srcTable); err == nil && len(srcSchema.PrimaryKeys) == 0 {
+			if sk, ok := conv.SyntheticPKeys[spTable]; ok {
+				l = append(l, SourceFix{Table: srcTable, Issue: "no primary key", SQL: f.AddPrimaryKey(srcTable, sk.Col)})
+			}
+		}
+		for _, a := range invalidDates[srcTable] {
+			fix := SourceFix{Table: srcTable, Issue: fmt.Sprintf("%d rows with invalid dates in column %s", a.Count, a.Column)}
+			if srcSchema.ColDefs[a.Column].NotNull {
+				fix.Issue += " (NOT NULL, so it must be fixed by hand)"
+			} else {
+				fix.SQL = f.ClearInvalidDates(srcTable, a.Column)
+			}
+			l = append(l, fix)
+		}
+		for _, index := range srcSchema.Indexes {
+			if index.HasScans && index.Scans == 0 && !index.Unique {
+				l = append(l, SourceFix{Table: srcTable, Issue: fmt.Sprintf("index %s is unused", index.Name), SQL: f.DropIndex(srcTable, index.Name)})
+			}
+		}
+	}
+	return l
+}
+
+// WriteSourceFixes writes fixes to w as a SQL script, with a comment
+// describing the issue fixed by each statement.
+func WriteSourceFixes(fixes []SourceFix, w *bufio.Writer) {
+	w.WriteString("-- Statements that fix issues in the source database before migrating.\n")
+	w.WriteString("-- Review them, and take a backup, before running them.\n")
+	if len(fixes) == 0 {
+		w.WriteString("-- No issues found.\n")
+		return
+	}
+	for _, f := range fixes {
+		fmt.Fprintf(w, "\n-- Table %s: %s.\n", f.Table, f.Issue)
+		if f.SQL != "" {
+			fmt.Fprintf(w, "%s\n", f.SQL)
+		}
+	}
+}
@@ -0,0 +1,80 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package internal
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"testing"
+
+	"github.com/cloudspannerecosystem/harbourbridge/schema"
+	"github.com/stretchr/testify/assert"
+)
+
+type testFixer struct{}
+
+func (testFixer) AddPrimaryKey(srcTable, col string) string {
+	return fmt.Sprintf("ALTER TABLE %s ADD %s;", srcTable, col)
+}
+
+func (testFixer) ClearInvalidDates(srcTable, col string) string {
+	return fmt.Sprintf("UPDATE %s SET %s = NULL;", srcTable, col)
+}
+
+func (testFixer) DropIndex(srcTable, index string) string {
+	return fmt.Sprintf("DROP INDEX %s;", index)
+}
+
+func TestSourceFixes(t *testing.T) {
+	conv := MakeConv()
+	conv.SrcSchema["t1"] = schema.Table{
+		Name:     "t1",
+		ColNames: []string{"a", "b"},
+		ColDefs:  map[string]schema.Column{"a": {Name: "a"}, "b": {Name: "b", NotNull: true}},
+		Indexes: []schema.Index{
+			{Name: "unused", Keys: []schema.Key{{Column: "a"}}, HasScans: true},
+			{Name: "used", Keys: []schema.Key{{Column: "a"}}, HasScans: true, Scans: 3},
+			{Name: "unique", Unique: true, Keys: []schema.Key{{Column: "b"}}, HasScans: true},
+			{Name: "unknown", Keys: []schema.Key{{Column: "b"}}},
+		},
+	}
+	conv.SrcSchema["t2"] = schema.Table{Name: "t2", ColNames: []string{"c"}, PrimaryKeys: []schema.Key{{Column: "c"}}}
+	conv.ToSpanner["t1"] = NameAndCols{Name: "t1", Cols: map[string]string{"a": "a", "b": "b"}}
+	conv.ToSpanner["t2"] = NameAndCols{Name: "t2", Cols: map[string]string{"c": "c"}}
+	conv.SyntheticPKeys["t1"] = SyntheticPKey{Col: "synth_id"}
+	anomalies := []Anomaly{
+		{Table: "t1", Column: "a", Kind: InvalidDate, Count: 2},
+		{Table: "t1", Column: "b", Kind: InvalidDate, Count: 1},
+		{Table: "t2", Column: "c", Kind: InvalidUTF8, Count: 5},
+	}
+	fixes := SourceFixes(conv, testFixer{}, anomalies)
+	assert.Equal(t, []SourceFix{
+		{Table: "t1", Issue: "no primary key", SQL: "ALTER TABLE t1 ADD synth_id;"},
+		{Table: "t1", Issue: "2 rows with invalid dates in column a", SQL: "UPDATE t1 SET a = NULL;"},
+		{Table: "t1", Issue: "1 rows with invalid dates in column b (NOT NULL, so it must be fixed by hand)"},
+		{Table: "t1", Issue: "index unused is unused", SQL: "DROP INDEX unused;"},
+	}, fixes)
+
+	var buf bytes.Buffer
+	w := bufio.NewWriter(&buf)
+	WriteSourceFixes(fixes[2:4], w)
+	w.Flush()
+	assert.Equal(t, "-- Statements that fix issues in the source database before migrating.\n"+
+		"-- Review them, and take a backup, before running them.\n"+
+		"\n-- Table t1: 1 rows with invalid dates in column b (NOT NULL, so it must be fixed by hand).\n"+
+		"\n-- Table t1: index unused is unused.\n"+
+		"DROP INDEX unused;\n", buf.String())
+}
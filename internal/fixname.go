@@ -27,10 +27,11 @@ var badOtherChar = regexp.MustCompile("[^a-zA-Z0-9_]")
 // adhere to the following regexp:
 //   {a-z|A-Z}[{a-z|A-Z|0-9|_}+]
 // If the first character of the name is not allowed, we replace it by "A".
-// We replace all other problem characters by "_".
+// We replace all other problem characters by "_", and truncate names
+// longer than MaxNameLength.
 // Returns a Spanner-acceptable name, and whether we had to change the name.
 func FixName(name string) (string, bool) {
-	if nameRegexp.MatchString(name) {
+	if nameRegexp.MatchString(name) && len(name) <= MaxNameLength {
 		return name, false
 	}
 	if len(name) == 0 {
@@ -38,5 +39,8 @@ func FixName(name string) (string, bool) {
 	}
 	name = badFirstChar.ReplaceAllString(name, "A")
 	name = badOtherChar.ReplaceAllString(name, "_")
+	if len(name) > MaxNameLength {
+		name = name[:MaxNameLength]
+	}
 	return name, true
 }
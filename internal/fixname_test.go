@@ -15,6 +15,7 @@
 package internal
 
 import (
+	"strings"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -32,6 +33,9 @@ func TestFixName(t *testing.T) {
 		{"badstart1", "_mytable", "Amytable", true},
 		{"badstart2", "8mytable", "Amytable", true},
 		{"verybad", "my\nt\ta&$#ble", "my_t_a___ble", true},
+		{"spaces and dots", "my schema.my table", "my_schema_my_table", true},
+		{"unicode", "tablé", "tabl_", true},
+		{"too long", strings.Repeat("x", MaxNameLength+1), strings.Repeat("x", MaxNameLength), true},
 	}
 	for _, tc := range tests {
 		n, c := FixName(tc.in)
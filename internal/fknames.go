@@ -0,0 +1,104 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package internal
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// MaxNameLength is Spanner's limit on the length of table, column, index
+// and constraint names.
+const MaxNameLength = 128
+
+var placeholderRegexp = regexp.MustCompile(`\{[^{}]*\}`)
+
+// NameForeignKeys renames the foreign keys of the Spanner schema using
+// template, in which the following placeholders are replaced:
+//
+//	{table}: the Spanner table of the foreign key
+//	{cols}: its columns, separated by '_'
+//	{ref_table}: the referenced table
+//	{ref_cols}: the referenced columns, separated by '_'
+//	{name}: the current name of the foreign key (derived from its name in
+//	the source database, and empty if it has none)
+//
+// For example, 'FK_{table}_{cols}' names a foreign key of table orders on
+// column customer_id 'FK_orders_customer_id'. Names are fixed to be legal
+// Spanner names (see FixName). Since names only depend on the foreign key
+// itself, they don't change when other parts of the schema change. When
+// names clash (with each other, or with a table or index), a suffix _2,
+// _3, ... is added to the later ones (in table name order). An empty
+// template leaves names unchanged.
+func (conv *Conv) NameForeignKeys(template string) error {
+	if template == "" {
+		return nil
+	}
+	for _, p := range placeholderRegexp.FindAllString(template, -1) {
+		switch p {
+		case "{table}", "{cols}", "{ref_table}", "{ref_cols}", "{name}":
+		default:
+			return fmt.Errorf("bad foreign key name template %q: unknown placeholder %s", template, p)
+		}
+	}
+	var tables []string
+	used := make(map[string]bool)
+	for t, ct := range conv.SpSchema {
+		tables = append(tables, t)
+		used[strings.ToLower(t)] = true
+		for _, index := range ct.Indexes {
+			used[strings.ToLower(index.Name)] = true
+		}
+	}
+	sort.Strings(tables)
+	for _, t := range tables {
+		ct := conv.SpSchema[t]
+		for i, fk := range ct.Fks {
+			name := strings.NewReplacer(
+				"{table}", t,
+				"{cols}", strings.Join(fk.Columns, "_"),
+				"{ref_table}", fk.ReferTable,
+				"{ref_cols}", strings.Join(fk.ReferColumns, "_"),
+				"{name}", fk.Name,
+			).Replace(template)
+			ct.Fks[i].Name = uniqueName(name, used)
+		}
+	}
+	return nil
+}
+
+// uniqueName fixes name to be a legal Spanner name that isn't in used
+// (ignoring case), adding a suffix _2, _3, ... if needed, and adds it to
+// used.
+func uniqueName(name string, used map[string]bool) string {
+	name, _ = FixName(name)
+	base := name
+	if len(base) > MaxNameLength {
+		base = base[:MaxNameLength]
+	}
+	name = base
+	for i := 2; used[strings.ToLower(name)]; i++ {
+		suffix := fmt.Sprintf("_%d", i)
+		if len(base)+len(suffix) > MaxNameLength {
+			name = base[:MaxNameLength-len(suffix)] + suffix
+		} else {
+			name = base + suffix
+		}
+	}
+	used[strings.ToLower(name)] = true
+	return name
+}
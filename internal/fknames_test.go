@@ -0,0 +1,61 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package internal
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/cloudspannerecosystem/harbourbridge/spanner/ddl"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNameForeignKeys(t *testing.T) {
+	conv := remodelTestConv()
+	settings := conv.SpSchema["settings"]
+	settings.Fks = []ddl.Foreignkey{
+		{Name: "settings_user", Columns: []string{"user_id"}, ReferTable: "users", ReferColumns: []string{"id"}},
+		{Name: "", Columns: []string{"user_id"}, ReferTable: "users", ReferColumns: []string{"id"}},
+	}
+	settings.Indexes = []ddl.CreateIndex{{Name: "FK_settings_user_id_users_2", Table: "settings", Keys: []ddl.IndexKey{{Col: "theme"}}}}
+	conv.SpSchema["settings"] = settings
+
+	assert.Nil(t, conv.NameForeignKeys(""))
+	assert.Equal(t, "settings_user", conv.SpSchema["settings"].Fks[0].Name)
+
+	// The second foreign key's name clashes with the first one, and then
+	// with an index.
+	assert.Nil(t, conv.NameForeignKeys("FK_{table}_{cols}_{ref_table}"))
+	assert.Equal(t, "FK_settings_user_id_users", conv.SpSchema["settings"].Fks[0].Name)
+	assert.Equal(t, "FK_settings_user_id_users_3", conv.SpSchema["settings"].Fks[1].Name)
+	// Applying the template again doesn't change names.
+	assert.Nil(t, conv.NameForeignKeys("FK_{table}_{cols}_{ref_table}"))
+	assert.Equal(t, "FK_settings_user_id_users_3", conv.SpSchema["settings"].Fks[1].Name)
+
+	assert.Nil(t, conv.NameForeignKeys("{ref_cols} fk"))
+	assert.Equal(t, "id_fk", conv.SpSchema["settings"].Fks[0].Name)
+	assert.Equal(t, "id_fk_2", conv.SpSchema["settings"].Fks[1].Name)
+
+	assert.NotNil(t, conv.NameForeignKeys("FK_{tables}"))
+}
+
+func TestUniqueName(t *testing.T) {
+	used := map[string]bool{"users": true}
+	assert.Equal(t, "Users_2", uniqueName("Users", used))
+	assert.Equal(t, "USERS_3", uniqueName("USERS", used))
+	long := strings.Repeat("x", 200)
+	assert.Equal(t, strings.Repeat("x", MaxNameLength), uniqueName(long, used))
+	assert.Equal(t, strings.Repeat("x", MaxNameLength-2)+"_2", uniqueName(long, used))
+}
@@ -0,0 +1,177 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package golden implements golden-file tests of schema conversion, shared
+// by the source packages (postgres, mysql, dynamodb).
+//
+// Each fixture in a test data directory is a source schema: either a dump
+// snippet (name.sql), or a JSON list of schema.Table, as built from the
+// information schema (name.json). For each target dialect, the Spanner
+// DDL and the report generated for the fixture are compared to the golden
+// files name.<target>.ddl and name.<target>.report.txt. Targets support
+// all Spanner features (see internal.DefaultFeatures). To add a type
+// mapping, add a fixture that uses it and run
+//
+//	go test ./<package> -run TestGolden -update
+//
+// to write its golden files, then review them like any other change.
+package golden
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"flag"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/cloudspannerecosystem/harbourbridge/internal"
+	"github.com/cloudspannerecosystem/harbourbridge/schema"
+	"github.com/cloudspannerecosystem/harbourbridge/spanner/ddl"
+)
+
+var update = flag.Bool("update", false, "update: rewrite the golden files of schema conversion tests")
+
+// Targets of conversions.
+const (
+	Spanner              = "spanner"
+	ExperimentalPostgres = "experimental_postgres"
+)
+
+// Converter converts fixtures of a source package.
+type Converter struct {
+	// Driver is the driver named in reports of JSON fixtures, and
+	// DumpDriver the one named in reports of dump fixtures.
+	Driver, DumpDriver string
+	// Schema converts the source schema of conv (read from a JSON
+	// fixture) to Spanner.
+	Schema func(conv *internal.Conv) error
+	// Dump converts dump r, in schema mode. It is nil for sources that
+	// don't have dumps.
+	Dump func(conv *internal.Conv, r *internal.Reader) error
+	// Targets are the targets fixtures are converted to (nil means
+	// Spanner only).
+	Targets []string
+}
+
+// Run runs the golden-file tests of the fixtures of dir.
+func Run(t *testing.T, dir string, c Converter) {
+	fixtures, err := filepath.Glob(filepath.Join(dir, "*"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	sort.Strings(fixtures)
+	targets := c.Targets
+	if len(targets) == 0 {
+		targets = []string{Spanner}
+	}
+	n := 0
+	for _, f := range fixtures {
+		ext := filepath.Ext(f)
+		if ext != ".sql" && ext != ".json" {
+			continue
+		}
+		if ext == ".sql" && c.Dump == nil {
+			t.Errorf("%s: dump fixtures aren't supported for driver %s", f, c.Driver)
+			continue
+		}
+		n++
+		name := strings.TrimSuffix(f, ext)
+		for _, target := range targets {
+			t.Run(filepath.Base(name)+"/"+target, func(t *testing.T) {
+				conv, driver, err := convert(f, target, c)
+				if err != nil {
+					t.Fatalf("can't convert %s: %v", f, err)
+				}
+				check(t, name+"."+target+".ddl", ddlOf(conv, target))
+				check(t, name+"."+target+".report.txt", reportOf(conv, driver))
+			})
+		}
+	}
+	if n == 0 {
+		t.Errorf("no fixtures in %s", dir)
+	}
+}
+
+func convert(fixture, target string, c Converter) (*internal.Conv, string, error) {
+	conv := internal.MakeConv()
+	conv.TargetDb = target
+	conv.Features = internal.DefaultFeatures()
+	conv.SetSchemaMode()
+	f, err := os.Open(fixture)
+	if err != nil {
+		return nil, "", err
+	}
+	defer f.Close()
+	if filepath.Ext(fixture) == ".sql" {
+		conv.SetDataSink(nil)
+		return conv, c.DumpDriver, c.Dump(conv, internal.NewReader(bufio.NewReader(f), nil))
+	}
+	var tables []schema.Table
+	if err := json.NewDecoder(f).Decode(&tables); err != nil {
+		return nil, "", err
+	}
+	for _, t := range tables {
+		conv.SetSrcTable(t)
+	}
+	return conv, c.Driver, c.Schema(conv)
+}
+
+func ddlOf(conv *internal.Conv, target string) string {
+	stmts := conv.GetDDL(ddl.Config{Comments: true, Tables: true, ForeignKeys: true, PostgreSQL: target == ExperimentalPostgres})
+	return strings.Join(stmts, ";\n\n") + ";\n"
+}
+
+func reportOf(conv *internal.Conv, driver string) string {
+	var b bytes.Buffer
+	w := bufio.NewWriter(&b)
+	internal.GenerateReport(driver, conv, w, nil, true, true)
+	w.Flush()
+	return b.String()
+}
+
+// check compares got to golden file name, or rewrites it with -update.
+func check(t *testing.T, name, got string) {
+	if *update {
+		if err := ioutil.WriteFile(name, []byte(got), 0644); err != nil {
+			t.Fatal(err)
+		}
+		return
+	}
+	want, err := ioutil.ReadFile(name)
+	if err != nil {
+		t.Fatalf("can't read golden file (use -update to create it): %v", err)
+	}
+	if got == string(want) {
+		return
+	}
+	gotLines, wantLines := strings.Split(got, "\n"), strings.Split(string(want), "\n")
+	for i := 0; i < len(gotLines) || i < len(wantLines); i++ {
+		var g, w string
+		if i < len(gotLines) {
+			g = gotLines[i]
+		}
+		if i < len(wantLines) {
+			w = wantLines[i]
+		}
+		if g != w {
+			t.Errorf("%s differs from the golden file (use -update to rewrite it after review) at line %d:\ngot:  %q\nwant: %q", name, i+1, g, w)
+			return
+		}
+	}
+}
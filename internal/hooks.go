@@ -0,0 +1,159 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package internal
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+
+	"github.com/cloudspannerecosystem/harbourbridge/schema"
+	"github.com/cloudspannerecosystem/harbourbridge/spanner/ddl"
+)
+
+// PreTableHook is called with the source schema of each table before it
+// is converted to Spanner. It can modify t e.g. to change column types,
+// but not its name. Returning an error aborts schema conversion.
+type PreTableHook func(t *schema.Table) error
+
+// PostTableHook is called with each Spanner table produced by schema
+// conversion, before it is added to the Spanner schema. It can modify
+// ct e.g. to add columns or indexes, or change column types, but it
+// can't rename ct or remove columns that data is converted to (columns
+// it adds are not written by data conversion, so they should be
+// nullable). Returning an error aborts schema conversion, which can be
+// used to enforce naming conventions.
+type PostTableHook func(srcTable string, ct *ddl.CreateTable) error
+
+var hooks struct {
+	sync.Mutex
+	pre  []PreTableHook
+	post []PostTableHook
+}
+
+// RegisterPreTableHook adds h to the hooks called before each table is
+// converted. Hooks are called in registration order.
+func RegisterPreTableHook(h PreTableHook) {
+	hooks.Lock()
+	defer hooks.Unlock()
+	hooks.pre = append(hooks.pre, h)
+}
+
+// RegisterPostTableHook adds h to the hooks called after each table is
+// converted. Hooks are called in registration order.
+func RegisterPostTableHook(h PostTableHook) {
+	hooks.Lock()
+	defer hooks.Unlock()
+	hooks.post = append(hooks.post, h)
+}
+
+// ResetTableHooks removes all registered hooks.
+func ResetTableHooks() {
+	hooks.Lock()
+	defer hooks.Unlock()
+	hooks.pre, hooks.post = nil, nil
+}
+
+func tableHooks() ([]PreTableHook, []PostTableHook) {
+	hooks.Lock()
+	defer hooks.Unlock()
+	return hooks.pre, hooks.post
+}
+
+// RunPreTableHooks calls the registered PreTableHooks on each table of
+// conv.SrcSchema (in name order), and updates conv.SrcSchema with the
+// tables they return. Source packages call it before converting the
+// schema.
+func (conv *Conv) RunPreTableHooks() error {
+	pre, _ := tableHooks()
+	if len(pre) == 0 {
+		return nil
+	}
+	var names []string
+	for name := range conv.SrcSchema {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		t := conv.SrcSchema[name]
+		for _, h := range pre {
+			if err := h(&t); err != nil {
+				return fmt.Errorf("table %s rejected by hook: %w", name, err)
+			}
+			if t.Name != name {
+				return fmt.Errorf("hook renamed table %s to %s: hooks can't rename tables", name, t.Name)
+			}
+		}
+		conv.SetSrcTable(t)
+	}
+	return nil
+}
+
+// AddConvertedTable calls the registered PostTableHooks on ct, the
+// Spanner table converted from srcTable, and adds the result to
+// conv.SpSchema. Source packages call it for each table they convert.
+func (conv *Conv) AddConvertedTable(srcTable string, ct ddl.CreateTable) error {
+	_, post := tableHooks()
+	for _, h := range post {
+		name, cols := ct.Name, conv.mappedCols(ct.Name)
+		if err := h(srcTable, &ct); err != nil {
+			return fmt.Errorf("table %s rejected by hook: %w", ct.Name, err)
+		}
+		if ct.Name != name {
+			return fmt.Errorf("hook renamed table %s to %s: hooks can't rename tables", name, ct.Name)
+		}
+		for _, c := range cols {
+			if _, ok := ct.ColDefs[c]; !ok {
+				return fmt.Errorf("hook removed column %s of table %s: hooks can't remove or rename columns", c, name)
+			}
+		}
+		if err := checkColNames(ct); err != nil {
+			return fmt.Errorf("hook returned an invalid table %s: %w", name, err)
+		}
+	}
+	conv.schemaMu.Lock()
+	defer conv.schemaMu.Unlock()
+	conv.SpSchema[ct.Name] = ct
+	return nil
+}
+
+// mappedCols returns the Spanner columns of spTable that source columns
+// are mapped to.
+func (conv *Conv) mappedCols(spTable string) []string {
+	conv.schemaMu.Lock()
+	defer conv.schemaMu.Unlock()
+	var cols []string
+	for c := range conv.ToSource[spTable].Cols {
+		cols = append(cols, c)
+	}
+	return cols
+}
+
+// checkColNames checks that ColNames and ColDefs of ct are consistent,
+// and that new names are valid Spanner names.
+func checkColNames(ct ddl.CreateTable) error {
+	if len(ct.ColNames) != len(ct.ColDefs) {
+		return fmt.Errorf("ColNames and ColDefs have different columns")
+	}
+	for _, c := range ct.ColNames {
+		if _, ok := ct.ColDefs[c]; !ok {
+			return fmt.Errorf("column %s is in ColNames but not in ColDefs", c)
+		}
+		if _, changed := FixName(c); changed {
+			return fmt.Errorf("%s is not a valid Spanner column name", c)
+		}
+	}
+	return nil
+}
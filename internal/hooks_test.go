@@ -0,0 +1,107 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package internal
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/cloudspannerecosystem/harbourbridge/schema"
+	"github.com/cloudspannerecosystem/harbourbridge/spanner/ddl"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRunPreTableHooks(t *testing.T) {
+	defer ResetTableHooks()
+	conv := remodelTestConv()
+	RegisterPreTableHook(func(t *schema.Table) error {
+		if c, ok := t.ColDefs["bio"]; ok {
+			c.Type = schema.Type{Name: "text"}
+			t.ColDefs["bio"] = c
+		}
+		return nil
+	})
+	assert.Nil(t, conv.RunPreTableHooks())
+	assert.Equal(t, "text", conv.SrcSchema["users"].ColDefs["bio"].Type.Name)
+
+	RegisterPreTableHook(func(t *schema.Table) error {
+		if t.Name == "settings" {
+			return fmt.Errorf("no settings")
+		}
+		return nil
+	})
+	assert.NotNil(t, conv.RunPreTableHooks())
+
+	ResetTableHooks()
+	RegisterPreTableHook(func(t *schema.Table) error {
+		t.Name = "renamed"
+		return nil
+	})
+	assert.NotNil(t, conv.RunPreTableHooks())
+}
+
+func TestAddConvertedTable(t *testing.T) {
+	defer ResetTableHooks()
+	conv := remodelTestConv()
+	RegisterPostTableHook(func(srcTable string, ct *ddl.CreateTable) error {
+		ct.ColNames = append(ct.ColNames, "updated_at")
+		ct.ColDefs["updated_at"] = ddl.ColumnDef{Name: "updated_at", T: ddl.Type{Name: ddl.Timestamp}}
+		return nil
+	})
+	ct := conv.SpSchema["users"]
+	ct.ColDefs = copyColDefs(ct.ColDefs)
+	assert.Nil(t, conv.AddConvertedTable("users", ct))
+	assert.Equal(t, []string{"id", "name", "bio", "photo", "updated_at"}, conv.SpSchema["users"].ColNames)
+
+	for _, tc := range []struct {
+		name string
+		hook PostTableHook
+	}{
+		{"error", func(srcTable string, ct *ddl.CreateTable) error { return fmt.Errorf("bad table") }},
+		{"rename table", func(srcTable string, ct *ddl.CreateTable) error {
+			ct.Name = "other"
+			return nil
+		}},
+		{"remove column", func(srcTable string, ct *ddl.CreateTable) error {
+			ct.ColNames = []string{"user_id"}
+			delete(ct.ColDefs, "theme")
+			return nil
+		}},
+		{"ColNames and ColDefs differ", func(srcTable string, ct *ddl.CreateTable) error {
+			ct.ColNames = append(ct.ColNames, "extra")
+			return nil
+		}},
+		{"invalid name", func(srcTable string, ct *ddl.CreateTable) error {
+			ct.ColNames = append(ct.ColNames, "bad name")
+			ct.ColDefs["bad name"] = ddl.ColumnDef{Name: "bad name", T: ddl.Type{Name: ddl.Int64}}
+			return nil
+		}},
+	} {
+		ResetTableHooks()
+		RegisterPostTableHook(tc.hook)
+		ct := conv.SpSchema["settings"]
+		ct.ColDefs = copyColDefs(ct.ColDefs)
+		assert.NotNil(t, conv.AddConvertedTable("settings", ct), tc.name)
+		assert.Equal(t, []string{"user_id", "theme"}, conv.SpSchema["settings"].ColNames, tc.name)
+	}
+}
+
+func copyColDefs(m map[string]ddl.ColumnDef) map[string]ddl.ColumnDef {
+	c := make(map[string]ddl.ColumnDef)
+	for k, v := range m {
+		c[k] = v
+	}
+	return c
+}
@@ -0,0 +1,126 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package internal
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+)
+
+// incremental is the state of incremental data loads (see
+// SetIncremental).
+type incremental struct {
+	keys       map[string]string // Incremental key column, by source table.
+	since      map[string]string // Watermark of the previous load, by source table.
+	mu         sync.Mutex        // Protects watermarks.
+	watermarks map[string]string // Watermark reached by this load, by source table.
+}
+
+// ParseIncrementalKeys parses a comma-separated list of table=column
+// items, giving the incremental key column of source tables (see
+// SetIncremental).
+func ParseIncrementalKeys(s string) (map[string]string, error) {
+	m := make(map[string]string)
+	for _, item := range strings.Split(s, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+		i := strings.LastIndex(item, "=")
+		if i <= 0 || i == len(item)-1 {
+			return nil, fmt.Errorf("invalid incremental key %q: expected table=column", item)
+		}
+		table := item[:i]
+		if _, ok := m[table]; ok {
+			return nil, fmt.Errorf("table %s has several incremental keys", table)
+		}
+		m[table] = item[i+1:]
+	}
+	return m, nil
+}
+
+// SetIncremental configures incremental data loads: the data of the
+// source tables in keys is only read from rows whose incremental key
+// column (e.g. an updated_at timestamp, or a version number that
+// increases with each change) is at least the watermark of the table in
+// since, i.e. the rows changed since the previous load. Tables without a
+// watermark are read in full. Source packages read the new watermark of
+// each table (the largest value of its key column) before reading its
+// rows, and record it with SetWatermark.
+//
+// Rows whose key is exactly the watermark are read again, so that rows
+// changed within the same tick as the previous load aren't missed: rows
+// must be written with insert-or-update semantics.
+func (conv *Conv) SetIncremental(keys, since map[string]string) error {
+	for t, col := range keys {
+		srcSchema, ok := conv.SrcSchema[t]
+		if !ok && conv.spill != nil {
+			_, ok = conv.spill.files[t]
+		}
+		if !ok {
+			return fmt.Errorf("can't load table %s incrementally: no such source table", t)
+		}
+		if srcSchema.ColDefs != nil {
+			if _, ok := srcSchema.ColDefs[col]; !ok {
+				return fmt.Errorf("can't load table %s incrementally: no such column %s", t, col)
+			}
+		}
+	}
+	conv.incremental = &incremental{keys: keys, since: since, watermarks: make(map[string]string)}
+	return nil
+}
+
+// IncrementalKey returns the incremental key column of srcTable and its
+// watermark (empty if the table is read in full), and true if srcTable is
+// loaded incrementally.
+func (conv *Conv) IncrementalKey(srcTable string) (string, string, bool) {
+	if conv.incremental == nil {
+		return "", "", false
+	}
+	col, ok := conv.incremental.keys[srcTable]
+	return col, conv.incremental.since[srcTable], ok
+}
+
+// SetWatermark records the watermark reached by the load of srcTable. An
+// empty watermark (e.g. of an empty table) keeps the previous one.
+func (conv *Conv) SetWatermark(srcTable, watermark string) {
+	if conv.incremental == nil {
+		return
+	}
+	if watermark == "" {
+		watermark = conv.incremental.since[srcTable]
+	}
+	conv.incremental.mu.Lock()
+	defer conv.incremental.mu.Unlock()
+	conv.incremental.watermarks[srcTable] = watermark
+}
+
+// Watermarks returns the watermarks reached by the load of the tables
+// loaded incrementally, by source table.
+func (conv *Conv) Watermarks() map[string]string {
+	m := make(map[string]string)
+	if conv.incremental == nil {
+		return m
+	}
+	conv.incremental.mu.Lock()
+	defer conv.incremental.mu.Unlock()
+	for t, w := range conv.incremental.watermarks {
+		if w != "" {
+			m[t] = w
+		}
+	}
+	return m
+}
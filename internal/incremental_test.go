@@ -0,0 +1,53 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package internal
+
+import (
+	"testing"
+
+	"github.com/cloudspannerecosystem/harbourbridge/schema"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseIncrementalKeys(t *testing.T) {
+	m, err := ParseIncrementalKeys("orders=updated_at, s.users=version,")
+	assert.Nil(t, err)
+	assert.Equal(t, map[string]string{"orders": "updated_at", "s.users": "version"}, m)
+	for _, s := range []string{"orders", "orders=", "=updated_at", "orders=a,orders=b"} {
+		_, err := ParseIncrementalKeys(s)
+		assert.NotNil(t, err, s)
+	}
+}
+
+func TestIncremental(t *testing.T) {
+	conv := MakeConv()
+	_, _, ok := conv.IncrementalKey("t1")
+	assert.False(t, ok)
+	conv.SrcSchema["t1"] = schema.Table{Name: "t1", ColNames: []string{"a", "u"}, ColDefs: map[string]schema.Column{"a": {Name: "a"}, "u": {Name: "u"}}}
+	conv.SrcSchema["t2"] = schema.Table{Name: "t2", ColNames: []string{"u"}, ColDefs: map[string]schema.Column{"u": {Name: "u"}}}
+	assert.NotNil(t, conv.SetIncremental(map[string]string{"t3": "u"}, nil))
+	assert.NotNil(t, conv.SetIncremental(map[string]string{"t1": "b"}, nil))
+	assert.Nil(t, conv.SetIncremental(map[string]string{"t1": "u", "t2": "u"}, map[string]string{"t1": "2020-01-01"}))
+	col, since, ok := conv.IncrementalKey("t1")
+	assert.Equal(t, []interface{}{"u", "2020-01-01", true}, []interface{}{col, since, ok})
+	col, since, ok = conv.IncrementalKey("t2")
+	assert.Equal(t, []interface{}{"u", "", true}, []interface{}{col, since, ok})
+	// Empty tables keep their watermark.
+	conv.SetWatermark("t1", "")
+	conv.SetWatermark("t2", "")
+	assert.Equal(t, map[string]string{"t1": "2020-01-01"}, conv.Watermarks())
+	conv.SetWatermark("t2", "7")
+	assert.Equal(t, map[string]string{"t1": "2020-01-01", "t2": "7"}, conv.Watermarks())
+}
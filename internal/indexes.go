@@ -0,0 +1,205 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package internal
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/cloudspannerecosystem/harbourbridge/spanner/ddl"
+)
+
+// RedundantIndex is a secondary index of the Spanner schema that can
+// probably be dropped (or was dropped): every index slows down data conversion (and
+// writes), and Spanner has no need for indexes that the primary key or
+// another index already provide.
+type RedundantIndex struct {
+	Table  string // Spanner table.
+	Index  string // Spanner index.
+	Reason string // Why the index is redundant.
+}
+
+// SuggestDropIndexes returns the secondary indexes of the Spanner schema
+// that are redundant (sorted by table and index):
+//   - non-unique indexes whose keys are a prefix of the primary key, and
+//     unique indexes whose keys are the primary key;
+//   - non-unique indexes whose keys are a prefix of the keys of another
+//     index (of the first of two identical indexes);
+//   - non-unique indexes that the source database reports as unused
+//     (see Conv.IndexScans).
+//
+// Unique indexes are only redundant if the primary key enforces the same
+// constraint.
+func (conv *Conv) SuggestDropIndexes() []RedundantIndex {
+	var l []RedundantIndex
+	tables := conv.SpTables()
+	sort.Strings(tables)
+	for _, t := range tables {
+		l = append(l, conv.redundantIndexes(t)...)
+	}
+	return l
+}
+
+// redundantIndexes returns the redundant indexes of spTable, sorted by
+// name (see SuggestDropIndexes).
+func (conv *Conv) redundantIndexes(spTable string) []RedundantIndex {
+	var l []RedundantIndex
+	ct := conv.SpSchema[spTable]
+	for i, index := range ct.Indexes {
+		if reason := redundantReason(conv, ct, i); reason != "" {
+			l = append(l, RedundantIndex{Table: spTable, Index: index.Name, Reason: reason})
+		}
+	}
+	sort.Slice(l, func(i, j int) bool { return l[i].Index < l[j].Index })
+	return l
+}
+
+// redundantReason returns why the i-th index of ct is redundant, or ""
+// if it isn't.
+func redundantReason(conv *Conv, ct ddl.CreateTable, i int) string {
+	index := ct.Indexes[i]
+	switch {
+	case index.Unique && len(index.Keys) == len(ct.Pks) && isKeyPrefix(index.Keys, ct.Pks):
+		return "its keys are the primary key, which already enforces their uniqueness"
+	case index.Unique:
+		return ""
+	case isKeyPrefix(index.Keys, ct.Pks):
+		return "its keys are a prefix of the primary key, which Spanner orders rows by"
+	}
+	for j, other := range ct.Indexes {
+		if j == i || !isKeyPrefix(index.Keys, other.Keys) {
+			continue
+		}
+		// Of two identical indexes, only the first is redundant.
+		if len(index.Keys) < len(other.Keys) || other.Unique || j > i {
+			return fmt.Sprintf("its keys are a prefix of the keys of index '%s'", other.Name)
+		}
+	}
+	if scans, ok := conv.IndexScans[index.Name]; ok && scans == 0 {
+		return "the source database reports that it hasn't been used"
+	}
+	return ""
+}
+
+// isKeyPrefix returns true if keys (in order, with the same directions)
+// are a prefix of of.
+func isKeyPrefix(keys, of []ddl.IndexKey) bool {
+	if len(keys) == 0 || len(keys) > len(of) {
+		return false
+	}
+	for i, k := range keys {
+		if k != of[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// ParseDropIndexes parses a comma-separated list of entries of the form
+// 'index=drop' or 'index=keep', optionally starting with "auto" to also
+// drop the indexes suggested by SuggestDropIndexes. For example,
+// "auto,orders_customer_idx=keep". See Remodel.DropIndexes.
+func ParseDropIndexes(s string) (bool, map[string]bool, error) {
+	auto := false
+	indexes := make(map[string]bool)
+	for _, e := range strings.Split(s, ",") {
+		e = strings.TrimSpace(e)
+		switch {
+		case e == "":
+			continue
+		case strings.ToLower(e) == "auto":
+			auto = true
+			continue
+		}
+		i := strings.LastIndex(e, "=")
+		if i < 0 {
+			return false, nil, fmt.Errorf("bad index %q: expected index=drop or index=keep", e)
+		}
+		switch strings.ToLower(e[i+1:]) {
+		case "drop":
+			indexes[e[:i]] = true
+		case "keep":
+			indexes[e[:i]] = false
+		default:
+			return false, nil, fmt.Errorf("bad index %q: accepted values are \"drop\" and \"keep\"", e)
+		}
+	}
+	return auto, indexes, nil
+}
+
+// applyDropIndexes drops the Spanner indexes that indexes maps to true
+// and, if auto is set, the indexes suggested by SuggestDropIndexes that
+// indexes doesn't keep (map to false), and records them in
+// conv.DroppedIndexes. Suggestions are recomputed after each index is
+// dropped, so that of two indexes that make each other redundant, only
+// one is dropped. Indexes that have already been dropped (e.g. when conv
+// was read from a session file) are skipped.
+func (conv *Conv) applyDropIndexes(auto bool, indexes map[string]bool) error {
+	reasons := make(map[string]string)
+	for _, r := range conv.SuggestDropIndexes() {
+		reasons[r.Index] = r.Reason
+	}
+	var names []string
+	for n, drop := range indexes {
+		if drop {
+			names = append(names, n)
+		}
+	}
+	sort.Strings(names)
+	for _, n := range names {
+		if _, ok := conv.DroppedIndexes[n]; ok {
+			continue // Already applied.
+		}
+		reason, ok := reasons[n]
+		if !ok {
+			reason = "requested by the user"
+		}
+		if !conv.dropIndex(n, reason) {
+			return fmt.Errorf("can't drop index %s: unknown index", n)
+		}
+	}
+	for auto {
+		auto = false
+		for _, r := range conv.SuggestDropIndexes() {
+			if drop, ok := indexes[r.Index]; !ok || drop {
+				conv.dropIndex(r.Index, r.Reason)
+				auto = true
+				break
+			}
+		}
+	}
+	return nil
+}
+
+// dropIndex drops the Spanner index name, and returns false if there is
+// no such index.
+func (conv *Conv) dropIndex(name, reason string) bool {
+	for t, ct := range conv.SpSchema {
+		for i, index := range ct.Indexes {
+			if index.Name != name {
+				continue
+			}
+			ct.Indexes = append(ct.Indexes[:i:i], ct.Indexes[i+1:]...)
+			conv.SpSchema[t] = ct
+			if conv.DroppedIndexes == nil {
+				conv.DroppedIndexes = make(map[string]RedundantIndex)
+			}
+			conv.DroppedIndexes[name] = RedundantIndex{Table: t, Index: name, Reason: reason}
+			return true
+		}
+	}
+	return false
+}
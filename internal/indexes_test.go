@@ -0,0 +1,76 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package internal
+
+import (
+	"testing"
+
+	"github.com/cloudspannerecosystem/harbourbridge/spanner/ddl"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDropIndexes(t *testing.T) {
+	key := func(cols ...string) []ddl.IndexKey {
+		var l []ddl.IndexKey
+		for _, c := range cols {
+			l = append(l, ddl.IndexKey{Col: c})
+		}
+		return l
+	}
+	conv := MakeConv()
+	conv.SpSchema["t"] = ddl.CreateTable{
+		Name: "t",
+		Pks:  key("a", "b"),
+		Indexes: []ddl.CreateIndex{
+			{Name: "i1", Table: "t", Keys: key("a")},
+			{Name: "i2", Table: "t", Unique: true, Keys: key("a", "b")},
+			{Name: "i3", Table: "t", Unique: true, Keys: key("a")},
+			{Name: "i4", Table: "t", Keys: key("c")},
+			{Name: "i5", Table: "t", Keys: key("c", "d")},
+			{Name: "i6", Table: "t", Keys: key("d")},
+			{Name: "i7", Table: "t", Keys: key("d")},
+			{Name: "i8", Table: "t", Keys: []ddl.IndexKey{{Col: "b", Desc: true}}},
+			{Name: "i9", Table: "t", Keys: key("e")},
+		},
+	}
+	conv.IndexScans = map[string]int64{"i5": 12, "i9": 0}
+	assert.Equal(t, []RedundantIndex{
+		{Table: "t", Index: "i1", Reason: "its keys are a prefix of the primary key, which Spanner orders rows by"},
+		{Table: "t", Index: "i2", Reason: "its keys are the primary key, which already enforces their uniqueness"},
+		{Table: "t", Index: "i4", Reason: "its keys are a prefix of the keys of index 'i5'"},
+		{Table: "t", Index: "i6", Reason: "its keys are a prefix of the keys of index 'i7'"},
+		{Table: "t", Index: "i9", Reason: "the source database reports that it hasn't been used"},
+	}, conv.SuggestDropIndexes())
+
+	r := Remodel{AutoDropIndexes: true, DropIndexes: map[string]bool{"i3": true, "i9": false}}
+	assert.Nil(t, conv.ApplyRemodel(r))
+	var names []string
+	for _, index := range conv.SpSchema["t"].Indexes {
+		names = append(names, index.Name)
+	}
+	assert.Equal(t, []string{"i5", "i7", "i8", "i9"}, names)
+	assert.Equal(t, "requested by the user", conv.DroppedIndexes["i3"].Reason)
+	assert.Equal(t, 5, len(conv.DroppedIndexes))
+	// Already applied.
+	assert.Nil(t, conv.ApplyRemodel(Remodel{DropIndexes: map[string]bool{"i3": true}}))
+	assert.NotNil(t, conv.ApplyRemodel(Remodel{DropIndexes: map[string]bool{"missing": true}}))
+
+	auto, indexes, err := ParseDropIndexes("auto, i1=drop,i2=KEEP")
+	assert.Nil(t, err)
+	assert.True(t, auto)
+	assert.Equal(t, map[string]bool{"i1": true, "i2": false}, indexes)
+	_, _, err = ParseDropIndexes("i1")
+	assert.NotNil(t, err)
+}
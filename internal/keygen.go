@@ -0,0 +1,212 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package internal
+
+import (
+	"crypto/rand"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math/big"
+	"math/bits"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/cloudspannerecosystem/harbourbridge/spanner/ddl"
+)
+
+// KeyGenerator generates the values of synthetic primary keys (see
+// SyntheticPKey). Next is called with a lock held, so it doesn't need to
+// be safe for concurrent use.
+type KeyGenerator interface {
+	// Type is the type of the key column.
+	Type() ddl.Type
+	// Next returns the key of the row with sequence number seq (the
+	// number of rows of the table written before it).
+	Next(seq int64) interface{}
+}
+
+// RandomKeyGenerator is a KeyGenerator of random keys. When a seed is set
+// (see Conv.SetSeed), NextRandom is called instead of Next, with a source
+// of random values seeded by it, so that keys are reproducible.
+type RandomKeyGenerator interface {
+	KeyGenerator
+	// NextRandom is Next, taking random bytes from r.
+	NextRandom(seq int64, r io.Reader) interface{}
+}
+
+// SequenceKeys is the name of the default KeyGenerator: bit-reversed
+// sequence numbers, so that rows are spread across the key space.
+const SequenceKeys = "sequence"
+
+var keyGenerators = struct {
+	sync.Mutex
+	m map[string]KeyGenerator
+}{m: map[string]KeyGenerator{
+	SequenceKeys: sequenceKeys{},
+	"uuid":       uuidKeys{},
+	"ulid":       ulidKeys{},
+	"ksuid":      ksuidKeys{},
+}}
+
+// RegisterKeyGenerator makes g available as name (e.g. for plugins to add
+// generators), replacing any generator of that name.
+func RegisterKeyGenerator(name string, g KeyGenerator) {
+	keyGenerators.Lock()
+	defer keyGenerators.Unlock()
+	keyGenerators.m[name] = g
+}
+
+// KeyGenerators returns the names of the available key generators.
+func KeyGenerators() []string {
+	keyGenerators.Lock()
+	defer keyGenerators.Unlock()
+	var l []string
+	for name := range keyGenerators.m {
+		l = append(l, name)
+	}
+	sort.Strings(l)
+	return l
+}
+
+func keyGenerator(name string) (KeyGenerator, error) {
+	if name == "" {
+		name = SequenceKeys
+	}
+	keyGenerators.Lock()
+	g, ok := keyGenerators.m[name]
+	keyGenerators.Unlock()
+	if !ok {
+		return nil, fmt.Errorf("unknown key generator %q (accepted values are %s)", name, strings.Join(KeyGenerators(), ", "))
+	}
+	return g, nil
+}
+
+// setKeyGenerator makes the synthetic primary keys of all tables use the
+// KeyGenerator name, changing the type of their columns. Tables whose
+// keys already use it are skipped; it is an error to change the generator
+// of a table once rows have been given keys.
+func (conv *Conv) setKeyGenerator(name string) error {
+	g, err := keyGenerator(name)
+	if err != nil {
+		return err
+	}
+	if name == SequenceKeys {
+		name = ""
+	}
+	for t, sk := range conv.SyntheticPKeys {
+		if sk.Generator == name {
+			continue
+		}
+		if sk.Sequence > 0 {
+			return fmt.Errorf("can't change the key generator of table %s: keys have already been generated", t)
+		}
+		ct := conv.SpSchema[t]
+		cd := ct.ColDefs[sk.Col]
+		cd.T = g.Type()
+		ct.ColDefs[sk.Col] = cd
+		sk.Generator = name
+		conv.SyntheticPKeys[t] = sk
+	}
+	return nil
+}
+
+// nowForKeys is the clock of time-based key generators (for tests).
+var nowForKeys = time.Now
+
+type sequenceKeys struct{}
+
+func (sequenceKeys) Type() ddl.Type { return ddl.Type{Name: ddl.Int64} }
+
+func (sequenceKeys) Next(seq int64) interface{} {
+	return int64(bits.Reverse64(uint64(seq)))
+}
+
+// uuidKeys generates random (version 4) UUIDs, e.g.
+// 0b1e3a74-9f6c-4c1e-8a2d-5e8f3b7c9d10.
+type uuidKeys struct{}
+
+func (uuidKeys) Type() ddl.Type { return ddl.Type{Name: ddl.String, Len: 36} }
+
+func (g uuidKeys) Next(seq int64) interface{} { return g.NextRandom(seq, rand.Reader) }
+
+func (uuidKeys) NextRandom(_ int64, r io.Reader) interface{} {
+	var b [16]byte
+	io.ReadFull(r, b[:])
+	b[6] = b[6]&0x0f | 0x40 // Version 4.
+	b[8] = b[8]&0x3f | 0x80 // RFC 4122 variant.
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:])
+}
+
+// ulidKeys generates ULIDs: a 48-bit timestamp in milliseconds followed
+// by 80 random bits, in Crockford's base 32 (26 characters), e.g.
+// 01ARZ3NDEKTSV4RRFFQ69G5FAV. Only the random bits are reproducible (see
+// RandomKeyGenerator).
+type ulidKeys struct{}
+
+func (ulidKeys) Type() ddl.Type { return ddl.Type{Name: ddl.String, Len: 26} }
+
+func (g ulidKeys) Next(seq int64) interface{} { return g.NextRandom(seq, rand.Reader) }
+
+func (ulidKeys) NextRandom(_ int64, r io.Reader) interface{} {
+	var b [16]byte
+	ms := uint64(nowForKeys().UnixNano() / int64(time.Millisecond))
+	binary.BigEndian.PutUint64(b[:8], ms<<16)
+	io.ReadFull(r, b[6:])
+	return encodeDigits(b[:], 32, 26, func(r rune) rune { return rune(crockford[strings.IndexRune(bigDigits, r)]) })
+}
+
+// ksuidEpoch is the start of KSUID timestamps (2014-05-13T16:53:20Z).
+const ksuidEpoch = 1400000000
+
+// ksuidKeys generates KSUIDs: a 32-bit timestamp in seconds since
+// ksuidEpoch followed by 128 random bits, in base 62 (27 characters),
+// e.g. 0ujtsYcgvSTl8PAuAdqWYSMnLOv. As for ULIDs, only the random bits
+// are reproducible.
+type ksuidKeys struct{}
+
+func (ksuidKeys) Type() ddl.Type { return ddl.Type{Name: ddl.String, Len: 27} }
+
+func (g ksuidKeys) Next(seq int64) interface{} { return g.NextRandom(seq, rand.Reader) }
+
+func (ksuidKeys) NextRandom(_ int64, r io.Reader) interface{} {
+	var b [20]byte
+	binary.BigEndian.PutUint32(b[:4], uint32(nowForKeys().Unix()-ksuidEpoch))
+	io.ReadFull(r, b[4:])
+	// KSUIDs put upper case letters before lower case ones, as ASCII does.
+	return encodeDigits(b[:], 62, 27, func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z':
+			return r - 'a' + 'A'
+		case r >= 'A' && r <= 'Z':
+			return r - 'A' + 'a'
+		}
+		return r
+	})
+}
+
+const (
+	bigDigits = "0123456789abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ" // Digits of big.Int.Text.
+	crockford = "0123456789ABCDEFGHJKMNPQRSTVWXYZ"
+)
+
+// encodeDigits encodes b, as a big-endian number, in base, padded with
+// zeros to n digits, mapping the digits of big.Int.Text with digit.
+func encodeDigits(b []byte, base, n int, digit func(rune) rune) string {
+	s := strings.Map(digit, new(big.Int).SetBytes(b).Text(base))
+	return strings.Repeat("0", n-len(s)) + s
+}
@@ -0,0 +1,69 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package internal
+
+import (
+	"math/bits"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/cloudspannerecosystem/harbourbridge/spanner/ddl"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestKeyGenerators(t *testing.T) {
+	defer func() { nowForKeys = time.Now }()
+	now := time.Unix(0, 1469918176385*int64(time.Millisecond))
+	nowForKeys = func() time.Time { return now }
+
+	assert.Equal(t, int64(bits.Reverse64(3)), sequenceKeys{}.Next(3))
+	assert.Regexp(t, regexp.MustCompile(`^[0-9a-f]{8}-[0-9a-f]{4}-4[0-9a-f]{3}-[89ab][0-9a-f]{3}-[0-9a-f]{12}$`), uuidKeys{}.Next(0))
+	ulid := ulidKeys{}.Next(0).(string)
+	assert.Regexp(t, regexp.MustCompile(`^01ARYZ6S41[0-9A-HJKMNP-TV-Z]{16}$`), ulid)
+	ksuid := ksuidKeys{}.Next(0).(string)
+	assert.Regexp(t, regexp.MustCompile(`^[0-9A-Za-z]{27}$`), ksuid)
+
+	// Keys sort by time.
+	now = now.Add(time.Hour)
+	assert.True(t, ulidKeys{}.Next(0).(string) > ulid)
+	assert.True(t, ksuidKeys{}.Next(0).(string) > ksuid)
+}
+
+func TestSetKeyGenerator(t *testing.T) {
+	conv := MakeConv()
+	conv.SpSchema["t"] = ddl.CreateTable{
+		Name:     "t",
+		ColNames: []string{"a", "synth_id"},
+		ColDefs: map[string]ddl.ColumnDef{
+			"a":        {Name: "a", T: ddl.Type{Name: ddl.Int64}},
+			"synth_id": {Name: "synth_id", T: ddl.Type{Name: ddl.Int64}},
+		},
+		Pks: []ddl.IndexKey{{Col: "synth_id"}},
+	}
+	conv.SyntheticPKeys["t"] = SyntheticPKey{Col: "synth_id"}
+	assert.Nil(t, conv.ApplyRemodel(Remodel{SyntheticKeys: "ulid"}))
+	assert.Equal(t, ddl.Type{Name: ddl.String, Len: 26}, conv.SpSchema["t"].ColDefs["synth_id"].T)
+	assert.Equal(t, SyntheticPKey{Col: "synth_id", Generator: "ulid"}, conv.SyntheticPKeys["t"])
+	col, v, ok := conv.NextSyntheticPKey("t")
+	assert.True(t, ok)
+	assert.Equal(t, "synth_id", col)
+	assert.Equal(t, 26, len(v.(string)))
+
+	// Keys have been generated.
+	assert.Nil(t, conv.ApplyRemodel(Remodel{SyntheticKeys: "ulid"}))
+	assert.NotNil(t, conv.ApplyRemodel(Remodel{SyntheticKeys: "uuid"}))
+	assert.NotNil(t, conv.ApplyRemodel(Remodel{SyntheticKeys: "snowflake"}))
+}
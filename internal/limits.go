@@ -0,0 +1,96 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package internal
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/cloudspannerecosystem/harbourbridge/spanner/ddl"
+)
+
+// MaxIndexesPerTable is Spanner's limit on the number of secondary
+// indexes of a table.
+const MaxIndexesPerTable = 128
+
+// MaxForeignKeysPerTable is Spanner's limit on the number of foreign keys
+// of a table.
+const MaxForeignKeysPerTable = 64
+
+// limitWarnings describes how spTable exceeds Spanner's limits on indexes
+// and foreign keys per table, which would make creating its DDL fail, and
+// its primary key columns that can exceed Spanner's limit on key size.
+func (conv *Conv) limitWarnings(spTable string) []string {
+	var l []string
+	ct := conv.SpSchema[spTable]
+	if n := len(ct.Indexes); n > MaxIndexesPerTable {
+		l = append(l, fmt.Sprintf("Table has %d indexes, more than Spanner's limit of %d: creating them will fail. Drop some with -drop-indexes, or use -trim-to-limits to keep unique indexes and the most used indexes", n, MaxIndexesPerTable))
+	}
+	if n := len(ct.Fks); n > MaxForeignKeysPerTable {
+		l = append(l, fmt.Sprintf("Table has %d foreign keys, more than Spanner's limit of %d: creating them will fail. Use -trim-to-limits to keep the first %d", n, MaxForeignKeysPerTable, MaxForeignKeysPerTable))
+	}
+	if fks := conv.TrimmedFks[spTable]; len(fks) > 0 {
+		var names []string
+		for _, fk := range fks {
+			names = append(names, fk.Name)
+		}
+		l = append(l, fmt.Sprintf("Foreign keys %s were dropped to keep the table within Spanner's limit of %d foreign keys", strings.Join(names, ", "), MaxForeignKeysPerTable))
+	}
+	for _, c := range conv.longKeyCols(spTable) {
+		line := fmt.Sprintf("Primary key column '%s' is %s, so its values can exceed Spanner's limit of %d bytes on key size, and Spanner rejects their rows", c, ct.ColDefs[c].T.PrintColumnDefType(), MaxKeyBytes)
+		if err := conv.canChangeLongKey(spTable, c); err != nil {
+			line += fmt.Sprintf(": it can't be changed with -long-keys since %s", err)
+		} else {
+			line += fmt.Sprintf(": consider replacing it by its hash in the key with -long-keys=%s.%s=hash (or -long-keys=auto), or truncating it with -long-keys=%s.%s=truncate", spTable, c, spTable, c)
+		}
+		l = append(l, line)
+	}
+	return l
+}
+
+// trimToLimits drops the indexes and foreign keys of Spanner tables that
+// exceed Spanner's limits. Unique indexes are kept first, since they
+// enforce constraints, then the indexes with the most scans in the source
+// database (see Conv.IndexScans), in the order of the schema when scans
+// aren't known. Foreign keys are kept in the order of the schema. Dropped
+// indexes are recorded in conv.DroppedIndexes, and dropped foreign keys
+// in conv.TrimmedFks.
+func (conv *Conv) trimToLimits() {
+	for t, ct := range conv.SpSchema {
+		if len(ct.Indexes) > MaxIndexesPerTable {
+			indexes := append([]ddl.CreateIndex{}, ct.Indexes...)
+			sort.SliceStable(indexes, func(i, j int) bool {
+				if indexes[i].Unique != indexes[j].Unique {
+					return indexes[i].Unique
+				}
+				return conv.IndexScans[indexes[i].Name] > conv.IndexScans[indexes[j].Name]
+			})
+			reason := fmt.Sprintf("the table had more than %d indexes (Spanner's limit), and it was among the least used", MaxIndexesPerTable)
+			for _, index := range indexes[MaxIndexesPerTable:] {
+				conv.dropIndex(index.Name, reason)
+			}
+			ct = conv.SpSchema[t]
+		}
+		if len(ct.Fks) > MaxForeignKeysPerTable {
+			if conv.TrimmedFks == nil {
+				conv.TrimmedFks = make(map[string][]ddl.Foreignkey)
+			}
+			conv.TrimmedFks[t] = append(conv.TrimmedFks[t], ct.Fks[MaxForeignKeysPerTable:]...)
+			ct.Fks = ct.Fks[:MaxForeignKeysPerTable:MaxForeignKeysPerTable]
+			conv.SpSchema[t] = ct
+		}
+	}
+}
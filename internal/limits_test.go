@@ -0,0 +1,51 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package internal
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/cloudspannerecosystem/harbourbridge/spanner/ddl"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTrimToLimits(t *testing.T) {
+	conv := MakeConv()
+	ct := ddl.CreateTable{Name: "t", Pks: []ddl.IndexKey{{Col: "id"}}}
+	for i := 0; i < MaxIndexesPerTable+2; i++ {
+		ct.Indexes = append(ct.Indexes, ddl.CreateIndex{Name: fmt.Sprintf("i%d", i), Table: "t", Keys: []ddl.IndexKey{{Col: fmt.Sprintf("c%d", i)}}})
+	}
+	// i0 is unique, and i1 is the least used index.
+	ct.Indexes[0].Unique = true
+	conv.IndexScans = map[string]int64{"i1": 0}
+	for i := 2; i < MaxIndexesPerTable+2; i++ {
+		conv.IndexScans[fmt.Sprintf("i%d", i)] = int64(i)
+	}
+	for i := 0; i < MaxForeignKeysPerTable+1; i++ {
+		ct.Fks = append(ct.Fks, ddl.Foreignkey{Name: fmt.Sprintf("fk%d", i), Columns: []string{"id"}, ReferTable: "p", ReferColumns: []string{"id"}})
+	}
+	conv.SpSchema["t"] = ct
+	assert.Equal(t, 2, len(conv.limitWarnings("t")))
+
+	assert.Nil(t, conv.ApplyRemodel(Remodel{TrimToLimits: true}))
+	ct = conv.SpSchema["t"]
+	assert.Equal(t, MaxIndexesPerTable, len(ct.Indexes))
+	assert.Equal(t, "i0", ct.Indexes[0].Name)
+	assert.Equal(t, []string{"i1", "i2"}, []string{conv.DroppedIndexes["i1"].Index, conv.DroppedIndexes["i2"].Index})
+	assert.Equal(t, MaxForeignKeysPerTable, len(ct.Fks))
+	assert.Equal(t, "fk64", conv.TrimmedFks["t"][0].Name)
+	assert.Equal(t, []string{"Foreign keys fk64 were dropped to keep the table within Spanner's limit of 64 foreign keys"}, conv.limitWarnings("t"))
+}
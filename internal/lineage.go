@@ -0,0 +1,173 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package internal
+
+import (
+	"fmt"
+
+	"github.com/cloudspannerecosystem/harbourbridge/spanner/ddl"
+)
+
+// Lineage maps every Spanner table and column back to the source tables
+// and columns its data comes from, with the type conversion and the
+// transforms applied during data conversion. It is meant to be ingested
+// by data-governance tooling.
+type Lineage struct {
+	Driver string // Source database driver, e.g. "pg_dump".
+	Tables []TableLineage
+}
+
+// TableLineage describes where the rows of a Spanner table come from.
+type TableLineage struct {
+	SpTable   string
+	SrcTables []string // Several tables when tables are merged.
+	Note      string   `json:",omitempty"` // How the table was derived, for tables that aren't a plain copy of a source table.
+	Columns   []ColumnLineage
+}
+
+// ColumnLineage describes where the values of a Spanner column come from.
+type ColumnLineage struct {
+	SpColumn   string
+	SpType     string
+	Sources    []ColumnSource // Empty for generated columns (e.g. synthetic primary keys).
+	Transforms []string       `json:",omitempty"` // Changes to values, beyond type conversion.
+}
+
+// ColumnSource is a source column.
+type ColumnSource struct {
+	Table  string
+	Column string
+	Type   string
+}
+
+// BuildLineage returns the lineage of the tables of conv.SpSchema,
+// ordered according to conv.Ordering.
+func BuildLineage(driver string, conv *Conv) Lineage {
+	// Source tables of each Spanner table, and how it was derived.
+	srcTables := make(map[string][]string)
+	notes := make(map[string]string)
+	for _, srcTable := range conv.SrcTables() {
+		if nc, ok := conv.ToSpanner[srcTable]; ok {
+			srcTables[nc.Name] = append(srcTables[nc.Name], srcTable)
+		}
+		if m, ok := conv.MergedTables[srcTable]; ok {
+			notes[m.Into] = appendNote(notes[m.Into], fmt.Sprintf("Table %s merged into it", m.Table))
+		}
+	}
+	for t, splits := range conv.Splits {
+		for _, s := range splits {
+			srcTables[s.NewTable] = srcTables[t]
+			notes[s.NewTable] = fmt.Sprintf("Split from table %s", t)
+		}
+	}
+	for t, ot := range conv.OverflowTables {
+		srcTables[ot] = srcTables[t]
+		notes[ot] = fmt.Sprintf("Overflow table for oversize values in table %s", t)
+	}
+	l := Lineage{Driver: driver}
+	for _, t := range conv.SpTables() {
+		ct := conv.SpSchema[t]
+		tl := TableLineage{SpTable: t, SrcTables: srcTables[t], Note: notes[t]}
+		for _, c := range ct.ColNames {
+			cd := ct.ColDefs[c]
+			cl := ColumnLineage{SpColumn: c, SpType: cd.T.PrintColumnDefType()}
+			if cc, ok := conv.computedCol(t, c); ok {
+				cl.Transforms = append(cl.Transforms, "computed as "+cc.Expr)
+				if e, err := parseExpr(cc.Expr); err == nil {
+					for _, col := range e.cols() {
+						cl.Sources = append(cl.Sources, conv.lineageSources(srcTables[t], col)...)
+					}
+				}
+			} else {
+				cl.Sources = conv.lineageSources(srcTables[t], c)
+			}
+			if sk, ok := conv.SyntheticPKeys[t]; ok && sk.Col == c {
+				cl.Transforms = append(cl.Transforms, "synthetic primary key")
+			}
+			cl.Transforms = append(cl.Transforms, conv.policyTransforms(t, cd, tl.SrcTables)...)
+			tl.Columns = append(tl.Columns, cl)
+		}
+		l.Tables = append(l.Tables, tl)
+	}
+	return l
+}
+
+// lineageSources returns the columns of srcTables that are mapped to
+// Spanner column spCol.
+func (conv *Conv) lineageSources(srcTables []string, spCol string) []ColumnSource {
+	var l []ColumnSource
+	for _, srcTable := range srcTables {
+		st := conv.SrcSchema[srcTable]
+		for _, srcCol := range st.ColNames {
+			if conv.ToSpanner[srcTable].Cols[srcCol] == spCol && !conv.IsDroppedCol(srcTable, srcCol) {
+				l = append(l, ColumnSource{Table: srcTable, Column: srcCol, Type: st.ColDefs[srcCol].Type.Print()})
+			}
+		}
+	}
+	return l
+}
+
+// policyTransforms lists the changes that data conversion policies make
+// to values of column cd of Spanner table spTable. Policies that only
+// drop rows don't change values, and aren't listed.
+func (conv *Conv) policyTransforms(spTable string, cd ddl.ColumnDef, srcTables []string) []string {
+	var l []string
+	switch cd.T.Name {
+	case ddl.Date, ddl.Timestamp, ddl.Numeric:
+		switch conv.Policies.SpecialValues {
+		case ClampSpecialValues:
+			l = append(l, "special values clamped to the closest supported value")
+		case NullSpecialValues:
+			l = append(l, "special values replaced by NULL")
+		}
+	case ddl.String, ddl.Bytes:
+		if cd.T.Len == ddl.MaxLength && !cd.T.IsArray {
+			switch conv.Policies.Oversize {
+			case TruncateOversize:
+				l = append(l, "oversize values truncated")
+			case OverflowOversize:
+				if ot, ok := conv.OverflowTables[spTable]; ok {
+					l = append(l, fmt.Sprintf("oversize values moved to table %s", ot))
+				}
+			}
+		}
+	}
+	if cd.NotNull && conv.notNullPolicy(spTable, cd.Name) == DefaultNotNull {
+		l = append(l, "NULL values replaced by the default value of the type")
+	}
+	for _, srcTable := range srcTables {
+		for srcCol, spCol := range conv.ToSpanner[srcTable].Cols {
+			if spCol != cd.Name {
+				continue
+			}
+			if m, ok := conv.Policies.Masks[srcTable+"."+srcCol]; ok {
+				l = append(l, fmt.Sprintf("masked (%s)", m.Method))
+			}
+		}
+		for _, c := range conv.Stats.NotNullRelaxed[srcTable] {
+			if c == cd.Name {
+				l = append(l, "NOT NULL constraint removed")
+			}
+		}
+	}
+	return l
+}
+
+func appendNote(note, s string) string {
+	if note == "" {
+		return s
+	}
+	return note + "; " + s
+}
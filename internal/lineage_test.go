@@ -0,0 +1,66 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package internal
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBuildLineage_Merge(t *testing.T) {
+	conv := remodelTestConv()
+	assert.Nil(t, conv.ApplyRemodel(Remodel{Merges: []MergeTable{{Table: "settings", Into: "users"}}}))
+	assert.Nil(t, conv.AddComputedCols([]ComputedCol{{Table: "users", Column: "initials", Type: "STRING(10)", Expr: "substr(name, 1, 2)"}}))
+	conv.Policies.Oversize = TruncateOversize
+	l := BuildLineage("pg_dump", conv)
+	assert.Equal(t, "pg_dump", l.Driver)
+	assert.Equal(t, 1, len(l.Tables))
+	users := l.Tables[0]
+	assert.Equal(t, "users", users.SpTable)
+	assert.Equal(t, []string{"settings", "users"}, users.SrcTables)
+	assert.Equal(t, "Table settings merged into it", users.Note)
+	cols := make(map[string]ColumnLineage)
+	for _, c := range users.Columns {
+		cols[c.SpColumn] = c
+	}
+	assert.Equal(t, []ColumnSource{{Table: "settings", Column: "user_id"}, {Table: "users", Column: "id"}}, cols["id"].Sources)
+	assert.Equal(t, []ColumnSource{{Table: "settings", Column: "theme"}}, cols["theme"].Sources)
+	assert.Equal(t, []string{"oversize values truncated"}, cols["theme"].Transforms)
+	assert.Equal(t, ColumnLineage{
+		SpColumn:   "initials",
+		SpType:     "STRING(10)",
+		Sources:    []ColumnSource{{Table: "users", Column: "name"}},
+		Transforms: []string{"computed as substr(name, 1, 2)"},
+	}, cols["initials"])
+}
+
+func TestBuildLineage_Split(t *testing.T) {
+	conv := remodelTestConv()
+	assert.Nil(t, conv.ApplyRemodel(Remodel{Splits: []SplitTable{{Table: "users", NewTable: "user_media", Cols: []string{"bio", "photo"}}}}))
+	l := BuildLineage("mysql", conv)
+	assert.Equal(t, 3, len(l.Tables))
+	media := l.Tables[1]
+	assert.Equal(t, "user_media", media.SpTable)
+	assert.Equal(t, []string{"users"}, media.SrcTables)
+	assert.Equal(t, "Split from table users", media.Note)
+	assert.Equal(t, []ColumnLineage{
+		{SpColumn: "id", SpType: "INT64", Sources: []ColumnSource{{Table: "users", Column: "id"}}},
+		{SpColumn: "bio", SpType: "STRING(MAX)", Sources: []ColumnSource{{Table: "users", Column: "bio"}}},
+		{SpColumn: "photo", SpType: "BYTES(MAX)", Sources: []ColumnSource{{Table: "users", Column: "photo"}}},
+	}, media.Columns)
+	assert.Equal(t, "users", l.Tables[2].SpTable)
+	assert.Equal(t, 2, len(l.Tables[2].Columns))
+}
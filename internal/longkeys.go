@@ -0,0 +1,397 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package internal
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/cloudspannerecosystem/harbourbridge/spanner/ddl"
+)
+
+// MaxKeyBytes is Spanner's limit on the size of the primary key of a row
+// (and of the key of an index entry).
+const MaxKeyBytes = 8192
+
+// Strategies for primary key columns too long for Spanner's limit on key
+// size (see LongKey).
+const (
+	HashLongKey     = "hash"
+	TruncateLongKey = "truncate"
+	KeepLongKey     = "keep" // Keeps a column that SuggestLongKeys suggests.
+)
+
+// DefaultLongKeyLen is the number of characters kept by LongKey when Len
+// isn't given. At 4 bytes per character at most, it leaves room for the
+// other columns of the key.
+const DefaultLongKeyLen = 1024
+
+// LongKey makes a STRING primary key column fit Spanner's limit on key
+// size (MaxKeyBytes): values of STRING(MAX) columns, and of STRING(n)
+// columns whose n characters can take more than MaxKeyBytes bytes, may not
+// fit, and Spanner rejects their rows. Strategy is one of:
+//
+//	hash      the column is replaced in the primary key by a new
+//	          BYTES(32) column <Column>_hash holding the SHA-256 hash of
+//	          its values, and kept as a non-key column. A new
+//	          STRING(Len) column <Column>_prefix holds the first Len
+//	          characters of its values, with an index for lookups and
+//	          range scans by value. Secondary indexes on the column use
+//	          the hash instead.
+//	truncate  the column becomes STRING(Len), and its values are
+//	          truncated to Len characters during data conversion. Rows
+//	          whose truncated primary key is that of an earlier row with
+//	          different values are reported as bad rows.
+//
+// Hash columns are computed during data conversion, so both strategies
+// only apply to tables converted from a source table.
+type LongKey struct {
+	Table    string
+	Column   string
+	Strategy string
+	Len      int64 // Characters kept by prefixes or truncated values, DefaultLongKeyLen if 0.
+	// Type is the original type of Column. HashCol, PrefixCol and Index
+	// are the columns and index added by the hash strategy. They are set
+	// when the change is applied.
+	Type      ddl.Type
+	HashCol   string `json:",omitempty"`
+	PrefixCol string `json:",omitempty"`
+	Index     string `json:",omitempty"`
+}
+
+// truncatedKeys is the state of the truncate strategy during data
+// conversion.
+type truncatedKeys struct {
+	truncated  map[string]int64                 // Values truncated, by Spanner table.column.
+	collisions map[string]int64                 // Rows rejected, by Spanner table.
+	seen       map[string]map[[32]byte][32]byte // Hashes of the original key values, by hash of the truncated key, by Spanner table.
+}
+
+// ParseLongKeys parses a comma-separated list of entries of the form
+// 'table.column=strategy', where strategy is hash, truncate or keep,
+// optionally followed by ':len' (see LongKey.Len), optionally starting
+// with "auto" to also apply the hash strategy to the columns suggested by
+// SuggestLongKeys. For example, "auto,urls.url=truncate:500". See
+// Remodel.LongKeys.
+func ParseLongKeys(s string) (bool, []LongKey, error) {
+	auto := false
+	var keys []LongKey
+	for _, e := range strings.Split(s, ",") {
+		e = strings.TrimSpace(e)
+		switch {
+		case e == "":
+			continue
+		case strings.ToLower(e) == "auto":
+			auto = true
+			continue
+		}
+		i := strings.LastIndex(e, "=")
+		j := strings.Index(e, ".")
+		if i < 0 || j < 0 || j > i {
+			return false, nil, fmt.Errorf("bad long key %q: expected table.column=hash, table.column=truncate or table.column=keep", e)
+		}
+		k := LongKey{Table: e[:j], Column: e[j+1 : i], Strategy: strings.ToLower(e[i+1:])}
+		if x := strings.Index(k.Strategy, ":"); x >= 0 {
+			n, err := strconv.ParseInt(k.Strategy[x+1:], 10, 64)
+			if err != nil || n <= 0 {
+				return false, nil, fmt.Errorf("bad long key %q: bad length %q", e, k.Strategy[x+1:])
+			}
+			k.Strategy, k.Len = k.Strategy[:x], n
+		}
+		switch k.Strategy {
+		case HashLongKey, TruncateLongKey, KeepLongKey:
+		default:
+			return false, nil, fmt.Errorf("bad long key %q: accepted strategies are %q, %q and %q", e, HashLongKey, TruncateLongKey, KeepLongKey)
+		}
+		keys = append(keys, k)
+	}
+	return auto, keys, nil
+}
+
+// longKeyCols returns the STRING primary key columns of spTable whose
+// values can exceed Spanner's limit on key size, and that haven't been
+// changed with LongKey.
+func (conv *Conv) longKeyCols(spTable string) []string {
+	var l []string
+	ct := conv.SpSchema[spTable]
+	for _, k := range ct.Pks {
+		t := ct.ColDefs[k.Col].T
+		if t.Name != ddl.String || t.IsArray || (t.Len != ddl.MaxLength && 4*t.Len <= MaxKeyBytes) {
+			continue
+		}
+		if _, ok := conv.LongKeys[spTable+"."+k.Col]; !ok {
+			l = append(l, k.Col)
+		}
+	}
+	return l
+}
+
+// SuggestLongKeys returns the primary key columns (as table.column,
+// sorted) whose values can exceed Spanner's limit on key size, and whose
+// key can be changed (see LongKey).
+func (conv *Conv) SuggestLongKeys() []string {
+	var l []string
+	for t := range conv.SpSchema {
+		for _, c := range conv.longKeyCols(t) {
+			if conv.canChangeLongKey(t, c) == nil {
+				l = append(l, t+"."+c)
+			}
+		}
+	}
+	sort.Strings(l)
+	return l
+}
+
+// canChangeLongKey returns an error if LongKey can't be applied to spCol
+// of spTable.
+func (conv *Conv) canChangeLongKey(spTable, spCol string) error {
+	if _, ok := conv.ToSource[spTable]; !ok {
+		return fmt.Errorf("only tables converted from a source table can be changed")
+	}
+	if err := conv.canChangeKey(spTable); err != nil {
+		return err
+	}
+	for t, ct := range conv.SpSchema {
+		for _, fk := range ct.Fks {
+			if (t == spTable && containsString(fk.Columns, spCol)) || (fk.ReferTable == spTable && containsString(fk.ReferColumns, spCol)) {
+				return fmt.Errorf("it is used by foreign key %s", fk.Name)
+			}
+		}
+	}
+	return nil
+}
+
+// applyLongKeys applies keys and, if auto is set, the hash strategy to the
+// columns suggested by SuggestLongKeys that keys doesn't keep, and records
+// them in conv.LongKeys. Changes that have already been applied (e.g.
+// when conv was read from a session file) are skipped.
+func (conv *Conv) applyLongKeys(auto bool, keys []LongKey) error {
+	all := make(map[string]LongKey)
+	if auto {
+		for _, c := range conv.SuggestLongKeys() {
+			i := strings.Index(c, ".")
+			all[c] = LongKey{Table: c[:i], Column: c[i+1:], Strategy: HashLongKey}
+		}
+	}
+	for _, k := range keys {
+		all[k.Table+"."+k.Column] = k
+	}
+	var cols []string
+	for c := range all {
+		cols = append(cols, c)
+	}
+	sort.Strings(cols)
+	for _, c := range cols {
+		if err := conv.applyLongKey(all[c]); err != nil {
+			return fmt.Errorf("can't change long key column %s: %w", c, err)
+		}
+	}
+	return nil
+}
+
+func (conv *Conv) applyLongKey(k LongKey) error {
+	if k.Len == 0 {
+		k.Len = DefaultLongKeyLen
+	}
+	if x, ok := conv.LongKeys[k.Table+"."+k.Column]; ok {
+		if x.Strategy != k.Strategy || x.Len != k.Len {
+			return fmt.Errorf("it was already changed with strategy %s:%d", x.Strategy, x.Len)
+		}
+		return nil // Already applied.
+	}
+	if k.Strategy == KeepLongKey {
+		return nil
+	}
+	ct, ok := conv.SpSchema[k.Table]
+	if !ok {
+		return fmt.Errorf("unknown table")
+	}
+	cd, ok := ct.ColDefs[k.Column]
+	if !ok {
+		return fmt.Errorf("unknown column")
+	}
+	switch {
+	case cd.T.Name != ddl.String || cd.T.IsArray:
+		return fmt.Errorf("it isn't a STRING column")
+	case !isKeyCol(ct, k.Column):
+		return fmt.Errorf("it isn't a primary key column")
+	case 4*k.Len > MaxKeyBytes:
+		return fmt.Errorf("%d characters can exceed Spanner's limit of %d bytes on key size", k.Len, MaxKeyBytes)
+	case cd.T.Len != ddl.MaxLength && cd.T.Len <= k.Len:
+		return fmt.Errorf("its values have at most %d characters", cd.T.Len)
+	}
+	if err := conv.canChangeLongKey(k.Table, k.Column); err != nil {
+		return err
+	}
+	k.Type = cd.T
+	switch k.Strategy {
+	case HashLongKey:
+		k.HashCol = unusedColName(ct, k.Column+"_hash")
+		ct.ColDefs[k.HashCol] = ddl.ColumnDef{Name: k.HashCol, T: ddl.Type{Name: ddl.Bytes, Len: sha256.Size}, NotNull: true, Comment: fmt.Sprintf("SHA-256 hash of column %s", k.Column)}
+		k.PrefixCol = unusedColName(ct, k.Column+"_prefix")
+		ct.ColDefs[k.PrefixCol] = ddl.ColumnDef{Name: k.PrefixCol, T: ddl.Type{Name: ddl.String, Len: k.Len}, NotNull: cd.NotNull, Comment: fmt.Sprintf("First %d characters of column %s", k.Len, k.Column)}
+		var colNames []string
+		for _, c := range ct.ColNames {
+			colNames = append(colNames, c)
+			if c == k.Column {
+				colNames = append(colNames, k.HashCol, k.PrefixCol)
+			}
+		}
+		ct.ColNames = colNames
+		ct.Pks = replaceKeyCol(ct.Pks, k.Column, k.HashCol)
+		for i := range ct.Indexes {
+			ct.Indexes[i].Keys = replaceKeyCol(ct.Indexes[i].Keys, k.Column, k.HashCol)
+		}
+		k.Index = conv.unusedName(k.Table + "_" + k.PrefixCol)
+		ct.Indexes = append(ct.Indexes, ddl.CreateIndex{Name: k.Index, Table: k.Table, Keys: []ddl.IndexKey{{Col: k.PrefixCol}}})
+	case TruncateLongKey:
+		cd.T = ddl.Type{Name: ddl.String, Len: k.Len}
+		ct.ColDefs[k.Column] = cd
+	}
+	conv.SpSchema[k.Table] = ct
+	if conv.LongKeys == nil {
+		conv.LongKeys = make(map[string]LongKey)
+	}
+	conv.LongKeys[k.Table+"."+k.Column] = k
+	return nil
+}
+
+// unusedColName returns base, or base followed by a number, whichever is
+// first not the name of a column of ct.
+func unusedColName(ct ddl.CreateTable, base string) string {
+	used := make(map[string]bool)
+	for c := range ct.ColDefs {
+		used[strings.ToLower(c)] = true
+	}
+	name := base
+	for i := 1; used[strings.ToLower(name)]; i++ {
+		name = fmt.Sprintf("%s%d", base, i)
+	}
+	return name
+}
+
+// replaceKeyCol returns keys, with column col replaced by column by.
+func replaceKeyCol(keys []ddl.IndexKey, col, by string) []ddl.IndexKey {
+	var l []ddl.IndexKey
+	for _, k := range keys {
+		if k.Col == col {
+			k = ddl.IndexKey{Col: by}
+		}
+		l = append(l, k)
+	}
+	return l
+}
+
+// applyLongKeyStrategies applies the strategies of the long key columns
+// of spTable to a row: it adds the hash and prefix columns of hashed
+// columns, and truncates the values of truncated columns. It returns an
+// error if the truncated primary key is that of an earlier row with
+// different values.
+func (conv *Conv) applyLongKeyStrategies(spTable string, spCols []string, spVals []interface{}) ([]string, []interface{}, error) {
+	cols := spCols
+	vals := spVals
+	copied := false
+	var orig []string // Original values of truncated columns.
+	for i, c := range spCols {
+		k, ok := conv.LongKeys[spTable+"."+c]
+		if !ok || spVals[i] == nil {
+			continue
+		}
+		s, ok := spVals[i].(string)
+		if !ok {
+			return nil, nil, fmt.Errorf("can't change value of type %T of column %s", spVals[i], c)
+		}
+		if !copied {
+			cols = append([]string{}, spCols...)
+			vals = append([]interface{}{}, spVals...)
+			copied = true
+		}
+		switch k.Strategy {
+		case HashLongKey:
+			h := sha256.Sum256([]byte(s))
+			prefix, _ := truncateChars(s, k.Len)
+			cols = append(cols, k.HashCol, k.PrefixCol)
+			vals = append(vals, h[:], prefix)
+		case TruncateLongKey:
+			orig = append(orig, s)
+			if t, ok := truncateChars(s, k.Len); ok {
+				vals[i] = t
+				conv.rowsMu.Lock()
+				if conv.truncKeys == nil {
+					conv.truncKeys = &truncatedKeys{truncated: make(map[string]int64), collisions: make(map[string]int64), seen: make(map[string]map[[32]byte][32]byte)}
+				}
+				conv.truncKeys.truncated[spTable+"."+c]++
+				conv.rowsMu.Unlock()
+			}
+		}
+	}
+	if len(orig) == 0 {
+		return cols, vals, nil
+	}
+	pk, ok := conv.primaryKeyVals(spTable, cols, vals)
+	if !ok {
+		return cols, vals, nil
+	}
+	key := sha256.Sum256([]byte(fmt.Sprintf("%#v", pk)))
+	h := sha256.Sum256([]byte(fmt.Sprintf("%q", orig)))
+	conv.rowsMu.Lock()
+	defer conv.rowsMu.Unlock()
+	if conv.truncKeys == nil {
+		// Keys of tables without truncated values can't collide.
+		return cols, vals, nil
+	}
+	seen := conv.truncKeys.seen[spTable]
+	if seen == nil {
+		seen = make(map[[32]byte][32]byte)
+		conv.truncKeys.seen[spTable] = seen
+	}
+	if x, ok := seen[key]; ok && x != h {
+		conv.truncKeys.collisions[spTable]++
+		// Don't include the values: this message is used as a key for
+		// counting unexpected conditions.
+		return nil, nil, fmt.Errorf("truncated primary key is the same as that of an earlier row with different values")
+	}
+	seen[key] = h
+	return cols, vals, nil
+}
+
+// truncateChars returns the first n characters of s, and true if s is
+// longer.
+func truncateChars(s string, n int64) (string, bool) {
+	if int64(len(s)) <= n {
+		return s, false // A string has at most as many characters as bytes.
+	}
+	i := 0
+	for c := int64(0); c < n && i < len(s); c++ {
+		_, size := utf8.DecodeRuneInString(s[i:])
+		i += size
+	}
+	return s[:i], i < len(s)
+}
+
+// LongKeyStats returns the number of values of truncated long key column
+// spCol of spTable truncated during data conversion, and the number of
+// rows of spTable rejected because their truncated primary key collided
+// with that of another row.
+func (conv *Conv) LongKeyStats(spTable, spCol string) (int64, int64) {
+	if conv.truncKeys == nil {
+		return 0, 0
+	}
+	return conv.truncKeys.truncated[spTable+"."+spCol], conv.truncKeys.collisions[spTable]
+}
@@ -0,0 +1,132 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package internal
+
+import (
+	"crypto/sha256"
+	"strings"
+	"testing"
+
+	"github.com/cloudspannerecosystem/harbourbridge/spanner/ddl"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseLongKeys(t *testing.T) {
+	auto, keys, err := ParseLongKeys("auto, urls.url=hash, pages.path=truncate:500,docs.id=keep")
+	assert.Nil(t, err)
+	assert.True(t, auto)
+	assert.Equal(t, []LongKey{
+		{Table: "urls", Column: "url", Strategy: HashLongKey},
+		{Table: "pages", Column: "path", Strategy: TruncateLongKey, Len: 500},
+		{Table: "docs", Column: "id", Strategy: KeepLongKey},
+	}, keys)
+	for _, s := range []string{"urls.url", "url=hash", "urls.url=md5", "urls.url=truncate:0", "urls.url=truncate:x"} {
+		_, _, err := ParseLongKeys(s)
+		assert.NotNil(t, err, s)
+	}
+}
+
+func longKeysConv() *Conv {
+	conv := MakeConv()
+	conv.SpSchema["urls"] = ddl.CreateTable{
+		Name:     "urls",
+		ColNames: []string{"url", "hits"},
+		ColDefs: map[string]ddl.ColumnDef{
+			"url":  {Name: "url", T: ddl.Type{Name: ddl.String, Len: ddl.MaxLength}, NotNull: true},
+			"hits": {Name: "hits", T: ddl.Type{Name: ddl.Int64}},
+		},
+		Pks:     []ddl.IndexKey{{Col: "url"}},
+		Indexes: []ddl.CreateIndex{{Name: "urls_hits_idx", Table: "urls", Keys: []ddl.IndexKey{{Col: "hits"}, {Col: "url"}}}},
+	}
+	conv.ToSource["urls"] = NameAndCols{Name: "urls", Cols: map[string]string{"url": "url", "hits": "hits"}}
+	conv.ToSpanner["urls"] = NameAndCols{Name: "urls", Cols: map[string]string{"url": "url", "hits": "hits"}}
+	return conv
+}
+
+func TestLongKeysHash(t *testing.T) {
+	conv := longKeysConv()
+	assert.Equal(t, []string{"urls.url"}, conv.SuggestLongKeys())
+	assert.Equal(t, 1, len(conv.limitWarnings("urls")))
+	r := Remodel{AutoLongKeys: true, LongKeys: []LongKey{{Table: "urls", Column: "url", Strategy: HashLongKey, Len: 100}}}
+	assert.Nil(t, conv.ApplyRemodel(r))
+	ct := conv.SpSchema["urls"]
+	assert.Equal(t, []string{"url", "url_hash", "url_prefix", "hits"}, ct.ColNames)
+	assert.Equal(t, ddl.Type{Name: ddl.Bytes, Len: 32}, ct.ColDefs["url_hash"].T)
+	assert.Equal(t, ddl.Type{Name: ddl.String, Len: 100}, ct.ColDefs["url_prefix"].T)
+	assert.Equal(t, []ddl.IndexKey{{Col: "url_hash"}}, ct.Pks)
+	assert.Equal(t, []ddl.CreateIndex{
+		{Name: "urls_hits_idx", Table: "urls", Keys: []ddl.IndexKey{{Col: "hits"}, {Col: "url_hash"}}},
+		{Name: "urls_url_prefix", Table: "urls", Keys: []ddl.IndexKey{{Col: "url_prefix"}}},
+	}, ct.Indexes)
+	assert.Empty(t, conv.SuggestLongKeys())
+	assert.Empty(t, conv.limitWarnings("urls"))
+	// Already applied.
+	assert.Nil(t, conv.ApplyRemodel(r))
+	assert.NotNil(t, conv.ApplyRemodel(Remodel{LongKeys: []LongKey{{Table: "urls", Column: "url", Strategy: TruncateLongKey}}}))
+
+	var rows [][]interface{}
+	conv.SetDataMode()
+	conv.SetDataSink(func(table string, cols []string, vals []interface{}) {
+		rows = append(rows, vals)
+	})
+	url := strings.Repeat("é", 150)
+	conv.WriteRow("urls", "urls", []string{"url", "hits"}, []interface{}{url, int64(3)})
+	h := sha256.Sum256([]byte(url))
+	assert.Equal(t, [][]interface{}{{url, int64(3), h[:], strings.Repeat("é", 100)}}, rows)
+}
+
+func TestLongKeysTruncate(t *testing.T) {
+	conv := longKeysConv()
+	assert.Nil(t, conv.ApplyRemodel(Remodel{LongKeys: []LongKey{{Table: "urls", Column: "url", Strategy: TruncateLongKey, Len: 10}}}))
+	assert.Equal(t, ddl.Type{Name: ddl.String, Len: 10}, conv.SpSchema["urls"].ColDefs["url"].T)
+	ds, _ := conv.DataSchema("urls")
+	assert.Equal(t, ddl.Type{Name: ddl.String, Len: ddl.MaxLength}, ds.ColDefs["url"].T)
+
+	var rows [][]interface{}
+	conv.SetDataMode()
+	conv.SetDataSink(func(table string, cols []string, vals []interface{}) {
+		rows = append(rows, vals)
+	})
+	conv.WriteRow("urls", "urls", []string{"url", "hits"}, []interface{}{"http://a.com/x", int64(1)})
+	conv.WriteRow("urls", "urls", []string{"url", "hits"}, []interface{}{"http://b", int64(2)})
+	conv.WriteRow("urls", "urls", []string{"url", "hits"}, []interface{}{"http://a.com/y", int64(3)})
+	assert.Equal(t, [][]interface{}{{"http://a.c", int64(1)}, {"http://b", int64(2)}}, rows)
+	truncated, collisions := conv.LongKeyStats("urls", "url")
+	assert.Equal(t, int64(2), truncated)
+	assert.Equal(t, int64(1), collisions)
+	assert.Equal(t, int64(1), conv.BadRows())
+}
+
+func TestLongKeysErrors(t *testing.T) {
+	for _, k := range []LongKey{
+		{Table: "missing", Column: "url", Strategy: HashLongKey},
+		{Table: "urls", Column: "missing", Strategy: HashLongKey},
+		{Table: "urls", Column: "hits", Strategy: HashLongKey},
+		{Table: "urls", Column: "url", Strategy: TruncateLongKey, Len: 4096},
+	} {
+		conv := longKeysConv()
+		assert.NotNil(t, conv.ApplyRemodel(Remodel{LongKeys: []LongKey{k}}), k)
+	}
+	conv := longKeysConv()
+	conv.SpSchema["visits"] = ddl.CreateTable{
+		Name:     "visits",
+		ColNames: []string{"url"},
+		ColDefs:  map[string]ddl.ColumnDef{"url": {Name: "url", T: ddl.Type{Name: ddl.String, Len: ddl.MaxLength}}},
+		Fks:      []ddl.Foreignkey{{Name: "visits_url_fk", Columns: []string{"url"}, ReferTable: "urls", ReferColumns: []string{"url"}}},
+	}
+	assert.Empty(t, conv.SuggestLongKeys())
+	assert.NotNil(t, conv.ApplyRemodel(Remodel{LongKeys: []LongKey{{Table: "urls", Column: "url", Strategy: HashLongKey}}}))
+	assert.Contains(t, conv.limitWarnings("urls")[0], "it is used by foreign key visits_url_fk")
+}
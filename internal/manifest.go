@@ -0,0 +1,147 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package internal
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/cloudspannerecosystem/harbourbridge/schema"
+	"github.com/cloudspannerecosystem/harbourbridge/spanner/ddl"
+)
+
+// ManifestRows are the row counts of a Spanner table and their
+// verification, for its manifest (see WriteManifest).
+type ManifestRows struct {
+	Read      int64 // Rows read from the source tables.
+	Converted int64 // Rows converted.
+	Bad       int64 // Rows that couldn't be converted or written.
+	Spanner   int64 // Rows in Spanner, -1 if they couldn't be counted.
+	// Verification is the outcome of the verification of the row count,
+	// e.g. "verified: 12 rows, as expected".
+	Verification string
+}
+
+// WriteManifest writes the manifest of the Spanner table of t to w, in
+// Markdown: a page giving the evidence of the migration of the table for
+// change-management sign-off. It gives the definition of the source
+// tables and the DDL of the Spanner table, the row counts and their
+// verification (rows is nil if the data wasn't converted), the issues
+// found by conversion with a box to tick to acknowledge each of them, and
+// sign-off fields.
+func WriteManifest(w io.Writer, driver string, conv *Conv, t TableLineage, rows *ManifestRows, now time.Time) error {
+	b := bufio.NewWriter(w)
+	fmt.Fprintf(b, "# Migration manifest: table %s\n\n", t.SpTable)
+	fmt.Fprintf(b, "| | |\n|---|---|\n")
+	fmt.Fprintf(b, "| Spanner table | `%s` |\n", t.SpTable)
+	fmt.Fprintf(b, "| Source tables | %s (%s) |\n", codeList(t.SrcTables), driver)
+	if t.Note != "" {
+		fmt.Fprintf(b, "| Derivation | %s |\n", t.Note)
+	}
+	fmt.Fprintf(b, "| Generated | %s |\n\n", now.Format("2006-01-02 15:04:05 MST"))
+
+	fmt.Fprintf(b, "## Source definition\n\n```sql\n")
+	for _, srcTable := range t.SrcTables {
+		fmt.Fprintf(b, "%s\n", sourceDefinition(conv.SrcSchema[srcTable]))
+	}
+	fmt.Fprintf(b, "```\n\n")
+
+	fmt.Fprintf(b, "## Target DDL\n\n```sql\n")
+	ct := conv.SpSchema[t.SpTable]
+	c := ddl.Config{Tables: true, ForeignKeys: true, PostgreSQL: conv.TargetDb == "experimental_postgres"} // conversion.TARGET_EXPERIMENTAL_POSTGRES, which would be an import cycle.
+	fmt.Fprintf(b, "%s;\n", ct.PrintCreateTable(c))
+	for _, i := range ct.Indexes {
+		fmt.Fprintf(b, "%s;\n", i.PrintCreateIndex(c))
+	}
+	for _, fk := range ct.Fks {
+		fmt.Fprintf(b, "%s;\n", fk.PrintForeignKeyAlterTable(c, ct.Name))
+	}
+	fmt.Fprintf(b, "```\n\n")
+
+	fmt.Fprintf(b, "## Row counts\n\n")
+	if rows == nil {
+		fmt.Fprintf(b, "Data not converted.\n\n")
+	} else {
+		spanner := "unknown"
+		if rows.Spanner >= 0 {
+			spanner = fmt.Sprint(rows.Spanner)
+		}
+		fmt.Fprintf(b, "| Rows read | Rows converted | Bad rows | Rows in Spanner |\n|---|---|---|---|\n")
+		fmt.Fprintf(b, "| %d | %d | %d | %s |\n\n", rows.Read, rows.Converted, rows.Bad, spanner)
+		fmt.Fprintf(b, "Verification: %s\n\n", rows.Verification)
+	}
+
+	fmt.Fprintf(b, "## Issues\n\n")
+	var issues int
+	for _, srcTable := range t.SrcTables {
+		for _, body := range buildTableReport(conv, srcTable, nil).Body {
+			for _, l := range body.Lines {
+				fmt.Fprintf(b, "- [ ] %s (%s): %s\n", body.Heading, srcTable, l)
+				issues++
+			}
+		}
+	}
+	if issues == 0 {
+		fmt.Fprintf(b, "None.\n")
+	}
+	fmt.Fprintf(b, "\n## Sign-off\n\n")
+	fmt.Fprintf(b, "| Role | Name | Signature | Date |\n|---|---|---|---|\n")
+	fmt.Fprintf(b, "| Table owner | | | |\n| Migration lead | | | |\n")
+	return b.Flush()
+}
+
+// sourceDefinition returns the definition of source table t, in the SQL
+// syntax of PostgreSQL and MySQL.
+func sourceDefinition(t schema.Table) string {
+	var l []string
+	for _, name := range t.ColNames {
+		c := t.ColDefs[name]
+		s := fmt.Sprintf("  %s %s", name, c.Type.Print())
+		if c.NotNull {
+			s += " NOT NULL"
+		}
+		l = append(l, s)
+	}
+	if len(t.PrimaryKeys) > 0 {
+		l = append(l, "  PRIMARY KEY "+keysString(t.PrimaryKeys))
+	}
+	for _, fk := range t.ForeignKeys {
+		s := "  "
+		if fk.Name != "" {
+			s += "CONSTRAINT " + fk.Name + " "
+		}
+		l = append(l, s+fmt.Sprintf("FOREIGN KEY (%s) REFERENCES %s (%s)", strings.Join(fk.Columns, ", "), fk.ReferTable, strings.Join(fk.ReferColumns, ", ")))
+	}
+	s := fmt.Sprintf("CREATE TABLE %s (\n%s\n);", t.Name, strings.Join(l, ",\n"))
+	for _, i := range t.Indexes {
+		unique := ""
+		if i.Unique {
+			unique = "UNIQUE "
+		}
+		s += fmt.Sprintf("\nCREATE %sINDEX %s ON %s %s;", unique, i.Name, t.Name, keysString(i.Keys))
+	}
+	return s
+}
+
+func codeList(l []string) string {
+	var q []string
+	for _, s := range l {
+		q = append(q, "`"+s+"`")
+	}
+	return strings.Join(q, ", ")
+}
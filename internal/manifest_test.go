@@ -0,0 +1,55 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package internal
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/cloudspannerecosystem/harbourbridge/schema"
+	"github.com/cloudspannerecosystem/harbourbridge/spanner/ddl"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWriteManifest(t *testing.T) {
+	conv := changesTestConv([]changesTestCol{
+		{"id", schema.Type{Name: "int4"}, ddl.Type{Name: ddl.Int64}, true},
+		{"name", schema.Type{Name: "varchar", Mods: []int64{10}}, ddl.Type{Name: ddl.String, Len: 10}, false},
+	})
+	src := conv.SrcSchema["t"]
+	src.Indexes = []schema.Index{{Name: "t_name", Unique: true, Keys: []schema.Key{{Column: "name"}}}}
+	conv.SrcSchema["t"] = src
+	conv.Issues["t"] = map[string][]SchemaIssue{"id": []SchemaIssue{Widened}}
+	now := time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)
+	tl := BuildLineage("postgres", conv).Tables[0]
+
+	var b bytes.Buffer
+	assert.Nil(t, WriteManifest(&b, "postgres", conv, tl, nil, now))
+	s := b.String()
+	assert.Contains(t, s, "# Migration manifest: table t\n")
+	assert.Contains(t, s, "| Source tables | `t` (postgres) |\n| Generated | 2020-01-02 03:04:05 UTC |\n")
+	assert.Contains(t, s, "```sql\nCREATE TABLE t (\n  id int4 NOT NULL,\n  name varchar(10),\n  PRIMARY KEY (id)\n);\nCREATE UNIQUE INDEX t_name ON t (name);\n```")
+	assert.Contains(t, s, "## Target DDL\n\n```sql\nCREATE TABLE t (")
+	assert.Contains(t, s, "## Row counts\n\nData not converted.\n")
+	assert.Contains(t, s, "- [ ] Note (t): Some columns will consume more storage in Spanner e.g. for column 'id', source DB type int4 is mapped to Spanner type int64\n")
+	assert.Contains(t, s, "| Table owner | | | |\n")
+
+	b.Reset()
+	conv.SetDataMode()
+	rows := &ManifestRows{Read: 12, Converted: 11, Bad: 1, Spanner: 11, Verification: "verified: 11 rows, as expected"}
+	assert.Nil(t, WriteManifest(&b, "postgres", conv, tl, rows, now))
+	assert.Contains(t, b.String(), "| 12 | 11 | 1 | 11 |\n\nVerification: verified: 11 rows, as expected\n")
+}
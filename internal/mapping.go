@@ -31,28 +31,30 @@ import (
 // b) the new table name doesn't clash with other Spanner table names
 // c) we consistently return the same name for this table.
 func GetSpannerTable(conv *Conv, srcTable string) (string, error) {
+	conv.schemaMu.Lock()
+	defer conv.schemaMu.Unlock()
+	return getSpannerTable(conv, srcTable)
+}
+
+func getSpannerTable(conv *Conv, srcTable string) (string, error) {
 	if srcTable == "" {
 		return "", fmt.Errorf("bad parameter: table string is empty")
 	}
 	if sp, found := conv.ToSpanner[srcTable]; found {
 		return sp.Name, nil
 	}
-	spTable, _ := FixName(srcTable)
-	if _, found := conv.ToSource[spTable]; found {
-		// s has been used before i.e. FixName caused a collision.
-		// Add unique postfix: use number of tables so far.
-		// However, there is a chance this has already been used,
-		// so need to iterate.
-		id := len(conv.ToSpanner)
-		for {
-			t := spTable + "_" + strconv.Itoa(id)
-			if _, found := conv.ToSource[t]; !found {
-				spTable = t
-				break
+	spTable, _ := conv.spannerName(srcTable)
+	// Spanner table names are case-insensitive, so a FixName collision
+	// includes names that only differ by case. If there is a collision,
+	// add unique postfix: use number of tables so far.
+	spTable = suffixName(spTable, len(conv.ToSpanner), func(n string) bool {
+		for t := range conv.ToSource {
+			if strings.EqualFold(t, n) {
+				return true
 			}
-			id++
 		}
-	}
+		return false
+	})
 	if spTable != srcTable {
 		VerbosePrintf("Mapping source DB table %s to Spanner table %s\n", srcTable, spTable)
 	}
@@ -71,6 +73,12 @@ func GetSpannerTable(conv *Conv, srcTable string) (string, error) {
 // b) the new col name doesn't clash with other col names in the same table
 // c) we consistently return the same name for the same col.
 func GetSpannerCol(conv *Conv, srcTable, srcCol string, mustExist bool) (string, error) {
+	conv.schemaMu.Lock()
+	defer conv.schemaMu.Unlock()
+	return getSpannerCol(conv, srcTable, srcCol, mustExist)
+}
+
+func getSpannerCol(conv *Conv, srcTable, srcCol string, mustExist bool) (string, error) {
 	if srcTable == "" {
 		return "", fmt.Errorf("bad parameter: table string is empty")
 	}
@@ -83,8 +91,10 @@ func GetSpannerCol(conv *Conv, srcTable, srcCol string, mustExist bool) (string,
 	}
 	// Sanity check: do reverse mapping and check consistency.
 	// Consider dropping this check.
+	// Tables merged into another table (see MergeTable) map to a Spanner
+	// table whose reverse mapping is to a different source table.
 	src, found := conv.ToSource[sp.Name]
-	if !found || src.Name != srcTable {
+	if _, merged := conv.MergedTables[srcTable]; !found || (src.Name != srcTable && !merged) {
 		return "", fmt.Errorf("internal error: table mapping inconsistency for table %s (%s)", srcTable, src.Name)
 	}
 	if spCol, found := sp.Cols[srcCol]; found {
@@ -93,22 +103,18 @@ func GetSpannerCol(conv *Conv, srcTable, srcCol string, mustExist bool) (string,
 	if mustExist {
 		return "", fmt.Errorf("table %s does not have a column %s", srcTable, srcCol)
 	}
-	spCol, _ := FixName(srcCol)
-	if _, found := conv.ToSource[sp.Name].Cols[spCol]; found {
-		// spCol has been used before i.e. FixName caused a collision.
-		// Add unique postfix: use number of cols in this table so far.
-		// However, there is a chance this has already been used,
-		// so need to iterate.
-		id := len(sp.Cols)
-		for {
-			c := spCol + "_" + strconv.Itoa(id)
-			if _, found := conv.ToSource[sp.Name].Cols[c]; !found {
-				spCol = c
-				break
+	spCol, _ := conv.spannerName(srcCol)
+	// As for tables, column names clash if they only differ by case. If
+	// there is a collision, add unique postfix: use number of cols in this
+	// table so far.
+	spCol = suffixName(spCol, len(sp.Cols), func(n string) bool {
+		for c := range conv.ToSource[sp.Name].Cols {
+			if strings.EqualFold(c, n) {
+				return true
 			}
-			id++
 		}
-	}
+		return false
+	})
 	if spCol != srcCol {
 		VerbosePrintf("Mapping source DB col %s (table %s) to Spanner col %s\n", srcCol, srcTable, spCol)
 	}
@@ -118,11 +124,18 @@ func GetSpannerCol(conv *Conv, srcTable, srcCol string, mustExist bool) (string,
 }
 
 // GetSpannerCols maps a slice of source columns into their corresponding
-// Spanner columns using GetSpannerCol.
+// Spanner columns using GetSpannerCol. Columns that are not migrated (see
+// DropColumns) are mapped to the empty string: callers must skip them.
 func GetSpannerCols(conv *Conv, srcTable string, srcCols []string) ([]string, error) {
+	conv.schemaMu.Lock()
+	defer conv.schemaMu.Unlock()
 	var spCols []string
 	for _, srcCol := range srcCols {
-		spCol, err := GetSpannerCol(conv, srcTable, srcCol, false)
+		if conv.IsDroppedCol(srcTable, srcCol) {
+			spCols = append(spCols, "")
+			continue
+		}
+		spCol, err := getSpannerCol(conv, srcTable, srcCol, false)
 		if err != nil {
 			return nil, err
 		}
@@ -166,31 +179,58 @@ func ToSpannerIndexName(srcId string, used map[string]bool) string {
 
 func getSpannerId(srcId string, used map[string]bool) string {
 	spKeyName, _ := FixName(srcId)
-	if _, found := used[spKeyName]; found {
-		// spKeyName has been used before.
-		// Add unique postfix: use number of keys so far.
-		// However, there is a chance this has already been used,
-		// so need to iterate.
-		id := len(used)
-		for {
-			c := spKeyName + "_" + strconv.Itoa(id)
-			if _, found := used[c]; !found {
-				spKeyName = c
-				break
+	// If spKeyName has been used before (ignoring case), add unique
+	// postfix: use number of keys so far.
+	spKeyName = suffixName(spKeyName, len(used), func(n string) bool {
+		if used[n] {
+			return true
+		}
+		for k := range used {
+			if strings.EqualFold(k, n) {
+				return true
 			}
-			id++
 		}
-	}
+		return false
+	})
 	used[spKeyName] = true
 	return spKeyName
 }
 
+// suffixName returns name if it isn't used. Otherwise it adds the postfix
+// _id to name, incrementing id until the result isn't used. Note that there
+// is a chance a postfixed name has already been used, so we need to iterate.
+// Names are truncated so that the result fits in MaxNameLength.
+func suffixName(name string, id int, used func(string) bool) string {
+	if !used(name) {
+		return name
+	}
+	for ; ; id++ {
+		suffix := "_" + strconv.Itoa(id)
+		n := name
+		if len(n)+len(suffix) > MaxNameLength {
+			n = n[:MaxNameLength-len(suffix)]
+		}
+		if !used(n + suffix) {
+			return n + suffix
+		}
+	}
+}
+
 // ResolveRefs resolves all table and column references in foreign key constraints
 // in the Spanner Schema. Note: Spanner requires that DDL references match
 // the case of the referenced object, but this is not so for many source databases.
 //
 // TODO: Expand ResolveRefs to primary keys and indexes.
 func ResolveRefs(conv *Conv) {
+	if conv.spill != nil {
+		// Referenced tables may not be converted yet: foreign keys of
+		// spilled tables are resolved by ResolveSpilledRefs.
+		return
+	}
+	resolveAllRefs(conv)
+}
+
+func resolveAllRefs(conv *Conv) {
 	for table, spTable := range conv.SpSchema {
 		spTable.Fks = resolveFks(conv, table, spTable.Fks)
 		conv.SpSchema[table] = spTable
@@ -221,7 +261,7 @@ func resolveFks(conv *Conv, table string, fks []ddl.Foreignkey) []ddl.Foreignkey
 }
 
 func resolveTableRef(conv *Conv, tableRef string) (string, error) {
-	if _, ok := conv.SpSchema[tableRef]; ok {
+	if _, ok := conv.spannerCols(tableRef); ok {
 		return tableRef, nil
 	}
 	// Do case-insensitive search for tableRef.
@@ -231,6 +271,13 @@ func resolveTableRef(conv *Conv, tableRef string) (string, error) {
 			return t, nil
 		}
 	}
+	if conv.spill != nil {
+		for t := range conv.spill.cols {
+			if strings.ToLower(t) == tr {
+				return t, nil
+			}
+		}
+	}
 	return "", fmt.Errorf("Can't resolve table %v", tableRef)
 }
 
@@ -239,13 +286,16 @@ func resolveColRefs(conv *Conv, tableRef string, colRefs []string) ([]string, er
 	if err != nil {
 		return nil, err
 	}
+	colNames, _ := conv.spannerCols(table)
 	resolveColRef := func(colRef string) (string, error) {
-		if _, ok := conv.SpSchema[table].ColDefs[colRef]; ok {
-			return colRef, nil
+		for _, c := range colNames {
+			if c == colRef {
+				return colRef, nil
+			}
 		}
 		// Do case-insensitive search for colRef.
 		cr := strings.ToLower(colRef)
-		for _, c := range conv.SpSchema[table].ColNames {
+		for _, c := range colNames {
 			if strings.ToLower(c) == cr {
 				return c, nil
 			}
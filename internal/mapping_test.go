@@ -15,6 +15,7 @@
 package internal
 
 import (
+	"strings"
 	"testing"
 
 	"github.com/cloudspannerecosystem/harbourbridge/spanner/ddl"
@@ -93,6 +94,49 @@ func TestGetSpannerCol(t *testing.T) {
 	}
 }
 
+func TestGetSpannerTableAndCol_PathologicalNames(t *testing.T) {
+	conv := MakeConv()
+	long := strings.Repeat("x", MaxNameLength+10)
+	tables := []struct {
+		srcTable string
+		spTable  string
+	}{
+		{"Users", "Users"},
+		{"users", "users_1"}, // Spanner names are case-insensitive.
+		{"USERS", "USERS_2"},
+		{"my schema.my table", "my_schema_my_table"},
+		{"my_schema.my_table", "my_schema_my_table_4"},
+		{"Ünïcødé", "An_c_d_"},
+		{long, strings.Repeat("x", MaxNameLength)},
+		{long + "y", strings.Repeat("x", MaxNameLength-2) + "_7"},
+	}
+	for _, tc := range tables {
+		spTable, err := GetSpannerTable(conv, tc.srcTable)
+		assert.Nil(t, err, tc.srcTable)
+		assert.Equal(t, tc.spTable, spTable, tc.srcTable)
+	}
+	cols := []struct {
+		srcCol string
+		spCol  string
+	}{
+		{"Id", "Id"},
+		{"ID", "ID_1"},
+		{"first name", "first_name"},
+		{"First.Name", "First_Name_3"},
+		{"名前", "A_"},
+	}
+	for _, tc := range cols {
+		spCol, err := GetSpannerCol(conv, "Users", tc.srcCol, false)
+		assert.Nil(t, err, tc.srcCol)
+		assert.Equal(t, tc.spCol, spCol, tc.srcCol)
+		// Mappings must be stable in both directions.
+		spCol, err = GetSpannerCol(conv, "Users", tc.srcCol, true)
+		assert.Nil(t, err, tc.srcCol)
+		assert.Equal(t, tc.spCol, spCol, tc.srcCol)
+		assert.Equal(t, tc.srcCol, conv.ToSource["Users"].Cols[spCol], tc.srcCol)
+	}
+}
+
 func TestToSpannerForeignKey(t *testing.T) {
 	schemaForeignKeys := make(map[string]bool)
 
@@ -130,6 +174,7 @@ func TestGetSpannerId(t *testing.T) {
 		{"Bad name with collision", "in\tdex", "in_dex_9"},
 		{"Bad name with collision 2", "in\ndex", "in_dex_10"},
 		{"Bad name with collision 3", "in?dex", "in_dex_11"},
+		{"Case collision", "INDEX1", "INDEX1_12"},
 	}
 	for _, tc := range basicTests {
 		spKeyName := getSpannerId(tc.srcKeyName, schemaIndexKeys)
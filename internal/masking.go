@@ -0,0 +1,217 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package internal
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"sort"
+	"strings"
+	"unicode"
+
+	"github.com/cloudspannerecosystem/harbourbridge/spanner/ddl"
+)
+
+// Masking methods (see MaskRule).
+const (
+	// MaskNull replaces values by NULL.
+	MaskNull = "null"
+	// MaskRedact replaces letters by 'x' and digits by '0', keeping other
+	// characters, so values keep their length and format.
+	MaskRedact = "redact"
+	// MaskHash replaces values by the hex SHA-256 hash of Value followed
+	// by the value, truncated to the length of the source column. Equal
+	// values have equal hashes, so joins on masked columns still work.
+	MaskHash = "hash"
+	// MaskFixed replaces values by Value.
+	MaskFixed = "fixed"
+)
+
+// MaskRule specifies how the values of a source column are masked during
+// data conversion, e.g. to load production data in a test database
+// without personal data. Masks are given in Policies.Masks, by source
+// table.column, and are applied to the values read from the source
+// database, before any other transform (e.g. computed columns). NULL
+// values are left as is.
+type MaskRule struct {
+	Method string // One of MaskNull, MaskRedact, MaskHash and MaskFixed.
+	Value  string `json:",omitempty"` // Replacement for MaskFixed, or salt for MaskHash.
+}
+
+// CheckMasks checks that conv.Policies.Masks are valid for the schema of
+// conv: masked columns must exist, masks other than MaskNull need
+// columns converted to STRING, MaskNull needs nullable columns, and
+// primary key columns can only be hashed.
+func (conv *Conv) CheckMasks() error {
+	var cols []string
+	for c := range conv.Policies.Masks {
+		cols = append(cols, c)
+	}
+	sort.Strings(cols)
+	for _, c := range cols {
+		m := conv.Policies.Masks[c]
+		i := strings.LastIndex(c, ".")
+		if i < 0 {
+			return fmt.Errorf("can't mask %s: columns must be given as table.column", c)
+		}
+		srcTable, srcCol := c[:i], c[i+1:]
+		st, ok := conv.SrcSchema[srcTable]
+		if !ok {
+			return fmt.Errorf("can't mask %s: unknown source table %s", c, srcTable)
+		}
+		cd, ok := st.ColDefs[srcCol]
+		if !ok {
+			return fmt.Errorf("can't mask %s: unknown column %s of table %s", c, srcCol, srcTable)
+		}
+		isKey := false
+		for _, k := range st.PrimaryKeys {
+			isKey = isKey || k.Column == srcCol
+		}
+		switch m.Method {
+		case MaskNull:
+			if cd.NotNull || isKey {
+				return fmt.Errorf("can't mask %s with NULL: column is NOT NULL", c)
+			}
+			continue
+		case MaskRedact, MaskHash, MaskFixed:
+		default:
+			return fmt.Errorf("can't mask %s: unknown method %q (accepted values are %q, %q, %q and %q)", c, m.Method, MaskNull, MaskRedact, MaskHash, MaskFixed)
+		}
+		if isKey && m.Method != MaskHash {
+			return fmt.Errorf("can't mask %s with %s: primary key columns can only be hashed", c, m.Method)
+		}
+		if spCol, ok := conv.ToSpanner[srcTable].Cols[srcCol]; ok {
+			ty := conv.SpSchema[conv.ToSpanner[srcTable].Name].ColDefs[spCol].T
+			if ty.Name != ddl.String || ty.IsArray {
+				return fmt.Errorf("can't mask %s with %s: column is converted to %s, not STRING", c, m.Method, ty.PrintColumnDefType())
+			}
+		}
+	}
+	return nil
+}
+
+// HasMasks returns true if any column of srcTable is masked.
+func (conv *Conv) HasMasks(srcTable string) bool {
+	for c := range conv.Policies.Masks {
+		if strings.HasPrefix(c, srcTable+".") && !strings.Contains(c[len(srcTable)+1:], ".") {
+			return true
+		}
+	}
+	return false
+}
+
+// Mask returns the masked version of v, a (non-NULL) value of srcCol of
+// srcTable, and false if it is masked as NULL. Values of columns without
+// a mask are returned as is.
+func (conv *Conv) Mask(srcTable, srcCol, v string) (string, bool) {
+	m, ok := conv.Policies.Masks[srcTable+"."+srcCol]
+	if !ok {
+		return v, true
+	}
+	switch m.Method {
+	case MaskNull:
+		return "", false
+	case MaskRedact:
+		return strings.Map(func(r rune) rune {
+			switch {
+			case unicode.IsLetter(r):
+				return 'x'
+			case unicode.IsDigit(r):
+				return '0'
+			}
+			return r
+		}, v), true
+	case MaskHash:
+		h := sha256.Sum256([]byte(m.Value + v))
+		s := hex.EncodeToString(h[:])
+		if n := conv.maskLength(srcTable, srcCol); n > 0 && n < int64(len(s)) {
+			s = s[:n]
+		}
+		return s, true
+	case MaskFixed:
+		return m.Value, true
+	}
+	return v, true
+}
+
+// maskLength returns the length of srcCol of srcTable, if it is a
+// (var)char column with a length, and 0 otherwise.
+func (conv *Conv) maskLength(srcTable, srcCol string) int64 {
+	ty := conv.SrcSchema[srcTable].ColDefs[srcCol].Type
+	if strings.Contains(strings.ToLower(ty.Name), "char") && len(ty.Mods) > 0 {
+		return ty.Mods[0]
+	}
+	return 0
+}
+
+// maskRow applies the masks of the source columns of srcTable to spVals.
+// Columns masked as NULL are omitted from the row.
+func (conv *Conv) maskRow(srcTable string, spCols []string, spVals []interface{}) ([]string, []interface{}) {
+	conv.rowsMu.Lock()
+	if conv.maskedCols == nil {
+		// Map the Spanner columns of masked columns to their source
+		// column, by source table.
+		conv.maskedCols = make(map[string]map[string]string)
+		for c := range conv.Policies.Masks {
+			i := strings.LastIndex(c, ".")
+			if i < 0 {
+				continue
+			}
+			t := c[:i]
+			if spCol, ok := conv.ToSpanner[t].Cols[c[i+1:]]; ok {
+				if conv.maskedCols[t] == nil {
+					conv.maskedCols[t] = make(map[string]string)
+				}
+				conv.maskedCols[t][spCol] = c[i+1:]
+			}
+		}
+	}
+	masked := conv.maskedCols[srcTable]
+	conv.rowsMu.Unlock()
+	if len(masked) == 0 {
+		return spCols, spVals
+	}
+	var cols []string
+	var vals []interface{}
+	for i, c := range spCols {
+		v := spVals[i]
+		if srcCol, ok := masked[c]; ok && v != nil {
+			if conv.Policies.Masks[srcTable+"."+srcCol].Method == MaskNull {
+				continue
+			}
+			if s, ok := v.(string); ok {
+				v, _ = conv.Mask(srcTable, srcCol, s)
+			}
+		}
+		cols = append(cols, c)
+		vals = append(vals, v)
+	}
+	return cols, vals
+}
+
+// buildMasksBody lists the masked columns of srcTable.
+func buildMasksBody(conv *Conv, srcTable string) []tableReportBody {
+	var l []string
+	for _, c := range conv.SrcSchema[srcTable].ColNames {
+		if m, ok := conv.Policies.Masks[srcTable+"."+c]; ok {
+			l = append(l, fmt.Sprintf("Column '%s' is masked (%s)", c, m.Method))
+		}
+	}
+	if len(l) == 0 {
+		return nil
+	}
+	return []tableReportBody{{Heading: "Masked columns", Lines: l}}
+}
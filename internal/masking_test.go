@@ -0,0 +1,114 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package internal
+
+import (
+	"testing"
+
+	"github.com/cloudspannerecosystem/harbourbridge/schema"
+	"github.com/cloudspannerecosystem/harbourbridge/spanner/ddl"
+	"github.com/stretchr/testify/assert"
+)
+
+func maskingTestConv() *Conv {
+	conv := MakeConv()
+	conv.SrcSchema["users"] = schema.Table{
+		Name:     "users",
+		ColNames: []string{"id", "email", "phone", "note", "age"},
+		ColDefs: map[string]schema.Column{
+			"id":    schema.Column{Name: "id", Type: schema.Type{Name: "varchar", Mods: []int64{8}}, NotNull: true},
+			"email": schema.Column{Name: "email", Type: schema.Type{Name: "varchar", Mods: []int64{16}}},
+			"phone": schema.Column{Name: "phone", Type: schema.Type{Name: "text"}},
+			"note":  schema.Column{Name: "note", Type: schema.Type{Name: "text"}},
+			"age":   schema.Column{Name: "age", Type: schema.Type{Name: "int"}},
+		},
+		PrimaryKeys: []schema.Key{schema.Key{Column: "id"}},
+	}
+	conv.SpSchema["users"] = ddl.CreateTable{
+		Name:     "users",
+		ColNames: []string{"id", "email", "phone", "note", "age"},
+		ColDefs: map[string]ddl.ColumnDef{
+			"id":    ddl.ColumnDef{Name: "id", T: ddl.Type{Name: ddl.String, Len: 8}, NotNull: true},
+			"email": ddl.ColumnDef{Name: "email", T: ddl.Type{Name: ddl.String, Len: 16}},
+			"phone": ddl.ColumnDef{Name: "phone", T: ddl.Type{Name: ddl.String, Len: ddl.MaxLength}},
+			"note":  ddl.ColumnDef{Name: "note", T: ddl.Type{Name: ddl.String, Len: ddl.MaxLength}},
+			"age":   ddl.ColumnDef{Name: "age", T: ddl.Type{Name: ddl.Int64}},
+		},
+		Pks: []ddl.IndexKey{ddl.IndexKey{Col: "id"}},
+	}
+	conv.ToSpanner["users"] = NameAndCols{Name: "users", Cols: map[string]string{"id": "id", "email": "email", "phone": "phone", "note": "note", "age": "age"}}
+	return conv
+}
+
+func TestMask(t *testing.T) {
+	conv := maskingTestConv()
+	conv.Policies.Masks = map[string]MaskRule{
+		"users.email": {Method: MaskHash, Value: "s"},
+		"users.phone": {Method: MaskRedact},
+		"users.note":  {Method: MaskFixed, Value: "n/a"},
+		"users.age":   {Method: MaskNull},
+	}
+	assert.Nil(t, conv.CheckMasks())
+	assert.True(t, conv.HasMasks("users"))
+	assert.False(t, conv.HasMasks("orders"))
+
+	for _, tc := range []struct {
+		col, v, want string
+		notNull      bool
+	}{
+		// Hashes are truncated to the length of the column.
+		{"email", "ada@example.com", "d94188c85e931cfd", true},
+		{"phone", "+1 (555) 010-Ada", "+0 (000) 000-xxx", true},
+		{"note", "secret", "n/a", true},
+		{"age", "42", "", false},
+		{"id", "abc", "abc", true},
+	} {
+		got, notNull := conv.Mask("users", tc.col, tc.v)
+		assert.Equal(t, tc.want, got, tc.col)
+		assert.Equal(t, tc.notNull, notNull, tc.col)
+	}
+
+	conv.SetDataMode()
+	var gotCols []string
+	var gotVals []interface{}
+	conv.SetDataSink(func(table string, cols []string, vals []interface{}) {
+		gotCols, gotVals = cols, vals
+	})
+	conv.WriteRow("users", "users", []string{"id", "email", "phone", "age"}, []interface{}{"abc", "ada@example.com", "555-0100", int64(42)})
+	assert.Equal(t, []string{"id", "email", "phone"}, gotCols)
+	assert.Equal(t, []interface{}{"abc", "d94188c85e931cfd", "000-0000"}, gotVals)
+}
+
+func TestCheckMasks(t *testing.T) {
+	for _, tc := range []struct {
+		col  string
+		mask MaskRule
+	}{
+		{"users", MaskRule{Method: MaskNull}},
+		{"orders.id", MaskRule{Method: MaskNull}},
+		{"users.name", MaskRule{Method: MaskNull}},
+		{"users.email", MaskRule{Method: "scramble"}},
+		{"users.id", MaskRule{Method: MaskNull}},
+		{"users.id", MaskRule{Method: MaskRedact}},
+		{"users.age", MaskRule{Method: MaskHash}},
+	} {
+		conv := maskingTestConv()
+		conv.Policies.Masks = map[string]MaskRule{tc.col: tc.mask}
+		assert.NotNil(t, conv.CheckMasks(), tc.col+" "+tc.mask.Method)
+	}
+	conv := maskingTestConv()
+	conv.Policies.Masks = map[string]MaskRule{"users.id": {Method: MaskHash}}
+	assert.Nil(t, conv.CheckMasks())
+}
@@ -0,0 +1,375 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package internal
+
+import (
+	"fmt"
+	"go/format"
+	"sort"
+	"strings"
+
+	"github.com/cloudspannerecosystem/harbourbridge/spanner/ddl"
+)
+
+// ModelLanguage is a language for which we generate models (classes or
+// structs mapping the rows of each Spanner table) from the Spanner schema,
+// as a starting point for application code.
+type ModelLanguage int
+
+const (
+	// GoModels are Go structs, with spanner tags for use with
+	// spanner.Row.ToStruct and spanner.InsertStruct.
+	GoModels ModelLanguage = iota
+	// SQLAlchemyModels are SQLAlchemy declarative classes, for use with
+	// the sqlalchemy-spanner dialect.
+	SQLAlchemyModels
+)
+
+var modelLanguageNames = map[ModelLanguage]string{
+	GoModels:         "go",
+	SQLAlchemyModels: "sqlalchemy",
+}
+
+func (l ModelLanguage) String() string {
+	if s, ok := modelLanguageNames[l]; ok {
+		return s
+	}
+	return fmt.Sprintf("ModelLanguage(%d)", int(l))
+}
+
+// Extension returns the file extension of models in language l.
+func (l ModelLanguage) Extension() string {
+	if l == SQLAlchemyModels {
+		return "py"
+	}
+	return "go"
+}
+
+// ParseModelLanguages maps a comma-separated list of model language names
+// (as used on the command line) to ModelLanguages.
+func ParseModelLanguages(s string) ([]ModelLanguage, error) {
+	var l []ModelLanguage
+	for _, name := range strings.Split(s, ",") {
+		name = strings.ToLower(strings.TrimSpace(name))
+		if name == "" {
+			continue
+		}
+		found := false
+		for m, n := range modelLanguageNames {
+			if n == name {
+				l = append(l, m)
+				found = true
+			}
+		}
+		if !found {
+			return nil, fmt.Errorf("unknown model language %q (accepted values are \"go\" and \"sqlalchemy\")", name)
+		}
+	}
+	return l, nil
+}
+
+// GenerateModels returns the source code of models for the tables of
+// conv.SpSchema, in language l. Models are ordered like the tables of the
+// generated DDL, and document the primary key and interleaving of each
+// table.
+func GenerateModels(conv *Conv, l ModelLanguage) string {
+	if l == SQLAlchemyModels {
+		return generateSQLAlchemyModels(conv)
+	}
+	return generateGoModels(conv)
+}
+
+func generateGoModels(conv *Conv) string {
+	imports := make(map[string]bool)
+	names := modelNames(conv)
+	var body strings.Builder
+	for _, t := range conv.SpTables() {
+		ct := conv.SpSchema[t]
+		name := names[t]
+		fmt.Fprintf(&body, "\n// %s is a row of table %s.\n// Primary key: (%s).\n", name, ct.Name, modelKeys(ct))
+		if ct.Parent != "" {
+			fmt.Fprintf(&body, "// Interleaved in %s (%s).\n", ct.Parent, names[ct.Parent])
+		}
+		fmt.Fprintf(&body, "type %s struct {\n", name)
+		used := map[string]bool{"TableName": true} // Method of the model.
+		for _, c := range ct.ColNames {
+			cd := ct.ColDefs[c]
+			goType, imp := goModelType(cd)
+			if imp != "" {
+				imports[imp] = true
+			}
+			field := camelCase(c)
+			for i := 1; used[field]; i++ {
+				field = fmt.Sprintf("%s%d", camelCase(c), i)
+			}
+			used[field] = true
+			fmt.Fprintf(&body, "\t%s %s `spanner:\"%s\"`%s\n", field, goType, c, keyComment(ct, c))
+		}
+		fmt.Fprintf(&body, "}\n\n// TableName returns the name of the Spanner table of %s.\nfunc (%s) TableName() string { return %q }\n", name, name, ct.Name)
+	}
+	var s strings.Builder
+	s.WriteString("// Code generated by HarbourBridge. Models of the Spanner schema.\n\npackage models\n")
+	if len(imports) > 0 {
+		var l []string
+		for i := range imports {
+			l = append(l, i)
+		}
+		sort.Strings(l)
+		s.WriteString("\nimport (\n")
+		for _, i := range l {
+			fmt.Fprintf(&s, "\t%q\n", i)
+		}
+		s.WriteString(")\n")
+	}
+	s.WriteString(body.String())
+	b, err := format.Source([]byte(s.String()))
+	if err != nil {
+		return s.String()
+	}
+	return string(b)
+}
+
+// goModelType returns the Go type of column cd, and the package it needs.
+// Nullable columns use the spanner.Null* types. Array elements can always
+// be NULL.
+func goModelType(cd ddl.ColumnDef) (string, string) {
+	const sp = "cloud.google.com/go/spanner"
+	nullable := !cd.NotNull || cd.T.IsArray
+	var t, imp string
+	switch cd.T.Name {
+	case ddl.Bool:
+		t = "bool"
+	case ddl.Int64:
+		t = "int64"
+	case ddl.Float32:
+		t = "float32"
+	case ddl.Float64:
+		t = "float64"
+	case ddl.String:
+		t = "string"
+	case ddl.Bytes:
+		t, nullable = "[]byte", false // nil is NULL.
+	case ddl.Date:
+		t, imp = "civil.Date", "cloud.google.com/go/civil"
+	case ddl.Timestamp:
+		t, imp = "time.Time", "time"
+	case ddl.Numeric:
+		t, imp = "big.Rat", "math/big"
+	case ddl.JSON:
+		t, nullable = "spanner.NullJSON", false
+		imp = sp
+	default:
+		t = "interface{}"
+	}
+	if nullable {
+		switch t {
+		case "time.Time":
+			t = "spanner.NullTime"
+		case "big.Rat":
+			t = "spanner.NullNumeric"
+		default:
+			t = "spanner.Null" + strings.Title(strings.TrimPrefix(t, "civil."))
+		}
+		imp = sp
+	}
+	if cd.T.IsArray {
+		t = "[]" + t
+	}
+	return t, imp
+}
+
+func generateSQLAlchemyModels(conv *Conv) string {
+	types := make(map[string]bool)
+	names := modelNames(conv)
+	var body strings.Builder
+	for _, t := range conv.SpTables() {
+		ct := conv.SpSchema[t]
+		fmt.Fprintf(&body, "\n\nclass %s(Base):\n    \"\"\"Row of table %s. Primary key: (%s).\"\"\"\n\n    __tablename__ = %q\n", names[t], ct.Name, modelKeys(ct), ct.Name)
+		if ct.Parent != "" {
+			fmt.Fprintf(&body, "    __table_args__ = {\"spanner_interleave_in\": %q}\n", ct.Parent)
+		}
+		body.WriteString("\n")
+		fks := make(map[string]string)
+		for _, fk := range ct.Fks {
+			if len(fk.Columns) == 1 {
+				fks[fk.Columns[0]] = fk.ReferTable + "." + fk.ReferColumns[0]
+			}
+		}
+		for _, c := range ct.ColNames {
+			cd := ct.ColDefs[c]
+			pyType, l := sqlAlchemyType(cd.T)
+			for _, x := range l {
+				types[x] = true
+			}
+			args := []string{fmt.Sprintf("%q", c), pyType}
+			if ref, ok := fks[c]; ok {
+				types["ForeignKey"] = true
+				args = append(args, fmt.Sprintf("ForeignKey(%q)", ref))
+			}
+			if isKey(ct, c) {
+				args = append(args, "primary_key=True")
+			} else if cd.NotNull {
+				args = append(args, "nullable=False")
+			}
+			attr := c
+			if pythonKeywords[attr] {
+				attr += "_"
+			}
+			fmt.Fprintf(&body, "    %s = Column(%s)\n", attr, strings.Join(args, ", "))
+		}
+	}
+	types["Column"] = true
+	var l []string
+	for t := range types {
+		if t != "ARRAY" {
+			l = append(l, t)
+		}
+	}
+	sort.Strings(l)
+	var s strings.Builder
+	s.WriteString("# Code generated by HarbourBridge. Models of the Spanner schema.\n\n")
+	fmt.Fprintf(&s, "from sqlalchemy import %s\n", strings.Join(l, ", "))
+	if types["ARRAY"] {
+		s.WriteString("from sqlalchemy.types import ARRAY\n")
+	}
+	s.WriteString("from sqlalchemy.orm import declarative_base\n\nBase = declarative_base()\n")
+	s.WriteString(body.String())
+	return s.String()
+}
+
+// sqlAlchemyType returns the SQLAlchemy type of t, and the names it uses.
+func sqlAlchemyType(t ddl.Type) (string, []string) {
+	var s string
+	switch t.Name {
+	case ddl.Bool:
+		s = "Boolean"
+	case ddl.Int64:
+		s = "BigInteger"
+	case ddl.Float32, ddl.Float64:
+		s = "Float"
+	case ddl.String:
+		s = "String"
+	case ddl.Bytes:
+		s = "LargeBinary"
+	case ddl.Date:
+		s = "Date"
+	case ddl.Timestamp:
+		s = "DateTime"
+	case ddl.Numeric:
+		s = "Numeric"
+	case ddl.JSON:
+		s = "JSON"
+	default:
+		s = "String"
+	}
+	names := []string{s}
+	switch {
+	case (t.Name == ddl.String || t.Name == ddl.Bytes) && t.Len != ddl.MaxLength && t.Len > 0:
+		s = fmt.Sprintf("%s(%d)", s, t.Len)
+	case t.Name == ddl.Timestamp:
+		s = "DateTime(timezone=True)"
+	}
+	if t.IsArray {
+		s = "ARRAY(" + s + ")"
+		names = append(names, "ARRAY")
+	}
+	return s, names
+}
+
+var pythonKeywords = map[string]bool{
+	"False": true, "None": true, "True": true, "and": true, "as": true, "assert": true, "async": true,
+	"await": true, "break": true, "class": true, "continue": true, "def": true, "del": true, "elif": true,
+	"else": true, "except": true, "finally": true, "for": true, "from": true, "global": true, "if": true,
+	"import": true, "in": true, "is": true, "lambda": true, "nonlocal": true, "not": true, "or": true,
+	"pass": true, "raise": true, "return": true, "try": true, "while": true, "with": true, "yield": true,
+	"metadata": true, // Reserved by SQLAlchemy's declarative base.
+}
+
+// modelKeys lists the primary key columns of ct.
+func modelKeys(ct ddl.CreateTable) string {
+	var l []string
+	for _, k := range ct.Pks {
+		if k.Desc {
+			l = append(l, k.Col+" DESC")
+		} else {
+			l = append(l, k.Col)
+		}
+	}
+	return strings.Join(l, ", ")
+}
+
+func isKey(ct ddl.CreateTable, col string) bool {
+	for _, k := range ct.Pks {
+		if k.Col == col {
+			return true
+		}
+	}
+	return false
+}
+
+// keyComment returns a comment for primary key column col of ct (e.g.
+// "// Primary key (1/2)."), or "" if col isn't a key column.
+func keyComment(ct ddl.CreateTable, col string) string {
+	for i, k := range ct.Pks {
+		if k.Col == col {
+			return fmt.Sprintf(" // Primary key (%d/%d).", i+1, len(ct.Pks))
+		}
+	}
+	return ""
+}
+
+// modelNames maps the Spanner tables of conv to model names (see
+// camelCase). Tables whose names only differ by underscores or case get a
+// numeric suffix.
+func modelNames(conv *Conv) map[string]string {
+	m := make(map[string]string)
+	used := make(map[string]bool)
+	var tables []string
+	for t := range conv.SpSchema {
+		tables = append(tables, t)
+	}
+	sort.Strings(tables)
+	for _, t := range tables {
+		name := camelCase(t)
+		for i := 1; used[strings.ToLower(name)]; i++ {
+			name = fmt.Sprintf("%s%d", camelCase(t), i)
+		}
+		used[strings.ToLower(name)] = true
+		m[t] = name
+	}
+	return m
+}
+
+// camelCase converts a Spanner name (letters, digits and underscores) to
+// an exported Go or Python class name, e.g. order_items to OrderItems.
+func camelCase(s string) string {
+	var b strings.Builder
+	upper := true
+	for _, r := range s {
+		switch {
+		case r == '_':
+			upper = true
+		case upper:
+			b.WriteString(strings.ToUpper(string(r)))
+			upper = false
+		default:
+			b.WriteRune(r)
+		}
+	}
+	if b.Len() == 0 {
+		return "X"
+	}
+	return b.String()
+}
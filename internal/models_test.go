@@ -0,0 +1,133 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package internal
+
+import (
+	"testing"
+
+	"github.com/cloudspannerecosystem/harbourbridge/spanner/ddl"
+	"github.com/stretchr/testify/assert"
+)
+
+func modelsConv() *Conv {
+	conv := MakeConv()
+	conv.SpSchema["singers"] = ddl.CreateTable{
+		Name:     "singers",
+		ColNames: []string{"singer_id", "name", "table_name", "born"},
+		ColDefs: map[string]ddl.ColumnDef{
+			"singer_id":  {Name: "singer_id", T: ddl.Type{Name: ddl.Int64}, NotNull: true},
+			"name":       {Name: "name", T: ddl.Type{Name: ddl.String, Len: 100}, NotNull: true},
+			"table_name": {Name: "table_name", T: ddl.Type{Name: ddl.String, Len: ddl.MaxLength}},
+			"born":       {Name: "born", T: ddl.Type{Name: ddl.Date}},
+		},
+		Pks: []ddl.IndexKey{{Col: "singer_id"}},
+	}
+	conv.SpSchema["albums"] = ddl.CreateTable{
+		Name:     "albums",
+		ColNames: []string{"singer_id", "album_id", "tags", "from"},
+		ColDefs: map[string]ddl.ColumnDef{
+			"singer_id": {Name: "singer_id", T: ddl.Type{Name: ddl.Int64}, NotNull: true},
+			"album_id":  {Name: "album_id", T: ddl.Type{Name: ddl.Int64}, NotNull: true},
+			"tags":      {Name: "tags", T: ddl.Type{Name: ddl.String, Len: ddl.MaxLength, IsArray: true}},
+			"from":      {Name: "from", T: ddl.Type{Name: ddl.Timestamp}, NotNull: true},
+		},
+		Pks:    []ddl.IndexKey{{Col: "singer_id"}, {Col: "album_id", Desc: true}},
+		Fks:    []ddl.Foreignkey{{Columns: []string{"singer_id"}, ReferTable: "singers", ReferColumns: []string{"singer_id"}}},
+		Parent: "singers",
+	}
+	return conv
+}
+
+func TestGenerateModels_Go(t *testing.T) {
+	assert.Equal(t, `// Code generated by HarbourBridge. Models of the Spanner schema.
+
+package models
+
+import (
+	"cloud.google.com/go/spanner"
+	"time"
+)
+
+// Albums is a row of table albums.
+// Primary key: (singer_id, album_id DESC).
+// Interleaved in singers (Singers).
+type Albums struct {
+	SingerId int64                `+"`spanner:\"singer_id\"`"+` // Primary key (1/2).
+	AlbumId  int64                `+"`spanner:\"album_id\"`"+`  // Primary key (2/2).
+	Tags     []spanner.NullString `+"`spanner:\"tags\"`"+`
+	From     time.Time            `+"`spanner:\"from\"`"+`
+}
+
+// TableName returns the name of the Spanner table of Albums.
+func (Albums) TableName() string { return "albums" }
+
+// Singers is a row of table singers.
+// Primary key: (singer_id).
+type Singers struct {
+	SingerId   int64              `+"`spanner:\"singer_id\"`"+` // Primary key (1/1).
+	Name       string             `+"`spanner:\"name\"`"+`
+	TableName1 spanner.NullString `+"`spanner:\"table_name\"`"+`
+	Born       spanner.NullDate   `+"`spanner:\"born\"`"+`
+}
+
+// TableName returns the name of the Spanner table of Singers.
+func (Singers) TableName() string { return "singers" }
+`, GenerateModels(modelsConv(), GoModels))
+}
+
+func TestGenerateModels_SQLAlchemy(t *testing.T) {
+	assert.Equal(t, `# Code generated by HarbourBridge. Models of the Spanner schema.
+
+from sqlalchemy import BigInteger, Column, Date, DateTime, ForeignKey, String
+from sqlalchemy.types import ARRAY
+from sqlalchemy.orm import declarative_base
+
+Base = declarative_base()
+
+
+class Albums(Base):
+    """Row of table albums. Primary key: (singer_id, album_id DESC)."""
+
+    __tablename__ = "albums"
+    __table_args__ = {"spanner_interleave_in": "singers"}
+
+    singer_id = Column("singer_id", BigInteger, ForeignKey("singers.singer_id"), primary_key=True)
+    album_id = Column("album_id", BigInteger, primary_key=True)
+    tags = Column("tags", ARRAY(String))
+    from_ = Column("from", DateTime(timezone=True), nullable=False)
+
+
+class Singers(Base):
+    """Row of table singers. Primary key: (singer_id)."""
+
+    __tablename__ = "singers"
+
+    singer_id = Column("singer_id", BigInteger, primary_key=True)
+    name = Column("name", String(100), nullable=False)
+    table_name = Column("table_name", String)
+    born = Column("born", Date)
+`, GenerateModels(modelsConv(), SQLAlchemyModels))
+}
+
+func TestParseModelLanguages(t *testing.T) {
+	l, err := ParseModelLanguages("go, SQLAlchemy")
+	assert.Nil(t, err)
+	assert.Equal(t, []ModelLanguage{GoModels, SQLAlchemyModels}, l)
+	l, err = ParseModelLanguages("")
+	assert.Nil(t, err)
+	assert.Nil(t, l)
+	_, err = ParseModelLanguages("cobol")
+	assert.NotNil(t, err)
+}
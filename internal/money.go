@@ -0,0 +1,215 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package internal
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"unicode"
+
+	"github.com/cloudspannerecosystem/harbourbridge/spanner/ddl"
+)
+
+// moneyWords are the words of column names that suggest the column holds
+// monetary amounts (see LooksMonetary).
+var moneyWords = map[string]bool{
+	"amount": true, "amt": true, "balance": true, "charge": true, "cost": true,
+	"credit": true, "debit": true, "discount": true, "eur": true, "fee": true,
+	"gbp": true, "income": true, "money": true, "paid": true, "payment": true,
+	"price": true, "refund": true, "revenue": true, "salary": true,
+	"subtotal": true, "tax": true, "total": true, "usd": true, "wage": true,
+}
+
+// LooksMonetary returns true if col is the name of a column that is
+// likely to hold monetary amounts, such as unit_price or totalAmount.
+func LooksMonetary(col string) bool {
+	for _, w := range nameWords(col) {
+		if moneyWords[w] || moneyWords[strings.TrimSuffix(w, "s")] {
+			return true
+		}
+	}
+	return false
+}
+
+// nameWords splits a snake_case or camelCase name into lower case words.
+func nameWords(name string) []string {
+	var words []string
+	var w []rune
+	flush := func() {
+		if len(w) > 0 {
+			words = append(words, strings.ToLower(string(w)))
+			w = nil
+		}
+	}
+	for i, r := range name {
+		switch {
+		case !unicode.IsLetter(r):
+			flush()
+			continue
+		case unicode.IsUpper(r) && i > 0 && len(w) > 0 && unicode.IsLower(w[len(w)-1]):
+			flush()
+		}
+		w = append(w, r)
+	}
+	flush()
+	return words
+}
+
+// ParseMoneyColumns parses a comma-separated list of entries of the form
+// 'table.column=numeric' (convert the FLOAT64 or FLOAT32 Spanner column
+// to NUMERIC) or 'table.column=float' (keep it), optionally starting with
+// "auto" to also convert the columns suggested by SuggestMoneyCols. For
+// example, "auto,stats.score=float". See Remodel.Money.
+func ParseMoneyColumns(s string) (bool, map[string]bool, error) {
+	return parseColumnTypes(s, "money", "numeric", "float")
+}
+
+// parseColumnTypes parses a comma-separated list of entries of the form
+// 'table.column=on' (mapped to true) or 'table.column=off' (mapped to
+// false), optionally starting with "auto". what names the kind of column
+// in errors.
+func parseColumnTypes(s, what, on, off string) (bool, map[string]bool, error) {
+	auto := false
+	cols := make(map[string]bool)
+	for _, e := range strings.Split(s, ",") {
+		e = strings.TrimSpace(e)
+		switch {
+		case e == "":
+			continue
+		case strings.ToLower(e) == "auto":
+			auto = true
+			continue
+		}
+		i := strings.LastIndex(e, "=")
+		if i < 0 || !strings.Contains(e[:i], ".") {
+			return false, nil, fmt.Errorf("bad %s column %q: expected table.column=%s or table.column=%s", what, e, on, off)
+		}
+		switch strings.ToLower(e[i+1:]) {
+		case on:
+			cols[e[:i]] = true
+		case off:
+			cols[e[:i]] = false
+		default:
+			return false, nil, fmt.Errorf("bad %s column %q: accepted types are %q and %q", what, e, on, off)
+		}
+	}
+	return auto, cols, nil
+}
+
+// SuggestMoneyCols returns the FLOAT64 and FLOAT32 Spanner columns (as
+// table.column, sorted) whose name suggests they hold monetary amounts
+// (see LooksMonetary). Binary floating point can't represent most
+// decimal amounts exactly, so they are better stored as NUMERIC.
+func (conv *Conv) SuggestMoneyCols() []string {
+	var l []string
+	for t, ct := range conv.SpSchema {
+		for _, c := range ct.ColNames {
+			if isFloat(ct.ColDefs[c].T) && LooksMonetary(c) && conv.canChangeColType(t, c) {
+				l = append(l, t+"."+c)
+			}
+		}
+	}
+	sort.Strings(l)
+	return l
+}
+
+func isFloat(ty ddl.Type) bool {
+	return !ty.IsArray && (ty.Name == ddl.Float64 || ty.Name == ddl.Float32)
+}
+
+// canChangeColType returns false if the type of spCol must match that of
+// columns of other tables: key columns (which are shared with interleaved
+// tables) and columns of foreign keys.
+func (conv *Conv) canChangeColType(spTable, spCol string) bool {
+	if isKeyCol(conv.SpSchema[spTable], spCol) {
+		return false
+	}
+	for t, ct := range conv.SpSchema {
+		for _, fk := range ct.Fks {
+			if (t == spTable && containsString(fk.Columns, spCol)) || (fk.ReferTable == spTable && containsString(fk.ReferColumns, spCol)) {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// applyMoneyCols converts Spanner columns to NUMERIC (if cols maps them
+// to true), or back to their original floating point type (if cols maps
+// them to false and they were converted), and records the conversions in
+// conv.MoneyCols. If auto is set, the columns suggested by
+// SuggestMoneyCols are also converted, unless cols maps them to false.
+// During data conversion, the values of converted columns are parsed
+// exactly from their decimal text.
+func (conv *Conv) applyMoneyCols(auto bool, cols map[string]bool) error {
+	all := make(map[string]bool)
+	if auto {
+		for _, c := range conv.SuggestMoneyCols() {
+			all[c] = true
+		}
+	}
+	for c, numeric := range cols {
+		all[c] = numeric
+	}
+	var names []string
+	for c := range all {
+		names = append(names, c)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		i := strings.LastIndex(name, ".")
+		if i < 0 {
+			return fmt.Errorf("money column %s must be given as table.column", name)
+		}
+		if err := conv.setMoneyCol(name[:i], name[i+1:], all[name]); err != nil {
+			return fmt.Errorf("can't convert column %s: %w", name, err)
+		}
+	}
+	return nil
+}
+
+func (conv *Conv) setMoneyCol(spTable, spCol string, numeric bool) error {
+	ct, ok := conv.SpSchema[spTable]
+	if !ok {
+		return fmt.Errorf("unknown table")
+	}
+	cd, ok := ct.ColDefs[spCol]
+	if !ok {
+		return fmt.Errorf("unknown column")
+	}
+	original, converted := conv.MoneyCols[spTable+"."+spCol]
+	switch {
+	case numeric && converted, !numeric && !converted && isFloat(cd.T):
+		return nil // Already applied.
+	case !numeric && converted:
+		cd.T = original
+		delete(conv.MoneyCols, spTable+"."+spCol)
+	case !isFloat(cd.T):
+		return fmt.Errorf("type %s is not a floating point type", cd.T.PrintColumnDefType())
+	case !conv.canChangeColType(spTable, spCol):
+		return fmt.Errorf("key columns and columns used by foreign keys can't be converted")
+	case conv.TargetDb == "experimental_postgres" && !conv.Supports(PGNumeric): // conversion.TARGET_EXPERIMENTAL_POSTGRES, which would be an import cycle.
+		return fmt.Errorf("the target doesn't support numeric columns")
+	default:
+		if conv.MoneyCols == nil {
+			conv.MoneyCols = make(map[string]ddl.Type)
+		}
+		conv.MoneyCols[spTable+"."+spCol] = cd.T
+		cd.T = ddl.Type{Name: ddl.Numeric}
+	}
+	ct.ColDefs[spCol] = cd
+	return nil
+}
@@ -0,0 +1,94 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package internal
+
+import (
+	"testing"
+
+	"github.com/cloudspannerecosystem/harbourbridge/spanner/ddl"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLooksMonetary(t *testing.T) {
+	for col, want := range map[string]bool{
+		"price":       true,
+		"unit_price":  true,
+		"totalAmount": true,
+		"Fees":        true,
+		"price_usd":   true,
+		"score":       false,
+		"latitude":    false,
+		"costume":     false,
+		"taxonomy_id": false,
+	} {
+		assert.Equal(t, want, LooksMonetary(col), col)
+	}
+}
+
+func TestParseMoneyColumns(t *testing.T) {
+	auto, cols, err := ParseMoneyColumns("auto, orders.total=numeric,stats.score=float")
+	assert.Nil(t, err)
+	assert.True(t, auto)
+	assert.Equal(t, map[string]bool{"orders.total": true, "stats.score": false}, cols)
+	auto, cols, err = ParseMoneyColumns("")
+	assert.Nil(t, err)
+	assert.False(t, auto)
+	assert.Equal(t, map[string]bool{}, cols)
+	for _, s := range []string{"total=numeric", "orders.total", "orders.total=decimal"} {
+		_, _, err := ParseMoneyColumns(s)
+		assert.NotNil(t, err, s)
+	}
+}
+
+func TestMoneyCols(t *testing.T) {
+	conv := MakeConv()
+	f64 := ddl.Type{Name: ddl.Float64}
+	conv.SpSchema["orders"] = ddl.CreateTable{
+		Name:     "orders",
+		ColNames: []string{"id", "price", "discount", "weight", "customer_balance"},
+		ColDefs: map[string]ddl.ColumnDef{
+			"id":               ddl.ColumnDef{Name: "id", T: ddl.Type{Name: ddl.Int64}, NotNull: true},
+			"price":            ddl.ColumnDef{Name: "price", T: f64, NotNull: true},
+			"discount":         ddl.ColumnDef{Name: "discount", T: ddl.Type{Name: ddl.Float32}},
+			"weight":           ddl.ColumnDef{Name: "weight", T: f64},
+			"customer_balance": ddl.ColumnDef{Name: "customer_balance", T: f64},
+		},
+		Pks: []ddl.IndexKey{ddl.IndexKey{Col: "id"}},
+		Fks: []ddl.Foreignkey{ddl.Foreignkey{Name: "fk", Columns: []string{"customer_balance"}, ReferTable: "customers", ReferColumns: []string{"balance"}}},
+	}
+	// customer_balance is used by a foreign key, so it isn't suggested.
+	assert.Equal(t, []string{"orders.discount", "orders.price"}, conv.SuggestMoneyCols())
+
+	r := Remodel{AutoMoney: true, Money: map[string]bool{"orders.discount": false, "orders.weight": true}}
+	assert.Nil(t, conv.ApplyRemodel(r))
+	ct := conv.SpSchema["orders"]
+	assert.Equal(t, ddl.ColumnDef{Name: "price", T: ddl.Type{Name: ddl.Numeric}, NotNull: true}, ct.ColDefs["price"])
+	assert.Equal(t, ddl.Type{Name: ddl.Float32}, ct.ColDefs["discount"].T)
+	assert.Equal(t, ddl.Type{Name: ddl.Numeric}, ct.ColDefs["weight"].T)
+	assert.Equal(t, map[string]ddl.Type{"orders.price": ddl.Type{Name: ddl.Float64}, "orders.weight": ddl.Type{Name: ddl.Float64}}, conv.MoneyCols)
+	assert.Equal(t, []string{"orders.discount"}, conv.SuggestMoneyCols())
+	// Already applied.
+	assert.Nil(t, conv.ApplyRemodel(r))
+	assert.Equal(t, 2, len(conv.MoneyCols))
+
+	// A converted column can be converted back.
+	assert.Nil(t, conv.ApplyRemodel(Remodel{Money: map[string]bool{"orders.weight": false}}))
+	assert.Equal(t, ddl.Type{Name: ddl.Float64}, conv.SpSchema["orders"].ColDefs["weight"].T)
+	assert.Equal(t, map[string]ddl.Type{"orders.price": ddl.Type{Name: ddl.Float64}}, conv.MoneyCols)
+
+	for _, c := range []string{"orders.id", "orders.customer_balance", "orders.missing", "other.price"} {
+		assert.NotNil(t, conv.ApplyRemodel(Remodel{Money: map[string]bool{c: true}}), c)
+	}
+}
@@ -0,0 +1,212 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package internal
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// Ways of mapping the characters of source names that Spanner doesn't
+// accept (see NameMapping).
+const (
+	// ReplaceNames replaces them by underscores (see FixName).
+	ReplaceNames = "replace"
+	// TransliterateNames spells the letters of other scripts with ASCII
+	// letters: accented Latin letters, Cyrillic, Greek and kana (Hepburn
+	// romanization). Other characters, e.g. Chinese characters, are
+	// replaced by U and their code point (e.g. U7528), which unlike
+	// underscores keeps names distinct; give their meaning in the
+	// dictionary to get readable names.
+	TransliterateNames = "transliterate"
+)
+
+// NameMapping specifies how source names are mapped to Spanner names,
+// which can only contain ASCII letters, digits and underscores. It applies
+// to tables, columns, indexes, foreign keys and sequences, before FixName.
+type NameMapping struct {
+	Mode string `json:",omitempty"` // ReplaceNames (the default if empty) or TransliterateNames.
+	// Dictionary maps words (or characters) of source names to their
+	// replacement, e.g. "用户" to "user". It is applied first, to the
+	// longest words first, whatever the mode.
+	Dictionary map[string]string `json:",omitempty"`
+}
+
+// ParseNameMappingMode checks mode, for NameMapping.Mode.
+func ParseNameMappingMode(mode string) (string, error) {
+	switch mode {
+	case ReplaceNames, TransliterateNames:
+		return mode, nil
+	}
+	return "", fmt.Errorf("unknown name mapping %q: accepted values are %s and %s", mode, ReplaceNames, TransliterateNames)
+}
+
+// apply maps name as t specifies, before FixName.
+func (t NameMapping) apply(name string) string {
+	if len(t.Dictionary) > 0 {
+		var words []string
+		for w := range t.Dictionary {
+			if w != "" {
+				words = append(words, w)
+			}
+		}
+		sort.Slice(words, func(i, j int) bool {
+			if len(words[i]) != len(words[j]) {
+				return len(words[i]) > len(words[j])
+			}
+			return words[i] < words[j]
+		})
+		var l []string
+		for _, w := range words {
+			l = append(l, w, t.Dictionary[w])
+		}
+		name = strings.NewReplacer(l...).Replace(name)
+	}
+	if t.Mode == TransliterateNames {
+		name = transliterate(name)
+	}
+	return name
+}
+
+// spannerName maps source name to a legal Spanner name (see
+// NameMapping and FixName), and returns whether it had to be changed.
+func (conv *Conv) spannerName(name string) (string, bool) {
+	sp, _ := FixName(conv.NameMapping.apply(name))
+	return sp, sp != name
+}
+
+// transliterate spells the non-ASCII characters of s with ASCII letters
+// (see TransliterateNames).
+func transliterate(s string) string {
+	var b strings.Builder
+	rs := []rune(s)
+	for i := 0; i < len(rs); i++ {
+		r := rs[i]
+		if r <= unicode.MaxASCII {
+			b.WriteRune(r)
+			continue
+		}
+		lower := unicode.ToLower(r)
+		if t, ok := letters[lower]; ok {
+			if lower != r && t != "" {
+				t = strings.ToUpper(t[:1]) + t[1:]
+			}
+			b.WriteString(t)
+			continue
+		}
+		if k, ok := kanaBase(r); ok {
+			t, n := romanizeKana(rs[i:], k)
+			b.WriteString(t)
+			i += n - 1
+			continue
+		}
+		fmt.Fprintf(&b, "U%04X", r)
+	}
+	return b.String()
+}
+
+// letters are the romanizations of lower case letters.
+var letters = map[rune]string{
+	// Latin.
+	'à': "a", 'á': "a", 'â': "a", 'ã': "a", 'ä': "a", 'å': "a", 'ā': "a", 'ă': "a", 'ą': "a",
+	'æ': "ae", 'ç': "c", 'ć': "c", 'č': "c", 'ď': "d", 'đ': "d", 'ð': "d",
+	'è': "e", 'é': "e", 'ê': "e", 'ë': "e", 'ē': "e", 'ė': "e", 'ę': "e", 'ě': "e",
+	'ğ': "g", 'ì': "i", 'í': "i", 'î': "i", 'ï': "i", 'ī': "i", 'ı': "i",
+	'ł': "l", 'ľ': "l", 'ñ': "n", 'ń': "n", 'ň': "n",
+	'ò': "o", 'ó': "o", 'ô': "o", 'õ': "o", 'ö': "o", 'ø': "o", 'ō': "o", 'ő': "o", 'œ': "oe",
+	'ř': "r", 'ś': "s", 'š': "s", 'ş': "s", 'ß': "ss", 'ť': "t", 'ţ': "t", 'þ': "th",
+	'ù': "u", 'ú': "u", 'û': "u", 'ü': "u", 'ū': "u", 'ů': "u", 'ű': "u",
+	'ý': "y", 'ÿ': "y", 'ź': "z", 'ż': "z", 'ž': "z",
+	// Cyrillic.
+	'а': "a", 'б': "b", 'в': "v", 'г': "g", 'д': "d", 'е': "e", 'ё': "e", 'ж': "zh",
+	'з': "z", 'и': "i", 'й': "y", 'к': "k", 'л': "l", 'м': "m", 'н': "n", 'о': "o",
+	'п': "p", 'р': "r", 'с': "s", 'т': "t", 'у': "u", 'ф': "f", 'х': "kh", 'ц': "ts",
+	'ч': "ch", 'ш': "sh", 'щ': "shch", 'ъ': "", 'ы': "y", 'ь': "", 'э': "e", 'ю': "yu",
+	'я': "ya", 'і': "i", 'ї': "yi", 'є': "ye", 'ґ': "g", 'ў': "u",
+	// Greek.
+	'α': "a", 'β': "v", 'γ': "g", 'δ': "d", 'ε': "e", 'ζ': "z", 'η': "i", 'θ': "th",
+	'ι': "i", 'κ': "k", 'λ': "l", 'μ': "m", 'ν': "n", 'ξ': "x", 'ο': "o", 'π': "p",
+	'ρ': "r", 'σ': "s", 'ς': "s", 'τ': "t", 'υ': "y", 'φ': "f", 'χ': "ch", 'ψ': "ps",
+	'ω': "o", 'ά': "a", 'έ': "e", 'ή': "i", 'ί': "i", 'ό': "o", 'ύ': "y", 'ώ': "o",
+}
+
+// kana are the romanizations of hiragana.
+var kana = map[rune]string{
+	'あ': "a", 'い': "i", 'う': "u", 'え': "e", 'お': "o",
+	'か': "ka", 'き': "ki", 'く': "ku", 'け': "ke", 'こ': "ko",
+	'が': "ga", 'ぎ': "gi", 'ぐ': "gu", 'げ': "ge", 'ご': "go",
+	'さ': "sa", 'し': "shi", 'す': "su", 'せ': "se", 'そ': "so",
+	'ざ': "za", 'じ': "ji", 'ず': "zu", 'ぜ': "ze", 'ぞ': "zo",
+	'た': "ta", 'ち': "chi", 'つ': "tsu", 'て': "te", 'と': "to",
+	'だ': "da", 'ぢ': "ji", 'づ': "zu", 'で': "de", 'ど': "do",
+	'な': "na", 'に': "ni", 'ぬ': "nu", 'ね': "ne", 'の': "no",
+	'は': "ha", 'ひ': "hi", 'ふ': "fu", 'へ': "he", 'ほ': "ho",
+	'ば': "ba", 'び': "bi", 'ぶ': "bu", 'べ': "be", 'ぼ': "bo",
+	'ぱ': "pa", 'ぴ': "pi", 'ぷ': "pu", 'ぺ': "pe", 'ぽ': "po",
+	'ま': "ma", 'み': "mi", 'む': "mu", 'め': "me", 'も': "mo",
+	'や': "ya", 'ゆ': "yu", 'よ': "yo",
+	'ら': "ra", 'り': "ri", 'る': "ru", 'れ': "re", 'ろ': "ro",
+	'わ': "wa", 'を': "o", 'ん': "n", 'ゔ': "vu",
+	'ぁ': "a", 'ぃ': "i", 'ぅ': "u", 'ぇ': "e", 'ぉ': "o",
+}
+
+// kanaBase returns the hiragana of kana r (hiragana or katakana).
+func kanaBase(r rune) (rune, bool) {
+	if r >= 'ァ' && r <= 'ヶ' {
+		r -= 'ァ' - 'ぁ'
+	}
+	switch r {
+	case 'っ', 'ゃ', 'ゅ', 'ょ', 'ー':
+		return r, true
+	}
+	_, ok := kana[r]
+	return r, ok
+}
+
+// romanizeKana romanizes the kana k that starts rs, and returns the
+// romanization and the number of runes of rs it covers: small ya, yu and
+// yo combine with the kana before them (e.g. きゃ is kya), small tsu
+// doubles the consonant after it, and the long vowel mark is dropped.
+func romanizeKana(rs []rune, k rune) (string, int) {
+	switch k {
+	case 'ー':
+		return "", 1
+	case 'ゃ', 'ゅ', 'ょ':
+		return kana[k+1], 1
+	case 'っ':
+		if len(rs) > 1 {
+			if next, ok := kanaBase(rs[1]); ok {
+				if t, n := romanizeKana(rs[1:], next); t != "" {
+					return t[:1] + t, n + 1
+				}
+			}
+		}
+		return "", 1
+	}
+	t := kana[k]
+	if len(rs) > 1 && strings.HasSuffix(t, "i") && len(t) > 1 {
+		if small, ok := kanaBase(rs[1]); ok && (small == 'ゃ' || small == 'ゅ' || small == 'ょ') {
+			vowel := kana[small+1][1:]
+			switch t {
+			case "shi", "chi", "ji":
+				return t[:len(t)-1] + vowel, 2
+			}
+			return t[:len(t)-1] + "y" + vowel, 2
+		}
+	}
+	return t, 1
+}
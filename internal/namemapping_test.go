@@ -0,0 +1,64 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package internal
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTransliterate(t *testing.T) {
+	tests := []struct {
+		in, out string
+	}{
+		{"name", "name"},
+		{"Пользователи", "Polzovateli"},
+		{"имя_пользователя", "imya_polzovatelya"},
+		{"Straße", "Strasse"},
+		{"Ελλάδα", "Ellada"},
+		{"ユーザー", "yuza"},
+		{"きょう", "kyou"},
+		{"しゃしん", "shashin"},
+		{"ざっし", "zasshi"},
+		{"用户id", "U7528U6237id"},
+	}
+	for _, tc := range tests {
+		assert.Equal(t, tc.out, transliterate(tc.in), tc.in)
+	}
+}
+
+func TestNameMapping(t *testing.T) {
+	m := NameMapping{Dictionary: map[string]string{"用户": "user", "用": "use", "表": "table"}}
+	assert.Equal(t, "user表", NameMapping{Dictionary: map[string]string{"用户": "user"}}.apply("用户表"))
+	assert.Equal(t, "usertable_use", m.apply("用户table_用"))
+	m.Mode = TransliterateNames
+	assert.Equal(t, "user_U540D", m.apply("用户_名"))
+
+	conv := MakeConv()
+	conv.NameMapping = NameMapping{Mode: TransliterateNames}
+	spTable, err := GetSpannerTable(conv, "заказы")
+	assert.Nil(t, err)
+	assert.Equal(t, "zakazy", spTable)
+	spCol, err := GetSpannerCol(conv, "заказы", "Сумма", false)
+	assert.Nil(t, err)
+	assert.Equal(t, "Summa", spCol)
+	assert.Equal(t, "it was transliterated: Spanner names can only contain ASCII letters, digits and underscores", conv.renameReason("заказы", spTable))
+	conv.NameMapping = NameMapping{}
+	assert.Equal(t, "Spanner names must start with a letter, and can only contain letters, digits and underscores", conv.renameReason("заказы", "A_____"))
+
+	_, err = ParseNameMappingMode("keep-unicode")
+	assert.NotNil(t, err)
+}
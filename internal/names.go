@@ -0,0 +1,103 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package internal
+
+import (
+	"strings"
+)
+
+// SpannerNames records the Spanner names given to foreign keys and
+// indexes. Spanner uses a single namespace for tables, foreign keys and
+// indexes, so names are allocated uniquely across all of them (see
+// ToSpannerIndexName). Allocations are recorded by source object and
+// saved in the session file: converting the schema again, e.g. after
+// edits in the web interface, gives each foreign key and index the name
+// it had before, regardless of the objects added or removed since.
+type SpannerNames struct {
+	Used      map[string]bool   // Spanner names of tables, foreign keys and indexes.
+	Allocated map[string]string // Maps a source foreign key or index (see nameKey) to its Spanner name.
+}
+
+// nameKey identifies a foreign key or index of source table srcTable.
+// Source names aren't unique (e.g. unnamed indexes), so the key also
+// includes the columns of the object.
+func nameKey(kind, srcTable, srcName string, srcCols []string) string {
+	return kind + ":" + srcTable + ":" + srcName + ":" + strings.Join(srcCols, ",")
+}
+
+// ReserveName records that Spanner name is used, e.g. by a table, so that
+// foreign keys and indexes don't get it.
+func (conv *Conv) ReserveName(name string) {
+	conv.schemaMu.Lock()
+	defer conv.schemaMu.Unlock()
+	conv.initNames()
+	conv.Names.Used[name] = true
+}
+
+// ForeignKeyName returns the Spanner name of foreign key srcName (on
+// columns srcCols) of source table srcTable, allocating it on first use.
+// Unnamed foreign keys stay unnamed.
+func (conv *Conv) ForeignKeyName(srcTable, srcName string, srcCols []string) string {
+	if srcName == "" {
+		return ""
+	}
+	return conv.allocateName(nameKey("fk", srcTable, srcName, srcCols), srcName)
+}
+
+// IndexName returns the Spanner name of index srcName (on columns
+// srcCols) of source table srcTable, allocating it on first use.
+func (conv *Conv) IndexName(srcTable, srcName string, srcCols []string) string {
+	return conv.allocateName(nameKey("index", srcTable, srcName, srcCols), srcName)
+}
+
+// RenameSpannerName records that the foreign key or index named old in
+// Spanner was renamed to new (e.g. in the web interface), so that it
+// keeps its new name when the schema is converted again.
+func (conv *Conv) RenameSpannerName(old, new string) {
+	conv.schemaMu.Lock()
+	defer conv.schemaMu.Unlock()
+	conv.initNames()
+	for k, n := range conv.Names.Allocated {
+		if n == old {
+			conv.Names.Allocated[k] = new
+		}
+	}
+	delete(conv.Names.Used, old)
+	conv.Names.Used[new] = true
+}
+
+func (conv *Conv) allocateName(key, srcName string) string {
+	conv.schemaMu.Lock()
+	defer conv.schemaMu.Unlock()
+	conv.initNames()
+	if n, ok := conv.Names.Allocated[key]; ok {
+		conv.Names.Used[n] = true
+		return n
+	}
+	n := getSpannerId(conv.NameMapping.apply(srcName), conv.Names.Used)
+	conv.Names.Allocated[key] = n
+	return n
+}
+
+// initNames initializes the name state of Convs read from session files
+// that predate it.
+func (conv *Conv) initNames() {
+	if conv.Names.Used == nil {
+		conv.Names.Used = make(map[string]bool)
+	}
+	if conv.Names.Allocated == nil {
+		conv.Names.Allocated = make(map[string]string)
+	}
+}
@@ -0,0 +1,63 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package internal
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNames(t *testing.T) {
+	conv := MakeConv()
+	conv.ReserveName("t")
+	assert.Equal(t, "t_1", conv.IndexName("t", "t", []string{"a"}))
+	assert.Equal(t, "idx", conv.IndexName("t", "idx", []string{"a"}))
+	// Same source name, different columns.
+	assert.Equal(t, "idx_3", conv.IndexName("u", "idx", []string{"b"}))
+	assert.Equal(t, "fk", conv.ForeignKeyName("t", "fk", []string{"a"}))
+	assert.Equal(t, "", conv.ForeignKeyName("t", "", []string{"a"}))
+	// Allocations are stable.
+	assert.Equal(t, "idx", conv.IndexName("t", "idx", []string{"a"}))
+	assert.Equal(t, "idx_3", conv.IndexName("u", "idx", []string{"b"}))
+}
+
+func TestNamesPersisted(t *testing.T) {
+	conv := MakeConv()
+	conv.ReserveName("t")
+	assert.Equal(t, "idx", conv.IndexName("t", "idx", []string{"a"}))
+	assert.Equal(t, "idx_2", conv.IndexName("u", "idx", []string{"b"}))
+	conv.RenameSpannerName("idx_2", "u_idx")
+
+	// Save and restore the names, as the session file does, then convert
+	// again with a new index that comes first.
+	b, err := json.Marshal(conv.Names)
+	assert.Nil(t, err)
+	conv2 := MakeConv()
+	assert.Nil(t, json.Unmarshal(b, &conv2.Names))
+	conv2.ReserveName("t")
+	assert.Equal(t, "idx_3", conv2.IndexName("a", "idx", []string{"c"}))
+	assert.Equal(t, "idx", conv2.IndexName("t", "idx", []string{"a"}))
+	assert.Equal(t, "u_idx", conv2.IndexName("u", "idx", []string{"b"}))
+	// The old name is free again.
+	assert.Equal(t, "idx_2", conv2.ForeignKeyName("t", "idx_2", []string{"a"}))
+}
+
+func TestNamesOldSession(t *testing.T) {
+	conv := &Conv{}
+	assert.Equal(t, "idx", conv.IndexName("t", "idx", []string{"a"}))
+	assert.Equal(t, "idx_1", conv.IndexName("t", "idx", []string{"b"}))
+}
@@ -0,0 +1,254 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package internal
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"cloud.google.com/go/civil"
+	"github.com/cloudspannerecosystem/harbourbridge/spanner/ddl"
+)
+
+// NotNullPolicy specifies how data conversion handles NULL values for
+// Spanner columns that are NOT NULL. This happens when a nullable source
+// column is mapped to a NOT NULL column (e.g. by editing the schema), and
+// Spanner rejects such rows, failing the batch they are written in.
+type NotNullPolicy int
+
+const (
+	// IgnoreNotNull doesn't check NOT NULL columns during data conversion.
+	IgnoreNotNull NotNullPolicy = iota
+	// DefaultNotNull writes the default value of the column's type
+	// (zero, empty string, false, 1970-01-01 etc.) instead of NULL.
+	DefaultNotNull
+	// DropNotNull drops rows with NULL values for NOT NULL columns
+	// (they are reported as bad rows and are not written to Spanner).
+	DropNotNull
+	// RelaxNotNull removes the NOT NULL constraint from Spanner columns
+	// whose source column is nullable, before the database is created.
+	// Primary key columns can't be relaxed: rows with NULL values for
+	// them are dropped.
+	RelaxNotNull
+)
+
+var notNullPolicyNames = map[NotNullPolicy]string{
+	IgnoreNotNull:  "ignore",
+	DefaultNotNull: "default",
+	DropNotNull:    "drop",
+	RelaxNotNull:   "relax",
+}
+
+func (p NotNullPolicy) String() string {
+	if s, ok := notNullPolicyNames[p]; ok {
+		return s
+	}
+	return fmt.Sprintf("NotNullPolicy(%d)", int(p))
+}
+
+// ParseNotNullPolicy maps a policy name (as used on the command line)
+// to a NotNullPolicy.
+func ParseNotNullPolicy(s string) (NotNullPolicy, error) {
+	for p, name := range notNullPolicyNames {
+		if strings.ToLower(s) == name {
+			return p, nil
+		}
+	}
+	return IgnoreNotNull, fmt.Errorf("unknown NOT NULL policy %q (accepted values are \"ignore\", \"default\", \"drop\" and \"relax\")", s)
+}
+
+// ParseNotNullPolicies parses a comma-separated list of NOT NULL
+// policies. An entry of the form 'table.column=policy' sets the policy
+// for a Spanner column; an entry without '=' sets the policy for all
+// other columns. For example, "drop,orders.note=default".
+func ParseNotNullPolicies(s string) (NotNullPolicy, map[string]NotNullPolicy, error) {
+	policy := IgnoreNotNull
+	cols := make(map[string]NotNullPolicy)
+	for _, e := range strings.Split(s, ",") {
+		e = strings.TrimSpace(e)
+		if e == "" {
+			continue
+		}
+		i := strings.LastIndex(e, "=")
+		if i < 0 {
+			p, err := ParseNotNullPolicy(e)
+			if err != nil {
+				return IgnoreNotNull, nil, err
+			}
+			policy = p
+			continue
+		}
+		col := e[:i]
+		if !strings.Contains(col, ".") {
+			return IgnoreNotNull, nil, fmt.Errorf("bad NOT NULL policy %q: column must be given as table.column", e)
+		}
+		p, err := ParseNotNullPolicy(e[i+1:])
+		if err != nil {
+			return IgnoreNotNull, nil, err
+		}
+		cols[col] = p
+	}
+	return policy, cols, nil
+}
+
+// notNullPolicy returns the NOT NULL policy for a Spanner column.
+func (conv *Conv) notNullPolicy(spTable, spCol string) NotNullPolicy {
+	if p, ok := conv.Policies.NotNullColumns[spTable+"."+spCol]; ok {
+		return p
+	}
+	return conv.Policies.NotNull
+}
+
+// RelaxNotNull applies RelaxNotNull policies to the Spanner schema: it
+// removes the NOT NULL constraint from non-key columns whose source
+// column is nullable. It must be called before the database is created.
+func (conv *Conv) RelaxNotNull() {
+	for srcTable, nc := range conv.ToSpanner {
+		ct, ok := conv.SpSchema[nc.Name]
+		if !ok {
+			continue
+		}
+		srcSchema := conv.SrcSchema[srcTable]
+		for srcCol, spCol := range nc.Cols {
+			cd, ok := ct.ColDefs[spCol]
+			if !ok || !cd.NotNull || srcSchema.ColDefs[srcCol].NotNull || conv.notNullPolicy(ct.Name, spCol) != RelaxNotNull || isKeyCol(ct, spCol) {
+				continue
+			}
+			cd.NotNull = false
+			ct.ColDefs[spCol] = cd
+			if conv.Stats.NotNullRelaxed == nil {
+				conv.Stats.NotNullRelaxed = make(map[string][]string)
+			}
+			conv.Stats.NotNullRelaxed[srcTable] = append(conv.Stats.NotNullRelaxed[srcTable], spCol)
+		}
+		sort.Strings(conv.Stats.NotNullRelaxed[srcTable])
+	}
+}
+
+func isKeyCol(ct ddl.CreateTable, col string) bool {
+	for _, k := range ct.Pks {
+		if k.Col == col {
+			return true
+		}
+	}
+	return false
+}
+
+// applyNotNullPolicy checks a row for NULL values in NOT NULL columns
+// (NULL values are either missing from spCols, or nil), and applies the
+// NOT NULL policy of each such column. It returns the columns and values
+// to write, and false if the row should not be written.
+func (conv *Conv) applyNotNullPolicy(srcTable, spTable string, spCols []string, spVals []interface{}) ([]string, []interface{}, bool) {
+	ct := conv.SpSchema[spTable]
+	present := make(map[string]bool)
+	for i, c := range spCols {
+		if spVals[i] != nil {
+			present[c] = true
+		}
+	}
+	cols, vals := spCols, spVals
+	copied := false
+	for _, c := range ct.ColNames {
+		cd := ct.ColDefs[c]
+		if !cd.NotNull || present[c] {
+			continue
+		}
+		p := conv.notNullPolicy(spTable, c)
+		if p == IgnoreNotNull {
+			continue
+		}
+		conv.statsAddNotNull(srcTable, c)
+		v, ok := defaultValue(cd.T)
+		if p != DefaultNotNull || !ok {
+			return nil, nil, false
+		}
+		if !copied {
+			// Don't modify the caller's slices.
+			cols, vals = nil, nil
+			for i := range spCols {
+				if spVals[i] != nil {
+					cols = append(cols, spCols[i])
+					vals = append(vals, spVals[i])
+				}
+			}
+			copied = true
+		}
+		cols = append(cols, c)
+		vals = append(vals, v)
+	}
+	return cols, vals, true
+}
+
+// defaultValue returns the value written for NULL values of a NOT NULL
+// column of type t by DefaultNotNull.
+func defaultValue(t ddl.Type) (interface{}, bool) {
+	if t.IsArray {
+		switch t.Name {
+		case ddl.Bool:
+			return []bool{}, true
+		case ddl.Bytes:
+			return [][]byte{}, true
+		case ddl.Date:
+			return []civil.Date{}, true
+		case ddl.Float32, ddl.Float64:
+			return []float64{}, true
+		case ddl.Int64:
+			return []int64{}, true
+		case ddl.String, ddl.Numeric, ddl.JSON:
+			return []string{}, true
+		case ddl.Timestamp:
+			return []time.Time{}, true
+		}
+		return nil, false
+	}
+	switch t.Name {
+	case ddl.Bool:
+		return false, true
+	case ddl.Bytes:
+		return []byte{}, true
+	case ddl.Date:
+		return civil.Date{Year: 1970, Month: time.January, Day: 1}, true
+	case ddl.Float32, ddl.Float64:
+		return float64(0), true
+	case ddl.Int64:
+		return int64(0), true
+	case ddl.Numeric:
+		// Numerics are written as strings (see postgres.convNumeric).
+		return "0", true
+	case ddl.JSON:
+		return "null", true
+	case ddl.String:
+		return "", true
+	case ddl.Timestamp:
+		return time.Unix(0, 0).UTC(), true
+	}
+	return nil, false
+}
+
+// statsAddNotNull increments the NOT NULL violation stats for 'srcTable'
+// and 'spCol'. Only called in data mode (from WriteRow).
+func (conv *Conv) statsAddNotNull(srcTable, spCol string) {
+	conv.statsMu.Lock()
+	defer conv.statsMu.Unlock()
+	if conv.Stats.NotNull == nil {
+		conv.Stats.NotNull = make(map[string]map[string]int64)
+	}
+	if conv.Stats.NotNull[srcTable] == nil {
+		conv.Stats.NotNull[srcTable] = make(map[string]int64)
+	}
+	conv.Stats.NotNull[srcTable][spCol]++
+}
@@ -0,0 +1,130 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package internal
+
+import (
+	"testing"
+
+	"github.com/cloudspannerecosystem/harbourbridge/schema"
+	"github.com/cloudspannerecosystem/harbourbridge/spanner/ddl"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWriteRow_NotNull(t *testing.T) {
+	for _, tc := range []struct {
+		name     string
+		policy   NotNullPolicy
+		cols     map[string]NotNullPolicy
+		wantCols []string
+		wantVals []interface{}
+		good     int64
+		bad      int64
+		stats    map[string]int64
+	}{
+		{"ignore", IgnoreNotNull, nil, []string{"k", "a", "b"}, []interface{}{int64(1), nil, int64(2)}, 1, 0, nil},
+		{"default", DefaultNotNull, nil, []string{"k", "b", "a"}, []interface{}{int64(1), int64(2), ""}, 1, 0, map[string]int64{"a": 1}},
+		{"drop", DropNotNull, nil, nil, nil, 0, 1, map[string]int64{"a": 1}},
+		{"per-column", DropNotNull, map[string]NotNullPolicy{"t.a": DefaultNotNull}, []string{"k", "b", "a"}, []interface{}{int64(1), int64(2), ""}, 1, 0, map[string]int64{"a": 1}},
+		{"per-column ignore", DropNotNull, map[string]NotNullPolicy{"t.a": IgnoreNotNull}, []string{"k", "a", "b"}, []interface{}{int64(1), nil, int64(2)}, 1, 0, nil},
+	} {
+		conv := MakeConv()
+		conv.SrcSchema["src"] = schema.Table{
+			Name:     "src",
+			ColNames: []string{"k", "a", "b"},
+			ColDefs: map[string]schema.Column{
+				"k": schema.Column{Name: "k", NotNull: true},
+				"a": schema.Column{Name: "a"},
+				"b": schema.Column{Name: "b"},
+			}}
+		conv.SpSchema["t"] = ddl.CreateTable{
+			Name:     "t",
+			ColNames: []string{"k", "a", "b"},
+			ColDefs: map[string]ddl.ColumnDef{
+				"k": ddl.ColumnDef{Name: "k", T: ddl.Type{Name: ddl.Int64}, NotNull: true},
+				"a": ddl.ColumnDef{Name: "a", T: ddl.Type{Name: ddl.String, Len: ddl.MaxLength}, NotNull: true},
+				"b": ddl.ColumnDef{Name: "b", T: ddl.Type{Name: ddl.Int64}, NotNull: true},
+			},
+			Pks: []ddl.IndexKey{ddl.IndexKey{Col: "k"}}}
+		conv.ToSpanner["src"] = NameAndCols{Name: "t", Cols: map[string]string{"k": "k", "a": "a", "b": "b"}}
+		conv.Policies.NotNull = tc.policy
+		conv.Policies.NotNullColumns = tc.cols
+		conv.SetDataMode()
+		var gotCols []string
+		var gotVals []interface{}
+		conv.SetDataSink(func(table string, cols []string, vals []interface{}) {
+			gotCols, gotVals = cols, vals
+		})
+		conv.WriteRow("src", "t", []string{"k", "a", "b"}, []interface{}{int64(1), nil, int64(2)})
+		assert.Equal(t, tc.wantCols, gotCols, tc.name)
+		assert.Equal(t, tc.wantVals, gotVals, tc.name)
+		assert.Equal(t, tc.good, conv.Stats.GoodRows["src"], tc.name)
+		assert.Equal(t, tc.bad, conv.Stats.BadRows["src"], tc.name)
+		assert.Equal(t, tc.stats, conv.Stats.NotNull["src"], tc.name)
+	}
+}
+
+func TestRelaxNotNull(t *testing.T) {
+	conv := MakeConv()
+	conv.SrcSchema["src"] = schema.Table{
+		Name:     "src",
+		ColNames: []string{"k", "a", "b"},
+		ColDefs: map[string]schema.Column{
+			"k": schema.Column{Name: "k", NotNull: true},
+			"a": schema.Column{Name: "a"},
+			"b": schema.Column{Name: "b"},
+		}}
+	conv.SpSchema["t"] = ddl.CreateTable{
+		Name:     "t",
+		ColNames: []string{"k", "a", "b"},
+		ColDefs: map[string]ddl.ColumnDef{
+			"k": ddl.ColumnDef{Name: "k", T: ddl.Type{Name: ddl.Int64}, NotNull: true},
+			"a": ddl.ColumnDef{Name: "a", T: ddl.Type{Name: ddl.String, Len: ddl.MaxLength}, NotNull: true},
+			"b": ddl.ColumnDef{Name: "b", T: ddl.Type{Name: ddl.Int64}, NotNull: true},
+		},
+		Pks: []ddl.IndexKey{ddl.IndexKey{Col: "k"}}}
+	conv.ToSpanner["src"] = NameAndCols{Name: "t", Cols: map[string]string{"k": "k", "a": "a", "b": "b"}}
+	conv.Policies.NotNull = RelaxNotNull
+	conv.Policies.NotNullColumns = map[string]NotNullPolicy{"t.b": DropNotNull}
+	conv.RelaxNotNull()
+	ct := conv.SpSchema["t"]
+	assert.True(t, ct.ColDefs["k"].NotNull)
+	assert.False(t, ct.ColDefs["a"].NotNull)
+	assert.True(t, ct.ColDefs["b"].NotNull)
+	assert.Equal(t, []string{"a"}, conv.Stats.NotNullRelaxed["src"])
+
+	// Key columns can't be relaxed, so NULL keys are dropped.
+	conv.SetDataMode()
+	conv.SetDataSink(func(table string, cols []string, vals []interface{}) {})
+	conv.WriteRow("src", "t", []string{"a", "b"}, []interface{}{"x", int64(2)})
+	assert.Equal(t, int64(1), conv.Stats.BadRows["src"])
+	assert.Equal(t, map[string]int64{"k": 1}, conv.Stats.NotNull["src"])
+}
+
+func TestParseNotNullPolicies(t *testing.T) {
+	p, cols, err := ParseNotNullPolicies("drop, orders.note=default,orders.x=relax")
+	assert.Nil(t, err)
+	assert.Equal(t, DropNotNull, p)
+	assert.Equal(t, map[string]NotNullPolicy{"orders.note": DefaultNotNull, "orders.x": RelaxNotNull}, cols)
+
+	p, cols, err = ParseNotNullPolicies("ignore")
+	assert.Nil(t, err)
+	assert.Equal(t, IgnoreNotNull, p)
+	assert.Equal(t, map[string]NotNullPolicy{}, cols)
+
+	for _, s := range []string{"nullify", "note=default", "orders.note=zero"} {
+		_, _, err = ParseNotNullPolicies(s)
+		assert.NotNil(t, err, s)
+	}
+}
@@ -0,0 +1,197 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package internal
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/cloudspannerecosystem/harbourbridge/spanner/ddl"
+)
+
+// Ordering specifies the order of tables, columns, indexes and foreign
+// keys in the generated DDL and reports. Both orderings are
+// deterministic, so converting the same schema twice produces the same
+// files.
+type Ordering int
+
+const (
+	// NameOrder lists tables in alphabetical order, and the columns of
+	// a table in the report, its indexes and its foreign keys in
+	// alphabetical order. Interleaved tables are listed after their
+	// parent.
+	NameOrder Ordering = iota
+	// SourceOrder lists tables, columns, indexes and foreign keys in the
+	// order they are defined in the source database. Tables added by
+	// HarbourBridge (e.g. split or overflow tables) are listed after the
+	// table they are derived from. For direct connections to a database,
+	// tables are listed in the order the database returns them.
+	SourceOrder
+)
+
+var orderingNames = map[Ordering]string{
+	NameOrder:   "name",
+	SourceOrder: "source",
+}
+
+func (o Ordering) String() string {
+	if s, ok := orderingNames[o]; ok {
+		return s
+	}
+	return fmt.Sprintf("Ordering(%d)", int(o))
+}
+
+// ParseOrdering maps an ordering name (as used on the command line) to an
+// Ordering.
+func ParseOrdering(s string) (Ordering, error) {
+	for o, name := range orderingNames {
+		if strings.ToLower(s) == name {
+			return o, nil
+		}
+	}
+	return NameOrder, fmt.Errorf("unknown ordering %q (accepted values are \"name\" and \"source\")", s)
+}
+
+// SrcTables returns the names of the source tables, ordered according to
+// conv.Ordering.
+func (conv *Conv) SrcTables() []string {
+	var tables []string
+	seen := make(map[string]bool)
+	if conv.Ordering == SourceOrder {
+		for _, t := range conv.SrcOrder {
+			if _, ok := conv.SrcSchema[t]; ok && !seen[t] {
+				tables = append(tables, t)
+				seen[t] = true
+			}
+		}
+	}
+	var rest []string
+	for t := range conv.SrcSchema {
+		if !seen[t] {
+			rest = append(rest, t)
+		}
+	}
+	sort.Strings(rest)
+	return append(tables, rest...)
+}
+
+// SpTables returns the names of the Spanner tables, ordered according to
+// conv.Ordering (except that GetDDL moves interleaved tables after their
+// parent).
+func (conv *Conv) SpTables() []string {
+	var tables []string
+	seen := make(map[string]bool)
+	add := func(t string) {
+		if _, ok := conv.SpSchema[t]; ok && !seen[t] {
+			tables = append(tables, t)
+			seen[t] = true
+		}
+	}
+	if conv.Ordering == SourceOrder {
+		for _, srcTable := range conv.SrcTables() {
+			spTable := conv.ToSpanner[srcTable].Name
+			add(spTable)
+			for _, s := range conv.Splits[spTable] {
+				add(s.NewTable)
+			}
+			if ot, ok := conv.OverflowTables[spTable]; ok {
+				add(ot)
+			}
+		}
+	}
+	var rest []string
+	for t := range conv.SpSchema {
+		if !seen[t] {
+			rest = append(rest, t)
+		}
+	}
+	sort.Strings(rest)
+	return append(tables, rest...)
+}
+
+// GetDDL returns the DDL statements of conv.SpSchema (see ddl.Schema.GetDDL),
+// with tables, indexes and foreign keys ordered according to conv.Ordering.
+// Sequences (see AddSequences) are printed first, with the tables.
+func (conv *Conv) GetDDL(c ddl.Config) []string {
+	var l []string
+	for _, st := range conv.GetStatements(c) {
+		l = append(l, st.DDL)
+	}
+	return l
+}
+
+// GetStatements returns the DDL statements of conv.SpSchema, one per schema
+// object (see ddl.Schema.GetStatements), ordered like GetDDL. Spilled
+// tables (see SpillTo) are loaded one at a time, in the order of their
+// source tables.
+func (conv *Conv) GetStatements(c ddl.Config) []ddl.Statement {
+	c.Order = conv.SpTables()
+	c.SortConstraints = conv.Ordering == NameOrder
+	var l []ddl.Statement
+	if c.Tables {
+		for _, s := range conv.SpSequences() {
+			l = append(l, ddl.Statement{Kind: ddl.SequenceStatement, Name: s.Name, DDL: s.PrintCreateSequence(c)})
+		}
+	}
+	if conv.spill == nil {
+		return append(l, conv.SpSchema.GetStatements(c)...)
+	}
+	// Foreign keys come after all tables, as for unspilled schemas.
+	tables, fks := c, c
+	tables.ForeignKeys, fks.Tables = false, false
+	for _, pass := range []ddl.Config{tables, fks} {
+		if !pass.Tables && !pass.ForeignKeys {
+			continue
+		}
+		// Spilled tables aren't interleaved (see SpillTo), so each table
+		// can be printed on its own.
+		pass.Order = nil
+		err := conv.ForEachSpilled(false, func(srcTable string) error {
+			l = append(l, conv.SpSchema.GetStatements(pass)...)
+			return nil
+		})
+		if err != nil {
+			conv.Unexpected(fmt.Sprintf("Can't print spilled tables: %s", err))
+		}
+	}
+	return l
+}
+
+// orderCols orders cols, columns of srcTable (or the Spanner columns they
+// are mapped to), according to conv.Ordering. Columns that aren't in the
+// source schema of srcTable are listed last, in alphabetical order.
+func (conv *Conv) orderCols(srcTable string, cols []string) {
+	pos := make(map[string]int)
+	if conv.Ordering == SourceOrder {
+		for i, c := range conv.SrcSchema[srcTable].ColNames {
+			pos[c] = i + 1
+			if spCol, ok := conv.ToSpanner[srcTable].Cols[c]; ok && pos[spCol] == 0 {
+				pos[spCol] = i + 1
+			}
+		}
+	}
+	sort.Slice(cols, func(i, j int) bool {
+		pi, pj := pos[cols[i]], pos[cols[j]]
+		switch {
+		case pi != 0 && pj != 0:
+			return pi < pj
+		case pi != 0 || pj != 0:
+			return pi != 0
+		default:
+			return cols[i] < cols[j]
+		}
+	})
+}
@@ -0,0 +1,56 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package internal
+
+import (
+	"testing"
+
+	"github.com/cloudspannerecosystem/harbourbridge/schema"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestOrdering(t *testing.T) {
+	conv := remodelTestConv()
+	// remodelTestConv adds users before settings.
+	conv.SrcOrder = []string{"users", "settings"}
+	assert.Nil(t, conv.ApplyRemodel(Remodel{Splits: []SplitTable{{Table: "users", NewTable: "a_media", Cols: []string{"photo"}}}}))
+	cols := []string{"photo", "id", "bio", "zzz"}
+
+	assert.Equal(t, []string{"settings", "users"}, conv.SrcTables())
+	assert.Equal(t, []string{"a_media", "settings", "users"}, conv.SpTables())
+	conv.orderCols("users", cols)
+	assert.Equal(t, []string{"bio", "id", "photo", "zzz"}, cols)
+
+	conv.Ordering = SourceOrder
+	assert.Equal(t, []string{"users", "settings"}, conv.SrcTables())
+	assert.Equal(t, []string{"users", "a_media", "settings"}, conv.SpTables())
+	conv.orderCols("users", cols)
+	assert.Equal(t, []string{"id", "bio", "photo", "zzz"}, cols)
+
+	// SetSrcTable records the order of tables. Tables missing from
+	// SrcOrder are listed last, in alphabetical order.
+	conv.SrcSchema["a"] = schema.Table{Name: "a"}
+	conv.SetSrcTable(schema.Table{Name: "c"})
+	conv.SrcSchema["b"] = schema.Table{Name: "b"}
+	assert.Equal(t, []string{"users", "settings", "c", "a", "b"}, conv.SrcTables())
+}
+
+func TestParseOrdering(t *testing.T) {
+	o, err := ParseOrdering("Source")
+	assert.Nil(t, err)
+	assert.Equal(t, SourceOrder, o)
+	_, err = ParseOrdering("random")
+	assert.NotNil(t, err)
+}
@@ -0,0 +1,241 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package internal
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/cloudspannerecosystem/harbourbridge/spanner/ddl"
+)
+
+// OrphanPolicy specifies how data conversion handles orphaned rows:
+// rows whose foreign key doesn't match any row of the referenced table.
+// Spanner can't add a foreign key to a table with orphaned rows, so
+// unless they are fixed, the foreign key can't be created after data
+// conversion.
+//
+// Checking foreign keys requires keeping the referenced key values of
+// every row in memory, as well as rows whose referenced rows haven't
+// been seen yet (dumps don't order tables by foreign key dependencies).
+type OrphanPolicy int
+
+const (
+	// IgnoreOrphans doesn't check foreign keys during data conversion.
+	IgnoreOrphans OrphanPolicy = iota
+	// LoadOrphans checks foreign keys and counts orphaned rows, but
+	// writes them anyway.
+	LoadOrphans
+	// DropOrphans drops orphaned rows (they are reported as bad rows
+	// and are not written to Spanner).
+	DropOrphans
+	// NullOrphans writes NULL in the foreign key columns of orphaned
+	// rows. Rows where these columns can't be NULL are dropped.
+	NullOrphans
+)
+
+var orphanPolicyNames = map[OrphanPolicy]string{
+	IgnoreOrphans: "ignore",
+	LoadOrphans:   "load",
+	DropOrphans:   "drop",
+	NullOrphans:   "null",
+}
+
+func (p OrphanPolicy) String() string {
+	if s, ok := orphanPolicyNames[p]; ok {
+		return s
+	}
+	return fmt.Sprintf("OrphanPolicy(%d)", int(p))
+}
+
+// ParseOrphanPolicy maps a policy name (as used on the command line)
+// to an OrphanPolicy.
+func ParseOrphanPolicy(s string) (OrphanPolicy, error) {
+	for p, name := range orphanPolicyNames {
+		if strings.ToLower(s) == name {
+			return p, nil
+		}
+	}
+	return IgnoreOrphans, fmt.Errorf("unknown orphan policy %q (accepted values are \"ignore\", \"load\", \"drop\" and \"null\")", s)
+}
+
+// fkChecker tracks the key values referenced by foreign keys, and the
+// rows waiting for their referenced rows.
+type fkChecker struct {
+	keys     map[string]map[string]bool // Maps referenced table/columns (see refKey) to the set of key values written.
+	refs     map[string][][]string      // Maps Spanner table to the lists of columns of it that are referenced by foreign keys.
+	deferred []deferredRow
+}
+
+// deferredRow is a row that references rows that haven't been written yet.
+type deferredRow struct {
+	srcTable, spTable string
+	cols              []string
+	vals              []interface{}
+	overflow          []overflowRow
+	replace           bool // Row replaces an earlier row with the same primary key (see LastWins).
+}
+
+func (conv *Conv) getFkChecker() *fkChecker {
+	if conv.fks == nil {
+		fc := &fkChecker{keys: make(map[string]map[string]bool), refs: make(map[string][][]string)}
+		for _, t := range conv.SpSchema {
+			for _, fk := range t.Fks {
+				fc.refs[fk.ReferTable] = append(fc.refs[fk.ReferTable], fk.ReferColumns)
+				fc.keys[refKey(fk.ReferTable, fk.ReferColumns)] = make(map[string]bool)
+			}
+		}
+		conv.fks = fc
+	}
+	return conv.fks
+}
+
+// refKey identifies a set of referenced columns of a table.
+func refKey(table string, cols []string) string {
+	return table + "\x00" + strings.Join(cols, "\x00")
+}
+
+// keyVals encodes the values of keyCols in a row as a string, and returns
+// false if any of them are missing (i.e. NULL).
+func keyVals(keyCols, cols []string, vals []interface{}) (string, bool) {
+	var l []interface{}
+	for _, k := range keyCols {
+		found := false
+		for i, c := range cols {
+			if c == k {
+				l = append(l, vals[i])
+				found = true
+				break
+			}
+		}
+		if !found {
+			return "", false
+		}
+	}
+	return encodeKey(l), true
+}
+
+// encodeKey encodes key values as a string, for use in sets of keys.
+func encodeKey(vals []interface{}) string {
+	var l []string
+	for _, v := range vals {
+		l = append(l, fmt.Sprintf("%T:%v", v, v))
+	}
+	return strings.Join(l, "\x00")
+}
+
+// missingRefs returns the foreign keys of spTable whose referenced row
+// hasn't been written. Foreign keys with NULL columns aren't checked.
+func (conv *Conv) missingRefs(spTable string, cols []string, vals []interface{}) []ddl.Foreignkey {
+	fc := conv.getFkChecker()
+	var missing []ddl.Foreignkey
+	for _, fk := range conv.SpSchema[spTable].Fks {
+		v, ok := keyVals(fk.Columns, cols, vals)
+		if ok && !fc.keys[refKey(fk.ReferTable, fk.ReferColumns)][v] {
+			missing = append(missing, fk)
+		}
+	}
+	return missing
+}
+
+// recordKeys records the values of the columns of spTable referenced by
+// foreign keys.
+func (conv *Conv) recordKeys(spTable string, cols []string, vals []interface{}) {
+	fc := conv.getFkChecker()
+	for _, refCols := range fc.refs[spTable] {
+		if v, ok := keyVals(refCols, cols, vals); ok {
+			fc.keys[refKey(spTable, refCols)][v] = true
+		}
+	}
+}
+
+// ResolveOrphans writes the rows that were held back because the rows
+// they reference hadn't been written yet, and applies the orphan policy
+// to the rows whose referenced rows never appeared. It must be called
+// once all data has been processed.
+func (conv *Conv) ResolveOrphans() {
+	conv.rowsMu.Lock()
+	defer conv.rowsMu.Unlock()
+	if conv.fks == nil {
+		return
+	}
+	fc := conv.fks
+	// Writing a row can resolve other rows, so iterate until nothing
+	// changes.
+	for progress := true; progress; {
+		progress = false
+		var remaining []deferredRow
+		for _, r := range fc.deferred {
+			if len(conv.missingRefs(r.spTable, r.cols, r.vals)) == 0 {
+				conv.writeRow(r.srcTable, r.spTable, r.cols, r.vals, r.overflow, r.replace)
+				progress = true
+			} else {
+				remaining = append(remaining, r)
+			}
+		}
+		fc.deferred = remaining
+	}
+	deferred := fc.deferred
+	fc.deferred = nil
+	for _, r := range deferred {
+		missing := conv.missingRefs(r.spTable, r.cols, r.vals)
+		for _, fk := range missing {
+			conv.statsAddOrphan(r.srcTable, fk.Name)
+		}
+		switch conv.Policies.Orphans {
+		case LoadOrphans:
+			conv.writeRow(r.srcTable, r.spTable, r.cols, r.vals, r.overflow, r.replace)
+		case NullOrphans:
+			if cols, vals, ok := conv.nullRefs(r.spTable, missing, r.cols, r.vals); ok {
+				conv.writeRow(r.srcTable, r.spTable, cols, vals, r.overflow, r.replace)
+				continue
+			}
+			fallthrough
+		default:
+			conv.StatsAddBadRow(r.srcTable, conv.DataMode())
+			conv.CollectBadRow(r.srcTable, r.cols, oversizeRowVals(r.vals))
+		}
+	}
+}
+
+// nullRefs removes the columns of the missing foreign keys from a row
+// (i.e. writes them as NULL), and returns false if any of them are NOT
+// NULL or primary key columns.
+func (conv *Conv) nullRefs(spTable string, missing []ddl.Foreignkey, cols []string, vals []interface{}) ([]string, []interface{}, bool) {
+	ct := conv.SpSchema[spTable]
+	drop := make(map[string]bool)
+	for _, fk := range missing {
+		for _, c := range fk.Columns {
+			if ct.ColDefs[c].NotNull {
+				return nil, nil, false
+			}
+			for _, k := range ct.Pks {
+				if k.Col == c {
+					return nil, nil, false
+				}
+			}
+			drop[c] = true
+		}
+	}
+	var newCols []string
+	var newVals []interface{}
+	for i, c := range cols {
+		if !drop[c] {
+			newCols = append(newCols, c)
+			newVals = append(newVals, vals[i])
+		}
+	}
+	return newCols, newVals, true
+}
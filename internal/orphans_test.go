@@ -0,0 +1,88 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package internal
+
+import (
+	"testing"
+
+	"github.com/cloudspannerecosystem/harbourbridge/spanner/ddl"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestResolveOrphans(t *testing.T) {
+	type write struct {
+		table string
+		cols  []string
+		vals  []interface{}
+	}
+	child1 := write{"child", []string{"id", "pid"}, []interface{}{int64(1), int64(10)}}
+	child2 := write{"child", []string{"id", "pid"}, []interface{}{int64(2), int64(20)}}
+	parent := write{"parent", []string{"id"}, []interface{}{int64(10)}}
+	for _, tc := range []struct {
+		policy OrphanPolicy
+		writes []write
+		bad    int64
+	}{
+		// With IgnoreOrphans, rows are written in order and aren't checked.
+		{IgnoreOrphans, []write{child1, child2, parent}, 0},
+		// Otherwise child rows are held back until ResolveOrphans.
+		{LoadOrphans, []write{parent, child1, child2}, 0},
+		{DropOrphans, []write{parent, child1}, 1},
+		{NullOrphans, []write{parent, child1, write{"child", []string{"id"}, []interface{}{int64(2)}}}, 0},
+	} {
+		conv := MakeConv()
+		conv.SpSchema["parent"] = ddl.CreateTable{
+			Name:     "parent",
+			ColNames: []string{"id"},
+			ColDefs:  map[string]ddl.ColumnDef{"id": ddl.ColumnDef{Name: "id", T: ddl.Type{Name: ddl.Int64}, NotNull: true}},
+			Pks:      []ddl.IndexKey{ddl.IndexKey{Col: "id"}}}
+		conv.SpSchema["child"] = ddl.CreateTable{
+			Name:     "child",
+			ColNames: []string{"id", "pid"},
+			ColDefs: map[string]ddl.ColumnDef{
+				"id":  ddl.ColumnDef{Name: "id", T: ddl.Type{Name: ddl.Int64}, NotNull: true},
+				"pid": ddl.ColumnDef{Name: "pid", T: ddl.Type{Name: ddl.Int64}},
+			},
+			Pks: []ddl.IndexKey{ddl.IndexKey{Col: "id"}},
+			Fks: []ddl.Foreignkey{ddl.Foreignkey{Name: "fk", Columns: []string{"pid"}, ReferTable: "parent", ReferColumns: []string{"id"}}}}
+		conv.Policies.Orphans = tc.policy
+		conv.SetDataMode()
+		var writes []write
+		conv.SetDataSink(func(table string, cols []string, vals []interface{}) {
+			writes = append(writes, write{table, cols, vals})
+		})
+		conv.WriteRow("child", "child", child1.cols, child1.vals)
+		conv.WriteRow("child", "child", child2.cols, child2.vals)
+		conv.WriteRow("parent", "parent", parent.cols, parent.vals)
+		conv.ResolveOrphans()
+		assert.Equal(t, tc.writes, writes, tc.policy.String())
+		assert.Equal(t, tc.bad, conv.Stats.BadRows["child"], tc.policy.String())
+		if tc.policy == IgnoreOrphans {
+			assert.Equal(t, 0, len(conv.Stats.Orphans), tc.policy.String())
+		} else {
+			assert.Equal(t, map[string]int64{"fk": 1}, conv.Stats.Orphans["child"], tc.policy.String())
+		}
+	}
+}
+
+func TestParseOrphanPolicy(t *testing.T) {
+	for _, p := range []OrphanPolicy{IgnoreOrphans, LoadOrphans, DropOrphans, NullOrphans} {
+		q, err := ParseOrphanPolicy(p.String())
+		assert.Nil(t, err)
+		assert.Equal(t, p, q)
+	}
+	_, err := ParseOrphanPolicy("cascade")
+	assert.NotNil(t, err)
+}
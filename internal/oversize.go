@@ -0,0 +1,311 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package internal
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/cloudspannerecosystem/harbourbridge/spanner/ddl"
+)
+
+// MaxCellBytes is Spanner's limit on the size of a single STRING or
+// BYTES value.
+const MaxCellBytes = 10 << 20
+
+// truncationMarker is appended to values truncated by TruncateOversize.
+const truncationMarker = "...[truncated by HarbourBridge]"
+
+// Column names used in overflow tables (see OverflowOversize).
+const (
+	overflowColumn = "overflow_column"
+	overflowChunk  = "overflow_chunk"
+	overflowData   = "overflow_data"
+)
+
+// OversizePolicy specifies how data conversion handles STRING and
+// BYTES values that exceed MaxCellBytes. Spanner rejects such values,
+// and the error fails the entire batch being written.
+type OversizePolicy int
+
+const (
+	// SidelineOversize drops rows with oversize values (they are
+	// reported as bad rows and are not written to Spanner).
+	SidelineOversize OversizePolicy = iota
+	// TruncateOversize truncates oversize values so they fit, and
+	// appends a marker to show they were truncated.
+	TruncateOversize
+	// OverflowOversize writes oversize values in chunks to an overflow
+	// table that shares the table's primary key, and writes NULL in
+	// the original column. Overflow tables are added during schema
+	// conversion for tables with STRING(MAX) or BYTES(MAX) columns.
+	OverflowOversize
+)
+
+var oversizePolicyNames = map[OversizePolicy]string{
+	SidelineOversize: "sideline",
+	TruncateOversize: "truncate",
+	OverflowOversize: "overflow",
+}
+
+func (p OversizePolicy) String() string {
+	if s, ok := oversizePolicyNames[p]; ok {
+		return s
+	}
+	return fmt.Sprintf("OversizePolicy(%d)", int(p))
+}
+
+// ParseOversizePolicy maps a policy name (as used on the command line)
+// to an OversizePolicy.
+func ParseOversizePolicy(s string) (OversizePolicy, error) {
+	for p, name := range oversizePolicyNames {
+		if strings.ToLower(s) == name {
+			return p, nil
+		}
+	}
+	return SidelineOversize, fmt.Errorf("unknown oversize policy %q (accepted values are \"sideline\", \"truncate\" and \"overflow\")", s)
+}
+
+// AddOverflowTables adds an overflow table for each Spanner table that
+// has STRING(MAX) or BYTES(MAX) columns (the only columns that can hold
+// values larger than MaxCellBytes). An overflow table has the primary
+// key columns of its table, plus the name of the column and a chunk
+// number. It is linked to its table by a foreign key, rather than by
+// interleaving, so that data conversion from dump files (which doesn't
+// support interleaved tables) still works.
+func (conv *Conv) AddOverflowTables() {
+	isOverflow := make(map[string]bool)
+	for _, ot := range conv.OverflowTables {
+		isOverflow[ot] = true
+	}
+	var tables []string
+	for t, ct := range conv.SpSchema {
+		if _, ok := conv.OverflowTables[t]; !ok && !isOverflow[t] && hasMaxLengthCol(ct) {
+			tables = append(tables, t)
+		}
+	}
+	sort.Strings(tables) // Ensure names are allocated deterministically.
+	for _, t := range tables {
+		ct := conv.SpSchema[t]
+		name := conv.unusedName(t + "_overflow")
+		ot := ddl.CreateTable{
+			Name:    name,
+			ColDefs: make(map[string]ddl.ColumnDef),
+			Comment: fmt.Sprintf("Overflow table for oversize values in table %s", t),
+		}
+		var pkCols []string
+		for _, k := range ct.Pks {
+			ot.ColNames = append(ot.ColNames, k.Col)
+			cd := ct.ColDefs[k.Col]
+			ot.ColDefs[k.Col] = ddl.ColumnDef{Name: k.Col, T: cd.T, NotNull: cd.NotNull}
+			ot.Pks = append(ot.Pks, ddl.IndexKey{Col: k.Col, Desc: k.Desc})
+			pkCols = append(pkCols, k.Col)
+		}
+		ot.ColNames = append(ot.ColNames, overflowColumn, overflowChunk, overflowData)
+		ot.ColDefs[overflowColumn] = ddl.ColumnDef{Name: overflowColumn, T: ddl.Type{Name: ddl.String, Len: ddl.MaxLength}, NotNull: true}
+		ot.ColDefs[overflowChunk] = ddl.ColumnDef{Name: overflowChunk, T: ddl.Type{Name: ddl.Int64}, NotNull: true}
+		ot.ColDefs[overflowData] = ddl.ColumnDef{Name: overflowData, T: ddl.Type{Name: ddl.Bytes, Len: ddl.MaxLength}}
+		ot.Pks = append(ot.Pks, ddl.IndexKey{Col: overflowColumn}, ddl.IndexKey{Col: overflowChunk})
+		if len(pkCols) > 0 {
+			ot.Fks = []ddl.Foreignkey{{Name: conv.unusedName(name + "_fk"), Columns: pkCols, ReferTable: t, ReferColumns: pkCols}}
+		}
+		conv.SpSchema[name] = ot
+		conv.OverflowTables[t] = name
+	}
+}
+
+func hasMaxLengthCol(ct ddl.CreateTable) bool {
+	for _, cd := range ct.ColDefs {
+		if (cd.T.Name == ddl.String || cd.T.Name == ddl.Bytes) && cd.T.Len == ddl.MaxLength && !cd.T.IsArray {
+			return true
+		}
+	}
+	return false
+}
+
+// unusedName returns base (or base with a numeric suffix) such that it
+// isn't used by any table, index or foreign key in conv.SpSchema.
+func (conv *Conv) unusedName(base string) string {
+	used := make(map[string]bool)
+	for t, ct := range conv.SpSchema {
+		used[strings.ToLower(t)] = true
+		for _, fk := range ct.Fks {
+			used[strings.ToLower(fk.Name)] = true
+		}
+		for _, index := range ct.Indexes {
+			used[strings.ToLower(index.Name)] = true
+		}
+	}
+	name := base
+	for i := 1; used[strings.ToLower(name)]; i++ {
+		name = fmt.Sprintf("%s%d", base, i)
+	}
+	return name
+}
+
+// overflowRow is a chunk of an oversize value destined for an overflow table.
+type overflowRow struct {
+	cols []string
+	vals []interface{}
+}
+
+// applyOversizePolicy checks the STRING and BYTES values in spVals against
+// MaxCellBytes, and applies conv's oversize policy to any oversize values.
+// It returns the columns and values to write, any overflow rows to write
+// after them, and false if the row should not be written at all.
+func (conv *Conv) applyOversizePolicy(srcTable, spTable string, spCols []string, spVals []interface{}) ([]string, []interface{}, []overflowRow, bool) {
+	var oversize []int
+	for i, v := range spVals {
+		if cellBytes(v) > MaxCellBytes {
+			oversize = append(oversize, i)
+		}
+	}
+	if len(oversize) == 0 {
+		return spCols, spVals, nil, true
+	}
+	for _, i := range oversize {
+		conv.statsAddOversize(srcTable, spCols[i])
+	}
+	policy := conv.Policies.Oversize
+	if _, ok := conv.OverflowTables[spTable]; policy == OverflowOversize && !ok {
+		conv.Unexpected(fmt.Sprintf("No overflow table for table %s: dropping rows with oversize values", spTable))
+		policy = SidelineOversize
+	}
+	switch policy {
+	case TruncateOversize:
+		vals := make([]interface{}, len(spVals))
+		copy(vals, spVals)
+		for _, i := range oversize {
+			vals[i] = truncateCell(vals[i])
+		}
+		return spCols, vals, nil, true
+	case OverflowOversize:
+		pkVals, ok := conv.primaryKeyVals(spTable, spCols, spVals)
+		if !ok {
+			return nil, nil, nil, false
+		}
+		isOversize := make(map[int]bool)
+		var overflow []overflowRow
+		for _, i := range oversize {
+			if conv.SpSchema[spTable].ColDefs[spCols[i]].NotNull {
+				// Column can't be written as NULL, so drop the row.
+				return nil, nil, nil, false
+			}
+			isOversize[i] = true
+			b := cellAsBytes(spVals[i])
+			for chunk := int64(0); len(b) > 0; chunk++ {
+				n := len(b)
+				if n > MaxCellBytes {
+					n = MaxCellBytes
+				}
+				var r overflowRow
+				for _, k := range conv.SpSchema[spTable].Pks {
+					r.cols = append(r.cols, k.Col)
+				}
+				r.cols = append(r.cols, overflowColumn, overflowChunk, overflowData)
+				r.vals = append(append(r.vals, pkVals...), spCols[i], chunk, b[:n])
+				overflow = append(overflow, r)
+				b = b[n:]
+			}
+		}
+		// Oversize columns are omitted from the row i.e. written as NULL.
+		var cols []string
+		var vals []interface{}
+		for i := range spCols {
+			if !isOversize[i] {
+				cols = append(cols, spCols[i])
+				vals = append(vals, spVals[i])
+			}
+		}
+		return cols, vals, overflow, true
+	default:
+		return nil, nil, nil, false
+	}
+}
+
+// primaryKeyVals returns the values of the primary key columns of
+// spTable from spVals, and false if any are missing.
+func (conv *Conv) primaryKeyVals(spTable string, spCols []string, spVals []interface{}) ([]interface{}, bool) {
+	var vals []interface{}
+	for _, k := range conv.SpSchema[spTable].Pks {
+		found := false
+		for i, c := range spCols {
+			if c == k.Col {
+				vals = append(vals, spVals[i])
+				found = true
+				break
+			}
+		}
+		if !found {
+			return nil, false
+		}
+	}
+	return vals, true
+}
+
+func cellBytes(v interface{}) int {
+	switch x := v.(type) {
+	case string:
+		return len(x)
+	case []byte:
+		return len(x)
+	}
+	return 0
+}
+
+func cellAsBytes(v interface{}) []byte {
+	switch x := v.(type) {
+	case string:
+		return []byte(x)
+	case []byte:
+		return x
+	}
+	return nil
+}
+
+// truncateCell truncates v (a string or []byte) so that, with
+// truncationMarker appended, it is MaxCellBytes long. Strings are
+// truncated at a UTF-8 character boundary.
+func truncateCell(v interface{}) interface{} {
+	n := MaxCellBytes - len(truncationMarker)
+	switch x := v.(type) {
+	case string:
+		for n > 0 && !utf8.RuneStart(x[n]) {
+			n--
+		}
+		return x[:n] + truncationMarker
+	case []byte:
+		b := make([]byte, 0, MaxCellBytes)
+		b = append(b, x[:n]...)
+		return append(b, truncationMarker...)
+	}
+	return v
+}
+
+// oversizeRowVals returns a printable version of spVals for bad-row
+// reporting, with oversize values replaced by their size.
+func oversizeRowVals(spVals []interface{}) []string {
+	var l []string
+	for _, v := range spVals {
+		if n := cellBytes(v); n > MaxCellBytes {
+			l = append(l, fmt.Sprintf("<oversize value: %d bytes>", n))
+		} else {
+			l = append(l, fmt.Sprintf("%v", v))
+		}
+	}
+	return l
+}
@@ -0,0 +1,111 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package internal
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/cloudspannerecosystem/harbourbridge/spanner/ddl"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAddOverflowTables(t *testing.T) {
+	conv := MakeConv()
+	conv.SpSchema["t1"] = ddl.CreateTable{
+		Name:     "t1",
+		ColNames: []string{"a", "b"},
+		ColDefs: map[string]ddl.ColumnDef{
+			"a": ddl.ColumnDef{Name: "a", T: ddl.Type{Name: ddl.Int64}, NotNull: true},
+			"b": ddl.ColumnDef{Name: "b", T: ddl.Type{Name: ddl.String, Len: ddl.MaxLength}},
+		},
+		Pks: []ddl.IndexKey{ddl.IndexKey{Col: "a"}}}
+	conv.SpSchema["t2"] = ddl.CreateTable{
+		Name:     "t2",
+		ColNames: []string{"a", "b"},
+		ColDefs: map[string]ddl.ColumnDef{
+			"a": ddl.ColumnDef{Name: "a", T: ddl.Type{Name: ddl.Int64}},
+			"b": ddl.ColumnDef{Name: "b", T: ddl.Type{Name: ddl.String, Len: 10}},
+		},
+		Pks: []ddl.IndexKey{ddl.IndexKey{Col: "a"}}}
+	conv.AddOverflowTables()
+	assert.Equal(t, map[string]string{"t1": "t1_overflow"}, conv.OverflowTables)
+	ot := conv.SpSchema["t1_overflow"]
+	assert.Equal(t, []string{"a", "overflow_column", "overflow_chunk", "overflow_data"}, ot.ColNames)
+	assert.Equal(t, []ddl.IndexKey{{Col: "a"}, {Col: "overflow_column"}, {Col: "overflow_chunk"}}, ot.Pks)
+	assert.Equal(t, []ddl.Foreignkey{{Name: "t1_overflow_fk", Columns: []string{"a"}, ReferTable: "t1", ReferColumns: []string{"a"}}}, ot.Fks)
+	// Calling AddOverflowTables again should not add more tables.
+	conv.AddOverflowTables()
+	assert.Equal(t, 3, len(conv.SpSchema))
+}
+
+func TestWriteRow_Oversize(t *testing.T) {
+	big := strings.Repeat("x", MaxCellBytes+1)
+	type write struct {
+		table string
+		cols  []string
+		vals  []interface{}
+	}
+	for _, tc := range []struct {
+		name   string
+		policy OversizePolicy
+		writes []write
+	}{
+		{"sideline", SidelineOversize, nil},
+		{"truncate", TruncateOversize, []write{
+			{"t1", []string{"a", "b"}, []interface{}{int64(1), big[:MaxCellBytes-len(truncationMarker)] + truncationMarker}}}},
+		{"overflow", OverflowOversize, []write{
+			{"t1", []string{"a"}, []interface{}{int64(1)}},
+			{"t1_overflow", []string{"a", "overflow_column", "overflow_chunk", "overflow_data"}, []interface{}{int64(1), "b", int64(0), []byte(big[:MaxCellBytes])}},
+			{"t1_overflow", []string{"a", "overflow_column", "overflow_chunk", "overflow_data"}, []interface{}{int64(1), "b", int64(1), []byte("x")}}}},
+	} {
+		conv := MakeConv()
+		conv.SpSchema["t1"] = ddl.CreateTable{
+			Name:     "t1",
+			ColNames: []string{"a", "b"},
+			ColDefs: map[string]ddl.ColumnDef{
+				"a": ddl.ColumnDef{Name: "a", T: ddl.Type{Name: ddl.Int64}, NotNull: true},
+				"b": ddl.ColumnDef{Name: "b", T: ddl.Type{Name: ddl.String, Len: ddl.MaxLength}},
+			},
+			Pks: []ddl.IndexKey{ddl.IndexKey{Col: "a"}}}
+		conv.Policies.Oversize = tc.policy
+		conv.AddOverflowTables()
+		conv.SetDataMode()
+		var writes []write
+		conv.SetDataSink(func(table string, cols []string, vals []interface{}) {
+			writes = append(writes, write{table, cols, vals})
+		})
+		conv.WriteRow("src", "t1", []string{"a", "b"}, []interface{}{int64(1), big})
+		assert.Equal(t, tc.writes, writes, tc.name)
+		assert.Equal(t, map[string]int64{"b": 1}, conv.Stats.Oversize["src"], tc.name)
+		if tc.writes == nil {
+			assert.Equal(t, int64(1), conv.Stats.BadRows["src"], tc.name)
+		} else {
+			assert.Equal(t, int64(1), conv.Stats.GoodRows["src"], tc.name)
+		}
+	}
+}
+
+func TestBuildOversizeBody(t *testing.T) {
+	conv := MakeConv()
+	conv.ToSpanner["src"] = NameAndCols{Name: "t1"}
+	conv.Stats.Oversize = map[string]map[string]int64{"src": {"b": 2}}
+	conv.Policies.Oversize = OverflowOversize
+	conv.OverflowTables["t1"] = "t1_overflow"
+	assert.Equal(t, []string{"Column 'b': 2 values larger than 10485760 bytes were moved to overflow table t1_overflow"}, buildOversizeBody(conv, "src")[0].Lines)
+	// Rows of tables without an overflow table are sidelined.
+	delete(conv.OverflowTables, "t1")
+	assert.Equal(t, []string{"Column 'b': 2 values larger than 10485760 bytes were dropped (the table has no overflow table, so the rows containing them were not written)"}, buildOversizeBody(conv, "src")[0].Lines)
+}
@@ -0,0 +1,201 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package internal
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/cloudspannerecosystem/harbourbridge/spanner/ddl"
+)
+
+// MaxColumnsPerTable is Spanner's limit on the number of columns of a
+// table.
+const MaxColumnsPerTable = 1024
+
+// MaxCommitBytes is Spanner's limit on the size of the data written by a
+// single commit, and hence on the size of a row.
+const MaxCommitBytes = 100 << 20
+
+// Tables with more columns than partitionCols, or whose rows can be
+// larger than partitionBytes, are approaching Spanner's limits (see
+// SuggestPartitions).
+const (
+	partitionCols  = MaxColumnsPerTable * 4 / 5
+	partitionBytes = MaxCommitBytes * 4 / 5
+)
+
+// SuggestPartitions returns splits (see SplitTable) for the Spanner
+// tables that are approaching Spanner's limit on the number of columns of
+// a table, or whose rows can approach the commit size limit (based on
+// the declared sizes of their columns). Columns are moved to side tables
+// interleaved in the table until it is within both limits. Nullable
+// columns are moved first (they are the most likely to be rarely used),
+// then the widest ones. Key columns and columns used by indexes or foreign
+// keys are never moved.
+func (conv *Conv) SuggestPartitions() []SplitTable {
+	var tables []string
+	for t := range conv.SpSchema {
+		tables = append(tables, t)
+	}
+	sort.Strings(tables) // Ensure names are allocated deterministically.
+	taken := make(map[string]bool)
+	var splits []SplitTable
+	for _, t := range tables {
+		splits = append(splits, conv.suggestPartition(t, taken)...)
+	}
+	return splits
+}
+
+// suggestPartition returns the splits suggested for spTable (see
+// SuggestPartitions). Names of new tables are added to taken.
+func (conv *Conv) suggestPartition(spTable string, taken map[string]bool) []SplitTable {
+	ct := conv.SpSchema[spTable]
+	n, size := len(ct.ColNames), rowBytes(ct, ct.ColNames)
+	if n <= partitionCols && size <= partitionBytes {
+		return nil
+	}
+	if conv.isMergeTarget(spTable) || conv.isOverflowTable(spTable) {
+		return nil
+	}
+	isKey := make(map[string]bool)
+	var keyCols []string
+	for _, k := range ct.Pks {
+		isKey[k.Col] = true
+		keyCols = append(keyCols, k.Col)
+	}
+	var cands []string
+	for _, c := range ct.ColNames {
+		if !isKey[c] && conv.canDropCol(spTable, c) == nil {
+			cands = append(cands, c)
+		}
+	}
+	sort.SliceStable(cands, func(i, j int) bool {
+		ci, cj := ct.ColDefs[cands[i]], ct.ColDefs[cands[j]]
+		if ci.NotNull != cj.NotNull {
+			return !ci.NotNull
+		}
+		return colBytes(ci.T) > colBytes(cj.T)
+	})
+	var move []string
+	for _, c := range cands {
+		if n <= partitionCols && size <= partitionBytes {
+			break
+		}
+		move = append(move, c)
+		n--
+		size -= colBytes(ct.ColDefs[c].T)
+	}
+	if len(move) == 0 {
+		return nil
+	}
+	// Pack the moved columns into as many side tables as needed for each
+	// of them to be within the limits.
+	keyBytes := rowBytes(ct, keyCols)
+	var splits []SplitTable
+	var cur *SplitTable
+	var curBytes int64
+	for _, c := range move {
+		b := colBytes(ct.ColDefs[c].T)
+		if cur == nil || len(keyCols)+len(cur.Cols) >= partitionCols || curBytes+b > partitionBytes {
+			splits = append(splits, SplitTable{Table: spTable, NewTable: conv.sideTableName(spTable, taken), Interleave: true})
+			cur = &splits[len(splits)-1]
+			curBytes = keyBytes
+		}
+		cur.Cols = append(cur.Cols, c)
+		curBytes += b
+	}
+	return splits
+}
+
+// sideTableName returns an unused name for a side table of spTable, and
+// adds it to taken.
+func (conv *Conv) sideTableName(spTable string, taken map[string]bool) string {
+	for i := 1; ; i++ {
+		base := spTable + "_ext"
+		if i > 1 {
+			base = fmt.Sprintf("%s%d", base, i)
+		}
+		name := conv.unusedName(base)
+		if !taken[strings.ToLower(name)] {
+			taken[strings.ToLower(name)] = true
+			return name
+		}
+	}
+}
+
+func (conv *Conv) isOverflowTable(spTable string) bool {
+	for _, ot := range conv.OverflowTables {
+		if ot == spTable {
+			return true
+		}
+	}
+	return false
+}
+
+// rowBytes estimates the largest size of the values of cols in a row of ct.
+func rowBytes(ct ddl.CreateTable, cols []string) int64 {
+	var n int64
+	for _, c := range cols {
+		n += colBytes(ct.ColDefs[c].T)
+	}
+	return n
+}
+
+// colBytes estimates the largest size of a value of type t. Values of
+// STRING and BYTES columns of unbounded length, and arrays, can be up to
+// MaxCellBytes.
+func colBytes(t ddl.Type) int64 {
+	if t.IsArray {
+		return MaxCellBytes
+	}
+	switch t.Name {
+	case ddl.String:
+		// UTF-8 encodes a character in up to 4 bytes.
+		if t.Len == ddl.MaxLength || t.Len > MaxCellBytes/4 {
+			return MaxCellBytes
+		}
+		return 4 * t.Len
+	case ddl.Bytes:
+		if t.Len == ddl.MaxLength || t.Len > MaxCellBytes {
+			return MaxCellBytes
+		}
+		return t.Len
+	case ddl.Bool:
+		return 1
+	case ddl.Numeric:
+		return 22
+	default:
+		return 8
+	}
+}
+
+// PartitionReason describes why SuggestPartitions suggests splitting
+// spTable, or returns "" if it doesn't.
+func (conv *Conv) PartitionReason(spTable string) string {
+	ct, ok := conv.SpSchema[spTable]
+	if !ok {
+		return ""
+	}
+	var l []string
+	if n := len(ct.ColNames); n > partitionCols {
+		l = append(l, fmt.Sprintf("it has %d columns (Spanner's limit is %d)", n, MaxColumnsPerTable))
+	}
+	if n := rowBytes(ct, ct.ColNames); n > partitionBytes {
+		l = append(l, fmt.Sprintf("its rows can be up to %d MB based on column sizes (Spanner's commit limit is %d MB)", n>>20, MaxCommitBytes>>20))
+	}
+	return strings.Join(l, ", and ")
+}
@@ -0,0 +1,91 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package internal
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/cloudspannerecosystem/harbourbridge/spanner/ddl"
+	"github.com/stretchr/testify/assert"
+)
+
+// wideTable returns a table with key column 'id', n STRING(MAX) columns
+// big0, big1, ... and m nullable INT64 columns c0, c1, ... The first
+// column of each kind is NOT NULL.
+func wideTable(name string, n, m int) ddl.CreateTable {
+	ct := ddl.CreateTable{
+		Name:     name,
+		ColNames: []string{"id"},
+		ColDefs:  map[string]ddl.ColumnDef{"id": ddl.ColumnDef{Name: "id", T: ddl.Type{Name: ddl.Int64}, NotNull: true}},
+		Pks:      []ddl.IndexKey{ddl.IndexKey{Col: "id"}}}
+	add := func(c string, t ddl.Type, notNull bool) {
+		ct.ColNames = append(ct.ColNames, c)
+		ct.ColDefs[c] = ddl.ColumnDef{Name: c, T: t, NotNull: notNull}
+	}
+	for i := 0; i < n; i++ {
+		add(fmt.Sprintf("big%d", i), ddl.Type{Name: ddl.String, Len: ddl.MaxLength}, i == 0)
+	}
+	for i := 0; i < m; i++ {
+		add(fmt.Sprintf("c%d", i), ddl.Type{Name: ddl.Int64}, i == 0)
+	}
+	return ct
+}
+
+func TestSuggestPartitions(t *testing.T) {
+	conv := MakeConv()
+	conv.SpSchema["narrow"] = wideTable("narrow", 2, 10)
+	conv.SpSchema["many"] = wideTable("many", 0, 900)
+	conv.SpSchema["large"] = wideTable("large", 10, 1)
+	conv.SpSchema["huge"] = wideTable("huge", 20, 0)
+	var cols []string
+	for i := 1; i <= 901-partitionCols; i++ {
+		cols = append(cols, fmt.Sprintf("c%d", i))
+	}
+	assert.Equal(t, []SplitTable{
+		{Table: "huge", NewTable: "huge_ext", Cols: []string{"big1", "big2", "big3", "big4", "big5", "big6", "big7"}, Interleave: true},
+		{Table: "huge", NewTable: "huge_ext2", Cols: []string{"big8", "big9", "big10", "big11", "big12", "big13"}, Interleave: true},
+		{Table: "large", NewTable: "large_ext", Cols: []string{"big1", "big2", "big3"}, Interleave: true},
+		{Table: "many", NewTable: "many_ext", Cols: cols, Interleave: true},
+	}, conv.SuggestPartitions())
+	assert.Equal(t, "", conv.PartitionReason("narrow"))
+	assert.Equal(t, "it has 901 columns (Spanner's limit is 1024)", conv.PartitionReason("many"))
+	assert.Equal(t, "its rows can be up to 100 MB based on column sizes (Spanner's commit limit is 100 MB)", conv.PartitionReason("large"))
+}
+
+func TestAutoPartition(t *testing.T) {
+	conv := MakeConv()
+	conv.SpSchema["t"] = wideTable("t", 9, 1)
+	assert.Nil(t, conv.ApplyRemodel(Remodel{AutoPartition: true}))
+	assert.Equal(t, []string{"id", "big0", "big3", "big4", "big5", "big6", "big7", "big8", "c0"}, conv.SpSchema["t"].ColNames)
+	assert.Equal(t, "t", conv.SpSchema["t_ext"].Parent)
+	assert.Nil(t, conv.SpSchema["t_ext"].Fks)
+	assert.Equal(t, "", conv.PartitionReason("t"))
+	assert.False(t, conv.HasInterleavedTables())
+	// The tables are within the limits, so applying it again is a no-op.
+	assert.Nil(t, conv.ApplyRemodel(Remodel{AutoPartition: true}))
+	assert.Equal(t, 2, len(conv.SpSchema))
+
+	// Rows of interleaved tables are written to the child sink.
+	rows := remodelTestSinks(conv)
+	conv.SetChildSink(func(table string, cols []string, vals []interface{}) {
+		*rows = append(*rows, sinkRow{"child", table, cols, vals})
+	})
+	conv.WriteRow("t", "t", []string{"id", "big0", "big1"}, []interface{}{int64(1), "a", "b"})
+	assert.Equal(t, []sinkRow{
+		{"insert", "t", []string{"id", "big0"}, []interface{}{int64(1), "a"}},
+		{"child", "t_ext", []string{"id", "big1"}, []interface{}{int64(1), "b"}},
+	}, *rows)
+}
@@ -0,0 +1,97 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package internal
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Policies contains user-configurable policies that control how data
+// conversion handles source values that Spanner can't store as-is.
+// The zero value of each policy is the default behavior, so session
+// files written before a policy was added still load as expected.
+type Policies struct {
+	SpecialValues SpecialValuePolicy // Handling of 'infinity' dates/timestamps and NaN/Infinity numerics.
+	Oversize      OversizePolicy     // Handling of STRING and BYTES values larger than MaxCellBytes.
+	Orphans       OrphanPolicy       // Handling of rows whose foreign key doesn't match a row of the referenced table.
+	Duplicates    DuplicatePolicy    // Handling of rows whose primary key matches that of an earlier row.
+	NotNull       NotNullPolicy      // Handling of NULL values for NOT NULL columns.
+	// NotNullColumns overrides NotNull for specific Spanner columns,
+	// given as table.column.
+	NotNullColumns map[string]NotNullPolicy
+	// EmptyStrings is the handling of empty strings and NULL values of
+	// STRING columns, and EmptyStringColumns overrides it for specific
+	// Spanner columns, given as table.column.
+	EmptyStrings       EmptyStringPolicy            `json:",omitempty"`
+	EmptyStringColumns map[string]EmptyStringPolicy `json:",omitempty"`
+	// CharPadding is the handling of the trailing blanks of blank-padded
+	// CHAR columns, and CharPaddingColumns overrides it for specific
+	// Spanner columns, given as table.column.
+	CharPadding        CharPaddingPolicy            `json:",omitempty"`
+	CharPaddingColumns map[string]CharPaddingPolicy `json:",omitempty"`
+	// DateRules remaps sentinel values of DATE and TIMESTAMP columns
+	// (see DateRule).
+	DateRules []DateRule `json:",omitempty"`
+	// ValueDictionaries validates and normalizes the values of STRING
+	// columns against value dictionaries (see ValueDictionaryRule).
+	ValueDictionaries []ValueDictionaryRule `json:",omitempty"`
+	// Masks specifies how the values of source columns, given as
+	// table.column, are masked (see MaskRule).
+	Masks map[string]MaskRule `json:",omitempty"`
+	// ErrorBudget aborts data conversion when too many rows can't be
+	// converted.
+	ErrorBudget ErrorBudget
+}
+
+// SpecialValuePolicy specifies how data conversion handles special
+// values such as PostgreSQL's 'infinity' dates and timestamps, and
+// NaN/Infinity numerics, when the Spanner column type can't store them.
+type SpecialValuePolicy int
+
+const (
+	// RejectSpecialValues treats rows with special values as bad rows.
+	RejectSpecialValues SpecialValuePolicy = iota
+	// ClampSpecialValues replaces special values by the closest value
+	// the Spanner type supports e.g. 'infinity' dates become 9999-12-31.
+	// Values that have no closest value (NaN) are replaced by NULL.
+	ClampSpecialValues
+	// NullSpecialValues replaces special values by NULL.
+	NullSpecialValues
+)
+
+var specialValuePolicyNames = map[SpecialValuePolicy]string{
+	RejectSpecialValues: "reject",
+	ClampSpecialValues:  "clamp",
+	NullSpecialValues:   "null",
+}
+
+func (p SpecialValuePolicy) String() string {
+	if s, ok := specialValuePolicyNames[p]; ok {
+		return s
+	}
+	return fmt.Sprintf("SpecialValuePolicy(%d)", int(p))
+}
+
+// ParseSpecialValuePolicy maps a policy name (as used on the
+// command line) to a SpecialValuePolicy.
+func ParseSpecialValuePolicy(s string) (SpecialValuePolicy, error) {
+	for p, name := range specialValuePolicyNames {
+		if strings.ToLower(s) == name {
+			return p, nil
+		}
+	}
+	return RejectSpecialValues, fmt.Errorf("unknown special value policy %q (accepted values are \"reject\", \"clamp\" and \"null\")", s)
+}
@@ -0,0 +1,34 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package internal
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseSpecialValuePolicy(t *testing.T) {
+	for _, p := range []SpecialValuePolicy{RejectSpecialValues, ClampSpecialValues, NullSpecialValues} {
+		q, err := ParseSpecialValuePolicy(p.String())
+		assert.Nil(t, err)
+		assert.Equal(t, p, q)
+	}
+	p, err := ParseSpecialValuePolicy("NULL")
+	assert.Nil(t, err)
+	assert.Equal(t, NullSpecialValues, p)
+	_, err = ParseSpecialValuePolicy("ignore")
+	assert.NotNil(t, err)
+}
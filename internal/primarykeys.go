@@ -0,0 +1,245 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package internal
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+
+	"github.com/cloudspannerecosystem/harbourbridge/spanner/ddl"
+)
+
+// PrimaryKeyIndex replaces the primary key of a Spanner table by the
+// columns of one of its unique indexes, e.g. when the primary key is
+// monotonically increasing (which creates hotspots in Spanner) and the
+// index isn't. The index is removed, and the original primary key becomes
+// a unique index, unless it is a synthetic primary key (see
+// AddPrimaryKeys), which is removed: rows are then written without it.
+type PrimaryKeyIndex struct {
+	Table string // Spanner table.
+	Index string // Unique index of Table whose columns become its primary key.
+	// Demoted is the unique index the original primary key became. It is
+	// set when the change is applied (empty for synthetic primary keys).
+	Demoted string `json:",omitempty"`
+}
+
+// usePrimaryKeyIndex applies p. Changes that have already been applied
+// (e.g. when conv was read from a session file) are skipped.
+func (conv *Conv) usePrimaryKeyIndex(p PrimaryKeyIndex) error {
+	if x, ok := conv.KeyIndexes[p.Table]; ok {
+		if x.Index == p.Index {
+			return nil // Already applied.
+		}
+		return fmt.Errorf("primary key was already taken from index %s", x.Index)
+	}
+	ct, ok := conv.SpSchema[p.Table]
+	if !ok {
+		return fmt.Errorf("unknown table")
+	}
+	if err := conv.canChangeKey(p.Table); err != nil {
+		return err
+	}
+	pos := -1
+	for i, index := range ct.Indexes {
+		if index.Name == p.Index {
+			pos = i
+		}
+	}
+	if pos < 0 {
+		return fmt.Errorf("unknown index %s", p.Index)
+	}
+	index := ct.Indexes[pos]
+	if !index.Unique {
+		return fmt.Errorf("index %s isn't unique", p.Index)
+	}
+	for _, k := range index.Keys {
+		// Unique indexes allow several rows with NULL values.
+		if !ct.ColDefs[k.Col].NotNull {
+			return fmt.Errorf("column %s of index %s is nullable", k.Col, p.Index)
+		}
+	}
+	ct.Indexes = append(ct.Indexes[:pos:pos], ct.Indexes[pos+1:]...)
+	if sk, ok := conv.SyntheticPKeys[p.Table]; ok {
+		ct.ColNames = removeString(ct.ColNames, sk.Col)
+		delete(ct.ColDefs, sk.Col)
+		delete(conv.SyntheticPKeys, p.Table)
+	} else {
+		p.Demoted = conv.unusedName(p.Table + "_pkey")
+		ct.Indexes = append(ct.Indexes, ddl.CreateIndex{Name: p.Demoted, Table: p.Table, Unique: true, Keys: ct.Pks})
+	}
+	ct.Pks = index.Keys
+	conv.SpSchema[p.Table] = ct
+	if conv.KeyIndexes == nil {
+		conv.KeyIndexes = make(map[string]PrimaryKeyIndex)
+	}
+	conv.KeyIndexes[p.Table] = p
+	return nil
+}
+
+// KeyOrder reorders the columns of the primary key of a Spanner table,
+// e.g. to put a tenant column first so that the rows of each tenant are
+// stored together.
+type KeyOrder struct {
+	Table string   // Spanner table.
+	Cols  []string // All columns of the primary key of Table, in their new order.
+	// Original is the original order of the columns. It is set when the
+	// change is applied.
+	Original []string `json:",omitempty"`
+}
+
+// reorderKeys applies orders. Orders that have already been applied (e.g.
+// when conv was read from a session file) are skipped. The primary key of
+// interleaved tables must still start with the primary key of their
+// parent once all orders are applied, so a parent and the tables
+// interleaved in it must be reordered together. Foreign keys don't depend
+// on the order of primary key columns.
+func (conv *Conv) reorderKeys(orders []KeyOrder) error {
+	pks := make(map[string][]ddl.IndexKey)
+	var applied []KeyOrder
+	for _, o := range orders {
+		ct, ok := conv.SpSchema[o.Table]
+		if !ok {
+			return fmt.Errorf("can't reorder primary key of table %s: unknown table", o.Table)
+		}
+		if len(conv.Splits[o.Table]) > 0 || conv.isMergeTarget(o.Table) {
+			return fmt.Errorf("can't reorder primary key of table %s: split tables and tables with merged tables can't change their primary key", o.Table)
+		}
+		key, err := reorderKey(ct.Pks, o.Cols)
+		if err != nil {
+			return fmt.Errorf("can't reorder primary key of table %s: %w", o.Table, err)
+		}
+		if equalStrings(keyCols(ct.Pks), o.Cols) {
+			continue // Already applied.
+		}
+		o.Original = keyCols(ct.Pks)
+		if x, ok := conv.KeyOrders[o.Table]; ok {
+			o.Original = x.Original
+		}
+		pks[o.Table] = key
+		applied = append(applied, o)
+	}
+	key := func(t string) []ddl.IndexKey {
+		if k, ok := pks[t]; ok {
+			return k
+		}
+		return conv.SpSchema[t].Pks
+	}
+	for _, ct := range conv.SpSchema {
+		_, ok1 := pks[ct.Name]
+		_, ok2 := pks[ct.Parent]
+		if ct.Parent == "" || !(ok1 || ok2) {
+			continue
+		}
+		parent, child := key(ct.Parent), key(ct.Name)
+		if len(child) < len(parent) || !reflect.DeepEqual(parent, child[:len(parent)]) {
+			return fmt.Errorf("can't reorder primary keys: the primary key of table %s must start with the primary key of table %s, which it is interleaved in", ct.Name, ct.Parent)
+		}
+	}
+	if conv.KeyOrders == nil {
+		conv.KeyOrders = make(map[string]KeyOrder)
+	}
+	for _, o := range applied {
+		ct := conv.SpSchema[o.Table]
+		ct.Pks = pks[o.Table]
+		conv.SpSchema[o.Table] = ct
+		conv.KeyOrders[o.Table] = o
+	}
+	return nil
+}
+
+// reorderKey returns key with its columns in the order of cols, which
+// must list each column of key once.
+func reorderKey(key []ddl.IndexKey, cols []string) ([]ddl.IndexKey, error) {
+	if len(cols) != len(key) {
+		return nil, fmt.Errorf("primary key has %d columns, got %d", len(key), len(cols))
+	}
+	byCol := make(map[string]ddl.IndexKey)
+	for _, k := range key {
+		byCol[k.Col] = k
+	}
+	var l []ddl.IndexKey
+	for _, c := range cols {
+		k, ok := byCol[c]
+		if !ok {
+			return nil, fmt.Errorf("column %s isn't part of the primary key, or is repeated", c)
+		}
+		delete(byCol, c)
+		l = append(l, k)
+	}
+	return l, nil
+}
+
+func keyCols(key []ddl.IndexKey) []string {
+	var l []string
+	for _, k := range key {
+		l = append(l, k.Col)
+	}
+	return l
+}
+
+func equalStrings(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// canChangeKey returns an error if the primary key of spTable can't be
+// changed: the primary keys of interleaved tables must start with the
+// primary key of their parent, and split and merged tables share their
+// primary key with other tables.
+func (conv *Conv) canChangeKey(spTable string) error {
+	if conv.SpSchema[spTable].Parent != "" {
+		return fmt.Errorf("table is interleaved in %s", conv.SpSchema[spTable].Parent)
+	}
+	for _, t := range conv.SpSchema {
+		if t.Parent == spTable {
+			return fmt.Errorf("table %s is interleaved in it", t.Name)
+		}
+	}
+	if len(conv.Splits[spTable]) > 0 || conv.isMergeTarget(spTable) {
+		return fmt.Errorf("split tables and tables with merged tables can't change their primary key")
+	}
+	return nil
+}
+
+func removeString(l []string, s string) []string {
+	var r []string
+	for _, x := range l {
+		if x != s {
+			r = append(r, x)
+		}
+	}
+	return r
+}
+
+// describeKey returns the columns of key, as printed in DDL.
+func describeKey(key []ddl.IndexKey) string {
+	var l []string
+	for _, k := range key {
+		if k.Desc {
+			l = append(l, k.Col+" DESC")
+		} else {
+			l = append(l, k.Col)
+		}
+	}
+	return "(" + strings.Join(l, ", ") + ")"
+}
@@ -0,0 +1,134 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package internal
+
+import (
+	"testing"
+
+	"github.com/cloudspannerecosystem/harbourbridge/spanner/ddl"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPrimaryKeyIndex(t *testing.T) {
+	conv := remodelTestConv()
+	users := conv.SpSchema["users"]
+	name := users.ColDefs["name"]
+	name.NotNull = true
+	users.ColDefs["name"] = name
+	users.Indexes = []ddl.CreateIndex{
+		{Name: "users_name", Table: "users", Unique: true, Keys: []ddl.IndexKey{{Col: "name", Desc: true}}},
+		{Name: "users_bio", Table: "users", Unique: true, Keys: []ddl.IndexKey{{Col: "bio"}}},
+	}
+	conv.SpSchema["users"] = users
+	r := Remodel{PrimaryKeys: []PrimaryKeyIndex{{Table: "users", Index: "users_name"}}}
+	assert.Nil(t, conv.ApplyRemodel(r))
+	users = conv.SpSchema["users"]
+	assert.Equal(t, []ddl.IndexKey{{Col: "name", Desc: true}}, users.Pks)
+	assert.Equal(t, []ddl.CreateIndex{
+		{Name: "users_bio", Table: "users", Unique: true, Keys: []ddl.IndexKey{{Col: "bio"}}},
+		{Name: "users_pkey", Table: "users", Unique: true, Keys: []ddl.IndexKey{{Col: "id"}}},
+	}, users.Indexes)
+	assert.Equal(t, "users_pkey", conv.KeyIndexes["users"].Demoted)
+	// Applying the change again (e.g. after reading a session file) is a no-op.
+	assert.Nil(t, conv.ApplyRemodel(r))
+	assert.Equal(t, users, conv.SpSchema["users"])
+
+	body := buildRemodelBody(conv, "users", "users")
+	assert.Equal(t, []string{"Primary key (name DESC) was taken from unique index 'users_name': the original primary key (id) is now unique index 'users_pkey'"}, body[0].Lines)
+
+	for _, p := range []PrimaryKeyIndex{
+		{Table: "users", Index: "users_bio"},       // Already changed.
+		{Table: "settings", Index: "settings_idx"}, // Unknown index.
+		{Table: "orders", Index: "orders_idx"},     // Unknown table.
+	} {
+		assert.NotNil(t, conv.ApplyRemodel(Remodel{PrimaryKeys: []PrimaryKeyIndex{p}}), p.Index)
+	}
+	settings := conv.SpSchema["settings"]
+	settings.Indexes = []ddl.CreateIndex{{Name: "settings_theme", Table: "settings", Keys: []ddl.IndexKey{{Col: "theme"}}}}
+	conv.SpSchema["settings"] = settings
+	assert.NotNil(t, conv.ApplyRemodel(Remodel{PrimaryKeys: []PrimaryKeyIndex{{Table: "settings", Index: "settings_theme"}}}), "not unique")
+	settings.Indexes[0].Unique = true
+	assert.NotNil(t, conv.ApplyRemodel(Remodel{PrimaryKeys: []PrimaryKeyIndex{{Table: "settings", Index: "settings_theme"}}}), "nullable")
+	settings.Parent = "users"
+	theme := settings.ColDefs["theme"]
+	theme.NotNull = true
+	settings.ColDefs["theme"] = theme
+	conv.SpSchema["settings"] = settings
+	assert.NotNil(t, conv.ApplyRemodel(Remodel{PrimaryKeys: []PrimaryKeyIndex{{Table: "settings", Index: "settings_theme"}}}), "interleaved")
+}
+
+func TestPrimaryKeyIndexSynthetic(t *testing.T) {
+	conv := remodelTestConv()
+	settings := conv.SpSchema["settings"]
+	settings.Pks = nil
+	settings.Indexes = []ddl.CreateIndex{{Name: "settings_user", Table: "settings", Unique: true, Keys: []ddl.IndexKey{{Col: "user_id"}}}}
+	conv.SpSchema["settings"] = settings
+	conv.AddPrimaryKeys()
+	col, _, ok := conv.NextSyntheticPKey("settings")
+	assert.True(t, ok)
+	assert.Nil(t, conv.ApplyRemodel(Remodel{PrimaryKeys: []PrimaryKeyIndex{{Table: "settings", Index: "settings_user"}}}))
+	settings = conv.SpSchema["settings"]
+	assert.Equal(t, []string{"user_id", "theme"}, settings.ColNames)
+	assert.NotContains(t, settings.ColDefs, col)
+	assert.Equal(t, []ddl.IndexKey{{Col: "user_id"}}, settings.Pks)
+	assert.Empty(t, settings.Indexes)
+	// Rows are written without the synthetic primary key.
+	_, _, ok = conv.NextSyntheticPKey("settings")
+	assert.False(t, ok)
+	assert.Equal(t, "", conv.KeyIndexes["settings"].Demoted)
+}
+
+func TestKeyOrder(t *testing.T) {
+	conv := MakeConv()
+	addTable := func(name, parent string, key []string) {
+		ct := ddl.CreateTable{Name: name, ColNames: key, ColDefs: make(map[string]ddl.ColumnDef), Parent: parent}
+		for _, c := range key {
+			ct.ColDefs[c] = ddl.ColumnDef{Name: c, T: ddl.Type{Name: ddl.Int64}, NotNull: true}
+			ct.Pks = append(ct.Pks, ddl.IndexKey{Col: c, Desc: c == "ts"})
+		}
+		conv.SpSchema[name] = ct
+	}
+	addTable("invoices", "", []string{"invoice_id", "tenant_id"})
+	addTable("lines", "invoices", []string{"invoice_id", "tenant_id", "line_id"})
+	addTable("events", "", []string{"id", "ts", "tenant_id"})
+
+	// Interleaved tables must be reordered with their parent.
+	assert.NotNil(t, conv.ApplyRemodel(Remodel{KeyOrders: []KeyOrder{{Table: "invoices", Cols: []string{"tenant_id", "invoice_id"}}}}))
+	assert.NotNil(t, conv.ApplyRemodel(Remodel{KeyOrders: []KeyOrder{{Table: "lines", Cols: []string{"tenant_id", "invoice_id", "line_id"}}}}))
+	assert.Equal(t, []string{"invoice_id", "tenant_id"}, keyCols(conv.SpSchema["invoices"].Pks))
+	r := Remodel{KeyOrders: []KeyOrder{
+		{Table: "invoices", Cols: []string{"tenant_id", "invoice_id"}},
+		{Table: "lines", Cols: []string{"tenant_id", "invoice_id", "line_id"}},
+		{Table: "events", Cols: []string{"tenant_id", "ts", "id"}},
+	}}
+	assert.Nil(t, conv.ApplyRemodel(r))
+	assert.Equal(t, []string{"tenant_id", "invoice_id"}, keyCols(conv.SpSchema["invoices"].Pks))
+	assert.Equal(t, []string{"tenant_id", "invoice_id", "line_id"}, keyCols(conv.SpSchema["lines"].Pks))
+	assert.Equal(t, []ddl.IndexKey{{Col: "tenant_id"}, {Col: "ts", Desc: true}, {Col: "id"}}, conv.SpSchema["events"].Pks)
+	// Applying the orders again (e.g. after reading a session file) is a no-op.
+	assert.Nil(t, conv.ApplyRemodel(r))
+	assert.Equal(t, []string{"id", "ts", "tenant_id"}, conv.KeyOrders["events"].Original)
+	body := buildRemodelBody(conv, "events", "events")
+	assert.Equal(t, []string{"Primary key columns were reordered from (id, ts, tenant_id) to (tenant_id, ts, id)"}, body[0].Lines)
+
+	for _, o := range []KeyOrder{
+		{Table: "events", Cols: []string{"tenant_id", "id"}},           // Missing column.
+		{Table: "events", Cols: []string{"tenant_id", "id", "id"}},     // Repeated column.
+		{Table: "events", Cols: []string{"tenant_id", "id", "amount"}}, // Not a key column.
+		{Table: "orders", Cols: []string{"id"}},                        // Unknown table.
+	} {
+		assert.NotNil(t, conv.ApplyRemodel(Remodel{KeyOrders: []KeyOrder{o}}), o.Cols)
+	}
+}
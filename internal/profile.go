@@ -0,0 +1,145 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package internal
+
+import (
+	"database/sql"
+	"fmt"
+
+	"github.com/cloudspannerecosystem/harbourbridge/spanner/ddl"
+)
+
+// ColumnProfile describes the values of a source column in a sample of
+// the rows of its table.
+type ColumnProfile struct {
+	Rows     int64  // Number of rows sampled.
+	Nulls    int64  // Number of NULL values.
+	Distinct int64  // Number of distinct non-NULL values.
+	Min      string // Smallest value, if the column has an order and values.
+	Max      string // Largest value, if the column has an order and values.
+}
+
+// NullFraction returns the fraction of sampled values that are NULL.
+func (p ColumnProfile) NullFraction() float64 {
+	if p.Rows == 0 {
+		return 0
+	}
+	return float64(p.Nulls) / float64(p.Rows)
+}
+
+// ProfiledCols returns the source columns of srcTable that data profiling
+// samples: columns converted to Spanner columns that aren't arrays, BYTES
+// or JSON.
+func (conv *Conv) ProfiledCols(srcTable string) []string {
+	var l []string
+	spTable := conv.ToSpanner[srcTable].Name
+	for _, c := range conv.SrcSchema[srcTable].ColNames {
+		spCol, ok := conv.ToSpanner[srcTable].Cols[c]
+		if !ok {
+			continue
+		}
+		ty := conv.SpSchema[spTable].ColDefs[spCol].T
+		if !ty.IsArray && ty.Name != ddl.Bytes && ty.Name != ddl.JSON {
+			l = append(l, c)
+		}
+	}
+	return l
+}
+
+// ProfileMinMax returns true if data profiling reports the smallest and
+// largest values of srcCol of srcTable, i.e. if it is converted to a
+// Spanner numeric, date, time or string column, and whether it is
+// converted to a string column (whose values are compared as text).
+func (conv *Conv) ProfileMinMax(srcTable, srcCol string) (ok, text bool) {
+	spCol := conv.ToSpanner[srcTable].Cols[srcCol]
+	switch conv.SpSchema[conv.ToSpanner[srcTable].Name].ColDefs[spCol].T.Name {
+	case ddl.String:
+		return true, true
+	case ddl.Int64, ddl.Float64, ddl.Float32, ddl.Numeric, ddl.Date, ddl.Timestamp:
+		return true, false
+	}
+	return false, false
+}
+
+// ProfileTable runs q, a query returning the number of rows sampled from
+// srcTable and then for each of cols the number of non-NULL values, the
+// number of distinct values and, if ProfileMinMax is true, the smallest
+// and largest values as text, and records the profiles of cols.
+func ProfileTable(conv *Conv, db Queryer, srcTable string, cols []string, q string) error {
+	var rows int64
+	counts := make([]int64, 2*len(cols))
+	minMax := make([]sql.NullString, 2*len(cols))
+	ptrs := []interface{}{&rows}
+	for i, c := range cols {
+		ptrs = append(ptrs, &counts[2*i], &counts[2*i+1])
+		if ok, _ := conv.ProfileMinMax(srcTable, c); ok {
+			ptrs = append(ptrs, &minMax[2*i], &minMax[2*i+1])
+		}
+	}
+	if err := db.QueryRow(q).Scan(ptrs...); err != nil {
+		return err
+	}
+	for i, c := range cols {
+		conv.SetProfile(srcTable, c, ColumnProfile{
+			Rows:     rows,
+			Nulls:    rows - counts[2*i],
+			Distinct: counts[2*i+1],
+			Min:      minMax[2*i].String,
+			Max:      minMax[2*i+1].String,
+		})
+	}
+	return nil
+}
+
+// SetProfile records the profile of srcCol of srcTable.
+func (conv *Conv) SetProfile(srcTable, srcCol string, p ColumnProfile) {
+	if conv.Profiles == nil {
+		conv.Profiles = make(map[string]ColumnProfile)
+	}
+	conv.Profiles[srcTable+"."+srcCol] = p
+}
+
+// profileLine describes the profile of srcCol of srcTable, with hints for
+// the Spanner schema.
+func (conv *Conv) profileLine(srcTable, srcCol string, p ColumnProfile) string {
+	s := fmt.Sprintf("Column '%s': %.1f%% NULL, %d distinct value(s)", srcCol, 100*p.NullFraction(), p.Distinct)
+	if p.Min != "" || p.Max != "" {
+		s += fmt.Sprintf(", min %s, max %s", truncateValue(p.Min), truncateValue(p.Max))
+	}
+	if p.Rows == 0 || p.Nulls > 0 {
+		return s
+	}
+	var hints []string
+	spCol := conv.ToSpanner[srcTable].Cols[srcCol]
+	if !conv.SpSchema[conv.ToSpanner[srcTable].Name].ColDefs[spCol].NotNull {
+		hints = append(hints, "no NULL values, consider NOT NULL")
+	}
+	if p.Distinct == p.Rows && !isKeyCol(conv.SpSchema[conv.ToSpanner[srcTable].Name], spCol) {
+		hints = append(hints, "all values distinct, a candidate key")
+	}
+	for _, h := range hints {
+		s += " (" + h + ")"
+	}
+	return s
+}
+
+// truncateValue quotes a value for the report, truncating long values.
+func truncateValue(v string) string {
+	const maxRunes = 40
+	if r := []rune(v); len(r) > maxRunes {
+		v = string(r[:maxRunes]) + "..."
+	}
+	return fmt.Sprintf("%q", v)
+}
@@ -0,0 +1,62 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package internal
+
+import (
+	"testing"
+
+	"github.com/cloudspannerecosystem/harbourbridge/schema"
+	"github.com/cloudspannerecosystem/harbourbridge/spanner/ddl"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestProfileBody(t *testing.T) {
+	conv := MakeConv()
+	conv.SrcSchema["users"] = schema.Table{
+		Name:     "users",
+		ColNames: []string{"id", "email", "age", "avatar"},
+		ColDefs: map[string]schema.Column{
+			"id":     schema.Column{Name: "id", Type: schema.Type{Name: "int8"}},
+			"email":  schema.Column{Name: "email", Type: schema.Type{Name: "text"}},
+			"age":    schema.Column{Name: "age", Type: schema.Type{Name: "int8"}},
+			"avatar": schema.Column{Name: "avatar", Type: schema.Type{Name: "bytea"}},
+		},
+	}
+	conv.SpSchema["users"] = ddl.CreateTable{
+		Name:     "users",
+		ColNames: []string{"id", "email", "age", "avatar"},
+		ColDefs: map[string]ddl.ColumnDef{
+			"id":     ddl.ColumnDef{Name: "id", T: ddl.Type{Name: ddl.Int64}, NotNull: true},
+			"email":  ddl.ColumnDef{Name: "email", T: ddl.Type{Name: ddl.String, Len: ddl.MaxLength}},
+			"age":    ddl.ColumnDef{Name: "age", T: ddl.Type{Name: ddl.Int64}},
+			"avatar": ddl.ColumnDef{Name: "avatar", T: ddl.Type{Name: ddl.Bytes, Len: ddl.MaxLength}},
+		},
+		Pks: []ddl.IndexKey{ddl.IndexKey{Col: "id"}},
+	}
+	conv.ToSpanner["users"] = NameAndCols{Name: "users", Cols: map[string]string{"id": "id", "email": "email", "age": "age", "avatar": "avatar"}}
+	assert.Equal(t, []string{"id", "email", "age"}, conv.ProfiledCols("users"))
+	ok, text := conv.ProfileMinMax("users", "email")
+	assert.True(t, ok)
+	assert.True(t, text)
+
+	conv.SetProfile("users", "id", ColumnProfile{Rows: 100, Distinct: 100, Min: "1", Max: "100"})
+	conv.SetProfile("users", "email", ColumnProfile{Rows: 100, Distinct: 100, Min: "a@example.com", Max: "z@example.com"})
+	conv.SetProfile("users", "age", ColumnProfile{Rows: 100, Nulls: 25, Distinct: 40, Min: "18", Max: "90"})
+	assert.Equal(t, []tableReportBody{{Heading: "Column profiles (sample of 100 rows)", Lines: []string{
+		`Column 'age': 25.0% NULL, 40 distinct value(s), min "18", max "90"`,
+		`Column 'email': 0.0% NULL, 100 distinct value(s), min "a@example.com", max "z@example.com" (no NULL values, consider NOT NULL) (all values distinct, a candidate key)`,
+		`Column 'id': 0.0% NULL, 100 distinct value(s), min "1", max "100"`,
+	}}}, buildProfileBody(conv, "users"))
+}
@@ -16,22 +16,42 @@
 // HarbourBridge.
 package internal
 
-import "fmt"
+import (
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"time"
+)
 
 // Progress provides console progress functionality. i.e. it reports what
 // percentage of a task is complete to the console, overwriting previous
-// progress percentage with new progress.
+// progress percentage with new progress. Its methods can be called
+// concurrently.
 type Progress struct {
+	mu       sync.Mutex
 	total    int64  // How much we have to do.
 	progress int64  // How much we have done so far.
 	pct      int    // Percentage done i.e. progress/total * 100
 	message  string // Name of task being monitored.
 	verbose  bool   // If true, print detailed info about each progress step.
+	// out is where percentages are printed (nil means os.Stdout, as it is
+	// when they are printed).
+	out io.Writer
+	// sink, if not nil, is sent a TaskEvent for each percentage.
+	sink func(ProgressEvent)
 }
 
 // NewProgress creates and returns a Progress instance.
 func NewProgress(total int64, message string, verbose bool) *Progress {
-	p := &Progress{total, 0, 0, message, verbose}
+	return NewProgressTo(total, message, verbose, nil, nil)
+}
+
+// NewProgressTo creates and returns a Progress instance that prints
+// percentages to out (nil means the standard output), and sends them to
+// sink as TaskEvents, if sink isn't nil.
+func NewProgressTo(total int64, message string, verbose bool, out io.Writer, sink func(ProgressEvent)) *Progress {
+	p := &Progress{total: total, message: message, verbose: verbose, out: out, sink: sink}
 	if total == 0 {
 		p.pct = 100
 	}
@@ -44,6 +64,8 @@ func NewProgress(total int64, message string, verbose bool) *Progress {
 // MaybeReport will print out the new percentage, overwriting the previous
 // percentage.
 func (p *Progress) MaybeReport(progress int64) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
 	if progress > p.progress {
 		p.progress = progress
 		var pct int
@@ -70,16 +92,23 @@ func (p *Progress) Done() {
 }
 
 func (p *Progress) report(firstCall bool) {
+	if p.sink != nil {
+		p.sink(ProgressEvent{Time: time.Now(), Kind: TaskEvent, Detail: p.message, Percent: p.pct})
+	}
+	out := p.out
+	if out == nil {
+		out = os.Stdout
+	}
 	if p.verbose {
-		fmt.Printf("%s: %2d%%\n", p.message, p.pct)
+		fmt.Fprintf(out, "%s: %2d%%\n", p.message, p.pct)
 		return
 	}
 	if firstCall {
-		fmt.Printf("%s: %2d%%", p.message, p.pct)
+		fmt.Fprintf(out, "%s: %2d%%", p.message, p.pct)
 	} else {
-		fmt.Printf("\b\b\b%2d%%", p.pct)
+		fmt.Fprintf(out, "\b\b\b%2d%%", p.pct)
 	}
 	if p.pct == 100 {
-		fmt.Printf("\n")
+		fmt.Fprintf(out, "\n")
 	}
 }
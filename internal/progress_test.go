@@ -15,6 +15,10 @@
 package internal
 
 import (
+	"bytes"
+	"sort"
+	"strings"
+	"sync"
 	"testing"
 	"time"
 
@@ -47,3 +51,30 @@ func TestDone(t *testing.T) {
 	p.Done()
 	assert.Equal(t, 100, p.pct)
 }
+
+func TestProgressTo(t *testing.T) {
+	var out bytes.Buffer
+	var pcts []int
+	sink := func(e ProgressEvent) {
+		assert.Equal(t, TaskEvent, e.Kind)
+		assert.Equal(t, "Task", e.Detail)
+		pcts = append(pcts, e.Percent)
+	}
+	p := NewProgressTo(10, "Task", false, &out, sink)
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func(i int64) {
+			defer wg.Done()
+			p.MaybeReport(i)
+		}(int64(i + 1))
+	}
+	wg.Wait()
+	p.Done()
+	assert.Equal(t, 100, p.pct)
+	assert.Equal(t, 0, pcts[0])
+	assert.Equal(t, 100, pcts[len(pcts)-1])
+	assert.True(t, sort.IntsAreSorted(pcts))
+	assert.True(t, strings.HasPrefix(out.String(), "Task:  0%"))
+	assert.True(t, strings.HasSuffix(out.String(), "100%\n"))
+}
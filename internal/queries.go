@@ -0,0 +1,240 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package internal
+
+import (
+	"regexp"
+	"strings"
+)
+
+// SplitQueries splits s into SQL statements separated by semicolons,
+// ignoring semicolons in string literals, quoted identifiers and comments.
+// Empty statements are dropped.
+func SplitQueries(s string) []string {
+	var l []string
+	start := 0
+	for _, t := range tokenizeQuery(s) {
+		if t.text == ";" {
+			if q := strings.TrimSpace(s[start:t.pos]); q != "" {
+				l = append(l, q)
+			}
+			start = t.pos + 1
+		}
+	}
+	if q := strings.TrimSpace(s[start:]); q != "" {
+		l = append(l, q)
+	}
+	return l
+}
+
+// RewriteQuery replaces the source table and column names used in query q
+// by their Spanner names (see Renames). Table names qualified by a schema
+// (e.g. sales.orders or public.orders) are matched as a whole. A column name is only replaced if all source tables with that
+// column agree on its Spanner name.
+func RewriteQuery(conv *Conv, q string) string {
+	tables := make(map[string]string)
+	cols := make(map[string]string)
+	ambiguous := make(map[string]bool)
+	for srcTable, sp := range conv.ToSpanner {
+		tables[srcTable] = sp.Name
+		for srcCol, spCol := range sp.Cols {
+			if c, ok := cols[srcCol]; ok && c != spCol {
+				ambiguous[srcCol] = true
+			}
+			cols[srcCol] = spCol
+		}
+	}
+	// lookup returns the Spanner name of name if it was renamed.
+	lookup := func(name string, m map[string]string) (string, bool) {
+		if n, ok := m[name]; ok {
+			return n, n != name
+		}
+		// Unquoted identifiers are case-insensitive.
+		for k, n := range m {
+			if strings.EqualFold(k, name) {
+				return n, n != k
+			}
+		}
+		return "", false
+	}
+	toks := tokenizeQuery(q)
+	var b strings.Builder
+	last := 0
+	for i := 0; i < len(toks); i++ {
+		t := toks[i]
+		if !t.ident {
+			continue
+		}
+		name, end := t.name, t.pos+len(t.text)
+		// Qualified table name: schema.table.
+		if i+2 < len(toks) && toks[i+1].text == "." && toks[i+2].ident && toks[i+1].pos == end && toks[i+2].pos == end+1 {
+			qualified := name + "." + toks[i+2].name
+			if name == "public" {
+				qualified = toks[i+2].name
+			}
+			if sp, ok := lookup(qualified, tables); ok {
+				b.WriteString(q[last:t.pos])
+				b.WriteString(sp)
+				last = toks[i+2].pos + len(toks[i+2].text)
+				i += 2
+				continue
+			}
+		}
+		sp, ok := lookup(name, tables)
+		if _, table := tables[name]; !ok && !table && !ambiguous[name] {
+			sp, ok = lookup(name, cols)
+		}
+		if ok {
+			b.WriteString(q[last:t.pos])
+			b.WriteString(sp)
+			last = end
+		}
+	}
+	b.WriteString(q[last:])
+	return b.String()
+}
+
+// queryHints are source SQL constructs that Spanner doesn't support, with
+// the Spanner alternative.
+var queryHints = []struct {
+	re   *regexp.Regexp
+	hint string
+}{
+	{regexp.MustCompile(`(?i)\bILIKE\b`), "ILIKE is not supported: use LOWER(x) LIKE LOWER(y)"},
+	{regexp.MustCompile(`::`), "'::' casts are PostgreSQL syntax: use CAST(x AS type) for GoogleSQL"},
+	{regexp.MustCompile(`(?i)\bON\s+CONFLICT\b`), "ON CONFLICT is not supported: use INSERT OR UPDATE / INSERT OR IGNORE, or mutations"},
+	{regexp.MustCompile(`(?i)\bON\s+DUPLICATE\s+KEY\b`), "ON DUPLICATE KEY UPDATE is not supported: use INSERT OR UPDATE, or mutations"},
+	{regexp.MustCompile(`(?i)\bFOR\s+(UPDATE|SHARE)\b`), "SELECT ... FOR UPDATE is not needed: Spanner read-write transactions lock what they read"},
+	{regexp.MustCompile(`(?i)\bRETURNING\b`), "RETURNING is PostgreSQL syntax: use THEN RETURN for GoogleSQL"},
+	{regexp.MustCompile(`(?i)\bLIMIT\s+\d+\s*,\s*\d+`), "LIMIT offset, count is MySQL syntax: use LIMIT count OFFSET offset"},
+	{regexp.MustCompile(`(?i)\b(NOW|CURDATE|SYSDATE|GETDATE)\s*\(`), "use CURRENT_TIMESTAMP() or CURRENT_DATE() for the current time"},
+	{regexp.MustCompile(`(?i)\bnextval\s*\(`), "use GET_NEXT_SEQUENCE_VALUE(SEQUENCE name) to get sequence values"},
+	{regexp.MustCompile(`(?i)\bREPLACE\s+INTO\b`), "REPLACE INTO is not supported: use INSERT OR UPDATE, or mutations"},
+}
+
+// QueryHints returns suggestions for the constructs of query q that
+// Spanner is known not to support. They are meant to explain why Spanner
+// rejects q.
+func QueryHints(q string) []string {
+	// Only look at code: string literals and comments don't matter.
+	var code strings.Builder
+	end := 0
+	for _, t := range tokenizeQuery(q) {
+		if t.pos > end {
+			code.WriteString(" ")
+		}
+		if t.literal {
+			code.WriteString("''")
+		} else {
+			code.WriteString(t.text)
+		}
+		end = t.pos + len(t.text)
+	}
+	var l []string
+	for _, h := range queryHints {
+		if h.re.MatchString(code.String()) {
+			l = append(l, h.hint)
+		}
+	}
+	return l
+}
+
+// queryToken is a token of a SQL statement: an identifier, a string
+// literal or a single character (comments and spaces are dropped).
+type queryToken struct {
+	text    string
+	pos     int    // Offset of text in the statement.
+	ident   bool   // Unquoted or quoted identifier.
+	name    string // For identifiers, the name without quotes.
+	literal bool   // String literal.
+}
+
+func tokenizeQuery(s string) []queryToken {
+	var l []queryToken
+	for i := 0; i < len(s); {
+		c := s[i]
+		switch {
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r':
+			i++
+		case strings.HasPrefix(s[i:], "--"):
+			j := strings.IndexByte(s[i:], '\n')
+			if j < 0 {
+				j = len(s) - i
+			}
+			i += j
+		case strings.HasPrefix(s[i:], "/*"):
+			j := strings.Index(s[i+2:], "*/")
+			if j < 0 {
+				i = len(s)
+			} else {
+				i += j + 4
+			}
+		case c == '\'' || c == '"' || c == '`':
+			// Quotes are escaped by doubling them.
+			j := i + 1
+			for j < len(s) {
+				if s[j] == c {
+					if j+1 < len(s) && s[j+1] == c {
+						j += 2
+						continue
+					}
+					break
+				}
+				if s[j] == '\\' && c == '\'' {
+					j++
+				}
+				j++
+			}
+			if j < len(s) {
+				j++
+			}
+			t := queryToken{text: s[i:j], pos: i}
+			if c == '\'' {
+				t.literal = true
+			} else {
+				t.ident = true
+				name := t.text[1:]
+				if strings.HasSuffix(name, string(c)) {
+					name = name[:len(name)-1]
+				}
+				t.name = strings.ReplaceAll(name, string([]byte{c, c}), string(c))
+			}
+			l = append(l, t)
+			i = j
+		case isIdentStart(c):
+			j := i + 1
+			for j < len(s) && (isIdentStart(s[j]) || (s[j] >= '0' && s[j] <= '9') || s[j] == '$') {
+				j++
+			}
+			l = append(l, queryToken{text: s[i:j], pos: i, ident: true, name: s[i:j]})
+			i = j
+		case c >= '0' && c <= '9':
+			j := i + 1
+			for j < len(s) && ((s[j] >= '0' && s[j] <= '9') || s[j] == '.') {
+				j++
+			}
+			l = append(l, queryToken{text: s[i:j], pos: i})
+			i = j
+		default:
+			l = append(l, queryToken{text: s[i : i+1], pos: i})
+			i++
+		}
+	}
+	return l
+}
+
+func isIdentStart(c byte) bool {
+	return c == '_' || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z') || c >= 0x80
+}
@@ -0,0 +1,58 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package internal
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSplitQueries(t *testing.T) {
+	assert.Equal(t, []string{
+		"SELECT 'a;b' FROM t",
+		"SELECT \"x;y\" FROM t -- comment; with semicolon\nWHERE a = 1",
+		"/* ; */ DELETE FROM t",
+	}, SplitQueries("SELECT 'a;b' FROM t;\n\nSELECT \"x;y\" FROM t -- comment; with semicolon\nWHERE a = 1;;\n/* ; */ DELETE FROM t"))
+}
+
+func TestRewriteQuery(t *testing.T) {
+	conv := MakeConv()
+	conv.ToSpanner = map[string]NameAndCols{
+		"my table":    {Name: "my_table", Cols: map[string]string{"id": "id", "bad col": "bad_col", "a": "a1"}},
+		"sales.order": {Name: "sales_order", Cols: map[string]string{"id": "id", "a": "a2"}},
+		"users":       {Name: "users", Cols: map[string]string{"user-name": "user_name"}},
+	}
+	cases := []struct {
+		q, expected string
+	}{
+		{`SELECT "bad col", id FROM "my table" WHERE id = 1`, `SELECT bad_col, id FROM my_table WHERE id = 1`},
+		{"SELECT `user-name` FROM users", "SELECT user_name FROM users"},
+		{`SELECT * FROM sales.order JOIN public."my table" ON true`, `SELECT * FROM sales_order JOIN my_table ON true`},
+		{`SELECT a FROM "my table"`, `SELECT a FROM my_table`}, // a is ambiguous.
+		{`SELECT '"bad col"' FROM users`, `SELECT '"bad col"' FROM users`},
+	}
+	for _, tc := range cases {
+		assert.Equal(t, tc.expected, RewriteQuery(conv, tc.q), tc.q)
+	}
+}
+
+func TestQueryHints(t *testing.T) {
+	assert.Equal(t, []string{
+		"ILIKE is not supported: use LOWER(x) LIKE LOWER(y)",
+		"'::' casts are PostgreSQL syntax: use CAST(x AS type) for GoogleSQL",
+	}, QueryHints("SELECT a::text FROM t WHERE b ILIKE 'x' AND c = 'ON CONFLICT'"))
+	assert.Nil(t, QueryHints("SELECT a FROM t"))
+}
@@ -16,8 +16,10 @@ package internal
 
 import (
 	"bufio"
+	"bytes"
 	"fmt"
 	"io"
+	"io/ioutil"
 )
 
 // Reader is a simple line-reader wrapper around bufio.Reader
@@ -58,3 +60,38 @@ func (r *Reader) ReadLine() []byte {
 	}
 	return b
 }
+
+// ReadN returns the next n bytes of input, for input that isn't
+// line-oriented (e.g. binary-format COPY data). It returns fewer than
+// n bytes if it hits eof. Lengths read from malformed input can be huge,
+// so the buffer grows with the input read rather than being allocated up
+// front.
+func (r *Reader) ReadN(n int) []byte {
+	if r.EOF || n <= 0 {
+		return []byte{}
+	}
+	b, err := ioutil.ReadAll(io.LimitReader(r.r, int64(n)))
+	if err != nil {
+		fmt.Printf("Error reading input data: %v\n", err)
+		r.EOF = true
+	} else if len(b) < n {
+		r.EOF = true
+	}
+	m := len(b)
+	r.Offset += m
+	r.LineNumber += bytes.Count(b, []byte{'\n'})
+	if r.progress != nil {
+		r.progress.MaybeReport(int64(r.Offset - 1))
+	}
+	return b
+}
+
+// Peek returns the next n bytes of input without consuming them.
+// It returns fewer than n bytes if there are fewer bytes left.
+func (r *Reader) Peek(n int) []byte {
+	if r.EOF {
+		return []byte{}
+	}
+	b, _ := r.r.Peek(n)
+	return b
+}
@@ -59,3 +59,29 @@ func TestReadLine(t *testing.T) {
 		}
 	}
 }
+
+func TestReadN(t *testing.T) {
+	r := NewReader(bufio.NewReader(strings.NewReader("ab\x00\ncd\nef")), nil)
+	assert.Equal(t, "ab", string(r.Peek(2)))
+	assert.Equal(t, "ab\x00\n", string(r.ReadN(4)))
+	assert.Equal(t, false, r.EOF)
+	assert.Equal(t, 2, r.LineNumber)
+	assert.Equal(t, 5, r.Offset)
+	assert.Equal(t, "cd\n", string(r.ReadLine()))
+	assert.Equal(t, "ef", string(r.ReadN(4)))
+	assert.Equal(t, true, r.EOF)
+	assert.Equal(t, 3, r.LineNumber)
+	assert.Equal(t, 10, r.Offset)
+	assert.Equal(t, "", string(r.Peek(1)))
+}
+
+func TestReadN_HugeLength(t *testing.T) {
+	// Lengths read from malformed binary data can be huge: ReadN must not
+	// allocate them up front.
+	r := NewReader(bufio.NewReader(strings.NewReader("abc")), nil)
+	assert.Equal(t, "", string(r.ReadN(-1)))
+	assert.Equal(t, false, r.EOF)
+	assert.Equal(t, "abc", string(r.ReadN(1<<31-1)))
+	assert.Equal(t, true, r.EOF)
+	assert.Equal(t, 4, r.Offset)
+}
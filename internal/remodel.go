@@ -0,0 +1,466 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package internal
+
+import (
+	"fmt"
+
+	"github.com/cloudspannerecosystem/harbourbridge/spanner/ddl"
+)
+
+// Remodel specifies changes to the table structure of the Spanner schema:
+// primary keys to take from unique indexes, primary keys to reorder,
+// tables to split and tables to merge. Primary keys are changed first
+// (taken from indexes, then reordered), then merges are applied before
+// splits.
+type Remodel struct {
+	PrimaryKeys []PrimaryKeyIndex
+	KeyOrders   []KeyOrder
+	Splits      []SplitTable
+	Merges      []MergeTable
+	// AutoPartition also applies the splits suggested by
+	// SuggestPartitions, after Splits. It should only be set when the
+	// schema is converted: the splits are then recorded in the session
+	// file.
+	AutoPartition bool
+	// Money converts FLOAT64 and FLOAT32 Spanner columns, given as
+	// table.column, to NUMERIC (true), or keeps them as floating point
+	// columns (false). Values of converted columns are parsed exactly from
+	// their decimal text during data conversion, instead of going through
+	// binary floating point, which can't represent most monetary amounts.
+	Money map[string]bool
+	// AutoMoney also converts the columns suggested by SuggestMoneyCols,
+	// unless Money keeps them. As for AutoPartition, it should only be set
+	// when the schema is converted.
+	AutoMoney bool
+	// Bools converts STRING Spanner columns converted from MySQL CHAR(1)
+	// columns holding flags ('Y'/'N', 'T'/'F' or '1'/'0'), given as
+	// table.column, to BOOL (true), or keeps them (false).
+	Bools map[string]bool
+	// AutoBools also converts the columns suggested by SuggestBoolCols,
+	// unless Bools keeps them. It should only be set when the schema is
+	// converted.
+	AutoBools bool
+	// DropIndexes drops Spanner indexes, given by name (true), or keeps
+	// them (false), e.g. to drop the redundant indexes suggested by
+	// SuggestDropIndexes, which slow down data conversion.
+	DropIndexes map[string]bool
+	// AutoDropIndexes also drops the indexes suggested by
+	// SuggestDropIndexes, unless DropIndexes keeps them. It should only be
+	// set when the schema is converted.
+	AutoDropIndexes bool
+	// TrimToLimits drops the indexes and foreign keys of tables that
+	// exceed Spanner's limits on the number of indexes and foreign keys
+	// per table, keeping unique indexes and the most used indexes.
+	TrimToLimits bool
+	// Dictionaries dictionary-encodes STRING columns holding few distinct
+	// values, which are then stored once in lookup tables.
+	Dictionaries []DictionaryColumn
+	// LongKeys changes STRING primary key columns whose values can exceed
+	// Spanner's limit on key size (see LongKey).
+	LongKeys []LongKey
+	// AutoLongKeys also applies the hash strategy to the columns
+	// suggested by SuggestLongKeys, unless LongKeys keeps them. It should
+	// only be set when the schema is converted.
+	AutoLongKeys bool
+	// SyntheticKeys is the name of the KeyGenerator of the synthetic
+	// primary keys added to tables without a primary key (see
+	// KeyGenerators). Empty keeps the current generator.
+	SyntheticKeys string
+}
+
+// SplitTable moves some columns of a Spanner table to a new table that
+// has the same primary key. During data conversion, each row of Table is
+// written as a row of Table and a row of NewTable. Unless Interleave is
+// set, a foreign key from NewTable to Table is added after data
+// conversion.
+type SplitTable struct {
+	Table      string   // Spanner table to split.
+	NewTable   string   // Name of the new Spanner table.
+	Cols       []string // Spanner columns moved to NewTable.
+	Interleave bool     // Interleave NewTable in Table.
+}
+
+// MergeTable merges a Spanner table into another Spanner table with a 1:1
+// relationship i.e. rows of both tables with the same primary key values
+// describe the same entity. Table is removed from the schema, and its
+// (non-key) columns are added to Into as nullable columns. During data
+// conversion, rows of Table update the corresponding rows of Into, once
+// all rows of Into have been written (see ResolveMerges); rows without a
+// corresponding row are reported as bad rows.
+type MergeTable struct {
+	Table string // Spanner table to merge.
+	Into  string // Spanner table it is merged into.
+}
+
+// ApplyRemodel applies primary key changes, splits, merges, column type
+// conversions, dictionary encodings, long key changes, index drops,
+// trimming to Spanner's limits and synthetic key generators to the Spanner
+// schema. Changes that have already been applied (e.g. when conv was read
+// from a session file) are skipped.
+func (conv *Conv) ApplyRemodel(r Remodel) error {
+	for _, p := range r.PrimaryKeys {
+		if err := conv.usePrimaryKeyIndex(p); err != nil {
+			return fmt.Errorf("can't use index %s as primary key of table %s: %w", p.Index, p.Table, err)
+		}
+	}
+	if err := conv.reorderKeys(r.KeyOrders); err != nil {
+		return err
+	}
+	for _, m := range r.Merges {
+		if err := conv.mergeTable(m); err != nil {
+			return fmt.Errorf("can't merge table %s into %s: %w", m.Table, m.Into, err)
+		}
+	}
+	for _, s := range r.Splits {
+		if err := conv.splitTable(s); err != nil {
+			return fmt.Errorf("can't split table %s: %w", s.Table, err)
+		}
+	}
+	if r.AutoPartition {
+		for _, s := range conv.SuggestPartitions() {
+			if err := conv.splitTable(s); err != nil {
+				return fmt.Errorf("can't split table %s: %w", s.Table, err)
+			}
+		}
+	}
+	if err := conv.applyMoneyCols(r.AutoMoney, r.Money); err != nil {
+		return err
+	}
+	if err := conv.applyBoolCols(r.AutoBools, r.Bools); err != nil {
+		return err
+	}
+	for _, d := range r.Dictionaries {
+		if err := conv.applyDictionary(d); err != nil {
+			return fmt.Errorf("can't encode column %s.%s: %w", d.Table, d.Column, err)
+		}
+	}
+	if err := conv.applyLongKeys(r.AutoLongKeys, r.LongKeys); err != nil {
+		return err
+	}
+	if err := conv.applyDropIndexes(r.AutoDropIndexes, r.DropIndexes); err != nil {
+		return err
+	}
+	if r.TrimToLimits {
+		conv.trimToLimits()
+	}
+	if r.SyntheticKeys != "" {
+		return conv.setKeyGenerator(r.SyntheticKeys)
+	}
+	return nil
+}
+
+func (conv *Conv) splitTable(s SplitTable) error {
+	if conv.Splits == nil {
+		conv.Splits = make(map[string][]SplitTable)
+	}
+	for _, x := range conv.Splits[s.Table] {
+		if x.NewTable == s.NewTable {
+			return nil // Already applied.
+		}
+	}
+	ct, ok := conv.SpSchema[s.Table]
+	if !ok {
+		return fmt.Errorf("unknown table")
+	}
+	if conv.isMergeTarget(s.Table) {
+		return fmt.Errorf("tables with merged tables can't be split")
+	}
+	if _, ok := conv.SpSchema[s.NewTable]; ok {
+		return fmt.Errorf("table %s already exists", s.NewTable)
+	}
+	if _, changed := FixName(s.NewTable); changed {
+		return fmt.Errorf("%s is not a valid Spanner table name", s.NewTable)
+	}
+	if len(s.Cols) == 0 {
+		return fmt.Errorf("no columns to move to %s", s.NewTable)
+	}
+	nt := ddl.CreateTable{
+		Name:    s.NewTable,
+		ColDefs: make(map[string]ddl.ColumnDef),
+		Comment: fmt.Sprintf("Split from table %s", s.Table),
+	}
+	var pkCols []string
+	for _, k := range ct.Pks {
+		nt.ColNames = append(nt.ColNames, k.Col)
+		nt.ColDefs[k.Col] = ct.ColDefs[k.Col]
+		nt.Pks = append(nt.Pks, k)
+		pkCols = append(pkCols, k.Col)
+	}
+	move := make(map[string]bool)
+	for _, c := range s.Cols {
+		cd, ok := ct.ColDefs[c]
+		if !ok {
+			return fmt.Errorf("unknown column %s", c)
+		}
+		if err := conv.canDropCol(s.Table, c); err != nil {
+			return fmt.Errorf("can't move column %s: %w", c, err)
+		}
+		nt.ColNames = append(nt.ColNames, c)
+		nt.ColDefs[c] = cd
+		move[c] = true
+	}
+	if s.Interleave {
+		nt.Parent = s.Table
+	} else {
+		nt.Fks = []ddl.Foreignkey{{Name: conv.unusedName(s.NewTable + "_fk"), Columns: pkCols, ReferTable: s.Table, ReferColumns: pkCols}}
+	}
+	var colNames []string
+	for _, c := range ct.ColNames {
+		if !move[c] {
+			colNames = append(colNames, c)
+		}
+	}
+	ct.ColNames = colNames
+	for c := range move {
+		delete(ct.ColDefs, c)
+		delete(conv.ToSource[s.Table].Cols, c)
+	}
+	conv.SpSchema[s.Table] = ct
+	conv.SpSchema[s.NewTable] = nt
+	conv.Splits[s.Table] = append(conv.Splits[s.Table], s)
+	return nil
+}
+
+func (conv *Conv) mergeTable(m MergeTable) error {
+	if conv.MergedTables == nil {
+		conv.MergedTables = make(map[string]MergeTable)
+	}
+	for _, x := range conv.MergedTables {
+		if x == m {
+			return nil // Already applied.
+		}
+	}
+	mt, ok := conv.SpSchema[m.Table]
+	if !ok {
+		return fmt.Errorf("unknown table %s", m.Table)
+	}
+	it, ok := conv.SpSchema[m.Into]
+	if !ok {
+		return fmt.Errorf("unknown table %s", m.Into)
+	}
+	if m.Table == m.Into {
+		return fmt.Errorf("can't merge a table into itself")
+	}
+	if len(conv.Splits[m.Into]) > 0 || len(conv.Splits[m.Table]) > 0 {
+		return fmt.Errorf("split tables can't be merged")
+	}
+	if conv.isMergeTarget(m.Table) {
+		return fmt.Errorf("table %s has tables merged into it", m.Table)
+	}
+	_, ok1 := conv.SyntheticPKeys[m.Table]
+	_, ok2 := conv.SyntheticPKeys[m.Into]
+	if ok1 || ok2 {
+		return fmt.Errorf("tables without primary keys can't be merged")
+	}
+	if len(mt.Pks) != len(it.Pks) {
+		return fmt.Errorf("primary keys have different numbers of columns")
+	}
+	keyMap := make(map[string]string) // Maps key columns of m.Table to those of m.Into.
+	for i, k := range mt.Pks {
+		if mt.ColDefs[k.Col].T != it.ColDefs[it.Pks[i].Col].T {
+			return fmt.Errorf("primary key column %s has a different type from %s", k.Col, it.Pks[i].Col)
+		}
+		keyMap[k.Col] = it.Pks[i].Col
+	}
+	if len(mt.Fks) > 0 || len(mt.Indexes) > 0 {
+		return fmt.Errorf("table %s has foreign keys or secondary indexes", m.Table)
+	}
+	for _, t := range conv.SpSchema {
+		if t.Parent == m.Table {
+			return fmt.Errorf("table %s is interleaved in %s", t.Name, m.Table)
+		}
+		for _, fk := range t.Fks {
+			if fk.ReferTable == m.Table {
+				return fmt.Errorf("table %s is referenced by foreign key %s", m.Table, fk.Name)
+			}
+		}
+	}
+	if mt.Parent != "" {
+		return fmt.Errorf("table %s is interleaved", m.Table)
+	}
+	for _, c := range mt.ColNames {
+		if _, ok := keyMap[c]; ok {
+			continue
+		}
+		if _, ok := it.ColDefs[c]; ok {
+			return fmt.Errorf("both tables have a column %s", c)
+		}
+		cd := mt.ColDefs[c]
+		cd.NotNull = false // Rows of m.Into may have no matching row.
+		cd.Comment = fmt.Sprintf("Merged from table %s", m.Table)
+		it.ColNames = append(it.ColNames, c)
+		it.ColDefs[c] = cd
+	}
+	conv.SpSchema[m.Into] = it
+	src := conv.ToSource[m.Table]
+	nc := conv.ToSpanner[src.Name]
+	nc.Name = m.Into
+	for srcCol, spCol := range nc.Cols {
+		if k, ok := keyMap[spCol]; ok {
+			nc.Cols[srcCol] = k
+		}
+	}
+	conv.ToSpanner[src.Name] = nc
+	delete(conv.ToSource, m.Table)
+	delete(conv.SpSchema, m.Table)
+	conv.MergedTables[src.Name] = m
+	return nil
+}
+
+func (conv *Conv) isMergeTarget(spTable string) bool {
+	for _, m := range conv.MergedTables {
+		if m.Into == spTable {
+			return true
+		}
+	}
+	return false
+}
+
+// DataSchema returns the Spanner schema used to convert rows of spTable:
+// for tables that have been split, it includes the columns that were moved
+// to other tables, and dictionary-encoded and truncated long key columns
+// have the type of their values (they are changed by WriteRow).
+func (conv *Conv) DataSchema(spTable string) (ddl.CreateTable, bool) {
+	ct, ok := conv.SpSchema[spTable]
+	if !ok || (len(conv.Splits[spTable]) == 0 && len(conv.DictCols) == 0 && len(conv.LongKeys) == 0) {
+		return ct, ok
+	}
+	colDefs := make(map[string]ddl.ColumnDef)
+	for c, cd := range ct.ColDefs {
+		if d, ok := conv.DictCols[spTable+"."+c]; ok {
+			cd.T = conv.SpSchema[d.Dictionary].ColDefs["value"].T
+		}
+		if k, ok := conv.LongKeys[spTable+"."+c]; ok && k.Strategy == TruncateLongKey {
+			cd.T = k.Type
+		}
+		colDefs[c] = cd
+	}
+	colNames := append([]string{}, ct.ColNames...)
+	for _, s := range conv.Splits[spTable] {
+		for _, c := range s.Cols {
+			colNames = append(colNames, c)
+			colDefs[c] = conv.SpSchema[s.NewTable].ColDefs[c]
+		}
+	}
+	ct.ColNames = colNames
+	ct.ColDefs = colDefs
+	return ct, true
+}
+
+// HasInterleavedTables returns true if conv.SpSchema contains interleaved
+// tables, other than tables split from their parent with
+// SplitTable.Interleave (their rows are written along with the parent's
+// rows, so they don't depend on the order in which tables are converted).
+func (conv *Conv) HasInterleavedTables() bool {
+	split := make(map[string]bool)
+	for _, l := range conv.Splits {
+		for _, s := range l {
+			if s.Interleave {
+				split[s.NewTable] = true
+			}
+		}
+	}
+	for _, t := range conv.SpSchema {
+		if t.Parent != "" && !split[t.Name] {
+			return true
+		}
+	}
+	return false
+}
+
+// splitRow splits a row of spTable into the row written to spTable and
+// rows for the tables split from it.
+func (conv *Conv) splitRow(spTable string, cols []string, vals []interface{}) ([]string, []interface{}, []tableRow) {
+	splits := conv.Splits[spTable]
+	if len(splits) == 0 {
+		return cols, vals, nil
+	}
+	dest := make(map[string]int) // Maps moved columns to their index in rows.
+	rows := make([]tableRow, len(splits))
+	for i, s := range splits {
+		rows[i].table = s.NewTable
+		rows[i].interleaved = s.Interleave
+		for _, c := range s.Cols {
+			dest[c] = i
+		}
+	}
+	isKey := make(map[string]bool)
+	for _, k := range conv.SpSchema[spTable].Pks {
+		isKey[k.Col] = true
+	}
+	var mainCols []string
+	var mainVals []interface{}
+	for i, c := range cols {
+		if j, ok := dest[c]; ok {
+			rows[j].cols = append(rows[j].cols, c)
+			rows[j].vals = append(rows[j].vals, vals[i])
+			continue
+		}
+		mainCols = append(mainCols, c)
+		mainVals = append(mainVals, vals[i])
+		if isKey[c] {
+			for j := range rows {
+				rows[j].cols = append(rows[j].cols, c)
+				rows[j].vals = append(rows[j].vals, vals[i])
+			}
+		}
+	}
+	return mainCols, mainVals, rows
+}
+
+// tableRow is a row of a table other than the one being converted.
+type tableRow struct {
+	table       string
+	cols        []string
+	vals        []interface{}
+	interleaved bool // table is interleaved in the table being converted.
+}
+
+// SetUpdateSink configures conv to use the specified sink for rows that
+// update existing rows (see MergeTable). If no update sink is configured,
+// such rows are written to the data sink.
+func (conv *Conv) SetUpdateSink(us func(table string, cols []string, values []interface{})) {
+	conv.updateSink = us
+}
+
+// SetChildSink configures conv to use the specified sink for rows of
+// tables interleaved in the table being converted (see SplitTable). The
+// sink must write them after the row written just before them (their
+// parent row). If no child sink is configured, such rows are written to
+// the data sink.
+func (conv *Conv) SetChildSink(cs func(table string, cols []string, values []interface{})) {
+	conv.childSink = cs
+}
+
+// ResolveMerges writes the rows of merged tables (see MergeTable) that
+// were held back during data conversion, as updates of the rows they are
+// merged into. It must be called once all other rows have been written.
+func (conv *Conv) ResolveMerges() {
+	conv.rowsMu.Lock()
+	defer conv.rowsMu.Unlock()
+	sink := conv.updateSink
+	if sink == nil {
+		sink = conv.dataSink
+	}
+	for _, r := range conv.merges {
+		sink(r.spTable, r.cols, r.vals)
+		for _, o := range r.overflow {
+			conv.dataSink(conv.OverflowTables[r.spTable], o.cols, o.vals)
+		}
+		conv.statsAddGoodRow(r.srcTable, conv.DataMode())
+	}
+	conv.merges = nil
+}
@@ -0,0 +1,157 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package internal
+
+import (
+	"testing"
+
+	"github.com/cloudspannerecosystem/harbourbridge/schema"
+	"github.com/cloudspannerecosystem/harbourbridge/spanner/ddl"
+	"github.com/stretchr/testify/assert"
+)
+
+type sinkRow struct {
+	op    string
+	table string
+	cols  []string
+	vals  []interface{}
+}
+
+// remodelTestConv builds a conv with tables 'users' (id, name, bio, photo)
+// and 'settings' (user_id, theme), mapped from source tables of the same
+// names.
+func remodelTestConv() *Conv {
+	conv := MakeConv()
+	addTable := func(name string, cols []string, types []ddl.Type) {
+		st := schema.Table{Name: name, ColNames: cols, ColDefs: make(map[string]schema.Column)}
+		ct := ddl.CreateTable{Name: name, ColNames: cols, ColDefs: make(map[string]ddl.ColumnDef), Pks: []ddl.IndexKey{{Col: cols[0]}}}
+		toSp, toSrc := make(map[string]string), make(map[string]string)
+		for i, c := range cols {
+			st.ColDefs[c] = schema.Column{Name: c}
+			ct.ColDefs[c] = ddl.ColumnDef{Name: c, T: types[i], NotNull: i == 0}
+			toSp[c], toSrc[c] = c, c
+		}
+		conv.SrcSchema[name] = st
+		conv.SpSchema[name] = ct
+		conv.ToSpanner[name] = NameAndCols{Name: name, Cols: toSp}
+		conv.ToSource[name] = NameAndCols{Name: name, Cols: toSrc}
+	}
+	str := ddl.Type{Name: ddl.String, Len: ddl.MaxLength}
+	addTable("users", []string{"id", "name", "bio", "photo"}, []ddl.Type{{Name: ddl.Int64}, str, str, {Name: ddl.Bytes, Len: ddl.MaxLength}})
+	addTable("settings", []string{"user_id", "theme"}, []ddl.Type{{Name: ddl.Int64}, str})
+	return conv
+}
+
+func remodelTestSinks(conv *Conv) *[]sinkRow {
+	var rows []sinkRow
+	conv.SetDataMode()
+	conv.SetDataSink(func(table string, cols []string, vals []interface{}) {
+		rows = append(rows, sinkRow{"insert", table, cols, vals})
+	})
+	conv.SetUpdateSink(func(table string, cols []string, vals []interface{}) {
+		rows = append(rows, sinkRow{"update", table, cols, vals})
+	})
+	return &rows
+}
+
+func TestSplitTable(t *testing.T) {
+	conv := remodelTestConv()
+	r := Remodel{Splits: []SplitTable{{Table: "users", NewTable: "user_media", Cols: []string{"bio", "photo"}}}}
+	assert.Nil(t, conv.ApplyRemodel(r))
+	assert.Equal(t, []string{"id", "name"}, conv.SpSchema["users"].ColNames)
+	nt := conv.SpSchema["user_media"]
+	assert.Equal(t, []string{"id", "bio", "photo"}, nt.ColNames)
+	assert.Equal(t, []ddl.IndexKey{{Col: "id"}}, nt.Pks)
+	assert.Equal(t, []ddl.Foreignkey{{Name: "user_media_fk", Columns: []string{"id"}, ReferTable: "users", ReferColumns: []string{"id"}}}, nt.Fks)
+	ds, ok := conv.DataSchema("users")
+	assert.True(t, ok)
+	assert.Equal(t, []string{"id", "name", "bio", "photo"}, ds.ColNames)
+	// Applying the split again (e.g. after reading a session file) is a no-op.
+	assert.Nil(t, conv.ApplyRemodel(r))
+	assert.Equal(t, 3, len(conv.SpSchema))
+
+	rows := remodelTestSinks(conv)
+	conv.WriteRow("users", "users", []string{"id", "name", "bio"}, []interface{}{int64(1), "ada", "analyst"})
+	assert.Equal(t, []sinkRow{
+		{"insert", "users", []string{"id", "name"}, []interface{}{int64(1), "ada"}},
+		{"insert", "user_media", []string{"id", "bio"}, []interface{}{int64(1), "analyst"}},
+	}, *rows)
+	assert.Equal(t, int64(1), conv.Stats.GoodRows["users"])
+}
+
+func TestMergeTable(t *testing.T) {
+	conv := remodelTestConv()
+	r := Remodel{Merges: []MergeTable{{Table: "settings", Into: "users"}}}
+	assert.Nil(t, conv.ApplyRemodel(r))
+	_, ok := conv.SpSchema["settings"]
+	assert.False(t, ok)
+	users := conv.SpSchema["users"]
+	assert.Equal(t, []string{"id", "name", "bio", "photo", "theme"}, users.ColNames)
+	assert.False(t, users.ColDefs["theme"].NotNull)
+	spTable, err := GetSpannerTable(conv, "settings")
+	assert.Nil(t, err)
+	assert.Equal(t, "users", spTable)
+	spCols, err := GetSpannerCols(conv, "settings", []string{"user_id", "theme"})
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"id", "theme"}, spCols)
+	// Applying the merge again (e.g. after reading a session file) is a no-op.
+	assert.Nil(t, conv.ApplyRemodel(r))
+
+	rows := remodelTestSinks(conv)
+	conv.Policies.Duplicates = SidelineDuplicates
+	conv.WriteRow("settings", "users", []string{"id", "theme"}, []interface{}{int64(1), "dark"})
+	conv.WriteRow("users", "users", []string{"id", "name"}, []interface{}{int64(1), "ada"})
+	assert.Equal(t, []sinkRow{{"insert", "users", []string{"id", "name"}, []interface{}{int64(1), "ada"}}}, *rows)
+	conv.ResolveMerges()
+	assert.Equal(t, []sinkRow{
+		{"insert", "users", []string{"id", "name"}, []interface{}{int64(1), "ada"}},
+		{"update", "users", []string{"id", "theme"}, []interface{}{int64(1), "dark"}},
+	}, *rows)
+	assert.Equal(t, int64(1), conv.Stats.GoodRows["users"])
+	assert.Equal(t, int64(1), conv.Stats.GoodRows["settings"])
+	assert.Equal(t, int64(0), conv.BadRows())
+}
+
+func TestApplyRemodel_Errors(t *testing.T) {
+	for _, tc := range []struct {
+		name    string
+		remodel Remodel
+		setup   func(conv *Conv)
+	}{
+		{"split unknown table", Remodel{Splits: []SplitTable{{Table: "nope", NewTable: "x", Cols: []string{"bio"}}}}, nil},
+		{"split unknown column", Remodel{Splits: []SplitTable{{Table: "users", NewTable: "x", Cols: []string{"nope"}}}}, nil},
+		{"split key column", Remodel{Splits: []SplitTable{{Table: "users", NewTable: "x", Cols: []string{"id"}}}}, nil},
+		{"split to existing table", Remodel{Splits: []SplitTable{{Table: "users", NewTable: "settings", Cols: []string{"bio"}}}}, nil},
+		{"split bad name", Remodel{Splits: []SplitTable{{Table: "users", NewTable: "user media", Cols: []string{"bio"}}}}, nil},
+		{"merge into itself", Remodel{Merges: []MergeTable{{Table: "users", Into: "users"}}}, nil},
+		{"merge key types differ", Remodel{Merges: []MergeTable{{Table: "settings", Into: "users"}}}, func(conv *Conv) {
+			conv.SpSchema["settings"].ColDefs["user_id"] = ddl.ColumnDef{Name: "user_id", T: ddl.Type{Name: ddl.String, Len: 10}}
+		}},
+		{"merge column clash", Remodel{Merges: []MergeTable{{Table: "settings", Into: "users"}}}, func(conv *Conv) {
+			conv.SpSchema["users"].ColDefs["theme"] = ddl.ColumnDef{Name: "theme", T: ddl.Type{Name: ddl.Int64}}
+		}},
+		{"merge referenced table", Remodel{Merges: []MergeTable{{Table: "users", Into: "settings"}}}, func(conv *Conv) {
+			ct := conv.SpSchema["settings"]
+			ct.Fks = []ddl.Foreignkey{{Name: "fk", Columns: []string{"user_id"}, ReferTable: "users", ReferColumns: []string{"id"}}}
+			conv.SpSchema["settings"] = ct
+		}},
+	} {
+		conv := remodelTestConv()
+		if tc.setup != nil {
+			tc.setup(conv)
+		}
+		assert.NotNil(t, conv.ApplyRemodel(tc.remodel), tc.name)
+	}
+}
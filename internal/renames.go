@@ -0,0 +1,46 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package internal
+
+// TableRename describes how a source table and its columns are named in
+// the Spanner schema.
+type TableRename struct {
+	SrcTable string
+	SpTable  string
+	Cols     map[string]string // Maps renamed source columns to Spanner columns.
+}
+
+// Renames returns the source tables that have a different name in Spanner,
+// or that have columns with a different name in Spanner, ordered according
+// to conv.Ordering. Dropped columns are left out.
+func Renames(conv *Conv) []TableRename {
+	var l []TableRename
+	for _, srcTable := range conv.SrcTables() {
+		sp, ok := conv.ToSpanner[srcTable]
+		if !ok {
+			continue
+		}
+		r := TableRename{SrcTable: srcTable, SpTable: sp.Name, Cols: make(map[string]string)}
+		for srcCol, spCol := range sp.Cols {
+			if spCol != srcCol && !conv.IsDroppedCol(srcTable, srcCol) {
+				r.Cols[srcCol] = spCol
+			}
+		}
+		if r.SpTable != srcTable || len(r.Cols) > 0 {
+			l = append(l, r)
+		}
+	}
+	return l
+}
@@ -0,0 +1,39 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package internal
+
+import (
+	"testing"
+
+	"github.com/cloudspannerecosystem/harbourbridge/schema"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRenames(t *testing.T) {
+	conv := MakeConv()
+	conv.SrcSchema["my table"] = schema.Table{Name: "my table", ColNames: []string{"id", "bad col"}}
+	conv.SrcSchema["fine"] = schema.Table{Name: "fine", ColNames: []string{"a"}}
+	conv.SrcSchema["cols"] = schema.Table{Name: "cols", ColNames: []string{"Id", "ID", "gone col"}, ColDefs: map[string]schema.Column{
+		"Id": {Name: "Id"}, "ID": {Name: "ID"}, "gone col": {Name: "gone col"}}}
+	for _, t := range []string{"my table", "fine", "cols"} {
+		GetSpannerTable(conv, t)
+		GetSpannerCols(conv, t, conv.SrcSchema[t].ColNames)
+	}
+	conv.DropColumns([]string{"cols.gone col"})
+	assert.Equal(t, []TableRename{
+		{SrcTable: "cols", SpTable: "cols", Cols: map[string]string{"ID": "ID_1"}},
+		{SrcTable: "my table", SpTable: "my_table", Cols: map[string]string{"bad col": "bad_col"}},
+	}, Renames(conv))
+}
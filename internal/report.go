@@ -27,7 +27,30 @@ import (
 // GenerateReport analyzes schema and data conversion stats and writes a
 // detailed report to w and returns a brief summary (as a string).
 func GenerateReport(driverName string, conv *Conv, w *bufio.Writer, badWrites map[string]int64, printTableReports bool, printUnexpecteds bool) string {
+	return GenerateTablesReport(driverName, conv, w, badWrites, printTableReports, printUnexpecteds, nil)
+}
+
+// GenerateTablesReport is GenerateReport, with the table-by-table listing
+// restricted to the source tables in tables (nil means all tables). The
+// summary still covers all tables.
+func GenerateTablesReport(driverName string, conv *Conv, w *bufio.Writer, badWrites map[string]int64, printTableReports bool, printUnexpecteds bool, tables map[string]bool) string {
 	reports := AnalyzeTables(conv, badWrites)
+	summary := writeReportIntro(driverName, conv, w, reports, badWrites, "a table-by-table listing of schema and data conversion details")
+	if printTableReports {
+		for _, t := range reports {
+			if tables == nil || tables[t.SrcTable] {
+				writeTableReport(conv, w, t)
+			}
+		}
+	}
+	writeReportEnd(driverName, conv, w, printUnexpecteds)
+	return summary
+}
+
+// writeReportIntro writes the summary of the report, and the statements
+// processed for dumps, and returns the summary. listing describes the
+// rest of the report.
+func writeReportIntro(driverName string, conv *Conv, w *bufio.Writer, reports []tableReport, badWrites map[string]int64, listing string) string {
 	summary := GenerateSummary(conv, reports, badWrites)
 	writeHeading(w, "Summary of Conversion")
 	w.WriteString(summary)
@@ -48,7 +71,7 @@ func GenerateReport(driverName string, conv *Conv, w *bufio.Writer, badWrites ma
 		statementsMsg = "stats on the " + driverName + " statements processed, followed by "
 	}
 	justifyLines(w, "The remainder of this report provides "+statementsMsg+
-		"a table-by-table listing of schema and data conversion details. "+
+		listing+". "+
 		"For background on the schema and data conversion process used, "+
 		"and explanations of the terms and notes used in this "+
 		"report, see HarbourBridge's README.", 80, 0)
@@ -56,28 +79,66 @@ func GenerateReport(driverName string, conv *Conv, w *bufio.Writer, badWrites ma
 	if isDump {
 		writeStmtStats(driverName, conv, w)
 	}
-	if printTableReports {
-		for _, t := range reports {
-			h := fmt.Sprintf("Table %s", t.SrcTable)
-			if t.SrcTable != t.SpTable {
-				h = h + fmt.Sprintf(" (mapped to Spanner table %s)", t.SpTable)
-			}
-			writeHeading(w, h)
-			w.WriteString(rateConversion(t.rows, t.badRows, t.Cols, t.Warnings, t.SyntheticPKey != "", false, conv.SchemaMode()))
-			w.WriteString("\n")
-			for _, x := range t.Body {
-				fmt.Fprintf(w, "%s\n", x.Heading)
-				for i, l := range x.Lines {
-					justifyLines(w, fmt.Sprintf("%d) %s.\n", i+1, l), 80, 3)
-				}
-				w.WriteString("\n")
+	return summary
+}
+
+func writeTableReport(conv *Conv, w *bufio.Writer, t tableReport) {
+	h := fmt.Sprintf("Table %s", t.SrcTable)
+	if t.SrcTable != t.SpTable {
+		h = h + fmt.Sprintf(" (mapped to Spanner table %s)", t.SpTable)
+	}
+	writeHeading(w, h)
+	w.WriteString(rateConversion(t.rows, t.badRows, t.Cols, t.Warnings, t.SyntheticPKey != "", false, conv.SchemaMode()))
+	w.WriteString("\n")
+	for _, x := range t.Body {
+		fmt.Fprintf(w, "%s\n", x.Heading)
+		for i, l := range x.Lines {
+			justifyLines(w, fmt.Sprintf("%d) %s.\n", i+1, l), 80, 3)
+		}
+		w.WriteString("\n")
+	}
+}
+
+// writeReportEnd writes the parts of the report that follow the
+// table-by-table listing.
+func writeReportEnd(driverName string, conv *Conv, w *bufio.Writer, printUnexpecteds bool) {
+	if l := skippedTablesReport(conv); len(l) > 0 {
+		writeHeading(w, "Skipped Tables")
+		for i, x := range l {
+			justifyLines(w, fmt.Sprintf("%d) %s.\n", i+1, x), 80, 3)
+		}
+		w.WriteString("\n")
+	}
+	if l := sequenceReport(conv); len(l) > 0 {
+		writeHeading(w, "Sequences")
+		for i, x := range l {
+			justifyLines(w, fmt.Sprintf("%d) %s.\n", i+1, x), 80, 3)
+		}
+		w.WriteString("\n")
+	}
+	if l := triggerReport(conv); len(l) > 0 {
+		writeHeading(w, "Triggers")
+		for i, x := range l {
+			justifyLines(w, fmt.Sprintf("%d) %s.\n", i+1, x), 80, 3)
+		}
+		w.WriteString("\n")
+	}
+	if groups := conv.ErrorGroups(10); len(groups) > 0 {
+		writeHeading(w, "Data Conversion Errors")
+		w.WriteString("Rows that couldn't be converted, grouped by cause (most frequent first),\n")
+		w.WriteString("with examples. Causes are the same error of the same column, for values\n")
+		w.WriteString("of the same shape (digits are 9, letters a).\n")
+		for i, g := range groups {
+			justifyLines(w, fmt.Sprintf("%d) %s.\n", i+1, g), 80, 3)
+			for _, x := range g.Examples {
+				fmt.Fprintf(w, "   e.g. %s\n", x)
 			}
 		}
+		w.WriteString("\n")
 	}
 	if printUnexpecteds {
 		writeUnexpectedConditions(driverName, conv, w)
 	}
-	return summary
 }
 
 type tableReport struct {
@@ -97,14 +158,19 @@ type tableReportBody struct {
 }
 
 func AnalyzeTables(conv *Conv, badWrites map[string]int64) (r []tableReport) {
-	// Process tables in alphabetical order. This ensures that tables
-	// appear in alphabetical order in report.txt.
-	var tables []string
-	for t := range conv.SrcSchema {
-		tables = append(tables, t)
-	}
-	sort.Strings(tables)
-	for _, srcTable := range tables {
+	// Process tables in the order given by conv.Ordering. This ensures
+	// that tables appear in the same order in report.txt and the DDL.
+	if conv.Spilling() {
+		err := conv.ForEachSpilled(false, func(srcTable string) error {
+			r = append(r, buildTableReport(conv, srcTable, badWrites))
+			return nil
+		})
+		if err != nil {
+			conv.Unexpected(fmt.Sprintf("report: %s", err))
+		}
+		return r
+	}
+	for _, srcTable := range conv.SrcTables() {
 		r = append(r, buildTableReport(conv, srcTable, badWrites))
 	}
 	return r
@@ -132,7 +198,34 @@ func buildTableReport(conv *Conv, srcTable string, badWrites map[string]int64) t
 	}
 	if !conv.SchemaMode() {
 		fillRowStats(conv, srcTable, badWrites, &tr)
+		tr.Body = append(tr.Body, buildExcludedBody(conv, srcTable)...)
+		tr.Body = append(tr.Body, buildTenantBody(conv, srcTable)...)
+		tr.Body = append(tr.Body, buildSpecialValuesBody(conv, srcTable)...)
+		tr.Body = append(tr.Body, buildOversizeBody(conv, srcTable)...)
+		tr.Body = append(tr.Body, buildLongStringsBody(conv, srcTable, spTable)...)
+		tr.Body = append(tr.Body, buildOrphansBody(conv, srcTable)...)
+		tr.Body = append(tr.Body, buildDuplicatesBody(conv, srcTable)...)
+		tr.Body = append(tr.Body, buildNotNullBody(conv, srcTable)...)
+		tr.Body = append(tr.Body, buildCharPaddingBody(conv, srcTable)...)
+		tr.Body = append(tr.Body, buildEmptyStringsBody(conv, srcTable)...)
+		tr.Body = append(tr.Body, buildDateRulesBody(conv, srcTable)...)
+		tr.Body = append(tr.Body, buildValueDictionariesBody(conv, srcTable, spTable)...)
 	}
+	tr.Body = append(tr.Body, buildRelaxedNotNullBody(conv, srcTable)...)
+	tr.Body = append(tr.Body, buildRenamesBody(conv, srcTable, spTable)...)
+	tr.Body = append(tr.Body, buildUniqueConstraintsBody(conv, srcTable, spTable)...)
+	tr.Body = append(tr.Body, buildDroppedColsBody(conv, srcTable)...)
+	tr.Body = append(tr.Body, buildMasksBody(conv, srcTable)...)
+	tr.Body = append(tr.Body, buildComputedColsBody(conv, spTable)...)
+	tr.Body = append(tr.Body, buildRemodelBody(conv, srcTable, spTable)...)
+	tr.Body = append(tr.Body, buildPartitionBody(conv, srcTable, spTable)...)
+	tr.Body = append(tr.Body, buildMoneyBody(conv, spTable)...)
+	tr.Body = append(tr.Body, buildBoolBody(conv, spTable)...)
+	tr.Body = append(tr.Body, buildRedundantIndexesBody(conv, spTable)...)
+	tr.Body = append(tr.Body, buildLimitsBody(conv, spTable)...)
+	tr.Body = append(tr.Body, buildStringLensBody(conv, spTable)...)
+	tr.Body = append(tr.Body, buildCollationBody(conv, srcTable, spTable)...)
+	tr.Body = append(tr.Body, buildProfileBody(conv, srcTable)...)
 	return tr
 }
 
@@ -145,12 +238,12 @@ func buildTableReportBody(conv *Conv, srcTable string, issues map[string][]Schem
 		{"Warning", warning},
 		{"Note", note},
 	} {
-		// Print out issues is alphabetical column order.
+		// Print out issues in the column order given by conv.Ordering.
 		var cols []string
 		for t := range issues {
 			cols = append(cols, t)
 		}
-		sort.Strings(cols)
+		conv.orderCols(srcTable, cols)
 		var l []string
 		if syntheticPK != nil {
 			// Warnings about synthetic primary keys must be handled as a special case
@@ -203,6 +296,8 @@ func buildTableReportBody(conv *Conv, srcTable string, issues map[string][]Schem
 					l = append(l, fmt.Sprintf("Some columns have source DB type 'datetime' which is mapped to Spanner type timestamp e.g. column '%s'. %s", srcCol, IssueDB[i].Brief))
 				case Widened:
 					l = append(l, fmt.Sprintf("%s e.g. for column '%s', source DB type %s is mapped to Spanner type %s", IssueDB[i].Brief, srcCol, srcType, spType))
+				case IndexPrefix:
+					l = append(l, fmt.Sprintf("Column '%s' is indexed with a prefix length. %s", srcCol, IssueDB[i].Brief))
 				default:
 					l = append(l, fmt.Sprintf("Column '%s': type %s is mapped to %s. %s", srcCol, srcType, spType, IssueDB[i].Brief))
 				}
@@ -220,6 +315,480 @@ func buildTableReportBody(conv *Conv, srcTable string, issues map[string][]Schem
 	return body
 }
 
+// buildSpecialValuesBody lists, for each column of srcTable, how many
+// special values (such as 'infinity' dates or NaN numerics) were found
+// during data conversion and how they were handled.
+func buildSpecialValuesBody(conv *Conv, srcTable string) []tableReportBody {
+	var action string
+	switch conv.Policies.SpecialValues {
+	case ClampSpecialValues:
+		action = "clamped to the closest value Spanner supports (NaN values were converted to NULL)"
+	case NullSpecialValues:
+		action = "converted to NULL"
+	default:
+		action = "rejected (the rows containing them were not written)"
+	}
+	return buildColCountBody(conv, srcTable, fmt.Sprintf("Special values (policy: %s)", conv.Policies.SpecialValues), conv.Stats.SpecialValues[srcTable],
+		func(col string, n int64) string {
+			return fmt.Sprintf("Column '%s': %d special values (e.g. infinity, NaN) were %s", col, n, action)
+		})
+}
+
+// buildOversizeBody lists, for each Spanner column of srcTable, how many
+// values exceeded Spanner's limit on the size of a STRING or BYTES value
+// and how they were handled.
+func buildOversizeBody(conv *Conv, srcTable string) []tableReportBody {
+	var action string
+	switch conv.Policies.Oversize {
+	case TruncateOversize:
+		action = "truncated"
+	case OverflowOversize:
+		if ot, ok := conv.OverflowTables[conv.ToSpanner[srcTable].Name]; ok {
+			action = "moved to overflow table " + ot
+		} else {
+			// applyOversizePolicy sidelines rows of tables without an
+			// overflow table.
+			action = "dropped (the table has no overflow table, so the rows containing them were not written)"
+		}
+	default:
+		action = "dropped (the rows containing them were not written)"
+	}
+	return buildColCountBody(conv, srcTable, fmt.Sprintf("Oversize values (policy: %s)", conv.Policies.Oversize), conv.Stats.Oversize[srcTable],
+		func(col string, n int64) string {
+			return fmt.Sprintf("Column '%s': %d values larger than %d bytes were %s", col, n, MaxCellBytes, action)
+		})
+}
+
+// buildLongStringsBody lists, for each STRING(n) Spanner column of
+// srcTable, how many values were longer than the column.
+func buildLongStringsBody(conv *Conv, srcTable, spTable string) []tableReportBody {
+	return buildColCountBody(conv, srcTable, "Values too long for their column", conv.Stats.LongStrings[srcTable],
+		func(col string, n int64) string {
+			return fmt.Sprintf("Column '%s': %d values longer than %s were dropped (the rows containing them were not written); the longest has %d characters (see -long-strings)",
+				col, n, conv.SpSchema[spTable].ColDefs[col].T.PrintColumnDefType(), conv.Stats.LongestStrings[srcTable][col])
+		})
+}
+
+// buildOrphansBody lists, for each foreign key of srcTable, how many
+// rows didn't match a row of the referenced table and how they were
+// handled.
+func buildOrphansBody(conv *Conv, srcTable string) []tableReportBody {
+	var action string
+	switch conv.Policies.Orphans {
+	case LoadOrphans:
+		action = "written anyway (the foreign key can't be added until they are fixed)"
+	case NullOrphans:
+		action = "written with NULL foreign key columns (rows where this isn't possible were not written)"
+	default:
+		action = "dropped (they were not written)"
+	}
+	return buildColCountBody(conv, srcTable, fmt.Sprintf("Orphaned rows (policy: %s)", conv.Policies.Orphans), conv.Stats.Orphans[srcTable],
+		func(fk string, n int64) string {
+			return fmt.Sprintf("Foreign key '%s': %d rows didn't match a row of the referenced table, and were %s", fk, n, action)
+		})
+}
+
+// buildDuplicatesBody reports how many rows of srcTable had the same
+// Spanner primary key as an earlier row, with a sample of the keys.
+func buildDuplicatesBody(conv *Conv, srcTable string) []tableReportBody {
+	n := conv.Stats.Duplicates[srcTable]
+	if n == 0 {
+		return nil
+	}
+	var action string
+	switch conv.Policies.Duplicates {
+	case LastWins:
+		action = "replaced the earlier rows"
+	case SidelineDuplicates:
+		action = "were not written (see the bad data file)"
+	default:
+		action = "were discarded"
+	}
+	return []tableReportBody{{
+		Heading: fmt.Sprintf("Duplicate primary keys (policy: %s)", conv.Policies.Duplicates),
+		Lines: []string{fmt.Sprintf("%d rows had the same primary key as an earlier row, and %s. Duplicate keys include: %s",
+			n, action, strings.Join(conv.Stats.DuplicateKeys[srcTable], ", "))},
+	}}
+}
+
+// buildNotNullBody lists, for each Spanner column of srcTable, how many
+// NULL values for NOT NULL columns were found during data conversion and
+// how they were handled.
+func buildNotNullBody(conv *Conv, srcTable string) []tableReportBody {
+	spTable := conv.ToSpanner[srcTable].Name
+	return buildColCountBody(conv, srcTable, "NULL values in NOT NULL columns", conv.Stats.NotNull[srcTable],
+		func(col string, n int64) string {
+			var action string
+			switch p := conv.notNullPolicy(spTable, col); p {
+			case DefaultNotNull:
+				action = "replaced by the default value of the column's type"
+			default:
+				action = fmt.Sprintf("dropped (policy: %s; the rows containing them were not written)", p)
+			}
+			return fmt.Sprintf("Column '%s': %d NULL values were %s", col, n, action)
+		})
+}
+
+// buildEmptyStringsBody lists, for each STRING column of srcTable, how
+// many empty strings or NULL values were changed by the empty string
+// policy during data conversion.
+func buildEmptyStringsBody(conv *Conv, srcTable string) []tableReportBody {
+	spTable := conv.ToSpanner[srcTable].Name
+	return buildColCountBody(conv, srcTable, "Empty strings and NULL values", conv.Stats.EmptyStrings[srcTable],
+		func(col string, n int64) string {
+			if conv.emptyStringPolicy(spTable, col) == NullToEmpty {
+				return fmt.Sprintf("Column '%s': %d NULL values were written as empty strings (policy: %s)", col, n, NullToEmpty)
+			}
+			return fmt.Sprintf("Column '%s': %d empty strings were written as NULL (policy: %s)", col, n, EmptyToNull)
+		})
+}
+
+// buildRelaxedNotNullBody lists the Spanner columns of srcTable whose
+// NOT NULL constraint was removed by RelaxNotNull.
+func buildRelaxedNotNullBody(conv *Conv, srcTable string) []tableReportBody {
+	relaxed := conv.Stats.NotNullRelaxed[srcTable]
+	if len(relaxed) == 0 {
+		return nil
+	}
+	var l []string
+	for _, c := range relaxed {
+		l = append(l, fmt.Sprintf("Column '%s': NOT NULL constraint was removed because the source column is nullable", c))
+	}
+	return []tableReportBody{{Heading: fmt.Sprintf("Relaxed NOT NULL constraints (policy: %s)", RelaxNotNull), Lines: l}}
+}
+
+// buildDroppedColsBody lists the source columns of srcTable that are not
+// migrated (see DropColumns).
+func buildDroppedColsBody(conv *Conv, srcTable string) []tableReportBody {
+	dropped := conv.DroppedCols[srcTable]
+	if len(dropped) == 0 {
+		return nil
+	}
+	var l []string
+	for _, c := range dropped {
+		l = append(l, fmt.Sprintf("Column '%s' was dropped: it is not in the Spanner schema, and its data was not migrated", c))
+	}
+	return []tableReportBody{{Heading: "Dropped columns", Lines: l}}
+}
+
+// buildRenamesBody lists the source names of srcTable and its columns
+// that had to be changed to get legal, unique Spanner names (see
+// GetSpannerTable and GetSpannerCol).
+func buildRenamesBody(conv *Conv, srcTable, spTable string) []tableReportBody {
+	var l []string
+	if spTable != srcTable {
+		l = append(l, fmt.Sprintf("Table '%s' was renamed to '%s': %s", srcTable, spTable, conv.renameReason(srcTable, spTable)))
+	}
+	cols := append([]string{}, conv.SrcSchema[srcTable].ColNames...)
+	conv.orderCols(srcTable, cols)
+	for _, c := range cols {
+		spCol, ok := conv.ToSpanner[srcTable].Cols[c]
+		if !ok || spCol == c || conv.IsDroppedCol(srcTable, c) {
+			continue
+		}
+		l = append(l, fmt.Sprintf("Column '%s' was renamed to '%s': %s", c, spCol, conv.renameReason(c, spCol)))
+	}
+	if len(l) == 0 {
+		return nil
+	}
+	return []tableReportBody{{Heading: "Renamed tables and columns", Lines: l}}
+}
+
+// buildUniqueConstraintsBody lists the UNIQUE constraints of srcTable,
+// with the Spanner unique index that enforces each one.
+func buildUniqueConstraintsBody(conv *Conv, srcTable, spTable string) []tableReportBody {
+	var l []string
+	for _, index := range conv.SrcSchema[srcTable].Indexes {
+		if !index.Unique || !index.Constraint {
+			continue
+		}
+		var srcCols, spCols []string
+		for _, k := range index.Keys {
+			srcCols = append(srcCols, k.Column)
+			spCols = append(spCols, conv.ToSpanner[srcTable].Cols[k.Column])
+		}
+		src := fmt.Sprintf("UNIQUE constraint '%s'", index.Name)
+		if index.Name == "" {
+			src = fmt.Sprintf("UNIQUE constraint on (%s)", strings.Join(srcCols, ", "))
+		}
+		spIndex := ""
+		for _, i := range conv.SpSchema[spTable].Indexes {
+			var cols []string
+			for _, k := range i.Keys {
+				cols = append(cols, k.Col)
+			}
+			if i.Unique && strings.Join(cols, ",") == strings.Join(spCols, ",") {
+				spIndex = i.Name
+				break
+			}
+		}
+		if spIndex == "" {
+			l = append(l, fmt.Sprintf("%s was not converted: there is no matching unique index in the Spanner schema", src))
+			continue
+		}
+		l = append(l, fmt.Sprintf("%s is enforced by unique index '%s'. Spanner has no UNIQUE constraints, so tools that inspect constraints (such as ORMs) see an index instead", src, spIndex))
+	}
+	if len(l) == 0 {
+		return nil
+	}
+	return []tableReportBody{{Heading: "Unique constraints", Lines: l}}
+}
+
+// renameReason explains why source name src was mapped to Spanner name sp.
+func (conv *Conv) renameReason(src, sp string) string {
+	mapped := conv.NameMapping.apply(src)
+	fixed, changed := FixName(mapped)
+	switch {
+	case len(mapped) > MaxNameLength:
+		return fmt.Sprintf("Spanner names are limited to %d characters", MaxNameLength)
+	case mapped != src && fixed == sp:
+		return "it was transliterated: Spanner names can only contain ASCII letters, digits and underscores"
+	case changed && fixed == sp:
+		return "Spanner names must start with a letter, and can only contain letters, digits and underscores"
+	case changed:
+		return "Spanner names can only contain letters, digits and underscores, and the fixed name clashes with another name"
+	default:
+		return "it clashes with another name (Spanner names are case-insensitive)"
+	}
+}
+
+// buildComputedColsBody lists the computed columns of spTable (see
+// AddComputedCols).
+func buildComputedColsBody(conv *Conv, spTable string) []tableReportBody {
+	var l []string
+	for _, cc := range conv.ComputedCols[spTable] {
+		l = append(l, fmt.Sprintf("Column '%s' was added: its values are computed as %s", cc.Column, cc.Expr))
+	}
+	if len(l) == 0 {
+		return nil
+	}
+	return []tableReportBody{{Heading: "Computed columns", Lines: l}}
+}
+
+// buildRemodelBody describes the primary key changes, splits and merges
+// (see Remodel) that affect srcTable.
+func buildRemodelBody(conv *Conv, srcTable, spTable string) []tableReportBody {
+	var l []string
+	if p, ok := conv.KeyIndexes[spTable]; ok {
+		line := fmt.Sprintf("Primary key %s was taken from unique index '%s'", describeKey(conv.SpSchema[spTable].Pks), p.Index)
+		if p.Demoted == "" {
+			line += ", replacing the synthetic primary key"
+		} else {
+			for _, index := range conv.SpSchema[spTable].Indexes {
+				if index.Name == p.Demoted {
+					line += fmt.Sprintf(": the original primary key %s is now unique index '%s'", describeKey(index.Keys), p.Demoted)
+				}
+			}
+		}
+		l = append(l, line)
+	}
+	if o, ok := conv.KeyOrders[spTable]; ok {
+		l = append(l, fmt.Sprintf("Primary key columns were reordered from (%s) to (%s)", strings.Join(o.Original, ", "), strings.Join(o.Cols, ", ")))
+	}
+	if m, ok := conv.MergedTables[srcTable]; ok {
+		l = append(l, fmt.Sprintf("Table was merged into table '%s': its rows update the rows of '%s' with the same primary key", m.Into, m.Into))
+	} else {
+		for _, s := range conv.Splits[spTable] {
+			line := fmt.Sprintf("Columns %s were moved to table '%s', which has the same primary key", strings.Join(s.Cols, ", "), s.NewTable)
+			if s.Interleave {
+				line += " and is interleaved in this table"
+			}
+			l = append(l, line)
+		}
+	}
+	for _, c := range conv.SpSchema[spTable].ColNames {
+		if t, ok := conv.MoneyCols[spTable+"."+c]; ok {
+			l = append(l, fmt.Sprintf("Column '%s' was converted from %s to NUMERIC to store monetary amounts exactly: its values are parsed from their decimal text", c, t.PrintColumnDefType()))
+		}
+		if t, ok := conv.BoolCols[spTable+"."+c]; ok {
+			l = append(l, fmt.Sprintf("Column '%s' was converted from %s to BOOL: 'Y', 'T' and '1' are written as true, and 'N', 'F' and '0' as false", c, t.PrintColumnDefType()))
+		}
+		if d, ok := conv.DictCols[spTable+"."+c]; ok {
+			line := fmt.Sprintf("Column '%s' was dictionary-encoded: its distinct values are stored in table '%s', and it holds their ids", c, d.Dictionary)
+			if n, distinct := conv.DictionaryStats(spTable, c); n > 0 {
+				line += fmt.Sprintf(" (%d values, %d distinct)", n, distinct)
+			}
+			l = append(l, line)
+		}
+		if k, ok := conv.LongKeys[spTable+"."+c]; ok {
+			switch k.Strategy {
+			case HashLongKey:
+				l = append(l, fmt.Sprintf("Column '%s' was replaced in the primary key by its SHA-256 hash in column '%s', since its values can exceed Spanner's limit on key size: column '%s' holds its first %d characters, indexed by '%s'", c, k.HashCol, k.PrefixCol, k.Len, k.Index))
+			case TruncateLongKey:
+				line := fmt.Sprintf("Column '%s' was converted from %s to %s, since its values can exceed Spanner's limit on key size: longer values are truncated", c, k.Type.PrintColumnDefType(), conv.SpSchema[spTable].ColDefs[c].T.PrintColumnDefType())
+				if n, collisions := conv.LongKeyStats(spTable, c); n > 0 {
+					line += fmt.Sprintf(" (%d values truncated, %d rows rejected because their truncated key collided with that of another row)", n, collisions)
+				}
+				l = append(l, line)
+			}
+		}
+	}
+	if len(l) == 0 {
+		return nil
+	}
+	return []tableReportBody{{Heading: "Table remodeling", Lines: l}}
+}
+
+// buildMoneyBody describes the floating point columns of spTable that
+// look like they hold monetary amounts (see SuggestMoneyCols).
+func buildMoneyBody(conv *Conv, spTable string) []tableReportBody {
+	var l []string
+	ct := conv.SpSchema[spTable]
+	for _, c := range ct.ColNames {
+		if isFloat(ct.ColDefs[c].T) && LooksMonetary(c) && conv.canChangeColType(spTable, c) {
+			l = append(l, fmt.Sprintf("Column '%s' looks like it holds monetary amounts, which %s can't store exactly: consider converting it to NUMERIC with -money-columns=%s.%s=numeric (or -money-columns=auto)", c, ct.ColDefs[c].T.PrintColumnDefType(), spTable, c))
+		}
+	}
+	if len(l) == 0 {
+		return nil
+	}
+	return []tableReportBody{{Heading: "Monetary columns", Lines: l}}
+}
+
+// buildCollationBody describes the keys of srcTable whose order changes
+// in Spanner because of the collation of their columns.
+func buildCollationBody(conv *Conv, srcTable, spTable string) []tableReportBody {
+	l := conv.collationWarnings(srcTable, spTable)
+	if len(l) == 0 {
+		return nil
+	}
+	return []tableReportBody{{Heading: "Collation-sensitive ordering", Lines: l}}
+}
+
+// buildBoolBody describes the CHAR(1) columns of spTable that look like
+// they hold flags (see SuggestBoolCols).
+func buildBoolBody(conv *Conv, spTable string) []tableReportBody {
+	var l []string
+	for _, c := range conv.SpSchema[spTable].ColNames {
+		if _, _, ok := conv.isCharFlag(spTable, c); ok && LooksBoolean(c) && conv.canChangeColType(spTable, c) {
+			l = append(l, fmt.Sprintf("Column '%s' looks like it holds flags: consider converting it to BOOL with -bool-columns=%s.%s=bool (or -bool-columns=auto)", c, spTable, c))
+		}
+	}
+	if len(l) == 0 {
+		return nil
+	}
+	return []tableReportBody{{Heading: "Flag columns", Lines: l}}
+}
+
+// buildRedundantIndexesBody describes the indexes of spTable that were
+// dropped, and the indexes that look redundant (see SuggestDropIndexes).
+func buildRedundantIndexesBody(conv *Conv, spTable string) []tableReportBody {
+	var l []string
+	var dropped []string
+	for name, r := range conv.DroppedIndexes {
+		if r.Table == spTable {
+			dropped = append(dropped, name)
+		}
+	}
+	sort.Strings(dropped)
+	for _, name := range dropped {
+		l = append(l, fmt.Sprintf("Index '%s' was dropped: %s", name, conv.DroppedIndexes[name].Reason))
+	}
+	for _, r := range conv.redundantIndexes(spTable) {
+		l = append(l, fmt.Sprintf("Index '%s' can probably be dropped, since %s: consider dropping it with -drop-indexes=%s=drop (or -drop-indexes=auto) to speed up data conversion", r.Index, r.Reason, r.Index))
+	}
+	if len(l) == 0 {
+		return nil
+	}
+	return []tableReportBody{{Heading: "Redundant indexes", Lines: l}}
+}
+
+// buildLimitsBody describes how spTable exceeds Spanner's limits on
+// indexes, foreign keys and key size, or was trimmed to be within them.
+func buildLimitsBody(conv *Conv, spTable string) []tableReportBody {
+	l := conv.limitWarnings(spTable)
+	if len(l) == 0 {
+		return nil
+	}
+	return []tableReportBody{{Heading: "Spanner limits", Lines: l}}
+}
+
+// buildStringLensBody describes the STRING columns of spTable whose
+// length was changed to fit the source data: the STRING(MAX) columns that
+// were given a length from the longest of their values, and the STRING(n)
+// columns too short for it.
+func buildStringLensBody(conv *Conv, spTable string) []tableReportBody {
+	var l []string
+	ct := conv.SpSchema[spTable]
+	for _, c := range ct.ColNames {
+		if n, ok := conv.StringLens[spTable+"."+c]; ok {
+			l = append(l, fmt.Sprintf("Column '%s' was converted to %s instead of STRING(MAX): its longest value has %d characters", c, ct.ColDefs[c].T.PrintColumnDefType(), n))
+		}
+		if w, ok := conv.WidenedStrs[spTable+"."+c]; ok {
+			l = append(l, fmt.Sprintf("Column '%s' was converted to %s instead of STRING(%d): its longest value has %d characters", c, ct.ColDefs[c].T.PrintColumnDefType(), w.Len, w.Longest))
+		}
+	}
+	if len(l) == 0 {
+		return nil
+	}
+	return []tableReportBody{{Heading: "String lengths", Lines: l}}
+}
+
+// buildProfileBody describes the profiles of the columns of srcTable, if
+// its data was profiled.
+func buildProfileBody(conv *Conv, srcTable string) []tableReportBody {
+	var cols []string
+	for _, c := range conv.SrcSchema[srcTable].ColNames {
+		if _, ok := conv.Profiles[srcTable+"."+c]; ok {
+			cols = append(cols, c)
+		}
+	}
+	if len(cols) == 0 {
+		return nil
+	}
+	conv.orderCols(srcTable, cols)
+	var l []string
+	var rows int64
+	for _, c := range cols {
+		p := conv.Profiles[srcTable+"."+c]
+		l = append(l, conv.profileLine(srcTable, c, p))
+		rows = p.Rows
+	}
+	return []tableReportBody{{Heading: fmt.Sprintf("Column profiles (sample of %d rows)", rows), Lines: l}}
+}
+
+// buildPartitionBody describes the splits suggested for spTable by
+// SuggestPartitions, if any.
+func buildPartitionBody(conv *Conv, srcTable, spTable string) []tableReportBody {
+	if _, ok := conv.MergedTables[srcTable]; ok {
+		return nil
+	}
+	reason := conv.PartitionReason(spTable)
+	if reason == "" {
+		return nil
+	}
+	l := []string{fmt.Sprintf("Table is approaching Spanner's limits: %s", reason)}
+	splits := conv.suggestPartition(spTable, make(map[string]bool))
+	for _, s := range splits {
+		l = append(l, fmt.Sprintf("Consider moving columns %s to a table '%s' interleaved in this table", strings.Join(s.Cols, ", "), s.NewTable))
+	}
+	if len(splits) == 0 {
+		l = append(l, "No columns can be moved to another table automatically: they are used by keys, indexes or foreign keys, or tables are merged into this table")
+	} else {
+		l = append(l, "Use -auto-partition to apply these splits when converting the schema")
+	}
+	return []tableReportBody{{Heading: "Vertical partitioning", Lines: l}}
+}
+
+// buildColCountBody builds a report section with a line for each column
+// of srcTable in counts (in the column order given by conv.Ordering), as
+// generated by line.
+func buildColCountBody(conv *Conv, srcTable, heading string, counts map[string]int64, line func(col string, n int64) string) []tableReportBody {
+	if len(counts) == 0 {
+		return nil
+	}
+	var cols []string
+	for c := range counts {
+		cols = append(cols, c)
+	}
+	conv.orderCols(srcTable, cols)
+	var l []string
+	for _, c := range cols {
+		l = append(l, line(c, counts[c]))
+	}
+	return []tableReportBody{{Heading: heading, Lines: l}}
+}
+
 func fillRowStats(conv *Conv, srcTable string, badWrites map[string]int64, tr *tableReport) {
 	rows := conv.Stats.Rows[srcTable]
 	goodConvRows := conv.Stats.GoodRows[srcTable]
@@ -264,6 +833,9 @@ var IssueDB = map[SchemaIssue]struct {
 	Datetime:              {Brief: "Spanner timestamp is closer to MySQL timestamp", severity: note, batch: true},
 	Time:                  {Brief: "Spanner does not support time/year types", severity: note, batch: true},
 	Widened:               {Brief: "Some columns will consume more storage in Spanner", severity: note, batch: true},
+	IndexPrefix:           {Brief: "Spanner does not support index prefix lengths, so the whole column is indexed", severity: warning},
+	TinyintBool:           {Brief: "MySQL uses tinyint(1) for booleans: non-zero values are converted to true", severity: note},
+	LargeObject:           {Brief: "The column references large objects, whose contents are migrated instead of their oid (contents too large for Spanner are handled by the oversize policy)", severity: note},
 }
 
 type severity int
@@ -403,11 +975,15 @@ func IgnoredStatements(conv *Conv) (l []string) {
 		case "CreateFunctionStmt":
 			l = append(l, "functions")
 		case "CreateSeqStmt", "CreateSequenceStmt":
-			l = append(l, "sequences")
+			if len(conv.SrcSequences) == 0 {
+				l = append(l, "sequences")
+			}
 		case "CreatePLangStmt", "CreateProcedureStmt":
 			l = append(l, "procedures")
 		case "CreateTrigStmt":
-			l = append(l, "triggers")
+			if len(conv.SrcTriggers) == 0 {
+				l = append(l, "triggers")
+			}
 		case "IndexStmt", "CreateIndexStmt":
 			l = append(l, "(non-primary) indexes")
 		case "ViewStmt", "CreateViewStmt":
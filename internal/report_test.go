@@ -0,0 +1,68 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package internal
+
+import (
+	"testing"
+
+	"github.com/cloudspannerecosystem/harbourbridge/schema"
+	"github.com/cloudspannerecosystem/harbourbridge/spanner/ddl"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBuildRenamesBody(t *testing.T) {
+	conv := MakeConv()
+	conv.SrcSchema["my table"] = schema.Table{Name: "my table", ColNames: []string{"Id", "ID", "ok", "bad col"}}
+	spTable, err := GetSpannerTable(conv, "my table")
+	assert.Nil(t, err)
+	_, err = GetSpannerCols(conv, "my table", []string{"Id", "ID", "ok", "bad col"})
+	assert.Nil(t, err)
+	assert.Equal(t, []tableReportBody{{
+		Heading: "Renamed tables and columns",
+		Lines: []string{
+			"Table 'my table' was renamed to 'my_table': Spanner names must start with a letter, and can only contain letters, digits and underscores",
+			"Column 'ID' was renamed to 'ID_1': it clashes with another name (Spanner names are case-insensitive)",
+			"Column 'bad col' was renamed to 'bad_col': Spanner names must start with a letter, and can only contain letters, digits and underscores",
+		},
+	}}, buildRenamesBody(conv, "my table", spTable))
+	assert.Nil(t, buildRenamesBody(MakeConv(), "t", "t"))
+}
+
+func TestBuildUniqueConstraintsBody(t *testing.T) {
+	conv := MakeConv()
+	conv.SrcSchema["t"] = schema.Table{
+		Name:     "t",
+		ColNames: []string{"a", "b"},
+		Indexes: []schema.Index{
+			{Name: "t_a_key", Unique: true, Keys: []schema.Key{{Column: "a"}}, Constraint: true},
+			{Name: "", Unique: true, Keys: []schema.Key{{Column: "a"}, {Column: "b"}}, Constraint: true},
+			{Name: "t_b_idx", Unique: true, Keys: []schema.Key{{Column: "b"}}},
+		}}
+	GetSpannerTable(conv, "t")
+	GetSpannerCols(conv, "t", []string{"a", "b"})
+	conv.SpSchema["t"] = ddl.CreateTable{
+		Name: "t",
+		Indexes: []ddl.CreateIndex{
+			{Name: "t_a_key", Table: "t", Unique: true, Keys: []ddl.IndexKey{{Col: "a"}}},
+			{Name: "t_b_idx", Table: "t", Unique: true, Keys: []ddl.IndexKey{{Col: "b"}}},
+		}}
+	assert.Equal(t, []tableReportBody{{
+		Heading: "Unique constraints",
+		Lines: []string{
+			"UNIQUE constraint 't_a_key' is enforced by unique index 't_a_key'. Spanner has no UNIQUE constraints, so tools that inspect constraints (such as ORMs) see an index instead",
+			"UNIQUE constraint on (a, b) was not converted: there is no matching unique index in the Spanner schema",
+		},
+	}}, buildUniqueConstraintsBody(conv, "t", "t"))
+}
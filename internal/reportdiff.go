@@ -0,0 +1,214 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package internal
+
+import (
+	"bufio"
+	"fmt"
+	"sort"
+)
+
+// StructuredReport is a machine-readable version of the conversion
+// report (see GenerateReport), written as JSON so that reports from
+// different runs can be compared (see DiffReports).
+type StructuredReport struct {
+	Driver     string
+	Summary    string // Overall rating of the conversion.
+	Rows       int64
+	BadRows    int64
+	Tables     []TableSummary
+	Unexpected map[string]int64 // Count of unexpected conditions, broken down by condition description.
+	Errors     []ErrorGroup     `json:",omitempty"` // Most frequent causes of bad rows (see Conv.ErrorGroups).
+}
+
+// TableSummary is the per-table part of a StructuredReport.
+type TableSummary struct {
+	SrcTable string
+	SpTable  string
+	Rows     int64
+	BadRows  int64
+	Issues   []string // One entry per line of the table's report, prefixed by its heading.
+}
+
+// GenerateStructuredReport builds a StructuredReport for conv.
+func GenerateStructuredReport(driverName string, conv *Conv, badWrites map[string]int64) StructuredReport {
+	reports := AnalyzeTables(conv, badWrites)
+	sr := StructuredReport{
+		Driver:     driverName,
+		Summary:    GenerateSummary(conv, reports, badWrites),
+		Rows:       conv.Rows(),
+		BadRows:    conv.BadRows(),
+		Unexpected: make(map[string]int64),
+		Errors:     conv.ErrorGroups(10),
+	}
+	for _, n := range badWrites {
+		sr.BadRows += n
+	}
+	for _, t := range reports {
+		ts := TableSummary{SrcTable: t.SrcTable, SpTable: t.SpTable, Rows: t.rows, BadRows: t.badRows}
+		for _, b := range t.Body {
+			for _, l := range b.Lines {
+				ts.Issues = append(ts.Issues, fmt.Sprintf("%s: %s", b.Heading, l))
+			}
+		}
+		sr.Tables = append(sr.Tables, ts)
+	}
+	for u, n := range conv.Stats.Unexpected {
+		sr.Unexpected[u] = n
+	}
+	return sr
+}
+
+// ReportDiff describes the differences between two StructuredReports.
+type ReportDiff struct {
+	AddedTables        []string            // Tables only in the new report.
+	RemovedTables      []string            // Tables only in the old report.
+	NewIssues          map[string][]string // Issues only in the new report, broken down by table.
+	ResolvedIssues     map[string][]string // Issues only in the old report, broken down by table.
+	RowChanges         []RowChange         // Tables whose row or bad row counts changed.
+	NewUnexpected      []string            // Unexpected conditions only in the new report.
+	ResolvedUnexpected []string            // Unexpected conditions only in the old report.
+}
+
+// RowChange records a change in a table's row counts between two reports.
+type RowChange struct {
+	Table                  string
+	OldRows, NewRows       int64
+	OldBadRows, NewBadRows int64
+}
+
+// DiffReports compares the reports of two runs. Tables are matched by
+// source table name.
+func DiffReports(old, new StructuredReport) ReportDiff {
+	d := ReportDiff{NewIssues: make(map[string][]string), ResolvedIssues: make(map[string][]string)}
+	oldTables := make(map[string]TableSummary)
+	for _, t := range old.Tables {
+		oldTables[t.SrcTable] = t
+	}
+	newTables := make(map[string]TableSummary)
+	for _, t := range new.Tables {
+		newTables[t.SrcTable] = t
+	}
+	for _, t := range new.Tables {
+		o, ok := oldTables[t.SrcTable]
+		if !ok {
+			d.AddedTables = append(d.AddedTables, t.SrcTable)
+			continue
+		}
+		if added := minus(t.Issues, o.Issues); len(added) > 0 {
+			d.NewIssues[t.SrcTable] = added
+		}
+		if resolved := minus(o.Issues, t.Issues); len(resolved) > 0 {
+			d.ResolvedIssues[t.SrcTable] = resolved
+		}
+		if o.Rows != t.Rows || o.BadRows != t.BadRows {
+			d.RowChanges = append(d.RowChanges, RowChange{Table: t.SrcTable, OldRows: o.Rows, NewRows: t.Rows, OldBadRows: o.BadRows, NewBadRows: t.BadRows})
+		}
+	}
+	for _, t := range old.Tables {
+		if _, ok := newTables[t.SrcTable]; !ok {
+			d.RemovedTables = append(d.RemovedTables, t.SrcTable)
+		}
+	}
+	d.NewUnexpected = minus(keys(new.Unexpected), keys(old.Unexpected))
+	d.ResolvedUnexpected = minus(keys(old.Unexpected), keys(new.Unexpected))
+	sort.Strings(d.AddedTables)
+	sort.Strings(d.RemovedTables)
+	sort.Slice(d.RowChanges, func(i, j int) bool { return d.RowChanges[i].Table < d.RowChanges[j].Table })
+	return d
+}
+
+// Empty returns true if d records no differences.
+func (d ReportDiff) Empty() bool {
+	return len(d.AddedTables) == 0 && len(d.RemovedTables) == 0 && len(d.NewIssues) == 0 && len(d.ResolvedIssues) == 0 &&
+		len(d.RowChanges) == 0 && len(d.NewUnexpected) == 0 && len(d.ResolvedUnexpected) == 0
+}
+
+// WriteReportDiff writes a human-readable version of d to w.
+func WriteReportDiff(d ReportDiff, w *bufio.Writer) {
+	if d.Empty() {
+		w.WriteString("No differences.\n")
+		return
+	}
+	writeList := func(heading string, l []string) {
+		if len(l) == 0 {
+			return
+		}
+		writeHeading(w, heading)
+		for _, x := range l {
+			justifyLines(w, fmt.Sprintf("- %s\n", x), 80, 2)
+		}
+		w.WriteString("\n")
+	}
+	writeIssues := func(heading string, m map[string][]string) {
+		if len(m) == 0 {
+			return
+		}
+		writeHeading(w, heading)
+		for _, t := range sortedKeys(m) {
+			fmt.Fprintf(w, "Table %s\n", t)
+			for _, x := range m[t] {
+				justifyLines(w, fmt.Sprintf("- %s\n", x), 80, 2)
+			}
+			w.WriteString("\n")
+		}
+	}
+	writeList("Added Tables", d.AddedTables)
+	writeList("Removed Tables", d.RemovedTables)
+	writeIssues("New Issues", d.NewIssues)
+	writeIssues("Resolved Issues", d.ResolvedIssues)
+	if len(d.RowChanges) > 0 {
+		writeHeading(w, "Row Count Changes")
+		for _, c := range d.RowChanges {
+			fmt.Fprintf(w, "Table %s: rows %d -> %d, bad rows %d -> %d\n", c.Table, c.OldRows, c.NewRows, c.OldBadRows, c.NewBadRows)
+		}
+		w.WriteString("\n")
+	}
+	writeList("New Unexpected Conditions", d.NewUnexpected)
+	writeList("Resolved Unexpected Conditions", d.ResolvedUnexpected)
+}
+
+// minus returns the elements of l that are not in m, preserving order.
+func minus(l, m []string) []string {
+	in := make(map[string]bool)
+	for _, x := range m {
+		in[x] = true
+	}
+	var r []string
+	for _, x := range l {
+		if !in[x] {
+			r = append(r, x)
+		}
+	}
+	return r
+}
+
+func keys(m map[string]int64) []string {
+	var l []string
+	for k := range m {
+		l = append(l, k)
+	}
+	sort.Strings(l)
+	return l
+}
+
+func sortedKeys(m map[string][]string) []string {
+	var l []string
+	for k := range m {
+		l = append(l, k)
+	}
+	sort.Strings(l)
+	return l
+}
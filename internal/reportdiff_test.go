@@ -0,0 +1,60 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package internal
+
+import (
+	"bufio"
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDiffReports(t *testing.T) {
+	old := StructuredReport{
+		Tables: []TableSummary{
+			{SrcTable: "t1", Rows: 10, Issues: []string{"Warning: a", "Note: b"}},
+			{SrcTable: "t2", Rows: 5},
+			{SrcTable: "t3", Rows: 1},
+		},
+		Unexpected: map[string]int64{"x": 1, "y": 2},
+	}
+	new := StructuredReport{
+		Tables: []TableSummary{
+			{SrcTable: "t1", Rows: 10, BadRows: 2, Issues: []string{"Note: b", "Warning: c"}},
+			{SrcTable: "t2", Rows: 5},
+			{SrcTable: "t4", Rows: 3},
+		},
+		Unexpected: map[string]int64{"y": 3, "z": 1},
+	}
+	d := DiffReports(old, new)
+	assert.Equal(t, []string{"t4"}, d.AddedTables)
+	assert.Equal(t, []string{"t3"}, d.RemovedTables)
+	assert.Equal(t, map[string][]string{"t1": {"Warning: c"}}, d.NewIssues)
+	assert.Equal(t, map[string][]string{"t1": {"Warning: a"}}, d.ResolvedIssues)
+	assert.Equal(t, []RowChange{{Table: "t1", OldRows: 10, NewRows: 10, OldBadRows: 0, NewBadRows: 2}}, d.RowChanges)
+	assert.Equal(t, []string{"z"}, d.NewUnexpected)
+	assert.Equal(t, []string{"x"}, d.ResolvedUnexpected)
+	assert.False(t, d.Empty())
+
+	var b bytes.Buffer
+	w := bufio.NewWriter(&b)
+	WriteReportDiff(d, w)
+	w.Flush()
+	assert.Contains(t, b.String(), "- Warning: c")
+	assert.Contains(t, b.String(), "Table t1: rows 10 -> 10, bad rows 0 -> 2")
+
+	assert.True(t, DiffReports(old, old).Empty())
+}
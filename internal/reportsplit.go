@@ -0,0 +1,72 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package internal
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"strings"
+)
+
+// TableReportFile returns the name of the file of the report of srcTable
+// in a split report (see GenerateSplitReport). Characters other than
+// letters, digits, '.', '-' and '_' are replaced by '_'.
+func TableReportFile(srcTable string) string {
+	return strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9', r == '.', r == '-', r == '_':
+			return r
+		}
+		return '_'
+	}, srcTable) + ".txt"
+}
+
+// GenerateSplitReport is GenerateReport for sources with too many tables
+// for a single report: w receives the summary and an index of the tables,
+// giving the rating of each table and the file its report is in (see
+// TableReportFile), and the report of each table is passed to
+// writeTable. Only the reports of the source tables in tables are
+// written (nil means all tables), but the index lists all tables.
+func GenerateSplitReport(driverName string, conv *Conv, w *bufio.Writer, badWrites map[string]int64, tables map[string]bool, writeTable func(file string, report []byte) error) (string, error) {
+	reports := AnalyzeTables(conv, badWrites)
+	summary := writeReportIntro(driverName, conv, w, reports, badWrites, "an index of the tables, whose schema and data conversion details are in a file per table")
+	writeHeading(w, "Tables")
+	for i, t := range reports {
+		file := TableReportFile(t.SrcTable)
+		name := t.SrcTable
+		if t.SrcTable != t.SpTable {
+			name += fmt.Sprintf(" (Spanner table %s)", t.SpTable)
+		}
+		rating := "schema " + rateSchema(t.Cols, t.Warnings, t.SyntheticPKey != "", false)
+		if !conv.SchemaMode() {
+			rating += ", data " + rateData(t.rows, t.badRows)
+		}
+		justifyLines(w, fmt.Sprintf("%d) %s: %s, see %s.\n", i+1, name, rating, file), 80, 3)
+		if tables != nil && !tables[t.SrcTable] {
+			continue
+		}
+		var b bytes.Buffer
+		tw := bufio.NewWriter(&b)
+		writeTableReport(conv, tw, t)
+		tw.Flush()
+		if err := writeTable(file, b.Bytes()); err != nil {
+			return summary, err
+		}
+	}
+	w.WriteString("\n")
+	writeReportEnd(driverName, conv, w, true)
+	return summary, nil
+}
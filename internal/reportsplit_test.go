@@ -0,0 +1,68 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package internal
+
+import (
+	"bufio"
+	"bytes"
+	"testing"
+
+	"github.com/cloudspannerecosystem/harbourbridge/schema"
+	"github.com/cloudspannerecosystem/harbourbridge/spanner/ddl"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTableReportFile(t *testing.T) {
+	assert.Equal(t, "orders.txt", TableReportFile("orders"))
+	assert.Equal(t, "public.my_table.txt", TableReportFile("public.my table"))
+	assert.Equal(t, "a_b_c.txt", TableReportFile("a/b\\c"))
+}
+
+func TestGenerateSplitReport(t *testing.T) {
+	conv := MakeConv()
+	conv.SetSchemaMode()
+	for _, name := range []string{"a", "b c"} {
+		conv.SrcSchema[name] = schema.Table{
+			Name:        name,
+			ColNames:    []string{"id"},
+			ColDefs:     map[string]schema.Column{"id": {Name: "id", Type: schema.Type{Name: "bigint"}}},
+			PrimaryKeys: []schema.Key{{Column: "id"}},
+		}
+		spTable, err := GetSpannerTable(conv, name)
+		assert.Nil(t, err)
+		_, err = GetSpannerCols(conv, name, []string{"id"})
+		assert.Nil(t, err)
+		conv.SpSchema[spTable] = ddl.CreateTable{
+			Name:     spTable,
+			ColNames: []string{"id"},
+			ColDefs:  map[string]ddl.ColumnDef{"id": {Name: "id", T: ddl.Type{Name: ddl.Int64}}},
+			Pks:      []ddl.IndexKey{{Col: "id"}},
+		}
+	}
+	var index bytes.Buffer
+	w := bufio.NewWriter(&index)
+	files := make(map[string]string)
+	_, err := GenerateSplitReport("pg_dump", conv, w, nil, map[string]bool{"b c": true}, func(file string, report []byte) error {
+		files[file] = string(report)
+		return nil
+	})
+	assert.Nil(t, err)
+	w.Flush()
+	assert.Contains(t, index.String(), "1) a: schema EXCELLENT (all columns mapped cleanly), see a.txt.")
+	assert.Contains(t, index.String(), "2) b c (Spanner table b_c): schema EXCELLENT (all columns mapped cleanly), see\n   b_c.txt.")
+	assert.NotContains(t, index.String(), "Table a\n")
+	assert.Equal(t, 1, len(files))
+	assert.Contains(t, files["b_c.txt"], "Table b c (mapped to Spanner table b_c)")
+}
@@ -0,0 +1,68 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package internal
+
+import (
+	"database/sql/driver"
+	"errors"
+	"io"
+	"net"
+	"syscall"
+	"time"
+)
+
+// RetryPolicy specifies how operations on a source database that fail
+// with transient errors (such as lock timeouts or connection resets) are
+// retried.
+type RetryPolicy struct {
+	Attempts int           // Maximum number of attempts.
+	Delay    time.Duration // Delay before the first retry, doubled before each further retry.
+	// Transient returns true for errors that may not happen again if the
+	// operation is retried.
+	Transient func(err error) bool
+}
+
+// Do calls f until it succeeds, fails with an error that isn't
+// transient, or has been attempted p.Attempts times. It returns the last
+// error.
+func (p RetryPolicy) Do(f func() error) error {
+	delay := p.Delay
+	for attempt := 1; ; attempt++ {
+		err := f()
+		if err == nil || attempt >= p.Attempts || !p.Transient(err) {
+			return err
+		}
+		VerbosePrintf("Retrying after transient error (attempt %d of %d): %v\n", attempt, p.Attempts, err)
+		time.Sleep(delay)
+		delay *= 2
+	}
+}
+
+// IsTransientConnError returns true if err is a connection error that
+// may not happen again on a new connection: database/sql retries
+// driver.ErrBadConn itself, but only a couple of times and not for
+// connections dropped in the middle of a query.
+func IsTransientConnError(err error) bool {
+	var netErr net.Error
+	switch {
+	case errors.Is(err, driver.ErrBadConn), errors.Is(err, io.EOF), errors.Is(err, io.ErrUnexpectedEOF):
+		return true
+	case errors.Is(err, syscall.ECONNRESET), errors.Is(err, syscall.ECONNREFUSED), errors.Is(err, syscall.EPIPE):
+		return true
+	case errors.As(err, &netErr):
+		return netErr.Timeout()
+	}
+	return false
+}
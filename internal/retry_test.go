@@ -0,0 +1,61 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package internal
+
+import (
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"syscall"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRetryPolicy(t *testing.T) {
+	errBusy := errors.New("busy")
+	errFatal := errors.New("fatal")
+	p := RetryPolicy{Attempts: 3, Transient: func(err error) bool { return errors.Is(err, errBusy) }}
+	tc := []struct {
+		name     string
+		errs     []error // Errors returned by successive attempts.
+		err      error
+		attempts int
+	}{
+		{"success", nil, nil, 1},
+		{"retried", []error{errBusy, errBusy}, nil, 3},
+		{"wrapped", []error{fmt.Errorf("query failed: %w", errBusy)}, nil, 2},
+		{"not transient", []error{errBusy, errFatal}, errFatal, 2},
+		{"too many attempts", []error{errBusy, errBusy, errBusy, errBusy}, errBusy, 3},
+	}
+	for _, c := range tc {
+		attempts := 0
+		err := p.Do(func() error {
+			attempts++
+			if attempts <= len(c.errs) {
+				return c.errs[attempts-1]
+			}
+			return nil
+		})
+		assert.Equal(t, c.err, err, c.name)
+		assert.Equal(t, c.attempts, attempts, c.name)
+	}
+}
+
+func TestIsTransientConnError(t *testing.T) {
+	assert.True(t, IsTransientConnError(driver.ErrBadConn))
+	assert.True(t, IsTransientConnError(fmt.Errorf("read: %w", syscall.ECONNRESET)))
+	assert.False(t, IsTransientConnError(errors.New("syntax error")))
+}
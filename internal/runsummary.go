@@ -0,0 +1,80 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package internal
+
+import (
+	"encoding/json"
+)
+
+// Exit codes of conversion runs. They are part of the command's contract
+// with automation, as is RunSummary: don't change them.
+const (
+	ExitClean      = 0 // Converted without warnings or errors.
+	ExitWarnings   = 2 // Converted, but with schema conversion warnings or unexpected conditions.
+	ExitDataErrors = 3 // Converted, but some rows couldn't be converted or written to Spanner.
+	ExitFatal      = 4 // The run failed.
+)
+
+// RunSummary is the outcome of a conversion run, printed as a single line
+// of JSON at the end of the run, so that pipelines can branch on it
+// without parsing the report. Fields are only ever added to it.
+type RunSummary struct {
+	Status         string `json:"status"` // "clean", "warnings", "data_errors" or "fatal".
+	ExitCode       int    `json:"exit_code"`
+	Tables         int    `json:"tables"`
+	SchemaWarnings int64  `json:"schema_warnings"` // Columns that didn't map cleanly.
+	Unexpected     int64  `json:"unexpected"`      // Unexpected conditions encountered.
+	Rows           int64  `json:"rows"`
+	BadRows        int64  `json:"bad_rows"`       // Rows that couldn't be converted or written to Spanner.
+	Seed           int64  `json:"seed,omitempty"` // Seed of the random values generated (see Conv.SetSeed), to reproduce the run.
+	Error          string `json:"error,omitempty"`
+}
+
+// Summarize returns the summary of a run that converted conv, where
+// badWrites are the rows Spanner rejected, by Spanner table.
+func Summarize(conv *Conv, badWrites map[string]int64) RunSummary {
+	s := RunSummary{Rows: conv.Rows(), BadRows: conv.BadRows(), Unexpected: conv.Unexpecteds(), Seed: conv.Seed}
+	for _, t := range AnalyzeTables(conv, badWrites) {
+		s.Tables++
+		s.SchemaWarnings += t.Warnings
+	}
+	for _, n := range badWrites {
+		s.BadRows += n
+	}
+	switch {
+	case s.BadRows > 0:
+		s.Status, s.ExitCode = "data_errors", ExitDataErrors
+	case s.SchemaWarnings > 0 || s.Unexpected > 0:
+		s.Status, s.ExitCode = "warnings", ExitWarnings
+	default:
+		s.Status, s.ExitCode = "clean", ExitClean
+	}
+	return s
+}
+
+// FatalSummary returns the summary of a run that failed with err.
+func FatalSummary(err error) RunSummary {
+	return RunSummary{Status: "fatal", ExitCode: ExitFatal, Error: err.Error()}
+}
+
+// JSON returns s as a single line of JSON.
+func (s RunSummary) JSON() string {
+	b, err := json.Marshal(s)
+	if err != nil {
+		// Can't happen: all fields can be encoded.
+		return `{"status":"fatal","exit_code":4}`
+	}
+	return string(b)
+}
@@ -0,0 +1,61 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package internal
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/cloudspannerecosystem/harbourbridge/schema"
+	"github.com/cloudspannerecosystem/harbourbridge/spanner/ddl"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSummarize(t *testing.T) {
+	newConv := func() *Conv {
+		conv := MakeConv()
+		conv.SrcSchema["t"] = schema.Table{
+			Name:        "t",
+			ColNames:    []string{"id"},
+			ColDefs:     map[string]schema.Column{"id": {Name: "id", Type: schema.Type{Name: "bigint"}}},
+			PrimaryKeys: []schema.Key{{Column: "id"}},
+		}
+		GetSpannerTable(conv, "t")
+		GetSpannerCols(conv, "t", []string{"id"})
+		conv.SpSchema["t"] = ddl.CreateTable{
+			Name:     "t",
+			ColNames: []string{"id"},
+			ColDefs:  map[string]ddl.ColumnDef{"id": {Name: "id", T: ddl.Type{Name: ddl.Int64}}},
+			Pks:      []ddl.IndexKey{{Col: "id"}},
+		}
+		conv.Stats.Rows["t"] = 10
+		return conv
+	}
+	conv := newConv()
+	assert.Equal(t, RunSummary{Status: "clean", ExitCode: ExitClean, Tables: 1, Rows: 10}, Summarize(conv, nil))
+
+	conv = newConv()
+	conv.Unexpected("something odd")
+	assert.Equal(t, RunSummary{Status: "warnings", ExitCode: ExitWarnings, Tables: 1, Unexpected: 1, Rows: 10}, Summarize(conv, nil))
+
+	conv = newConv()
+	conv.Stats.BadRows["t"] = 1
+	assert.Equal(t, RunSummary{Status: "data_errors", ExitCode: ExitDataErrors, Tables: 1, Rows: 10, BadRows: 3}, Summarize(conv, map[string]int64{"t": 2}))
+}
+
+func TestRunSummaryJSON(t *testing.T) {
+	assert.Equal(t, `{"status":"clean","exit_code":0,"tables":2,"schema_warnings":0,"unexpected":0,"rows":5,"bad_rows":0}`, RunSummary{Status: "clean", Tables: 2, Rows: 5}.JSON())
+	assert.Equal(t, `{"status":"fatal","exit_code":4,"tables":0,"schema_warnings":0,"unexpected":0,"rows":0,"bad_rows":0,"error":"can't create database"}`, FatalSummary(fmt.Errorf("can't create database")).JSON())
+}
@@ -0,0 +1,234 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package internal
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/cloudspannerecosystem/harbourbridge/spanner/ddl"
+)
+
+// SARIF is a log of the schema issues of a conversion in the Static
+// Analysis Results Interchange Format (SARIF 2.1.0, restricted to the
+// properties used here), so that code review tools (e.g. GitHub code
+// scanning) show them as annotations of the generated DDL.
+type SARIF struct {
+	Schema  string     `json:"$schema"`
+	Version string     `json:"version"`
+	Runs    []SARIFRun `json:"runs"`
+}
+
+// SARIFRun is the run of a tool.
+type SARIFRun struct {
+	Tool    SARIFTool     `json:"tool"`
+	Results []SARIFResult `json:"results"`
+}
+
+// SARIFTool describes the tool and the rules of its results.
+type SARIFTool struct {
+	Driver SARIFDriver `json:"driver"`
+}
+
+// SARIFDriver is the tool's main component.
+type SARIFDriver struct {
+	Name           string      `json:"name"`
+	InformationURI string      `json:"informationUri"`
+	Rules          []SARIFRule `json:"rules"`
+}
+
+// SARIFRule is a kind of issue.
+type SARIFRule struct {
+	ID                   string             `json:"id"`
+	ShortDescription     SARIFMessage       `json:"shortDescription"`
+	DefaultConfiguration SARIFConfiguration `json:"defaultConfiguration"`
+}
+
+// SARIFConfiguration gives the level of the results of a rule.
+type SARIFConfiguration struct {
+	Level string `json:"level"` // "warning" or "note".
+}
+
+// SARIFMessage is a plain text message.
+type SARIFMessage struct {
+	Text string `json:"text"`
+}
+
+// SARIFResult is an issue, located in the DDL file.
+type SARIFResult struct {
+	RuleID    string          `json:"ruleId"`
+	Level     string          `json:"level"`
+	Message   SARIFMessage    `json:"message"`
+	Locations []SARIFLocation `json:"locations"`
+}
+
+// SARIFLocation is the line of the DDL file that defines the object an
+// issue is about, and the object itself.
+type SARIFLocation struct {
+	PhysicalLocation SARIFPhysicalLocation  `json:"physicalLocation"`
+	LogicalLocations []SARIFLogicalLocation `json:"logicalLocations"`
+}
+
+// SARIFPhysicalLocation is a line of a file.
+type SARIFPhysicalLocation struct {
+	ArtifactLocation SARIFArtifactLocation `json:"artifactLocation"`
+	Region           SARIFRegion           `json:"region"`
+}
+
+// SARIFArtifactLocation is a file, given by its URI (relative to the
+// directory of the SARIF file).
+type SARIFArtifactLocation struct {
+	URI string `json:"uri"`
+}
+
+// SARIFRegion is a line of a file (lines start at 1).
+type SARIFRegion struct {
+	StartLine int `json:"startLine"`
+}
+
+// SARIFLogicalLocation is a Spanner table or column.
+type SARIFLogicalLocation struct {
+	FullyQualifiedName string `json:"fullyQualifiedName"` // table or table.column.
+	Kind               string `json:"kind"`               // "table" or "column".
+}
+
+// issueRules are the SARIF rule IDs of schema issues.
+var issueRules = map[SchemaIssue]string{
+	DefaultValue:          "default-value",
+	ForeignKey:            "foreign-key",
+	MissingPrimaryKey:     "missing-primary-key",
+	MultiDimensionalArray: "multi-dimensional-array",
+	NoGoodType:            "no-good-type",
+	Numeric:               "numeric",
+	NumericThatFits:       "numeric-that-fits",
+	Decimal:               "decimal",
+	DecimalThatFits:       "decimal-that-fits",
+	Serial:                "serial",
+	AutoIncrement:         "auto-increment",
+	Timestamp:             "timestamp",
+	Datetime:              "datetime",
+	Widened:               "widened",
+	Time:                  "time",
+	IndexPrefix:           "index-prefix",
+	TinyintBool:           "tinyint-bool",
+	LargeObject:           "large-object",
+}
+
+const missingPrimaryKeyBrief = "Spanner requires a primary key for every table, so a column was added"
+
+// BuildSARIF returns the schema issues of conv as SARIF results located
+// in ddlFile, the file the DDL statements of conv (as printed with c) are
+// written to, separated by ";\n\n" and starting on the first line (see
+// conversion.WriteSchemaFile). c must not print comments. Column issues
+// are located on the line of the column, and missing primary keys on the
+// line of the table. Unlike the report, every instance of batched issues
+// is given, since they annotate different lines.
+func BuildSARIF(conv *Conv, ddlFile string, c ddl.Config) SARIF {
+	// First line of the CREATE TABLE statement of each Spanner table.
+	lines := make(map[string]int)
+	line := 1
+	for _, st := range conv.GetStatements(c) {
+		if st.Kind == ddl.TableStatement {
+			lines[st.Name] = line
+		}
+		line += strings.Count(st.DDL, "\n") + 2
+	}
+	used := make(map[SchemaIssue]bool)
+	var results []SARIFResult
+	add := func(i SchemaIssue, spTable, spCol, msg string) {
+		used[i] = true
+		r := SARIFResult{RuleID: issueRules[i], Level: issueLevel(i), Message: SARIFMessage{Text: msg}}
+		loc := SARIFLocation{PhysicalLocation: SARIFPhysicalLocation{
+			ArtifactLocation: SARIFArtifactLocation{URI: ddlFile},
+			Region:           SARIFRegion{StartLine: lines[spTable]},
+		}}
+		if spCol == "" {
+			loc.LogicalLocations = []SARIFLogicalLocation{{FullyQualifiedName: spTable, Kind: "table"}}
+		} else {
+			// Columns are printed one per line, after the first line.
+			for k, col := range conv.SpSchema[spTable].ColNames {
+				if col == spCol {
+					loc.PhysicalLocation.Region.StartLine += k + 1
+				}
+			}
+			loc.LogicalLocations = []SARIFLogicalLocation{{FullyQualifiedName: spTable + "." + spCol, Kind: "column"}}
+		}
+		r.Locations = []SARIFLocation{loc}
+		results = append(results, r)
+	}
+	for _, srcTable := range conv.SrcTables() {
+		spTable := conv.ToSpanner[srcTable].Name
+		if _, ok := lines[spTable]; !ok {
+			continue
+		}
+		if pk, ok := conv.SyntheticPKeys[spTable]; ok {
+			add(MissingPrimaryKey, spTable, pk.Col, fmt.Sprintf("Table '%s' has no primary key: column '%s' was added. %s", srcTable, pk.Col, missingPrimaryKeyBrief))
+		}
+		var cols []string
+		for col := range conv.Issues[srcTable] {
+			cols = append(cols, col)
+		}
+		conv.orderCols(srcTable, cols)
+		for _, srcCol := range cols {
+			spCol, ok := conv.ToSpanner[srcTable].Cols[srcCol]
+			if !ok {
+				continue
+			}
+			srcType := conv.SrcSchema[srcTable].ColDefs[srcCol].Type.Print()
+			spType := conv.SpSchema[spTable].ColDefs[spCol].T.PrintColumnDefType()
+			for _, i := range conv.Issues[srcTable][srcCol] {
+				if _, ok := IssueDB[i]; !ok {
+					continue
+				}
+				add(i, spTable, spCol, fmt.Sprintf("Column '%s.%s' (source type %s, Spanner type %s): %s", srcTable, srcCol, srcType, spType, IssueDB[i].Brief))
+			}
+		}
+	}
+	var rules []SARIFRule
+	for _, i := range sortedIssues(used) {
+		brief := missingPrimaryKeyBrief
+		if i != MissingPrimaryKey {
+			brief = IssueDB[i].Brief
+		}
+		rules = append(rules, SARIFRule{ID: issueRules[i], ShortDescription: SARIFMessage{Text: brief}, DefaultConfiguration: SARIFConfiguration{Level: issueLevel(i)}})
+	}
+	return SARIF{
+		Schema:  "https://json.schemastore.org/sarif-2.1.0.json",
+		Version: "2.1.0",
+		Runs: []SARIFRun{{
+			Tool:    SARIFTool{Driver: SARIFDriver{Name: "HarbourBridge", InformationURI: "https://github.com/cloudspannerecosystem/harbourbridge", Rules: rules}},
+			Results: results,
+		}},
+	}
+}
+
+// issueLevel returns the SARIF level of issue i.
+func issueLevel(i SchemaIssue) string {
+	if i != MissingPrimaryKey && IssueDB[i].severity == note {
+		return "note"
+	}
+	return "warning"
+}
+
+// sortedIssues returns the issues of m, in the order they are defined.
+func sortedIssues(m map[SchemaIssue]bool) []SchemaIssue {
+	var l []SchemaIssue
+	for i := range m {
+		l = append(l, i)
+	}
+	sort.Slice(l, func(i, j int) bool { return l[i] < l[j] })
+	return l
+}
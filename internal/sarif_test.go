@@ -0,0 +1,73 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package internal
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/cloudspannerecosystem/harbourbridge/schema"
+	"github.com/cloudspannerecosystem/harbourbridge/spanner/ddl"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBuildSARIF(t *testing.T) {
+	conv := MakeConv()
+	conv.SrcSchema["a"] = schema.Table{Name: "a", ColNames: []string{"id"}, ColDefs: map[string]schema.Column{
+		"id": {Name: "id", Type: schema.Type{Name: "bigint"}},
+	}}
+	conv.SrcSchema["b"] = schema.Table{Name: "b", ColNames: []string{"x", "y", "z"}, ColDefs: map[string]schema.Column{
+		"x": {Name: "x", Type: schema.Type{Name: "numeric"}},
+		"y": {Name: "y", Type: schema.Type{Name: "timestamp"}},
+		"z": {Name: "z", Type: schema.Type{Name: "serial"}},
+	}}
+	conv.SpSchema["a"] = ddl.CreateTable{Name: "a", ColNames: []string{"id"}, ColDefs: map[string]ddl.ColumnDef{
+		"id": {Name: "id", T: ddl.Type{Name: ddl.Int64}},
+	}, Pks: []ddl.IndexKey{{Col: "id"}}}
+	conv.SpSchema["b"] = ddl.CreateTable{Name: "b", ColNames: []string{"x", "y", "z", "synth_id"}, ColDefs: map[string]ddl.ColumnDef{
+		"x":        {Name: "x", T: ddl.Type{Name: ddl.Numeric}},
+		"y":        {Name: "y", T: ddl.Type{Name: ddl.Timestamp}},
+		"z":        {Name: "z", T: ddl.Type{Name: ddl.Int64}},
+		"synth_id": {Name: "synth_id", T: ddl.Type{Name: ddl.String, Len: 50}},
+	}, Pks: []ddl.IndexKey{{Col: "synth_id"}}}
+	conv.ToSpanner["a"] = NameAndCols{Name: "a", Cols: map[string]string{"id": "id"}}
+	conv.ToSpanner["b"] = NameAndCols{Name: "b", Cols: map[string]string{"x": "x", "y": "y", "z": "z"}}
+	conv.SyntheticPKeys["b"] = SyntheticPKey{Col: "synth_id"}
+	conv.Issues["b"] = map[string][]SchemaIssue{"x": {Numeric}, "y": {Timestamp}, "z": {Serial}}
+	c := ddl.Config{ProtectIds: true, Tables: true, ForeignKeys: true}
+	ddlLines := strings.Split(strings.Join(conv.GetDDL(c), ";\n\n"), "\n")
+
+	log := BuildSARIF(conv, "schema.ddl.txt", c)
+	assert.Equal(t, "2.1.0", log.Version)
+	run := log.Runs[0]
+	var rules []string
+	for _, r := range run.Tool.Driver.Rules {
+		rules = append(rules, r.ID+":"+r.DefaultConfiguration.Level)
+	}
+	assert.Equal(t, []string{"missing-primary-key:warning", "numeric:warning", "serial:warning", "timestamp:note"}, rules)
+	// Each result is located on the line of its column in the DDL file.
+	got := make(map[string]string)
+	for _, r := range run.Results {
+		loc := r.Locations[0]
+		assert.Equal(t, "schema.ddl.txt", loc.PhysicalLocation.ArtifactLocation.URI)
+		got[r.RuleID] = loc.LogicalLocations[0].FullyQualifiedName + " " + strings.TrimSpace(ddlLines[loc.PhysicalLocation.Region.StartLine-1])
+	}
+	assert.Equal(t, map[string]string{
+		"missing-primary-key": "b.synth_id `synth_id` STRING(50)",
+		"numeric":             "b.x `x` NUMERIC,",
+		"timestamp":           "b.y `y` TIMESTAMP,",
+		"serial":              "b.z `z` INT64,",
+	}, got)
+}
@@ -0,0 +1,181 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package internal
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/cloudspannerecosystem/harbourbridge/schema"
+	"github.com/cloudspannerecosystem/harbourbridge/spanner/ddl"
+)
+
+// SchemaChange is a change of the source schema that can be propagated
+// to Spanner (see SchemaChanges).
+type SchemaChange struct {
+	SrcTable    string
+	Description string // E.g. "column note was added (text)".
+	// DDL is the statement applying the change to Spanner, empty if the
+	// Spanner schema doesn't change.
+	DDL    string
+	srcCol string
+	spCol  string        // Empty if the column isn't migrated.
+	def    ddl.ColumnDef // Spanner column after the change.
+	added  bool
+}
+
+// SchemaChanges compares the source schema of conv with current, the
+// source schema converted again (e.g. after it changed while changes are
+// replicated to Spanner), and returns the changes that can be propagated
+// to Spanner, and the other changes (see SchemaDrift). Changes that can
+// be propagated are new columns, which are added to the Spanner table as
+// nullable columns (since Spanner can't add NOT NULL columns to tables
+// with rows), columns that became nullable, and columns whose type
+// changed but still converts to the same Spanner type, or to a longer
+// STRING or BYTES type (e.g. a widened varchar). Like SchemaDrift, it returns nothing if the source schema hash wasn't
+// recorded, or if current still has this hash.
+func (conv *Conv) SchemaChanges(current *Conv) ([]SchemaChange, []string) {
+	if conv.SrcSchemaHash == "" || SchemaHash(current.SrcSchema) == conv.SrcSchemaHash {
+		return nil, nil
+	}
+	var changes []SchemaChange
+	var others []string
+	for _, l := range conv.SchemaDrift(current.SrcSchema) {
+		if !strings.Contains(l, ": column ") {
+			others = append(others, l)
+		}
+	}
+	var tables []string
+	for t := range conv.SrcSchema {
+		if _, ok := current.SrcSchema[t]; ok {
+			tables = append(tables, t)
+		}
+	}
+	sort.Strings(tables)
+	for _, t := range tables {
+		c, o := conv.columnChanges(current, t)
+		changes = append(changes, c...)
+		others = append(others, o...)
+	}
+	sort.Strings(others)
+	return changes, others
+}
+
+// columnChanges returns the changes of the columns of source table
+// srcTable in current that can be propagated to Spanner, and the others.
+func (conv *Conv) columnChanges(current *Conv, srcTable string) ([]SchemaChange, []string) {
+	old, cur := conv.SrcSchema[srcTable], current.SrcSchema[srcTable]
+	spTable := conv.ToSpanner[srcTable].Name
+	sp := conv.SpSchema[spTable]
+	curSp := current.SpSchema[current.ToSpanner[srcTable].Name]
+	var changes []SchemaChange
+	var others []string
+	for _, name := range old.ColNames {
+		if _, ok := cur.ColDefs[name]; !ok {
+			others = append(others, fmt.Sprintf("Table %s: column %s was dropped", srcTable, name))
+		}
+	}
+	for _, name := range cur.ColNames {
+		was, inOld := old.ColDefs[name]
+		is := cur.ColDefs[name]
+		curSpCol, converted := current.ToSpanner[srcTable].Cols[name]
+		if !inOld {
+			ch := SchemaChange{SrcTable: srcTable, srcCol: name, spCol: curSpCol, added: true, Description: fmt.Sprintf("column %s was added (%s)", name, is.Type.Print())}
+			_, exists := sp.ColDefs[curSpCol]
+			switch {
+			case !converted:
+			case exists:
+				others = append(others, fmt.Sprintf("Table %s: column %s was added, but Spanner table %s already has a column %s", srcTable, name, spTable, curSpCol))
+				continue
+			default:
+				ch.def = curSp.ColDefs[curSpCol]
+				ch.def.NotNull = false
+				ch.DDL = ch.def.PrintAddColumn(ddl.Config{ProtectIds: true}, spTable)
+			}
+			changes = append(changes, ch)
+			continue
+		}
+		if was.Type.Print() == is.Type.Print() && was.NotNull == is.NotNull {
+			continue
+		}
+		ch := SchemaChange{SrcTable: srcTable, srcCol: name, Description: fmt.Sprintf("column %s changed from %s to %s", name, columnString(was), columnString(is))}
+		spCol, ok := conv.ToSpanner[srcTable].Cols[name]
+		if !ok || !converted {
+			// The column isn't migrated, or is dropped from the
+			// Spanner table (see DropColumns).
+			changes = append(changes, ch)
+			continue
+		}
+		from := sp.ColDefs[spCol]
+		to := from
+		to.NotNull = from.NotNull && is.NotNull
+		if t := curSp.ColDefs[curSpCol].T; t != from.T {
+			if !widens(from.T, t) {
+				others = append(others, fmt.Sprintf("Table %s: column %s changed from %s to %s, which Spanner column %s can't store as %s", srcTable, name, columnString(was), columnString(is), spCol, from.T.PrintColumnDefType()))
+				continue
+			}
+			to.T = t
+		}
+		ch.spCol, ch.def = spCol, to
+		if to != from {
+			if isKeyCol(sp, spCol) {
+				others = append(others, fmt.Sprintf("Table %s: column %s changed from %s to %s, but Spanner key column %s can't be altered", srcTable, name, columnString(was), columnString(is), spCol))
+				continue
+			}
+			ch.DDL = to.PrintAlterColumn(ddl.Config{ProtectIds: true}, spTable)
+		}
+		changes = append(changes, ch)
+	}
+	return changes, others
+}
+
+// ApplySchemaChanges updates the schema of conv with changes returned by
+// SchemaChanges for current, once they have been applied to Spanner.
+func (conv *Conv) ApplySchemaChanges(current *Conv, changes []SchemaChange) {
+	for _, ch := range changes {
+		src := conv.SrcSchema[ch.SrcTable]
+		if ch.added {
+			src.ColNames = append(src.ColNames, ch.srcCol)
+		}
+		src.ColDefs[ch.srcCol] = current.SrcSchema[ch.SrcTable].ColDefs[ch.srcCol]
+		conv.SrcSchema[ch.SrcTable] = src
+		if ch.spCol == "" {
+			continue
+		}
+		spTable := conv.ToSpanner[ch.SrcTable].Name
+		sp := conv.SpSchema[spTable]
+		if ch.added {
+			sp.ColNames = append(sp.ColNames, ch.spCol)
+			conv.ToSpanner[ch.SrcTable].Cols[ch.srcCol] = ch.spCol
+			conv.ToSource[spTable].Cols[ch.spCol] = ch.srcCol
+		}
+		sp.ColDefs[ch.spCol] = ch.def
+		conv.SpSchema[spTable] = sp
+	}
+}
+
+// widens returns true if values of type from can be stored in a column
+// of type from altered to type to.
+func widens(from, to ddl.Type) bool {
+	return (from.Name == ddl.String || from.Name == ddl.Bytes) && to.Name == from.Name && to.IsArray == from.IsArray && to.Len > from.Len
+}
+
+func columnString(c schema.Column) string {
+	if c.NotNull {
+		return c.Type.Print() + " NOT NULL"
+	}
+	return c.Type.Print()
+}
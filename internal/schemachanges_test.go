@@ -0,0 +1,108 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package internal
+
+import (
+	"testing"
+
+	"github.com/cloudspannerecosystem/harbourbridge/schema"
+	"github.com/cloudspannerecosystem/harbourbridge/spanner/ddl"
+	"github.com/stretchr/testify/assert"
+)
+
+type changesTestCol struct {
+	name    string
+	src     schema.Type
+	sp      ddl.Type
+	notNull bool
+}
+
+// changesTestConv returns a conv with table t converted from cols, with
+// the same names in Spanner.
+func changesTestConv(cols []changesTestCol) *Conv {
+	conv := MakeConv()
+	src := schema.Table{Name: "t", ColDefs: map[string]schema.Column{}, PrimaryKeys: []schema.Key{{Column: "id"}}}
+	sp := ddl.CreateTable{Name: "t", ColDefs: map[string]ddl.ColumnDef{}, Pks: []ddl.IndexKey{{Col: "id"}}}
+	m := map[string]string{}
+	for _, c := range cols {
+		src.ColNames = append(src.ColNames, c.name)
+		src.ColDefs[c.name] = schema.Column{Name: c.name, Type: c.src, NotNull: c.notNull}
+		sp.ColNames = append(sp.ColNames, c.name)
+		sp.ColDefs[c.name] = ddl.ColumnDef{Name: c.name, T: c.sp, NotNull: c.notNull}
+		m[c.name] = c.name
+	}
+	conv.SrcSchema["t"] = src
+	conv.SpSchema["t"] = sp
+	conv.ToSpanner["t"] = NameAndCols{Name: "t", Cols: m}
+	inverse := map[string]string{}
+	for k, v := range m {
+		inverse[v] = k
+	}
+	conv.ToSource["t"] = NameAndCols{Name: "t", Cols: inverse}
+	return conv
+}
+
+func TestSchemaChanges(t *testing.T) {
+	cols := []changesTestCol{
+		{"id", schema.Type{Name: "int4"}, ddl.Type{Name: ddl.Int64}, true},
+		{"name", schema.Type{Name: "varchar", Mods: []int64{10}}, ddl.Type{Name: ddl.String, Len: 10}, true},
+		{"code", schema.Type{Name: "varchar", Mods: []int64{5}}, ddl.Type{Name: ddl.String, Len: 5}, false},
+		{"price", schema.Type{Name: "numeric"}, ddl.Type{Name: ddl.Numeric}, false},
+	}
+	conv := changesTestConv(cols)
+	conv.RecordSchemaHash()
+	changes, others := conv.SchemaChanges(changesTestConv(cols))
+	assert.Nil(t, changes)
+	assert.Nil(t, others)
+
+	cols[0] = changesTestCol{"id", schema.Type{Name: "int8"}, ddl.Type{Name: ddl.Int64}, true}
+	cols[1] = changesTestCol{"name", schema.Type{Name: "varchar", Mods: []int64{20}}, ddl.Type{Name: ddl.String, Len: 20}, false}
+	cols[2] = changesTestCol{"code", schema.Type{Name: "int4"}, ddl.Type{Name: ddl.Int64}, false}
+	cols = append(cols[:3], changesTestCol{"note", schema.Type{Name: "text"}, ddl.Type{Name: ddl.String, Len: ddl.MaxLength}, true})
+	current := changesTestConv(cols)
+	changes, others = conv.SchemaChanges(current)
+	var descs, stmts []string
+	for _, c := range changes {
+		descs = append(descs, c.Description)
+		stmts = append(stmts, c.DDL)
+	}
+	assert.Equal(t, []string{
+		"column id changed from int4 NOT NULL to int8 NOT NULL",
+		"column name changed from varchar(10) NOT NULL to varchar(20)",
+		"column note was added (text)",
+	}, descs)
+	assert.Equal(t, []string{
+		"",
+		"ALTER TABLE `t` ALTER COLUMN `name` STRING(20)",
+		"ALTER TABLE `t` ADD COLUMN `note` STRING(MAX)",
+	}, stmts)
+	assert.Equal(t, []string{
+		"Table t: column code changed from varchar(5) to int4, which Spanner column code can't store as STRING(5)",
+		"Table t: column price was dropped",
+	}, others)
+
+	conv.ApplySchemaChanges(current, changes)
+	assert.Equal(t, []string{"id", "name", "code", "price", "note"}, conv.SrcSchema["t"].ColNames)
+	assert.Equal(t, schema.Type{Name: "int8"}, conv.SrcSchema["t"].ColDefs["id"].Type)
+	assert.Equal(t, ddl.ColumnDef{Name: "name", T: ddl.Type{Name: ddl.String, Len: 20}}, conv.SpSchema["t"].ColDefs["name"])
+	assert.Equal(t, []string{"id", "name", "code", "price", "note"}, conv.SpSchema["t"].ColNames)
+	assert.Equal(t, ddl.ColumnDef{Name: "note", T: ddl.Type{Name: ddl.String, Len: ddl.MaxLength}}, conv.SpSchema["t"].ColDefs["note"])
+	assert.Equal(t, "note", conv.ToSpanner["t"].Cols["note"])
+	assert.Equal(t, "note", conv.ToSource["t"].Cols["note"])
+	// Only the changes that can't be propagated are left.
+	changes, others = conv.SchemaChanges(current)
+	assert.Empty(t, changes)
+	assert.Len(t, others, 2)
+}
@@ -0,0 +1,173 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package internal
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/cloudspannerecosystem/harbourbridge/schema"
+)
+
+// RecordSchemaHash records the hash of the source schema (see SchemaHash)
+// in conv.SrcSchemaHash, so that later runs can check that the source
+// schema hasn't changed since it was converted. It is called right after
+// the source schema is read, before it is changed by options such as
+// SkipColumnlessTables.
+func (conv *Conv) RecordSchemaHash() {
+	conv.SrcSchemaHash = SchemaHash(conv.SrcSchema)
+}
+
+// SchemaHash returns a hash of the structure of tables: their columns
+// (names, types and nullability), primary keys, foreign keys and indexes.
+// Statistics (e.g. index scans) aren't part of the structure.
+func SchemaHash(tables map[string]schema.Table) string {
+	h := sha256.New()
+	for _, l := range structureLines(tables) {
+		fmt.Fprintln(h, l)
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// SchemaDrift returns the differences between the structure of the source
+// tables of conv and their structure in current (e.g. as read again from
+// the source database), as sentences sorted by table. Tables skipped by
+// schema conversion (see SkipColumnlessTables) are ignored. It returns
+// nil if the source schema hash wasn't recorded, or if current still has
+// this hash.
+func (conv *Conv) SchemaDrift(current map[string]schema.Table) []string {
+	if conv.SrcSchemaHash == "" || SchemaHash(current) == conv.SrcSchemaHash {
+		return nil
+	}
+	var names []string
+	for name := range conv.SrcSchema {
+		names = append(names, name)
+	}
+	for name := range current {
+		if _, ok := conv.SrcSchema[name]; !ok {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	var l []string
+	for _, name := range names {
+		if _, ok := conv.SkippedTables[name]; ok {
+			continue
+		}
+		old, inOld := conv.SrcSchema[name]
+		cur, inCur := current[name]
+		switch {
+		case !inCur:
+			l = append(l, fmt.Sprintf("Table %s was dropped", name))
+		case !inOld:
+			l = append(l, fmt.Sprintf("Table %s was added", name))
+		default:
+			l = append(l, tableDrift(old, cur)...)
+		}
+	}
+	return l
+}
+
+// tableDrift returns the differences between the structure of table old
+// and its structure cur.
+func tableDrift(old, cur schema.Table) []string {
+	o, c := tableStructure(old), tableStructure(cur)
+	var parts []string
+	for p := range o {
+		parts = append(parts, p)
+	}
+	for p := range c {
+		if _, ok := o[p]; !ok {
+			parts = append(parts, p)
+		}
+	}
+	sort.Strings(parts)
+	var l []string
+	for _, p := range parts {
+		was, inOld := o[p]
+		is, inCur := c[p]
+		switch {
+		case !inCur:
+			l = append(l, fmt.Sprintf("Table %s: %s was dropped", old.Name, p))
+		case !inOld:
+			l = append(l, fmt.Sprintf("Table %s: %s was added (%s)", old.Name, p, is))
+		case was != is:
+			l = append(l, fmt.Sprintf("Table %s: %s changed from %s to %s", old.Name, p, was, is))
+		}
+	}
+	return l
+}
+
+// structureLines describes the structure of tables, one line per part of
+// a table, in a stable order.
+func structureLines(tables map[string]schema.Table) []string {
+	var l []string
+	for name, t := range tables {
+		for p, s := range tableStructure(t) {
+			l = append(l, name+"\t"+p+"\t"+s)
+		}
+	}
+	sort.Strings(l)
+	return l
+}
+
+// tableStructure describes the parts of table t (e.g. "column a"), by
+// part.
+func tableStructure(t schema.Table) map[string]string {
+	m := make(map[string]string)
+	for _, c := range t.ColDefs {
+		s := c.Type.Print()
+		if c.NotNull {
+			s += " NOT NULL"
+		}
+		m["column "+c.Name] = s
+	}
+	if len(t.PrimaryKeys) > 0 {
+		m["primary key"] = keysString(t.PrimaryKeys)
+	}
+	for _, fk := range t.ForeignKeys {
+		name := fk.Name
+		if name == "" {
+			name = "(" + strings.Join(fk.Columns, ", ") + ")"
+		}
+		m["foreign key "+name] = fmt.Sprintf("(%s) REFERENCES %s (%s)", strings.Join(fk.Columns, ", "), fk.ReferTable, strings.Join(fk.ReferColumns, ", "))
+	}
+	for _, i := range t.Indexes {
+		s := keysString(i.Keys)
+		if i.Unique {
+			s = "UNIQUE " + s
+		}
+		m["index "+i.Name] = s
+	}
+	return m
+}
+
+func keysString(keys []schema.Key) string {
+	var l []string
+	for _, k := range keys {
+		s := k.Column
+		if k.Length > 0 {
+			s += fmt.Sprintf("(%d)", k.Length)
+		}
+		if k.Desc {
+			s += " DESC"
+		}
+		l = append(l, s)
+	}
+	return "(" + strings.Join(l, ", ") + ")"
+}
@@ -0,0 +1,94 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package internal
+
+import (
+	"testing"
+
+	"github.com/cloudspannerecosystem/harbourbridge/schema"
+	"github.com/stretchr/testify/assert"
+)
+
+func driftTestSchema() map[string]schema.Table {
+	return map[string]schema.Table{
+		"orders": schema.Table{
+			Name:     "orders",
+			ColNames: []string{"id", "total", "customer"},
+			ColDefs: map[string]schema.Column{
+				"id":       schema.Column{Name: "id", Type: schema.Type{Name: "int4"}, NotNull: true},
+				"total":    schema.Column{Name: "total", Type: schema.Type{Name: "numeric", Mods: []int64{6, 2}}},
+				"customer": schema.Column{Name: "customer", Type: schema.Type{Name: "int8"}},
+			},
+			PrimaryKeys: []schema.Key{schema.Key{Column: "id"}},
+			ForeignKeys: []schema.ForeignKey{schema.ForeignKey{Columns: []string{"customer"}, ReferTable: "customers", ReferColumns: []string{"id"}}},
+			Indexes:     []schema.Index{schema.Index{Name: "orders_total", Keys: []schema.Key{schema.Key{Column: "total", Desc: true}}}},
+		},
+		"customers": schema.Table{
+			Name:        "customers",
+			ColNames:    []string{"id"},
+			ColDefs:     map[string]schema.Column{"id": schema.Column{Name: "id", Type: schema.Type{Name: "int8"}, NotNull: true}},
+			PrimaryKeys: []schema.Key{schema.Key{Column: "id"}},
+		},
+	}
+}
+
+func TestSchemaHash(t *testing.T) {
+	h := SchemaHash(driftTestSchema())
+	assert.Equal(t, h, SchemaHash(driftTestSchema()))
+	// Statistics aren't part of the structure.
+	s := driftTestSchema()
+	s["orders"].Indexes[0].Scans = 12
+	s["orders"].Indexes[0].HasScans = true
+	assert.Equal(t, h, SchemaHash(s))
+	s["orders"].ColDefs["total"] = schema.Column{Name: "total", Type: schema.Type{Name: "numeric", Mods: []int64{8, 2}}}
+	assert.NotEqual(t, h, SchemaHash(s))
+}
+
+func TestSchemaDrift(t *testing.T) {
+	conv := MakeConv()
+	conv.SrcSchema = driftTestSchema()
+	conv.SrcSchema["empty"] = schema.Table{Name: "empty"}
+	assert.Nil(t, conv.SchemaDrift(map[string]schema.Table{}), "no recorded hash")
+	conv.RecordSchemaHash()
+	delete(conv.SrcSchema, "empty")
+	conv.SkippedTables = map[string]string{"empty": "it has no columns"}
+
+	current := driftTestSchema()
+	current["empty"] = schema.Table{Name: "empty"}
+	assert.Nil(t, conv.SchemaDrift(current))
+
+	// Changes of skipped tables are ignored.
+	current["empty"] = schema.Table{Name: "empty", ColDefs: map[string]schema.Column{"a": schema.Column{Name: "a", Type: schema.Type{Name: "text"}}}}
+	assert.Nil(t, conv.SchemaDrift(current))
+
+	orders := current["orders"]
+	orders.ColDefs["total"] = schema.Column{Name: "total", Type: schema.Type{Name: "numeric", Mods: []int64{8, 2}}}
+	orders.ColDefs["note"] = schema.Column{Name: "note", Type: schema.Type{Name: "text"}}
+	delete(orders.ColDefs, "customer")
+	orders.ForeignKeys = nil
+	orders.Indexes[0].Unique = true
+	current["orders"] = orders
+	delete(current, "customers")
+	current["refunds"] = schema.Table{Name: "refunds"}
+	assert.Equal(t, []string{
+		"Table customers was dropped",
+		"Table orders: column customer was dropped",
+		"Table orders: column note was added (text)",
+		"Table orders: column total changed from numeric(6,2) to numeric(8,2)",
+		"Table orders: foreign key (customer) was dropped",
+		"Table orders: index orders_total changed from (total DESC) to UNIQUE (total DESC)",
+		"Table refunds was added",
+	}, conv.SchemaDrift(current))
+}
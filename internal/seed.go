@@ -0,0 +1,61 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package internal
+
+import (
+	crand "crypto/rand"
+	"encoding/binary"
+	"hash/fnv"
+	"math/rand"
+)
+
+// SetSeed sets the seed of the random values generated during conversion
+// (the keys of random synthetic primary keys, see RandomKeyGenerator), so
+// that runs with the same seed generate the same values, e.g. to
+// reproduce a rehearsal while debugging. The seed is recorded in
+// conv.Seed, and so in session files. A seed of 0 keeps conv.Seed if it
+// is set (e.g. read from a session file), and picks a random seed
+// otherwise.
+func (conv *Conv) SetSeed(seed int64) {
+	if seed == 0 {
+		seed = conv.Seed
+	}
+	for seed == 0 {
+		var b [8]byte
+		crand.Read(b[:])
+		seed = int64(binary.BigEndian.Uint64(b[:]))
+	}
+	conv.Seed = seed
+	conv.rands = make(map[string]*rand.Rand)
+}
+
+// randFor returns the source of the random values of spTable, or nil if
+// no seed is set (see SetSeed). Each table has its own source, seeded from
+// conv.Seed and the name of the table, so that the values of a table
+// don't depend on the order tables are converted in. It must be called
+// with conv.schemaMu held.
+func (conv *Conv) randFor(spTable string) *rand.Rand {
+	if conv.rands == nil {
+		return nil
+	}
+	r, ok := conv.rands[spTable]
+	if !ok {
+		h := fnv.New64a()
+		h.Write([]byte(spTable))
+		r = rand.New(rand.NewSource(conv.Seed ^ int64(h.Sum64())))
+		conv.rands[spTable] = r
+	}
+	return r
+}
@@ -0,0 +1,52 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package internal
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSetSeed(t *testing.T) {
+	keys := func(seed int64, table string) []interface{} {
+		conv := MakeConv()
+		conv.SyntheticPKeys[table] = SyntheticPKey{Col: "synth_id", Generator: "uuid"}
+		if seed != 0 {
+			conv.SetSeed(seed)
+		}
+		var l []interface{}
+		for i := 0; i < 3; i++ {
+			_, v, ok := conv.NextSyntheticPKey(table)
+			assert.True(t, ok)
+			l = append(l, v)
+		}
+		return l
+	}
+	assert.Equal(t, keys(42, "t"), keys(42, "t"))
+	assert.NotEqual(t, keys(42, "t"), keys(43, "t"))
+	assert.NotEqual(t, keys(42, "t"), keys(42, "u"))
+	assert.NotEqual(t, keys(0, "t"), keys(0, "t"))
+
+	conv := MakeConv()
+	conv.SetSeed(0)
+	assert.NotEqual(t, int64(0), conv.Seed)
+	conv.Seed = 7
+	conv.SetSeed(0)
+	assert.Equal(t, int64(7), conv.Seed)
+	conv.SetSeed(9)
+	assert.Equal(t, int64(9), conv.Seed)
+	assert.Equal(t, int64(9), Summarize(conv, nil).Seed)
+}
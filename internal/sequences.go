@@ -0,0 +1,113 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package internal
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/cloudspannerecosystem/harbourbridge/schema"
+	"github.com/cloudspannerecosystem/harbourbridge/spanner/ddl"
+)
+
+// SetSrcSequence adds (or replaces) a source sequence.
+func (conv *Conv) SetSrcSequence(s schema.Sequence) {
+	conv.schemaMu.Lock()
+	defer conv.schemaMu.Unlock()
+	if conv.SrcSequences == nil {
+		conv.SrcSequences = make(map[string]schema.Sequence)
+	}
+	conv.SrcSequences[s.Name] = s
+}
+
+// AddSequences maps each source sequence to a Spanner sequence, if the
+// target supports sequences. Spanner sequences are bit-reversed, so they
+// don't return the same values as the source sequence. Instead, their
+// counter starts at the next value of the source sequence: values
+// returned by applications after cutover are then based on counter values
+// the source never used. Sequence names can't clash with table or index
+// names.
+func (conv *Conv) AddSequences() {
+	conv.Sequences = make(map[string]ddl.CreateSequence)
+	if !conv.Supports(Sequences) {
+		return
+	}
+	used := make(map[string]bool)
+	for t, ct := range conv.SpSchema {
+		used[t] = true
+		for _, index := range ct.Indexes {
+			used[index.Name] = true
+		}
+	}
+	if conv.spill != nil {
+		for t, indexes := range conv.spill.indexes {
+			used[t] = true
+			for _, index := range indexes {
+				used[index] = true
+			}
+		}
+	}
+	for _, name := range conv.srcSequenceNames() {
+		s := conv.SrcSequences[name]
+		start := s.Next
+		if start < 1 {
+			start = 1
+		}
+		conv.Sequences[name] = ddl.CreateSequence{Name: getSpannerId(conv.NameMapping.apply(name), used), StartWithCounter: start}
+	}
+}
+
+// SpSequences returns the Spanner sequences, in name order.
+func (conv *Conv) SpSequences() []ddl.CreateSequence {
+	var l []ddl.CreateSequence
+	for _, s := range conv.Sequences {
+		l = append(l, s)
+	}
+	sort.Slice(l, func(i, j int) bool { return l[i].Name < l[j].Name })
+	return l
+}
+
+func (conv *Conv) srcSequenceNames() []string {
+	var l []string
+	for name := range conv.SrcSequences {
+		l = append(l, name)
+	}
+	sort.Strings(l)
+	return l
+}
+
+// sequenceReport describes how each source sequence was converted, for
+// the report.
+func sequenceReport(conv *Conv) []string {
+	var l []string
+	for _, name := range conv.srcSequenceNames() {
+		s := conv.SrcSequences[name]
+		src := fmt.Sprintf("Sequence '%s'", name)
+		if s.Table != "" {
+			src += fmt.Sprintf(" (owned by column '%s' of table '%s')", s.Column, s.Table)
+		}
+		sp, ok := conv.Sequences[name]
+		if !ok {
+			l = append(l, fmt.Sprintf("%s was not migrated because the target doesn't support sequences: its next value is %d", src, s.Next))
+			continue
+		}
+		line := fmt.Sprintf("%s was mapped to Spanner sequence '%s', with start counter %d", src, sp.Name, sp.StartWithCounter)
+		if s.Increment != 1 {
+			line += fmt.Sprintf(". Spanner sequences are bit-reversed and ignore the source increment (%d)", s.Increment)
+		}
+		l = append(l, line)
+	}
+	return l
+}
@@ -0,0 +1,67 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package internal
+
+import (
+	"testing"
+
+	"github.com/cloudspannerecosystem/harbourbridge/schema"
+	"github.com/cloudspannerecosystem/harbourbridge/spanner/ddl"
+	"github.com/stretchr/testify/assert"
+)
+
+func sequencesConv() *Conv {
+	conv := MakeConv()
+	conv.SpSchema["users"] = ddl.CreateTable{
+		Name:     "users",
+		ColNames: []string{"id"},
+		ColDefs:  map[string]ddl.ColumnDef{"id": {Name: "id", T: ddl.Type{Name: ddl.Int64}}},
+		Pks:      []ddl.IndexKey{{Col: "id"}},
+	}
+	conv.SetSrcSequence(schema.Sequence{Name: "users_id_seq", Increment: 1, Next: 43, Table: "users", Column: "id"})
+	conv.SetSrcSequence(schema.Sequence{Name: "Users", Increment: 10, Next: 0})
+	return conv
+}
+
+func TestAddSequences(t *testing.T) {
+	conv := sequencesConv()
+	conv.Features = DefaultFeatures()
+	conv.AddSequences()
+	assert.Equal(t, map[string]ddl.CreateSequence{
+		"users_id_seq": {Name: "users_id_seq", StartWithCounter: 43},
+		"Users":        {Name: "Users_1", StartWithCounter: 1}, // Clashes with table users.
+	}, conv.Sequences)
+	assert.Equal(t, []string{
+		"CREATE SEQUENCE Users_1 OPTIONS (sequence_kind = 'bit_reversed_positive', start_with_counter = 1)",
+		"CREATE SEQUENCE users_id_seq OPTIONS (sequence_kind = 'bit_reversed_positive', start_with_counter = 43)",
+		"CREATE TABLE users (\n    id INT64 \n) PRIMARY KEY (id)",
+	}, conv.GetDDL(ddl.Config{Tables: true}))
+	assert.Empty(t, conv.GetDDL(ddl.Config{ForeignKeys: true}))
+	assert.Equal(t, []string{
+		"Sequence 'Users' was mapped to Spanner sequence 'Users_1', with start counter 1. Spanner sequences are bit-reversed and ignore the source increment (10)",
+		"Sequence 'users_id_seq' (owned by column 'id' of table 'users') was mapped to Spanner sequence 'users_id_seq', with start counter 43",
+	}, sequenceReport(conv))
+}
+
+func TestAddSequences_Unsupported(t *testing.T) {
+	conv := sequencesConv()
+	conv.AddSequences() // Sequences are not a base feature.
+	assert.Empty(t, conv.Sequences)
+	assert.Equal(t, []string{"CREATE TABLE users (\n    id INT64 \n) PRIMARY KEY (id)"}, conv.GetDDL(ddl.Config{Tables: true}))
+	assert.Equal(t, []string{
+		"Sequence 'Users' was not migrated because the target doesn't support sequences: its next value is 0",
+		"Sequence 'users_id_seq' (owned by column 'id' of table 'users') was not migrated because the target doesn't support sequences: its next value is 43",
+	}, sequenceReport(conv))
+}
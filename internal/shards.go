@@ -0,0 +1,83 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package internal
+
+import (
+	"sort"
+)
+
+// ShardTables splits the source tables of conv into at most n shards of
+// similar sizes, to load their data in parallel. The size of a table is
+// its number of rows counted during schema conversion (see Stats.Rows);
+// for sources whose rows aren't counted then, tables are spread evenly.
+// Groups of tables, largest first, are assigned to the shard with the
+// fewest rows so far. A table merged into the Spanner table of another
+// (see MergeTable) is in the same group as that table, since merges are
+// only resolved among the tables loaded together. Empty shards are
+// dropped, and the tables of each shard are in the order of SrcTables.
+func (conv *Conv) ShardTables(n int) [][]string {
+	groups := make(map[string][]string)
+	var keys []string
+	for _, t := range conv.SrcTables() {
+		key := t
+		if m, ok := conv.MergedTables[t]; ok {
+			key = m.Into
+		} else if spTable, err := GetSpannerTable(conv, t); err == nil {
+			key = spTable
+		}
+		if _, ok := groups[key]; !ok {
+			keys = append(keys, key)
+		}
+		groups[key] = append(groups[key], t)
+	}
+	size := func(key string) int64 {
+		// Counting each table as at least one row spreads tables of
+		// unknown size evenly.
+		var rows int64
+		for _, t := range groups[key] {
+			rows += conv.Stats.Rows[t] + 1
+		}
+		return rows
+	}
+	sort.SliceStable(keys, func(i, j int) bool { return size(keys[i]) > size(keys[j]) })
+	if n < 1 {
+		n = 1
+	}
+	shards := make([][]string, n)
+	rows := make([]int64, n)
+	for _, key := range keys {
+		min := 0
+		for i := range rows {
+			if rows[i] < rows[min] {
+				min = i
+			}
+		}
+		shards[min] = append(shards[min], groups[key]...)
+		rows[min] += size(key)
+	}
+	order := make(map[string]int)
+	for i, t := range conv.SrcTables() {
+		order[t] = i
+	}
+	var l [][]string
+	for _, s := range shards {
+		if len(s) == 0 {
+			continue
+		}
+		sort.Slice(s, func(i, j int) bool { return order[s[i]] < order[s[j]] })
+		l = append(l, s)
+	}
+	return l
+}
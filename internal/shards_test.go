@@ -0,0 +1,37 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package internal
+
+import (
+	"testing"
+
+	"github.com/cloudspannerecosystem/harbourbridge/schema"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestShardTables(t *testing.T) {
+	conv := MakeConv()
+	for table, rows := range map[string]int64{"a": 1000, "b": 600, "c": 500, "d": 100, "e": 50} {
+		conv.SrcSchema[table] = schema.Table{Name: table}
+		conv.ToSpanner[table] = NameAndCols{Name: table}
+		conv.Stats.Rows[table] = rows
+	}
+	// e is merged into a, so they are loaded together.
+	conv.MergedTables = map[string]MergeTable{"e": {Table: "e", Into: "a"}}
+
+	assert.Equal(t, [][]string{{"a", "d", "e"}, {"b", "c"}}, conv.ShardTables(2))
+	assert.Equal(t, [][]string{{"a", "e"}, {"b"}, {"c"}, {"d"}}, conv.ShardTables(10))
+	assert.Equal(t, [][]string{{"a", "b", "c", "d", "e"}}, conv.ShardTables(0))
+}
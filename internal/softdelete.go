@@ -0,0 +1,96 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package internal
+
+import (
+	"fmt"
+	"strings"
+)
+
+// SoftDelete identifies the soft-deleted rows of source tables, which
+// are excluded from data conversion: the rows whose Column isn't NULL if
+// Value is empty (e.g. a deleted_at timestamp), and the rows whose Column
+// is Value otherwise (e.g. an is_deleted flag). Values are compared as
+// text, as the source database formats them. It applies to every source
+// table that has Column.
+type SoftDelete struct {
+	Column string
+	Value  string
+}
+
+// ParseSoftDelete parses a soft-delete specification: a column name, or
+// column=value (see SoftDelete).
+func ParseSoftDelete(s string) (SoftDelete, error) {
+	sd := SoftDelete{Column: strings.TrimSpace(s)}
+	if i := strings.Index(s, "="); i >= 0 {
+		sd = SoftDelete{Column: strings.TrimSpace(s[:i]), Value: strings.TrimSpace(s[i+1:])}
+		if sd.Value == "" {
+			return SoftDelete{}, fmt.Errorf("invalid soft-delete column %q: expected column or column=value", s)
+		}
+	}
+	if sd.Column == "" {
+		return SoftDelete{}, fmt.Errorf("invalid soft-delete column %q: expected column or column=value", s)
+	}
+	return sd, nil
+}
+
+func (sd SoftDelete) String() string {
+	if sd.Value == "" {
+		return fmt.Sprintf("%s is not NULL", sd.Column)
+	}
+	return fmt.Sprintf("%s = %s", sd.Column, sd.Value)
+}
+
+// SetSoftDelete configures conv to exclude soft-deleted rows from data
+// conversion. Source packages filter them out when they read the rows
+// of each table (see SoftDeleted), and record how many rows they
+// excluded with StatsAddExcludedRows.
+func (conv *Conv) SetSoftDelete(sd SoftDelete) {
+	conv.softDelete = sd
+}
+
+// SoftDeleted returns how the soft-deleted rows of srcTable are
+// identified, and true if srcTable has soft-deleted rows to exclude.
+func (conv *Conv) SoftDeleted(srcTable string) (SoftDelete, bool) {
+	if conv.softDelete.Column == "" {
+		return SoftDelete{}, false
+	}
+	_, ok := conv.SrcSchema[srcTable].ColDefs[conv.softDelete.Column]
+	return conv.softDelete, ok
+}
+
+// StatsAddExcludedRows adds n to the count of soft-deleted rows of
+// srcTable that were excluded from data conversion.
+func (conv *Conv) StatsAddExcludedRows(srcTable string, n int64) {
+	conv.statsMu.Lock()
+	defer conv.statsMu.Unlock()
+	if conv.Stats.Excluded == nil {
+		conv.Stats.Excluded = make(map[string]int64)
+	}
+	conv.Stats.Excluded[srcTable] += n
+}
+
+// buildExcludedBody reports how many soft-deleted rows of srcTable were
+// excluded.
+func buildExcludedBody(conv *Conv, srcTable string) []tableReportBody {
+	n := conv.Stats.Excluded[srcTable]
+	if n == 0 {
+		return nil
+	}
+	return []tableReportBody{{
+		Heading: "Soft-deleted rows",
+		Lines:   []string{fmt.Sprintf("%d soft-deleted rows (%s) were excluded", n, conv.softDelete)},
+	}}
+}
@@ -0,0 +1,55 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package internal
+
+import (
+	"testing"
+
+	"github.com/cloudspannerecosystem/harbourbridge/schema"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseSoftDelete(t *testing.T) {
+	sd, err := ParseSoftDelete("deleted_at")
+	assert.Nil(t, err)
+	assert.Equal(t, SoftDelete{Column: "deleted_at"}, sd)
+	assert.Equal(t, "deleted_at is not NULL", sd.String())
+	sd, err = ParseSoftDelete("is_deleted = 1")
+	assert.Nil(t, err)
+	assert.Equal(t, SoftDelete{Column: "is_deleted", Value: "1"}, sd)
+	assert.Equal(t, "is_deleted = 1", sd.String())
+	for _, s := range []string{"", "is_deleted=", "=1"} {
+		_, err := ParseSoftDelete(s)
+		assert.NotNil(t, err, s)
+	}
+}
+
+func TestSoftDeleted(t *testing.T) {
+	conv := MakeConv()
+	conv.SrcSchema["t1"] = schema.Table{Name: "t1", ColNames: []string{"a", "deleted_at"}, ColDefs: map[string]schema.Column{"a": {Name: "a"}, "deleted_at": {Name: "deleted_at"}}}
+	conv.SrcSchema["t2"] = schema.Table{Name: "t2", ColNames: []string{"a"}, ColDefs: map[string]schema.Column{"a": {Name: "a"}}}
+	_, ok := conv.SoftDeleted("t1")
+	assert.False(t, ok)
+	conv.SetSoftDelete(SoftDelete{Column: "deleted_at"})
+	sd, ok := conv.SoftDeleted("t1")
+	assert.True(t, ok)
+	assert.Equal(t, SoftDelete{Column: "deleted_at"}, sd)
+	_, ok = conv.SoftDeleted("t2")
+	assert.False(t, ok)
+
+	conv.StatsAddExcludedRows("t1", 3)
+	assert.Equal(t, []tableReportBody{{Heading: "Soft-deleted rows", Lines: []string{"3 soft-deleted rows (deleted_at is not NULL) were excluded"}}}, buildExcludedBody(conv, "t1"))
+	assert.Nil(t, buildExcludedBody(conv, "t2"))
+}
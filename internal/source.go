@@ -0,0 +1,80 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package internal
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+// Queryer runs queries on a live source database. It is implemented by
+// *sql.DB and *sql.Tx, and can be implemented for a single *sql.Conn,
+// so that data is read from one snapshot of the source database. Rows
+// must be closed before the next query is run.
+type Queryer interface {
+	Query(query string, args ...interface{}) (*sql.Rows, error)
+	QueryRow(query string, args ...interface{}) *sql.Row
+}
+
+// SnapshotPosition is the position of the source database at which the
+// data of a table was read. Changes made after it must be replayed (e.g.
+// by CDC) to bring the Spanner table up to date. The zero value means
+// the source has no position for the table.
+type SnapshotPosition struct {
+	// Kind is "lsn" (a PostgreSQL WAL location), "gtid" (a MySQL GTID
+	// set), "binlog" (a MySQL binary log file and position, as
+	// file:position) or "dynamodb-stream" (the ARN of the table's DynamoDB
+	// stream: changes must be replayed from Time).
+	Kind     string
+	Position string
+	Snapshot string `json:",omitempty"` // Snapshot the data was read from, if any.
+	// Exact is true if the data is exactly the state of the table at
+	// Position. Otherwise, it can also include changes made after
+	// Position, so replaying changes from Position must tolerate changes
+	// that are already applied (e.g. by writing rows with upserts).
+	Exact bool
+	Time  time.Time // When the position was taken.
+}
+
+// SetSnapshotSource sets the function that returns the current position
+// of the source database, used by RecordSnapshot.
+func (conv *Conv) SetSnapshotSource(f func(srcTable string) (SnapshotPosition, error)) {
+	conv.snapshotSource = f
+}
+
+// RecordSnapshot records the current position of the source database as
+// the position the data of srcTable is read at. Source packages call it
+// just before reading the data of each table. It does nothing if no
+// snapshot source is set (see SetSnapshotSource).
+func (conv *Conv) RecordSnapshot(srcTable string) {
+	if conv.snapshotSource == nil {
+		return
+	}
+	p, err := conv.snapshotSource(srcTable)
+	if err != nil {
+		conv.Unexpected(fmt.Sprintf("Can't get snapshot position for table %s: %s", srcTable, err))
+		return
+	}
+	if p.Kind == "" {
+		return
+	}
+	conv.schemaMu.Lock()
+	defer conv.schemaMu.Unlock()
+	if conv.Snapshots == nil {
+		conv.Snapshots = make(map[string]SnapshotPosition)
+	}
+	conv.Snapshots[srcTable] = p
+}
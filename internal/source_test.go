@@ -0,0 +1,55 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package internal
+
+import (
+	"encoding/json"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRecordSnapshot(t *testing.T) {
+	conv := MakeConv()
+	conv.RecordSnapshot("t1") // No snapshot source.
+	assert.Empty(t, conv.Snapshots)
+
+	now := time.Date(2021, 3, 4, 5, 6, 7, 0, time.UTC)
+	conv.SetSnapshotSource(func(srcTable string) (SnapshotPosition, error) {
+		switch srcTable {
+		case "t1":
+			return SnapshotPosition{Kind: "lsn", Position: "0/16B3748", Exact: true, Time: now}, nil
+		case "t2":
+			return SnapshotPosition{}, nil
+		}
+		return SnapshotPosition{}, fmt.Errorf("no position")
+	})
+	conv.RecordSnapshot("t1")
+	conv.RecordSnapshot("t2")
+	conv.RecordSnapshot("t3")
+	assert.Equal(t, map[string]SnapshotPosition{
+		"t1": {Kind: "lsn", Position: "0/16B3748", Exact: true, Time: now},
+	}, conv.Snapshots)
+	assert.Equal(t, int64(1), conv.Unexpecteds())
+
+	// Positions are saved in session files.
+	b, err := json.Marshal(conv)
+	assert.Nil(t, err)
+	conv2 := MakeConv()
+	assert.Nil(t, json.Unmarshal(b, conv2))
+	assert.Equal(t, conv.Snapshots, conv2.Snapshots)
+}
@@ -0,0 +1,298 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package internal
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"github.com/cloudspannerecosystem/harbourbridge/schema"
+	"github.com/cloudspannerecosystem/harbourbridge/spanner/ddl"
+)
+
+// spill keeps the schema of converted tables in files, one per source
+// table, for source databases with too many tables for their whole schema
+// to be held in memory (see SpillTo). Name mappings, statistics and
+// sequences stay in memory: they are much smaller than table schemas.
+type spill struct {
+	dir       string
+	tables    []string            // Spilled source tables, in the order they were first spilled.
+	files     map[string]string   // Maps spilled source table to its file.
+	cols      map[string][]string // Columns of spilled Spanner tables, used to resolve foreign keys.
+	indexes   map[string][]string // Index names of spilled Spanner tables, used to allocate names.
+	mutations map[string]int64    // Index mutations per row of spilled Spanner tables (see IndexMutations).
+}
+
+// spilledTable is the content of the file of a spilled table.
+type spilledTable struct {
+	Src    schema.Table
+	Sp     ddl.CreateTable
+	Issues map[string][]SchemaIssue
+}
+
+// SpillTo makes conv keep the schema of tables in files under dir once
+// they are converted (see SpillTable), so that the memory needed to
+// convert a source database doesn't grow with the size of its schema.
+// Spilled tables are loaded one at a time when they are used (see
+// ForEachSpilled), e.g. to write the schema file and the report, and to
+// convert their data. Changes to the schema that involve several tables,
+// such as splitting, merging and interleaving tables (see ApplyRemodel),
+// aren't supported for spilled tables.
+func (conv *Conv) SpillTo(dir string) error {
+	if err := os.MkdirAll(dir, os.ModePerm); err != nil {
+		return fmt.Errorf("can't create spill directory: %w", err)
+	}
+	conv.spill = &spill{
+		dir:       dir,
+		files:     make(map[string]string),
+		cols:      make(map[string][]string),
+		indexes:   make(map[string][]string),
+		mutations: make(map[string]int64),
+	}
+	return nil
+}
+
+// Spilling returns true if conv keeps the schema of tables in files.
+func (conv *Conv) Spilling() bool {
+	return conv.spill != nil
+}
+
+// SpillTable writes the source schema, Spanner schema and issues of
+// srcTable to its file, and removes them from conv.
+func (conv *Conv) SpillTable(srcTable string) error {
+	conv.schemaMu.Lock()
+	defer conv.schemaMu.Unlock()
+	s := conv.spill
+	spTable := conv.ToSpanner[srcTable].Name
+	st := spilledTable{Src: conv.SrcSchema[srcTable], Sp: conv.SpSchema[spTable], Issues: conv.Issues[srcTable]}
+	file, ok := s.files[srcTable]
+	if !ok {
+		file = filepath.Join(s.dir, fmt.Sprintf("table%06d.json", len(s.tables)))
+	}
+	b, err := json.Marshal(st)
+	if err != nil {
+		return fmt.Errorf("can't encode schema of table %s: %w", srcTable, err)
+	}
+	if err := ioutil.WriteFile(file, b, 0644); err != nil {
+		return fmt.Errorf("can't spill schema of table %s: %w", srcTable, err)
+	}
+	if !ok {
+		s.tables = append(s.tables, srcTable)
+		s.files[srcTable] = file
+	}
+	if _, ok := conv.SpSchema[spTable]; ok {
+		s.cols[spTable] = st.Sp.ColNames
+		s.indexes[spTable] = nil
+		for _, index := range st.Sp.Indexes {
+			s.indexes[spTable] = append(s.indexes[spTable], index.Name)
+		}
+		s.mutations[spTable] = conv.SpSchema.IndexMutations(spTable)
+		delete(conv.SpSchema, spTable)
+	}
+	delete(conv.SrcSchema, srcTable)
+	delete(conv.Issues, srcTable)
+	return nil
+}
+
+// LoadTable reads the schema of srcTable back into conv, if it is
+// spilled.
+func (conv *Conv) LoadTable(srcTable string) error {
+	if conv.spill == nil {
+		return nil
+	}
+	file, ok := conv.spill.files[srcTable]
+	if !ok {
+		return nil
+	}
+	b, err := ioutil.ReadFile(file)
+	if err != nil {
+		return fmt.Errorf("can't load schema of table %s: %w", srcTable, err)
+	}
+	var st spilledTable
+	if err := json.Unmarshal(b, &st); err != nil {
+		return fmt.Errorf("can't decode schema of table %s: %w", srcTable, err)
+	}
+	conv.schemaMu.Lock()
+	defer conv.schemaMu.Unlock()
+	conv.SrcSchema[srcTable] = st.Src
+	if st.Sp.Name != "" {
+		conv.SpSchema[st.Sp.Name] = st.Sp
+	}
+	if st.Issues != nil {
+		conv.Issues[srcTable] = st.Issues
+	}
+	return nil
+}
+
+// UnloadTable removes the schema of srcTable from conv, if it is spilled.
+// Changes made since it was loaded are lost (see SpillTable).
+func (conv *Conv) UnloadTable(srcTable string) {
+	if conv.spill == nil {
+		return
+	}
+	if _, ok := conv.spill.files[srcTable]; !ok {
+		return
+	}
+	conv.schemaMu.Lock()
+	defer conv.schemaMu.Unlock()
+	delete(conv.SpSchema, conv.ToSpanner[srcTable].Name)
+	delete(conv.SrcSchema, srcTable)
+	delete(conv.Issues, srcTable)
+}
+
+// SpilledTables returns the spilled source tables, ordered according to
+// conv.Ordering.
+func (conv *Conv) SpilledTables() []string {
+	if conv.spill == nil {
+		return nil
+	}
+	// Tables are spilled in the order they are read from the source.
+	l := append([]string{}, conv.spill.tables...)
+	if conv.Ordering != SourceOrder {
+		sort.Strings(l)
+	}
+	return l
+}
+
+// ForEachSpilled loads each spilled table in turn (in the order of
+// SpilledTables), calls f with its name, and unloads it. If update is
+// true, changes that f makes to the table are written back to its file.
+func (conv *Conv) ForEachSpilled(update bool, f func(srcTable string) error) error {
+	for _, t := range conv.SpilledTables() {
+		if err := conv.LoadTable(t); err != nil {
+			return err
+		}
+		err := f(t)
+		if err == nil && update {
+			err = conv.SpillTable(t)
+		} else {
+			conv.UnloadTable(t)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ResolveSpilledRefs resolves the foreign keys of spilled tables (see
+// ResolveRefs), once all tables are converted and spilled: foreign keys
+// can reference tables converted after theirs.
+func (conv *Conv) ResolveSpilledRefs() error {
+	return conv.ForEachSpilled(true, func(srcTable string) error {
+		resolveAllRefs(conv)
+		return nil
+	})
+}
+
+// IndexMutations returns the number of mutations needed to update the
+// secondary indexes of Spanner table spTable per row written (see
+// ddl.Schema.IndexMutations), whether spTable is spilled or not.
+func (conv *Conv) IndexMutations(spTable string) int64 {
+	if conv.spill != nil {
+		// Spilled tables are loaded and unloaded while rows are written:
+		// don't read conv.SpSchema concurrently.
+		return conv.spill.mutations[spTable]
+	}
+	return conv.SpSchema.IndexMutations(spTable)
+}
+
+// spannerCols returns the columns of Spanner table spTable, which may be
+// spilled.
+func (conv *Conv) spannerCols(spTable string) ([]string, bool) {
+	if ct, ok := conv.SpSchema[spTable]; ok {
+		return ct.ColNames, true
+	}
+	if conv.spill != nil {
+		cols, ok := conv.spill.cols[spTable]
+		return cols, ok
+	}
+	return nil, false
+}
+
+// WriteJSON writes conv to w in JSON format. If tables are spilled, their
+// schemas are written one at a time, so that they are never all in
+// memory.
+func (conv *Conv) WriteJSON(w io.Writer) error {
+	b, err := json.Marshal(conv)
+	if err != nil {
+		return err
+	}
+	if conv.spill == nil {
+		_, err = w.Write(b)
+		return err
+	}
+	// The schema maps are empty in b (all tables are spilled): write
+	// their entries inside the braces. They are the first fields of Conv
+	// to have these names, so the first match is the right one.
+	entries := []struct {
+		field string
+		entry func(srcTable string) (string, interface{}, bool)
+	}{
+		{"SpSchema", func(srcTable string) (string, interface{}, bool) {
+			t := conv.ToSpanner[srcTable].Name
+			ct, ok := conv.SpSchema[t]
+			return t, ct, ok
+		}},
+		{"SrcSchema", func(srcTable string) (string, interface{}, bool) {
+			st, ok := conv.SrcSchema[srcTable]
+			return srcTable, st, ok
+		}},
+		{"Issues", func(srcTable string) (string, interface{}, bool) {
+			issues, ok := conv.Issues[srcTable]
+			return srcTable, issues, ok
+		}},
+	}
+	for _, e := range entries {
+		empty := []byte(`"` + e.field + `":{}`)
+		i := bytes.Index(b, empty)
+		if i < 0 {
+			return fmt.Errorf("can't write spilled %s: tables are loaded", e.field)
+		}
+		if _, err := w.Write(b[:i+len(empty)-1]); err != nil {
+			return err
+		}
+		b = b[i+len(empty)-1:]
+		sep := ""
+		err := conv.ForEachSpilled(false, func(srcTable string) error {
+			key, value, ok := e.entry(srcTable)
+			if !ok {
+				return nil
+			}
+			k, err := json.Marshal(key)
+			if err != nil {
+				return err
+			}
+			v, err := json.Marshal(value)
+			if err != nil {
+				return err
+			}
+			_, err = fmt.Fprintf(w, "%s%s:%s", sep, k, v)
+			sep = ","
+			return err
+		})
+		if err != nil {
+			return err
+		}
+	}
+	_, err = w.Write(b)
+	return err
+}
@@ -0,0 +1,147 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package internal
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/cloudspannerecosystem/harbourbridge/spanner/ddl"
+	"github.com/stretchr/testify/assert"
+)
+
+func spillTestConv() *Conv {
+	conv := remodelTestConv()
+	users := conv.SpSchema["users"]
+	users.Indexes = []ddl.CreateIndex{{Name: "users_name", Table: "users", Keys: []ddl.IndexKey{{Col: "name"}}}}
+	conv.SpSchema["users"] = users
+	settings := conv.SpSchema["settings"]
+	// References are resolved case-insensitively.
+	settings.Fks = []ddl.Foreignkey{{Name: "fk", Columns: []string{"user_id"}, ReferTable: "USERS", ReferColumns: []string{"ID"}}}
+	conv.SpSchema["settings"] = settings
+	conv.Issues["users"] = map[string][]SchemaIssue{"bio": {Widened}}
+	return conv
+}
+
+func TestSpill(t *testing.T) {
+	dir, err := ioutil.TempDir("", "spill-test-")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+
+	want := spillTestConv()
+	ResolveRefs(want)
+	c := ddl.Config{Tables: true, ForeignKeys: true}
+
+	conv := spillTestConv()
+	assert.Nil(t, conv.SpillTo(dir))
+	assert.True(t, conv.Spilling())
+	// Foreign keys can reference tables spilled after theirs.
+	assert.Nil(t, conv.SpillTable("settings"))
+	ResolveRefs(conv)
+	assert.Nil(t, conv.SpillTable("users"))
+	assert.Empty(t, conv.SrcSchema)
+	assert.Empty(t, conv.SpSchema)
+	assert.Empty(t, conv.Issues)
+	assert.Nil(t, conv.ResolveSpilledRefs())
+
+	assert.Equal(t, []string{"settings", "users"}, conv.SpilledTables())
+	assert.Equal(t, want.GetDDL(c), conv.GetDDL(c))
+	assert.Equal(t, want.SpSchema.IndexMutations("users"), conv.IndexMutations("users"))
+
+	assert.Nil(t, conv.LoadTable("users"))
+	assert.Equal(t, want.SrcSchema["users"], conv.SrcSchema["users"])
+	assert.Equal(t, want.SpSchema["users"], conv.SpSchema["users"])
+	assert.Equal(t, want.Issues["users"], conv.Issues["users"])
+	conv.UnloadTable("users")
+	assert.Empty(t, conv.SpSchema)
+
+	var b bytes.Buffer
+	assert.Nil(t, conv.WriteJSON(&b))
+	got := MakeConv()
+	assert.Nil(t, json.Unmarshal(b.Bytes(), got))
+	assert.Equal(t, want.SpSchema, got.SpSchema)
+	assert.Equal(t, want.SrcSchema, got.SrcSchema)
+	assert.Equal(t, want.Issues, got.Issues)
+	assert.Equal(t, want.ToSpanner, got.ToSpanner)
+}
+
+func TestSpill_Errors(t *testing.T) {
+	dir, err := ioutil.TempDir("", "spill-test-")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+
+	conv := spillTestConv()
+	assert.Nil(t, conv.LoadTable("users"))
+	assert.Nil(t, conv.SpilledTables())
+	file := filepath.Join(dir, "file")
+	assert.Nil(t, ioutil.WriteFile(file, nil, 0644))
+	assert.NotNil(t, conv.SpillTo(filepath.Join(file, "spill")))
+	assert.False(t, conv.Spilling())
+
+	assert.Nil(t, conv.SpillTo(filepath.Join(dir, "spill")))
+	assert.Nil(t, conv.SpillTable("settings"))
+	assert.Nil(t, conv.SpillTable("users"))
+	// Tables that aren't spilled are left as is.
+	assert.Nil(t, conv.LoadTable("unknown"))
+	conv.UnloadTable("unknown")
+
+	// Tables spilled again keep their file.
+	assert.Nil(t, conv.LoadTable("users"))
+	assert.Nil(t, conv.SpillTable("users"))
+	files, err := ioutil.ReadDir(filepath.Join(dir, "spill"))
+	assert.Nil(t, err)
+	assert.Len(t, files, 2)
+
+	// Loaded tables can't be written as JSON.
+	assert.Nil(t, conv.LoadTable("users"))
+	assert.EqualError(t, conv.WriteJSON(ioutil.Discard), "can't write spilled SpSchema: tables are loaded")
+	conv.UnloadTable("users")
+
+	// Tables are unloaded when f fails, and changes are only kept on
+	// update.
+	var visited []string
+	err = conv.ForEachSpilled(true, func(srcTable string) error {
+		visited = append(visited, srcTable)
+		return fmt.Errorf("failed")
+	})
+	assert.EqualError(t, err, "failed")
+	assert.Equal(t, []string{"settings"}, visited)
+	assert.Empty(t, conv.SrcSchema)
+	assert.Empty(t, conv.SpSchema)
+	assert.Nil(t, conv.ForEachSpilled(false, func(srcTable string) error {
+		delete(conv.Issues, srcTable)
+		return nil
+	}))
+	assert.Nil(t, conv.LoadTable("users"))
+	assert.NotEmpty(t, conv.Issues["users"])
+	conv.UnloadTable("users")
+
+	// Files that can't be read back.
+	settings := conv.spill.files["settings"]
+	assert.Nil(t, ioutil.WriteFile(settings, []byte("{"), 0644))
+	err = conv.LoadTable("settings")
+	assert.Contains(t, err.Error(), "can't decode schema of table settings")
+	err = conv.ForEachSpilled(false, func(srcTable string) error { return nil })
+	assert.Contains(t, err.Error(), "can't decode schema of table settings")
+	assert.Nil(t, os.Remove(settings))
+	err = conv.LoadTable("settings")
+	assert.Contains(t, err.Error(), "can't load schema of table settings")
+	assert.NotNil(t, conv.WriteJSON(ioutil.Discard))
+}
@@ -0,0 +1,189 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package internal
+
+import (
+	"fmt"
+	"math"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/cloudspannerecosystem/harbourbridge/spanner/ddl"
+)
+
+// maxStringLength is the largest length of Spanner STRING columns (in
+// characters). Columns needing more are left as STRING(MAX).
+const maxStringLength = 2621440
+
+// UnboundedStringCols returns the source columns of srcTable that are
+// converted to STRING(MAX) Spanner columns, and whose length can be
+// tightened by TightenStrings.
+func (conv *Conv) UnboundedStringCols(srcTable string) []string {
+	var l []string
+	spTable := conv.ToSpanner[srcTable].Name
+	for _, c := range conv.SrcSchema[srcTable].ColNames {
+		if spCol, ok := conv.ToSpanner[srcTable].Cols[c]; ok && conv.isUnboundedString(spTable, spCol) {
+			l = append(l, c)
+		}
+	}
+	return l
+}
+
+func (conv *Conv) isUnboundedString(spTable, spCol string) bool {
+	ty := conv.SpSchema[spTable].ColDefs[spCol].T
+	return ty.Name == ddl.String && ty.Len == ddl.MaxLength && !ty.IsArray && conv.canChangeColType(spTable, spCol)
+}
+
+// TightenStrings gives the STRING(MAX) Spanner columns converted from the
+// source columns of srcTable in maxLens (the length, in characters, of
+// their longest value) a length of factor times that of their longest
+// value, and records the longest values in conv.StringLens. Columns with
+// no values, and columns that would need more than Spanner's largest
+// length, are left as STRING(MAX). factor is a safety margin for values
+// longer than the ones seen so far: values longer than the new length are
+// rejected by Spanner during data conversion.
+func (conv *Conv) TightenStrings(srcTable string, maxLens map[string]int64, factor float64) {
+	spTable := conv.ToSpanner[srcTable].Name
+	ct, ok := conv.SpSchema[spTable]
+	if !ok {
+		return
+	}
+	for srcCol, n := range maxLens {
+		spCol, ok := conv.ToSpanner[srcTable].Cols[srcCol]
+		if !ok || n <= 0 || !conv.isUnboundedString(spTable, spCol) {
+			continue
+		}
+		l := int64(math.Ceil(float64(n) * factor))
+		if l > maxStringLength {
+			continue
+		}
+		cd := ct.ColDefs[spCol]
+		cd.T.Len = l
+		ct.ColDefs[spCol] = cd
+		if conv.StringLens == nil {
+			conv.StringLens = make(map[string]int64)
+		}
+		conv.StringLens[spTable+"."+spCol] = n
+	}
+}
+
+// Values of the -long-strings option, which widens the STRING(n) columns
+// that are too short for the source data (see FitStrings).
+const (
+	// InflateLongStrings doubles the length of a column until its longest
+	// value fits.
+	InflateLongStrings = "inflate"
+	// MaxLongStrings converts the column to STRING(MAX).
+	MaxLongStrings = "max"
+)
+
+// WidenedString describes a STRING(n) column widened by FitStrings.
+type WidenedString struct {
+	Len     int64 // Length of the column before it was widened.
+	Longest int64 // Length (in characters) of the longest source value.
+}
+
+// ParseLongStrings checks that s is a value of the -long-strings option.
+func ParseLongStrings(s string) (string, error) {
+	switch strings.ToLower(s) {
+	case InflateLongStrings, MaxLongStrings:
+		return strings.ToLower(s), nil
+	}
+	return "", fmt.Errorf("unknown value %q for long strings (accepted values are %q and %q)", s, InflateLongStrings, MaxLongStrings)
+}
+
+// BoundedStringCols returns the source columns of srcTable that are
+// converted to STRING(n) Spanner columns, and whose length can be changed
+// by FitStrings.
+func (conv *Conv) BoundedStringCols(srcTable string) []string {
+	var l []string
+	spTable := conv.ToSpanner[srcTable].Name
+	for _, c := range conv.SrcSchema[srcTable].ColNames {
+		spCol, ok := conv.ToSpanner[srcTable].Cols[c]
+		if !ok || !conv.canChangeColType(spTable, spCol) {
+			continue
+		}
+		if ty := conv.SpSchema[spTable].ColDefs[spCol].T; ty.Name == ddl.String && ty.Len != ddl.MaxLength && !ty.IsArray {
+			l = append(l, c)
+		}
+	}
+	return l
+}
+
+// FitStrings widens the STRING(n) Spanner columns converted from the
+// source columns of srcTable in maxLens (the length, in characters, of
+// their longest value) whose longest value doesn't fit, and records them
+// in conv.WidenedStrs. Source databases count the length of strings in
+// the characters of the column's character set, while Spanner counts
+// Unicode characters, so a value that fits the source column can be too
+// long for the Spanner column. mode is InflateLongStrings or
+// MaxLongStrings. Columns that would need more than Spanner's largest
+// length are converted to STRING(MAX).
+func (conv *Conv) FitStrings(srcTable string, maxLens map[string]int64, mode string) {
+	spTable := conv.ToSpanner[srcTable].Name
+	ct, ok := conv.SpSchema[spTable]
+	if !ok {
+		return
+	}
+	for srcCol, n := range maxLens {
+		spCol, ok := conv.ToSpanner[srcTable].Cols[srcCol]
+		if !ok {
+			continue
+		}
+		cd, ok := ct.ColDefs[spCol]
+		if !ok || cd.T.Name != ddl.String || cd.T.Len == ddl.MaxLength || cd.T.IsArray || n <= cd.T.Len || cd.T.Len <= 0 {
+			continue
+		}
+		old := cd.T.Len
+		l := int64(ddl.MaxLength)
+		if mode == InflateLongStrings {
+			for l = old; l < n; l *= 2 {
+			}
+			if l > maxStringLength {
+				l = ddl.MaxLength
+			}
+		}
+		cd.T.Len = l
+		ct.ColDefs[spCol] = cd
+		if conv.WidenedStrs == nil {
+			conv.WidenedStrs = make(map[string]WidenedString)
+		}
+		conv.WidenedStrs[spTable+"."+spCol] = WidenedString{Len: old, Longest: n}
+	}
+}
+
+// checkStringLengths checks the string values in spVals against the
+// length of their STRING(n) column, and returns false if any are too long
+// (Spanner would reject the row, failing the entire batch being written).
+// Lengths are counted in Unicode characters, as by Spanner.
+func (conv *Conv) checkStringLengths(srcTable, spTable string, spCols []string, spVals []interface{}) bool {
+	ok := true
+	for i, v := range spVals {
+		s, isString := v.(string)
+		if !isString {
+			continue
+		}
+		ty := conv.SpSchema[spTable].ColDefs[spCols[i]].T
+		if ty.Name != ddl.String || ty.Len == ddl.MaxLength || ty.IsArray || int64(len(s)) <= ty.Len {
+			// A string has at most as many characters as bytes.
+			continue
+		}
+		if n := int64(utf8.RuneCountInString(s)); n > ty.Len {
+			conv.statsAddLongString(srcTable, spCols[i], n)
+			ok = false
+		}
+	}
+	return ok
+}
@@ -0,0 +1,142 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package internal
+
+import (
+	"testing"
+
+	"github.com/cloudspannerecosystem/harbourbridge/schema"
+	"github.com/cloudspannerecosystem/harbourbridge/spanner/ddl"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTightenStrings(t *testing.T) {
+	conv := MakeConv()
+	text := schema.Type{Name: "text"}
+	conv.SrcSchema["users"] = schema.Table{
+		Name:     "users",
+		ColNames: []string{"id", "name", "bio", "notes", "code"},
+		ColDefs: map[string]schema.Column{
+			"id":    schema.Column{Name: "id", Type: text},
+			"name":  schema.Column{Name: "name", Type: text},
+			"bio":   schema.Column{Name: "bio", Type: text},
+			"notes": schema.Column{Name: "notes", Type: text},
+			"code":  schema.Column{Name: "code", Type: schema.Type{Name: "varchar", Mods: []int64{10}}},
+		},
+	}
+	str := ddl.Type{Name: ddl.String, Len: ddl.MaxLength}
+	conv.SpSchema["users"] = ddl.CreateTable{
+		Name:     "users",
+		ColNames: []string{"id", "name", "bio", "notes", "code"},
+		ColDefs: map[string]ddl.ColumnDef{
+			"id":    ddl.ColumnDef{Name: "id", T: str},
+			"name":  ddl.ColumnDef{Name: "name", T: str},
+			"bio":   ddl.ColumnDef{Name: "bio", T: str},
+			"notes": ddl.ColumnDef{Name: "notes", T: str},
+			"code":  ddl.ColumnDef{Name: "code", T: ddl.Type{Name: ddl.String, Len: 10}},
+		},
+		Pks: []ddl.IndexKey{ddl.IndexKey{Col: "id"}},
+	}
+	conv.ToSpanner["users"] = NameAndCols{Name: "users", Cols: map[string]string{"id": "id", "name": "name", "bio": "bio", "notes": "notes", "code": "code"}}
+	// id is a key column, and code already has a length.
+	assert.Equal(t, []string{"name", "bio", "notes"}, conv.UnboundedStringCols("users"))
+
+	// notes has no values, and bio's longest value is too long for a length.
+	conv.TightenStrings("users", map[string]int64{"name": 41, "bio": 2000000}, 1.5)
+	ct := conv.SpSchema["users"]
+	assert.Equal(t, ddl.Type{Name: ddl.String, Len: 62}, ct.ColDefs["name"].T)
+	assert.Equal(t, str, ct.ColDefs["bio"].T)
+	assert.Equal(t, str, ct.ColDefs["notes"].T)
+	assert.Equal(t, map[string]int64{"users.name": 41}, conv.StringLens)
+}
+
+func TestFitStrings(t *testing.T) {
+	for _, tc := range []struct {
+		mode string
+		want map[string]ddl.Type
+	}{
+		{InflateLongStrings, map[string]ddl.Type{
+			"name":  {Name: ddl.String, Len: 40},
+			"title": {Name: ddl.String, Len: 10},
+			"bio":   {Name: ddl.String, Len: ddl.MaxLength},
+		}},
+		{MaxLongStrings, map[string]ddl.Type{
+			"name":  {Name: ddl.String, Len: ddl.MaxLength},
+			"title": {Name: ddl.String, Len: 10},
+			"bio":   {Name: ddl.String, Len: ddl.MaxLength},
+		}},
+	} {
+		conv := MakeConv()
+		conv.SrcSchema["users"] = schema.Table{
+			Name:     "users",
+			ColNames: []string{"id", "name", "title", "bio"},
+			ColDefs: map[string]schema.Column{
+				"id":    schema.Column{Name: "id", Type: schema.Type{Name: "varchar", Mods: []int64{10}}},
+				"name":  schema.Column{Name: "name", Type: schema.Type{Name: "varchar", Mods: []int64{10}}},
+				"title": schema.Column{Name: "title", Type: schema.Type{Name: "varchar", Mods: []int64{10}}},
+				"bio":   schema.Column{Name: "bio", Type: schema.Type{Name: "varchar", Mods: []int64{2000000}}},
+			},
+		}
+		conv.SpSchema["users"] = ddl.CreateTable{
+			Name:     "users",
+			ColNames: []string{"id", "name", "title", "bio"},
+			ColDefs: map[string]ddl.ColumnDef{
+				"id":    ddl.ColumnDef{Name: "id", T: ddl.Type{Name: ddl.String, Len: 10}},
+				"name":  ddl.ColumnDef{Name: "name", T: ddl.Type{Name: ddl.String, Len: 10}},
+				"title": ddl.ColumnDef{Name: "title", T: ddl.Type{Name: ddl.String, Len: 10}},
+				"bio":   ddl.ColumnDef{Name: "bio", T: ddl.Type{Name: ddl.String, Len: 2000000}},
+			},
+			Pks: []ddl.IndexKey{ddl.IndexKey{Col: "id"}},
+		}
+		conv.ToSpanner["users"] = NameAndCols{Name: "users", Cols: map[string]string{"id": "id", "name": "name", "title": "title", "bio": "bio"}}
+		// id is a key column.
+		assert.Equal(t, []string{"name", "title", "bio"}, conv.BoundedStringCols("users"), tc.mode)
+
+		// title's longest value fits, and bio would need more than
+		// Spanner's largest length.
+		conv.FitStrings("users", map[string]int64{"name": 35, "title": 10, "bio": 2000001}, tc.mode)
+		for c, ty := range tc.want {
+			assert.Equal(t, ty, conv.SpSchema["users"].ColDefs[c].T, tc.mode+": "+c)
+		}
+		assert.Equal(t, map[string]WidenedString{"users.name": {Len: 10, Longest: 35}, "users.bio": {Len: 2000000, Longest: 2000001}}, conv.WidenedStrs, tc.mode)
+	}
+}
+
+func TestCheckStringLengths(t *testing.T) {
+	conv := MakeConv()
+	conv.SpSchema["t"] = ddl.CreateTable{
+		Name:     "t",
+		ColNames: []string{"id", "name", "bio"},
+		ColDefs: map[string]ddl.ColumnDef{
+			"id":   ddl.ColumnDef{Name: "id", T: ddl.Type{Name: ddl.Int64}},
+			"name": ddl.ColumnDef{Name: "name", T: ddl.Type{Name: ddl.String, Len: 4}},
+			"bio":  ddl.ColumnDef{Name: "bio", T: ddl.Type{Name: ddl.String, Len: ddl.MaxLength}},
+		},
+		Pks: []ddl.IndexKey{ddl.IndexKey{Col: "id"}},
+	}
+	conv.SetDataMode()
+	var rows int
+	conv.SetDataSink(func(table string, cols []string, vals []interface{}) { rows++ })
+	cols := []string{"id", "name", "bio"}
+	// Lengths are counted in characters: "😀😀😀😀" has 4 characters and
+	// 16 bytes.
+	conv.WriteRow("src", "t", cols, []interface{}{int64(1), "😀😀😀😀", "long long long"})
+	conv.WriteRow("src", "t", cols, []interface{}{int64(2), "😀😀😀😀😀", ""})
+	conv.WriteRow("src", "t", cols, []interface{}{int64(3), "abcdef", ""})
+	assert.Equal(t, 1, rows)
+	assert.Equal(t, int64(2), conv.Stats.BadRows["src"])
+	assert.Equal(t, map[string]int64{"name": 2}, conv.Stats.LongStrings["src"])
+	assert.Equal(t, map[string]int64{"name": 6}, conv.Stats.LongestStrings["src"])
+}
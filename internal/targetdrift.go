@@ -0,0 +1,120 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package internal
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/cloudspannerecosystem/harbourbridge/spanner/ddl"
+)
+
+// TargetDrift compares the Spanner schema of conv with target, the schema
+// of the Spanner database it was (or will be) created in, e.g. as read
+// from its INFORMATION_SCHEMA. It returns the differences that make
+// writing the rows of conv fail or go to the wrong place (mismatches),
+// and those that don't (notes), as sentences sorted by table. Foreign keys
+// that don't exist are notes, since they are added after data conversion,
+// as are tables, indexes and nullable columns that only exist in target.
+func (conv *Conv) TargetDrift(target ddl.Schema) (mismatches, notes []string) {
+	var names []string
+	for name := range conv.SpSchema {
+		names = append(names, name)
+	}
+	for name := range target {
+		if _, ok := conv.SpSchema[name]; !ok {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		want, inConv := conv.SpSchema[name]
+		got, inTarget := target[name]
+		switch {
+		case !inTarget:
+			mismatches = append(mismatches, fmt.Sprintf("Table %s doesn't exist", name))
+		case !inConv:
+			notes = append(notes, fmt.Sprintf("Table %s isn't in the session file", name))
+		default:
+			m, n := targetTableDrift(want, got)
+			mismatches = append(mismatches, m...)
+			notes = append(notes, n...)
+		}
+	}
+	return mismatches, notes
+}
+
+// targetTableDrift returns the differences between table want of the
+// session file and table got of the database (see TargetDrift).
+func targetTableDrift(want, got ddl.CreateTable) (mismatches, notes []string) {
+	w, g := spannerStructure(want), spannerStructure(got)
+	var parts []string
+	for p := range w {
+		parts = append(parts, p)
+	}
+	for p := range g {
+		if _, ok := w[p]; !ok {
+			parts = append(parts, p)
+		}
+	}
+	sort.Strings(parts)
+	for _, p := range parts {
+		was, inWant := w[p]
+		is, inGot := g[p]
+		switch {
+		case !inGot && strings.HasPrefix(p, "foreign key "):
+			notes = append(notes, fmt.Sprintf("Table %s: %s doesn't exist yet", want.Name, p))
+		case !inGot:
+			mismatches = append(mismatches, fmt.Sprintf("Table %s: %s doesn't exist (expected %s)", want.Name, p, was))
+		case !inWant && strings.HasPrefix(p, "column ") && got.ColDefs[strings.TrimPrefix(p, "column ")].NotNull:
+			mismatches = append(mismatches, fmt.Sprintf("Table %s: %s isn't in the session file, and rows written without it are rejected (%s)", want.Name, p, is))
+		case !inWant:
+			notes = append(notes, fmt.Sprintf("Table %s: %s isn't in the session file (%s)", want.Name, p, is))
+		case was != is:
+			mismatches = append(mismatches, fmt.Sprintf("Table %s: %s is %s, expected %s", want.Name, p, is, was))
+		}
+	}
+	return mismatches, notes
+}
+
+// spannerStructure describes the parts of Spanner table ct (e.g. "column
+// a"), by part.
+func spannerStructure(ct ddl.CreateTable) map[string]string {
+	m := make(map[string]string)
+	for _, cd := range ct.ColDefs {
+		s := cd.T.PrintColumnDefType()
+		if cd.NotNull {
+			s += " NOT NULL"
+		}
+		m["column "+cd.Name] = s
+	}
+	m["primary key"] = describeKey(ct.Pks)
+	m["parent table"] = "none"
+	if ct.Parent != "" {
+		m["parent table"] = ct.Parent
+	}
+	for _, fk := range ct.Fks {
+		m["foreign key "+fk.Name] = fmt.Sprintf("(%s) REFERENCES %s (%s)", strings.Join(fk.Columns, ", "), fk.ReferTable, strings.Join(fk.ReferColumns, ", "))
+	}
+	for _, index := range ct.Indexes {
+		s := describeKey(index.Keys)
+		if index.Unique {
+			s = "UNIQUE " + s
+		}
+		m["index "+index.Name] = s
+	}
+	return m
+}
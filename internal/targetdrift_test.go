@@ -0,0 +1,79 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package internal
+
+import (
+	"testing"
+
+	"github.com/cloudspannerecosystem/harbourbridge/spanner/ddl"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTargetDrift(t *testing.T) {
+	orders := func() ddl.CreateTable {
+		return ddl.CreateTable{
+			Name:     "orders",
+			ColNames: []string{"id", "customer", "total"},
+			ColDefs: map[string]ddl.ColumnDef{
+				"id":       {Name: "id", T: ddl.Type{Name: ddl.Int64}, NotNull: true},
+				"customer": {Name: "customer", T: ddl.Type{Name: ddl.String, Len: 50}},
+				"total":    {Name: "total", T: ddl.Type{Name: ddl.Numeric}},
+			},
+			Pks:     []ddl.IndexKey{{Col: "id"}},
+			Fks:     []ddl.Foreignkey{{Name: "orders_customer_fk", Columns: []string{"customer"}, ReferTable: "customers", ReferColumns: []string{"id"}}},
+			Indexes: []ddl.CreateIndex{{Name: "orders_customer_idx", Table: "orders", Keys: []ddl.IndexKey{{Col: "customer"}}}},
+		}
+	}
+	conv := MakeConv()
+	conv.SpSchema["orders"] = orders()
+	conv.SpSchema["items"] = ddl.CreateTable{
+		Name:     "items",
+		ColNames: []string{"id"},
+		ColDefs:  map[string]ddl.ColumnDef{"id": {Name: "id", T: ddl.Type{Name: ddl.Int64}, NotNull: true}},
+		Pks:      []ddl.IndexKey{{Col: "id"}},
+	}
+	target := ddl.NewSchema()
+	target["orders"] = orders()
+	target["items"] = conv.SpSchema["items"]
+	mismatches, notes := conv.TargetDrift(target)
+	assert.Empty(t, mismatches)
+	assert.Empty(t, notes)
+
+	ct := orders()
+	ct.ColDefs["customer"] = ddl.ColumnDef{Name: "customer", T: ddl.Type{Name: ddl.String, Len: 20}}
+	delete(ct.ColDefs, "total")
+	ct.ColDefs["status"] = ddl.ColumnDef{Name: "status", T: ddl.Type{Name: ddl.String, Len: 10}, NotNull: true}
+	ct.ColDefs["note"] = ddl.ColumnDef{Name: "note", T: ddl.Type{Name: ddl.String, Len: ddl.MaxLength}}
+	ct.Fks = nil
+	ct.Indexes = nil
+	ct.Parent = "customers"
+	target["orders"] = ct
+	delete(target, "items")
+	target["audit"] = ddl.CreateTable{Name: "audit"}
+	mismatches, notes = conv.TargetDrift(target)
+	assert.Equal(t, []string{
+		"Table items doesn't exist",
+		"Table orders: column customer is STRING(20), expected STRING(50)",
+		"Table orders: column status isn't in the session file, and rows written without it are rejected (STRING(10) NOT NULL)",
+		"Table orders: column total doesn't exist (expected NUMERIC)",
+		"Table orders: index orders_customer_idx doesn't exist (expected (customer))",
+		"Table orders: parent table is customers, expected none",
+	}, mismatches)
+	assert.Equal(t, []string{
+		"Table audit isn't in the session file",
+		"Table orders: column note isn't in the session file (STRING(MAX))",
+		"Table orders: foreign key orders_customer_fk doesn't exist yet",
+	}, notes)
+}
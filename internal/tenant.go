@@ -0,0 +1,125 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package internal
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/cloudspannerecosystem/harbourbridge/schema"
+)
+
+// Tenant selects the rows of a single tenant of a multi-tenant source
+// database: the rows whose Column is Value (compared as text, as the
+// source database formats it), and the rows of tables without Column
+// that reference such rows, directly or through other tables, by foreign
+// keys (see TenantPath).
+type Tenant struct {
+	Column string
+	Value  string
+}
+
+// ParseTenant parses a tenant specification: column=value.
+func ParseTenant(s string) (Tenant, error) {
+	i := strings.Index(s, "=")
+	if i < 0 {
+		return Tenant{}, fmt.Errorf("invalid tenant %q: expected column=value", s)
+	}
+	t := Tenant{Column: strings.TrimSpace(s[:i]), Value: strings.TrimSpace(s[i+1:])}
+	if t.Column == "" || t.Value == "" {
+		return Tenant{}, fmt.Errorf("invalid tenant %q: expected column=value", s)
+	}
+	return t, nil
+}
+
+func (t Tenant) String() string {
+	return fmt.Sprintf("%s = %s", t.Column, t.Value)
+}
+
+// SetTenant configures conv to only convert the rows of tenant t. Source
+// packages filter the rows of each table when they read them (see
+// TenantPath). It returns an error if no source table has the tenant
+// column.
+func (conv *Conv) SetTenant(t Tenant) error {
+	for _, srcSchema := range conv.SrcSchema {
+		if _, ok := srcSchema.ColDefs[t.Column]; ok {
+			conv.tenant = t
+			return nil
+		}
+	}
+	return fmt.Errorf("can't extract tenant: no source table has column %s", t.Column)
+}
+
+// TenantPath returns the tenant whose rows are converted, the foreign keys
+// to follow from srcTable to reach a table with the tenant column (none if
+// srcTable has it), and true if the rows of srcTable are filtered by
+// tenant. Of the shortest paths, the one following the first foreign keys
+// (in the order of the schema) is used. Tables with no such path (e.g.
+// tables of reference data shared by all tenants) aren't filtered.
+func (conv *Conv) TenantPath(srcTable string) (Tenant, []schema.ForeignKey, bool) {
+	t := conv.tenant
+	if t.Column == "" {
+		return t, nil, false
+	}
+	type step struct {
+		table string
+		path  []schema.ForeignKey
+	}
+	seen := map[string]bool{srcTable: true}
+	queue := []step{{table: srcTable}}
+	for len(queue) > 0 {
+		s := queue[0]
+		queue = queue[1:]
+		srcSchema, ok := conv.SrcSchema[s.table]
+		if !ok {
+			continue
+		}
+		if _, ok := srcSchema.ColDefs[t.Column]; ok {
+			return t, s.path, true
+		}
+		for _, fk := range srcSchema.ForeignKeys {
+			if seen[fk.ReferTable] || len(fk.Columns) != len(fk.ReferColumns) {
+				continue
+			}
+			seen[fk.ReferTable] = true
+			path := append(append([]schema.ForeignKey{}, s.path...), fk)
+			queue = append(queue, step{table: fk.ReferTable, path: path})
+		}
+	}
+	return t, nil, false
+}
+
+// buildTenantBody reports how the rows of srcTable were filtered by
+// tenant (see SetTenant).
+func buildTenantBody(conv *Conv, srcTable string) []tableReportBody {
+	if conv.tenant.Column == "" {
+		return nil
+	}
+	t, path, ok := conv.TenantPath(srcTable)
+	var line string
+	switch {
+	case !ok:
+		line = fmt.Sprintf("No table with column %s is reachable by foreign keys: all rows were migrated, as data shared by all tenants", t.Column)
+	case len(path) == 0:
+		line = fmt.Sprintf("Only the rows with %s were migrated", t)
+	default:
+		var l []string
+		for _, fk := range path {
+			l = append(l, fmt.Sprintf("'%s' (to table %s)", fk.Name, fk.ReferTable))
+		}
+		line = fmt.Sprintf("Only the rows of tenant %s were migrated, found by following foreign keys %s", t, strings.Join(l, ", "))
+	}
+	return []tableReportBody{{Heading: "Tenant", Lines: []string{line}}}
+}
@@ -0,0 +1,70 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package internal
+
+import (
+	"testing"
+
+	"github.com/cloudspannerecosystem/harbourbridge/schema"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseTenant(t *testing.T) {
+	tenant, err := ParseTenant("tenant_id = 42")
+	assert.Nil(t, err)
+	assert.Equal(t, Tenant{Column: "tenant_id", Value: "42"}, tenant)
+	for _, s := range []string{"", "tenant_id", "tenant_id=", "=42"} {
+		_, err := ParseTenant(s)
+		assert.NotNil(t, err, s)
+	}
+}
+
+func TestTenantPath(t *testing.T) {
+	conv := MakeConv()
+	col := func(names ...string) map[string]schema.Column {
+		m := make(map[string]schema.Column)
+		for _, n := range names {
+			m[n] = schema.Column{Name: n}
+		}
+		return m
+	}
+	conv.SrcSchema["accounts"] = schema.Table{Name: "accounts", ColDefs: col("id", "tenant_id")}
+	conv.SrcSchema["orders"] = schema.Table{Name: "orders", ColDefs: col("id", "account_id"), ForeignKeys: []schema.ForeignKey{
+		{Name: "fk_account", Columns: []string{"account_id"}, ReferTable: "accounts", ReferColumns: []string{"id"}},
+	}}
+	conv.SrcSchema["items"] = schema.Table{Name: "items", ColDefs: col("order_id", "country"), ForeignKeys: []schema.ForeignKey{
+		{Name: "fk_country", Columns: []string{"country"}, ReferTable: "countries", ReferColumns: []string{"code"}},
+		{Name: "fk_order", Columns: []string{"order_id"}, ReferTable: "orders", ReferColumns: []string{"id"}},
+	}}
+	conv.SrcSchema["countries"] = schema.Table{Name: "countries", ColDefs: col("code")}
+
+	_, _, ok := conv.TenantPath("orders")
+	assert.False(t, ok)
+	assert.NotNil(t, conv.SetTenant(Tenant{Column: "org_id", Value: "1"}))
+	assert.Nil(t, conv.SetTenant(Tenant{Column: "tenant_id", Value: "42"}))
+
+	tenant, path, ok := conv.TenantPath("accounts")
+	assert.True(t, ok)
+	assert.Equal(t, Tenant{Column: "tenant_id", Value: "42"}, tenant)
+	assert.Empty(t, path)
+	_, path, ok = conv.TenantPath("items")
+	assert.True(t, ok)
+	assert.Equal(t, []string{"fk_order", "fk_account"}, []string{path[0].Name, path[1].Name})
+	_, _, ok = conv.TenantPath("countries")
+	assert.False(t, ok)
+
+	assert.Equal(t, []string{"Only the rows of tenant tenant_id = 42 were migrated, found by following foreign keys 'fk_account' (to table accounts)"}, buildTenantBody(conv, "orders")[0].Lines)
+	assert.Equal(t, []string{"No table with column tenant_id is reachable by foreign keys: all rows were migrated, as data shared by all tenants"}, buildTenantBody(conv, "countries")[0].Lines)
+}
@@ -0,0 +1,129 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package internal
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/cloudspannerecosystem/harbourbridge/schema"
+)
+
+// Patterns of trigger code. They are deliberately loose: they are only used
+// to suggest alternatives in the report.
+var (
+	// e.g. 'NEW.updated_at := now()' (PostgreSQL) or
+	// 'SET NEW.updated_at = CURRENT_TIMESTAMP' (MySQL).
+	timestampTriggerRegexp = regexp.MustCompile(`(?i)\bNEW\s*\.\s*"?(\w+)"?\s*:?=\s*(now\s*\(\s*\)|current_timestamp|localtimestamp|clock_timestamp\s*\(\s*\)|statement_timestamp\s*\(\s*\)|transaction_timestamp\s*\(\s*\)|utc_timestamp\s*\(\s*\)|sysdate\s*\(\s*\))`)
+	// e.g. 'INSERT INTO audit_log ...'.
+	auditTriggerRegexp = regexp.MustCompile("(?i)\\bINSERT\\s+INTO\\s+([\\w.\"`]+)")
+)
+
+// AddSrcTrigger records a source trigger.
+func (conv *Conv) AddSrcTrigger(t schema.Trigger) {
+	conv.schemaMu.Lock()
+	defer conv.schemaMu.Unlock()
+	if conv.SrcTriggers == nil {
+		conv.SrcTriggers = make(map[string][]schema.Trigger)
+	}
+	conv.SrcTriggers[t.Table] = append(conv.SrcTriggers[t.Table], t)
+}
+
+// SetSrcFunction records the body of a source function, so that triggers
+// that call it can be analyzed.
+func (conv *Conv) SetSrcFunction(name, body string) {
+	conv.schemaMu.Lock()
+	defer conv.schemaMu.Unlock()
+	if conv.SrcFunctions == nil {
+		conv.SrcFunctions = make(map[string]string)
+	}
+	conv.SrcFunctions[name] = body
+}
+
+// triggerReport describes the triggers of the source database, with a
+// Spanner alternative for each one. Spanner has no triggers, but the most
+// common uses of triggers have alternatives: commit timestamps for
+// columns that record when a row was last changed, and change streams for
+// audit tables.
+func triggerReport(conv *Conv) []string {
+	var tables []string
+	for t := range conv.SrcTriggers {
+		tables = append(tables, t)
+	}
+	sort.Strings(tables)
+	var l []string
+	for _, t := range tables {
+		for _, tr := range conv.SrcTriggers[t] {
+			l = append(l, fmt.Sprintf("Trigger '%s' (%s on table '%s') %s", tr.Name, tr.Timing, t, triggerSuggestion(conv, tr)))
+		}
+	}
+	return l
+}
+
+func triggerSuggestion(conv *Conv, t schema.Trigger) string {
+	var l []string
+	var cols []string
+	for _, m := range timestampTriggerRegexp.FindAllStringSubmatch(t.Body, -1) {
+		if !containsString(cols, m[1]) {
+			cols = append(cols, m[1])
+		}
+	}
+	if len(cols) > 0 {
+		l = append(l, fmt.Sprintf("sets %s to the current time: make %s with OPTIONS (allow_commit_timestamp = true), and write PENDING_COMMIT_TIMESTAMP() (spanner.CommitTimestamp in the Go client) to %s from the application", quoteList(cols), plural(len(cols), "it a TIMESTAMP column", "them TIMESTAMP columns"), plural(len(cols), "it", "them")))
+	}
+	var tables []string
+	for _, m := range auditTriggerRegexp.FindAllStringSubmatch(t.Body, -1) {
+		if name := strings.Trim(m[1], "\"`"); !containsString(tables, name) {
+			tables = append(tables, name)
+		}
+	}
+	if len(tables) > 0 {
+		spTable := t.Table
+		if sp, ok := conv.ToSpanner[t.Table]; ok {
+			spTable = sp.Name
+		}
+		l = append(l, fmt.Sprintf("copies changes to %s: create a change stream on table '%s' (CREATE CHANGE STREAM ... FOR %s), and fill the audit %s from the change stream (e.g. with Dataflow), or write %s in the same transaction as the changes", quoteList(tables), spTable, spTable, plural(len(tables), "table", "tables"), plural(len(tables), "it", "them")))
+	}
+	if len(l) == 0 {
+		return "was not migrated: Spanner has no triggers, so its logic must move to the application"
+	}
+	return strings.Join(l, ". It ")
+}
+
+func quoteList(l []string) string {
+	var q []string
+	for _, s := range l {
+		q = append(q, "'"+s+"'")
+	}
+	return strings.Join(q, ", ")
+}
+
+func plural(n int, one, many string) string {
+	if n == 1 {
+		return one
+	}
+	return many
+}
+
+func containsString(l []string, s string) bool {
+	for _, x := range l {
+		if x == s {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,36 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package internal
+
+import (
+	"testing"
+
+	"github.com/cloudspannerecosystem/harbourbridge/schema"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTriggerReport(t *testing.T) {
+	conv := MakeConv()
+	conv.AddSrcTrigger(schema.Trigger{Name: "touch", Table: "users", Timing: "BEFORE UPDATE", Body: "BEGIN\n  NEW.updated_at := now();\n  RETURN NEW;\nEND;"})
+	conv.AddSrcTrigger(schema.Trigger{Name: "audit", Table: "users", Timing: "AFTER INSERT OR UPDATE", Body: "BEGIN INSERT INTO \"audit_log\" SELECT TG_OP, NEW.*; RETURN NEW; END;"})
+	conv.AddSrcTrigger(schema.Trigger{Name: "both", Table: "orders", Timing: "BEFORE INSERT", Body: "BEGIN SET NEW.created = CURRENT_TIMESTAMP; SET NEW.modified = NOW(); INSERT INTO orders_log VALUES (NEW.id); END"})
+	conv.AddSrcTrigger(schema.Trigger{Name: "check", Table: "orders", Timing: "BEFORE INSERT", Body: "IF NEW.id < 0 THEN SET NEW.id = -NEW.id; END IF"})
+	assert.Equal(t, []string{
+		"Trigger 'both' (BEFORE INSERT on table 'orders') sets 'created', 'modified' to the current time: make them TIMESTAMP columns with OPTIONS (allow_commit_timestamp = true), and write PENDING_COMMIT_TIMESTAMP() (spanner.CommitTimestamp in the Go client) to them from the application. It copies changes to 'orders_log': create a change stream on table 'orders' (CREATE CHANGE STREAM ... FOR orders), and fill the audit table from the change stream (e.g. with Dataflow), or write it in the same transaction as the changes",
+		"Trigger 'check' (BEFORE INSERT on table 'orders') was not migrated: Spanner has no triggers, so its logic must move to the application",
+		"Trigger 'touch' (BEFORE UPDATE on table 'users') sets 'updated_at' to the current time: make it a TIMESTAMP column with OPTIONS (allow_commit_timestamp = true), and write PENDING_COMMIT_TIMESTAMP() (spanner.CommitTimestamp in the Go client) to it from the application",
+		"Trigger 'audit' (AFTER INSERT OR UPDATE on table 'users') copies changes to 'audit_log': create a change stream on table 'users' (CREATE CHANGE STREAM ... FOR users), and fill the audit table from the change stream (e.g. with Dataflow), or write it in the same transaction as the changes",
+	}, triggerReport(conv))
+}
@@ -0,0 +1,351 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package internal
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/cloudspannerecosystem/harbourbridge/spanner/ddl"
+)
+
+// ValueDictionary validates and normalizes the values of a code format,
+// such as ICD-10 diagnosis codes or ISO 4217 currency codes (see
+// ValueDictionaryRule). Normalize is called with a lock held, so it
+// doesn't need to be safe for concurrent use.
+type ValueDictionary interface {
+	// Normalize returns the canonical form of value, and false if value
+	// isn't a valid code.
+	Normalize(value string) (string, bool)
+}
+
+var valueDictionaries = struct {
+	sync.Mutex
+	m map[string]ValueDictionary
+}{m: make(map[string]ValueDictionary)}
+
+// RegisterValueDictionary makes d available as name (e.g. for plugins to
+// add dictionaries), replacing any dictionary of that name.
+func RegisterValueDictionary(name string, d ValueDictionary) {
+	valueDictionaries.Lock()
+	defer valueDictionaries.Unlock()
+	valueDictionaries.m[name] = d
+}
+
+// ValueDictionaries returns the names of the registered value
+// dictionaries, sorted.
+func ValueDictionaries() []string {
+	valueDictionaries.Lock()
+	defer valueDictionaries.Unlock()
+	var l []string
+	for name := range valueDictionaries.m {
+		l = append(l, name)
+	}
+	sort.Strings(l)
+	return l
+}
+
+func valueDictionary(name string) (ValueDictionary, bool) {
+	valueDictionaries.Lock()
+	defer valueDictionaries.Unlock()
+	d, ok := valueDictionaries.m[name]
+	return d, ok
+}
+
+// CodeList is a ValueDictionary of a list of codes, each with optional
+// aliases (e.g. other spellings, or retired codes), which are normalized
+// to the code. Values are matched without leading and trailing white
+// space, and optionally ignoring case and some characters (e.g. the dot
+// of ICD-10 codes, so that E11.9 and E119 match).
+type CodeList struct {
+	ignoreCase  bool
+	ignoreChars string
+	codes       map[string]string // Codes, by the matching key of themselves and their aliases.
+}
+
+// NewCodeList returns a CodeList of the codes of codes (the keys), with
+// their aliases. It returns an error if two codes (or aliases of
+// different codes) match each other.
+func NewCodeList(codes map[string][]string, ignoreCase bool, ignoreChars string) (*CodeList, error) {
+	l := &CodeList{ignoreCase: ignoreCase, ignoreChars: ignoreChars, codes: make(map[string]string)}
+	var names []string
+	for c := range codes {
+		names = append(names, c)
+	}
+	sort.Strings(names)
+	for _, c := range names {
+		for _, v := range append([]string{c}, codes[c]...) {
+			k := l.key(v)
+			if x, ok := l.codes[k]; ok && x != c {
+				return nil, fmt.Errorf("%q matches both codes %q and %q", v, x, c)
+			}
+			l.codes[k] = c
+		}
+	}
+	return l, nil
+}
+
+func (l *CodeList) key(s string) string {
+	s = strings.TrimSpace(s)
+	if l.ignoreChars != "" {
+		s = strings.Map(func(r rune) rune {
+			if strings.ContainsRune(l.ignoreChars, r) {
+				return -1
+			}
+			return r
+		}, s)
+	}
+	if l.ignoreCase {
+		s = strings.ToUpper(s)
+	}
+	return s
+}
+
+// Normalize returns the code value matches.
+func (l *CodeList) Normalize(value string) (string, bool) {
+	c, ok := l.codes[l.key(value)]
+	return c, ok
+}
+
+// Handling of values not in their value dictionary (see
+// ValueDictionaryRule.Unknown).
+const (
+	KeepUnknownCodes   = "keep"   // Write them as is.
+	NullUnknownCodes   = "null"   // Write NULL instead.
+	RejectUnknownCodes = "reject" // Report their rows as bad rows.
+)
+
+// maxUnknownSamples is the number of distinct unknown values of each
+// column listed in the report.
+const maxUnknownSamples = 10
+
+// ValueDictionaryRule validates the values of STRING columns against a
+// value dictionary during data conversion, and replaces them by their
+// canonical form. Values not in the dictionary are counted, and
+// sampled in the report.
+type ValueDictionaryRule struct {
+	// Columns are the Spanner STRING columns the rule applies to, given
+	// as table.column.
+	Columns []string
+	// Dictionary is the name of a registered ValueDictionary (see
+	// RegisterValueDictionary).
+	Dictionary string
+	// File is a CSV file the dictionary is read from (and registered as
+	// Dictionary, or as File if Dictionary is empty) instead: one code
+	// per line, followed by its aliases, e.g. "USD,US$". Lines starting
+	// with # are comments. IgnoreCase and IgnoreChars apply to File (see
+	// CodeList).
+	File        string `json:",omitempty"`
+	IgnoreCase  bool   `json:",omitempty"`
+	IgnoreChars string `json:",omitempty"`
+	// Unknown is the handling of values not in the dictionary: "keep"
+	// (the default), "null" or "reject".
+	Unknown string `json:",omitempty"`
+}
+
+func (r ValueDictionaryRule) String() string {
+	return r.Dictionary + " for " + strings.Join(r.Columns, ", ")
+}
+
+// valueDictRule is a ValueDictionaryRule resolved for data conversion.
+type valueDictRule struct {
+	name    string
+	dict    ValueDictionary
+	unknown string
+}
+
+// CheckValueDictionaries checks that conv.Policies.ValueDictionaries are
+// valid for the schema of conv: dictionaries must be registered, and
+// columns must be STRING Spanner columns, each given in one rule at most.
+func (conv *Conv) CheckValueDictionaries() error {
+	rules := make(map[string]valueDictRule)
+	for _, r := range conv.Policies.ValueDictionaries {
+		d, ok := valueDictionary(r.Dictionary)
+		if !ok {
+			return fmt.Errorf("bad value dictionary rule %s: unknown dictionary %q (registered dictionaries: %s)", r, r.Dictionary, strings.Join(ValueDictionaries(), ", "))
+		}
+		unknown := strings.ToLower(r.Unknown)
+		switch unknown {
+		case "":
+			unknown = KeepUnknownCodes
+		case KeepUnknownCodes, NullUnknownCodes, RejectUnknownCodes:
+		default:
+			return fmt.Errorf("bad value dictionary rule %s: unknown handling %q (accepted values are %q, %q and %q)", r, r.Unknown, KeepUnknownCodes, NullUnknownCodes, RejectUnknownCodes)
+		}
+		if len(r.Columns) == 0 {
+			return fmt.Errorf("bad value dictionary rule %s: no columns", r)
+		}
+		for _, c := range r.Columns {
+			i := strings.LastIndex(c, ".")
+			if i < 0 {
+				return fmt.Errorf("bad value dictionary rule %s: columns must be given as table.column", r)
+			}
+			cd, ok := conv.SpSchema[c[:i]].ColDefs[c[i+1:]]
+			if !ok {
+				return fmt.Errorf("bad value dictionary rule %s: unknown column %s", r, c)
+			}
+			if cd.T.Name != ddl.String || cd.T.IsArray {
+				return fmt.Errorf("bad value dictionary rule %s: column %s isn't a STRING column", r, c)
+			}
+			if _, ok := rules[c]; ok {
+				return fmt.Errorf("bad value dictionary rule %s: column %s is given in several rules", r, c)
+			}
+			rules[c] = valueDictRule{name: r.Dictionary, dict: d, unknown: unknown}
+		}
+	}
+	conv.valueDicts = rules
+	return nil
+}
+
+// applyValueDictionaries normalizes the values of a row of spTable
+// checked against value dictionaries, and applies the handling of unknown
+// values. It returns the values to write, and false if the row should not
+// be written.
+func (conv *Conv) applyValueDictionaries(srcTable, spTable string, spCols []string, spVals []interface{}) ([]interface{}, bool) {
+	conv.rowsMu.Lock()
+	if conv.valueDicts == nil {
+		// Rules are checked by CheckValueDictionaries.
+		_ = conv.CheckValueDictionaries()
+	}
+	dicts := conv.valueDicts
+	conv.rowsMu.Unlock()
+	vals := spVals
+	copied := false
+	ok := true
+	for i, c := range spCols {
+		r, found := dicts[spTable+"."+c]
+		if !found {
+			continue
+		}
+		s, isString := spVals[i].(string)
+		if !isString {
+			continue
+		}
+		code, known := r.dict.Normalize(s)
+		if known && code == s {
+			continue
+		}
+		if !known {
+			conv.statsAddUnknownCode(srcTable, c, s)
+		}
+		switch {
+		case known:
+			conv.statsAddNormalizedCode(srcTable, c)
+		case r.unknown == NullUnknownCodes:
+			code = ""
+		case r.unknown == RejectUnknownCodes:
+			ok = false
+			continue
+		default:
+			continue
+		}
+		if !copied {
+			// Don't modify the caller's slice.
+			vals = append([]interface{}{}, spVals...)
+			copied = true
+		}
+		if known {
+			vals[i] = code
+		} else {
+			vals[i] = nil
+		}
+	}
+	return vals, ok
+}
+
+// statsAddNormalizedCode increments the count of values of Spanner
+// column spCol of srcTable replaced by their canonical form. Only called
+// in data mode (from WriteRow).
+func (conv *Conv) statsAddNormalizedCode(srcTable, spCol string) {
+	conv.statsMu.Lock()
+	defer conv.statsMu.Unlock()
+	if conv.Stats.Normalized == nil {
+		conv.Stats.Normalized = make(map[string]map[string]int64)
+	}
+	if conv.Stats.Normalized[srcTable] == nil {
+		conv.Stats.Normalized[srcTable] = make(map[string]int64)
+	}
+	conv.Stats.Normalized[srcTable][spCol]++
+}
+
+// statsAddUnknownCode increments the count of values of Spanner column
+// spCol of srcTable not in their value dictionary, and samples value.
+// Only called in data mode (from WriteRow).
+func (conv *Conv) statsAddUnknownCode(srcTable, spCol, value string) {
+	conv.statsMu.Lock()
+	defer conv.statsMu.Unlock()
+	if conv.Stats.UnknownCodes == nil {
+		conv.Stats.UnknownCodes = make(map[string]map[string]int64)
+		conv.Stats.UnknownSamples = make(map[string][]string)
+	}
+	if conv.Stats.UnknownCodes[srcTable] == nil {
+		conv.Stats.UnknownCodes[srcTable] = make(map[string]int64)
+	}
+	conv.Stats.UnknownCodes[srcTable][spCol]++
+	samples := conv.Stats.UnknownSamples[srcTable]
+	if s := spCol + "=" + value; len(unknownSamples(samples, spCol)) < maxUnknownSamples && !containsString(samples, s) {
+		conv.Stats.UnknownSamples[srcTable] = append(samples, s)
+	}
+}
+
+// unknownSamples returns the values of Spanner column spCol in samples
+// (see stats.UnknownSamples).
+func unknownSamples(samples []string, spCol string) []string {
+	var l []string
+	for _, s := range samples {
+		if strings.HasPrefix(s, spCol+"=") {
+			l = append(l, strings.TrimPrefix(s, spCol+"="))
+		}
+	}
+	return l
+}
+
+// buildValueDictionariesBody lists, for each column of srcTable checked
+// against a value dictionary, how many values were normalized during
+// data conversion, and how many weren't in the dictionary, with a sample
+// of them.
+func buildValueDictionariesBody(conv *Conv, srcTable, spTable string) []tableReportBody {
+	normalized, unknown := conv.Stats.Normalized[srcTable], conv.Stats.UnknownCodes[srcTable]
+	if len(normalized) == 0 && len(unknown) == 0 {
+		return nil
+	}
+	actions := map[string]string{
+		KeepUnknownCodes:   "written as is",
+		NullUnknownCodes:   "replaced by NULL",
+		RejectUnknownCodes: "rejected as bad rows",
+	}
+	var l []string
+	for _, c := range conv.SpSchema[spTable].ColNames {
+		r, ok := conv.valueDicts[spTable+"."+c]
+		if !ok || (normalized[c] == 0 && unknown[c] == 0) {
+			continue
+		}
+		line := fmt.Sprintf("Column '%s' (dictionary %s): %d values were normalized", c, r.name, normalized[c])
+		if n := unknown[c]; n > 0 {
+			var samples []string
+			for _, s := range unknownSamples(conv.Stats.UnknownSamples[srcTable], c) {
+				samples = append(samples, fmt.Sprintf("%q", s))
+			}
+			line += fmt.Sprintf(", and %d values not in the dictionary were %s (e.g. %s)", n, actions[r.unknown], strings.Join(samples, ", "))
+		}
+		l = append(l, line)
+	}
+	if len(l) == 0 {
+		return nil
+	}
+	return []tableReportBody{{Heading: "Value dictionaries", Lines: l}}
+}
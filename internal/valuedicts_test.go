@@ -0,0 +1,108 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package internal
+
+import (
+	"testing"
+
+	"github.com/cloudspannerecosystem/harbourbridge/spanner/ddl"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCodeList(t *testing.T) {
+	l, err := NewCodeList(map[string][]string{"E11.9": nil, "USD": {"US$"}}, true, ".")
+	assert.Nil(t, err)
+	for _, tc := range []struct {
+		value string
+		code  string
+		ok    bool
+	}{
+		{"E11.9", "E11.9", true},
+		{" e119 ", "E11.9", true},
+		{"us$", "USD", true},
+		{"E11", "", false},
+	} {
+		code, ok := l.Normalize(tc.value)
+		assert.Equal(t, tc.code, code, tc.value)
+		assert.Equal(t, tc.ok, ok, tc.value)
+	}
+	_, err = NewCodeList(map[string][]string{"E11.9": nil, "E119": nil}, false, ".")
+	assert.NotNil(t, err)
+}
+
+func valueDictsTestConv() *Conv {
+	conv := MakeConv()
+	conv.SpSchema["t"] = ddl.CreateTable{
+		Name:     "t",
+		ColNames: []string{"k", "a", "b", "n"},
+		ColDefs: map[string]ddl.ColumnDef{
+			"k": ddl.ColumnDef{Name: "k", T: ddl.Type{Name: ddl.Int64}, NotNull: true},
+			"a": ddl.ColumnDef{Name: "a", T: ddl.Type{Name: ddl.String, Len: ddl.MaxLength}},
+			"b": ddl.ColumnDef{Name: "b", T: ddl.Type{Name: ddl.String, Len: ddl.MaxLength}},
+			"n": ddl.ColumnDef{Name: "n", T: ddl.Type{Name: ddl.Int64}},
+		},
+		Pks: []ddl.IndexKey{ddl.IndexKey{Col: "k"}}}
+	conv.ToSpanner["src"] = NameAndCols{Name: "t", Cols: map[string]string{"k": "k", "a": "a", "b": "b", "n": "n"}}
+	l, _ := NewCodeList(map[string][]string{"USD": {"US$"}, "EUR": nil}, true, "")
+	RegisterValueDictionary("test-currencies", l)
+	return conv
+}
+
+func TestWriteRow_ValueDictionaries(t *testing.T) {
+	for _, tc := range []struct {
+		unknown string
+		want    [][]interface{}
+		bad     int64
+	}{
+		{"", [][]interface{}{{int64(1), "USD", "EUR", nil}, {int64(2), "XXX", "EUR", nil}}, 0},
+		{NullUnknownCodes, [][]interface{}{{int64(1), "USD", "EUR", nil}, {int64(2), nil, "EUR", nil}}, 0},
+		{RejectUnknownCodes, [][]interface{}{{int64(1), "USD", "EUR", nil}}, 1},
+	} {
+		conv := valueDictsTestConv()
+		conv.Policies.ValueDictionaries = []ValueDictionaryRule{
+			{Columns: []string{"t.a", "t.b"}, Dictionary: "test-currencies", Unknown: tc.unknown},
+		}
+		assert.Nil(t, conv.CheckValueDictionaries())
+		conv.SetDataMode()
+		var got [][]interface{}
+		conv.SetDataSink(func(table string, cols []string, vals []interface{}) {
+			got = append(got, vals)
+		})
+		cols := []string{"k", "a", "b", "n"}
+		conv.WriteRow("src", "t", cols, []interface{}{int64(1), "us$", "EUR", nil})
+		conv.WriteRow("src", "t", cols, []interface{}{int64(2), "XXX", "eur", nil})
+		assert.Equal(t, tc.want, got, tc.unknown)
+		assert.Equal(t, map[string]int64{"a": 1, "b": 1}, conv.Stats.Normalized["src"], tc.unknown)
+		assert.Equal(t, map[string]int64{"a": 1}, conv.Stats.UnknownCodes["src"], tc.unknown)
+		assert.Equal(t, []string{"a=XXX"}, conv.Stats.UnknownSamples["src"], tc.unknown)
+		assert.Equal(t, tc.bad, conv.Stats.BadRows["src"], tc.unknown)
+	}
+}
+
+func TestCheckValueDictionaries(t *testing.T) {
+	for _, r := range []ValueDictionaryRule{
+		{Columns: []string{"t.a"}, Dictionary: "missing"},
+		{Columns: []string{"t.a"}, Dictionary: "test-currencies", Unknown: "drop"},
+		{Dictionary: "test-currencies"},
+		{Columns: []string{"a"}, Dictionary: "test-currencies"},
+		{Columns: []string{"t.missing"}, Dictionary: "test-currencies"},
+		{Columns: []string{"t.n"}, Dictionary: "test-currencies"},
+		{Columns: []string{"t.a", "t.a"}, Dictionary: "test-currencies"},
+	} {
+		conv := valueDictsTestConv()
+		conv.Policies.ValueDictionaries = []ValueDictionaryRule{r}
+		assert.NotNil(t, conv.CheckValueDictionaries(), r.String())
+	}
+}
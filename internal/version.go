@@ -0,0 +1,81 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package internal
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// ServerVersion is the version of the server of a live source database,
+// detected when HarbourBridge connects to it. Discovery queries are
+// adjusted to the version, so that the catalogs of old servers are read
+// as far as they can be. The zero value is an unknown version, which is
+// assumed to be recent.
+type ServerVersion struct {
+	Product string // PostgreSQL, MySQL or MariaDB.
+	Major   int
+	Minor   int
+	Patch   int
+}
+
+var versionRegexp = regexp.MustCompile(`^(\d+)(?:\.(\d+))?(?:\.(\d+))?`)
+
+// ParseServerVersion parses the version s of a server of product, as
+// reported by the server (e.g. "8.0.32-log" for MySQL, or
+// "10.6.12-MariaDB-1:10.6.12+maria~ubu2004" for MariaDB, which is then
+// the product).
+func ParseServerVersion(product, s string) (ServerVersion, error) {
+	m := versionRegexp.FindStringSubmatch(strings.TrimSpace(s))
+	if m == nil {
+		return ServerVersion{}, fmt.Errorf("can't parse %s version %q", product, s)
+	}
+	if strings.Contains(s, "MariaDB") {
+		product = "MariaDB"
+	}
+	v := ServerVersion{Product: product}
+	v.Major, _ = strconv.Atoi(m[1])
+	v.Minor, _ = strconv.Atoi(m[2])
+	v.Patch, _ = strconv.Atoi(m[3])
+	return v, nil
+}
+
+// Known returns true if the version was detected.
+func (v ServerVersion) Known() bool {
+	return v.Product != ""
+}
+
+// AtLeast returns true if v is major.minor or later. Unknown versions are
+// assumed to be recent.
+func (v ServerVersion) AtLeast(major, minor int) bool {
+	if !v.Known() {
+		return true
+	}
+	return v.Major > major || (v.Major == major && v.Minor >= minor)
+}
+
+// String returns the product and version e.g. "PostgreSQL 9.6.24".
+func (v ServerVersion) String() string {
+	if !v.Known() {
+		return "unknown version"
+	}
+	if v.Product == "PostgreSQL" && v.Major >= 10 {
+		// Versions since PostgreSQL 10 have two parts.
+		return fmt.Sprintf("%s %d.%d", v.Product, v.Major, v.Minor)
+	}
+	return fmt.Sprintf("%s %d.%d.%d", v.Product, v.Major, v.Minor, v.Patch)
+}
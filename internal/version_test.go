@@ -0,0 +1,55 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package internal
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseServerVersion(t *testing.T) {
+	for _, tc := range []struct {
+		product, s string
+		want       ServerVersion
+		str        string
+	}{
+		{"PostgreSQL", "9.6.24", ServerVersion{"PostgreSQL", 9, 6, 24}, "PostgreSQL 9.6.24"},
+		{"PostgreSQL", "15.2 (Debian 15.2-1.pgdg110+1)", ServerVersion{"PostgreSQL", 15, 2, 0}, "PostgreSQL 15.2"},
+		{"MySQL", "5.6.51-log", ServerVersion{"MySQL", 5, 6, 51}, "MySQL 5.6.51"},
+		{"MySQL", "8.0.32", ServerVersion{"MySQL", 8, 0, 32}, "MySQL 8.0.32"},
+		{"MySQL", "10.6.12-MariaDB-1:10.6.12+maria~ubu2004", ServerVersion{"MariaDB", 10, 6, 12}, "MariaDB 10.6.12"},
+	} {
+		v, err := ParseServerVersion(tc.product, tc.s)
+		assert.Nil(t, err, tc.s)
+		assert.Equal(t, tc.want, v, tc.s)
+		assert.Equal(t, tc.str, v.String(), tc.s)
+	}
+	_, err := ParseServerVersion("MySQL", "unknown")
+	assert.NotNil(t, err)
+}
+
+func TestServerVersionAtLeast(t *testing.T) {
+	v := ServerVersion{"PostgreSQL", 9, 6, 24}
+	assert.True(t, v.AtLeast(9, 4))
+	assert.True(t, v.AtLeast(9, 6))
+	assert.False(t, v.AtLeast(10, 0))
+	v = ServerVersion{"PostgreSQL", 15, 2, 0}
+	assert.True(t, v.AtLeast(10, 0))
+	assert.False(t, v.AtLeast(16, 0))
+	// Unknown versions are assumed to be recent.
+	assert.True(t, ServerVersion{}.AtLeast(99, 0))
+	assert.Equal(t, "unknown version", ServerVersion{}.String())
+}
@@ -0,0 +1,192 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package internal
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+// TimeWindow is a daily time window, e.g. 22:00-06:00. Windows that end
+// before they start span midnight.
+type TimeWindow struct {
+	Start, End time.Duration // Time of day.
+}
+
+// ParseTimeWindow parses a time window: start-end, with times of day in
+// the 24-hour format hh:mm.
+func ParseTimeWindow(s string) (TimeWindow, error) {
+	parts := strings.Split(s, "-")
+	if len(parts) != 2 {
+		return TimeWindow{}, fmt.Errorf("invalid time window %q: expected start-end e.g. 22:00-06:00", s)
+	}
+	var w TimeWindow
+	for i, p := range parts {
+		t, err := time.Parse("15:04", strings.TrimSpace(p))
+		if err != nil {
+			return TimeWindow{}, fmt.Errorf("invalid time window %q: %s isn't a time of day hh:mm", s, p)
+		}
+		d := time.Duration(t.Hour())*time.Hour + time.Duration(t.Minute())*time.Minute
+		if i == 0 {
+			w.Start = d
+		} else {
+			w.End = d
+		}
+	}
+	if w.Start == w.End {
+		return TimeWindow{}, fmt.Errorf("invalid time window %q: it starts when it ends", s)
+	}
+	return w, nil
+}
+
+func (w TimeWindow) String() string {
+	f := func(d time.Duration) string {
+		return fmt.Sprintf("%02d:%02d", int(d.Hours()), int(d.Minutes())%60)
+	}
+	return f(w.Start) + "-" + f(w.End)
+}
+
+// Until returns how long it is from t until w opens, 0 if w is open at t.
+func (w TimeWindow) Until(t time.Time) time.Duration {
+	midnight := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, t.Location())
+	now := t.Sub(midnight)
+	open := now >= w.Start && now < w.End
+	if w.End < w.Start {
+		open = now >= w.Start || now < w.End
+	}
+	switch {
+	case open:
+		return 0
+	case now < w.Start:
+		return w.Start - now
+	default:
+		return 24*time.Hour - now + w.Start
+	}
+}
+
+// TableWindows schedules the data conversion of source tables during time
+// windows, e.g. to only read enormous history tables at night, when the
+// source database is quiet, while other tables are read right away.
+// Windows are given by tag, and tables are tagged with the window they
+// are read during, e.g. {"windows": {"night": "22:00-06:00"}, "tables":
+// {"orders_history": "night"}}. Times are in TimeZone, the local time
+// zone if it is empty.
+type TableWindows struct {
+	Windows  map[string]string `json:"windows"`
+	Tables   map[string]string `json:"tables"`
+	TimeZone string            `json:"time_zone,omitempty"`
+}
+
+// tableWindows are the parsed TableWindows.
+type tableWindows struct {
+	windows map[string]TimeWindow // Windows by source table.
+	tags    map[string]string     // Tags by source table.
+	loc     *time.Location
+}
+
+// SetTableWindows schedules the data conversion of source tables during
+// the time windows of w. It returns an error for unknown tables and tags.
+// Source packages of live databases read tables in the order their
+// windows open (see WindowDelay), and wait for the window of each table
+// before reading it (see WaitForWindow). The writes of the rows of tables
+// are paused while their window is closed, e.g. when a table isn't read
+// by the end of its window, or for dumps, whose tables are read in order
+// (see ClosedWindowTables).
+func (conv *Conv) SetTableWindows(w TableWindows) error {
+	if len(w.Tables) == 0 {
+		conv.windows = nil
+		return nil
+	}
+	tw := &tableWindows{windows: make(map[string]TimeWindow), tags: make(map[string]string), loc: time.Local}
+	if w.TimeZone != "" {
+		var err error
+		if tw.loc, err = time.LoadLocation(w.TimeZone); err != nil {
+			return fmt.Errorf("invalid time zone %q: %w", w.TimeZone, err)
+		}
+	}
+	for t, tag := range w.Tables {
+		if _, ok := conv.SrcSchema[t]; !ok {
+			return fmt.Errorf("can't schedule table %s: no such source table", t)
+		}
+		s, ok := w.Windows[tag]
+		if !ok {
+			return fmt.Errorf("can't schedule table %s: no window %s", t, tag)
+		}
+		window, err := ParseTimeWindow(s)
+		if err != nil {
+			return fmt.Errorf("invalid window %s: %w", tag, err)
+		}
+		tw.windows[t] = window
+		tw.tags[t] = tag
+	}
+	conv.windows = tw
+	return nil
+}
+
+// WindowDelay returns how long it is from now until the window of
+// srcTable opens, 0 if it is open or if srcTable has no window.
+func (conv *Conv) WindowDelay(srcTable string, now time.Time) time.Duration {
+	if conv.windows == nil {
+		return 0
+	}
+	w, ok := conv.windows.windows[srcTable]
+	if !ok {
+		return 0
+	}
+	return w.Until(now.In(conv.windows.loc))
+}
+
+// WaitForWindow waits until the window of srcTable opens, if it has one,
+// unless data conversion is aborted (see Aborted).
+func (conv *Conv) WaitForWindow(srcTable string) {
+	d := conv.WindowDelay(srcTable, time.Now())
+	if d == 0 {
+		return
+	}
+	fmt.Printf("Waiting %s for window %s (%s) to read table %s\n", d.Round(time.Minute), conv.windows.tags[srcTable], conv.windows.windows[srcTable], srcTable)
+	for d > 0 && conv.Aborted() == nil {
+		if d > time.Minute {
+			d = time.Minute
+		}
+		time.Sleep(d)
+		d = conv.WindowDelay(srcTable, time.Now())
+	}
+}
+
+// ClosedWindowTables returns the Spanner tables of the source tables
+// whose window is closed at now, sorted, and all the Spanner tables of
+// source tables that have a window.
+func (conv *Conv) ClosedWindowTables(now time.Time) (closed, all []string) {
+	if conv.windows == nil {
+		return nil, nil
+	}
+	conv.schemaMu.Lock()
+	defer conv.schemaMu.Unlock()
+	for srcTable := range conv.windows.windows {
+		sp, ok := conv.ToSpanner[srcTable]
+		if !ok {
+			continue
+		}
+		all = append(all, sp.Name)
+		if conv.WindowDelay(srcTable, now) > 0 {
+			closed = append(closed, sp.Name)
+		}
+	}
+	sort.Strings(closed)
+	sort.Strings(all)
+	return closed, all
+}
@@ -0,0 +1,81 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package internal
+
+import (
+	"testing"
+	"time"
+
+	"github.com/cloudspannerecosystem/harbourbridge/schema"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseTimeWindow(t *testing.T) {
+	w, err := ParseTimeWindow("22:00-06:30")
+	assert.Nil(t, err)
+	assert.Equal(t, TimeWindow{Start: 22 * time.Hour, End: 6*time.Hour + 30*time.Minute}, w)
+	assert.Equal(t, "22:00-06:30", w.String())
+	for _, s := range []string{"22:00", "22:00-25:00", "noon-06:00", "06:00-06:00"} {
+		_, err := ParseTimeWindow(s)
+		assert.NotNil(t, err, s)
+	}
+}
+
+func TestTimeWindowUntil(t *testing.T) {
+	at := func(h, m int) time.Time { return time.Date(2021, 3, 4, h, m, 0, 0, time.UTC) }
+	night := TimeWindow{Start: 22 * time.Hour, End: 6 * time.Hour}
+	lunch := TimeWindow{Start: 12 * time.Hour, End: 14 * time.Hour}
+	tests := []struct {
+		w    TimeWindow
+		t    time.Time
+		want time.Duration
+	}{
+		{night, at(23, 0), 0},
+		{night, at(5, 59), 0},
+		{night, at(6, 0), 16 * time.Hour},
+		{night, at(21, 30), 30 * time.Minute},
+		{lunch, at(13, 0), 0},
+		{lunch, at(11, 0), time.Hour},
+		{lunch, at(14, 0), 22 * time.Hour},
+	}
+	for _, tc := range tests {
+		assert.Equal(t, tc.want, tc.w.Until(tc.t), "%s at %s", tc.w, tc.t)
+	}
+}
+
+func TestSetTableWindows(t *testing.T) {
+	conv := MakeConv()
+	for _, name := range []string{"history", "users"} {
+		conv.SrcSchema[name] = schema.Table{Name: name}
+		GetSpannerTable(conv, name)
+	}
+	w := TableWindows{
+		Windows: map[string]string{"night": "22:00-06:00"},
+		Tables:  map[string]string{"history": "night"},
+	}
+	assert.Nil(t, conv.SetTableWindows(w))
+	now := time.Date(2021, 3, 4, 12, 0, 0, 0, time.Local)
+	assert.Equal(t, 10*time.Hour, conv.WindowDelay("history", now))
+	assert.Equal(t, time.Duration(0), conv.WindowDelay("users", now))
+	closed, all := conv.ClosedWindowTables(now)
+	assert.Equal(t, []string{"history"}, closed)
+	assert.Equal(t, []string{"history"}, all)
+	closed, _ = conv.ClosedWindowTables(now.Add(11 * time.Hour))
+	assert.Nil(t, closed)
+
+	assert.NotNil(t, conv.SetTableWindows(TableWindows{Windows: w.Windows, Tables: map[string]string{"orders": "night"}}))
+	assert.NotNil(t, conv.SetTableWindows(TableWindows{Windows: w.Windows, Tables: map[string]string{"users": "day"}}))
+	assert.NotNil(t, conv.SetTableWindows(TableWindows{Windows: map[string]string{"night": "late"}, Tables: w.Tables}))
+}
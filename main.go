@@ -21,6 +21,9 @@ import (
 	"flag"
 	"fmt"
 	"os"
+	"runtime"
+	"runtime/debug"
+	"strings"
 	"time"
 
 	_ "github.com/go-sql-driver/mysql"
@@ -43,25 +46,341 @@ var (
 	dataOnly         bool
 	skipForeignKeys  bool
 	sessionJSON      string
+	modelLangs       string
+	diagramFormats   string
+	diagramSource    bool
+	auditLog         string
+	notifyWebhooks   string
+	notifySlack      string
+	notifyTopics     string
+	progressTopic    string
+	metadataTable    bool
+	kmsKey           string
+	allowExisting    bool
+	backupExisting   bool
+	backupCutover    bool
+	backupRetention  time.Duration
+	analyze          bool
+	optimizerVersion int
+	grants           string
+	dropColumns      string
+	computedColumns  string
+	remodelFile      string
+	autoPartition    bool
+	moneyColumns     string
+	boolColumns      string
+	dropIndexes      string
+	longKeys         string
+	trimToLimits     bool
+	syntheticKeys    string
+	seed             int64
+	plugins          string
+	tableHook        string
+	order            string
+	schemaDir        bool
+	fkNames          string
+	spannerFeatures  string
 	webapi           bool
+	offline          bool
 	dumpFilePath     string
 	targetDb         = conversion.TARGET_SPANNER
+	noSpanner        bool
+	target           string
+	specialValues    string
+	oversize         string
+	orphans          string
+	duplicates       string
+	notNull          string
+	emptyStrings     string
+	charPadding      string
+	maxBadTable      int64
+	maxBadTotal      int64
+	priority         string
+	transactionTag   string
+	requestTag       string
+	routeToLeader    bool
+	numChannels      int
+	minSessions      uint64
+	maxSessions      uint64
+	keepaliveTime    time.Duration
+	writes           int64
+	writeMode        string
+	tableWrites      string
+	tableWindows     string
+	tui              bool
+	scaleUnits       int
+	scaleConfirm     bool
+	scanAnomalies    bool
+	sourceFixes      bool
+	tightenStrings   float64
+	profileRows      int64
+	longStrings      string
+	ddlComments      string
+	masksFile        string
+	dateRulesFile    string
+	valueDictsFile   string
+	maskedDump       string
+	cacheDir         string
+	reportLayout     string
+	reportFilter     string
+	manifests        bool
+	sarif            bool
+	validateRows     int
+	propagateDDL     bool
+	validateInterval time.Duration
+	cutoverStopCDC   string
+	cutoverDrain     time.Duration
+	cutoverReadOnly  string
+	cutoverWebhook   string
+	controlAddr      string
+	cloneInstance    string
+	cloneDBName      string
+	cloneMethod      string
+	sourceReplica    string
+	sourceSnapshot   string
+	incrementalKeys  string
+	softDelete       string
+	tenant           string
+	names            string
+	nameDictionary   string
+	spillDir         string
+	staging          string
+	stagingStep      string
+	fetchRows        int
+	fetchBytes       int64
+	schemaDrift      string
+	phase            string
+	stateStore       string
+	phaseTables      string
+	outDir           string
+	k8sJobsFile      string
+	k8sShards        int
+	k8sImage         string
+	k8sAccount       string
+	k8sSecret        string
 )
 
 func init() {
 	flag.StringVar(&dbNameOverride, "dbname", "", "dbname: name to use for Spanner DB")
 	flag.StringVar(&instanceOverride, "instance", "", "instance: Spanner instance to use")
 	flag.StringVar(&filePrefix, "prefix", "", "prefix: file prefix for generated files")
+	flag.StringVar(&outDir, "out-dir", "hb-out", "out-dir: directory where each run writes its files, to a directory named after the time it started, with ddl, report, session, badrows and logs subdirectories; empty writes them to the current directory")
 	flag.StringVar(&driverName, "driver", "pg_dump", "driver name: flag for accessing source DB or dump files (accepted values are \"pg_dump\", \"postgres\", \"mysqldump\", and \"mysql\")")
 	flag.Int64Var(&schemaSampleSize, "schema-sample-size", int64(100000), "schema-sample-size: the number of rows to use for inferring schema (only for DynamoDB)")
 	flag.BoolVar(&verbose, "v", false, "verbose: print additional output")
 	flag.BoolVar(&schemaOnly, "schema-only", false, "schema-only: in this mode we do schema conversion, but skip data conversion")
 	flag.BoolVar(&dataOnly, "data-only", false, "data-only: in this mode we skip schema conversion and just do data conversion (use the session flag to specify the session file for schema and data mapping)")
 	flag.BoolVar(&skipForeignKeys, "skip-foreign-keys", false, "skip-foreign-keys: if true, skip creating foreign keys after data migration is complete (ddl statements for foreign keys can still be found in the downloaded schema.ddl.txt file and the same can be applied separately)")
+	flag.BoolVar(&scanAnomalies, "scan-anomalies", false, "scan-anomalies: before loading data, scan the source database for data that will cause conversion problems, and report counts per column (only for postgres and mysql drivers)")
+	flag.BoolVar(&sourceFixes, "source-fixes", false, "source-fixes: write SQL statements that fix issues of the source database that are cheaper to fix before migrating (tables without a primary key, invalid dates found by -scan-anomalies, unused indexes) to fixes.sql (only for postgres and mysql drivers)")
+	flag.Float64Var(&tightenStrings, "tighten-strings", 0, "tighten-strings: profile the source data, and give the columns that would be converted to STRING(MAX) the length of their longest value multiplied by this safety factor (at least 1) e.g. 1.5; 0 keeps STRING(MAX) (only for postgres and mysql drivers)")
+	flag.StringVar(&longStrings, "long-strings", "", "long-strings: check the source data for values longer than their STRING(n) column (Spanner counts lengths in Unicode characters, which can differ from the source's character set), and widen these columns: inflate doubles their length until the longest value fits, max converts them to STRING(MAX) (only for postgres and mysql drivers)")
+	flag.Int64Var(&profileRows, "profile-rows", 0, "profile-rows: profile the columns of the source database (NULL fraction, distinct values, min and max) from a sample of this many rows per table, and show the profiles in the report; 0 disables profiling (only for postgres and mysql drivers)")
+	flag.StringVar(&dropColumns, "drop-columns", "", "drop-columns: comma-separated list of source columns (given as table.column) that are not migrated: they are removed from the Spanner schema and their data is skipped")
+	flag.StringVar(&masksFile, "masks", "", "masks: JSON file specifying how the values of source columns (given as table.column) are masked during data conversion e.g. {\"users.email\": {\"Method\": \"hash\"}} (accepted methods are \"null\", \"redact\", \"hash\" and \"fixed\")")
+	flag.StringVar(&valueDictsFile, "value-dictionaries", "", "value-dictionaries: JSON file listing value dictionaries (e.g. ICD-10 or currency codes) to validate and normalize the values of STRING Spanner columns (given as table.column) against during data conversion, each read from a CSV file of codes and their aliases or registered by a plugin, with the handling of unknown values (keep, null or reject) e.g. [{\"Columns\": [\"claims.diagnosis\"], \"File\": \"icd10.csv\", \"IgnoreChars\": \".\", \"Unknown\": \"reject\"}]")
+	flag.StringVar(&dateRulesFile, "date-rules", "", "date-rules: JSON file listing sentinel values of DATE and TIMESTAMP columns to remap during data conversion, with their replacement (NULL if not given) and optionally the Spanner columns (given as table.column) they apply to e.g. [{\"Match\": \"9999-12-31\"}, {\"Match\": \"1900-01-01\", \"Replace\": \"1970-01-01\", \"Columns\": [\"users.birth_date\"]}]")
+	flag.StringVar(&maskedDump, "masked-dump", "", "masked-dump: instead of converting the dump, write a copy of it with the values of the columns given by -masks masked to this file, without using Spanner (only for pg_dump and mysqldump drivers)")
+	flag.StringVar(&computedColumns, "computed-columns", "", "computed-columns: JSON file defining new Spanner columns whose values are computed from other columns during data conversion")
+	flag.StringVar(&remodelFile, "remodel", "", "remodel: JSON file specifying Spanner tables to split into several tables, or to merge into another table, unique indexes to use as primary keys and primary keys to reorder")
+	flag.BoolVar(&autoPartition, "auto-partition", false, "auto-partition: move columns of tables approaching Spanner's limits on columns per table or row size to interleaved side tables (the report suggests these splits even without this flag)")
+	flag.StringVar(&moneyColumns, "money-columns", "", "money-columns: comma-separated list of FLOAT64 and FLOAT32 Spanner columns to convert to NUMERIC, so that monetary amounts are stored exactly, as table.column=numeric (or table.column=float to keep a column), optionally starting with auto to convert the columns whose name looks monetary e.g. auto,stats.score=float")
+	flag.StringVar(&boolColumns, "bool-columns", "", "bool-columns: comma-separated list of Spanner columns converted from MySQL CHAR(1) columns holding flags ('Y'/'N', 'T'/'F' or '1'/'0') to convert to BOOL, as table.column=bool (or table.column=string to keep a column), optionally starting with auto to convert the columns whose name looks like a flag e.g. auto,users.grade=string")
+	flag.StringVar(&dropIndexes, "drop-indexes", "", "drop-indexes: comma-separated list of Spanner indexes to drop, as index=drop (or index=keep to keep an index), optionally starting with auto to drop the indexes that look redundant (implied by the primary key or another index, or unused in the source) e.g. auto,orders_date_idx=keep")
+	flag.StringVar(&longKeys, "long-keys", "", "long-keys: comma-separated list of STRING primary key columns whose values can exceed Spanner's limit of 8KB on key size, as table.column=hash (replace the column by its SHA-256 hash in the key, and index a prefix of its values), table.column=truncate (truncate its values, rejecting rows whose truncated keys collide) or table.column=keep, optionally followed by :len, the number of characters kept (1024 by default), and optionally starting with auto to hash the key columns that can exceed the limit e.g. auto,urls.url=truncate:500")
+	flag.BoolVar(&trimToLimits, "trim-to-limits", false, "trim-to-limits: drop the indexes and foreign keys of tables that exceed Spanner's limits per table, keeping unique indexes and the indexes most used in the source (the report lists tables over the limits even without this flag)")
+	flag.Int64Var(&seed, "seed", 0, "seed: seed of the random values generated by the run (e.g. uuid synthetic keys), recorded in the session file so that rehearsals generate the same values; 0 means the seed recorded in the session file given by -session, or a random seed")
+	flag.StringVar(&syntheticKeys, "synthetic-keys", "", "synthetic-keys: generator of the primary keys added to tables without one (accepted values are \"sequence\" for bit-reversed INT64 sequence numbers, \"uuid\", \"ulid\" and \"ksuid\", or a generator registered by a plugin); by default, the generator recorded in the session file, or sequence")
+	flag.StringVar(&plugins, "plugins", "", "plugins: comma-separated list of Go plugins (.so files) defining hooks called before and after each table is converted")
+	flag.StringVar(&tableHook, "table-hook", "", "table-hook: command run for each converted table, which can modify the Spanner table (read from stdin as JSON) by writing it to stdout, or reject it with a non-zero exit status")
+	flag.StringVar(&order, "order", "name", "order: order of tables, columns, indexes and foreign keys in the generated schema and report (accepted values are \"name\" for alphabetical order and \"source\" for the order they are defined in the source database)")
+	flag.StringVar(&ddlComments, "ddl-comments", conversion.DDLCommentsFull, "ddl-comments: comments of the schema file: full (table comments, and the source of each column), minimal (table comments only) or none")
+	flag.BoolVar(&schemaDir, "schema-dir", false, "schema-dir: also write the Spanner DDL as one file per table, index and foreign key, under a directory with a manifest listing the order to apply them in")
+	flag.StringVar(&modelLangs, "models", "", "models: comma-separated list of languages to generate models (structs or classes for the rows of each table) of the Spanner schema in (accepted values are \"go\" and \"sqlalchemy\")")
+	flag.StringVar(&diagramFormats, "diagrams", "", "diagrams: comma-separated list of formats to write entity relationship diagrams of the Spanner schema in, with foreign key and interleaving edges (accepted values are \"dbml\" and \"mermaid\")")
+	flag.BoolVar(&diagramSource, "diagram-source", false, "diagram-source: with -diagrams, also write diagrams of the source schema")
+	flag.StringVar(&reportLayout, "report-layout", "single", "report-layout: layout of the report: single writes it to one file, split writes a summary with an index of the tables to the report file, and the report of each table to a file of its own in a directory named after the report file (for sources with many tables)")
+	flag.BoolVar(&sarif, "sarif", false, "sarif: write the schema issues in SARIF format, to a file ending in issues.sarif, so that code review tools show them as annotations of the legal schema DDL file")
+	flag.BoolVar(&manifests, "manifests", false, "manifests: write a manifest of each Spanner table for change-management sign-off (source definition, Spanner DDL, row counts and their verification, issues to acknowledge and sign-off fields), in Markdown, to a directory ending in manifests")
+	flag.StringVar(&reportFilter, "report-filter", "", "report-filter: comma-separated list of source tables whose reports are written (the summary still covers all tables), e.g. to regenerate the reports of the tables under review")
+	flag.StringVar(&fkNames, "fk-names", "", "fk-names: template for naming foreign keys, e.g. FK_{table}_{cols} (placeholders are {table}, {cols}, {ref_table}, {ref_cols} and {name}; by default, source names are kept)")
+	flag.StringVar(&notifyWebhooks, "notify-webhook", "", "notify-webhook: comma-separated list of URLs sent a POST request with a JSON summary when schema conversion, data conversion or row count verification completes, optimizer statistics are ready, or the run fails")
+	flag.StringVar(&notifySlack, "notify-slack", "", "notify-slack: comma-separated list of Slack incoming webhook URLs sent a message when schema conversion, data conversion or row count verification completes, optimizer statistics are ready, or the run fails")
+	flag.StringVar(&notifyTopics, "notify-pubsub", "", "notify-pubsub: comma-separated list of Pub/Sub topics (projects/<project>/topics/<topic>) published a JSON summary when schema conversion, data conversion or row count verification completes, optimizer statistics are ready, or the run fails")
+	flag.StringVar(&progressTopic, "progress-pubsub", "", "progress-pubsub: Pub/Sub topic (projects/<project>/topics/<topic>) to publish progress events to as JSON: start and finish (with row counts) of the data conversion of each table, unexpected conditions and checkpoints")
+	flag.StringVar(&auditLog, "audit-log", "", "audit-log: file to append a record of overrides of the default conversion, applied DDL statements and data conversion runs to, as JSON lines")
+	flag.BoolVar(&metadataTable, "metadata-table", false, "metadata-table: create a "+conversion.MetadataTable+" table in the Spanner database, recording the load status, checkpoints and row count verification of each run")
+	flag.StringVar(&kmsKey, "kms-key", "", "kms-key: Cloud KMS key (projects/<project>/locations/<location>/keyRings/<key ring>/cryptoKeys/<key>) to encrypt the new database with, instead of a Google-managed key")
+	flag.BoolVar(&allowExisting, "allow-existing", false, "allow-existing: if the Spanner database already exists and has tables, add the migrated tables to it (by default, HarbourBridge refuses to use such databases)")
+	flag.BoolVar(&backupExisting, "backup-existing", false, "backup-existing: with -allow-existing, back up the existing database (see -backup-retention) before adding tables to it")
+	flag.BoolVar(&backupCutover, "backup-before-cutover", false, "backup-before-cutover: once data conversion is complete and row counts are verified, back up the database as a rollback point for go-live (the backup is named in the report)")
+	flag.BoolVar(&analyze, "analyze", false, "analyze: once data is loaded, construct a new query optimizer statistics package (ANALYZE) so that the optimizer has statistics on the loaded data at cutover, and report when it is ready")
+	flag.IntVar(&optimizerVersion, "optimizer-version", 0, "optimizer-version: once data is loaded, set the default query optimizer version of the database (by default, Spanner's default is kept)")
+	flag.DurationVar(&backupRetention, "backup-retention", 7*24*time.Hour, "backup-retention: how long backups made by HarbourBridge are kept, between 6h and 8784h (366 days)")
+	flag.StringVar(&grants, "grant", "", "grant: comma-separated list of IAM roles to grant on the database once its tables are created, as member[=role] e.g. app@my-project.iam.gserviceaccount.com=databaseReader (members default to service accounts, and roles to roles/spanner.databaseUser)")
+	flag.StringVar(&cacheDir, "cache-dir", "", "cache-dir: directory where the schema parsed from a dump is cached, keyed by a hash of the dump, of the schema conversion settings and of HarbourBridge, so that converting the schema of the same dump again (e.g. to regenerate the report and DDL during schema reviews) doesn't parse the dump again (only for pg_dump and mysqldump drivers)")
 	flag.StringVar(&sessionJSON, "session", "", "session: specifies the file we restore session state from (used in schema-only to provide schema and data mapping)")
 	flag.BoolVar(&webapi, "web", false, "web: run the web interface (experimental)")
+	flag.BoolVar(&offline, "offline", false, "offline: guarantee the run makes no network access (including credential lookups), failing if one is attempted; requires schema-only mode and a dump file driver (pg_dump or mysqldump)")
+	flag.StringVar(&sourceReplica, "source-replica", "", "source-replica: host (host or host:port) of a read replica to read the source schema and data from (only for postgres and mysql drivers)")
+	flag.StringVar(&incrementalKeys, "incremental", "", "incremental: comma-separated list of table=column items giving the column (e.g. an updated_at timestamp) that tracks changes to the rows of source tables, to only load the rows changed since the previous load, e.g. for data-only top-up runs (only for postgres and mysql drivers)")
+	flag.StringVar(&softDelete, "soft-delete", "", "soft-delete: column (e.g. deleted_at) or column=value (e.g. is_deleted=1) identifying soft-deleted rows, which are not migrated: rows whose column isn't NULL, or is value, in every table that has the column (only for postgres and mysql drivers)")
+	flag.StringVar(&names, "names", internal.ReplaceNames, "names: how characters of source names that Spanner doesn't accept (names are ASCII letters, digits and underscores) are mapped: replace replaces them by underscores, transliterate spells accented, Cyrillic, Greek and kana letters with ASCII letters, and other characters with their code point")
+	flag.StringVar(&nameDictionary, "name-dictionary", "", "name-dictionary: JSON file mapping words of source names to their replacement in Spanner names e.g. {\"用户\": \"user\"}, applied before -names")
+	flag.StringVar(&tenant, "tenant", "", "tenant: column=value (e.g. tenant_id=42) selecting the rows of a single tenant to migrate from a multi-tenant source: rows whose column is value, and rows of tables without the column that reference them through foreign keys (only for postgres and mysql drivers)")
+	flag.StringVar(&sourceSnapshot, "source-snapshot", "", "source-snapshot: read all tables from a single consistent snapshot of the source (only for postgres and mysql drivers): \"consistent\" for a snapshot taken when data conversion starts, the name of an exported PostgreSQL snapshot, or a MySQL GTID set the server must have executed before the snapshot is taken")
+	flag.IntVar(&fetchRows, "fetch-rows", 0, "fetch-rows: read tables with a primary key in batches of at most this many rows, in primary key order, instead of with a single query, e.g. for tables with very wide rows (only for postgres and mysql drivers; 0 means tables are read with a single query)")
+	flag.StringVar(&schemaDrift, "schema-drift", conversion.DriftAbort, "schema-drift: how changes of the source schema since the session was created are handled when data is converted from a session file (with -data-only or -phase data), and reported by the validate subcommand (only for postgres and mysql drivers; accepted values are \"abort\", \"warn\" and \"ignore\")")
+	flag.Int64Var(&fetchBytes, "fetch-bytes", internal.DefaultFetchBytes, "fetch-bytes: with -fetch-rows, the number of bytes to read per batch: batches have fewer rows when the average size of rows exceeds this size divided by -fetch-rows")
+	flag.StringVar(&spillDir, "spill-dir", "", "spill-dir: directory to keep the schema of converted tables in, one file per table, instead of keeping the whole schema in memory, for source databases with too many tables to convert otherwise (only for postgres and mysql drivers; options that change several tables at once can't be used)")
+	flag.StringVar(&phase, "phase", "", "phase: run a single phase of the migration, coordinated with the other phases through the state store given by -state, e.g. to drive it from a workflow scheduler (accepted values are \"assess\", \"schema\", \"data\" and \"verify\", run in this order; data can run once per batch of tables)")
+	flag.StringVar(&staging, "staging", "", "staging: gs://bucket/prefix where the data of the source is staged before it is loaded, e.g. for sources in AWS: converted rows are streamed to the bucket with resumable uploads, then loaded from it (only for postgres, mysql and dynamodb drivers; the bucket is created in the region of the Spanner instance if it doesn't exist)")
+	flag.StringVar(&stagingStep, "staging-step", conversion.StageAll, "staging-step: with -staging, \"all\" stages the data and loads it, \"extract\" only stages it (the database is created, without data), and \"load\" loads data staged by a previous extract run (with -data-only)")
+	flag.StringVar(&stateStore, "state", "", "state: with -phase, where the state shared by the phases is kept: gs://bucket/prefix, spanner://projects/<project>/instances/<instance>/databases/<db> (an existing database, where a "+conversion.PhaseStateTable+" table is created) or a local directory")
+	flag.StringVar(&phaseTables, "tables", "", "tables: with -phase data, comma-separated list of the source tables to load (by default, all tables)")
+	flag.StringVar(&k8sJobsFile, "emit-k8s-jobs", "", "emit-k8s-jobs: with -phase assess, file to write Kubernetes Job manifests to, which run the data phase for shards of the tables (see -k8s-shards), with the flags of this run, to spread the load across a cluster")
+	flag.IntVar(&k8sShards, "k8s-shards", 4, "k8s-shards: with -emit-k8s-jobs, number of jobs the tables are split into, balanced by row counts when they are known")
+	flag.StringVar(&k8sImage, "k8s-image", "", "k8s-image: with -emit-k8s-jobs, container image of HarbourBridge run by the jobs")
+	flag.StringVar(&k8sAccount, "k8s-service-account", "", "k8s-service-account: with -emit-k8s-jobs, Kubernetes service account the jobs run as (e.g. bound to a Google service account with Workload Identity)")
+	flag.StringVar(&k8sSecret, "k8s-secret", "", "k8s-secret: with -emit-k8s-jobs, Kubernetes secret whose keys are set as environment variables of the jobs, e.g. the source connection settings PGHOST, PGUSER and PGPASSWORD")
 	flag.StringVar(&dumpFilePath, "dump-file", "", "dump-file: location of dump file to process")
 	flag.StringVar(&targetDb, "target-db", conversion.TARGET_SPANNER, "target-db: Specifies the target DB. Defaults to spanner")
+	flag.BoolVar(&noSpanner, "no-spanner", false, "no-spanner: don't connect to Spanner at all, and only write the schema, session and report files (requires schema-only mode)")
+	flag.StringVar(&target, "target", conversion.TARGET_SPANNER, "target: where the converted database goes: spanner (the default), or none, the same as -no-spanner")
+	flag.StringVar(&spannerFeatures, "spanner-features", "auto", "spanner-features: Spanner features the target supports, as a comma-separated list that can start with auto (all features, or only pg-numeric, pg-date and pg-arrays when SPANNER_EMULATOR_HOST is set), all or none, where -feature removes a feature e.g. auto,-json (known features are pg-numeric, pg-date, pg-arrays, json, float32, default-values, check-constraints, sequences and named-schemas)")
+	flag.StringVar(&specialValues, "special-values", "reject", "special-values: policy for source values that Spanner can't store, such as 'infinity' dates/timestamps and NaN/Infinity numerics (accepted values are \"reject\", \"clamp\" and \"null\")")
+	flag.StringVar(&oversize, "oversize", "sideline", "oversize: policy for STRING and BYTES values larger than Spanner's 10MB limit (accepted values are \"sideline\", \"truncate\" and \"overflow\")")
+	flag.StringVar(&duplicates, "duplicates", "ignore", "duplicates: policy for rows whose Spanner primary key matches that of an earlier row (accepted values are \"ignore\", \"first-wins\", \"last-wins\" and \"sideline\")")
+	flag.Int64Var(&maxBadTable, "max-bad-rows-per-table", 0, "max-bad-rows-per-table: abort data conversion, after writing the report and the bad data file, when a table has more rows that can't be converted (0 means no limit)")
+	flag.Int64Var(&maxBadTotal, "max-bad-rows-total", 0, "max-bad-rows-total: abort data conversion, after writing the report and the bad data file, when all tables together have more rows that can't be converted (0 means no limit)")
+	flag.StringVar(&notNull, "not-null", "ignore", "not-null: policy for NULL values in NOT NULL Spanner columns, optionally followed by per-column policies e.g. drop,orders.note=default (accepted policies are \"ignore\", \"default\", \"drop\" and \"relax\")")
+	flag.StringVar(&emptyStrings, "empty-strings", "keep", "empty-strings: policy for empty strings and NULL values of STRING Spanner columns, optionally followed by per-column policies e.g. keep,users.middle_name=null (accepted policies are \"keep\", \"null\" to write empty strings as NULL and \"empty\" to write NULL values as empty strings)")
+	flag.StringVar(&charPadding, "char-padding", "keep", "char-padding: policy for the trailing blanks of PostgreSQL's blank-padded CHAR(n) columns, optionally followed by per-column policies e.g. trim,accounts.code=keep (accepted policies are \"keep\" and \"trim\")")
+	flag.StringVar(&orphans, "orphans", "ignore", "orphans: policy for rows whose foreign key doesn't match a row of the referenced table (accepted values are \"ignore\", \"load\", \"drop\" and \"null\")")
+	flag.StringVar(&priority, "priority", "", "priority: priority of data conversion writes to Spanner, e.g. low to reduce the impact on live traffic (accepted values are \"low\", \"medium\" and \"high\"; defaults to Spanner's default)")
+	flag.StringVar(&transactionTag, "transaction-tag", "", "transaction-tag: tag for data conversion write transactions, shown in Spanner's transaction statistics")
+	flag.StringVar(&requestTag, "request-tag", "", "request-tag: tag for data conversion commits and DML requests, shown in Spanner's query statistics")
+	flag.BoolVar(&routeToLeader, "route-to-leader", false, "route-to-leader: route data conversion requests to Spanner's leader region")
+	// The Spanner client defaults (4 channels, at most 400 sessions) are
+	// tuned for serving traffic; bulk loading benefits from more channels
+	// and sessions (the client allows 100 sessions per channel).
+	flag.IntVar(&numChannels, "num-channels", 8, "num-channels: number of gRPC channels used by the Spanner client")
+	flag.Uint64Var(&minSessions, "min-sessions", 0, "min-sessions: minimum number of sessions in the Spanner client's session pool (0 means the client default)")
+	flag.Uint64Var(&maxSessions, "max-sessions", 800, "max-sessions: maximum number of sessions in the Spanner client's session pool")
+	flag.IntVar(&scaleUnits, "scale-processing-units", 0, "scale-processing-units: scale the Spanner instance to this many processing units (1 node is 1000 processing units) during data conversion, and restore its original size afterwards (0 means don't scale)")
+	flag.BoolVar(&scaleConfirm, "scale-confirm", false, "scale-confirm: confirm that the instance can be scaled by scale-processing-units")
+	flag.BoolVar(&propagateDDL, "propagate-ddl", false, "propagate-ddl: with the validate subcommand, propagate the compatible changes of the source schema (new columns, widened types) to Spanner and to the session file in each round, and report the others")
+	flag.IntVar(&validateRows, "validate-rows", 100, "validate-rows: with the validate and cutover subcommands, the number of recent rows (rows with the largest primary keys) of each table compared in each round")
+	flag.DurationVar(&validateInterval, "validate-interval", time.Minute, "validate-interval: with the validate and cutover subcommands, the interval between rounds of validation")
+	flag.StringVar(&cutoverStopCDC, "cutover-stop-cdc", "", "cutover-stop-cdc: with the cutover subcommand, command (a program and its arguments, separated by spaces) that stops applying changes to Spanner")
+	flag.DurationVar(&cutoverDrain, "cutover-drain-timeout", 10*time.Minute, "cutover-drain-timeout: with the cutover subcommand, how long to wait for recent rows of Spanner to match the source before giving up")
+	flag.StringVar(&cutoverReadOnly, "cutover-read-only-sql", "", "cutover-read-only-sql: with the cutover subcommand, SQL statement run on the source database to make it read-only")
+	flag.StringVar(&cloneInstance, "clone-instance", "", "clone-instance: with the clone subcommand, instance to clone the database into e.g. a staging instance")
+	flag.StringVar(&cloneDBName, "clone-dbname", "", "clone-dbname: with the clone subcommand, name of the clone (default: the name of the database)")
+	flag.StringVar(&cloneMethod, "clone-method", "", "clone-method: with the clone subcommand, how the database is cloned: \"backup\" (back up the database and restore the backup, the default) or \"copy\" (create the tables and copy their rows, the default with -masks)")
+	flag.StringVar(&controlAddr, "control-addr", "", "control-addr: local address (e.g. localhost:9099) of an HTTP endpoint that pauses writes during data conversion, drains the writes in progress, and resumes them")
+	flag.StringVar(&cutoverWebhook, "cutover-webhook", "", "cutover-webhook: with the cutover subcommand, URL sent a POST request to switch applications to Spanner once the cutover is verified (e.g. to flip a feature flag or DNS record)")
+	flag.StringVar(&writeMode, "write-mode", conversion.WriteMutations, "write-mode: how rows are written to Spanner: mutation (fastest), dml (batches of INSERT statements in read-write transactions, so that Spanner evaluates DEFAULT values and generated columns) or auto (dml for tables with DEFAULT values or generated columns, mutation for other tables)")
+	flag.BoolVar(&tui, "tui", false, "tui: show an interactive terminal UI during data conversion, with the tables being read and written, the throughput of writes and errors, to pause and resume the writes of tables")
+	flag.StringVar(&tableWindows, "table-windows", "", "table-windows: JSON file scheduling the data conversion of source tables during daily time windows, by tag, e.g. {\"windows\": {\"night\": \"22:00-06:00\"}, \"tables\": {\"orders_history\": \"night\"}} to only load a large table at night (other tables are loaded right away)")
+	flag.StringVar(&tableWrites, "table-writes", "", "table-writes: JSON file lowering the number of concurrent writes and the number of rows per write for some Spanner tables, e.g. {\"users\": {\"writes\": 2, \"batch_rows\": 500}} for a hot parent table (other tables use the global limits)")
+	flag.Int64Var(&writes, "writes", 0, "writes: number of concurrent writes to Spanner during data conversion (0 means it is adjusted automatically: it is increased until commit latency or the rate of aborted commits shows that Spanner is pushing back, then reduced)")
+	flag.DurationVar(&keepaliveTime, "keepalive", 0, "keepalive: interval for gRPC keepalive pings on idle Spanner connections, e.g. 1m (0 disables keepalive pings)")
+}
+
+// driverFlags lists the flags that only apply to some drivers; other
+// flags apply to all drivers.
+var driverFlags = map[string][]string{
+	"bool-columns":          {conversion.MYSQL, conversion.MYSQLDUMP},
+	"cache-dir":             {conversion.PGDUMP, conversion.MYSQLDUMP},
+	"cutover-drain-timeout": {conversion.POSTGRES, conversion.MYSQL},
+	"cutover-read-only-sql": {conversion.POSTGRES, conversion.MYSQL},
+	"cutover-stop-cdc":      {conversion.POSTGRES, conversion.MYSQL},
+	"cutover-webhook":       {conversion.POSTGRES, conversion.MYSQL},
+	"dump-file":             {conversion.PGDUMP, conversion.MYSQLDUMP},
+	"fetch-bytes":           {conversion.POSTGRES, conversion.MYSQL},
+	"fetch-rows":            {conversion.POSTGRES, conversion.MYSQL},
+	"incremental":           {conversion.POSTGRES, conversion.MYSQL},
+	"long-strings":          {conversion.POSTGRES, conversion.MYSQL},
+	"masked-dump":           {conversion.PGDUMP, conversion.MYSQLDUMP},
+	"offline":               {conversion.PGDUMP, conversion.MYSQLDUMP},
+	"profile-rows":          {conversion.POSTGRES, conversion.MYSQL},
+	"propagate-ddl":         {conversion.POSTGRES, conversion.MYSQL},
+	"scan-anomalies":        {conversion.POSTGRES, conversion.MYSQL},
+	"schema-drift":          {conversion.POSTGRES, conversion.MYSQL},
+	"soft-delete":           {conversion.POSTGRES, conversion.MYSQL},
+	"source-fixes":          {conversion.POSTGRES, conversion.MYSQL},
+	"source-replica":        {conversion.POSTGRES, conversion.MYSQL},
+	"source-snapshot":       {conversion.POSTGRES, conversion.MYSQL},
+	"spill-dir":             {conversion.POSTGRES, conversion.MYSQL},
+	"staging":               {conversion.POSTGRES, conversion.MYSQL, conversion.DYNAMODB},
+	"staging-step":          {conversion.POSTGRES, conversion.MYSQL, conversion.DYNAMODB},
+	"schema-sample-size":    {conversion.DYNAMODB},
+	"target-db":             {conversion.PGDUMP, conversion.POSTGRES},
+	"tenant":                {conversion.POSTGRES, conversion.MYSQL},
+	"tighten-strings":       {conversion.POSTGRES, conversion.MYSQL},
+	"validate-interval":     {conversion.POSTGRES, conversion.MYSQL},
+	"validate-rows":         {conversion.POSTGRES, conversion.MYSQL},
+}
+
+// spillFlags are the flags that need the schema of all tables in memory,
+// and so can't be used with -spill-dir.
+var spillFlags = []string{
+	"allow-existing", "audit-log", "auto-partition", "backup-before-cutover",
+	"bool-columns", "computed-columns", "data-only", "date-rules", "diagrams",
+	"drop-columns", "drop-indexes", "fk-names", "long-keys", "long-strings", "manifests", "masks",
+	"metadata-table", "models", "money-columns", "phase", "profile-rows",
+	"remodel", "sarif", "scan-anomalies", "schema-dir", "soft-delete",
+	"source-fixes", "table-windows", "tenant", "tighten-strings", "trim-to-limits",
+	"value-dictionaries",
+}
+
+// checkSpill returns an error if the flags that are set, or policies,
+// can't be used with -spill-dir.
+func checkSpill(policies internal.Policies) error {
+	var l []string
+	flag.Visit(func(f *flag.Flag) {
+		for _, name := range spillFlags {
+			if f.Name == name {
+				l = append(l, "-"+name)
+			}
+		}
+	})
+	if policies.Oversize == internal.OverflowOversize {
+		l = append(l, "-oversize=overflow")
+	}
+	if policies.Orphans != internal.IgnoreOrphans {
+		l = append(l, "-orphans")
+	}
+	relax := policies.NotNull == internal.RelaxNotNull
+	for _, p := range policies.NotNullColumns {
+		relax = relax || p == internal.RelaxNotNull
+	}
+	if relax {
+		l = append(l, "-not-null=relax")
+	}
+	if len(l) > 0 {
+		return fmt.Errorf("can't use -spill-dir with %s", strings.Join(l, ", "))
+	}
+	return nil
+}
+
+// flagsOf returns the names of the flags that apply to driver.
+func flagsOf(driver string) []string {
+	l := []string{}
+	flag.VisitAll(func(f *flag.Flag) {
+		if drivers, ok := driverFlags[f.Name]; ok {
+			found := false
+			for _, d := range drivers {
+				found = found || d == driver
+			}
+			if !found {
+				return
+			}
+		}
+		l = append(l, f.Name)
+	})
+	return l
 }
 
 func usage() {
@@ -71,13 +390,275 @@ func usage() {
 Sample usage:
   pg_dump mydb | %s
   %s < my_pg_dump_file
-`, os.Args[0], os.Args[0])
+To convert the schema of a dump without connecting to Spanner:
+  %s -schema-only -no-spanner < my_pg_dump_file
+To compare the reports of two runs:
+  %s report-diff old.report.json new.report.json
+To print the Spanner connection configuration of a migrated database:
+  %s -instance my-instance -dbname my-db connection-config my-db.session.json
+To check application queries against a migrated database:
+  %s -instance my-instance -dbname my-db check-queries my-db.session.json queries.sql
+To check that the schema of a database still matches its session file, e.g. before a data-only run:
+  %s -instance my-instance -dbname my-db check my-db.session.json
+To print the types, constructs and flags supported for a driver, as JSON:
+  %s capabilities -driver=postgres
+To continuously compare recent rows of the source and of Spanner while changes are replicated:
+  %s -driver=postgres -instance my-instance -dbname my-db validate my-db.session.json
+To stop replication, verify Spanner and switch applications to it:
+  %s -driver=postgres -instance my-instance -dbname my-db -cutover-webhook https://... cutover my-db.session.json
+To load the rows changed since the previous load of a migrated database:
+  %s -driver=postgres -instance my-instance -dbname my-db -data-only -session my-db.session.json -incremental orders=updated_at
+To run the phases of a migration as separate invocations (assess, schema, data and verify, in order):
+  %s -driver=postgres -instance my-instance -dbname my-db -state gs://my-bucket/my-db -phase data -tables orders,users
+To also write Kubernetes jobs that run the data phase for shards of the tables:
+  %s -driver=postgres -instance my-instance -dbname my-db -state gs://my-bucket/my-db -phase assess -emit-k8s-jobs jobs.yaml -k8s-image my-image
+To clone a migrated database into a staging instance, masking personal data:
+  %s -instance my-instance -dbname my-db -clone-instance my-staging-instance -masks masks.json clone my-db.session.json
+To run all phases against ephemeral Docker and Spanner emulator databases, as a smoke test of the other flags:
+  %s -driver=postgres -write-mode auto smoke-test fixtures.sql
+`, os.Args[0], os.Args[0], os.Args[0], os.Args[0], os.Args[0], os.Args[0], os.Args[0], os.Args[0], os.Args[0], os.Args[0], os.Args[0], os.Args[0], os.Args[0], os.Args[0], os.Args[0])
 }
 
 func main() {
 	flag.Usage = usage
 	flag.Parse()
 
+	if flag.Arg(0) == "report-diff" {
+		if flag.NArg() != 3 {
+			fmt.Fprintf(os.Stderr, "Usage: %s report-diff old.report.json new.report.json\n", os.Args[0])
+			os.Exit(2)
+		}
+		if err := cmd.ReportDiff(flag.Arg(1), flag.Arg(2), os.Stdout); err != nil {
+			fmt.Fprintf(os.Stderr, "%v\n", err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	if flag.Arg(0) == "connection-config" {
+		if flag.NArg() != 2 || instanceOverride == "" || dbNameOverride == "" {
+			fmt.Fprintf(os.Stderr, "Usage: %s -instance my-instance -dbname my-db connection-config my-db.session.json\n", os.Args[0])
+			os.Exit(2)
+		}
+		project, err := conversion.GetProject()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Can't get project: %v\n", err)
+			os.Exit(1)
+		}
+		if err := cmd.ConnectionConfig(flag.Arg(1), project, instanceOverride, dbNameOverride, os.Stdout); err != nil {
+			fmt.Fprintf(os.Stderr, "%v\n", err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	if flag.Arg(0) == "check-queries" {
+		if flag.NArg() != 3 || instanceOverride == "" || dbNameOverride == "" {
+			fmt.Fprintf(os.Stderr, "Usage: %s -instance my-instance -dbname my-db check-queries my-db.session.json queries.sql\n", os.Args[0])
+			os.Exit(2)
+		}
+		project, err := conversion.GetProject()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Can't get project: %v\n", err)
+			os.Exit(1)
+		}
+		db := fmt.Sprintf("projects/%s/instances/%s/databases/%s", project, instanceOverride, dbNameOverride)
+		if err := cmd.CheckQueries(flag.Arg(1), flag.Arg(2), db, os.Stdout); err != nil {
+			fmt.Fprintf(os.Stderr, "%v\n", err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	if flag.Arg(0) == "check" {
+		session := flag.Arg(1)
+		if flag.NArg() == 1 {
+			session = sessionJSON
+		}
+		if flag.NArg() > 2 || session == "" || instanceOverride == "" || dbNameOverride == "" {
+			fmt.Fprintf(os.Stderr, "Usage: %s -instance my-instance -dbname my-db check my-db.session.json\n", os.Args[0])
+			os.Exit(2)
+		}
+		project, err := conversion.GetProject()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Can't get project: %v\n", err)
+			os.Exit(1)
+		}
+		db := fmt.Sprintf("projects/%s/instances/%s/databases/%s", project, instanceOverride, dbNameOverride)
+		if err := cmd.Check(session, db, os.Stdout); err != nil {
+			fmt.Fprintf(os.Stderr, "%v\n", err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	if flag.Arg(0) == "validate" {
+		if flag.NArg() != 2 || instanceOverride == "" || dbNameOverride == "" {
+			fmt.Fprintf(os.Stderr, "Usage: %s -driver=postgres -instance my-instance -dbname my-db validate my-db.session.json\n", os.Args[0])
+			os.Exit(2)
+		}
+		project, err := conversion.GetProject()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Can't get project: %v\n", err)
+			os.Exit(1)
+		}
+		db := fmt.Sprintf("projects/%s/instances/%s/databases/%s", project, instanceOverride, dbNameOverride)
+		if err := cmd.Validate(flag.Arg(1), driverName, db, validateRows, validateInterval, propagateDDL, os.Stdout); err != nil {
+			fmt.Fprintf(os.Stderr, "%v\n", err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	if flag.Arg(0) == "cutover" {
+		if flag.NArg() != 2 || instanceOverride == "" || dbNameOverride == "" {
+			fmt.Fprintf(os.Stderr, "Usage: %s -driver=postgres -instance my-instance -dbname my-db cutover my-db.session.json\n", os.Args[0])
+			os.Exit(2)
+		}
+		project, err := conversion.GetProject()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Can't get project: %v\n", err)
+			os.Exit(1)
+		}
+		if filePrefix == "" {
+			filePrefix = dbNameOverride + "."
+		}
+		cfg := conversion.CutoverConfig{
+			Database:      fmt.Sprintf("projects/%s/instances/%s/databases/%s", project, instanceOverride, dbNameOverride),
+			StopCDC:       cutoverStopCDC,
+			DrainTimeout:  cutoverDrain,
+			DrainInterval: validateInterval,
+			ReadOnlySQL:   cutoverReadOnly,
+			Webhook:       cutoverWebhook,
+		}
+		if err := cmd.Cutover(flag.Arg(1), driverName, validateRows, cfg, filePrefix+"cutover.json", os.Stdout); err != nil {
+			fmt.Fprintf(os.Stderr, "%v\n", err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	if flag.Arg(0) == "clone" {
+		if flag.NArg() != 2 || instanceOverride == "" || dbNameOverride == "" || cloneInstance == "" {
+			fmt.Fprintf(os.Stderr, "Usage: %s -instance my-instance -dbname my-db -clone-instance my-staging-instance [-masks masks.json] clone my-db.session.json\n", os.Args[0])
+			os.Exit(2)
+		}
+		project, err := conversion.GetProject()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Can't get project: %v\n", err)
+			os.Exit(1)
+		}
+		cfg := conversion.CloneConfig{
+			Project:        project,
+			Instance:       instanceOverride,
+			Database:       dbNameOverride,
+			TargetInstance: cloneInstance,
+			TargetDatabase: cloneDBName,
+			Method:         cloneMethod,
+			Retention:      backupRetention,
+		}
+		if cfg.TargetDatabase == "" {
+			cfg.TargetDatabase = dbNameOverride
+		}
+		if masksFile != "" {
+			if cfg.Masks, err = conversion.ReadMasksFile(masksFile); err != nil {
+				fmt.Fprintf(os.Stderr, "%v\n", err)
+				os.Exit(1)
+			}
+		}
+		if cfg.Method == "" {
+			cfg.Method = conversion.CloneBackup
+			if len(cfg.Masks) > 0 {
+				cfg.Method = conversion.CloneCopy
+			}
+		}
+		if err := cmd.Clone(flag.Arg(1), cfg, os.Stdout); err != nil {
+			fmt.Fprintf(os.Stderr, "%v\n", err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	if flag.Arg(0) == "smoke-test" {
+		if flag.NArg() > 2 || (driverName != conversion.POSTGRES && driverName != conversion.MYSQL) {
+			fmt.Fprintf(os.Stderr, "Usage: %s -driver=postgres [-instance my-instance] smoke-test [fixtures.sql]\n", os.Args[0])
+			os.Exit(2)
+		}
+		binary, err := os.Executable()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Can't find executable: %v\n", err)
+			os.Exit(1)
+		}
+		cfg := conversion.SmokeTestConfig{Driver: driverName, Fixtures: flag.Arg(1), Instance: instanceOverride, Binary: binary, Args: smokeTestArgs()}
+		if instanceOverride != "" {
+			if cfg.Project, err = conversion.GetProject(); err != nil {
+				fmt.Fprintf(os.Stderr, "Can't get project: %v\n", err)
+				os.Exit(1)
+			}
+		}
+		if err := cmd.SmokeTest(cfg, os.Stdout); err != nil {
+			fmt.Fprintf(os.Stderr, "%v\n", err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	if flag.Arg(0) == "capabilities" {
+		// The driver, target and features can also be given after the
+		// subcommand.
+		fs := flag.NewFlagSet("capabilities", flag.ExitOnError)
+		fs.StringVar(&driverName, "driver", driverName, "driver: source driver")
+		fs.StringVar(&targetDb, "target-db", targetDb, "target-db: target database")
+		fs.StringVar(&spannerFeatures, "spanner-features", spannerFeatures, "spanner-features: Spanner features the target supports")
+		fs.Parse(flag.Args()[1:])
+		if fs.NArg() != 0 {
+			fmt.Fprintf(os.Stderr, "Usage: %s capabilities -driver=postgres\n", os.Args[0])
+			os.Exit(2)
+		}
+		features, err := internal.ParseFeatures(spannerFeatures)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%v\n", err)
+			os.Exit(2)
+		}
+		if err := cmd.Capabilities(driverName, targetDb, features, flagsOf(driverName), os.Stdout); err != nil {
+			fmt.Fprintf(os.Stderr, "%v\n", err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	// Conversion runs end with a single line of JSON summarizing their
+	// outcome, and an exit code automation can branch on (see
+	// internal.RunSummary). Errors are reported by panicking.
+	var summary *internal.RunSummary
+	var notifier *conversion.Notifier
+	defer func() {
+		if r := recover(); r != nil {
+			fmt.Fprintf(os.Stderr, "%v\n", r)
+			if _, ok := r.(runtime.Error); ok {
+				os.Stderr.Write(debug.Stack())
+			}
+			s := internal.FatalSummary(fmt.Errorf("%v", r))
+			summary = &s
+			notifier.Notify(conversion.MigrationFailed, summary, "")
+		}
+		notifier.Close()
+		if summary != nil {
+			fmt.Println(summary.JSON())
+			os.Exit(summary.ExitCode)
+		}
+	}()
+
+	if offline {
+		if webapi {
+			panic(fmt.Errorf("can't use both offline mode and the web interface"))
+		}
+		if err := conversion.CheckOffline(driverName, schemaOnly); err != nil {
+			panic(err)
+		}
+		conversion.DisableNetwork()
+	}
+
 	// Note: the web interface does not use any commandline flags.
 	if webapi {
 		web.WebApp()
@@ -85,7 +666,7 @@ func main() {
 	}
 
 	internal.VerboseInit(verbose)
-	lf, err := conversion.SetupLogFile()
+	lf, err := conversion.SetupLogFile("")
 	if err != nil {
 		fmt.Printf("\nCan't set up log file: %v\n", err)
 		panic(fmt.Errorf("can't set up log file"))
@@ -98,6 +679,57 @@ func main() {
 	if dataOnly && sessionJSON == "" {
 		panic(fmt.Errorf("when using data-only mode, the session must specify the session file to use"))
 	}
+	if scanAnomalies && !(driverName == conversion.POSTGRES || driverName == conversion.MYSQL) {
+		panic(fmt.Errorf("can only scan for anomalies when source is %s or %s (driver: %s)", conversion.POSTGRES, conversion.MYSQL, driverName))
+	}
+	if tightenStrings != 0 && tightenStrings < 1 {
+		panic(fmt.Errorf("the safety factor of tighten-strings must be at least 1 (tighten-strings: %v)", tightenStrings))
+	}
+	if profileRows != 0 && !(driverName == conversion.POSTGRES || driverName == conversion.MYSQL) {
+		panic(fmt.Errorf("can only profile columns when source is %s or %s (driver: %s)", conversion.POSTGRES, conversion.MYSQL, driverName))
+	}
+	if tightenStrings != 0 && !(driverName == conversion.POSTGRES || driverName == conversion.MYSQL) {
+		panic(fmt.Errorf("can only tighten strings when source is %s or %s (driver: %s)", conversion.POSTGRES, conversion.MYSQL, driverName))
+	}
+	if longStrings != "" {
+		if longStrings, err = internal.ParseLongStrings(longStrings); err != nil {
+			panic(err)
+		}
+		if !(driverName == conversion.POSTGRES || driverName == conversion.MYSQL) {
+			panic(fmt.Errorf("can only widen long strings when source is %s or %s (driver: %s)", conversion.POSTGRES, conversion.MYSQL, driverName))
+		}
+	}
+	switch ddlComments {
+	case conversion.DDLCommentsFull, conversion.DDLCommentsMinimal, conversion.DDLCommentsNone:
+	default:
+		panic(fmt.Errorf("unknown ddl-comments %q: accepted values are %s, %s and %s", ddlComments, conversion.DDLCommentsFull, conversion.DDLCommentsMinimal, conversion.DDLCommentsNone))
+	}
+	if phase != "" {
+		if schemaOnly || dataOnly {
+			panic(fmt.Errorf("can't use -phase with schema-only or data-only modes"))
+		}
+		if stateStore == "" || dbNameOverride == "" {
+			panic(fmt.Errorf("-phase needs a state store (see -state) and a database name (see -dbname), shared by all phases"))
+		}
+		// The assess phase is schema conversion.
+		schemaOnly = phase == conversion.AssessPhase
+	} else if stateStore != "" {
+		panic(fmt.Errorf("-state can only be used with -phase"))
+	}
+	if phaseTables != "" && phase != conversion.DataPhase {
+		panic(fmt.Errorf("-tables can only be used with -phase %s", conversion.DataPhase))
+	}
+	if k8sJobsFile != "" {
+		if phase != conversion.AssessPhase {
+			panic(fmt.Errorf("-emit-k8s-jobs can only be used with -phase %s", conversion.AssessPhase))
+		}
+		if k8sImage == "" || instanceOverride == "" {
+			panic(fmt.Errorf("-emit-k8s-jobs needs the image of the jobs (see -k8s-image) and an instance (see -instance)"))
+		}
+		if (driverName == conversion.PGDUMP || driverName == conversion.MYSQLDUMP) && dumpFilePath == "" {
+			panic(fmt.Errorf("-emit-k8s-jobs needs the dump to be given by -dump-file, at a path the jobs can read"))
+		}
+	}
 	if schemaOnly && skipForeignKeys {
 		panic(fmt.Errorf("can't use both schema-only and skip-foreign-keys at once. Foreign Key creation can only be skipped when data migration takes place."))
 	}
@@ -110,9 +742,217 @@ func main() {
 		panic(fmt.Errorf("unkown target-db %s", targetDb))
 	}
 
+	var policies internal.Policies
+	policies.SpecialValues, err = internal.ParseSpecialValuePolicy(specialValues)
+	if err != nil {
+		panic(err)
+	}
+	policies.Oversize, err = internal.ParseOversizePolicy(oversize)
+	if err != nil {
+		panic(err)
+	}
+	policies.Orphans, err = internal.ParseOrphanPolicy(orphans)
+	if err != nil {
+		panic(err)
+	}
+	policies.Duplicates, err = internal.ParseDuplicatePolicy(duplicates)
+	if err != nil {
+		panic(err)
+	}
+	policies.NotNull, policies.NotNullColumns, err = internal.ParseNotNullPolicies(notNull)
+	if err != nil {
+		panic(err)
+	}
+	policies.EmptyStrings, policies.EmptyStringColumns, err = internal.ParseEmptyStringPolicies(emptyStrings)
+	if err != nil {
+		panic(err)
+	}
+	policies.CharPadding, policies.CharPaddingColumns, err = internal.ParseCharPaddingPolicies(charPadding)
+	if err != nil {
+		panic(err)
+	}
+	if maxBadTable < 0 || maxBadTotal < 0 {
+		panic(fmt.Errorf("-max-bad-rows-per-table and -max-bad-rows-total can't be negative"))
+	}
+	policies.ErrorBudget = internal.ErrorBudget{MaxBadRowsPerTable: maxBadTable, MaxBadRowsTotal: maxBadTotal}
+	if masksFile != "" {
+		policies.Masks, err = conversion.ReadMasksFile(masksFile)
+		if err != nil {
+			panic(err)
+		}
+	}
+	if dateRulesFile != "" {
+		policies.DateRules, err = conversion.ReadDateRulesFile(dateRulesFile)
+		if err != nil {
+			panic(err)
+		}
+	}
+	if valueDictsFile != "" {
+		policies.ValueDictionaries, err = conversion.ReadValueDictionariesFile(valueDictsFile)
+		if err != nil {
+			panic(err)
+		}
+	}
+	if maskedDump != "" && len(policies.Masks) == 0 {
+		panic(fmt.Errorf("masked-dump needs the masks to apply (see -masks)"))
+	}
+	features, err := internal.ParseFeatures(spannerFeatures)
+	if err != nil {
+		panic(err)
+	}
+	ordering, err := internal.ParseOrdering(order)
+	if err != nil {
+		panic(err)
+	}
+	models, err := internal.ParseModelLanguages(modelLangs)
+	if err != nil {
+		panic(err)
+	}
+	diagramList, err := internal.ParseDiagramFormats(diagramFormats)
+	if err != nil {
+		panic(err)
+	}
+	diagrams := internal.Diagrams{Formats: diagramList, Source: diagramSource}
+	if reportLayout != "single" && reportLayout != "split" {
+		panic(fmt.Errorf("unknown report layout %q: accepted values are single and split", reportLayout))
+	}
+	layout := conversion.ReportLayout{Split: reportLayout == "split", Manifests: manifests, SARIF: sarif}
+	if reportFilter != "" {
+		for _, t := range strings.Split(reportFilter, ",") {
+			layout.Tables = append(layout.Tables, strings.TrimSpace(t))
+		}
+	}
+	spannerOpts := conversion.SpannerOptions{
+		TransactionTag:      transactionTag,
+		RequestTag:          requestTag,
+		RouteToLeader:       routeToLeader,
+		NumChannels:         numChannels,
+		MinSessions:         minSessions,
+		MaxSessions:         maxSessions,
+		KeepaliveTime:       keepaliveTime,
+		Writes:              writes,
+		WriteMode:           writeMode,
+		LoadProcessingUnits: int32(scaleUnits),
+		ConfirmScaling:      scaleConfirm,
+		KMSKey:              kmsKey,
+		AllowExisting:       allowExisting,
+		BackupExisting:      backupExisting,
+		BackupBeforeCutover: backupCutover,
+		BackupRetention:     backupRetention,
+		Analyze:             analyze,
+		OptimizerVersion:    optimizerVersion,
+	}
+	spannerOpts.Priority, err = conversion.ParsePriority(priority)
+	if err != nil {
+		panic(err)
+	}
+	spannerOpts.Grants, err = conversion.ParseIAMGrants(grants)
+	if err != nil {
+		panic(err)
+	}
+	if tableWrites != "" {
+		if spannerOpts.TableWrites, err = conversion.ReadTableWritesFile(tableWrites); err != nil {
+			panic(err)
+		}
+	}
+	if err = spannerOpts.Validate(targetDb); err != nil {
+		panic(err)
+	}
+	switch target {
+	case conversion.TARGET_SPANNER:
+	case conversion.TargetNone:
+		noSpanner = true
+	default:
+		panic(fmt.Errorf("unknown target %q: accepted values are %s and %s", target, conversion.TARGET_SPANNER, conversion.TargetNone))
+	}
+	if noSpanner {
+		if phase != "" || instanceOverride != "" {
+			panic(fmt.Errorf("-phase and -instance can't be used with -no-spanner"))
+		}
+		if err = conversion.CheckNoSpanner(schemaOnly, spannerOpts); err != nil {
+			panic(err)
+		}
+	}
+	source := conversion.SourceOptions{Replica: sourceReplica, Snapshot: sourceSnapshot, SpillDir: spillDir, SchemaDrift: schemaDrift}
+	if fetchRows < 0 || fetchBytes <= 0 {
+		panic(fmt.Errorf("-fetch-rows can't be negative, and -fetch-bytes must be positive"))
+	}
+	source.Fetch = internal.FetchSize{Rows: fetchRows, Bytes: fetchBytes}
+	if source.Names.Mode, err = internal.ParseNameMappingMode(names); err != nil {
+		panic(err)
+	}
+	if nameDictionary != "" {
+		if source.Names.Dictionary, err = conversion.ReadNameDictionary(nameDictionary); err != nil {
+			panic(err)
+		}
+	}
+	if tableWindows != "" {
+		if source.Windows, err = conversion.ReadTableWindowsFile(tableWindows); err != nil {
+			panic(err)
+		}
+	}
+	if softDelete != "" {
+		if source.SoftDelete, err = internal.ParseSoftDelete(softDelete); err != nil {
+			panic(err)
+		}
+	}
+	if tenant != "" {
+		if source.Tenant, err = internal.ParseTenant(tenant); err != nil {
+			panic(err)
+		}
+	}
+	if incrementalKeys != "" {
+		if schemaOnly || phase != "" {
+			panic(fmt.Errorf("-incremental can't be used with schema-only mode or -phase"))
+		}
+		if source.Incremental, err = internal.ParseIncrementalKeys(incrementalKeys); err != nil {
+			panic(err)
+		}
+	}
+	if staging != "" {
+		if schemaOnly || phase != "" {
+			panic(fmt.Errorf("-staging can't be used with schema-only mode or -phase"))
+		}
+		source.Staging = conversion.Staging{URI: staging, Step: stagingStep}
+		if stagingStep == conversion.StageLoad && !dataOnly {
+			panic(fmt.Errorf("-staging-step %s loads data into an existing database: use it with -data-only", conversion.StageLoad))
+		}
+	}
+	if err = source.Validate(driverName); err != nil {
+		panic(err)
+	}
+	if spillDir != "" {
+		if err = checkSpill(policies); err != nil {
+			panic(err)
+		}
+	}
+
+	notifier, err = conversion.NewNotifier(splitList(notifyWebhooks), splitList(notifySlack), splitList(notifyTopics), progressTopic, os.Stdout)
+	if err != nil {
+		panic(err)
+	}
+	var store conversion.StateStore
+	if phase != "" {
+		if store, err = conversion.OpenStateStore(stateStore); err != nil {
+			panic(err)
+		}
+		if err = conversion.CheckPhase(store, phase); err != nil {
+			panic(err)
+		}
+	}
+
 	input := loadInput(dumpFilePath)
-	ioHelper := &conversion.IOStreams{In: input, Out: os.Stdout}
+	ioHelper := &conversion.IOStreams{In: input, Out: os.Stdout, CacheDir: cacheDir, TUI: tui}
+	if maskedDump != "" {
+		if err := conversion.MaskDump(driverName, policies.Masks, ioHelper, maskedDump); err != nil {
+			panic(err)
+		}
+		return
+	}
 	fmt.Printf("Using driver (source DB): %s target-db: %s\n", driverName, targetDb)
+	if noSpanner {
+		fmt.Println("Not using Spanner: only the schema, session and report files are written")
+	}
 
 	var project, instance string
 	if !schemaOnly {
@@ -133,6 +973,15 @@ func main() {
 		}
 		fmt.Println("Using Cloud Spanner instance:", instance)
 		conversion.PrintPermissionsWarning(driverName, ioHelper.Out)
+		if kmsKey != "" {
+			// Check the key before the (possibly long) schema conversion.
+			cfg, err := conversion.GetInstanceConfig(project, instance)
+			if err != nil {
+				fmt.Printf("\nCan't check KMS key: can't get configuration of instance %s: %v\n", instance, err)
+			} else if err := cfg.CheckKMSKey(kmsKey); err != nil {
+				panic(err)
+			}
+		}
 	}
 
 	now := time.Now()
@@ -145,17 +994,184 @@ func main() {
 		}
 	}
 
+	if notifier != nil {
+		notifier.Database = fmt.Sprintf("projects/%s/instances/%s/databases/%s", project, instance, dbName)
+	}
+
 	// If filePrefix not explicitly set, use dbName.
 	if filePrefix == "" {
 		filePrefix = dbName + "."
 	}
+	workspace, err := conversion.NewWorkspace(outDir, filePrefix, now)
+	if err != nil {
+		panic(err)
+	}
+	if workspace.Dir != "" {
+		fmt.Printf("Writing files to %s\n", workspace.Dir)
+		lf, err := conversion.SetupLogFile(workspace.File(conversion.LogFiles, "harbourbridge.log"))
+		if err != nil {
+			fmt.Printf("\nCan't set up log file: %v\n", err)
+			panic(fmt.Errorf("can't set up log file"))
+		}
+		defer conversion.Close(lf)
+	}
 
+	var dropCols []string
+	if dropColumns != "" {
+		for _, c := range strings.Split(dropColumns, ",") {
+			dropCols = append(dropCols, strings.TrimSpace(c))
+		}
+	}
+	var computedCols []internal.ComputedCol
+	if computedColumns != "" {
+		computedCols, err = conversion.ReadComputedColsFile(computedColumns)
+		if err != nil {
+			panic(err)
+		}
+	}
+	var remodel internal.Remodel
+	if remodelFile != "" {
+		remodel, err = conversion.ReadRemodelFile(remodelFile)
+		if err != nil {
+			panic(err)
+		}
+	}
+	remodel.AutoPartition = remodel.AutoPartition || autoPartition
+	autoMoney, money, err := internal.ParseMoneyColumns(moneyColumns)
+	if err != nil {
+		panic(err)
+	}
+	remodel.AutoMoney = remodel.AutoMoney || autoMoney
+	for c, numeric := range money {
+		if remodel.Money == nil {
+			remodel.Money = make(map[string]bool)
+		}
+		remodel.Money[c] = numeric
+	}
+	autoBools, bools, err := internal.ParseBoolColumns(boolColumns)
+	if err != nil {
+		panic(err)
+	}
+	remodel.AutoBools = remodel.AutoBools || autoBools
+	for c, b := range bools {
+		if remodel.Bools == nil {
+			remodel.Bools = make(map[string]bool)
+		}
+		remodel.Bools[c] = b
+	}
+	autoDropIndexes, indexes, err := internal.ParseDropIndexes(dropIndexes)
+	if err != nil {
+		panic(err)
+	}
+	remodel.AutoDropIndexes = remodel.AutoDropIndexes || autoDropIndexes
+	autoLongKeys, keys, err := internal.ParseLongKeys(longKeys)
+	if err != nil {
+		panic(err)
+	}
+	remodel.AutoLongKeys = remodel.AutoLongKeys || autoLongKeys
+	remodel.LongKeys = append(remodel.LongKeys, keys...)
+	remodel.TrimToLimits = remodel.TrimToLimits || trimToLimits
+	if syntheticKeys != "" {
+		remodel.SyntheticKeys = syntheticKeys
+	}
+	for index, drop := range indexes {
+		if remodel.DropIndexes == nil {
+			remodel.DropIndexes = make(map[string]bool)
+		}
+		remodel.DropIndexes[index] = drop
+	}
+	if plugins != "" {
+		for _, p := range strings.Split(plugins, ",") {
+			if err := conversion.LoadPlugin(strings.TrimSpace(p)); err != nil {
+				panic(err)
+			}
+		}
+	}
+	if tableHook != "" {
+		internal.RegisterPostTableHook(conversion.CommandTableHook(tableHook))
+	}
+	var audit *conversion.AuditLog
+	if auditLog != "" {
+		audit, err = conversion.OpenAuditLog(auditLog, ioHelper.Out)
+		if err != nil {
+			panic(err)
+		}
+		defer audit.Close()
+	}
+	if controlAddr != "" {
+		var stop func()
+		if spannerOpts.Control, stop, err = conversion.ServeControl(controlAddr); err != nil {
+			panic(err)
+		}
+		defer stop()
+		fmt.Fprintf(ioHelper.Out, "Serving control endpoint at http://%s\n", controlAddr)
+	}
+	if phase != "" && phase != conversion.AssessPhase {
+		s, err := cmd.Phase(phase, store, driverName, project, instance, dbName, splitList(phaseTables), skipForeignKeys, dropCols, computedCols, remodel, policies, ordering, layout, spannerOpts, source, audit, notifier, ioHelper, workspace, now)
+		if err != nil {
+			panic(err)
+		}
+		summary = &s
+		return
+	}
 	// TODO (agasheesh@): Collect all the config state in a single struct and pass the same to CommandLine instead of
 	// passing multiple parameters. Config state would be populated by parsing the flags and environment variables.
-	err = cmd.CommandLine(driverName, targetDb, project, instance, dbName, dataOnly, schemaOnly, skipForeignKeys, schemaSampleSize, sessionJSON, ioHelper, filePrefix, now)
+	s, err := cmd.CommandLine(driverName, targetDb, features, project, instance, dbName, dataOnly, schemaOnly, skipForeignKeys, scanAnomalies, sourceFixes, schemaDir, metadataTable, schemaSampleSize, tightenStrings, profileRows, seed, longStrings, ddlComments, sessionJSON, fkNames, dropCols, computedCols, remodel, policies, ordering, models, diagrams, layout, spannerOpts, source, audit, notifier, ioHelper, workspace, now)
 	if err != nil {
 		panic(err)
 	}
+	if phase == conversion.AssessPhase {
+		if err := cmd.RecordAssessment(store, s, workspace, now); err != nil {
+			panic(err)
+		}
+		if k8sJobsFile != "" {
+			cfg := conversion.K8sJobConfig{Database: dbName, Image: k8sImage, ServiceAccount: k8sAccount, Secret: k8sSecret, Args: k8sArgs()}
+			if err := cmd.EmitK8sJobs(store, cfg, k8sShards, k8sJobsFile, ioHelper.Out); err != nil {
+				panic(err)
+			}
+		}
+	}
+	summary = &s
+}
+
+// k8sArgs returns the arguments of the jobs written by -emit-k8s-jobs:
+// the flags set for this run, with the data phase instead of the assess
+// phase.
+func k8sArgs() []string {
+	l := flagArgs("phase", "emit-k8s-jobs", "k8s-shards", "k8s-image", "k8s-service-account", "k8s-secret")
+	return append(l, "-phase="+conversion.DataPhase)
+}
+
+// smokeTestArgs returns the arguments of the phases run by the smoke-test
+// subcommand: the flags set for this run, except those selecting the
+// source, the database and the phase, which the smoke test sets.
+func smokeTestArgs() []string {
+	return flagArgs("driver", "instance", "dbname", "phase", "state", "tables", "replica", "emit-k8s-jobs", "k8s-shards", "k8s-image", "k8s-service-account", "k8s-secret")
+}
+
+// flagArgs returns the flags set for this run as arguments, except skip.
+func flagArgs(skip ...string) []string {
+	var l []string
+	flag.Visit(func(f *flag.Flag) {
+		for _, s := range skip {
+			if f.Name == s {
+				return
+			}
+		}
+		l = append(l, "-"+f.Name+"="+f.Value.String())
+	})
+	return l
+}
+
+// splitList splits a comma-separated list, ignoring spaces around items.
+func splitList(s string) []string {
+	var l []string
+	if s != "" {
+		for _, x := range strings.Split(s, ",") {
+			l = append(l, strings.TrimSpace(x))
+		}
+	}
+	return l
 }
 
 // Load the dump file if parameter has been passed by the user.
@@ -0,0 +1,137 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package mysql
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/cloudspannerecosystem/harbourbridge/internal"
+)
+
+// ScanAnomalies scans the source database for data that will cause
+// problems during data conversion (see internal.AnomalyKind), so that
+// it can be fixed before any data is loaded. Only the columns and
+// foreign keys of tables in conv.SrcSchema are scanned.
+//
+// Only columns with a UTF-8 character set are scanned for invalid
+// UTF-8: values in other character sets are converted by MySQL.
+func ScanAnomalies(conv *internal.Conv, db *sql.DB, dbName string) ([]internal.Anomaly, error) {
+	tables, err := getTables(db, dbName)
+	if err != nil {
+		return nil, err
+	}
+	var anomalies []internal.Anomaly
+	add := func(table, col string, kind internal.AnomalyKind, q string, count func(*sql.DB, string) (int64, error)) {
+		n, err := count(db, q)
+		if err != nil {
+			conv.Unexpected(fmt.Sprintf("Couldn't scan %s for %s: %s", table, kind, err))
+			return
+		}
+		if n > 0 {
+			anomalies = append(anomalies, internal.Anomaly{Table: table, Column: col, Kind: kind, Count: n})
+		}
+	}
+	for _, t := range tables {
+		srcTable := t.name
+		srcSchema, ok := conv.SrcSchema[srcTable]
+		if !ok {
+			continue
+		}
+		from := fmt.Sprintf("`%s`.`%s`", t.schema, t.name)
+		cols := internal.AnomalyColumns(conv, srcTable)
+		utf8Cols, err := getUTF8Columns(db, t)
+		if err != nil {
+			conv.Unexpected(fmt.Sprintf("Couldn't get character sets of columns of %s: %s", srcTable, err))
+		}
+		for _, c := range cols[internal.InvalidUTF8] {
+			if utf8Cols[c] {
+				add(srcTable, c, internal.InvalidUTF8, fmt.Sprintf("SELECT CAST(`%s` AS BINARY) FROM %s WHERE `%s` IS NOT NULL;", c, from, c), countInvalidUTF8)
+			}
+		}
+		for _, c := range cols[internal.NumericOutOfRange] {
+			add(srcTable, c, internal.NumericOutOfRange, fmt.Sprintf("SELECT COUNT(*) FROM %s WHERE ABS(`%s`) >= 1e29 OR `%s` <> ROUND(`%s`, 9);", from, c, c, c), countRows)
+		}
+		for _, c := range cols[internal.InvalidDate] {
+			// MySQL's date range is within Spanner's, so we only need
+			// to look for zero dates (and dates with a zero month or day).
+			add(srcTable, c, internal.InvalidDate, fmt.Sprintf("SELECT COUNT(*) FROM %s WHERE YEAR(`%s`) = 0 OR MONTH(`%s`) = 0 OR DAYOFMONTH(`%s`) = 0;", from, c, c, c), countRows)
+		}
+		for _, fk := range srcSchema.ForeignKeys {
+			if len(fk.Columns) != len(fk.ReferColumns) {
+				continue
+			}
+			var notNull, match []string
+			for i, c := range fk.Columns {
+				notNull = append(notNull, fmt.Sprintf("c.`%s` IS NOT NULL", c))
+				match = append(match, fmt.Sprintf("p.`%s` = c.`%s`", fk.ReferColumns[i], c))
+			}
+			q := fmt.Sprintf("SELECT COUNT(*) FROM %s c WHERE %s AND NOT EXISTS (SELECT 1 FROM `%s`.`%s` p WHERE %s);",
+				from, strings.Join(notNull, " AND "), t.schema, fk.ReferTable, strings.Join(match, " AND "))
+			add(srcTable, fk.Name, internal.OrphanedRow, q, countRows)
+		}
+	}
+	return anomalies, nil
+}
+
+// getUTF8Columns returns the columns of table that use a UTF-8
+// character set.
+func getUTF8Columns(db *sql.DB, table schemaAndName) (map[string]bool, error) {
+	q := "SELECT column_name FROM information_schema.COLUMNS WHERE table_schema = ? AND table_name = ? AND character_set_name LIKE 'utf8%';"
+	rows, err := db.Query(q, table.schema, table.name)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	cols := make(map[string]bool)
+	var col string
+	for rows.Next() {
+		if err := rows.Scan(&col); err != nil {
+			return cols, err
+		}
+		cols[col] = true
+	}
+	return cols, nil
+}
+
+// countRows runs a 'SELECT COUNT(*)' query.
+func countRows(db *sql.DB, q string) (int64, error) {
+	var n int64
+	err := db.QueryRow(q).Scan(&n)
+	return n, err
+}
+
+// countInvalidUTF8 runs a query that returns a single column of raw
+// bytes, and counts the values that aren't valid UTF-8.
+func countInvalidUTF8(db *sql.DB, q string) (int64, error) {
+	rows, err := db.Query(q)
+	if err != nil {
+		return 0, err
+	}
+	defer rows.Close()
+	var n int64
+	var b sql.RawBytes
+	for rows.Next() {
+		if err := rows.Scan(&b); err != nil {
+			return n, err
+		}
+		if !utf8.Valid(b) {
+			n++
+		}
+	}
+	return n, rows.Err()
+}
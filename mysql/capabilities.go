@@ -0,0 +1,73 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package mysql
+
+import (
+	"github.com/cloudspannerecosystem/harbourbridge/internal"
+	"github.com/cloudspannerecosystem/harbourbridge/schema"
+)
+
+// sourceTypes are the types that toSpannerType maps (TestSourceTypes
+// checks that they match its cases). Other types are mapped to
+// STRING(MAX).
+var sourceTypes = []string{
+	"bigint", "binary", "bit", "blob", "bool", "boolean", "char", "date",
+	"datetime", "decimal", "double", "enum", "float", "int", "integer",
+	"json", "longblob", "longtext", "mediumblob", "mediumint", "mediumtext",
+	"numeric", "set", "smallint", "text", "time", "timestamp", "tinyblob",
+	"tinyint", "tinytext", "varbinary", "varchar", "year",
+}
+
+// constructs are samples of the constructs, other than tables and
+// columns, found in mysqldump files.
+var constructs = []internal.Construct{
+	{Name: "views", Dump: "CREATE VIEW v AS SELECT 1;\n"},
+	{Name: "triggers", Dump: "CREATE TABLE t (a int PRIMARY KEY);\nDELIMITER ;;\n/*!50003 CREATE*/ /*!50003 TRIGGER tr BEFORE INSERT ON t FOR EACH ROW SET NEW.a = 1 */;;\nDELIMITER ;\n"},
+	{Name: "stored procedures", Dump: "DELIMITER ;;\nCREATE PROCEDURE p() BEGIN SELECT 1; END ;;\nDELIMITER ;\n"},
+	{Name: "functions", Dump: "DELIMITER ;;\nCREATE FUNCTION f() RETURNS int DETERMINISTIC BEGIN RETURN 1; END ;;\nDELIMITER ;\n"},
+	{Name: "auto_increment columns", Dump: "CREATE TABLE t (a int NOT NULL AUTO_INCREMENT, PRIMARY KEY (a)) AUTO_INCREMENT=7;\n"},
+	{Name: "column defaults", Dump: "CREATE TABLE t (a int PRIMARY KEY, b int DEFAULT 1);\n"},
+	{Name: "check constraints", Dump: "CREATE TABLE t (a int PRIMARY KEY, CHECK (a > 0));\n"},
+	{Name: "foreign keys", Dump: "CREATE TABLE t (a int PRIMARY KEY);\nCREATE TABLE u (a int PRIMARY KEY, FOREIGN KEY (a) REFERENCES t (a));\n"},
+	{Name: "unique constraints", Dump: "CREATE TABLE t (a int PRIMARY KEY, b int, UNIQUE KEY u (b));\n"},
+	{Name: "indexes", Dump: "CREATE TABLE t (a int PRIMARY KEY, b int, KEY i (b));\n"},
+	{Name: "index prefix lengths", Dump: "CREATE TABLE t (a int PRIMARY KEY, b varchar(100), KEY i (b(10)));\n"},
+}
+
+// Capabilities returns how MySQL types are mapped to targetDb when it
+// supports features, and which constructs are dropped.
+func Capabilities(targetDb string, features internal.Features) (internal.Capabilities, error) {
+	newConv := func() *internal.Conv {
+		conv := internal.MakeConv()
+		conv.TargetDb = targetDb
+		conv.Features = features
+		return conv
+	}
+	var types []schema.Type
+	for _, t := range sourceTypes {
+		types = append(types, schema.Type{Name: t})
+	}
+	types = append(types, schema.Type{Name: "tinyint", Mods: []int64{1}}, schema.Type{Name: "varchar", Mods: []int64{255}}, schema.Type{Name: "char", Mods: []int64{10}})
+	mappings, err := internal.MapTypes(newConv(), types, schemaToDDL)
+	if err != nil {
+		return internal.Capabilities{}, err
+	}
+	return internal.Capabilities{
+		Target:   targetDb,
+		Features: features.String(),
+		Types:    mappings,
+		Dropped:  internal.DroppedConstructs(newConv, constructs, ProcessMySQLDump),
+	}, nil
+}
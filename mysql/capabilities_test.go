@@ -0,0 +1,78 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package mysql
+
+import (
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"strconv"
+	"testing"
+
+	"github.com/cloudspannerecosystem/harbourbridge/internal"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSourceTypes(t *testing.T) {
+	assert.ElementsMatch(t, caseLabels(t, "toddl.go", "toSpannerType"), sourceTypes)
+}
+
+func TestCapabilities(t *testing.T) {
+	c, err := Capabilities("spanner", internal.DefaultFeatures())
+	assert.Nil(t, err)
+	types := make(map[string]string)
+	for _, m := range c.Types {
+		types[m.Source] = m.Spanner
+	}
+	assert.Equal(t, "BOOL", types["tinyint(1)"])
+	assert.Equal(t, "INT64", types["tinyint"])
+	assert.Equal(t, "FLOAT32", types["float"])
+	assert.Contains(t, c.Dropped, "views")
+	assert.Contains(t, c.Dropped, "triggers")
+	assert.Contains(t, c.Dropped, "check constraints")
+	assert.NotContains(t, c.Dropped, "indexes")
+	assert.NotContains(t, c.Dropped, "auto_increment columns")
+
+	c, err = Capabilities("spanner", internal.Features{})
+	assert.Nil(t, err)
+	assert.Contains(t, c.Dropped, "auto_increment columns")
+}
+
+// caseLabels returns the string constants of the case clauses of function
+// fn of file.
+func caseLabels(t *testing.T, file, fn string) []string {
+	f, err := parser.ParseFile(token.NewFileSet(), file, nil, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var l []string
+	for _, d := range f.Decls {
+		if fd, ok := d.(*ast.FuncDecl); !ok || fd.Name.Name != fn {
+			continue
+		}
+		ast.Inspect(d, func(n ast.Node) bool {
+			if cc, ok := n.(*ast.CaseClause); ok {
+				for _, e := range cc.List {
+					if lit, ok := e.(*ast.BasicLit); ok && lit.Kind == token.STRING {
+						s, _ := strconv.Unquote(lit.Value)
+						l = append(l, s)
+					}
+				}
+			}
+			return true
+		})
+	}
+	return l
+}
@@ -17,7 +17,6 @@ package mysql
 import (
 	"fmt"
 	"math/big"
-	"math/bits"
 	"strconv"
 	"strings"
 	"time"
@@ -43,6 +42,7 @@ func ProcessDataRow(conv *internal.Conv, srcTable string, srcCols []string, srcS
 		conv.Unexpected(fmt.Sprintf("Error while converting data: %s\n", err))
 		conv.StatsAddBadRow(srcTable, conv.DataMode())
 		conv.CollectBadRow(srcTable, srcCols, vals)
+		conv.GroupBadRow(srcTable, err, srcCols, vals)
 	} else {
 		conv.WriteRow(srcTable, spTable, cvtCols, cvtVals)
 	}
@@ -60,6 +60,9 @@ func ConvertData(conv *internal.Conv, srcTable string, srcCols []string, srcSche
 	}
 	for i, spCol := range spCols {
 		srcCol := srcCols[i]
+		if spCol == "" { // Column is not migrated (see internal.DropColumns).
+			continue
+		}
 		// Skip columns with 'NULL' values. When processing data rows from mysqldump, these values
 		// are represented as nil (by pingcap/tidb/types/parser_driver's ValueExpr), which is
 		// converted to the string '<nil>'. When processing data rows obtained from the MySQL driver,
@@ -80,16 +83,14 @@ func ConvertData(conv *internal.Conv, srcTable string, srcCols []string, srcSche
 			x, err = convScalar(conv, spColDef.T, srcColDef.Type.Name, conv.TimezoneOffset, vals[i])
 		}
 		if err != nil {
-			return "", []string{}, []interface{}{}, err
+			return "", []string{}, []interface{}{}, &internal.ColumnError{Col: srcCol, Val: vals[i], Err: err}
 		}
 		v = append(v, x)
 		c = append(c, spCol)
 	}
-	if aux, ok := conv.SyntheticPKeys[spTable]; ok {
-		c = append(c, aux.Col)
-		v = append(v, int64(bits.Reverse64(uint64(aux.Sequence))))
-		aux.Sequence++
-		conv.SyntheticPKeys[spTable] = aux
+	if col, val, ok := conv.NextSyntheticPKey(spTable); ok {
+		c = append(c, col)
+		v = append(v, val)
 	}
 	return spTable, c, v, nil
 }
@@ -106,18 +107,24 @@ func convScalar(conv *internal.Conv, spannerType ddl.Type, srcTypeName string, T
 	// We do not expect mysqldump to generate such output.
 	switch spannerType.Name {
 	case ddl.Bool:
+		if srcTypeName == "char" {
+			// A CHAR(1) column holding flags (see internal.Remodel.Bools).
+			return internal.ParseCharFlag(val)
+		}
 		return convBool(conv, val)
 	case ddl.Bytes:
 		return convBytes(val)
 	case ddl.Date:
 		return convDate(val)
+	case ddl.Float32:
+		return convFloat32(val)
 	case ddl.Float64:
 		return convFloat64(val)
 	case ddl.Int64:
 		return convInt64(val)
 	case ddl.Numeric:
 		return convNumeric(val)
-	case ddl.String:
+	case ddl.String, ddl.JSON:
 		return val, nil
 	case ddl.Timestamp:
 		return convTimestamp(srcTypeName, TimezoneOffset, val)
@@ -160,6 +167,16 @@ func convDate(val string) (civil.Date, error) {
 	return d, err
 }
 
+// convFloat32 converts val to a FLOAT32 value. It is returned as a float64,
+// since that's what the Spanner client accepts.
+func convFloat32(val string) (float64, error) {
+	f, err := strconv.ParseFloat(val, 32)
+	if err != nil {
+		return f, fmt.Errorf("can't convert to float32: %w", err)
+	}
+	return f, err
+}
+
 func convFloat64(val string) (float64, error) {
 	f, err := strconv.ParseFloat(val, 64)
 	if err != nil {
@@ -81,11 +81,16 @@ func TestConvertData(t *testing.T) {
 		{"bool 5", ddl.Type{Name: ddl.Bool}, "", "5", true},
 		{"bool -128", ddl.Type{Name: ddl.Bool}, "", "-128", true},
 		{"bool 127", ddl.Type{Name: ddl.Bool}, "", "127", true},
+		{"bool char Y", ddl.Type{Name: ddl.Bool}, "char", "Y", true},
+		{"bool char n", ddl.Type{Name: ddl.Bool}, "char", "n", false},
+		{"bool char 1", ddl.Type{Name: ddl.Bool}, "char", "1", true},
 		{"bytes", ddl.Type{Name: ddl.Bytes, Len: ddl.MaxLength}, "", string([]byte{137, 80}), []byte{0x89, 0x50}}, // need some other approach to testblob type
 		{"date", ddl.Type{Name: ddl.Date}, "", "2019-10-29", getDate("2019-10-29")},
+		{"float32", ddl.Type{Name: ddl.Float32}, "", "42.6", float64(float32(42.6))},
 		{"float64", ddl.Type{Name: ddl.Float64}, "", "42.6", float64(42.6)},
 		{"int64", ddl.Type{Name: ddl.Int64}, "", "42", int64(42)},
 		{"string", ddl.Type{Name: ddl.String, Len: ddl.MaxLength}, "", "eh", "eh"},
+		{"json", ddl.Type{Name: ddl.JSON}, "", `{"a": [1, 2]}`, `{"a": [1, 2]}`},
 		{"datetime", ddl.Type{Name: ddl.Timestamp}, "datetime", "2019-10-29 05:30:00", getTimeWithoutTimezone(t, "2019-10-29 05:30:00")},
 		{"timestamp", ddl.Type{Name: ddl.Timestamp}, "timestamp", "2019-10-29 05:30:00", getTime(t, "2019-10-29T05:30:00+05:30")},
 		{"string array(set)", ddl.Type{Name: ddl.String, Len: ddl.MaxLength, IsArray: true}, "", "1,Travel,3,Dance", []spanner.NullString{
@@ -0,0 +1,51 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package mysql
+
+import (
+	"fmt"
+
+	"github.com/cloudspannerecosystem/harbourbridge/internal"
+)
+
+// sourceFixer gives MySQL statements that fix issues of the tables of a
+// database (see internal.SourceFixes).
+type sourceFixer struct {
+	dbName string
+}
+
+// SourceFixer returns an internal.SourceFixer for the tables of dbName.
+// MySQL doesn't report index usage, so it never drops indexes.
+func SourceFixer(dbName string) internal.SourceFixer {
+	return sourceFixer{dbName: dbName}
+}
+
+func (f sourceFixer) from(srcTable string) string {
+	return fmt.Sprintf("`%s`.`%s`", f.dbName, srcTable)
+}
+
+func (f sourceFixer) AddPrimaryKey(srcTable, col string) string {
+	return fmt.Sprintf("ALTER TABLE %s ADD COLUMN `%s` BIGINT NOT NULL AUTO_INCREMENT PRIMARY KEY;", f.from(srcTable), col)
+}
+
+func (f sourceFixer) ClearInvalidDates(srcTable, col string) string {
+	// As for ScanAnomalies, matches zero dates and dates with a zero
+	// month or day.
+	return fmt.Sprintf("UPDATE %s SET `%s` = NULL WHERE YEAR(`%s`) = 0 OR MONTH(`%s`) = 0 OR DAYOFMONTH(`%s`) = 0;", f.from(srcTable), col, col, col, col)
+}
+
+func (f sourceFixer) DropIndex(srcTable, index string) string {
+	return fmt.Sprintf("DROP INDEX `%s` ON %s;", index, f.from(srcTable))
+}
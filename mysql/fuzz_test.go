@@ -0,0 +1,40 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build go1.18
+// +build go1.18
+
+package mysql
+
+import (
+	"testing"
+)
+
+// FuzzProcessMySQLDump checks that malformed mysqldump files are
+// reported as errors or bad rows, rather than panicking. Run it with
+//
+//	go test ./mysql -run '^$' -fuzz FuzzProcessMySQLDump
+//
+// Inputs that failed are saved in testdata/fuzz, and are then run as
+// regression tests by go test.
+func FuzzProcessMySQLDump(f *testing.F) {
+	f.Add([]byte("CREATE TABLE t (a int NOT NULL AUTO_INCREMENT, b varchar(10), c json, PRIMARY KEY (a)) AUTO_INCREMENT=7;\n" +
+		"INSERT INTO t VALUES (1,'x\\'y',NULL),(2,'\\\\',-3);\n"))
+	f.Add([]byte("CREATE TABLE p (id int PRIMARY KEY, g point);\nALTER TABLE p ADD CONSTRAINT fk FOREIGN KEY (id) REFERENCES q (id);\n" +
+		"CREATE INDEX i ON p (id(4));\n"))
+	f.Add([]byte("/*!50003 CREATE*/ /*!50003 TRIGGER tr BEFORE INSERT ON t FOR EACH ROW SET NEW.a = 1 */;;\n"))
+	f.Fuzz(func(t *testing.T, data []byte) {
+		runProcessMySQLDump(string(data))
+	})
+}
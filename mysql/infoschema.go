@@ -19,6 +19,7 @@ import (
 	"fmt"
 	"sort"
 	"strings"
+	"time"
 
 	"github.com/cloudspannerecosystem/harbourbridge/internal"
 	"github.com/cloudspannerecosystem/harbourbridge/schema"
@@ -29,21 +30,84 @@ import (
 // ProcessInfoSchema performs schema conversion for source database
 // 'db'. Information schema tables are a broadly supported ANSI standard,
 // and we use them to obtain source database's schema information.
+// Queries that fail with transient errors are retried (see
+// discoveryRetry). Tables whose schema still can't be read are skipped,
+// and reported as unexpected conditions. If conv spills tables to disk
+// (see internal.Conv.SpillTo), each table is converted and spilled as
+// soon as its schema is read.
 func ProcessInfoSchema(conv *internal.Conv, db *sql.DB, dbName string) error {
-	tables, err := getTables(db, dbName)
+	var tables []schemaAndName
+	err := discoveryRetry.Do(func() (err error) {
+		tables, err = getTables(db, dbName)
+		return err
+	})
 	if err != nil {
 		return err
 	}
+	var next map[string]int64
+	if conv.Spilling() {
+		// AUTO_INCREMENT values are needed before tables are spilled.
+		if err := discoveryRetry.Do(func() (err error) {
+			next, err = autoIncrements(db, dbName)
+			return err
+		}); err != nil {
+			return err
+		}
+		// As in schemaToDDL, reserve the names of all tables before
+		// allocating index and foreign key names.
+		for _, t := range tables {
+			if spTable, err := internal.GetSpannerTable(conv, t.name); err == nil {
+				conv.ReserveName(spTable)
+			}
+		}
+	}
 	for _, t := range tables {
-		if err := processTable(conv, db, t); err != nil {
+		if err := discoveryRetry.Do(func() error { return processTable(conv, db, t) }); err != nil {
+			conv.Unexpected(fmt.Sprintf("Skipped table %s: %s", t.name, err))
+			continue
+		}
+		if conv.Spilling() {
+			if n, ok := next[t.name]; ok {
+				addAutoIncrementSequence(conv, t.name, n)
+			}
+			if err := spillTable(conv, t.name); err != nil {
+				return err
+			}
+		}
+	}
+	if !conv.Spilling() {
+		if err := discoveryRetry.Do(func() error { return getAutoIncrements(conv, db, dbName) }); err != nil {
 			return err
 		}
 	}
-	schemaToDDL(conv)
+	if err := discoveryRetry.Do(func() error { return getTriggers(conv, db, dbName) }); err != nil {
+		conv.Unexpected(err.Error())
+	}
+	if conv.Spilling() {
+		if err := conv.ResolveSpilledRefs(); err != nil {
+			return err
+		}
+		conv.AddSequences()
+		return nil
+	}
+	if err := schemaToDDL(conv); err != nil {
+		return err
+	}
 	conv.AddPrimaryKeys()
+	conv.AddSequences()
 	return nil
 }
 
+// spillTable converts the schema of srcTable, the only table of
+// conv.SrcSchema, and spills it.
+func spillTable(conv *internal.Conv, srcTable string) error {
+	if err := schemaToDDL(conv); err != nil {
+		return err
+	}
+	conv.AddPrimaryKeys()
+	return conv.SpillTable(srcTable)
+}
+
 // ProcessSQLData performs data conversion for source database
 // 'db'. For each table, we extract data using a "SELECT (colNamesList)" query,
 // convert the data to Spanner data (based on the source and Spanner
@@ -52,7 +116,7 @@ func ProcessInfoSchema(conv *internal.Conv, db *sql.DB, dbName string) error {
 //
 // Using database/sql library we pass *sql.RawBytes to rows.scan.
 // RawBytes is a byte slice and values can be easily converted to string.
-func ProcessSQLData(conv *internal.Conv, db *sql.DB, dbName string) {
+func ProcessSQLData(conv *internal.Conv, db internal.Queryer, dbName string) {
 	// TODO: refactor to use the set of tables computed by
 	// ProcessInfoSchema instead of computing them again.
 	tables, err := getTables(db, dbName)
@@ -60,66 +124,175 @@ func ProcessSQLData(conv *internal.Conv, db *sql.DB, dbName string) {
 		conv.Unexpected(fmt.Sprintf("Couldn't get list of table: %s", err))
 		return
 	}
+	// Tables with a time window are read in the order their windows open
+	// (see internal.Conv.SetTableWindows).
+	now := time.Now()
+	sort.SliceStable(tables, func(i, j int) bool {
+		return conv.WindowDelay(tables[i].name, now) < conv.WindowDelay(tables[j].name, now)
+	})
 	for _, t := range tables {
-		srcTable := t.name
-		srcSchema, ok := conv.SrcSchema[srcTable]
-		if !ok {
-			conv.Stats.BadRows[srcTable] += conv.Stats.Rows[srcTable]
-			conv.Unexpected(fmt.Sprintf("Can't get schemas for table %s", srcTable))
+		// Tables that had no rows when they were counted aren't read.
+		if conv.SkipData(t.name) || conv.EmptyTable(t.name) {
 			continue
 		}
-		srcCols := srcSchema.ColNames
-		if len(srcCols) == 0 {
-			conv.Unexpected(fmt.Sprintf("Couldn't get source columns for table %s ", t.name))
+		conv.WaitForWindow(t.name)
+		// Only the schema of the table being converted is loaded.
+		if err := conv.LoadTable(t.name); err != nil {
+			conv.Unexpected(err.Error())
 			continue
 		}
-		colNameList := buildColNameList(srcSchema, srcCols)
-		// MySQL schema and name can be arbitrary strings.
-		// Ideally we would pass schema/name as a query parameter,
-		// but MySQL doesn't support this. So we quote it instead.
-		q := fmt.Sprintf("SELECT %s FROM `%s`.`%s`;", colNameList, t.schema, t.name)
-		rows, err := db.Query(q)
-		if err != nil {
-			conv.Unexpected(fmt.Sprintf("Couldn't get data for table %s : err = %s", t.name, err))
-			continue
+		processTableData(conv, db, t)
+		conv.UnloadTable(t.name)
+	}
+}
+
+// processTableData performs data conversion for table t (see
+// ProcessSQLData).
+func processTableData(conv *internal.Conv, db internal.Queryer, t schemaAndName) {
+	srcTable := t.name
+	srcSchema, ok := conv.SrcSchema[srcTable]
+	if !ok {
+		conv.StatsAddTableBadRows(srcTable)
+		conv.Unexpected(fmt.Sprintf("Can't get schemas for table %s", srcTable))
+		return
+	}
+	srcCols := srcSchema.ColNames
+	if len(srcCols) == 0 {
+		conv.Unexpected(fmt.Sprintf("Couldn't get source columns for table %s ", t.name))
+		return
+	}
+	colNameList := buildColNameList(srcSchema, srcCols, conv.SrcVersion)
+	// MySQL schema and name can be arbitrary strings.
+	// Ideally we would pass schema/name as a query parameter,
+	// but MySQL doesn't support this. So we quote it instead.
+	from := fmt.Sprintf("`%s`.`%s`", t.schema, t.name)
+	if col, _, ok := conv.IncrementalKey(srcTable); ok {
+		// The watermark is read before the rows, so that rows changed
+		// while they are read are read again by the next load.
+		var watermark sql.NullString
+		if err := db.QueryRow(fmt.Sprintf("SELECT CAST(MAX(`%s`) AS CHAR) FROM `%s`.`%s`;", col, t.schema, t.name)).Scan(&watermark); err != nil {
+			conv.Unexpected(fmt.Sprintf("Couldn't get watermark of table %s: %s", srcTable, err))
+			return
 		}
-		defer rows.Close()
-		srcCols, _ = rows.Columns()
-		spTable, err := internal.GetSpannerTable(conv, srcTable)
-		if err != nil {
-			conv.Unexpected(fmt.Sprintf("Couldn't get spanner table : %s", err))
-			continue
+		conv.SetWatermark(srcTable, watermark.String)
+	}
+	where, args := rowFilter(conv, t.schema, srcTable, false)
+	conv.StartTable(srcTable)
+	conv.RecordSnapshot(srcTable)
+	f, batched := conv.Fetcher(srcTable)
+	if !batched {
+		processRows(conv, db, t, fmt.Sprintf("SELECT %s FROM %s%s;", colNameList, from, where), args, nil)
+		return
+	}
+	// Tables read in batches are read in primary key order, each batch
+	// starting after the primary key of the last row of the previous one.
+	var keys []string
+	for _, k := range srcSchema.PrimaryKeys {
+		keys = append(keys, fmt.Sprintf("`%s`", k.Column))
+	}
+	var last []string
+	for conv.Aborted() == nil {
+		w, a := where, args
+		if last != nil {
+			for _, k := range last {
+				a = append(a, k)
+			}
+			cond := fmt.Sprintf("(%s) > (%s)", strings.Join(keys, ", "), strings.TrimSuffix(strings.Repeat("?, ", len(last)), ", "))
+			if w == "" {
+				w = " WHERE " + cond
+			} else {
+				w += " AND " + cond
+			}
+		}
+		q := fmt.Sprintf("SELECT %s FROM %s%s ORDER BY %s LIMIT %d;", colNameList, from, w, strings.Join(keys, ", "), f.Rows())
+		n, bytes, k, ok := processRows(conv, db, t, q, a, srcSchema.PrimaryKeys)
+		if !ok || n < f.Rows() {
+			return
+		}
+		f.Done(n, bytes)
+		last = k
+	}
+}
+
+// processRows converts the rows of table t returned by query q. It returns
+// the number of rows read, their size in bytes, the values of the key
+// columns of the last row (if t is read in batches), and false if the rows
+// couldn't be read.
+func processRows(conv *internal.Conv, db internal.Queryer, t schemaAndName, q string, args []interface{}, keys []schema.Key) (int, int64, []string, bool) {
+	srcTable := t.name
+	srcSchema := conv.SrcSchema[srcTable]
+	rows, err := db.Query(q, args...)
+	if err != nil {
+		conv.Unexpected(fmt.Sprintf("Couldn't get data for table %s : err = %s", t.name, err))
+		return 0, 0, nil, false
+	}
+	defer rows.Close()
+	srcCols, _ := rows.Columns()
+	spTable, err := internal.GetSpannerTable(conv, srcTable)
+	if err != nil {
+		conv.Unexpected(fmt.Sprintf("Couldn't get spanner table : %s", err))
+		return 0, 0, nil, false
+	}
+	spCols, err := internal.GetSpannerCols(conv, srcTable, srcCols)
+	if err != nil {
+		conv.Unexpected(fmt.Sprintf("Couldn't get spanner columns for table %s : err = %s", t.name, err))
+		return 0, 0, nil, false
+	}
+	spSchema, ok := conv.DataSchema(spTable)
+	if !ok {
+		conv.StatsAddTableBadRows(srcTable)
+		conv.Unexpected(fmt.Sprintf("Can't get schemas for table %s", srcTable))
+		return 0, 0, nil, false
+	}
+	colIndex := make(map[string]int)
+	for i, c := range srcCols {
+		colIndex[c] = i
+	}
+	v, scanArgs := buildVals(len(srcCols))
+	var n int
+	var bytes int64
+	var last []string
+	for rows.Next() {
+		if conv.Aborted() != nil {
+			break
 		}
-		spCols, err := internal.GetSpannerCols(conv, srcTable, srcCols)
+		n++
+		// get RawBytes from data.
+		err = rows.Scan(scanArgs...)
 		if err != nil {
-			conv.Unexpected(fmt.Sprintf("Couldn't get spanner columns for table %s : err = %s", t.name, err))
+			conv.Unexpected(fmt.Sprintf("Couldn't process sql data row: %s", err))
+			// Scan failed, so we don't have any data to add to bad rows.
+			conv.StatsAddBadRow(srcTable, conv.DataMode())
+			if len(keys) > 0 {
+				// Without the key of the row, the next batch can't
+				// start after it.
+				return n, bytes, last, false
+			}
 			continue
 		}
-		spSchema, ok := conv.SpSchema[spTable]
-		if !ok {
-			conv.Stats.BadRows[srcTable] += conv.Stats.Rows[srcTable]
-			conv.Unexpected(fmt.Sprintf("Can't get schemas for table %s", srcTable))
-			continue
+		values := valsToStrings(v)
+		for _, val := range v {
+			bytes += int64(len(val))
 		}
-		v, scanArgs := buildVals(len(srcCols))
-		for rows.Next() {
-			// get RawBytes from data.
-			err = rows.Scan(scanArgs...)
-			if err != nil {
-				conv.Unexpected(fmt.Sprintf("Couldn't process sql data row: %s", err))
-				// Scan failed, so we don't have any data to add to bad rows.
-				conv.StatsAddBadRow(srcTable, conv.DataMode())
-				continue
+		if len(keys) > 0 {
+			last = nil
+			for _, k := range keys {
+				last = append(last, values[colIndex[k.Column]])
 			}
-			values := valsToStrings(v)
-			ProcessDataRow(conv, srcTable, srcCols, srcSchema, spTable, spCols, spSchema, values)
 		}
+		ProcessDataRow(conv, srcTable, srcCols, srcSchema, spTable, spCols, spSchema, values)
 	}
+	return n, bytes, last, true
 }
 
 // Building list of column names to support mysql spatial datatypes instead of
-// using 'SELECT *' because spatial columns will be fetched using ST_AsText(colName).
-func buildColNameList(srcSchema schema.Table, srcColName []string) string {
+// using 'SELECT *' because spatial columns will be fetched using ST_AsText(colName)
+// (AsText before MySQL 5.6, which added ST_AsText).
+func buildColNameList(srcSchema schema.Table, srcColName []string, version internal.ServerVersion) string {
+	asText := "ST_AsText"
+	if version.Product == "MySQL" && !version.AtLeast(5, 6) {
+		asText = "AsText"
+	}
 	var srcColTypes []string
 	var colList, colTmpName string
 	for _, colName := range srcColName {
@@ -128,7 +301,7 @@ func buildColNameList(srcSchema schema.Table, srcColName []string) string {
 		srcColTypes = append(srcColTypes, srcSchema.ColDefs[colName].Type.Name)
 		for _, spatial := range MysqlSpatialDataTypes {
 			if strings.Contains(strings.ToLower(srcSchema.ColDefs[colName].Type.Name), spatial) {
-				colTmpName = "ST_AsText" + "(" + colTmpName + ")" + colTmpName
+				colTmpName = asText + "(" + colTmpName + ")" + colTmpName
 				break
 			}
 		}
@@ -137,34 +310,101 @@ func buildColNameList(srcSchema schema.Table, srcColName []string) string {
 	return colList[:len(colList)-1]
 }
 
+// rowFilter returns the WHERE clause (empty if all rows are read), and
+// its arguments, that selects the rows of srcTable to convert: the rows
+// changed since the previous load (see internal.Conv.SetIncremental),
+// of the tenant being extracted (see internal.Conv.SetTenant), excluding
+// soft-deleted rows (see internal.Conv.SetSoftDelete). If deleted is set,
+// it selects the soft-deleted rows instead. Tables are in database
+// dbName.
+func rowFilter(conv *internal.Conv, dbName, srcTable string, deleted bool) (string, []interface{}) {
+	var conds []string
+	var args []interface{}
+	if col, since, ok := conv.IncrementalKey(srcTable); ok && since != "" {
+		conds = append(conds, fmt.Sprintf("`%s` >= ?", col))
+		args = append(args, since)
+	}
+	if tenant, path, ok := conv.TenantPath(srcTable); ok {
+		conds = append(conds, tenantCond(dbName, fmt.Sprintf("`%s`.`%s`", dbName, srcTable), tenant.Column, path, 1))
+		args = append(args, tenant.Value)
+	}
+	if sd, ok := conv.SoftDeleted(srcTable); ok {
+		cond := fmt.Sprintf("`%s` IS NOT NULL", sd.Column)
+		if sd.Value != "" {
+			cond = fmt.Sprintf("CAST(`%s` AS CHAR) = ?", sd.Column)
+			args = append(args, sd.Value)
+		}
+		// Rows for which the condition is NULL aren't deleted.
+		if deleted {
+			conds = append(conds, fmt.Sprintf("(%s) IS TRUE", cond))
+		} else {
+			conds = append(conds, fmt.Sprintf("(%s) IS NOT TRUE", cond))
+		}
+	}
+	if len(conds) == 0 {
+		return "", nil
+	}
+	return " WHERE " + strings.Join(conds, " AND "), args
+}
+
+// tenantCond returns the condition that selects the rows of table from
+// (quoted, or an alias) whose tenant column, reached by following the
+// foreign keys of path, is the query argument. Tables of the path are
+// aliased t<depth>.
+func tenantCond(dbName, from, col string, path []schema.ForeignKey, depth int) string {
+	if len(path) == 0 {
+		return fmt.Sprintf("CAST(%s.`%s` AS CHAR) = ?", from, col)
+	}
+	fk := path[0]
+	alias := fmt.Sprintf("t%d", depth)
+	var join []string
+	for i, c := range fk.Columns {
+		join = append(join, fmt.Sprintf("%s.`%s` = %s.`%s`", alias, fk.ReferColumns[i], from, c))
+	}
+	return fmt.Sprintf("EXISTS (SELECT 1 FROM `%s`.`%s` %s WHERE %s AND %s)", dbName, fk.ReferTable, alias, strings.Join(join, " AND "), tenantCond(dbName, alias, col, path[1:], depth+1))
+}
+
 // SetRowStats populates conv with the number of rows in each table.
-func SetRowStats(conv *internal.Conv, db *sql.DB, dbName string) {
+func SetRowStats(conv *internal.Conv, db internal.Queryer, dbName string) {
 	tables, err := getTables(db, dbName)
 	if err != nil {
 		conv.Unexpected(fmt.Sprintf("Couldn't get list of table: %s", err))
 		return
 	}
 	for _, t := range tables {
+		if conv.SkipData(t.name) {
+			continue
+		}
 		// MySQL schema and name can be arbitrary strings.
 		// Ideally we would pass schema/name as a query parameter,
 		// but MySQL doesn't support this. So we quote it instead.
-		q := fmt.Sprintf("SELECT COUNT(*) FROM `%s`.`%s`;", t.schema, t.name)
+		q := fmt.Sprintf("SELECT COUNT(*) FROM `%s`.`%s`", t.schema, t.name)
 		tableName := t.name
-		rows, err := db.Query(q)
+		if _, ok := conv.SoftDeleted(tableName); ok {
+			where, args := rowFilter(conv, dbName, tableName, true)
+			var excluded int64
+			if err := db.QueryRow(q+where+";", args...).Scan(&excluded); err != nil {
+				conv.Unexpected(fmt.Sprintf("Couldn't count soft-deleted rows of table %s: %s", tableName, err))
+			}
+			conv.StatsAddExcludedRows(tableName, excluded)
+		}
+		where, args := rowFilter(conv, dbName, tableName, false)
+		rows, err := db.Query(q+where+";", args...)
 		if err != nil {
 			conv.Unexpected(fmt.Sprintf("Couldn't get number of rows for table %s", tableName))
 			continue
 		}
-		defer rows.Close()
 		var count int64
 		if rows.Next() {
 			err := rows.Scan(&count)
 			if err != nil {
+				rows.Close()
 				conv.Unexpected(fmt.Sprintf("Can't get row count: %s", err))
 				continue
 			}
-			conv.Stats.Rows[tableName] += count
+			conv.StatsAddRows(tableName, count)
 		}
+		rows.Close()
 	}
 }
 
@@ -177,7 +417,7 @@ type schemaAndName struct {
 // Note that sql.DB already effectively has the dbName
 // embedded within it (dbName is part of the DSN passed to sql.Open),
 // but unfortunately there is no way to extract it from sql.DB.
-func getTables(db *sql.DB, dbName string) ([]schemaAndName, error) {
+func getTables(db internal.Queryer, dbName string) ([]schemaAndName, error) {
 	// In MySQL, schema is the same as database name.
 	q := "SELECT table_name FROM information_schema.tables where table_type = 'BASE TABLE' and table_schema=?"
 	rows, err := db.Query(q, dbName)
@@ -194,23 +434,87 @@ func getTables(db *sql.DB, dbName string) ([]schemaAndName, error) {
 	return tables, nil
 }
 
+// getTriggers records the triggers of the tables of dbName.
+func getTriggers(conv *internal.Conv, db *sql.DB, dbName string) error {
+	q := "SELECT trigger_name, event_object_table, action_timing, event_manipulation, action_statement FROM information_schema.triggers WHERE trigger_schema = ?"
+	rows, err := db.Query(q, dbName)
+	if err != nil {
+		return fmt.Errorf("couldn't get triggers: %w", err)
+	}
+	defer rows.Close()
+	var name, table, timing, event, body string
+	for rows.Next() {
+		if err := rows.Scan(&name, &table, &timing, &event, &body); err != nil {
+			conv.Unexpected(fmt.Sprintf("Can't scan: %v", err))
+			continue
+		}
+		conv.AddSrcTrigger(schema.Trigger{Name: name, Table: table, Timing: timing + " " + event, Body: body})
+	}
+	return nil
+}
+
+// getAutoIncrements records the next AUTO_INCREMENT value of tables
+// with an AUTO_INCREMENT column (see addAutoIncrementSequence). Note that
+// MySQL 8 caches these values: set information_schema_stats_expiry to 0
+// to get current values.
+func getAutoIncrements(conv *internal.Conv, db *sql.DB, dbName string) error {
+	found := false
+	for _, t := range conv.SrcSchema {
+		for _, c := range t.ColDefs {
+			found = found || c.Ignored.AutoIncrement
+		}
+	}
+	if !found {
+		return nil
+	}
+	next, err := autoIncrements(db, dbName)
+	if err != nil {
+		return err
+	}
+	for tableName, n := range next {
+		addAutoIncrementSequence(conv, tableName, n)
+	}
+	return nil
+}
+
+// autoIncrements returns the next AUTO_INCREMENT value of tables, by
+// table name.
+func autoIncrements(db *sql.DB, dbName string) (map[string]int64, error) {
+	q := "SELECT table_name, auto_increment FROM information_schema.tables WHERE table_schema = ? AND auto_increment IS NOT NULL"
+	rows, err := db.Query(q, dbName)
+	if err != nil {
+		return nil, fmt.Errorf("couldn't get AUTO_INCREMENT values: %w", err)
+	}
+	defer rows.Close()
+	next := make(map[string]int64)
+	var tableName string
+	var n int64
+	for rows.Next() {
+		if err := rows.Scan(&tableName, &n); err != nil {
+			return nil, fmt.Errorf("can't scan AUTO_INCREMENT values: %w", err)
+		}
+		next[tableName] = n
+	}
+	return next, nil
+}
+
 func processTable(conv *internal.Conv, db *sql.DB, table schemaAndName) error {
 	cols, err := getColumns(table, db)
 	if err != nil {
-		return fmt.Errorf("couldn't get schema for table %s.%s: %s", table.schema, table.name, err)
+		return fmt.Errorf("couldn't get schema for table %s.%s: %w", table.schema, table.name, err)
 	}
 	defer cols.Close()
 	primaryKeys, constraints, err := getConstraints(conv, db, table)
 	if err != nil {
-		return fmt.Errorf("couldn't get constraints for table %s.%s: %s", table.schema, table.name, err)
+		return fmt.Errorf("couldn't get constraints for table %s.%s: %w", table.schema, table.name, err)
 	}
 	foreignKeys, err := getForeignKeys(conv, db, table)
 	if err != nil {
-		return fmt.Errorf("couldn't get foreign key constraints for table %s.%s: %s", table.schema, table.name, err)
+		return fmt.Errorf("couldn't get foreign key constraints for table %s.%s: %w", table.schema, table.name, err)
 	}
 	indexes, err := getIndexes(conv, db, table)
 	if err != nil {
-		return fmt.Errorf("couldn't get indexes for table %s.%s: %s", table.schema, table.name, err)
+		return fmt.Errorf("couldn't get indexes for table %s.%s: %w", table.schema, table.name, err)
 	}
 	colDefs, colNames := processColumns(conv, cols, constraints)
 	name := table.name
@@ -218,18 +522,18 @@ func processTable(conv *internal.Conv, db *sql.DB, table schemaAndName) error {
 	for _, k := range primaryKeys {
 		schemaPKeys = append(schemaPKeys, schema.Key{Column: k})
 	}
-	conv.SrcSchema[name] = schema.Table{
+	conv.SetSrcTable(schema.Table{
 		Name:        name,
 		ColNames:    colNames,
 		ColDefs:     colDefs,
 		PrimaryKeys: schemaPKeys,
 		Indexes:     indexes,
-		ForeignKeys: foreignKeys}
+		ForeignKeys: foreignKeys})
 	return nil
 }
 
 func getColumns(table schemaAndName, db *sql.DB) (*sql.Rows, error) {
-	q := `SELECT c.column_name, c.data_type, c.column_type, c.is_nullable, c.column_default, c.character_maximum_length, c.numeric_precision, c.numeric_scale, c.extra
+	q := `SELECT c.column_name, c.data_type, c.column_type, c.is_nullable, c.column_default, c.character_maximum_length, c.numeric_precision, c.numeric_scale, c.extra, c.collation_name
               FROM information_schema.COLUMNS c
               where table_schema = ? and table_name = ? ORDER BY c.ordinal_position;`
 	return db.Query(q, table.schema, table.name)
@@ -239,10 +543,10 @@ func processColumns(conv *internal.Conv, cols *sql.Rows, constraints map[string]
 	colDefs := make(map[string]schema.Column)
 	var colNames []string
 	var colName, dataType, isNullable, columnType string
-	var colDefault, colExtra sql.NullString
+	var colDefault, colExtra, collation sql.NullString
 	var charMaxLen, numericPrecision, numericScale sql.NullInt64
 	for cols.Next() {
-		err := cols.Scan(&colName, &dataType, &columnType, &isNullable, &colDefault, &charMaxLen, &numericPrecision, &numericScale, &colExtra)
+		err := cols.Scan(&colName, &dataType, &columnType, &isNullable, &colDefault, &charMaxLen, &numericPrecision, &numericScale, &colExtra, &collation)
 		if err != nil {
 			conv.Unexpected(fmt.Sprintf("Can't scan: %v", err))
 			continue
@@ -263,10 +567,11 @@ func processColumns(conv *internal.Conv, cols *sql.Rows, constraints map[string]
 			ignored.AutoIncrement = true
 		}
 		c := schema.Column{
-			Name:    colName,
-			Type:    toType(dataType, columnType, charMaxLen, numericPrecision, numericScale),
-			NotNull: toNotNull(conv, isNullable),
-			Ignored: ignored,
+			Name:      colName,
+			Type:      toType(dataType, columnType, charMaxLen, numericPrecision, numericScale),
+			NotNull:   toNotNull(conv, isNullable),
+			Ignored:   ignored,
+			Collation: collation.String,
 		}
 		colDefs[colName] = c
 		colNames = append(colNames, colName)
@@ -378,7 +683,7 @@ func getForeignKeys(conv *internal.Conv, db *sql.DB, table schemaAndName) (forei
 
 // getIndexes return a list of all indexes for the specified table.
 func getIndexes(conv *internal.Conv, db *sql.DB, table schemaAndName) ([]schema.Index, error) {
-	q := `SELECT DISTINCT INDEX_NAME,COLUMN_NAME,SEQ_IN_INDEX,COLLATION,NON_UNIQUE
+	q := `SELECT DISTINCT INDEX_NAME,COLUMN_NAME,SEQ_IN_INDEX,COLLATION,NON_UNIQUE,SUB_PART
 		FROM INFORMATION_SCHEMA.STATISTICS 
 		WHERE TABLE_SCHEMA = ?
 			AND TABLE_NAME = ?
@@ -391,20 +696,26 @@ func getIndexes(conv *internal.Conv, db *sql.DB, table schemaAndName) ([]schema.
 	defer rows.Close()
 	var name, column, sequence, nonUnique string
 	var collation sql.NullString
+	var subPart sql.NullInt64
 	indexMap := make(map[string]schema.Index)
 	var indexNames []string
 	var indexes []schema.Index
 	for rows.Next() {
-		if err := rows.Scan(&name, &column, &sequence, &collation, &nonUnique); err != nil {
+		if err := rows.Scan(&name, &column, &sequence, &collation, &nonUnique, &subPart); err != nil {
 			conv.Unexpected(fmt.Sprintf("Can't scan: %v", err))
 			continue
 		}
 		if _, found := indexMap[name]; !found {
 			indexNames = append(indexNames, name)
-			indexMap[name] = schema.Index{Name: name, Unique: (nonUnique == "0")}
+			// MySQL doesn't distinguish unique indexes from UNIQUE
+			// constraints: every unique index is listed as a constraint
+			// in TABLE_CONSTRAINTS.
+			indexMap[name] = schema.Index{Name: name, Unique: (nonUnique == "0"), Constraint: (nonUnique == "0")}
 		}
 		index := indexMap[name]
-		index.Keys = append(index.Keys, schema.Key{Column: column, Desc: (collation.Valid && collation.String == "D")})
+		// SUB_PART is the length of the indexed prefix of the column, and
+		// NULL if the whole column is indexed.
+		index.Keys = append(index.Keys, schema.Key{Column: column, Desc: (collation.Valid && collation.String == "D"), Length: subPart.Int64})
 		indexMap[name] = index
 	}
 	for _, k := range indexNames {
@@ -416,6 +727,11 @@ func toType(dataType string, columnType string, charLen sql.NullInt64, numericPr
 	switch {
 	case dataType == "set":
 		return schema.Type{Name: dataType, ArrayBounds: []int64{-1}}
+	case dataType == "tinyint" && strings.HasPrefix(columnType, "tinyint(1)"):
+		// The display width of integer types is deprecated (since MySQL
+		// 8.0.17), but tinyint(1), which MySQL uses for booleans, is kept
+		// in column_type.
+		return schema.Type{Name: dataType, Mods: []int64{1}}
 	case charLen.Valid:
 		return schema.Type{Name: dataType, Mods: []int64{charLen.Int64}}
 	case dataType == "decimal" && numericPrecision.Valid && numericScale.Valid && numericScale.Int64 != 0:
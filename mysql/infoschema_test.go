@@ -49,11 +49,11 @@ func TestProcessInfoSchemaMYSQL(t *testing.T) {
 		}, {
 			query: "SELECT (.+) FROM information_schema.COLUMNS (.+)",
 			args:  []driver.Value{"test", "user"},
-			cols:  []string{"column_name", "data_type", "column_type", "is_nullable", "column_default", "character_maximum_length", "numeric_precision", "numeric_scale", "extra"},
+			cols:  []string{"column_name", "data_type", "column_type", "is_nullable", "column_default", "character_maximum_length", "numeric_precision", "numeric_scale", "extra", "collation_name"},
 			rows: [][]driver.Value{
-				{"user_id", "text", "text", "NO", nil, nil, nil, nil, nil},
-				{"name", "text", "text", "NO", nil, nil, nil, nil, nil},
-				{"ref", "bigint", "bigint", "NO", nil, nil, nil, nil, nil}},
+				{"user_id", "text", "text", "NO", nil, nil, nil, nil, nil, nil},
+				{"name", "text", "text", "NO", nil, nil, nil, nil, nil, nil},
+				{"ref", "bigint", "bigint", "NO", nil, nil, nil, nil, nil, nil}},
 		}, {
 			query: "SELECT (.+) FROM INFORMATION_SCHEMA.TABLE_CONSTRAINTS (.+)",
 			args:  []driver.Value{"test", "user"},
@@ -71,16 +71,16 @@ func TestProcessInfoSchemaMYSQL(t *testing.T) {
 		}, {
 			query: "SELECT (.+) FROM INFORMATION_SCHEMA.STATISTICS (.+)",
 			args:  []driver.Value{"test", "user"},
-			cols:  []string{"INDEX_NAME", "COLUMN_NAME", "SEQ_IN_INDEX", "COLLATION", "NON_UNIQUE"},
+			cols:  []string{"INDEX_NAME", "COLUMN_NAME", "SEQ_IN_INDEX", "COLLATION", "NON_UNIQUE", "SUB_PART"},
 		},
 		{
 			query: "SELECT (.+) FROM information_schema.COLUMNS (.+)",
 			args:  []driver.Value{"test", "cart"},
-			cols:  []string{"column_name", "data_type", "column_type", "is_nullable", "column_default", "character_maximum_length", "numeric_precision", "numeric_scale", "extra"},
+			cols:  []string{"column_name", "data_type", "column_type", "is_nullable", "column_default", "character_maximum_length", "numeric_precision", "numeric_scale", "extra", "collation_name"},
 			rows: [][]driver.Value{
-				{"productid", "text", "text", "NO", nil, nil, nil, nil, nil},
-				{"userid", "text", "text", "NO", nil, nil, nil, nil, nil},
-				{"quantity", "bigint", "bigint", "YES", nil, nil, 64, 0, nil}},
+				{"productid", "text", "text", "NO", nil, nil, nil, nil, nil, nil},
+				{"userid", "text", "text", "NO", nil, nil, nil, nil, nil, nil},
+				{"quantity", "bigint", "bigint", "YES", nil, nil, 64, 0, nil, nil}},
 		}, {
 			query: "SELECT (.+) FROM INFORMATION_SCHEMA.TABLE_CONSTRAINTS (.+)",
 			args:  []driver.Value{"test", "cart"},
@@ -98,20 +98,20 @@ func TestProcessInfoSchemaMYSQL(t *testing.T) {
 		}, {
 			query: "SELECT (.+) FROM INFORMATION_SCHEMA.STATISTICS (.+)",
 			args:  []driver.Value{"test", "cart"},
-			cols:  []string{"INDEX_NAME", "COLUMN_NAME", "SEQ_IN_INDEX", "COLLATION", "NON_UNIQUE"},
+			cols:  []string{"INDEX_NAME", "COLUMN_NAME", "SEQ_IN_INDEX", "COLLATION", "NON_UNIQUE", "SUB_PART"},
 			rows: [][]driver.Value{
-				{"index1", "userid", 1, sql.NullString{Valid: false}, "0"},
-				{"index2", "userid", 1, "A", "1"},
-				{"index2", "productid", 2, "D", "1"},
-				{"index3", "productid", 1, "A", "0"},
-				{"index3", "userid", 2, "D", "0"}},
+				{"index1", "userid", 1, sql.NullString{Valid: false}, "0", nil},
+				{"index2", "userid", 1, "A", "1", nil},
+				{"index2", "productid", 2, "D", "1", 10},
+				{"index3", "productid", 1, "A", "0", nil},
+				{"index3", "userid", 2, "D", "0", nil}},
 		}, {
 			query: "SELECT (.+) FROM information_schema.COLUMNS (.+)",
 			args:  []driver.Value{"test", "product"},
-			cols:  []string{"column_name", "data_type", "column_type", "is_nullable", "column_default", "character_maximum_length", "numeric_precision", "numeric_scale", "extra"},
+			cols:  []string{"column_name", "data_type", "column_type", "is_nullable", "column_default", "character_maximum_length", "numeric_precision", "numeric_scale", "extra", "collation_name"},
 			rows: [][]driver.Value{
-				{"product_id", "text", "text", "NO", nil, nil, nil, nil, nil},
-				{"product_name", "text", "text", "NO", nil, nil, nil, nil, nil}},
+				{"product_id", "text", "text", "NO", nil, nil, nil, nil, nil, nil},
+				{"product_name", "text", "text", "NO", nil, nil, nil, nil, nil, nil}},
 		}, {
 			query: "SELECT (.+) FROM INFORMATION_SCHEMA.TABLE_CONSTRAINTS (.+)",
 			args:  []driver.Value{"test", "product"},
@@ -125,32 +125,33 @@ func TestProcessInfoSchemaMYSQL(t *testing.T) {
 		}, {
 			query: "SELECT (.+) FROM INFORMATION_SCHEMA.STATISTICS (.+)",
 			args:  []driver.Value{"test", "product"},
-			cols:  []string{"INDEX_NAME", "COLUMN_NAME", "SEQ_IN_INDEX", "COLLATION", "NON_UNIQUE"},
+			cols:  []string{"INDEX_NAME", "COLUMN_NAME", "SEQ_IN_INDEX", "COLLATION", "NON_UNIQUE", "SUB_PART"},
 		}, {
 			query: "SELECT (.+) FROM information_schema.COLUMNS (.+)",
 			args:  []driver.Value{"test", "test"},
-			cols:  []string{"column_name", "data_type", "column_type", "is_nullable", "column_default", "character_maximum_length", "numeric_precision", "numeric_scale", "extra"},
+			cols:  []string{"column_name", "data_type", "column_type", "is_nullable", "column_default", "character_maximum_length", "numeric_precision", "numeric_scale", "extra", "collation_name"},
 			rows: [][]driver.Value{
-				{"id", "bigint", "bigint", "NO", nil, nil, 64, 0, nil},
-				{"s", "set", "set", "YES", nil, nil, nil, nil, nil},
-				{"txt", "text", "text", "NO", nil, nil, nil, nil, nil},
-				{"b", "boolean", "boolean", "YES", nil, nil, nil, nil, nil},
-				{"bs", "bigint", "bigint", "NO", "nextval('test11_bs_seq'::regclass)", nil, 64, 0, nil},
-				{"bl", "blob", "blob", "YES", nil, nil, nil, nil, nil},
-				{"c", "char", "char(1)", "YES", nil, 1, nil, nil, nil},
-				{"c8", "char", "char(8)", "YES", nil, 8, nil, nil, nil},
-				{"d", "date", "date", "YES", nil, nil, nil, nil, nil},
-				{"dec", "decimal", "decimal(20,5)", "YES", nil, nil, 20, 5, nil},
-				{"f8", "double", "double", "YES", nil, nil, 53, nil, nil},
-				{"f4", "float", "float", "YES", nil, nil, 24, nil, nil},
-				{"i8", "bigint", "bigint", "YES", nil, nil, 64, 0, nil},
-				{"i4", "integer", "integer", "YES", nil, nil, 32, 0, "auto_increment"},
-				{"i2", "smallint", "smallint", "YES", nil, nil, 16, 0, nil},
-				{"si", "integer", "integer", "NO", "nextval('test11_s_seq'::regclass)", nil, 32, 0, nil},
-				{"ts", "datetime", "datetime", "YES", nil, nil, nil, nil, nil},
-				{"tz", "timestamp", "timestamp", "YES", nil, nil, nil, nil, nil},
-				{"vc", "varchar", "varchar", "YES", nil, nil, nil, nil, nil},
-				{"vc6", "varchar", "varchar(6)", "YES", nil, 6, nil, nil, nil}},
+				{"id", "bigint", "bigint", "NO", nil, nil, 64, 0, nil, nil},
+				{"s", "set", "set", "YES", nil, nil, nil, nil, nil, nil},
+				{"txt", "text", "text", "NO", nil, nil, nil, nil, nil, nil},
+				{"b", "boolean", "boolean", "YES", nil, nil, nil, nil, nil, nil},
+				{"bs", "bigint", "bigint", "NO", "nextval('test11_bs_seq'::regclass)", nil, 64, 0, nil, nil},
+				{"bl", "blob", "blob", "YES", nil, nil, nil, nil, nil, nil},
+				{"c", "char", "char(1)", "YES", nil, 1, nil, nil, nil, nil},
+				{"c8", "char", "char(8)", "YES", nil, 8, nil, nil, nil, nil},
+				{"d", "date", "date", "YES", nil, nil, nil, nil, nil, nil},
+				{"dec", "decimal", "decimal(20,5)", "YES", nil, nil, 20, 5, nil, nil},
+				{"f8", "double", "double", "YES", nil, nil, 53, nil, nil, nil},
+				{"f4", "float", "float", "YES", nil, nil, 24, nil, nil, nil},
+				{"i8", "bigint", "bigint", "YES", nil, nil, 64, 0, nil, nil},
+				{"i4", "integer", "integer", "YES", nil, nil, 32, 0, "auto_increment", nil},
+				{"i2", "smallint", "smallint", "YES", nil, nil, 16, 0, nil, nil},
+				{"si", "integer", "integer", "NO", "nextval('test11_s_seq'::regclass)", nil, 32, 0, nil, nil},
+				{"ts", "datetime", "datetime", "YES", nil, nil, nil, nil, nil, nil},
+				{"tz", "timestamp", "timestamp", "YES", nil, nil, nil, nil, nil, nil},
+				{"vc", "varchar", "varchar", "YES", nil, nil, nil, nil, nil, nil},
+				{"vc6", "varchar", "varchar(6)", "YES", nil, 6, nil, nil, nil, nil},
+				{"tb", "tinyint", "tinyint(1)", "YES", nil, nil, 3, 0, nil, nil}},
 		}, {
 			query: "SELECT (.+) FROM INFORMATION_SCHEMA.TABLE_CONSTRAINTS (.+)",
 			args:  []driver.Value{"test", "test"},
@@ -165,15 +166,15 @@ func TestProcessInfoSchemaMYSQL(t *testing.T) {
 		}, {
 			query: "SELECT (.+) FROM INFORMATION_SCHEMA.STATISTICS (.+)",
 			args:  []driver.Value{"test", "test"},
-			cols:  []string{"INDEX_NAME", "COLUMN_NAME", "SEQ_IN_INDEX", "COLLATION", "NON_UNIQUE"},
+			cols:  []string{"INDEX_NAME", "COLUMN_NAME", "SEQ_IN_INDEX", "COLLATION", "NON_UNIQUE", "SUB_PART"},
 		}, {
 			query: "SELECT (.+) FROM information_schema.COLUMNS (.+)",
 			args:  []driver.Value{"test", "test_ref"},
-			cols:  []string{"column_name", "data_type", "column_type", "is_nullable", "column_default", "character_maximum_length", "numeric_precision", "numeric_scale", "extra"},
+			cols:  []string{"column_name", "data_type", "column_type", "is_nullable", "column_default", "character_maximum_length", "numeric_precision", "numeric_scale", "extra", "collation_name"},
 			rows: [][]driver.Value{
-				{"ref_id", "bigint", "bigint", "NO", nil, nil, 64, 0, nil},
-				{"ref_txt", "text", "text", "NO", nil, nil, nil, nil, nil},
-				{"abc", "text", "text", "NO", nil, nil, nil, nil, nil}},
+				{"ref_id", "bigint", "bigint", "NO", nil, nil, 64, 0, nil, nil},
+				{"ref_txt", "text", "text", "NO", nil, nil, nil, nil, nil, nil},
+				{"abc", "text", "text", "NO", nil, nil, nil, nil, nil, nil}},
 		}, {
 			query: "SELECT (.+) FROM INFORMATION_SCHEMA.TABLE_CONSTRAINTS (.+)",
 			args:  []driver.Value{"test", "test_ref"},
@@ -188,13 +189,30 @@ func TestProcessInfoSchemaMYSQL(t *testing.T) {
 		}, {
 			query: "SELECT (.+) FROM INFORMATION_SCHEMA.STATISTICS (.+)",
 			args:  []driver.Value{"test", "test_ref"},
-			cols:  []string{"INDEX_NAME", "COLUMN_NAME", "SEQ_IN_INDEX", "COLLATION", "NON_UNIQUE"},
+			cols:  []string{"INDEX_NAME", "COLUMN_NAME", "SEQ_IN_INDEX", "COLLATION", "NON_UNIQUE", "SUB_PART"},
+		}, {
+			query: "SELECT table_name, auto_increment FROM information_schema.tables (.+)",
+			args:  []driver.Value{"test"},
+			cols:  []string{"table_name", "auto_increment"},
+			rows:  [][]driver.Value{{"test", int64(43)}},
+		}, {
+			query: "SELECT (.+) FROM information_schema.triggers (.+)",
+			args:  []driver.Value{"test"},
+			cols:  []string{"trigger_name", "event_object_table", "action_timing", "event_manipulation", "action_statement"},
+			rows: [][]driver.Value{
+				{"test_audit", "test", "AFTER", "DELETE", "INSERT INTO test_log (id) VALUES (OLD.id)"}},
 		},
 	}
 	db := mkMockDB(t, ms)
 	conv := internal.MakeConv()
 	err := ProcessInfoSchema(conv, db, "test")
 	assert.Nil(t, err)
+	assert.Equal(t, map[string]schema.Sequence{
+		"test_i4_seq": {Name: "test_i4_seq", Increment: 1, Next: 43, Table: "test", Column: "i4"},
+	}, conv.SrcSequences)
+	assert.Equal(t, map[string][]schema.Trigger{
+		"test": {{Name: "test_audit", Table: "test", Timing: "AFTER DELETE", Body: "INSERT INTO test_log (id) VALUES (OLD.id)"}},
+	}, conv.SrcTriggers)
 	expectedSchema := map[string]ddl.CreateTable{
 		"user": ddl.CreateTable{
 			Name:     "user",
@@ -230,7 +248,7 @@ func TestProcessInfoSchemaMYSQL(t *testing.T) {
 			Pks: []ddl.IndexKey{ddl.IndexKey{Col: "product_id"}}},
 		"test": ddl.CreateTable{
 			Name:     "test",
-			ColNames: []string{"id", "s", "txt", "b", "bs", "bl", "c", "c8", "d", "dec", "f8", "f4", "i8", "i4", "i2", "si", "ts", "tz", "vc", "vc6"},
+			ColNames: []string{"id", "s", "txt", "b", "bs", "bl", "c", "c8", "d", "dec", "f8", "f4", "i8", "i4", "i2", "si", "ts", "tz", "vc", "vc6", "tb"},
 			ColDefs: map[string]ddl.ColumnDef{
 				"id":  ddl.ColumnDef{Name: "id", T: ddl.Type{Name: ddl.Int64}, NotNull: true},
 				"s":   ddl.ColumnDef{Name: "s", T: ddl.Type{Name: ddl.String, Len: ddl.MaxLength, IsArray: true}},
@@ -252,6 +270,7 @@ func TestProcessInfoSchemaMYSQL(t *testing.T) {
 				"tz":  ddl.ColumnDef{Name: "tz", T: ddl.Type{Name: ddl.Timestamp}},
 				"vc":  ddl.ColumnDef{Name: "vc", T: ddl.Type{Name: ddl.String, Len: ddl.MaxLength}},
 				"vc6": ddl.ColumnDef{Name: "vc6", T: ddl.Type{Name: ddl.String, Len: int64(6)}},
+				"tb":  ddl.ColumnDef{Name: "tb", T: ddl.Type{Name: ddl.Bool}},
 			},
 			Pks: []ddl.IndexKey{ddl.IndexKey{Col: "id"}},
 			Fks: []ddl.Foreignkey{ddl.Foreignkey{Name: "fk_test4", Columns: []string{"id", "txt"}, ReferTable: "test_ref", ReferColumns: []string{"ref_id", "ref_txt"}}}},
@@ -266,7 +285,7 @@ func TestProcessInfoSchemaMYSQL(t *testing.T) {
 			Pks: []ddl.IndexKey{ddl.IndexKey{Col: "ref_id"}, ddl.IndexKey{Col: "ref_txt"}}},
 	}
 	assert.Equal(t, expectedSchema, stripSchemaComments(conv.SpSchema))
-	assert.Equal(t, len(conv.Issues["cart"]), 0)
+	assert.Equal(t, map[string][]internal.SchemaIssue{"productid": []internal.SchemaIssue{internal.IndexPrefix}}, conv.Issues["cart"])
 	expectedIssues := map[string][]internal.SchemaIssue{
 		"bs": []internal.SchemaIssue{internal.DefaultValue},
 		"f4": []internal.SchemaIssue{internal.Widened},
@@ -274,6 +293,7 @@ func TestProcessInfoSchemaMYSQL(t *testing.T) {
 		"i2": []internal.SchemaIssue{internal.Widened},
 		"si": []internal.SchemaIssue{internal.Widened, internal.DefaultValue},
 		"ts": []internal.SchemaIssue{internal.Datetime},
+		"tb": []internal.SchemaIssue{internal.TinyintBool},
 	}
 	assert.Equal(t, expectedIssues, conv.Issues["test"])
 	assert.Equal(t, int64(0), conv.Unexpecteds())
@@ -332,6 +352,59 @@ func TestProcessSQLData(t *testing.T) {
 	assert.Equal(t, int64(1), conv.Unexpecteds()) // Bad row generates an entry in unexpected.
 }
 
+func TestProcessSQLData_FetchSize(t *testing.T) {
+	ms := []mockSpec{
+		{
+			query: "SELECT table_name FROM information_schema.tables where table_type = 'BASE TABLE' and (.+)",
+			args:  []driver.Value{"test"},
+			cols:  []string{"table_name"},
+			rows:  [][]driver.Value{{"t"}},
+		}, {
+			query: "SELECT `a`,`b` FROM `test`.`t` ORDER BY `a` LIMIT 2;",
+			cols:  []string{"a", "b"},
+			rows:  [][]driver.Value{{"1", "x"}, {"2", "y"}},
+		}, {
+			query: "SELECT `a`,`b` FROM `test`.`t` WHERE [(]`a`[)] > [(][?][)] ORDER BY `a` LIMIT 2;",
+			args:  []driver.Value{"2"},
+			cols:  []string{"a", "b"},
+			rows:  [][]driver.Value{{"3", "z"}},
+		},
+	}
+	db := mkMockDB(t, ms)
+	conv := buildConv(
+		ddl.CreateTable{
+			Name:     "t",
+			ColNames: []string{"a", "b"},
+			ColDefs: map[string]ddl.ColumnDef{
+				"a": ddl.ColumnDef{Name: "a", T: ddl.Type{Name: ddl.Int64}},
+				"b": ddl.ColumnDef{Name: "b", T: ddl.Type{Name: ddl.String, Len: ddl.MaxLength}},
+			}},
+		schema.Table{
+			Name:     "t",
+			ColNames: []string{"a", "b"},
+			ColDefs: map[string]schema.Column{
+				"a": schema.Column{Name: "a", Type: schema.Type{Name: "int"}},
+				"b": schema.Column{Name: "b", Type: schema.Type{Name: "text"}},
+			},
+			PrimaryKeys: []schema.Key{schema.Key{Column: "a"}}})
+	conv.SetFetchSize(internal.FetchSize{Rows: 2})
+	conv.SetDataMode()
+	var rows []spannerData
+	conv.SetDataSink(
+		func(table string, cols []string, vals []interface{}) {
+			rows = append(rows, spannerData{table: table, cols: cols, vals: vals})
+		})
+	ProcessSQLData(conv, db, "test")
+	assert.Equal(t,
+		[]spannerData{
+			spannerData{table: "t", cols: []string{"a", "b"}, vals: []interface{}{int64(1), "x"}},
+			spannerData{table: "t", cols: []string{"a", "b"}, vals: []interface{}{int64(2), "y"}},
+			spannerData{table: "t", cols: []string{"a", "b"}, vals: []interface{}{int64(3), "z"}},
+		},
+		rows)
+	assert.Equal(t, int64(0), conv.Unexpecteds())
+}
+
 func TestProcessSQLData_MultiCol(t *testing.T) {
 	// Tests multi-column behavior of ProcessSQLData (including
 	// handling of null columns and synthetic keys). Also tests
@@ -347,11 +420,11 @@ func TestProcessSQLData_MultiCol(t *testing.T) {
 		}, {
 			query: "SELECT (.+) FROM information_schema.COLUMNS (.+)",
 			args:  []driver.Value{"test", "test"},
-			cols:  []string{"column_name", "data_type", "column_type", "is_nullable", "column_default", "character_maximum_length", "numeric_precision", "numeric_scale", "extra"},
+			cols:  []string{"column_name", "data_type", "column_type", "is_nullable", "column_default", "character_maximum_length", "numeric_precision", "numeric_scale", "extra", "collation_name"},
 			rows: [][]driver.Value{
-				{"a", "text", "text", "NO", nil, nil, nil, nil, nil},
-				{"b", "double", "double", "YES", nil, nil, 53, nil, nil},
-				{"c", "bigint", "bigint", "YES", nil, nil, 64, 0, nil}},
+				{"a", "text", "text", "NO", nil, nil, nil, nil, nil, nil},
+				{"b", "double", "double", "YES", nil, nil, 53, nil, nil, nil},
+				{"c", "bigint", "bigint", "YES", nil, nil, 64, 0, nil, nil}},
 		},
 		{
 			query: "SELECT (.+) FROM INFORMATION_SCHEMA.TABLE_CONSTRAINTS (.+)",
@@ -365,7 +438,11 @@ func TestProcessSQLData_MultiCol(t *testing.T) {
 		}, {
 			query: "SELECT (.+) FROM INFORMATION_SCHEMA.STATISTICS (.+)",
 			args:  []driver.Value{"test", "test"},
-			cols:  []string{"INDEX_NAME", "COLUMN_NAME", "SEQ_IN_INDEX", "COLLATION", "NON_UNIQUE"},
+			cols:  []string{"INDEX_NAME", "COLUMN_NAME", "SEQ_IN_INDEX", "COLLATION", "NON_UNIQUE", "SUB_PART"},
+		}, {
+			query: "SELECT (.+) FROM information_schema.triggers (.+)",
+			args:  []driver.Value{"test"},
+			cols:  []string{"trigger_name", "event_object_table", "action_timing", "event_manipulation", "action_statement"},
 		},
 		// Note: go-sqlmock mocks specify an ordered sequence
 		// of queries and results.  This (repeated) entry is
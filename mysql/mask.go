@@ -0,0 +1,167 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package mysql
+
+import (
+	"encoding/hex"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/cloudspannerecosystem/harbourbridge/internal"
+	"github.com/pingcap/parser/ast"
+	"github.com/pingcap/parser/opcode"
+	"github.com/pingcap/tidb/types"
+	driver "github.com/pingcap/tidb/types/parser_driver"
+)
+
+// MaskMySQLDump reads mysqldump data from r, and writes it to w with the
+// values of masked columns replaced as specified by conv.Policies.Masks
+// (see internal.MaskRule). conv must hold the schema of the dump.
+// Everything else is written as is. INSERT statements for tables with
+// masked columns are rewritten: they must not share a line with other
+// statements (mysqldump writes one statement per line).
+func MaskMySQLDump(conv *internal.Conv, r *internal.Reader, w io.Writer) error {
+	for {
+		b, stmts, err := readAndParseChunk(conv, r)
+		if err != nil {
+			return err
+		}
+		var inserts []*ast.InsertStmt
+		for _, stmt := range stmts {
+			if s, ok := stmt.(*ast.InsertStmt); ok && s.Table != nil {
+				if t, err := getTableNameInsert(s.Table); err == nil && conv.HasMasks(t) {
+					inserts = append(inserts, s)
+				}
+			}
+		}
+		if len(inserts) == 0 {
+			if _, err := w.Write(b); err != nil {
+				return err
+			}
+		} else {
+			if len(inserts) != len(stmts) {
+				return fmt.Errorf("can't mask INSERT statements mixed with other statements at line %d", r.LineNumber)
+			}
+			if err := maskInserts(conv, inserts, string(b), w); err != nil {
+				return err
+			}
+		}
+		if r.EOF {
+			break
+		}
+	}
+	return nil
+}
+
+// maskInserts writes the INSERT statements parsed from chunk, with the
+// values of masked columns replaced, to w.
+func maskInserts(conv *internal.Conv, stmts []*ast.InsertStmt, chunk string, w io.Writer) error {
+	loc := insertRegexp.FindStringIndex(chunk)
+	if loc == nil {
+		return fmt.Errorf("can't find INSERT statement to mask in %q", chunk)
+	}
+	// Keep any comments before the statements.
+	if _, err := io.WriteString(w, chunk[:loc[0]]); err != nil {
+		return err
+	}
+	for _, stmt := range stmts {
+		srcTable, err := getTableNameInsert(stmt.Table)
+		if err != nil {
+			return err
+		}
+		srcCols, err := getCols(stmt)
+		if err != nil {
+			srcCols = conv.SrcSchema[srcTable].ColNames
+		}
+		var rows []string
+		for _, row := range stmt.Lists {
+			vals, err := getVals(row)
+			if err != nil {
+				return fmt.Errorf("can't mask INSERT statement for table %s: %w", srcTable, err)
+			}
+			var l []string
+			for i, item := range row {
+				masked := false
+				if i < len(srcCols) && vals[i] != "<nil>" {
+					_, masked = conv.Policies.Masks[srcTable+"."+srcCols[i]]
+				}
+				if masked {
+					if v, ok := conv.Mask(srcTable, srcCols[i], vals[i]); ok {
+						l = append(l, mysqlQuote(v))
+					} else {
+						l = append(l, "NULL")
+					}
+					continue
+				}
+				lit, err := mysqlLiteral(item)
+				if err != nil {
+					return fmt.Errorf("can't mask INSERT statement for table %s: %w", srcTable, err)
+				}
+				l = append(l, lit)
+			}
+			rows = append(rows, "("+strings.Join(l, ",")+")")
+		}
+		if _, err := fmt.Fprintf(w, "%s%s;\n", chunk[loc[0]:loc[1]], strings.Join(rows, ",")); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// mysqlLiteral returns the value of item, a value of an INSERT
+// statement, as an SQL literal.
+func mysqlLiteral(item ast.ExprNode) (string, error) {
+	switch n := item.(type) {
+	case *driver.ValueExpr:
+		switch v := n.GetValue().(type) {
+		case nil:
+			return "NULL", nil
+		case string:
+			return mysqlQuote(v), nil
+		case []byte:
+			return "X'" + hex.EncodeToString(v) + "'", nil
+		case types.BinaryLiteral:
+			return "X'" + hex.EncodeToString(v) + "'", nil
+		case int64:
+			return strconv.FormatInt(v, 10), nil
+		case uint64:
+			return strconv.FormatUint(v, 10), nil
+		case float64:
+			return strconv.FormatFloat(v, 'g', -1, 64), nil
+		case *types.MyDecimal:
+			return v.String(), nil
+		default:
+			return mysqlQuote(fmt.Sprintf("%v", v)), nil
+		}
+	case *ast.UnaryOperationExpr:
+		if n.Op != opcode.Minus {
+			return "", fmt.Errorf("unexpected UnaryOperationExpr node with opcode %v", n.Op)
+		}
+		s, err := mysqlLiteral(n.V)
+		if err != nil {
+			return "", err
+		}
+		return "-" + s, nil
+	}
+	return "", fmt.Errorf("unexpected value node %T", item)
+}
+
+// mysqlQuote returns s as a MySQL string literal, escaped as by
+// mysqldump.
+func mysqlQuote(s string) string {
+	return "'" + strings.NewReplacer(`\`, `\\`, "'", `\'`, "\x00", `\0`, "\n", `\n`, "\r", `\r`, "\x1a", `\Z`).Replace(s) + "'"
+}
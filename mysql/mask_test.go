@@ -0,0 +1,57 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package mysql
+
+import (
+	"bufio"
+	"strings"
+	"testing"
+
+	"github.com/cloudspannerecosystem/harbourbridge/internal"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMaskMySQLDump(t *testing.T) {
+	dump := "CREATE TABLE `users` (\n" +
+		"  `id` bigint NOT NULL,\n" +
+		"  `email` varchar(16),\n" +
+		"  `name` text,\n" +
+		"  PRIMARY KEY (`id`)\n" +
+		");\n" +
+		"--\n" +
+		"-- Dumping data for table `users`\n" +
+		"--\n" +
+		"INSERT INTO `users` VALUES (1,'ada@example.com','Ada\\nL'),(2,NULL,'Bob'),(-3,'x','O\\'Neil');\n"
+	conv, _ := runProcessMySQLDump(dump)
+	conv.Policies.Masks = map[string]internal.MaskRule{
+		"users.email": {Method: internal.MaskHash, Value: "s"},
+		"users.name":  {Method: internal.MaskRedact},
+	}
+	assert.Nil(t, conv.CheckMasks())
+	conv.SetDataMode()
+	var b strings.Builder
+	assert.Nil(t, MaskMySQLDump(conv, internal.NewReader(bufio.NewReader(strings.NewReader(dump)), nil), &b))
+	want := "CREATE TABLE `users` (\n" +
+		"  `id` bigint NOT NULL,\n" +
+		"  `email` varchar(16),\n" +
+		"  `name` text,\n" +
+		"  PRIMARY KEY (`id`)\n" +
+		");\n" +
+		"--\n" +
+		"-- Dumping data for table `users`\n" +
+		"--\n" +
+		"INSERT INTO `users` VALUES (1,'d94188c85e931cfd','xxx\\nx'),(2,NULL,'xxx'),(-3,'9740c9dcdb4487d8','x\\'xxxx');\n"
+	assert.Equal(t, want, b.String())
+}
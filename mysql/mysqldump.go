@@ -49,6 +49,12 @@ var spatialRegexps = func() []*regexp.Regexp {
 var spatialIndexRegex = regexp.MustCompile("(?i)\\sSPATIAL\\s")
 var spatialSridRegex = regexp.MustCompile("(?i)\\sSRID\\s\\d*")
 
+// Regexps to extract triggers from mysqldump output, e.g.
+// /*!50003 CREATE*/ /*!50017 DEFINER=`root`@`%`*/ /*!50003 TRIGGER `t` BEFORE UPDATE ON `tbl` FOR EACH ROW SET NEW.updated_at = NOW() */;;
+var versionCommentRegexp = regexp.MustCompile("/\\*!\\d*|\\*/")
+var triggerRegexp = regexp.MustCompile("(?is)\\bCREATE\\b.*?\\bTRIGGER\\s+(\\S+)\\s+(BEFORE|AFTER)\\s+(INSERT|UPDATE|DELETE)\\s+ON\\s+(\\S+)\\s+FOR\\s+EACH\\s+ROW\\s+(.*)")
+var delimiterRegexp = regexp.MustCompile("(?is)\\s*\\bDELIMITER\\b.*$")
+
 // ProcessMySQLDump reads mysqldump data from r and does schema or data conversion,
 // depending on whether conv is configured for schema mode or data mode.
 // In schema mode, ProcessMySQLDump incrementally builds a schema (updating conv).
@@ -66,13 +72,18 @@ func ProcessMySQLDump(conv *internal.Conv, r *internal.Reader) error {
 			isInsert := processStatement(conv, stmt)
 			internal.VerbosePrintf("Parsed SQL command at line=%d/fpos=%d: %d stmts (%d lines, %d bytes) Insert Statement=%v\n", startLine, startOffset, 1, r.LineNumber-startLine, len(b), isInsert)
 		}
-		if r.EOF {
+		// The rest of the dump is skipped once data conversion is
+		// aborted.
+		if r.EOF || conv.Aborted() != nil {
 			break
 		}
 	}
 	if conv.SchemaMode() {
-		schemaToDDL(conv)
+		if err := schemaToDDL(conv); err != nil {
+			return err
+		}
 		conv.AddPrimaryKeys()
+		conv.AddSequences()
 	}
 	return nil
 }
@@ -167,9 +178,10 @@ func processCreateIndex(conv *internal.Conv, stmt *ast.CreateIndexStmt) {
 	if _, ok := conv.SrcSchema[tableName]; ok {
 		ctable := conv.SrcSchema[tableName]
 		ctable.Indexes = append(ctable.Indexes, schema.Index{
-			Name:   stmt.IndexName,
-			Unique: (stmt.KeyType == ast.IndexKeyTypeUnique),
-			Keys:   toSchemaKeys(stmt.IndexPartSpecifications),
+			Name:       stmt.IndexName,
+			Unique:     (stmt.KeyType == ast.IndexKeyTypeUnique),
+			Keys:       toSchemaKeys(stmt.IndexPartSpecifications),
+			Constraint: (stmt.KeyType == ast.IndexKeyTypeUnique),
 		})
 		conv.SrcSchema[tableName] = ctable
 	} else {
@@ -239,20 +251,94 @@ func processCreateTable(conv *internal.Conv, stmt *ast.CreateTableStmt) {
 			// TODO: Avoid Spanner-specific schema transformations in this file -- they should only
 			// appear in toddl.go. This file should focus on generic transformation from source
 			// database schemas into schema.go.
-			index = append(index, schema.Index{Name: "", Unique: true, Keys: []schema.Key{schema.Key{Column: colname, Desc: false}}})
+			index = append(index, schema.Index{Name: "", Unique: true, Keys: []schema.Key{schema.Key{Column: colname, Desc: false}}, Constraint: true})
+		}
+	}
+	// Text columns without a collation of their own use the default
+	// collation of the table.
+	var collation string
+	for _, o := range stmt.Options {
+		switch {
+		case o.Tp == ast.TableOptionCollate:
+			collation = o.StrValue
+		case o.Tp == ast.TableOptionCharset && collation == "":
+			collation = defaultCollation(o.StrValue)
+		}
+	}
+	for _, c := range colNames {
+		if col := colDef[c]; col.Collation == "" && isTextType(col.Type.Name) {
+			col.Collation = collation
+			colDef[c] = col
 		}
 	}
 	conv.SchemaStatement(NodeType(stmt))
-	conv.SrcSchema[tableName] = schema.Table{
+	conv.SetSrcTable(schema.Table{
 		Name:        tableName,
 		ColNames:    colNames,
 		ColDefs:     colDef,
 		PrimaryKeys: keys,
 		ForeignKeys: fkeys,
-		Indexes:     index}
+		Indexes:     index})
 	for _, constraint := range stmt.Constraints {
 		processConstraint(conv, tableName, constraint, "CREATE TABLE")
 	}
+	// mysqldump only writes the AUTO_INCREMENT table option for tables
+	// whose AUTO_INCREMENT value has moved past its initial value of 1.
+	next := int64(1)
+	for _, o := range stmt.Options {
+		if o.Tp == ast.TableOptionAutoIncrement {
+			next = int64(o.UintValue)
+		}
+	}
+	addAutoIncrementSequence(conv, tableName, next)
+}
+
+// defaultCollations are the default collations of common character sets.
+// Dumps of MySQL 8.0 tables always name their collation, so this is only
+// needed for older versions, whose default utf8mb4 collation is
+// utf8mb4_general_ci.
+var defaultCollations = map[string]string{
+	"binary": "binary",
+	"latin1": "latin1_swedish_ci",
+	"ucs2":   "ucs2_general_ci",
+	"utf8":   "utf8_general_ci",
+}
+
+// defaultCollation returns the default collation of charset.
+func defaultCollation(charset string) string {
+	charset = strings.ToLower(charset)
+	if c, ok := defaultCollations[charset]; ok {
+		return c
+	}
+	return charset + "_general_ci"
+}
+
+// isTextType returns true if columns of MySQL type ty have a collation.
+func isTextType(ty string) bool {
+	switch ty {
+	case "char", "varchar", "tinytext", "text", "mediumtext", "longtext", "enum", "set":
+		return true
+	}
+	return false
+}
+
+// addAutoIncrementSequence records the AUTO_INCREMENT column of table (if
+// it has one) as a sequence whose next value is next. Spanner has no
+// AUTO_INCREMENT columns, but the sequence is converted to a Spanner
+// sequence when the target supports sequences (see AddSequences), and the
+// next value is listed in the report and the session file in any case, so
+// that new keys don't collide with migrated ones.
+func addAutoIncrementSequence(conv *internal.Conv, table string, next int64) {
+	srcTable, ok := conv.SrcSchema[table]
+	if !ok {
+		return
+	}
+	for _, c := range srcTable.ColNames {
+		if srcTable.ColDefs[c].Ignored.AutoIncrement {
+			conv.SetSrcSequence(schema.Sequence{Name: fmt.Sprintf("%s_%s_seq", table, c), Increment: 1, Next: next, Table: table, Column: c})
+			return
+		}
+	}
 }
 
 func processConstraint(conv *internal.Conv, table string, constraint *ast.Constraint, stmtType string) {
@@ -269,12 +355,14 @@ func processConstraint(conv *internal.Conv, table string, constraint *ast.Constr
 		updateCols(conv, ast.ConstraintPrimaryKey, constraint.Keys, st.ColDefs, table)
 	case ast.ConstraintForeignKey:
 		st.ForeignKeys = append(st.ForeignKeys, toForeignKeys(conv, constraint))
-	case ast.ConstraintIndex:
+	case ast.ConstraintIndex, ast.ConstraintKey, ast.ConstraintFulltext:
+		// Fulltext indexes are mapped to regular indexes, like
+		// information_schema does for direct connections to MySQL.
 		st.Indexes = append(st.Indexes, schema.Index{Name: constraint.Name, Keys: toSchemaKeys(constraint.Keys)})
-	case ast.ConstraintUniq:
+	case ast.ConstraintUniq, ast.ConstraintUniqKey, ast.ConstraintUniqIndex:
 		// Convert unique column constraint in mysql to a corresponding unique index in schema
 		// Note that schema represents all unique constraints as indexes.
-		st.Indexes = append(st.Indexes, schema.Index{Name: constraint.Name, Unique: true, Keys: toSchemaKeys(constraint.Keys)})
+		st.Indexes = append(st.Indexes, schema.Index{Name: constraint.Name, Unique: true, Keys: toSchemaKeys(constraint.Keys), Constraint: true})
 	default:
 		updateCols(conv, ct, constraint.Keys, st.ColDefs, table)
 	}
@@ -289,9 +377,14 @@ func processConstraint(conv *internal.Conv, table string, constraint *ast.Constr
 // order. Check this for more details:
 // https://github.com/cloudspannerecosystem/harbourbridge/issues/96
 // TODO: Resolve ordering issue for non-primary keys.
+// Index prefix lengths e.g. KEY (name(10)) are recorded in the keys' Length.
 func toSchemaKeys(columns []*ast.IndexPartSpecification) (keys []schema.Key) {
 	for _, colname := range columns {
-		keys = append(keys, schema.Key{Column: colname.Column.Name.String()})
+		k := schema.Key{Column: colname.Column.Name.String()}
+		if colname.Length > 0 {
+			k.Length = int64(colname.Length)
+		}
+		keys = append(keys, k)
 	}
 	return keys
 }
@@ -352,29 +445,17 @@ func processAlterTable(conv *internal.Conv, stmt *ast.AlterTableStmt) {
 				processConstraint(conv, tableName, item.Constraint, "ALTER TABLE")
 				conv.SchemaStatement(NodeType(stmt))
 			case ast.AlterTableModifyColumn:
-				colname, col, constraint, err := processColumn(conv, tableName, item.NewColumns[0])
-				if err != nil {
+				if err := alterColumn(conv, tableName, item.NewColumns[0], false); err != nil {
 					logStmtError(conv, stmt, err)
 					return
 				}
-				conv.SrcSchema[tableName].ColDefs[colname] = col
-				if constraint.isPk {
-					ctable := conv.SrcSchema[tableName]
-					checkEmpty(conv, ctable.PrimaryKeys, "ALTER TABLE")
-					ctable.PrimaryKeys = []schema.Key{{Column: colname}}
-					conv.SrcSchema[tableName] = ctable
-				}
-				if constraint.fk.Columns != nil {
-					ctable := conv.SrcSchema[tableName]
-					ctable.ForeignKeys = append(ctable.ForeignKeys, constraint.fk)
-					conv.SrcSchema[tableName] = ctable
-				}
-				if constraint.isUniqueKey {
-					// Convert unique column constraint in mysql to a corresponding unique index in schema
-					// Note that schema represents all unique constraints as indexes.
-					ctable := conv.SrcSchema[tableName]
-					ctable.Indexes = append(ctable.Indexes, schema.Index{Name: "", Unique: true, Keys: []schema.Key{schema.Key{Column: colname, Desc: false}}})
-					conv.SrcSchema[tableName] = ctable
+				conv.SchemaStatement(NodeType(stmt))
+			case ast.AlterTableAddColumns:
+				for _, col := range item.NewColumns {
+					if err := alterColumn(conv, tableName, col, true); err != nil {
+						logStmtError(conv, stmt, err)
+						return
+					}
 				}
 				conv.SchemaStatement(NodeType(stmt))
 			default:
@@ -386,6 +467,35 @@ func processAlterTable(conv *internal.Conv, stmt *ast.AlterTableStmt) {
 	}
 }
 
+// alterColumn processes a column of an ALTER TABLE statement that
+// modifies (or if add is true, adds) a column of tableName, including its
+// column constraints (e.g. an inline foreign key).
+func alterColumn(conv *internal.Conv, tableName string, colDef *ast.ColumnDef, add bool) error {
+	colname, col, constraint, err := processColumn(conv, tableName, colDef)
+	if err != nil {
+		return err
+	}
+	ctable := conv.SrcSchema[tableName]
+	if _, ok := ctable.ColDefs[colname]; add && !ok {
+		ctable.ColNames = append(ctable.ColNames, colname)
+	}
+	ctable.ColDefs[colname] = col
+	if constraint.isPk {
+		checkEmpty(conv, ctable.PrimaryKeys, "ALTER TABLE")
+		ctable.PrimaryKeys = []schema.Key{{Column: colname}}
+	}
+	if constraint.fk.Columns != nil {
+		ctable.ForeignKeys = append(ctable.ForeignKeys, constraint.fk)
+	}
+	if constraint.isUniqueKey {
+		// Convert unique column constraint in mysql to a corresponding unique index in schema
+		// Note that schema represents all unique constraints as indexes.
+		ctable.Indexes = append(ctable.Indexes, schema.Index{Name: "", Unique: true, Keys: []schema.Key{schema.Key{Column: colname, Desc: false}}, Constraint: true})
+	}
+	conv.SrcSchema[tableName] = ctable
+	return nil
+}
+
 // getTableName extracts the table name from *ast.TableName table, and returns
 // the raw extracted name (the MySQL table name).
 // *ast.TableName is used to represent table names. It consists of two components:
@@ -423,7 +533,10 @@ func processColumn(conv *internal.Conv, tableName string, col *ast.ColumnDef) (s
 		Name:        tid,
 		Mods:        mods,
 		ArrayBounds: getArrayBounds(col.Tp.String(), col.Tp.Elems)}
-	column := schema.Column{Name: name, Type: ty}
+	column := schema.Column{Name: name, Type: ty, Collation: col.Tp.Collate}
+	if column.Collation == "" && col.Tp.Charset != "" {
+		column.Collation = defaultCollation(col.Tp.Charset)
+	}
 	return name, column, updateColsByOption(conv, tableName, col, &column), nil
 }
 
@@ -458,6 +571,8 @@ func updateColsByOption(conv *internal.Conv, tableName string, col *ast.ColumnDe
 			if !nullDefault {
 				column.Ignored.Default = true
 			}
+		case ast.ColumnOptionCollate:
+			column.Collation = elem.StrValue
 		case ast.ColumnOptionUniqKey:
 			cc.isUniqueKey = true
 		case ast.ColumnOptionCheck:
@@ -538,7 +653,13 @@ func handleParseError(conv *internal.Conv, chunk string, err error, l [][]byte)
 		if strings.Count(strings.ToLower(chunk), "delimiter") == 1 {
 			return nil, false
 		}
-		return nil, skipUnsupported(conv, strings.ToLower(chunk))
+		if !skipUnsupported(conv, strings.ToLower(chunk)) {
+			return nil, false
+		}
+		if conv.SchemaMode() {
+			processTrigger(conv, chunk)
+		}
+		return nil, true
 	}
 	// Check if error is due to Insert statement.
 	insertStmtPrefix := insertRegexp.FindString(chunk)
@@ -634,6 +755,30 @@ func skipUnsupported(conv *internal.Conv, chunk string) bool {
 	return true
 }
 
+// processTrigger records the trigger defined in chunk, if any. Triggers
+// are not converted, but they are analyzed in the report.
+func processTrigger(conv *internal.Conv, chunk string) {
+	chunk = versionCommentRegexp.ReplaceAllString(chunk, " ")
+	m := triggerRegexp.FindStringSubmatch(chunk)
+	if m == nil {
+		return
+	}
+	// The body ends with the custom delimiter (e.g. ';;'), possibly
+	// followed by the statement that restores the default delimiter.
+	body := strings.TrimSpace(delimiterRegexp.ReplaceAllString(m[5], ""))
+	body = strings.TrimSpace(strings.TrimRight(body, ";$/"))
+	table := m[4]
+	if i := strings.LastIndex(table, "."); i >= 0 {
+		table = table[i+1:]
+	}
+	conv.AddSrcTrigger(schema.Trigger{
+		Name:   strings.Trim(m[1], "`"),
+		Table:  strings.Trim(table, "`"),
+		Timing: strings.ToUpper(m[2] + " " + m[3]),
+		Body:   body,
+	})
+}
+
 // getArrayBounds calculate array bound for only set data type
 // and we do not expect multidimensional array.
 func getArrayBounds(ft string, elem []string) []int64 {
@@ -654,20 +799,23 @@ func processInsertStmt(conv *internal.Conv, stmt *ast.InsertStmt) {
 		return
 	}
 	if conv.SchemaMode() {
-		conv.Stats.Rows[srcTable] += int64(len(stmt.Lists))
+		conv.StatsAddRows(srcTable, int64(len(stmt.Lists)))
 		conv.DataStatement(NodeType(stmt))
 		return
 	}
+	if conv.SkipData(srcTable) {
+		return
+	}
 	spTable, err1 := internal.GetSpannerTable(conv, srcTable)
 	if err1 != nil {
 		logStmtError(conv, stmt, fmt.Errorf("can't get spanner table name for source table '%s' : err=%w", srcTable, err1))
 		return
 	}
-	spSchema, ok1 := conv.SpSchema[spTable]
+	spSchema, ok1 := conv.DataSchema(spTable)
 	srcSchema, ok2 := conv.SrcSchema[srcTable]
 	if !ok1 || !ok2 {
 		conv.Unexpected(fmt.Sprintf("Can't get schemas for table %s", srcTable))
-		conv.Stats.BadRows[srcTable] += conv.Stats.Rows[srcTable]
+		conv.StatsAddTableBadRows(srcTable)
 		return
 	}
 	srcCols, err2 := getCols(stmt)
@@ -677,14 +825,14 @@ func processInsertStmt(conv *internal.Conv, stmt *ast.InsertStmt) {
 		srcCols = conv.SrcSchema[srcTable].ColNames
 		if len(srcCols) == 0 {
 			conv.Unexpected(fmt.Sprintf("Can't get columns for table %s", srcTable))
-			conv.Stats.BadRows[srcTable] += conv.Stats.Rows[srcTable]
+			conv.StatsAddTableBadRows(srcTable)
 			return
 		}
 	}
 	spCols, err3 := internal.GetSpannerCols(conv, srcTable, srcCols)
 	if err3 != nil {
 		conv.Unexpected(fmt.Sprintf("Can't get spanner columns for table %s: err=%s", srcTable, err3))
-		conv.Stats.BadRows[srcTable] += conv.Stats.Rows[srcTable]
+		conv.StatsAddTableBadRows(srcTable)
 		return
 	}
 	var values []string
@@ -717,7 +865,17 @@ func getVals(row []ast.ExprNode) ([]string, error) {
 	for _, item := range row {
 		switch valueNode := item.(type) {
 		case *driver.ValueExpr:
-			values = append(values, fmt.Sprintf("%v", valueNode.GetValue()))
+			switch v := valueNode.GetValue().(type) {
+			case types.BinaryLiteral:
+				// Hex literals (e.g. 0x89504E47, as generated by
+				// mysqldump --hex-blob) and bit literals (e.g. b'1010').
+				// Use the raw bytes, rather than BinaryLiteral's "0x..."
+				// string representation, to match data read directly
+				// from MySQL.
+				values = append(values, string(v))
+			default:
+				values = append(values, fmt.Sprintf("%v", v))
+			}
 		case *ast.UnaryOperationExpr:
 			if valueNode.Op != opcode.Minus {
 				return nil, fmt.Errorf("unexpected UnaryOperationExpr node with opcode %v", valueNode.Op)
@@ -24,6 +24,7 @@ import (
 
 	"cloud.google.com/go/spanner"
 	"github.com/cloudspannerecosystem/harbourbridge/internal"
+	"github.com/cloudspannerecosystem/harbourbridge/schema"
 	"github.com/cloudspannerecosystem/harbourbridge/spanner/ddl"
 	"github.com/stretchr/testify/assert"
 )
@@ -720,6 +721,14 @@ CREATE TABLE test (a text PRIMARY KEY, b text);`,
 			expectedData: []spannerData{
 				spannerData{table: "test", cols: []string{"id", "a", "b", "c", "d"}, vals: []interface{}{int64(1), true, int64(42), "x", []byte{0x89, 0x50}}}},
 		},
+		{
+			name: "Data conversion: hex-blob and bit literals",
+			input: `
+	CREATE TABLE test (id integer PRIMARY KEY, a blob, b bit(8));
+	INSERT INTO test (id, a, b) VALUES (1, 0x8950, b'00001010');`,
+			expectedData: []spannerData{
+				spannerData{table: "test", cols: []string{"id", "a", "b"}, vals: []interface{}{int64(1), []byte{0x89, 0x50}, []byte{0x0a}}}},
+		},
 		{
 			name: "Data conversion: date, float, decimal, mediumint",
 			input: `
@@ -909,6 +918,59 @@ func TestProcessMySQLDump_AddPrimaryKeys(t *testing.T) {
 	}
 }
 
+// TestProcessMySQLDump_Indexes checks that constructs that information_schema
+// reports for direct connections to MySQL are also found in dumps.
+func TestProcessMySQLDump_Indexes(t *testing.T) {
+	conv, _ := runProcessMySQLDump(
+		"CREATE TABLE test (a SMALLINT, b text, c varchar(100), PRIMARY KEY (a));\n" +
+			"CREATE TABLE test2 (d SMALLINT, e text, f varchar(100), " +
+			"KEY idx_e (e(10)), UNIQUE INDEX idx_f (f), FULLTEXT KEY ft_e (e));\n" +
+			"ALTER TABLE test2 ADD COLUMN g SMALLINT REFERENCES test(a);\n")
+	noIssues(conv, t, "Indexes")
+	st := conv.SrcSchema["test2"]
+	assert.Equal(t, []string{"d", "e", "f", "g"}, st.ColNames)
+	assert.Equal(t, []schema.Index{
+		{Name: "idx_e", Keys: []schema.Key{{Column: "e", Length: 10}}},
+		{Name: "idx_f", Unique: true, Keys: []schema.Key{{Column: "f"}}},
+		{Name: "ft_e", Keys: []schema.Key{{Column: "e"}}}}, st.Indexes)
+	assert.Equal(t, 1, len(st.ForeignKeys))
+	assert.Equal(t, []string{"g"}, st.ForeignKeys[0].Columns)
+	assert.Equal(t, "test", st.ForeignKeys[0].ReferTable)
+	assert.Equal(t, []internal.SchemaIssue{internal.IndexPrefix}, conv.Issues["test2"]["e"])
+}
+
+func TestProcessMySQLDump_AutoIncrement(t *testing.T) {
+	conv := internal.MakeConv()
+	conv.Features = internal.DefaultFeatures()
+	conv.SetSchemaMode()
+	s := "CREATE TABLE test (id int NOT NULL AUTO_INCREMENT, a text, PRIMARY KEY (id)) ENGINE=InnoDB AUTO_INCREMENT=43 DEFAULT CHARSET=utf8mb4;\n" +
+		"CREATE TABLE test2 (id bigint NOT NULL AUTO_INCREMENT, PRIMARY KEY (id)) ENGINE=InnoDB;\n" +
+		"CREATE TABLE test3 (id bigint NOT NULL, PRIMARY KEY (id)) ENGINE=InnoDB;\n"
+	ProcessMySQLDump(conv, internal.NewReader(bufio.NewReader(strings.NewReader(s)), nil))
+	noIssues(conv, t, "AutoIncrement")
+	assert.Equal(t, map[string]schema.Sequence{
+		"test_id_seq":  {Name: "test_id_seq", Increment: 1, Next: 43, Table: "test", Column: "id"},
+		"test2_id_seq": {Name: "test2_id_seq", Increment: 1, Next: 1, Table: "test2", Column: "id"},
+	}, conv.SrcSequences)
+	assert.Equal(t, map[string]ddl.CreateSequence{
+		"test_id_seq":  {Name: "test_id_seq", StartWithCounter: 43},
+		"test2_id_seq": {Name: "test2_id_seq", StartWithCounter: 1},
+	}, conv.Sequences)
+}
+
+func TestProcessMySQLDump_Triggers(t *testing.T) {
+	conv := internal.MakeConv()
+	conv.SetSchemaMode()
+	s := "CREATE TABLE test (id int NOT NULL, updated_at datetime, PRIMARY KEY (id));\n" +
+		"DELIMITER ;;\n" +
+		"/*!50003 CREATE*/ /*!50017 DEFINER=`root`@`localhost`*/ /*!50003 TRIGGER `test_touch` BEFORE UPDATE ON `test` FOR EACH ROW SET NEW.updated_at = NOW() */;;\n" +
+		"DELIMITER ;\n"
+	ProcessMySQLDump(conv, internal.NewReader(bufio.NewReader(strings.NewReader(s)), nil))
+	assert.Equal(t, map[string][]schema.Trigger{
+		"test": {{Name: "test_touch", Table: "test", Timing: "BEFORE UPDATE", Body: "SET NEW.updated_at = NOW()"}},
+	}, conv.SrcTriggers)
+}
+
 func runProcessMySQLDump(s string) (*internal.Conv, []spannerData) {
 	conv := internal.MakeConv()
 	conv.SetLocation(time.UTC)
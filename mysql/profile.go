@@ -0,0 +1,109 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package mysql
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+
+	"github.com/cloudspannerecosystem/harbourbridge/internal"
+)
+
+// MaxStringLengths returns the length (in characters) of the longest
+// value of the source columns returned by colsOf for each table (e.g.
+// internal.Conv.UnboundedStringCols), by table and column. Columns with
+// no values are omitted. Each table is read in full.
+func MaxStringLengths(conv *internal.Conv, db *sql.DB, dbName string, colsOf func(srcTable string) []string) (map[string]map[string]int64, error) {
+	tables, err := getTables(db, dbName)
+	if err != nil {
+		return nil, err
+	}
+	lens := make(map[string]map[string]int64)
+	for _, t := range tables {
+		srcTable := t.name
+		if _, ok := conv.SrcSchema[srcTable]; !ok {
+			continue
+		}
+		cols := colsOf(srcTable)
+		if len(cols) == 0 {
+			continue
+		}
+		var exprs []string
+		for _, c := range cols {
+			exprs = append(exprs, fmt.Sprintf("MAX(CHAR_LENGTH(CONVERT(`%s` USING utf8mb4)))", c))
+		}
+		q := fmt.Sprintf("SELECT %s FROM `%s`.`%s`;", strings.Join(exprs, ", "), t.schema, t.name)
+		m, err := maxLengths(db, q, cols)
+		if err != nil {
+			conv.Unexpected(fmt.Sprintf("Couldn't get the length of the values of %s: %s", srcTable, err))
+			continue
+		}
+		lens[srcTable] = m
+	}
+	return lens, nil
+}
+
+// maxLengths runs a query returning the longest value of each of cols.
+func maxLengths(db *sql.DB, q string, cols []string) (map[string]int64, error) {
+	vals := make([]sql.NullInt64, len(cols))
+	ptrs := make([]interface{}, len(cols))
+	for i := range vals {
+		ptrs[i] = &vals[i]
+	}
+	if err := db.QueryRow(q).Scan(ptrs...); err != nil {
+		return nil, err
+	}
+	m := make(map[string]int64)
+	for i, c := range cols {
+		if vals[i].Valid {
+			m[c] = vals[i].Int64
+		}
+	}
+	return m, nil
+}
+
+// ProfileColumns profiles the columns of each table of conv (see
+// internal.Conv.ProfiledCols) from a sample of up to rows rows, and
+// records the profiles in conv. Distinct values are counted in the
+// sample, so they are a lower bound for the whole table.
+func ProfileColumns(conv *internal.Conv, db *sql.DB, dbName string, rows int64) error {
+	tables, err := getTables(db, dbName)
+	if err != nil {
+		return err
+	}
+	for _, t := range tables {
+		srcTable := t.name
+		if _, ok := conv.SrcSchema[srcTable]; !ok {
+			continue
+		}
+		cols := conv.ProfiledCols(srcTable)
+		if len(cols) == 0 {
+			continue
+		}
+		exprs := []string{"COUNT(*)"}
+		for _, c := range cols {
+			exprs = append(exprs, fmt.Sprintf("COUNT(`%s`)", c), fmt.Sprintf("COUNT(DISTINCT `%s`)", c))
+			if ok, _ := conv.ProfileMinMax(srcTable, c); ok {
+				exprs = append(exprs, fmt.Sprintf("CAST(MIN(`%s`) AS CHAR)", c), fmt.Sprintf("CAST(MAX(`%s`) AS CHAR)", c))
+			}
+		}
+		q := fmt.Sprintf("SELECT %s FROM (SELECT * FROM `%s`.`%s` LIMIT %d) t;", strings.Join(exprs, ", "), t.schema, t.name, rows)
+		if err := internal.ProfileTable(conv, db, srcTable, cols, q); err != nil {
+			conv.Unexpected(fmt.Sprintf("Couldn't profile %s: %s", srcTable, err))
+		}
+	}
+	return nil
+}
@@ -0,0 +1,47 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package mysql
+
+import (
+	"errors"
+	"time"
+
+	"github.com/go-sql-driver/mysql"
+
+	"github.com/cloudspannerecosystem/harbourbridge/internal"
+)
+
+// discoveryRetry is how schema discovery queries that fail with
+// transient errors are retried.
+var discoveryRetry = internal.RetryPolicy{Attempts: 5, Delay: time.Second, Transient: isTransient}
+
+// isTransient returns true for errors caused by a busy server or a
+// broken connection, which may not happen again if the query is retried.
+func isTransient(err error) bool {
+	var myErr *mysql.MySQLError
+	if !errors.As(err, &myErr) {
+		return errors.Is(err, mysql.ErrInvalidConn) || internal.IsTransientConnError(err)
+	}
+	switch myErr.Number {
+	case 1040, // Too many connections.
+		1053, // Server shutdown in progress.
+		1205, // Lock wait timeout exceeded.
+		1213, // Deadlock found when trying to get lock.
+		1317, // Query execution was interrupted.
+		3024: // Query execution was interrupted, maximum statement execution time exceeded.
+		return true
+	}
+	return false
+}
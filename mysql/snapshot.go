@@ -0,0 +1,69 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package mysql
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/cloudspannerecosystem/harbourbridge/internal"
+)
+
+// CurrentPosition returns the current replication position of the
+// server: its executed GTID set if GTIDs are enabled, and otherwise its
+// binary log file and position. The position is empty if binary logging
+// is disabled. version is the version of the server (see ServerVersion),
+// which tells whether it has GTIDs (see HasGTIDs).
+func CurrentPosition(db internal.Queryer, version internal.ServerVersion) (internal.SnapshotPosition, error) {
+	p := internal.SnapshotPosition{Kind: "gtid", Time: time.Now()}
+	if HasGTIDs(version) {
+		if err := db.QueryRow("SELECT @@GLOBAL.gtid_executed").Scan(&p.Position); err != nil {
+			return p, fmt.Errorf("can't get executed GTID set: %w", err)
+		}
+		if p.Position != "" {
+			return p, nil
+		}
+	}
+	// SHOW MASTER STATUS was renamed in MySQL 8.2.
+	status := "SHOW MASTER STATUS"
+	if version.Product == "MySQL" && version.AtLeast(8, 2) {
+		status = "SHOW BINARY LOG STATUS"
+	}
+	rows, err := db.Query(status)
+	if err != nil {
+		return p, fmt.Errorf("can't get binary log position: %w", err)
+	}
+	defer rows.Close()
+	cols, err := rows.Columns()
+	if err != nil {
+		return p, err
+	}
+	if !rows.Next() {
+		return internal.SnapshotPosition{}, nil
+	}
+	// The first two columns are the file and position; the others vary
+	// between versions.
+	vals := make([]sql.RawBytes, len(cols))
+	args := make([]interface{}, len(cols))
+	for i := range vals {
+		args[i] = &vals[i]
+	}
+	if err := rows.Scan(args...); err != nil || len(vals) < 2 {
+		return p, fmt.Errorf("can't read binary log position: %v", err)
+	}
+	p.Kind, p.Position = "binlog", fmt.Sprintf("%s:%s", vals[0], vals[1])
+	return p, nil
+}
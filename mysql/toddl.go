@@ -32,24 +32,25 @@ import (
 // Spanner. It uses the source schema in conv.SrcSchema, and writes
 // the Spanner schema to conv.SpSchema.
 func schemaToDDL(conv *internal.Conv) error {
-	// Tracks Spanner names that have been used for foreign key constraints
-	// and indexes. We use this to ensure we generate unique names when
-	// we map from MySQL to Spanner since Spanner requires all foreign
-	// key and index names to be distinct (you can't use the same name
-	// for a foreign key constraint and an index).
-	usedNames := make(map[string]bool)
+	if err := conv.RunPreTableHooks(); err != nil {
+		return err
+	}
 	// As Spanner uses same namespace for table names, foreign key constraint
-	// names and index names, we need to pre-populate usedNames with Spanner table
-	// names to handle collision with foreign key names and index names.
-	for _, srcTable := range conv.SrcSchema {
+	// names and index names, we need to reserve Spanner table names to
+	// handle collision with foreign key names and index names.
+	// Process tables in a deterministic order, so that Spanner names are
+	// allocated the same way in every run.
+	for _, t := range conv.SrcTables() {
+		srcTable := conv.SrcSchema[t]
 		spTableName, err := internal.GetSpannerTable(conv, srcTable.Name)
 		if err != nil {
 			conv.Unexpected(fmt.Sprintf("Couldn't map source table %s to Spanner: %s", srcTable.Name, err))
 			continue
 		}
-		usedNames[spTableName] = true
+		conv.ReserveName(spTableName)
 	}
-	for _, srcTable := range conv.SrcSchema {
+	for _, t := range conv.SrcTables() {
+		srcTable := conv.SrcSchema[t]
 		spTableName, err := internal.GetSpannerTable(conv, srcTable.Name)
 		if err != nil {
 			conv.Unexpected(fmt.Sprintf("Couldn't map source table %s to Spanner: %s", srcTable.Name, err))
@@ -57,7 +58,8 @@ func schemaToDDL(conv *internal.Conv) error {
 		}
 		var spColNames []string
 		spColDef := make(map[string]ddl.ColumnDef)
-		conv.Issues[srcTable.Name] = make(map[string][]internal.SchemaIssue)
+		colIssues := make(map[string][]internal.SchemaIssue)
+		prefixCols := indexPrefixCols(srcTable)
 		// Iterate over columns using ColNames order.
 		for _, srcColName := range srcTable.ColNames {
 			srcCol := srcTable.ColDefs[srcColName]
@@ -82,8 +84,11 @@ func schemaToDDL(conv *internal.Conv) error {
 			if srcCol.Ignored.AutoIncrement {
 				issues = append(issues, internal.AutoIncrement)
 			}
+			if prefixCols[srcCol.Name] {
+				issues = append(issues, internal.IndexPrefix)
+			}
 			if len(issues) > 0 {
-				conv.Issues[srcTable.Name][srcCol.Name] = issues
+				colIssues[srcCol.Name] = issues
 			}
 			ty.IsArray = len(srcCol.Type.ArrayBounds) == 1
 			spColDef[colName] = ddl.ColumnDef{
@@ -94,14 +99,18 @@ func schemaToDDL(conv *internal.Conv) error {
 			}
 		}
 		comment := "Spanner schema for source table " + quoteIfNeeded(srcTable.Name)
-		conv.SpSchema[spTableName] = ddl.CreateTable{
+		conv.SetIssues(srcTable.Name, colIssues)
+		ct := ddl.CreateTable{
 			Name:     spTableName,
 			ColNames: spColNames,
 			ColDefs:  spColDef,
 			Pks:      cvtPrimaryKeys(conv, srcTable.Name, srcTable.PrimaryKeys),
-			Fks:      cvtForeignKeys(conv, srcTable.Name, srcTable.ForeignKeys, usedNames),
-			Indexes:  cvtIndexes(conv, spTableName, srcTable.Name, srcTable.Indexes, usedNames),
+			Fks:      cvtForeignKeys(conv, srcTable.Name, srcTable.ForeignKeys),
+			Indexes:  cvtIndexes(conv, spTableName, srcTable.Name, srcTable.Indexes),
 			Comment:  comment}
+		if err := conv.AddConvertedTable(srcTable.Name, ct); err != nil {
+			return err
+		}
 	}
 	internal.ResolveRefs(conv)
 	return nil
@@ -118,12 +127,15 @@ func toSpannerType(conv *internal.Conv, id string, mods []int64) (ddl.Type, []in
 	case "tinyint":
 		// tinyint(1) is a bool in MySQL
 		if len(mods) > 0 && mods[0] == 1 {
-			return ddl.Type{Name: ddl.Bool}, nil
+			return ddl.Type{Name: ddl.Bool}, []internal.SchemaIssue{internal.TinyintBool}
 		}
 		return ddl.Type{Name: ddl.Int64}, []internal.SchemaIssue{internal.Widened}
 	case "double":
 		return ddl.Type{Name: ddl.Float64}, nil
 	case "float":
+		if conv.Supports(internal.Float32) {
+			return ddl.Type{Name: ddl.Float32}, nil
+		}
 		return ddl.Type{Name: ddl.Float64}, []internal.SchemaIssue{internal.Widened}
 	case "numeric", "decimal":
 		// MySQL's NUMERIC type can store up to 65 digits, with up to 30 after the
@@ -150,6 +162,9 @@ func toSpannerType(conv *internal.Conv, id string, mods []int64) (ddl.Type, []in
 	case "set", "enum":
 		return ddl.Type{Name: ddl.String, Len: ddl.MaxLength}, nil
 	case "json":
+		if conv.Supports(internal.JSON) {
+			return ddl.Type{Name: ddl.JSON}, nil
+		}
 		return ddl.Type{Name: ddl.String, Len: ddl.MaxLength}, nil
 	case "binary", "varbinary":
 		return ddl.Type{Name: ddl.Bytes, Len: ddl.MaxLength}, nil
@@ -167,6 +182,20 @@ func toSpannerType(conv *internal.Conv, id string, mods []int64) (ddl.Type, []in
 	return ddl.Type{Name: ddl.String, Len: ddl.MaxLength}, []internal.SchemaIssue{internal.NoGoodType}
 }
 
+// indexPrefixCols returns the columns of srcTable that are indexed with
+// a prefix length in some index. Spanner indexes whole columns.
+func indexPrefixCols(srcTable schema.Table) map[string]bool {
+	m := make(map[string]bool)
+	for _, index := range srcTable.Indexes {
+		for _, k := range index.Keys {
+			if k.Length > 0 {
+				m[k.Column] = true
+			}
+		}
+	}
+	return m
+}
+
 func quoteIfNeeded(s string) string {
 	for _, r := range s {
 		if unicode.IsLetter(r) || unicode.IsDigit(r) || unicode.IsPunct(r) {
@@ -190,7 +219,7 @@ func cvtPrimaryKeys(conv *internal.Conv, srcTable string, srcKeys []schema.Key)
 	return spKeys
 }
 
-func cvtForeignKeys(conv *internal.Conv, srcTable string, srcKeys []schema.ForeignKey, usedNames map[string]bool) []ddl.Foreignkey {
+func cvtForeignKeys(conv *internal.Conv, srcTable string, srcKeys []schema.ForeignKey) []ddl.Foreignkey {
 	var spKeys []ddl.Foreignkey
 	for _, key := range srcKeys {
 		if len(key.Columns) != len(key.ReferColumns) {
@@ -213,7 +242,7 @@ func cvtForeignKeys(conv *internal.Conv, srcTable string, srcKeys []schema.Forei
 			spCols = append(spCols, spCol)
 			spReferCols = append(spReferCols, spReferCol)
 		}
-		spKeyName := internal.ToSpannerForeignKey(key.Name, usedNames)
+		spKeyName := conv.ForeignKeyName(srcTable, key.Name, key.Columns)
 		spKey := ddl.Foreignkey{
 			Name:         spKeyName,
 			Columns:      spCols,
@@ -224,11 +253,13 @@ func cvtForeignKeys(conv *internal.Conv, srcTable string, srcKeys []schema.Forei
 	return spKeys
 }
 
-func cvtIndexes(conv *internal.Conv, spTableName string, srcTable string, srcIndexes []schema.Index, usedNames map[string]bool) []ddl.CreateIndex {
+func cvtIndexes(conv *internal.Conv, spTableName string, srcTable string, srcIndexes []schema.Index) []ddl.CreateIndex {
 	var spIndexes []ddl.CreateIndex
 	for _, srcIndex := range srcIndexes {
 		var spKeys []ddl.IndexKey
+		var srcCols []string
 		for _, k := range srcIndex.Keys {
+			srcCols = append(srcCols, k.Column)
 			spCol, err := internal.GetSpannerCol(conv, srcTable, k.Column, true)
 			if err != nil {
 				conv.Unexpected(fmt.Sprintf("Can't map index key column name for table %s", srcTable))
@@ -236,12 +267,17 @@ func cvtIndexes(conv *internal.Conv, spTableName string, srcTable string, srcInd
 			}
 			spKeys = append(spKeys, ddl.IndexKey{Col: spCol, Desc: k.Desc})
 		}
+		if srcIndex.Name == "" && srcIndex.Constraint && len(srcIndex.Keys) > 0 {
+			// Name unnamed UNIQUE constraints like MySQL does: after
+			// their first column.
+			srcIndex.Name = srcIndex.Keys[0].Column
+		}
 		if srcIndex.Name == "" {
 			// Generate a name if index name is empty in MySQL.
-			// Collision of index name will be handled by ToSpannerIndexName.
+			// Collision of index name will be handled by IndexName.
 			srcIndex.Name = fmt.Sprintf("Index_%s", srcTable)
 		}
-		spIndexName := internal.ToSpannerIndexName(srcIndex.Name, usedNames)
+		spIndexName := conv.IndexName(srcTable, srcIndex.Name, srcCols)
 		spIndex := ddl.CreateIndex{
 			Name:   spIndexName,
 			Table:  spTableName,
@@ -88,10 +88,46 @@ func TestToSpannerType(t *testing.T) {
 	expectedIssues := map[string][]internal.SchemaIssue{
 		"a": []internal.SchemaIssue{internal.Widened},
 		"b": []internal.SchemaIssue{internal.Widened},
+		"c": []internal.SchemaIssue{internal.TinyintBool},
 	}
 	assert.Equal(t, expectedIssues, conv.Issues[name])
 }
 
+func TestToSpannerType_Features(t *testing.T) {
+	conv := internal.MakeConv()
+	ty, _ := toSpannerType(conv, "json", nil)
+	assert.Equal(t, ddl.Type{Name: ddl.String, Len: ddl.MaxLength}, ty)
+	ty, issues := toSpannerType(conv, "float", nil)
+	assert.Equal(t, ddl.Type{Name: ddl.Float64}, ty)
+	assert.Equal(t, []internal.SchemaIssue{internal.Widened}, issues)
+	conv.Features = internal.Features{internal.JSON: true, internal.Float32: true}
+	ty, _ = toSpannerType(conv, "json", nil)
+	assert.Equal(t, ddl.Type{Name: ddl.JSON}, ty)
+	ty, issues = toSpannerType(conv, "float", nil)
+	assert.Equal(t, ddl.Type{Name: ddl.Float32}, ty)
+	assert.Nil(t, issues)
+}
+
+func TestCvtIndexes_UniqueConstraints(t *testing.T) {
+	conv := internal.MakeConv()
+	conv.SetSrcTable(schema.Table{
+		Name:     "test",
+		ColNames: []string{"a", "b", "c"},
+		ColDefs:  map[string]schema.Column{"a": {Name: "a"}, "b": {Name: "b"}, "c": {Name: "c"}},
+	})
+	internal.GetSpannerTable(conv, "test")
+	internal.GetSpannerCols(conv, "test", []string{"a", "b", "c"})
+	conv.ReserveName("test")
+	indexes := []schema.Index{
+		{Name: "", Unique: true, Keys: []schema.Key{{Column: "b"}, {Column: "c"}}, Constraint: true},
+		{Name: "", Unique: true, Keys: []schema.Key{{Column: "a"}}},
+	}
+	assert.Equal(t, []ddl.CreateIndex{
+		{Name: "b", Table: "test", Unique: true, Keys: []ddl.IndexKey{{Col: "b"}, {Col: "c"}}},
+		{Name: "Index_test", Table: "test", Unique: true, Keys: []ddl.IndexKey{{Col: "a"}}},
+	}, cvtIndexes(conv, "test", "test", indexes))
+}
+
 func dropComments(t *ddl.CreateTable) {
 	t.Comment = ""
 	for _, c := range t.ColNames {
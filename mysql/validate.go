@@ -0,0 +1,83 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package mysql
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+
+	"github.com/cloudspannerecosystem/harbourbridge/internal"
+)
+
+// RecentRows returns the n rows of srcTable (in database dbName) with the
+// largest primary keys (usually the most recently inserted ones),
+// converted to Spanner values. Rows are maps from Spanner column to
+// value, without NULL values.
+func RecentRows(conv *internal.Conv, db *sql.DB, dbName, srcTable string, n int) ([]map[string]interface{}, error) {
+	srcSchema, ok := conv.SrcSchema[srcTable]
+	if !ok || len(srcSchema.PrimaryKeys) == 0 {
+		return nil, fmt.Errorf("table %s has no primary key", srcTable)
+	}
+	srcCols := srcSchema.ColNames
+	var order []string
+	for _, k := range srcSchema.PrimaryKeys {
+		order = append(order, fmt.Sprintf("`%s` DESC", k.Column))
+	}
+	spTable, err := internal.GetSpannerTable(conv, srcTable)
+	if err != nil {
+		return nil, err
+	}
+	spCols, err := internal.GetSpannerCols(conv, srcTable, srcCols)
+	if err != nil {
+		return nil, err
+	}
+	spSchema, ok := conv.DataSchema(spTable)
+	if !ok {
+		return nil, fmt.Errorf("can't get schema of Spanner table %s", spTable)
+	}
+	q := fmt.Sprintf("SELECT %s FROM `%s`.`%s` ORDER BY %s LIMIT %d;", buildColNameList(srcSchema, srcCols, conv.SrcVersion), dbName, srcTable, strings.Join(order, ", "), n)
+	rows, err := db.Query(q)
+	if err != nil {
+		return nil, fmt.Errorf("couldn't get rows of table %s: %w", srcTable, err)
+	}
+	defer rows.Close()
+	var l []map[string]interface{}
+	v, scanArgs := buildVals(len(srcCols))
+	for rows.Next() {
+		if err := rows.Scan(scanArgs...); err != nil {
+			return nil, fmt.Errorf("couldn't read row of table %s: %w", srcTable, err)
+		}
+		_, cvtCols, cvtVals, err := ConvertData(conv, srcTable, srcCols, srcSchema, spTable, spCols, spSchema, valsToStrings(v))
+		if err != nil {
+			return nil, err
+		}
+		r := make(map[string]interface{})
+		for i, c := range cvtCols {
+			r[c] = cvtVals[i]
+		}
+		l = append(l, r)
+	}
+	return l, rows.Err()
+}
+
+// CountRows returns the number of rows of srcTable (in database dbName).
+func CountRows(db *sql.DB, dbName, srcTable string) (int64, error) {
+	var n int64
+	if err := db.QueryRow(fmt.Sprintf("SELECT COUNT(*) FROM `%s`.`%s`;", dbName, srcTable)).Scan(&n); err != nil {
+		return 0, fmt.Errorf("couldn't count rows of table %s: %w", srcTable, err)
+	}
+	return n, nil
+}
@@ -0,0 +1,59 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package mysql
+
+import (
+	"fmt"
+
+	"github.com/cloudspannerecosystem/harbourbridge/internal"
+)
+
+// ServerVersion returns the version of the MySQL (or MariaDB) server of
+// db.
+func ServerVersion(db internal.Queryer) (internal.ServerVersion, error) {
+	var s string
+	if err := db.QueryRow("SELECT VERSION()").Scan(&s); err != nil {
+		return internal.ServerVersion{}, fmt.Errorf("can't get MySQL version: %w", err)
+	}
+	return internal.ParseServerVersion("MySQL", s)
+}
+
+// HasGTIDs returns true if servers of version v have MySQL GTIDs, which
+// were added in MySQL 5.6. MariaDB has its own GTIDs, which aren't
+// supported.
+func HasGTIDs(v internal.ServerVersion) bool {
+	return v.Product != "MariaDB" && v.AtLeast(5, 6)
+}
+
+// CanWaitForGTIDs returns true if servers of version v can wait for a
+// GTID set to be executed (with WAIT_FOR_EXECUTED_GTID_SET, added in
+// MySQL 5.7).
+func CanWaitForGTIDs(v internal.ServerVersion) bool {
+	return HasGTIDs(v) && v.AtLeast(5, 7)
+}
+
+// VersionWarnings describes what HarbourBridge can't read from MySQL
+// servers of version v. Discovery queries are adjusted to older versions
+// (e.g. spatial values are read with AsText before MySQL 5.6), but the
+// positions of sources without MySQL GTIDs are binary log positions.
+func VersionWarnings(v internal.ServerVersion) []string {
+	switch {
+	case v.Product == "MariaDB":
+		return []string{fmt.Sprintf("%s: MariaDB GTIDs are not supported, source positions are binary log positions", v)}
+	case !HasGTIDs(v):
+		return []string{fmt.Sprintf("%s is older than 5.6, the oldest version with GTIDs: source positions are binary log positions", v)}
+	}
+	return nil
+}
@@ -0,0 +1,121 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package postgres
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/cloudspannerecosystem/harbourbridge/internal"
+)
+
+// ScanAnomalies scans the source database for data that will cause
+// problems during data conversion (see internal.AnomalyKind), so that
+// it can be fixed before any data is loaded. Only the columns and
+// foreign keys of tables in conv.SrcSchema are scanned.
+//
+// PostgreSQL validates text against the database encoding, so text
+// columns are only scanned for invalid UTF-8 in SQL_ASCII databases.
+func ScanAnomalies(conv *internal.Conv, db *sql.DB) ([]internal.Anomaly, error) {
+	tables, err := getTables(db)
+	if err != nil {
+		return nil, err
+	}
+	var encoding string
+	if err := db.QueryRow("SHOW server_encoding").Scan(&encoding); err != nil {
+		return nil, fmt.Errorf("couldn't get server encoding: %w", err)
+	}
+	names := make(map[string]schemaAndName)
+	for _, t := range tables {
+		names[buildTableName(t.schema, t.name)] = t
+	}
+	var anomalies []internal.Anomaly
+	add := func(table, col string, kind internal.AnomalyKind, q string, count func(*sql.DB, string) (int64, error)) {
+		n, err := count(db, q)
+		if err != nil {
+			conv.Unexpected(fmt.Sprintf("Couldn't scan %s for %s: %s", table, kind, err))
+			return
+		}
+		if n > 0 {
+			anomalies = append(anomalies, internal.Anomaly{Table: table, Column: col, Kind: kind, Count: n})
+		}
+	}
+	for _, t := range tables {
+		srcTable := buildTableName(t.schema, t.name)
+		srcSchema, ok := conv.SrcSchema[srcTable]
+		if !ok {
+			continue
+		}
+		from := fmt.Sprintf(`"%s"."%s"`, t.schema, t.name)
+		cols := internal.AnomalyColumns(conv, srcTable)
+		if encoding == "SQL_ASCII" {
+			for _, c := range cols[internal.InvalidUTF8] {
+				add(srcTable, c, internal.InvalidUTF8, fmt.Sprintf(`SELECT convert_to("%s"::text, 'SQL_ASCII') FROM %s WHERE "%s" IS NOT NULL;`, c, from, c), countInvalidUTF8)
+			}
+		}
+		for _, c := range cols[internal.NumericOutOfRange] {
+			add(srcTable, c, internal.NumericOutOfRange, fmt.Sprintf(`SELECT COUNT(*) FROM %s WHERE "%s" = 'NaN' OR abs("%s") >= 1e29 OR "%s" <> round("%s", 9);`, from, c, c, c, c), countRows)
+		}
+		for _, c := range cols[internal.InvalidDate] {
+			// Also matches infinity and -infinity.
+			add(srcTable, c, internal.InvalidDate, fmt.Sprintf(`SELECT COUNT(*) FROM %s WHERE "%s" < '0001-01-01' OR "%s" >= '10000-01-01';`, from, c, c), countRows)
+		}
+		for _, fk := range srcSchema.ForeignKeys {
+			ref, ok := names[fk.ReferTable]
+			if !ok || len(fk.Columns) != len(fk.ReferColumns) {
+				continue
+			}
+			var notNull, match []string
+			for i, c := range fk.Columns {
+				notNull = append(notNull, fmt.Sprintf(`c."%s" IS NOT NULL`, c))
+				match = append(match, fmt.Sprintf(`p."%s" = c."%s"`, fk.ReferColumns[i], c))
+			}
+			q := fmt.Sprintf(`SELECT COUNT(*) FROM %s c WHERE %s AND NOT EXISTS (SELECT 1 FROM "%s"."%s" p WHERE %s);`,
+				from, strings.Join(notNull, " AND "), ref.schema, ref.name, strings.Join(match, " AND "))
+			add(srcTable, fk.Name, internal.OrphanedRow, q, countRows)
+		}
+	}
+	return anomalies, nil
+}
+
+// countRows runs a 'SELECT COUNT(*)' query.
+func countRows(db *sql.DB, q string) (int64, error) {
+	var n int64
+	err := db.QueryRow(q).Scan(&n)
+	return n, err
+}
+
+// countInvalidUTF8 runs a query that returns a single column of raw
+// bytes, and counts the values that aren't valid UTF-8.
+func countInvalidUTF8(db *sql.DB, q string) (int64, error) {
+	rows, err := db.Query(q)
+	if err != nil {
+		return 0, err
+	}
+	defer rows.Close()
+	var n int64
+	var b []byte
+	for rows.Next() {
+		if err := rows.Scan(&b); err != nil {
+			return n, err
+		}
+		if !utf8.Valid(b) {
+			n++
+		}
+	}
+	return n, rows.Err()
+}
@@ -0,0 +1,85 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package postgres
+
+import (
+	"database/sql/driver"
+	"regexp"
+	"testing"
+
+	"github.com/cloudspannerecosystem/harbourbridge/internal"
+	"github.com/cloudspannerecosystem/harbourbridge/schema"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestScanAnomalies(t *testing.T) {
+	conv := internal.MakeConv()
+	conv.SrcSchema["parent"] = schema.Table{
+		Name:        "parent",
+		ColNames:    []string{"id"},
+		ColDefs:     map[string]schema.Column{"id": schema.Column{Name: "id", Type: schema.Type{Name: "int8"}}},
+		PrimaryKeys: []schema.Key{schema.Key{Column: "id"}},
+	}
+	conv.SrcSchema["child"] = schema.Table{
+		Name:     "child",
+		ColNames: []string{"id", "pid", "s", "n", "d"},
+		ColDefs: map[string]schema.Column{
+			"id":  schema.Column{Name: "id", Type: schema.Type{Name: "int8"}},
+			"pid": schema.Column{Name: "pid", Type: schema.Type{Name: "int8"}},
+			"s":   schema.Column{Name: "s", Type: schema.Type{Name: "text"}},
+			"n":   schema.Column{Name: "n", Type: schema.Type{Name: "numeric"}},
+			"d":   schema.Column{Name: "d", Type: schema.Type{Name: "date"}},
+		},
+		PrimaryKeys: []schema.Key{schema.Key{Column: "id"}},
+		ForeignKeys: []schema.ForeignKey{schema.ForeignKey{Name: "fk", Columns: []string{"pid"}, ReferTable: "parent", ReferColumns: []string{"id"}}},
+	}
+	assert.Nil(t, schemaToDDL(conv))
+	ms := []mockSpec{
+		{
+			query: "SELECT table_schema, table_name FROM information_schema.tables where table_type = 'BASE TABLE'",
+			cols:  []string{"table_schema", "table_name"},
+			rows:  [][]driver.Value{{"public", "parent"}, {"public", "child"}},
+		}, {
+			query: "SHOW server_encoding",
+			cols:  []string{"server_encoding"},
+			rows:  [][]driver.Value{{"SQL_ASCII"}},
+		}, {
+			query: regexp.QuoteMeta(`SELECT convert_to("s"::text, 'SQL_ASCII') FROM "public"."child" WHERE "s" IS NOT NULL;`),
+			cols:  []string{"convert_to"},
+			rows:  [][]driver.Value{{[]byte("ok")}, {[]byte{0xff, 0x61}}, {[]byte("caf\xc3\xa9")}},
+		}, {
+			query: regexp.QuoteMeta(`SELECT COUNT(*) FROM "public"."child" WHERE "n" = 'NaN' OR abs("n") >= 1e29 OR "n" <> round("n", 9);`),
+			cols:  []string{"count"},
+			rows:  [][]driver.Value{{0}},
+		}, {
+			query: regexp.QuoteMeta(`SELECT COUNT(*) FROM "public"."child" WHERE "d" < '0001-01-01' OR "d" >= '10000-01-01';`),
+			cols:  []string{"count"},
+			rows:  [][]driver.Value{{2}},
+		}, {
+			query: regexp.QuoteMeta(`SELECT COUNT(*) FROM "public"."child" c WHERE c."pid" IS NOT NULL AND NOT EXISTS (SELECT 1 FROM "public"."parent" p WHERE p."id" = c."pid");`),
+			cols:  []string{"count"},
+			rows:  [][]driver.Value{{3}},
+		},
+	}
+	db := mkMockDB(t, ms)
+	anomalies, err := ScanAnomalies(conv, db)
+	assert.Nil(t, err)
+	assert.Equal(t, []internal.Anomaly{
+		internal.Anomaly{Table: "child", Column: "s", Kind: internal.InvalidUTF8, Count: 1},
+		internal.Anomaly{Table: "child", Column: "d", Kind: internal.InvalidDate, Count: 2},
+		internal.Anomaly{Table: "child", Column: "fk", Kind: internal.OrphanedRow, Count: 3},
+	}, anomalies)
+	assert.Equal(t, int64(0), conv.Unexpecteds())
+}
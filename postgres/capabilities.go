@@ -0,0 +1,83 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package postgres
+
+import (
+	"github.com/cloudspannerecosystem/harbourbridge/internal"
+	"github.com/cloudspannerecosystem/harbourbridge/schema"
+)
+
+// sourceTypes are the types that toSpannerType maps (TestSourceTypes
+// checks that they match its cases). Other types are mapped to
+// STRING(MAX).
+var sourceTypes = []string{
+	"bigint", "bigserial", "bool", "boolean", "bpchar", "bytea", "character",
+	"character varying", "date", "double precision", "float4", "float8",
+	"int2", "int4", "int8", "integer", "json", "jsonb", "numeric", "real",
+	"serial", "smallint", "text", "timestamp", "timestamp with time zone",
+	"timestamp without time zone", "timestamptz", "varchar",
+}
+
+// constructs are samples of the constructs, other than tables and
+// columns, found in pg_dump files.
+var constructs = []internal.Construct{
+	{Name: "views", Dump: "CREATE VIEW v AS SELECT 1;\n"},
+	{Name: "materialized views", Dump: "CREATE MATERIALIZED VIEW v AS SELECT 1;\n"},
+	{Name: "functions", Dump: "CREATE FUNCTION f() RETURNS integer LANGUAGE sql AS 'SELECT 1';\n"},
+	{Name: "triggers", Dump: "CREATE TABLE t (a bigint PRIMARY KEY);\nCREATE TRIGGER tr BEFORE INSERT ON t FOR EACH ROW EXECUTE PROCEDURE f();\n"},
+	{Name: "enum types", Dump: "CREATE TYPE e AS ENUM ('a', 'b');\n"},
+	{Name: "domains", Dump: "CREATE DOMAIN d AS integer;\n"},
+	{Name: "extensions", Dump: "CREATE EXTENSION hstore;\n"},
+	{Name: "schemas", Dump: "CREATE SCHEMA s;\n"},
+	{Name: "sequences", Dump: "CREATE SEQUENCE s START WITH 1;\n"},
+	{Name: "column defaults", Dump: "CREATE TABLE t (a bigint PRIMARY KEY, b bigint DEFAULT 1);\n"},
+	{Name: "check constraints", Dump: "CREATE TABLE t (a bigint PRIMARY KEY CHECK (a > 0));\n"},
+	{Name: "foreign keys", Dump: "CREATE TABLE t (a bigint PRIMARY KEY);\nCREATE TABLE u (a bigint PRIMARY KEY REFERENCES t (a));\n"},
+	{Name: "unique constraints", Dump: "CREATE TABLE t (a bigint PRIMARY KEY, b bigint UNIQUE);\n"},
+	{Name: "indexes", Dump: "CREATE TABLE t (a bigint PRIMARY KEY, b bigint);\nCREATE INDEX i ON t (b);\n"},
+	{Name: "inherited tables", Dump: "CREATE TABLE t (a bigint PRIMARY KEY);\nCREATE TABLE u (b bigint) INHERITS (t);\n"},
+	{Name: "comments", Dump: "CREATE TABLE t (a bigint PRIMARY KEY);\nCOMMENT ON TABLE t IS 'c';\n"},
+	{Name: "grants", Dump: "CREATE TABLE t (a bigint PRIMARY KEY);\nGRANT SELECT ON t TO u;\n"},
+}
+
+// Capabilities returns how PostgreSQL types are mapped to targetDb when it
+// supports features, and which constructs are dropped. Types are listed
+// both as scalars and as arrays.
+func Capabilities(targetDb string, features internal.Features) (internal.Capabilities, error) {
+	newConv := func() *internal.Conv {
+		conv := internal.MakeConv()
+		conv.TargetDb = targetDb
+		conv.Features = features
+		return conv
+	}
+	var types []schema.Type
+	for _, t := range sourceTypes {
+		types = append(types, schema.Type{Name: t})
+	}
+	types = append(types, schema.Type{Name: "varchar", Mods: []int64{255}}, schema.Type{Name: "bpchar", Mods: []int64{10}})
+	for _, t := range sourceTypes {
+		types = append(types, schema.Type{Name: t, ArrayBounds: []int64{-1}})
+	}
+	mappings, err := internal.MapTypes(newConv(), types, schemaToDDL)
+	if err != nil {
+		return internal.Capabilities{}, err
+	}
+	return internal.Capabilities{
+		Target:   targetDb,
+		Features: features.String(),
+		Types:    mappings,
+		Dropped:  internal.DroppedConstructs(newConv, constructs, ProcessPgDump),
+	}, nil
+}
@@ -0,0 +1,82 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package postgres
+
+import (
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"strconv"
+	"testing"
+
+	"github.com/cloudspannerecosystem/harbourbridge/internal"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSourceTypes(t *testing.T) {
+	assert.ElementsMatch(t, caseLabels(t, "toddl.go", "toSpannerType"), sourceTypes)
+}
+
+func TestCapabilities(t *testing.T) {
+	c, err := Capabilities("spanner", internal.DefaultFeatures())
+	assert.Nil(t, err)
+	types := make(map[string]string)
+	for _, m := range c.Types {
+		types[m.Source] = m.Spanner
+	}
+	assert.Equal(t, "JSON", types["jsonb"])
+	assert.Equal(t, "ARRAY<INT64>", types["int8[]"])
+	assert.Equal(t, "STRING(255)", types["varchar(255)"])
+	assert.Contains(t, c.Dropped, "views")
+	assert.Contains(t, c.Dropped, "column defaults")
+	assert.NotContains(t, c.Dropped, "indexes")
+	assert.NotContains(t, c.Dropped, "sequences")
+
+	c, err = Capabilities("spanner", internal.Features{})
+	assert.Nil(t, err)
+	for _, m := range c.Types {
+		if m.Source == "jsonb" {
+			assert.Equal(t, "STRING(MAX)", m.Spanner)
+		}
+	}
+	assert.Contains(t, c.Dropped, "sequences")
+}
+
+// caseLabels returns the string constants of the case clauses of function
+// fn of file.
+func caseLabels(t *testing.T, file, fn string) []string {
+	f, err := parser.ParseFile(token.NewFileSet(), file, nil, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var l []string
+	for _, d := range f.Decls {
+		if fd, ok := d.(*ast.FuncDecl); !ok || fd.Name.Name != fn {
+			continue
+		}
+		ast.Inspect(d, func(n ast.Node) bool {
+			if cc, ok := n.(*ast.CaseClause); ok {
+				for _, e := range cc.List {
+					if lit, ok := e.(*ast.BasicLit); ok && lit.Kind == token.STRING {
+						s, _ := strconv.Unquote(lit.Value)
+						l = append(l, s)
+					}
+				}
+			}
+			return true
+		})
+	}
+	return l
+}
@@ -0,0 +1,421 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package postgres
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	nodes "github.com/lfittl/pg_query_go/nodes"
+
+	"github.com/cloudspannerecosystem/harbourbridge/internal"
+	"github.com/cloudspannerecosystem/harbourbridge/schema"
+)
+
+// This file implements support for COPY-FROM blocks in PostgreSQL's
+// binary COPY format (COPY ... FROM stdin WITH (FORMAT binary)). See
+// www.postgresql.org/docs/current/sql-copy.html for a description of
+// the format. We decode each binary field into the text representation
+// used by text-format COPY-FROM blocks, and then use the same data
+// conversion code as text-format blocks.
+
+// copyBinarySignature is the fixed start of the binary COPY header.
+var copyBinarySignature = []byte("PGCOPY\n\377\r\n\000")
+
+// maxBinaryFieldLen is the largest field PostgreSQL stores (1 GB): longer
+// lengths come from malformed input.
+const maxBinaryFieldLen = 1 << 30
+
+// pgEpoch is the origin for binary date and timestamp values.
+var pgEpoch = time.Date(2000, 1, 1, 0, 0, 0, 0, time.UTC)
+
+// isBinaryCopy returns true if the COPY statement n uses binary format,
+// either via 'WITH (FORMAT binary)' or the older 'WITH BINARY' syntax
+// (both are parsed as a 'format' option).
+func isBinaryCopy(n nodes.CopyStmt) bool {
+	for _, o := range n.Options.Items {
+		d, ok := o.(nodes.DefElem)
+		if !ok || d.Defname == nil || strings.ToLower(*d.Defname) != "format" {
+			continue
+		}
+		if s, err := getString(d.Arg); err == nil && strings.ToLower(s) == "binary" {
+			return true
+		}
+	}
+	return false
+}
+
+// processBinaryCopyBlock reads a binary-format COPY-FROM block from r
+// and converts its data. Unlike text-format blocks, binary data can't
+// be resynchronized after a framing error (there are no line
+// boundaries), so such errors are returned and end processing.
+func processBinaryCopyBlock(conv *internal.Conv, srcTable string, srcCols []string, r *internal.Reader) error {
+	internal.VerbosePrintf("Parsing binary COPY-FROM stdin block starting at line=%d/fpos=%d\n", r.LineNumber, r.Offset)
+	if !bytes.Equal(r.ReadN(len(copyBinarySignature)), copyBinarySignature) {
+		return fmt.Errorf("binary COPY-FROM block for table %s at fpos=%d: bad signature", srcTable, r.Offset)
+	}
+	if _, ok := readInt32(r); !ok { // Flags field: no flags affect decoding.
+		return fmt.Errorf("binary COPY-FROM block for table %s: truncated header", srcTable)
+	}
+	n, ok := readInt32(r)
+	if !ok || n < 0 || n > maxBinaryFieldLen || len(r.ReadN(int(n))) != int(n) { // Header extension: skipped.
+		return fmt.Errorf("binary COPY-FROM block for table %s: truncated header", srcTable)
+	}
+	for {
+		b := r.ReadN(2)
+		if len(b) != 2 {
+			return fmt.Errorf("binary COPY-FROM block for table %s: reached eof before trailer", srcTable)
+		}
+		nf := int16(binary.BigEndian.Uint16(b))
+		if nf == -1 { // Trailer.
+			break
+		}
+		if nf < 0 {
+			return fmt.Errorf("binary COPY-FROM block for table %s at fpos=%d: bad field count %d", srcTable, r.Offset, nf)
+		}
+		fields := make([][]byte, nf)
+		for i := range fields {
+			n, ok := readInt32(r)
+			if !ok {
+				return fmt.Errorf("binary COPY-FROM block for table %s: reached eof in tuple", srcTable)
+			}
+			if n == -1 { // NULL.
+				continue
+			}
+			if n < 0 || n > maxBinaryFieldLen {
+				return fmt.Errorf("binary COPY-FROM block for table %s at fpos=%d: bad field length %d", srcTable, r.Offset, n)
+			}
+			fields[i] = r.ReadN(int(n))
+			if len(fields[i]) != int(n) {
+				return fmt.Errorf("binary COPY-FROM block for table %s: reached eof in tuple", srcTable)
+			}
+		}
+		conv.StatsAddRow(srcTable, conv.SchemaMode())
+		if !conv.DataMode() || conv.SkipData(srcTable) {
+			continue
+		}
+		vals, err := decodeBinaryTuple(conv, srcTable, srcCols, fields)
+		if err != nil {
+			conv.Unexpected(fmt.Sprintf("Error while decoding binary COPY data: %s\n", err))
+			conv.StatsAddBadRow(srcTable, conv.DataMode())
+			conv.CollectBadRow(srcTable, srcCols, vals)
+			conv.GroupBadRow(srcTable, err, srcCols, vals)
+			continue
+		}
+		ProcessDataRow(conv, srcTable, srcCols, vals)
+	}
+	// When binary data is embedded in a script, it is followed by the
+	// usual end-of-data marker.
+	if p := r.Peek(2); string(p) == "\\." {
+		r.ReadLine()
+	}
+	internal.VerbosePrintf("Parsed binary COPY-FROM stdin block ending at line=%d/fpos=%d\n", r.LineNumber, r.Offset)
+	return nil
+}
+
+func readInt32(r *internal.Reader) (int32, bool) {
+	b := r.ReadN(4)
+	if len(b) != 4 {
+		return 0, false
+	}
+	return int32(binary.BigEndian.Uint32(b)), true
+}
+
+// decodeBinaryTuple converts the fields of a binary COPY tuple to the
+// text representation used in text-format COPY-FROM blocks, using the
+// source type of each column. NULL fields (nil) become \N.
+func decodeBinaryTuple(conv *internal.Conv, srcTable string, srcCols []string, fields [][]byte) ([]string, error) {
+	vals := make([]string, len(fields))
+	for i, f := range fields {
+		if f == nil {
+			vals[i] = "\\N"
+			continue
+		}
+		// Show undecoded values as hex in bad-row reports.
+		vals[i] = `\x` + hex.EncodeToString(f)
+	}
+	if len(fields) != len(srcCols) {
+		return vals, fmt.Errorf("binary COPY tuple has %d fields, expected %d", len(fields), len(srcCols))
+	}
+	for i, f := range fields {
+		if f == nil {
+			continue
+		}
+		colDef, ok := conv.SrcSchema[srcTable].ColDefs[srcCols[i]]
+		if !ok {
+			return vals, fmt.Errorf("can't find source-db schema for col %s", srcCols[i])
+		}
+		var s string
+		var err error
+		if len(colDef.Type.ArrayBounds) > 0 {
+			s, err = decodeBinaryArray(conv, colDef.Type, f)
+		} else {
+			s, err = decodeBinaryValue(conv, colDef.Type.Name, f)
+		}
+		if err != nil {
+			return vals, &internal.ColumnError{Col: srcCols[i], Val: fmt.Sprintf("%x", f), Err: err}
+		}
+		vals[i] = s
+	}
+	return vals, nil
+}
+
+// decodeBinaryValue converts a binary value of PostgreSQL type typeName
+// to its text representation. The binary format of character types, json,
+// xml, citext and enums is their text representation; we reject values
+// that are not valid UTF-8. Values of other types are rejected, since
+// their binary format is unknown.
+func decodeBinaryValue(conv *internal.Conv, typeName string, b []byte) (string, error) {
+	if _, ok := conv.SrcEnums[typeName]; ok {
+		return decodeBinaryText(typeName, b)
+	}
+	switch typeName {
+	case "text", "varchar", "character varying", "bpchar", "character", "name", "json", "xml", "citext":
+		return decodeBinaryText(typeName, b)
+	case "bool", "boolean":
+		if len(b) != 1 {
+			return "", badBinaryLength(typeName, b)
+		}
+		if b[0] != 0 {
+			return "true", nil
+		}
+		return "false", nil
+	case "int2", "smallint", "smallserial":
+		if len(b) != 2 {
+			return "", badBinaryLength(typeName, b)
+		}
+		return strconv.FormatInt(int64(int16(binary.BigEndian.Uint16(b))), 10), nil
+	case "int4", "integer", "serial":
+		if len(b) != 4 {
+			return "", badBinaryLength(typeName, b)
+		}
+		return strconv.FormatInt(int64(int32(binary.BigEndian.Uint32(b))), 10), nil
+	case "int8", "bigint", "bigserial":
+		if len(b) != 8 {
+			return "", badBinaryLength(typeName, b)
+		}
+		return strconv.FormatInt(int64(binary.BigEndian.Uint64(b)), 10), nil
+	case "float4", "real":
+		if len(b) != 4 {
+			return "", badBinaryLength(typeName, b)
+		}
+		return formatFloat(float64(math.Float32frombits(binary.BigEndian.Uint32(b))), 32), nil
+	case "float8", "double precision":
+		if len(b) != 8 {
+			return "", badBinaryLength(typeName, b)
+		}
+		return formatFloat(math.Float64frombits(binary.BigEndian.Uint64(b)), 64), nil
+	case "numeric":
+		return decodeBinaryNumeric(b)
+	case "bytea":
+		return `\x` + hex.EncodeToString(b), nil
+	case "date":
+		if len(b) != 4 {
+			return "", badBinaryLength(typeName, b)
+		}
+		switch d := int32(binary.BigEndian.Uint32(b)); d {
+		case math.MaxInt32:
+			return "infinity", nil
+		case math.MinInt32:
+			return "-infinity", nil
+		default:
+			return pgEpoch.AddDate(0, 0, int(d)).Format("2006-01-02"), nil
+		}
+	case "timestamp", "timestamp without time zone", "timestamptz", "timestamp with time zone":
+		if len(b) != 8 {
+			return "", badBinaryLength(typeName, b)
+		}
+		switch us := int64(binary.BigEndian.Uint64(b)); us {
+		case math.MaxInt64:
+			return "infinity", nil
+		case math.MinInt64:
+			return "-infinity", nil
+		default:
+			t := time.Unix(pgEpoch.Unix()+us/1e6, (us%1e6)*1e3).UTC()
+			if typeName == "timestamptz" || typeName == "timestamp with time zone" {
+				// Binary timestamptz values are always UTC.
+				return t.Format("2006-01-02 15:04:05.999999Z07:00"), nil
+			}
+			return t.Format("2006-01-02 15:04:05.999999"), nil
+		}
+	case "uuid":
+		if len(b) != 16 {
+			return "", badBinaryLength(typeName, b)
+		}
+		h := hex.EncodeToString(b)
+		return h[0:8] + "-" + h[8:12] + "-" + h[12:16] + "-" + h[16:20] + "-" + h[20:], nil
+	case "jsonb":
+		// jsonb's binary format is a version number followed by text.
+		if len(b) < 1 || b[0] != 1 {
+			return "", fmt.Errorf("unsupported jsonb binary format version")
+		}
+		return string(b[1:]), nil
+	}
+	return "", fmt.Errorf("binary format not supported for type %s", typeName)
+}
+
+// decodeBinaryText converts a binary value of a type whose binary format
+// is its text representation.
+func decodeBinaryText(typeName string, b []byte) (string, error) {
+	if !utf8.Valid(b) {
+		return "", fmt.Errorf("bad binary value for type %s: invalid UTF-8", typeName)
+	}
+	return string(b), nil
+}
+
+func badBinaryLength(typeName string, b []byte) error {
+	return fmt.Errorf("bad binary value for type %s: unexpected length %d", typeName, len(b))
+}
+
+// formatFloat formats f using the same names as PostgreSQL for special values.
+func formatFloat(f float64, bitSize int) string {
+	switch {
+	case math.IsNaN(f):
+		return "NaN"
+	case math.IsInf(f, 1):
+		return "Infinity"
+	case math.IsInf(f, -1):
+		return "-Infinity"
+	}
+	return strconv.FormatFloat(f, 'g', -1, bitSize)
+}
+
+// Sign values used in binary numerics.
+const (
+	numericPos    = 0x0000
+	numericNeg    = 0x4000
+	numericNaN    = 0xC000
+	numericPosInf = 0xD000
+	numericNegInf = 0xF000
+)
+
+// decodeBinaryNumeric converts a binary numeric value to text. The binary
+// format is a header (number of digits, weight, sign and display scale,
+// each int16) followed by the digits, which are base 10000 int16 values.
+// Weight is the base 10000 exponent of the first digit.
+func decodeBinaryNumeric(b []byte) (string, error) {
+	if len(b) < 8 {
+		return "", badBinaryLength("numeric", b)
+	}
+	ndigits := int(binary.BigEndian.Uint16(b[0:]))
+	weight := int(int16(binary.BigEndian.Uint16(b[2:])))
+	sign := binary.BigEndian.Uint16(b[4:])
+	dscale := int(binary.BigEndian.Uint16(b[6:]))
+	if len(b) != 8+2*ndigits {
+		return "", badBinaryLength("numeric", b)
+	}
+	switch sign {
+	case numericNaN:
+		return "NaN", nil
+	case numericPosInf:
+		return "Infinity", nil
+	case numericNegInf:
+		return "-Infinity", nil
+	case numericPos, numericNeg:
+	default:
+		return "", fmt.Errorf("bad binary value for type numeric: unexpected sign 0x%04x", sign)
+	}
+	digit := func(i int) int {
+		if i < 0 || i >= ndigits {
+			return 0
+		}
+		return int(binary.BigEndian.Uint16(b[8+2*i:]))
+	}
+	var s strings.Builder
+	if sign == numericNeg {
+		s.WriteByte('-')
+	}
+	if weight < 0 {
+		s.WriteByte('0')
+	}
+	for i := 0; i <= weight; i++ {
+		if i == 0 {
+			fmt.Fprintf(&s, "%d", digit(i))
+		} else {
+			fmt.Fprintf(&s, "%04d", digit(i))
+		}
+	}
+	if dscale > 0 {
+		var f strings.Builder
+		for i := weight + 1; f.Len() < dscale; i++ {
+			fmt.Fprintf(&f, "%04d", digit(i))
+		}
+		s.WriteByte('.')
+		s.WriteString(f.String()[:dscale])
+	}
+	return s.String(), nil
+}
+
+// decodeBinaryArray converts a binary array value to the text
+// representation used by PostgreSQL e.g. {1,NULL,3}. The binary format
+// is a header (number of dimensions, a has-nulls flag and the element
+// type OID, each int32), then the size and lower bound of each
+// dimension (int32s), followed by the elements, each preceded by its
+// length (-1 for NULL). Spanner only supports one-dimensional arrays.
+func decodeBinaryArray(conv *internal.Conv, t schema.Type, b []byte) (string, error) {
+	if len(b) < 12 {
+		return "", badBinaryLength(t.Name+" array", b)
+	}
+	ndim := int32(binary.BigEndian.Uint32(b[0:]))
+	if ndim == 0 {
+		return "{}", nil
+	}
+	if ndim != 1 {
+		return "", fmt.Errorf("can't convert %d-dimensional array", ndim)
+	}
+	if len(b) < 20 {
+		return "", badBinaryLength(t.Name+" array", b)
+	}
+	n := int(int32(binary.BigEndian.Uint32(b[12:])))
+	b = b[20:]
+	var elems []string
+	for i := 0; i < n; i++ {
+		if len(b) < 4 {
+			return "", badBinaryLength(t.Name+" array", b)
+		}
+		l := int(int32(binary.BigEndian.Uint32(b)))
+		b = b[4:]
+		if l == -1 {
+			elems = append(elems, "NULL")
+			continue
+		}
+		if l < 0 || len(b) < l {
+			return "", badBinaryLength(t.Name+" array", b)
+		}
+		s, err := decodeBinaryValue(conv, t.Name, b[:l])
+		if err != nil {
+			return "", err
+		}
+		b = b[l:]
+		elems = append(elems, quoteArrayElem(s))
+	}
+	return "{" + strings.Join(elems, ",") + "}", nil
+}
+
+// quoteArrayElem quotes s, if necessary, following PostgreSQL's rules
+// for array output (see processQuote).
+func quoteArrayElem(s string) string {
+	if s != "" && !strings.EqualFold(s, "NULL") && !strings.ContainsAny(s, "{}\",\\ \t\n\r\v\f") {
+		return s
+	}
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(s) + `"`
+}
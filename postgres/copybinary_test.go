@@ -0,0 +1,210 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package postgres
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"math"
+	"testing"
+
+	"cloud.google.com/go/spanner"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/cloudspannerecosystem/harbourbridge/internal"
+	"github.com/cloudspannerecosystem/harbourbridge/schema"
+)
+
+func TestDecodeBinaryValue(t *testing.T) {
+	tests := []struct {
+		typeName string
+		in       []byte
+		e        string
+	}{
+		{"bool", []byte{1}, "true"},
+		{"int2", []byte{0xff, 0xfe}, "-2"},
+		{"int4", []byte{0, 0, 1, 0}, "256"},
+		{"int8", []byte{0, 0, 0, 0, 0, 0, 0, 42}, "42"},
+		{"float8", be64(math.Float64bits(4.25)), "4.25"},
+		{"float8", be64(math.Float64bits(math.Inf(-1))), "-Infinity"},
+		{"bytea", []byte{0, 1, 0xbe, 0xef}, `\x0001beef`},
+		{"date", be32(7241), "2019-10-29"},
+		{"date", be32(math.MaxInt32), "infinity"},
+		{"timestamp", be64(625640400000000 + 123), "2019-10-29 05:00:00.000123"},
+		{"timestamptz", be64(625640400000000), "2019-10-29 05:00:00Z"},
+		{"numeric", numeric(2, 0, numericNeg, 2, 12, 3400), "-12.34"},
+		{"numeric", numeric(1, -1, numericPos, 6, 5), "0.000500"},
+		{"numeric", numeric(1, 1, numericPos, 0, 7), "70000"},
+		{"numeric", numeric(0, 0, numericNaN, 0), "NaN"},
+		{"uuid", []byte{0xa0, 0xee, 0xbc, 0x99, 0x9c, 0x0b, 0x4e, 0xf8, 0xbb, 0x6d, 0x6b, 0xb9, 0xbd, 0x38, 0x0a, 0x11}, "a0eebc99-9c0b-4ef8-bb6d-6bb9bd380a11"},
+		{"jsonb", []byte("\x01{\"a\": 1}"), `{"a": 1}`},
+		{"varchar", []byte("eh"), "eh"},
+		{"public.mood", []byte("happy"), "happy"},
+	}
+	conv := internal.MakeConv()
+	conv.SetSrcEnum("public.mood", []string{"sad", "happy"})
+	for _, tc := range tests {
+		s, err := decodeBinaryValue(conv, tc.typeName, tc.in)
+		assert.Nil(t, err, tc.typeName)
+		assert.Equal(t, tc.e, s, tc.typeName)
+	}
+	errTests := []struct {
+		typeName string
+		in       []byte
+	}{
+		{"int4", []byte{0, 1}},
+		{"numeric", []byte{0, 1, 0, 0, 0, 0, 0, 0}},
+		{"jsonb", []byte("{}")},
+		{"text", []byte{0xff, 0xfe}},
+		// Types whose binary format isn't text.
+		{"interval", []byte("1 day")},
+		{"public.unknown", []byte("x")},
+	}
+	for _, tc := range errTests {
+		_, err := decodeBinaryValue(conv, tc.typeName, tc.in)
+		assert.NotNil(t, err, tc.typeName)
+	}
+}
+
+func TestDecodeBinaryArray(t *testing.T) {
+	var b bytes.Buffer
+	b.Write(be32(1))  // Dimensions.
+	b.Write(be32(1))  // Has nulls.
+	b.Write(be32(25)) // Element type: text.
+	b.Write(be32(3))  // Size.
+	b.Write(be32(1))  // Lower bound.
+	b.Write(be32(3))
+	b.WriteString("a,b")
+	b.Write(be32(0xffffffff))
+	b.Write(be32(1))
+	b.WriteString("c")
+	s, err := decodeBinaryArray(internal.MakeConv(), schema.Type{Name: "text", ArrayBounds: []int64{-1}}, b.Bytes())
+	assert.Nil(t, err)
+	assert.Equal(t, `{"a,b",NULL,c}`, s)
+
+	s, err = decodeBinaryArray(internal.MakeConv(), schema.Type{Name: "int4", ArrayBounds: []int64{-1}}, append(be32(0), make([]byte, 8)...))
+	assert.Nil(t, err)
+	assert.Equal(t, "{}", s)
+}
+
+func TestProcessPgDump_BinaryCopy(t *testing.T) {
+	var b bytes.Buffer
+	b.WriteString("CREATE TABLE test (id bigint PRIMARY KEY, a text, b bytea, c integer array);\n")
+	b.WriteString("COPY test (id, a, b, c) FROM stdin WITH (FORMAT binary);\n")
+	b.Write(copyBinarySignature)
+	b.Write(be32(0)) // Flags.
+	b.Write(be32(0)) // Header extension length.
+	// Tuple 1: all values.
+	b.Write(be16(4))
+	b.Write(be32(8))
+	b.Write(be64(1))
+	b.Write(be32(4))
+	b.WriteString("a\tb\n")
+	b.Write(be32(2))
+	b.Write([]byte{0x0a, 0xff})
+	arr := bytes.Join([][]byte{be32(1), be32(0), be32(23), be32(2), be32(1), be32(4), be32(42), be32(4), be32(6)}, nil)
+	b.Write(be32(uint32(len(arr))))
+	b.Write(arr)
+	// Tuple 2: NULLs.
+	b.Write(be16(4))
+	b.Write(be32(8))
+	b.Write(be64(2))
+	b.Write(be32(0xffffffff))
+	b.Write(be32(0xffffffff))
+	b.Write(be32(0xffffffff))
+	b.Write(be16(0xffff)) // Trailer.
+	b.WriteString("\\.\n")
+	b.WriteString("INSERT INTO test (id, a) VALUES (3, 'x');\n")
+
+	conv, rows := runProcessPgDump(b.String())
+	noIssues(conv, t, "Binary COPY")
+	assert.Equal(t, []spannerData{
+		spannerData{table: "test", cols: []string{"id", "a", "b", "c"}, vals: []interface{}{int64(1), "a\tb\n", []byte{0x0a, 0xff},
+			[]spanner.NullInt64{{Int64: 42, Valid: true}, {Int64: 6, Valid: true}}}},
+		spannerData{table: "test", cols: []string{"id"}, vals: []interface{}{int64(2)}},
+		spannerData{table: "test", cols: []string{"id", "a"}, vals: []interface{}{int64(3), "x"}},
+	}, rows)
+}
+
+func TestProcessPgDump_BinaryCopyEnum(t *testing.T) {
+	var b bytes.Buffer
+	b.WriteString("CREATE TYPE public.mood AS ENUM ('sad', 'happy');\n")
+	b.WriteString("CREATE TABLE test (id bigint PRIMARY KEY, m public.mood);\n")
+	b.WriteString("COPY test (id, m) FROM stdin WITH (FORMAT binary);\n")
+	b.Write(copyBinarySignature)
+	b.Write(be32(0)) // Flags.
+	b.Write(be32(0)) // Header extension length.
+	b.Write(be16(2))
+	b.Write(be32(8))
+	b.Write(be64(1))
+	b.Write(be32(5))
+	b.WriteString("happy")
+	b.Write(be16(0xffff)) // Trailer.
+
+	conv, rows := runProcessPgDump(b.String())
+	assert.Equal(t, []string{"sad", "happy"}, conv.SrcEnums["public.mood"])
+	assert.Equal(t, []spannerData{
+		spannerData{table: "test", cols: []string{"id", "m"}, vals: []interface{}{int64(1), "happy"}},
+	}, rows)
+}
+
+func TestProcessPgDump_BinaryCopyFieldTooLong(t *testing.T) {
+	var b bytes.Buffer
+	b.WriteString("CREATE TABLE test (id bigint PRIMARY KEY, a text);\n")
+	b.WriteString("COPY test (id, a) FROM stdin WITH (FORMAT binary);\n")
+	b.Write(copyBinarySignature)
+	b.Write(be32(0)) // Flags.
+	b.Write(be32(0)) // Header extension length.
+	b.Write(be16(2))
+	b.Write(be32(8))
+	b.Write(be64(1))
+	b.Write(be32(maxBinaryFieldLen + 1))
+	b.WriteString("a")
+
+	conv := internal.MakeConv()
+	conv.SetSchemaMode()
+	err := ProcessPgDump(conv, internal.NewReader(bufio.NewReader(&b), nil))
+	assert.Contains(t, err.Error(), "bad field length 1073741825")
+}
+
+func be16(i uint16) []byte {
+	b := make([]byte, 2)
+	binary.BigEndian.PutUint16(b, i)
+	return b
+}
+
+func be32(i uint32) []byte {
+	b := make([]byte, 4)
+	binary.BigEndian.PutUint32(b, i)
+	return b
+}
+
+func be64(i uint64) []byte {
+	b := make([]byte, 8)
+	binary.BigEndian.PutUint64(b, i)
+	return b
+}
+
+// numeric builds a binary numeric value.
+func numeric(ndigits, weight int16, sign, dscale uint16, digits ...uint16) []byte {
+	b := append(be16(uint16(ndigits)), be16(uint16(weight))...)
+	b = append(b, be16(sign)...)
+	b = append(b, be16(dscale)...)
+	for _, d := range digits {
+		b = append(b, be16(d)...)
+	}
+	return b
+}
@@ -18,7 +18,6 @@ import (
 	"encoding/hex"
 	"fmt"
 	"math/big"
-	"math/bits"
 	"reflect"
 	"strconv"
 	"strings"
@@ -40,6 +39,7 @@ func ProcessDataRow(conv *internal.Conv, srcTable string, srcCols, vals []string
 		conv.Unexpected(fmt.Sprintf("Error while converting data: %s\n", err))
 		conv.StatsAddBadRow(srcTable, conv.DataMode())
 		conv.CollectBadRow(srcTable, srcCols, vals)
+		conv.GroupBadRow(srcTable, err, srcCols, vals)
 	} else {
 		conv.WriteRow(srcTable, spTable, spCols, spVals)
 	}
@@ -63,7 +63,7 @@ func ConvertData(conv *internal.Conv, srcTable string, srcCols []string, vals []
 	if err != nil {
 		return "", []string{}, []interface{}{}, fmt.Errorf("can't map source columns %v", srcCols)
 	}
-	spSchema, ok1 := conv.SpSchema[spTable]
+	spSchema, ok1 := conv.DataSchema(spTable)
 	srcSchema, ok2 := conv.SrcSchema[srcTable]
 	if !ok1 || !ok2 {
 		return "", []string{}, []interface{}{}, fmt.Errorf("can't find table %s in schema", spTable)
@@ -75,6 +75,9 @@ func ConvertData(conv *internal.Conv, srcTable string, srcCols []string, vals []
 	}
 	for i, spCol := range spCols {
 		srcCol := srcCols[i]
+		if spCol == "" { // Column is not migrated (see internal.DropColumns).
+			continue
+		}
 		if vals[i] == "\\N" { // PostgreSQL representation of empty column in COPY-FROM blocks.
 			continue
 		}
@@ -85,22 +88,26 @@ func ConvertData(conv *internal.Conv, srcTable string, srcCols []string, vals []
 		}
 		var x interface{}
 		var err error
-		if spColDef.T.IsArray {
+		switch {
+		case spColDef.T.IsArray:
 			x, err = convArray(spColDef.T, srcColDef.Type.Name, conv.Location, vals[i])
-		} else {
+		case isSpecialValue(spColDef.T, vals[i]):
+			x, err = convSpecialValue(conv, srcTable, srcCol, spColDef.T, vals[i])
+		default:
 			x, err = convScalar(spColDef.T, srcColDef.Type.Name, conv.Location, vals[i])
 		}
 		if err != nil {
-			return "", []string{}, []interface{}{}, err
+			return "", []string{}, []interface{}{}, &internal.ColumnError{Col: srcCol, Val: vals[i], Err: err}
+		}
+		if x == nil { // Special value mapped to NULL.
+			continue
 		}
 		v = append(v, x)
 		c = append(c, spCol)
 	}
-	if aux, ok := conv.SyntheticPKeys[spTable]; ok {
-		c = append(c, aux.Col)
-		v = append(v, int64(bits.Reverse64(uint64(aux.Sequence))))
-		aux.Sequence++
-		conv.SyntheticPKeys[spTable] = aux
+	if col, val, ok := conv.NextSyntheticPKey(spTable); ok {
+		c = append(c, col)
+		v = append(v, val)
 	}
 	return spTable, c, v, nil
 }
@@ -122,13 +129,15 @@ func convScalar(spannerType ddl.Type, srcTypeName string, location *time.Locatio
 		return convBytes(val)
 	case ddl.Date:
 		return convDate(val)
+	case ddl.Float32:
+		return convFloat32(val)
 	case ddl.Float64:
 		return convFloat64(val)
 	case ddl.Int64:
 		return convInt64(val)
 	case ddl.Numeric:
 		return convNumeric(val)
-	case ddl.String:
+	case ddl.String, ddl.JSON:
 		return val, nil
 	case ddl.Timestamp:
 		return convTimestamp(srcTypeName, location, val)
@@ -145,9 +154,13 @@ func convBool(val string) (bool, error) {
 	return b, err
 }
 
+// convBytes converts a bytea value, which PostgreSQL outputs in either
+// hex format (e.g. \x0001beef, the default since PostgreSQL 9.0) or
+// escape format (e.g. \000\001\276\357, used when bytea_output is set
+// to 'escape').
 func convBytes(val string) ([]byte, error) {
-	if val[0:2] != `\x` {
-		return []byte{}, fmt.Errorf("can't convert to bytes: doesn't start with \\x prefix")
+	if !strings.HasPrefix(val, `\x`) {
+		return convEscapedBytes(val)
 	}
 	b, err := hex.DecodeString(val[2:])
 	if err != nil {
@@ -156,6 +169,43 @@ func convBytes(val string) ([]byte, error) {
 	return b, err
 }
 
+// convEscapedBytes converts a bytea value in escape format. In this
+// format, PostgreSQL outputs printable ASCII characters as-is, except
+// for backslash (which is doubled), and outputs all other bytes as
+// \ooo (three octal digits). Since escape-format output never contains
+// other characters, we reject them: they indicate val isn't bytea output.
+func convEscapedBytes(val string) ([]byte, error) {
+	var b []byte
+	for i := 0; i < len(val); i++ {
+		c := val[i]
+		switch {
+		case c == '\\' && i+1 < len(val) && val[i+1] == '\\':
+			b = append(b, '\\')
+			i++
+		case c == '\\' && i+4 <= len(val) && isOctalByte(val[i+1:i+4]):
+			b = append(b, (val[i+1]-'0')<<6|(val[i+2]-'0')<<3|(val[i+3]-'0'))
+			i += 3
+		case c == '\\':
+			return []byte{}, fmt.Errorf("can't convert to bytes: bad escape sequence at offset %d", i)
+		case c < 0x20 || c > 0x7e:
+			return []byte{}, fmt.Errorf("can't convert to bytes: unexpected character 0x%02x at offset %d", c, i)
+		default:
+			b = append(b, c)
+		}
+	}
+	if b == nil {
+		b = []byte{}
+	}
+	return b, nil
+}
+
+// isOctalByte returns true if s consists of three octal digits
+// representing a value in the range 0-255.
+func isOctalByte(s string) bool {
+	return len(s) == 3 && s[0] >= '0' && s[0] <= '3' &&
+		s[1] >= '0' && s[1] <= '7' && s[2] >= '0' && s[2] <= '7'
+}
+
 func convDate(val string) (civil.Date, error) {
 	d, err := civil.ParseDate(val)
 	if err != nil {
@@ -164,6 +214,16 @@ func convDate(val string) (civil.Date, error) {
 	return d, err
 }
 
+// convFloat32 converts val to a FLOAT32 value. It is returned as a float64,
+// since that's what the Spanner client accepts.
+func convFloat32(val string) (float64, error) {
+	f, err := strconv.ParseFloat(val, 32)
+	if err != nil {
+		return f, fmt.Errorf("can't convert to float32: %w", err)
+	}
+	return f, err
+}
+
 func convFloat64(val string) (float64, error) {
 	f, err := strconv.ParseFloat(val, 64)
 	if err != nil {
@@ -233,6 +293,71 @@ func convTimestamp(srcTypeName string, location *time.Location, val string) (t t
 	return t, err
 }
 
+// isSpecialValue returns true if val is one of PostgreSQL's special
+// values (such as 'infinity' for dates and timestamps, or 'NaN' for
+// numerics) that can't be stored in Spanner type spannerType.
+// Note that Spanner's FLOAT64 supports NaN and +/-Infinity, so float
+// special values only need handling when mapped to NUMERIC.
+func isSpecialValue(spannerType ddl.Type, val string) bool {
+	switch spannerType.Name {
+	case ddl.Date, ddl.Timestamp:
+		return val == "infinity" || val == "-infinity"
+	case ddl.Numeric:
+		switch strings.ToLower(val) {
+		case "nan", "infinity", "+infinity", "-infinity", "inf", "+inf", "-inf":
+			return true
+		}
+	}
+	return false
+}
+
+// convSpecialValue applies conv's special value policy to val, which
+// must be a special value for spannerType (see isSpecialValue). It
+// returns nil if the value should be written as NULL.
+func convSpecialValue(conv *internal.Conv, srcTable, srcCol string, spannerType ddl.Type, val string) (interface{}, error) {
+	conv.StatsAddSpecialValue(srcTable, srcCol, conv.DataMode())
+	switch conv.Policies.SpecialValues {
+	case internal.ClampSpecialValues:
+		return clampSpecialValue(spannerType, val), nil
+	case internal.NullSpecialValues:
+		return nil, nil
+	default:
+		return nil, fmt.Errorf("can't convert special value %q to Spanner type %s (special value policy is %s)", val, spannerType.Name, conv.Policies.SpecialValues)
+	}
+}
+
+// clampSpecialValue maps special value val to the closest value that
+// Spanner type spannerType can store. NaN has no closest value, so we
+// return nil (i.e. NULL) for it.
+func clampSpecialValue(spannerType ddl.Type, val string) interface{} {
+	negative := strings.HasPrefix(val, "-")
+	switch spannerType.Name {
+	case ddl.Date:
+		if negative {
+			return civil.Date{Year: 1, Month: time.January, Day: 1}
+		}
+		return civil.Date{Year: 9999, Month: time.December, Day: 31}
+	case ddl.Timestamp:
+		if negative {
+			return time.Date(1, time.January, 1, 0, 0, 0, 0, time.UTC)
+		}
+		return time.Date(9999, time.December, 31, 23, 59, 59, 999999999, time.UTC)
+	case ddl.Numeric:
+		if strings.ToLower(val) == "nan" {
+			return nil
+		}
+		if negative {
+			return "-" + maxNumeric
+		}
+		return maxNumeric
+	}
+	return nil
+}
+
+// maxNumeric is the largest value that Spanner's NUMERIC type can store
+// (precision 38, scale 9).
+const maxNumeric = "99999999999999999999999999999.999999999"
+
 // convArray converts a source database string value (representing an
 // array) to an appropriate Spanner array value. It is the caller's
 // responsibility to detect and handle the case where the entire array
@@ -310,7 +435,11 @@ func convArray(spannerType ddl.Type, srcTypeName string, location *time.Location
 			r = append(r, spanner.NullDate{Date: d, Valid: true})
 		}
 		return r, nil
-	case ddl.Float64:
+	case ddl.Float32, ddl.Float64:
+		convFloat := convFloat64
+		if spannerType.Name == ddl.Float32 {
+			convFloat = convFloat32
+		}
 		var r []spanner.NullFloat64
 		for _, s := range a {
 			if s == "NULL" {
@@ -321,7 +450,7 @@ func convArray(spannerType ddl.Type, srcTypeName string, location *time.Location
 			if err != nil {
 				return []spanner.NullFloat64{}, err
 			}
-			f, err := convFloat64(s)
+			f, err := convFloat(s)
 			if err != nil {
 				return []spanner.NullFloat64{}, err
 			}
@@ -346,7 +475,7 @@ func convArray(spannerType ddl.Type, srcTypeName string, location *time.Location
 			r = append(r, spanner.NullInt64{Int64: i, Valid: true})
 		}
 		return r, nil
-	case ddl.String:
+	case ddl.String, ddl.JSON:
 		var r []spanner.NullString
 		for _, s := range a {
 			if s == "NULL" {
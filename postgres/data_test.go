@@ -60,6 +60,74 @@ func TestProcessDataRow(t *testing.T) {
 	assert.Equal(t, []spannerData{spannerData{table: tableName, cols: cols, vals: []interface{}{float64(4.2), int64(6), "prisoner zero"}}}, rows)
 }
 
+func TestProcessDataRow_DroppedColumn(t *testing.T) {
+	tableName := "testtable"
+	cols := []string{"a", "b", "c"}
+	conv := buildConv(
+		ddl.CreateTable{
+			Name:     tableName,
+			ColNames: cols,
+			ColDefs: map[string]ddl.ColumnDef{
+				"a": ddl.ColumnDef{Name: "a", T: ddl.Type{Name: ddl.Float64}},
+				"b": ddl.ColumnDef{Name: "b", T: ddl.Type{Name: ddl.Int64}},
+				"c": ddl.ColumnDef{Name: "c", T: ddl.Type{Name: ddl.String, Len: ddl.MaxLength}},
+			},
+			Pks: []ddl.IndexKey{ddl.IndexKey{Col: "a"}}},
+		schema.Table{
+			Name:     tableName,
+			ColNames: cols,
+			ColDefs: map[string]schema.Column{
+				"a": schema.Column{Name: "a", Type: schema.Type{Name: "float4"}},
+				"b": schema.Column{Name: "b", Type: schema.Type{Name: "int8"}},
+				"c": schema.Column{Name: "c", Type: schema.Type{Name: "text"}},
+			}})
+	assert.Nil(t, conv.DropColumns([]string{"testtable.b"}))
+	assert.Equal(t, []string{"a", "c"}, conv.SpSchema[tableName].ColNames)
+	conv.SetDataMode()
+	var rows []spannerData
+	conv.SetDataSink(
+		func(table string, cols []string, vals []interface{}) {
+			rows = append(rows, spannerData{table: table, cols: cols, vals: vals})
+		})
+	ProcessDataRow(conv, tableName, cols, []string{"4.2", "6", "prisoner zero"})
+	assert.Equal(t, []spannerData{spannerData{table: tableName, cols: []string{"a", "c"}, vals: []interface{}{float64(4.2), "prisoner zero"}}}, rows)
+	assert.Equal(t, int64(0), conv.BadRows())
+}
+
+func TestProcessDataRow_ErrorGroups(t *testing.T) {
+	tableName := "testtable"
+	cols := []string{"a", "b"}
+	conv := buildConv(
+		ddl.CreateTable{
+			Name:     tableName,
+			ColNames: cols,
+			ColDefs: map[string]ddl.ColumnDef{
+				"a": ddl.ColumnDef{Name: "a", T: ddl.Type{Name: ddl.Int64}},
+				"b": ddl.ColumnDef{Name: "b", T: ddl.Type{Name: ddl.Date}},
+			}},
+		schema.Table{
+			Name:     tableName,
+			ColNames: cols,
+			ColDefs: map[string]schema.Column{
+				"a": schema.Column{Name: "a", Type: schema.Type{Name: "int8"}},
+				"b": schema.Column{Name: "b", Type: schema.Type{Name: "date"}},
+			}})
+	conv.SetDataMode()
+	conv.SetDataSink(func(table string, cols []string, vals []interface{}) {})
+	ProcessDataRow(conv, tableName, cols, []string{"1", "2020-13-45"})
+	ProcessDataRow(conv, tableName, cols, []string{"2", "2021-14-01"})
+	ProcessDataRow(conv, tableName, cols, []string{"x3", "2021-01-01"})
+	assert.Equal(t, int64(3), conv.BadRows())
+	groups := conv.ErrorGroups(10)
+	assert.Equal(t, 2, len(groups))
+	assert.Equal(t, int64(2), groups[0].Count)
+	assert.Equal(t, "b", groups[0].Column)
+	assert.Equal(t, "9-9-9", groups[0].Pattern)
+	assert.Equal(t, []string{"cols=[a b] data=[1 2020-13-45]", "cols=[a b] data=[2 2021-14-01]"}, groups[0].Examples)
+	assert.Equal(t, "a", groups[1].Column)
+	assert.Equal(t, "a9", groups[1].Pattern)
+}
+
 func TestConvertData(t *testing.T) {
 	singleColTests := []struct {
 		name  string
@@ -70,10 +138,14 @@ func TestConvertData(t *testing.T) {
 	}{
 		{"bool", ddl.Type{Name: ddl.Bool}, "", "true", true},
 		{"bytes", ddl.Type{Name: ddl.Bytes, Len: ddl.MaxLength}, "", `\x0001beef`, []byte{0x0, 0x1, 0xbe, 0xef}},
+		{"bytes escape format", ddl.Type{Name: ddl.Bytes, Len: ddl.MaxLength}, "", `\000\001\276\357`, []byte{0x0, 0x1, 0xbe, 0xef}},
+		{"bytes escape format with backslash", ddl.Type{Name: ddl.Bytes, Len: ddl.MaxLength}, "", `a\\b`, []byte("a\\b")},
 		{"date", ddl.Type{Name: ddl.Date}, "", "2019-10-29", getDate("2019-10-29")},
+		{"float32", ddl.Type{Name: ddl.Float32}, "", "42.6", float64(float32(42.6))},
 		{"float64", ddl.Type{Name: ddl.Float64}, "", "42.6", float64(42.6)},
 		{"int64", ddl.Type{Name: ddl.Int64}, "", "42", int64(42)},
 		{"string", ddl.Type{Name: ddl.String, Len: ddl.MaxLength}, "", "eh", "eh"},
+		{"json", ddl.Type{Name: ddl.JSON}, "", `{"a": [1, 2]}`, `{"a": [1, 2]}`},
 		{"timestamptz", ddl.Type{Name: ddl.Timestamp}, "timestamptz", "2019-10-29 05:30:00+10", getTime(t, "2019-10-29T05:30:00+10:00")},
 		{"timestamp", ddl.Type{Name: ddl.Timestamp}, "timestamp", "2019-10-29 05:30:00", getTime(t, "2019-10-29T05:30:00Z")},
 
@@ -306,3 +378,69 @@ func getDate(s string) civil.Date {
 	d, _ := civil.ParseDate(s)
 	return d
 }
+
+func TestConvertData_SpecialValues(t *testing.T) {
+	tableName := "testtable"
+	cols := []string{"a", "b", "c"}
+	spTable := ddl.CreateTable{
+		Name:     tableName,
+		ColNames: cols,
+		ColDefs: map[string]ddl.ColumnDef{
+			"a": ddl.ColumnDef{Name: "a", T: ddl.Type{Name: ddl.Date}},
+			"b": ddl.ColumnDef{Name: "b", T: ddl.Type{Name: ddl.Timestamp}},
+			"c": ddl.ColumnDef{Name: "c", T: ddl.Type{Name: ddl.Numeric}},
+		}}
+	srcTable := schema.Table{
+		Name:     tableName,
+		ColNames: cols,
+		ColDefs: map[string]schema.Column{
+			"a": schema.Column{Name: "a", Type: schema.Type{Name: "date"}},
+			"b": schema.Column{Name: "b", Type: schema.Type{Name: "timestamp"}},
+			"c": schema.Column{Name: "c", Type: schema.Type{Name: "numeric"}},
+		}}
+	tests := []struct {
+		name   string
+		policy internal.SpecialValuePolicy
+		vals   []string      // Input values.
+		ecols  []string      // Expected columns.
+		evals  []interface{} // Expected values.
+	}{
+		{
+			name:   "Clamp infinity",
+			policy: internal.ClampSpecialValues,
+			vals:   []string{"infinity", "infinity", "Infinity"},
+			ecols:  []string{"a", "b", "c"},
+			evals: []interface{}{getDate("9999-12-31"), time.Date(9999, time.December, 31, 23, 59, 59, 999999999, time.UTC),
+				"99999999999999999999999999999.999999999"},
+		},
+		{
+			name:   "Clamp -infinity and NaN",
+			policy: internal.ClampSpecialValues,
+			vals:   []string{"-infinity", "-infinity", "NaN"},
+			ecols:  []string{"a", "b"},
+			evals:  []interface{}{getDate("0001-01-01"), time.Date(1, time.January, 1, 0, 0, 0, 0, time.UTC)},
+		},
+		{
+			name:   "Null",
+			policy: internal.NullSpecialValues,
+			vals:   []string{"infinity", "2019-10-29 05:30:00", "NaN"},
+			ecols:  []string{"b"},
+			evals:  []interface{}{getTime(t, "2019-10-29T05:30:00Z")},
+		},
+	}
+	for _, tc := range tests {
+		conv := buildConv(spTable, srcTable)
+		conv.SetDataMode()
+		conv.Policies.SpecialValues = tc.policy
+		atable, acols, avals, err := ConvertData(conv, tableName, cols, tc.vals)
+		checkResults(t, atable, acols, avals, err, tableName, tc.ecols, tc.evals, tc.name)
+	}
+
+	conv := buildConv(spTable, srcTable)
+	conv.SetDataMode()
+	_, _, _, err := ConvertData(conv, tableName, cols, []string{"infinity", "\\N", "\\N"})
+	assert.NotNil(t, err, "Reject infinity")
+	_, _, _, err = ConvertData(conv, tableName, cols, []string{"\\N", "\\N", "NaN"})
+	assert.NotNil(t, err, "Reject NaN")
+	assert.Equal(t, map[string]int64{"a": 1, "c": 1}, conv.Stats.SpecialValues[tableName])
+}
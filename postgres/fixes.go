@@ -0,0 +1,67 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package postgres
+
+import (
+	"database/sql"
+	"fmt"
+
+	"github.com/cloudspannerecosystem/harbourbridge/internal"
+)
+
+// sourceFixer gives PostgreSQL statements that fix issues of the source
+// database (see internal.SourceFixes).
+type sourceFixer struct {
+	names map[string]schemaAndName // By source table name.
+}
+
+// SourceFixer returns an internal.SourceFixer for the tables of db.
+func SourceFixer(db *sql.DB) (internal.SourceFixer, error) {
+	tables, err := getTables(db)
+	if err != nil {
+		return nil, err
+	}
+	f := sourceFixer{names: make(map[string]schemaAndName)}
+	for _, t := range tables {
+		f.names[buildTableName(t.schema, t.name)] = t
+	}
+	return f, nil
+}
+
+func (f sourceFixer) from(srcTable string) string {
+	t, ok := f.names[srcTable]
+	if !ok {
+		return fmt.Sprintf(`"%s"`, srcTable)
+	}
+	return fmt.Sprintf(`"%s"."%s"`, t.schema, t.name)
+}
+
+func (f sourceFixer) AddPrimaryKey(srcTable, col string) string {
+	return fmt.Sprintf(`ALTER TABLE %s ADD COLUMN "%s" bigserial PRIMARY KEY;`, f.from(srcTable), col)
+}
+
+func (f sourceFixer) ClearInvalidDates(srcTable, col string) string {
+	// As for ScanAnomalies, also matches infinity and -infinity.
+	return fmt.Sprintf(`UPDATE %s SET "%s" = NULL WHERE "%s" < '0001-01-01' OR "%s" >= '10000-01-01';`, f.from(srcTable), col, col, col)
+}
+
+func (f sourceFixer) DropIndex(srcTable, index string) string {
+	// Indexes are in the schema of their table.
+	schema := "public"
+	if t, ok := f.names[srcTable]; ok {
+		schema = t.schema
+	}
+	return fmt.Sprintf(`DROP INDEX "%s"."%s";`, schema, index)
+}
@@ -0,0 +1,31 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package postgres
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSourceFixer(t *testing.T) {
+	f := sourceFixer{names: map[string]schemaAndName{
+		"t":   {schema: "public", name: "t"},
+		"s.u": {schema: "s", name: "u"},
+	}}
+	assert.Equal(t, `ALTER TABLE "public"."t" ADD COLUMN "synth_id" bigserial PRIMARY KEY;`, f.AddPrimaryKey("t", "synth_id"))
+	assert.Equal(t, `UPDATE "s"."u" SET "d" = NULL WHERE "d" < '0001-01-01' OR "d" >= '10000-01-01';`, f.ClearInvalidDates("s.u", "d"))
+	assert.Equal(t, `DROP INDEX "s"."i";`, f.DropIndex("s.u", "i"))
+}
@@ -0,0 +1,89 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build go1.18
+// +build go1.18
+
+package postgres
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/cloudspannerecosystem/harbourbridge/internal"
+	"github.com/cloudspannerecosystem/harbourbridge/schema"
+)
+
+// Fuzz targets for pg_dump parsing. Malformed dumps must be reported as
+// errors or bad rows, never panic. Run e.g.
+//
+//	go test ./postgres -run '^$' -fuzz FuzzProcessPgDump
+//
+// Inputs that failed are saved in testdata/fuzz, and are then run as
+// regression tests by go test.
+
+func FuzzProcessPgDump(f *testing.F) {
+	f.Add([]byte("CREATE TABLE t (a bigint PRIMARY KEY, b text[], c numeric);\n" +
+		"COPY t (a, b, c) FROM stdin;\n1\t{x,\"y\\\\z\"}\t1.5\n2\t\\N\t\\x41\n\\.\n" +
+		"INSERT INTO t (a, c) VALUES (3, 'NaN');\n"))
+	f.Add([]byte("CREATE TABLE t (a int, b date);\nALTER TABLE ONLY t ADD CONSTRAINT t_pkey PRIMARY KEY (a);\n" +
+		"CREATE SEQUENCE s START WITH 5 INCREMENT BY 2;\nSELECT pg_catalog.setval('s', 42, true);\n"))
+	var b bytes.Buffer
+	b.WriteString("CREATE TABLE t (a bigint PRIMARY KEY, b integer array);\n")
+	b.WriteString("COPY t (a, b) FROM stdin WITH (FORMAT binary);\n")
+	b.Write(copyBinarySignature)
+	b.Write(be32(0))
+	b.Write(be32(0))
+	b.Write(be16(2))
+	b.Write(be32(8))
+	b.Write(be64(1))
+	arr := bytes.Join([][]byte{be32(1), be32(0), be32(23), be32(1), be32(1), be32(4), be32(42)}, nil)
+	b.Write(be32(uint32(len(arr))))
+	b.Write(arr)
+	b.Write(be16(0xffff))
+	b.WriteString("\\.\n")
+	f.Add(b.Bytes())
+	f.Fuzz(func(t *testing.T, data []byte) {
+		runProcessPgDump(string(data))
+	})
+}
+
+func FuzzCopyUnescape(f *testing.F) {
+	for _, s := range []string{`a\tb`, `\x4`, `\101\1`, `\`, `a\\N`, `\xzz\0`} {
+		f.Add(s)
+	}
+	f.Fuzz(func(t *testing.T, s string) {
+		u := copyUnescape(s)
+		if !strings.Contains(s, `\`) && u != s {
+			t.Errorf("copyUnescape(%q) = %q, want the input unchanged", s, u)
+		}
+		if len(u) > len(s) {
+			t.Errorf("copyUnescape(%q) = %q, which is longer than the input", s, u)
+		}
+	})
+}
+
+func FuzzDecodeBinaryValue(f *testing.F) {
+	f.Add("numeric", []byte{0, 2, 0, 0, 0, 0, 0, 4, 0, 1, 0x13, 0x88})
+	f.Add("timestamptz", be64(1))
+	f.Add("int4", be32(42))
+	f.Add("jsonb", []byte("\x01{}"))
+	f.Add("int4", bytes.Join([][]byte{be32(1), be32(0), be32(23), be32(1), be32(1), be32(4), be32(42)}, nil))
+	f.Fuzz(func(t *testing.T, typeName string, b []byte) {
+		conv := internal.MakeConv()
+		decodeBinaryValue(conv, typeName, b)
+		decodeBinaryArray(conv, schema.Type{Name: typeName, ArrayBounds: []int64{-1}}, b)
+	})
+}
@@ -0,0 +1,41 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package postgres
+
+import (
+	"testing"
+
+	"github.com/cloudspannerecosystem/harbourbridge/internal"
+	"github.com/cloudspannerecosystem/harbourbridge/internal/golden"
+)
+
+// TestGolden checks the DDL and report of the schema fixtures of
+// testdata/golden (see package golden).
+func TestGolden(t *testing.T) {
+	golden.Run(t, "testdata/golden", golden.Converter{
+		Driver:     "postgres",
+		DumpDriver: "pg_dump",
+		Schema: func(conv *internal.Conv) error {
+			if err := schemaToDDL(conv); err != nil {
+				return err
+			}
+			conv.AddPrimaryKeys()
+			conv.AddSequences()
+			return nil
+		},
+		Dump:    ProcessPgDump,
+		Targets: []string{golden.Spanner, golden.ExperimentalPostgres},
+	})
+}
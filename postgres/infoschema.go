@@ -17,10 +17,11 @@ package postgres
 import (
 	"database/sql"
 	"fmt"
-	"math/bits"
+	"math"
 	"reflect"
 	"sort"
 	"strconv"
+	"strings"
 	"time"
 
 	"cloud.google.com/go/civil"
@@ -39,21 +40,71 @@ import (
 // ProcessInfoSchema performs schema conversion for source database
 // 'db'. Information schema tables are a broadly supported ANSI standard,
 // and we use them to obtain source database's schema information.
+// Queries that fail with transient errors are retried (see
+// discoveryRetry). Tables whose schema still can't be read are skipped,
+// and reported as unexpected conditions. If conv spills tables to disk
+// (see internal.Conv.SpillTo), each table is converted and spilled as
+// soon as its schema is read.
 func ProcessInfoSchema(conv *internal.Conv, db *sql.DB) error {
-	tables, err := getTables(db)
+	var tables []schemaAndName
+	err := discoveryRetry.Do(func() (err error) {
+		tables, err = getTables(db)
+		return err
+	})
 	if err != nil {
 		return err
 	}
+	if conv.Spilling() {
+		// As in schemaToDDL, reserve the names of all tables before
+		// allocating index and foreign key names.
+		for _, t := range tables {
+			if spTable, err := internal.GetSpannerTable(conv, buildTableName(t.schema, t.name)); err == nil {
+				conv.ReserveName(spTable)
+			}
+		}
+	}
 	for _, t := range tables {
-		if err := processTable(conv, db, t); err != nil {
+		if err := discoveryRetry.Do(func() error { return processTable(conv, db, t) }); err != nil {
+			conv.Unexpected(fmt.Sprintf("Skipped table %s: %s", buildTableName(t.schema, t.name), err))
+			continue
+		}
+		if conv.Spilling() {
+			if err := spillTable(conv, buildTableName(t.schema, t.name)); err != nil {
+				return err
+			}
+		}
+	}
+	if err := discoveryRetry.Do(func() error { return getSequences(conv, db) }); err != nil {
+		conv.Unexpected(err.Error())
+	}
+	if err := discoveryRetry.Do(func() error { return getTriggers(conv, db) }); err != nil {
+		conv.Unexpected(err.Error())
+	}
+	if conv.Spilling() {
+		if err := conv.ResolveSpilledRefs(); err != nil {
 			return err
 		}
+		conv.AddSequences()
+		return nil
+	}
+	if err := schemaToDDL(conv); err != nil {
+		return err
 	}
-	schemaToDDL(conv)
 	conv.AddPrimaryKeys()
+	conv.AddSequences()
 	return nil
 }
 
+// spillTable converts the schema of srcTable, the only table of
+// conv.SrcSchema, and spills it.
+func spillTable(conv *internal.Conv, srcTable string) error {
+	if err := schemaToDDL(conv); err != nil {
+		return err
+	}
+	conv.AddPrimaryKeys()
+	return conv.SpillTable(srcTable)
+}
+
 // ProcessSQLData performs data conversion for source database
 // 'db'. For each table, we extract data using a "SELECT *" query,
 // convert the data to Spanner data (based on the source and Spanner
@@ -73,7 +124,7 @@ func ProcessInfoSchema(conv *internal.Conv, db *sql.DB) error {
 // We choose to do all type conversions explicitly ourselves so that
 // we can generate more targeted error messages: hence we pass
 // *interface{} parameters to row.Scan.
-func ProcessSQLData(conv *internal.Conv, db *sql.DB) {
+func ProcessSQLData(conv *internal.Conv, db internal.Queryer) {
 	// TODO: refactor to use the set of tables computed by
 	// ProcessInfoSchema instead of computing them again.
 	tables, err := getTables(db)
@@ -81,48 +132,153 @@ func ProcessSQLData(conv *internal.Conv, db *sql.DB) {
 		conv.Unexpected(fmt.Sprintf("Couldn't get list of table: %s", err))
 		return
 	}
+	// Tables with a time window are read in the order their windows open
+	// (see internal.Conv.SetTableWindows).
+	now := time.Now()
+	sort.SliceStable(tables, func(i, j int) bool {
+		return conv.WindowDelay(buildTableName(tables[i].schema, tables[i].name), now) < conv.WindowDelay(buildTableName(tables[j].schema, tables[j].name), now)
+	})
 	for _, t := range tables {
-		// PostgreSQL schema and name can be arbitrary strings.
-		// Ideally we would pass schema/name as a query parameter,
-		// but PostgreSQL doesn't support this. So we quote it instead.
-		q := fmt.Sprintf(`SELECT * FROM "%s"."%s";`, t.schema, t.name)
-		rows, err := db.Query(q)
-		if err != nil {
-			conv.Unexpected(fmt.Sprintf("Couldn't get data for table: %s", err))
+		srcTable := buildTableName(t.schema, t.name)
+		// Tables that had no rows when they were counted aren't read.
+		if conv.SkipData(srcTable) || conv.EmptyTable(srcTable) {
 			continue
 		}
-		defer rows.Close()
-		srcTable := buildTableName(t.schema, t.name)
-		srcCols, err1 := rows.Columns()
-		spTable, err2 := internal.GetSpannerTable(conv, srcTable)
-		spCols, err3 := internal.GetSpannerCols(conv, srcTable, srcCols)
-		spSchema, ok1 := conv.SpSchema[spTable]
-		srcSchema, ok2 := conv.SrcSchema[srcTable]
-		if err1 != nil || err2 != nil || err3 != nil || !ok1 || !ok2 {
-			conv.Stats.BadRows[srcTable] += conv.Stats.Rows[srcTable]
-			conv.Unexpected(fmt.Sprintf("Can't get cols and schemas for table %s: err1=%s, err2=%s, err3=%s, ok1=%t, ok2=%t",
-				srcTable, err1, err2, err3, ok1, ok2))
+		conv.WaitForWindow(srcTable)
+		// Only the schema of the table being converted is loaded.
+		if err := conv.LoadTable(srcTable); err != nil {
+			conv.Unexpected(err.Error())
 			continue
 		}
-		v, iv := buildVals(len(srcCols))
-		for rows.Next() {
-			err := rows.Scan(iv...)
-			if err != nil {
-				conv.Unexpected(fmt.Sprintf("Couldn't process sql data row: %s", err))
-				// Scan failed, so we don't have any data to add to bad rows.
-				conv.StatsAddBadRow(srcTable, conv.DataMode())
-				continue
+		processTableData(conv, db, t)
+		conv.UnloadTable(srcTable)
+	}
+}
+
+// processTableData performs data conversion for table t (see
+// ProcessSQLData).
+func processTableData(conv *internal.Conv, db internal.Queryer, t schemaAndName) {
+	// PostgreSQL schema and name can be arbitrary strings.
+	// Ideally we would pass schema/name as a query parameter,
+	// but PostgreSQL doesn't support this. So we quote it instead.
+	srcTable := buildTableName(t.schema, t.name)
+	from := fmt.Sprintf(`"%s"."%s"`, t.schema, t.name)
+	if col, _, ok := conv.IncrementalKey(srcTable); ok {
+		// The watermark is read before the rows, so that rows changed
+		// while they are read are read again by the next load.
+		var watermark sql.NullString
+		if err := db.QueryRow(fmt.Sprintf(`SELECT max("%s")::text FROM "%s"."%s";`, col, t.schema, t.name)).Scan(&watermark); err != nil {
+			conv.Unexpected(fmt.Sprintf("Couldn't get watermark of table %s: %s", srcTable, err))
+			return
+		}
+		conv.SetWatermark(srcTable, watermark.String)
+	}
+	where, args := rowFilter(conv, srcTable, false)
+	conv.StartTable(srcTable)
+	conv.RecordSnapshot(srcTable)
+	f, batched := conv.Fetcher(srcTable)
+	if !batched {
+		processRows(conv, db, srcTable, fmt.Sprintf(`SELECT %s FROM %s%s;`, selectList(conv, srcTable, from), from, where), args, 0)
+		return
+	}
+	// Tables read in batches are read in primary key order, each batch
+	// starting after the primary key of the last row of the previous one.
+	// Keys are read as text, which PostgreSQL converts back to their type.
+	var keys, keyCols []string
+	for _, k := range conv.SrcSchema[srcTable].PrimaryKeys {
+		keys = append(keys, fmt.Sprintf(`%s."%s"`, from, k.Column))
+		keyCols = append(keyCols, fmt.Sprintf(`%s."%s"::text`, from, k.Column))
+	}
+	var last []string
+	for conv.Aborted() == nil {
+		w, a := where, args
+		if last != nil {
+			var params []string
+			for _, k := range last {
+				a = append(a, k)
+				params = append(params, fmt.Sprintf("$%d", len(a)))
 			}
-			cvtCols, cvtVals, err := ConvertSQLRow(conv, srcTable, srcCols, srcSchema, spTable, spCols, spSchema, v)
-			if err != nil {
-				conv.Unexpected(fmt.Sprintf("Couldn't process sql data row: %s", err))
-				conv.StatsAddBadRow(srcTable, conv.DataMode())
-				conv.CollectBadRow(srcTable, srcCols, valsToStrings(v))
-				continue
+			cond := fmt.Sprintf("(%s) > (%s)", strings.Join(keys, ", "), strings.Join(params, ", "))
+			if w == "" {
+				w = " WHERE " + cond
+			} else {
+				w += " AND " + cond
+			}
+		}
+		q := fmt.Sprintf(`SELECT %s, %s FROM %s%s ORDER BY %s LIMIT %d;`, selectList(conv, srcTable, from), strings.Join(keyCols, ", "), from, w, strings.Join(keys, ", "), f.Rows())
+		n, bytes, k, ok := processRows(conv, db, srcTable, q, a, len(keys))
+		if !ok || n < f.Rows() {
+			return
+		}
+		f.Done(n, bytes)
+		last = k
+	}
+}
+
+// processRows converts the rows of srcTable returned by query q. The last
+// nKeys columns of the rows are the primary key of srcTable, as text (see
+// processTableData), and aren't converted. It returns the number of rows
+// read, their size in bytes, the primary key of the last row, and false
+// if the rows couldn't be read.
+func processRows(conv *internal.Conv, db internal.Queryer, srcTable, q string, args []interface{}, nKeys int) (int, int64, []string, bool) {
+	rows, err := db.Query(q, args...)
+	if err != nil {
+		conv.Unexpected(fmt.Sprintf("Couldn't get data for table: %s", err))
+		return 0, 0, nil, false
+	}
+	defer rows.Close()
+	cols, err1 := rows.Columns()
+	srcCols := cols
+	if err1 == nil {
+		srcCols = cols[:len(cols)-nKeys]
+	}
+	spTable, err2 := internal.GetSpannerTable(conv, srcTable)
+	spCols, err3 := internal.GetSpannerCols(conv, srcTable, srcCols)
+	spSchema, ok1 := conv.DataSchema(spTable)
+	srcSchema, ok2 := conv.SrcSchema[srcTable]
+	if err1 != nil || err2 != nil || err3 != nil || !ok1 || !ok2 {
+		conv.StatsAddTableBadRows(srcTable)
+		conv.Unexpected(fmt.Sprintf("Can't get cols and schemas for table %s: err1=%s, err2=%s, err3=%s, ok1=%t, ok2=%t",
+			srcTable, err1, err2, err3, ok1, ok2))
+		return 0, 0, nil, false
+	}
+	v, iv := buildVals(len(cols))
+	var n int
+	var bytes int64
+	var last []string
+	for rows.Next() {
+		if conv.Aborted() != nil {
+			break
+		}
+		n++
+		err := rows.Scan(iv...)
+		if err != nil {
+			conv.Unexpected(fmt.Sprintf("Couldn't process sql data row: %s", err))
+			// Scan failed, so we don't have any data to add to bad rows.
+			conv.StatsAddBadRow(srcTable, conv.DataMode())
+			if nKeys > 0 {
+				// Without the key of the row, the next batch can't
+				// start after it.
+				return n, bytes, last, false
 			}
-			conv.WriteRow(srcTable, spTable, cvtCols, cvtVals)
+			continue
+		}
+		bytes += rowBytes(v)
+		if nKeys > 0 {
+			last = keyStrings(v[len(srcCols):])
+		}
+		vals := v[:len(srcCols)]
+		cvtCols, cvtVals, err := ConvertSQLRow(conv, srcTable, srcCols, srcSchema, spTable, spCols, spSchema, vals)
+		if err != nil {
+			conv.Unexpected(fmt.Sprintf("Couldn't process sql data row: %s", err))
+			conv.StatsAddBadRow(srcTable, conv.DataMode())
+			conv.CollectBadRow(srcTable, srcCols, valsToStrings(vals))
+			conv.GroupBadRow(srcTable, err, srcCols, valsToStrings(vals))
+			continue
 		}
+		conv.WriteRow(srcTable, spTable, cvtCols, cvtVals)
 	}
+	return n, bytes, last, true
 }
 
 // ConvertSQLRow performs data conversion for a single row of data
@@ -135,6 +291,9 @@ func ConvertSQLRow(conv *internal.Conv, srcTable string, srcCols []string, srcSc
 	var vs []interface{}
 	var cs []string
 	for i := range srcCols {
+		if spCols[i] == "" { // Column is not migrated (see internal.DropColumns).
+			continue
+		}
 		srcCd, ok1 := srcSchema.ColDefs[srcCols[i]]
 		spCd, ok2 := spSchema.ColDefs[spCols[i]]
 		if !ok1 || !ok2 {
@@ -147,26 +306,91 @@ func ConvertSQLRow(conv *internal.Conv, srcTable string, srcCols []string, srcSc
 		var err error
 		if spCd.T.IsArray {
 			spVal, err = cvtSQLArray(conv, srcCd, spCd, srcVals[i])
+		} else if s, ok := sqlSpecialValue(spCd.T, srcVals[i]); ok {
+			spVal, err = convSpecialValue(conv, srcTable, srcCols[i], spCd.T, s)
 		} else {
 			spVal, err = cvtSQLScalar(conv, srcCd, spCd, srcVals[i])
 		}
 		if err != nil { // Skip entire row if we hit error.
-			return nil, nil, fmt.Errorf("can't convert sql data for column %s of table %s: %w", srcCols[i], srcTable, err)
+			return nil, nil, fmt.Errorf("can't convert sql data of table %s: %w", srcTable, &internal.ColumnError{Col: srcCols[i], Val: valsToStrings(srcVals[i : i+1])[0], Err: err})
+		}
+		if spVal == nil { // Special value mapped to NULL.
+			continue
 		}
 		vs = append(vs, spVal)
 		cs = append(cs, srcCols[i])
 	}
-	if aux, ok := conv.SyntheticPKeys[spTable]; ok {
-		cs = append(cs, aux.Col)
-		vs = append(vs, int64(bits.Reverse64(uint64(aux.Sequence))))
-		aux.Sequence++
-		conv.SyntheticPKeys[spTable] = aux
+	if col, val, ok := conv.NextSyntheticPKey(spTable); ok {
+		cs = append(cs, col)
+		vs = append(vs, val)
 	}
 	return cs, vs, nil
 }
 
+// rowFilter returns the WHERE clause (empty if all rows are read), and
+// its arguments, that selects the rows of srcTable to convert: the rows
+// changed since the previous load (see internal.Conv.SetIncremental),
+// of the tenant being extracted (see internal.Conv.SetTenant), excluding
+// soft-deleted rows (see internal.Conv.SetSoftDelete). If deleted is set,
+// it selects the soft-deleted rows instead.
+func rowFilter(conv *internal.Conv, srcTable string, deleted bool) (string, []interface{}) {
+	var conds []string
+	var args []interface{}
+	if col, since, ok := conv.IncrementalKey(srcTable); ok && since != "" {
+		args = append(args, since)
+		conds = append(conds, fmt.Sprintf(`"%s" >= $%d`, col, len(args)))
+	}
+	if tenant, path, ok := conv.TenantPath(srcTable); ok {
+		args = append(args, tenant.Value)
+		conds = append(conds, tenantCond(quoteTableName(srcTable), tenant.Column, path, 1, len(args)))
+	}
+	if sd, ok := conv.SoftDeleted(srcTable); ok {
+		cond := fmt.Sprintf(`"%s" IS NOT NULL`, sd.Column)
+		if sd.Value != "" {
+			args = append(args, sd.Value)
+			cond = fmt.Sprintf(`"%s"::text = $%d`, sd.Column, len(args))
+		}
+		// Rows for which the condition is NULL aren't deleted.
+		if deleted {
+			conds = append(conds, fmt.Sprintf("(%s) IS TRUE", cond))
+		} else {
+			conds = append(conds, fmt.Sprintf("(%s) IS NOT TRUE", cond))
+		}
+	}
+	if len(conds) == 0 {
+		return "", nil
+	}
+	return " WHERE " + strings.Join(conds, " AND "), args
+}
+
+// tenantCond returns the condition that selects the rows of table from
+// (quoted, or an alias) whose tenant column, reached by following the
+// foreign keys of path, is query argument arg. Tables of the path are
+// aliased t<depth>.
+func tenantCond(from, col string, path []schema.ForeignKey, depth, arg int) string {
+	if len(path) == 0 {
+		return fmt.Sprintf(`%s."%s"::text = $%d`, from, col, arg)
+	}
+	fk := path[0]
+	alias := fmt.Sprintf("t%d", depth)
+	var join []string
+	for i, c := range fk.Columns {
+		join = append(join, fmt.Sprintf(`%s."%s" = %s."%s"`, alias, fk.ReferColumns[i], from, c))
+	}
+	return fmt.Sprintf(`EXISTS (SELECT 1 FROM %s %s WHERE %s AND %s)`, quoteTableName(fk.ReferTable), alias, strings.Join(join, " AND "), tenantCond(alias, col, path[1:], depth+1, arg))
+}
+
+// quoteTableName returns the quoted schema and name of srcTable (see
+// buildTableName).
+func quoteTableName(srcTable string) string {
+	if i := strings.Index(srcTable, "."); i >= 0 {
+		return fmt.Sprintf(`"%s"."%s"`, srcTable[:i], srcTable[i+1:])
+	}
+	return fmt.Sprintf(`"public"."%s"`, srcTable)
+}
+
 // SetRowStats populates conv with the number of rows in each table.
-func SetRowStats(conv *internal.Conv, db *sql.DB) {
+func SetRowStats(conv *internal.Conv, db internal.Queryer) {
 	// TODO: refactor to use the set of tables computed by
 	// ProcessInfoSchema instead of computing them again.
 	tables, err := getTables(db)
@@ -175,26 +399,39 @@ func SetRowStats(conv *internal.Conv, db *sql.DB) {
 		return
 	}
 	for _, t := range tables {
+		if conv.SkipData(buildTableName(t.schema, t.name)) {
+			continue
+		}
 		// PostgreSQL schema and name can be arbitrary strings.
 		// Ideally we would pass schema/name as a query parameter,
 		// but PostgreSQL doesn't support this. So we quote it instead.
-		q := fmt.Sprintf(`SELECT COUNT(*) FROM "%s"."%s";`, t.schema, t.name)
+		q := fmt.Sprintf(`SELECT COUNT(*) FROM "%s"."%s"`, t.schema, t.name)
 		tableName := buildTableName(t.schema, t.name)
-		rows, err := db.Query(q)
+		if _, ok := conv.SoftDeleted(tableName); ok {
+			where, args := rowFilter(conv, tableName, true)
+			var excluded int64
+			if err := db.QueryRow(q+where+";", args...).Scan(&excluded); err != nil {
+				conv.Unexpected(fmt.Sprintf("Couldn't count soft-deleted rows of table %s: %s", tableName, err))
+			}
+			conv.StatsAddExcludedRows(tableName, excluded)
+		}
+		where, args := rowFilter(conv, tableName, false)
+		rows, err := db.Query(q+where+";", args...)
 		if err != nil {
 			conv.Unexpected(fmt.Sprintf("Couldn't get number of rows for table %s", tableName))
 			continue
 		}
-		defer rows.Close()
 		var count int64
 		if rows.Next() {
 			err := rows.Scan(&count)
 			if err != nil {
+				rows.Close()
 				conv.Unexpected(fmt.Sprintf("Can't get row count: %s", err))
 				continue
 			}
-			conv.Stats.Rows[tableName] += count
+			conv.StatsAddRows(tableName, count)
 		}
+		rows.Close()
 	}
 }
 
@@ -203,7 +440,7 @@ type schemaAndName struct {
 	name   string
 }
 
-func getTables(db *sql.DB) ([]schemaAndName, error) {
+func getTables(db internal.Queryer) ([]schemaAndName, error) {
 	ignored := make(map[string]bool)
 	// Ignore all system tables: we just want to convert user tables.
 	for _, s := range []string{"information_schema", "postgres", "pg_catalog", "pg_temp_1", "pg_toast", "pg_toast_temp_1"} {
@@ -226,42 +463,195 @@ func getTables(db *sql.DB) ([]schemaAndName, error) {
 	return tables, nil
 }
 
+// getSequences records the user sequences of db, with their next value.
+func getSequences(conv *internal.Conv, db *sql.DB) error {
+	if !conv.SrcVersion.AtLeast(10, 0) {
+		return getOldSequences(conv, db)
+	}
+	q := "SELECT schemaname, sequencename, increment_by, start_value, last_value FROM pg_sequences"
+	rows, err := db.Query(q)
+	if err != nil {
+		return fmt.Errorf("couldn't get sequences: %w", err)
+	}
+	defer rows.Close()
+	var seqSchema, seqName string
+	var increment, start int64
+	var last sql.NullInt64
+	for rows.Next() {
+		if err := rows.Scan(&seqSchema, &seqName, &increment, &start, &last); err != nil {
+			conv.Unexpected(fmt.Sprintf("Can't scan: %v", err))
+			continue
+		}
+		seq := schema.Sequence{Name: buildTableName(seqSchema, seqName), Increment: increment, Next: start}
+		if last.Valid { // last_value is NULL if the sequence hasn't been used.
+			seq.Next = last.Int64 + increment
+		}
+		conv.SetSrcSequence(seq)
+	}
+	return nil
+}
+
+// getOldSequences is getSequences for PostgreSQL 9.x, which doesn't have
+// pg_sequences: the last value of each sequence is read from the
+// sequence itself.
+func getOldSequences(conv *internal.Conv, db *sql.DB) error {
+	rows, err := db.Query("SELECT sequence_schema, sequence_name, increment::bigint, start_value::bigint FROM information_schema.sequences")
+	if err != nil {
+		return fmt.Errorf("couldn't get sequences: %w", err)
+	}
+	var seqs []schema.Sequence
+	var seqSchema, seqName string
+	var increment, start int64
+	for rows.Next() {
+		if err := rows.Scan(&seqSchema, &seqName, &increment, &start); err != nil {
+			conv.Unexpected(fmt.Sprintf("Can't scan: %v", err))
+			continue
+		}
+		seqs = append(seqs, schema.Sequence{Name: buildTableName(seqSchema, seqName), Increment: increment, Next: start})
+	}
+	rows.Close()
+	for _, seq := range seqs {
+		var last int64
+		var called bool
+		q := fmt.Sprintf("SELECT last_value, is_called FROM %s", quoteTableName(seq.Name))
+		if err := db.QueryRow(q).Scan(&last, &called); err != nil {
+			conv.Unexpected(fmt.Sprintf("Couldn't get last value of sequence %s: %s", seq.Name, err))
+		} else if called { // last_value is the start value if the sequence hasn't been used.
+			seq.Next = last + seq.Increment
+		}
+		conv.SetSrcSequence(seq)
+	}
+	return nil
+}
+
+// getTriggers records the user triggers of db, with the source of the
+// function they execute.
+func getTriggers(conv *internal.Conv, db *sql.DB) error {
+	q := `SELECT n.nspname, c.relname, t.tgname, t.tgtype, p.prosrc
+              FROM pg_trigger AS t
+                JOIN pg_class AS c ON c.oid = t.tgrelid
+                JOIN pg_namespace AS n ON n.oid = c.relnamespace
+                JOIN pg_proc AS p ON p.oid = t.tgfoid
+              WHERE NOT t.tgisinternal AND n.nspname NOT IN ('pg_catalog', 'information_schema')`
+	rows, err := db.Query(q)
+	if err != nil {
+		return fmt.Errorf("couldn't get triggers: %w", err)
+	}
+	defer rows.Close()
+	var tableSchema, tableName, name, body string
+	var tgtype int16
+	for rows.Next() {
+		if err := rows.Scan(&tableSchema, &tableName, &name, &tgtype, &body); err != nil {
+			conv.Unexpected(fmt.Sprintf("Can't scan: %v", err))
+			continue
+		}
+		// tgtype uses the same bits as the timing and events of
+		// CREATE TRIGGER statements.
+		conv.AddSrcTrigger(schema.Trigger{Name: name, Table: buildTableName(tableSchema, tableName), Timing: triggerTiming(tgtype, tgtype), Body: body})
+	}
+	return nil
+}
+
 func processTable(conv *internal.Conv, db *sql.DB, table schemaAndName) error {
-	cols, err := getColumns(table, db)
+	cols, err := getColumns(table, db, conv.SrcVersion)
 	if err != nil {
-		return fmt.Errorf("couldn't get schema for table %s.%s: %s", table.schema, table.name, err)
+		return fmt.Errorf("couldn't get schema for table %s.%s: %w", table.schema, table.name, err)
 	}
 	defer cols.Close()
 	primaryKeys, constraints, err := getConstraints(conv, db, table)
 	if err != nil {
-		return fmt.Errorf("couldn't get constraints for table %s.%s: %s", table.schema, table.name, err)
+		return fmt.Errorf("couldn't get constraints for table %s.%s: %w", table.schema, table.name, err)
 	}
 	foreignKeys, err := getForeignKeys(conv, db, table)
 	if err != nil {
-		return fmt.Errorf("couldn't get foreign key constraints for table %s.%s: %s", table.schema, table.name, err)
+		return fmt.Errorf("couldn't get foreign key constraints for table %s.%s: %w", table.schema, table.name, err)
 	}
-	indexes, err := getIndexes(conv, db, table)
-	if err != nil {
-		return fmt.Errorf("couldn't get indexes for table %s.%s: %s", table.schema, table.name, err)
+	// Indexes and large objects can't be read before PostgreSQL 9.4 (see
+	// VersionWarnings).
+	var indexes []schema.Index
+	if conv.SrcVersion.AtLeast(9, 4) {
+		indexes, err = getIndexes(conv, db, table)
+		if err != nil {
+			return fmt.Errorf("couldn't get indexes for table %s.%s: %w", table.schema, table.name, err)
+		}
 	}
 	colDefs, colNames := processColumns(conv, cols, constraints)
 	name := buildTableName(table.schema, table.name)
+	if conv.SrcVersion.AtLeast(9, 4) {
+		if err := markLargeObjects(db, table, colDefs, colNames); err != nil {
+			conv.Unexpected(fmt.Sprintf("Couldn't look for large objects referenced by table %s: %s", name, err))
+		}
+	}
 	var schemaPKeys []schema.Key
 	for _, k := range primaryKeys {
 		schemaPKeys = append(schemaPKeys, schema.Key{Column: k})
 	}
-	conv.SrcSchema[name] = schema.Table{
+	conv.SetSrcTable(schema.Table{
 		Name:        name,
 		ColNames:    colNames,
 		ColDefs:     colDefs,
 		PrimaryKeys: schemaPKeys,
 		Indexes:     indexes,
-		ForeignKeys: foreignKeys}
+		ForeignKeys: foreignKeys})
+	return nil
+}
+
+// markLargeObjects marks the oid columns of table that reference large
+// objects (see schema.Column.LargeObject): those that have a value that is
+// the oid of a large object.
+func markLargeObjects(db *sql.DB, table schemaAndName, colDefs map[string]schema.Column, colNames []string) error {
+	for _, name := range colNames {
+		c := colDefs[name]
+		if c.Type.Name != "oid" || len(c.Type.ArrayBounds) > 0 {
+			continue
+		}
+		q := fmt.Sprintf(`SELECT EXISTS (SELECT 1 FROM "%s"."%s" AS t JOIN pg_largeobject_metadata AS m ON m.oid = t."%s")`, table.schema, table.name, name)
+		if err := db.QueryRow(q).Scan(&c.LargeObject); err != nil {
+			return err
+		}
+		colDefs[name] = c
+	}
 	return nil
 }
 
-func getColumns(table schemaAndName, db *sql.DB) (*sql.Rows, error) {
-	q := `SELECT c.column_name, c.data_type, e.data_type, c.is_nullable, c.column_default, c.character_maximum_length, c.numeric_precision, c.numeric_scale
+// selectList returns the select list of a query reading the rows of
+// srcTable from table from (quoted): *, unless srcTable has large object
+// columns (see colExpr).
+func selectList(conv *internal.Conv, srcTable, from string) string {
+	srcSchema := conv.SrcSchema[srcTable]
+	var l []string
+	lo := false
+	for _, name := range srcSchema.ColNames {
+		l = append(l, colExpr(from, srcSchema.ColDefs[name]))
+		lo = lo || srcSchema.ColDefs[name].LargeObject
+	}
+	if !lo {
+		return "*"
+	}
+	return strings.Join(l, ", ")
+}
+
+// colExpr returns the expression reading column c of table from (quoted).
+// The large objects of large object columns are read (with lo_get)
+// instead of their oid. Large objects that don't exist are read as NULL,
+// rather than failing the query.
+func colExpr(from string, c schema.Column) string {
+	if !c.LargeObject {
+		return fmt.Sprintf(`"%s"`, c.Name)
+	}
+	col := fmt.Sprintf(`%s."%s"`, from, c.Name)
+	return fmt.Sprintf(`CASE WHEN EXISTS (SELECT 1 FROM pg_largeobject_metadata AS m WHERE m.oid = %s) THEN lo_get(%s) END AS "%s"`, col, col, c.Name)
+}
+
+func getColumns(table schemaAndName, db *sql.DB, version internal.ServerVersion) (*sql.Rows, error) {
+	// collation_name is NULL for columns using the default collation of
+	// the database. Collations were added in PostgreSQL 9.1.
+	collation := `COALESCE(c.collation_name, CASE WHEN c.data_type IN ('text', 'character varying', 'character') THEN (SELECT datcollate FROM pg_database WHERE datname = current_database()) END)`
+	if !version.AtLeast(9, 1) {
+		collation = "NULL"
+	}
+	q := `SELECT c.column_name, c.data_type, e.data_type, c.is_nullable, c.column_default, c.character_maximum_length, c.numeric_precision, c.numeric_scale,
+                     ` + collation + `
               FROM information_schema.COLUMNS c LEFT JOIN information_schema.element_types e
                  ON ((c.table_catalog, c.table_schema, c.table_name, 'TABLE', c.dtd_identifier)
                      = (e.object_catalog, e.object_schema, e.object_name, e.object_type, e.collection_type_identifier))
@@ -273,10 +663,10 @@ func processColumns(conv *internal.Conv, cols *sql.Rows, constraints map[string]
 	colDefs := make(map[string]schema.Column)
 	var colNames []string
 	var colName, dataType, isNullable string
-	var colDefault, elementDataType sql.NullString
+	var colDefault, elementDataType, collation sql.NullString
 	var charMaxLen, numericPrecision, numericScale sql.NullInt64
 	for cols.Next() {
-		err := cols.Scan(&colName, &dataType, &elementDataType, &isNullable, &colDefault, &charMaxLen, &numericPrecision, &numericScale)
+		err := cols.Scan(&colName, &dataType, &elementDataType, &isNullable, &colDefault, &charMaxLen, &numericPrecision, &numericScale, &collation)
 		if err != nil {
 			conv.Unexpected(fmt.Sprintf("Can't scan: %v", err))
 			continue
@@ -295,10 +685,11 @@ func processColumns(conv *internal.Conv, cols *sql.Rows, constraints map[string]
 		}
 		ignored.Default = colDefault.Valid
 		c := schema.Column{
-			Name:    colName,
-			Type:    toType(dataType, elementDataType, charMaxLen, numericPrecision, numericScale),
-			NotNull: toNotNull(conv, isNullable),
-			Ignored: ignored,
+			Name:      colName,
+			Type:      toType(dataType, elementDataType, charMaxLen, numericPrecision, numericScale),
+			NotNull:   toNotNull(conv, isNullable),
+			Ignored:   ignored,
+			Collation: collation.String,
 		}
 		colDefs[colName] = c
 		colNames = append(colNames, colName)
@@ -421,12 +812,20 @@ func getForeignKeys(conv *internal.Conv, db *sql.DB, table schemaAndName) (forei
 // See https://stackoverflow.com/questions/6777456/list-all-index-names-column-names-and-its-table-name-of-a-postgresql-database/44460269#44460269
 // for background.
 func getIndexes(conv *internal.Conv, db *sql.DB, table schemaAndName) ([]schema.Index, error) {
+	// array_position was added in PostgreSQL 9.5: before, the position of
+	// columns is their ordinality in indkey.
+	position := "array_position(i.indkey, a.attnum)"
+	if !conv.SrcVersion.AtLeast(9, 5) {
+		position = "c.ordinality"
+	}
 	q := `SELECT
 			irel.relname AS index_name,
 			a.attname AS column_name,
-			1 + Array_position(i.indkey, a.attnum) AS column_position,
+			1 + ` + position + ` AS column_position,
 			i.indisunique AS is_unique,
-			CASE o.OPTION & 1 WHEN 1 THEN 'DESC' ELSE 'ASC' END AS order
+			CASE o.OPTION & 1 WHEN 1 THEN 'DESC' ELSE 'ASC' END AS order,
+			EXISTS (SELECT 1 FROM pg_constraint AS con WHERE con.conindid = i.indexrelid AND con.contype = 'u') AS is_constraint,
+			(SELECT s.idx_scan FROM pg_stat_user_indexes AS s WHERE s.indexrelid = i.indexrelid) AS scans
 		FROM pg_index AS i
 		JOIN pg_class AS trel
 		ON trel.oid = i.indrelid
@@ -447,26 +846,30 @@ func getIndexes(conv *internal.Conv, db *sql.DB, table schemaAndName) ([]schema.
            		trel.relname,
            		irel.relname,
            		a.attname,
-           		array_position(i.indkey, a.attnum),
-           		o.OPTION,i.indisunique
-		ORDER BY irel.relname, array_position(i.indkey, a.attnum);`
+           		` + position + `,
+           		o.OPTION,i.indisunique,
+           		i.indexrelid
+		ORDER BY irel.relname, ` + position + `;`
 	rows, err := db.Query(q, table.schema, table.name)
 	if err != nil {
 		return nil, err
 	}
 	defer rows.Close()
-	var name, column, sequence, isUnique, collation string
+	var name, column, sequence, isUnique, collation, isConstraint string
+	var scans sql.NullInt64
 	indexMap := make(map[string]schema.Index)
 	var indexNames []string
 	var indexes []schema.Index
 	for rows.Next() {
-		if err := rows.Scan(&name, &column, &sequence, &isUnique, &collation); err != nil {
+		if err := rows.Scan(&name, &column, &sequence, &isUnique, &collation, &isConstraint, &scans); err != nil {
 			conv.Unexpected(fmt.Sprintf("Can't scan: %v", err))
 			continue
 		}
 		if _, found := indexMap[name]; !found {
 			indexNames = append(indexNames, name)
-			indexMap[name] = schema.Index{Name: name, Unique: (isUnique == "true")}
+			// idx_scan counts the scans of the index since the statistics
+			// of the database were reset.
+			indexMap[name] = schema.Index{Name: name, Unique: (isUnique == "true"), Constraint: (isConstraint == "true"), Scans: scans.Int64, HasScans: scans.Valid}
 		}
 		index := indexMap[name]
 		index.Keys = append(index.Keys, schema.Key{Column: column, Desc: (collation == "DESC")})
@@ -514,6 +917,30 @@ func cvtSQLArray(conv *internal.Conv, srcCd schema.Column, spCd ddl.ColumnDef, v
 	return convArray(spCd.T, srcCd.Type.Name, conv.Location, string(a))
 }
 
+// sqlSpecialValue checks whether val (a value returned from a SQL
+// query) is a special value for Spanner type spannerType, and if so
+// returns its string form. The lib/pq driver returns 'infinity'
+// dates and timestamps as []byte, and float8 NaN/Infinity as float64.
+func sqlSpecialValue(spannerType ddl.Type, val interface{}) (string, bool) {
+	var s string
+	switch v := val.(type) {
+	case []byte:
+		s = string(v)
+	case string:
+		s = v
+	case float64:
+		switch {
+		case math.IsNaN(v):
+			s = "NaN"
+		case math.IsInf(v, 1):
+			s = "Infinity"
+		case math.IsInf(v, -1):
+			s = "-Infinity"
+		}
+	}
+	return s, isSpecialValue(spannerType, s)
+}
+
 // cvtSQLScalar converts a values returned from a SQL query to a
 // Spanner value.  In principle, we could just hand the values we get
 // from the driver over to Spanner and have the Spanner client handle
@@ -522,12 +949,13 @@ func cvtSQLArray(conv *internal.Conv, srcCd schema.Column, spCd ddl.ColumnDef, v
 // messages. Note that the caller is responsible for handling nil
 // values (used to represent NULL). We handle each of the remaining
 // cases of values returned by the database/sql library:
-//    bool
-//    []byte
-//    int64
-//    float64
-//    string
-//    time.Time
+//
+//	bool
+//	[]byte
+//	int64
+//	float64
+//	string
+//	time.Time
 func cvtSQLScalar(conv *internal.Conv, srcCd schema.Column, spCd ddl.ColumnDef, val interface{}) (interface{}, error) {
 	switch spCd.T.Name {
 	case ddl.Bool:
@@ -577,10 +1005,38 @@ func cvtSQLScalar(conv *internal.Conv, srcCd schema.Column, spCd ddl.ColumnDef,
 		case string:
 			return convFloat64(v)
 		}
+	case ddl.Float32:
+		switch v := val.(type) {
+		case []byte:
+			return convFloat32(string(v))
+		case int64:
+			return float64(float32(v)), nil
+		case float64:
+			return float64(float32(v)), nil
+		case string:
+			return convFloat32(v)
+		}
+	case ddl.JSON:
+		switch v := val.(type) {
+		case []byte:
+			return string(v), nil
+		case string:
+			return v, nil
+		}
 	case ddl.Numeric:
 		switch v := val.(type) {
 		case []byte: // Note: PostgreSQL uses []byte for numeric.
 			return convNumeric(string(v))
+		case float64:
+			// A float4 or float8 column converted to NUMERIC (see
+			// internal.Remodel.Money). lib/pq parses float4 values as
+			// 32-bit floats: the shortest decimal that parses to the same
+			// float is the text PostgreSQL sent.
+			bits := 64
+			if srcCd.Type.Name == "float4" || srcCd.Type.Name == "real" {
+				bits = 32
+			}
+			return convNumeric(strconv.FormatFloat(v, 'f', -1, bits))
 		}
 	case ddl.String:
 		switch v := val.(type) {
@@ -638,6 +1094,38 @@ func valsToStrings(vals []interface{}) []string {
 	return s
 }
 
+// rowBytes returns the approximate size of a row: the size of its strings
+// and byte slices, and 8 bytes for other values.
+func rowBytes(vals []interface{}) int64 {
+	var n int64
+	for _, val := range vals {
+		switch v := val.(type) {
+		case nil:
+		case []byte:
+			n += int64(len(v))
+		case string:
+			n += int64(len(v))
+		default:
+			n += 8
+		}
+	}
+	return n
+}
+
+// keyStrings returns the values of a primary key read as text.
+func keyStrings(vals []interface{}) []string {
+	var l []string
+	for _, val := range vals {
+		switch v := val.(type) {
+		case []byte:
+			l = append(l, string(v))
+		default:
+			l = append(l, fmt.Sprint(v))
+		}
+	}
+	return l
+}
+
 func buildTableName(schema, name string) string {
 	if schema == "public" { // Drop 'public' prefix.
 		return name
@@ -25,6 +25,7 @@ import (
 	"github.com/cloudspannerecosystem/harbourbridge/internal"
 	"github.com/cloudspannerecosystem/harbourbridge/schema"
 	"github.com/cloudspannerecosystem/harbourbridge/spanner/ddl"
+	"github.com/lib/pq"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -33,6 +34,7 @@ type mockSpec struct {
 	args  []driver.Value   // Query args.
 	cols  []string         // Columns names for returned rows.
 	rows  [][]driver.Value // Set of rows returned.
+	err   error            // Error returned instead of rows.
 }
 
 func TestProcessInfoSchema(t *testing.T) {
@@ -50,11 +52,11 @@ func TestProcessInfoSchema(t *testing.T) {
 		{
 			query: "SELECT (.+) FROM information_schema.COLUMNS (.+)",
 			args:  []driver.Value{"public", "user"},
-			cols:  []string{"column_name", "data_type", "data_type", "is_nullable", "column_default", "character_maximum_length", "numeric_precision", "numeric_scale"},
+			cols:  []string{"column_name", "data_type", "data_type", "is_nullable", "column_default", "character_maximum_length", "numeric_precision", "numeric_scale", "collation_name"},
 			rows: [][]driver.Value{
-				{"user_id", "text", nil, "NO", nil, nil, nil, nil},
-				{"name", "text", nil, "NO", nil, nil, nil, nil},
-				{"ref", "bigint", nil, "YES", nil, nil, nil, nil}},
+				{"user_id", "text", nil, "NO", nil, nil, nil, nil, nil},
+				{"name", "text", nil, "NO", nil, nil, nil, nil, nil},
+				{"ref", "bigint", nil, "YES", nil, nil, nil, nil, nil}},
 		}, {
 			query: "SELECT (.+) FROM INFORMATION_SCHEMA.TABLE_CONSTRAINTS (.+)",
 			args:  []driver.Value{"public", "user"},
@@ -72,15 +74,15 @@ func TestProcessInfoSchema(t *testing.T) {
 		}, {
 			query: "SELECT (.+) FROM pg_index (.+)",
 			args:  []driver.Value{"public", "user"},
-			cols:  []string{"index_name", "column_name", "column_position", "is_unique", "order"},
+			cols:  []string{"index_name", "column_name", "column_position", "is_unique", "order", "is_constraint", "scans"},
 		}, {
 			query: "SELECT (.+) FROM information_schema.COLUMNS (.+)",
 			args:  []driver.Value{"public", "cart"},
-			cols:  []string{"column_name", "data_type", "data_type", "is_nullable", "column_default", "character_maximum_length", "numeric_precision", "numeric_scale"},
+			cols:  []string{"column_name", "data_type", "data_type", "is_nullable", "column_default", "character_maximum_length", "numeric_precision", "numeric_scale", "collation_name"},
 			rows: [][]driver.Value{
-				{"productid", "text", nil, "NO", nil, nil, nil, nil},
-				{"userid", "text", nil, "NO", nil, nil, nil, nil},
-				{"quantity", "bigint", nil, "YES", nil, nil, 64, 0}},
+				{"productid", "text", nil, "NO", nil, nil, nil, nil, nil},
+				{"userid", "text", nil, "NO", nil, nil, nil, nil, nil},
+				{"quantity", "bigint", nil, "YES", nil, nil, 64, 0, nil}},
 		}, {
 			query: "SELECT (.+) FROM INFORMATION_SCHEMA.TABLE_CONSTRAINTS (.+)",
 			args:  []driver.Value{"public", "cart"},
@@ -98,20 +100,20 @@ func TestProcessInfoSchema(t *testing.T) {
 		}, {
 			query: "SELECT (.+) FROM pg_index (.+)",
 			args:  []driver.Value{"public", "cart"},
-			cols:  []string{"index_name", "column_name", "column_position", "is_unique", "order"},
-			rows: [][]driver.Value{{"index1", "userid", 1, "false", "ASC"},
-				{"index2", "userid", 1, "true", "ASC"},
-				{"index2", "productid", 2, "true", "DESC"},
-				{"index3", "productid", 1, "true", "DESC"},
-				{"index3", "userid", 2, "true", "ASC"},
+			cols:  []string{"index_name", "column_name", "column_position", "is_unique", "order", "is_constraint", "scans"},
+			rows: [][]driver.Value{{"index1", "userid", 1, "false", "ASC", "false", 0},
+				{"index2", "userid", 1, "true", "ASC", "false", 7},
+				{"index2", "productid", 2, "true", "DESC", "false", 7},
+				{"index3", "productid", 1, "true", "DESC", "true", nil},
+				{"index3", "userid", 2, "true", "ASC", "true", nil},
 			},
 		}, {
 			query: "SELECT (.+) FROM information_schema.COLUMNS (.+)",
 			args:  []driver.Value{"public", "product"},
-			cols:  []string{"column_name", "data_type", "data_type", "is_nullable", "column_default", "character_maximum_length", "numeric_precision", "numeric_scale"},
+			cols:  []string{"column_name", "data_type", "data_type", "is_nullable", "column_default", "character_maximum_length", "numeric_precision", "numeric_scale", "collation_name"},
 			rows: [][]driver.Value{
-				{"product_id", "text", nil, "NO", nil, nil, nil, nil},
-				{"product_name", "text", nil, "NO", nil, nil, nil, nil}},
+				{"product_id", "text", nil, "NO", nil, nil, nil, nil, nil},
+				{"product_name", "text", nil, "NO", nil, nil, nil, nil, nil}},
 		}, {
 			query: "SELECT (.+) FROM INFORMATION_SCHEMA.TABLE_CONSTRAINTS (.+)",
 			args:  []driver.Value{"public", "product"},
@@ -125,33 +127,33 @@ func TestProcessInfoSchema(t *testing.T) {
 		}, {
 			query: "SELECT (.+) FROM pg_index (.+)",
 			args:  []driver.Value{"public", "product"},
-			cols:  []string{"index_name", "column_name", "column_position", "is_unique", "order"},
+			cols:  []string{"index_name", "column_name", "column_position", "is_unique", "order", "is_constraint", "scans"},
 		}, {
 			query: "SELECT (.+) FROM information_schema.COLUMNS (.+)",
 			args:  []driver.Value{"public", "test"},
-			cols:  []string{"column_name", "data_type", "data_type", "is_nullable", "column_default", "character_maximum_length", "numeric_precision", "numeric_scale"},
+			cols:  []string{"column_name", "data_type", "data_type", "is_nullable", "column_default", "character_maximum_length", "numeric_precision", "numeric_scale", "collation_name"},
 			rows: [][]driver.Value{
-				{"id", "bigint", nil, "NO", nil, nil, 64, 0},
-				{"aint", "ARRAY", "integer", "YES", nil, nil, nil, nil},
-				{"atext", "ARRAY", "text", "YES", nil, nil, nil, nil},
-				{"b", "boolean", nil, "YES", nil, nil, nil, nil},
-				{"bs", "bigint", nil, "NO", "nextval('test11_bs_seq'::regclass)", nil, 64, 0},
-				{"by", "bytea", nil, "YES", nil, nil, nil, nil},
-				{"c", "character", nil, "YES", nil, 1, nil, nil},
-				{"c8", "character", nil, "YES", nil, 8, nil, nil},
-				{"d", "date", nil, "YES", nil, nil, nil, nil},
-				{"f8", "double precision", nil, "YES", nil, nil, 53, nil},
-				{"f4", "real", nil, "YES", nil, nil, 24, nil},
-				{"i8", "bigint", nil, "YES", nil, nil, 64, 0},
-				{"i4", "integer", nil, "YES", nil, nil, 32, 0},
-				{"i2", "smallint", nil, "YES", nil, nil, 16, 0},
-				{"num", "numeric", nil, "YES", nil, nil, nil, nil},
-				{"s", "integer", nil, "NO", "nextval('test11_s_seq'::regclass)", nil, 32, 0},
-				{"ts", "timestamp without time zone", nil, "YES", nil, nil, nil, nil},
-				{"tz", "timestamp with time zone", nil, "YES", nil, nil, nil, nil},
-				{"txt", "text", nil, "NO", nil, nil, nil, nil},
-				{"vc", "character varying", nil, "YES", nil, nil, nil, nil},
-				{"vc6", "character varying", nil, "YES", nil, 6, nil, nil}},
+				{"id", "bigint", nil, "NO", nil, nil, 64, 0, nil},
+				{"aint", "ARRAY", "integer", "YES", nil, nil, nil, nil, nil},
+				{"atext", "ARRAY", "text", "YES", nil, nil, nil, nil, nil},
+				{"b", "boolean", nil, "YES", nil, nil, nil, nil, nil},
+				{"bs", "bigint", nil, "NO", "nextval('test11_bs_seq'::regclass)", nil, 64, 0, nil},
+				{"by", "bytea", nil, "YES", nil, nil, nil, nil, nil},
+				{"c", "character", nil, "YES", nil, 1, nil, nil, nil},
+				{"c8", "character", nil, "YES", nil, 8, nil, nil, nil},
+				{"d", "date", nil, "YES", nil, nil, nil, nil, nil},
+				{"f8", "double precision", nil, "YES", nil, nil, 53, nil, nil},
+				{"f4", "real", nil, "YES", nil, nil, 24, nil, nil},
+				{"i8", "bigint", nil, "YES", nil, nil, 64, 0, nil},
+				{"i4", "integer", nil, "YES", nil, nil, 32, 0, nil},
+				{"i2", "smallint", nil, "YES", nil, nil, 16, 0, nil},
+				{"num", "numeric", nil, "YES", nil, nil, nil, nil, nil},
+				{"s", "integer", nil, "NO", "nextval('test11_s_seq'::regclass)", nil, 32, 0, nil},
+				{"ts", "timestamp without time zone", nil, "YES", nil, nil, nil, nil, nil},
+				{"tz", "timestamp with time zone", nil, "YES", nil, nil, nil, nil, nil},
+				{"txt", "text", nil, "NO", nil, nil, nil, nil, nil},
+				{"vc", "character varying", nil, "YES", nil, nil, nil, nil, nil},
+				{"vc6", "character varying", nil, "YES", nil, 6, nil, nil, nil}},
 		}, {
 			query: "SELECT (.+) FROM INFORMATION_SCHEMA.TABLE_CONSTRAINTS (.+)",
 			args:  []driver.Value{"public", "test"},
@@ -166,15 +168,15 @@ func TestProcessInfoSchema(t *testing.T) {
 		}, {
 			query: "SELECT (.+) FROM pg_index (.+)",
 			args:  []driver.Value{"public", "test"},
-			cols:  []string{"index_name", "column_name", "column_position", "is_unique", "order"},
+			cols:  []string{"index_name", "column_name", "column_position", "is_unique", "order", "is_constraint", "scans"},
 		}, {
 			query: "SELECT (.+) FROM information_schema.COLUMNS (.+)",
 			args:  []driver.Value{"public", "test_ref"},
-			cols:  []string{"column_name", "data_type", "data_type", "is_nullable", "column_default", "character_maximum_length", "numeric_precision", "numeric_scale"},
+			cols:  []string{"column_name", "data_type", "data_type", "is_nullable", "column_default", "character_maximum_length", "numeric_precision", "numeric_scale", "collation_name"},
 			rows: [][]driver.Value{
-				{"ref_id", "bigint", nil, "NO", nil, nil, 64, 0},
-				{"ref_txt", "text", nil, "NO", nil, nil, nil, nil},
-				{"abc", "text", nil, "NO", nil, nil, nil, nil}},
+				{"ref_id", "bigint", nil, "NO", nil, nil, 64, 0, nil},
+				{"ref_txt", "text", nil, "NO", nil, nil, nil, nil, nil},
+				{"abc", "text", nil, "NO", nil, nil, nil, nil, nil}},
 		}, {
 			query: "SELECT (.+) FROM INFORMATION_SCHEMA.TABLE_CONSTRAINTS (.+)",
 			args:  []driver.Value{"public", "test_ref"},
@@ -189,13 +191,31 @@ func TestProcessInfoSchema(t *testing.T) {
 		}, {
 			query: "SELECT (.+) FROM pg_index (.+)",
 			args:  []driver.Value{"public", "test_ref"},
-			cols:  []string{"index_name", "column_name", "column_position", "is_unique", "order"},
+			cols:  []string{"index_name", "column_name", "column_position", "is_unique", "order", "is_constraint", "scans"},
+		}, {
+			query: "SELECT (.+) FROM pg_sequences",
+			cols:  []string{"schemaname", "sequencename", "increment_by", "start_value", "last_value"},
+			rows: [][]driver.Value{
+				{"public", "test_id_seq", int64(1), int64(1), int64(42)},
+				{"other", "unused_seq", int64(10), int64(100), nil}},
+		}, {
+			query: "SELECT (.+) FROM pg_trigger (.+)",
+			cols:  []string{"nspname", "relname", "tgname", "tgtype", "prosrc"},
+			rows: [][]driver.Value{
+				{"public", "user", "user_touch", int64(19), "BEGIN NEW.updated_at := now(); RETURN NEW; END;"}},
 		},
 	}
 	db := mkMockDB(t, ms)
 	conv := internal.MakeConv()
 	err := ProcessInfoSchema(conv, db)
 	assert.Nil(t, err)
+	assert.Equal(t, map[string]schema.Sequence{
+		"test_id_seq":      {Name: "test_id_seq", Increment: 1, Next: 43},
+		"other.unused_seq": {Name: "other.unused_seq", Increment: 10, Next: 100},
+	}, conv.SrcSequences)
+	assert.Equal(t, map[string][]schema.Trigger{
+		"user": {{Name: "user_touch", Table: "user", Timing: "BEFORE UPDATE", Body: "BEGIN NEW.updated_at := now(); RETURN NEW; END;"}},
+	}, conv.SrcTriggers)
 	expectedSchema := map[string]ddl.CreateTable{
 		"user": ddl.CreateTable{
 			Name:     "user",
@@ -279,9 +299,73 @@ func TestProcessInfoSchema(t *testing.T) {
 		"ts":   []internal.SchemaIssue{internal.Timestamp},
 	}
 	assert.Equal(t, expectedIssues, conv.Issues["test"])
+	assert.Equal(t, map[string]int64{"index1": 0, "index2": 7}, conv.IndexScans)
 	assert.Equal(t, int64(0), conv.Unexpecteds())
 }
 
+func TestProcessInfoSchemaRetry(t *testing.T) {
+	defer func(p internal.RetryPolicy) { discoveryRetry = p }(discoveryRetry)
+	discoveryRetry.Delay = 0
+	ms := []mockSpec{
+		{
+			query: "SELECT table_schema, table_name FROM information_schema.tables where table_type = 'BASE TABLE'",
+			err:   &pq.Error{Code: "57P01"}, // Admin shutdown.
+		}, {
+			query: "SELECT table_schema, table_name FROM information_schema.tables where table_type = 'BASE TABLE'",
+			cols:  []string{"table_schema", "table_name"},
+			rows:  [][]driver.Value{{"public", "a"}, {"public", "b"}},
+		}, {
+			query: "SELECT (.+) FROM information_schema.COLUMNS (.+)",
+			args:  []driver.Value{"public", "a"},
+			cols:  []string{"column_name", "data_type", "data_type", "is_nullable", "column_default", "character_maximum_length", "numeric_precision", "numeric_scale", "collation_name"},
+			rows:  [][]driver.Value{{"id", "bigint", nil, "NO", nil, nil, nil, nil, nil}},
+		}, {
+			query: "SELECT (.+) FROM INFORMATION_SCHEMA.TABLE_CONSTRAINTS (.+)",
+			args:  []driver.Value{"public", "a"},
+			err:   &pq.Error{Code: "40P01"}, // Deadlock.
+		}, {
+			query: "SELECT (.+) FROM information_schema.COLUMNS (.+)",
+			args:  []driver.Value{"public", "a"},
+			cols:  []string{"column_name", "data_type", "data_type", "is_nullable", "column_default", "character_maximum_length", "numeric_precision", "numeric_scale", "collation_name"},
+			rows:  [][]driver.Value{{"id", "bigint", nil, "NO", nil, nil, nil, nil, nil}},
+		}, {
+			query: "SELECT (.+) FROM INFORMATION_SCHEMA.TABLE_CONSTRAINTS (.+)",
+			args:  []driver.Value{"public", "a"},
+			cols:  []string{"column_name", "constraint_type"},
+			rows:  [][]driver.Value{{"id", "PRIMARY KEY"}},
+		}, {
+			query: "SELECT (.+) FROM PG_CLASS (.+) JOIN PG_NAMESPACE (.+) JOIN PG_CONSTRAINT (.+)",
+			args:  []driver.Value{"public", "a"},
+			cols:  []string{"TABLE_SCHEMA", "TABLE_NAME", "COLUMN_NAME", "REF_COLUMN_NAME", "CONSTRAINT_NAME"},
+		}, {
+			query: "SELECT (.+) FROM pg_index (.+)",
+			args:  []driver.Value{"public", "a"},
+			cols:  []string{"index_name", "column_name", "column_position", "is_unique", "order", "is_constraint", "scans"},
+		}, {
+			query: "SELECT (.+) FROM information_schema.COLUMNS (.+)",
+			args:  []driver.Value{"public", "b"},
+			err:   &pq.Error{Code: "42501"}, // Insufficient privilege: not retried.
+		}, {
+			query: "SELECT (.+) FROM pg_sequences",
+			cols:  []string{"schemaname", "sequencename", "increment_by", "start_value", "last_value"},
+		}, {
+			query: "SELECT (.+) FROM pg_trigger (.+)",
+			cols:  []string{"nspname", "relname", "tgname", "tgtype", "prosrc"},
+		},
+	}
+	db := mkMockDB(t, ms)
+	conv := internal.MakeConv()
+	assert.Nil(t, ProcessInfoSchema(conv, db))
+	assert.Equal(t, ddl.CreateTable{
+		Name:     "a",
+		ColNames: []string{"id"},
+		ColDefs:  map[string]ddl.ColumnDef{"id": ddl.ColumnDef{Name: "id", T: ddl.Type{Name: ddl.Int64}, NotNull: true}},
+		Pks:      []ddl.IndexKey{ddl.IndexKey{Col: "id"}},
+	}, stripSchemaComments(conv.SpSchema)["a"])
+	assert.NotContains(t, conv.SpSchema, "b")
+	assert.Equal(t, int64(1), conv.Unexpecteds())
+}
+
 // TestProcessSqlData is a basic test of ProcessSqlData that checks
 // handling of bad rows and table and column renaming. The core data
 // conversion work of ProcessSqlData is done by ConvertData, which is
@@ -338,6 +422,175 @@ func TestProcessSqlData(t *testing.T) {
 	assert.Equal(t, int64(1), conv.Unexpecteds()) // Bad row generates an entry in unexpected.
 }
 
+func TestProcessSqlDataIncremental(t *testing.T) {
+	ms := []mockSpec{
+		{
+			query: "SELECT table_schema, table_name FROM information_schema.tables where table_type = 'BASE TABLE'",
+			cols:  []string{"table_schema", "table_name"},
+			rows:  [][]driver.Value{{"public", "t"}},
+		}, {
+			query: `SELECT max[(]"v"[)]::text FROM "public"."t"`,
+			cols:  []string{"max"},
+			rows:  [][]driver.Value{{"7"}},
+		}, {
+			query: `SELECT [*] FROM "public"."t" WHERE "v" >= [$]1`,
+			args:  []driver.Value{"5"},
+			cols:  []string{"a", "v"},
+			rows:  [][]driver.Value{{1, 5}, {2, 7}},
+		},
+	}
+	db := mkMockDB(t, ms)
+	conv := buildConv(
+		ddl.CreateTable{
+			Name:     "t",
+			ColNames: []string{"a", "v"},
+			ColDefs: map[string]ddl.ColumnDef{
+				"a": ddl.ColumnDef{Name: "a", T: ddl.Type{Name: ddl.Int64}},
+				"v": ddl.ColumnDef{Name: "v", T: ddl.Type{Name: ddl.Int64}},
+			}},
+		schema.Table{
+			Name:     "t",
+			ColNames: []string{"a", "v"},
+			ColDefs: map[string]schema.Column{
+				"a": schema.Column{Name: "a", Type: schema.Type{Name: "int8"}},
+				"v": schema.Column{Name: "v", Type: schema.Type{Name: "int8"}},
+			}})
+	assert.Nil(t, conv.SetIncremental(map[string]string{"t": "v"}, map[string]string{"t": "5"}))
+	conv.SetDataMode()
+	var rows []spannerData
+	conv.SetDataSink(
+		func(table string, cols []string, vals []interface{}) {
+			rows = append(rows, spannerData{table: table, cols: cols, vals: vals})
+		})
+	ProcessSQLData(conv, db)
+	assert.Equal(t, 2, len(rows))
+	assert.Equal(t, map[string]string{"t": "7"}, conv.Watermarks())
+	assert.Equal(t, int64(0), conv.Unexpecteds())
+}
+
+func TestProcessSqlDataFetchSize(t *testing.T) {
+	ms := []mockSpec{
+		{
+			query: "SELECT table_schema, table_name FROM information_schema.tables where table_type = 'BASE TABLE'",
+			cols:  []string{"table_schema", "table_name"},
+			rows:  [][]driver.Value{{"public", "t"}},
+		}, {
+			query: `SELECT [*], "public"."t"."a"::text FROM "public"."t" ORDER BY "public"."t"."a" LIMIT 2;`,
+			cols:  []string{"a", "b", "a"},
+			rows:  [][]driver.Value{{1, "x", "1"}, {2, "y", "2"}},
+		}, {
+			query: `SELECT [*], "public"."t"."a"::text FROM "public"."t" WHERE [(]"public"."t"."a"[)] > [(][$]1[)] ORDER BY "public"."t"."a" LIMIT 2;`,
+			args:  []driver.Value{"2"},
+			cols:  []string{"a", "b", "a"},
+			rows:  [][]driver.Value{{3, "z", "3"}},
+		},
+	}
+	db := mkMockDB(t, ms)
+	conv := buildConv(
+		ddl.CreateTable{
+			Name:     "t",
+			ColNames: []string{"a", "b"},
+			ColDefs: map[string]ddl.ColumnDef{
+				"a": ddl.ColumnDef{Name: "a", T: ddl.Type{Name: ddl.Int64}},
+				"b": ddl.ColumnDef{Name: "b", T: ddl.Type{Name: ddl.String, Len: ddl.MaxLength}},
+			}},
+		schema.Table{
+			Name:     "t",
+			ColNames: []string{"a", "b"},
+			ColDefs: map[string]schema.Column{
+				"a": schema.Column{Name: "a", Type: schema.Type{Name: "int8"}},
+				"b": schema.Column{Name: "b", Type: schema.Type{Name: "text"}},
+			},
+			PrimaryKeys: []schema.Key{schema.Key{Column: "a"}}})
+	conv.SetFetchSize(internal.FetchSize{Rows: 2})
+	conv.SetDataMode()
+	var rows []spannerData
+	conv.SetDataSink(
+		func(table string, cols []string, vals []interface{}) {
+			rows = append(rows, spannerData{table: table, cols: cols, vals: vals})
+		})
+	ProcessSQLData(conv, db)
+	assert.Equal(t,
+		[]spannerData{
+			spannerData{table: "t", cols: []string{"a", "b"}, vals: []interface{}{int64(1), "x"}},
+			spannerData{table: "t", cols: []string{"a", "b"}, vals: []interface{}{int64(2), "y"}},
+			spannerData{table: "t", cols: []string{"a", "b"}, vals: []interface{}{int64(3), "z"}},
+		},
+		rows)
+	assert.Equal(t, int64(0), conv.Unexpecteds())
+}
+
+func TestProcessSqlDataEmptyTable(t *testing.T) {
+	// The rows of the table aren't read: there is no SELECT * query.
+	ms := []mockSpec{
+		{
+			query: "SELECT table_schema, table_name FROM information_schema.tables where table_type = 'BASE TABLE'",
+			cols:  []string{"table_schema", "table_name"},
+			rows:  [][]driver.Value{{"public", "t"}},
+		},
+	}
+	db := mkMockDB(t, ms)
+	conv := buildConv(
+		ddl.CreateTable{
+			Name:     "t",
+			ColNames: []string{"a"},
+			ColDefs:  map[string]ddl.ColumnDef{"a": ddl.ColumnDef{Name: "a", T: ddl.Type{Name: ddl.Int64}}},
+		},
+		schema.Table{
+			Name:     "t",
+			ColNames: []string{"a"},
+			ColDefs:  map[string]schema.Column{"a": schema.Column{Name: "a", Type: schema.Type{Name: "int8"}}},
+		})
+	conv.StatsAddRows("t", 0)
+	conv.SetDataMode()
+	ProcessSQLData(conv, db)
+	assert.Equal(t, int64(0), conv.Unexpecteds())
+}
+
+func TestRowFilter(t *testing.T) {
+	conv := internal.MakeConv()
+	conv.SrcSchema["t"] = schema.Table{Name: "t", ColNames: []string{"a", "v", "deleted"}, ColDefs: map[string]schema.Column{
+		"a":       schema.Column{Name: "a"},
+		"v":       schema.Column{Name: "v"},
+		"deleted": schema.Column{Name: "deleted"},
+	}}
+	where, args := rowFilter(conv, "t", false)
+	assert.Equal(t, "", where)
+	assert.Nil(t, args)
+	conv.SetSoftDelete(internal.SoftDelete{Column: "deleted", Value: "true"})
+	assert.Nil(t, conv.SetIncremental(map[string]string{"t": "v"}, map[string]string{"t": "5"}))
+	where, args = rowFilter(conv, "t", false)
+	assert.Equal(t, ` WHERE "v" >= $1 AND ("deleted"::text = $2) IS NOT TRUE`, where)
+	assert.Equal(t, []interface{}{"5", "true"}, args)
+	conv.SetSoftDelete(internal.SoftDelete{Column: "deleted"})
+	where, args = rowFilter(conv, "t", true)
+	assert.Equal(t, ` WHERE "v" >= $1 AND ("deleted" IS NOT NULL) IS TRUE`, where)
+	assert.Equal(t, []interface{}{"5"}, args)
+
+	conv = internal.MakeConv()
+	conv.SrcSchema["s.tenants"] = schema.Table{Name: "s.tenants", ColDefs: map[string]schema.Column{"id": schema.Column{Name: "id"}, "tid": schema.Column{Name: "tid"}}}
+	conv.SrcSchema["t"] = schema.Table{Name: "t", ColDefs: map[string]schema.Column{"a": schema.Column{Name: "a"}}, ForeignKeys: []schema.ForeignKey{
+		{Name: "fk", Columns: []string{"a"}, ReferTable: "s.tenants", ReferColumns: []string{"id"}},
+	}}
+	assert.Nil(t, conv.SetTenant(internal.Tenant{Column: "tid", Value: "42"}))
+	where, args = rowFilter(conv, "t", false)
+	assert.Equal(t, ` WHERE EXISTS (SELECT 1 FROM "s"."tenants" t1 WHERE t1."id" = "public"."t"."a" AND t1."tid"::text = $1)`, where)
+	assert.Equal(t, []interface{}{"42"}, args)
+	where, _ = rowFilter(conv, "s.tenants", false)
+	assert.Equal(t, ` WHERE "s"."tenants"."tid"::text = $1`, where)
+}
+
+func TestSelectList(t *testing.T) {
+	conv := internal.MakeConv()
+	conv.SrcSchema["t"] = schema.Table{Name: "t", ColNames: []string{"a", "b"}, ColDefs: map[string]schema.Column{
+		"a": schema.Column{Name: "a", Type: schema.Type{Name: "int8"}},
+		"b": schema.Column{Name: "b", Type: schema.Type{Name: "oid"}},
+	}}
+	assert.Equal(t, "*", selectList(conv, "t", `"public"."t"`))
+	conv.SrcSchema["t"].ColDefs["b"] = schema.Column{Name: "b", Type: schema.Type{Name: "oid"}, LargeObject: true}
+	assert.Equal(t, `"a", CASE WHEN EXISTS (SELECT 1 FROM pg_largeobject_metadata AS m WHERE m.oid = "public"."t"."b") THEN lo_get("public"."t"."b") END AS "b"`, selectList(conv, "t", `"public"."t"`))
+}
+
 func TestConvertSqlRow_SingleCol(t *testing.T) {
 	tDate, _ := time.Parse("2006-01-02", "2019-10-29")
 	tc := []struct {
@@ -361,6 +614,8 @@ func TestConvertSqlRow_SingleCol(t *testing.T) {
 		{name: "float64 int", srcType: schema.Type{Name: "bigint"}, spType: ddl.Type{Name: ddl.Float64}, in: int64(42), e: float64(42)},
 		{name: "float64 byte", srcType: schema.Type{Name: "numeric"}, spType: ddl.Type{Name: ddl.Float64}, in: []byte("42.6"), e: float64(42.6)},
 		{name: "numeric", srcType: schema.Type{Name: "numeric"}, spType: ddl.Type{Name: ddl.Numeric}, in: []byte("999.99999"), e: "999.999990000"},
+		{name: "numeric float8", srcType: schema.Type{Name: "float8"}, spType: ddl.Type{Name: ddl.Numeric}, in: float64(19.99), e: "19.990000000"},
+		{name: "numeric float4", srcType: schema.Type{Name: "float4"}, spType: ddl.Type{Name: ddl.Numeric}, in: float64(float32(0.1)), e: "0.100000000"},
 		{name: "string", srcType: schema.Type{Name: "text"}, spType: ddl.Type{Name: ddl.String, Len: ddl.MaxLength}, in: "eh", e: "eh"},
 		{name: "string bool", srcType: schema.Type{Name: "bool"}, spType: ddl.Type{Name: ddl.String, Len: ddl.MaxLength}, in: true, e: "true"},
 		{name: "string byte", srcType: schema.Type{Name: "bytea"}, spType: ddl.Type{Name: ddl.String, Len: ddl.MaxLength}, in: []byte("abc"), e: "abc"},
@@ -423,11 +678,11 @@ func TestConvertSqlRow_MultiCol(t *testing.T) {
 		}, {
 			query: "SELECT (.+) FROM information_schema.COLUMNS (.+)",
 			args:  []driver.Value{"public", "test"},
-			cols:  []string{"column_name", "data_type", "data_type", "is_nullable", "column_default", "character_maximum_length", "numeric_precision", "numeric_scale"},
+			cols:  []string{"column_name", "data_type", "data_type", "is_nullable", "column_default", "character_maximum_length", "numeric_precision", "numeric_scale", "collation_name"},
 			rows: [][]driver.Value{
-				{"a", "text", nil, "NO", nil, nil, nil, nil},
-				{"b", "double precision", nil, "YES", nil, nil, 53, nil},
-				{"c", "bigint", nil, "YES", nil, nil, 64, 0}},
+				{"a", "text", nil, "NO", nil, nil, nil, nil, nil},
+				{"b", "double precision", nil, "YES", nil, nil, 53, nil, nil},
+				{"c", "bigint", nil, "YES", nil, nil, 64, 0, nil}},
 		},
 		{
 			query: "SELECT (.+) FROM INFORMATION_SCHEMA.TABLE_CONSTRAINTS (.+)",
@@ -443,7 +698,13 @@ func TestConvertSqlRow_MultiCol(t *testing.T) {
 		{
 			query: "SELECT (.+) FROM pg_index (.+)",
 			args:  []driver.Value{"public", "test"},
-			cols:  []string{"index_name", "column_name", "column_position", "is_unique", "order"},
+			cols:  []string{"index_name", "column_name", "column_position", "is_unique", "order", "is_constraint", "scans"},
+		}, {
+			query: "SELECT (.+) FROM pg_sequences",
+			cols:  []string{"schemaname", "sequencename", "increment_by", "start_value", "last_value"},
+		}, {
+			query: "SELECT (.+) FROM pg_trigger (.+)",
+			cols:  []string{"nspname", "relname", "tgname", "tgtype", "prosrc"},
 		},
 		// Note: go-sqlmock mocks specify an ordered sequence
 		// of queries and results.  This (repeated) entry is
@@ -512,10 +773,14 @@ func mkMockDB(t *testing.T, ms []mockSpec) *sql.DB {
 		for _, r := range m.rows {
 			rows.AddRow(r...)
 		}
+		q := mock.ExpectQuery(m.query)
 		if len(m.args) > 0 {
-			mock.ExpectQuery(m.query).WithArgs(m.args...).WillReturnRows(rows)
+			q = q.WithArgs(m.args...)
+		}
+		if m.err != nil {
+			q.WillReturnError(m.err)
 		} else {
-			mock.ExpectQuery(m.query).WillReturnRows(rows)
+			q.WillReturnRows(rows)
 		}
 
 	}
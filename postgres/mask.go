@@ -0,0 +1,147 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package postgres
+
+import (
+	"fmt"
+	"io"
+	"regexp"
+	"strings"
+
+	"github.com/cloudspannerecosystem/harbourbridge/internal"
+)
+
+var insertPrefixRegexp = regexp.MustCompile(`(?is)\bINSERT\s+INTO\s.*?\bVALUES\s*`)
+
+// MaskPgDump reads pg_dump data from r, and writes it to w with the
+// values of masked columns replaced as specified by conv.Policies.Masks
+// (see internal.MaskRule). conv must hold the schema of the dump, and be
+// in data mode. Everything else is written as is. INSERT statements for
+// tables with masked columns are rewritten with one row per line, and
+// without ON CONFLICT clauses. Binary COPY-FROM data can't be masked.
+func MaskPgDump(conv *internal.Conv, r *internal.Reader, w io.Writer) error {
+	for {
+		b, stmts, err := readAndParseChunk(conv, r)
+		if err != nil {
+			return err
+		}
+		ci := processStatements(conv, stmts)
+		switch {
+		case ci != nil && ci.stmt == copyFrom:
+			if _, err := w.Write(b); err != nil {
+				return err
+			}
+			if ci.binary {
+				return fmt.Errorf("can't mask binary COPY-FROM data for table %s", ci.table)
+			}
+			if err := maskCopyBlock(conv, ci.table, ci.cols, r, w); err != nil {
+				return err
+			}
+		case ci != nil && ci.stmt == insert && conv.HasMasks(ci.table):
+			if err := maskInsert(conv, ci, string(b), w); err != nil {
+				return err
+			}
+		default:
+			if _, err := w.Write(b); err != nil {
+				return err
+			}
+		}
+		if r.EOF {
+			break
+		}
+	}
+	return nil
+}
+
+// maskCopyBlock copies the data of a COPY-FROM block from r to w,
+// masking the values of masked columns.
+func maskCopyBlock(conv *internal.Conv, srcTable string, srcCols []string, r *internal.Reader, w io.Writer) error {
+	masked := conv.HasMasks(srcTable)
+	for {
+		b := r.ReadLine()
+		s := string(b)
+		if masked && s != "\\.\n" && s != "\\.\r\n" && len(s) > 0 {
+			eol := s[len(strings.TrimRight(s, "\r\n")):]
+			vals := strings.Split(strings.TrimRight(s, "\r\n"), "\t")
+			for i := range vals {
+				if i >= len(srcCols) || vals[i] == "\\N" {
+					continue
+				}
+				v, ok := conv.Mask(srcTable, srcCols[i], copyUnescape(vals[i]))
+				if ok {
+					vals[i] = copyEscape(v)
+				} else {
+					vals[i] = "\\N"
+				}
+			}
+			s = strings.Join(vals, "\t") + eol
+		}
+		if _, err := io.WriteString(w, s); err != nil {
+			return err
+		}
+		if string(b) == "\\.\n" || string(b) == "\\.\r\n" || r.EOF {
+			return nil
+		}
+	}
+}
+
+// maskInsert writes the INSERT statement chunk, with the values of masked
+// columns replaced, to w. Values are written as string literals, which
+// PostgreSQL converts to the type of their column.
+func maskInsert(conv *internal.Conv, ci *copyOrInsert, chunk string, w io.Writer) error {
+	loc := insertPrefixRegexp.FindStringIndex(chunk)
+	if loc == nil {
+		return fmt.Errorf("can't mask INSERT statement for table %s", ci.table)
+	}
+	cols := ci.cols
+	if len(cols) == 0 {
+		cols = conv.SrcSchema[ci.table].ColNames
+	}
+	// Keep any comments before the statement.
+	if _, err := io.WriteString(w, chunk[:loc[0]]); err != nil {
+		return err
+	}
+	for _, row := range ci.rows {
+		var l []string
+		for i, v := range row {
+			if v != "\\N" && i < len(cols) {
+				if m, ok := conv.Mask(ci.table, cols[i], v); ok {
+					v = m
+				} else {
+					v = "\\N"
+				}
+			}
+			l = append(l, pgLiteral(v))
+		}
+		if _, err := fmt.Fprintf(w, "%s(%s);\n", chunk[loc[0]:loc[1]], strings.Join(l, ", ")); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// pgLiteral returns v (as returned by getVal) as an SQL literal.
+func pgLiteral(v string) string {
+	if v == "\\N" {
+		return "NULL"
+	}
+	return "'" + strings.ReplaceAll(v, "'", "''") + "'"
+}
+
+// copyEscape escapes s for COPY-FROM text format (the reverse of
+// copyUnescape).
+func copyEscape(s string) string {
+	return strings.NewReplacer(`\`, `\\`, "\t", `\t`, "\n", `\n`, "\r", `\r`).Replace(s)
+}
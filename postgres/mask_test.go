@@ -0,0 +1,59 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package postgres
+
+import (
+	"bufio"
+	"strings"
+	"testing"
+
+	"github.com/cloudspannerecosystem/harbourbridge/internal"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMaskPgDump(t *testing.T) {
+	dump := "CREATE TABLE public.users (\n" +
+		"    id bigint NOT NULL,\n" +
+		"    email character varying(16),\n" +
+		"    name text\n" +
+		");\n" +
+		"COPY public.users (id, email, name) FROM stdin;\n" +
+		"1\tada@example.com\tAda\\tL\n" +
+		"2\t\\N\tBob\n" +
+		"\\.\n" +
+		"-- Data for other tables is copied as is.\n" +
+		"INSERT INTO public.users VALUES (3, 'c''d@x.org', 'Cy');\n"
+	conv, _ := runProcessPgDump(dump)
+	conv.Policies.Masks = map[string]internal.MaskRule{
+		"users.email": {Method: internal.MaskHash, Value: "s"},
+		"users.name":  {Method: internal.MaskRedact},
+	}
+	assert.Nil(t, conv.CheckMasks())
+	conv.SetDataMode()
+	var b strings.Builder
+	assert.Nil(t, MaskPgDump(conv, internal.NewReader(bufio.NewReader(strings.NewReader(dump)), nil), &b))
+	want := "CREATE TABLE public.users (\n" +
+		"    id bigint NOT NULL,\n" +
+		"    email character varying(16),\n" +
+		"    name text\n" +
+		");\n" +
+		"COPY public.users (id, email, name) FROM stdin;\n" +
+		"1\td94188c85e931cfd\txxx\\tx\n" +
+		"2\t\\N\txxx\n" +
+		"\\.\n" +
+		"-- Data for other tables is copied as is.\n" +
+		"INSERT INTO public.users VALUES ('3', 'e40d132213327aae', 'xx');\n"
+	assert.Equal(t, want, b.String())
+}
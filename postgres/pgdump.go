@@ -17,6 +17,7 @@ package postgres
 import (
 	"fmt"
 	"reflect"
+	"regexp"
 	"strconv"
 	"strings"
 	"time"
@@ -29,10 +30,11 @@ import (
 )
 
 type copyOrInsert struct {
-	stmt  stmtType
-	table string
-	cols  []string
-	vals  []string // Empty for COPY-FROM.
+	stmt   stmtType
+	table  string
+	cols   []string
+	rows   [][]string // Rows of INSERT's VALUES (empty for COPY-FROM).
+	binary bool       // COPY-FROM data is in binary format.
 }
 
 type stmtType int
@@ -42,6 +44,8 @@ const (
 	insert
 )
 
+var executeFunctionRegexp = regexp.MustCompile(`(?is)(\bCREATE\s+(?:OR\s+REPLACE\s+)?(?:CONSTRAINT\s+)?TRIGGER\b.*?)\bEXECUTE\s+FUNCTION\b`)
+
 // ProcessPgDump reads pg_dump data from r and does schema or data conversion,
 // depending on whether conv is configured for schema mode or data mode.
 // In schema mode, ProcessPgDump incrementally builds a schema (updating conv).
@@ -60,24 +64,39 @@ func ProcessPgDump(conv *internal.Conv, r *internal.Reader) error {
 		if ci != nil {
 			switch ci.stmt {
 			case copyFrom:
-				processCopyBlock(conv, ci.table, ci.cols, r)
+				if ci.binary {
+					if err := processBinaryCopyBlock(conv, ci.table, ci.cols, r); err != nil {
+						return err
+					}
+				} else {
+					processCopyBlock(conv, ci.table, ci.cols, r)
+				}
 			case insert:
 				// Handle INSERT statements where columns are not
 				// specified i.e. an insert for all table columns.
-				if len(ci.cols) == 0 {
-					ProcessDataRow(conv, ci.table, conv.SrcSchema[ci.table].ColNames, ci.vals)
-				} else {
-					ProcessDataRow(conv, ci.table, ci.cols, ci.vals)
+				cols := ci.cols
+				if len(cols) == 0 {
+					cols = conv.SrcSchema[ci.table].ColNames
+				}
+				if !conv.SkipData(ci.table) {
+					for _, vals := range ci.rows {
+						ProcessDataRow(conv, ci.table, cols, vals)
+					}
 				}
 			}
 		}
-		if r.EOF {
+		// The rest of the dump is skipped once data conversion is
+		// aborted.
+		if r.EOF || conv.Aborted() != nil {
 			break
 		}
 	}
 	if conv.SchemaMode() {
-		schemaToDDL(conv)
+		if err := schemaToDDL(conv); err != nil {
+			return err
+		}
 		conv.AddPrimaryKeys()
+		conv.AddSequences()
 	}
 
 	return nil
@@ -106,6 +125,15 @@ func readAndParseChunk(conv *internal.Conv, r *internal.Reader) ([]byte, []nodes
 			if err == nil {
 				return s, tree.Statements, nil
 			}
+			// pg_dump for PostgreSQL 11+ writes 'EXECUTE FUNCTION' in
+			// CREATE TRIGGER statements, which our parser only knows
+			// under its older name 'EXECUTE PROCEDURE'.
+			if executeFunctionRegexp.Match(s) {
+				tree, err = pg_query.Parse(executeFunctionRegexp.ReplaceAllString(string(s), "${1}EXECUTE PROCEDURE"))
+				if err == nil {
+					return s, tree.Statements, nil
+				}
+			}
 			// Likely causes of failing to parse:
 			// a) complex statements with embedded semicolons e.g. 'CREATE FUNCTION'
 			// b) a semicolon embedded in a multi-line comment, or
@@ -135,19 +163,99 @@ func processCopyBlock(conv *internal.Conv, srcTable string, srcCols []string, r
 		// We have to read the copy-block data so that we can process the remaining
 		// pg_dump content. However, if we don't want the data, stop here.
 		// In particular, avoid the strings.Split and ProcessDataRow calls below, which
-		// will be expensive for huge datasets.
-		if !conv.DataMode() {
+		// will be expensive for huge datasets. Rows of tables whose data
+		// isn't converted (see internal.Conv.SetDataTables) are skipped.
+		if !conv.DataMode() || conv.SkipData(srcTable) {
 			continue
 		}
-		// pg_dump escapes backslash in copy-block statements. For example:
-		// a) a\"b becomes a\\"b in COPY-BLOCK (but 'a\"b' in INSERT-INTO)
-		// b) {"a\"b"} becomes {"a\\"b"} in COPY-BLOCK (but '{"a\"b"}' in INSERT-INTO)
-		// Note: a'b and {a'b} are unchanged in COPY-BLOCK and INSERT-INTO.
-		s := strings.ReplaceAll(string(b), `\\`, `\`)
 		// COPY-FROM blocks use tabs to separate data items. Note that space within data
 		// items is significant e.g. if a table row contains data items "a ", " b "
 		// it will be shown in the COPY-FROM block as "a \t b ".
-		ProcessDataRow(conv, srcTable, srcCols, strings.Split(strings.Trim(s, "\r\n"), "\t"))
+		vals := strings.Split(strings.Trim(string(b), "\r\n"), "\t")
+		for i := range vals {
+			// pg_dump escapes backslash (and control characters) in copy-block
+			// statements. For example:
+			// a) a\"b becomes a\\"b in COPY-BLOCK (but 'a\"b' in INSERT-INTO)
+			// b) {"a\"b"} becomes {"a\\"b"} in COPY-BLOCK (but '{"a\"b"}' in INSERT-INTO)
+			// Note: a'b and {a'b} are unchanged in COPY-BLOCK and INSERT-INTO.
+			// We must unescape each data item separately, and leave the NULL
+			// marker \N as-is.
+			if vals[i] != "\\N" {
+				vals[i] = copyUnescape(vals[i])
+			}
+		}
+		ProcessDataRow(conv, srcTable, srcCols, vals)
+	}
+}
+
+// copyUnescape decodes the backslash sequences used by COPY-FROM text
+// format: \b, \f, \n, \r, \t and \v, octal (\ooo) and hex (\xhh)
+// byte values, and backslash followed by any other character, which
+// represents that character. See www.postgresql.org/docs/current/sql-copy.html.
+// The caller must handle the NULL marker \N.
+func copyUnescape(s string) string {
+	if !strings.Contains(s, `\`) {
+		return s
+	}
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] != '\\' || i+1 == len(s) {
+			b.WriteByte(s[i])
+			continue
+		}
+		i++
+		switch c := s[i]; c {
+		case 'b':
+			b.WriteByte('\b')
+		case 'f':
+			b.WriteByte('\f')
+		case 'n':
+			b.WriteByte('\n')
+		case 'r':
+			b.WriteByte('\r')
+		case 't':
+			b.WriteByte('\t')
+		case 'v':
+			b.WriteByte('\v')
+		case 'x':
+			// One or two hex digits. If none, 'x' is just an escaped character.
+			v, n := 0, 0
+			for ; n < 2 && i+1 < len(s) && isHexDigit(s[i+1]); n++ {
+				i++
+				v = v*16 + hexDigitVal(s[i])
+			}
+			if n == 0 {
+				b.WriteByte(c)
+			} else {
+				b.WriteByte(byte(v))
+			}
+		case '0', '1', '2', '3', '4', '5', '6', '7':
+			// One to three octal digits.
+			v := int(c - '0')
+			for n := 1; n < 3 && i+1 < len(s) && s[i+1] >= '0' && s[i+1] <= '7'; n++ {
+				i++
+				v = v*8 + int(s[i]-'0')
+			}
+			b.WriteByte(byte(v))
+		default:
+			b.WriteByte(c)
+		}
+	}
+	return b.String()
+}
+
+func isHexDigit(c byte) bool {
+	return (c >= '0' && c <= '9') || (c >= 'a' && c <= 'f') || (c >= 'A' && c <= 'F')
+}
+
+func hexDigitVal(c byte) int {
+	switch {
+	case c >= 'a':
+		return int(c-'a') + 10
+	case c >= 'A':
+		return int(c-'A') + 10
+	default:
+		return int(c - '0')
 	}
 }
 
@@ -189,6 +297,33 @@ func processStatements(conv *internal.Conv, statements []nodes.Node) *copyOrInse
 			if conv.SchemaMode() {
 				processIndexStmt(conv, n)
 			}
+		case nodes.CreateSeqStmt:
+			if conv.SchemaMode() {
+				processCreateSeqStmt(conv, n)
+			}
+		case nodes.AlterSeqStmt:
+			if conv.SchemaMode() {
+				processAlterSeqStmt(conv, n)
+			}
+		case nodes.SelectStmt:
+			if conv.SchemaMode() {
+				processSelectStmt(conv, n)
+			}
+		case nodes.CreateEnumStmt:
+			if conv.SchemaMode() {
+				processCreateEnumStmt(conv, n)
+			}
+			conv.SkipStatement(prNodes([]nodes.Node{node}))
+		case nodes.CreateFunctionStmt:
+			if conv.SchemaMode() {
+				processCreateFunctionStmt(conv, n)
+			}
+			conv.SkipStatement(prNodes([]nodes.Node{node}))
+		case nodes.CreateTrigStmt:
+			if conv.SchemaMode() {
+				processCreateTrigStmt(conv, n)
+			}
+			conv.SkipStatement(prNodes([]nodes.Node{node}))
 		default:
 			conv.SkipStatement(prNodes([]nodes.Node{node}))
 		}
@@ -196,6 +331,265 @@ func processStatements(conv *internal.Conv, statements []nodes.Node) *copyOrInse
 	return nil
 }
 
+// processCreateSeqStmt records the sequence created by n, with its start
+// value and increment. pg_dump sets the current value of sequences
+// separately, with setval (see processSelectStmt).
+func processCreateSeqStmt(conv *internal.Conv, n nodes.CreateSeqStmt) {
+	if n.Sequence == nil {
+		logStmtError(conv, n, fmt.Errorf("sequence is nil"))
+		return
+	}
+	name, err := getTableName(conv, *n.Sequence)
+	if err != nil {
+		logStmtError(conv, n, fmt.Errorf("can't get sequence name: %w", err))
+		return
+	}
+	seq := schema.Sequence{Name: name, Increment: 1}
+	start := int64(0)
+	for _, o := range n.Options.Items {
+		d, ok := o.(nodes.DefElem)
+		if !ok || d.Defname == nil {
+			continue
+		}
+		switch *d.Defname {
+		case "increment":
+			seq.Increment, err = getInt64(d.Arg)
+		case "start":
+			start, err = getInt64(d.Arg)
+		}
+		if err != nil {
+			logStmtError(conv, n, fmt.Errorf("bad option %s: %w", *d.Defname, err))
+			return
+		}
+	}
+	seq.Next = start
+	if start == 0 {
+		// Ascending sequences start at 1 by default (descending ones
+		// start at -1).
+		seq.Next = 1
+		if seq.Increment < 0 {
+			seq.Next = -1
+		}
+	}
+	conv.SchemaStatement(prNodes([]nodes.Node{n}))
+	conv.SetSrcSequence(seq)
+}
+
+// processAlterSeqStmt handles ALTER SEQUENCE ... OWNED BY table.column,
+// which pg_dump uses to tie sequences to serial columns. Other changes to
+// sequences are ignored.
+func processAlterSeqStmt(conv *internal.Conv, n nodes.AlterSeqStmt) {
+	if n.Sequence == nil {
+		logStmtError(conv, n, fmt.Errorf("sequence is nil"))
+		return
+	}
+	name, err := getTableName(conv, *n.Sequence)
+	if err != nil {
+		logStmtError(conv, n, fmt.Errorf("can't get sequence name: %w", err))
+		return
+	}
+	seq, ok := conv.SrcSequences[name]
+	if !ok {
+		conv.SkipStatement(prNodes([]nodes.Node{n}))
+		return
+	}
+	for _, o := range n.Options.Items {
+		d, ok := o.(nodes.DefElem)
+		if !ok || d.Defname == nil || *d.Defname != "owned_by" {
+			continue
+		}
+		l, ok := d.Arg.(nodes.List)
+		if !ok || len(l.Items) < 2 {
+			continue // OWNED BY NONE.
+		}
+		var parts []string
+		for _, i := range l.Items {
+			s, err := getString(i)
+			if err != nil {
+				logStmtError(conv, n, err)
+				return
+			}
+			parts = append(parts, s)
+		}
+		if len(parts) > 2 && parts[0] == "public" {
+			parts = parts[1:]
+		}
+		seq.Table = strings.Join(parts[:len(parts)-1], ".")
+		seq.Column = parts[len(parts)-1]
+	}
+	conv.SchemaStatement(prNodes([]nodes.Node{n}))
+	conv.SetSrcSequence(seq)
+}
+
+// processSelectStmt handles 'SELECT pg_catalog.setval(sequence, value,
+// is_called)', which pg_dump uses to set the current value of sequences.
+// Other SELECT statements are skipped.
+func processSelectStmt(conv *internal.Conv, n nodes.SelectStmt) {
+	if len(n.TargetList.Items) != 1 {
+		conv.SkipStatement(prNodes([]nodes.Node{n}))
+		return
+	}
+	rt, ok := n.TargetList.Items[0].(nodes.ResTarget)
+	if !ok {
+		conv.SkipStatement(prNodes([]nodes.Node{n}))
+		return
+	}
+	fc, ok := rt.Val.(nodes.FuncCall)
+	if !ok || len(fc.Funcname.Items) == 0 {
+		conv.SkipStatement(prNodes([]nodes.Node{n}))
+		return
+	}
+	if f, err := getString(fc.Funcname.Items[len(fc.Funcname.Items)-1]); err != nil || f != "setval" {
+		conv.SkipStatement(prNodes([]nodes.Node{n}))
+		return
+	}
+	args, err := getVals(fc.Args.Items)
+	if err != nil || len(args) < 2 {
+		logStmtError(conv, n, fmt.Errorf("bad setval arguments"))
+		return
+	}
+	v, err := strconv.ParseInt(args[1], 10, 64)
+	if err != nil {
+		logStmtError(conv, n, fmt.Errorf("bad setval value: %w", err))
+		return
+	}
+	name := parseSeqName(args[0])
+	seq, ok := conv.SrcSequences[name]
+	if !ok {
+		seq = schema.Sequence{Name: name, Increment: 1}
+	}
+	// When is_called is true (the default), v is the last value returned
+	// by the sequence. Otherwise, it is the next value.
+	seq.Next = v
+	if len(args) < 3 || args[2] == "t" || args[2] == "true" {
+		seq.Next = v + seq.Increment
+	}
+	conv.SchemaStatement(prNodes([]nodes.Node{n}))
+	conv.SetSrcSequence(seq)
+}
+
+// parseSeqName converts the text form of a sequence name used in setval
+// calls (e.g. 'public."Users_id_seq"') to a name built like getTableName
+// builds table names: unquoted identifiers are lower-cased and the
+// "public" schema is dropped.
+func parseSeqName(s string) string {
+	var parts []string
+	for _, p := range strings.Split(s, ".") {
+		if len(p) >= 2 && strings.HasPrefix(p, `"`) && strings.HasSuffix(p, `"`) {
+			parts = append(parts, strings.ReplaceAll(p[1:len(p)-1], `""`, `"`))
+		} else {
+			parts = append(parts, strings.ToLower(p))
+		}
+	}
+	if len(parts) > 1 && parts[0] == "public" {
+		parts = parts[1:]
+	}
+	return strings.Join(parts, ".")
+}
+
+// processCreateEnumStmt records the values of enum types, so that binary
+// COPY data of enum columns can be decoded. Enum types are not converted:
+// enum columns become STRING columns.
+func processCreateEnumStmt(conv *internal.Conv, n nodes.CreateEnumStmt) {
+	name, err := getTypeID(n.TypeName.Items)
+	if err != nil {
+		logStmtError(conv, n, err)
+		return
+	}
+	var values []string
+	for _, v := range n.Vals.Items {
+		s, err := getString(v)
+		if err != nil {
+			logStmtError(conv, n, err)
+			return
+		}
+		values = append(values, s)
+	}
+	conv.SetSrcEnum(name, values)
+}
+
+// processCreateFunctionStmt records the body of functions, so that the
+// triggers that call them can be analyzed. Functions are not converted.
+func processCreateFunctionStmt(conv *internal.Conv, n nodes.CreateFunctionStmt) {
+	if len(n.Funcname.Items) == 0 {
+		return
+	}
+	name, err := getString(n.Funcname.Items[len(n.Funcname.Items)-1])
+	if err != nil {
+		return
+	}
+	for _, o := range n.Options.Items {
+		d, ok := o.(nodes.DefElem)
+		if !ok || d.Defname == nil || *d.Defname != "as" {
+			continue
+		}
+		// The body is the first item of 'AS'. For C functions, the
+		// second item is the link symbol.
+		l, ok := d.Arg.(nodes.List)
+		if !ok || len(l.Items) == 0 {
+			continue
+		}
+		if body, err := getString(l.Items[0]); err == nil {
+			conv.SetSrcFunction(name, body)
+		}
+	}
+}
+
+// Bits of CreateTrigStmt.Timing and CreateTrigStmt.Events (see
+// TRIGGER_TYPE_* in PostgreSQL's pg_trigger.h).
+const (
+	triggerTypeBefore   = 1 << 1
+	triggerTypeInsert   = 1 << 2
+	triggerTypeDelete   = 1 << 3
+	triggerTypeUpdate   = 1 << 4
+	triggerTypeTruncate = 1 << 5
+	triggerTypeInstead  = 1 << 6
+)
+
+// processCreateTrigStmt records the trigger created by n, along with the
+// body of the function it executes (when the function appears earlier in
+// the dump, as pg_dump arranges).
+func processCreateTrigStmt(conv *internal.Conv, n nodes.CreateTrigStmt) {
+	if n.Relation == nil || n.Trigname == nil {
+		logStmtError(conv, n, fmt.Errorf("trigger name or table is nil"))
+		return
+	}
+	table, err := getTableName(conv, *n.Relation)
+	if err != nil {
+		logStmtError(conv, n, fmt.Errorf("can't get table name: %w", err))
+		return
+	}
+	var body string
+	if len(n.Funcname.Items) > 0 {
+		if f, err := getString(n.Funcname.Items[len(n.Funcname.Items)-1]); err == nil {
+			body = conv.SrcFunctions[f]
+		}
+	}
+	conv.AddSrcTrigger(schema.Trigger{Name: *n.Trigname, Table: table, Timing: triggerTiming(n.Timing, n.Events), Body: body})
+}
+
+// triggerTiming describes when a trigger fires, e.g. "BEFORE INSERT OR
+// UPDATE".
+func triggerTiming(timing, events int16) string {
+	when := "AFTER"
+	switch {
+	case timing&triggerTypeBefore != 0:
+		when = "BEFORE"
+	case timing&triggerTypeInstead != 0:
+		when = "INSTEAD OF"
+	}
+	var l []string
+	for _, e := range []struct {
+		bit  int16
+		name string
+	}{{triggerTypeInsert, "INSERT"}, {triggerTypeUpdate, "UPDATE"}, {triggerTypeDelete, "DELETE"}, {triggerTypeTruncate, "TRUNCATE"}} {
+		if events&e.bit != 0 {
+			l = append(l, e.name)
+		}
+	}
+	return when + " " + strings.Join(l, " OR ")
+}
+
 func processIndexStmt(conv *internal.Conv, n nodes.IndexStmt) {
 	if n.Relation == nil {
 		logStmtError(conv, n, fmt.Errorf("cannot process index statement with nil relation."))
@@ -238,10 +632,58 @@ func processAlterTableStmt(conv *internal.Conv, n nodes.AlterTableStmt) {
 					c := constraint{ct: nodes.CONSTR_NOTNULL, cols: []string{*a.Name}}
 					updateSchema(conv, table, []constraint{c}, "ALTER TABLE")
 					conv.SchemaStatement(prNodes([]nodes.Node{n, a}))
+				case a.Subtype == nodes.AT_DropNotNull && a.Name != nil:
+					ct := conv.SrcSchema[table]
+					if cd, ok := ct.ColDefs[*a.Name]; ok {
+						cd.NotNull = false
+						ct.ColDefs[*a.Name] = cd
+					}
+					conv.SchemaStatement(prNodes([]nodes.Node{n, a}))
+				case a.Subtype == nodes.AT_ColumnDefault && a.Name != nil:
+					// pg_dump sets the defaults of serial columns (and of
+					// columns using sequences) with SET DEFAULT.
+					ct := conv.SrcSchema[table]
+					if cd, ok := ct.ColDefs[*a.Name]; ok {
+						cd.Ignored.Default = a.Def != nil // DROP DEFAULT has no Def.
+						ct.ColDefs[*a.Name] = cd
+					}
+					conv.SchemaStatement(prNodes([]nodes.Node{n, a}))
 				case a.Subtype == nodes.AT_AddConstraint && a.Def != nil:
 					switch d := a.Def.(type) {
 					case nodes.Constraint:
-						updateSchema(conv, table, extractConstraints(conv, n, table, []nodes.Node{d}), "ALTER TABLE")
+						// Constraints added with NOT VALID or DEFERRABLE are
+						// converted like other constraints: Spanner has
+						// neither option.
+						if d.Indexname != nil {
+							addConstraintUsingIndex(conv, n, table, d)
+						} else {
+							updateSchema(conv, table, extractConstraints(conv, n, table, []nodes.Node{d}), "ALTER TABLE")
+						}
+						conv.SchemaStatement(prNodes([]nodes.Node{n, a, d}))
+					default:
+						conv.SkipStatement(prNodes([]nodes.Node{n, a, d}))
+					}
+				case a.Subtype == nodes.AT_ValidateConstraint:
+					// Validating a NOT VALID constraint doesn't change it.
+					conv.SchemaStatement(prNodes([]nodes.Node{n, a}))
+				case a.Subtype == nodes.AT_DropConstraint && a.Name != nil:
+					dropConstraint(conv, table, *a.Name)
+					conv.SchemaStatement(prNodes([]nodes.Node{n, a}))
+				case a.Subtype == nodes.AT_AddColumn && a.Def != nil:
+					switch d := a.Def.(type) {
+					case nodes.ColumnDef:
+						name, col, cs, err := processColumn(conv, d, table)
+						if err != nil {
+							logStmtError(conv, n, err)
+							continue
+						}
+						ct := conv.SrcSchema[table]
+						if _, ok := ct.ColDefs[name]; !ok {
+							ct.ColNames = append(ct.ColNames, name)
+						}
+						ct.ColDefs[name] = col
+						conv.SrcSchema[table] = ct
+						updateSchema(conv, table, cs, "ALTER TABLE")
 						conv.SchemaStatement(prNodes([]nodes.Node{n, a, d}))
 					default:
 						conv.SkipStatement(prNodes([]nodes.Node{n, a, d}))
@@ -264,6 +706,62 @@ func processAlterTableStmt(conv *internal.Conv, n nodes.AlterTableStmt) {
 	}
 }
 
+// addConstraintUsingIndex processes an 'ADD CONSTRAINT ... USING INDEX'
+// clause, which turns an existing unique index of table into a primary key
+// or unique constraint.
+func addConstraintUsingIndex(conv *internal.Conv, n nodes.Node, table string, d nodes.Constraint) {
+	ct := conv.SrcSchema[table]
+	for i, index := range ct.Indexes {
+		if index.Name != *d.Indexname {
+			continue
+		}
+		switch d.Contype {
+		case nodes.CONSTR_PRIMARY:
+			checkEmpty(conv, ct.PrimaryKeys, "ALTER TABLE")
+			ct.PrimaryKeys = nil
+			var cols []string
+			for _, k := range index.Keys {
+				ct.PrimaryKeys = append(ct.PrimaryKeys, schema.Key{Column: k.Column})
+				cols = append(cols, k.Column)
+			}
+			updateCols(nodes.CONSTR_NOTNULL, cols, ct.ColDefs)
+			// The index becomes the primary key's index.
+			ct.Indexes = append(ct.Indexes[:i], ct.Indexes[i+1:]...)
+		default:
+			// PostgreSQL renames the index to the constraint's name.
+			if d.Conname != nil {
+				ct.Indexes[i].Name = *d.Conname
+			}
+			ct.Indexes[i].Unique = true
+			ct.Indexes[i].Constraint = true
+		}
+		conv.SrcSchema[table] = ct
+		return
+	}
+	conv.Unexpected(fmt.Sprintf("Processing %v statement: index %s of table %s not found", reflect.TypeOf(n), *d.Indexname, table))
+}
+
+// dropConstraint removes constraint name (a foreign key or a unique
+// constraint) from table.
+func dropConstraint(conv *internal.Conv, table, name string) {
+	ct := conv.SrcSchema[table]
+	var fks []schema.ForeignKey
+	for _, fk := range ct.ForeignKeys {
+		if fk.Name != name {
+			fks = append(fks, fk)
+		}
+	}
+	ct.ForeignKeys = fks
+	var indexes []schema.Index
+	for _, index := range ct.Indexes {
+		if !(index.Unique && index.Name == name) {
+			indexes = append(indexes, index)
+		}
+	}
+	ct.Indexes = indexes
+	conv.SrcSchema[table] = ct
+}
+
 func processCreateStmt(conv *internal.Conv, n nodes.CreateStmt) {
 	var colNames []string
 	colDef := make(map[string]schema.Column)
@@ -305,10 +803,10 @@ func processCreateStmt(conv *internal.Conv, n nodes.CreateStmt) {
 		}
 	}
 	conv.SchemaStatement(prNodes([]nodes.Node{n}))
-	conv.SrcSchema[table] = schema.Table{
+	conv.SetSrcTable(schema.Table{
 		Name:     table,
 		ColNames: colNames,
-		ColDefs:  colDef}
+		ColDefs:  colDef})
 	// Note: constraints contains all info about primary keys,
 	// not-null keys and foreign keys.
 	updateSchema(conv, table, constraints, "CREATE TABLE")
@@ -328,7 +826,15 @@ func processColumn(conv *internal.Conv, n nodes.ColumnDef, table string) (string
 		Name:        tid,
 		Mods:        mods,
 		ArrayBounds: getArrayBounds(conv, n.TypeName.ArrayBounds)}
-	return name, schema.Column{Name: name, Type: ty}, analyzeColDefConstraints(conv, n, table, n.Constraints.Items, name), nil
+	column := schema.Column{Name: name, Type: ty}
+	// pg_dump only names collations that differ from the database default,
+	// which isn't in the dump.
+	if n.CollClause != nil {
+		if coll, err := getTypeID(n.CollClause.Collname.Items); err == nil && coll != "default" {
+			column.Collation = coll
+		}
+	}
+	return name, column, analyzeColDefConstraints(conv, n, table, n.Constraints.Items, name), nil
 }
 
 func processInsertStmt(conv *internal.Conv, n nodes.InsertStmt) *copyOrInsert {
@@ -349,23 +855,42 @@ func processInsertStmt(conv *internal.Conv, n nodes.InsertStmt) *copyOrInsert {
 		internal.VerbosePrintf("Processing %v statement: table %s not found", reflect.TypeOf(n), table)
 		return nil
 	}
-	conv.StatsAddRow(table, conv.SchemaMode())
+	sel, ok := n.SelectStmt.(nodes.SelectStmt)
+	if !ok {
+		conv.Unexpected(fmt.Sprintf("Found %s node while processing InsertStmt SelectStmt", PrNodeType(n.SelectStmt)))
+		return nil
+	}
+	if len(sel.ValuesLists) == 0 {
+		// e.g. INSERT INTO ... SELECT.
+		logStmtError(conv, n, fmt.Errorf("only INSERT statements with VALUES are supported"))
+		return nil
+	}
 	colNames, err := getCols(conv, table, n.Cols.Items)
 	if err != nil {
 		logStmtError(conv, n, fmt.Errorf("can't get col name: %w", err))
-		conv.StatsAddBadRow(table, conv.SchemaMode())
+		for range sel.ValuesLists {
+			conv.StatsAddRow(table, conv.SchemaMode())
+			conv.StatsAddBadRow(table, conv.SchemaMode())
+		}
 		return nil
 	}
-	var values []string
-	switch sel := n.SelectStmt.(type) {
-	case nodes.SelectStmt:
-		values = getVals(conv, sel.ValuesLists, n)
-		conv.DataStatement(prNodes([]nodes.Node{n}))
-		if conv.DataMode() {
-			return &copyOrInsert{stmt: insert, table: table, cols: colNames, vals: values}
+	// pg_dump --inserts writes one INSERT per row, but --rows-per-insert
+	// writes several rows per INSERT. ON CONFLICT clauses (written by
+	// --on-conflict-do-nothing) are ignored.
+	var rows [][]string
+	for _, vl := range sel.ValuesLists {
+		conv.StatsAddRow(table, conv.SchemaMode())
+		vals, err := getVals(vl)
+		if err != nil {
+			conv.Unexpected(fmt.Sprintf("Processing %v statement: %s", reflect.TypeOf(n), err))
+			conv.StatsAddBadRow(table, conv.SchemaMode())
+			continue
 		}
-	default:
-		conv.Unexpected(fmt.Sprintf("Found %s node while processing InsertStmt SelectStmt", PrNodeType(sel)))
+		rows = append(rows, vals)
+	}
+	conv.DataStatement(prNodes([]nodes.Node{n}))
+	if conv.DataMode() {
+		return &copyOrInsert{stmt: insert, table: table, cols: colNames, rows: rows}
 	}
 	return nil
 }
@@ -391,7 +916,7 @@ func processCopyStmt(conv *internal.Conv, n nodes.CopyStmt) *copyOrInsert {
 		// for a table is that it is an inherited table - we skip all inherited tables.
 		conv.SkipStatement(prNodes([]nodes.Node{n}))
 		internal.VerbosePrintf("Processing %v statement: table %s not found", reflect.TypeOf(n), table)
-		return &copyOrInsert{stmt: copyFrom, table: table, cols: []string{}}
+		return &copyOrInsert{stmt: copyFrom, table: table, cols: []string{}, binary: isBinaryCopy(n)}
 	}
 	var cols []string
 	for _, a := range n.Attlist.Items {
@@ -403,30 +928,51 @@ func processCopyStmt(conv *internal.Conv, n nodes.CopyStmt) *copyOrInsert {
 		cols = append(cols, s)
 	}
 	conv.DataStatement(prNodes([]nodes.Node{n}))
-	return &copyOrInsert{stmt: copyFrom, table: table, cols: cols}
+	return &copyOrInsert{stmt: copyFrom, table: table, cols: cols, binary: isBinaryCopy(n)}
 }
 
 func processVariableSetStmt(conv *internal.Conv, n nodes.VariableSetStmt) {
-	if n.Name != nil && *n.Name == "timezone" {
-		if len(n.Args.Items) == 1 {
-			switch c := n.Args.Items[0].(type) {
-			case nodes.A_Const:
-				tz, err := getString(c.Val)
-				if err != nil {
-					logStmtError(conv, n, fmt.Errorf("can't get Arg: %w", err))
-					return
-				}
-				loc, err := time.LoadLocation(tz)
-				if err != nil {
-					logStmtError(conv, n, err)
-					return
-				}
-				conv.SetLocation(loc)
-			default:
-				logStmtError(conv, n, fmt.Errorf("found %s node in Arg", reflect.TypeOf(c)))
-				return
+	if n.Name == nil || *n.Name != "timezone" {
+		// Other settings emitted by pg_dump (e.g. client_encoding or
+		// statement_timeout) don't affect conversion.
+		return
+	}
+	if n.Kind != nodes.VAR_SET_VALUE {
+		// SET TIME ZONE DEFAULT/LOCAL and RESET TIME ZONE restore the
+		// server's time zone, which we don't know: keep the current one.
+		internal.VerbosePrintf("Processing %v statement: ignoring time zone reset\n", reflect.TypeOf(n))
+		return
+	}
+	if len(n.Args.Items) != 1 {
+		return
+	}
+	switch c := n.Args.Items[0].(type) {
+	case nodes.A_Const:
+		var loc *time.Location
+		var err error
+		switch v := c.Val.(type) {
+		case nodes.Integer:
+			// A numeric time zone is an offset in hours east of UTC.
+			loc = time.FixedZone("", int(v.Ival)*3600)
+		case nodes.Float:
+			var h float64
+			h, err = strconv.ParseFloat(v.Str, 64)
+			loc = time.FixedZone("", int(h*3600))
+		default:
+			var tz string
+			tz, err = getString(c.Val)
+			if err == nil {
+				loc, err = time.LoadLocation(tz)
 			}
 		}
+		if err != nil {
+			logStmtError(conv, n, fmt.Errorf("can't get time zone: %w", err))
+			return
+		}
+		conv.SetLocation(loc)
+	default:
+		logStmtError(conv, n, fmt.Errorf("found %s node in Arg", reflect.TypeOf(c)))
+		return
 	}
 }
 
@@ -622,7 +1168,7 @@ func updateSchema(conv *internal.Conv, table string, cs []constraint, stmtType s
 			// appear in toddl.go. This file should focus on generic transformation from source
 			// database schemas into schema.go.
 			ct := conv.SrcSchema[table]
-			ct.Indexes = append(ct.Indexes, schema.Index{Name: c.name, Unique: true, Keys: toSchemaKeys(conv, table, c.cols)})
+			ct.Indexes = append(ct.Indexes, schema.Index{Name: c.name, Unique: true, Keys: toSchemaKeys(conv, table, c.cols), Constraint: true})
 			conv.SrcSchema[table] = ct
 		default:
 			ct := conv.SrcSchema[table]
@@ -643,6 +1189,8 @@ func updateCols(ct nodes.ConstrType, colNames []string, colDef map[string]schema
 			cd.NotNull = true
 		case nodes.CONSTR_DEFAULT:
 			cd.Ignored.Default = true
+		case nodes.CONSTR_CHECK:
+			cd.Ignored.Check = true
 		}
 		colDef[c] = cd
 	}
@@ -698,30 +1246,50 @@ func getCols(conv *internal.Conv, table string, l []nodes.Node) (cols []string,
 	return cols, nil
 }
 
-// getVals extracts and returns the values for an InsertStatement.
-func getVals(conv *internal.Conv, l [][]nodes.Node, n nodes.InsertStmt) (values []string) {
-	for _, vl := range l {
-		for _, v := range vl {
-			switch c := v.(type) {
-			case nodes.A_Const:
-				switch st := c.Val.(type) {
-				case nodes.String:
-					values = append(values, st.Str)
-				case nodes.Integer:
-					// For uniformity, convert to string and handle everything in
-					// dataConversion(). If performance of insert statements becomes a
-					// high priority (it isn't right now), then consider preserving int64
-					// here to avoid the int64 -> string -> int64 conversions.
-					values = append(values, strconv.FormatInt(st.Ival, 10))
-				default:
-					conv.Unexpected(fmt.Sprintf("Processing %v statement: found %s node for A_Const Val", reflect.TypeOf(n), reflect.TypeOf(c.Val)))
-				}
-			default:
-				conv.Unexpected(fmt.Sprintf("Processing %v statement: found %s node in ValuesList", reflect.TypeOf(n), reflect.TypeOf(v)))
-			}
+// getVals extracts and returns the values of a row of an InsertStatement,
+// using the same representation as COPY-FROM blocks (in particular, NULL
+// is represented by \N).
+func getVals(l []nodes.Node) ([]string, error) {
+	var values []string
+	for _, v := range l {
+		s, err := getVal(v)
+		if err != nil {
+			return nil, err
+		}
+		values = append(values, s)
+	}
+	return values, nil
+}
+
+func getVal(v nodes.Node) (string, error) {
+	switch c := v.(type) {
+	case nodes.A_Const:
+		switch st := c.Val.(type) {
+		case nodes.String:
+			return st.Str, nil
+		case nodes.Integer:
+			// For uniformity, convert to string and handle everything in
+			// dataConversion(). If performance of insert statements becomes a
+			// high priority (it isn't right now), then consider preserving int64
+			// here to avoid the int64 -> string -> int64 conversions.
+			return strconv.FormatInt(st.Ival, 10), nil
+		case nodes.Float:
+			// Floats (and integers too large for int64) are kept as strings
+			// by the parser.
+			return st.Str, nil
+		case nodes.Null:
+			return "\\N", nil
+		default:
+			return "", fmt.Errorf("found %s node for A_Const Val", reflect.TypeOf(c.Val))
 		}
+	case nodes.TypeCast:
+		// e.g. true, which is parsed as 't'::boolean, or
+		// 'NaN'::double precision. Data conversion is based on the
+		// column's type, so we drop the cast.
+		return getVal(c.Arg)
+	default:
+		return "", fmt.Errorf("found %s node in ValuesList", reflect.TypeOf(v))
 	}
-	return values
 }
 
 func logStmtError(conv *internal.Conv, n nodes.Node, err error) {
@@ -729,6 +1297,20 @@ func logStmtError(conv *internal.Conv, n nodes.Node, err error) {
 	conv.ErrorInStatement(prNodes([]nodes.Node{n}))
 }
 
+// getInt64 returns the value of an Integer node, or of a Float node
+// holding an integer (the parser uses Float nodes for integers that don't
+// fit in an int).
+func getInt64(node nodes.Node) (int64, error) {
+	switch n := node.(type) {
+	case nodes.Integer:
+		return n.Ival, nil
+	case nodes.Float:
+		return strconv.ParseInt(n.Str, 10, 64)
+	default:
+		return 0, fmt.Errorf("node %v is not an Integer node", reflect.TypeOf(node))
+	}
+}
+
 func getString(node nodes.Node) (string, error) {
 	switch n := node.(type) {
 	case nodes.String:
@@ -17,6 +17,7 @@ package postgres
 import (
 	"bufio"
 	"fmt"
+	"math"
 	"math/bits"
 	"strings"
 	"testing"
@@ -25,6 +26,7 @@ import (
 	"cloud.google.com/go/spanner"
 
 	"github.com/cloudspannerecosystem/harbourbridge/internal"
+	"github.com/cloudspannerecosystem/harbourbridge/schema"
 	"github.com/cloudspannerecosystem/harbourbridge/spanner/ddl"
 	pg_query "github.com/lfittl/pg_query_go"
 	"github.com/stretchr/testify/assert"
@@ -404,6 +406,41 @@ func TestProcessPgDump(t *testing.T) {
 					},
 					Pks: []ddl.IndexKey{ddl.IndexKey{Col: "a"}}}},
 		},
+		{
+			name: "ALTER TABLE constraints",
+			input: "CREATE TABLE test (a text, b text NOT NULL, c text, d text);\n" +
+				"CREATE TABLE test2 (e text PRIMARY KEY);\n" +
+				"CREATE UNIQUE INDEX test_idx ON test (a);\n" +
+				"ALTER TABLE ONLY test ADD CONSTRAINT test_pkey PRIMARY KEY USING INDEX test_idx;\n" +
+				"ALTER TABLE ONLY test ADD CONSTRAINT test_b_c_key UNIQUE (b, c) DEFERRABLE INITIALLY DEFERRED;\n" +
+				"ALTER TABLE ONLY test ADD CONSTRAINT test_d_fkey FOREIGN KEY (d) REFERENCES test2(e) NOT VALID;\n" +
+				"ALTER TABLE test VALIDATE CONSTRAINT test_d_fkey;\n" +
+				"ALTER TABLE ONLY test ADD CONSTRAINT test_c_fkey FOREIGN KEY (c) REFERENCES test2(e);\n" +
+				"ALTER TABLE test DROP CONSTRAINT test_c_fkey;\n" +
+				"ALTER TABLE test ALTER COLUMN b DROP NOT NULL;\n" +
+				"ALTER TABLE test ADD COLUMN f text NOT NULL;\n",
+			expectedSchema: map[string]ddl.CreateTable{
+				"test": ddl.CreateTable{
+					Name:     "test",
+					ColNames: []string{"a", "b", "c", "d", "f"},
+					ColDefs: map[string]ddl.ColumnDef{
+						"a": ddl.ColumnDef{Name: "a", T: ddl.Type{Name: ddl.String, Len: ddl.MaxLength}, NotNull: true},
+						"b": ddl.ColumnDef{Name: "b", T: ddl.Type{Name: ddl.String, Len: ddl.MaxLength}},
+						"c": ddl.ColumnDef{Name: "c", T: ddl.Type{Name: ddl.String, Len: ddl.MaxLength}},
+						"d": ddl.ColumnDef{Name: "d", T: ddl.Type{Name: ddl.String, Len: ddl.MaxLength}},
+						"f": ddl.ColumnDef{Name: "f", T: ddl.Type{Name: ddl.String, Len: ddl.MaxLength}, NotNull: true},
+					},
+					Pks:     []ddl.IndexKey{ddl.IndexKey{Col: "a"}},
+					Fks:     []ddl.Foreignkey{ddl.Foreignkey{Name: "test_d_fkey", Columns: []string{"d"}, ReferTable: "test2", ReferColumns: []string{"e"}}},
+					Indexes: []ddl.CreateIndex{ddl.CreateIndex{Name: "test_b_c_key", Table: "test", Unique: true, Keys: []ddl.IndexKey{ddl.IndexKey{Col: "b"}, ddl.IndexKey{Col: "c"}}}}},
+				"test2": ddl.CreateTable{
+					Name:     "test2",
+					ColNames: []string{"e"},
+					ColDefs: map[string]ddl.ColumnDef{
+						"e": ddl.ColumnDef{Name: "e", T: ddl.Type{Name: ddl.String, Len: ddl.MaxLength}, NotNull: true},
+					},
+					Pks: []ddl.IndexKey{ddl.IndexKey{Col: "e"}}}},
+		},
 		{
 			name: "Multiple statements on one line",
 			input: "CREATE TABLE t1 (a text, b text); CREATE TABLE t2 (c text);" +
@@ -518,6 +555,15 @@ func TestProcessPgDump(t *testing.T) {
 			expectedData: []spannerData{
 				spannerData{table: "test", cols: []string{"a", "b", "n"}, vals: []interface{}{"a42", "b6", int64(2)}}},
 		},
+		{
+			name: "INSERT with multiple rows",
+			input: "CREATE TABLE test (a text NOT NULL, b bool, n bigint, f float8);\n" +
+				"ALTER TABLE ONLY test ADD CONSTRAINT test_pkey PRIMARY KEY (a);" +
+				"INSERT INTO public.test VALUES ('a1', true, NULL, 1.5), ('a2', false, -3, 'Infinity'::double precision) ON CONFLICT DO NOTHING;",
+			expectedData: []spannerData{
+				spannerData{table: "test", cols: []string{"a", "b", "f"}, vals: []interface{}{"a1", true, float64(1.5)}},
+				spannerData{table: "test", cols: []string{"a", "b", "n", "f"}, vals: []interface{}{"a2", false, int64(-3), math.Inf(1)}}},
+		},
 		{
 			name: "INSERT with no primary key",
 			input: "CREATE TABLE test (a text NOT NULL, b text NOT NULL, n bigint);\n" +
@@ -589,6 +635,17 @@ COPY test (id, a, b, c, d) FROM stdin;
 			expectedData: []spannerData{
 				spannerData{table: "test", cols: []string{"id", "a", "b", "c", "d"}, vals: []interface{}{int64(1), true, int64(42), "x", []byte{0x0, 0x1, 0xbe, 0xef}}}},
 		},
+		{
+			name: "Data conversion: COPY escapes, bytea escape format",
+			input: `
+CREATE TABLE test (id integer PRIMARY KEY, a text, b bytea);
+COPY test (id, a, b) FROM stdin;
+1	x\ty\nz\\	\\001\\276ab
+\.
+`,
+			expectedData: []spannerData{
+				spannerData{table: "test", cols: []string{"id", "a", "b"}, vals: []interface{}{int64(1), "x\ty\nz\\", []byte{0x1, 0xbe, 'a', 'b'}}}},
+		},
 		{
 			name: "Data conversion: date, float8, float4, int8",
 			input: `
@@ -640,6 +697,10 @@ COPY test (id, a, b, c, d, e) FROM stdin;
 		conv, _ := runProcessPgDump("set timezone='US/Eastern';")
 		loc, _ := time.LoadLocation("US/Eastern")
 		assert.Equal(t, conv.Location, loc, "Set timezone")
+		conv, _ = runProcessPgDump("SET TIME ZONE 'US/Eastern'; SET TIME ZONE LOCAL;")
+		assert.Equal(t, loc, conv.Location, "Set time zone local")
+		conv, _ = runProcessPgDump("SET TIME ZONE -7;")
+		assert.Equal(t, time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC).Unix(), time.Date(2019, 12, 31, 17, 0, 0, 0, conv.Location).Unix(), "Set numeric time zone")
 	}
 
 	// Finally test data conversion errors.
@@ -710,6 +771,49 @@ func TestProcessPgDump_GetDDL(t *testing.T) {
 	assert.Equal(t, normalizeSpace(expected), normalizeSpace(strings.Join(conv.SpSchema.GetDDL(c), " ")))
 }
 
+func TestProcessPgDump_Sequences(t *testing.T) {
+	s := "CREATE TABLE test (id integer NOT NULL);\n" +
+		"CREATE SEQUENCE public.test_id_seq\n    AS integer\n    START WITH 1\n    INCREMENT BY 1\n    NO MINVALUE\n    NO MAXVALUE\n    CACHE 1;\n" +
+		"ALTER SEQUENCE public.test_id_seq OWNED BY public.test.id;\n" +
+		"CREATE SEQUENCE \"Odd_seq\" START WITH 10 INCREMENT BY 5;\n" +
+		"CREATE SEQUENCE down_seq INCREMENT BY -1;\n" +
+		"SELECT pg_catalog.setval('public.test_id_seq', 42, true);\n" +
+		"SELECT pg_catalog.setval('public.\"Odd_seq\"', 20, false);\n"
+	conv := internal.MakeConv()
+	conv.Features = internal.DefaultFeatures()
+	conv.SetLocation(time.UTC)
+	conv.SetSchemaMode()
+	assert.Nil(t, ProcessPgDump(conv, internal.NewReader(bufio.NewReader(strings.NewReader(s)), nil)))
+	noIssues(conv, t, "Sequences")
+	assert.Equal(t, map[string]schema.Sequence{
+		"test_id_seq": {Name: "test_id_seq", Increment: 1, Next: 43, Table: "test", Column: "id"},
+		"Odd_seq":     {Name: "Odd_seq", Increment: 5, Next: 20},
+		"down_seq":    {Name: "down_seq", Increment: -1, Next: -1},
+	}, conv.SrcSequences)
+	assert.Equal(t, map[string]ddl.CreateSequence{
+		"test_id_seq": {Name: "test_id_seq", StartWithCounter: 43},
+		"Odd_seq":     {Name: "Odd_seq", StartWithCounter: 20},
+		"down_seq":    {Name: "down_seq", StartWithCounter: 1},
+	}, conv.Sequences)
+}
+
+func TestProcessPgDump_Triggers(t *testing.T) {
+	s := "CREATE TABLE test (id integer NOT NULL, updated_at timestamp with time zone);\n" +
+		"CREATE FUNCTION public.touch() RETURNS trigger\n    LANGUAGE plpgsql\n    AS $$\nBEGIN\n  NEW.updated_at := now();\n  RETURN NEW;\nEND;\n$$;\n" +
+		"CREATE TRIGGER test_touch BEFORE UPDATE ON public.test FOR EACH ROW EXECUTE FUNCTION public.touch();\n" +
+		"CREATE TRIGGER test_other AFTER INSERT OR DELETE ON public.test FOR EACH ROW EXECUTE PROCEDURE public.missing();\n"
+	conv := internal.MakeConv()
+	conv.SetSchemaMode()
+	assert.Nil(t, ProcessPgDump(conv, internal.NewReader(bufio.NewReader(strings.NewReader(s)), nil)))
+	assert.Zero(t, len(conv.Stats.Unexpected)) // Function bodies with semicolons are reparsed, so don't use noIssues.
+	assert.Equal(t, map[string][]schema.Trigger{
+		"test": {
+			{Name: "test_touch", Table: "test", Timing: "BEFORE UPDATE", Body: "\nBEGIN\n  NEW.updated_at := now();\n  RETURN NEW;\nEND;\n"},
+			{Name: "test_other", Table: "test", Timing: "AFTER INSERT OR DELETE"},
+		},
+	}, conv.SrcTriggers)
+}
+
 func TestProcessPgDump_Rows(t *testing.T) {
 	conv, _ := runProcessPgDump("CREATE TABLE cart (a text, n bigint);\n" +
 		"INSERT INTO cart (a, n) VALUES ('a42', 2);")
@@ -0,0 +1,89 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package postgres
+
+import (
+	"database/sql/driver"
+	"regexp"
+	"testing"
+
+	"github.com/cloudspannerecosystem/harbourbridge/internal"
+	"github.com/cloudspannerecosystem/harbourbridge/schema"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMaxStringLengths(t *testing.T) {
+	conv := internal.MakeConv()
+	conv.SrcSchema["users"] = schema.Table{
+		Name:     "users",
+		ColNames: []string{"id", "name", "bio", "n"},
+		ColDefs: map[string]schema.Column{
+			"id":   schema.Column{Name: "id", Type: schema.Type{Name: "int8"}},
+			"name": schema.Column{Name: "name", Type: schema.Type{Name: "text"}},
+			"bio":  schema.Column{Name: "bio", Type: schema.Type{Name: "text"}},
+			"n":    schema.Column{Name: "n", Type: schema.Type{Name: "int8"}},
+		},
+		PrimaryKeys: []schema.Key{schema.Key{Column: "id"}},
+	}
+	assert.Nil(t, schemaToDDL(conv))
+	ms := []mockSpec{
+		{
+			query: "SELECT table_schema, table_name FROM information_schema.tables where table_type = 'BASE TABLE'",
+			cols:  []string{"table_schema", "table_name"},
+			rows:  [][]driver.Value{{"public", "users"}},
+		}, {
+			query: regexp.QuoteMeta(`SELECT max(char_length("name"::text)), max(char_length("bio"::text)) FROM "public"."users";`),
+			cols:  []string{"max", "max"},
+			rows:  [][]driver.Value{{41, nil}},
+		},
+	}
+	db := mkMockDB(t, ms)
+	lens, err := MaxStringLengths(conv, db, conv.UnboundedStringCols)
+	assert.Nil(t, err)
+	assert.Equal(t, map[string]map[string]int64{"users": map[string]int64{"name": 41}}, lens)
+}
+
+func TestProfileColumns(t *testing.T) {
+	conv := internal.MakeConv()
+	conv.SrcSchema["users"] = schema.Table{
+		Name:     "users",
+		ColNames: []string{"id", "name", "ok"},
+		ColDefs: map[string]schema.Column{
+			"id":   schema.Column{Name: "id", Type: schema.Type{Name: "int8"}},
+			"name": schema.Column{Name: "name", Type: schema.Type{Name: "text"}},
+			"ok":   schema.Column{Name: "ok", Type: schema.Type{Name: "bool"}},
+		},
+		PrimaryKeys: []schema.Key{schema.Key{Column: "id"}},
+	}
+	assert.Nil(t, schemaToDDL(conv))
+	ms := []mockSpec{
+		{
+			query: "SELECT table_schema, table_name FROM information_schema.tables where table_type = 'BASE TABLE'",
+			cols:  []string{"table_schema", "table_name"},
+			rows:  [][]driver.Value{{"public", "users"}},
+		}, {
+			query: regexp.QuoteMeta(`SELECT count(*), count("id"), count(DISTINCT "id"::text), min("id")::text, max("id")::text, count("name"), count(DISTINCT "name"::text), min("name"::text), max("name"::text), count("ok"), count(DISTINCT "ok"::text) FROM (SELECT * FROM "public"."users" LIMIT 1000) t;`),
+			cols:  []string{"count", "count", "count", "min", "max", "count", "count", "min", "max", "count", "count"},
+			rows:  [][]driver.Value{{10, 10, 10, "1", "10", 8, 5, "ann", "zoe", 0, 0}},
+		},
+	}
+	db := mkMockDB(t, ms)
+	assert.Nil(t, ProfileColumns(conv, db, 1000))
+	assert.Equal(t, map[string]internal.ColumnProfile{
+		"users.id":   internal.ColumnProfile{Rows: 10, Distinct: 10, Min: "1", Max: "10"},
+		"users.name": internal.ColumnProfile{Rows: 10, Nulls: 2, Distinct: 5, Min: "ann", Max: "zoe"},
+		"users.ok":   internal.ColumnProfile{Rows: 10, Nulls: 10},
+	}, conv.Profiles)
+}
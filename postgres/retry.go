@@ -0,0 +1,46 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package postgres
+
+import (
+	"errors"
+	"time"
+
+	"github.com/lib/pq"
+
+	"github.com/cloudspannerecosystem/harbourbridge/internal"
+)
+
+// discoveryRetry is how schema discovery queries that fail with
+// transient errors are retried.
+var discoveryRetry = internal.RetryPolicy{Attempts: 5, Delay: time.Second, Transient: isTransient}
+
+// isTransient returns true for errors caused by a busy server or a
+// broken connection, which may not happen again if the query is retried.
+func isTransient(err error) bool {
+	var pqErr *pq.Error
+	if !errors.As(err, &pqErr) {
+		return internal.IsTransientConnError(err)
+	}
+	switch pqErr.Code.Class() {
+	case "08", "40", "53": // Connection exception, transaction rollback (e.g. deadlock) and insufficient resources.
+		return true
+	}
+	switch pqErr.Code {
+	case "55P03", "57014", "57P01", "57P03": // Lock not available, query canceled (e.g. by statement_timeout), admin shutdown, cannot connect now.
+		return true
+	}
+	return false
+}
@@ -0,0 +1,39 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package postgres
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/cloudspannerecosystem/harbourbridge/internal"
+)
+
+// CurrentPosition returns the current WAL location of the server (the
+// last location replayed, for a standby), from which logical replication
+// can be started. version is the version of the server (see
+// ServerVersion): the functions giving WAL locations were renamed in
+// PostgreSQL 10.
+func CurrentPosition(db internal.Queryer, version internal.ServerVersion) (internal.SnapshotPosition, error) {
+	p := internal.SnapshotPosition{Kind: "lsn", Time: time.Now()}
+	q := "SELECT (CASE WHEN pg_is_in_recovery() THEN pg_last_wal_replay_lsn() ELSE pg_current_wal_lsn() END)::text"
+	if !version.AtLeast(10, 0) {
+		q = "SELECT (CASE WHEN pg_is_in_recovery() THEN pg_last_xlog_replay_location() ELSE pg_current_xlog_location() END)::text"
+	}
+	if err := db.QueryRow(q).Scan(&p.Position); err != nil {
+		return p, fmt.Errorf("can't get WAL location: %w", err)
+	}
+	return p, nil
+}
@@ -17,6 +17,7 @@ package postgres
 import (
 	"fmt"
 	"strconv"
+	"strings"
 	"unicode"
 
 	"github.com/cloudspannerecosystem/harbourbridge/internal"
@@ -28,24 +29,25 @@ import (
 // Spanner. It uses the source schema in conv.SrcSchema, and writes
 // the Spanner schema to conv.SpSchema.
 func schemaToDDL(conv *internal.Conv) error {
-	// Tracks Spanner names that have been used for foreign key constraints
-	// and indexes. We use this to ensure we generate unique names when
-	// we map from Postgres to Spanner since Spanner requires all foreign
-	// key and index names to be distinct (you can't use the same name
-	// for a foreign key constraint and an index).
-	usedNames := make(map[string]bool)
+	if err := conv.RunPreTableHooks(); err != nil {
+		return err
+	}
 	// As Spanner uses same namespace for table names, foreign key constraint
-	// names and index names, we need to pre-populate usedNames with Spanner table
-	// names to handle collision with foreign key names and index names.
-	for _, srcTable := range conv.SrcSchema {
+	// names and index names, we need to reserve Spanner table names to
+	// handle collision with foreign key names and index names.
+	// Process tables in a deterministic order, so that Spanner names are
+	// allocated the same way in every run.
+	for _, t := range conv.SrcTables() {
+		srcTable := conv.SrcSchema[t]
 		spTableName, err := internal.GetSpannerTable(conv, srcTable.Name)
 		if err != nil {
 			conv.Unexpected(fmt.Sprintf("Couldn't map source table %s to Spanner: %s", srcTable.Name, err))
 			continue
 		}
-		usedNames[spTableName] = true
+		conv.ReserveName(spTableName)
 	}
-	for _, srcTable := range conv.SrcSchema {
+	for _, t := range conv.SrcTables() {
+		srcTable := conv.SrcSchema[t]
 		spTableName, err := internal.GetSpannerTable(conv, srcTable.Name)
 		if err != nil {
 			conv.Unexpected(fmt.Sprintf("Couldn't map source table %s to Spanner: %s", srcTable.Name, err))
@@ -53,7 +55,7 @@ func schemaToDDL(conv *internal.Conv) error {
 		}
 		var spColNames []string
 		spColDef := make(map[string]ddl.ColumnDef)
-		conv.Issues[srcTable.Name] = make(map[string][]internal.SchemaIssue)
+		colIssues := make(map[string][]internal.SchemaIssue)
 		// Iterate over columns using ColNames order.
 		for _, srcColName := range srcTable.ColNames {
 			srcCol := srcTable.ColDefs[srcColName]
@@ -64,9 +66,14 @@ func schemaToDDL(conv *internal.Conv) error {
 			}
 			spColNames = append(spColNames, colName)
 			ty, issues := toSpannerType(conv, srcCol.Type.Name, srcCol.Type.Mods)
+			if srcCol.LargeObject {
+				// The contents of the large objects are read instead
+				// of their oid (see selectList).
+				ty, issues = ddl.Type{Name: ddl.Bytes, Len: ddl.MaxLength}, []internal.SchemaIssue{internal.LargeObject}
+			}
 
 			if conv.TargetDb == "experimental_postgres" { //TODO : Use constant instead. Using string to prevent import cycle
-				ty = overrideExperimentalType(srcCol, ty)
+				ty = overrideExperimentalType(conv, srcCol, ty, issues)
 			} else {
 				if len(srcCol.Type.ArrayBounds) > 1 {
 					ty = ddl.Type{Name: ddl.String, Len: ddl.MaxLength}
@@ -82,7 +89,7 @@ func schemaToDDL(conv *internal.Conv) error {
 				issues = append(issues, internal.DefaultValue)
 			}
 			if len(issues) > 0 {
-				conv.Issues[srcTable.Name][srcCol.Name] = issues
+				colIssues[srcCol.Name] = issues
 			}
 
 			spColDef[colName] = ddl.ColumnDef{
@@ -93,14 +100,18 @@ func schemaToDDL(conv *internal.Conv) error {
 			}
 		}
 		comment := "Spanner schema for source table " + quoteIfNeeded(srcTable.Name)
-		conv.SpSchema[spTableName] = ddl.CreateTable{
+		conv.SetIssues(srcTable.Name, colIssues)
+		ct := ddl.CreateTable{
 			Name:     spTableName,
 			ColNames: spColNames,
 			ColDefs:  spColDef,
 			Pks:      cvtPrimaryKeys(conv, srcTable.Name, srcTable.PrimaryKeys),
-			Fks:      cvtForeignKeys(conv, srcTable.Name, srcTable.ForeignKeys, usedNames),
-			Indexes:  cvtIndexes(conv, spTableName, srcTable.Name, srcTable.Indexes, usedNames),
+			Fks:      cvtForeignKeys(conv, srcTable.Name, srcTable.ForeignKeys),
+			Indexes:  cvtIndexes(conv, spTableName, srcTable.Name, srcTable.Indexes),
 			Comment:  comment}
+		if err := conv.AddConvertedTable(srcTable.Name, ct); err != nil {
+			return err
+		}
 	}
 	internal.ResolveRefs(conv)
 	return nil
@@ -129,6 +140,9 @@ func toSpannerType(conv *internal.Conv, id string, mods []int64) (ddl.Type, []in
 	case "float8", "double precision":
 		return ddl.Type{Name: ddl.Float64}, nil
 	case "float4", "real":
+		if conv.Supports(internal.Float32) {
+			return ddl.Type{Name: ddl.Float32}, nil
+		}
 		return ddl.Type{Name: ddl.Float64}, []internal.SchemaIssue{internal.Widened}
 	case "int8", "bigint":
 		return ddl.Type{Name: ddl.Int64}, nil
@@ -136,6 +150,10 @@ func toSpannerType(conv *internal.Conv, id string, mods []int64) (ddl.Type, []in
 		return ddl.Type{Name: ddl.Int64}, []internal.SchemaIssue{internal.Widened}
 	case "int2", "smallint":
 		return ddl.Type{Name: ddl.Int64}, []internal.SchemaIssue{internal.Widened}
+	case "json", "jsonb":
+		if conv.Supports(internal.JSON) {
+			return ddl.Type{Name: ddl.JSON}, nil
+		}
 	case "numeric":
 		// PostgreSQL's NUMERIC type can have a specified precision of up to 1000
 		// digits (and scale can be anything from 0 up to the value of 'precision').
@@ -168,14 +186,28 @@ func toSpannerType(conv *internal.Conv, id string, mods []int64) (ddl.Type, []in
 	return ddl.Type{Name: ddl.String, Len: ddl.MaxLength}, []internal.SchemaIssue{internal.NoGoodType}
 }
 
-// Override the types to map to experimental postgres types.
-func overrideExperimentalType(srcCol schema.Column, originalType ddl.Type) ddl.Type {
-	switch originalType.Name {
-	case ddl.Numeric, ddl.Date:
-		return ddl.Type{Name: ddl.String, Len: ddl.MaxLength}
+// Override the types to map to experimental postgres types, based on the
+// features the target supports. issues are the issues of the mapping of
+// originalType. Single-dimension arrays are kept if the target supports
+// arrays and the element type has a good mapping; other arrays are mapped
+// to text.
+func overrideExperimentalType(conv *internal.Conv, srcCol schema.Column, originalType ddl.Type, issues []internal.SchemaIssue) ddl.Type {
+	str := ddl.Type{Name: ddl.String, Len: ddl.MaxLength}
+	switch {
+	case originalType.Name == ddl.Numeric && !conv.Supports(internal.PGNumeric),
+		originalType.Name == ddl.Date && !conv.Supports(internal.PGDate):
+		return str
 	}
 	if len(srcCol.Type.ArrayBounds) > 0 {
-		return ddl.Type{Name: ddl.String, Len: ddl.MaxLength}
+		if len(srcCol.Type.ArrayBounds) > 1 || !conv.Supports(internal.PGArrays) {
+			return str
+		}
+		for _, i := range issues {
+			if i == internal.NoGoodType {
+				return str
+			}
+		}
+		originalType.IsArray = true
 	}
 	return originalType
 }
@@ -203,7 +235,7 @@ func cvtPrimaryKeys(conv *internal.Conv, srcTable string, srcKeys []schema.Key)
 	return spKeys
 }
 
-func cvtForeignKeys(conv *internal.Conv, srcTable string, srcKeys []schema.ForeignKey, usedNames map[string]bool) []ddl.Foreignkey {
+func cvtForeignKeys(conv *internal.Conv, srcTable string, srcKeys []schema.ForeignKey) []ddl.Foreignkey {
 	var spKeys []ddl.Foreignkey
 	for _, key := range srcKeys {
 		if len(key.Columns) != len(key.ReferColumns) {
@@ -226,7 +258,7 @@ func cvtForeignKeys(conv *internal.Conv, srcTable string, srcKeys []schema.Forei
 			spCols = append(spCols, spCol)
 			spReferCols = append(spReferCols, spReferCol)
 		}
-		spKeyName := internal.ToSpannerForeignKey(key.Name, usedNames)
+		spKeyName := conv.ForeignKeyName(srcTable, key.Name, key.Columns)
 		spKey := ddl.Foreignkey{
 			Name:         spKeyName,
 			Columns:      spCols,
@@ -237,11 +269,13 @@ func cvtForeignKeys(conv *internal.Conv, srcTable string, srcKeys []schema.Forei
 	return spKeys
 }
 
-func cvtIndexes(conv *internal.Conv, spTableName string, srcTable string, srcIndexes []schema.Index, usedNames map[string]bool) []ddl.CreateIndex {
+func cvtIndexes(conv *internal.Conv, spTableName string, srcTable string, srcIndexes []schema.Index) []ddl.CreateIndex {
 	var spIndexes []ddl.CreateIndex
 	for _, srcIndex := range srcIndexes {
 		var spKeys []ddl.IndexKey
+		var srcCols []string
 		for _, k := range srcIndex.Keys {
+			srcCols = append(srcCols, k.Column)
 			spCol, err := internal.GetSpannerCol(conv, srcTable, k.Column, true)
 			if err != nil {
 				conv.Unexpected(fmt.Sprintf("Can't map index key column name for table %s", srcTable))
@@ -249,12 +283,23 @@ func cvtIndexes(conv *internal.Conv, spTableName string, srcTable string, srcInd
 			}
 			spKeys = append(spKeys, ddl.IndexKey{Col: spCol, Desc: k.Desc})
 		}
+		if srcIndex.Name == "" && srcIndex.Constraint {
+			// Name unnamed UNIQUE constraints like PostgreSQL does:
+			// table_column1_column2_key.
+			srcIndex.Name = fmt.Sprintf("%s_%s_key", srcTable, strings.Join(srcCols, "_"))
+		}
 		if srcIndex.Name == "" {
 			// Generate a name if index name is empty in Postgres.
-			// Collision of index name will be handled by ToSpannerIndexName.
+			// Collision of index name will be handled by IndexName.
 			srcIndex.Name = fmt.Sprintf("Index_%s", srcTable)
 		}
-		spIndexName := internal.ToSpannerIndexName(srcIndex.Name, usedNames)
+		spIndexName := conv.IndexName(srcTable, srcIndex.Name, srcCols)
+		if srcIndex.HasScans {
+			if conv.IndexScans == nil {
+				conv.IndexScans = make(map[string]int64)
+			}
+			conv.IndexScans[spIndexName] = srcIndex.Scans
+		}
 		spIndex := ddl.CreateIndex{
 			Name:   spIndexName,
 			Table:  spTableName,
@@ -102,6 +102,7 @@ func TestToExperimentalSpannerType(t *testing.T) {
 	conv := internal.MakeConv()
 	conv.SetSchemaMode()
 	conv.TargetDb = "experimental_postgres"
+	conv.Features = internal.Features{} // Target doesn't support numeric or date.
 	name := "test"
 	srcSchema := schema.Table{
 		Name:     name,
@@ -168,6 +169,87 @@ func TestToExperimentalSpannerType(t *testing.T) {
 	assert.Equal(t, expectedIssues, conv.Issues[name])
 }
 
+func TestOverrideExperimentalType(t *testing.T) {
+	conv := internal.MakeConv()
+	numeric := schema.Column{Name: "n", Type: schema.Type{Name: "numeric"}}
+	date := schema.Column{Name: "d", Type: schema.Type{Name: "date"}}
+	str := ddl.Type{Name: ddl.String, Len: ddl.MaxLength}
+	assert.Equal(t, ddl.Type{Name: ddl.Numeric}, overrideExperimentalType(conv, numeric, ddl.Type{Name: ddl.Numeric}, nil))
+	assert.Equal(t, ddl.Type{Name: ddl.Date}, overrideExperimentalType(conv, date, ddl.Type{Name: ddl.Date}, nil))
+	conv.Features = internal.Features{internal.PGDate: true}
+	assert.Equal(t, str, overrideExperimentalType(conv, numeric, ddl.Type{Name: ddl.Numeric}, nil))
+	assert.Equal(t, ddl.Type{Name: ddl.Date}, overrideExperimentalType(conv, date, ddl.Type{Name: ddl.Date}, nil))
+}
+
+func TestOverrideExperimentalType_Arrays(t *testing.T) {
+	conv := internal.MakeConv()
+	ints := schema.Column{Name: "a", Type: schema.Type{Name: "int8", ArrayBounds: []int64{-1}}}
+	matrix := schema.Column{Name: "m", Type: schema.Type{Name: "int8", ArrayBounds: []int64{-1, -1}}}
+	points := schema.Column{Name: "p", Type: schema.Type{Name: "point", ArrayBounds: []int64{-1}}}
+	str := ddl.Type{Name: ddl.String, Len: ddl.MaxLength}
+	assert.Equal(t, ddl.Type{Name: ddl.Int64, IsArray: true}, overrideExperimentalType(conv, ints, ddl.Type{Name: ddl.Int64}, nil))
+	assert.Equal(t, str, overrideExperimentalType(conv, matrix, ddl.Type{Name: ddl.Int64}, nil))
+	assert.Equal(t, str, overrideExperimentalType(conv, points, str, []internal.SchemaIssue{internal.NoGoodType}))
+	conv.Features = internal.Features{}
+	assert.Equal(t, str, overrideExperimentalType(conv, ints, ddl.Type{Name: ddl.Int64}, nil))
+}
+
+func TestToSpannerType_Features(t *testing.T) {
+	conv := internal.MakeConv()
+	ty, issues := toSpannerType(conv, "jsonb", nil)
+	assert.Equal(t, ddl.Type{Name: ddl.String, Len: ddl.MaxLength}, ty)
+	assert.Equal(t, []internal.SchemaIssue{internal.NoGoodType}, issues)
+	ty, issues = toSpannerType(conv, "float4", nil)
+	assert.Equal(t, ddl.Type{Name: ddl.Float64}, ty)
+	assert.Equal(t, []internal.SchemaIssue{internal.Widened}, issues)
+	conv.Features = internal.DefaultFeatures()
+	ty, issues = toSpannerType(conv, "jsonb", nil)
+	assert.Equal(t, ddl.Type{Name: ddl.JSON}, ty)
+	assert.Nil(t, issues)
+	ty, issues = toSpannerType(conv, "real", nil)
+	assert.Equal(t, ddl.Type{Name: ddl.Float32}, ty)
+	assert.Nil(t, issues)
+}
+
+func TestToSpannerType_LargeObject(t *testing.T) {
+	conv := internal.MakeConv()
+	conv.SetSchemaMode()
+	conv.SrcSchema["t"] = schema.Table{
+		Name:     "t",
+		ColNames: []string{"a", "b", "c"},
+		ColDefs: map[string]schema.Column{
+			"a": schema.Column{Name: "a", Type: schema.Type{Name: "int8"}},
+			"b": schema.Column{Name: "b", Type: schema.Type{Name: "oid"}, LargeObject: true},
+			"c": schema.Column{Name: "c", Type: schema.Type{Name: "oid"}},
+		},
+		PrimaryKeys: []schema.Key{schema.Key{Column: "a"}},
+	}
+	assert.Nil(t, schemaToDDL(conv))
+	assert.Equal(t, ddl.Type{Name: ddl.Bytes, Len: ddl.MaxLength}, conv.SpSchema["t"].ColDefs["b"].T)
+	assert.Equal(t, []internal.SchemaIssue{internal.LargeObject}, conv.Issues["t"]["b"])
+	assert.NotEqual(t, ddl.Bytes, conv.SpSchema["t"].ColDefs["c"].T.Name)
+}
+
+func TestCvtIndexes_UniqueConstraints(t *testing.T) {
+	conv := internal.MakeConv()
+	conv.SetSrcTable(schema.Table{
+		Name:     "test",
+		ColNames: []string{"a", "b", "c"},
+		ColDefs:  map[string]schema.Column{"a": {Name: "a"}, "b": {Name: "b"}, "c": {Name: "c"}},
+	})
+	internal.GetSpannerTable(conv, "test")
+	internal.GetSpannerCols(conv, "test", []string{"a", "b", "c"})
+	conv.ReserveName("test")
+	indexes := []schema.Index{
+		{Name: "", Unique: true, Keys: []schema.Key{{Column: "b"}, {Column: "c"}}, Constraint: true},
+		{Name: "", Unique: true, Keys: []schema.Key{{Column: "a"}}},
+	}
+	assert.Equal(t, []ddl.CreateIndex{
+		{Name: "test_b_c_key", Table: "test", Unique: true, Keys: []ddl.IndexKey{{Col: "b"}, {Col: "c"}}},
+		{Name: "Index_test", Table: "test", Unique: true, Keys: []ddl.IndexKey{{Col: "a"}}},
+	}, cvtIndexes(conv, "test", "test", indexes))
+}
+
 func dropComments(t *ddl.CreateTable) {
 	t.Comment = ""
 	for _, c := range t.ColNames {
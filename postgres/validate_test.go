@@ -0,0 +1,116 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package postgres
+
+import (
+	"database/sql/driver"
+	"fmt"
+	"regexp"
+	"testing"
+
+	"github.com/cloudspannerecosystem/harbourbridge/internal"
+	"github.com/cloudspannerecosystem/harbourbridge/schema"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRecentRows(t *testing.T) {
+	conv := internal.MakeConv()
+	conv.SrcSchema["sales.orders"] = schema.Table{
+		Name:     "sales.orders",
+		ColNames: []string{"id", "note"},
+		ColDefs: map[string]schema.Column{
+			"id":   schema.Column{Name: "id", Type: schema.Type{Name: "int8"}},
+			"note": schema.Column{Name: "note", Type: schema.Type{Name: "text"}},
+		},
+		PrimaryKeys: []schema.Key{schema.Key{Column: "id"}},
+	}
+	assert.Nil(t, schemaToDDL(conv))
+	ms := []mockSpec{
+		{
+			query: "SELECT table_schema, table_name FROM information_schema.tables where table_type = 'BASE TABLE'",
+			cols:  []string{"table_schema", "table_name"},
+			rows:  [][]driver.Value{{"sales", "orders"}},
+		}, {
+			query: regexp.QuoteMeta(`SELECT "id", "note" FROM "sales"."orders" ORDER BY "id" DESC LIMIT 2;`),
+			cols:  []string{"id", "note"},
+			rows:  [][]driver.Value{{int64(9), "x"}, {int64(8), nil}},
+		},
+	}
+	rows, err := RecentRows(conv, mkMockDB(t, ms), "sales.orders", 2)
+	assert.Nil(t, err)
+	assert.Equal(t, []map[string]interface{}{
+		{"id": int64(9), "note": "x"},
+		{"id": int64(8)},
+	}, rows)
+
+	delete(conv.SrcSchema, "sales.orders")
+	_, err = RecentRows(conv, mkMockDB(t, nil), "sales.orders", 2)
+	assert.NotNil(t, err)
+}
+
+func TestCountRows(t *testing.T) {
+	ms := []mockSpec{
+		{
+			query: "SELECT table_schema, table_name FROM information_schema.tables where table_type = 'BASE TABLE'",
+			cols:  []string{"table_schema", "table_name"},
+			rows:  [][]driver.Value{{"public", "t"}},
+		}, {
+			query: regexp.QuoteMeta(`SELECT COUNT(*) FROM "public"."t";`),
+			cols:  []string{"count"},
+			rows:  [][]driver.Value{{int64(42)}},
+		},
+	}
+	n, err := CountRows(mkMockDB(t, ms), "t")
+	assert.Nil(t, err)
+	assert.Equal(t, int64(42), n)
+}
+
+func TestValidate_Errors(t *testing.T) {
+	conv := internal.MakeConv()
+	conv.SrcSchema["t"] = schema.Table{
+		Name:        "t",
+		ColNames:    []string{"id"},
+		ColDefs:     map[string]schema.Column{"id": schema.Column{Name: "id", Type: schema.Type{Name: "int8"}}},
+		PrimaryKeys: []schema.Key{schema.Key{Column: "id"}},
+	}
+	conv.SrcSchema["nokey"] = schema.Table{
+		Name:     "nokey",
+		ColNames: []string{"id"},
+		ColDefs:  map[string]schema.Column{"id": schema.Column{Name: "id", Type: schema.Type{Name: "int8"}}},
+	}
+	assert.Nil(t, schemaToDDL(conv))
+	tables := mockSpec{
+		query: "SELECT table_schema, table_name FROM information_schema.tables where table_type = 'BASE TABLE'",
+		cols:  []string{"table_schema", "table_name"},
+		rows:  [][]driver.Value{{"public", "t"}},
+	}
+
+	_, err := RecentRows(conv, mkMockDB(t, nil), "nokey", 2)
+	assert.EqualError(t, err, "table nokey has no primary key")
+	// Tables dropped from the source.
+	_, err = RecentRows(conv, mkMockDB(t, []mockSpec{{query: tables.query, cols: tables.cols}}), "t", 2)
+	assert.EqualError(t, err, "table t not found")
+	_, err = CountRows(mkMockDB(t, []mockSpec{{query: tables.query, cols: tables.cols}}), "t")
+	assert.EqualError(t, err, "table t not found")
+	// Queries that fail.
+	_, err = RecentRows(conv, mkMockDB(t, []mockSpec{tables, {query: `SELECT "id" FROM "public"."t"`, err: fmt.Errorf("connection reset")}}), "t", 2)
+	assert.Contains(t, err.Error(), "couldn't get rows of table t")
+	_, err = CountRows(mkMockDB(t, []mockSpec{tables, {query: regexp.QuoteMeta(`SELECT COUNT(*) FROM "public"."t";`), err: fmt.Errorf("connection reset")}}), "t")
+	assert.Contains(t, err.Error(), "couldn't count rows of table t")
+	// No rows.
+	rows, err := RecentRows(conv, mkMockDB(t, []mockSpec{tables, {query: `SELECT "id" FROM "public"."t"`, cols: []string{"id"}}}), "t", 2)
+	assert.Nil(t, err)
+	assert.Empty(t, rows)
+}
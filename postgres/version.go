@@ -0,0 +1,42 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package postgres
+
+import (
+	"fmt"
+
+	"github.com/cloudspannerecosystem/harbourbridge/internal"
+)
+
+// ServerVersion returns the version of the PostgreSQL server of db.
+func ServerVersion(db internal.Queryer) (internal.ServerVersion, error) {
+	var s string
+	if err := db.QueryRow("SHOW server_version").Scan(&s); err != nil {
+		return internal.ServerVersion{}, fmt.Errorf("can't get PostgreSQL version: %w", err)
+	}
+	return internal.ParseServerVersion("PostgreSQL", s)
+}
+
+// VersionWarnings describes what HarbourBridge can't read from the
+// catalogs of PostgreSQL servers of version v. Discovery queries are
+// adjusted to older versions (e.g. sequences are read without
+// pg_sequences before PostgreSQL 10), but indexes and large objects are
+// read with functions added in PostgreSQL 9.4.
+func VersionWarnings(v internal.ServerVersion) []string {
+	if v.AtLeast(9, 4) {
+		return nil
+	}
+	return []string{fmt.Sprintf("%s is older than 9.4, the oldest version whose catalogs HarbourBridge can fully read: indexes are not migrated, and large objects are migrated as their oid", v)}
+}
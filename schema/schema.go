@@ -48,6 +48,13 @@ type Column struct {
 	Type    Type
 	NotNull bool
 	Ignored Ignored
+	// Collation is the collation of text columns, if known (e.g.
+	// utf8mb4_0900_ai_ci or en_US.utf8). It is empty for other columns,
+	// and when the source doesn't say.
+	Collation string
+	// LargeObject is true for PostgreSQL oid columns that reference
+	// large objects, whose contents are migrated instead of their oid.
+	LargeObject bool `json:",omitempty"`
 }
 
 // ForeignKey represents a foreign key.
@@ -69,6 +76,9 @@ type ForeignKey struct {
 type Key struct {
 	Column string
 	Desc   bool // By default, order is ASC. Set to true to specifiy DESC.
+	// Length is the length of the indexed prefix of Column, for index
+	// keys that only index a prefix of the column (0 otherwise).
+	Length int64
 }
 
 // Index represents a database index.
@@ -87,6 +97,39 @@ type Index struct {
 	Name   string
 	Unique bool
 	Keys   []Key
+	// Constraint is true if the index implements a UNIQUE constraint of the
+	// source database, rather than being created as an index.
+	Constraint bool
+	// Scans is the number of scans of the index since its statistics were
+	// reset, if the source database reports it (HasScans is set).
+	Scans    int64
+	HasScans bool
+}
+
+// Sequence represents a database sequence (or a generator of key values
+// that behaves like one, such as a MySQL AUTO_INCREMENT column).
+type Sequence struct {
+	Name      string
+	Increment int64
+	// Next is the value the sequence returns next (its start value if it
+	// hasn't been used yet).
+	Next int64
+	// Table and Column are the column that owns the sequence, if any.
+	Table  string
+	Column string
+}
+
+// Trigger represents a database trigger. Triggers are not converted, but
+// their code is analyzed to suggest Spanner alternatives.
+type Trigger struct {
+	Name  string
+	Table string
+	// Timing is when the trigger fires, e.g. "BEFORE UPDATE" or
+	// "AFTER INSERT OR DELETE".
+	Timing string
+	// Body is the code run by the trigger (for PostgreSQL, the body of the
+	// trigger function).
+	Body string
 }
 
 // Type represents the type of a column.
@@ -0,0 +1,109 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package spanner
+
+import (
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	sp "cloud.google.com/go/spanner"
+	"google.golang.org/grpc/codes"
+)
+
+// Autotune configures adaptive write concurrency (see
+// BatchWriterConfig.Autotune). Instead of a fixed number of in-progress
+// writes, BatchWriter starts with Min writes and doubles them after each
+// window of Window writes, until Spanner pushes back: the mean commit
+// latency of the window exceeds MaxLatency, or the fraction of its writes
+// that fail with ABORTED or RESOURCE_EXHAUSTED exceeds MaxAbortedRate.
+// The number of writes is then halved, and from then on increased by one
+// per window without push back, and halved per window with push back. It
+// stays within Min and Max.
+type Autotune struct {
+	Min            int64
+	Max            int64
+	Window         int64
+	MaxLatency     time.Duration
+	MaxAbortedRate float64
+}
+
+// DefaultAutotune is the adaptive concurrency used for data conversion.
+var DefaultAutotune = Autotune{
+	Min:            4,
+	Max:            400,
+	Window:         20,
+	MaxLatency:     5 * time.Second,
+	MaxAbortedRate: 0.05,
+}
+
+// autotuner adjusts the write limit of a BatchWriter (see Autotune).
+type autotuner struct {
+	Autotune
+	limit    *int64 // The BatchWriter's write limit; access using atomic.
+	verbose  bool
+	lock     sync.Mutex // Protects the fields below.
+	writes   int64      // Writes completed in the current window.
+	aborted  int64      // Writes of the current window that failed with push back.
+	latency  time.Duration
+	rampedUp bool // True once Spanner has pushed back.
+}
+
+func newAutotuner(config Autotune, limit *int64, verbose bool) *autotuner {
+	atomic.StoreInt64(limit, config.Min)
+	return &autotuner{Autotune: config, limit: limit, verbose: verbose}
+}
+
+// record records a write to Spanner that took d and returned err, and
+// adjusts the write limit at the end of each window.
+// Note: record must be thread-safe because it is called by go routines
+// writing data to Spanner.
+func (t *autotuner) record(d time.Duration, err error) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	t.writes++
+	t.latency += d
+	if code := sp.ErrCode(err); code == codes.Aborted || code == codes.ResourceExhausted {
+		t.aborted++
+	}
+	if t.writes < t.Window {
+		return
+	}
+	mean := t.latency / time.Duration(t.writes)
+	rate := float64(t.aborted) / float64(t.writes)
+	t.writes, t.aborted, t.latency = 0, 0, 0
+	old := atomic.LoadInt64(t.limit)
+	n := old
+	switch {
+	case mean > t.MaxLatency || rate > t.MaxAbortedRate:
+		t.rampedUp = true
+		n = old / 2
+	case t.rampedUp:
+		n = old + 1
+	default:
+		n = old * 2
+	}
+	if n < t.Min {
+		n = t.Min
+	}
+	if n > t.Max {
+		n = t.Max
+	}
+	atomic.StoreInt64(t.limit, n)
+	if t.verbose && n != old {
+		fmt.Printf("Write concurrency %d -> %d (mean commit latency %v, %.1f%% of writes aborted)\n", old, n, mean.Round(time.Millisecond), 100*rate)
+	}
+}
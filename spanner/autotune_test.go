@@ -0,0 +1,55 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package spanner
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestAutotune(t *testing.T) {
+	var limit int64
+	tuner := newAutotuner(Autotune{Min: 2, Max: 16, Window: 2, MaxLatency: time.Second, MaxAbortedRate: 0.4}, &limit, false)
+	assert.Equal(t, int64(2), limit)
+	fast, slow := 10*time.Millisecond, 3*time.Second
+	aborted := status.Error(codes.Aborted, "transaction aborted")
+	windows := []struct {
+		latency time.Duration
+		err     error
+		limit   int64
+	}{
+		{fast, nil, 4},     // Ramp up, doubling...
+		{fast, nil, 8},     //
+		{fast, nil, 16},    // ... up to Max.
+		{fast, nil, 16},    //
+		{slow, nil, 8},     // Back off on latency.
+		{fast, nil, 9},     // Then increase slowly.
+		{fast, aborted, 4}, // Back off on aborted writes.
+		{slow, nil, 2},     // Never below Min.
+		{slow, nil, 2},     //
+	}
+	for i, w := range windows {
+		// The limit only changes at the end of each window.
+		before := limit
+		tuner.record(fast, nil)
+		assert.Equal(t, before, limit)
+		tuner.record(w.latency, w.err)
+		assert.Equal(t, w.limit, limit, "window %d", i)
+	}
+}
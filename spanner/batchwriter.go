@@ -19,6 +19,7 @@ package spanner
 
 import (
 	"fmt"
+	"strings"
 	"sync"
 	"sync/atomic"
 	"time"
@@ -40,11 +41,13 @@ const (
 // BatchWriter accumulates rows of data (via AddRow) and assembles them
 // into batches that it asynchronously writes to Spanner.  Rows are
 // written to Spanner using insert semantics i.e. if a row already exists
-// in the database, the row will fail with error 'AlreadyExists'.  If
-// Spanner returns an error for a batch, BatchWriter splits the batch
+// in the database, the row will fail with error 'AlreadyExists' (unless it
+// is added with ReplaceRow or UpdateRow, or BatchWriterConfig.Upsert is set).  If Spanner returns an error for a batch,
+// BatchWriter splits the batch
 // into smaller chunks to retry, as it attempts to isolate which row(s)
 // in a batch is bad.  BatchWriter respects Spanner's limits on byte size
-// and mutation count and has configurable limits on the number of
+// and mutation count (including mutations for secondary indexes, see
+// BatchWriterConfig.IndexMutations) and has configurable limits on the number of
 // in-progress writes, amount of data buffered and retry behavior.
 // BatchWriter is not threadsafe: only one call to AddRow or Flush should
 // be active at any time.  See ExampleBatchWriter (batchwriter_test.go)
@@ -55,17 +58,33 @@ type BatchWriter struct {
 	rCount     int64                      // Mutation count for buffered rows.
 	write      func([]*sp.Mutation) error // Typically a closure that calls client.Apply, but structured this way for testing.
 	wg         sync.WaitGroup             // Tracks in-progress writes.
-	writeLimit int64                      // Limit on number of in-progress writes.
+	writeLimit int64                      // Limit on number of in-progress writes; access using atomic (see Autotune).
+	tuner      *autotuner                 // Adjusts writeLimit; nil if it is fixed.
 	bytesLimit int64                      // Limit on bytes buffered. AddRow blocks if rBytes exceeded this value.
 	retryLimit int64                      // Limit on retries.
 	verbose    bool                       // If true, print out messages about each write batch.
+	indexMuts  func(string) int64         // Per-row index mutations for a table; may be nil.
+	indexCache map[string]int64           // Cache of indexMuts results.
+	mutations  map[string]int64           // Projected mutation count for rows added, broken down by table.
+	upsert     bool                       // If true, rows are inserted or updated (see BatchWriterConfig.Upsert).
+	dml        func(string) bool          // Tables written with DML; may be nil (see BatchWriterConfig.DML).
+	limits     map[string]TableLimit      // Per-table limits (see BatchWriterConfig.TableLimits).
+	inFlight   map[string]*int64          // Number of in-progress writes with rows of tables that have a limit on writes; access using atomic.
+	control    *WriteControl              // Pauses writes, and counts rows written; may be nil.
 	async      asyncState
+	// writeDML writes batches that have rows written with DML (see
+	// BatchWriterConfig.DML).
+	writeDML func([]*sp.Mutation, []sp.Statement) error
 }
 
 type row struct {
-	table string
-	cols  []string
-	vals  []interface{}
+	table     string
+	cols      []string
+	vals      []interface{}
+	mutations int64 // Projected mutation count, including index mutations.
+	replace   bool  // Write using replace semantics (see ReplaceRow).
+	update    bool  // Write using update semantics (see UpdateRow).
+	child     bool  // Must be written after the row before it (see AddChildRow).
 }
 
 // Fields in this struct are modified asynchronously e.g. by go routines writing
@@ -92,21 +111,84 @@ type BatchWriterConfig struct {
 	RetryLimit int64                      // Limit on retries.
 	Write      func([]*sp.Mutation) error // Function to call to write to Spanner (typically a closure that calls client.Apply).
 	Verbose    bool                       // If true, print out messages about each write batch.
+	// IndexMutations returns the number of extra mutations Spanner counts
+	// for each row written to a table, due to the table's secondary
+	// indexes (see ddl.Schema.IndexMutations). If nil, rows are assumed
+	// to generate one mutation per column.
+	IndexMutations func(table string) int64
+	// Autotune, if not nil, adjusts the limit on in-progress writes to
+	// Spanner's push back, instead of using WriteLimit.
+	Autotune *Autotune
+	// Upsert writes the rows added with AddRow and AddChildRow using
+	// insert-or-update semantics instead of insert semantics, e.g. when
+	// rows that were already written are loaded again.
+	Upsert bool
+	// DML, if not nil, returns true for the tables whose rows added with
+	// AddRow and AddChildRow are written with INSERT statements instead of
+	// mutations, so that Spanner evaluates the DEFAULT values and generated
+	// columns of the table. Batches with such rows are written with
+	// WriteDML, which writes the mutations of the other rows of the batch
+	// and the statements in a single read-write transaction.
+	DML      func(table string) bool
+	WriteDML func([]*sp.Mutation, []sp.Statement) error
+	// TableLimits lowers the limits on in-progress writes and on the size
+	// of batches for some tables, e.g. for hot parent tables that can't
+	// take as many concurrent writes as other tables.
+	TableLimits map[string]TableLimit
+	// Control pauses and resumes writes while rows are written, and
+	// keeps track of the rows written. nil means writes can't be paused.
+	Control *WriteControl
+}
+
+// TableLimit lowers the limits of BatchWriter for the rows of a table. Zero
+// values mean the global limits apply.
+type TableLimit struct {
+	// Writes is the limit on the number of in-progress writes with rows
+	// of the table.
+	Writes int64 `json:"writes,omitempty"`
+	// BatchRows is the limit on the number of rows of the table in a
+	// batch.
+	BatchRows int `json:"batch_rows,omitempty"`
 }
 
 // NewBatchWriter returns a new BatchWriter with parameters defined by config.
 func NewBatchWriter(config BatchWriterConfig) *BatchWriter {
-	return &BatchWriter{
+	bw := &BatchWriter{
 		write:      config.Write,
 		writeLimit: config.WriteLimit,
 		bytesLimit: config.BytesLimit,
 		retryLimit: config.RetryLimit,
 		verbose:    config.Verbose,
+		indexMuts:  config.IndexMutations,
+		indexCache: make(map[string]int64),
+		mutations:  make(map[string]int64),
+		upsert:     config.Upsert,
+		dml:        config.DML,
+		writeDML:   config.WriteDML,
+		limits:     config.TableLimits,
+		inFlight:   make(map[string]*int64),
+		control:    config.Control,
 		async: asyncState{
 			errors:      make(map[string]int64),
 			droppedRows: make(map[string]int64),
 		},
 	}
+	if config.Autotune != nil {
+		bw.tuner = newAutotuner(*config.Autotune, &bw.writeLimit, config.Verbose)
+	}
+	for t, l := range config.TableLimits {
+		if l.Writes > 0 {
+			bw.inFlight[t] = new(int64)
+		}
+	}
+	return bw
+}
+
+// WriteLimit returns the current limit on in-progress writes. With
+// BatchWriterConfig.Autotune, it is the concurrency that writes have
+// settled at.
+func (bw *BatchWriter) WriteLimit() int64 {
+	return atomic.LoadInt64(&bw.writeLimit)
 }
 
 // AddRow appends a new row of data to bw's buffer of rows. Depending on the
@@ -114,18 +196,49 @@ func NewBatchWriter(config BatchWriterConfig) *BatchWriter {
 // or it may block (waiting for some of the writes already in progress to
 // complete) and then initiate writes.
 func (bw *BatchWriter) AddRow(table string, cols []string, vals []interface{}) {
-	r := &row{table, cols, vals}
+	bw.addRow(&row{table: table, cols: cols, vals: vals, mutations: bw.mutationCount(table, cols)})
+}
+
+func (bw *BatchWriter) addRow(r *row) {
 	bw.rows = append(bw.rows, r)
 	bw.rBytes += byteSize(r)
-	bw.rCount += int64(len(r.cols))
+	bw.rCount += r.mutations
+	bw.mutations[r.table] += r.mutations
 	bw.writeData()
 }
 
+// ReplaceRow is like AddRow, but the row is written using replace
+// semantics i.e. it replaces any existing row with the same primary key
+// (columns not in cols become NULL). To ensure the row replaces rows
+// added earlier, ReplaceRow first waits for all of them to be written,
+// so it should only be used for the occasional row.
+func (bw *BatchWriter) ReplaceRow(table string, cols []string, vals []interface{}) {
+	bw.Flush()
+	bw.addRow(&row{table: table, cols: cols, vals: vals, mutations: bw.mutationCount(table, cols), replace: true})
+}
+
+// UpdateRow is like AddRow, but the row is written using update
+// semantics i.e. it sets cols of the existing row with the same primary
+// key, and fails if there is no such row. The caller must ensure that
+// the row being updated has been written (e.g. by calling Flush).
+func (bw *BatchWriter) UpdateRow(table string, cols []string, vals []interface{}) {
+	bw.addRow(&row{table: table, cols: cols, vals: vals, mutations: bw.mutationCount(table, cols), update: true})
+}
+
+// AddChildRow is like AddRow, but the row is written after the row added
+// just before it has been written, which makes it possible to write rows
+// of a table interleaved in the table of that row (its parent row).
+// BatchWriter keeps such rows in the same batch as their parent row when
+// it can; otherwise it waits for the parent row's batch to be written.
+func (bw *BatchWriter) AddChildRow(table string, cols []string, vals []interface{}) {
+	bw.addRow(&row{table: table, cols: cols, vals: vals, mutations: bw.mutationCount(table, cols), child: true})
+}
+
 // Flush initiates writes to Spanner of all buffered rows of data, and waits
 // for them to complete.
 func (bw *BatchWriter) Flush() {
 	for len(bw.rows) > 0 {
-		if atomic.LoadInt64(&bw.async.writes) < bw.writeLimit {
+		if atomic.LoadInt64(&bw.async.writes) < bw.WriteLimit() {
 			m, count, bytes := bw.getBatch()
 			if bw.verbose {
 				fmt.Printf("Starting write of %d rows to Spanner (%d bytes, %d mutations) [%d in progress]\n",
@@ -139,6 +252,28 @@ func (bw *BatchWriter) Flush() {
 	bw.wg.Wait()
 }
 
+// MutationsByTable returns a map of tables to the projected number of
+// Spanner mutations (including index mutations) for the rows added.
+// It should only be called when no AddRow call is active.
+func (bw *BatchWriter) MutationsByTable() map[string]int64 {
+	m := make(map[string]int64)
+	for t, n := range bw.mutations {
+		m[t] = n
+	}
+	return m
+}
+
+// Mutations returns the projected number of Spanner mutations
+// (including index mutations) for all rows added. It should only be
+// called when no AddRow call is active.
+func (bw *BatchWriter) Mutations() int64 {
+	var n int64
+	for _, x := range bw.mutations {
+		n += x
+	}
+	return n
+}
+
 // DroppedRowsByTable returns a map of tables to counts of dropped rows.
 // Dropped rows are rows that were not written to Spanner.
 func (bw *BatchWriter) DroppedRowsByTable() map[string]int64 {
@@ -189,16 +324,36 @@ func (bw *BatchWriter) getBadRowsForTest() []*row {
 }
 
 // getBatch returns a slice of data from the front of bw.rows.  The slice
-// returned is the largest one not exceeding countThreshold and byteThreshold.
+// returned is the largest one not exceeding countThreshold and byteThreshold
+// (and the limits on the rows of tables, see TableLimit.BatchRows).
 func (bw *BatchWriter) getBatch() (rows []*row, count int64, bytes int64) {
+	tableRows := make(map[string]int)
 	for i := range bw.rows {
-		c := count + int64(len(bw.rows[i].cols))
+		c := count + bw.rows[i].mutations
 		b := bytes + byteSize(bw.rows[i])
+		n := bw.limits[bw.rows[i].table].BatchRows
 		// If next row puts us over the thresholds, then stop. But make sure
 		// we have at least one row. If a single row puts us over the
 		// thresholds, there's not much we can do: we just try sending it to Spanner
 		// (it might succeed, since our thresholds are conservative).
-		if (c >= countThreshold || b >= byteThreshold) && len(rows) >= 1 {
+		if (c >= countThreshold || b >= byteThreshold || (n > 0 && tableRows[bw.rows[i].table] >= n)) && len(rows) >= 1 {
+			// Avoid separating child rows from their parent row: end
+			// the batch before the parent row instead, unless the
+			// parent row starts the batch.
+			if bw.rows[i].child {
+				p := i - 1
+				for p > 0 && bw.rows[p].child {
+					p--
+				}
+				if p > 0 {
+					for _, r := range rows[p:] {
+						count -= r.mutations
+						bytes -= byteSize(r)
+					}
+					rows = rows[:p]
+					i = p
+				}
+			}
 			bw.rCount -= count
 			bw.rBytes -= bytes
 			bw.rows = bw.rows[i:]
@@ -207,6 +362,7 @@ func (bw *BatchWriter) getBatch() (rows []*row, count int64, bytes int64) {
 		count = c
 		bytes = b
 		rows = append(rows, bw.rows[i])
+		tableRows[bw.rows[i].table]++
 	}
 	bw.rCount = 0
 	bw.rBytes = 0
@@ -216,7 +372,7 @@ func (bw *BatchWriter) getBatch() (rows []*row, count int64, bytes int64) {
 
 func (bw *BatchWriter) errorStats(rows []*row, err error, retry bool) {
 	if bw.verbose {
-		fmt.Printf("Error while writing %d rows to Spanner: %v\n", len(rows), err)
+		fmt.Printf("Error while writing %d rows to Spanner (%s): %v\n", len(rows), bw.describeBatch(rows), err)
 	}
 
 	bw.async.lock.Lock()
@@ -247,13 +403,41 @@ func (bw *BatchWriter) errorStats(rows []*row, err error, retry bool) {
 // inside a go routine.
 func (bw *BatchWriter) doWriteAndHandleErrors(rows []*row) {
 	var m []*sp.Mutation
+	var stmts []sp.Statement
 	for _, x := range rows {
-		m = append(m, sp.Insert(x.table, x.cols, x.vals))
+		switch {
+		case bw.useDML(x):
+			stmts = append(stmts, dmlStatement(x.table, x.cols, x.vals, bw.upsert))
+		case x.replace:
+			m = append(m, sp.Replace(x.table, x.cols, x.vals))
+		case x.update:
+			m = append(m, sp.Update(x.table, x.cols, x.vals))
+		case bw.upsert:
+			m = append(m, sp.InsertOrUpdate(x.table, x.cols, x.vals))
+		default:
+			m = append(m, sp.Insert(x.table, x.cols, x.vals))
+		}
+	}
+	start := time.Now()
+	var err error
+	if len(stmts) > 0 {
+		err = bw.writeDML(m, stmts)
+	} else {
+		err = bw.write(m)
 	}
-	if err := bw.write(m); err != nil {
+	if bw.tuner != nil {
+		bw.tuner.record(time.Since(start), err)
+	}
+	if err == nil && bw.control != nil {
+		bw.control.record(rows, nil, false)
+	}
+	if err != nil {
 		hitRetryLimit := atomic.LoadInt64(&bw.async.retries) >= bw.retryLimit
 		retry := len(rows) > 1 && !hitRetryLimit
 		bw.errorStats(rows, err, retry)
+		if bw.control != nil {
+			bw.control.record(rows, err, retry)
+		}
 		if !retry {
 			if hitRetryLimit && bw.verbose {
 				fmt.Printf("Have hit %d retries: will not do any more\n", atomic.LoadInt64(&bw.async.retries))
@@ -279,19 +463,113 @@ func (bw *BatchWriter) doWriteAndHandleErrors(rows []*row) {
 	}
 }
 
+// useDML returns true if r is written with a DML statement (see
+// BatchWriterConfig.DML). Rows written with replace or update semantics
+// are always written with mutations.
+func (bw *BatchWriter) useDML(r *row) bool {
+	return bw.dml != nil && !r.replace && !r.update && bw.dml(r.table)
+}
+
+// dmlStatement returns the statement that inserts (or, if upsert is set,
+// inserts or updates) the values vals of columns cols of table.
+func dmlStatement(table string, cols []string, vals []interface{}, upsert bool) sp.Statement {
+	params := make(map[string]interface{})
+	var quoted, refs []string
+	for i, c := range cols {
+		params[fmt.Sprintf("p%d", i+1)] = vals[i]
+		quoted = append(quoted, "`"+c+"`")
+		refs = append(refs, fmt.Sprintf("@p%d", i+1))
+	}
+	insert := "INSERT"
+	if upsert {
+		insert = "INSERT OR UPDATE"
+	}
+	return sp.Statement{
+		SQL:    fmt.Sprintf("%s INTO `%s` (%s) VALUES (%s)", insert, table, strings.Join(quoted, ", "), strings.Join(refs, ", ")),
+		Params: params,
+	}
+}
+
 // Note: backgroundWrite must be thread-safe because it is run as
 // a go routine.
-func (bw *BatchWriter) backgroundWrite(rows []*row) {
+func (bw *BatchWriter) backgroundWrite(rows []*row, tables []*int64) {
 	defer bw.wg.Done()
 	defer atomic.AddInt64(&bw.async.writes, -1)
+	if bw.control != nil {
+		defer bw.control.done()
+	}
+	defer func() {
+		for _, w := range tables {
+			atomic.AddInt64(w, -1)
+		}
+	}()
 	bw.doWriteAndHandleErrors(rows)
 }
 
-// startWrite initiates an asynchronous write of rows to Spanner.
+// startWrite initiates an asynchronous write of rows to Spanner. If the
+// first row is a child row, its parent row is in a batch that is possibly
+// still being written: startWrite waits for it to complete first. If rows
+// has rows of tables that have reached their limit on in-progress writes
+// (see TableLimit.Writes), startWrite waits for some of their writes to
+// complete first. If the writes of tables of rows are paused (see
+// WriteControl), startWrite waits for them to be resumed.
 func (bw *BatchWriter) startWrite(rows []*row) {
+	if rows[0].child {
+		bw.wg.Wait()
+	}
+	if bw.control != nil {
+		bw.control.wait(rowTables(rows))
+	}
+	tables := bw.limitedTables(rows)
+	for !bw.tablesAvailable(tables) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	var counters []*int64
+	for _, t := range tables {
+		atomic.AddInt64(bw.inFlight[t], 1)
+		counters = append(counters, bw.inFlight[t])
+	}
 	bw.wg.Add(1)
 	atomic.AddInt64(&bw.async.writes, 1)
-	go bw.backgroundWrite(rows)
+	go bw.backgroundWrite(rows, counters)
+}
+
+// rowTables returns the tables of rows.
+func rowTables(rows []*row) []string {
+	var tables []string
+	seen := make(map[string]bool)
+	for _, r := range rows {
+		if !seen[r.table] {
+			seen[r.table] = true
+			tables = append(tables, r.table)
+		}
+	}
+	return tables
+}
+
+// limitedTables returns the tables of rows that have a limit on
+// in-progress writes.
+func (bw *BatchWriter) limitedTables(rows []*row) []string {
+	var tables []string
+	seen := make(map[string]bool)
+	for _, r := range rows {
+		if _, ok := bw.inFlight[r.table]; ok && !seen[r.table] {
+			seen[r.table] = true
+			tables = append(tables, r.table)
+		}
+	}
+	return tables
+}
+
+// tablesAvailable returns true if none of tables has reached its limit on
+// in-progress writes.
+func (bw *BatchWriter) tablesAvailable(tables []string) bool {
+	for _, t := range tables {
+		if atomic.LoadInt64(bw.inFlight[t]) >= bw.limits[t].Writes {
+			return false
+		}
+	}
+	return true
 }
 
 // writeData initiates writes to Spanner until either:
@@ -300,7 +578,7 @@ func (bw *BatchWriter) startWrite(rows []*row) {
 // It will block and re-try till either (a) or (b) holds.
 func (bw *BatchWriter) writeData() {
 	for bw.rCount > countThreshold || bw.rBytes > byteThreshold {
-		if atomic.LoadInt64(&bw.async.writes) < bw.writeLimit {
+		if atomic.LoadInt64(&bw.async.writes) < bw.WriteLimit() {
 			m, count, bytes := bw.getBatch()
 			if bw.verbose {
 				fmt.Printf("Starting write of %d rows to Spanner (%d bytes, %d mutations) [%d in progress]\n",
@@ -316,6 +594,36 @@ func (bw *BatchWriter) writeData() {
 	}
 }
 
+// mutationCount returns the projected number of Spanner mutations for
+// writing a row: one per column, plus mutations for the table's indexes.
+func (bw *BatchWriter) mutationCount(table string, cols []string) int64 {
+	if bw.indexMuts == nil {
+		return int64(len(cols))
+	}
+	n, ok := bw.indexCache[table]
+	if !ok {
+		n = bw.indexMuts(table)
+		bw.indexCache[table] = n
+	}
+	return int64(len(cols)) + n
+}
+
+// describeBatch returns a summary of the tables and projected mutation
+// count of rows, to help identify which table is causing errors.
+func (bw *BatchWriter) describeBatch(rows []*row) string {
+	var tables []string
+	seen := make(map[string]bool)
+	var n int64
+	for _, r := range rows {
+		n += r.mutations
+		if !seen[r.table] {
+			seen[r.table] = true
+			tables = append(tables, r.table)
+		}
+	}
+	return fmt.Sprintf("%d mutations, tables: %s", n, strings.Join(tables, ", "))
+}
+
 func byteSize(r *row) int64 {
 	n := int64(len(r.table))
 	for _, c := range r.cols {
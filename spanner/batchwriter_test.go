@@ -21,6 +21,7 @@ import (
 	"sort"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 
@@ -93,6 +94,181 @@ func TestFlush(t *testing.T) {
 	}
 }
 
+func TestIndexMutations(t *testing.T) {
+	var maxRows int
+	var mutex sync.Mutex
+	config := BatchWriterConfig{
+		BytesLimit: 100 << 20,
+		WriteLimit: 40,
+		RetryLimit: 1000,
+		Write: func(m []*sp.Mutation) error {
+			mutex.Lock()
+			if len(m) > maxRows {
+				maxRows = len(m)
+			}
+			mutex.Unlock()
+			return nil
+		},
+		IndexMutations: func(table string) int64 {
+			if table == "indexed" {
+				return 8
+			}
+			return 0
+		},
+	}
+	bw := NewBatchWriter(config)
+	data, _ := generateRows(10000, 5)
+	for _, x := range data {
+		bw.AddRow("indexed", x.cols, x.vals)
+	}
+	bw.AddRow("plain", []string{"a", "b"}, []interface{}{1, "x"})
+	bw.Flush()
+	// Each row in table "indexed" counts as 10 mutations (2 columns, plus 8 index mutations).
+	assert.LessOrEqual(t, maxRows, countThreshold/10)
+	assert.Equal(t, map[string]int64{"indexed": 100000, "plain": 2}, bw.MutationsByTable())
+	assert.Equal(t, int64(100002), bw.Mutations())
+}
+
+func TestReplaceRow(t *testing.T) {
+	var batches []int
+	config := BatchWriterConfig{
+		BytesLimit: 100 << 20,
+		WriteLimit: 40,
+		RetryLimit: 1000,
+		Write: func(m []*sp.Mutation) error {
+			batches = append(batches, len(m))
+			return nil
+		},
+	}
+	bw := NewBatchWriter(config)
+	for i := 0; i < 3; i++ {
+		bw.AddRow("t", []string{"a"}, []interface{}{i})
+	}
+	// Rows added before ReplaceRow are written before it.
+	bw.ReplaceRow("t", []string{"a"}, []interface{}{0})
+	assert.Equal(t, []int{3}, batches)
+	bw.Flush()
+	assert.Equal(t, []int{3, 1}, batches)
+	assert.Equal(t, int64(4), bw.Mutations())
+}
+
+func TestAddChildRow(t *testing.T) {
+	var batches []int
+	var mutex sync.Mutex
+	config := BatchWriterConfig{
+		BytesLimit: 100 << 20,
+		WriteLimit: 40,
+		RetryLimit: 1000,
+		Write: func(m []*sp.Mutation) error {
+			mutex.Lock()
+			batches = append(batches, len(m))
+			mutex.Unlock()
+			return nil
+		},
+	}
+	bw := NewBatchWriter(config)
+	for i := 0; i < 10000; i++ {
+		bw.AddRow("parent", []string{"a"}, []interface{}{i})
+		bw.AddChildRow("child", []string{"a"}, []interface{}{i})
+	}
+	bw.Flush()
+	// Batches are never split between a parent row and its child row.
+	n := 0
+	for _, b := range batches {
+		assert.Equal(t, 0, b%2)
+		n += b
+	}
+	assert.Equal(t, 20000, n)
+
+	// A parent row with too many child rows for a single batch.
+	batches = nil
+	bw.AddRow("parent", []string{"a"}, []interface{}{0})
+	for i := 0; i < 15000; i++ {
+		bw.AddChildRow("child", []string{"a"}, []interface{}{i})
+	}
+	bw.Flush()
+	assert.Equal(t, []int{countThreshold - 1, 15001 - (countThreshold - 1)}, batches)
+}
+
+func TestDML(t *testing.T) {
+	var mutations, statements []int
+	var stmts []sp.Statement
+	config := BatchWriterConfig{
+		BytesLimit: 100 << 20,
+		WriteLimit: 1,
+		RetryLimit: 1000,
+		Write: func(m []*sp.Mutation) error {
+			mutations = append(mutations, len(m))
+			return nil
+		},
+		DML: func(table string) bool { return table == "dml" },
+		WriteDML: func(m []*sp.Mutation, s []sp.Statement) error {
+			mutations = append(mutations, len(m))
+			statements = append(statements, len(s))
+			stmts = append(stmts, s...)
+			return nil
+		},
+	}
+	bw := NewBatchWriter(config)
+	bw.AddRow("plain", []string{"a"}, []interface{}{1})
+	bw.Flush()
+	assert.Equal(t, []int{1}, mutations)
+	assert.Nil(t, statements)
+	bw.AddRow("plain", []string{"a"}, []interface{}{2})
+	bw.AddRow("dml", []string{"a", "b"}, []interface{}{3, "x"})
+	bw.Flush()
+	// Rows of both kinds are written in a single transaction.
+	assert.Equal(t, []int{1, 1}, mutations)
+	assert.Equal(t, []int{1}, statements)
+	assert.Equal(t, []sp.Statement{{SQL: "INSERT INTO `dml` (`a`, `b`) VALUES (@p1, @p2)", Params: map[string]interface{}{"p1": 3, "p2": "x"}}}, stmts)
+	// Replaced rows are written with mutations.
+	bw.ReplaceRow("dml", []string{"a"}, []interface{}{3})
+	bw.Flush()
+	assert.Equal(t, []int{1, 1, 1}, mutations)
+	assert.Equal(t, []int{1}, statements)
+}
+
+func TestDMLStatement(t *testing.T) {
+	s := dmlStatement("t", []string{"a"}, []interface{}{int64(1)}, true)
+	assert.Equal(t, "INSERT OR UPDATE INTO `t` (`a`) VALUES (@p1)", s.SQL)
+	assert.Equal(t, map[string]interface{}{"p1": int64(1)}, s.Params)
+}
+
+func TestTableLimits(t *testing.T) {
+	var mutex sync.Mutex
+	var batches []int
+	var hot, maxHot int64
+	config := BatchWriterConfig{
+		BytesLimit: 100 << 20,
+		WriteLimit: 40,
+		RetryLimit: 1000,
+		Write: func(m []*sp.Mutation) error {
+			n := atomic.AddInt64(&hot, 1)
+			mutex.Lock()
+			batches = append(batches, len(m))
+			if n > maxHot {
+				maxHot = n
+			}
+			mutex.Unlock()
+			time.Sleep(time.Millisecond)
+			atomic.AddInt64(&hot, -1)
+			return nil
+		},
+		TableLimits: map[string]TableLimit{"hot": {Writes: 1, BatchRows: 100}},
+	}
+	bw := NewBatchWriter(config)
+	for i := 0; i < 1000; i++ {
+		bw.AddRow("hot", []string{"a"}, []interface{}{i})
+	}
+	bw.Flush()
+	// Batches have at most 100 rows, and are written one at a time.
+	assert.Equal(t, 10, len(batches))
+	for _, b := range batches {
+		assert.Equal(t, 100, b)
+	}
+	assert.Equal(t, int64(1), maxHot)
+}
+
 func TestDroppedRowsByTable(t *testing.T) {
 	bw := NewBatchWriter(BatchWriterConfig{})
 	bw.async.lock.Lock()
@@ -109,8 +285,8 @@ func TestSampleBadRows(t *testing.T) {
 	bw := NewBatchWriter(BatchWriterConfig{})
 	bw.async.lock.Lock()
 	bw.async.sampleBadRows = []*row{
-		&row{"test", []string{"col1", "col2"}, []interface{}{"a", int64(42)}},
-		&row{"test", []string{"col1", "col2"}, []interface{}{"b", int64(6)}},
+		&row{table: "test", cols: []string{"col1", "col2"}, vals: []interface{}{"a", int64(42)}},
+		&row{table: "test", cols: []string{"col1", "col2"}, vals: []interface{}{"b", int64(6)}},
 	}
 	bw.async.lock.Unlock()
 	l := bw.SampleBadRows(1)
@@ -164,7 +340,7 @@ func generateRows(count int, size int) ([]*row, int) {
 	for i := 0; i < count; i++ {
 		// vals[0] serves as a unique id for each row.
 		vals := []interface{}{i, val}
-		r = append(r, &row{"table", cols, vals})
+		r = append(r, &row{table: "table", cols: cols, vals: vals})
 	}
 	// Find the max number of rows in a write for the (fixed sized)
 	// rows generated in this test data.
@@ -0,0 +1,194 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package spanner
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+// WriteControl pauses and resumes the writes of a BatchWriter, for all
+// tables or for some of them, and keeps track of the rows written. It is
+// shared between the BatchWriter (see BatchWriterConfig.Control) and the
+// code controlling a migration, e.g. an interactive terminal UI. Pausing
+// holds back batches that haven't started: writes in progress complete.
+// Since AddRow blocks once enough rows are buffered, pausing the writes of
+// a table also pauses reading the source once it gets to that table.
+// WriteControl is safe for concurrent use.
+type WriteControl struct {
+	mu      sync.Mutex
+	paused  bool             // All writes are paused.
+	tables  map[string]bool  // Tables whose writes are paused.
+	written map[string]int64 // Rows written, broken down by table.
+	dropped map[string]int64 // Rows that couldn't be written, broken down by table.
+	errors  int64            // Number of failed writes (including those retried).
+	writes  int64            // Number of writes in progress.
+}
+
+// WriteStats are the rows written by a BatchWriter (see WriteControl.Stats).
+type WriteStats struct {
+	Written map[string]int64 // Rows written, broken down by Spanner table.
+	Dropped map[string]int64 // Rows that couldn't be written, broken down by Spanner table.
+	Errors  int64            // Number of failed writes, including those that were retried.
+	Writes  int64            // Number of writes in progress.
+}
+
+// NewWriteControl returns a WriteControl with no writes paused.
+func NewWriteControl() *WriteControl {
+	return &WriteControl{
+		tables:  make(map[string]bool),
+		written: make(map[string]int64),
+		dropped: make(map[string]int64),
+	}
+}
+
+// Pause pauses the writes of all tables.
+func (c *WriteControl) Pause() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.paused = true
+}
+
+// Resume resumes the writes paused by Pause. Tables paused with
+// PauseTable stay paused.
+func (c *WriteControl) Resume() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.paused = false
+}
+
+// Paused returns true if the writes of all tables are paused.
+func (c *WriteControl) Paused() bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.paused
+}
+
+// PauseTable pauses the writes of table.
+func (c *WriteControl) PauseTable(table string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.tables[table] = true
+}
+
+// ResumeTable resumes the writes of table paused by PauseTable.
+func (c *WriteControl) ResumeTable(table string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	delete(c.tables, table)
+}
+
+// PausedTables returns the tables paused by PauseTable, sorted.
+func (c *WriteControl) PausedTables() []string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	var l []string
+	for t := range c.tables {
+		l = append(l, t)
+	}
+	sort.Strings(l)
+	return l
+}
+
+// Stats returns the rows written so far.
+func (c *WriteControl) Stats() WriteStats {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	s := WriteStats{
+		Written: make(map[string]int64),
+		Dropped: make(map[string]int64),
+		Errors:  c.errors,
+		Writes:  c.writes,
+	}
+	for t, n := range c.written {
+		s.Written[t] = n
+	}
+	for t, n := range c.dropped {
+		s.Dropped[t] = n
+	}
+	return s
+}
+
+// Drain waits until no writes are in progress, e.g. once they are paused,
+// for at most timeout. It returns false if writes are still in progress.
+func (c *WriteControl) Drain(timeout time.Duration) bool {
+	deadline := time.Now().Add(timeout)
+	for {
+		c.mu.Lock()
+		writes := c.writes
+		c.mu.Unlock()
+		if writes == 0 {
+			return true
+		}
+		if time.Now().After(deadline) {
+			return false
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+// start counts a write as in progress, unless the writes of any of tables
+// are paused. Both are done with c.mu held, so that once Pause returns, no
+// write starts until Resume is called.
+func (c *WriteControl) start(tables []string) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.paused {
+		return false
+	}
+	for _, t := range tables {
+		if c.tables[t] {
+			return false
+		}
+	}
+	c.writes++
+	return true
+}
+
+// wait blocks while the writes of any of tables are paused, and then
+// counts a write as in progress.
+func (c *WriteControl) wait(tables []string) {
+	for !c.start(tables) {
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+// done counts the end of a write.
+func (c *WriteControl) done() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.writes--
+}
+
+// record counts the rows of a write: written if err is nil, dropped if
+// they won't be retried.
+func (c *WriteControl) record(rows []*row, err error, retry bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if err != nil {
+		c.errors++
+	}
+	if err != nil && retry {
+		return
+	}
+	for _, r := range rows {
+		if err == nil {
+			c.written[r.table]++
+		} else {
+			c.dropped[r.table]++
+		}
+	}
+}
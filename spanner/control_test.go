@@ -0,0 +1,129 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package spanner
+
+import (
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	sp "cloud.google.com/go/spanner"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWriteControl(t *testing.T) {
+	var mutex sync.Mutex
+	tables := make(map[string]int)
+	control := NewWriteControl()
+	bw := NewBatchWriter(BatchWriterConfig{
+		BytesLimit: 100 << 20,
+		WriteLimit: 40,
+		RetryLimit: 1000,
+		Write: func(m []*sp.Mutation) error {
+			mutex.Lock()
+			defer mutex.Unlock()
+			tables["t"] += len(m)
+			return nil
+		},
+		TableLimits: map[string]TableLimit{"t": {BatchRows: 10}},
+		Control:     control,
+	})
+	control.PauseTable("t")
+	assert.Equal(t, []string{"t"}, control.PausedTables())
+	done := make(chan bool)
+	go func() {
+		for i := 0; i < 20; i++ {
+			bw.AddRow("t", []string{"a"}, []interface{}{i})
+		}
+		bw.Flush()
+		close(done)
+	}()
+	time.Sleep(50 * time.Millisecond)
+	mutex.Lock()
+	assert.Equal(t, 0, tables["t"]) // Writes are held back.
+	mutex.Unlock()
+	control.Pause()
+	control.ResumeTable("t")
+	assert.True(t, control.Paused())
+	assert.True(t, control.Drain(time.Second))
+	time.Sleep(50 * time.Millisecond)
+	mutex.Lock()
+	assert.Equal(t, 0, tables["t"]) // Still paused.
+	mutex.Unlock()
+	control.Resume()
+	<-done
+	s := control.Stats()
+	assert.Equal(t, int64(20), s.Written["t"])
+	assert.Equal(t, int64(0), s.Writes)
+	assert.Equal(t, int64(0), s.Errors)
+}
+
+func TestWriteControl_BadRows(t *testing.T) {
+	bad := []*sp.Mutation{sp.Insert("u", []string{"a"}, []interface{}{3})}
+	control := NewWriteControl()
+	bw := NewBatchWriter(BatchWriterConfig{
+		BytesLimit: 100 << 20,
+		WriteLimit: 40,
+		RetryLimit: 1000,
+		Write: func(m []*sp.Mutation) error {
+			if intersect(m, bad) {
+				return errors.New("bad data")
+			}
+			return nil
+		},
+		Control: control,
+	})
+	// Tables that aren't paused are written while others are.
+	control.PauseTable("t")
+	for i := 0; i < 10; i++ {
+		bw.AddRow("u", []string{"a"}, []interface{}{i})
+	}
+	bw.Flush()
+	s := control.Stats()
+	assert.Equal(t, map[string]int64{"u": 9}, s.Written)
+	assert.Equal(t, map[string]int64{"u": 1}, s.Dropped)
+	assert.Equal(t, bw.DroppedRowsByTable(), s.Dropped)
+	// Failed writes are counted, including those that were retried.
+	assert.True(t, s.Errors >= 2, "errors: %d", s.Errors)
+	assert.Equal(t, int64(0), s.Writes)
+
+	// Stats are a copy.
+	s.Written["u"] = 0
+	assert.Equal(t, int64(9), control.Stats().Written["u"])
+}
+
+func TestWriteControl_Drain(t *testing.T) {
+	control := NewWriteControl()
+	assert.True(t, control.Drain(0))
+	assert.True(t, control.start([]string{"t"}))
+	assert.False(t, control.Drain(30*time.Millisecond))
+	assert.Equal(t, int64(1), control.Stats().Writes)
+
+	// Writes in progress complete when paused, and no other write starts.
+	control.Pause()
+	control.PauseTable("u")
+	assert.False(t, control.start([]string{"t"}))
+	control.done()
+	assert.True(t, control.Drain(time.Second))
+	control.Resume()
+	assert.False(t, control.start([]string{"t", "u"}))
+	assert.True(t, control.start([]string{"t"}))
+	control.ResumeTable("u")
+	control.ResumeTable("v") // Tables that aren't paused are ignored.
+	assert.Empty(t, control.PausedTables())
+	assert.True(t, control.start([]string{"u"}))
+	assert.Equal(t, int64(2), control.Stats().Writes)
+}
@@ -45,13 +45,17 @@ const (
 	Timestamp string = "TIMESTAMP"
 	// Numeric represent NUMERIC type.
 	Numeric string = "NUMERIC"
+	// JSON represent JSON type.
+	JSON string = "JSON"
+	// Float32 represent FLOAT32 type.
+	Float32 string = "FLOAT32"
 	// MaxLength is a sentinel for Type's Len field, representing the MAX value.
 	MaxLength = math.MaxInt64
 )
 
 // Type represents the type of a column.
 //     type:
-//        { BOOL | INT64 | FLOAT64 | STRING( length ) | BYTES( length ) | DATE | TIMESTAMP | NUMERIC }
+//        { BOOL | INT64 | FLOAT32 | FLOAT64 | STRING( length ) | BYTES( length ) | DATE | TIMESTAMP | NUMERIC | JSON }
 type Type struct {
 	Name string
 	// Len encodes the following Spanner DDL definition:
@@ -81,6 +85,82 @@ func (ty Type) PrintColumnDefType() string {
 	return str
 }
 
+// ParseColumnDefType parses a type as printed by PrintColumnDefType (and
+// as reported by the SPANNER_TYPE column of Spanner's INFORMATION_SCHEMA).
+func ParseColumnDefType(s string) (Type, error) {
+	var ty Type
+	str := strings.ToUpper(strings.Replace(s, " ", "", -1))
+	if strings.HasPrefix(str, "ARRAY<") && strings.HasSuffix(str, ">") {
+		ty.IsArray = true
+		str = str[len("ARRAY<") : len(str)-1]
+	}
+	if i := strings.Index(str, "("); i >= 0 && strings.HasSuffix(str, ")") {
+		l := str[i+1 : len(str)-1]
+		str = str[:i]
+		if l == "MAX" {
+			ty.Len = MaxLength
+		} else {
+			n, err := strconv.ParseInt(l, 10, 64)
+			if err != nil || n <= 0 {
+				return Type{}, fmt.Errorf("bad length in type %q", s)
+			}
+			ty.Len = n
+		}
+	}
+	switch str {
+	case String, Bytes:
+		if ty.Len == 0 {
+			return Type{}, fmt.Errorf("missing length in type %q", s)
+		}
+	case Bool, Date, Float32, Float64, Int64, JSON, Numeric, Timestamp:
+		if ty.Len != 0 {
+			return Type{}, fmt.Errorf("unexpected length in type %q", s)
+		}
+	default:
+		return Type{}, fmt.Errorf("unsupported type %q", s)
+	}
+	ty.Name = str
+	return ty, nil
+}
+
+// PrintPGColumnDefType unparses the type encoded in a ColumnDef using the
+// type names of Spanner's PostgreSQL dialect.
+func (ty Type) PrintPGColumnDefType() string {
+	var str string
+	switch ty.Name {
+	case Bool:
+		str = "boolean"
+	case Bytes:
+		str = "bytea"
+	case Date:
+		str = "date"
+	case Float32:
+		str = "real"
+	case Float64:
+		str = "double precision"
+	case Int64:
+		str = "bigint"
+	case JSON:
+		str = "jsonb"
+	case Numeric:
+		str = "numeric"
+	case String:
+		if ty.Len == MaxLength {
+			str = "text"
+		} else {
+			str = "varchar(" + strconv.FormatInt(ty.Len, 10) + ")"
+		}
+	case Timestamp:
+		str = "timestamptz"
+	default:
+		str = strings.ToLower(ty.Name)
+	}
+	if ty.IsArray {
+		str += "[]"
+	}
+	return str
+}
+
 // ColumnDef encodes the following DDL definition:
 //     column_def:
 //       column_name type [NOT NULL] [options_def]
@@ -97,26 +177,65 @@ type Config struct {
 	ProtectIds  bool // If true, table and col names are quoted using backticks (avoids reserved-word issue).
 	Tables      bool // If true, print tables
 	ForeignKeys bool // If true, print foreign key constraints.
+	// If true (and Comments is), print table comments but not column
+	// comments, which give the names and types of source columns.
+	TableCommentsOnly bool
+	// Order is the order in which tables are printed. Tables not in
+	// Order are printed after those in Order, in alphabetical order.
+	Order []string
+	// If true, print the indexes and foreign keys of each table in
+	// alphabetical order, rather than in the order they are defined.
+	SortConstraints bool
+	// If true, print DDL in the syntax of Spanner's PostgreSQL dialect:
+	// PostgreSQL type names, identifiers quoted with double quotes and
+	// the primary key inside the column list.
+	PostgreSQL bool
 }
 
 func (c Config) quote(s string) string {
 	if c.ProtectIds {
+		if c.PostgreSQL {
+			return `"` + s + `"`
+		}
 		return "`" + s + "`"
 	}
 	return s
 }
 
+// printType unparses ty in the dialect specified by c.
+func (c Config) printType(ty Type) string {
+	if !c.PostgreSQL {
+		return ty.PrintColumnDefType()
+	}
+	return ty.PrintPGColumnDefType()
+}
+
 // PrintColumnDef unparses ColumnDef and returns it as well as any ColumnDef
 // comment. These are returned as separate strings to support formatting
 // needs of PrintCreateTable.
 func (cd ColumnDef) PrintColumnDef(c Config) (string, string) {
-	s := fmt.Sprintf("%s %s", c.quote(cd.Name), cd.T.PrintColumnDefType())
+	s := fmt.Sprintf("%s %s", c.quote(cd.Name), c.printType(cd.T))
 	if cd.NotNull {
 		s += " NOT NULL"
 	}
 	return s, cd.Comment
 }
 
+// PrintAddColumn unparses the statement adding column cd to table
+// tableName.
+func (cd ColumnDef) PrintAddColumn(c Config, tableName string) string {
+	s, _ := cd.PrintColumnDef(c)
+	return fmt.Sprintf("ALTER TABLE %s ADD COLUMN %s", c.quote(tableName), s)
+}
+
+// PrintAlterColumn unparses the statement changing the type and
+// nullability of column cd of table tableName to those of cd. It uses the
+// syntax of Spanner's GoogleSQL dialect.
+func (cd ColumnDef) PrintAlterColumn(c Config, tableName string) string {
+	s, _ := cd.PrintColumnDef(c)
+	return fmt.Sprintf("ALTER TABLE %s ALTER COLUMN %s", c.quote(tableName), s)
+}
+
 // IndexKey encodes the following DDL definition:
 //     primary_key:
 //       PRIMARY KEY ( [key_part, ...] )
@@ -182,7 +301,7 @@ func (ct CreateTable) PrintCreateTable(config Config) string {
 	for i, cn := range ct.ColNames {
 		s, c := ct.ColDefs[cn].PrintColumnDef(config)
 		s = "\n    " + s
-		if i < len(ct.ColNames)-1 {
+		if i < len(ct.ColNames)-1 || config.PostgreSQL {
 			s += ","
 		} else {
 			s += " "
@@ -194,7 +313,7 @@ func (ct CreateTable) PrintCreateTable(config Config) string {
 	var cols string
 	for i, c := range col {
 		cols += c
-		if config.Comments && len(colComment[i]) > 0 {
+		if config.Comments && !config.TableCommentsOnly && len(colComment[i]) > 0 {
 			cols += strings.Repeat(" ", n-len(c)) + " -- " + colComment[i]
 		}
 	}
@@ -205,6 +324,15 @@ func (ct CreateTable) PrintCreateTable(config Config) string {
 	if config.Comments && len(ct.Comment) > 0 {
 		tableComment = "--\n-- " + ct.Comment + "\n--\n"
 	}
+	if config.PostgreSQL {
+		// In the PostgreSQL dialect, the primary key is part of the
+		// column list and interleaving follows it.
+		var interleave string
+		if ct.Parent != "" {
+			interleave = " INTERLEAVE IN PARENT " + config.quote(ct.Parent)
+		}
+		return fmt.Sprintf("%sCREATE TABLE %s (%s\n    PRIMARY KEY (%s)\n)%s", tableComment, config.quote(ct.Name), cols, strings.Join(keys, ", "), interleave)
+	}
 	var interleave string
 	if ct.Parent != "" {
 		interleave = ",\nINTERLEAVE IN PARENT " + config.quote(ct.Parent)
@@ -250,6 +378,25 @@ func (k Foreignkey) PrintForeignKeyAlterTable(c Config, tableName string) string
 	return fmt.Sprintf("ALTER TABLE %s ADD %sFOREIGN KEY (%s) REFERENCES %s (%s)", c.quote(tableName), s, strings.Join(cols, ", "), c.quote(k.ReferTable), strings.Join(referCols, ", "))
 }
 
+// CreateSequence encodes the following DDL definition:
+//     create sequence: CREATE SEQUENCE sequence_name OPTIONS ( sequence_kind = 'bit_reversed_positive' [, start_with_counter = int64_value ] )
+// Spanner only supports bit-reversed sequences, which return positive
+// values that are spread across the key space (rather than consecutive
+// values). StartWithCounter is the value of the internal counter before
+// bit reversal.
+type CreateSequence struct {
+	Name             string
+	StartWithCounter int64
+}
+
+// PrintCreateSequence unparses a CREATE SEQUENCE statement.
+func (cs CreateSequence) PrintCreateSequence(c Config) string {
+	if c.PostgreSQL {
+		return fmt.Sprintf("CREATE SEQUENCE %s BIT_REVERSED_POSITIVE START COUNTER WITH %d", c.quote(cs.Name), cs.StartWithCounter)
+	}
+	return fmt.Sprintf("CREATE SEQUENCE %s OPTIONS (sequence_kind = 'bit_reversed_positive', start_with_counter = %d)", c.quote(cs.Name), cs.StartWithCounter)
+}
+
 type Schema map[string]CreateTable
 
 func NewSchema() Schema {
@@ -257,17 +404,40 @@ func NewSchema() Schema {
 }
 
 // GetDDL returns the string representation of Spanner schema represented by Schema struct.
-// Tables are printed in alphabetical order with one exception: interleaved
-// tables are potentially out of order since they must appear after the
-// definition of their parent table.
+// Tables are printed in the order specified by c.Order (alphabetical order
+// by default) with one exception: interleaved tables are potentially out
+// of order since they must appear after the definition of their parent table.
 func (s Schema) GetDDL(c Config) []string {
 	var ddl []string
-
-	var tableNames []string
-	for t := range s {
-		tableNames = append(tableNames, t)
+	for _, st := range s.GetStatements(c) {
+		ddl = append(ddl, st.DDL)
 	}
-	sort.Strings(tableNames)
+	return ddl
+}
+
+// Kinds of Statement.
+const (
+	TableStatement      = "table"
+	IndexStatement      = "index"
+	ForeignKeyStatement = "foreign key"
+	SequenceStatement   = "sequence"
+)
+
+// Statement is a DDL statement that creates a single schema object: a
+// table, an index, a foreign key constraint or a sequence.
+type Statement struct {
+	Kind  string // TableStatement, IndexStatement, ForeignKeyStatement or SequenceStatement.
+	Table string // Table the object belongs to.
+	Name  string // Name of the object (can be empty for foreign keys).
+	DDL   string
+}
+
+// GetStatements returns the statements of the Spanner schema, in the order
+// used by GetDDL (which is an order they can be applied in).
+func (s Schema) GetStatements(c Config) []Statement {
+	var ddl []Statement
+
+	tableNames := s.orderTables(c.Order)
 
 	if c.Tables {
 		tableQueue := tableNames
@@ -281,9 +451,9 @@ func (s Schema) GetDDL(c Config) []string {
 			// a) t is not interleaved in another table, or
 			// b) t is interleaved in another table and that table has already been printed.
 			if table.Parent == "" || printed[table.Parent] {
-				ddl = append(ddl, table.PrintCreateTable(c))
-				for _, index := range table.Indexes {
-					ddl = append(ddl, index.PrintCreateIndex(c))
+				ddl = append(ddl, Statement{TableStatement, tableName, tableName, table.PrintCreateTable(c)})
+				for _, index := range c.indexes(table) {
+					ddl = append(ddl, Statement{IndexStatement, tableName, index.Name, index.PrintCreateIndex(c)})
 				}
 				printed[tableName] = true
 			} else {
@@ -305,14 +475,55 @@ func (s Schema) GetDDL(c Config) []string {
 	// of circular foreign keys definitions. We opt for simplicity.
 	if c.ForeignKeys {
 		for _, t := range tableNames {
-			for _, fk := range s[t].Fks {
-				ddl = append(ddl, fk.PrintForeignKeyAlterTable(c, t))
+			for _, fk := range c.foreignKeys(s[t]) {
+				ddl = append(ddl, Statement{ForeignKeyStatement, t, fk.Name, fk.PrintForeignKeyAlterTable(c, t)})
 			}
 		}
 	}
 	return ddl
 }
 
+// orderTables returns the names of the tables of s: first those in
+// order, then the others in alphabetical order.
+func (s Schema) orderTables(order []string) []string {
+	var tableNames []string
+	printed := make(map[string]bool)
+	for _, t := range order {
+		if _, ok := s[t]; ok && !printed[t] {
+			tableNames = append(tableNames, t)
+			printed[t] = true
+		}
+	}
+	var rest []string
+	for t := range s {
+		if !printed[t] {
+			rest = append(rest, t)
+		}
+	}
+	sort.Strings(rest)
+	return append(tableNames, rest...)
+}
+
+// indexes returns the indexes of ct in the order they are printed.
+func (c Config) indexes(ct CreateTable) []CreateIndex {
+	if !c.SortConstraints {
+		return ct.Indexes
+	}
+	l := append([]CreateIndex{}, ct.Indexes...)
+	sort.SliceStable(l, func(i, j int) bool { return l[i].Name < l[j].Name })
+	return l
+}
+
+// foreignKeys returns the foreign keys of ct in the order they are printed.
+func (c Config) foreignKeys(ct CreateTable) []Foreignkey {
+	if !c.SortConstraints {
+		return ct.Fks
+	}
+	l := append([]Foreignkey{}, ct.Fks...)
+	sort.SliceStable(l, func(i, j int) bool { return l[i].Name < l[j].Name })
+	return l
+}
+
 // CheckInterleaved checks if schema contains interleaved tables.
 func (s Schema) CheckInterleaved() bool {
 	for _, table := range s {
@@ -323,6 +534,86 @@ func (s Schema) CheckInterleaved() bool {
 	return false
 }
 
+// IndexMutations returns the number of additional mutations Spanner
+// counts for each row inserted into table because of the table's
+// secondary indexes. Each index entry counts one mutation per column:
+// the index keys plus any primary key columns not in the index keys.
+// This includes the backing indexes Spanner creates for foreign keys,
+// unless they can use the primary key or an existing index. Since
+// foreign keys are usually added after data conversion, this is an
+// overestimate for most data conversions, which is safe.
+func (s Schema) IndexMutations(table string) int64 {
+	ct, ok := s[table]
+	if !ok {
+		return 0
+	}
+	var pkCols []string
+	for _, k := range ct.Pks {
+		pkCols = append(pkCols, k.Col)
+	}
+	entrySize := func(cols []string) int64 {
+		n := int64(len(cols))
+		for _, pk := range pkCols {
+			if !containsString(cols, pk) {
+				n++
+			}
+		}
+		return n
+	}
+	var n int64
+	var indexes [][]string
+	for _, index := range ct.Indexes {
+		var cols []string
+		for _, k := range index.Keys {
+			cols = append(cols, k.Col)
+		}
+		indexes = append(indexes, cols)
+		n += entrySize(cols)
+	}
+	// Backing index for foreign keys from table.
+	for _, fk := range ct.Fks {
+		covered := isPrefix(fk.Columns, pkCols)
+		for _, cols := range indexes {
+			covered = covered || isPrefix(fk.Columns, cols)
+		}
+		if !covered {
+			n += entrySize(fk.Columns)
+		}
+	}
+	// Backing index for foreign keys referencing table. These aren't
+	// needed if the foreign key references the primary key.
+	for _, other := range s {
+		for _, fk := range other.Fks {
+			if fk.ReferTable == table && !(len(fk.ReferColumns) == len(pkCols) && isPrefix(fk.ReferColumns, pkCols)) {
+				n += entrySize(fk.ReferColumns)
+			}
+		}
+	}
+	return n
+}
+
+// isPrefix returns true if l is a non-empty prefix of m.
+func isPrefix(l, m []string) bool {
+	if len(l) == 0 || len(l) > len(m) {
+		return false
+	}
+	for i := range l {
+		if l[i] != m[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func containsString(l []string, s string) bool {
+	for _, x := range l {
+		if x == s {
+			return true
+		}
+	}
+	return false
+}
+
 func maxStringLength(s []string) int {
 	n := 0
 	for _, x := range s {
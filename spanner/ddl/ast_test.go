@@ -28,6 +28,7 @@ func TestPrintScalarType(t *testing.T) {
 	}{
 		{Type{Name: Bool}, "BOOL"},
 		{Type{Name: Int64}, "INT64"},
+		{Type{Name: Float32}, "FLOAT32"},
 		{Type{Name: Float64}, "FLOAT64"},
 		{Type{Name: String, Len: MaxLength}, "STRING(MAX)"},
 		{Type{Name: String, Len: int64(42)}, "STRING(42)"},
@@ -35,12 +36,33 @@ func TestPrintScalarType(t *testing.T) {
 		{Type{Name: Bytes, Len: int64(42)}, "BYTES(42)"},
 		{Type{Name: Date}, "DATE"},
 		{Type{Name: Timestamp}, "TIMESTAMP"},
+		{Type{Name: JSON}, "JSON"},
 	}
 	for _, tc := range tests {
 		assert.Equal(t, normalizeSpace(tc.expected), normalizeSpace(tc.in.PrintColumnDefType()))
 	}
 }
 
+func TestParseColumnDefType(t *testing.T) {
+	for _, ty := range []Type{
+		{Name: Bool},
+		{Name: Int64},
+		{Name: Numeric},
+		{Name: String, Len: MaxLength},
+		{Name: String, Len: 42},
+		{Name: Bytes, Len: 32, IsArray: true},
+		{Name: Timestamp, IsArray: true},
+	} {
+		parsed, err := ParseColumnDefType(ty.PrintColumnDefType())
+		assert.Nil(t, err)
+		assert.Equal(t, ty, parsed)
+	}
+	for _, s := range []string{"STRING", "INT64(10)", "STRING(0)", "STRUCT<a INT64>", "ARRAY<STRING(x)>"} {
+		_, err := ParseColumnDefType(s)
+		assert.NotNil(t, err, s)
+	}
+}
+
 func TestPrintColumnDef(t *testing.T) {
 	tests := []struct {
 		in         ColumnDef
@@ -59,6 +81,15 @@ func TestPrintColumnDef(t *testing.T) {
 	}
 }
 
+func TestPrintAlterTableColumn(t *testing.T) {
+	cd := ColumnDef{Name: "col1", T: Type{Name: String, Len: 20}, NotNull: true}
+	assert.Equal(t, "ALTER TABLE table1 ADD COLUMN col1 STRING(20) NOT NULL", cd.PrintAddColumn(Config{}, "table1"))
+	assert.Equal(t, "ALTER TABLE `table1` ADD COLUMN `col1` STRING(20) NOT NULL", cd.PrintAddColumn(Config{ProtectIds: true}, "table1"))
+	cd.T.Len = MaxLength
+	cd.NotNull = false
+	assert.Equal(t, "ALTER TABLE `table1` ALTER COLUMN `col1` STRING(MAX)", cd.PrintAlterColumn(Config{ProtectIds: true}, "table1"))
+}
+
 func TestPrintIndexKey(t *testing.T) {
 	tests := []struct {
 		in         IndexKey
@@ -114,6 +145,52 @@ func TestPrintCreateTable(t *testing.T) {
 	}
 }
 
+func TestPrintCreateTable_Comments(t *testing.T) {
+	ct := CreateTable{
+		Name:     "mytable",
+		ColNames: []string{"col1", "col2"},
+		ColDefs: map[string]ColumnDef{
+			"col1": {Name: "col1", T: Type{Name: Int64}, NotNull: true, Comment: "From: id bigint"},
+			"col2": {Name: "col2", T: Type{Name: String, Len: MaxLength}},
+		},
+		Pks:     []IndexKey{{Col: "col1"}},
+		Comment: "Spanner schema for source table mytable",
+	}
+	assert.Equal(t, "--\n-- Spanner schema for source table mytable\n--\nCREATE TABLE mytable (\n    col1 INT64 NOT NULL, -- From: id bigint\n    col2 STRING(MAX) \n) PRIMARY KEY (col1)", ct.PrintCreateTable(Config{Comments: true}))
+	assert.Equal(t, "--\n-- Spanner schema for source table mytable\n--\nCREATE TABLE mytable (\n    col1 INT64 NOT NULL,\n    col2 STRING(MAX) \n) PRIMARY KEY (col1)", ct.PrintCreateTable(Config{Comments: true, TableCommentsOnly: true}))
+	assert.Equal(t, "CREATE TABLE mytable (\n    col1 INT64 NOT NULL,\n    col2 STRING(MAX) \n) PRIMARY KEY (col1)", ct.PrintCreateTable(Config{}))
+}
+
+func TestPrintCreateTable_PostgreSQL(t *testing.T) {
+	ct := CreateTable{
+		Name:     "mytable",
+		ColNames: []string{"col1", "col2", "col3", "col4", "col5"},
+		ColDefs: map[string]ColumnDef{
+			"col1": {Name: "col1", T: Type{Name: Int64}, NotNull: true},
+			"col2": {Name: "col2", T: Type{Name: String, Len: MaxLength}},
+			"col3": {Name: "col3", T: Type{Name: String, Len: 42}},
+			"col4": {Name: "col4", T: Type{Name: Timestamp}},
+			"col5": {Name: "col5", T: Type{Name: Float64, IsArray: true}},
+		},
+		Pks:    []IndexKey{{Col: "col1", Desc: true}},
+		Parent: "parent",
+	}
+	assert.Equal(t,
+		"CREATE TABLE \"mytable\" (\n"+
+			"    \"col1\" bigint NOT NULL,\n"+
+			"    \"col2\" text,\n"+
+			"    \"col3\" varchar(42),\n"+
+			"    \"col4\" timestamptz,\n"+
+			"    \"col5\" double precision[],\n"+
+			"    PRIMARY KEY (\"col1\" DESC)\n"+
+			") INTERLEAVE IN PARENT \"parent\"",
+		ct.PrintCreateTable(Config{ProtectIds: true, PostgreSQL: true}))
+	assert.Equal(t, "bytea", Type{Name: Bytes, Len: MaxLength}.PrintPGColumnDefType())
+	assert.Equal(t, "boolean", Type{Name: Bool}.PrintPGColumnDefType())
+	assert.Equal(t, "real", Type{Name: Float32}.PrintPGColumnDefType())
+	assert.Equal(t, "jsonb", Type{Name: JSON}.PrintPGColumnDefType())
+}
+
 func TestPrintCreateIndex(t *testing.T) {
 	ci := []CreateIndex{
 		{
@@ -143,6 +220,12 @@ func TestPrintCreateIndex(t *testing.T) {
 	}
 }
 
+func TestPrintCreateSequence(t *testing.T) {
+	cs := CreateSequence{Name: "myseq", StartWithCounter: 43}
+	assert.Equal(t, "CREATE SEQUENCE `myseq` OPTIONS (sequence_kind = 'bit_reversed_positive', start_with_counter = 43)", cs.PrintCreateSequence(Config{ProtectIds: true}))
+	assert.Equal(t, `CREATE SEQUENCE "myseq" BIT_REVERSED_POSITIVE START COUNTER WITH 43`, cs.PrintCreateSequence(Config{ProtectIds: true, PostgreSQL: true}))
+}
+
 func TestPrintForeignKey(t *testing.T) {
 	fk := []Foreignkey{
 		{
@@ -272,6 +355,57 @@ func TestGetDDL(t *testing.T) {
 	assert.ElementsMatch(t, e3, tablesAndFks)
 }
 
+func TestGetDDL_Order(t *testing.T) {
+	s := NewSchema()
+	table := func(name, parent string, indexes ...string) CreateTable {
+		ct := CreateTable{
+			Name:     name,
+			ColNames: []string{"a"},
+			ColDefs:  map[string]ColumnDef{"a": {Name: "a", T: Type{Name: Int64}}},
+			Pks:      []IndexKey{{Col: "a"}},
+			Parent:   parent,
+		}
+		for _, i := range indexes {
+			ct.Indexes = append(ct.Indexes, CreateIndex{Name: i, Table: name, Keys: []IndexKey{{Col: "a"}}})
+		}
+		return ct
+	}
+	s["b"] = table("b", "", "b_idx2", "b_idx1")
+	s["a"] = table("a", "b")
+	s["c"] = table("c", "")
+	ddl := s.GetDDL(Config{Tables: true, Order: []string{"a", "b"}})
+	var names []string
+	for _, d := range ddl {
+		names = append(names, strings.Fields(d)[2])
+	}
+	// a is interleaved in b, so it is deferred until b has been printed.
+	assert.Equal(t, []string{"b", "b_idx2", "b_idx1", "c", "a"}, names)
+
+	ddl = s.GetDDL(Config{Tables: true, SortConstraints: true})
+	assert.Equal(t, "CREATE INDEX b_idx1 ON b (a)", ddl[1])
+	assert.Equal(t, "CREATE INDEX b_idx2 ON b (a)", ddl[2])
+}
+
+func TestGetStatements(t *testing.T) {
+	s := NewSchema()
+	s["t"] = CreateTable{
+		Name:     "t",
+		ColNames: []string{"a", "b"},
+		ColDefs: map[string]ColumnDef{
+			"a": {Name: "a", T: Type{Name: Int64}},
+			"b": {Name: "b", T: Type{Name: Int64}},
+		},
+		Pks:     []IndexKey{{Col: "a"}},
+		Fks:     []Foreignkey{{Columns: []string{"b"}, ReferTable: "t", ReferColumns: []string{"a"}}},
+		Indexes: []CreateIndex{{Name: "t_b", Table: "t", Keys: []IndexKey{{Col: "b"}}}},
+	}
+	assert.Equal(t, []Statement{
+		{TableStatement, "t", "t", "CREATE TABLE t (\n    a INT64,\n    b INT64 \n) PRIMARY KEY (a)"},
+		{IndexStatement, "t", "t_b", "CREATE INDEX t_b ON t (b)"},
+		{ForeignKeyStatement, "t", "", "ALTER TABLE t ADD FOREIGN KEY (b) REFERENCES t (a)"},
+	}, s.GetStatements(Config{Tables: true, ForeignKeys: true}))
+}
+
 func normalizeSpace(s string) string {
 	// Insert whitespace around parenthesis and commas.
 	s = strings.ReplaceAll(s, ")", " ) ")
@@ -279,3 +413,26 @@ func normalizeSpace(s string) string {
 	s = strings.ReplaceAll(s, ",", " , ")
 	return strings.Join(strings.Fields(s), " ")
 }
+
+func TestIndexMutations(t *testing.T) {
+	s := Schema{
+		"a": CreateTable{
+			Name:     "a",
+			ColNames: []string{"id", "x", "y"},
+			Pks:      []IndexKey{IndexKey{Col: "id"}},
+			Indexes:  []CreateIndex{CreateIndex{Name: "ax", Table: "a", Keys: []IndexKey{IndexKey{Col: "x"}}}}},
+		"b": CreateTable{
+			Name:     "b",
+			ColNames: []string{"id", "aid", "ax", "ay"},
+			Pks:      []IndexKey{IndexKey{Col: "id"}},
+			Fks: []Foreignkey{
+				Foreignkey{Name: "fk1", Columns: []string{"aid"}, ReferTable: "a", ReferColumns: []string{"id"}},
+				Foreignkey{Name: "fk2", Columns: []string{"ay"}, ReferTable: "a", ReferColumns: []string{"y"}},
+				Foreignkey{Name: "fk3", Columns: []string{"id"}, ReferTable: "a", ReferColumns: []string{"id"}}}},
+	}
+	// Table a: index ax (x, id), plus backing index for fk2's referenced column (y, id).
+	assert.Equal(t, int64(4), s.IndexMutations("a"))
+	// Table b: backing indexes for fk1 (aid, id) and fk2 (ay, id); fk3 uses the primary key.
+	assert.Equal(t, int64(4), s.IndexMutations("b"))
+	assert.Equal(t, int64(0), s.IndexMutations("c"))
+}
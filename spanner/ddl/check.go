@@ -0,0 +1,638 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ddl
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Limits Spanner enforces when it parses DDL.
+const (
+	// MaxNameLength is the maximum length of the name of a table, column,
+	// index, constraint or sequence.
+	MaxNameLength = 128
+	// MaxStringLength is the maximum length of a STRING(n) column.
+	MaxStringLength = 2621440
+	// MaxBytesLength is the maximum length of a BYTES(n) column.
+	MaxBytesLength = 10485760
+)
+
+var nameRegexp = regexp.MustCompile("^[a-zA-Z][a-zA-Z0-9_]*$")
+
+// reservedWords are the reserved keywords of Spanner's GoogleSQL dialect,
+// which can't be used as unquoted names.
+var reservedWords = wordSet(`ALL AND ANY ARRAY AS ASC ASSERT_ROWS_MODIFIED AT
+	BETWEEN BY CASE CAST COLLATE CONTAINS CREATE CROSS CUBE CURRENT DEFAULT
+	DEFINE DESC DISTINCT ELSE END ENUM ESCAPE EXCEPT EXCLUDE EXISTS EXTRACT
+	FALSE FETCH FOLLOWING FOR FROM FULL GROUP GROUPING GROUPS HASH HAVING IF
+	IGNORE IN INNER INTERSECT INTERVAL INTO IS JOIN LATERAL LEFT LIKE LIMIT
+	LOOKUP MERGE NATURAL NEW NO NOT NULL NULLS OF ON OR ORDER OUTER OVER
+	PARTITION PRECEDING PROTO RANGE RECURSIVE RESPECT RIGHT ROLLUP ROWS SELECT
+	SET SOME STRUCT TABLESAMPLE THEN TO TREAT TRUE UNBOUNDED UNION UNNEST
+	USING WHEN WHERE WINDOW WITH WITHIN`)
+
+// pgReservedWords are the reserved keywords of Spanner's PostgreSQL
+// dialect.
+var pgReservedWords = wordSet(`ALL ANALYSE ANALYZE AND ANY ARRAY AS ASC
+	ASYMMETRIC BOTH CASE CAST CHECK COLLATE COLUMN CONSTRAINT CREATE
+	CURRENT_CATALOG CURRENT_DATE CURRENT_ROLE CURRENT_TIME CURRENT_TIMESTAMP
+	CURRENT_USER DEFAULT DEFERRABLE DESC DISTINCT DO ELSE END EXCEPT FALSE
+	FETCH FOR FOREIGN FROM GRANT GROUP HAVING IN INITIALLY INTERSECT INTO
+	LATERAL LEADING LIMIT LOCALTIME LOCALTIMESTAMP NOT NULL OFFSET ON ONLY OR
+	ORDER PLACING PRIMARY REFERENCES RETURNING SELECT SESSION_USER SOME
+	SYMMETRIC TABLE THEN TO TRAILING TRUE UNION UNIQUE USER USING VARIADIC
+	WHEN WHERE WINDOW WITH`)
+
+func wordSet(s string) map[string]bool {
+	m := make(map[string]bool)
+	for _, w := range strings.Fields(s) {
+		m[w] = true
+	}
+	return m
+}
+
+// SyntaxError is an error found by CheckSyntax.
+type SyntaxError struct {
+	Pos    int    // Byte offset in the statement of the token the error was found at.
+	Column string // Column whose definition contains the error, if any.
+	Msg    string
+}
+
+func (e *SyntaxError) Error() string {
+	return fmt.Sprintf("syntax error at position %d: %s", e.Pos, e.Msg)
+}
+
+// CheckSyntax checks that stmt is a valid DDL statement of the kinds
+// HarbourBridge generates (CREATE TABLE, CREATE INDEX, CREATE SEQUENCE,
+// ALTER TABLE and DROP statements), in Spanner's GoogleSQL dialect, or its
+// PostgreSQL dialect if postgreSQL is set. It also checks the rules that
+// Spanner applies when parsing DDL: names must be valid and no longer
+// than MaxNameLength, reserved words must be quoted, and types must exist
+// and have valid lengths. Errors are *SyntaxError.
+func CheckSyntax(stmt string, postgreSQL bool) error {
+	toks, err := tokenize(stmt, postgreSQL)
+	if err != nil {
+		return err
+	}
+	p := &parser{toks: toks, pg: postgreSQL}
+	if err := p.statement(); err != nil {
+		return err
+	}
+	p.symbol(";")
+	if t := p.peek(); t.kind != eofToken {
+		return p.errorf("unexpected %s after end of statement", t)
+	}
+	return nil
+}
+
+type tokenKind int
+
+const (
+	eofToken tokenKind = iota
+	identToken
+	numberToken
+	stringToken
+	symbolToken
+)
+
+type token struct {
+	kind   tokenKind
+	text   string // Identifiers are unquoted.
+	quoted bool   // Quoted identifier.
+	pos    int
+}
+
+func (t token) String() string {
+	switch t.kind {
+	case eofToken:
+		return "end of statement"
+	case stringToken:
+		return "string " + t.text
+	}
+	return fmt.Sprintf("%q", t.text)
+}
+
+func tokenize(s string, postgreSQL bool) ([]token, error) {
+	quote := byte('`')
+	if postgreSQL {
+		quote = '"'
+	}
+	var toks []token
+	for i := 0; i < len(s); {
+		c := s[i]
+		switch {
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r':
+			i++
+		case c == '-' && i+1 < len(s) && s[i+1] == '-':
+			for i < len(s) && s[i] != '\n' {
+				i++
+			}
+		case c == '_' || isLetter(c):
+			j := i
+			for j < len(s) && (s[j] == '_' || isLetter(s[j]) || isDigit(s[j])) {
+				j++
+			}
+			toks = append(toks, token{kind: identToken, text: s[i:j], pos: i})
+			i = j
+		case isDigit(c):
+			j := i
+			for j < len(s) && isDigit(s[j]) {
+				j++
+			}
+			toks = append(toks, token{kind: numberToken, text: s[i:j], pos: i})
+			i = j
+		case c == quote || c == '\'' || c == '"':
+			j := i + 1
+			var b strings.Builder
+			for ; j < len(s); j++ {
+				if s[j] == '\\' && c != quote && !postgreSQL && j+1 < len(s) {
+					j++
+				} else if s[j] == c {
+					if j+1 < len(s) && s[j+1] == c && postgreSQL {
+						j++ // Doubled quote.
+					} else {
+						break
+					}
+				}
+				b.WriteByte(s[j])
+			}
+			if j >= len(s) {
+				return nil, &SyntaxError{Pos: i, Msg: "unterminated quoted string or name"}
+			}
+			if c != quote {
+				toks = append(toks, token{kind: stringToken, text: s[i : j+1], pos: i})
+			} else {
+				toks = append(toks, token{kind: identToken, text: b.String(), quoted: true, pos: i})
+			}
+			i = j + 1
+		case strings.IndexByte("(),<>=;.[]-+*/", c) >= 0:
+			toks = append(toks, token{kind: symbolToken, text: string(c), pos: i})
+			i++
+		default:
+			return nil, &SyntaxError{Pos: i, Msg: fmt.Sprintf("unexpected character %q", c)}
+		}
+	}
+	return append(toks, token{kind: eofToken, pos: len(s)}), nil
+}
+
+func isLetter(c byte) bool { return 'a' <= c && c <= 'z' || 'A' <= c && c <= 'Z' }
+
+func isDigit(c byte) bool { return '0' <= c && c <= '9' }
+
+type parser struct {
+	toks []token
+	i    int
+	pg   bool
+	col  string // Column being defined.
+}
+
+func (p *parser) peek() token { return p.toks[p.i] }
+
+// atEnd returns true at the end of the statement.
+func (p *parser) atEnd() bool {
+	t := p.peek()
+	return t.kind == eofToken || t.kind == symbolToken && t.text == ";"
+}
+
+func (p *parser) errorf(format string, args ...interface{}) error {
+	return &SyntaxError{Pos: p.peek().pos, Column: p.col, Msg: fmt.Sprintf(format, args...)}
+}
+
+// keyword consumes the next tokens if they are the unquoted keywords kws.
+func (p *parser) keyword(kws ...string) bool {
+	if p.i+len(kws) >= len(p.toks) {
+		return false
+	}
+	for i, kw := range kws {
+		t := p.toks[p.i+i]
+		if t.kind != identToken || t.quoted || !strings.EqualFold(t.text, kw) {
+			return false
+		}
+	}
+	p.i += len(kws)
+	return true
+}
+
+func (p *parser) expectKeyword(kws ...string) error {
+	if !p.keyword(kws...) {
+		return p.errorf("expected %s, found %s", strings.Join(kws, " "), p.peek())
+	}
+	return nil
+}
+
+// symbol consumes the next token if it is symbol s.
+func (p *parser) symbol(s string) bool {
+	if t := p.peek(); t.kind == symbolToken && t.text == s {
+		p.i++
+		return true
+	}
+	return false
+}
+
+func (p *parser) expectSymbol(s string) error {
+	if !p.symbol(s) {
+		return p.errorf("expected %q, found %s", s, p.peek())
+	}
+	return nil
+}
+
+// name consumes a name.
+func (p *parser) name() (string, error) {
+	t := p.peek()
+	if t.kind != identToken {
+		return "", p.errorf("expected a name, found %s", t)
+	}
+	reserved := reservedWords
+	if p.pg {
+		reserved = pgReservedWords
+	}
+	if !t.quoted && reserved[strings.ToUpper(t.text)] {
+		return "", p.errorf("%s is a reserved word, and must be quoted to be used as a name", t.text)
+	}
+	if !nameRegexp.MatchString(t.text) {
+		return "", p.errorf("invalid name %q: names must start with a letter, and only contain letters, digits and underscores", t.text)
+	}
+	if len(t.text) > MaxNameLength {
+		return "", p.errorf("name %s is longer than %d characters", t.text, MaxNameLength)
+	}
+	p.i++
+	return t.text, nil
+}
+
+// names consumes a parenthesized list of names.
+func (p *parser) names() error {
+	if err := p.expectSymbol("("); err != nil {
+		return err
+	}
+	for {
+		if _, err := p.name(); err != nil {
+			return err
+		}
+		if !p.symbol(",") {
+			return p.expectSymbol(")")
+		}
+	}
+}
+
+// skipParens consumes a parenthesized expression, which isn't checked.
+func (p *parser) skipParens() error {
+	if err := p.expectSymbol("("); err != nil {
+		return err
+	}
+	for depth := 1; depth > 0; {
+		t := p.peek()
+		switch {
+		case t.kind == eofToken:
+			return p.errorf("missing \")\"")
+		case t.kind == symbolToken && t.text == "(":
+			depth++
+		case t.kind == symbolToken && t.text == ")":
+			depth--
+		}
+		p.i++
+	}
+	return nil
+}
+
+func (p *parser) statement() error {
+	switch {
+	case p.keyword("CREATE", "TABLE"):
+		return p.createTable()
+	case p.keyword("CREATE", "SEQUENCE"):
+		return p.createSequence()
+	case p.keyword("CREATE"):
+		return p.createIndex()
+	case p.keyword("ALTER", "TABLE"):
+		return p.alterTable()
+	case p.keyword("DROP", "TABLE"), p.keyword("DROP", "INDEX"), p.keyword("DROP", "SEQUENCE"):
+		_, err := p.name()
+		return err
+	}
+	return p.errorf("expected CREATE, ALTER or DROP, found %s", p.peek())
+}
+
+func (p *parser) createTable() error {
+	if _, err := p.name(); err != nil {
+		return err
+	}
+	if err := p.expectSymbol("("); err != nil {
+		return err
+	}
+	pk := false
+	for !p.symbol(")") {
+		switch {
+		case p.pg && p.keyword("PRIMARY", "KEY"):
+			if err := p.keyParts(); err != nil {
+				return err
+			}
+			pk = true
+		case p.keyword("CONSTRAINT"):
+			if _, err := p.name(); err != nil {
+				return err
+			}
+			if err := p.constraint(); err != nil {
+				return err
+			}
+		case p.peek().kind == identToken && !p.peek().quoted && (strings.EqualFold(p.peek().text, "FOREIGN") || strings.EqualFold(p.peek().text, "CHECK")):
+			if err := p.constraint(); err != nil {
+				return err
+			}
+		default:
+			if err := p.columnDef(); err != nil {
+				return err
+			}
+		}
+		if !p.symbol(",") && !(p.peek().kind == symbolToken && p.peek().text == ")") {
+			return p.errorf("expected \",\" or \")\", found %s", p.peek())
+		}
+	}
+	if p.pg {
+		if !pk {
+			return p.errorf("missing PRIMARY KEY")
+		}
+		if p.atEnd() {
+			return nil
+		}
+	} else {
+		if err := p.expectKeyword("PRIMARY", "KEY"); err != nil {
+			return err
+		}
+		if err := p.keyParts(); err != nil {
+			return err
+		}
+		if !p.symbol(",") {
+			return nil
+		}
+	}
+	if err := p.expectKeyword("INTERLEAVE", "IN", "PARENT"); err != nil {
+		return err
+	}
+	if _, err := p.name(); err != nil {
+		return err
+	}
+	if p.keyword("ON", "DELETE") {
+		if !p.keyword("CASCADE") && !p.keyword("NO", "ACTION") {
+			return p.errorf("expected CASCADE or NO ACTION, found %s", p.peek())
+		}
+	}
+	return nil
+}
+
+// constraint consumes a FOREIGN KEY or CHECK constraint.
+func (p *parser) constraint() error {
+	if p.keyword("CHECK") {
+		return p.skipParens()
+	}
+	if err := p.expectKeyword("FOREIGN", "KEY"); err != nil {
+		return err
+	}
+	if err := p.names(); err != nil {
+		return err
+	}
+	if err := p.expectKeyword("REFERENCES"); err != nil {
+		return err
+	}
+	if _, err := p.name(); err != nil {
+		return err
+	}
+	if err := p.names(); err != nil {
+		return err
+	}
+	if p.keyword("ON", "DELETE") {
+		if !p.keyword("CASCADE") && !p.keyword("NO", "ACTION") {
+			return p.errorf("expected CASCADE or NO ACTION, found %s", p.peek())
+		}
+	}
+	return nil
+}
+
+func (p *parser) keyParts() error {
+	if err := p.expectSymbol("("); err != nil {
+		return err
+	}
+	if p.symbol(")") {
+		return nil // Tables can have an empty primary key.
+	}
+	for {
+		if _, err := p.name(); err != nil {
+			return err
+		}
+		if !p.keyword("ASC") {
+			p.keyword("DESC")
+		}
+		if !p.symbol(",") {
+			return p.expectSymbol(")")
+		}
+	}
+}
+
+func (p *parser) columnDef() error {
+	if t := p.peek(); t.kind == identToken {
+		p.col = t.text
+	}
+	name, err := p.name()
+	if err != nil {
+		return err
+	}
+	p.col = name
+	if err := p.columnType(); err != nil {
+		return err
+	}
+	for {
+		switch {
+		case p.keyword("NOT", "NULL"):
+		case p.keyword("DEFAULT"), p.keyword("AS"):
+			if err := p.skipParens(); err != nil {
+				return err
+			}
+			p.keyword("STORED")
+		case !p.pg && p.keyword("OPTIONS"):
+			if err := p.skipParens(); err != nil {
+				return err
+			}
+		default:
+			p.col = ""
+			return nil
+		}
+	}
+}
+
+func (p *parser) columnType() error {
+	if p.pg {
+		return p.pgColumnType()
+	}
+	if p.keyword("ARRAY") {
+		if err := p.expectSymbol("<"); err != nil {
+			return err
+		}
+		if err := p.scalarType(); err != nil {
+			return err
+		}
+		return p.expectSymbol(">")
+	}
+	return p.scalarType()
+}
+
+func (p *parser) scalarType() error {
+	t := p.peek()
+	if t.kind != identToken || t.quoted {
+		return p.errorf("expected a type, found %s", t)
+	}
+	switch ty := strings.ToUpper(t.text); ty {
+	case Bool, Int64, Float32, Float64, Numeric, JSON, Date, Timestamp:
+		p.i++
+		return nil
+	case String, Bytes:
+		p.i++
+		max := int64(MaxStringLength)
+		if ty == Bytes {
+			max = MaxBytesLength
+		}
+		if err := p.expectSymbol("("); err != nil {
+			return err
+		}
+		if err := p.length(ty, max); err != nil {
+			return err
+		}
+		return p.expectSymbol(")")
+	}
+	return p.errorf("unknown type %s", t.text)
+}
+
+// length consumes the length of a STRING or BYTES type.
+func (p *parser) length(ty string, max int64) error {
+	if !p.pg && p.keyword("MAX") {
+		return nil
+	}
+	t := p.peek()
+	if t.kind != numberToken {
+		return p.errorf("expected the length of %s, found %s", ty, t)
+	}
+	n, err := strconv.ParseInt(t.text, 10, 64)
+	if err != nil || n < 1 || n > max {
+		return p.errorf("invalid length %s for %s (expected 1 to %d)", t.text, ty, max)
+	}
+	p.i++
+	return nil
+}
+
+func (p *parser) pgColumnType() error {
+	switch {
+	case p.keyword("boolean"), p.keyword("bool"), p.keyword("bytea"), p.keyword("date"),
+		p.keyword("real"), p.keyword("float4"), p.keyword("double", "precision"), p.keyword("float8"),
+		p.keyword("bigint"), p.keyword("int8"), p.keyword("jsonb"), p.keyword("numeric"), p.keyword("text"),
+		p.keyword("timestamptz"), p.keyword("timestamp", "with", "time", "zone"):
+	case p.keyword("varchar"), p.keyword("character", "varying"):
+		if p.symbol("(") {
+			if err := p.length("varchar", MaxStringLength); err != nil {
+				return err
+			}
+			if err := p.expectSymbol(")"); err != nil {
+				return err
+			}
+		}
+	default:
+		return p.errorf("unknown type %s", p.peek())
+	}
+	if p.symbol("[") {
+		return p.expectSymbol("]")
+	}
+	return nil
+}
+
+func (p *parser) createIndex() error {
+	p.keyword("UNIQUE")
+	if !p.pg {
+		p.keyword("NULL_FILTERED")
+	}
+	if err := p.expectKeyword("INDEX"); err != nil {
+		return err
+	}
+	if _, err := p.name(); err != nil {
+		return err
+	}
+	if err := p.expectKeyword("ON"); err != nil {
+		return err
+	}
+	if _, err := p.name(); err != nil {
+		return err
+	}
+	if err := p.keyParts(); err != nil {
+		return err
+	}
+	if p.keyword("STORING") || p.pg && p.keyword("INCLUDE") {
+		if err := p.names(); err != nil {
+			return err
+		}
+	}
+	if p.symbol(",") || p.pg {
+		if p.keyword("INTERLEAVE", "IN") {
+			_, err := p.name()
+			return err
+		}
+		if !p.pg {
+			return p.errorf("expected INTERLEAVE IN, found %s", p.peek())
+		}
+	}
+	return nil
+}
+
+func (p *parser) createSequence() error {
+	if _, err := p.name(); err != nil {
+		return err
+	}
+	if p.pg {
+		if err := p.expectKeyword("BIT_REVERSED_POSITIVE"); err != nil {
+			return err
+		}
+		if p.keyword("START", "COUNTER", "WITH") {
+			if p.peek().kind != numberToken {
+				return p.errorf("expected a number, found %s", p.peek())
+			}
+			p.i++
+		}
+		return nil
+	}
+	if err := p.expectKeyword("OPTIONS"); err != nil {
+		return err
+	}
+	return p.skipParens()
+}
+
+func (p *parser) alterTable() error {
+	if _, err := p.name(); err != nil {
+		return err
+	}
+	switch {
+	case p.keyword("ADD", "COLUMN"):
+		return p.columnDef()
+	case p.keyword("ALTER", "COLUMN"):
+		return p.columnDef()
+	case p.keyword("DROP", "COLUMN"), p.keyword("DROP", "CONSTRAINT"):
+		_, err := p.name()
+		return err
+	case p.keyword("ADD"):
+		if p.keyword("CONSTRAINT") {
+			if _, err := p.name(); err != nil {
+				return err
+			}
+		}
+		return p.constraint()
+	}
+	return p.errorf("expected ADD, ALTER COLUMN or DROP, found %s", p.peek())
+}
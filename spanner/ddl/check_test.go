@@ -0,0 +1,115 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ddl
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func checkTestSchema() Schema {
+	s := NewSchema()
+	s["orders"] = CreateTable{
+		Name:     "orders",
+		ColNames: []string{"id", "note", "data", "tags", "total", "placed", "day", "ok", "doc", "ratio"},
+		ColDefs: map[string]ColumnDef{
+			"id":     {Name: "id", T: Type{Name: Int64}, NotNull: true, Comment: "From: id bigint"},
+			"note":   {Name: "note", T: Type{Name: String, Len: 100}},
+			"data":   {Name: "data", T: Type{Name: Bytes, Len: MaxLength}},
+			"tags":   {Name: "tags", T: Type{Name: String, Len: MaxLength, IsArray: true}},
+			"total":  {Name: "total", T: Type{Name: Numeric}},
+			"placed": {Name: "placed", T: Type{Name: Timestamp}},
+			"day":    {Name: "day", T: Type{Name: Date}},
+			"ok":     {Name: "ok", T: Type{Name: Bool}},
+			"doc":    {Name: "doc", T: Type{Name: JSON}},
+			"ratio":  {Name: "ratio", T: Type{Name: Float64, IsArray: true}},
+		},
+		Pks:     []IndexKey{{Col: "id", Desc: true}},
+		Indexes: []CreateIndex{{Name: "orders_by_day", Table: "orders", Unique: true, Keys: []IndexKey{{Col: "day"}, {Col: "id", Desc: true}}}},
+		Comment: "From: orders",
+	}
+	s["lines"] = CreateTable{
+		Name:     "lines",
+		ColNames: []string{"id", "line"},
+		ColDefs: map[string]ColumnDef{
+			"id":   {Name: "id", T: Type{Name: Int64}, NotNull: true},
+			"line": {Name: "line", T: Type{Name: Int64}, NotNull: true},
+		},
+		Pks:    []IndexKey{{Col: "id"}, {Col: "line"}},
+		Fks:    []Foreignkey{{Name: "lines_fk", Columns: []string{"id"}, ReferTable: "orders", ReferColumns: []string{"id"}}},
+		Parent: "orders",
+	}
+	return s
+}
+
+func TestCheckSyntax_Generated(t *testing.T) {
+	s := checkTestSchema()
+	for _, c := range []Config{
+		{Tables: true, ForeignKeys: true},
+		{Tables: true, ForeignKeys: true, ProtectIds: true, Comments: true},
+		{Tables: true, ForeignKeys: true, PostgreSQL: true},
+		{Tables: true, ForeignKeys: true, PostgreSQL: true, ProtectIds: true},
+	} {
+		for _, stmt := range s.GetDDL(c) {
+			assert.Nil(t, CheckSyntax(stmt, c.PostgreSQL), stmt)
+		}
+		cd := ColumnDef{Name: "note", T: Type{Name: String, Len: 200}, NotNull: true}
+		assert.Nil(t, CheckSyntax(cd.PrintAddColumn(c, "orders"), c.PostgreSQL))
+		seq := CreateSequence{Name: "orders_seq", StartWithCounter: 42}
+		assert.Nil(t, CheckSyntax(seq.PrintCreateSequence(c), c.PostgreSQL))
+	}
+	assert.Nil(t, CheckSyntax(ColumnDef{Name: "note", T: Type{Name: String, Len: 200}}.PrintAlterColumn(Config{}, "orders"), false))
+	for _, stmt := range []string{
+		"DROP TABLE orders;",
+		"CREATE NULL_FILTERED INDEX i ON t (a) STORING (b, c), INTERLEAVE IN p",
+		"CREATE TABLE t (a INT64 NOT NULL DEFAULT (1), b STRING(MAX) OPTIONS (allow_commit_timestamp = true), CONSTRAINT c CHECK (a > 0)) PRIMARY KEY (a), INTERLEAVE IN PARENT p ON DELETE CASCADE",
+		"ALTER TABLE t ADD CONSTRAINT fk FOREIGN KEY (a) REFERENCES u (b) ON DELETE NO ACTION",
+	} {
+		assert.Nil(t, CheckSyntax(stmt, false), stmt)
+	}
+}
+
+func TestCheckSyntax_Errors(t *testing.T) {
+	for _, tc := range []struct {
+		stmt       string
+		postgreSQL bool
+		col        string
+		msg        string
+	}{
+		{"CREATE TABLE t (a INT64, order STRING(10)) PRIMARY KEY (a)", false, "order", "order is a reserved word"},
+		{"CREATE TABLE t (a INT64, b STRING(0)) PRIMARY KEY (a)", false, "b", "invalid length 0 for STRING"},
+		{"CREATE TABLE t (a INT64, b BYTES(10485761)) PRIMARY KEY (a)", false, "b", "invalid length"},
+		{"CREATE TABLE t (a INT64, b VARCHAR(10)) PRIMARY KEY (a)", false, "b", "unknown type VARCHAR"},
+		{"CREATE TABLE t (a INT64, `b c` INT64) PRIMARY KEY (a)", false, "b c", "invalid name"},
+		{"CREATE TABLE t (a INT64, b ARRAY<INT64) PRIMARY KEY (a)", false, "b", "expected \">\""},
+		{"CREATE TABLE t (a INT64) PRIMARY KEY (a) extra", false, "", "unexpected \"extra\""},
+		{"CREATE TABLE t (a INT64)", false, "", "expected PRIMARY KEY"},
+		{"CREATE TABLE t (a bigint, b text)", true, "", "missing PRIMARY KEY"},
+		{"CREATE TABLE t (a bigint, b varchar(0), PRIMARY KEY (a))", true, "b", "invalid length"},
+		{"CREATE TABLE t (a bigint, table text, PRIMARY KEY (a))", true, "table", "reserved word"},
+		{"CREATE TABLE " + strings.Repeat("t", 129) + " (a INT64) PRIMARY KEY (a)", false, "", "longer than 128"},
+		{"CREATE TABLE `t (a INT64) PRIMARY KEY (a)", false, "", "unterminated"},
+		{"SELECT 1", false, "", "expected CREATE, ALTER or DROP"},
+	} {
+		err := CheckSyntax(tc.stmt, tc.postgreSQL)
+		se, ok := err.(*SyntaxError)
+		if assert.True(t, ok, tc.stmt) {
+			assert.Equal(t, tc.col, se.Column, tc.stmt)
+			assert.Contains(t, se.Msg, tc.msg, tc.stmt)
+		}
+	}
+}
@@ -182,7 +182,7 @@ func TestUpdateDDLForeignKeys(t *testing.T) {
 
 	for _, tc := range foreignKeyTests {
 		conv := BuildConv(t, tc.numCols, tc.numFks)
-		dbpath, err := conversion.CreateDatabase(projectID, instanceID, tc.dbName, conv, os.Stdout)
+		dbpath, err := conversion.CreateDatabase(projectID, instanceID, tc.dbName, "", conv, os.Stdout)
 		if err != nil {
 			t.Fatal(err)
 		}
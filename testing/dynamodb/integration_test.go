@@ -28,6 +28,7 @@ import (
 
 	"github.com/cloudspannerecosystem/harbourbridge/cmd"
 	"github.com/cloudspannerecosystem/harbourbridge/conversion"
+	"github.com/cloudspannerecosystem/harbourbridge/internal"
 	"github.com/google/go-cmp/cmp"
 	"github.com/stretchr/testify/assert"
 
@@ -216,7 +217,7 @@ func TestIntegration_DYNAMODB_SimpleUse(t *testing.T) {
 	dbPath := fmt.Sprintf("projects/%s/instances/%s/databases/%s", projectID, instanceID, dbName)
 	filePrefix := filepath.Join(tmpdir, dbName+".")
 
-	err := cmd.CommandLine(conversion.DYNAMODB, "spanner", projectID, instanceID, dbName, false, false, false, 0, "", &conversion.IOStreams{Out: os.Stdout}, filePrefix, now)
+	_, err := cmd.CommandLine(conversion.DYNAMODB, "spanner", nil, projectID, instanceID, dbName, false, false, false, false, false, false, false, 0, 0, 0, 0, "", "", "", "", nil, nil, internal.Remodel{}, internal.Policies{}, internal.NameOrder, nil, internal.Diagrams{}, conversion.ReportLayout{}, conversion.SpannerOptions{}, conversion.SourceOptions{}, nil, nil, &conversion.IOStreams{Out: os.Stdout}, conversion.Workspace{Prefix: filePrefix}, now)
 	if err != nil {
 		t.Fatal(err)
 	}
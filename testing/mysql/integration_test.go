@@ -27,6 +27,7 @@ import (
 
 	"github.com/cloudspannerecosystem/harbourbridge/cmd"
 	"github.com/cloudspannerecosystem/harbourbridge/conversion"
+	"github.com/cloudspannerecosystem/harbourbridge/internal"
 
 	"cloud.google.com/go/spanner"
 	database "cloud.google.com/go/spanner/admin/database/apiv1"
@@ -118,7 +119,7 @@ func TestIntegration_MYSQLDUMP_SimpleUse(t *testing.T) {
 	if err != nil {
 		t.Fatalf("failed to open the test data file: %v", err)
 	}
-	err = cmd.CommandLine(conversion.MYSQLDUMP, "spanner", projectID, instanceID, dbName, false, false, false, 0, "", &conversion.IOStreams{In: f, Out: os.Stdout}, filePrefix, now)
+	_, err = cmd.CommandLine(conversion.MYSQLDUMP, "spanner", nil, projectID, instanceID, dbName, false, false, false, false, false, false, false, 0, 0, 0, 0, "", "", "", "", nil, nil, internal.Remodel{}, internal.Policies{}, internal.NameOrder, nil, internal.Diagrams{}, conversion.ReportLayout{}, conversion.SpannerOptions{}, conversion.SourceOptions{}, nil, nil, &conversion.IOStreams{In: f, Out: os.Stdout}, conversion.Workspace{Prefix: filePrefix}, now)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -140,7 +141,7 @@ func TestIntegration_MYSQL_SimpleUse(t *testing.T) {
 	dbPath := fmt.Sprintf("projects/%s/instances/%s/databases/%s", projectID, instanceID, dbName)
 	filePrefix := filepath.Join(tmpdir, dbName+".")
 
-	err := cmd.CommandLine(conversion.MYSQL, "spanner", projectID, instanceID, dbName, false, false, false, 0, "", &conversion.IOStreams{Out: os.Stdout}, filePrefix, now)
+	_, err := cmd.CommandLine(conversion.MYSQL, "spanner", nil, projectID, instanceID, dbName, false, false, false, false, false, false, false, 0, 0, 0, 0, "", "", "", "", nil, nil, internal.Remodel{}, internal.Policies{}, internal.NameOrder, nil, internal.Diagrams{}, conversion.ReportLayout{}, conversion.SpannerOptions{}, conversion.SourceOptions{}, nil, nil, &conversion.IOStreams{Out: os.Stdout}, conversion.Workspace{Prefix: filePrefix}, now)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -33,6 +33,7 @@ import (
 	database "cloud.google.com/go/spanner/admin/database/apiv1"
 	"github.com/cloudspannerecosystem/harbourbridge/cmd"
 	"github.com/cloudspannerecosystem/harbourbridge/conversion"
+	"github.com/cloudspannerecosystem/harbourbridge/internal"
 	"google.golang.org/api/iterator"
 	databasepb "google.golang.org/genproto/googleapis/spanner/admin/database/v1"
 )
@@ -120,7 +121,7 @@ func TestIntegration_PGDUMP_SimpleUse(t *testing.T) {
 	if err != nil {
 		t.Fatalf("failed to open the test data file: %v", err)
 	}
-	err = cmd.CommandLine(conversion.PGDUMP, "spanner", projectID, instanceID, dbName, false, false, false, 0, "", &conversion.IOStreams{In: f, Out: os.Stdout}, filePrefix, now)
+	_, err = cmd.CommandLine(conversion.PGDUMP, "spanner", nil, projectID, instanceID, dbName, false, false, false, false, false, false, false, 0, 0, 0, 0, "", "", "", "", nil, nil, internal.Remodel{}, internal.Policies{}, internal.NameOrder, nil, internal.Diagrams{}, conversion.ReportLayout{}, conversion.SpannerOptions{}, conversion.SourceOptions{}, nil, nil, &conversion.IOStreams{In: f, Out: os.Stdout}, conversion.Workspace{Prefix: filePrefix}, now)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -177,7 +178,7 @@ func TestIntegration_POSTGRES_SimpleUse(t *testing.T) {
 	dbPath := fmt.Sprintf("projects/%s/instances/%s/databases/%s", projectID, instanceID, dbName)
 	filePrefix := filepath.Join(tmpdir, dbName+".")
 
-	err := cmd.CommandLine(conversion.POSTGRES, "spanner", projectID, instanceID, dbName, false, false, false, 0, "", &conversion.IOStreams{Out: os.Stdout}, filePrefix, now)
+	_, err := cmd.CommandLine(conversion.POSTGRES, "spanner", nil, projectID, instanceID, dbName, false, false, false, false, false, false, false, 0, 0, 0, 0, "", "", "", "", nil, nil, internal.Remodel{}, internal.Policies{}, internal.NameOrder, nil, internal.Diagrams{}, conversion.ReportLayout{}, conversion.SpannerOptions{}, conversion.SourceOptions{}, nil, nil, &conversion.IOStreams{Out: os.Stdout}, conversion.Workspace{Prefix: filePrefix}, now)
 	if err != nil {
 		t.Fatal(err)
 	}
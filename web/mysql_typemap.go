@@ -42,7 +42,7 @@ func toSpannerTypeMySQL(srcType string, spType string, mods []int64) (ddl.Type,
 		case ddl.Int64:
 			return ddl.Type{Name: ddl.Int64}, []internal.SchemaIssue{internal.Widened}
 		default:
-			return ddl.Type{Name: ddl.Bool}, nil
+			return ddl.Type{Name: ddl.Bool}, []internal.SchemaIssue{internal.TinyintBool}
 		}
 	case "tinyint":
 		switch spType {
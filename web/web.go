@@ -121,6 +121,7 @@ func convertSchemaSQL(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 	conv := internal.MakeConv()
+	conversion.DetectServerVersion(sessionState.driver, sessionState.sourceDB, conv, os.Stdout)
 	var err error
 	switch sessionState.driver {
 	case "mysql":
@@ -166,7 +167,7 @@ func convertSchemaDump(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, fmt.Sprintf("failed to open dump file %v : %v", dc.FilePath, err), http.StatusNotFound)
 		return
 	}
-	conv, err := conversion.SchemaConv(dc.Driver, conversion.TARGET_SPANNER, &conversion.IOStreams{In: f, Out: os.Stdout}, 0)
+	conv, err := conversion.SchemaConv(dc.Driver, conversion.TARGET_SPANNER, nil, &conversion.IOStreams{In: f, Out: os.Stdout}, 0, conversion.SourceOptions{})
 	if err != nil {
 		http.Error(w, fmt.Sprintf("Schema Conversion Error : %v", err), http.StatusNotFound)
 		return
@@ -409,7 +410,7 @@ func getSchemaFile(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, fmt.Sprintf("Can not get file prefix : %v", err), http.StatusInternalServerError)
 	}
 	schemaFileName := "frontend/" + filePrefix + "schema.txt"
-	conversion.WriteSchemaFile(sessionState.conv, now, schemaFileName, ioHelper.Out)
+	conversion.WriteSchemaFile(sessionState.conv, now, schemaFileName, conversion.DDLCommentsFull, ioHelper.Out)
 	schemaAbsPath, err := filepath.Abs(schemaFileName)
 	if err != nil {
 		http.Error(w, fmt.Sprintf("Can not create absolute path : %v", err), http.StatusInternalServerError)
@@ -428,7 +429,7 @@ func getReportFile(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, fmt.Sprintf("Can not get file prefix : %v", err), http.StatusInternalServerError)
 	}
 	reportFileName := "frontend/" + filePrefix + "report.txt"
-	conversion.Report(sessionState.driver, nil, ioHelper.BytesRead, "", sessionState.conv, reportFileName, ioHelper.Out)
+	conversion.Report(sessionState.driver, nil, ioHelper.BytesRead, "", sessionState.conv, reportFileName, conversion.ReportLayout{}, ioHelper.Out)
 	reportAbsPath, err := filepath.Abs(reportFileName)
 	if err != nil {
 		http.Error(w, fmt.Sprintf("Can not create absolute path : %v", err), http.StatusInternalServerError)
@@ -583,6 +584,7 @@ func renameForeignKeys(w http.ResponseWriter, r *http.Request) {
 	newFKs := []ddl.Foreignkey{}
 	for _, foreignKey := range sp.Fks {
 		if newName, ok := renameMap[foreignKey.Name]; ok {
+			sessionState.conv.RenameSpannerName(foreignKey.Name, newName)
 			foreignKey.Name = newName
 		}
 		newFKs = append(newFKs, foreignKey)
@@ -640,6 +642,7 @@ func renameIndexes(w http.ResponseWriter, r *http.Request) {
 	newIndexes := []ddl.CreateIndex{}
 	for _, index := range sp.Indexes {
 		if newName, ok := renameMap[index.Name]; ok {
+			sessionState.conv.RenameSpannerName(index.Name, newName)
 			index.Name = newName
 		}
 		newIndexes = append(newIndexes, index)
@@ -692,6 +695,9 @@ func addIndexes(w http.ResponseWriter, r *http.Request) {
 
 	sp := sessionState.conv.SpSchema[table]
 	sp.Indexes = append(sp.Indexes, newIndexes...)
+	for _, index := range newIndexes {
+		sessionState.conv.ReserveName(index.Name)
+	}
 
 	sessionState.conv.SpSchema[table] = sp
 	updateSessionFile()
@@ -769,6 +775,52 @@ func dropSecondaryIndex(w http.ResponseWriter, r *http.Request) {
 	json.NewEncoder(w).Encode(sessionState.conv)
 }
 
+// setPrimaryKeyIndex uses the columns of a unique index of a table as its
+// primary key (see internal.PrimaryKeyIndex).
+func setPrimaryKeyIndex(w http.ResponseWriter, r *http.Request) {
+	table := r.FormValue("table")
+	index := r.FormValue("index")
+	if sessionState.conv == nil || sessionState.driver == "" {
+		http.Error(w, fmt.Sprintf("Schema is not converted or Driver is not configured properly. Please retry converting the database to Spanner."), http.StatusNotFound)
+		return
+	}
+	if table == "" || index == "" {
+		http.Error(w, fmt.Sprintf("Table name or index name is empty"), http.StatusBadRequest)
+		return
+	}
+	remodel := internal.Remodel{PrimaryKeys: []internal.PrimaryKeyIndex{{Table: table, Index: index}}}
+	if err := sessionState.conv.ApplyRemodel(remodel); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	updateSessionFile()
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(sessionState.conv)
+}
+
+// reorderPrimaryKey reorders the primary key columns of a table, given as
+// a comma-separated list (see internal.KeyOrder).
+func reorderPrimaryKey(w http.ResponseWriter, r *http.Request) {
+	table := r.FormValue("table")
+	cols := r.FormValue("cols")
+	if sessionState.conv == nil || sessionState.driver == "" {
+		http.Error(w, fmt.Sprintf("Schema is not converted or Driver is not configured properly. Please retry converting the database to Spanner."), http.StatusNotFound)
+		return
+	}
+	if table == "" || cols == "" {
+		http.Error(w, fmt.Sprintf("Table name or columns are empty"), http.StatusBadRequest)
+		return
+	}
+	remodel := internal.Remodel{KeyOrders: []internal.KeyOrder{{Table: table, Cols: strings.Split(cols, ",")}}}
+	if err := sessionState.conv.ApplyRemodel(remodel); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	updateSessionFile()
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(sessionState.conv)
+}
+
 // updateSessionFile updates the content of session file with
 // latest sessionState.conv while also dumping schemas and report.
 func updateSessionFile() error {
@@ -964,8 +1016,14 @@ func removeColumn(table string, colName string, srcTableName string) {
 	srcColName := sessionState.conv.ToSource[table].Cols[colName]
 	delete(sessionState.conv.ToSource[table].Cols, colName)
 	delete(sessionState.conv.ToSpanner[srcTableName].Cols, srcColName)
-	delete(sessionState.conv.Issues[srcTableName], srcColName)
+	sessionState.conv.SetColumnIssues(srcTableName, srcColName, nil)
 	sessionState.conv.SpSchema[table] = sp
+	// Record the column as dropped, so that data conversion (which may
+	// use a session file) skips its data.
+	if sessionState.conv.DroppedCols == nil {
+		sessionState.conv.DroppedCols = make(map[string][]string)
+	}
+	sessionState.conv.DroppedCols[srcTableName] = append(sessionState.conv.DroppedCols[srcTableName], srcColName)
 }
 
 func renameColumn(newName, table, colName, srcTableName string) {
@@ -1043,7 +1101,7 @@ func getType(newType, table, colName string, srcTableName string) (ddl.CreateTab
 		issues = append(issues, internal.AutoIncrement)
 	}
 	if sessionState.conv.Issues != nil && len(issues) > 0 {
-		sessionState.conv.Issues[srcTableName][srcCol.Name] = issues
+		sessionState.conv.SetColumnIssues(srcTableName, srcCol.Name, issues)
 	}
 	ty.IsArray = len(srcCol.Type.ArrayBounds) == 1
 	return sp, ty, nil
@@ -293,6 +293,7 @@ func TestUpdateTableSchema(t *testing.T) {
 				ToSpanner: map[string]internal.NameAndCols{
 					"t1": internal.NameAndCols{Name: "t1", Cols: map[string]string{"a": "a", "b": "b"}},
 				},
+				DroppedCols: map[string][]string{"t1": []string{"c"}},
 			},
 		},
 		{
@@ -2222,6 +2223,97 @@ func TestDropSecondaryIndex(t *testing.T) {
 	}
 }
 
+func TestSetPrimaryKeyIndex(t *testing.T) {
+	newConv := func() *internal.Conv {
+		return &internal.Conv{
+			SpSchema: map[string]ddl.CreateTable{
+				"t1": ddl.CreateTable{
+					Name:     "t1",
+					ColNames: []string{"a", "b", "c"},
+					ColDefs: map[string]ddl.ColumnDef{
+						"a": ddl.ColumnDef{Name: "a", T: ddl.Type{Name: ddl.Int64}, NotNull: true},
+						"b": ddl.ColumnDef{Name: "b", T: ddl.Type{Name: ddl.String, Len: ddl.MaxLength}, NotNull: true},
+						"c": ddl.ColumnDef{Name: "c", T: ddl.Type{Name: ddl.String, Len: ddl.MaxLength}},
+					},
+					Pks: []ddl.IndexKey{ddl.IndexKey{Col: "a"}},
+					Indexes: []ddl.CreateIndex{ddl.CreateIndex{Name: "idx_b", Table: "t1", Unique: true, Keys: []ddl.IndexKey{ddl.IndexKey{Col: "b"}}},
+						ddl.CreateIndex{Name: "idx_c", Table: "t1", Unique: true, Keys: []ddl.IndexKey{ddl.IndexKey{Col: "c"}}}},
+				}},
+		}
+	}
+	tc := []struct {
+		name        string
+		table       string
+		index       string
+		statusCode  int64
+		expectedPks []ddl.IndexKey
+	}{
+		{name: "Test use unique index as primary key", table: "t1", index: "idx_b", statusCode: http.StatusOK, expectedPks: []ddl.IndexKey{ddl.IndexKey{Col: "b"}}},
+		{name: "Test use index on nullable column", table: "t1", index: "idx_c", statusCode: http.StatusBadRequest},
+		{name: "Test use unknown index", table: "t1", index: "idx_d", statusCode: http.StatusBadRequest},
+		{name: "Test use index without table", table: "", index: "idx_b", statusCode: http.StatusBadRequest},
+	}
+	for _, tc := range tc {
+		sessionState.driver = "mysql"
+		sessionState.conv = newConv()
+		req, err := http.NewRequest("GET", "/primarykey?table="+tc.table+"&index="+tc.index, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		rr := httptest.NewRecorder()
+		handler := http.HandlerFunc(setPrimaryKeyIndex)
+		handler.ServeHTTP(rr, req)
+		assert.Equal(t, tc.statusCode, int64(rr.Code), tc.name)
+		if tc.statusCode == http.StatusOK {
+			var res *internal.Conv
+			json.Unmarshal(rr.Body.Bytes(), &res)
+			assert.Equal(t, tc.expectedPks, res.SpSchema[tc.table].Pks, tc.name)
+		}
+	}
+}
+
+func TestReorderPrimaryKey(t *testing.T) {
+	tc := []struct {
+		name        string
+		cols        string
+		statusCode  int64
+		expectedPks []ddl.IndexKey
+	}{
+		{name: "Test reorder primary key", cols: "b,a", statusCode: http.StatusOK, expectedPks: []ddl.IndexKey{ddl.IndexKey{Col: "b"}, ddl.IndexKey{Col: "a"}}},
+		{name: "Test reorder with missing column", cols: "b", statusCode: http.StatusBadRequest},
+		{name: "Test reorder with non-key column", cols: "b,c", statusCode: http.StatusBadRequest},
+	}
+	for _, tc := range tc {
+		sessionState.driver = "mysql"
+		sessionState.conv = &internal.Conv{
+			SpSchema: map[string]ddl.CreateTable{
+				"t1": ddl.CreateTable{
+					Name:     "t1",
+					ColNames: []string{"a", "b", "c"},
+					ColDefs: map[string]ddl.ColumnDef{
+						"a": ddl.ColumnDef{Name: "a", T: ddl.Type{Name: ddl.Int64}, NotNull: true},
+						"b": ddl.ColumnDef{Name: "b", T: ddl.Type{Name: ddl.Int64}, NotNull: true},
+						"c": ddl.ColumnDef{Name: "c", T: ddl.Type{Name: ddl.Int64}},
+					},
+					Pks: []ddl.IndexKey{ddl.IndexKey{Col: "a"}, ddl.IndexKey{Col: "b"}},
+				}},
+		}
+		req, err := http.NewRequest("GET", "/primarykey/order?table=t1&cols="+tc.cols, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		rr := httptest.NewRecorder()
+		handler := http.HandlerFunc(reorderPrimaryKey)
+		handler.ServeHTTP(rr, req)
+		assert.Equal(t, tc.statusCode, int64(rr.Code), tc.name)
+		if tc.statusCode == http.StatusOK {
+			var res *internal.Conv
+			json.Unmarshal(rr.Body.Bytes(), &res)
+			assert.Equal(t, tc.expectedPks, res.SpSchema["t1"].Pks, tc.name)
+		}
+	}
+}
+
 func buildConvMySQL(conv *internal.Conv) {
 	conv.SrcSchema = map[string]schema.Table{
 		"t1": schema.Table{
@@ -2325,7 +2417,7 @@ func buildConvMySQL(conv *internal.Conv) {
 			"a": []internal.SchemaIssue{internal.Widened},
 		},
 	}
-	conv.SyntheticPKeys["t2"] = internal.SyntheticPKey{"synth_id", 0}
+	conv.SyntheticPKeys["t2"] = internal.SyntheticPKey{Col: "synth_id"}
 }
 
 func buildConvPostgres(conv *internal.Conv) {
@@ -2433,5 +2525,5 @@ func buildConvPostgres(conv *internal.Conv) {
 			"b": []internal.SchemaIssue{internal.Widened},
 		},
 	}
-	conv.SyntheticPKeys["t2"] = internal.SyntheticPKey{"synth_id", 0}
+	conv.SyntheticPKeys["t2"] = internal.SyntheticPKey{Col: "synth_id"}
 }